@@ -5,8 +5,12 @@ go 1.24.0
 toolchain go1.24.11
 
 require (
+	github.com/andybalholm/brotli v1.2.6
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/muesli/smartcrop v0.3.0
 	golang.org/x/crypto v0.32.0
+	golang.org/x/image v0.34.0
+	golang.org/x/sys v0.36.0
 	modernc.org/sqlite v1.41.0
 )
 
@@ -18,8 +22,6 @@ require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
-	golang.org/x/image v0.34.0 // indirect
-	golang.org/x/sys v0.36.0 // indirect
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
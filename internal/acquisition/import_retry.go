@@ -0,0 +1,88 @@
+package acquisition
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/download"
+)
+
+// importRetryInterval is how often imports waiting for an automatic retry
+// are looked at
+const importRetryInterval = time.Minute
+
+// importRetryLoop retries failed imports once their wait is over
+func (s *Service) importRetryLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(importRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.retryDueImports()
+		}
+	}
+}
+
+// retryDueImports retries the failed imports whose automatic retry is due,
+// one at a time
+func (s *Service) retryDueImports() {
+	downloads, err := s.monitoring.GetActiveDownloads()
+	if err != nil {
+		logger.Errorf("Import retry: failed to get active downloads: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, td := range downloads {
+		if !td.ImportRetryDue(now) {
+			continue
+		}
+		select {
+		case <-s.stopCh:
+			return
+		default:
+		}
+		s.retryImport(td)
+	}
+}
+
+// retryImport retries a failed import, resuming it from its first
+// unfinished step if files were already moved
+func (s *Service) retryImport(td *download.TrackedDownload) {
+	logger.Infof("Retrying import for %s (attempt %d)", td.Title, td.ImportRetry.Attempts)
+
+	td.ImportBlockReason = ""
+	td.CancelImportRetry()
+	if err := s.monitoring.UpdateTrackedDownload(td); err != nil {
+		logger.Errorf("Import retry: failed to update %s: %v", td.Title, err)
+		return
+	}
+	if err := s.monitoring.SaveImportSteps(td); err != nil {
+		logger.Errorf("Import retry: failed to update %s: %v", td.Title, err)
+		return
+	}
+
+	if td.ImportStarted() {
+		s.resumeImport(td)
+	} else {
+		s.handleReadyForImport(td)
+	}
+}
+
+// importRetryAttempts returns how many times a failed import is retried
+// automatically before it's left for a manual retry
+func (s *Service) importRetryAttempts() int {
+	value, err := s.db.GetSetting("import_retry_max_attempts")
+	if err != nil || value == "" {
+		return download.DefaultImportRetryAttempts
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 0 {
+		return download.DefaultImportRetryAttempts
+	}
+	return attempts
+}
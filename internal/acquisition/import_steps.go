@@ -0,0 +1,373 @@
+package acquisition
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/download"
+	importpkg "github.com/outpost/outpost/internal/import"
+)
+
+// progressSaveInterval limits how often transfer progress is saved
+const progressSaveInterval = time.Second
+
+// copyBufferSize is the chunk size for copies across filesystems
+const copyBufferSize = 1 << 20
+
+// importRun runs the steps of a download's import, saving them as they go
+type importRun struct {
+	s     *Service
+	td    *download.TrackedDownload
+	perms *importpkg.Permissions
+	saved time.Time
+}
+
+// importRunFor runs the import steps of a download with the configured file
+// permissions
+func (s *Service) importRunFor(td *download.TrackedDownload) *importRun {
+	run := &importRun{s: s, td: td}
+	settings, err := s.db.GetAllSettings()
+	if err == nil {
+		run.perms, err = importpkg.LoadPermissions(settings)
+	}
+	if err != nil {
+		run.perms = &importpkg.Permissions{UID: -1, GID: -1}
+		run.permissionFailed(td.DownloadPath, "", err)
+	}
+	return run
+}
+
+// newImportRun starts a fresh import of a download, clearing the steps and
+// error of any earlier attempt
+func (s *Service) newImportRun(td *download.TrackedDownload) *importRun {
+	td.ImportSteps = nil
+	td.ImportError = nil
+	run := s.importRunFor(td)
+	run.save()
+	return run
+}
+
+func (r *importRun) save() {
+	r.saved = time.Now()
+	if err := r.s.monitoring.SaveImportSteps(r.td); err != nil {
+		logger.Errorf("Failed to save import steps for %s: %v", r.td.Title, err)
+	}
+}
+
+// run runs one step, returning why it failed
+func (r *importRun) run(step *download.ImportStep, fn func() error) error {
+	step.Start()
+	r.save()
+	err := fn()
+	step.Finish(err)
+	r.save()
+	if err != nil {
+		logger.Errorf("Import step %s failed for %s: %v", step.Kind, r.td.Title, err)
+		return step.Error
+	}
+	return nil
+}
+
+// runStep runs a planned step
+func (r *importRun) runStep(step *download.ImportStep) error {
+	switch step.Kind {
+	case download.ImportStepTransfer, download.ImportStepSubtitle:
+		return r.run(step, func() error {
+			return r.transfer(step)
+		})
+	case download.ImportStepStage:
+		return r.run(step, func() error {
+			return r.stage(step)
+		})
+	case download.ImportStepMetadata:
+		return r.run(step, func() error {
+			return r.s.recordImport(r.td, step.Source, step.Dest)
+		})
+	}
+	// Renames are worked out as the rest of the import is planned
+	return nil
+}
+
+// runSteps runs every step that isn't done yet, stopping at the first
+// required one that fails. The download is cleaned up once every step is done.
+func (r *importRun) runSteps() error {
+	for _, step := range r.td.ImportSteps {
+		if step.Status == download.ImportStepDone {
+			continue
+		}
+		if err := r.runStep(step); err != nil && !step.Optional {
+			return err
+		}
+	}
+	if !r.td.HasFailedImportSteps() {
+		r.s.cleanupSource(r.td.DownloadPath)
+		r.cleanupStaging()
+	}
+	return nil
+}
+
+// stage moves a step's file to the staging folder, unless an earlier attempt
+// already did, and checks it there. A file that fails a check is left there.
+func (r *importRun) stage(step *download.ImportStep) error {
+	if _, err := os.Stat(step.Source); err == nil {
+		if err := r.transfer(step); err != nil {
+			return err
+		}
+	} else if _, destErr := os.Stat(step.Dest); destErr != nil {
+		return err
+	}
+
+	checks := importpkg.StagingChecks{}
+	if formats, err := r.s.db.GetFormatSettings(); err == nil {
+		checks.Containers = formats.AcceptedContainers
+		checks.VideoCodecs = formats.AcceptedVideoCodecs
+	}
+	if scan, _ := r.s.db.GetSetting(importpkg.SettingVirusScan); scan == "true" {
+		checks.VirusScan = true
+	}
+
+	err := importpkg.ValidateStagedFile(step.Dest, checks)
+	var invalid *importpkg.ValidationError
+	if errors.As(err, &invalid) {
+		logger.Warnf("Quarantined %s for %s: %s", step.Dest, r.td.Title, invalid.Message)
+		return &download.ImportFailure{
+			Code:    download.ImportErrValidation,
+			Message: "Failed validation: " + invalid.Message,
+			Path:    step.Dest,
+		}
+	}
+	return err
+}
+
+// cleanupStaging removes the staging folders of a finished import
+func (r *importRun) cleanupStaging() {
+	for _, step := range r.td.ImportSteps {
+		if step.Kind == download.ImportStepStage {
+			os.Remove(filepath.Dir(step.Dest))
+		}
+	}
+}
+
+// progress records a transfer's progress, saving it now and then
+func (r *importRun) progress(step *download.ImportStep, done, total int64) {
+	step.SetProgress(done, total)
+	if time.Since(r.saved) >= progressSaveInterval {
+		r.save()
+	}
+}
+
+// importPath is where a stepped import put the media: the destination of
+// its metadata step
+func importPath(td *download.TrackedDownload) string {
+	for _, step := range td.ImportSteps {
+		if step.Kind == download.ImportStepMetadata {
+			return step.Dest
+		}
+	}
+	return ""
+}
+
+// recordImport records an import in the history and, for movies and shows,
+// the quality the media now has
+func (s *Service) recordImport(td *download.TrackedDownload, sourcePath, destPath string) error {
+	if err := s.db.CreateImportHistory(&database.ImportHistory{
+		SourcePath: sourcePath,
+		DestPath:   destPath,
+		MediaID:    td.MediaID,
+		MediaType:  &td.MediaType,
+		Success:    true,
+	}); err != nil {
+		return err
+	}
+	if td.MediaID != nil && td.MediaType != "music" && td.MediaType != "book" {
+		s.updateQualityStatus(*td.MediaID, td.MediaType, td.ParsedInfo)
+	}
+	return nil
+}
+
+// importFailure describes why an import failed. Files that can't be matched
+// or are rejected by the import decisions are parse failures.
+func importFailure(err error) *download.ImportFailure {
+	var importErr *importpkg.ImportError
+	if errors.As(err, &importErr) {
+		return download.ParseFailure(importErr.Message)
+	}
+	return download.NewImportFailure(err, "")
+}
+
+// RetryImportStep retries a failed import step. A blocked import resumes
+// from the step, or starts again if no file was moved yet; for a finished
+// import only the step itself runs again.
+func (s *Service) RetryImportStep(td *download.TrackedDownload, index int) error {
+	step := td.ImportSteps[index]
+	if step.Status != download.ImportStepFailed {
+		return download.ErrImportStepNotFailed
+	}
+
+	switch td.State {
+	case download.StateImportBlocked:
+		if !td.ImportStarted() {
+			return s.ManualImport(td, nil, "")
+		}
+		td.ImportBlockReason = ""
+		td.CancelImportRetry()
+		if err := s.monitoring.UpdateTrackedDownload(td); err != nil {
+			return err
+		}
+		if err := s.monitoring.SaveImportSteps(td); err != nil {
+			return err
+		}
+		go s.resumeImport(td)
+	case download.StateImported:
+		go func() {
+			run := s.importRunFor(td)
+			run.runStep(step)
+			if !td.HasFailedImportSteps() {
+				s.cleanupSource(td.DownloadPath)
+			}
+		}()
+	default:
+		return download.ErrNotAwaitingImport
+	}
+	return nil
+}
+
+// resumeImport carries on a blocked import from its first unfinished step
+func (s *Service) resumeImport(td *download.TrackedDownload) {
+	logger.Infof("Resuming import for: %s", td.Title)
+
+	if err := s.monitoring.MarkImporting(td); err != nil {
+		logger.Errorf("Error marking as importing: %v", err)
+		return
+	}
+	td.ImportError = nil
+	run := s.importRunFor(td)
+	if err := run.runSteps(); err != nil {
+		logger.Errorf("Import failed for %s: %v", td.Title, err)
+		s.handleImportFailure(td, err)
+		return
+	}
+	s.finishImport(td, importPath(td))
+}
+
+// transfer moves a step's file into place, renaming it when it stays on the
+// same filesystem and copying it when it doesn't, then gives it the
+// configured permissions
+func (r *importRun) transfer(step *download.ImportStep) error {
+	if err := r.mkdirAll(filepath.Dir(step.Dest), step.Source); err != nil {
+		return err
+	}
+
+	step.Method = download.TransferMove
+	err := os.Rename(step.Source, step.Dest)
+	if errors.Is(err, syscall.EXDEV) {
+		step.Method = download.TransferCopy
+		err = copyFile(step.Source, step.Dest, func(done, total int64) {
+			r.progress(step, done, total)
+		})
+		if err == nil {
+			err = os.Remove(step.Source)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := r.perms.ApplyFile(step.Dest); err != nil {
+		r.permissionFailed(step.Source, step.Dest, err)
+	}
+	return nil
+}
+
+// mkdirAll creates a folder for a file being imported from source, giving
+// the folders it creates the configured permissions
+func (r *importRun) mkdirAll(dir, source string) error {
+	created, err := r.perms.MkdirAll(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range created {
+		if err := r.perms.ApplyFolder(path); err != nil {
+			r.permissionFailed(source, path, err)
+		}
+	}
+	return nil
+}
+
+// permissionFailed records in the import history that the configured
+// permissions couldn't be given to an imported file or folder. The import
+// itself carries on.
+func (r *importRun) permissionFailed(source, path string, err error) {
+	logger.Warnf("Couldn't set permissions on %s for %s: %v", path, r.td.Title, err)
+	if err := r.s.db.CreateImportHistory(&database.ImportHistory{
+		SourcePath: source,
+		DestPath:   path,
+		MediaID:    r.td.MediaID,
+		MediaType:  &r.td.MediaType,
+		Success:    false,
+		Error:      strPtr("Couldn't set permissions: " + err.Error()),
+	}); err != nil {
+		logger.Errorf("Failed to record permission failure for %s: %v", r.td.Title, err)
+	}
+}
+
+// copyFile copies src to dst through a temporary file, so a failed copy
+// never leaves a partial file in the library
+func copyFile(src, dst string, progress func(done, total int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	tmp := dst + ".partial"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	var done int64
+	buf := make([]byte, copyBufferSize)
+	for {
+		n, readErr := in.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				out.Close()
+				os.Remove(tmp)
+				return err
+			}
+			done += int64(n)
+			progress(done, total)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			os.Remove(tmp)
+			return readErr
+		}
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,244 @@
+package acquisition
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/download"
+	importpkg "github.com/outpost/outpost/internal/import"
+)
+
+var (
+	releaseYearPattern   = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	releaseParenPattern  = regexp.MustCompile(`\([^)]*\)`)
+	releaseTagPattern    = regexp.MustCompile(`\[[^\]]*\]|\{[^}]*\}`)
+	trackFilenamePattern = regexp.MustCompile(`^(?:(\d)[\-\.])?(\d{1,3})[\s\.\-_]+(.+)$`)
+	invalidPathChars     = regexp.MustCompile(`[/\\:*?"<>|]`)
+)
+
+// albumArtNames are image files moved alongside imported tracks
+var albumArtNames = map[string]bool{
+	"cover.jpg": true, "cover.png": true, "folder.jpg": true, "folder.png": true,
+	"front.jpg": true, "front.png": true,
+}
+
+// musicTrack is an audio file with the metadata used to name it
+type musicTrack struct {
+	path   string
+	artist string
+	album  string
+	title  string
+	track  int
+	disc   int
+	year   int
+}
+
+// planMusicImport plans moving every track of an album download into the
+// music library as Artist/Album (Year)/NN - Title.ext. Names come from the file
+// tags where present, falling back to the release and file names.
+func (s *Service) planMusicImport(td *download.TrackedDownload, sourcePath string, decisions []importpkg.FileDecision, library *database.Library) (string, error) {
+	files := s.decisions.GetApprovedFiles(decisions)
+	if len(files) == 0 {
+		return "", &importpkg.ImportError{Message: "No valid audio files found"}
+	}
+
+	releaseArtist, releaseAlbum, releaseYear := parseMusicRelease(td.Title)
+
+	tracks := make([]musicTrack, 0, len(files))
+	multiDisc := false
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file.FilePath), filepath.Ext(file.FilePath))
+		disc, num, title := parseTrackName(name)
+		// Scene track names repeat the artist: "01-daft_punk-one_more_time"
+		title = strings.ReplaceAll(title, "_", " ")
+		if n := len(releaseArtist); n > 0 && len(title) > n && strings.EqualFold(title[:n], releaseArtist) {
+			title = strings.TrimLeft(title[n:], " -")
+		}
+		t := musicTrack{
+			path:   file.FilePath,
+			artist: releaseArtist,
+			album:  releaseAlbum,
+			title:  title,
+			track:  num,
+			disc:   disc,
+			year:   releaseYear,
+		}
+
+		tags, err := importpkg.ReadAudioTags(file.FilePath)
+		if err != nil {
+			logger.Errorf("Failed to read tags from %s: %v", file.FilePath, err)
+		}
+		if tags != nil {
+			if tags.AlbumArtist != "" {
+				t.artist = tags.AlbumArtist
+			} else if tags.Artist != "" {
+				t.artist = tags.Artist
+			}
+			if tags.Album != "" {
+				t.album = tags.Album
+			}
+			if tags.Title != "" {
+				t.title = tags.Title
+			}
+			if tags.Track > 0 {
+				t.track = tags.Track
+			}
+			if tags.Disc > 0 {
+				t.disc = tags.Disc
+			}
+			if tags.Year > 0 {
+				t.year = tags.Year
+			}
+		}
+		if t.disc > 1 {
+			multiDisc = true
+		}
+		tracks = append(tracks, t)
+	}
+
+	// Every track goes in one album folder, named from the first tagged track
+	first := tracks[0]
+	artist := cleanPathPart(first.artist, "Unknown Artist")
+	album := cleanPathPart(first.album, "Unknown Album")
+	if first.year > 0 {
+		album += " (" + strconv.Itoa(first.year) + ")"
+	}
+	albumDir := filepath.Join(library.Path, artist, album)
+
+	for _, t := range tracks {
+		dir := albumDir
+		if multiDisc {
+			disc := t.disc
+			if disc == 0 {
+				disc = 1
+			}
+			dir = filepath.Join(albumDir, "Disc "+strconv.Itoa(disc))
+		}
+		name := cleanPathPart(t.title, "Track")
+		if t.track > 0 {
+			name = padZero(t.track) + " - " + name
+		}
+		dest := filepath.Join(dir, name+strings.ToLower(filepath.Ext(t.path)))
+		td.AddImportStep(download.ImportStepTransfer, t.path, dest)
+	}
+
+	planAlbumArt(td, sourcePath, albumDir)
+	return albumDir, nil
+}
+
+// planBookImport plans moving ebook and audiobook files into the books library
+// as Author/Author - Title.ext, the layout the library scanner reads back
+func (s *Service) planBookImport(td *download.TrackedDownload, decisions []importpkg.FileDecision, library *database.Library) (string, error) {
+	files := s.decisions.GetApprovedFiles(decisions)
+	if len(files) == 0 {
+		return "", &importpkg.ImportError{Message: "No valid book files found"}
+	}
+
+	releaseAuthor, releaseTitle := parseBookRelease(td.Title)
+
+	var destPath string
+	for _, file := range files {
+		author, title := releaseAuthor, releaseTitle
+		// A single file is usually named better than the release
+		if len(files) == 1 || title == "" {
+			name := strings.TrimSuffix(filepath.Base(file.FilePath), filepath.Ext(file.FilePath))
+			if fileAuthor, fileTitle := parseBookRelease(name); fileAuthor != "" {
+				author, title = fileAuthor, fileTitle
+			} else if title == "" {
+				title = fileTitle
+			}
+		}
+
+		author = cleanPathPart(author, "Unknown Author")
+		title = cleanPathPart(title, "Unknown Title")
+		dest := filepath.Join(library.Path, author, author+" - "+title+strings.ToLower(filepath.Ext(file.FilePath)))
+		td.AddImportStep(download.ImportStepTransfer, file.FilePath, dest)
+		if destPath == "" {
+			destPath = dest
+		}
+	}
+
+	return destPath, nil
+}
+
+// parseMusicRelease extracts the artist, album and year from release names
+// like "Artist - Album (2020) [FLAC]" or "Artist-Album-WEB-2020-GROUP"
+func parseMusicRelease(name string) (artist, album string, year int) {
+	name = releaseTagPattern.ReplaceAllString(name, " ")
+	if m := releaseYearPattern.FindStringSubmatch(name); m != nil {
+		year, _ = strconv.Atoi(m[1])
+	}
+
+	var parts []string
+	if strings.Contains(name, " - ") {
+		parts = strings.Split(name, " - ")
+	} else {
+		parts = strings.Split(strings.NewReplacer(".", " ", "_", " ").Replace(name), "-")
+	}
+	if len(parts) < 2 {
+		return "", cleanReleasePart(name), year
+	}
+	return cleanReleasePart(parts[0]), cleanReleasePart(parts[1]), year
+}
+
+// parseBookRelease extracts the author and title from names like
+// "Author - Title (2020) [epub]" or "Title by Author"
+func parseBookRelease(name string) (author, title string) {
+	name = releaseTagPattern.ReplaceAllString(name, " ")
+	if author, title, ok := strings.Cut(name, " - "); ok {
+		return cleanReleasePart(author), cleanReleasePart(title)
+	}
+	if i := strings.LastIndex(strings.ToLower(name), " by "); i > 0 {
+		return cleanReleasePart(name[i+4:]), cleanReleasePart(name[:i])
+	}
+	return "", cleanReleasePart(name)
+}
+
+// parseTrackName splits track filenames like "01 - Title" or "2-05 Title"
+// into disc, track number and title
+func parseTrackName(name string) (disc, track int, title string) {
+	if m := trackFilenamePattern.FindStringSubmatch(name); m != nil {
+		disc, _ = strconv.Atoi(m[1])
+		track, _ = strconv.Atoi(m[2])
+		return disc, track, strings.TrimSpace(m[3])
+	}
+	return 0, 0, name
+}
+
+// cleanReleasePart strips parenthesised details and separators from a piece
+// of a release name
+func cleanReleasePart(s string) string {
+	s = releaseParenPattern.ReplaceAllString(s, "")
+	return strings.Trim(strings.Join(strings.Fields(s), " "), " -.")
+}
+
+// cleanPathPart makes a name safe to use as a file or folder name
+func cleanPathPart(s, fallback string) string {
+	s = strings.TrimSpace(invalidPathChars.ReplaceAllString(s, ""))
+	s = strings.TrimRight(s, ".")
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// planAlbumArt plans moving cover images from the download into the album
+// folder
+func planAlbumArt(td *download.TrackedDownload, sourcePath, albumDir string) {
+	filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		name := strings.ToLower(info.Name())
+		if albumArtNames[name] {
+			if _, err := os.Stat(filepath.Join(albumDir, name)); os.IsNotExist(err) {
+				td.AddImportStep(download.ImportStepTransfer, path, filepath.Join(albumDir, name)).Optional = true
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,278 @@
+package acquisition
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/download"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+const (
+	// etaRefreshInterval is how often request ETAs are recomputed
+	etaRefreshInterval = time.Minute
+	// theatricalWindow is how long after a theatrical release a movie with no
+	// known digital date is assumed to still be theaters-only
+	theatricalWindow = 120 * 24 * time.Hour
+)
+
+// ReleaseDateLookup fetches a movie's US theatrical and digital release dates
+type ReleaseDateLookup interface {
+	GetMovieReleaseDates(tmdbID int64) (theatrical, digital string, err error)
+}
+
+// etaStages ranks download states by how much they hold a request up; a
+// request with several downloads reports the one furthest behind
+var etaStages = map[download.DownloadState]struct {
+	status string
+	rank   int
+}{
+	download.StateCompleted:     {"importing", 1},
+	download.StateImportPending: {"importing", 1},
+	download.StateImporting:     {"importing", 1},
+	download.StateDownloading:   {"downloading", 2},
+	download.StateQueued:        {"queued", 3},
+	download.StatePaused:        {"paused", 4},
+	download.StateStalled:       {"stalled", 5},
+	download.StateImportBlocked: {"blocked", 6},
+}
+
+// SetReleaseDateLookup sets where release dates of requested movies come from
+func (s *Service) SetReleaseDateLookup(lookup ReleaseDateLookup) {
+	s.releaseDates = lookup
+}
+
+// etaLoop keeps request ETAs up to date while the service runs
+func (s *Service) etaLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(etaRefreshInterval)
+	defer ticker.Stop()
+
+	s.RefreshRequestETAs()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.RefreshRequestETAs()
+		}
+	}
+}
+
+// RefreshRequestETAs recomputes the fulfillment estimate of every approved or
+// processing request from its release date, active downloads and import state
+func (s *Service) RefreshRequestETAs() {
+	s.etaMu.Lock()
+	defer s.etaMu.Unlock()
+
+	downloads, err := s.monitoring.GetActiveDownloads()
+	if err != nil {
+		logger.Errorf("Request ETA: failed to get active downloads: %v", err)
+		return
+	}
+
+	for _, status := range []string{"approved", "processing"} {
+		requests, err := s.db.GetRequestsByStatus(status)
+		if err != nil {
+			logger.Errorf("Request ETA: failed to get %s requests: %v", status, err)
+			continue
+		}
+		for i := range requests {
+			eta := s.estimateRequest(&requests[i], downloads)
+			if err := s.db.UpdateRequestETA(requests[i].ID, eta); err != nil {
+				logger.Errorf("Request ETA: failed to update request %d: %v", requests[i].ID, err)
+			}
+		}
+	}
+
+	if err := s.db.ClearInactiveRequestETAs(); err != nil {
+		logger.Errorf("Request ETA: failed to clear finished requests: %v", err)
+	}
+}
+
+// estimateRequest works out where a request is: downloading or importing if
+// it has active downloads, otherwise waiting on a release or searching
+func (s *Service) estimateRequest(req *database.Request, downloads []*download.TrackedDownload) database.RequestETA {
+	var linked []*download.TrackedDownload
+	for _, td := range downloads {
+		if td.State == download.StateFailed {
+			continue
+		}
+		if (td.RequestID != nil && *td.RequestID == req.ID) ||
+			(td.MediaID != nil && *td.MediaID == req.TmdbID && td.MediaType == req.Type) {
+			linked = append(linked, td)
+		}
+	}
+	if len(linked) > 0 {
+		return downloadETA(linked)
+	}
+
+	// Imported without the download being linked back to the request
+	if s.inLibrary(req) {
+		return database.RequestETA{Status: "available", Message: "Imported into the library"}
+	}
+
+	if req.Type == "movie" {
+		if eta := s.releaseETA(req.TmdbID); eta != nil {
+			return *eta
+		}
+	}
+
+	return database.RequestETA{Status: "searching", Message: "Released - searching for a download"}
+}
+
+// downloadETA combines the progress of a request's downloads
+func downloadETA(downloads []*download.TrackedDownload) database.RequestETA {
+	var size, downloaded int64
+	var remaining time.Duration
+	var reason string
+	rank := 0
+	status := "importing"
+
+	for _, td := range downloads {
+		size += td.Size
+		downloaded += td.Downloaded
+
+		left := td.ETA
+		if left <= 0 && td.Speed > 0 && td.Size > td.Downloaded {
+			left = time.Duration((td.Size-td.Downloaded)/td.Speed) * time.Second
+		}
+		if left > remaining {
+			remaining = left
+		}
+
+		if stage, ok := etaStages[td.State]; ok && stage.rank > rank {
+			rank, status = stage.rank, stage.status
+			reason = td.ImportBlockReason
+		}
+	}
+
+	eta := database.RequestETA{Status: status}
+	progress := 100.0
+	if size > 0 {
+		progress = float64(downloaded) * 100 / float64(size)
+		if progress > 100 {
+			progress = 100
+		}
+	}
+	eta.Progress = &progress
+
+	switch status {
+	case "importing":
+		eta.Message = "Downloaded - importing into the library"
+	case "downloading":
+		eta.Message = fmt.Sprintf("Downloading - %.0f%% done", progress)
+		if remaining > 0 {
+			at := time.Now().Add(remaining)
+			eta.At = &at
+			eta.Message += ", about " + formatRemaining(remaining) + " left"
+		}
+	case "queued":
+		eta.Message = "Queued in the download client"
+	case "paused":
+		eta.Message = fmt.Sprintf("Download paused at %.0f%%", progress)
+	case "stalled":
+		eta.Message = fmt.Sprintf("Download stalled at %.0f%% - looking for a better source", progress)
+	case "blocked":
+		eta.Message = "Downloaded, but the import needs attention"
+		if reason != "" {
+			eta.Message += ": " + reason
+		}
+	}
+	return eta
+}
+
+// releaseETA reports when a movie that isn't out digitally yet will be, or
+// nil if it's already released (or nothing is known)
+func (s *Service) releaseETA(tmdbID int64) *database.RequestETA {
+	theatrical, digital := s.movieReleaseDates(tmdbID)
+	now := time.Now()
+
+	if date, ok := parseReleaseDate(digital); ok {
+		if date.After(now) {
+			return &database.RequestETA{
+				Status:  "unreleased",
+				Message: "Not released digitally until " + date.Format("Jan 2, 2006"),
+				At:      &date,
+			}
+		}
+		return nil
+	}
+
+	date, ok := parseReleaseDate(theatrical)
+	switch {
+	case !ok:
+		return nil
+	case date.After(now):
+		return &database.RequestETA{
+			Status:  "unreleased",
+			Message: "In theaters " + date.Format("Jan 2, 2006") + " - no digital release date yet",
+		}
+	case now.Sub(date) < theatricalWindow:
+		return &database.RequestETA{
+			Status:  "unreleased",
+			Message: "In theaters since " + date.Format("Jan 2, 2006") + " - no digital release date yet",
+		}
+	}
+	return nil
+}
+
+// inLibrary reports whether a request's media has been imported: the movie,
+// in the edition asked for if any, is in the library, or the show is and
+// nothing is still wanted for it
+func (s *Service) inLibrary(req *database.Request) bool {
+	if req.Type == "movie" && req.Edition != nil {
+		has, err := s.db.HasMovieEdition(req.TmdbID, *req.Edition)
+		return err == nil && has
+	}
+	if req.Type == "movie" {
+		movie, err := s.db.GetMovieByTmdb(req.TmdbID)
+		return err == nil && movie != nil
+	}
+	if show, err := s.db.GetShowByTmdb(req.TmdbID); err != nil || show == nil {
+		return false
+	}
+	_, err := s.db.GetWantedByTmdb(req.Type, req.TmdbID)
+	return err != nil
+}
+
+// movieReleaseDates returns the release dates of a requested movie
+func (s *Service) movieReleaseDates(tmdbID int64) (theatrical, digital string) {
+	if s.releaseDates == nil {
+		return "", ""
+	}
+	theatrical, digital, err := s.releaseDates.GetMovieReleaseDates(tmdbID)
+	if err != nil {
+		logger.Errorf("Request ETA: failed to look up release dates for tmdb=%d: %v", tmdbID, err)
+	}
+	return theatrical, digital
+}
+
+// parseReleaseDate parses TMDB release dates ("2024-03-01T00:00:00.000Z")
+// as midnight in the server's time zone
+func parseReleaseDate(value string) (time.Time, bool) {
+	if len(value) < 10 {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation("2006-01-02", value[:10], timezone.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// formatRemaining formats a download's remaining time for ETA messages
+func formatRemaining(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "a minute"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+}
@@ -2,8 +2,8 @@ package acquisition
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -15,17 +15,21 @@ import (
 	"github.com/outpost/outpost/internal/download"
 	"github.com/outpost/outpost/internal/downloadclient"
 	"github.com/outpost/outpost/internal/indexer"
+	"github.com/outpost/outpost/internal/logging"
 	importpkg "github.com/outpost/outpost/internal/import"
 	"github.com/outpost/outpost/internal/parser"
 	"github.com/outpost/outpost/internal/quality"
 	"github.com/outpost/outpost/internal/request"
 )
 
+var logger = logging.Module("acquisition")
+
 // NotificationHandler is called when notifications should be sent
 type NotificationHandler interface {
 	NotifyDownloadComplete(title string, mediaType string, mediaID int64, posterPath *string) error
 	NotifyDownloadFailed(title string, errorMsg string, posterPath *string) error
 	NotifyNewContent(userID int64, title, mediaType string, mediaID int64, posterPath *string) error
+	NotifyDownloadSwapped(oldTitle, newTitle, reason string, posterPath *string) error
 }
 
 // Service orchestrates the download lifecycle using TrackedDownload
@@ -46,6 +50,10 @@ type Service struct {
 
 	notifications NotificationHandler
 
+	// Request ETAs
+	releaseDates ReleaseDateLookup
+	etaMu        sync.Mutex
+
 	stopCh  chan struct{}
 	wg      sync.WaitGroup
 	running bool
@@ -107,6 +115,7 @@ func NewService(db *database.Database, rawDB *sql.DB, clients *downloadclient.Ma
 	// Wire up callbacks
 	monitoring.OnReadyForImport = svc.handleReadyForImport
 	monitoring.OnReadyToRemove = svc.handleReadyToRemove
+	monitoring.OnStalled = svc.handleStalled
 
 	return svc
 }
@@ -124,10 +133,18 @@ func (s *Service) Start() {
 		return
 	}
 	s.running = true
+	s.stopCh = make(chan struct{})
 	s.mu.Unlock()
 
 	s.monitoring.Start()
-	log.Println("Acquisition service started (using TrackedDownload)")
+
+	s.wg.Add(1)
+	go s.etaLoop()
+
+	s.wg.Add(1)
+	go s.importRetryLoop()
+
+	logger.Infof("Acquisition service started (using TrackedDownload)")
 }
 
 // Stop stops the service
@@ -138,19 +155,22 @@ func (s *Service) Stop() {
 		return
 	}
 	s.running = false
+	close(s.stopCh)
 	s.mu.Unlock()
 
 	s.monitoring.Stop()
-	log.Println("Acquisition service stopped")
+	s.wg.Wait()
+
+	logger.Infof("Acquisition service stopped")
 }
 
 // handleReadyForImport is called when a download completes and is ready for import
 func (s *Service) handleReadyForImport(td *download.TrackedDownload) {
-	log.Printf("Processing import for: %s", td.Title)
+	logger.Infof("Processing import for: %s", td.Title)
 
 	// Mark as importing
 	if err := s.monitoring.MarkImporting(td); err != nil {
-		log.Printf("Error marking as importing: %v", err)
+		logger.Errorf("Error marking as importing: %v", err)
 		return
 	}
 
@@ -158,18 +178,25 @@ func (s *Service) handleReadyForImport(td *download.TrackedDownload) {
 	if td.RequestID != nil {
 		s.requests.MarkProcessing(*td.RequestID)
 	}
+	go s.RefreshRequestETAs()
 
 	// Run import
-	importPath, err := s.runImport(td)
+	importPath, err := s.runImport(td, s.newImportRun(td))
 	if err != nil {
-		log.Printf("Import failed for %s: %v", td.Title, err)
+		logger.Errorf("Import failed for %s: %v", td.Title, err)
 		s.handleImportFailure(td, err)
 		return
 	}
 
+	s.finishImport(td, importPath)
+}
+
+// finishImport marks a download as imported and lets everyone waiting on it
+// know
+func (s *Service) finishImport(td *download.TrackedDownload, importPath string) {
 	// Mark as imported
 	if err := s.monitoring.MarkImported(td, importPath); err != nil {
-		log.Printf("Error marking as imported: %v", err)
+		logger.Errorf("Error marking as imported: %v", err)
 	}
 
 	// Update linked request status
@@ -177,16 +204,18 @@ func (s *Service) handleReadyForImport(td *download.TrackedDownload) {
 		s.requests.MarkAvailable(*td.RequestID)
 	}
 
-	log.Printf("Successfully imported: %s -> %s", td.Title, importPath)
+	logger.Infof("Successfully imported: %s -> %s", td.Title, importPath)
 
 	// Remove from wanted list (so it doesn't show as "searching" in Activity)
 	if td.MediaID != nil {
 		if err := s.db.DeleteWantedByTmdb(td.MediaType, *td.MediaID); err != nil {
-			log.Printf("Error removing from wanted list: %v", err)
+			logger.Errorf("Error removing from wanted list: %v", err)
 		} else {
-			log.Printf("Removed from wanted list: %s (tmdbID=%d)", td.Title, *td.MediaID)
+			logger.Infof("Removed from wanted list: %s (tmdbID=%d)", td.Title, *td.MediaID)
 		}
+		s.db.DeleteSearchCandidates(td.MediaType, *td.MediaID)
 	}
+	go s.RefreshRequestETAs()
 
 	// Send notifications
 	if s.notifications != nil {
@@ -206,8 +235,9 @@ func (s *Service) handleReadyForImport(td *download.TrackedDownload) {
 	}
 }
 
-// runImport performs the actual import
-func (s *Service) runImport(td *download.TrackedDownload) (string, error) {
+// runImport performs the actual import, planning a step for each file it
+// moves and running them in turn
+func (s *Service) runImport(td *download.TrackedDownload, run *importRun) (string, error) {
 	sourcePath := td.DownloadPath
 	if sourcePath == "" {
 		return "", &importpkg.ImportError{Message: "No download path set"}
@@ -219,74 +249,105 @@ func (s *Service) runImport(td *download.TrackedDownload) (string, error) {
 		return "", err
 	}
 
+	// Albums and books import every file rather than a single main file
+	if td.MediaType == "music" || td.MediaType == "book" {
+		return s.runLibraryImport(td, run, sourcePath, decisions)
+	}
+
 	// Get main file
 	mainFile := s.decisions.GetMainFile(decisions)
 	if mainFile == nil {
 		return "", &importpkg.ImportError{Message: "No valid video files found (all rejected as samples)"}
 	}
 
-	// Get destination library
-	library, err := s.getDestinationLibrary(td)
-	if err != nil {
+	// Reject fakes and bloated releases against the preset's size limits
+	if err := s.checkSizeLimits(td, mainFile); err != nil {
 		return "", err
 	}
 
-	// Generate destination path
-	destPath, err := s.generateDestPath(td, library, mainFile)
+	// Work out the destination in the library
+	var destPath string
+	rename := td.AddImportStep(download.ImportStepRename, mainFile.FilePath, "")
+	err = run.run(rename, func() error {
+		library, err := s.getDestinationLibrary(td, nil)
+		if err != nil {
+			return err
+		}
+		if destPath, err = s.generateDestPath(td, library, mainFile); err != nil {
+			return err
+		}
+		rename.Dest = destPath
+		return run.mkdirAll(filepath.Dir(destPath), mainFile.FilePath)
+	})
 	if err != nil {
 		return "", err
 	}
-
-	// Create directory
 	destDir := filepath.Dir(destPath)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return "", err
+
+	// Check the main file in the staging folder, if there is one, before it
+	// goes into the library
+	mainSource := mainFile.FilePath
+	if staging, _ := s.db.GetSetting(importpkg.SettingStagingPath); staging != "" {
+		staged := filepath.Join(staging, strconv.FormatInt(td.ID, 10), filepath.Base(mainFile.FilePath))
+		stage := td.AddImportStep(download.ImportStepStage, mainFile.FilePath, staged)
+		if err := run.run(stage, func() error { return run.stage(stage) }); err != nil {
+			return "", err
+		}
+		mainSource = staged
+	}
+
+	// Plan the rest of the import: the main file, extras and subtitles, then
+	// recording it
+	td.AddImportStep(download.ImportStepTransfer, mainSource, destPath).Main = true
+	for _, extra := range s.decisions.GetExtras(decisions) {
+		dest := filepath.Join(destDir, "Extras", filepath.Base(extra.FilePath))
+		td.AddImportStep(download.ImportStepTransfer, extra.FilePath, dest).Optional = true
 	}
+	for _, sub := range findSubtitles(sourcePath) {
+		td.AddImportStep(download.ImportStepSubtitle, sub, generateSubtitlePath(destPath, sub)).Optional = true
+	}
+	td.AddImportStep(download.ImportStepMetadata, sourcePath, destPath).Optional = true
+	run.save()
 
 	// Check for upgrade - if we already have this media, handle the old file
 	if td.MediaID != nil {
 		s.handleUpgrade(td, destDir)
 	}
 
-	// Move main file
-	if err := moveFile(mainFile.FilePath, destPath); err != nil {
+	if err := run.runSteps(); err != nil {
 		return "", err
 	}
+	return destPath, nil
+}
 
-	// Handle extras
-	extras := s.decisions.GetExtras(decisions)
-	if len(extras) > 0 {
-		extrasDir := filepath.Join(destDir, "Extras")
-		os.MkdirAll(extrasDir, 0755)
-		for _, extra := range extras {
-			moveFile(extra.FilePath, filepath.Join(extrasDir, filepath.Base(extra.FilePath)))
+// runLibraryImport imports a music or book download, recording history and
+// cleaning up the source like a movie or episode import
+func (s *Service) runLibraryImport(td *download.TrackedDownload, run *importRun, sourcePath string, decisions []importpkg.FileDecision) (string, error) {
+	// Names are worked out for every file up front, planning their moves
+	var destPath string
+	rename := td.AddImportStep(download.ImportStepRename, sourcePath, "")
+	err := run.run(rename, func() error {
+		library, err := s.getDestinationLibrary(td, decisions)
+		if err != nil {
+			return err
 		}
-	}
-
-	// Handle subtitles
-	subs := findSubtitles(sourcePath)
-	for _, sub := range subs {
-		subDest := generateSubtitlePath(destPath, sub)
-		moveFile(sub, subDest)
-	}
-
-	// Record import history
-	s.db.CreateImportHistory(&database.ImportHistory{
-		SourcePath: sourcePath,
-		DestPath:   destPath,
-		MediaID:    td.MediaID,
-		MediaType:  &td.MediaType,
-		Success:    true,
+		if td.MediaType == "music" {
+			destPath, err = s.planMusicImport(td, sourcePath, decisions, library)
+		} else {
+			destPath, err = s.planBookImport(td, decisions, library)
+		}
+		rename.Dest = destPath
+		return err
 	})
-
-	// Update media quality status
-	if td.MediaID != nil {
-		s.updateQualityStatus(*td.MediaID, td.MediaType, td.ParsedInfo)
+	if err != nil {
+		return "", err
 	}
+	td.AddImportStep(download.ImportStepMetadata, sourcePath, destPath).Optional = true
+	run.save()
 
-	// Clean up source
-	s.cleanupSource(sourcePath)
-
+	if err := run.runSteps(); err != nil {
+		return "", err
+	}
 	return destPath, nil
 }
 
@@ -312,7 +373,7 @@ func (s *Service) handleUpgrade(td *download.TrackedDownload, destDir string) {
 		return
 	}
 
-	log.Printf("Upgrade detected: %s -> %s (%s)", result.CurrentTier, result.NewTier, result.Reason)
+	logger.Infof("Upgrade detected: %s -> %s (%s)", result.CurrentTier, result.NewTier, result.Reason)
 
 	// Find and remove old files in the destination
 	entries, err := os.ReadDir(destDir)
@@ -320,6 +381,7 @@ func (s *Service) handleUpgrade(td *download.TrackedDownload, destDir string) {
 		return
 	}
 
+	var replaced []string
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -328,12 +390,71 @@ func (s *Service) handleUpgrade(td *download.TrackedDownload, destDir string) {
 		if ext == ".mkv" || ext == ".mp4" || ext == ".avi" {
 			oldPath := filepath.Join(destDir, entry.Name())
 			s.upgrades.HandleOldFile(oldPath)
+			replaced = append(replaced, oldPath)
 		}
 	}
+
+	if err := s.db.RecordMediaEvent(&database.MediaEvent{
+		EventType: database.MediaEventUpgrade,
+		MediaType: td.MediaType,
+		MediaID:   td.MediaID,
+		Summary:   fmt.Sprintf("Upgraded %s to %s", result.CurrentTier, result.NewTier),
+		Details: map[string]interface{}{
+			"releaseTitle":  td.Title,
+			"fromTier":      result.CurrentTier,
+			"toTier":        result.NewTier,
+			"reason":        result.Reason,
+			"replacedFiles": replaced,
+		},
+	}); err != nil {
+		logger.Errorf("Failed to record upgrade of %s: %v", td.Title, err)
+	}
 }
 
 // handleImportFailure handles when import fails
 func (s *Service) handleImportFailure(td *download.TrackedDownload, err error) {
+	failure := importFailure(err)
+	td.ImportError = failure
+	td.RecordImportFailure(failure)
+
+	// Failures that aren't the release's fault, and imports that already put
+	// files in the library, are retried rather than being blocklisted, by
+	// hand once the automatic retries run out. Files that failed their checks
+	// in the staging folder are the release's fault, wherever the import got
+	// to.
+	transient := failure.Code != download.ImportErrValidation && (failure.Retryable || td.ImportStarted())
+	maxAttempts := s.importRetryAttempts()
+	retrying := transient && td.ScheduleImportRetry(maxAttempts)
+	if err := s.monitoring.SaveImportSteps(td); err != nil {
+		logger.Errorf("Failed to save import error for %s: %v", td.Title, err)
+	}
+
+	if retrying {
+		logger.Infof("Retrying import of %s at %s (attempt %d of %d)", td.Title,
+			td.ImportRetry.NextAt.Format(time.RFC3339), td.ImportRetry.Attempts, maxAttempts)
+		s.monitoring.MarkImportBlocked(td, fmt.Sprintf("Import failed, retrying (attempt %d of %d): %s",
+			td.ImportRetry.Attempts, maxAttempts, failure.Message))
+		return
+	}
+	if transient {
+		reason := "Import failed: " + failure.Message
+		if td.ImportRetry.Attempts > 0 {
+			reason = fmt.Sprintf("Import failed after %d retries: %s", td.ImportRetry.Attempts, failure.Message)
+		}
+		s.monitoring.MarkImportBlocked(td, reason)
+		if s.notifications != nil {
+			go s.notifications.NotifyDownloadFailed(td.Title, "Import failed, waiting for a retry: "+failure.Message, strPtrOrNil(td.PosterPath))
+		}
+		return
+	}
+
+	// Downloads added outside Outpost go back to the manual import queue
+	// rather than being blocklisted and deleted from the client
+	if td.External {
+		s.monitoring.MarkImportBlocked(td, "Import failed: "+err.Error())
+		return
+	}
+
 	s.monitoring.MarkFailed(td, err.Error())
 
 	// Update linked request
@@ -343,12 +464,16 @@ func (s *Service) handleImportFailure(td *download.TrackedDownload, err error) {
 
 	// Record in blocklist if we have parsed info
 	if td.ParsedInfo != nil {
+		reason := "Import failed"
+		if failure.Code == download.ImportErrValidation {
+			reason = "Failed validation"
+		}
 		s.db.AddToBlocklist(&database.BlocklistEntry{
 			MediaID:      td.MediaID,
 			MediaType:    &td.MediaType,
 			ReleaseTitle: td.Title,
 			ReleaseGroup: &td.ParsedInfo.ReleaseGroup,
-			Reason:       "Import failed",
+			Reason:       reason,
 			ErrorMessage: strPtr(err.Error()),
 		})
 	}
@@ -375,12 +500,100 @@ func (s *Service) handleImportFailure(td *download.TrackedDownload, err error) {
 	}
 }
 
+// handleStalled swaps a stalled torrent for the next-best release: the stalled
+// release is blocklisted and removed from the client, then the best remaining
+// candidate from the last automatic search is grabbed
+func (s *Service) handleStalled(td *download.TrackedDownload, reason string) {
+	logger.Infof("Swapping stalled download %s: %s", td.Title, reason)
+
+	s.db.AddToBlocklist(&database.BlocklistEntry{
+		MediaID:      td.MediaID,
+		MediaType:    &td.MediaType,
+		ReleaseTitle: td.Title,
+		ReleaseGroup: releaseGroup(td),
+		Reason:       "Stalled",
+		ErrorMessage: strPtr(reason),
+	})
+
+	if err := s.monitoring.MarkFailed(td, "Stalled: "+reason); err != nil {
+		logger.Errorf("Error marking stalled download as failed: %v", err)
+	}
+	if err := s.removeFromClient(td, true); err != nil {
+		logger.Errorf("Failed to remove stalled download from client: %v", err)
+	}
+
+	var replacement *indexer.ScoredSearchResult
+	if td.MediaID != nil {
+		replacement = s.grabNextCandidate(td)
+	}
+
+	if replacement == nil && s.searchAlternative && td.MediaID != nil {
+		// Nothing usable left from the last search, so look for something new
+		go s.searchAlternative_(*td.MediaID, td.MediaType)
+	}
+
+	if s.notifications != nil {
+		posterPath := strPtrOrNil(td.PosterPath)
+		if replacement != nil {
+			go s.notifications.NotifyDownloadSwapped(td.Title, replacement.Title, reason, posterPath)
+		} else {
+			go s.notifications.NotifyDownloadFailed(td.Title, "Stalled ("+reason+"), no alternative release available", posterPath)
+		}
+	}
+}
+
+// grabNextCandidate grabs the best release remaining from the last automatic
+// search for the download's media that isn't blocklisted or excluded for it
+func (s *Service) grabNextCandidate(td *download.TrackedDownload) *indexer.ScoredSearchResult {
+	data, err := s.db.GetSearchCandidates(td.MediaType, *td.MediaID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Errorf("Error loading search candidates: %v", err)
+		}
+		return nil
+	}
+
+	var candidates []indexer.ScoredSearchResult
+	if err := json.Unmarshal([]byte(data), &candidates); err != nil {
+		logger.Errorf("Error decoding search candidates: %v", err)
+		return nil
+	}
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Title == td.Title {
+			continue
+		}
+		if blocked, _ := s.db.IsReleaseBlocklisted(candidate.Title); blocked {
+			continue
+		}
+		if excluded, _ := s.db.ReleaseExcluded(td.MediaType, *td.MediaID, candidate.Title, candidate.ReleaseGroup); excluded != nil {
+			continue
+		}
+		if err := s.GrabRelease(candidate, *td.MediaID, td.MediaType, td.RequestID); err != nil {
+			logger.Errorf("Failed to grab replacement %s: %v", candidate.Title, err)
+			continue
+		}
+		logger.Infof("Replaced stalled download %s with %s", td.Title, candidate.Title)
+		return candidate
+	}
+	return nil
+}
+
+// releaseGroup returns the parsed release group of a download, if known
+func releaseGroup(td *download.TrackedDownload) *string {
+	if td.ParsedInfo == nil {
+		return nil
+	}
+	return strPtr(td.ParsedInfo.ReleaseGroup)
+}
+
 // handleReadyToRemove is called when a download has met seeding requirements
 func (s *Service) handleReadyToRemove(td *download.TrackedDownload) {
-	log.Printf("Download ready for removal (ratio: %.2f, time: %v): %s",
+	logger.Infof("Download ready for removal (ratio: %.2f, time: %v): %s",
 		td.Ratio, td.SeedingTime, td.Title)
 	if err := s.removeFromClient(td, false); err != nil {
-		log.Printf("Failed to remove completed download from client: %v", err)
+		logger.Errorf("Failed to remove completed download from client: %v", err)
 	}
 }
 
@@ -388,22 +601,22 @@ func (s *Service) handleReadyToRemove(td *download.TrackedDownload) {
 func (s *Service) removeFromClient(td *download.TrackedDownload, deleteFiles bool) error {
 	clientConfig, err := s.db.GetDownloadClient(td.DownloadClientID)
 	if err != nil {
-		log.Printf("Error getting download client: %v", err)
+		logger.Errorf("Error getting download client: %v", err)
 		return fmt.Errorf("failed to get download client: %w", err)
 	}
 
 	client, err := downloadclient.New(clientConfig)
 	if err != nil {
-		log.Printf("Error creating download client: %v", err)
+		logger.Errorf("Error creating download client: %v", err)
 		return fmt.Errorf("failed to create download client: %w", err)
 	}
 
 	if err := client.DeleteDownload(td.ExternalID, deleteFiles); err != nil {
-		log.Printf("Error removing from client: %v", err)
+		logger.Errorf("Error removing from client: %v", err)
 		return fmt.Errorf("failed to remove from client: %w", err)
 	}
 
-	log.Printf("Removed from client: %s", td.Title)
+	logger.Infof("Removed from client: %s", td.Title)
 	return nil
 }
 
@@ -416,7 +629,8 @@ func (s *Service) GrabRelease(result *indexer.ScoredSearchResult, mediaID int64,
 		downloadURL = result.Link
 	}
 
-	isTorrent := result.IndexerType == "torznab" || result.MagnetLink != ""
+	// Prowlarr uses "prowlarr" as type, treat as torrent unless explicitly newznab
+	isTorrent := result.IndexerType == "torznab" || result.IndexerType == "prowlarr" || result.MagnetLink != ""
 
 	// Find appropriate client
 	clients, err := s.db.GetEnabledDownloadClients()
@@ -447,10 +661,11 @@ func (s *Service) GrabRelease(result *indexer.ScoredSearchResult, mediaID int64,
 	}
 
 	category := targetClient.Category
+	priority := s.downloadPriority(mediaType, mediaID)
 	if isTorrent {
-		err = client.AddTorrent(downloadURL, category)
+		err = client.AddTorrent(downloadURL, category, priority)
 	} else {
-		err = client.AddNZB(downloadURL, category)
+		err = client.AddNZB(downloadURL, category, priority)
 	}
 
 	if err != nil {
@@ -479,13 +694,27 @@ func (s *Service) GrabRelease(result *indexer.ScoredSearchResult, mediaID int64,
 		s.requests.MarkProcessing(*requestID)
 	}
 
-	log.Printf("Grabbed: %s (client: %s)", result.Title, targetClient.Name)
+	logger.Infof("Grabbed: %s (client: %s)", result.Title, targetClient.Name)
 	return nil
 }
 
+// downloadPriority returns the download client priority of the wanted
+// item a release is grabbed for
+func (s *Service) downloadPriority(mediaType string, mediaID int64) downloadclient.Priority {
+	wantedType := mediaType
+	if mediaType == "episode" {
+		wantedType = "show"
+	}
+	wanted, err := s.db.GetWantedByTmdb(wantedType, mediaID)
+	if err != nil {
+		return downloadclient.PriorityNormal
+	}
+	return downloadclient.ParsePriority(wanted.Priority)
+}
+
 // searchAlternative_ searches for an alternative release after failure
 func (s *Service) searchAlternative_(mediaID int64, mediaType string) {
-	log.Printf("Searching for alternative release for %s %d", mediaType, mediaID)
+	logger.Infof("Searching for alternative release for %s %d", mediaType, mediaID)
 
 	if s.indexers == nil {
 		return
@@ -561,7 +790,7 @@ func (s *Service) searchAlternative_(mediaID int64, mediaType string) {
 	}
 
 	if err := s.GrabRelease(bestResult, mediaID, mediaType, nil); err != nil {
-		log.Printf("Failed to grab alternative: %v", err)
+		logger.Errorf("Failed to grab alternative: %v", err)
 	}
 }
 
@@ -575,40 +804,75 @@ func (s *Service) GetTrackedDownload(id int64) (*download.TrackedDownload, error
 	return s.monitoring.GetTrackedDownload(id)
 }
 
+// ManualImport imports a download waiting in the manual import queue,
+// optionally linking it to a different title first. The import runs in the
+// background.
+func (s *Service) ManualImport(td *download.TrackedDownload, mediaID *int64, mediaType string) error {
+	if td.State != download.StateImportBlocked {
+		return download.ErrNotAwaitingImport
+	}
+
+	if mediaID != nil {
+		td.MediaID = mediaID
+	}
+	if mediaType != "" {
+		td.MediaType = mediaType
+	}
+	td.ImportBlockReason = ""
+	td.CancelImportRetry()
+	if err := s.monitoring.SaveImportSteps(td); err != nil {
+		return err
+	}
+	if err := s.monitoring.UpdateTrackedDownload(td); err != nil {
+		return err
+	}
+
+	go s.handleReadyForImport(td)
+	return nil
+}
+
+// IgnoreImport removes a download from the manual import queue without importing it
+func (s *Service) IgnoreImport(td *download.TrackedDownload) error {
+	if td.State != download.StateImportBlocked {
+		return download.ErrNotAwaitingImport
+	}
+	return s.monitoring.IgnoreDownload(td)
+}
+
 // DeleteTrackedDownload removes a tracked download, optionally deleting from client
 func (s *Service) DeleteTrackedDownload(id int64, deleteFromClient bool, deleteFiles bool) error {
-	log.Printf("DeleteTrackedDownload: id=%d, deleteFromClient=%v, deleteFiles=%v", id, deleteFromClient, deleteFiles)
+	logger.Infof("DeleteTrackedDownload: id=%d, deleteFromClient=%v, deleteFiles=%v", id, deleteFromClient, deleteFiles)
 
 	td, err := s.monitoring.GetTrackedDownload(id)
 	if err != nil {
-		log.Printf("DeleteTrackedDownload: failed to get tracked download: %v", err)
+		logger.Errorf("DeleteTrackedDownload: failed to get tracked download: %v", err)
 		return err
 	}
 	if td == nil {
-		log.Printf("DeleteTrackedDownload: tracked download %d not found (already deleted?)", id)
+		logger.Infof("DeleteTrackedDownload: tracked download %d not found (already deleted?)", id)
 		return nil // Already deleted
 	}
 
-	log.Printf("DeleteTrackedDownload: found download - title=%s, externalID=%s, clientID=%d", td.Title, td.ExternalID, td.DownloadClientID)
+	logger.Infof("DeleteTrackedDownload: found download - title=%s, externalID=%s, clientID=%d", td.Title, td.ExternalID, td.DownloadClientID)
 
 	var clientErr error
 	// Remove from download client if requested
 	if deleteFromClient {
-		log.Printf("DeleteTrackedDownload: removing from client...")
+		logger.Infof("DeleteTrackedDownload: removing from client...")
 		clientErr = s.removeFromClient(td, deleteFiles)
 		if clientErr != nil {
-			log.Printf("DeleteTrackedDownload: client removal failed: %v", clientErr)
+			logger.Errorf("DeleteTrackedDownload: client removal failed: %v", clientErr)
 		} else {
-			log.Printf("DeleteTrackedDownload: client removal successful")
+			logger.Infof("DeleteTrackedDownload: client removal successful")
 		}
 	}
 
 	// Delete from database (even if client delete failed, to avoid orphaned records)
 	if err := s.monitoring.DeleteTrackedDownload(id); err != nil {
-		log.Printf("DeleteTrackedDownload: failed to delete from database: %v", err)
+		logger.Errorf("DeleteTrackedDownload: failed to delete from database: %v", err)
 		return err
 	}
-	log.Printf("DeleteTrackedDownload: deleted from database")
+	logger.Infof("DeleteTrackedDownload: deleted from database")
 
 	// Return client error after database is cleaned up (so user knows to check manually)
 	if clientErr != nil {
@@ -620,15 +884,30 @@ func (s *Service) DeleteTrackedDownload(id int64, deleteFromClient bool, deleteF
 
 // --- Helper functions ---
 
-func (s *Service) getDestinationLibrary(td *download.TrackedDownload) (*database.Library, error) {
+// getDestinationLibrary picks the library a download is imported into.
+// Books that are all comic archives go to a comics library, or to a books
+// library when there isn't one.
+func (s *Service) getDestinationLibrary(td *download.TrackedDownload, decisions []importpkg.FileDecision) (*database.Library, error) {
 	libraries, err := s.db.GetLibraries()
 	if err != nil {
 		return nil, err
 	}
 
 	targetType := "movies"
-	if td.MediaType == "show" || td.MediaType == "episode" {
+	switch td.MediaType {
+	case "show", "episode":
 		targetType = "tv"
+	case "music":
+		targetType = "music"
+	case "book":
+		targetType = "books"
+		if comicFiles(s.decisions.GetApprovedFiles(decisions)) {
+			for _, lib := range libraries {
+				if lib.Type == "comics" {
+					return &lib, nil
+				}
+			}
+		}
 	}
 
 	for _, lib := range libraries {
@@ -637,6 +916,11 @@ func (s *Service) getDestinationLibrary(td *download.TrackedDownload) (*database
 		}
 	}
 
+	// Never drop albums or books into a video library
+	if targetType == "music" || targetType == "books" {
+		return nil, &importpkg.ImportError{Message: "No " + targetType + " library configured"}
+	}
+
 	if len(libraries) > 0 {
 		return &libraries[0], nil
 	}
@@ -644,6 +928,18 @@ func (s *Service) getDestinationLibrary(td *download.TrackedDownload) (*database
 	return nil, &importpkg.ImportError{Message: "No library configured"}
 }
 
+// comicFiles reports whether files are all comic archives
+func comicFiles(files []importpkg.FileDecision) bool {
+	for _, file := range files {
+		switch strings.ToLower(filepath.Ext(file.FilePath)) {
+		case ".cbz", ".cbr":
+		default:
+			return false
+		}
+	}
+	return len(files) > 0
+}
+
 func (s *Service) generateDestPath(td *download.TrackedDownload, library *database.Library, file *importpkg.FileDecision) (string, error) {
 	parsed := td.ParsedInfo
 	if parsed == nil {
@@ -732,13 +1028,6 @@ func padZero(n int) string {
 	return strconv.Itoa(n)
 }
 
-func moveFile(src, dst string) error {
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return err
-	}
-	return os.Rename(src, dst)
-}
-
 func findSubtitles(dir string) []string {
 	var subs []string
 	subExts := []string{".srt", ".sub", ".ass", ".ssa", ".vtt"}
@@ -0,0 +1,90 @@
+package acquisition
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/download"
+	importpkg "github.com/outpost/outpost/internal/import"
+	"github.com/outpost/outpost/internal/quality"
+)
+
+// checkSizeLimits rejects a file whose size for its runtime falls outside the
+// size and bitrate limits of the media's quality preset. Catches fakes and
+// bloated releases that slipped through scoring, e.g. when the indexer
+// reported the wrong size.
+func (s *Service) checkSizeLimits(td *download.TrackedDownload, file *importpkg.FileDecision) error {
+	if td.MediaID == nil {
+		return nil
+	}
+
+	preset := s.presetForMedia(td.MediaType, *td.MediaID)
+	if !quality.HasSizeLimits(preset) {
+		return nil
+	}
+
+	runtime := s.fileRuntime(td.MediaType, *td.MediaID, file)
+	if rejection := quality.CheckSizeLimits(file.FileSize, runtime, preset); rejection != nil {
+		return &importpkg.ImportError{Message: "Rejected by quality preset: " + rejection.Reason}
+	}
+	return nil
+}
+
+// presetForMedia returns the quality preset the media is searched with: the
+// wanted item's preset, then the media's quality override
+func (s *Service) presetForMedia(mediaType string, tmdbID int64) *database.QualityPreset {
+	if wanted, err := s.db.GetWantedByTmdb(mediaType, tmdbID); err == nil && wanted != nil && wanted.QualityPresetID != nil {
+		if preset, err := s.db.GetQualityPreset(*wanted.QualityPresetID); err == nil {
+			return preset
+		}
+	}
+
+	var libraryID int64
+	overrideType := "movie"
+	if mediaType == "movie" {
+		movie, err := s.db.GetMovieByTmdb(tmdbID)
+		if err != nil {
+			return nil
+		}
+		libraryID = movie.ID
+	} else {
+		show, err := s.db.GetShowByTmdb(tmdbID)
+		if err != nil {
+			return nil
+		}
+		libraryID = show.ID
+		overrideType = "show"
+	}
+
+	override, err := s.db.GetMediaQualityOverride(libraryID, overrideType)
+	if err != nil || override == nil || override.PresetID == nil {
+		return nil
+	}
+	preset, err := s.db.GetQualityPreset(*override.PresetID)
+	if err != nil {
+		return nil
+	}
+	return preset
+}
+
+// fileRuntime returns the runtime a file covers: the movie runtime, or the
+// average episode runtime times the episodes in the file. Returns 0 if unknown.
+func (s *Service) fileRuntime(mediaType string, tmdbID int64, file *importpkg.FileDecision) time.Duration {
+	if mediaType == "movie" {
+		movie, err := s.db.GetMovieByTmdb(tmdbID)
+		if err != nil || movie.Runtime == nil {
+			return 0
+		}
+		return time.Duration(*movie.Runtime) * time.Minute
+	}
+
+	minutes, _, err := s.db.GetEpisodeRuntime(tmdbID, -1)
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	episodes := 1
+	if p := file.ParsedInfo; p != nil && p.EpisodeEnd > p.Episode {
+		episodes = p.EpisodeEnd - p.Episode + 1
+	}
+	return time.Duration(episodes*minutes) * time.Minute
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Account data handlers
+//
+// Users can download their data as JSON or as a zip of CSV files, and delete
+// their own account. Admins can do the same for any user through
+// /api/users/{id}/export and DELETE /api/users/{id}.
+
+// handleAccountExport handles GET /api/account/export?format=json|csv
+func (s *Server) handleAccountExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.sendUserExport(w, r, user.ID)
+}
+
+// handleUserExport handles GET /api/users/{id}/export?format=json|csv
+func (s *Server) handleUserExport(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.recordAudit(r, "user.export", "user", &userID, "")
+	s.sendUserExport(w, r, userID)
+}
+
+// sendUserExport writes a user's data as a JSON file, or a zip of CSV files
+// when format=csv
+func (s *Server) sendUserExport(w http.ResponseWriter, r *http.Request, userID int64) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		httpError(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	export, err := s.db.ExportUserData(userID)
+	if err != nil {
+		httpError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	base := fmt.Sprintf("outpost-%s-%s", export.Account.Username, export.ExportedAt.Format("2006-01-02"))
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.json\"", base))
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", base))
+	if err := writeExportCSVs(w, export); err != nil {
+		requestLog(r).Errorf("Account export for user %d failed: %v", userID, err)
+	}
+}
+
+// exportTime formats a time for CSV exports
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeExportCSVs writes an export as a zip with one CSV file per section
+func writeExportCSVs(w http.ResponseWriter, export *database.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	account := export.Account
+	email := ""
+	if account.Email != nil {
+		email = *account.Email
+	}
+	files := []struct {
+		name string
+		rows [][]string
+	}{
+		{"account.csv", [][]string{
+			{"id", "username", "role", "email", "created_at"},
+			{strconv.FormatInt(account.ID, 10), account.Username, account.Role, email, exportTime(account.CreatedAt)},
+		}},
+		{"watch_history.csv", [][]string{{"profile", "media_type", "tmdb_id", "title", "watched_at"}}},
+		{"progress.csv", [][]string{{"profile", "media_type", "title", "position_seconds", "duration_seconds", "updated_at"}}},
+		{"ratings.csv", [][]string{{"media_type", "tmdb_id", "rating", "rated_at"}}},
+		{"requests.csv", [][]string{{"type", "tmdb_id", "title", "year", "status", "requested_at"}}},
+		{"watchlist.csv", [][]string{{"media_type", "tmdb_id", "added_at"}}},
+	}
+
+	for _, h := range export.WatchHistory {
+		tmdbID := ""
+		if h.TmdbID != nil {
+			tmdbID = strconv.FormatInt(*h.TmdbID, 10)
+		}
+		files[1].rows = append(files[1].rows, []string{h.Profile, h.MediaType, tmdbID, h.Title, exportTime(h.WatchedAt)})
+	}
+	for _, p := range export.Progress {
+		files[2].rows = append(files[2].rows, []string{p.Profile, p.MediaType, p.Title,
+			strconv.FormatFloat(p.Position, 'f', 0, 64), strconv.FormatFloat(p.Duration, 'f', 0, 64), exportTime(p.UpdatedAt)})
+	}
+	for _, rating := range export.Ratings {
+		files[3].rows = append(files[3].rows, []string{rating.MediaType, strconv.FormatInt(rating.TmdbID, 10), rating.Rating, exportTime(rating.RatedAt)})
+	}
+	for _, req := range export.Requests {
+		year := ""
+		if req.Year > 0 {
+			year = strconv.Itoa(req.Year)
+		}
+		files[4].rows = append(files[4].rows, []string{req.Type, strconv.FormatInt(req.TmdbID, 10), req.Title, year, req.Status, exportTime(req.RequestedAt)})
+	}
+	for _, item := range export.Watchlist {
+		files[5].rows = append(files[5].rows, []string{item.MediaType, strconv.FormatInt(item.TmdbID, 10), exportTime(item.AddedAt)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.WriteAll(f.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// handleAccount handles the caller's own account: GET returns their
+// language and time zone and the languages available, PUT with {language,
+// timezone} changes them, and DELETE with {password} deletes the account
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.sendAccount(w, user)
+	case http.MethodPut:
+		var req struct {
+			Language *string `json:"language"`
+			Timezone *string `json:"timezone"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		// Empty string goes back to the server's language
+		if req.Language != nil {
+			language, ok := normalizeLanguage(*req.Language)
+			if !ok {
+				httpError(w, "Unsupported language", http.StatusBadRequest)
+				return
+			}
+			user.Language = language
+		}
+		if req.Timezone != nil {
+			zone, ok := normalizeTimezone(*req.Timezone)
+			if !ok {
+				httpError(w, "Unknown time zone", http.StatusBadRequest)
+				return
+			}
+			user.Timezone = zone
+		}
+		if err := s.db.UpdateUser(user); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.sendAccount(w, user)
+	case http.MethodDelete:
+		s.handleAccountDelete(w, r, user)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sendAccount writes the caller's account settings
+func (s *Server) sendAccount(w http.ResponseWriter, user *database.User) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"language":       user.Language,
+		"serverLanguage": i18n.Default(),
+		"languages":      i18n.Languages(),
+		"timezone":       user.Timezone,
+		"serverTimezone": timezone.Location().String(),
+	})
+}
+
+// handleAccountDelete handles DELETE /api/account with {password}, deleting
+// the caller's own account
+func (s *Server) handleAccountDelete(w http.ResponseWriter, r *http.Request, user *database.User) {
+	if session, ok := r.Context().Value(sessionContextKey).(*database.Session); ok && session.ImpersonatorID != nil {
+		localizedError(w, r, http.StatusForbidden, "Accounts can't be deleted while impersonating")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	if !auth.CheckPassword(req.Password, user.PasswordHash) {
+		localizedError(w, r, http.StatusForbidden, "Incorrect password")
+		return
+	}
+
+	if err := s.deleteAccount(r, user); err != nil {
+		localizedError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errLastAdmin is returned when deleting the only admin. It's shown to the
+// user, so it's worded as a message and has a translation.
+var errLastAdmin = errors.New("The last admin account can't be deleted")
+
+// deleteAccount anonymizes an account, refusing to remove the last admin
+func (s *Server) deleteAccount(r *http.Request, user *database.User) error {
+	if user.Role == "admin" {
+		admins, err := s.db.GetAdminUserIDs()
+		if err != nil {
+			return err
+		}
+		if len(admins) <= 1 {
+			return errLastAdmin
+		}
+	}
+
+	// Audited first so a self-deletion's entry is anonymized with the rest
+	s.recordAudit(r, "user.delete", "user", &user.ID, "")
+	if err := s.db.AnonymizeUser(user.ID); err != nil {
+		return err
+	}
+	requestLog(r).Infof("Account %d deleted and anonymized", user.ID)
+	return nil
+}
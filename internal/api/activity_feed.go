@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Activity feed
+//
+// /api/activity/feed is the household's "what's everyone watching": what
+// profiles that opted in with shareActivity watched, and the movies and shows
+// added, latest first. Entries from libraries the viewer can't access, or
+// above their content rating limit, are left out.
+
+const (
+	defaultActivityFeedLimit = 50
+	maxActivityFeedLimit     = 200
+	// defaultActivityFeedWindow is how far back the feed goes without ?since
+	defaultActivityFeedWindow = 7 * 24 * time.Hour
+)
+
+// handleActivityFeed handles GET /api/activity/feed[?since=RFC 3339 time][&limit=n]
+func (s *Server) handleActivityFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	query := r.URL.Query()
+
+	since := time.Now().Add(-defaultActivityFeedWindow)
+	if v := query.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			httpError(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	limit := defaultActivityFeedLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxActivityFeedLimit {
+			n = maxActivityFeedLimit
+		}
+		limit = n
+	}
+
+	// Entries the viewer can't see are dropped after the query, so look at
+	// more than will be shown
+	entries, err := s.db.GetActivityFeed(since, limit*4)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	visible := []database.ActivityFeedEntry{}
+	for _, entry := range entries {
+		if !user.CanAccessLibrary(entry.LibraryID) || !s.isContentAllowed(user, entry.ContentRating, r) {
+			continue
+		}
+		visible = append(visible, entry)
+		if len(visible) == limit {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
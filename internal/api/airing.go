@@ -0,0 +1,189 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/scheduler"
+)
+
+// Airing shows
+//
+// Admins follow a show while it airs at PUT /api/airing/{showId}, giving the
+// time of day it airs, and the scheduler searches for each new episode soon
+// after. Anyone can follow one of these shows with POST
+// /api/airing/{showId}/follow to be notified when new episodes come in.
+
+// airingSearchHistory is how many of a show's recent episode searches are
+// listed with it
+const airingSearchHistory = 3
+
+// airingShowView is an airing show with whether the current user follows it
+// and how its latest episodes' searches went
+type airingShowView struct {
+	database.AiringShow
+	Following bool                    `json:"following"`
+	Searches  []database.AiringSearch `json:"searches"`
+}
+
+// handleAiringShows handles GET /api/airing
+func (s *Server) handleAiringShows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+
+	shows, err := s.db.GetAiringShows()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	following, err := s.db.GetFollowedAiringShowIDs(user.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]airingShowView, 0, len(shows))
+	for _, show := range shows {
+		searches, err := s.db.GetAiringSearchesByShow(show.ShowID, airingSearchHistory)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if searches == nil {
+			searches = []database.AiringSearch{}
+		}
+		views = append(views, airingShowView{AiringShow: show, Following: following[show.ShowID], Searches: searches})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// handleAiringShow handles PUT and DELETE /api/airing/{showId}, which admins
+// use to follow a show while it airs and stop, and POST and DELETE
+// /api/airing/{showId}/follow, which users use to be notified of its new
+// episodes and stop
+func (s *Server) handleAiringShow(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/airing/"), "/")
+	showID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "follow" {
+		s.handleAiringFollow(w, r, showID)
+		return
+	}
+	if len(parts) != 1 {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user.Role != "admin" {
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			AirTime string `json:"airTime"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if _, _, err := scheduler.ParseAirTime(req.AirTime); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		show, err := s.db.GetShow(showID)
+		if err != nil {
+			httpError(w, "Show not found", http.StatusNotFound)
+			return
+		}
+		if show.TmdbID == nil {
+			httpError(w, "Match the show to TMDB first: air dates come from there", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.db.SetAiringShow(showID, req.AirTime, &user.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.db.AddAiringFollower(showID, user.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "airing.follow", "show", &showID, show.Title+" at "+req.AirTime)
+
+		airing, err := s.db.GetAiringShow(showID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(airing)
+
+	case http.MethodDelete:
+		airing, err := s.db.GetAiringShow(showID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if airing == nil {
+			httpError(w, "Show isn't followed", http.StatusNotFound)
+			return
+		}
+		if err := s.db.DeleteAiringShow(showID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "airing.unfollow", "show", &showID, airing.Title)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAiringFollow handles POST and DELETE /api/airing/{showId}/follow
+func (s *Server) handleAiringFollow(w http.ResponseWriter, r *http.Request, showID int64) {
+	user := s.getCurrentUser(r)
+
+	switch r.Method {
+	case http.MethodPost:
+		airing, err := s.db.GetAiringShow(showID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if airing == nil {
+			httpError(w, "Show isn't followed while it airs", http.StatusNotFound)
+			return
+		}
+		if err := s.db.AddAiringFollower(showID, user.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := s.db.RemoveAiringFollower(showID, user.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
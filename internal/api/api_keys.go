@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/netaccess"
+	"github.com/outpost/outpost/internal/notification"
+)
+
+// API keys
+//
+// Scripts and other services authenticate with an X-Api-Key header instead
+// of a session. A key acts as its user, limited by its scope: read keys can
+// only GET, request keys can also make media requests, and full keys can do
+// whatever the user can. Admins create and revoke keys at /api/apikeys.
+// Managing keys, password reset links, impersonation and the Radarr and
+// Sonarr API key need a session: otherwise a leaked key could mint keys or
+// credentials that outlive it being revoked.
+
+// apiKeyHeader carries an API key in place of a session token
+const apiKeyHeader = "X-Api-Key"
+
+// apiKeyContextKey holds the *database.APIKey a request was made with
+const apiKeyContextKey contextKey = "apiKey"
+
+// maxAPIKeyName bounds the name an API key is given
+const maxAPIKeyName = 100
+
+// sessionOnly reports whether a path can't be used with an API key
+func sessionOnly(path string) bool {
+	if path == "/api/apikeys" || strings.HasPrefix(path, "/api/apikeys/") ||
+		path == "/api/arr" || strings.HasPrefix(path, "/api/arr/") {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(path, "/api/users/"); ok {
+		parts := strings.Split(strings.Trim(rest, "/"), "/")
+		return len(parts) >= 2 && (parts[1] == "password-reset" || parts[1] == "impersonate")
+	}
+	return false
+}
+
+// apiKeyAllows reports whether a key's scope covers a request
+func apiKeyAllows(scope string, r *http.Request) bool {
+	switch scope {
+	case database.APIKeyScopeFull:
+		return true
+	case database.APIKeyScopeRequest:
+		if r.Method == http.MethodPost && r.URL.Path == "/api/requests" {
+			return true
+		}
+	}
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// serveWithAPIKey authenticates a request by its API key, for requireAuth
+// and requireAdmin. The key's user goes through the same network and guest
+// checks as a signed-in one, and the request is counted against the key.
+func (s *Server) serveWithAPIKey(w http.ResponseWriter, r *http.Request, key string, adminOnly bool, next http.HandlerFunc) {
+	apiKey, user, err := s.auth.ValidateAPIKey(key)
+	if err != nil {
+		requestLog(r).Warnf("Auth failed: %v for %s %s", err, r.Method, r.URL.Path)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if adminOnly && user.Role != "admin" {
+		requestLog(r).Errorf("Auth failed: not admin for %s %s (API key %d of %s)", r.Method, r.URL.Path, apiKey.ID, user.Username)
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+		return
+	}
+	if sessionOnly(r.URL.Path) {
+		requestLog(r).Warnf("API key %d of %s refused for %s %s: needs a session", apiKey.ID, user.Username, r.Method, r.URL.Path)
+		httpError(w, "API keys can't be used here, sign in instead", http.StatusForbidden)
+		return
+	}
+	if !apiKeyAllows(apiKey.Scope, r) {
+		httpError(w, fmt.Sprintf("A %s API key can't make this request", apiKey.Scope), http.StatusForbidden)
+		return
+	}
+	if !allowUserNetwork(w, r, user, adminOnly) {
+		return
+	}
+	if !allowGuest(w, r, user) {
+		return
+	}
+
+	if err := s.db.RecordAPIKeyUse(apiKey.ID, netaccess.ClientIP(r)); err != nil {
+		requestLog(r).Errorf("Failed to record use of API key %d: %v", apiKey.ID, err)
+	}
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	ctx = context.WithValue(ctx, apiKeyContextKey, apiKey)
+	next(w, r.WithContext(ctx))
+}
+
+// handleAPIKeys handles GET and POST /api/apikeys. GET lists the keys, with
+// revoked ones too if ?all=true. POST creates one from {name, scope, userId},
+// acting as the admin creating it unless userId says otherwise, and is the
+// only time the key itself is returned.
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.db.GetAPIKeys(r.URL.Query().Get("all") == "true")
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(keys)
+
+	case http.MethodPost:
+		admin := s.getCurrentUser(r)
+		if admin == nil {
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Name   string `json:"name"`
+			Scope  string `json:"scope"`
+			UserID *int64 `json:"userId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > maxAPIKeyName {
+			httpError(w, fmt.Sprintf("A name of up to %d characters is required", maxAPIKeyName), http.StatusBadRequest)
+			return
+		}
+		if !database.ValidAPIKeyScope(req.Scope) {
+			httpError(w, "scope must be read, request or full", http.StatusBadRequest)
+			return
+		}
+		userID := admin.ID
+		if req.UserID != nil {
+			userID = *req.UserID
+		}
+		user, err := s.db.GetUserByID(userID)
+		if err != nil {
+			httpError(w, "User not found", http.StatusBadRequest)
+			return
+		}
+
+		key, apiKey, err := s.auth.CreateAPIKey(req.Name, req.Scope, user.ID, &admin.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		apiKey.Username = user.Username
+		s.recordAudit(r, "apikey.create", "apikey", &apiKey.ID, fmt.Sprintf("%s (%s, as %s)", apiKey.Name, apiKey.Scope, user.Username))
+		s.notifySecurity(notification.SecurityAPIKey, i18n.M("API Key Changed"),
+			i18n.M("%s created API key %q", s.actorName(r), apiKey.Name))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":    key,
+			"apiKey": apiKey,
+		})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIKey handles GET /api/apikeys/{id}, and DELETE to revoke the key
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/apikeys/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	apiKey, err := s.db.GetAPIKey(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if apiKey == nil {
+		httpError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(apiKey)
+
+	case http.MethodDelete:
+		revoked, err := s.db.RevokeAPIKey(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !revoked {
+			httpError(w, "API key is already revoked", http.StatusConflict)
+			return
+		}
+		s.recordAudit(r, "apikey.revoke", "apikey", &id, apiKey.Name)
+		s.notifySecurity(notification.SecurityAPIKey, i18n.M("API Key Changed"),
+			i18n.M("%s revoked API key %q", s.actorName(r), apiKey.Name))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,474 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/download"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/notification"
+	"github.com/outpost/outpost/internal/storage"
+)
+
+// Radarr and Sonarr compatibility
+//
+// Overseerr, Notifiarr, Bazarr and other tools talk to Radarr and Sonarr
+// through their v3 API. With arr_api_enabled on, a subset of it is served so
+// they can use Outpost instead: add Outpost as a Radarr server with the URL
+// base /radarr and as a Sonarr server with /sonarr, using the API key from
+// /api/arr. /api/v3 without a base serves movies and series together, for
+// tools that can't set one.
+//
+// Movies and series are the library and the wanted list merged by TMDB ID,
+// which is also their ID. Adding one puts it on the wanted list. Quality
+// profiles are quality presets and root folders are libraries.
+
+// Versions reported to tools, which check them before connecting
+const (
+	arrRadarrVersion = "4.7.5.7809"
+	arrSonarrVersion = "3.0.10.1567"
+)
+
+// Which app a request is for, from the URL base it came in on
+const (
+	arrBoth   = ""
+	arrRadarr = "radarr"
+	arrSonarr = "sonarr"
+)
+
+// arrKeyPattern is what an API key set through the settings must look like
+var arrKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]{16,128}$`)
+
+// arrCommandID numbers the commands tools send, which they expect back
+var arrCommandID atomic.Int64
+
+// validateArrSetting checks the arr_api_* settings. Other settings are
+// always valid.
+func validateArrSetting(key, value string) error {
+	switch key {
+	case "arr_api_enabled":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("arr_api_enabled must be true or false")
+		}
+	case "arr_api_key":
+		if value != "" && !arrKeyPattern.MatchString(value) {
+			return fmt.Errorf("arr_api_key must be 16 to 128 letters and digits")
+		}
+	}
+	return nil
+}
+
+// newArrKey makes an API key in the form Radarr and Sonarr use
+func newArrKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// arrKey returns the API key tools use, made the first time it's asked for
+func (s *Server) arrKey() (string, error) {
+	if key, err := s.db.GetSetting("arr_api_key"); err == nil && key != "" {
+		return key, nil
+	}
+	key := newArrKey()
+	if err := s.db.SetSetting("arr_api_key", key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// handleArrSettings handles /api/arr: GET returns whether the compatibility
+// API is on, its key and the URLs to give tools; POST /api/arr/key replaces
+// the key.
+func (s *Server) handleArrSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.URL.Path == "/api/arr" && r.Method == http.MethodGet:
+	case r.URL.Path == "/api/arr/key" && r.Method == http.MethodPost:
+		if err := s.db.SetSetting("arr_api_key", newArrKey()); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "arr.key", "setting", nil, "")
+		s.notifySecurity(notification.SecurityAPIKey, i18n.M("API Key Changed"),
+			i18n.M("%s set %s", s.actorName(r), "arr_api_key"))
+	case r.URL.Path == "/api/arr" || r.URL.Path == "/api/arr/key":
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	key, err := s.arrKey()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	enabled, _ := s.db.GetSetting("arr_api_enabled")
+	base := s.publicBaseURL(r)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":   enabled == "true",
+		"apiKey":    key,
+		"radarrUrl": base + "/radarr",
+		"sonarrUrl": base + "/sonarr",
+	})
+}
+
+// arrAuthorized checks the API key a tool sent, in the X-Api-Key header or
+// the apikey query parameter
+func (s *Server) arrAuthorized(r *http.Request) bool {
+	sent := r.Header.Get("X-Api-Key")
+	if sent == "" {
+		sent = r.URL.Query().Get("apikey")
+	}
+	key, err := s.db.GetSetting("arr_api_key")
+	if err != nil || key == "" || sent == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(key)) == 1
+}
+
+// handleArr routes /api/v3/..., /radarr/api/v3/... and /sonarr/api/v3/...
+// Paths are matched without regard to case.
+func (s *Server) handleArr(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	app := arrBoth
+	for _, base := range []string{arrRadarr, arrSonarr} {
+		if strings.HasPrefix(path, "/"+base+"/") {
+			app = base
+			path = strings.TrimPrefix(path, "/"+base)
+		}
+	}
+	if !strings.HasPrefix(path, "/api/v3/") {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if enabled, _ := s.db.GetSetting("arr_api_enabled"); enabled != "true" {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if !s.arrAuthorized(r) {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path = strings.ToLower(strings.Trim(strings.TrimPrefix(path, "/api/v3/"), "/"))
+	parts := strings.Split(path, "/")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case path == "system/status":
+		s.handleArrStatus(w, r, app)
+	case path == "qualityprofile":
+		s.handleArrQualityProfiles(w, r, app)
+	case path == "rootfolder":
+		s.handleArrRootFolders(w, r, app)
+	case path == "languageprofile" && app != arrRadarr:
+		// Sonarr v3 files series under a language profile; there's only one
+		arrGet(w, r, []map[string]interface{}{{"id": 1, "name": "Any"}})
+	case path == "tag":
+		arrGet(w, r, []interface{}{})
+	case path == "queue":
+		s.handleArrQueue(w, r, app)
+	case path == "command":
+		s.handleArrCommand(w, r, app)
+	case parts[0] == "movie" && app != arrSonarr:
+		s.routeArrMovies(w, r, parts[1:])
+	case parts[0] == "series" && app != arrRadarr:
+		s.routeArrSeries(w, r, parts[1:])
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// arrGet answers a GET with a fixed value
+func arrGet(w http.ResponseWriter, r *http.Request, value interface{}) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
+
+// handleArrStatus handles GET system/status, which tools use to test the
+// connection
+func (s *Server) handleArrStatus(w http.ResponseWriter, r *http.Request, app string) {
+	name, version := "Radarr", arrRadarrVersion
+	if app == arrSonarr {
+		name, version = "Sonarr", arrSonarrVersion
+	}
+	urlBase := ""
+	if app != arrBoth {
+		urlBase = "/" + app
+	}
+	arrGet(w, r, map[string]interface{}{
+		"appName":      name,
+		"instanceName": "Outpost",
+		"version":      version,
+		"urlBase":      urlBase,
+	})
+}
+
+// arrMediaTypes returns the quality preset media types an app works with
+func arrMediaTypes(app string) map[string]bool {
+	switch app {
+	case arrRadarr:
+		return map[string]bool{"movie": true}
+	case arrSonarr:
+		return map[string]bool{"tv": true, "anime": true}
+	}
+	return map[string]bool{"movie": true, "tv": true, "anime": true}
+}
+
+// handleArrQualityProfiles handles GET qualityprofile: the enabled quality
+// presets for the app's media
+func (s *Server) handleArrQualityProfiles(w http.ResponseWriter, r *http.Request, app string) {
+	presets, err := s.db.GetQualityPresets()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	types := arrMediaTypes(app)
+	profiles := []map[string]interface{}{}
+	for _, p := range presets {
+		if !p.Enabled || !types[p.MediaType] {
+			continue
+		}
+		profiles = append(profiles, map[string]interface{}{
+			"id":             p.ID,
+			"name":           p.Name,
+			"upgradeAllowed": p.AutoUpgrade,
+			"cutoff":         0,
+			"items":          []interface{}{},
+		})
+	}
+	arrGet(w, r, profiles)
+}
+
+// handleArrRootFolders handles GET rootfolder: the libraries for the app's
+// media, with their free space
+func (s *Server) handleArrRootFolders(w http.ResponseWriter, r *http.Request, app string) {
+	libraries, err := s.db.GetLibraries()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	folders := []map[string]interface{}{}
+	for _, lib := range libraries {
+		if !arrLibraryFor(app, lib) {
+			continue
+		}
+		var free uint64
+		accessible := false
+		if usage, err := storage.GetDiskUsage(lib.Path); err == nil {
+			free, accessible = usage.Free, true
+		}
+		folders = append(folders, map[string]interface{}{
+			"id":              lib.ID,
+			"path":            lib.Path,
+			"accessible":      accessible,
+			"freeSpace":       free,
+			"unmappedFolders": []interface{}{},
+		})
+	}
+	arrGet(w, r, folders)
+}
+
+// arrHandles reports whether an app works with movies or shows
+func arrHandles(app, mediaType string) bool {
+	switch mediaType {
+	case "movie":
+		return app != arrSonarr
+	case "show":
+		return app != arrRadarr
+	}
+	return false
+}
+
+// arrLibraryFor reports whether a library holds the app's media
+func arrLibraryFor(app string, lib database.Library) bool {
+	switch lib.Type {
+	case "movies":
+		return app != arrSonarr
+	case "tv", "anime":
+		return app != arrRadarr
+	}
+	return false
+}
+
+// arrQueuePageSize is how many queue records a page has unless a tool asks
+const arrQueuePageSize = 50
+
+// handleArrQueue handles GET queue: active downloads for the app's media, a
+// page at a time
+func (s *Server) handleArrQueue(w http.ResponseWriter, r *http.Request, app string) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	downloads, err := s.acquisition.GetActiveDownloads()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	records := []map[string]interface{}{}
+	for _, td := range downloads {
+		if !arrHandles(app, td.MediaType) {
+			continue
+		}
+		records = append(records, arrQueueRecord(td))
+	}
+
+	page, pageSize := 1, arrQueuePageSize
+	q := r.URL.Query()
+	if n, err := strconv.Atoi(q.Get("page")); err == nil && n > 0 {
+		page = n
+	}
+	if n, err := strconv.Atoi(q.Get("pageSize")); err == nil && n > 0 {
+		pageSize = n
+	}
+	total := len(records)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":          page,
+		"pageSize":      pageSize,
+		"sortKey":       "timeleft",
+		"sortDirection": "ascending",
+		"totalRecords":  total,
+		"records":       records[start:end],
+	})
+}
+
+// arrQueueRecord describes a download the way the queue endpoint does
+func arrQueueRecord(td *download.TrackedDownload) map[string]interface{} {
+	status, trackedStatus, trackedState := "downloading", "ok", "downloading"
+	switch td.State {
+	case download.StateQueued:
+		status = "queued"
+	case download.StatePaused:
+		status = "paused"
+	case download.StateStalled:
+		status, trackedStatus = "warning", "warning"
+	case download.StateCompleted, download.StateImportPending:
+		status, trackedState = "completed", "importPending"
+	case download.StateImporting:
+		status, trackedState = "completed", "importing"
+	case download.StateImported:
+		status, trackedState = "completed", "imported"
+	case download.StateImportBlocked:
+		status, trackedStatus, trackedState = "completed", "warning", "importBlocked"
+	case download.StateFailed:
+		status, trackedStatus, trackedState = "failed", "error", "failedPending"
+	}
+
+	sizeLeft := td.Size - td.Downloaded
+	if sizeLeft < 0 {
+		sizeLeft = 0
+	}
+	record := map[string]interface{}{
+		"id":                    td.ID,
+		"title":                 td.Title,
+		"size":                  td.Size,
+		"sizeleft":              sizeLeft,
+		"status":                status,
+		"trackedDownloadStatus": trackedStatus,
+		"trackedDownloadState":  trackedState,
+		"statusMessages":        []interface{}{},
+		"downloadId":            td.ExternalID,
+		"added":                 td.GrabbedAt,
+	}
+	if td.Quality != "" {
+		record["quality"] = map[string]interface{}{"quality": map[string]string{"name": td.Quality}}
+	}
+	if td.ETA > 0 {
+		eta := td.ETA.Round(time.Second)
+		record["timeleft"] = fmt.Sprintf("%02d:%02d:%02d", int(eta.Hours()), int(eta.Minutes())%60, int(eta.Seconds())%60)
+		record["estimatedCompletionTime"] = time.Now().Add(eta).UTC()
+	}
+	if td.MediaType == "movie" {
+		record["movieId"] = td.TmdbID
+	} else {
+		record["seriesId"] = td.TmdbID
+	}
+	return record
+}
+
+// handleArrCommand handles commands. MoviesSearch, SeriesSearch and
+// SeasonSearch search for items on the wanted list now; GET lists no
+// commands, as they aren't kept.
+func (s *Server) handleArrCommand(w http.ResponseWriter, r *http.Request, app string) {
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode([]interface{}{})
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var cmd struct {
+		Name     string  `json:"name"`
+		MovieIDs []int64 `json:"movieIds"`
+		SeriesID int64   `json:"seriesId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+
+	var mediaType string
+	var ids []int64
+	switch strings.ToLower(cmd.Name) {
+	case "moviessearch":
+		mediaType, ids = "movie", cmd.MovieIDs
+	case "seriessearch", "seasonsearch":
+		mediaType, ids = "show", []int64{cmd.SeriesID}
+	default:
+		httpError(w, "Unsupported command: "+cmd.Name, http.StatusBadRequest)
+		return
+	}
+	if !arrHandles(app, mediaType) {
+		httpError(w, "Unsupported command: "+cmd.Name, http.StatusBadRequest)
+		return
+	}
+	if state := s.maintenance.current(); state.Enabled {
+		writeMaintenance(w, r, state)
+		return
+	}
+	for _, id := range ids {
+		if err := s.scheduler.SearchWantedItem(id, mediaType); err != nil {
+			httpError(w, fmt.Sprintf("Can't search for %d: %v", id, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      arrCommandID.Add(1),
+		"name":    cmd.Name,
+		"status":  "started",
+		"queued":  now,
+		"started": now,
+	})
+}
@@ -0,0 +1,995 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Radarr movies and Sonarr series, built from the library and the wanted
+// list. See arr.go.
+
+// arrMovie is a movie as Radarr describes it. ID is 0 for lookup results
+// that haven't been added.
+type arrMovie struct {
+	ID               int64         `json:"id,omitempty"`
+	Title            string        `json:"title"`
+	SortTitle        string        `json:"sortTitle"`
+	Year             int           `json:"year"`
+	TmdbID           int64         `json:"tmdbId"`
+	ImdbID           string        `json:"imdbId,omitempty"`
+	Overview         string        `json:"overview,omitempty"`
+	Status           string        `json:"status"`
+	Monitored        bool          `json:"monitored"`
+	HasFile          bool          `json:"hasFile"`
+	IsAvailable      bool          `json:"isAvailable"`
+	QualityProfileID int64         `json:"qualityProfileId"`
+	Path             string        `json:"path,omitempty"`
+	RootFolderPath   string        `json:"rootFolderPath,omitempty"`
+	SizeOnDisk       int64         `json:"sizeOnDisk"`
+	Added            *time.Time    `json:"added,omitempty"`
+	Images           []interface{} `json:"images"`
+	Tags             []int         `json:"tags"`
+}
+
+// arrSeries is a show as Sonarr describes it
+type arrSeries struct {
+	ID                int64         `json:"id,omitempty"`
+	Title             string        `json:"title"`
+	SortTitle         string        `json:"sortTitle"`
+	Year              int           `json:"year"`
+	TvdbID            int64         `json:"tvdbId"`
+	TmdbID            int64         `json:"tmdbId"`
+	ImdbID            string        `json:"imdbId,omitempty"`
+	Overview          string        `json:"overview,omitempty"`
+	Status            string        `json:"status"`
+	Monitored         bool          `json:"monitored"`
+	QualityProfileID  int64         `json:"qualityProfileId"`
+	LanguageProfileID int64         `json:"languageProfileId"`
+	SeriesType        string        `json:"seriesType"`
+	SeasonFolder      bool          `json:"seasonFolder"`
+	Path              string        `json:"path,omitempty"`
+	RootFolderPath    string        `json:"rootFolderPath,omitempty"`
+	Added             *time.Time    `json:"added,omitempty"`
+	Seasons           []arrSeason   `json:"seasons"`
+	Statistics        arrStatistics `json:"statistics"`
+	Images            []interface{} `json:"images"`
+	Tags              []int         `json:"tags"`
+}
+
+// arrSeason is a season of an arrSeries
+type arrSeason struct {
+	SeasonNumber int           `json:"seasonNumber"`
+	Monitored    bool          `json:"monitored"`
+	Statistics   arrStatistics `json:"statistics"`
+}
+
+// arrStatistics counts the episode files of a series or season. Outpost
+// only knows the episodes it has, so those are all the episodes there are.
+type arrStatistics struct {
+	SeasonCount       int     `json:"seasonCount,omitempty"`
+	EpisodeFileCount  int     `json:"episodeFileCount"`
+	EpisodeCount      int     `json:"episodeCount"`
+	TotalEpisodeCount int     `json:"totalEpisodeCount"`
+	SizeOnDisk        int64   `json:"sizeOnDisk"`
+	PercentOfEpisodes float64 `json:"percentOfEpisodes"`
+}
+
+// arrSortTitle is a title as Radarr and Sonarr sort it
+func arrSortTitle(title string) string {
+	return strings.TrimPrefix(strings.ToLower(title), "the ")
+}
+
+func newArrMovie(tmdbID int64) *arrMovie {
+	return &arrMovie{TmdbID: tmdbID, Status: "announced", Images: []interface{}{}, Tags: []int{}}
+}
+
+func newArrSeries(tmdbID int64) *arrSeries {
+	return &arrSeries{
+		TmdbID: tmdbID, Status: "continuing", LanguageProfileID: 1, SeriesType: "standard",
+		SeasonFolder: true, Seasons: []arrSeason{}, Images: []interface{}{}, Tags: []int{},
+	}
+}
+
+// arrPresetID picks the quality preset for a quality profile a tool asked
+// for, falling back to the default preset for the media type
+func (s *Server) arrPresetID(profileID int64, mediaType string) *int64 {
+	presets, err := s.db.GetQualityPresets()
+	if err != nil {
+		return nil
+	}
+	presetType := "movie"
+	if mediaType == "show" {
+		presetType = "tv"
+	}
+	for _, p := range presets {
+		if p.ID == profileID && p.Enabled {
+			return &p.ID
+		}
+	}
+	for _, p := range presets {
+		if p.MediaType == presetType && p.IsDefault && p.Enabled {
+			return &p.ID
+		}
+	}
+	for _, p := range presets {
+		if p.MediaType == presetType && p.Enabled {
+			return &p.ID
+		}
+	}
+	return nil
+}
+
+// libraryPaths maps library IDs to their paths
+func (s *Server) libraryPaths() map[int64]string {
+	paths := make(map[int64]string)
+	if libraries, err := s.db.GetLibraries(); err == nil {
+		for _, lib := range libraries {
+			paths[lib.ID] = lib.Path
+		}
+	}
+	return paths
+}
+
+// arrMovies merges the wanted list and the library's movies by TMDB ID.
+// Movies without a TMDB match are left out, as tools can't refer to them.
+func (s *Server) arrMovies() (map[int64]*arrMovie, error) {
+	wanted, err := s.db.GetWantedItems()
+	if err != nil {
+		return nil, err
+	}
+	library, err := s.db.GetMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	movies := make(map[int64]*arrMovie)
+	for _, item := range wanted {
+		if item.Type != "movie" {
+			continue
+		}
+		m := newArrMovie(item.TmdbID)
+		m.ID = item.TmdbID
+		m.Title, m.Year, m.Monitored = item.Title, item.Year, item.Monitored
+		if item.ImdbID != nil {
+			m.ImdbID = *item.ImdbID
+		}
+		if item.QualityPresetID != nil {
+			m.QualityProfileID = *item.QualityPresetID
+		}
+		added := item.AddedAt
+		m.Added = &added
+		movies[item.TmdbID] = m
+	}
+
+	paths := s.libraryPaths()
+	for _, movie := range library {
+		if movie.TmdbID == nil {
+			continue
+		}
+		m, ok := movies[*movie.TmdbID]
+		if !ok {
+			m = newArrMovie(*movie.TmdbID)
+			m.ID = *movie.TmdbID
+			movies[*movie.TmdbID] = m
+		}
+		m.Title, m.Year = movie.Title, movie.Year
+		if movie.ImdbID != nil {
+			m.ImdbID = *movie.ImdbID
+		}
+		if movie.Overview != nil {
+			m.Overview = *movie.Overview
+		}
+		m.Path, m.RootFolderPath = movie.Path, paths[movie.LibraryID]
+		if movie.MissingSince == nil {
+			m.HasFile, m.IsAvailable, m.Status = true, true, "released"
+			m.SizeOnDisk = movie.Size
+		}
+		added := movie.AddedAt
+		m.Added = &added
+	}
+	for _, m := range movies {
+		m.SortTitle = arrSortTitle(m.Title)
+	}
+	return movies, nil
+}
+
+// parseWantedSeasons reads a wanted show's seasons; nil means all of them
+func parseWantedSeasons(seasons string) map[int]bool {
+	var numbers []int
+	if err := json.Unmarshal([]byte(seasons), &numbers); err != nil || len(numbers) == 0 {
+		return nil
+	}
+	set := make(map[int]bool, len(numbers))
+	for _, n := range numbers {
+		set[n] = true
+	}
+	return set
+}
+
+// arrSeriesList merges the wanted list and the library's shows by TMDB ID,
+// with the library's episode files counted per season
+func (s *Server) arrSeriesList() (map[int64]*arrSeries, error) {
+	wanted, err := s.db.GetWantedItems()
+	if err != nil {
+		return nil, err
+	}
+	library, err := s.db.GetShows()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.db.GetSeasonFileStats()
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[int64]*arrSeries)
+	wantedSeasons := make(map[int64]map[int]bool)
+	for _, item := range wanted {
+		if item.Type != "show" {
+			continue
+		}
+		sr := newArrSeries(item.TmdbID)
+		sr.ID = item.TmdbID
+		sr.Title, sr.Year, sr.Monitored = item.Title, item.Year, item.Monitored
+		if item.ImdbID != nil {
+			sr.ImdbID = *item.ImdbID
+		}
+		if item.QualityPresetID != nil {
+			sr.QualityProfileID = *item.QualityPresetID
+		}
+		added := item.AddedAt
+		sr.Added = &added
+		wantedSeasons[item.TmdbID] = parseWantedSeasons(item.Seasons)
+		for n := range wantedSeasons[item.TmdbID] {
+			sr.Seasons = append(sr.Seasons, arrSeason{SeasonNumber: n, Monitored: item.Monitored})
+		}
+		series[item.TmdbID] = sr
+	}
+
+	showSeasons := make(map[int64][]database.SeasonFileStats)
+	for _, st := range stats {
+		showSeasons[st.ShowID] = append(showSeasons[st.ShowID], st)
+	}
+	paths := s.libraryPaths()
+	for _, show := range library {
+		if show.TmdbID == nil {
+			continue
+		}
+		sr, ok := series[*show.TmdbID]
+		if !ok {
+			sr = newArrSeries(*show.TmdbID)
+			sr.ID = *show.TmdbID
+			series[*show.TmdbID] = sr
+		}
+		sr.Title, sr.Year = show.Title, show.Year
+		if show.TvdbID != nil {
+			sr.TvdbID = *show.TvdbID
+		}
+		if show.ImdbID != nil {
+			sr.ImdbID = *show.ImdbID
+		}
+		if show.Overview != nil {
+			sr.Overview = *show.Overview
+		}
+		if show.Status != nil && (*show.Status == "Ended" || *show.Status == "Canceled") {
+			sr.Status = "ended"
+		}
+		sr.Path, sr.RootFolderPath = show.Path, paths[show.LibraryID]
+		if show.AddedAt != nil {
+			sr.Added = show.AddedAt
+		}
+
+		_, isWanted := wantedSeasons[*show.TmdbID]
+		monitoredSeasons := wantedSeasons[*show.TmdbID]
+		for _, st := range showSeasons[show.ID] {
+			season := arrSeason{
+				SeasonNumber: st.SeasonNumber,
+				Monitored:    isWanted && sr.Monitored && (monitoredSeasons == nil || monitoredSeasons[st.SeasonNumber]),
+				Statistics:   arrSeasonStatistics(st.EpisodeFiles, st.Size),
+			}
+			sr.Seasons = mergeArrSeason(sr.Seasons, season)
+		}
+	}
+
+	for _, sr := range series {
+		sort.Slice(sr.Seasons, func(i, j int) bool { return sr.Seasons[i].SeasonNumber < sr.Seasons[j].SeasonNumber })
+		sr.SortTitle = arrSortTitle(sr.Title)
+		sr.Statistics = arrStatistics{SeasonCount: len(sr.Seasons)}
+		for _, season := range sr.Seasons {
+			sr.Statistics.EpisodeFileCount += season.Statistics.EpisodeFileCount
+			sr.Statistics.SizeOnDisk += season.Statistics.SizeOnDisk
+		}
+		sr.Statistics.EpisodeCount = sr.Statistics.EpisodeFileCount
+		sr.Statistics.TotalEpisodeCount = sr.Statistics.EpisodeFileCount
+		if sr.Statistics.EpisodeFileCount > 0 {
+			sr.Statistics.PercentOfEpisodes = 100
+		}
+	}
+	return series, nil
+}
+
+// arrSeasonStatistics describes a season with the given episode files
+func arrSeasonStatistics(files int, size int64) arrStatistics {
+	st := arrStatistics{EpisodeFileCount: files, EpisodeCount: files, TotalEpisodeCount: files, SizeOnDisk: size}
+	if files > 0 {
+		st.PercentOfEpisodes = 100
+	}
+	return st
+}
+
+// mergeArrSeason adds a library season, replacing the wanted list's entry
+// for it but keeping whether it's monitored
+func mergeArrSeason(seasons []arrSeason, season arrSeason) []arrSeason {
+	for i := range seasons {
+		if seasons[i].SeasonNumber == season.SeasonNumber {
+			season.Monitored = seasons[i].Monitored
+			seasons[i] = season
+			return seasons
+		}
+	}
+	return append(seasons, season)
+}
+
+// arrLookupTerm splits a lookup term such as tmdb:603 into the database and
+// the ID. Plain search terms have no database.
+func arrLookupTerm(term string) (source string, id string) {
+	for _, prefix := range []string{"tmdb", "tvdb", "imdb"} {
+		if strings.HasPrefix(strings.ToLower(term), prefix+":") {
+			return prefix, strings.TrimSpace(term[len(prefix)+1:])
+		}
+	}
+	return "", term
+}
+
+// routeArrMovies routes movie, movie/{id} and movie/lookup
+func (s *Server) routeArrMovies(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		s.handleArrMovieList(w, r)
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		s.handleArrAddMovie(w, r)
+	case len(parts) >= 1 && parts[0] == "lookup":
+		s.handleArrMovieLookup(w, r, parts[1:])
+	case len(parts) == 0 && r.Method == http.MethodPut:
+		s.handleArrMovie(w, r, 0)
+	case len(parts) == 1:
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+			return
+		}
+		s.handleArrMovie(w, r, id)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleArrMovieList handles GET movie, narrowed by ?tmdbId=
+func (s *Server) handleArrMovieList(w http.ResponseWriter, r *http.Request) {
+	movies, err := s.arrMovies()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmdbID, _ := strconv.ParseInt(r.URL.Query().Get("tmdbId"), 10, 64)
+	list := []*arrMovie{}
+	for _, m := range movies {
+		if tmdbID == 0 || m.TmdbID == tmdbID {
+			list = append(list, m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SortTitle < list[j].SortTitle })
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleArrMovieLookup handles GET movie/lookup?term= and
+// movie/lookup/tmdb?tmdbId=. Terms can be tmdb:ID, imdb:ID or a title.
+func (s *Server) handleArrMovieLookup(w http.ResponseWriter, r *http.Request, parts []string) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	movies, err := s.arrMovies()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	source, term := arrLookupTerm(r.URL.Query().Get("term"))
+	single := len(parts) == 1 && parts[0] == "tmdb"
+	if single {
+		source, term = "tmdb", r.URL.Query().Get("tmdbId")
+	}
+	if source == "tmdb" {
+		if id, err := strconv.ParseInt(term, 10, 64); err == nil {
+			if m, ok := movies[id]; ok {
+				writeArrLookup(w, single, m)
+				return
+			}
+		}
+	}
+	if !s.metadataConfigured() {
+		writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "TMDB API key not configured")
+		return
+	}
+	tmdbClient := s.metadata.GetTMDBClient()
+
+	var results []*arrMovie
+	switch source {
+	case "tmdb":
+		id, err := strconv.ParseInt(term, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid TMDB ID")
+			return
+		}
+		details, err := tmdbClient.GetMovieDetails(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		m := newArrMovie(details.ID)
+		m.Title, m.Year, m.ImdbID, m.Overview = details.Title, releaseYear(details.ReleaseDate), details.ImdbID, details.Overview
+		results = append(results, m)
+	case "imdb", "tvdb":
+		found, err := tmdbClient.Find(term, source+"_id")
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, res := range found.MovieResults {
+			m := newArrMovie(res.ID)
+			m.Title, m.Year, m.Overview = res.Title, releaseYear(res.ReleaseDate), res.Overview
+			results = append(results, m)
+		}
+	default:
+		found, err := s.metadata.SearchMovies(term, 0)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, res := range found {
+			m := newArrMovie(res.ID)
+			m.Title, m.Year, m.Overview = res.Title, releaseYear(res.ReleaseDate), res.Overview
+			results = append(results, m)
+		}
+	}
+
+	// Movies already added are returned as they are, with their ID
+	for i, m := range results {
+		if existing, ok := movies[m.TmdbID]; ok {
+			results[i] = existing
+		} else {
+			m.SortTitle = arrSortTitle(m.Title)
+		}
+	}
+	if single {
+		if len(results) == 0 {
+			httpError(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(results[0])
+		return
+	}
+	if results == nil {
+		results = []*arrMovie{}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// writeArrLookup writes a lookup result on its own or as a list of one
+func writeArrLookup(w http.ResponseWriter, single bool, result interface{}) {
+	if single {
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	json.NewEncoder(w).Encode([]interface{}{result})
+}
+
+// arrMovieRequest is the body of adding or updating a movie
+type arrMovieRequest struct {
+	ID               int64  `json:"id"`
+	TmdbID           int64  `json:"tmdbId"`
+	Title            string `json:"title"`
+	Year             int    `json:"year"`
+	ImdbID           string `json:"imdbId"`
+	QualityProfileID int64  `json:"qualityProfileId"`
+	Monitored        *bool  `json:"monitored"`
+	AddOptions       struct {
+		SearchForMovie bool `json:"searchForMovie"`
+	} `json:"addOptions"`
+}
+
+// handleArrAddMovie handles POST movie: the movie goes on the wanted list,
+// searched for now if addOptions.searchForMovie is set
+func (s *Server) handleArrAddMovie(w http.ResponseWriter, r *http.Request) {
+	var req arrMovieRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TmdbID == 0 {
+		httpError(w, "tmdbId is required", http.StatusBadRequest)
+		return
+	}
+	movies, err := s.arrMovies()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := movies[req.TmdbID]; ok {
+		httpError(w, "This movie has already been added", http.StatusBadRequest)
+		return
+	}
+	if req.Title == "" && s.metadataConfigured() {
+		if details, err := s.metadata.GetTMDBClient().GetMovieDetails(req.TmdbID); err == nil {
+			req.Title, req.Year, req.ImdbID = details.Title, releaseYear(details.ReleaseDate), details.ImdbID
+		}
+	}
+	if req.Title == "" {
+		httpError(w, "title is required", http.StatusBadRequest)
+		return
+	}
+
+	item := &database.WantedItem{
+		Type:            "movie",
+		TmdbID:          req.TmdbID,
+		Title:           req.Title,
+		Year:            req.Year,
+		QualityPresetID: s.arrPresetID(req.QualityProfileID, "movie"),
+		Monitored:       req.Monitored == nil || *req.Monitored,
+		Seasons:         "[]",
+	}
+	if req.ImdbID != "" {
+		item.ImdbID = &req.ImdbID
+	}
+	if err := s.db.CreateWantedItem(item); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(r, "arr.add", "wanted", &item.ID, fmt.Sprintf("movie %s (tmdb %d)", item.Title, item.TmdbID))
+	requestLog(r).Infof("Movie added through the Radarr API: %s (tmdb=%d)", item.Title, item.TmdbID)
+	if req.AddOptions.SearchForMovie && item.Monitored {
+		go s.scheduler.SearchWantedItem(item.TmdbID, "movie")
+	}
+
+	s.writeArrMovie(w, item.TmdbID, http.StatusCreated)
+}
+
+// handleArrMovie handles GET, PUT and DELETE movie/{id}. PUT changes whether
+// a wanted movie is monitored and its quality profile; DELETE takes it off
+// the wanted list and leaves any files alone.
+func (s *Server) handleArrMovie(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeArrMovie(w, id, http.StatusOK)
+
+	case http.MethodPut:
+		var req arrMovieRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if id == 0 {
+			id = req.ID
+		}
+		item, _ := s.db.GetWantedByTmdb("movie", id)
+		if item != nil {
+			if req.Monitored != nil {
+				item.Monitored = *req.Monitored
+			}
+			if req.QualityProfileID != 0 {
+				item.QualityPresetID = s.arrPresetID(req.QualityProfileID, "movie")
+			}
+			if err := s.db.UpdateWantedItem(item); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		s.writeArrMovie(w, id, http.StatusAccepted)
+
+	case http.MethodDelete:
+		item, _ := s.db.GetWantedByTmdb("movie", id)
+		if item != nil {
+			if err := s.db.DeleteWantedItem(item.ID); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			s.recordAudit(r, "arr.remove", "wanted", &item.ID, fmt.Sprintf("movie %s (tmdb %d)", item.Title, item.TmdbID))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeArrMovie writes a movie as it now stands
+func (s *Server) writeArrMovie(w http.ResponseWriter, tmdbID int64, status int) {
+	movies, err := s.arrMovies()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m, ok := movies[tmdbID]
+	if !ok {
+		httpError(w, "Movie not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(m)
+}
+
+// routeArrSeries routes series, series/{id} and series/lookup
+func (s *Server) routeArrSeries(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		s.handleArrSeriesList(w, r)
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		s.handleArrAddSeries(w, r)
+	case len(parts) == 1 && parts[0] == "lookup":
+		s.handleArrSeriesLookup(w, r)
+	case len(parts) == 0 && r.Method == http.MethodPut:
+		s.handleArrSeries(w, r, 0)
+	case len(parts) == 1:
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+			return
+		}
+		s.handleArrSeries(w, r, id)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleArrSeriesList handles GET series, narrowed by ?tvdbId= or ?tmdbId=
+func (s *Server) handleArrSeriesList(w http.ResponseWriter, r *http.Request) {
+	series, err := s.arrSeriesList()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query()
+	tvdbID, _ := strconv.ParseInt(q.Get("tvdbId"), 10, 64)
+	tmdbID, _ := strconv.ParseInt(q.Get("tmdbId"), 10, 64)
+	list := []*arrSeries{}
+	for _, sr := range series {
+		if (tvdbID == 0 || sr.TvdbID == tvdbID) && (tmdbID == 0 || sr.TmdbID == tmdbID) {
+			list = append(list, sr)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SortTitle < list[j].SortTitle })
+	json.NewEncoder(w).Encode(list)
+}
+
+// arrSeriesFromTMDB describes a show that hasn't been added, with its
+// seasons unmonitored
+func (s *Server) arrSeriesFromTMDB(tmdbID int64) (*arrSeries, error) {
+	details, err := s.metadata.GetTMDBClient().GetTVDetails(tmdbID)
+	if err != nil {
+		return nil, err
+	}
+	sr := newArrSeries(details.ID)
+	sr.Title, sr.Year, sr.Overview = details.Name, releaseYear(details.FirstAirDate), details.Overview
+	sr.TvdbID, sr.ImdbID = details.ExternalIDs.TvdbID, details.ExternalIDs.ImdbID
+	if details.Status == "Ended" || details.Status == "Canceled" {
+		sr.Status = "ended"
+	}
+	for _, season := range details.Seasons {
+		sr.Seasons = append(sr.Seasons, arrSeason{SeasonNumber: season.SeasonNumber})
+	}
+	sr.Statistics.SeasonCount = len(sr.Seasons)
+	sr.SortTitle = arrSortTitle(sr.Title)
+	return sr, nil
+}
+
+// handleArrSeriesLookup handles GET series/lookup?term=. Terms can be
+// tvdb:ID, tmdb:ID, imdb:ID or a title.
+func (s *Server) handleArrSeriesLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	series, err := s.arrSeriesList()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	source, term := arrLookupTerm(r.URL.Query().Get("term"))
+	if source == "tvdb" {
+		if id, err := strconv.ParseInt(term, 10, 64); err == nil {
+			for _, sr := range series {
+				if sr.TvdbID == id {
+					writeArrLookup(w, false, sr)
+					return
+				}
+			}
+		}
+	}
+	if source == "tmdb" {
+		if id, err := strconv.ParseInt(term, 10, 64); err == nil {
+			if sr, ok := series[id]; ok {
+				writeArrLookup(w, false, sr)
+				return
+			}
+		}
+	}
+	if !s.metadataConfigured() {
+		writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "TMDB API key not configured")
+		return
+	}
+
+	var tmdbIDs []int64
+	switch source {
+	case "tmdb":
+		id, err := strconv.ParseInt(term, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid TMDB ID")
+			return
+		}
+		tmdbIDs = append(tmdbIDs, id)
+	case "tvdb", "imdb":
+		found, err := s.metadata.GetTMDBClient().Find(term, source+"_id")
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, res := range found.TVResults {
+			tmdbIDs = append(tmdbIDs, res.ID)
+		}
+	default:
+		// Title searches aren't looked up show by show, so have no TVDB ID
+		found, err := s.metadata.SearchTV(term, 0)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		results := []*arrSeries{}
+		for _, res := range found {
+			sr, ok := series[res.ID]
+			if !ok {
+				sr = newArrSeries(res.ID)
+				sr.Title, sr.Year, sr.Overview = res.Name, releaseYear(res.FirstAirDate), res.Overview
+				sr.SortTitle = arrSortTitle(sr.Title)
+			}
+			results = append(results, sr)
+		}
+		json.NewEncoder(w).Encode(results)
+		return
+	}
+
+	results := []*arrSeries{}
+	for _, id := range tmdbIDs {
+		if sr, ok := series[id]; ok {
+			if source == "tvdb" && sr.TvdbID == 0 {
+				sr.TvdbID, _ = strconv.ParseInt(term, 10, 64)
+			}
+			results = append(results, sr)
+			continue
+		}
+		sr, err := s.arrSeriesFromTMDB(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		results = append(results, sr)
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// arrSeriesRequest is the body of adding or updating a series
+type arrSeriesRequest struct {
+	ID               int64       `json:"id"`
+	TvdbID           int64       `json:"tvdbId"`
+	TmdbID           int64       `json:"tmdbId"`
+	Title            string      `json:"title"`
+	Year             int         `json:"year"`
+	ImdbID           string      `json:"imdbId"`
+	QualityProfileID int64       `json:"qualityProfileId"`
+	Monitored        *bool       `json:"monitored"`
+	Seasons          []arrSeason `json:"seasons"`
+	AddOptions       struct {
+		SearchForMissingEpisodes bool `json:"searchForMissingEpisodes"`
+	} `json:"addOptions"`
+}
+
+// wantedSeasons turns the seasons a tool monitors into the wanted list's
+// form: "[]" when every season listed is monitored, which means all of them
+func (req *arrSeriesRequest) wantedSeasons() string {
+	var monitored []int
+	for _, season := range req.Seasons {
+		if season.Monitored {
+			monitored = append(monitored, season.SeasonNumber)
+		}
+	}
+	if len(monitored) == len(req.Seasons) {
+		return "[]"
+	}
+	sort.Ints(monitored)
+	data, _ := json.Marshal(monitored)
+	return string(data)
+}
+
+// handleArrAddSeries handles POST series: the show goes on the wanted list
+// with the seasons monitored, searched for now if
+// addOptions.searchForMissingEpisodes is set. Sonarr tools send a TVDB ID,
+// which TMDB maps to its own.
+func (s *Server) handleArrAddSeries(w http.ResponseWriter, r *http.Request) {
+	var req arrSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.TmdbID == 0 && req.TvdbID != 0 && s.metadataConfigured() {
+		found, err := s.metadata.GetTMDBClient().Find(strconv.FormatInt(req.TvdbID, 10), "tvdb_id")
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if len(found.TVResults) > 0 {
+			req.TmdbID = found.TVResults[0].ID
+		}
+	}
+	if req.TmdbID == 0 {
+		httpError(w, "A tvdbId known to TMDB or a tmdbId is required", http.StatusBadRequest)
+		return
+	}
+
+	existing, _ := s.db.GetWantedByTmdb("show", req.TmdbID)
+	if existing != nil {
+		httpError(w, "This series has already been added", http.StatusBadRequest)
+		return
+	}
+	if req.Title == "" && s.metadataConfigured() {
+		if details, err := s.metadata.GetTMDBClient().GetTVDetails(req.TmdbID); err == nil {
+			req.Title, req.Year = details.Name, releaseYear(details.FirstAirDate)
+		}
+	}
+	if req.Title == "" {
+		httpError(w, "title is required", http.StatusBadRequest)
+		return
+	}
+
+	item := &database.WantedItem{
+		Type:            "show",
+		TmdbID:          req.TmdbID,
+		Title:           req.Title,
+		Year:            req.Year,
+		QualityPresetID: s.arrPresetID(req.QualityProfileID, "show"),
+		Monitored:       req.Monitored == nil || *req.Monitored,
+		Seasons:         req.wantedSeasons(),
+	}
+	if req.ImdbID != "" {
+		item.ImdbID = &req.ImdbID
+	}
+	if err := s.db.CreateWantedItem(item); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(r, "arr.add", "wanted", &item.ID, fmt.Sprintf("show %s (tmdb %d)", item.Title, item.TmdbID))
+	requestLog(r).Infof("Series added through the Sonarr API: %s (tmdb=%d)", item.Title, item.TmdbID)
+	if req.AddOptions.SearchForMissingEpisodes && item.Monitored {
+		go s.scheduler.SearchWantedItem(item.TmdbID, "show")
+	}
+
+	s.writeArrSeries(w, item.TmdbID, req.TvdbID, http.StatusCreated)
+}
+
+// handleArrSeries handles GET, PUT and DELETE series/{id}. PUT changes which
+// seasons are monitored and the quality profile, putting a library show
+// back on the wanted list if seasons are newly monitored; DELETE takes it
+// off the wanted list and leaves any files alone.
+func (s *Server) handleArrSeries(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeArrSeries(w, id, 0, http.StatusOK)
+
+	case http.MethodPut:
+		var req arrSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if id == 0 {
+			id = req.ID
+		}
+		series, err := s.arrSeriesList()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		current, ok := series[id]
+		if !ok {
+			httpError(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		item, _ := s.db.GetWantedByTmdb("show", id)
+
+		if item == nil {
+			// A library show goes back on the wanted list once a season is monitored
+			if !arrAnyMonitored(req.Seasons) {
+				s.writeArrSeries(w, id, 0, http.StatusAccepted)
+				return
+			}
+			item = &database.WantedItem{
+				Type:            "show",
+				TmdbID:          id,
+				Title:           current.Title,
+				Year:            current.Year,
+				QualityPresetID: s.arrPresetID(req.QualityProfileID, "show"),
+				Monitored:       true,
+				Seasons:         req.wantedSeasons(),
+			}
+			if err := s.db.CreateWantedItem(item); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			s.recordAudit(r, "arr.add", "wanted", &item.ID, fmt.Sprintf("show %s (tmdb %d)", item.Title, item.TmdbID))
+			s.writeArrSeries(w, id, 0, http.StatusAccepted)
+			return
+		}
+
+		if req.Monitored != nil {
+			item.Monitored = *req.Monitored
+		}
+		if req.QualityProfileID != 0 {
+			item.QualityPresetID = s.arrPresetID(req.QualityProfileID, "show")
+		}
+		if len(req.Seasons) > 0 {
+			item.Seasons = req.wantedSeasons()
+		}
+		if err := s.db.UpdateWantedItem(item); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.writeArrSeries(w, id, 0, http.StatusAccepted)
+
+	case http.MethodDelete:
+		item, _ := s.db.GetWantedByTmdb("show", id)
+		if item != nil {
+			if err := s.db.DeleteWantedItem(item.ID); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			s.recordAudit(r, "arr.remove", "wanted", &item.ID, fmt.Sprintf("show %s (tmdb %d)", item.Title, item.TmdbID))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// arrAnyMonitored reports whether any season is monitored
+func arrAnyMonitored(seasons []arrSeason) bool {
+	for _, season := range seasons {
+		if season.Monitored {
+			return true
+		}
+	}
+	return false
+}
+
+// writeArrSeries writes a series as it now stands. A TVDB ID the tool sent
+// fills in for one the library doesn't know yet.
+func (s *Server) writeArrSeries(w http.ResponseWriter, tmdbID, tvdbID int64, status int) {
+	series, err := s.arrSeriesList()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sr, ok := series[tmdbID]
+	if !ok {
+		httpError(w, "Series not found", http.StatusNotFound)
+		return
+	}
+	if sr.TvdbID == 0 {
+		sr.TvdbID = tvdbID
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(sr)
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Audiobooks
+//
+// Audiobooks are found in book libraries by the scanner. Their files play
+// one after another, so positions are seconds from the start of the book,
+// and the player asks which file and how far into it a position is:
+//
+//	GET    /api/audiobooks                  audiobooks, with the active profile's progress
+//	GET    /api/audiobooks/{id}             the audiobook, its files, chapters and progress
+//	GET    /api/audiobooks/{id}/progress    where the active profile is
+//	PUT    /api/audiobooks/{id}/progress    save {position or chapter, speed, finished}
+//	DELETE /api/audiobooks/{id}/progress    start over
+//
+// Files stream from /api/stream/audiobook_file/{fileId}. A book the profile
+// hasn't started plays at the speed it last listened at, and books it's
+// partway through show under continue listening.
+
+const (
+	minAudiobookSpeed = 0.5
+	maxAudiobookSpeed = 3.0
+	// audiobookFinishedMargin is how close to the end a book counts as
+	// finished, as closing credits and silence often aren't listened to
+	audiobookFinishedMargin = 30.0
+)
+
+// audiobookState is where a profile is in an audiobook, with the chapter
+// and file that position is in
+type audiobookState struct {
+	database.AudiobookProgress
+	Started    bool              `json:"started"`
+	Chapter    *database.Chapter `json:"chapter,omitempty"`
+	FileID     int64             `json:"fileId,omitempty"`
+	FileOffset float64           `json:"fileOffset"` // seconds into the file
+	Remaining  float64           `json:"remaining"`  // seconds left at the speed
+}
+
+// audiobookDetail is an audiobook with its files, chapters and the active
+// profile's progress
+type audiobookDetail struct {
+	database.Audiobook
+	Files    []database.AudiobookFile `json:"files"`
+	Chapters []database.Chapter       `json:"chapters"`
+	Progress *audiobookState          `json:"progress,omitempty"`
+}
+
+// audiobookSummary is an audiobook in the list, with the active profile's
+// progress
+type audiobookSummary struct {
+	database.Audiobook
+	Progress *database.AudiobookProgress `json:"progress,omitempty"`
+}
+
+// handleAudiobooks handles GET /api/audiobooks
+func (s *Server) handleAudiobooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	books, err := s.db.GetAudiobooks()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	items := []audiobookSummary{}
+	for _, book := range books {
+		if !user.CanAccessLibrary(book.LibraryID) {
+			continue
+		}
+		item := audiobookSummary{Audiobook: book}
+		if profileID != nil {
+			if item.Progress, err = s.db.GetAudiobookProgress(*profileID, book.ID); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		items = append(items, item)
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+// handleAudiobook routes /api/audiobooks/{id}[/progress]
+func (s *Server) handleAudiobook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/audiobooks/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	book, err := s.db.GetAudiobook(id)
+	if err != nil {
+		httpError(w, "Audiobook not found", http.StatusNotFound)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user != nil && !user.CanAccessLibrary(book.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		detail, err := s.audiobookDetail(r, book)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(detail)
+	case len(parts) == 2 && parts[1] == "progress":
+		profileID := s.getActiveProfileID(r)
+		if profileID == nil {
+			writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+			return
+		}
+		s.handleAudiobookProgress(w, r, *profileID, book)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// audiobookDetail returns an audiobook with its files, chapters and the
+// active profile's progress
+func (s *Server) audiobookDetail(r *http.Request, book *database.Audiobook) (*audiobookDetail, error) {
+	files, err := s.db.GetAudiobookFiles(book.ID)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := s.db.GetChapters("audiobook", book.ID)
+	if err != nil {
+		return nil, err
+	}
+	if files == nil {
+		files = []database.AudiobookFile{}
+	}
+	if chapters == nil {
+		chapters = []database.Chapter{}
+	}
+	detail := &audiobookDetail{Audiobook: *book, Files: files, Chapters: chapters}
+	if profileID := s.getActiveProfileID(r); profileID != nil {
+		progress, err := s.audiobookProgress(*profileID, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		detail.Progress = newAudiobookState(book, progress, files, chapters)
+	}
+	return detail, nil
+}
+
+// audiobookProgress returns where a profile is in an audiobook. A book it
+// hasn't started is at the start, at the speed it last listened at.
+func (s *Server) audiobookProgress(profileID, audiobookID int64) (*database.AudiobookProgress, error) {
+	progress, err := s.db.GetAudiobookProgress(profileID, audiobookID)
+	if err != nil || progress != nil {
+		return progress, err
+	}
+	speed, err := s.db.GetAudiobookSpeed(profileID)
+	if err != nil {
+		return nil, err
+	}
+	if speed == 0 {
+		speed = 1
+	}
+	return &database.AudiobookProgress{AudiobookID: audiobookID, Speed: speed}, nil
+}
+
+// newAudiobookState works out which chapter and file a position is in
+func newAudiobookState(book *database.Audiobook, progress *database.AudiobookProgress, files []database.AudiobookFile, chapters []database.Chapter) *audiobookState {
+	state := &audiobookState{AudiobookProgress: *progress, Started: !progress.UpdatedAt.IsZero()}
+	position := progress.Position
+	for i := range chapters {
+		if chapters[i].StartTime <= position || i == 0 {
+			state.Chapter = &chapters[i]
+		}
+	}
+	for _, f := range files {
+		if f.StartTime <= position || f.Index == 0 {
+			state.FileID = f.ID
+			state.FileOffset = position - f.StartTime
+		}
+	}
+	if state.FileOffset < 0 {
+		state.FileOffset = 0
+	}
+	if left := book.Duration - position; left > 0 && progress.Speed > 0 {
+		state.Remaining = left / progress.Speed
+	}
+	return state
+}
+
+// audiobookProgressRequest is the body of a progress PUT. A chapter, by
+// index, moves to its start.
+type audiobookProgressRequest struct {
+	Position *float64 `json:"position"`
+	Chapter  *int     `json:"chapter"`
+	Speed    *float64 `json:"speed"`
+	Finished *bool    `json:"finished"`
+}
+
+func (s *Server) handleAudiobookProgress(w http.ResponseWriter, r *http.Request, profileID int64, book *database.Audiobook) {
+	files, err := s.db.GetAudiobookFiles(book.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	chapters, err := s.db.GetChapters("audiobook", book.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		progress, err := s.audiobookProgress(profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(newAudiobookState(book, progress, files, chapters))
+
+	case http.MethodPut:
+		var req audiobookProgressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		progress, err := s.audiobookProgress(profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case req.Chapter != nil:
+			if *req.Chapter < 0 || *req.Chapter >= len(chapters) {
+				httpError(w, fmt.Sprintf("Chapter must be between 0 and %d", len(chapters)-1), http.StatusBadRequest)
+				return
+			}
+			progress.Position = chapters[*req.Chapter].StartTime
+		case req.Position != nil:
+			if *req.Position < 0 {
+				httpError(w, "Position can't be negative", http.StatusBadRequest)
+				return
+			}
+			progress.Position = *req.Position
+			if book.Duration > 0 && progress.Position > book.Duration {
+				progress.Position = book.Duration
+			}
+		}
+		if req.Speed != nil {
+			if *req.Speed < minAudiobookSpeed || *req.Speed > maxAudiobookSpeed {
+				httpError(w, fmt.Sprintf("Speed must be between %.1f and %.1f", minAudiobookSpeed, maxAudiobookSpeed), http.StatusBadRequest)
+				return
+			}
+			progress.Speed = *req.Speed
+		}
+		if req.Finished != nil {
+			progress.Finished = *req.Finished
+		} else if req.Position != nil || req.Chapter != nil {
+			progress.Finished = book.Duration > 0 && progress.Position >= book.Duration-audiobookFinishedMargin
+		}
+
+		if err := s.db.SaveAudiobookProgress(profileID, progress); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(newAudiobookState(book, progress, files, chapters))
+
+	case http.MethodDelete:
+		if err := s.db.DeleteAudiobookProgress(profileID, book.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// continueAudiobooks returns the audiobooks a profile is partway through
+// for continue listening
+func (s *Server) continueAudiobooks(user *database.User, profileID int64) ([]continueListeningItem, error) {
+	books, err := s.db.GetAudiobooksInProgress(profileID, continueListeningSize)
+	if err != nil {
+		return nil, err
+	}
+	var items []continueListeningItem
+	for i := range books {
+		book := &books[i]
+		if !user.CanAccessLibrary(book.LibraryID) {
+			continue
+		}
+		files, err := s.db.GetAudiobookFiles(book.ID)
+		if err != nil {
+			return nil, err
+		}
+		chapters, err := s.db.GetChapters("audiobook", book.ID)
+		if err != nil {
+			return nil, err
+		}
+		item := continueListeningItem{
+			Type:         "audiobook",
+			ID:           book.ID,
+			Title:        book.Title,
+			LastPlayedAt: book.Progress.UpdatedAt,
+			Audiobook:    newAudiobookState(&book.Audiobook, &book.Progress, files, chapters),
+		}
+		if book.Author != nil {
+			item.Subtitle = *book.Author
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Audit log handlers
+
+// recordAudit writes an audit log entry attributed to the current user
+func (s *Server) recordAudit(r *http.Request, action, targetType string, targetID *int64, details string) {
+	entry := &database.AuditEntry{
+		Action:   action,
+		TargetID: targetID,
+	}
+	if user := s.getCurrentUser(r); user != nil {
+		entry.UserID = &user.ID
+		entry.Username = user.Username
+	}
+	if targetType != "" {
+		entry.TargetType = &targetType
+	}
+	if details != "" {
+		entry.Details = &details
+	}
+	if ip := netaccess.ClientIP(r); ip != "" {
+		entry.IPAddress = &ip
+	}
+
+	if err := s.db.CreateAuditEntry(entry); err != nil {
+		requestLog(r).Errorf("Failed to record audit entry %s: %v", action, err)
+	}
+}
+
+// handleAuditLog handles GET /api/audit
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	entries, err := s.db.GetAuditLog(r.URL.Query().Get("action"), limit)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []database.AuditEntry{}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
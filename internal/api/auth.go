@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,6 +11,8 @@ import (
 
 	"github.com/outpost/outpost/internal/auth"
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/timezone"
 )
 
 // Auth handlers
@@ -17,24 +21,48 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		deviceInfo
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+
+	if !allowCountry(w, r) {
 		return
 	}
 
 	session, user, err := s.auth.Login(req.Username, req.Password)
+	if err == auth.ErrAccountDisabled {
+		httpError(w, i18n.T(requestLanguage(r, user), "This account is disabled or has expired"), http.StatusForbidden)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		s.recordLoginFailure(r, req.Username)
+		localizedError(w, r, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if !allowUserNetwork(w, r, user, false) {
+		s.auth.Logout(session.Token)
 		return
 	}
+	s.recordLoginDevice(r, user)
+	device, err := s.registerDevice(r, user, session.Token, req.deviceInfo)
+	if err == errDeviceBlocked {
+		s.auth.Logout(session.Token)
+		localizedError(w, r, http.StatusForbidden, "This device has been blocked")
+		return
+	}
+	if err != nil {
+		requestLog(r).Errorf("Failed to register device for %s: %v", user.Username, err)
+	}
 
 	// Set session cookie
 	http.SetCookie(w, &http.Cookie{
@@ -53,6 +81,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			"username": user.Username,
 			"role":     user.Role,
 		},
+		"device": device,
 	})
 }
 
@@ -60,12 +89,20 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	token := s.getSessionToken(r)
 	if token != "" {
+		// Ending an impersonation session is recorded against the admin
+		if session, err := s.db.GetSessionByToken(token); err == nil && session.ImpersonatorID != nil {
+			if admin, err := s.db.GetUserByID(*session.ImpersonatorID); err == nil {
+				targetID := session.UserID
+				r = r.WithContext(context.WithValue(r.Context(), userContextKey, admin))
+				s.recordAudit(r, "impersonation.end", "user", &targetID, "")
+			}
+		}
 		s.auth.Logout(token)
 	}
 
@@ -85,19 +122,22 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	token := s.getSessionToken(r)
 	if token == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	user, err := s.auth.ValidateSession(token)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if session, _ := s.db.GetSessionByToken(token); !s.allowSessionDevice(w, r, session) {
 		return
 	}
 
@@ -119,6 +159,26 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 		"requirePin":         user.RequirePin,
 		"isElevated":         isElevated,
 		"hasPin":             user.PinHash != nil && *user.PinHash != "",
+		"language":           i18n.Resolve(user.Language),
+		"timezone":           timezone.Resolve(user.Timezone).String(),
+	}
+
+	session, err := s.db.GetSessionByToken(token)
+	if err == nil && session.DeviceID != nil {
+		if device, err := s.db.GetDevice(*session.DeviceID); err == nil && device != nil {
+			response["device"] = device
+		}
+	}
+	if err == nil && session.ImpersonatorID != nil {
+		impersonation := map[string]interface{}{
+			"impersonatorId": *session.ImpersonatorID,
+			"expiresAt":      session.ExpiresAt,
+		}
+		if admin, err := s.db.GetUserByID(*session.ImpersonatorID); err == nil {
+			impersonation["impersonatorUsername"] = admin.Username
+		}
+		response["impersonation"] = impersonation
+		setImpersonationHeaders(w, s.db, session)
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -145,13 +205,13 @@ func (s *Server) handleVerifyPin(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	user := s.getCurrentUser(r)
 	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -159,7 +219,7 @@ func (s *Server) handleVerifyPin(w http.ResponseWriter, r *http.Request) {
 		Pin string `json:"pin"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -192,7 +252,7 @@ func (s *Server) handleVerifyPin(w http.ResponseWriter, r *http.Request) {
 	// Create elevation token (valid for 1 hour)
 	elevationToken, err := auth.GenerateToken()
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		httpError(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 	elevation := &database.PinElevation{
@@ -202,7 +262,7 @@ func (s *Server) handleVerifyPin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.db.CreatePinElevation(elevation); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -228,7 +288,7 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	// Check if any users exist
 	count, err := s.db.CountUsers()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -241,13 +301,13 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Only allow setup if no users exist
 	if count > 0 {
-		http.Error(w, "Setup already completed", http.StatusForbidden)
+		httpError(w, "Setup already completed", http.StatusForbidden)
 		return
 	}
 
@@ -256,19 +316,19 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and password required", http.StatusBadRequest)
+		httpError(w, "Username and password required", http.StatusBadRequest)
 		return
 	}
 
 	// Create admin user
 	user, err := s.auth.CreateUser(req.Username, req.Password, "admin")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -283,7 +343,7 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleSetupStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -355,7 +415,7 @@ func (s *Server) handleSetupStatus(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleSetupComplete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -363,7 +423,7 @@ func (s *Server) handleSetupComplete(w http.ResponseWriter, r *http.Request) {
 
 	// Set setup_completed flag
 	if err := s.db.SetSetting("setup_completed", "true"); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -381,7 +441,7 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		users, err := s.db.GetUsers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if users == nil {
@@ -391,26 +451,54 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var req struct {
-			Username           string  `json:"username"`
-			Password           string  `json:"password"`
-			Role               string  `json:"role"`
-			ContentRatingLimit *string `json:"contentRatingLimit"`
-			RequirePin         bool    `json:"requirePin"`
-			Pin                string  `json:"pin"`
+			Username           string     `json:"username"`
+			Password           string     `json:"password"`
+			Role               string     `json:"role"`
+			ContentRatingLimit *string    `json:"contentRatingLimit"`
+			RequirePin         bool       `json:"requirePin"`
+			Pin                string     `json:"pin"`
+			Email              string     `json:"email"`
+			LibraryIDs         []int64    `json:"libraryIds"`
+			RequestQuota       *int       `json:"requestQuota"`
+			RequestQuotaDays   int        `json:"requestQuotaDays"`
+			LanOnly            bool       `json:"lanOnly"`
+			ExpiresAt          *time.Time `json:"expiresAt"`
+			Language           string     `json:"language"`
+			Timezone           string     `json:"timezone"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if req.Username == "" || req.Password == "" {
-			http.Error(w, "Username and password required", http.StatusBadRequest)
+			httpError(w, "Username and password required", http.StatusBadRequest)
 			return
 		}
 
 		if req.Role == "" {
 			req.Role = "user"
 		}
+		if msg := validateAccountExpiry(req.Role, req.ExpiresAt, true); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		email, ok := normalizeEmail(req.Email)
+		if !ok {
+			httpError(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+		language, ok := normalizeLanguage(req.Language)
+		if !ok {
+			httpError(w, "Unsupported language", http.StatusBadRequest)
+			return
+		}
+		zone, ok := normalizeTimezone(req.Timezone)
+		if !ok {
+			httpError(w, "Unknown time zone", http.StatusBadRequest)
+			return
+		}
 
 		// For kid role, default to PG if no limit set
 		if req.Role == "kid" && req.ContentRatingLimit == nil {
@@ -420,15 +508,23 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 
 		user, err := s.auth.CreateUser(req.Username, req.Password, req.Role)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Set parental controls
 		user.ContentRatingLimit = req.ContentRatingLimit
 		user.RequirePin = req.RequirePin
+		user.Email = email
+		user.LibraryIDs = req.LibraryIDs
+		user.RequestQuota = req.RequestQuota
+		user.RequestQuotaDays = req.RequestQuotaDays
+		user.LanOnly = req.LanOnly
+		user.ExpiresAt = req.ExpiresAt
+		user.Language = language
+		user.Timezone = zone
 		if err := s.db.UpdateUser(user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -436,11 +532,11 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 		if req.Pin != "" && len(req.Pin) == 4 {
 			pinHash, err := auth.HashPassword(req.Pin)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			if err := s.db.UpdateUserPin(user.ID, &pinHash); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
@@ -449,48 +545,76 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(user)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Parse path: /api/users/{id}
+	// Parse path: /api/users/{id}, /api/users/{id}/impersonate, /api/users/{id}/password-reset
+	// or /api/users/{id}/export
 	path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-	id, err := strconv.ParseInt(path, 10, 64)
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid user ID")
 		return
 	}
 
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "impersonate":
+			s.handleImpersonate(w, r, id)
+			return
+		case "password-reset":
+			s.handleUserPasswordReset(w, r, id)
+			return
+		case "export":
+			s.handleUserExport(w, r, id)
+			return
+		}
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		user, err := s.db.GetUserByID(id)
 		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+			httpError(w, "User not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(user)
 
 	case http.MethodPut:
 		var req struct {
-			Username           string  `json:"username"`
-			Password           string  `json:"password"`
-			Role               string  `json:"role"`
-			ContentRatingLimit *string `json:"contentRatingLimit"`
-			RequirePin         *bool   `json:"requirePin"`
-			Pin                string  `json:"pin"`
-			ClearPin           bool    `json:"clearPin"`
+			Username           string     `json:"username"`
+			Password           string     `json:"password"`
+			Role               string     `json:"role"`
+			ContentRatingLimit *string    `json:"contentRatingLimit"`
+			RequirePin         *bool      `json:"requirePin"`
+			Pin                string     `json:"pin"`
+			ClearPin           bool       `json:"clearPin"`
+			Email              *string    `json:"email"`
+			LibraryIDs         *[]int64   `json:"libraryIds"`
+			AllLibraries       bool       `json:"allLibraries"`
+			RequestQuota       *int       `json:"requestQuota"`
+			ClearRequestQuota  bool       `json:"clearRequestQuota"`
+			RequestQuotaDays   *int       `json:"requestQuotaDays"`
+			LanOnly            *bool      `json:"lanOnly"`
+			ExpiresAt          *time.Time `json:"expiresAt"`
+			ClearExpiry        bool       `json:"clearExpiry"`
+			Disabled           *bool      `json:"disabled"`
+			Language           *string    `json:"language"`
+			Timezone           *string    `json:"timezone"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		user, err := s.db.GetUserByID(id)
 		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+			httpError(w, "User not found", http.StatusNotFound)
 			return
 		}
 
@@ -514,20 +638,91 @@ func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 			user.RequirePin = *req.RequirePin
 		}
 
+		// Empty string clears the email address
+		if req.Email != nil {
+			email, ok := normalizeEmail(*req.Email)
+			if !ok {
+				httpError(w, "Invalid email address", http.StatusBadRequest)
+				return
+			}
+			user.Email = email
+		}
+
+		// Empty string goes back to the server's language
+		if req.Language != nil {
+			language, ok := normalizeLanguage(*req.Language)
+			if !ok {
+				httpError(w, "Unsupported language", http.StatusBadRequest)
+				return
+			}
+			user.Language = language
+		}
+		if req.Timezone != nil {
+			zone, ok := normalizeTimezone(*req.Timezone)
+			if !ok {
+				httpError(w, "Unknown time zone", http.StatusBadRequest)
+				return
+			}
+			user.Timezone = zone
+		}
+
+		// Library access: a list restricts the user, allLibraries removes the restriction
+		if req.AllLibraries {
+			user.LibraryIDs = nil
+		} else if req.LibraryIDs != nil {
+			user.LibraryIDs = *req.LibraryIDs
+		}
+
+		// Request quota: clearRequestQuota makes requests unlimited
+		if req.ClearRequestQuota {
+			user.RequestQuota = nil
+		} else if req.RequestQuota != nil {
+			user.RequestQuota = req.RequestQuota
+		}
+		if req.RequestQuotaDays != nil {
+			user.RequestQuotaDays = *req.RequestQuotaDays
+		}
+		if req.LanOnly != nil {
+			user.LanOnly = *req.LanOnly
+		}
+
+		// Expiry: clearExpiry removes it. Extending an expired account's
+		// expiry doesn't re-enable it; that takes disabled: false.
+		if req.ClearExpiry {
+			user.ExpiresAt = nil
+		} else if req.ExpiresAt != nil {
+			user.ExpiresAt = req.ExpiresAt
+		}
+		if msg := validateAccountExpiry(user.Role, user.ExpiresAt, req.ExpiresAt != nil); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if req.Disabled != nil {
+			if !*req.Disabled && user.ExpiresAt != nil && !user.ExpiresAt.After(time.Now()) {
+				httpError(w, "Account has expired, set a new expiry date to enable it", http.StatusBadRequest)
+				return
+			}
+			user.Disabled = *req.Disabled
+		}
+
 		if err := s.db.UpdateUser(user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !user.Active() {
+			s.db.DeleteUserSessions(id)
+			s.db.DeleteUserPinElevations(id)
+		}
 
 		// Update password if provided
 		if req.Password != "" {
 			hash, err := auth.HashPassword(req.Password)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			if err := s.db.UpdateUserPassword(id, hash); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
@@ -535,17 +730,17 @@ func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 		// Update or clear PIN
 		if req.ClearPin {
 			if err := s.db.UpdateUserPin(id, nil); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		} else if req.Pin != "" && len(req.Pin) == 4 {
 			pinHash, err := auth.HashPassword(req.Pin)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			if err := s.db.UpdateUserPin(user.ID, &pinHash); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
@@ -556,21 +751,93 @@ func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 		// Don't allow deleting yourself
 		currentUser := s.getCurrentUser(r)
 		if currentUser != nil && currentUser.ID == id {
-			http.Error(w, "Cannot delete yourself", http.StatusBadRequest)
+			httpError(w, "Cannot delete yourself", http.StatusBadRequest)
 			return
 		}
 
-		// Delete user sessions first
-		s.db.DeleteUserSessions(id)
+		user, err := s.db.GetUserByID(id)
+		if err != nil {
+			httpError(w, "User not found", http.StatusNotFound)
+			return
+		}
 
-		if err := s.db.DeleteUser(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Deleting anonymizes the account so its history still counts
+		if err := s.deleteAccount(r, user); err != nil {
+			localizedError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleImpersonate handles /api/users/{id}/impersonate
+// POST starts a time-limited impersonation session, DELETE revokes all of them for the user
+func (s *Server) handleImpersonate(w http.ResponseWriter, r *http.Request, userID int64) {
+	admin := s.getCurrentUser(r)
+	if admin == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Minutes int    `json:"minutes"`
+			Reason  string `json:"reason"`
+		}
+		// Body is optional
+		json.NewDecoder(r.Body).Decode(&req)
+
+		session, target, err := s.auth.Impersonate(admin, userID, time.Duration(req.Minutes)*time.Minute)
+		if err == auth.ErrCannotImpersonateAdmin {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			httpError(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		details := fmt.Sprintf("Impersonating %s until %s", target.Username, session.ExpiresAt.Format(time.RFC3339))
+		if req.Reason != "" {
+			details += ": " + req.Reason
+		}
+		s.recordAudit(r, "impersonation.start", "user", &target.ID, details)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":         session.Token,
+			"expiresAt":     session.ExpiresAt,
+			"impersonating": true,
+			"user": map[string]interface{}{
+				"id":                 target.ID,
+				"username":           target.Username,
+				"role":               target.Role,
+				"contentRatingLimit": target.ContentRatingLimit,
+			},
+		})
+
+	case http.MethodDelete:
+		if err := s.db.DeleteImpersonationSessions(userID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "impersonation.revoke", "user", &userID, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setImpersonationHeaders marks a response as served to an impersonation session
+func setImpersonationHeaders(w http.ResponseWriter, db *database.Database, session *database.Session) {
+	w.Header().Set("X-Impersonation", "true")
+	w.Header().Set("X-Impersonation-Expires", session.ExpiresAt.UTC().Format(time.RFC3339))
+	if admin, err := db.GetUserByID(*session.ImpersonatorID); err == nil {
+		w.Header().Set("X-Impersonated-By", admin.Username)
 	}
 }
 
@@ -580,7 +847,7 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	user := s.getCurrentUser(r)
 	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -588,7 +855,7 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		profiles, err := s.db.GetProfilesByUser(user.ID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if profiles == nil {
@@ -602,25 +869,32 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 			AvatarURL          *string `json:"avatarUrl"`
 			IsKid              bool    `json:"isKid"`
 			ContentRatingLimit *string `json:"contentRatingLimit"`
+			PlayedThreshold    *int    `json:"playedThreshold"`
+			PlayedCredits      *bool   `json:"playedCredits"`
+			ShareActivity      bool    `json:"shareActivity"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if req.Name == "" {
-			http.Error(w, "Profile name is required", http.StatusBadRequest)
+			httpError(w, "Profile name is required", http.StatusBadRequest)
+			return
+		}
+		if req.PlayedThreshold != nil && !database.ValidPlayedThreshold(*req.PlayedThreshold) {
+			httpError(w, "Played threshold must be between 50 and 100", http.StatusBadRequest)
 			return
 		}
 
 		// Check profile limit (max 5 per user)
 		count, err := s.db.CountProfilesByUser(user.ID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if count >= 5 {
-			http.Error(w, "Maximum 5 profiles per user", http.StatusBadRequest)
+			httpError(w, "Maximum 5 profiles per user", http.StatusBadRequest)
 			return
 		}
 
@@ -630,10 +904,13 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 			AvatarURL:          req.AvatarURL,
 			IsKid:              req.IsKid,
 			ContentRatingLimit: req.ContentRatingLimit,
+			PlayedThreshold:    req.PlayedThreshold,
+			PlayedCredits:      req.PlayedCredits,
+			ShareActivity:      req.ShareActivity,
 		}
 
 		if err := s.db.CreateProfile(profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -641,7 +918,7 @@ func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(profile)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -649,16 +926,16 @@ func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	user := s.getCurrentUser(r)
 	if user == nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Parse path: /api/profiles/{id} or /api/profiles/{id}/select
+	// Parse path: /api/profiles/{id}, /api/profiles/{id}/select or /api/profiles/{id}/avatar
 	path := strings.TrimPrefix(r.URL.Path, "/api/profiles/")
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Profile ID required", http.StatusBadRequest)
+		httpError(w, "Profile ID required", http.StatusBadRequest)
 		return
 	}
 
@@ -670,7 +947,7 @@ func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid profile ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid profile ID")
 		return
 	}
 
@@ -683,12 +960,17 @@ func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
 	// Get profile and verify ownership
 	profile, err := s.db.GetProfile(id)
 	if err != nil {
-		http.Error(w, "Profile not found", http.StatusNotFound)
+		httpError(w, "Profile not found", http.StatusNotFound)
 		return
 	}
 
 	if profile.UserID != user.ID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		httpError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "avatar" {
+		s.handleProfileAvatar(w, r, profile)
 		return
 	}
 
@@ -702,85 +984,105 @@ func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
 			AvatarURL          *string `json:"avatarUrl"`
 			IsKid              *bool   `json:"isKid"`
 			ContentRatingLimit *string `json:"contentRatingLimit"`
+			PlayedThreshold    *int    `json:"playedThreshold"`
+			PlayedCredits      *bool   `json:"playedCredits"`
+			ShareActivity      *bool   `json:"shareActivity"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.PlayedThreshold != nil && !database.ValidPlayedThreshold(*req.PlayedThreshold) {
+			httpError(w, "Played threshold must be between 50 and 100", http.StatusBadRequest)
 			return
 		}
 
 		if req.Name != "" {
 			profile.Name = req.Name
 		}
+		oldAvatar := profile.AvatarURL
 		if req.AvatarURL != nil {
 			profile.AvatarURL = req.AvatarURL
 		}
 		if req.IsKid != nil {
 			profile.IsKid = *req.IsKid
 		}
+		if req.ShareActivity != nil {
+			profile.ShareActivity = *req.ShareActivity
+		}
 		profile.ContentRatingLimit = req.ContentRatingLimit
+		// Unset overrides fall back to the server's played rule
+		profile.PlayedThreshold = req.PlayedThreshold
+		profile.PlayedCredits = req.PlayedCredits
 
 		if err := s.db.UpdateProfile(profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if oldAvatar != nil && (profile.AvatarURL == nil || *profile.AvatarURL != *oldAvatar) {
+			s.removeAvatar(profile.ID, *oldAvatar)
+		}
 		json.NewEncoder(w).Encode(profile)
 
 	case http.MethodDelete:
 		// Don't allow deleting the only profile
 		count, err := s.db.CountProfilesByUser(user.ID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if count <= 1 {
-			http.Error(w, "Cannot delete the only profile", http.StatusBadRequest)
+			httpError(w, "Cannot delete the only profile", http.StatusBadRequest)
 			return
 		}
 
 		// Don't allow deleting default profile
 		if profile.IsDefault {
-			http.Error(w, "Cannot delete default profile", http.StatusBadRequest)
+			httpError(w, "Cannot delete default profile", http.StatusBadRequest)
 			return
 		}
 
 		if err := s.db.DeleteProfile(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if profile.AvatarURL != nil {
+			s.removeAvatar(profile.ID, *profile.AvatarURL)
+		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleProfileSelect(w http.ResponseWriter, r *http.Request, user *database.User, profileID int64) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Verify profile belongs to user
 	profile, err := s.db.GetProfile(profileID)
 	if err != nil {
-		http.Error(w, "Profile not found", http.StatusNotFound)
+		httpError(w, "Profile not found", http.StatusNotFound)
 		return
 	}
 
 	if profile.UserID != user.ID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		httpError(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	// Get session token and update active profile
 	token := s.getSessionToken(r)
 	if token == "" {
-		http.Error(w, "No session", http.StatusBadRequest)
+		httpError(w, "No session", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.db.SetActiveProfile(token, profileID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -789,7 +1091,7 @@ func (s *Server) handleProfileSelect(w http.ResponseWriter, r *http.Request, use
 
 func (s *Server) handleActiveProfile(w http.ResponseWriter, r *http.Request, user *database.User) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
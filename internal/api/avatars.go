@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/avatar"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Profile avatars
+//
+// Profiles show a built-in avatar or a picture uploaded for them. Either way
+// the profile's avatarUrl points at it:
+//
+//	GET    /api/avatars                      the built-in gallery
+//	GET    /api/avatars/builtin/{id}.svg     a built-in avatar
+//	GET    /api/avatars/uploads/{name}       an uploaded avatar
+//	POST   /api/profiles/{id}/avatar         upload a picture, multipart "file" with x, y and size to crop it
+//	PUT    /api/profiles/{id}/avatar         pick a built-in avatar, {"builtin": id}
+//	DELETE /api/profiles/{id}/avatar         go back to no avatar
+//
+// Uploads are cropped to a square, scaled down and saved as JPEG in the
+// avatars folder of the data directory. A profile's old upload is removed
+// when it gets a new avatar or is deleted.
+
+const (
+	builtinAvatarPrefix  = "/api/avatars/builtin/"
+	uploadedAvatarPrefix = "/api/avatars/uploads/"
+)
+
+// avatarOption is a built-in avatar in the gallery
+type avatarOption struct {
+	avatar.Avatar
+	URL string `json:"url"`
+}
+
+// builtinAvatarURL returns the URL of a built-in avatar
+func builtinAvatarURL(a avatar.Avatar) string {
+	return builtinAvatarPrefix + a.ID + ".svg?v=" + a.Version()
+}
+
+// handleAvatars handles GET /api/avatars
+func (s *Server) handleAvatars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	options := []avatarOption{}
+	for _, a := range avatar.Gallery() {
+		options = append(options, avatarOption{Avatar: a, URL: builtinAvatarURL(a)})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"builtin":    options,
+		"uploadSize": avatar.Size,
+	})
+}
+
+// handleAvatar serves built-in and uploaded avatars
+func (s *Server) handleAvatar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, builtinAvatarPrefix):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, builtinAvatarPrefix), ".svg")
+		a, ok := avatar.Builtin(id)
+		if !ok {
+			notFound(w)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		// URLs carry the version, so they can be cached for good
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		io.WriteString(w, a.SVG())
+
+	case strings.HasPrefix(r.URL.Path, uploadedAvatarPrefix):
+		name := strings.TrimPrefix(r.URL.Path, uploadedAvatarPrefix)
+		if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			notFound(w)
+			return
+		}
+		file, err := os.Open(filepath.Join(s.avatarDir, name))
+		if err != nil {
+			notFound(w)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			notFound(w)
+			return
+		}
+		// Names change with each upload
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		http.ServeContent(w, r, name, info.ModTime(), file)
+
+	default:
+		notFound(w)
+	}
+}
+
+// handleProfileAvatar handles /api/profiles/{id}/avatar for a profile the
+// user owns
+func (s *Server) handleProfileAvatar(w http.ResponseWriter, r *http.Request, profile *database.Profile) {
+	switch r.Method {
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, avatar.MaxUploadSize+1<<20)
+		if err := r.ParseMultipartForm(avatar.MaxUploadSize); err != nil {
+			httpError(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			httpError(w, "No file uploaded", http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, avatar.MaxUploadSize+1))
+		file.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(data) > avatar.MaxUploadSize {
+			httpError(w, fmt.Sprintf("Avatars can be up to %d MB", avatar.MaxUploadSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		crop, err := parseAvatarCrop(r)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		processed, err := avatar.Process(data, crop)
+		if errors.Is(err, avatar.ErrInvalidCrop) {
+			httpError(w, "Crop is outside the picture", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			httpError(w, "Avatar must be a jpg, png, gif or webp image", http.StatusBadRequest)
+			return
+		}
+
+		url, err := s.saveAvatar(profile.ID, processed)
+		if err != nil {
+			requestLog(r).Errorf("Failed to save avatar for profile %d: %v", profile.ID, err)
+			httpError(w, "Failed to save avatar", http.StatusInternalServerError)
+			return
+		}
+		s.setProfileAvatar(w, r, profile, &url)
+
+	case http.MethodPut:
+		var req struct {
+			Builtin string `json:"builtin"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		a, ok := avatar.Builtin(req.Builtin)
+		if !ok {
+			httpError(w, "Unknown avatar", http.StatusBadRequest)
+			return
+		}
+		url := builtinAvatarURL(a)
+		s.setProfileAvatar(w, r, profile, &url)
+
+	case http.MethodDelete:
+		s.setProfileAvatar(w, r, profile, nil)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseAvatarCrop reads the crop square of an upload, if one was given
+func parseAvatarCrop(r *http.Request) (*avatar.Crop, error) {
+	if r.FormValue("size") == "" {
+		return nil, nil
+	}
+	var values [3]int
+	for i, key := range []string{"x", "y", "size"} {
+		v, err := strconv.Atoi(r.FormValue(key))
+		if err != nil {
+			return nil, fmt.Errorf("Crop %s must be a whole number", key)
+		}
+		values[i] = v
+	}
+	return &avatar.Crop{X: values[0], Y: values[1], Size: values[2]}, nil
+}
+
+// setProfileAvatar saves a profile's new avatar and removes its old upload
+func (s *Server) setProfileAvatar(w http.ResponseWriter, r *http.Request, profile *database.Profile, url *string) {
+	old := profile.AvatarURL
+	profile.AvatarURL = url
+	if err := s.db.UpdateProfile(profile); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if old != nil && (url == nil || *old != *url) {
+		s.removeAvatar(profile.ID, *old)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// saveAvatar writes a processed upload to the avatars folder and returns its
+// URL. The name carries a hash of the picture, so each upload gets a new URL.
+func (s *Server) saveAvatar(profileID int64, data []byte) (string, error) {
+	if err := os.MkdirAll(s.avatarDir, 0755); err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	name := fmt.Sprintf("%d-%s.jpg", profileID, hex.EncodeToString(sum[:6]))
+	tmp, err := os.CreateTemp(s.avatarDir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.avatarDir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return uploadedAvatarPrefix + name, nil
+}
+
+// removeAvatar deletes a profile's uploaded avatar. Built-in avatars, other
+// URLs and other profiles' uploads are left alone.
+func (s *Server) removeAvatar(profileID int64, url string) {
+	name, ok := strings.CutPrefix(url, uploadedAvatarPrefix)
+	if !ok || name != filepath.Base(name) || !strings.HasPrefix(name, fmt.Sprintf("%d-", profileID)) {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.avatarDir, name)); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("Failed to remove avatar %s: %v", name, err)
+	}
+}
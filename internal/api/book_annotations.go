@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// EPUB reading sync
+//
+// The reader saves the active profile's position in an EPUB and its
+// bookmarks and highlights here, so a book opened on another device starts
+// where it was left:
+//
+//	GET    /api/books/{id}/position              position, null if unread
+//	PUT    /api/books/{id}/position              save {cfi, progress}
+//	GET    /api/books/{id}/annotations           bookmarks and highlights
+//	POST   /api/books/{id}/annotations           add {type, cfi, text, note, color}
+//	PUT    /api/books/{id}/annotations/{annId}   change the note or color
+//	DELETE /api/books/{id}/annotations/{annId}   remove
+//
+// The reader's progress in any format, and the table of contents, are in
+// book_reader.go.
+
+// Bounds on what the reader sends
+const (
+	maxCFILength      = 2048
+	maxAnnotationText = 10000
+	maxColorLength    = 32
+)
+
+// validCFI reports whether s looks like an EPUB CFI, epubcfi(...)
+func validCFI(s string) bool {
+	return len(s) <= maxCFILength && strings.HasPrefix(s, "epubcfi(") && strings.HasSuffix(s, ")")
+}
+
+// handleBookReading routes a book's progress, table of contents, position
+// and annotations
+func (s *Server) handleBookReading(w http.ResponseWriter, r *http.Request, book *database.Book, parts []string) {
+	user := s.getCurrentUser(r)
+	if user != nil && !user.CanAccessLibrary(book.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
+	if len(parts) == 1 && (parts[0] == "progress" || parts[0] == "toc") {
+		w.Header().Set("Content-Type", "application/json")
+		if parts[0] == "progress" {
+			s.handleBookProgress(w, r, book)
+		} else {
+			s.handleBookTOC(w, r, book)
+		}
+		return
+	}
+	if book.Format != "epub" {
+		httpError(w, "Reading sync is only available for EPUB books", http.StatusBadRequest)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil {
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "position":
+		s.handleBookPosition(w, r, *profileID, book)
+	case len(parts) == 1 && parts[0] == "annotations":
+		s.handleBookAnnotations(w, r, *profileID, book)
+	case len(parts) == 2 && parts[0] == "annotations":
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid annotation ID")
+			return
+		}
+		s.handleBookAnnotation(w, r, *profileID, id)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleBookPosition(w http.ResponseWriter, r *http.Request, profileID int64, book *database.Book) {
+	switch r.Method {
+	case http.MethodGet:
+		position, err := s.db.GetBookPosition(profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(position)
+
+	case http.MethodPut:
+		var req struct {
+			CFI      string  `json:"cfi"`
+			Progress float64 `json:"progress"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if !validCFI(req.CFI) {
+			httpError(w, "Invalid CFI", http.StatusBadRequest)
+			return
+		}
+		if req.Progress < 0 || req.Progress > 1 {
+			httpError(w, "Progress must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		if err := s.db.SaveBookPosition(profileID, book.ID, req.CFI, req.Progress); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		position, err := s.db.GetBookPosition(profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(position)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// annotationRequest is the body of an annotation POST or PUT
+type annotationRequest struct {
+	Type  string  `json:"type"`
+	CFI   string  `json:"cfi"`
+	Text  *string `json:"text"`
+	Note  *string `json:"note"`
+	Color *string `json:"color"`
+}
+
+// validate checks the note and color, which can be changed later
+func (req *annotationRequest) validate() string {
+	if req.Note != nil && len(*req.Note) > maxAnnotationText {
+		return "Note is too long"
+	}
+	if req.Color != nil && len(*req.Color) > maxColorLength {
+		return "Invalid color"
+	}
+	return ""
+}
+
+func (s *Server) handleBookAnnotations(w http.ResponseWriter, r *http.Request, profileID int64, book *database.Book) {
+	switch r.Method {
+	case http.MethodGet:
+		annotations, err := s.db.GetBookAnnotations(profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if annotations == nil {
+			annotations = []database.BookAnnotation{}
+		}
+		json.NewEncoder(w).Encode(annotations)
+
+	case http.MethodPost:
+		var req annotationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.Type != "bookmark" && req.Type != "highlight" {
+			httpError(w, "Type must be bookmark or highlight", http.StatusBadRequest)
+			return
+		}
+		if !validCFI(req.CFI) {
+			httpError(w, "Invalid CFI", http.StatusBadRequest)
+			return
+		}
+		if req.Text != nil && len(*req.Text) > maxAnnotationText {
+			httpError(w, "Highlighted text is too long", http.StatusBadRequest)
+			return
+		}
+		if msg := req.validate(); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		annotation := &database.BookAnnotation{
+			BookID: book.ID,
+			Type:   req.Type,
+			CFI:    req.CFI,
+			Text:   req.Text,
+			Note:   req.Note,
+			Color:  req.Color,
+		}
+		if err := s.db.CreateBookAnnotation(profileID, annotation); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(annotation)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleBookAnnotation(w http.ResponseWriter, r *http.Request, profileID, id int64) {
+	switch r.Method {
+	case http.MethodPut:
+		annotation, err := s.db.GetBookAnnotation(profileID, id)
+		if err != nil {
+			httpError(w, "Annotation not found", http.StatusNotFound)
+			return
+		}
+		var req annotationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if msg := req.validate(); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		// Fields left out are kept; empty ones are cleared
+		if req.Note != nil {
+			annotation.Note = emptyToNil(*req.Note)
+		}
+		if req.Color != nil {
+			annotation.Color = emptyToNil(*req.Color)
+		}
+		if err := s.db.UpdateBookAnnotation(profileID, annotation); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		annotation, err = s.db.GetBookAnnotation(profileID, id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(annotation)
+
+	case http.MethodDelete:
+		deleted, err := s.db.DeleteBookAnnotation(profileID, id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			httpError(w, "Annotation not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func emptyToNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/epub"
+)
+
+// Book reader
+//
+// Any book a reader can page through keeps the active profile's progress
+// here, whatever its format. EPUB positions are CFIs, as for reading sync;
+// PDFs and comics are read by page:
+//
+//	GET    /api/books/{id}/progress    progress, null if unread
+//	PUT    /api/books/{id}/progress    save {cfi, progress} or {page, pageCount}
+//	DELETE /api/books/{id}/progress    start over
+//	GET    /api/books/{id}/toc         an EPUB's table of contents
+//
+// The reader jumps to a heading by its href, which is relative to the
+// package document as EPUB readers expect.
+
+// bookProgress is where a profile is in a book. EPUBs have a CFI, other
+// books a page.
+type bookProgress struct {
+	BookID    int64     `json:"bookId"`
+	Format    string    `json:"format"`
+	CFI       string    `json:"cfi,omitempty"`
+	Page      int       `json:"page,omitempty"` // From 1
+	PageCount int       `json:"pageCount,omitempty"`
+	Progress  float64   `json:"progress"` // 0-1
+	Finished  bool      `json:"finished"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// pagedBook reports whether a book is read by page rather than by CFI
+func pagedBook(book *database.Book) bool {
+	switch book.Format {
+	case "pdf", "cbz", "cbr":
+		return true
+	}
+	return false
+}
+
+// getBookProgress returns a profile's progress in a book, or nil if it
+// hasn't been opened
+func (s *Server) getBookProgress(profileID int64, book *database.Book) (*bookProgress, error) {
+	if pagedBook(book) {
+		p, err := s.db.GetReadingProgress(profileID, book.ID)
+		if err != nil || p == nil {
+			return nil, err
+		}
+		progress := &bookProgress{
+			BookID:    book.ID,
+			Format:    book.Format,
+			Page:      p.Page,
+			PageCount: p.PageCount,
+			Finished:  p.Finished,
+			UpdatedAt: p.UpdatedAt,
+		}
+		if p.PageCount > 0 {
+			progress.Progress = float64(p.Page) / float64(p.PageCount)
+		}
+		return progress, nil
+	}
+
+	p, err := s.db.GetBookPosition(profileID, book.ID)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return &bookProgress{
+		BookID:    book.ID,
+		Format:    book.Format,
+		CFI:       p.CFI,
+		Progress:  p.Progress,
+		Finished:  p.Progress >= 1,
+		UpdatedAt: p.UpdatedAt,
+	}, nil
+}
+
+// bookProgressRequest is the body of a progress PUT
+type bookProgressRequest struct {
+	CFI       string  `json:"cfi"`
+	Progress  float64 `json:"progress"`
+	Page      int     `json:"page"`
+	PageCount int     `json:"pageCount"` // For PDFs, whose pages aren't counted when scanned
+}
+
+func (s *Server) handleBookProgress(w http.ResponseWriter, r *http.Request, book *database.Book) {
+	if book.Format != "epub" && !pagedBook(book) {
+		httpError(w, "Reading progress isn't available for this format", http.StatusBadRequest)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil {
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		progress, err := s.getBookProgress(*profileID, book)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(progress)
+
+	case http.MethodPut:
+		var req bookProgressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if pagedBook(book) {
+			pageCount := req.PageCount
+			if book.PageCount != nil && *book.PageCount > 0 {
+				pageCount = *book.PageCount
+			}
+			if pageCount <= 0 {
+				httpError(w, "pageCount is required for this book", http.StatusBadRequest)
+				return
+			}
+			if req.Page < 1 || req.Page > pageCount {
+				httpError(w, "Page out of range", http.StatusBadRequest)
+				return
+			}
+			if err := s.db.SaveReadingProgress(*profileID, book.ID, req.Page, pageCount); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			if !validCFI(req.CFI) {
+				httpError(w, "Invalid CFI", http.StatusBadRequest)
+				return
+			}
+			if req.Progress < 0 || req.Progress > 1 {
+				httpError(w, "Progress must be between 0 and 1", http.StatusBadRequest)
+				return
+			}
+			if err := s.db.SaveBookPosition(*profileID, book.ID, req.CFI, req.Progress); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		progress, err := s.getBookProgress(*profileID, book)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(progress)
+
+	case http.MethodDelete:
+		var err error
+		if pagedBook(book) {
+			err = s.db.DeleteReadingProgress(*profileID, book.ID)
+		} else {
+			err = s.db.DeleteBookPosition(*profileID, book.ID)
+		}
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBookTOC handles GET /api/books/{id}/toc
+func (s *Server) handleBookTOC(w http.ResponseWriter, r *http.Request, book *database.Book) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if book.Format != "epub" {
+		httpError(w, "A table of contents is only available for EPUB books", http.StatusBadRequest)
+		return
+	}
+
+	b, err := epub.Open(book.Path)
+	if err != nil {
+		requestLog(r).Errorf("Failed to open %s: %v", book.Path, err)
+		httpError(w, "Failed to read book", http.StatusInternalServerError)
+		return
+	}
+	defer b.Close()
+	toc, err := b.TOC()
+	if err != nil {
+		requestLog(r).Errorf("Failed to read the table of contents of %s: %v", book.Path, err)
+		httpError(w, "Failed to read the table of contents", http.StatusInternalServerError)
+		return
+	}
+	if toc == nil {
+		toc = []epub.TOCEntry{}
+	}
+	json.NewEncoder(w).Encode(toc)
+}
@@ -0,0 +1,359 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/jobs"
+)
+
+// Bulk library operations
+//
+// Admins act on many movies and shows at once at /api/library/bulk: delete
+// them, refresh their metadata, change whether they're monitored, assign a
+// quality preset or add tags. The work runs as a library_bulk job, polled and
+// cancelled at /api/jobs/{id}. In four-eyes mode bulk deletes wait for a
+// second admin, and the job starts when they confirm.
+
+// Bulk actions
+const (
+	bulkDelete  = "delete"
+	bulkRefresh = "refresh"
+	bulkMonitor = "monitor"
+	bulkPreset  = "preset"
+	bulkTag     = "tag"
+)
+
+// maxBulkItems bounds the movies and shows one job acts on
+const maxBulkItems = 5000
+
+// bulkRequest is the body of POST /api/library/bulk
+type bulkRequest struct {
+	Action    string   `json:"action"`
+	MovieIDs  []int64  `json:"movieIds"`
+	ShowIDs   []int64  `json:"showIds"`
+	Monitored *bool    `json:"monitored"` // For monitor
+	PresetID  *int64   `json:"presetId"`  // For preset; null goes back to the default preset
+	Tags      []string `json:"tags"`      // For tag
+}
+
+// bulkItemError is a movie or show a bulk job couldn't act on
+type bulkItemError struct {
+	MediaType string `json:"mediaType"`
+	MediaID   int64  `json:"mediaId"`
+	Title     string `json:"title,omitempty"`
+	Error     string `json:"error"`
+}
+
+// bulkResult is what a bulk job did, kept as the job's result
+type bulkResult struct {
+	Action    string          `json:"action"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Errors    []bulkItemError `json:"errors"`
+}
+
+// handleLibraryBulk handles POST /api/library/bulk, starting a bulk job and
+// answering 202 with it, and GET to list the recent jobs
+func (s *Server) handleLibraryBulk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.jobs.List(database.JobFilter{Type: jobLibraryBulk})
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views := make([]jobView, 0, len(list))
+		for i := range list {
+			views = append(views, newJobView(&list[i]))
+		}
+		json.NewEncoder(w).Encode(views)
+
+	case http.MethodPost:
+		var req bulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := s.validateBulkRequest(&req); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if isDryRun(r) {
+			if req.Action != bulkDelete {
+				httpError(w, "Only bulk deletes can be dry run", http.StatusBadRequest)
+				return
+			}
+			s.simulateBulkDelete(w, r, &req)
+			return
+		}
+		if req.Action == bulkDelete {
+			payload, err := json.Marshal(req)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			summary := fmt.Sprintf("Delete %d movies and %d shows", len(req.MovieIDs), len(req.ShowIDs))
+			if s.holdForApproval(w, r, opLibraryBulkDelete, summary, nil, payload) {
+				return
+			}
+		}
+
+		job, err := s.startBulkJob(r, &req)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(newJobView(job))
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startBulkJob submits a bulk job. r is the request it's started for, which
+// deletions are attributed to.
+func (s *Server) startBulkJob(r *http.Request, req *bulkRequest) (*database.Job, error) {
+	title := fmt.Sprintf("Bulk %s of %d movies and %d shows", req.Action, len(req.MovieIDs), len(req.ShowIDs))
+	job, err := s.submitJob(r, jobLibraryBulk, title, func(ctx context.Context, p *jobs.Progress) error {
+		return s.runBulkJob(ctx, p, r, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordAudit(r, "library.bulk", "job", &job.ID,
+		fmt.Sprintf("%s on %d movies and %d shows", req.Action, len(req.MovieIDs), len(req.ShowIDs)))
+	return job, nil
+}
+
+// handleLibraryTags handles GET /api/library/tags, listing the tags in use
+func (s *Server) handleLibraryTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tags, err := s.db.GetTagCounts()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+// validateBulkRequest checks a bulk request has what its action needs,
+// tidying its IDs and tags
+func (s *Server) validateBulkRequest(req *bulkRequest) error {
+	req.MovieIDs = uniqueIDs(req.MovieIDs)
+	req.ShowIDs = uniqueIDs(req.ShowIDs)
+	total := len(req.MovieIDs) + len(req.ShowIDs)
+	if total == 0 {
+		return fmt.Errorf("No movies or shows given")
+	}
+	if total > maxBulkItems {
+		return fmt.Errorf("A bulk operation can act on at most %d movies and shows", maxBulkItems)
+	}
+
+	switch req.Action {
+	case bulkDelete:
+	case bulkRefresh:
+		if !s.metadataConfigured() {
+			return fmt.Errorf("Metadata can't be refreshed until a TMDB API key is set")
+		}
+	case bulkMonitor:
+		if req.Monitored == nil {
+			return fmt.Errorf("monitored is required")
+		}
+	case bulkPreset:
+		if req.PresetID != nil {
+			if _, err := s.db.GetQualityPreset(*req.PresetID); err != nil {
+				return fmt.Errorf("Quality preset not found")
+			}
+		}
+	case bulkTag:
+		req.Tags = database.NormalizeTags(req.Tags)
+		if len(req.Tags) == 0 {
+			return fmt.Errorf("No tags given")
+		}
+	default:
+		return fmt.Errorf("action must be delete, refresh, monitor, preset or tag")
+	}
+	return nil
+}
+
+// uniqueIDs drops repeated IDs, keeping their order
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var out []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// runBulkJob acts on each movie and show of a bulk request in turn,
+// stopping early if the job is cancelled. r is the request that started the
+// job, for attributing deletions.
+func (s *Server) runBulkJob(ctx context.Context, p *jobs.Progress, r *http.Request, req *bulkRequest) error {
+	var preset *database.QualityPreset
+	if req.Action == bulkPreset && req.PresetID != nil {
+		preset, _ = s.db.GetQualityPreset(*req.PresetID)
+	}
+
+	result := bulkResult{Action: req.Action, Errors: []bulkItemError{}}
+	record := func(mediaType string, id int64, title string, err error) {
+		p.Step()
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, bulkItemError{MediaType: mediaType, MediaID: id, Title: title, Error: err.Error()})
+			return
+		}
+		result.Succeeded++
+	}
+	defer func() {
+		p.SetResult(result)
+		requestLog(r).Infof("Bulk %s finished: %d succeeded, %d failed", req.Action, result.Succeeded, result.Failed)
+	}()
+
+	p.SetTotal(len(req.MovieIDs) + len(req.ShowIDs))
+	for _, id := range req.MovieIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		movie, err := s.db.GetMovie(id)
+		if err != nil {
+			record("movie", id, "", fmt.Errorf("movie not found"))
+			continue
+		}
+		record("movie", id, movie.Title, s.bulkMovie(r, req, movie, preset))
+	}
+	for _, id := range req.ShowIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		show, err := s.db.GetShow(id)
+		if err != nil {
+			record("show", id, "", fmt.Errorf("show not found"))
+			continue
+		}
+		record("show", id, show.Title, s.bulkShow(r, req, show, preset))
+	}
+	return nil
+}
+
+// bulkMovie applies a bulk action to a movie
+func (s *Server) bulkMovie(r *http.Request, req *bulkRequest, movie *database.Movie, preset *database.QualityPreset) error {
+	switch req.Action {
+	case bulkDelete:
+		return s.deleteMovie(r, movie)
+	case bulkRefresh:
+		return s.metadata.FetchMovieMetadata(movie)
+	case bulkMonitor:
+		return s.updateQualityOverride("movie", movie.ID, func(o *database.MediaQualityOverride) {
+			o.Monitored = *req.Monitored
+		})
+	case bulkPreset:
+		if preset != nil && preset.MediaType != "movie" {
+			return fmt.Errorf("%s is a preset for shows", preset.Name)
+		}
+		return s.updateQualityOverride("movie", movie.ID, func(o *database.MediaQualityOverride) {
+			o.PresetID = req.PresetID
+		})
+	case bulkTag:
+		return s.db.AddMediaTags("movie", movie.ID, req.Tags)
+	}
+	return nil
+}
+
+// bulkShow applies a bulk action to a show
+func (s *Server) bulkShow(r *http.Request, req *bulkRequest, show *database.Show, preset *database.QualityPreset) error {
+	switch req.Action {
+	case bulkDelete:
+		return s.deleteShow(r, show)
+	case bulkRefresh:
+		return s.metadata.FetchShowMetadata(show)
+	case bulkMonitor:
+		return s.updateQualityOverride("show", show.ID, func(o *database.MediaQualityOverride) {
+			o.Monitored = *req.Monitored
+		})
+	case bulkPreset:
+		if preset != nil && preset.MediaType == "movie" {
+			return fmt.Errorf("%s is a preset for movies", preset.Name)
+		}
+		return s.updateQualityOverride("show", show.ID, func(o *database.MediaQualityOverride) {
+			o.PresetID = req.PresetID
+		})
+	case bulkTag:
+		return s.db.AddMediaTags("show", show.ID, req.Tags)
+	}
+	return nil
+}
+
+// updateQualityOverride changes a movie or show's quality override, creating
+// a monitored one if it has none
+func (s *Server) updateQualityOverride(mediaType string, mediaID int64, change func(*database.MediaQualityOverride)) error {
+	override, err := s.db.GetMediaQualityOverride(mediaID, mediaType)
+	if err != nil {
+		return err
+	}
+	if override == nil {
+		override = &database.MediaQualityOverride{MediaID: mediaID, MediaType: mediaType, Monitored: true}
+	}
+	change(override)
+	// Replaced rather than updated, as nothing stops a second row being added
+	if err := s.db.DeleteMediaQualityOverride(mediaID, mediaType); err != nil {
+		return err
+	}
+	return s.db.SetMediaQualityOverride(override)
+}
+
+// deleteShow moves a show's episode files to the trash and removes the show
+// from the library
+func (s *Server) deleteShow(r *http.Request, show *database.Show) error {
+	seasons, err := s.db.GetSeasonsByShow(show.ID)
+	if err != nil {
+		return err
+	}
+	var deletedBy *int64
+	if user := s.getCurrentUser(r); user != nil {
+		deletedBy = &user.ID
+	}
+
+	episodes := 0
+	for _, season := range seasons {
+		eps, err := s.db.GetEpisodesBySeason(season.ID)
+		if err != nil {
+			return err
+		}
+		for i := range eps {
+			ep := &eps[i]
+			if err := s.trash.Delete(&database.TrashItem{
+				MediaType:    "episode",
+				MediaID:      ep.ID,
+				Title:        fmt.Sprintf("%s - S%02dE%02d - %s", show.Title, season.SeasonNumber, ep.EpisodeNumber, ep.Title),
+				OriginalPath: ep.Path,
+				DeletedBy:    deletedBy,
+			}, ep); err != nil {
+				return fmt.Errorf("couldn't move %s to the trash: %w", ep.Path, err)
+			}
+			episodes++
+		}
+	}
+
+	if err := s.db.DeleteShow(show.ID); err != nil {
+		return err
+	}
+	s.recordDeletion(r, "show", show.TmdbID, show.Title,
+		map[string]interface{}{"showId": show.ID, "path": show.Path, "episodes": episodes})
+	return nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Change feed
+
+const (
+	defaultChangesLimit = 500
+	maxChangesLimit     = 5000
+)
+
+// handleChanges handles GET /api/changes?since=cursor[&tables=movies,shows][&limit=n].
+// It returns the latest change of each row changed after the cursor, oldest
+// first, and the cursor to pass next time. A cursor older than the retained
+// journal gets 410 Gone: the client has to reload /api/snapshot. Like the
+// snapshot, it only has the libraries the user can see and the watch state
+// of their profile.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	since, err := strconv.ParseInt(query.Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		httpError(w, "since must be a cursor from /api/snapshot or /api/changes", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultChangesLimit
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if l > maxChangesLimit {
+			l = maxChangesLimit
+		}
+		limit = l
+	}
+
+	filter := database.ChangeFilter{ProfileID: s.getActiveProfileID(r)}
+	for _, t := range strings.Split(query.Get("tables"), ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !isJournaledTable(t) {
+			httpError(w, "Unknown table: "+t, http.StatusBadRequest)
+			return
+		}
+		filter.Tables = append(filter.Tables, t)
+	}
+	if user.LibraryIDs != nil && user.Role != "admin" {
+		libraries, err := s.db.GetLibraries()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		filter.LibraryIDs = []int64{}
+		for _, lib := range libraries {
+			if user.CanAccessLibrary(lib.ID) {
+				filter.LibraryIDs = append(filter.LibraryIDs, lib.ID)
+			}
+		}
+	}
+
+	cursor, err := s.db.GetChangeCursor()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// Changes before the floor were dropped; a cursor past the latest change
+	// comes from a database that has since been replaced
+	if since < s.db.GetChangeFloor() || since > cursor {
+		writeErrorDetails(w, http.StatusGone, codeCursorExpired, "Changes since the cursor are gone, resync",
+			map[string]interface{}{"resync": true, "cursor": cursor})
+		return
+	}
+
+	changes, err := s.db.GetChanges(since, filter, limit+1)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	if changes == nil {
+		changes = []database.Change{}
+	}
+
+	// Resume after the last change sent, or skip to the latest when caught up
+	next := cursor
+	if hasMore {
+		next = changes[len(changes)-1].Cursor
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes": changes,
+		"cursor":  next,
+		"hasMore": hasMore,
+	})
+}
+
+// handleChangeJournal handles /api/changes/journal: GET reports the journal's
+// size and bounds, POST prunes entries past the retention period and compacts
+// the rest now instead of waiting for the cleanup task
+func (s *Server) handleChangeJournal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if isDryRun(r) {
+			s.simulateChangeJournalCleanup(w, r)
+			return
+		}
+		pruned, err := s.db.PruneChangeJournal(s.db.GetChangeRetentionDays())
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		compacted, err := s.db.CompactChangeJournal()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status, err := s.db.GetChangeJournalStatus()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pruned":    pruned,
+			"compacted": compacted,
+			"status":    status,
+		})
+		return
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, err := s.db.GetChangeJournalStatus()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+func isJournaledTable(table string) bool {
+	for _, t := range database.JournaledTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/comic"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Comics
+//
+// CBZ and CBR books are read a page at a time. Pages come out of the archive
+// as they're requested, scaled down when the reader asks for a width, so a
+// phone doesn't download full-size scans. Each profile keeps the page it's
+// on. Comics are found in book libraries, and in comics libraries, which
+// hold nothing else.
+
+// maxPageWidth bounds the width pages are resized to
+const maxPageWidth = 4096
+
+// comicDetail is a comic with its pages and the profile's position in it
+type comicDetail struct {
+	database.Book
+	Pages    []string                  `json:"pages"` // Page URLs, in reading order
+	Manga    bool                      `json:"manga"` // Read right to left
+	Progress *database.ReadingProgress `json:"progress"`
+}
+
+// comicSummary is a comic in the list, with the profile's position in it
+type comicSummary struct {
+	database.Book
+	Progress *database.ReadingProgress `json:"progress"`
+}
+
+// handleComics handles GET /api/comics: the comics in book and comics
+// libraries the user can see, by series and issue. ?libraryId= and ?series=
+// narrow it down.
+func (s *Server) handleComics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var libraryID int64
+	if v := r.URL.Query().Get("libraryId"); v != "" {
+		var err error
+		if libraryID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid library ID")
+			return
+		}
+	}
+	series := r.URL.Query().Get("series")
+
+	books, err := s.db.GetBooks()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	comics := []comicSummary{}
+	for _, book := range books {
+		if book.Format != "cbz" && book.Format != "cbr" {
+			continue
+		}
+		if !user.CanAccessLibrary(book.LibraryID) || (libraryID != 0 && book.LibraryID != libraryID) {
+			continue
+		}
+		if series != "" && (book.Series == nil || !strings.EqualFold(*book.Series, series)) {
+			continue
+		}
+		item := comicSummary{Book: book}
+		if profileID != nil {
+			if item.Progress, err = s.db.GetReadingProgress(*profileID, book.ID); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		comics = append(comics, item)
+	}
+
+	// Issues of a series together and in order; comics without a series
+	// after, by title
+	sort.SliceStable(comics, func(i, j int) bool {
+		a, b := comics[i].Series, comics[j].Series
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil && !strings.EqualFold(*a, *b) {
+			return strings.ToLower(*a) < strings.ToLower(*b)
+		}
+		return comic.NaturalLess(comics[i].Title, comics[j].Title)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comics)
+}
+
+// handleComic handles /api/comics/{id} and its pages and progress:
+//
+//	GET    /api/comics/{id}             comic with its pages
+//	GET    /api/comics/{id}/page/{n}    page n, from 1; ?width= scales it down
+//	GET    /api/comics/{id}/progress    the profile's page
+//	PUT    /api/comics/{id}/progress    save the profile's page
+//	DELETE /api/comics/{id}/progress    mark unread
+func (s *Server) handleComic(w http.ResponseWriter, r *http.Request) {
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/comics/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	book, err := s.db.GetBook(id)
+	if err != nil || (book.Format != "cbz" && book.Format != "cbr") {
+		httpError(w, "Comic not found", http.StatusNotFound)
+		return
+	}
+	if !user.CanAccessLibrary(book.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		s.handleComicDetail(w, r, book)
+	case len(parts) == 3 && parts[1] == "page":
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			httpError(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+		s.handleComicPage(w, r, book, n)
+	case len(parts) == 2 && parts[1] == "progress":
+		s.handleComicProgress(w, r, book)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleComicDetail returns a comic with its page URLs
+func (s *Server) handleComicDetail(w http.ResponseWriter, r *http.Request, book *database.Book) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	archive, err := comic.Open(book.Path)
+	if err != nil {
+		httpError(w, fmt.Sprintf("Failed to open comic: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer archive.Close()
+
+	detail := comicDetail{Book: *book, Pages: make([]string, len(archive.Pages))}
+	for i := range archive.Pages {
+		detail.Pages[i] = fmt.Sprintf("/api/comics/%d/page/%d", book.ID, i+1)
+	}
+	if archive.Info != nil {
+		detail.Manga = archive.Info.Manga == "YesAndRightToLeft"
+	}
+	if profileID := s.getActiveProfileID(r); profileID != nil {
+		detail.Progress, _ = s.db.GetReadingProgress(*profileID, book.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// handleComicPage serves one page of a comic. Pages don't change while the
+// file doesn't, so they're cached by the file's size and page.
+func (s *Server) handleComicPage(w http.ResponseWriter, r *http.Request, book *database.Book, n int) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	width := 0
+	if v := r.URL.Query().Get("width"); v != "" {
+		var err error
+		if width, err = strconv.Atoi(v); err != nil || width < 1 {
+			httpError(w, "Invalid width", http.StatusBadRequest)
+			return
+		}
+		width = min(width, maxPageWidth)
+	}
+
+	etag := fmt.Sprintf(`"%d-%d-%d-%d"`, book.ID, book.Size, n, width)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	archive, err := comic.Open(book.Path)
+	if err != nil {
+		httpError(w, fmt.Sprintf("Failed to open comic: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer archive.Close()
+
+	if n < 1 || n > len(archive.Pages) {
+		httpError(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	data, contentType, err := archive.Page(n - 1)
+	if err != nil {
+		httpError(w, fmt.Sprintf("Failed to read page: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if width > 0 {
+		// A page that can't be decoded is sent as it is
+		if resized, resizedType, err := comic.Resize(data, contentType, width); err == nil {
+			data, contentType = resized, resizedType
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// handleComicProgress gets, saves or clears the active profile's page
+func (s *Server) handleComicProgress(w http.ResponseWriter, r *http.Request, book *database.Book) {
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil {
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		progress, err := s.db.GetReadingProgress(*profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(progress)
+
+	case http.MethodPut:
+		var req struct {
+			Page int `json:"page"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		pageCount := 0
+		if book.PageCount != nil {
+			pageCount = *book.PageCount
+		}
+		if req.Page < 1 || (pageCount > 0 && req.Page > pageCount) {
+			httpError(w, "Page out of range", http.StatusBadRequest)
+			return
+		}
+		if pageCount == 0 {
+			pageCount = req.Page
+		}
+		if err := s.db.SaveReadingProgress(*profileID, book.ID, req.Page, pageCount); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		progress, err := s.db.GetReadingProgress(*profileID, book.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(progress)
+
+	case http.MethodDelete:
+		if err := s.db.DeleteReadingProgress(*profileID, book.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
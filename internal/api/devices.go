@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Devices
+//
+// Clients name the device they sign in from, so sessions and streams can be
+// told apart. Each device keeps its own playback preferences: a bitrate cap
+// for transcoding and whether to play files directly or transcode them.
+// Users manage their own devices; admins can see and block anyone's.
+
+// deviceTypes are the kinds of device a client can register as
+var deviceTypes = []string{"web", "mobile", "tablet", "tv", "desktop", "other"}
+
+// Preferred players. Without one the server picks by container.
+var devicePlayers = []string{"direct", "transcode"}
+
+// maxDeviceName bounds device names and identifiers
+const maxDeviceName = 100
+
+// errDeviceBlocked is returned when signing in from a blocked device
+var errDeviceBlocked = errors.New("device is blocked")
+
+// deviceInfo is the device a client names when it signs in
+type deviceInfo struct {
+	ID   string `json:"deviceId"`
+	Name string `json:"deviceName"`
+	Type string `json:"deviceType"`
+}
+
+// registerDevice records the device a session signed in from. Clients that
+// don't name a device sign in without one. Returns errDeviceBlocked if an
+// admin has blocked the device.
+func (s *Server) registerDevice(r *http.Request, user *database.User, token string, info deviceInfo) (*database.Device, error) {
+	identifier := strings.TrimSpace(info.ID)
+	if identifier == "" {
+		return nil, nil
+	}
+	if len(identifier) > maxDeviceName {
+		identifier = identifier[:maxDeviceName]
+	}
+	name := strings.TrimSpace(info.Name)
+	if name == "" {
+		name = identifier
+	}
+	if len(name) > maxDeviceName {
+		name = name[:maxDeviceName]
+	}
+	deviceType := strings.ToLower(strings.TrimSpace(info.Type))
+	if !slices.Contains(deviceTypes, deviceType) {
+		deviceType = "other"
+	}
+
+	device, err := s.db.RegisterDevice(user.ID, identifier, name, deviceType, netaccess.ClientIP(r))
+	if err != nil {
+		return nil, err
+	}
+	if device.Blocked {
+		return device, errDeviceBlocked
+	}
+	if err := s.db.SetSessionDevice(token, device.ID); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// allowSessionDevice checks the device a session signed in from hasn't been
+// blocked since. A blocked device's sessions are ended and it gets 403, so
+// blocking takes effect even for sessions that outlived the block.
+func (s *Server) allowSessionDevice(w http.ResponseWriter, r *http.Request, session *database.Session) bool {
+	if session == nil || session.DeviceID == nil {
+		return true
+	}
+	device, err := s.db.GetDevice(*session.DeviceID)
+	if err != nil || !device.Blocked {
+		return true
+	}
+	if err := s.db.DeleteDeviceSessions(device.ID); err != nil {
+		requestLog(r).Errorf("Failed to sign out device %d: %v", device.ID, err)
+	}
+	localizedError(w, r, http.StatusForbidden, "This device has been blocked")
+	return false
+}
+
+// currentDevice returns the device the request's session signed in from, if
+// it named one
+func (s *Server) currentDevice(r *http.Request) *database.Device {
+	session, ok := r.Context().Value(sessionContextKey).(*database.Session)
+	if !ok || session.DeviceID == nil {
+		return nil
+	}
+	device, err := s.db.GetDevice(*session.DeviceID)
+	if err != nil {
+		requestLog(r).Errorf("Failed to get device %d: %v", *session.DeviceID, err)
+	}
+	return device
+}
+
+// handleDevices handles GET /api/devices: the user's devices, or with
+// ?all=true everyone's for admins
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID := user.ID
+	if r.URL.Query().Get("all") == "true" {
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+			return
+		}
+		userID = 0
+	}
+	devices, err := s.db.GetDevices(userID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
+}
+
+// handleDevice handles /api/devices/{id}. Users can rename their devices,
+// set their playback preferences and remove them; only admins can block a
+// device or touch someone else's.
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/devices/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	device, err := s.db.GetDevice(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if device == nil || (device.UserID != user.ID && user.Role != "admin") {
+		httpError(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(device)
+
+	case http.MethodPut:
+		var req struct {
+			Name            *string `json:"name"`
+			MaxBitrate      *int    `json:"maxBitrate"`      // 0 removes the cap
+			PreferredPlayer *string `json:"preferredPlayer"` // Empty lets the server pick
+			Blocked         *bool   `json:"blocked"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" || len(name) > maxDeviceName {
+				httpError(w, "Name must be 1 to 100 characters", http.StatusBadRequest)
+				return
+			}
+			device.Name = name
+		}
+		if req.MaxBitrate != nil {
+			switch {
+			case *req.MaxBitrate < 0:
+				httpError(w, "Invalid maxBitrate", http.StatusBadRequest)
+				return
+			case *req.MaxBitrate == 0:
+				device.MaxBitrate = nil
+			default:
+				device.MaxBitrate = req.MaxBitrate
+			}
+		}
+		if req.PreferredPlayer != nil {
+			switch {
+			case *req.PreferredPlayer == "":
+				device.PreferredPlayer = nil
+			case slices.Contains(devicePlayers, *req.PreferredPlayer):
+				device.PreferredPlayer = req.PreferredPlayer
+			default:
+				httpError(w, "preferredPlayer must be direct or transcode", http.StatusBadRequest)
+				return
+			}
+		}
+		changedBlock := req.Blocked != nil && *req.Blocked != device.Blocked
+		if changedBlock {
+			if user.Role != "admin" {
+				writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+				return
+			}
+			device.Blocked = *req.Blocked
+		}
+
+		if err := s.db.UpdateDevice(device); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if changedBlock {
+			action := "device.unblock"
+			if device.Blocked {
+				action = "device.block"
+				// A blocked device is signed out straight away
+				if err := s.db.DeleteDeviceSessions(device.ID); err != nil {
+					requestLog(r).Errorf("Failed to sign out device %d: %v", device.ID, err)
+				}
+			}
+			s.recordAudit(r, action, "device", &device.ID, device.Username+": "+device.Name)
+		}
+		json.NewEncoder(w).Encode(device)
+
+	case http.MethodDelete:
+		// Removing a blocked device would let it sign in again
+		if device.Blocked && user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+			return
+		}
+		if err := s.db.DeleteDeviceSessions(device.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.db.DeleteDevice(device.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/outpost/outpost/internal/database"
 	"github.com/outpost/outpost/internal/downloadclient"
@@ -13,6 +15,13 @@ import (
 
 // Download client handlers
 
+// downloadClientResponse is a download client with the free space, queue
+// and errors it last reported
+type downloadClientResponse struct {
+	database.DownloadClient
+	Status *downloadclient.ClientStatus `json:"status,omitempty"`
+}
+
 func (s *Server) handleDownloadClients(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -20,40 +29,45 @@ func (s *Server) handleDownloadClients(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		clients, err := s.db.GetDownloadClients()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if clients == nil {
 			clients = []database.DownloadClient{}
 		}
 		// Don't expose passwords in responses
+		response := make([]downloadClientResponse, len(clients))
 		for i := range clients {
 			clients[i].Password = ""
+			response[i].DownloadClient = clients[i]
+			if status, ok := s.downloads.GetClientStatus(clients[i].ID); ok && clients[i].Enabled {
+				response[i].Status = &status
+			}
 		}
-		json.NewEncoder(w).Encode(clients)
+		json.NewEncoder(w).Encode(response)
 
 	case http.MethodPost:
 		var client database.DownloadClient
 		if err := json.NewDecoder(r.Body).Decode(&client); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if client.Name == "" || client.Type == "" || client.Host == "" || client.Port == 0 {
-			http.Error(w, "Name, type, host, and port are required", http.StatusBadRequest)
+			httpError(w, "Name, type, host, and port are required", http.StatusBadRequest)
 			return
 		}
 
 		// Validate client type
 		validTypes := map[string]bool{"qbittorrent": true, "transmission": true, "sabnzbd": true, "nzbget": true}
 		if !validTypes[client.Type] {
-			http.Error(w, "Invalid client type", http.StatusBadRequest)
+			httpError(w, "Invalid client type", http.StatusBadRequest)
 			return
 		}
 
 		client.Enabled = true
 		if err := s.db.CreateDownloadClient(&client); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -62,7 +76,7 @@ func (s *Server) handleDownloadClients(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(client)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -74,20 +88,20 @@ func (s *Server) handleDownloadClient(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Client ID required", http.StatusBadRequest)
+		httpError(w, "Client ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid client ID")
 		return
 	}
 
 	// Handle test endpoint
 	if len(parts) == 2 && parts[1] == "test" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		if err := s.downloads.TestClient(id); err != nil {
@@ -108,22 +122,27 @@ func (s *Server) handleDownloadClient(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		client, err := s.db.GetDownloadClient(id)
 		if err != nil {
-			http.Error(w, "Client not found", http.StatusNotFound)
+			httpError(w, "Client not found", http.StatusNotFound)
 			return
 		}
 		client.Password = ""
-		json.NewEncoder(w).Encode(client)
+		response := downloadClientResponse{DownloadClient: *client}
+		if client.Enabled {
+			status := s.downloads.CurrentClientStatus(client)
+			response.Status = &status
+		}
+		json.NewEncoder(w).Encode(response)
 
 	case http.MethodPut:
 		var req database.DownloadClient
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		client, err := s.db.GetDownloadClient(id)
 		if err != nil {
-			http.Error(w, "Client not found", http.StatusNotFound)
+			httpError(w, "Client not found", http.StatusNotFound)
 			return
 		}
 
@@ -155,7 +174,7 @@ func (s *Server) handleDownloadClient(w http.ResponseWriter, r *http.Request) {
 		client.Enabled = req.Enabled
 
 		if err := s.db.UpdateDownloadClient(client); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -164,13 +183,13 @@ func (s *Server) handleDownloadClient(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if err := s.db.DeleteDownloadClient(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -178,13 +197,13 @@ func (s *Server) handleDownloads(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	downloads, err := s.downloads.GetAllDownloads()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -195,6 +214,131 @@ func (s *Server) handleDownloads(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(downloads)
 }
 
+// Bandwidth schedule handlers
+
+// validateBandwidthSchedule checks schedule fields before saving
+func validateBandwidthSchedule(schedule *database.BandwidthSchedule) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if schedule.Days == "" {
+		schedule.Days = "0,1,2,3,4,5,6"
+	}
+	if _, err := downloadclient.ParseDays(schedule.Days); err != nil {
+		return err
+	}
+	if _, err := downloadclient.ParseClock(schedule.StartTime); err != nil {
+		return err
+	}
+	if _, err := downloadclient.ParseClock(schedule.EndTime); err != nil {
+		return err
+	}
+	if schedule.DownloadLimit < 0 || schedule.UploadLimit < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+func (s *Server) handleBandwidthSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		schedules, err := s.db.GetBandwidthSchedules()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if schedules == nil {
+			schedules = []database.BandwidthSchedule{}
+		}
+		json.NewEncoder(w).Encode(schedules)
+
+	case http.MethodPost:
+		schedule := database.BandwidthSchedule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if err := validateBandwidthSchedule(&schedule); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.CreateBandwidthSchedule(&schedule); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.downloads.ApplyBandwidthSchedules(time.Now(), false)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(schedule)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleBandwidthSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/bandwidth-schedules/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid schedule ID")
+		return
+	}
+
+	schedule, err := s.db.GetBandwidthSchedule(id)
+	if err != nil {
+		httpError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(schedule)
+
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(schedule); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		schedule.ID = id
+		if err := validateBandwidthSchedule(schedule); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.UpdateBandwidthSchedule(schedule); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.downloads.ApplyBandwidthSchedules(time.Now(), false)
+		json.NewEncoder(w).Encode(schedule)
+
+	case http.MethodDelete:
+		if err := s.db.DeleteBandwidthSchedule(id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.downloads.ApplyBandwidthSchedules(time.Now(), false)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBandwidthStatus returns the speed limit mode currently applied to each client
+func (s *Server) handleBandwidthStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.downloads.GetBandwidthStatus())
+}
+
 // Indexer handlers
 
 func (s *Server) handleIndexers(w http.ResponseWriter, r *http.Request) {
@@ -204,7 +348,7 @@ func (s *Server) handleIndexers(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		indexers, err := s.db.GetIndexers()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if indexers == nil {
@@ -219,27 +363,28 @@ func (s *Server) handleIndexers(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var idx database.Indexer
 		if err := json.NewDecoder(r.Body).Decode(&idx); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if idx.Name == "" || idx.Type == "" || idx.URL == "" {
-			http.Error(w, "Name, type, and URL are required", http.StatusBadRequest)
+			httpError(w, "Name, type, and URL are required", http.StatusBadRequest)
 			return
 		}
 
 		// Validate indexer type
 		validTypes := map[string]bool{"torznab": true, "newznab": true, "prowlarr": true}
 		if !validTypes[idx.Type] {
-			http.Error(w, "Invalid indexer type", http.StatusBadRequest)
+			httpError(w, "Invalid indexer type", http.StatusBadRequest)
 			return
 		}
 
 		idx.Enabled = true
 		if err := s.db.CreateIndexer(&idx); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.notifyIndexerChange(r, "added", idx.Name, idx.APIKey != "")
 
 		// Add to manager
 		config := &indexer.IndexerConfig{
@@ -265,7 +410,7 @@ func (s *Server) handleIndexers(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(idx)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -277,20 +422,20 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Indexer ID required", http.StatusBadRequest)
+		httpError(w, "Indexer ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid indexer ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid indexer ID")
 		return
 	}
 
 	// Handle test endpoint
 	if len(parts) == 2 && parts[1] == "test" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		if err := s.indexers.TestIndexer(id); err != nil {
@@ -310,12 +455,12 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 	// Handle capabilities endpoint
 	if len(parts) == 2 && parts[1] == "capabilities" {
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		caps, err := s.indexers.GetCapabilities(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(caps)
@@ -326,7 +471,7 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		idx, err := s.db.GetIndexer(id)
 		if err != nil {
-			http.Error(w, "Indexer not found", http.StatusNotFound)
+			httpError(w, "Indexer not found", http.StatusNotFound)
 			return
 		}
 		idx.APIKey = ""
@@ -335,13 +480,13 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var req database.Indexer
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		idx, err := s.db.GetIndexer(id)
 		if err != nil {
-			http.Error(w, "Indexer not found", http.StatusNotFound)
+			httpError(w, "Indexer not found", http.StatusNotFound)
 			return
 		}
 
@@ -364,9 +509,10 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 		idx.ContentTypes = req.ContentTypes
 
 		if err := s.db.UpdateIndexer(idx); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.notifyIndexerChange(r, "changed", idx.Name, req.APIKey != "")
 
 		// Update manager
 		s.indexers.RemoveIndexer(id)
@@ -388,14 +534,18 @@ func (s *Server) handleIndexer(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(idx)
 
 	case http.MethodDelete:
+		idx, _ := s.db.GetIndexer(id)
 		s.indexers.RemoveIndexer(id)
 		if err := s.db.DeleteIndexer(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if idx != nil {
+			s.notifyIndexerChange(r, "removed", idx.Name, false)
+		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
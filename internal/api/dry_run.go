@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Dry runs
+//
+// The destructive maintenance endpoints - clearing the library, bulk
+// deletes, emptying the trash, pruning the change journal, deleting denied
+// requests and restoring a backup - take dryRun=true. They then answer with
+// what they would change, the rows and files and how many, without changing
+// anything, and record the simulation in the audit log as
+// "<action>.simulated". A dry run doesn't wait for a second admin in
+// four-eyes mode, since there's nothing to confirm.
+
+// isDryRun reports whether a request asks for a dry run
+func isDryRun(r *http.Request) bool {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	return dryRun
+}
+
+// dryRunResult is what a dry run answers with
+type dryRunResult struct {
+	DryRun bool             `json:"dryRun"`
+	Action string           `json:"action"`
+	Counts map[string]int64 `json:"counts"`          // Rows, files or bytes affected, by kind
+	Paths  []string         `json:"paths,omitempty"` // Files that would be moved or removed
+	Rows   interface{}      `json:"rows,omitempty"`  // Records that would be deleted
+	Notes  []string         `json:"notes,omitempty"`
+}
+
+func newDryRunResult(action string) *dryRunResult {
+	return &dryRunResult{DryRun: true, Action: action, Counts: map[string]int64{}}
+}
+
+// writeDryRun records a simulated action in the audit log and answers with
+// what it would have done
+func (s *Server) writeDryRun(w http.ResponseWriter, r *http.Request, action, targetType, details string, result interface{}) {
+	s.recordAudit(r, action+".simulated", targetType, nil, details)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// simulateLibraryClear answers a dry run of clearing the library
+func (s *Server) simulateLibraryClear(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.db.CountLibraryData()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := newDryRunResult(opLibraryClear)
+	for table, n := range counts {
+		result.Counts[table] = int64(n)
+	}
+	result.Notes = append(result.Notes, "Files in library folders are left alone")
+	s.writeDryRun(w, r, opLibraryClear, "library", fmt.Sprintf("%d movies and %d shows", counts["movies"], counts["shows"]), result)
+}
+
+// simulateClearDeniedRequests answers a dry run of deleting denied requests
+func (s *Server) simulateClearDeniedRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := s.db.GetRequestsByStatus("denied")
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if requests == nil {
+		requests = []database.Request{}
+	}
+	result := newDryRunResult(opClearDeniedRequests)
+	result.Counts["requests"] = int64(len(requests))
+	result.Rows = requests
+	s.writeDryRun(w, r, opClearDeniedRequests, "request", fmt.Sprintf("%d requests", len(requests)), result)
+}
+
+// bulkDeleteRow is a movie, show or episode a bulk delete would remove
+type bulkDeleteRow struct {
+	MediaType string `json:"mediaType"`
+	MediaID   int64  `json:"mediaId"`
+	Title     string `json:"title"`
+	Path      string `json:"path,omitempty"`
+}
+
+// simulateBulkDelete answers a dry run of a bulk delete with the movies,
+// shows and episodes it would remove and the files it would move to the
+// trash
+func (s *Server) simulateBulkDelete(w http.ResponseWriter, r *http.Request, req *bulkRequest) {
+	result := newDryRunResult("library.bulk")
+	rows := []bulkDeleteRow{}
+	addFile := func(path string) {
+		if path != "" {
+			result.Paths = append(result.Paths, path)
+			result.Counts["files"]++
+		}
+	}
+
+	for _, id := range req.MovieIDs {
+		movie, err := s.db.GetMovie(id)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Movie %d not found", id))
+			continue
+		}
+		rows = append(rows, bulkDeleteRow{MediaType: "movie", MediaID: movie.ID, Title: movie.Title, Path: movie.Path})
+		result.Counts["movies"]++
+		addFile(movie.Path)
+	}
+	for _, id := range req.ShowIDs {
+		show, err := s.db.GetShow(id)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Show %d not found", id))
+			continue
+		}
+		rows = append(rows, bulkDeleteRow{MediaType: "show", MediaID: show.ID, Title: show.Title, Path: show.Path})
+		result.Counts["shows"]++
+		seasons, err := s.db.GetSeasonsByShow(show.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, season := range seasons {
+			episodes, err := s.db.GetEpisodesBySeason(season.ID)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, ep := range episodes {
+				rows = append(rows, bulkDeleteRow{
+					MediaType: "episode",
+					MediaID:   ep.ID,
+					Title:     fmt.Sprintf("%s - S%02dE%02d - %s", show.Title, season.SeasonNumber, ep.EpisodeNumber, ep.Title),
+					Path:      ep.Path,
+				})
+				result.Counts["episodes"]++
+				addFile(ep.Path)
+			}
+		}
+	}
+	result.Rows = rows
+	if s.trash.Retention() == 0 {
+		result.Notes = append(result.Notes, "The trash keeps nothing, so files would be deleted for good")
+	}
+	s.writeDryRun(w, r, "library.bulk", "bulk",
+		fmt.Sprintf("%s on %d movies and %d shows", req.Action, len(req.MovieIDs), len(req.ShowIDs)), result)
+}
+
+// simulateTrashEmpty answers a dry run of emptying the trash
+func (s *Server) simulateTrashEmpty(w http.ResponseWriter, r *http.Request) {
+	items, err := s.trash.List()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := newDryRunResult("trash.empty")
+	result.Counts["items"] = int64(len(items))
+	for _, item := range items {
+		result.Paths = append(result.Paths, item.TrashPath)
+		result.Counts["bytes"] += item.Size
+	}
+	if items == nil {
+		items = []database.TrashItem{}
+	}
+	result.Rows = items
+	s.writeDryRun(w, r, "trash.empty", "trash", fmt.Sprintf("%d items", len(items)), result)
+}
+
+// simulateChangeJournalCleanup answers a dry run of pruning and compacting
+// the change journal
+func (s *Server) simulateChangeJournalCleanup(w http.ResponseWriter, r *http.Request) {
+	retention := s.db.GetChangeRetentionDays()
+	pruned, compacted, err := s.db.SimulateChangeJournalCleanup(retention)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := newDryRunResult("changes.cleanup")
+	result.Counts["pruned"] = pruned
+	result.Counts["compacted"] = compacted
+	if retention <= 0 {
+		result.Notes = append(result.Notes, "Retention is 0, so entries are kept forever and only compacted")
+	}
+	s.writeDryRun(w, r, "changes.cleanup", "change_journal", fmt.Sprintf("%d pruned, %d compacted", pruned, compacted), result)
+}
+
+// simulateRestore answers a dry run of restoring a backup, running the
+// restore and rolling it back
+func (s *Server) simulateRestore(w http.ResponseWriter, r *http.Request, backup *database.Backup, mode string) {
+	result, err := s.db.SimulateRestoreBackup(backup, mode)
+	if err != nil {
+		requestLog(r).Errorf("Failed to simulate backup restore: %v", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeDryRun(w, r, opBackupRestore, "backup", fmt.Sprintf("Backup from %s (%s)", backup.CreatedAt.Format("2006-01-02 15:04"), mode), result)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error responses
+//
+// Every error the API answers with is a JSON envelope:
+//
+//	{"code": "not_found", "message": "Movie not found", "details": {...}}
+//
+// code is stable, for clients to branch on. message is for people and may
+// change or be translated. details, when there are any, depend on the code.
+// Most codes follow the status; handlers give a more specific one where a
+// client can do something about it, such as picking a profile or asking an
+// administrator.
+
+// Error codes
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeGone             = "gone"
+	codeUpgradeRequired  = "upgrade_required"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+	codeUpstream         = "upstream_error" // A service Outpost relies on failed
+	codeUnavailable      = "unavailable"
+
+	codeInvalidBody    = "invalid_body"    // The body isn't valid JSON, or not what's expected
+	codeInvalidID      = "invalid_id"      // An ID in the path or query isn't a number
+	codeInvalidSetting = "invalid_setting" // details: {"key"}
+	codeAdminRequired  = "admin_required"
+	codeNoProfile      = "no_profile" // Select a profile first
+	codeNotConfigured  = "not_configured"
+	codeMaintenance    = "maintenance"    // details: {"message", "since"}
+	codeServerBusy     = "server_busy"    // details: {"resource"}
+	codeCursorExpired  = "cursor_expired" // details: {"resync", "cursor"}
+)
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// statusCodes are the codes errors get from their status
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusMethodNotAllowed:    codeMethodNotAllowed,
+	http.StatusConflict:            codeConflict,
+	http.StatusGone:                codeGone,
+	http.StatusUpgradeRequired:     codeUpgradeRequired,
+	http.StatusTooManyRequests:     codeRateLimited,
+	http.StatusInternalServerError: codeInternal,
+	http.StatusBadGateway:          codeUpstream,
+	http.StatusServiceUnavailable:  codeUnavailable,
+	http.StatusGatewayTimeout:      codeUpstream,
+}
+
+// statusCode returns the code of an error with a status
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return codeInternal
+	}
+	return codeBadRequest
+}
+
+// writeErrorDetails answers with an error envelope
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	h := w.Header()
+	// Anything set for the response that was meant to be sent no longer
+	// applies, as with http.Error
+	h.Del("Content-Length")
+	h.Del("Content-Disposition")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Code: code, Message: message, Details: details})
+}
+
+// writeError answers with an error envelope without details
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorDetails(w, status, code, message, nil)
+}
+
+// httpError answers like http.Error, with an error envelope whose code
+// follows the status
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, status, statusCode(status), message)
+}
+
+// notFound answers like http.NotFound
+func notFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, codeNotFound, "Not found")
+}
+
+// writeInvalidSetting answers 400 for a setting that can't be saved as
+// given
+func writeInvalidSetting(w http.ResponseWriter, key, message string) {
+	writeErrorDetails(w, http.StatusBadRequest, codeInvalidSetting, message, map[string]string{"key": key})
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/downloadclient"
+)
+
+// Server-sent events
+//
+// /api/events streams the data clients otherwise poll for - scan progress,
+// downloads, notifications, tasks and system status - for clients that can't
+// hold a WebSocket open, e.g. behind proxies that only pass plain HTTP; /api/ws
+// sends the same events over a WebSocket. Each topic is checked on its own
+// interval and an event is only sent when its data changed.
+
+const (
+	// eventsHeartbeat is how often an idle stream gets a comment line so
+	// proxies don't close it; ?heartbeat= (seconds) overrides it
+	eventsHeartbeat = 15 * time.Second
+	// eventsRetry is how long browsers wait before reconnecting a dropped stream
+	eventsRetry = 5 * time.Second
+)
+
+// eventTopic is a kind of data a client can subscribe to
+type eventTopic struct {
+	name      string
+	interval  time.Duration
+	adminOnly bool
+}
+
+// eventTopics lists the topics in the order their first events are sent
+var eventTopics = []eventTopic{
+	{name: "scan", interval: time.Second},
+	{name: "downloads", interval: 3 * time.Second, adminOnly: true},
+	{name: "notifications", interval: 5 * time.Second},
+	{name: "tasks", interval: 2 * time.Second, adminOnly: true},
+	{name: "status", interval: 5 * time.Second},
+}
+
+// handleEvents handles GET /api/events?topics=scan,downloads,notifications,
+// tasks,status. Without topics, every topic the user may see is sent. Each event is named
+// after its topic and carries the same JSON as the matching polling endpoint;
+// notifications events carry the unread count and any new notifications.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	isAdmin := user.Role == "admin"
+
+	topics, err := parseEventTopics(r.URL.Query().Get("topics"), isAdmin)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errTopicForbidden {
+			status = http.StatusForbidden
+		}
+		httpError(w, err.Error(), status)
+		return
+	}
+
+	heartbeatEvery := eventsHeartbeat
+	if v := r.URL.Query().Get("heartbeat"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 5 || secs > 120 {
+			httpError(w, "Heartbeat must be between 5 and 120 seconds", http.StatusBadRequest)
+			return
+		}
+		heartbeatEvery = time.Duration(secs) * time.Second
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	feed := s.newEventFeed(user, topics)
+
+	// send writes the events of every topic that's due and changed
+	send := func(now time.Time) bool {
+		for _, event := range feed.poll(now) {
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.topic, event.payload); err != nil {
+				return false
+			}
+			flusher.Flush()
+		}
+		return true
+	}
+
+	if !send(time.Now()) {
+		return
+	}
+
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(heartbeatEvery)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case now := <-poll.C:
+			if !send(now) {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// eventFeed tracks what a client was last sent for each of its topics
+type eventFeed struct {
+	s      *Server
+	user   *database.User
+	topics []eventTopic
+	due    map[string]time.Time
+	last   map[string][]byte
+
+	// Only notifications created since the feed opened are new
+	lastNotificationID int64
+	firstNotifications bool
+}
+
+// feedEvent is a topic's changed data, as JSON
+type feedEvent struct {
+	topic   string
+	payload json.RawMessage
+}
+
+func (s *Server) newEventFeed(user *database.User, topics []eventTopic) *eventFeed {
+	return &eventFeed{
+		s:                  s,
+		user:               user,
+		topics:             topics,
+		due:                make(map[string]time.Time, len(topics)),
+		last:               make(map[string][]byte, len(topics)),
+		firstNotifications: true,
+	}
+}
+
+// poll fetches every topic that's due and returns those whose data changed
+// since they were last returned. Every topic is due on the first poll.
+func (f *eventFeed) poll(now time.Time) []feedEvent {
+	var events []feedEvent
+	for _, topic := range f.topics {
+		if now.Before(f.due[topic.name]) {
+			continue
+		}
+		f.due[topic.name] = now.Add(topic.interval)
+
+		data, err := f.fetch(topic.name)
+		if err != nil {
+			// Leave the last good data in place; the topic is tried again when due
+			continue
+		}
+		payload, err := json.Marshal(data)
+		if err != nil || bytes.Equal(payload, f.last[topic.name]) {
+			continue
+		}
+		f.last[topic.name] = payload
+		events = append(events, feedEvent{topic: topic.name, payload: payload})
+	}
+	return events
+}
+
+// fetch returns a topic's current data
+func (f *eventFeed) fetch(topic string) (interface{}, error) {
+	s := f.s
+	switch topic {
+	case "scan":
+		return s.scanner.GetProgress(), nil
+	case "downloads":
+		downloads, err := s.downloads.GetAllDownloads()
+		if downloads == nil {
+			downloads = []downloadclient.Download{}
+		}
+		return downloads, err
+	case "notifications":
+		count, err := s.notifications.GetUnreadCount(f.user.ID)
+		if err != nil {
+			return nil, err
+		}
+		recent, err := s.notifications.GetForUser(f.user.ID, false, 20)
+		if err != nil {
+			return nil, err
+		}
+		fresh := []database.Notification{}
+		maxID := f.lastNotificationID
+		for _, n := range recent {
+			if n.ID > f.lastNotificationID && !f.firstNotifications {
+				fresh = append(fresh, n)
+			}
+			if n.ID > maxID {
+				maxID = n.ID
+			}
+		}
+		f.lastNotificationID, f.firstNotifications = maxID, false
+		return map[string]interface{}{
+			"unreadCount":   count,
+			"notifications": fresh,
+		}, nil
+	case "tasks":
+		return s.scheduler.GetStatus(), nil
+	case "status":
+		return s.systemStatus(), nil
+	}
+	return nil, nil
+}
+
+// errTopicForbidden is returned when a user asks for an admin-only topic
+var errTopicForbidden = errors.New("Topic requires admin access")
+
+// parseEventTopics resolves the topics query parameter. An empty list means
+// every topic the user may see.
+func parseEventTopics(param string, isAdmin bool) ([]eventTopic, error) {
+	if strings.TrimSpace(param) == "" {
+		var topics []eventTopic
+		for _, topic := range eventTopics {
+			if !topic.adminOnly || isAdmin {
+				topics = append(topics, topic)
+			}
+		}
+		return topics, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, topic := range eventTopics {
+			if topic.name == name {
+				if topic.adminOnly && !isAdmin {
+					return nil, errTopicForbidden
+				}
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown topic: %s", name)
+		}
+		wanted[name] = true
+	}
+
+	var topics []eventTopic
+	for _, topic := range eventTopics {
+		if wanted[topic.name] {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
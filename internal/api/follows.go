@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Follows
+//
+// The active profile follows shows and people here, by TMDB ID, and the
+// Follows task notifies it of new episodes of the shows and new movies with
+// the people in them:
+//
+//	GET    /api/follows        what the profile follows
+//	POST   /api/follows        follow {kind: show|person, tmdbId}
+//	DELETE /api/follows/{id}   stop following
+
+// handleFollows handles GET and POST /api/follows
+func (s *Server) handleFollows(w http.ResponseWriter, r *http.Request) {
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil {
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		follows, err := s.db.GetFollows(*profileID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if follows == nil {
+			follows = []database.Follow{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(follows)
+
+	case http.MethodPost:
+		var req struct {
+			Kind   string `json:"kind"`
+			TmdbID int64  `json:"tmdbId"`
+			Name   string `json:"name"` // Used when TMDB isn't configured
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.Kind != database.FollowShow && req.Kind != database.FollowPerson {
+			httpError(w, "kind must be show or person", http.StatusBadRequest)
+			return
+		}
+		if req.TmdbID <= 0 {
+			httpError(w, "tmdbId is required", http.StatusBadRequest)
+			return
+		}
+
+		following, err := s.db.IsFollowing(*profileID, req.Kind, req.TmdbID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if following {
+			httpError(w, "Already following", http.StatusConflict)
+			return
+		}
+
+		follow := &database.Follow{ProfileID: *profileID, Kind: req.Kind, TmdbID: req.TmdbID, Name: strings.TrimSpace(req.Name)}
+		if s.metadataConfigured() {
+			if err := s.describeFollow(follow); err != nil {
+				httpError(w, "Not found on TMDB", http.StatusNotFound)
+				return
+			}
+		}
+		if follow.Name == "" {
+			httpError(w, "name is required while TMDB isn't configured", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.db.CreateFollow(follow); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		created, err := s.db.GetFollow(follow.ID)
+		if err != nil || created == nil {
+			httpError(w, "Failed to load follow", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// describeFollow fills in a followed show or person's name and image from
+// TMDB
+func (s *Server) describeFollow(follow *database.Follow) error {
+	client := s.metadata.GetTMDBClient()
+	var name, image string
+	if follow.Kind == database.FollowShow {
+		details, err := client.GetTVDetails(follow.TmdbID)
+		if err != nil {
+			return err
+		}
+		name, image = details.Name, details.PosterPath
+	} else {
+		details, err := client.GetPersonDetails(follow.TmdbID)
+		if err != nil {
+			return err
+		}
+		name, image = details.Name, details.ProfilePath
+	}
+	follow.Name = name
+	if image != "" {
+		follow.ImagePath = &image
+	}
+	return nil
+}
+
+// handleFollow handles DELETE /api/follows/{id}
+func (s *Server) handleFollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil {
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/follows/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid follow ID")
+		return
+	}
+
+	follow, err := s.db.GetFollow(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if follow == nil || follow.ProfileID != *profileID {
+		httpError(w, "Follow not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.DeleteFollow(id); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+)
+
+// Guest account rules
+//
+// Guests are read-only accounts for visitors and demos. They always expire,
+// can be limited to some libraries like any account, and may browse and
+// stream but not request or change anything. The scheduler disables them at
+// expiry and revokes their sessions.
+
+// guestWriteAllowed reports whether a guest may make a non-GET request:
+// only the calls playback itself needs
+func guestWriteAllowed(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case path == "/api/progress":
+		return true
+	case path == "/api/auth/verify-pin":
+		return true
+	case strings.HasPrefix(path, "/api/profiles/") && strings.HasSuffix(path, "/select"):
+		return true
+	}
+	return false
+}
+
+// allowGuest refuses guests anything but reads and playback. It writes the
+// error and returns false when the request is refused.
+func allowGuest(w http.ResponseWriter, r *http.Request, user *database.User) bool {
+	if user.Role != "guest" || r.Method == http.MethodGet || r.Method == http.MethodHead || guestWriteAllowed(r) {
+		return true
+	}
+	requestLog(r).Infof("Access denied: guest %s tried %s %s", user.Username, r.Method, r.URL.Path)
+	httpError(w, i18n.T(requestLanguage(r, user), "Guest accounts are read-only"), http.StatusForbidden)
+	return false
+}
+
+// validateAccountExpiry checks an account's role and expiry: guests must
+// expire, and an expiry being set must be in the future
+func validateAccountExpiry(role string, expiresAt *time.Time, changed bool) string {
+	if role == "guest" && expiresAt == nil {
+		return "Guest accounts need an expiry date"
+	}
+	if changed && expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "Expiry date must be in the future"
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Unified history handlers
+
+// maxHistoryLimit caps the page size of GET /api/history
+const maxHistoryLimit = 500
+
+// handleHistory handles GET /api/history, the timeline of grabs, imports,
+// upgrades and deletions. Query: type (comma-separated), mediaType and
+// tmdbId, or movieId/showId for a library item; from and to (YYYY-MM-DD in
+// the caller's time zone, to inclusive); limit (default 50) and offset.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := database.HistoryFilter{MediaType: q.Get("mediaType")}
+	if err := parseHistoryPage(q, database.HistoryEventTypes, &filter); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if v := q.Get("tmdbId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid tmdbId")
+			return
+		}
+		filter.MediaID = id
+	}
+	if v := q.Get("movieId"); v != "" {
+		id, _ := strconv.ParseInt(v, 10, 64)
+		movie, err := s.db.GetMovie(id)
+		if err != nil || movie.TmdbID == nil {
+			httpError(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		filter.MediaType, filter.MediaID = "movie", *movie.TmdbID
+	}
+	if v := q.Get("showId"); v != "" {
+		id, _ := strconv.ParseInt(v, 10, 64)
+		show, err := s.db.GetShow(id)
+		if err != nil || show.TmdbID == nil {
+			httpError(w, "Show not found", http.StatusNotFound)
+			return
+		}
+		filter.MediaType, filter.MediaID = "show", *show.TmdbID
+	}
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, requestLocation(r))
+		if err != nil {
+			httpError(w, "from must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		filter.Since = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, requestLocation(r))
+		if err != nil {
+			httpError(w, "to must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		filter.Until = to.AddDate(0, 0, 1)
+	}
+
+	s.sendHistoryPage(w, filter)
+}
+
+// handleMediaActivity handles GET /api/movies/{id}/activity and
+// /api/shows/{id}/activity: everything that happened to one item, including
+// the searches run for it and the releases they considered. Query: type
+// (comma-separated), limit and offset, as for GET /api/history.
+func (s *Server) handleMediaActivity(w http.ResponseWriter, r *http.Request, mediaType string, tmdbID *int64) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if user := s.getCurrentUser(r); user == nil || user.Role != "admin" {
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+		return
+	}
+	if tmdbID == nil {
+		// Activity is keyed by TMDB ID, so unmatched items have none
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"events": []database.HistoryEvent{}, "total": 0, "limit": 0, "offset": 0, "hasMore": false,
+		})
+		return
+	}
+
+	filter := database.HistoryFilter{MediaType: mediaType, MediaID: *tmdbID}
+	if err := parseHistoryPage(r.URL.Query(), database.ActivityEventTypes, &filter); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(filter.Types) == 0 {
+		filter.Types = database.ActivityEventTypes
+	}
+	s.sendHistoryPage(w, filter)
+}
+
+// parseHistoryPage reads the type, limit and offset query parameters into a
+// history filter, allowing only the given event types
+func parseHistoryPage(q url.Values, allowed []string, filter *database.HistoryFilter) error {
+	if types := q.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			known := false
+			for _, historyType := range allowed {
+				known = known || t == historyType
+			}
+			if !known {
+				return fmt.Errorf("unknown history type: %s", t)
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	filter.Limit = 50
+	if v := q.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			filter.Limit = n
+		}
+	}
+	if filter.Limit > maxHistoryLimit {
+		filter.Limit = maxHistoryLimit
+	}
+	if v := q.Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			filter.Offset = n
+		}
+	}
+	return nil
+}
+
+// sendHistoryPage writes a page of history matching a filter
+func (s *Server) sendHistoryPage(w http.ResponseWriter, filter database.HistoryFilter) {
+	events, total, err := s.db.GetHistory(filter)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":  events,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+		"hasMore": filter.Offset+len(events) < total,
+	})
+}
+
+// recordDeletion adds a deleted movie or episode to the history
+func (s *Server) recordDeletion(r *http.Request, mediaType string, tmdbID *int64, summary string, details map[string]interface{}) {
+	event := &database.MediaEvent{
+		EventType: database.MediaEventDeletion,
+		MediaType: mediaType,
+		MediaID:   tmdbID,
+		Summary:   summary,
+		Details:   details,
+	}
+	if user := s.getCurrentUser(r); user != nil {
+		event.UserID = &user.ID
+	}
+	if err := s.db.RecordMediaEvent(event); err != nil {
+		requestLog(r).Errorf("Failed to record deletion of %s: %v", summary, err)
+	}
+}
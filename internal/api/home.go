@@ -0,0 +1,275 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Home screen handlers
+//
+// GET /api/home serves the home screen's rows: items admins pinned, then
+// what was added recently. How far back Recently Added looks, how many items
+// it shows and which libraries are left out of it are settings.
+
+// Home screen defaults, used when the settings are missing or invalid
+const (
+	defaultRecentlyAddedDays  = 30
+	defaultRecentlyAddedLimit = 20
+	maxRecentlyAddedLimit     = 100
+)
+
+// homeItem is a movie or show in a home row
+type homeItem struct {
+	Type  string      `json:"type"` // movie, show
+	Item  interface{} `json:"item"` // MovieWithWatchState or ShowWithWatchState
+	PinID *int64      `json:"pinId,omitempty"`
+	Note  *string     `json:"note,omitempty"`
+	added time.Time
+}
+
+// homeRow is a titled row of the home screen
+type homeRow struct {
+	ID    string     `json:"id"` // pinned, recently_added
+	Title string     `json:"title"`
+	Items []homeItem `json:"items"`
+}
+
+// homeSettingInt reads a positive home screen setting
+func (s *Server) homeSettingInt(key string, fallback int) int {
+	if v, err := s.db.GetSetting(key); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// validateHomeSetting checks the home screen settings. Other settings are
+// always valid.
+func validateHomeSetting(key, value string) error {
+	switch key {
+	case "home_recently_added_days":
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("Recently added must cover at least 1 day")
+		}
+	case "home_recently_added_limit":
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > maxRecentlyAddedLimit {
+			return fmt.Errorf("Recently added must show between 1 and %d items", maxRecentlyAddedLimit)
+		}
+	case "home_excluded_libraries":
+		if _, err := database.ParseLibraryIDList(value); err != nil {
+			return fmt.Errorf("Excluded libraries must be a comma-separated list of library IDs")
+		}
+	}
+	return nil
+}
+
+// handleHome handles GET /api/home
+func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	shows, err := s.db.GetShows()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pins, err := s.db.GetHomePins()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	days := s.homeSettingInt("home_recently_added_days", defaultRecentlyAddedDays)
+	limit := s.homeSettingInt("home_recently_added_limit", defaultRecentlyAddedLimit)
+	if limit > maxRecentlyAddedLimit {
+		limit = maxRecentlyAddedLimit
+	}
+	excludedSetting, _ := s.db.GetSetting("home_excluded_libraries")
+	excludedIDs, _ := database.ParseLibraryIDList(excludedSetting)
+	excluded := make(map[int64]bool)
+	for _, id := range excludedIDs {
+		excluded[id] = true
+	}
+
+	user := s.getCurrentUser(r)
+	rule := s.db.GetPlayedRule(s.getActiveProfileID(r))
+	movieStates, _ := s.db.GetAllMovieWatchStates(rule)
+	showStates, _ := s.db.GetAllShowWatchStates(rule)
+
+	// Everything the caller may see, keyed for the pins
+	visibleMovies := make(map[int64]homeItem)
+	visibleShows := make(map[int64]homeItem)
+	for _, m := range movies {
+		if user != nil && (!user.CanAccessLibrary(m.LibraryID) || !s.isContentAllowed(user, m.ContentRating, r)) {
+			continue
+		}
+		item := MovieWithWatchState{Movie: m}
+		if state, ok := movieStates[m.ID]; ok {
+			item.WatchState = state.WatchState
+			item.Progress = state.Progress
+		}
+		visibleMovies[m.ID] = homeItem{Type: "movie", Item: item, added: m.AddedAt}
+	}
+	for _, sh := range shows {
+		if user != nil && (!user.CanAccessLibrary(sh.LibraryID) || !s.isContentAllowed(user, sh.ContentRating, r)) {
+			continue
+		}
+		item := ShowWithWatchState{Show: sh}
+		if state, ok := showStates[sh.ID]; ok {
+			item.WatchState = state.WatchState
+			item.WatchedEpisodes = state.WatchedEpisodes
+			item.TotalEpisodes = state.TotalEpisodes
+		}
+		var added time.Time
+		if sh.AddedAt != nil {
+			added = *sh.AddedAt
+		}
+		visibleShows[sh.ID] = homeItem{Type: "show", Item: item, added: added}
+	}
+
+	// Pins were chosen on purpose, so excluded libraries don't hide them
+	pinned := []homeItem{}
+	for i := range pins {
+		pin := &pins[i]
+		item, ok := visibleMovies[pin.MediaID]
+		if pin.MediaType == "show" {
+			item, ok = visibleShows[pin.MediaID]
+		}
+		if !ok {
+			continue
+		}
+		item.PinID = &pin.ID
+		item.Note = pin.Note
+		pinned = append(pinned, item)
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	recent := []homeItem{}
+	for _, m := range movies {
+		if item, ok := visibleMovies[m.ID]; ok && !excluded[m.LibraryID] && item.added.After(since) {
+			recent = append(recent, item)
+		}
+	}
+	for _, sh := range shows {
+		if item, ok := visibleShows[sh.ID]; ok && !excluded[sh.LibraryID] && item.added.After(since) {
+			recent = append(recent, item)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].added.After(recent[j].added) })
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rows": []homeRow{
+			{ID: "pinned", Title: "Pinned", Items: pinned},
+			{ID: "recently_added", Title: "Recently Added", Items: recent},
+		},
+		"recentlyAddedDays": days,
+	})
+}
+
+// handleHomePins handles GET /api/home/pins, listing pins, and POST with
+// {mediaType, mediaId, note, position, expiresAt}, pinning an item
+func (s *Server) handleHomePins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		pins, err := s.db.GetHomePins()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(pins)
+
+	case http.MethodPost:
+		var req struct {
+			MediaType string     `json:"mediaType"`
+			MediaID   int64      `json:"mediaId"`
+			Note      string     `json:"note"`
+			Position  int        `json:"position"`
+			ExpiresAt *time.Time `json:"expiresAt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		switch req.MediaType {
+		case "movie":
+			if _, err := s.db.GetMovie(req.MediaID); err != nil {
+				httpError(w, "Movie not found", http.StatusNotFound)
+				return
+			}
+		case "show":
+			if _, err := s.db.GetShow(req.MediaID); err != nil {
+				httpError(w, "Show not found", http.StatusNotFound)
+				return
+			}
+		default:
+			httpError(w, "mediaType must be movie or show", http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			httpError(w, "Expiry must be in the future", http.StatusBadRequest)
+			return
+		}
+
+		pin := &database.HomePin{
+			MediaType: req.MediaType,
+			MediaID:   req.MediaID,
+			Position:  req.Position,
+			ExpiresAt: req.ExpiresAt,
+		}
+		if note := strings.TrimSpace(req.Note); note != "" {
+			pin.Note = &note
+		}
+		if user := s.getCurrentUser(r); user != nil {
+			pin.PinnedBy = &user.ID
+		}
+		if err := s.db.CreateHomePin(pin); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pin)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHomePin handles DELETE /api/home/pins/{id}
+func (s *Server) handleHomePin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/home/pins/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid pin ID")
+		return
+	}
+	if err := s.db.DeleteHomePin(id); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
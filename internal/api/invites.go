@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Invite handlers
+
+// inviteResponse adds the shareable registration link to an invite
+type inviteResponse struct {
+	database.Invite
+	URL    string `json:"url"`
+	Usable bool   `json:"usable"`
+}
+
+func (s *Server) toInviteResponse(r *http.Request, invite database.Invite) inviteResponse {
+	return inviteResponse{
+		Invite: invite,
+		URL:    s.publicBaseURL(r) + "/invite/" + invite.Token,
+		Usable: invite.IsUsable(),
+	}
+}
+
+// isValidInviteRole limits invites to non-admin roles; admins are promoted manually
+func isValidInviteRole(role string) bool {
+	return role == "user" || role == "kid"
+}
+
+// handleInvites handles GET/POST /api/invites
+func (s *Server) handleInvites(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := s.db.GetInvites()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result := make([]inviteResponse, len(invites))
+		for i, invite := range invites {
+			result[i] = s.toInviteResponse(r, invite)
+		}
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodPost:
+		var req struct {
+			Note               string  `json:"note"`
+			Role               string  `json:"role"`
+			ContentRatingLimit *string `json:"contentRatingLimit"`
+			LibraryIDs         []int64 `json:"libraryIds"`
+			RequestQuota       *int    `json:"requestQuota"`
+			RequestQuotaDays   int     `json:"requestQuotaDays"`
+			MaxUses            int     `json:"maxUses"`
+			ExpiresInDays      int     `json:"expiresInDays"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+
+		if req.Role == "" {
+			req.Role = "user"
+		}
+		if !isValidInviteRole(req.Role) {
+			httpError(w, "Invalid role (must be user or kid)", http.StatusBadRequest)
+			return
+		}
+		if req.MaxUses < 0 || req.ExpiresInDays < 0 || req.RequestQuotaDays < 0 {
+			httpError(w, "maxUses, expiresInDays and requestQuotaDays must not be negative", http.StatusBadRequest)
+			return
+		}
+		if req.RequestQuota != nil && *req.RequestQuota < 0 {
+			httpError(w, "requestQuota must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		// For kid role, default to PG if no limit set
+		if req.Role == "kid" && req.ContentRatingLimit == nil {
+			pg := "PG"
+			req.ContentRatingLimit = &pg
+		}
+
+		token, err := auth.GenerateToken()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		invite := &database.Invite{
+			Token:              token,
+			Role:               req.Role,
+			ContentRatingLimit: req.ContentRatingLimit,
+			LibraryIDs:         req.LibraryIDs,
+			RequestQuota:       req.RequestQuota,
+			RequestQuotaDays:   req.RequestQuotaDays,
+			MaxUses:            req.MaxUses,
+		}
+		if req.Note != "" {
+			invite.Note = &req.Note
+		}
+		if req.ExpiresInDays > 0 {
+			expires := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+			invite.ExpiresAt = &expires
+		}
+		if admin := s.getCurrentUser(r); admin != nil {
+			invite.CreatedBy = &admin.ID
+		}
+
+		if err := s.db.CreateInvite(invite); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.recordAudit(r, "invite.create", "invite", &invite.ID, fmt.Sprintf("Role %s, max uses %d", invite.Role, invite.MaxUses))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.toInviteResponse(r, *invite))
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleInvite handles GET/DELETE /api/invites/{id}
+func (s *Server) handleInvite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/invites/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid invite ID")
+		return
+	}
+
+	invite, err := s.db.GetInvite(id)
+	if err != nil {
+		httpError(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(s.toInviteResponse(r, *invite))
+
+	case http.MethodDelete:
+		if err := s.db.DeleteInvite(id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "invite.revoke", "invite", &id, "")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRegisterInvite handles the public /api/invite/{token} endpoint
+// GET validates the invite, POST registers a new account and signs it in
+func (s *Server) handleRegisterInvite(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := strings.TrimPrefix(r.URL.Path, "/api/invite/")
+	invite, err := s.db.GetInviteByToken(token)
+	if err != nil || !invite.IsUsable() {
+		httpError(w, "Invite is invalid or has expired", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"role":      invite.Role,
+			"note":      invite.Note,
+			"expiresAt": invite.ExpiresAt,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Email    string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" || req.Password == "" {
+			httpError(w, "Username and password required", http.StatusBadRequest)
+			return
+		}
+		email, ok := normalizeEmail(req.Email)
+		if !ok {
+			httpError(w, "Invalid email address", http.StatusBadRequest)
+			return
+		}
+		if _, err := s.db.GetUserByUsername(req.Username); err == nil {
+			httpError(w, "Username already taken", http.StatusConflict)
+			return
+		}
+
+		claimed, err := s.db.ClaimInvite(invite.ID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !claimed {
+			httpError(w, "Invite is invalid or has expired", http.StatusNotFound)
+			return
+		}
+
+		user, err := s.auth.CreateUser(req.Username, req.Password, invite.Role)
+		if err != nil {
+			s.db.ReleaseInvite(invite.ID)
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Apply the invite's presets
+		user.Email = email
+		user.ContentRatingLimit = invite.ContentRatingLimit
+		user.LibraryIDs = invite.LibraryIDs
+		user.RequestQuota = invite.RequestQuota
+		user.RequestQuotaDays = invite.RequestQuotaDays
+		if err := s.db.UpdateUser(user); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.db.CreateDefaultProfileForUser(user.ID, user.Username)
+
+		session, _, err := s.auth.Login(req.Username, req.Password)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+		s.recordAudit(r, "invite.redeem", "invite", &invite.ID, "Registered "+user.Username)
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "session",
+			Value:    session.Token,
+			Path:     "/",
+			HttpOnly: true,
+			Expires:  session.ExpiresAt,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token": session.Token,
+			"user": map[string]interface{}{
+				"id":       user.ID,
+				"username": user.Username,
+				"role":     user.Role,
+			},
+		})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+)
+
+// Jellyfin compatibility
+//
+// A subset of the Jellyfin API is served under /jellyfin so Jellyfin apps
+// can use Outpost as their server: enter http://host:port/jellyfin as the
+// server address. It covers signing in, browsing movie and TV libraries,
+// playback and reporting progress. Sign-ins go through the same checks as
+// Outpost's own and the token is an Outpost session token, so requests are
+// handled by the same middleware once the token is found.
+//
+// Jellyfin identifies everything by a GUID; Outpost's IDs are encoded in one
+// with the kind of item first (see jellyfinID).
+
+// jellyfinVersion is the Jellyfin server version reported to apps, which
+// check it before connecting
+const jellyfinVersion = "10.8.13"
+
+// Kinds of item encoded in Jellyfin IDs
+const (
+	jfLibrary = iota + 1
+	jfMovie
+	jfShow
+	jfSeason
+	jfEpisode
+	jfUser
+)
+
+// jellyfinID encodes an Outpost ID as a Jellyfin GUID
+func jellyfinID(kind int, id int64) string {
+	return fmt.Sprintf("%08x%024x", kind, id)
+}
+
+// parseJellyfinID decodes a Jellyfin GUID, with or without dashes
+func parseJellyfinID(s string) (kind int, id int64, ok bool) {
+	s = strings.ReplaceAll(strings.ToLower(s), "-", "")
+	if len(s) != 32 {
+		return 0, 0, false
+	}
+	k, err1 := strconv.ParseInt(s[:8], 16, 32)
+	n, err2 := strconv.ParseInt(s[8:], 16, 64)
+	if err1 != nil || err2 != nil || k < jfLibrary || k > jfUser {
+		return 0, 0, false
+	}
+	return int(k), n, true
+}
+
+// jellyfinAuthParam matches the Key="value" pairs of a MediaBrowser
+// authorization header
+var jellyfinAuthParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// jellyfinAuth returns the parameters of the MediaBrowser authorization
+// header: Client, Device, DeviceId, Version and, once signed in, Token
+func jellyfinAuth(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	for _, header := range []string{"Authorization", "X-Emby-Authorization"} {
+		value := r.Header.Get(header)
+		if !strings.HasPrefix(value, "MediaBrowser ") && !strings.HasPrefix(value, "Emby ") {
+			continue
+		}
+		for _, m := range jellyfinAuthParam.FindAllStringSubmatch(value, -1) {
+			params[m[1]] = m[2]
+		}
+		break
+	}
+	return params
+}
+
+// jellyfinToken finds the session token wherever a Jellyfin app sends it
+func jellyfinToken(r *http.Request) string {
+	if token := jellyfinAuth(r)["Token"]; token != "" {
+		return token
+	}
+	for _, header := range []string{"X-Emby-Token", "X-MediaBrowser-Token"} {
+		if token := r.Header.Get(header); token != "" {
+			return token
+		}
+	}
+	q := r.URL.Query()
+	if token := q.Get("api_key"); token != "" {
+		return token
+	}
+	return q.Get("ApiKey")
+}
+
+// jellyfinServerID returns the server's Jellyfin ID, made the first time
+// it's asked for. Apps key their saved servers by it.
+func (s *Server) jellyfinServerID() string {
+	s.jellyfinIDOnce.Do(func() {
+		if id, err := s.db.GetSetting("jellyfin_server_id"); err == nil && id != "" {
+			s.jellyfinID = id
+			return
+		}
+		b := make([]byte, 16)
+		rand.Read(b)
+		s.jellyfinID = hex.EncodeToString(b)
+		s.db.SetSetting("jellyfin_server_id", s.jellyfinID)
+	})
+	return s.jellyfinID
+}
+
+// handleJellyfin routes /jellyfin/... Paths are matched without regard to
+// case, as Jellyfin does. Everything past the sign-in screen needs a token.
+func (s *Server) handleJellyfin(w http.ResponseWriter, r *http.Request) {
+	path := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jellyfin"), "/"))
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "system/info/public":
+		s.handleJellyfinSystemInfo(w, r, false)
+		return
+	case path == "system/ping":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode("Jellyfin Server")
+		return
+	case path == "branding/configuration":
+		jellyfinJSON(w, map[string]interface{}{"LoginDisclaimer": "", "CustomCss": "", "SplashscreenEnabled": false})
+		return
+	case path == "branding/css" || path == "branding/css.css":
+		w.Header().Set("Content-Type", "text/css")
+		return
+	case path == "users/public":
+		// Apps offer these as one-tap sign-ins; nobody is listed
+		jellyfinJSON(w, []interface{}{})
+		return
+	case path == "quickconnect/enabled":
+		jellyfinJSON(w, false)
+		return
+	case path == "users/authenticatebyname":
+		s.handleJellyfinAuthenticate(w, r)
+		return
+	case len(parts) >= 4 && parts[0] == "items" && parts[2] == "images":
+		// Images are public, as apps load them without the token
+		s.handleJellyfinImage(w, r, parts[1], parts[3])
+		return
+	}
+
+	token := jellyfinToken(r)
+	if token == "" {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		s.routeJellyfin(w, r, path, parts)
+	})(w, r)
+}
+
+// routeJellyfin routes the signed-in part of the Jellyfin API
+func (s *Server) routeJellyfin(w http.ResponseWriter, r *http.Request, path string, parts []string) {
+	// /Users/{id}/Items... is /Items... for the signed-in user
+	if len(parts) >= 3 && parts[0] == "users" && parts[2] != "" &&
+		(parts[2] == "items" || parts[2] == "views" || parts[2] == "playeditems") {
+		parts = parts[2:]
+		path = strings.Join(parts, "/")
+	}
+	if len(parts) >= 2 && parts[0] == "useritems" {
+		parts = append([]string{"items"}, parts[1:]...)
+		path = strings.Join(parts, "/")
+	}
+
+	switch {
+	case path == "system/info":
+		s.handleJellyfinSystemInfo(w, r, true)
+	case path == "users/me" || (len(parts) == 2 && parts[0] == "users"):
+		jellyfinJSON(w, s.jellyfinUser(s.getCurrentUser(r)))
+	case path == "views" || path == "userviews":
+		s.handleJellyfinViews(w, r)
+	case path == "items":
+		s.handleJellyfinItems(w, r)
+	case path == "items/resume":
+		s.handleJellyfinResume(w, r)
+	case path == "items/latest":
+		s.handleJellyfinLatest(w, r)
+	case len(parts) == 2 && parts[0] == "items":
+		s.handleJellyfinItem(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "items" && parts[2] == "playbackinfo":
+		s.handleJellyfinPlaybackInfo(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "playeditems":
+		s.handleJellyfinPlayed(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "shows" && parts[2] == "seasons":
+		s.handleJellyfinSeasons(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "shows" && parts[2] == "episodes":
+		s.handleJellyfinEpisodes(w, r, parts[1])
+	case path == "shows/nextup":
+		jellyfinJSON(w, jellyfinItems{Items: []jellyfinItem{}})
+	case len(parts) >= 3 && parts[0] == "videos" && strings.HasPrefix(parts[len(parts)-1], "stream"):
+		s.handleJellyfinStream(w, r, parts[1])
+	case path == "sessions/playing" || path == "sessions/playing/progress" || path == "sessions/playing/stopped":
+		s.handleJellyfinPlaying(w, r)
+	case path == "sessions/capabilities" || path == "sessions/capabilities/full" || path == "sessions/playing/ping":
+		w.WriteHeader(http.StatusNoContent)
+	case path == "sessions/logout":
+		s.auth.Logout(s.getSessionToken(r))
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[0] == "displaypreferences":
+		jellyfinJSON(w, map[string]interface{}{
+			"Id": parts[1], "SortBy": "SortName", "SortOrder": "Ascending", "RememberIndexing": false,
+			"PrimaryImageHeight": 250, "PrimaryImageWidth": 250, "CustomPrefs": map[string]string{},
+			"ScrollDirection": "Horizontal", "ShowBackdrop": true, "RememberSorting": false,
+			"ShowSidebar": false, "Client": r.URL.Query().Get("client"),
+		})
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func jellyfinJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleJellyfinSystemInfo handles /System/Info and /System/Info/Public
+func (s *Server) handleJellyfinSystemInfo(w http.ResponseWriter, r *http.Request, full bool) {
+	info := map[string]interface{}{
+		"LocalAddress":           s.publicBaseURL(r) + "/jellyfin",
+		"ServerName":             "Outpost",
+		"Version":                jellyfinVersion,
+		"ProductName":            "Jellyfin Server",
+		"OperatingSystem":        runtime.GOOS,
+		"Id":                     s.jellyfinServerID(),
+		"StartupWizardCompleted": true,
+	}
+	if full {
+		info["OperatingSystemDisplayName"] = runtime.GOOS
+		info["HasPendingRestart"] = false
+		info["IsShuttingDown"] = false
+		info["SupportsLibraryMonitor"] = false
+		info["CanSelfRestart"] = false
+		info["CanLaunchWebBrowser"] = false
+		info["HasUpdateAvailable"] = false
+	}
+	jellyfinJSON(w, info)
+}
+
+// handleJellyfinAuthenticate handles POST /Users/AuthenticateByName. It
+// applies the checks Outpost's own sign-in does and selects the user's
+// default profile, as Jellyfin apps don't know about profiles.
+func (s *Server) handleJellyfinAuthenticate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Username string `json:"Username"`
+		Pw       string `json:"Pw"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	if !allowCountry(w, r) {
+		return
+	}
+
+	session, user, err := s.auth.Login(req.Username, req.Pw)
+	if err == auth.ErrAccountDisabled {
+		httpError(w, i18n.T(requestLanguage(r, user), "This account is disabled or has expired"), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		s.recordLoginFailure(r, req.Username)
+		localizedError(w, r, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+	if !allowUserNetwork(w, r, user, false) {
+		s.auth.Logout(session.Token)
+		return
+	}
+	s.recordLoginDevice(r, user)
+	device := jellyfinAuth(r)
+	_, err = s.registerDevice(r, user, session.Token, deviceInfo{ID: device["DeviceId"], Name: device["Device"]})
+	if err == errDeviceBlocked {
+		s.auth.Logout(session.Token)
+		localizedError(w, r, http.StatusForbidden, "This device has been blocked")
+		return
+	}
+	if err != nil {
+		requestLog(r).Errorf("Failed to register device for %s: %v", user.Username, err)
+	}
+	if profile, err := s.db.GetDefaultProfile(user.ID); err == nil {
+		s.db.SetActiveProfile(session.Token, profile.ID)
+	}
+
+	jellyfinJSON(w, map[string]interface{}{
+		"User": s.jellyfinUser(user),
+		"SessionInfo": map[string]interface{}{
+			"Id":         strconv.FormatInt(session.ID, 10),
+			"UserId":     jellyfinID(jfUser, user.ID),
+			"UserName":   user.Username,
+			"Client":     device["Client"],
+			"DeviceId":   device["DeviceId"],
+			"DeviceName": device["Device"],
+			"ServerId":   s.jellyfinServerID(),
+		},
+		"AccessToken": session.Token,
+		"ServerId":    s.jellyfinServerID(),
+	})
+}
+
+// jellyfinUser is a user as Jellyfin describes one
+func (s *Server) jellyfinUser(user *database.User) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":                      user.Username,
+		"ServerId":                  s.jellyfinServerID(),
+		"Id":                        jellyfinID(jfUser, user.ID),
+		"HasPassword":               true,
+		"HasConfiguredPassword":     true,
+		"HasConfiguredEasyPassword": false,
+		"EnableAutoLogin":           false,
+		"Configuration": map[string]interface{}{
+			"PlayDefaultAudioTrack":      true,
+			"SubtitleMode":               "Default",
+			"OrderedViews":               []string{},
+			"LatestItemsExcludes":        []string{},
+			"MyMediaExcludes":            []string{},
+			"HidePlayedInLatest":         true,
+			"RememberAudioSelections":    true,
+			"RememberSubtitleSelections": true,
+			"EnableNextEpisodeAutoPlay":  true,
+		},
+		"Policy": map[string]interface{}{
+			"IsAdministrator":                user.Role == "admin",
+			"IsDisabled":                     user.Disabled,
+			"IsHidden":                       true,
+			"EnableMediaPlayback":            true,
+			"EnableAudioPlaybackTranscoding": false,
+			"EnableVideoPlaybackTranscoding": true,
+			"EnablePlaybackRemuxing":         false,
+			"EnableContentDeletion":          false,
+			"EnableContentDownloading":       true,
+			"EnableAllFolders":               user.LibraryIDs == nil,
+			"EnableRemoteAccess":             !user.LanOnly,
+		},
+	}
+}
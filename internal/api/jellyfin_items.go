@@ -0,0 +1,966 @@
+package api
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Jellyfin items: libraries, movies, shows, seasons and episodes as
+// Jellyfin's BaseItemDto, and their playback
+
+// ticksPerSecond converts seconds to Jellyfin's 100ns ticks
+const ticksPerSecond = 10_000_000
+
+// jellyfinItem is the part of Jellyfin's BaseItemDto apps need to browse and
+// play
+type jellyfinItem struct {
+	Name              string            `json:"Name"`
+	SortName          string            `json:"SortName,omitempty"`
+	ServerID          string            `json:"ServerId"`
+	ID                string            `json:"Id"`
+	Type              string            `json:"Type"`
+	IsFolder          bool              `json:"IsFolder"`
+	CollectionType    string            `json:"CollectionType,omitempty"`
+	MediaType         string            `json:"MediaType,omitempty"`
+	LocationType      string            `json:"LocationType"`
+	ParentID          string            `json:"ParentId,omitempty"`
+	Overview          string            `json:"Overview,omitempty"`
+	ProductionYear    int               `json:"ProductionYear,omitempty"`
+	PremiereDate      string            `json:"PremiereDate,omitempty"`
+	DateCreated       string            `json:"DateCreated,omitempty"`
+	CommunityRating   float64           `json:"CommunityRating,omitempty"`
+	OfficialRating    string            `json:"OfficialRating,omitempty"`
+	Genres            []string          `json:"Genres,omitempty"`
+	RunTimeTicks      int64             `json:"RunTimeTicks,omitempty"`
+	Container         string            `json:"Container,omitempty"`
+	ProviderIDs       map[string]string `json:"ProviderIds,omitempty"`
+	SeriesID          string            `json:"SeriesId,omitempty"`
+	SeriesName        string            `json:"SeriesName,omitempty"`
+	SeasonID          string            `json:"SeasonId,omitempty"`
+	SeasonName        string            `json:"SeasonName,omitempty"`
+	IndexNumber       *int              `json:"IndexNumber,omitempty"`
+	IndexNumberEnd    *int              `json:"IndexNumberEnd,omitempty"`
+	ParentIndexNumber *int              `json:"ParentIndexNumber,omitempty"`
+	ImageTags         map[string]string `json:"ImageTags"`
+	BackdropImageTags []string          `json:"BackdropImageTags"`
+	UserData          *jellyfinUserData `json:"UserData,omitempty"`
+
+	// For sorting and access checks, not sent
+	libraryID     int64
+	contentRating *string
+	added         time.Time
+}
+
+// jellyfinUserData is a profile's watch state of an item
+type jellyfinUserData struct {
+	PlaybackPositionTicks int64   `json:"PlaybackPositionTicks"`
+	PlayedPercentage      float64 `json:"PlayedPercentage,omitempty"`
+	PlayCount             int     `json:"PlayCount"`
+	IsFavorite            bool    `json:"IsFavorite"`
+	Played                bool    `json:"Played"`
+	Key                   string  `json:"Key"`
+}
+
+// jellyfinItems is Jellyfin's paged item list
+type jellyfinItems struct {
+	Items            []jellyfinItem `json:"Items"`
+	TotalRecordCount int            `json:"TotalRecordCount"`
+	StartIndex       int            `json:"StartIndex"`
+}
+
+// jellyfinCollectionTypes maps the library types shown to Jellyfin apps
+var jellyfinCollectionTypes = map[string]string{
+	"movies": "movies",
+	"tv":     "tvshows",
+	"anime":  "tvshows",
+}
+
+// imageTag identifies an image version, so apps cache it until it changes
+func imageTag(path *string) string {
+	sum := sha1.Sum([]byte(*path))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Server) jellyfinImages(item *jellyfinItem, primary, backdrop *string) {
+	item.ImageTags = map[string]string{}
+	item.BackdropImageTags = []string{}
+	if primary != nil && *primary != "" {
+		item.ImageTags["Primary"] = imageTag(primary)
+	}
+	if backdrop != nil && *backdrop != "" {
+		item.BackdropImageTags = append(item.BackdropImageTags, imageTag(backdrop))
+	}
+}
+
+func jellyfinGenres(genres *string) []string {
+	var list []string
+	if genres != nil {
+		json.Unmarshal([]byte(*genres), &list)
+	}
+	return list
+}
+
+func intPtr(n int) *int { return &n }
+
+func (s *Server) jellyfinLibraryItem(lib *database.Library) jellyfinItem {
+	item := jellyfinItem{
+		Name:           lib.Name,
+		ServerID:       s.jellyfinServerID(),
+		ID:             jellyfinID(jfLibrary, lib.ID),
+		Type:           "CollectionFolder",
+		IsFolder:       true,
+		CollectionType: jellyfinCollectionTypes[lib.Type],
+		LocationType:   "FileSystem",
+		libraryID:      lib.ID,
+	}
+	s.jellyfinImages(&item, nil, nil)
+	return item
+}
+
+func (s *Server) jellyfinMovieItem(m *database.Movie) jellyfinItem {
+	item := jellyfinItem{
+		Name:           m.Title,
+		SortName:       strings.ToLower(m.Title),
+		ServerID:       s.jellyfinServerID(),
+		ID:             jellyfinID(jfMovie, m.ID),
+		Type:           "Movie",
+		MediaType:      "Video",
+		LocationType:   "FileSystem",
+		ParentID:       jellyfinID(jfLibrary, m.LibraryID),
+		ProductionYear: m.Year,
+		DateCreated:    m.AddedAt.UTC().Format(time.RFC3339),
+		Genres:         jellyfinGenres(m.Genres),
+		Container:      strings.TrimPrefix(strings.ToLower(filepath.Ext(m.Path)), "."),
+		ProviderIDs:    map[string]string{},
+		libraryID:      m.LibraryID,
+		contentRating:  m.ContentRating,
+		added:          m.AddedAt,
+	}
+	if m.Overview != nil {
+		item.Overview = *m.Overview
+	}
+	if m.Rating != nil {
+		item.CommunityRating = *m.Rating
+	}
+	if m.ContentRating != nil {
+		item.OfficialRating = *m.ContentRating
+	}
+	if m.Runtime != nil {
+		item.RunTimeTicks = int64(*m.Runtime) * 60 * ticksPerSecond
+	}
+	if m.TmdbID != nil {
+		item.ProviderIDs["Tmdb"] = strconv.FormatInt(*m.TmdbID, 10)
+	}
+	if m.ImdbID != nil {
+		item.ProviderIDs["Imdb"] = *m.ImdbID
+	}
+	if m.Year > 0 {
+		item.PremiereDate = fmt.Sprintf("%04d-01-01T00:00:00Z", m.Year)
+	}
+	s.jellyfinImages(&item, m.PosterPath, m.BackdropPath)
+	return item
+}
+
+func (s *Server) jellyfinShowItem(sh *database.Show) jellyfinItem {
+	item := jellyfinItem{
+		Name:           sh.Title,
+		SortName:       strings.ToLower(sh.Title),
+		ServerID:       s.jellyfinServerID(),
+		ID:             jellyfinID(jfShow, sh.ID),
+		Type:           "Series",
+		IsFolder:       true,
+		LocationType:   "FileSystem",
+		ParentID:       jellyfinID(jfLibrary, sh.LibraryID),
+		ProductionYear: sh.Year,
+		Genres:         jellyfinGenres(sh.Genres),
+		ProviderIDs:    map[string]string{},
+		libraryID:      sh.LibraryID,
+		contentRating:  sh.ContentRating,
+	}
+	if sh.AddedAt != nil {
+		item.DateCreated = sh.AddedAt.UTC().Format(time.RFC3339)
+		item.added = *sh.AddedAt
+	}
+	if sh.Overview != nil {
+		item.Overview = *sh.Overview
+	}
+	if sh.Rating != nil {
+		item.CommunityRating = *sh.Rating
+	}
+	if sh.ContentRating != nil {
+		item.OfficialRating = *sh.ContentRating
+	}
+	if sh.TmdbID != nil {
+		item.ProviderIDs["Tmdb"] = strconv.FormatInt(*sh.TmdbID, 10)
+	}
+	if sh.TvdbID != nil {
+		item.ProviderIDs["Tvdb"] = strconv.FormatInt(*sh.TvdbID, 10)
+	}
+	if sh.ImdbID != nil {
+		item.ProviderIDs["Imdb"] = *sh.ImdbID
+	}
+	s.jellyfinImages(&item, sh.PosterPath, sh.BackdropPath)
+	return item
+}
+
+func (s *Server) jellyfinSeasonItem(sh *database.Show, season *database.Season) jellyfinItem {
+	name := fmt.Sprintf("Season %d", season.SeasonNumber)
+	if season.SeasonNumber == 0 {
+		name = "Specials"
+	}
+	if season.Name != nil && *season.Name != "" {
+		name = *season.Name
+	}
+	item := jellyfinItem{
+		Name:         name,
+		ServerID:     s.jellyfinServerID(),
+		ID:           jellyfinID(jfSeason, season.ID),
+		Type:         "Season",
+		IsFolder:     true,
+		LocationType: "FileSystem",
+		ParentID:     jellyfinID(jfShow, sh.ID),
+		SeriesID:     jellyfinID(jfShow, sh.ID),
+		SeriesName:   sh.Title,
+		IndexNumber:  intPtr(season.SeasonNumber),
+		libraryID:    sh.LibraryID,
+	}
+	if season.Overview != nil {
+		item.Overview = *season.Overview
+	}
+	poster := season.PosterPath
+	if poster == nil {
+		poster = sh.PosterPath
+	}
+	s.jellyfinImages(&item, poster, sh.BackdropPath)
+	return item
+}
+
+func (s *Server) jellyfinEpisodeItem(sh *database.Show, season *database.Season, e *database.Episode) jellyfinItem {
+	item := jellyfinItem{
+		Name:              e.Title,
+		ServerID:          s.jellyfinServerID(),
+		ID:                jellyfinID(jfEpisode, e.ID),
+		Type:              "Episode",
+		MediaType:         "Video",
+		LocationType:      "FileSystem",
+		ParentID:          jellyfinID(jfSeason, season.ID),
+		SeriesID:          jellyfinID(jfShow, sh.ID),
+		SeriesName:        sh.Title,
+		SeasonID:          jellyfinID(jfSeason, season.ID),
+		SeasonName:        fmt.Sprintf("Season %d", season.SeasonNumber),
+		IndexNumber:       intPtr(e.EpisodeNumber),
+		IndexNumberEnd:    e.EpisodeEnd,
+		ParentIndexNumber: intPtr(season.SeasonNumber),
+		Container:         strings.TrimPrefix(strings.ToLower(filepath.Ext(e.Path)), "."),
+		libraryID:         sh.LibraryID,
+		contentRating:     sh.ContentRating,
+	}
+	if e.Overview != nil {
+		item.Overview = *e.Overview
+	}
+	if e.AirDate != nil && *e.AirDate != "" {
+		item.PremiereDate = *e.AirDate + "T00:00:00Z"
+	}
+	if e.Runtime != nil {
+		item.RunTimeTicks = int64(*e.Runtime) * 60 * ticksPerSecond
+	}
+	still := e.StillPath
+	if still == nil {
+		still = sh.PosterPath
+	}
+	s.jellyfinImages(&item, still, sh.BackdropPath)
+	return item
+}
+
+// jellyfinLoad loads an item by its Jellyfin ID, checking the user may see
+// it
+func (s *Server) jellyfinLoad(r *http.Request, id string) (*jellyfinItem, bool) {
+	kind, n, ok := parseJellyfinID(id)
+	if !ok {
+		return nil, false
+	}
+	var item jellyfinItem
+	switch kind {
+	case jfLibrary:
+		lib, err := s.db.GetLibrary(n)
+		if err != nil || jellyfinCollectionTypes[lib.Type] == "" {
+			return nil, false
+		}
+		item = s.jellyfinLibraryItem(lib)
+	case jfMovie:
+		m, err := s.db.GetMovie(n)
+		if err != nil {
+			return nil, false
+		}
+		item = s.jellyfinMovieItem(m)
+	case jfShow:
+		sh, err := s.db.GetShow(n)
+		if err != nil {
+			return nil, false
+		}
+		item = s.jellyfinShowItem(sh)
+	case jfSeason:
+		season, err := s.db.GetSeasonByID(n)
+		if err != nil {
+			return nil, false
+		}
+		sh, err := s.db.GetShow(season.ShowID)
+		if err != nil {
+			return nil, false
+		}
+		item = s.jellyfinSeasonItem(sh, season)
+	case jfEpisode:
+		e, err := s.db.GetEpisode(n)
+		if err != nil {
+			return nil, false
+		}
+		season, err := s.db.GetSeasonByID(e.SeasonID)
+		if err != nil {
+			return nil, false
+		}
+		sh, err := s.db.GetShow(season.ShowID)
+		if err != nil {
+			return nil, false
+		}
+		item = s.jellyfinEpisodeItem(sh, season, e)
+	default:
+		return nil, false
+	}
+	if !s.jellyfinAllowed(r, &item) {
+		return nil, false
+	}
+	return &item, true
+}
+
+// jellyfinAllowed applies the user's library access and content rating limit
+func (s *Server) jellyfinAllowed(r *http.Request, item *jellyfinItem) bool {
+	user := s.getCurrentUser(r)
+	return user != nil && user.CanAccessLibrary(item.libraryID) && s.isContentAllowed(user, item.contentRating, r)
+}
+
+// jellyfinFillUserData adds the active profile's watch state to items
+func (s *Server) jellyfinFillUserData(r *http.Request, items []jellyfinItem) {
+	profileID := s.getActiveProfileID(r)
+	rule := s.db.GetPlayedRule(profileID)
+	for i := range items {
+		item := &items[i]
+		data := &jellyfinUserData{Key: item.ID}
+		item.UserData = data
+		mediaType := map[string]string{"Movie": "movie", "Episode": "episode"}[item.Type]
+		if mediaType == "" || profileID == nil {
+			continue
+		}
+		_, id, _ := parseJellyfinID(item.ID)
+		p, err := s.db.GetProgress(*profileID, mediaType, id)
+		if err != nil {
+			continue
+		}
+		data.Played = s.db.IsPlayed(rule, mediaType, id, p.Position, p.Duration)
+		if data.Played {
+			data.PlayCount = 1
+		} else {
+			data.PlaybackPositionTicks = int64(p.Position * ticksPerSecond)
+			if p.Duration > 0 {
+				data.PlayedPercentage = p.Position / p.Duration * 100
+			}
+		}
+	}
+}
+
+// handleJellyfinViews handles /Users/{id}/Views: the movie and TV libraries
+// the user can see
+func (s *Server) handleJellyfinViews(w http.ResponseWriter, r *http.Request) {
+	libraries, err := s.db.GetLibraries()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := []jellyfinItem{}
+	for i := range libraries {
+		item := s.jellyfinLibraryItem(&libraries[i])
+		if item.CollectionType != "" && s.jellyfinAllowed(r, &item) {
+			items = append(items, item)
+		}
+	}
+	jellyfinJSON(w, jellyfinItems{Items: items, TotalRecordCount: len(items)})
+}
+
+// handleJellyfinItem handles /Users/{id}/Items/{itemId}
+func (s *Server) handleJellyfinItem(w http.ResponseWriter, r *http.Request, id string) {
+	item, ok := s.jellyfinLoad(r, id)
+	if !ok {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	items := []jellyfinItem{*item}
+	s.jellyfinFillUserData(r, items)
+	jellyfinJSON(w, items[0])
+}
+
+// jellyfinChildren lists a library's movies or shows, a show's seasons or a
+// season's episodes
+func (s *Server) jellyfinChildren(r *http.Request, parent *jellyfinItem) ([]jellyfinItem, error) {
+	kind, id, _ := parseJellyfinID(parent.ID)
+	var items []jellyfinItem
+	switch kind {
+	case jfLibrary:
+		// The ByLibrary queries load too little to show
+		all, err := s.jellyfinAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range all {
+			if item.libraryID == id {
+				items = append(items, item)
+			}
+		}
+	case jfShow:
+		return s.jellyfinSeasons(id)
+	case jfSeason:
+		return s.jellyfinSeasonEpisodes(id)
+	}
+	return items, nil
+}
+
+func (s *Server) jellyfinSeasons(showID int64) ([]jellyfinItem, error) {
+	sh, err := s.db.GetShow(showID)
+	if err != nil {
+		return nil, err
+	}
+	seasons, err := s.db.GetSeasonsByShow(showID)
+	if err != nil {
+		return nil, err
+	}
+	items := []jellyfinItem{}
+	for i := range seasons {
+		items = append(items, s.jellyfinSeasonItem(sh, &seasons[i]))
+	}
+	return items, nil
+}
+
+func (s *Server) jellyfinSeasonEpisodes(seasonID int64) ([]jellyfinItem, error) {
+	season, err := s.db.GetSeasonByID(seasonID)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := s.db.GetShow(season.ShowID)
+	if err != nil {
+		return nil, err
+	}
+	episodes, err := s.db.GetEpisodesBySeason(seasonID)
+	if err != nil {
+		return nil, err
+	}
+	items := []jellyfinItem{}
+	for i := range episodes {
+		items = append(items, s.jellyfinEpisodeItem(sh, season, &episodes[i]))
+	}
+	return items, nil
+}
+
+// jellyfinAll lists every movie and show the user can see
+func (s *Server) jellyfinAll() ([]jellyfinItem, error) {
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		return nil, err
+	}
+	shows, err := s.db.GetShows()
+	if err != nil {
+		return nil, err
+	}
+	var items []jellyfinItem
+	for i := range movies {
+		items = append(items, s.jellyfinMovieItem(&movies[i]))
+	}
+	for i := range shows {
+		items = append(items, s.jellyfinShowItem(&shows[i]))
+	}
+	return items, nil
+}
+
+// handleJellyfinItems handles /Users/{id}/Items. It supports the queries
+// apps browse with: ParentId, Ids, IncludeItemTypes, SearchTerm,
+// Filters=IsPlayed/IsUnplayed/IsResumable, SortBy, SortOrder, StartIndex and
+// Limit.
+func (s *Server) handleJellyfinItems(w http.ResponseWriter, r *http.Request) {
+	q := jellyfinQuery(r)
+
+	var items []jellyfinItem
+	var err error
+	switch {
+	case q.Get("ids") != "":
+		for _, id := range strings.Split(q.Get("ids"), ",") {
+			if item, ok := s.jellyfinLoad(r, id); ok {
+				items = append(items, *item)
+			}
+		}
+	case q.Get("parentid") != "":
+		parent, ok := s.jellyfinLoad(r, q.Get("parentid"))
+		if !ok {
+			httpError(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		items, err = s.jellyfinChildren(r, parent)
+	default:
+		items, err = s.jellyfinAll()
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	types := map[string]bool{}
+	for _, t := range strings.Split(q.Get("includeitemtypes"), ",") {
+		if t != "" {
+			types[strings.ToLower(t)] = true
+		}
+	}
+	search := strings.ToLower(q.Get("searchterm"))
+	filtered := items[:0]
+	for _, item := range items {
+		if len(types) > 0 && !types[strings.ToLower(item.Type)] {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(item.Name), search) {
+			continue
+		}
+		if s.jellyfinAllowed(r, &item) {
+			filtered = append(filtered, item)
+		}
+	}
+	items = filtered
+
+	// Filters on watch state need everything's user data
+	filters := strings.ToLower(q.Get("filters"))
+	if filters != "" {
+		s.jellyfinFillUserData(r, items)
+		filtered := items[:0]
+		for _, item := range items {
+			switch {
+			case strings.Contains(filters, "isplayed") && !item.UserData.Played,
+				strings.Contains(filters, "isunplayed") && item.UserData.Played,
+				strings.Contains(filters, "isresumable") && item.UserData.PlaybackPositionTicks == 0:
+				continue
+			}
+			filtered = append(filtered, item)
+		}
+		items = filtered
+	}
+
+	jellyfinSort(items, q.Get("sortby"), q.Get("sortorder"))
+	page, start := jellyfinPage(items, q)
+	if filters == "" {
+		s.jellyfinFillUserData(r, page)
+	}
+	jellyfinJSON(w, jellyfinItems{Items: page, TotalRecordCount: len(items), StartIndex: start})
+}
+
+// jellyfinQuery returns the query with lowercased keys, as apps vary their
+// case
+func jellyfinQuery(r *http.Request) jellyfinValues {
+	q := jellyfinValues{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			q[strings.ToLower(k)] = v[0]
+		}
+	}
+	return q
+}
+
+type jellyfinValues map[string]string
+
+func (q jellyfinValues) Get(key string) string { return q[key] }
+
+// jellyfinSort sorts items by the first key of a Jellyfin SortBy
+func jellyfinSort(items []jellyfinItem, sortBy, order string) {
+	key := strings.ToLower(strings.Split(sortBy, ",")[0])
+	if key == "random" {
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		return
+	}
+	less := func(a, b *jellyfinItem) bool {
+		switch key {
+		case "datecreated":
+			return a.added.Before(b.added)
+		case "productionyear", "premieredate":
+			if a.PremiereDate != b.PremiereDate {
+				return a.PremiereDate < b.PremiereDate
+			}
+			return a.ProductionYear < b.ProductionYear
+		case "communityrating":
+			return a.CommunityRating < b.CommunityRating
+		}
+		// Seasons and episodes keep their order; everything else by name
+		if a.IndexNumber != nil && b.IndexNumber != nil {
+			return *a.IndexNumber < *b.IndexNumber
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}
+	descending := strings.HasPrefix(strings.ToLower(order), "desc")
+	sort.SliceStable(items, func(i, j int) bool {
+		if descending {
+			return less(&items[j], &items[i])
+		}
+		return less(&items[i], &items[j])
+	})
+}
+
+// jellyfinPage applies StartIndex and Limit
+func jellyfinPage(items []jellyfinItem, q jellyfinValues) ([]jellyfinItem, int) {
+	start, _ := strconv.Atoi(q.Get("startindex"))
+	start = min(max(start, 0), len(items))
+	end := len(items)
+	if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit >= 0 {
+		end = min(start+limit, end)
+	}
+	page := make([]jellyfinItem, end-start)
+	copy(page, items[start:end])
+	return page, start
+}
+
+// handleJellyfinResume handles /Users/{id}/Items/Resume: continue watching
+func (s *Server) handleJellyfinResume(w http.ResponseWriter, r *http.Request) {
+	q := jellyfinQuery(r)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	rule := s.db.GetPlayedRule(s.getActiveProfileID(r))
+	resume, err := s.db.GetContinueWatching(rule, max(limit, 20))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := []jellyfinItem{}
+	for _, cw := range resume {
+		kind := jfMovie
+		if cw.MediaType == "episode" {
+			kind = jfEpisode
+		}
+		if item, ok := s.jellyfinLoad(r, jellyfinID(kind, cw.MediaID)); ok {
+			items = append(items, *item)
+		}
+	}
+	page, start := jellyfinPage(items, q)
+	s.jellyfinFillUserData(r, page)
+	jellyfinJSON(w, jellyfinItems{Items: page, TotalRecordCount: len(items), StartIndex: start})
+}
+
+// handleJellyfinLatest handles /Users/{id}/Items/Latest: the newest movies
+// or shows of a library, as a plain list
+func (s *Server) handleJellyfinLatest(w http.ResponseWriter, r *http.Request) {
+	q := jellyfinQuery(r)
+	var items []jellyfinItem
+	var err error
+	if parentID := q.Get("parentid"); parentID != "" {
+		parent, ok := s.jellyfinLoad(r, parentID)
+		if !ok {
+			httpError(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		items, err = s.jellyfinChildren(r, parent)
+	} else {
+		items, err = s.jellyfinAll()
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	allowed := items[:0]
+	for _, item := range items {
+		if s.jellyfinAllowed(r, &item) {
+			allowed = append(allowed, item)
+		}
+	}
+	jellyfinSort(allowed, "DateCreated", "Descending")
+	if _, err := strconv.Atoi(q.Get("limit")); err != nil {
+		q["limit"] = "16"
+	}
+	page, _ := jellyfinPage(allowed, q)
+	s.jellyfinFillUserData(r, page)
+	jellyfinJSON(w, page)
+}
+
+// handleJellyfinSeasons handles /Shows/{id}/Seasons
+func (s *Server) handleJellyfinSeasons(w http.ResponseWriter, r *http.Request, showID string) {
+	show, ok := s.jellyfinLoad(r, showID)
+	if !ok || show.Type != "Series" {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	_, id, _ := parseJellyfinID(show.ID)
+	items, err := s.jellyfinSeasons(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.jellyfinFillUserData(r, items)
+	jellyfinJSON(w, jellyfinItems{Items: items, TotalRecordCount: len(items)})
+}
+
+// handleJellyfinEpisodes handles /Shows/{id}/Episodes, of one season with
+// ?SeasonId= or else of the whole show
+func (s *Server) handleJellyfinEpisodes(w http.ResponseWriter, r *http.Request, showID string) {
+	show, ok := s.jellyfinLoad(r, showID)
+	if !ok || show.Type != "Series" {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	_, id, _ := parseJellyfinID(show.ID)
+
+	var seasonIDs []int64
+	q := jellyfinQuery(r)
+	if seasonID := q.Get("seasonid"); seasonID != "" {
+		kind, n, ok := parseJellyfinID(seasonID)
+		if !ok || kind != jfSeason {
+			httpError(w, "Invalid season", http.StatusBadRequest)
+			return
+		}
+		seasonIDs = append(seasonIDs, n)
+	} else {
+		seasons, err := s.db.GetSeasonsByShow(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, season := range seasons {
+			seasonIDs = append(seasonIDs, season.ID)
+		}
+	}
+
+	items := []jellyfinItem{}
+	for _, seasonID := range seasonIDs {
+		episodes, err := s.jellyfinSeasonEpisodes(seasonID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, episodes...)
+	}
+	page, start := jellyfinPage(items, q)
+	s.jellyfinFillUserData(r, page)
+	jellyfinJSON(w, jellyfinItems{Items: page, TotalRecordCount: len(items), StartIndex: start})
+}
+
+// jellyfinMedia returns the Outpost media type, ID and file of a playable
+// item
+func (s *Server) jellyfinMedia(r *http.Request, id string) (mediaType string, mediaID int64, path string, ok bool) {
+	item, ok := s.jellyfinLoad(r, id)
+	if !ok {
+		return "", 0, "", false
+	}
+	kind, mediaID, _ := parseJellyfinID(item.ID)
+	switch kind {
+	case jfMovie:
+		m, err := s.db.GetMovie(mediaID)
+		if err != nil {
+			return "", 0, "", false
+		}
+		return "movie", mediaID, m.Path, true
+	case jfEpisode:
+		e, err := s.db.GetEpisode(mediaID)
+		if err != nil {
+			return "", 0, "", false
+		}
+		return "episode", mediaID, e.Path, true
+	}
+	return "", 0, "", false
+}
+
+// handleJellyfinPlaybackInfo handles /Items/{id}/PlaybackInfo. The file is
+// offered for direct play; apps that can't play it ask /Videos/{id}/stream
+// without static=true, which transcodes like Outpost's own player.
+func (s *Server) handleJellyfinPlaybackInfo(w http.ResponseWriter, r *http.Request, id string) {
+	item, ok := s.jellyfinLoad(r, id)
+	if !ok {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	_, _, path, ok := s.jellyfinMedia(r, id)
+	if !ok {
+		httpError(w, "Item is not playable", http.StatusBadRequest)
+		return
+	}
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	jellyfinJSON(w, map[string]interface{}{
+		"MediaSources": []map[string]interface{}{{
+			"Id":                   item.ID,
+			"Protocol":             "File",
+			"Type":                 "Default",
+			"Container":            item.Container,
+			"Size":                 size,
+			"Name":                 item.Name,
+			"IsRemote":             false,
+			"RunTimeTicks":         item.RunTimeTicks,
+			"SupportsDirectPlay":   true,
+			"SupportsDirectStream": true,
+			"SupportsTranscoding":  false,
+			"DirectStreamUrl":      fmt.Sprintf("/Videos/%s/stream?static=true&mediaSourceId=%s", item.ID, item.ID),
+			"MediaStreams":         []interface{}{},
+		}},
+		"PlaySessionId": item.ID,
+	})
+}
+
+// handleJellyfinStream handles /Videos/{id}/stream, through Outpost's
+// stream handler so bandwidth is metered and limits apply
+func (s *Server) handleJellyfinStream(w http.ResponseWriter, r *http.Request, id string) {
+	mediaType, mediaID, _, ok := s.jellyfinMedia(r, id)
+	if !ok {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	r = r.Clone(r.Context())
+	r.URL.Path = fmt.Sprintf("/api/stream/%s/%d", mediaType, mediaID)
+	if strings.EqualFold(r.URL.Query().Get("static"), "true") {
+		r.URL.RawQuery = "static=true"
+	} else {
+		r.URL.RawQuery = ""
+	}
+	s.handleStream(w, r)
+}
+
+// handleJellyfinImage handles /Items/{id}/Images/{type}: Primary is the
+// poster (an episode's still), Backdrop and Thumb the backdrop
+func (s *Server) handleJellyfinImage(w http.ResponseWriter, r *http.Request, id, imageType string) {
+	kind, n, ok := parseJellyfinID(id)
+	if !ok {
+		notFound(w)
+		return
+	}
+	var primary, backdrop *string
+	switch kind {
+	case jfMovie:
+		if m, err := s.db.GetMovie(n); err == nil {
+			primary, backdrop = m.PosterPath, m.BackdropPath
+		}
+	case jfShow:
+		if sh, err := s.db.GetShow(n); err == nil {
+			primary, backdrop = sh.PosterPath, sh.BackdropPath
+		}
+	case jfSeason:
+		if season, err := s.db.GetSeasonByID(n); err == nil {
+			if sh, err := s.db.GetShow(season.ShowID); err == nil {
+				primary, backdrop = sh.PosterPath, sh.BackdropPath
+				if season.PosterPath != nil {
+					primary = season.PosterPath
+				}
+			}
+		}
+	case jfEpisode:
+		if e, err := s.db.GetEpisode(n); err == nil {
+			if season, err := s.db.GetSeasonByID(e.SeasonID); err == nil {
+				if sh, err := s.db.GetShow(season.ShowID); err == nil {
+					primary, backdrop = sh.PosterPath, sh.BackdropPath
+				}
+			}
+			if e.StillPath != nil {
+				primary = e.StillPath
+			}
+		}
+	}
+
+	image := primary
+	if imageType == "backdrop" || imageType == "thumb" {
+		image = backdrop
+	}
+	if image == nil || *image == "" {
+		notFound(w)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=86400")
+	s.serveStoredFile(w, r, s.images, *image)
+}
+
+// handleJellyfinPlayed handles /Users/{id}/PlayedItems/{itemId}: POST marks
+// an item watched, DELETE unwatched
+func (s *Server) handleJellyfinPlayed(w http.ResponseWriter, r *http.Request, id string) {
+	item, ok := s.jellyfinLoad(r, id)
+	if !ok {
+		httpError(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	mediaType, mediaID, _, ok := s.jellyfinMedia(r, id)
+	if !ok {
+		httpError(w, "Item is not playable", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		duration := float64(item.RunTimeTicks / ticksPerSecond)
+		if duration <= 0 {
+			duration = 3600
+		}
+		err = s.db.MarkAsWatched(mediaType, mediaID, duration)
+	case http.MethodDelete:
+		err = s.db.MarkAsUnwatched(mediaType, mediaID)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := []jellyfinItem{*item}
+	s.jellyfinFillUserData(r, items)
+	jellyfinJSON(w, items[0].UserData)
+}
+
+// handleJellyfinPlaying handles the /Sessions/Playing reports apps send as
+// they play, saving the position like Outpost's player does
+func (s *Server) handleJellyfinPlaying(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ItemID        string `json:"ItemId"`
+		PositionTicks int64  `json:"PositionTicks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+	item, ok := s.jellyfinLoad(r, req.ItemID)
+	mediaType, mediaID, _, playable := s.jellyfinMedia(r, req.ItemID)
+	if profileID == nil || !ok || !playable || req.PositionTicks <= 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	p := &database.Progress{
+		ProfileID: *profileID,
+		MediaType: mediaType,
+		MediaID:   mediaID,
+		Position:  float64(req.PositionTicks) / ticksPerSecond,
+		Duration:  float64(item.RunTimeTicks) / ticksPerSecond,
+	}
+	// Without a runtime the last saved duration, from Outpost's player, is
+	// the best there is
+	if p.Duration <= 0 {
+		if prev, err := s.db.GetProgress(*profileID, mediaType, mediaID); err == nil {
+			p.Duration = prev.Duration
+		}
+	}
+	if _, err := s.db.SaveProgressPlayed(p); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
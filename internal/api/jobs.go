@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/jobs"
+)
+
+// Background jobs
+//
+// Library scans, metadata refreshes, subtitle extractions, subtitle
+// searches, storage copies and bulk library operations run as jobs.
+// Admins list them at /api/jobs, poll one's progress at /api/jobs/{id} and
+// stop it with POST /api/jobs/{id}/cancel.
+
+// Job types
+const (
+	jobScan               = "scan"
+	jobMetadataRefresh    = "metadata_refresh"
+	jobSubtitleExtraction = "subtitle_extraction"
+	jobSubtitleSearch     = "subtitle_search"
+	jobStorageCopy        = "storage_copy"
+	jobLibraryBulk        = "library_bulk"
+)
+
+// jobView is a job with its result decoded for clients
+type jobView struct {
+	database.Job
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+func newJobView(job *database.Job) jobView {
+	v := jobView{Job: *job}
+	if job.Result != "" {
+		v.Result = json.RawMessage(job.Result)
+	}
+	return v
+}
+
+// handleJobs handles GET /api/jobs, listing jobs newest first, optionally
+// narrowed by ?status=, ?type= and ?limit=
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := database.JobFilter{Status: query.Get("status"), Type: query.Get("type")}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	list, err := s.jobs.List(filter)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := make([]jobView, 0, len(list))
+	for i := range list {
+		views = append(views, newJobView(&list[i]))
+	}
+	json.NewEncoder(w).Encode(views)
+}
+
+// handleJob handles GET /api/jobs/{id} and POST /api/jobs/{id}/cancel
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "cancel" {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.cancelJob(w, r, id)
+		return
+	}
+	if len(parts) != 1 {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, err := s.jobs.Get(id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		httpError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(newJobView(job))
+}
+
+// cancelJob stops a queued or running job. The job ends as cancelled once
+// its work notices, so it's answered with 202.
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request, id int64) {
+	err := s.jobs.Cancel(id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		httpError(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(r, "job.cancel", "job", &id, "")
+
+	w.WriteHeader(http.StatusAccepted)
+	if job, err := s.jobs.Get(id); err == nil {
+		json.NewEncoder(w).Encode(newJobView(job))
+	}
+}
+
+// submitJob starts a job on behalf of the user making a request
+func (s *Server) submitJob(r *http.Request, jobType, title string, fn jobs.Func) (*database.Job, error) {
+	var createdBy *int64
+	if user := s.getCurrentUser(r); user != nil {
+		createdBy = &user.ID
+	}
+	return s.jobs.Submit(jobType, title, createdBy, fn)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+)
+
+// Language handling
+//
+// Errors meant for end users are shown in the caller's language: their own
+// setting when signed in, otherwise the browser's Accept-Language, otherwise
+// the server's language.
+
+// requestLanguage returns the language to answer a request in. The user may
+// be nil, in which case the signed-in user, if any, is used.
+func requestLanguage(r *http.Request, user *database.User) string {
+	if user == nil {
+		user, _ = r.Context().Value(userContextKey).(*database.User)
+	}
+	if user != nil && user.Language != nil {
+		if lang := i18n.Normalize(*user.Language); lang != "" {
+			return lang
+		}
+	}
+	if lang := i18n.Match(r.Header.Get("Accept-Language")); lang != "" {
+		return lang
+	}
+	return i18n.Default()
+}
+
+// localizedError writes an error in the caller's language
+func localizedError(w http.ResponseWriter, r *http.Request, status int, format string, args ...interface{}) {
+	httpError(w, i18n.T(requestLanguage(r, nil), format, args...), status)
+}
+
+// normalizeLanguage validates a user's language. An empty value returns nil,
+// meaning the server's language; ok is false if it isn't supported.
+func normalizeLanguage(value string) (*string, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	lang := i18n.Normalize(value)
+	if lang == "" {
+		return nil, false
+	}
+	return &lang, true
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Library artwork
+
+// validateArtwork checks a library's artwork settings, leaving empty ones for
+// the defaults
+func validateArtwork(style, text string) string {
+	if style != "" && !database.ValidArtworkStyle(style) {
+		return "Artwork style must be poster or landscape"
+	}
+	if text != "" && !database.ValidArtworkText(text) {
+		return "Artwork text must be any, text or textless"
+	}
+	return ""
+}
+
+// handleLibraryArtwork handles /api/libraries/{id}/artwork: the artwork style
+// of a library's grids. Changing it picks new artwork for the library's movies
+// and shows in the background.
+func (s *Server) handleLibraryArtwork(w http.ResponseWriter, r *http.Request, libraryID int64) {
+	lib, err := s.db.GetLibrary(libraryID)
+	if err != nil {
+		httpError(w, "Library not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Style string `json:"artworkStyle"`
+			Text  string `json:"artworkText"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if msg := validateArtwork(req.Style, req.Text); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if req.Style == "" {
+			req.Style = lib.ArtworkStyle
+		}
+		if req.Text == "" {
+			req.Text = lib.ArtworkText
+		}
+
+		changed := req.Style != lib.ArtworkStyle || req.Text != lib.ArtworkText
+		if err := s.db.UpdateLibraryArtwork(libraryID, req.Style, req.Text); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lib.ArtworkStyle, lib.ArtworkText = req.Style, req.Text
+		if changed && s.metadata != nil {
+			go s.metadata.RefreshLibraryArtwork(libraryID)
+		}
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"libraryId":    libraryID,
+		"artworkStyle": lib.ArtworkStyle,
+		"artworkText":  lib.ArtworkText,
+	})
+}
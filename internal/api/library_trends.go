@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Library growth trends
+//
+// GET /api/stats/trends charts how libraries have grown from the daily
+// snapshots the Library Stats task records. Query: from and to (YYYY-MM-DD,
+// default the last year), libraryId to narrow it to one library, and
+// interval, day (the default), week or month. Longer intervals keep each
+// library's last snapshot in them, and points are dated by the day the
+// interval starts so libraries line up. Totals add up the libraries at each
+// point, including ones since removed.
+
+// trendPoint is a library's, or all libraries', size at a point
+type trendPoint struct {
+	Day   string `json:"day"`
+	Items int    `json:"items"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// libraryTrend is one library's points
+type libraryTrend struct {
+	LibraryID   int64        `json:"libraryId"`
+	LibraryName string       `json:"libraryName"`
+	LibraryType string       `json:"libraryType"`
+	Points      []trendPoint `json:"points"`
+}
+
+// trendBucket returns the day an interval containing a day starts
+func trendBucket(day, interval string) string {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	switch interval {
+	case "week":
+		// Weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	case "month":
+		return t.Format("2006-01") + "-01"
+	}
+	return day
+}
+
+// handleStatsTrends handles GET /api/stats/trends
+func (s *Server) handleStatsTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Snapshots are recorded in server days
+	now := timezone.Now()
+	from := now.AddDate(-1, 0, 1).Format("2006-01-02")
+	to := now.Format("2006-01-02")
+	if v := r.URL.Query().Get("from"); v != "" {
+		from = v
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to = v
+	}
+	if !validDay(from) || !validDay(to) {
+		httpError(w, "from and to must be dates (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if from > to {
+		httpError(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	switch interval {
+	case "":
+		interval = "day"
+	case "day", "week", "month":
+	default:
+		httpError(w, "interval must be day, week or month", http.StatusBadRequest)
+		return
+	}
+
+	var libraryID int64
+	if v := r.URL.Query().Get("libraryId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid libraryId")
+			return
+		}
+		libraryID = id
+	}
+
+	snapshots, err := s.db.GetLibrarySnapshots(from, to, libraryID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	libraries, totals := libraryTrends(snapshots, interval)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"interval":  interval,
+		"libraries": libraries,
+		"totals":    totals,
+	})
+}
+
+// libraryTrends groups snapshots, oldest first, into each library's points
+// and the totals over all of them
+func libraryTrends(snapshots []database.LibrarySnapshot, interval string) ([]libraryTrend, []trendPoint) {
+	libraries := []libraryTrend{}
+	index := make(map[int64]int)
+	for _, snap := range snapshots {
+		i, ok := index[snap.LibraryID]
+		if !ok {
+			i = len(libraries)
+			index[snap.LibraryID] = i
+			libraries = append(libraries, libraryTrend{LibraryID: snap.LibraryID, Points: []trendPoint{}})
+		}
+		lib := &libraries[i]
+		// The newest name and type win, in case the library was renamed
+		lib.LibraryName = snap.LibraryName
+		lib.LibraryType = snap.LibraryType
+
+		point := trendPoint{Day: trendBucket(snap.Day, interval), Items: snap.Items, Files: snap.Files, Size: snap.Size}
+		if n := len(lib.Points); n > 0 && lib.Points[n-1].Day == point.Day {
+			lib.Points[n-1] = point
+		} else {
+			lib.Points = append(lib.Points, point)
+		}
+	}
+
+	totals := []trendPoint{}
+	totalIndex := make(map[string]int)
+	for _, lib := range libraries {
+		for _, p := range lib.Points {
+			i, ok := totalIndex[p.Day]
+			if !ok {
+				i = len(totals)
+				totalIndex[p.Day] = i
+				totals = append(totals, trendPoint{Day: p.Day})
+			}
+			totals[i].Items += p.Items
+			totals[i].Files += p.Files
+			totals[i].Size += p.Size
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Day < totals[j].Day })
+	return libraries, totals
+}
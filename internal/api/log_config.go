@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Log configuration
+//
+// Admins can change the default log level, give modules their own and switch
+// between text and JSON output without a restart. Changes are saved and
+// applied again at startup.
+
+// logLevels are the levels a module can log at
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// handleLogConfig handles GET and PUT /api/logs/config
+func (s *Server) handleLogConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeLogConfig(w)
+
+	case http.MethodPut:
+		var cfg logging.Config
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := logging.Configure(cfg); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		saved, err := json.Marshal(logging.CurrentConfig())
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := s.db.SetSetting(logging.ConfigSetting, string(saved)); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "logs.config", "setting", nil, string(saved))
+		requestLog(r).Infof("Log config changed: %s", saved)
+		s.writeLogConfig(w)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeLogConfig writes the logging configuration in effect, with the modules
+// and levels it can use
+func (s *Server) writeLogConfig(w http.ResponseWriter) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":  logging.CurrentConfig(),
+		"modules": logging.Modules(),
+		"levels":  logLevels,
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/metadata"
+)
+
+// handleLyrics handles /api/lyrics/{trackId}: GET returns a track's lyrics,
+// with the time of each line when they're synced, and DELETE forgets the
+// cached ones so they're looked up again. Admins can look them up again
+// straight away with GET ?refresh=true.
+func (s *Server) handleLyrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/lyrics/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.allowMediaAccess(w, r, "track", id) {
+		return
+	}
+	track, err := s.db.GetTrack(id)
+	if err != nil {
+		httpError(w, "Track not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		refresh := r.URL.Query().Get("refresh") == "true"
+		if refresh && user.Role != "admin" {
+			httpError(w, "Only admins can refresh lyrics", http.StatusForbidden)
+			return
+		}
+		result, err := s.metadata.TrackLyrics(track, refresh)
+		if errors.Is(err, metadata.ErrLyricsDisabled) {
+			httpError(w, "Lyrics lookups are turned off", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			requestLog(r).Errorf("Failed to get lyrics of track %d: %v", track.ID, err)
+			httpError(w, "Failed to fetch lyrics", http.StatusBadGateway)
+			return
+		}
+		if result == nil {
+			httpError(w, "No lyrics found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+
+	case http.MethodDelete:
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+			return
+		}
+		if err := s.db.DeleteLyrics(track.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
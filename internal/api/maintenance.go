@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Library maintenance
+
+// handleMaintenanceDedupe handles /api/maintenance/dedupe. GET lists movies
+// and shows matched to the same TMDB ID more than once; POST merges them.
+// ?libraryId= limits either to one library.
+func (s *Server) handleMaintenanceDedupe(w http.ResponseWriter, r *http.Request) {
+	var libraryID int64
+	if v := r.URL.Query().Get("libraryId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid library ID")
+			return
+		}
+		libraryID = id
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		movies, err := s.db.FindDuplicateMovies(libraryID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		shows, err := s.db.FindDuplicateShows(libraryID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		groups := append([]database.DuplicateGroup{}, movies...)
+		groups = append(groups, shows...)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups": groups,
+		})
+
+	case http.MethodPost:
+		result, err := s.scanner.Dedupe(libraryID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
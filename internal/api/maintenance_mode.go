@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Maintenance mode
+//
+// An admin turns maintenance mode on before backups, migrations or storage
+// work. It pauses the scheduler and acquisition polling and turns away new
+// streams with a 503 that clients can show, while streams already playing
+// carry on until they finish. It's saved, so a restart stays in maintenance
+// until an admin turns it off at /api/system/maintenance.
+
+// maintenanceSetting stores the maintenance state as JSON
+const maintenanceSetting = "maintenance_mode"
+
+// streamDrainWindow is how long a stream counts as playing after its last
+// request, since players fetch ranges and segments as they go
+const streamDrainWindow = 10 * time.Minute
+
+// maintenanceRetryAfter is the Retry-After clients get while in maintenance
+const maintenanceRetryAfter = 5 * time.Minute
+
+// maxMaintenanceMessage bounds the message shown to clients
+const maxMaintenanceMessage = 500
+
+// maintenanceState is what's saved and shown to clients
+type maintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	By      string     `json:"by,omitempty"`
+}
+
+// maintenanceMode tracks whether the server is in maintenance and which
+// streams were playing, so those can drain
+type maintenanceMode struct {
+	mu      sync.Mutex
+	state   maintenanceState
+	streams map[string]time.Time // Last request per stream
+
+	applyMu sync.Mutex // Serializes pausing and resuming background services
+}
+
+func newMaintenanceMode() *maintenanceMode {
+	return &maintenanceMode{streams: make(map[string]time.Time)}
+}
+
+// current returns the maintenance state
+func (m *maintenanceMode) current() maintenanceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// admit records a request for a stream, returning false if it's a new stream
+// during maintenance
+func (m *maintenanceMode) admit(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, last := range m.streams {
+		if now.Sub(last) >= streamDrainWindow {
+			delete(m.streams, k)
+		}
+	}
+	if _, playing := m.streams[key]; m.state.Enabled && !playing {
+		return false
+	}
+	m.streams[key] = now
+	return true
+}
+
+// activeStreams counts streams requested within the drain window
+func (m *maintenanceMode) activeStreams() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, last := range m.streams {
+		if time.Since(last) < streamDrainWindow {
+			count++
+		}
+	}
+	return count
+}
+
+// InMaintenance reports whether maintenance mode is on, so startup can leave
+// the scheduler and acquisition paused
+func (s *Server) InMaintenance() bool {
+	return s.maintenance.current().Enabled
+}
+
+// loadMaintenance restores the saved maintenance state
+func (s *Server) loadMaintenance() {
+	value, err := s.db.GetSetting(maintenanceSetting)
+	if err != nil || value == "" {
+		return
+	}
+	var state maintenanceState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		logger.Warnf("Ignoring invalid %s setting: %v", maintenanceSetting, err)
+		return
+	}
+	s.maintenance.mu.Lock()
+	s.maintenance.state = state
+	s.maintenance.mu.Unlock()
+}
+
+// validateMaintenanceSetting keeps maintenance mode out of the generic
+// settings endpoint, which wouldn't pause or resume anything
+func validateMaintenanceSetting(key, value string) error {
+	if key == maintenanceSetting {
+		return fmt.Errorf("maintenance mode is changed at /api/system/maintenance")
+	}
+	return nil
+}
+
+// setMaintenance saves a new maintenance state and pauses or resumes the
+// background services to match
+func (s *Server) setMaintenance(state maintenanceState) error {
+	saved, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := s.db.SetSetting(maintenanceSetting, string(saved)); err != nil {
+		return err
+	}
+	s.maintenance.mu.Lock()
+	s.maintenance.state = state
+	s.maintenance.mu.Unlock()
+
+	// Stopping waits for running tasks, which can take a while
+	go s.applyMaintenance()
+	return nil
+}
+
+// applyMaintenance pauses or resumes the scheduler, acquisition and library
+// watching for the current state. Runs are serialized and read the state when they start, so
+// the last one always matches it.
+func (s *Server) applyMaintenance() {
+	s.maintenance.applyMu.Lock()
+	defer s.maintenance.applyMu.Unlock()
+
+	if s.maintenance.current().Enabled {
+		logger.Infof("Maintenance mode on: pausing scheduler and acquisition")
+		s.acquisition.Stop()
+		s.scheduler.Stop()
+		s.watcher.Stop()
+		logger.Infof("Scheduler and acquisition paused for maintenance")
+		return
+	}
+	logger.Infof("Maintenance mode off: resuming scheduler and acquisition")
+	s.scheduler.Start()
+	s.acquisition.Start()
+	s.watcher.Sync()
+}
+
+// admitStream turns away new streams during maintenance with a 503. Streams
+// already playing are let through so they can finish.
+func (s *Server) admitStream(w http.ResponseWriter, r *http.Request, mediaType string, mediaID int64) bool {
+	// Players open several requests per stream, so key it by who's watching
+	viewer := "ip:" + netaccess.ClientIP(r)
+	if session, ok := r.Context().Value(sessionContextKey).(*database.Session); ok {
+		viewer = "session:" + strconv.FormatInt(session.ID, 10)
+	} else if user := s.getCurrentUser(r); user != nil {
+		viewer = "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	if s.maintenance.admit(fmt.Sprintf("%s/%s/%d", viewer, mediaType, mediaID)) {
+		return true
+	}
+	writeMaintenance(w, r, s.maintenance.current())
+	return false
+}
+
+// writeMaintenance answers 503 with the maintenance state and a message in
+// the client's language
+func writeMaintenance(w http.ResponseWriter, r *http.Request, state maintenanceState) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+	writeErrorDetails(w, http.StatusServiceUnavailable, codeMaintenance,
+		i18n.T(requestLanguage(r, nil), "Outpost is down for maintenance, try again later"),
+		map[string]interface{}{"message": state.Message, "since": state.Since})
+}
+
+// handleMaintenance handles /api/system/maintenance. Anyone signed in can GET
+// the state so clients can show it; admins PUT {enabled, message} to change it.
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeMaintenanceState(w)
+
+	case http.MethodPut:
+		user := s.getCurrentUser(r)
+		if user == nil || user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+			return
+		}
+		var req struct {
+			Enabled bool   `json:"enabled"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		req.Message = strings.TrimSpace(req.Message)
+		if len(req.Message) > maxMaintenanceMessage {
+			httpError(w, fmt.Sprintf("Message must be at most %d characters", maxMaintenanceMessage), http.StatusBadRequest)
+			return
+		}
+
+		state := s.maintenance.current()
+		if req.Enabled && !state.Enabled {
+			now := time.Now().UTC()
+			state = maintenanceState{Enabled: true, Since: &now, By: user.Username}
+		} else if !req.Enabled {
+			state = maintenanceState{}
+		}
+		if state.Enabled {
+			state.Message = req.Message
+		}
+		if err := s.setMaintenance(state); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		action := "maintenance.disable"
+		if state.Enabled {
+			action = "maintenance.enable"
+		}
+		s.recordAudit(r, action, "system", nil, state.Message)
+		requestLog(r).Infof("Maintenance mode set to %v by %s", state.Enabled, user.Username)
+		s.writeMaintenanceState(w)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeMaintenanceState writes the maintenance state with the number of
+// streams still draining
+func (s *Server) writeMaintenanceState(w http.ResponseWriter) {
+	state := s.maintenance.current()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":       state.Enabled,
+		"message":       state.Message,
+		"since":         state.Since,
+		"by":            state.By,
+		"activeStreams": s.maintenance.activeStreams(),
+	})
+}
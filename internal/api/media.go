@@ -1,10 +1,11 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -14,6 +15,29 @@ import (
 
 // Media handlers
 
+// allowMediaAccess refuses media in libraries the user can't access. It
+// writes the error and returns false when the request is refused. Media
+// that doesn't exist is let through for the handler to report.
+func (s *Server) allowMediaAccess(w http.ResponseWriter, r *http.Request, mediaType string, id int64) bool {
+	user := s.getCurrentUser(r)
+	if user == nil || user.LibraryIDs == nil || user.Role == "admin" {
+		return true
+	}
+	libraryID, ok, err := s.db.GetMediaLibraryID(mediaType, id)
+	if !ok || errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !user.CanAccessLibrary(libraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return false
+	}
+	return true
+}
+
 // MovieWithWatchState extends Movie with watch state
 type MovieWithWatchState struct {
 	database.Movie
@@ -25,25 +49,25 @@ func (s *Server) handleMovies(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	movies, err := s.db.GetMovies()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if movies == nil {
 		movies = []database.Movie{}
 	}
 
-	// Filter based on user's content rating limit
+	// Filter based on user's content rating limit and library access
 	user := s.getCurrentUser(r)
-	if user != nil && user.ContentRatingLimit != nil {
+	if user != nil && (user.ContentRatingLimit != nil || user.LibraryIDs != nil) {
 		var filtered []database.Movie
 		for _, m := range movies {
-			if s.isContentAllowed(user, m.ContentRating, r) {
+			if user.CanAccessLibrary(m.LibraryID) && s.isContentAllowed(user, m.ContentRating, r) {
 				filtered = append(filtered, m)
 			}
 		}
@@ -54,7 +78,7 @@ func (s *Server) handleMovies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get watch states
-	watchStates, _ := s.db.GetAllMovieWatchStates()
+	watchStates, _ := s.db.GetAllMovieWatchStates(s.db.GetPlayedRule(s.getActiveProfileID(r)))
 
 	// Build response with watch states
 	result := make([]MovieWithWatchState, len(movies))
@@ -81,25 +105,25 @@ func (s *Server) handleShows(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	shows, err := s.db.GetShows()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if shows == nil {
 		shows = []database.Show{}
 	}
 
-	// Filter based on user's content rating limit
+	// Filter based on user's content rating limit and library access
 	user := s.getCurrentUser(r)
-	if user != nil && user.ContentRatingLimit != nil {
+	if user != nil && (user.ContentRatingLimit != nil || user.LibraryIDs != nil) {
 		var filtered []database.Show
 		for _, sh := range shows {
-			if s.isContentAllowed(user, sh.ContentRating, r) {
+			if user.CanAccessLibrary(sh.LibraryID) && s.isContentAllowed(user, sh.ContentRating, r) {
 				filtered = append(filtered, sh)
 			}
 		}
@@ -110,7 +134,7 @@ func (s *Server) handleShows(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get watch states
-	watchStates, _ := s.db.GetAllShowWatchStates()
+	watchStates, _ := s.db.GetAllShowWatchStates(s.db.GetPlayedRule(s.getActiveProfileID(r)))
 
 	// Build response with watch states
 	result := make([]ShowWithWatchState, len(shows))
@@ -130,34 +154,39 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Parse path: /api/shows/{id} or /api/shows/{id}/refresh or /api/shows/{id}/match
+	// or /api/shows/{id}/watched
 	path := strings.TrimPrefix(r.URL.Path, "/api/shows/")
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Show ID required", http.StatusBadRequest)
+		httpError(w, "Show ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
 	show, err := s.db.GetShow(id)
 	if err != nil {
-		http.Error(w, "Show not found", http.StatusNotFound)
+		httpError(w, "Show not found", http.StatusNotFound)
 		return
 	}
 
-	// Check content rating restriction
+	// Check library access and content rating restriction
 	user := s.getCurrentUser(r)
+	if user != nil && !user.CanAccessLibrary(show.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
 	if user != nil && user.ContentRatingLimit != nil && !s.isContentAllowed(user, show.ContentRating, r) {
 		// Content is restricted - check if PIN is required
 		if user.RequirePin {
-			http.Error(w, "Content restricted - PIN required", http.StatusForbidden)
+			localizedError(w, r, http.StatusForbidden, "Content restricted - PIN required")
 		} else {
-			http.Error(w, "Content not available", http.StatusForbidden)
+			localizedError(w, r, http.StatusForbidden, "Content not available")
 		}
 		return
 	}
@@ -165,12 +194,12 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	// Handle refresh endpoint
 	if len(parts) == 2 && parts[1] == "refresh" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		if s.metadata != nil {
 			if err := s.metadata.FetchShowMetadata(show); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			show, _ = s.db.GetShow(id)
@@ -182,19 +211,19 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	// Handle match endpoint
 	if len(parts) == 2 && parts[1] == "match" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		var req struct {
 			TmdbID int64 `json:"tmdbId"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
-		if s.metadata != nil {
+		if s.metadataConfigured() {
 			if err := s.metadata.FetchShowMetadataByTmdbID(show, req.TmdbID); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			show, _ = s.db.GetShow(id)
@@ -203,10 +232,16 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle activity endpoint
+	if len(parts) == 2 && parts[1] == "activity" {
+		s.handleMediaActivity(w, r, "show", show.TmdbID)
+		return
+	}
+
 	// Handle missing episodes endpoint
 	if len(parts) == 2 && parts[1] == "missing" {
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		s.handleMissingEpisodes(w, r, show)
@@ -216,23 +251,39 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	// Handle request-missing endpoint
 	if len(parts) == 2 && parts[1] == "request-missing" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		s.handleRequestMissingEpisodes(w, r, show)
 		return
 	}
 
+	// Handle watched endpoints: /api/shows/{id}/watched and
+	// /api/shows/{id}/seasons/{number}/watched
+	if len(parts) == 2 && parts[1] == "watched" {
+		s.handleShowWatched(w, r, show, nil)
+		return
+	}
+	if len(parts) == 4 && parts[1] == "seasons" && parts[3] == "watched" {
+		seasonNumber, err := strconv.Atoi(parts[2])
+		if err != nil {
+			httpError(w, "Invalid season number", http.StatusBadRequest)
+			return
+		}
+		s.handleShowWatched(w, r, show, &seasonNumber)
+		return
+	}
+
 	// Handle detect-intros endpoint
 	if len(parts) >= 2 && parts[1] == "detect-intros" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		// Check admin permission
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
 		s.handleDetectIntros(w, r, show, parts[2:])
@@ -241,7 +292,7 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 
 	// Default: GET show
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -251,7 +302,7 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 func (s *Server) sendShowDetail(w http.ResponseWriter, show *database.Show) {
 	seasons, err := s.db.GetSeasonsByShow(show.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -280,6 +331,41 @@ func (s *Server) sendShowDetail(w http.ResponseWriter, show *database.Show) {
 	json.NewEncoder(w).Encode(detail)
 }
 
+// handleShowWatched marks a whole show, or one season of it, watched (POST)
+// or unwatched (DELETE) for the active profile. GET and both updates return
+// the show's watch state with a per-season breakdown.
+func (s *Server) handleShowWatched(w http.ResponseWriter, r *http.Request, show *database.Show, seasonNumber *int) {
+	updated := 0
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		n, err := s.db.SetShowWatched(s.getActiveProfileID(r), show.ID, seasonNumber, r.Method == http.MethodPost)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n == 0 && seasonNumber != nil {
+			httpError(w, "Season not found", http.StatusNotFound)
+			return
+		}
+		updated = n
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	detail, err := s.db.GetShowWatchDetail(s.db.GetPlayedRule(s.getActiveProfileID(r)), show.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		ShowID int64 `json:"showId"`
+		*database.ShowWatchDetail
+		Updated int `json:"updated"`
+	}{show.ID, detail, updated})
+}
+
 func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -288,13 +374,16 @@ func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Episode ID required", http.StatusBadRequest)
+		httpError(w, "Episode ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid episode ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid episode ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, "episode", id) {
 		return
 	}
 
@@ -309,7 +398,7 @@ func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
 
 	episode, err := s.db.GetEpisode(id)
 	if err != nil {
-		http.Error(w, "Episode not found", http.StatusNotFound)
+		httpError(w, "Episode not found", http.StatusNotFound)
 		return
 	}
 
@@ -318,8 +407,9 @@ func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
 		// Get showId for the episode
 		showID, err := s.db.GetShowIDForEpisode(id)
 		if err != nil {
-			log.Printf("Failed to get show ID for episode %d: %v", id, err)
+			requestLog(r).Errorf("Failed to get show ID for episode %d: %v", id, err)
 		}
+		episode.Versions, _ = s.db.GetMediaVersions("episode", episode.ID)
 		response := struct {
 			*database.Episode
 			ShowID int64 `json:"showId"`
@@ -332,23 +422,39 @@ func (s *Server) handleEpisode(w http.ResponseWriter, r *http.Request) {
 		// Admin only
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
-		// Delete the file if it exists
-		if episode.Path != "" {
-			if err := os.Remove(episode.Path); err != nil && !os.IsNotExist(err) {
-				log.Printf("Failed to delete episode file: %v", err)
-			}
+		showID, _ := s.db.GetShowIDForEpisode(id)
+		show, showErr := s.db.GetShow(showID)
+		title := episode.Title
+		if showErr == nil {
+			title = show.Title + " - " + episode.Title
+		}
+		// Move the file to the trash so the delete can be undone
+		if err := s.trash.Delete(&database.TrashItem{
+			MediaType:    "episode",
+			MediaID:      id,
+			Title:        title,
+			OriginalPath: episode.Path,
+			DeletedBy:    &user.ID,
+		}, episode); err != nil {
+			requestLog(r).Errorf("Failed to move episode file to the trash: %v", err)
+			httpError(w, "Couldn't move the file to the trash", http.StatusInternalServerError)
+			return
 		}
 		// Delete from database
 		if err := s.db.DeleteEpisode(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if showErr == nil {
+			s.recordDeletion(r, "show", show.TmdbID, title,
+				map[string]interface{}{"episodeId": id, "showId": showID, "episodeNumber": episode.EpisodeNumber, "path": episode.Path})
+		}
 		w.WriteHeader(http.StatusNoContent)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -357,23 +463,23 @@ func (s *Server) handleEpisodeSegments(w http.ResponseWriter, r *http.Request, e
 	switch r.Method {
 	case http.MethodGet:
 		// GET /api/episodes/{id}/segments - Get all segments for episode
-		log.Printf("GET segments for episode %d", episodeID)
+		requestLog(r).Infof("GET segments for episode %d", episodeID)
 		segments, err := s.db.GetMediaSegments(episodeID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if segments == nil {
 			segments = []database.MediaSegment{}
 		}
-		log.Printf("Returning %d segments", len(segments))
+		requestLog(r).Infof("Returning %d segments", len(segments))
 		json.NewEncoder(w).Encode(segments)
 
 	case http.MethodPost:
 		// POST /api/episodes/{id}/segments - Create/update a segment (admin only)
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
 
@@ -383,14 +489,14 @@ func (s *Server) handleEpisodeSegments(w http.ResponseWriter, r *http.Request, e
 			EndSeconds   float64 `json:"endSeconds"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		// Validate segment type
 		validTypes := map[string]bool{"intro": true, "credits": true, "recap": true, "preview": true}
 		if !validTypes[req.SegmentType] {
-			http.Error(w, "Invalid segment type. Must be: intro, credits, recap, or preview", http.StatusBadRequest)
+			httpError(w, "Invalid segment type. Must be: intro, credits, recap, or preview", http.StatusBadRequest)
 			return
 		}
 
@@ -404,7 +510,7 @@ func (s *Server) handleEpisodeSegments(w http.ResponseWriter, r *http.Request, e
 		}
 
 		if err := s.db.CreateMediaSegment(segment); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -415,14 +521,14 @@ func (s *Server) handleEpisodeSegments(w http.ResponseWriter, r *http.Request, e
 		// DELETE /api/episodes/{id}/segments/{segmentId} - Delete a specific segment (admin only)
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
 
 		if len(subParts) == 0 {
 			// Delete all segments for episode
 			if err := s.db.DeleteMediaSegmentsByEpisode(episodeID); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			w.WriteHeader(http.StatusNoContent)
@@ -431,18 +537,18 @@ func (s *Server) handleEpisodeSegments(w http.ResponseWriter, r *http.Request, e
 
 		segmentID, err := strconv.ParseInt(subParts[0], 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid segment ID", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid segment ID")
 			return
 		}
 
 		if err := s.db.DeleteMediaSegment(segmentID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -455,30 +561,34 @@ func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Movie ID required", http.StatusBadRequest)
+		httpError(w, "Movie ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid movie ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid movie ID")
 		return
 	}
 
 	movie, err := s.db.GetMovie(id)
 	if err != nil {
-		http.Error(w, "Movie not found", http.StatusNotFound)
+		httpError(w, "Movie not found", http.StatusNotFound)
 		return
 	}
 
-	// Check content rating restriction
+	// Check library access and content rating restriction
 	user := s.getCurrentUser(r)
+	if user != nil && !user.CanAccessLibrary(movie.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
 	if user != nil && user.ContentRatingLimit != nil && !s.isContentAllowed(user, movie.ContentRating, r) {
 		// Content is restricted - check if PIN is required
 		if user.RequirePin {
-			http.Error(w, "Content restricted - PIN required", http.StatusForbidden)
+			localizedError(w, r, http.StatusForbidden, "Content restricted - PIN required")
 		} else {
-			http.Error(w, "Content not available", http.StatusForbidden)
+			localizedError(w, r, http.StatusForbidden, "Content not available")
 		}
 		return
 	}
@@ -486,12 +596,12 @@ func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
 	// Handle refresh endpoint
 	if len(parts) == 2 && parts[1] == "refresh" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		if s.metadata != nil {
 			if err := s.metadata.FetchMovieMetadata(movie); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			// Reload movie to get updated data
@@ -504,19 +614,19 @@ func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
 	// Handle match endpoint
 	if len(parts) == 2 && parts[1] == "match" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		var req struct {
 			TmdbID int64 `json:"tmdbId"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
-		if s.metadata != nil {
+		if s.metadataConfigured() {
 			if err := s.metadata.FetchMovieMetadataByTmdbID(movie, req.TmdbID); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			movie, _ = s.db.GetMovie(id)
@@ -525,17 +635,17 @@ func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle activity endpoint
+	if len(parts) == 2 && parts[1] == "activity" {
+		s.handleMediaActivity(w, r, "movie", movie.TmdbID)
+		return
+	}
+
 	// Handle DELETE
 	if r.Method == http.MethodDelete {
-		// Delete the file if it exists
-		if movie.Path != "" {
-			if err := os.Remove(movie.Path); err != nil && !os.IsNotExist(err) {
-				log.Printf("Failed to delete movie file: %v", err)
-			}
-		}
-		// Delete from database
-		if err := s.db.DeleteMovie(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := s.deleteMovie(r, movie); err != nil {
+			requestLog(r).Errorf("Failed to delete movie %d: %v", id, err)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -544,10 +654,11 @@ func (s *Server) handleMovie(w http.ResponseWriter, r *http.Request) {
 
 	// Default: GET movie
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	movie.Versions, _ = s.db.GetMediaVersions("movie", movie.ID)
 	json.NewEncoder(w).Encode(movie)
 }
 
@@ -557,7 +668,7 @@ func (s *Server) handleDetectIntros(w http.ResponseWriter, r *http.Request, show
 	// Get all seasons for this show
 	seasons, err := s.db.GetSeasonsByShow(show.ID)
 	if err != nil {
-		http.Error(w, "Failed to get seasons: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Failed to get seasons: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -575,7 +686,7 @@ func (s *Server) handleDetectIntros(w http.ResponseWriter, r *http.Request, show
 
 	// Check if FFmpeg has chromaprint support
 	if !scanner.CheckFFmpegChromaprint() {
-		http.Error(w, "FFmpeg chromaprint support not available. Please ensure FFmpeg is compiled with chromaprint.", http.StatusInternalServerError)
+		httpError(w, "FFmpeg chromaprint support not available. Please ensure FFmpeg is compiled with chromaprint.", http.StatusInternalServerError)
 		return
 	}
 
@@ -598,10 +709,10 @@ func (s *Server) handleDetectIntros(w http.ResponseWriter, r *http.Request, show
 		if err := detector.AnalyzeSeason(season.ID); err != nil {
 			result.Status = "failed"
 			result.Error = err.Error()
-			log.Printf("Intro detection failed for show %d season %d: %v", show.ID, season.SeasonNumber, err)
+			requestLog(r).Errorf("Intro detection failed for show %d season %d: %v", show.ID, season.SeasonNumber, err)
 		} else {
 			result.Status = "completed"
-			log.Printf("Intro detection completed for show %d season %d", show.ID, season.SeasonNumber)
+			requestLog(r).Infof("Intro detection completed for show %d season %d", show.ID, season.SeasonNumber)
 		}
 
 		results = append(results, result)
@@ -613,3 +724,26 @@ func (s *Server) handleDetectIntros(w http.ResponseWriter, r *http.Request, show
 		"results": results,
 	})
 }
+
+// deleteMovie moves a movie's file to the trash and removes the movie from
+// the library
+func (s *Server) deleteMovie(r *http.Request, movie *database.Movie) error {
+	item := &database.TrashItem{
+		MediaType:    "movie",
+		MediaID:      movie.ID,
+		Title:        movie.Title,
+		OriginalPath: movie.Path,
+	}
+	if user := s.getCurrentUser(r); user != nil {
+		item.DeletedBy = &user.ID
+	}
+	if err := s.trash.Delete(item, movie); err != nil {
+		return fmt.Errorf("couldn't move the file to the trash: %w", err)
+	}
+	if err := s.db.DeleteMovie(movie.ID); err != nil {
+		return err
+	}
+	s.recordDeletion(r, "movie", movie.TmdbID, movie.Title,
+		map[string]interface{}{"movieId": movie.ID, "path": movie.Path})
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/metadata"
+)
+
+// Metadata providers
+
+// handleMetadataProviders lists the registered metadata providers, whether
+// each is configured, and the default order libraries use
+func (s *Server) handleMetadataProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers":    s.metadata.Providers(),
+		"defaultOrder": metadata.DefaultProviders,
+	})
+}
+
+// handleLibraryProviders handles /api/libraries/{id}/providers: the order a
+// library asks metadata providers in. PUT an empty list to use the default.
+func (s *Server) handleLibraryProviders(w http.ResponseWriter, r *http.Request, libraryID int64) {
+	lib, err := s.db.GetLibrary(libraryID)
+	if err != nil {
+		httpError(w, "Library not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Providers []string `json:"providers"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if err := s.validateProviders(req.Providers); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.UpdateLibraryMetadataProviders(libraryID, req.Providers); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lib.MetadataProviders = req.Providers
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	order := lib.MetadataProviders
+	if len(order) == 0 {
+		order = metadata.DefaultProviders
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"libraryId": libraryID,
+		"providers": lib.MetadataProviders,
+		"order":     order,
+	})
+}
+
+// validateProviders checks a provider order names registered providers once
+func (s *Server) validateProviders(providers []string) error {
+	seen := make(map[string]bool, len(providers))
+	for _, name := range providers {
+		if !s.metadata.HasProvider(name) {
+			return fmt.Errorf("Unknown metadata provider: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("Metadata provider listed twice: %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Degraded mode without TMDB
+//
+// Until a TMDB API key is set, library endpoints serve what's in the database
+// and endpoints that only exist to show TMDB data answer with an explicit
+// metadata_unconfigured payload instead of failing, so the UI can prompt for
+// setup rather than break.
+
+// metadataUnconfigured is the error code sent while no TMDB API key is set
+const metadataUnconfigured = "metadata_unconfigured"
+
+// metadataConfigured reports whether TMDB lookups can be made
+func (s *Server) metadataConfigured() bool {
+	return s.metadata != nil && s.metadata.Configured()
+}
+
+// sendMetadataUnconfigured answers a TMDB-backed endpoint while no API key is
+// set. listKey, if given, is sent as an empty list so clients expecting one
+// (e.g. "results") render nothing instead of failing.
+func (s *Server) sendMetadataUnconfigured(w http.ResponseWriter, listKey string) {
+	payload := map[string]interface{}{
+		"error":   metadataUnconfigured,
+		"message": "TMDB API key not configured",
+	}
+	if listKey != "" {
+		payload[listKey] = []interface{}{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Instant mixes
+//
+// GET /api/music/mix builds a play queue from a seed track, album or artist
+// for a radio mode in music players, without an outside service. Tracks are
+// scored by the genres they share with the seed, how close their tempo is
+// (when tagged), whether they're by the seed's artist and how often the
+// listener plays them; tracks played in the last couple of hours are pushed
+// back. A little randomness keeps two mixes from the same seed apart, and
+// artists are spread out so the queue doesn't play one artist in a row.
+
+const (
+	defaultMixSize = 50
+	maxMixSize     = 200
+	// mixRecentWindow is how long a played track is held back
+	mixRecentWindow = 2 * time.Hour
+	// mixTempoRange is the BPM difference at which tempo stops counting
+	mixTempoRange = 30.0
+)
+
+// handleMusicMix handles GET /api/music/mix?seed=track|album|artist&id=&limit=
+func (s *Server) handleMusicMix(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	seedType := query.Get("seed")
+	if seedType != "track" && seedType != "album" && seedType != "artist" {
+		httpError(w, "seed must be track, album or artist", http.StatusBadRequest)
+		return
+	}
+	seedID, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	limit := defaultMixSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMixSize {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxMixSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	tracks, err := s.db.GetMixTracks()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var pool, seeds []database.MixTrack
+	for _, t := range tracks {
+		if !user.CanAccessLibrary(t.LibraryID) {
+			continue
+		}
+		pool = append(pool, t)
+		if (seedType == "track" && t.ID == seedID) ||
+			(seedType == "album" && t.AlbumID == seedID) ||
+			(seedType == "artist" && t.ArtistID == seedID) {
+			seeds = append(seeds, t)
+		}
+	}
+	if len(seeds) == 0 {
+		httpError(w, "Seed not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := s.db.GetTrackPlayStats(user.ID, s.getActiveProfileID(r))
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	title := seeds[0].Title
+	if seedType == "album" {
+		title = seeds[0].AlbumTitle
+	} else if seedType == "artist" {
+		title = seeds[0].ArtistName
+	}
+	queue := buildMix(seedType, seeds, pool, stats, limit, time.Now())
+	if queue == nil {
+		queue = []database.MixTrack{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"seed":   map[string]interface{}{"type": seedType, "id": seedID, "title": title},
+		"tracks": queue,
+	})
+}
+
+// handleTrackPlayed handles POST /api/tracks/{id}/played, recording a play
+// for mixes
+func (s *Server) handleTrackPlayed(w http.ResponseWriter, r *http.Request, trackID int64) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, err := s.db.GetTrack(trackID); err != nil {
+		httpError(w, "Track not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.RecordTrackPlay(user.ID, s.getActiveProfileID(r), trackID); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// buildMix orders the pool into a queue for the seed tracks. A seed track
+// leads its own mix; album and artist mixes start wherever the scores say.
+func buildMix(seedType string, seeds, pool []database.MixTrack, stats map[int64]database.TrackPlayStats, limit int, now time.Time) []database.MixTrack {
+	genres := make(map[string]bool)
+	artists := make(map[int64]bool)
+	var bpms []int
+	for _, t := range seeds {
+		for _, g := range splitGenres(t.Genre) {
+			genres[g] = true
+		}
+		artists[t.ArtistID] = true
+		if t.BPM != nil {
+			bpms = append(bpms, *t.BPM)
+		}
+	}
+	seedBPM := 0
+	if len(bpms) > 0 {
+		sort.Ints(bpms)
+		seedBPM = bpms[len(bpms)/2]
+	}
+
+	type candidate struct {
+		track database.MixTrack
+		score float64
+	}
+	var candidates []candidate
+	for _, t := range pool {
+		if seedType == "track" && t.ID == seeds[0].ID {
+			continue
+		}
+		score := 0.0
+		for _, g := range splitGenres(t.Genre) {
+			if genres[g] {
+				score += 3
+			}
+		}
+		if artists[t.ArtistID] {
+			score += 2
+		}
+		if seedBPM > 0 && t.BPM != nil {
+			if diff := math.Abs(float64(*t.BPM - seedBPM)); diff < mixTempoRange {
+				score += 2 * (1 - diff/mixTempoRange)
+			}
+		}
+		if st, ok := stats[t.ID]; ok {
+			score += 0.3 * math.Min(float64(st.Plays), 5)
+			if now.Sub(st.LastPlayed) < mixRecentWindow {
+				score -= 4
+			}
+		}
+		score += rand.Float64()
+		candidates = append(candidates, candidate{track: t, score: score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var queue []database.MixTrack
+	if seedType == "track" {
+		queue = append(queue, seeds[0])
+	}
+
+	// Each artist gets at most a third of the mix, or half for the seed
+	// artist of an artist mix, and never two tracks in a row while there's
+	// someone else to play
+	perArtist := make(map[int64]int)
+	if seedType == "track" {
+		perArtist[seeds[0].ArtistID]++
+	}
+	allowed := func(artistID int64) bool {
+		limitFor := max(limit/3, 1)
+		if seedType == "artist" && artists[artistID] {
+			limitFor = max(limit/2, 1)
+		}
+		return perArtist[artistID] < limitFor
+	}
+	for len(queue) < limit && len(candidates) > 0 {
+		pick := -1
+		for i, c := range candidates {
+			if !allowed(c.track.ArtistID) {
+				continue
+			}
+			if len(queue) > 0 && queue[len(queue)-1].ArtistID == c.track.ArtistID {
+				continue
+			}
+			pick = i
+			break
+		}
+		if pick < 0 {
+			for i, c := range candidates {
+				if allowed(c.track.ArtistID) {
+					pick = i
+					break
+				}
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		t := candidates[pick].track
+		queue = append(queue, t)
+		perArtist[t.ArtistID]++
+		candidates = append(candidates[:pick], candidates[pick+1:]...)
+	}
+	return queue
+}
+
+// splitGenres splits a genre tag such as "Rock; Indie/Alternative" into
+// lowercase genres
+func splitGenres(genre *string) []string {
+	if genre == nil {
+		return nil
+	}
+	var genres []string
+	for _, g := range strings.FieldsFunc(*genre, func(r rune) bool { return r == ';' || r == ',' || r == '/' }) {
+		if g = strings.ToLower(strings.TrimSpace(g)); g != "" {
+			genres = append(genres, g)
+		}
+	}
+	return genres
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	importpkg "github.com/outpost/outpost/internal/import"
+	"github.com/outpost/outpost/internal/parser"
+)
+
+// Naming template previews
+//
+// Before a naming template is saved, it can be rendered against a few real
+// movies and episodes from the library - regular, multi-episode, specials and
+// anime with absolute numbering - so mistakes show up before an import names
+// files with it. Kinds the library has nothing of are previewed with made-up
+// examples.
+
+// namingSamplesPerKind is how many library items of each kind are previewed
+const namingSamplesPerKind = 3
+
+// namingSampleKinds are the kinds previewed for each template type
+var namingSampleKinds = map[string][]string{
+	"movie": {database.NamingSampleMovie},
+	"tv": {
+		database.NamingSampleEpisode,
+		database.NamingSampleMultiEpisode,
+		database.NamingSampleSpecial,
+		database.NamingSampleAbsolute,
+	},
+	"daily": {database.NamingSampleDaily},
+}
+
+// exampleNamingSamples stand in for kinds the library has nothing of
+var exampleNamingSamples = map[string]database.NamingSample{
+	database.NamingSampleMovie: {
+		Title: "The Matrix", Year: 1999,
+		Path: "The.Matrix.1999.1080p.BluRay.x264.mkv",
+	},
+	database.NamingSampleEpisode: {
+		Title: "Breaking Bad", Year: 2008, Season: 1, Episode: 2,
+		EpisodeTitle: "Cat's in the Bag...",
+		Path:         "Breaking.Bad.S01E02.1080p.BluRay.x264.mkv",
+	},
+	database.NamingSampleMultiEpisode: {
+		Title: "Doctor Who", Year: 2005, Season: 1, Episode: 4, EpisodeEnd: 5,
+		EpisodeTitle: "Aliens of London",
+		Path:         "Doctor.Who.2005.S01E04E05.720p.WEB-DL.x264.mkv",
+	},
+	database.NamingSampleSpecial: {
+		Title: "Sherlock", Year: 2010, Season: 0, Episode: 1,
+		EpisodeTitle: "The Abominable Bride",
+		Path:         "Sherlock.S00E01.1080p.WEB-DL.x264.mkv",
+	},
+	database.NamingSampleAbsolute: {
+		Title: "One Piece", Year: 1999, Season: 2, Episode: 17, Absolute: 78,
+		EpisodeTitle: "Luffy's Peril",
+		Path:         "One.Piece.078.1080p.WEB-DL.x265.mkv",
+	},
+	database.NamingSampleDaily: {
+		Title: "The Daily Show", Year: 1996, Season: 29, Episode: 12,
+		AirDate: "2024-01-15", EpisodeTitle: "Guest Host",
+		Path: "The.Daily.Show.2024.01.15.720p.WEB.h264.mkv",
+	},
+}
+
+type namingPreviewRequest struct {
+	Type           string `json:"type"`
+	FolderTemplate string `json:"folderTemplate"`
+	FileTemplate   string `json:"fileTemplate"`
+}
+
+type namingPreviewExample struct {
+	database.NamingSample
+	Example bool   `json:"example"` // Made up, as the library has nothing of this kind
+	Preview string `json:"preview"`
+}
+
+type namingPreviewResponse struct {
+	Type           string                 `json:"type"`
+	FolderTemplate string                 `json:"folderTemplate"`
+	FileTemplate   string                 `json:"fileTemplate"`
+	Examples       []namingPreviewExample `json:"examples"`
+	Warnings       []string               `json:"warnings"`
+}
+
+// handleNamingPreview handles POST /api/settings/naming/preview. Templates
+// left out of the request are taken from the saved ones.
+func (s *Server) handleNamingPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req namingPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	kinds, ok := namingSampleKinds[req.Type]
+	if !ok {
+		httpError(w, "Type must be movie, tv or daily", http.StatusBadRequest)
+		return
+	}
+	if req.FolderTemplate == "" || req.FileTemplate == "" {
+		saved, err := s.db.GetNamingTemplate(req.Type)
+		if err != nil {
+			httpError(w, "No saved template for "+req.Type, http.StatusBadRequest)
+			return
+		}
+		if req.FolderTemplate == "" {
+			req.FolderTemplate = saved.FolderTemplate
+		}
+		if req.FileTemplate == "" {
+			req.FileTemplate = saved.FileTemplate
+		}
+	}
+
+	var samples []namingPreviewExample
+	for _, kind := range kinds {
+		var found []database.NamingSample
+		var err error
+		if kind == database.NamingSampleMovie {
+			found, err = s.db.GetMovieNamingSamples(namingSamplesPerKind)
+		} else {
+			found, err = s.db.GetEpisodeNamingSamples(kind, namingSamplesPerKind)
+		}
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, sample := range found {
+			samples = append(samples, namingPreviewExample{NamingSample: sample})
+		}
+		if len(found) == 0 {
+			sample := exampleNamingSamples[kind]
+			sample.Kind = kind
+			sample.MediaType = "episode"
+			if kind == database.NamingSampleMovie {
+				sample.MediaType = "movie"
+			}
+			samples = append(samples, namingPreviewExample{NamingSample: sample, Example: true})
+		}
+	}
+
+	resp := namingPreviewResponse{
+		Type:           req.Type,
+		FolderTemplate: req.FolderTemplate,
+		FileTemplate:   req.FileTemplate,
+		Warnings:       namingTemplateWarnings(req.Type, req.FolderTemplate, req.FileTemplate),
+	}
+	warned := make(map[string]bool)
+	warn := func(format string, args ...any) {
+		if msg := fmt.Sprintf(format, args...); !warned[msg] {
+			warned[msg] = true
+			resp.Warnings = append(resp.Warnings, msg)
+		}
+	}
+	previews := make(map[string]string)
+	for _, sample := range samples {
+		preview, unknown := renderNamingPreview(req.FolderTemplate, req.FileTemplate, sample.NamingSample)
+		sample.Preview = preview
+		resp.Examples = append(resp.Examples, sample)
+
+		for _, p := range unknown {
+			warn("Unknown placeholder %s", p)
+		}
+		name := namingSampleName(sample.NamingSample)
+		for _, segment := range strings.Split(preview, "/") {
+			// Names are tidied of leading dots, so one left is an extension
+			// with no name before it
+			if segment == "" || strings.HasPrefix(segment, ".") {
+				warn("%s gets an empty folder or file name", name)
+				break
+			}
+		}
+		if other, ok := previews[preview]; ok {
+			warn("%s and %s get the same name", other, name)
+		} else {
+			previews[preview] = name
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// renderNamingPreview renders where a sample would be imported to, relative
+// to its library. Quality placeholders are filled from its current file name.
+func renderNamingPreview(folderTemplate, fileTemplate string, sample database.NamingSample) (string, []string) {
+	file := filepath.Base(sample.Path)
+	ext := filepath.Ext(file)
+	if ext == "" {
+		ext = ".mkv"
+	}
+	parsed := parser.Parse(strings.TrimSuffix(file, filepath.Ext(file)))
+
+	values := importpkg.NamingValues{
+		Title:        sample.Title,
+		Year:         sample.Year,
+		Season:       sample.Season,
+		Episode:      sample.Episode,
+		EpisodeEnd:   sample.EpisodeEnd,
+		Absolute:     sample.Absolute,
+		EpisodeTitle: sample.EpisodeTitle,
+		AirDate:      sample.AirDate,
+		Resolution:   parsed.Resolution,
+		Source:       parsed.Source,
+		Codec:        parsed.Codec,
+	}
+	folder, unknown := importpkg.RenderNaming(folderTemplate, values)
+	name, unknownInFile := importpkg.RenderNaming(fileTemplate, values)
+	return folder + "/" + name + ext, append(unknown, unknownInFile...)
+}
+
+// namingTemplateWarnings checks that a template keeps apart the files it'll
+// be used for
+func namingTemplateWarnings(templateType, folderTemplate, fileTemplate string) []string {
+	warnings := []string{}
+	has := func(template, placeholder string) bool {
+		return strings.Contains(template, "{"+placeholder+"}") || strings.Contains(template, "{"+placeholder+":")
+	}
+	both := folderTemplate + "/" + fileTemplate
+
+	switch templateType {
+	case "movie":
+		if !has(both, "Title") {
+			warnings = append(warnings, "The template doesn't include {Title}, so movies would overwrite each other")
+		}
+	case "tv":
+		if !has(fileTemplate, "Episode") && !has(fileTemplate, "Absolute") {
+			warnings = append(warnings, "The file name doesn't include {Episode:00}, so episodes would overwrite each other")
+		}
+		if !has(both, "Season") && !has(fileTemplate, "Absolute") {
+			warnings = append(warnings, "The template doesn't include {Season:00}, so episodes of different seasons would overwrite each other")
+		}
+	case "daily":
+		if !has(fileTemplate, "Air-Date") && !has(fileTemplate, "Episode") {
+			warnings = append(warnings, "The file name doesn't include {Air-Date}, so episodes would overwrite each other")
+		}
+	}
+	return warnings
+}
+
+// namingSampleName describes a sample in warnings
+func namingSampleName(sample database.NamingSample) string {
+	if sample.MediaType == "movie" {
+		return sample.Title
+	}
+	if sample.Kind == database.NamingSampleDaily && sample.AirDate != "" {
+		return fmt.Sprintf("%s %s", sample.Title, sample.AirDate)
+	}
+	name := fmt.Sprintf("%s S%02dE%02d", sample.Title, sample.Season, sample.Episode)
+	if sample.EpisodeEnd > sample.Episode {
+		name += fmt.Sprintf("-E%02d", sample.EpisodeEnd)
+	}
+	return name
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Network access rules
+
+// allowUserNetwork refuses LAN-only users outside the local network, and
+// admin routes outside the admin subnets. It writes the error and returns
+// false when the request is refused.
+func allowUserNetwork(w http.ResponseWriter, r *http.Request, user *database.User, adminRoute bool) bool {
+	ip := netaccess.ClientIP(r)
+	if user.LanOnly && !netaccess.IsLocal(ip) {
+		requestLog(r).Infof("Access denied: %s is LAN only (from %s)", user.Username, ip)
+		httpError(w, i18n.T(requestLanguage(r, user), "This account can only be used from the local network"), http.StatusForbidden)
+		return false
+	}
+	if adminRoute && !netaccess.AdminAllowed(ip) {
+		requestLog(r).Infof("Access denied: admin route %s from %s outside the admin subnets", r.URL.Path, ip)
+		httpError(w, i18n.T(requestLanguage(r, user), "Admin access is not allowed from this network"), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// allowCountry refuses sign-ins and streams from denied countries. It writes
+// the error and returns false when the request is refused.
+func allowCountry(w http.ResponseWriter, r *http.Request) bool {
+	ip := netaccess.ClientIP(r)
+	if country, denied := netaccess.CountryDenied(ip); denied {
+		requestLog(r).Infof("Access denied: %s %s from %s (%s)", r.Method, r.URL.Path, ip, country)
+		localizedError(w, r, http.StatusForbidden, "Access is not allowed from your location")
+		return false
+	}
+	return true
+}
+
+// handleNetworkAccess handles GET /api/settings/network-access, showing the
+// rules in effect and how they apply to the caller, so admins can check a
+// subnet list before it locks them out
+func (s *Server) handleNetworkAccess(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := netaccess.Current()
+	subnets := make([]string, 0, len(cfg.AdminSubnets))
+	for _, prefix := range cfg.AdminSubnets {
+		subnets = append(subnets, prefix.String())
+	}
+	proxies := make([]string, 0, len(cfg.TrustedProxies))
+	for _, prefix := range cfg.TrustedProxies {
+		proxies = append(proxies, prefix.String())
+	}
+	countries := make([]string, 0, len(cfg.DeniedCountries))
+	for code := range cfg.DeniedCountries {
+		countries = append(countries, code)
+	}
+	sort.Strings(countries)
+
+	ip := netaccess.ClientIP(r)
+	_, denied := netaccess.CountryDenied(ip)
+	var lanOnly []string
+	if users, err := s.db.GetUsers(); err == nil {
+		for _, u := range users {
+			if u.LanOnly {
+				lanOnly = append(lanOnly, u.Username)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"adminSubnets":    subnets,
+		"deniedCountries": countries,
+		"trustedProxies":  proxies,
+		"geoipLoaded":     cfg.GeoIPLoaded(),
+		"lanOnlyUsers":    lanOnly,
+		"client": map[string]interface{}{
+			"ip":            ip,
+			"local":         netaccess.IsLocal(ip),
+			"country":       netaccess.Country(ip),
+			"adminAllowed":  netaccess.AdminAllowed(ip),
+			"countryDenied": denied,
+		},
+	})
+}
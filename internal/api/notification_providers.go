@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/notification"
+)
+
+// Notification providers
+//
+// Admins set up Discord, Telegram, Pushover, webhook and email providers, and
+// choose which events each one sends. A provider can be tested before it's
+// relied on.
+
+// notificationProviderRequest is the body of a create or update
+type notificationProviderRequest struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Config  map[string]string `json:"config"`
+	Events  []string          `json:"events"`
+	Enabled *bool             `json:"enabled"`
+}
+
+// apply copies the request onto a provider, keeping what it leaves out
+func (req *notificationProviderRequest) apply(p *database.NotificationProvider) {
+	if req.Name != "" {
+		p.Name = strings.TrimSpace(req.Name)
+	}
+	if req.Type != "" {
+		p.Type = req.Type
+	}
+	if req.Config != nil {
+		p.Config = req.Config
+	}
+	if req.Events != nil {
+		p.Events = req.Events
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+}
+
+// handleNotificationProviders handles GET and POST /api/notification-providers
+func (s *Server) handleNotificationProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		providers, err := s.db.GetNotificationProviders()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"providers": providers,
+			"types":     notification.ProviderTypes,
+			"events":    notification.ProviderEvents,
+		})
+
+	case http.MethodPost:
+		var req notificationProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		provider := &database.NotificationProvider{Enabled: true, Config: map[string]string{}, Events: []string{}}
+		req.apply(provider)
+		if err := notification.ValidateProvider(provider); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.CreateNotificationProvider(provider); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "notification_provider.create", "notification_provider", &provider.ID, provider.Type+" "+provider.Name)
+
+		created, err := s.db.GetNotificationProvider(provider.ID)
+		if err != nil || created == nil {
+			httpError(w, "Failed to load provider", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNotificationProvider handles /api/notification-providers/{id}:
+//
+//	GET    /api/notification-providers/{id}         the provider
+//	PUT    /api/notification-providers/{id}         update it
+//	DELETE /api/notification-providers/{id}         remove it
+//	POST   /api/notification-providers/{id}/test    send a test notification
+func (s *Server) handleNotificationProvider(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/notification-providers/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	provider, err := s.db.GetNotificationProvider(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if provider == nil {
+		httpError(w, "Provider not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(parts) == 2 && parts[1] == "test" {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.testNotificationProvider(w, provider)
+		return
+	}
+	if len(parts) != 1 {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(provider)
+
+	case http.MethodPut:
+		var req notificationProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		req.apply(provider)
+		if err := notification.ValidateProvider(provider); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.db.UpdateNotificationProvider(provider); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "notification_provider.update", "notification_provider", &provider.ID, provider.Type+" "+provider.Name)
+
+		updated, err := s.db.GetNotificationProvider(id)
+		if err != nil || updated == nil {
+			httpError(w, "Failed to load provider", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		if err := s.db.DeleteNotificationProvider(id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "notification_provider.delete", "notification_provider", &id, provider.Type+" "+provider.Name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// testNotificationProvider sends a test notification and reports whether it
+// went through. A disabled provider can be tested too.
+func (s *Server) testNotificationProvider(w http.ResponseWriter, provider *database.NotificationProvider) {
+	if s.notifications == nil {
+		httpError(w, "Notifications are not available", http.StatusServiceUnavailable)
+		return
+	}
+	result := map[string]interface{}{"success": true}
+	if err := s.notifications.TestProvider(provider); err != nil {
+		result = map[string]interface{}{"success": false, "error": err.Error()}
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Four-eyes mode
+//
+// With four_eyes_enabled on, clearing the library, deleting movies and shows
+// in bulk, deleting denied requests in bulk and restoring a backup don't run
+// when an admin asks: they become pending operations that a second admin
+// confirms at /api/operations within four_eyes_window_minutes. The admin who
+// asked can cancel but not confirm. Turning the mode off or shortening its
+// window is held the same way, so one admin can't switch it off and then act
+// alone.
+
+// Destructive actions that need a second admin in four-eyes mode
+const (
+	opLibraryClear        = "library.clear"
+	opLibraryBulkDelete   = "library.bulk_delete"
+	opClearDeniedRequests = "requests.clear_denied"
+	opBackupRestore       = "backup.restore"
+	opFourEyesSettings    = "settings.four_eyes"
+)
+
+// defaultFourEyesWindow is how long an operation waits for a decision when
+// the window setting is unset
+const defaultFourEyesWindow = 30 * time.Minute
+
+// maxFourEyesWindow bounds four_eyes_window_minutes at a week
+const maxFourEyesWindow = 7 * 24 * 60
+
+// validateFourEyesSetting checks the four_eyes_* settings. Other settings are
+// always valid.
+func validateFourEyesSetting(key, value string) error {
+	switch key {
+	case "four_eyes_enabled":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("four_eyes_enabled must be true or false")
+		}
+	case "four_eyes_window_minutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 1 || minutes > maxFourEyesWindow {
+			return fmt.Errorf("four_eyes_window_minutes must be between 1 and %d", maxFourEyesWindow)
+		}
+	}
+	return nil
+}
+
+// fourEyesWindow returns how long operations wait for a second admin, or 0
+// if four-eyes mode is off
+func (s *Server) fourEyesWindow() time.Duration {
+	settings, err := s.db.GetAllSettings()
+	if err != nil || settings["four_eyes_enabled"] != "true" {
+		return 0
+	}
+	if minutes, err := strconv.Atoi(settings["four_eyes_window_minutes"]); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultFourEyesWindow
+}
+
+// weakensFourEyes reports whether saving settings would turn four-eyes mode
+// off or shorten its window while it's on
+func (s *Server) weakensFourEyes(data map[string]string) bool {
+	window := s.fourEyesWindow()
+	if window == 0 {
+		return false
+	}
+	if v, ok := data["four_eyes_enabled"]; ok && v != "true" {
+		return true
+	}
+	if v, ok := data["four_eyes_window_minutes"]; ok {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || time.Duration(minutes)*time.Minute < window {
+			return true
+		}
+	}
+	return false
+}
+
+// holdFourEyesSettings holds settings that weaken four-eyes mode for a
+// second admin. They must be saved on their own, as only they are held.
+// Returns true if the response was written.
+func (s *Server) holdFourEyesSettings(w http.ResponseWriter, r *http.Request, data map[string]string) bool {
+	if !s.weakensFourEyes(data) {
+		return false
+	}
+	params := make(map[string]string, len(data))
+	for key, value := range data {
+		if !strings.HasPrefix(key, "four_eyes_") {
+			writeInvalidSetting(w, key, "Save four-eyes settings on their own while four-eyes mode is on")
+			return true
+		}
+		params[key] = value
+	}
+	summary := "Shorten the four-eyes window"
+	if v, ok := data["four_eyes_enabled"]; ok && v != "true" {
+		summary = "Turn off four-eyes mode"
+	}
+	return s.holdForApproval(w, r, opFourEyesSettings, summary, params, nil)
+}
+
+// holdForApproval stores a destructive action as a pending operation when
+// four-eyes mode is on, answering 202 with it. Returns false if the action
+// should run now.
+func (s *Server) holdForApproval(w http.ResponseWriter, r *http.Request, action, summary string, params map[string]string, payload []byte) bool {
+	window := s.fourEyesWindow()
+	if window == 0 {
+		return false
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return true
+	}
+
+	// Without a second admin nobody could ever confirm it
+	users, err := s.db.GetUsers()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	otherAdmins := 0
+	for _, u := range users {
+		if u.Role == "admin" && u.ID != user.ID && !u.Disabled {
+			otherAdmins++
+		}
+	}
+	if otherAdmins == 0 {
+		httpError(w, "Four-eyes mode needs a second admin to confirm this", http.StatusConflict)
+		return true
+	}
+
+	if params == nil {
+		params = map[string]string{}
+	}
+	op := &database.PendingOperation{
+		Action:      action,
+		Summary:     summary,
+		Params:      params,
+		Payload:     payload,
+		RequestedBy: user.ID,
+	}
+	if err := s.db.CreatePendingOperation(op, window); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	s.recordAudit(r, "operation.request", "operation", &op.ID, action)
+
+	created, err := s.db.GetPendingOperation(op.ID)
+	if err != nil || created == nil {
+		httpError(w, "Failed to load operation", http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pending":   true,
+		"operation": created,
+	})
+	return true
+}
+
+// runOperation runs a confirmed destructive action, returning what its
+// endpoint would have answered. r is the confirming admin's request.
+func (s *Server) runOperation(r *http.Request, op *database.PendingOperation) (interface{}, error) {
+	switch op.Action {
+	case opLibraryClear:
+		return s.clearLibrary()
+	case opLibraryBulkDelete:
+		var req bulkRequest
+		if err := json.Unmarshal(op.Payload, &req); err != nil {
+			return nil, err
+		}
+		if err := s.validateBulkRequest(&req); err != nil {
+			return nil, err
+		}
+		job, err := s.startBulkJob(r, &req)
+		if err != nil {
+			return nil, err
+		}
+		return newJobView(job), nil
+	case opClearDeniedRequests:
+		return s.clearDeniedRequests()
+	case opBackupRestore:
+		return s.restoreBackup(op.Payload, op.Params["mode"])
+	case opFourEyesSettings:
+		for key, value := range op.Params {
+			if err := s.db.SetSetting(key, value); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]string{"status": "saved"}, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Action)
+}
+
+// handleOperations handles GET /api/operations: operations waiting for a
+// second admin, or with ?all=true recent ones whatever their status
+func (s *Server) handleOperations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.db.ExpirePendingOperations(); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+	}
+	ops, err := s.db.GetPendingOperations(r.URL.Query().Get("all") == "true", limit)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":    s.fourEyesWindow() > 0,
+		"operations": ops,
+	})
+}
+
+// handleOperation handles /api/operations/{id}:
+//
+//	GET  /api/operations/{id}          the operation
+//	POST /api/operations/{id}/confirm  run it, as a second admin
+//	POST /api/operations/{id}/reject   turn it down, or cancel it as the admin who asked
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/operations/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if err := s.db.ExpirePendingOperations(); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	op, err := s.db.GetPendingOperation(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if op == nil {
+		httpError(w, "Operation not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(op)
+		return
+	}
+	if len(parts) != 2 || (parts[1] != "confirm" && parts[1] != "reject") {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if parts[1] == "reject" {
+		status, action := database.OperationRejected, "operation.reject"
+		if user.ID == op.RequestedBy {
+			status, action = database.OperationCancelled, "operation.cancel"
+		}
+		if !s.decideOperation(w, op, status, user.ID) {
+			return
+		}
+		s.recordAudit(r, action, "operation", &op.ID, op.Action)
+		s.writeOperation(w, op.ID)
+		return
+	}
+
+	if user.ID == op.RequestedBy {
+		httpError(w, "A second admin must confirm this operation", http.StatusForbidden)
+		return
+	}
+	if !s.decideOperation(w, op, database.OperationConfirmed, user.ID) {
+		return
+	}
+	s.recordAudit(r, "operation.confirm", "operation", &op.ID, op.Action)
+
+	var resultJSON []byte
+	var errMsg *string
+	result, runErr := s.runOperation(r, op)
+	if runErr != nil {
+		msg := runErr.Error()
+		errMsg = &msg
+		requestLog(r).Errorf("Confirmed operation %d (%s) failed: %v", op.ID, op.Action, runErr)
+	} else if resultJSON, err = json.Marshal(result); err != nil {
+		resultJSON = nil
+	}
+	if err := s.db.FinishPendingOperation(op.ID, resultJSON, errMsg); err != nil {
+		requestLog(r).Errorf("Failed to record result of operation %d: %v", op.ID, err)
+	}
+	s.writeOperation(w, op.ID)
+}
+
+// decideOperation claims a pending operation for a decision, answering with
+// a conflict if it's already been decided or has expired
+func (s *Server) decideOperation(w http.ResponseWriter, op *database.PendingOperation, status string, userID int64) bool {
+	ok, err := s.db.DecidePendingOperation(op.ID, status, userID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		// Still pending here means its window ran out since it was loaded
+		current := op.Status
+		if current == database.OperationPending {
+			current = database.OperationExpired
+		}
+		httpError(w, "Operation is "+current, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// writeOperation writes an operation as it now stands
+func (s *Server) writeOperation(w http.ResponseWriter, id int64) {
+	op, err := s.db.GetPendingOperation(id)
+	if err != nil || op == nil {
+		httpError(w, "Failed to load operation", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(op)
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Password reset handlers
+
+// normalizeEmail trims and validates an email address. An empty address
+// returns nil; ok is false if the address is malformed.
+func normalizeEmail(value string) (*string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, true
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return nil, false
+	}
+	return &value, true
+}
+
+// Forgot-password limits. Requests past the address limit are refused;
+// requests past the account limit get the usual answer but send nothing, so
+// the limit doesn't tell whether the account exists.
+const (
+	forgotPasswordPerAddress = 5
+	forgotPasswordPerAccount = 3
+)
+
+var (
+	forgotPasswordAddressLimiter = newRateLimiter(time.Hour)
+	forgotPasswordAccountLimiter = newRateLimiter(time.Hour)
+)
+
+// resetLinkURL builds the frontend link for a reset token on a base URL
+func resetLinkURL(base, token string) string {
+	return base + "/reset-password?token=" + url.QueryEscape(token)
+}
+
+// emailResetsEnabled reports whether reset links can be emailed: that needs
+// a mail server and the public_url setting, as emailed links are never built
+// from the host a request names
+func (s *Server) emailResetsEnabled() bool {
+	return s.mailer.Enabled() && s.configuredBaseURL() != ""
+}
+
+// sendResetEmail emails a reset link to a user
+func (s *Server) sendResetEmail(user *database.User, link string, expiresAt time.Time) error {
+	body := fmt.Sprintf(
+		"Hi %s,\n\nA password reset was requested for your Outpost account.\n\n"+
+			"Open this link to choose a new password:\n%s\n\n"+
+			"The link expires at %s and can only be used once. "+
+			"If you didn't request this, you can ignore this email.\n",
+		user.Username, link, expiresAt.Format("Jan 2, 2006 3:04 PM MST"),
+	)
+	return s.mailer.Send(*user.Email, "Reset your Outpost password", body)
+}
+
+// handleUserPasswordReset handles POST /api/users/{id}/password-reset
+// Generates a one-time reset link for the user, optionally emailing it to them
+func (s *Server) handleUserPasswordReset(w http.ResponseWriter, r *http.Request, userID int64) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SendEmail bool `json:"sendEmail"`
+	}
+	// Body is optional
+	json.NewDecoder(r.Body).Decode(&req)
+
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		httpError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if req.SendEmail {
+		if user.Email == nil || *user.Email == "" {
+			httpError(w, "User has no email address", http.StatusBadRequest)
+			return
+		}
+		if !s.mailer.Enabled() {
+			writeError(w, http.StatusBadRequest, codeNotConfigured, "Email is not configured")
+			return
+		}
+		if s.configuredBaseURL() == "" {
+			writeError(w, http.StatusBadRequest, codeNotConfigured, "Set the public URL before emailing reset links")
+			return
+		}
+	}
+
+	var createdBy *int64
+	if admin := s.getCurrentUser(r); admin != nil {
+		createdBy = &admin.ID
+	}
+
+	token, reset, err := s.auth.CreatePasswordReset(user.ID, createdBy, auth.AdminResetLinkDuration)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// A link shown to the admin can use the host they're on; one that's
+	// emailed only ever uses the public URL
+	base := s.configuredBaseURL()
+	if !req.SendEmail {
+		base = s.publicBaseURL(r)
+	}
+	link := resetLinkURL(base, token)
+
+	emailed := false
+	if req.SendEmail {
+		if err := s.sendResetEmail(user, link, reset.ExpiresAt); err != nil {
+			httpError(w, "Failed to send email: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		emailed = true
+	}
+
+	details := "Reset link generated for " + user.Username
+	if emailed {
+		details += " (emailed)"
+	}
+	s.recordAudit(r, "password_reset.link", "user", &user.ID, details)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":     token,
+		"url":       link,
+		"expiresAt": reset.ExpiresAt,
+		"emailed":   emailed,
+	})
+}
+
+// handleForgotPassword handles /api/auth/forgot-password
+// GET reports whether self-service email resets are available.
+// POST emails a reset link when a matching account with an email address
+// exists. Both need email and the public_url setting configured.
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]bool{"emailEnabled": s.emailResetsEnabled()})
+
+	case http.MethodPost:
+		if !s.emailResetsEnabled() {
+			writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "Email password resets are not set up. Ask an administrator for a reset link.")
+			return
+		}
+		ip := netaccess.ClientIP(r)
+		if !forgotPasswordAddressLimiter.allow(ip, forgotPasswordPerAddress, time.Now()) {
+			requestLog(r).Infof("Password reset requests from %s rate limited", ip)
+			writeError(w, http.StatusTooManyRequests, codeRateLimited, "Too many reset requests, try again later")
+			return
+		}
+
+		var req struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.Username == "" && req.Email == "" {
+			httpError(w, "Username or email required", http.StatusBadRequest)
+			return
+		}
+
+		var user *database.User
+		var err error
+		if req.Email != "" {
+			user, err = s.db.GetUserByEmail(strings.TrimSpace(req.Email))
+		} else {
+			user, err = s.db.GetUserByUsername(req.Username)
+		}
+
+		// Respond the same way whether or not the account exists
+		hasEmail := err == nil && user.Email != nil && *user.Email != ""
+		if hasEmail && !forgotPasswordAccountLimiter.allow(strconv.FormatInt(user.ID, 10), forgotPasswordPerAccount, time.Now()) {
+			requestLog(r).Infof("Password reset requests for user %d rate limited", user.ID)
+		} else if hasEmail {
+			token, reset, err := s.auth.CreatePasswordReset(user.ID, nil, auth.EmailResetLinkDuration)
+			if err == nil {
+				err = s.sendResetEmail(user, resetLinkURL(s.configuredBaseURL(), token), reset.ExpiresAt)
+			}
+			if err != nil {
+				requestLog(r).Errorf("Failed to send password reset email to user %d: %v", user.ID, err)
+			} else {
+				r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+				s.recordAudit(r, "password_reset.request", "user", &user.ID, "Reset link emailed")
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "If the account exists and has an email address, a reset link has been sent",
+		})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleResetPassword handles /api/auth/reset-password
+// GET ?token= validates a link, POST {token, password} sets the new password
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		reset, user, err := s.auth.ValidatePasswordReset(r.URL.Query().Get("token"))
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"username":  user.Username,
+			"expiresAt": reset.ExpiresAt,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.Password == "" {
+			httpError(w, "Password required", http.StatusBadRequest)
+			return
+		}
+
+		user, err := s.auth.ResetPassword(req.Token, req.Password)
+		if err == auth.ErrInvalidResetToken {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+		s.recordAudit(r, "password_reset.complete", "user", &user.ID, "Password changed, all sessions signed out")
+
+		json.NewEncoder(w).Encode(map[string]string{"status": "password reset"})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/photo"
+	"github.com/outpost/outpost/internal/scanner"
+)
+
+// Photos
+//
+// Photo libraries hold pictures, found by the scanner with their EXIF
+// details and thumbnails in each size. They're browsed by date or by the
+// folders they're in:
+//
+//	GET /api/photos                        photos, newest first; ?album=, ?from=, ?to=, ?limit=, ?offset=
+//	GET /api/photos/timeline               photos per day, or per month with ?group=month
+//	GET /api/photos/albums                 folders with photos in them
+//	GET /api/photos/{id}                   a photo
+//	GET /api/photos/{id}/original          the photo's file
+//	GET /api/photos/{id}/thumbnail/{size}  a thumbnail, large, medium or small
+//
+// Every list takes ?libraryId= to keep to one library. Albums are opened by
+// listing photos with their libraryId and album. Photos come with the URLs
+// of their thumbnails, which like the photos need access to the library.
+
+const (
+	defaultPhotoLimit = 100
+	maxPhotoLimit     = 500
+)
+
+// photoItem is a photo with the URLs of its thumbnails
+type photoItem struct {
+	database.Photo
+	Thumbnails map[string]string `json:"thumbnails,omitempty"` // Size name to URL
+}
+
+// photoGroupItem is a day, month or album with its cover's thumbnails
+type photoGroupItem struct {
+	database.PhotoGroup
+	Cover photoItem `json:"cover"`
+}
+
+// newPhotoItem returns a photo with its thumbnails
+func newPhotoItem(p database.Photo) photoItem {
+	item := photoItem{Photo: p}
+	if p.ThumbnailPath != nil {
+		item.Thumbnails = make(map[string]string, len(photo.Sizes))
+		for _, size := range photo.Sizes {
+			item.Thumbnails[size.Name] = fmt.Sprintf("/api/photos/%d/thumbnail/%s", p.ID, size.Name)
+		}
+	}
+	return item
+}
+
+// newPhotoGroupItems returns groups with their covers' thumbnails
+func newPhotoGroupItems(groups []database.PhotoGroup) []photoGroupItem {
+	items := make([]photoGroupItem, 0, len(groups))
+	for _, g := range groups {
+		items = append(items, photoGroupItem{PhotoGroup: g, Cover: newPhotoItem(g.Cover)})
+	}
+	return items
+}
+
+// photoFilter reads the filter of a photo list: the photo libraries the user
+// can see, or the one asked for, and the album and dates asked for. It
+// writes an error and returns false if the request is invalid.
+func (s *Server) photoFilter(w http.ResponseWriter, r *http.Request, user *database.User) (database.PhotoFilter, bool) {
+	var f database.PhotoFilter
+	query := r.URL.Query()
+
+	var libraryID int64
+	if v := query.Get("libraryId"); v != "" {
+		var err error
+		if libraryID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid library ID")
+			return f, false
+		}
+	}
+	libraries, err := s.db.GetLibraries()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return f, false
+	}
+	for _, lib := range libraries {
+		if lib.Type != "photos" || !user.CanAccessLibrary(lib.ID) || (libraryID != 0 && lib.ID != libraryID) {
+			continue
+		}
+		f.LibraryIDs = append(f.LibraryIDs, lib.ID)
+	}
+
+	if _, ok := query["album"]; ok {
+		album := strings.Trim(query.Get("album"), "/")
+		f.Album = &album
+	}
+	f.From, f.To = query.Get("from"), query.Get("to")
+	if (f.From != "" && !validDay(f.From)) || (f.To != "" && !validDay(f.To)) {
+		httpError(w, "from and to must be dates (YYYY-MM-DD)", http.StatusBadRequest)
+		return f, false
+	}
+	return f, true
+}
+
+// handlePhotos handles GET /api/photos
+func (s *Server) handlePhotos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f, ok := s.photoFilter(w, r, user)
+	if !ok {
+		return
+	}
+
+	f.Limit = defaultPhotoLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, maxPhotoLimit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		f.Offset = n
+	}
+
+	photos, total, err := s.db.GetPhotos(f)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := make([]photoItem, 0, len(photos))
+	for _, p := range photos {
+		items = append(items, newPhotoItem(p))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"photos": items,
+		"total":  total,
+		"limit":  f.Limit,
+		"offset": f.Offset,
+	})
+}
+
+// handlePhoto routes /api/photos/{id}, /api/photos/timeline and
+// /api/photos/albums
+func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/photos/"), "/"), "/")
+	switch parts[0] {
+	case "timeline", "albums":
+		if len(parts) > 1 {
+			notFound(w)
+			return
+		}
+		s.handlePhotoGroups(w, r, user, parts[0])
+		return
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid photo ID")
+		return
+	}
+	p, err := s.db.GetPhoto(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpError(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !user.CanAccessLibrary(p.LibraryID) {
+		localizedError(w, r, http.StatusForbidden, "Content not available")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newPhotoItem(*p))
+	case len(parts) == 2 && parts[1] == "original":
+		file, err := os.Open(p.Path)
+		if err != nil {
+			httpError(w, "Photo file not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(p.Path), info.ModTime(), file)
+	case len(parts) == 3 && parts[1] == "thumbnail":
+		s.servePhotoThumbnail(w, r, p, parts[2])
+	default:
+		notFound(w)
+	}
+}
+
+// servePhotoThumbnail serves a photo's thumbnail in a size
+func (s *Server) servePhotoThumbnail(w http.ResponseWriter, r *http.Request, p *database.Photo, size string) {
+	known := slices.ContainsFunc(photo.Sizes, func(sz photo.Size) bool { return sz.Name == size })
+	if !known || p.ThumbnailPath == nil {
+		httpError(w, "Thumbnail not found", http.StatusNotFound)
+		return
+	}
+	file := scanner.PhotoThumbnailFile(filepath.Dir(s.config.DBPath), *p.ThumbnailPath, size)
+	if _, err := os.Stat(file); err != nil {
+		httpError(w, "Thumbnail not found", http.StatusNotFound)
+		return
+	}
+	// The folder changes with the photo, but the URL doesn't
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeFile(w, r, file)
+}
+
+// handlePhotoGroups handles GET /api/photos/timeline and /api/photos/albums
+func (s *Server) handlePhotoGroups(w http.ResponseWriter, r *http.Request, user *database.User, view string) {
+	f, ok := s.photoFilter(w, r, user)
+	if !ok {
+		return
+	}
+
+	var groups []database.PhotoGroup
+	var err error
+	if view == "albums" {
+		groups, err = s.db.GetPhotoAlbums(f)
+	} else {
+		group := r.URL.Query().Get("group")
+		if group != "" && group != "day" && group != "month" {
+			httpError(w, "group must be day or month", http.StatusBadRequest)
+			return
+		}
+		groups, err = s.db.GetPhotoTimeline(f, group == "month")
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPhotoGroupItems(groups))
+}
@@ -0,0 +1,708 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/m3u"
+)
+
+// Music playlists
+//
+// Each profile keeps its own playlists of music tracks:
+//
+//	GET    /api/playlists                        the active profile's playlists
+//	POST   /api/playlists                        create {name, description, trackIds}
+//	POST   /api/playlists/import?name=           create from an M3U or M3U8 file
+//	GET    /api/playlists/{id}                   the playlist and its entries
+//	PUT    /api/playlists/{id}                   rename {name, description}
+//	DELETE /api/playlists/{id}                   delete
+//	POST   /api/playlists/{id}/tracks            add {trackIds, position}
+//	PUT    /api/playlists/{id}/tracks            reorder {entryIds}, after a drag
+//	DELETE /api/playlists/{id}/tracks/{entryId}  remove an entry
+//	POST   /api/playlists/{id}/played            record {entryId} as playing
+//	GET    /api/playlists/{id}/export?format=    download as m3u8 (default) or m3u
+//	GET    /api/music/continue-listening         playlists, albums and audiobooks played partway
+//
+// Imported files are matched to tracks by path: exactly, then by the last
+// folders and file name, so playlists made on another machine or with
+// another library root still match, then by the #EXTINF "Artist - Title".
+
+const (
+	maxPlaylistName        = 200
+	maxPlaylistDescription = 2000
+	// continueListeningDays is how far back albums count as being listened to
+	continueListeningDays = 30
+	continueListeningSize = 20
+)
+
+// playlistInput is what's sent to create or rename a playlist
+type playlistInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	TrackIDs    []int64 `json:"trackIds"`
+}
+
+// validate trims and checks the name and description
+func (in *playlistInput) validate() error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > maxPlaylistName {
+		return fmt.Errorf("A name of up to %d characters is required", maxPlaylistName)
+	}
+	if in.Description != nil {
+		description := strings.TrimSpace(*in.Description)
+		if len(description) > maxPlaylistDescription {
+			return fmt.Errorf("The description can be up to %d characters", maxPlaylistDescription)
+		}
+		in.Description = &description
+		if description == "" {
+			in.Description = nil
+		}
+	}
+	return nil
+}
+
+// playlistResponse is a playlist with its entries
+type playlistResponse struct {
+	*database.Playlist
+	Entries []database.PlaylistEntry `json:"entries"`
+}
+
+// handlePlaylists handles /api/playlists, listing and creating the active
+// profile's playlists
+func (s *Server) handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		playlists, err := s.db.GetPlaylists(user.ID, profileID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if playlists == nil {
+			playlists = []database.Playlist{}
+		}
+		json.NewEncoder(w).Encode(playlists)
+
+	case http.MethodPost:
+		var input playlistInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := input.validate(); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkPlaylistTracks(user, input.TrackIDs); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		playlist := &database.Playlist{UserID: user.ID, ProfileID: profileID, Name: input.Name, Description: input.Description}
+		if err := s.db.CreatePlaylist(playlist); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(input.TrackIDs) > 0 {
+			if err := s.db.AddPlaylistTracks(playlist.ID, input.TrackIDs, -1); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		s.writePlaylist(w, user, playlist.ID)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlaylist routes /api/playlists/{id} and what's under it
+func (s *Server) handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/playlists/"), "/")
+	if parts[0] == "import" && len(parts) == 1 {
+		s.handlePlaylistImport(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	playlist, err := s.db.GetPlaylist(id)
+	if err != nil || !s.ownsPlaylist(r, user, playlist) {
+		httpError(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		s.handlePlaylistItself(w, r, user, playlist)
+	case len(parts) == 2 && parts[1] == "tracks":
+		s.handlePlaylistTracks(w, r, user, playlist)
+	case len(parts) == 3 && parts[1] == "tracks":
+		entryID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid entry ID")
+			return
+		}
+		if r.Method != http.MethodDelete {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := s.db.RemovePlaylistEntry(playlist.ID, entryID); errors.Is(err, sql.ErrNoRows) {
+			httpError(w, "Entry not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.writePlaylist(w, user, playlist.ID)
+	case len(parts) == 2 && parts[1] == "played":
+		s.handlePlaylistPlayed(w, r, user, playlist)
+	case len(parts) == 2 && parts[1] == "export":
+		s.handlePlaylistExport(w, r, user, playlist)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handlePlaylistItself gets, renames and deletes a playlist
+func (s *Server) handlePlaylistItself(w http.ResponseWriter, r *http.Request, user *database.User, playlist *database.Playlist) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writePlaylist(w, user, playlist.ID)
+
+	case http.MethodPut:
+		var input playlistInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := input.validate(); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		playlist.Name = input.Name
+		playlist.Description = input.Description
+		if err := s.db.UpdatePlaylist(playlist); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.writePlaylist(w, user, playlist.ID)
+
+	case http.MethodDelete:
+		if err := s.db.DeletePlaylist(playlist.ID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlaylistTracks adds tracks to a playlist and reorders its entries
+func (s *Server) handlePlaylistTracks(w http.ResponseWriter, r *http.Request, user *database.User, playlist *database.Playlist) {
+	switch r.Method {
+	case http.MethodPost:
+		var input struct {
+			TrackIDs []int64 `json:"trackIds"`
+			Position *int    `json:"position"` // Before this entry; the end if left out
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if len(input.TrackIDs) == 0 {
+			httpError(w, "trackIds is required", http.StatusBadRequest)
+			return
+		}
+		if err := s.checkPlaylistTracks(user, input.TrackIDs); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		position := -1
+		if input.Position != nil {
+			position = *input.Position
+		}
+		if err := s.db.AddPlaylistTracks(playlist.ID, input.TrackIDs, position); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.writePlaylist(w, user, playlist.ID)
+
+	case http.MethodPut:
+		var input struct {
+			EntryIDs []int64 `json:"entryIds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := s.db.ReorderPlaylist(playlist.ID, input.EntryIDs); errors.Is(err, database.ErrPlaylistEntries) {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.writePlaylist(w, user, playlist.ID)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlaylistPlayed handles POST /api/playlists/{id}/played, recording a
+// play of the entry for mixes and moving the playlist's resume point to it.
+// Playing the last entry takes the playlist off continue listening.
+func (s *Server) handlePlaylistPlayed(w http.ResponseWriter, r *http.Request, user *database.User, playlist *database.Playlist) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var input struct {
+		EntryID int64 `json:"entryId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	entries, err := s.db.GetPlaylistEntries(playlist.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	index := -1
+	for i, e := range entries {
+		if e.EntryID == input.EntryID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		httpError(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.db.RecordTrackPlay(user.ID, s.getActiveProfileID(r), entries[index].ID); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if index == len(entries)-1 {
+		err = s.db.ClearPlaylistResume(playlist.ID)
+	} else {
+		err = s.db.SetPlaylistResume(playlist.ID, input.EntryID)
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePlaylistExport handles GET /api/playlists/{id}/export, downloading
+// the playlist as an M3U8 file, or M3U with ?format=m3u. Entries point at
+// the tracks' paths on the server.
+func (s *Server) handlePlaylistExport(w http.ResponseWriter, r *http.Request, user *database.User, playlist *database.Playlist) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "m3u8"
+	}
+	if format != "m3u8" && format != "m3u" {
+		httpError(w, "format must be m3u8 or m3u", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := s.playlistEntries(user, playlist.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lines := make([]m3u.Entry, len(entries))
+	for i, e := range entries {
+		lines[i] = m3u.Entry{Path: e.Path, Title: e.ArtistName + " - " + e.Title, Duration: e.Duration}
+	}
+
+	filename := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, playlist.Name)
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+format))
+	m3u.Write(w, lines)
+}
+
+// handlePlaylistImport handles POST /api/playlists/import?name=, creating a
+// playlist from an M3U or M3U8 file sent as the body or as the "file" field
+// of a form. The name defaults to the file's. Entries that match no track
+// are left out and listed in the response.
+func (s *Server) handlePlaylistImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	input := playlistInput{Name: r.URL.Query().Get("name")}
+	r.Body = http.MaxBytesReader(w, r.Body, m3u.MaxSize+1<<20)
+	body := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			httpError(w, "A playlist file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+		if input.Name == "" {
+			input.Name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+		}
+	}
+	if err := input.validate(); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lines, err := m3u.Parse(body)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tracks, err := s.db.GetMixTracks()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	matcher := newTrackMatcher(user, tracks)
+	var trackIDs []int64
+	unmatched := []string{}
+	for _, line := range lines {
+		if id, ok := matcher.match(line); ok {
+			trackIDs = append(trackIDs, id)
+		} else {
+			unmatched = append(unmatched, line.Path)
+		}
+	}
+
+	playlist := &database.Playlist{UserID: user.ID, ProfileID: s.getActiveProfileID(r), Name: input.Name}
+	if err := s.db.CreatePlaylist(playlist); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(trackIDs) > 0 {
+		if err := s.db.AddPlaylistTracks(playlist.ID, trackIDs, -1); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	created, err := s.db.GetPlaylist(playlist.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"playlist":  created,
+		"matched":   len(trackIDs),
+		"unmatched": unmatched,
+	})
+}
+
+// continueListeningItem is a playlist, album or audiobook played partway
+// through
+type continueListeningItem struct {
+	Type         string             `json:"type"` // playlist, album, audiobook
+	ID           int64              `json:"id"`
+	Title        string             `json:"title"`
+	Subtitle     string             `json:"subtitle,omitempty"` // The album's artist or the book's author
+	CoverPath    *string            `json:"coverPath,omitempty"`
+	Next         *database.MixTrack `json:"next,omitempty"`    // Of a playlist or album
+	EntryID      *int64             `json:"entryId,omitempty"` // Of the next track, in a playlist
+	LastPlayedAt time.Time          `json:"lastPlayedAt"`
+	Playlist     *database.Playlist `json:"playlist,omitempty"`
+	Audiobook    *audiobookState    `json:"audiobook,omitempty"` // Where the book is at
+}
+
+// handleContinueListening handles GET /api/music/continue-listening, the
+// playlists, albums and audiobooks the active profile stopped partway
+// through, most recently played first, with where to carry on from
+func (s *Server) handleContinueListening(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	profileID := s.getActiveProfileID(r)
+
+	items := []continueListeningItem{}
+	playlists, err := s.db.GetContinueListeningPlaylists(user.ID, profileID, continueListeningSize)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range playlists {
+		p := &playlists[i]
+		if !user.CanAccessLibrary(p.Next.LibraryID) {
+			continue
+		}
+		entryID := p.Next.EntryID
+		items = append(items, continueListeningItem{
+			Type:         "playlist",
+			ID:           p.ID,
+			Title:        p.Name,
+			CoverPath:    p.Next.CoverPath,
+			Next:         &p.Next.MixTrack,
+			EntryID:      &entryID,
+			LastPlayedAt: *p.LastPlayedAt,
+			Playlist:     &p.Playlist,
+		})
+	}
+
+	since := time.Now().AddDate(0, 0, -continueListeningDays)
+	plays, err := s.db.GetRecentAlbumPlays(user.ID, profileID, since, continueListeningSize)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, play := range plays {
+		if item, ok := s.continueAlbum(user, play); ok {
+			items = append(items, item)
+		}
+	}
+
+	if profileID != nil {
+		books, err := s.continueAudiobooks(user, *profileID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, books...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].LastPlayedAt.After(items[j].LastPlayedAt) })
+	if len(items) > continueListeningSize {
+		items = items[:continueListeningSize]
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+// continueAlbum returns an album to carry on with after the track played
+// last, unless that was its last track
+func (s *Server) continueAlbum(user *database.User, play database.AlbumPlay) (continueListeningItem, bool) {
+	album, err := s.db.GetAlbum(play.AlbumID)
+	if err != nil {
+		return continueListeningItem{}, false
+	}
+	artist, err := s.db.GetArtist(album.ArtistID)
+	if err != nil || !user.CanAccessLibrary(artist.LibraryID) {
+		return continueListeningItem{}, false
+	}
+	tracks, err := s.db.GetTracksByAlbum(album.ID)
+	if err != nil {
+		return continueListeningItem{}, false
+	}
+	for i, t := range tracks {
+		if t.ID != play.TrackID || i+1 >= len(tracks) {
+			continue
+		}
+		next := database.MixTrack{
+			Track:      tracks[i+1],
+			ArtistID:   artist.ID,
+			ArtistName: artist.Name,
+			AlbumTitle: album.Title,
+			AlbumYear:  album.Year,
+			CoverPath:  album.CoverPath,
+			LibraryID:  artist.LibraryID,
+		}
+		return continueListeningItem{
+			Type:         "album",
+			ID:           album.ID,
+			Title:        album.Title,
+			Subtitle:     artist.Name,
+			CoverPath:    album.CoverPath,
+			Next:         &next,
+			LastPlayedAt: play.PlayedAt,
+		}, true
+	}
+	return continueListeningItem{}, false
+}
+
+// ownsPlaylist reports whether a playlist is the current user's, on the
+// active profile
+func (s *Server) ownsPlaylist(r *http.Request, user *database.User, playlist *database.Playlist) bool {
+	if playlist.UserID != user.ID {
+		return false
+	}
+	profileID := s.getActiveProfileID(r)
+	if profileID == nil || playlist.ProfileID == nil {
+		return profileID == nil && playlist.ProfileID == nil
+	}
+	return *profileID == *playlist.ProfileID
+}
+
+// checkPlaylistTracks checks that tracks exist in libraries the user can
+// see
+func (s *Server) checkPlaylistTracks(user *database.User, trackIDs []int64) error {
+	if len(trackIDs) == 0 {
+		return nil
+	}
+	tracks, err := s.db.GetMixTracks()
+	if err != nil {
+		return err
+	}
+	visible := make(map[int64]bool, len(tracks))
+	for _, t := range tracks {
+		if user.CanAccessLibrary(t.LibraryID) {
+			visible[t.ID] = true
+		}
+	}
+	for _, id := range trackIDs {
+		if !visible[id] {
+			return fmt.Errorf("Track %d not found", id)
+		}
+	}
+	return nil
+}
+
+// playlistEntries returns a playlist's entries in libraries the user can see
+func (s *Server) playlistEntries(user *database.User, playlistID int64) ([]database.PlaylistEntry, error) {
+	entries, err := s.db.GetPlaylistEntries(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	visible := []database.PlaylistEntry{}
+	for _, e := range entries {
+		if user.CanAccessLibrary(e.LibraryID) {
+			e.Position = len(visible)
+			visible = append(visible, e)
+		}
+	}
+	return visible, nil
+}
+
+// writePlaylist writes a playlist with its entries
+func (s *Server) writePlaylist(w http.ResponseWriter, user *database.User, playlistID int64) {
+	playlist, err := s.db.GetPlaylist(playlistID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entries, err := s.playlistEntries(user, playlistID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(playlistResponse{Playlist: playlist, Entries: entries})
+}
+
+// trackMatcher finds the tracks imported playlist entries are for
+type trackMatcher struct {
+	byPath   map[string]int64
+	bySuffix map[string][]int64 // By the last one, two and three path parts
+	byTitle  map[string][]int64 // By "artist - title"
+}
+
+func newTrackMatcher(user *database.User, tracks []database.MixTrack) *trackMatcher {
+	m := &trackMatcher{
+		byPath:   make(map[string]int64),
+		bySuffix: make(map[string][]int64),
+		byTitle:  make(map[string][]int64),
+	}
+	for _, t := range tracks {
+		if !user.CanAccessLibrary(t.LibraryID) {
+			continue
+		}
+		m.byPath[filepath.Clean(t.Path)] = t.ID
+		parts := splitPlaylistPath(t.Path)
+		for n := 1; n <= 3 && n <= len(parts); n++ {
+			key := strings.Join(parts[len(parts)-n:], "/")
+			m.bySuffix[key] = append(m.bySuffix[key], t.ID)
+		}
+		key := strings.ToLower(t.ArtistName + " - " + t.Title)
+		m.byTitle[key] = append(m.byTitle[key], t.ID)
+	}
+	return m
+}
+
+// match returns the track an entry is for. A suffix or title has to match
+// a single track.
+func (m *trackMatcher) match(entry m3u.Entry) (int64, bool) {
+	if id, ok := m.byPath[filepath.Clean(entry.Path)]; ok {
+		return id, true
+	}
+	parts := splitPlaylistPath(entry.Path)
+	for n := min(3, len(parts)); n >= 1; n-- {
+		if ids := m.bySuffix[strings.Join(parts[len(parts)-n:], "/")]; len(ids) == 1 {
+			return ids[0], true
+		} else if len(ids) > 1 {
+			break
+		}
+	}
+	if entry.Title != "" {
+		if ids := m.byTitle[strings.ToLower(entry.Title)]; len(ids) == 1 {
+			return ids[0], true
+		}
+	}
+	return 0, false
+}
+
+// splitPlaylistPath splits a path from any system, or a file URL, into its
+// parts in lower case
+func splitPlaylistPath(path string) []string {
+	path = strings.TrimPrefix(path, "file://")
+	path = strings.ToLower(strings.ReplaceAll(path, `\`, "/"))
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/podcast"
+)
+
+// Podcasts
+//
+// Users subscribe to podcast feeds under /api/podcasts. Admins change how a
+// podcast is downloaded and kept; subscribers can refresh it and download
+// single episodes. Episodes stream from /api/stream/podcast_episode/{id} and
+// save their position through /api/progress like other media.
+
+// SetPodcasts sets the podcast service. Without one the podcast routes
+// respond 503.
+func (s *Server) SetPodcasts(podcasts *podcast.Service) {
+	s.podcasts = podcasts
+}
+
+// podcastDetail is a podcast with its episodes
+type podcastDetail struct {
+	database.Podcast
+	Subscribed bool                      `json:"subscribed"`
+	Episodes   []database.PodcastEpisode `json:"episodes"`
+}
+
+// handlePodcasts handles GET/POST /api/podcasts: the user's subscriptions
+// (every podcast for admins with ?all=true), and subscribing to a feed
+func (s *Server) handlePodcasts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if s.podcasts == nil {
+		httpError(w, "Podcasts are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var podcasts []database.Podcast
+		var err error
+		if r.URL.Query().Get("all") == "true" && user.Role == "admin" {
+			podcasts, err = s.db.GetPodcasts()
+		} else {
+			podcasts, err = s.db.GetUserPodcasts(user.ID)
+		}
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if podcasts == nil {
+			podcasts = []database.Podcast{}
+		}
+		json.NewEncoder(w).Encode(podcasts)
+
+	case http.MethodPost:
+		var req struct {
+			FeedURL string `json:"feedUrl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := podcast.ValidateFeedURL(req.FeedURL); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p, err := s.podcasts.Subscribe(user.ID, req.FeedURL)
+		if err != nil {
+			podcastFetchError(w, r, "subscribe", err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePodcast handles /api/podcasts/{id} and its actions:
+//
+//	GET    /api/podcasts/{id}                         podcast with its episodes
+//	PUT    /api/podcasts/{id}                         download settings (admin)
+//	DELETE /api/podcasts/{id}                         unsubscribe
+//	POST   /api/podcasts/{id}/refresh                 check the feed now
+//	POST   /api/podcasts/episodes/{id}/download       download an episode
+//	DELETE /api/podcasts/episodes/{id}/download       remove its download (admin)
+func (s *Server) handlePodcast(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if s.podcasts == nil {
+		httpError(w, "Podcasts are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/podcasts/")
+	if rest, ok := strings.CutPrefix(path, "episodes/"); ok {
+		idStr, ok := strings.CutSuffix(rest, "/download")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if !ok || err != nil {
+			httpError(w, "Invalid episode path", http.StatusBadRequest)
+			return
+		}
+		s.handlePodcastEpisodeDownload(w, r, user, id)
+		return
+	}
+
+	idStr, refresh := strings.CutSuffix(path, "/refresh")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	p, err := s.db.GetPodcast(id)
+	if err != nil {
+		httpError(w, "Podcast not found", http.StatusNotFound)
+		return
+	}
+	subscribed := s.db.IsSubscribedToPodcast(user.ID, id)
+	if !subscribed && user.Role != "admin" {
+		httpError(w, "Podcast not found", http.StatusNotFound)
+		return
+	}
+
+	if refresh {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		added, err := s.podcasts.Refresh(id)
+		if err != nil {
+			podcastFetchError(w, r, "refresh", err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"newEpisodes": added})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		episodes, err := s.db.GetPodcastEpisodes(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if episodes == nil {
+			episodes = []database.PodcastEpisode{}
+		}
+		json.NewEncoder(w).Encode(podcastDetail{Podcast: *p, Subscribed: subscribed, Episodes: episodes})
+
+	case http.MethodPut:
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+			return
+		}
+		var req struct {
+			AutoDownload *bool `json:"autoDownload"`
+			KeepEpisodes *int  `json:"keepEpisodes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if req.AutoDownload != nil {
+			p.AutoDownload = *req.AutoDownload
+		}
+		if req.KeepEpisodes != nil {
+			if *req.KeepEpisodes < 0 {
+				httpError(w, "Episodes to keep must be 0 (all) or more", http.StatusBadRequest)
+				return
+			}
+			p.KeepEpisodes = *req.KeepEpisodes
+		}
+		if err := s.db.UpdatePodcastSettings(id, p.AutoDownload, p.KeepEpisodes); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(p)
+
+	case http.MethodDelete:
+		if _, err := s.podcasts.Unsubscribe(user.ID, id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePodcastEpisodeDownload downloads an episode for subscribers, or
+// removes its download for admins
+func (s *Server) handlePodcastEpisodeDownload(w http.ResponseWriter, r *http.Request, user *database.User, id int64) {
+	episode, err := s.db.GetPodcastEpisode(id)
+	if err != nil || (user.Role != "admin" && !s.db.IsSubscribedToPodcast(user.ID, episode.PodcastID)) {
+		httpError(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := s.podcasts.DownloadEpisode(id); err != nil {
+			podcastFetchError(w, r, "download", err)
+			return
+		}
+	case http.MethodDelete:
+		if user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
+			return
+		}
+		if err := s.podcasts.DeleteDownload(id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	episode, err = s.db.GetPodcastEpisode(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(episode)
+}
+
+// podcastFetchError writes the error of fetching a feed or an episode. What
+// the host answered is only logged, so the API can't be used to probe other
+// servers.
+func podcastFetchError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	if errors.Is(err, podcast.ErrPrivateAddress) {
+		httpError(w, "Podcasts must be on the public internet", http.StatusBadRequest)
+		return
+	}
+	requestLog(r).Errorf("Podcasts: failed to %s: %v", action, err)
+	httpError(w, "Failed to "+action, http.StatusBadGateway)
+}
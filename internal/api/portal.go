@@ -0,0 +1,382 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+)
+
+// Request portal handlers
+//
+// The request portal lets household members without accounts search TMDB and
+// request titles. It's off unless portal_enabled is set, can require a shared
+// passcode, and is rate limited per address. Requests are filed under the
+// portal_user_id account (the first admin when unset) with the requester's
+// name, and go through the normal approval queue.
+
+// portalSearchesPerMinute limits portal searches per address
+const portalSearchesPerMinute = 30
+
+// portalPasscodeFailures limits wrong passcodes per address in
+// portalPasscodeWindow, so the passcode can't be guessed
+const (
+	portalPasscodeFailures = 10
+	portalPasscodeWindow   = 15 * time.Minute
+)
+
+// maxPortalNameLength caps the name a portal requester gives
+const maxPortalNameLength = 50
+
+// rateLimiter counts hits per key within a sliding window
+type rateLimiter struct {
+	window time.Duration
+	mu     sync.Mutex
+	hits   map[string][]time.Time
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{window: window, hits: make(map[string][]time.Time)}
+}
+
+// allow records a hit for a key and reports whether it's within the limit.
+// Refused hits aren't recorded, so a client that backs off recovers.
+func (l *rateLimiter) allow(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.hits[key][:0]
+	for _, at := range l.hits[key] {
+		if now.Sub(at) < l.window {
+			recent = append(recent, at)
+		}
+	}
+	allowed := len(recent) < limit
+	if allowed {
+		recent = append(recent, now)
+	}
+	l.hits[key] = recent
+
+	// Drop keys that have gone quiet so the map doesn't grow forever
+	for k, times := range l.hits {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= l.window {
+			delete(l.hits, k)
+		}
+	}
+	return allowed
+}
+
+// full reports whether a key has reached its limit, without recording a hit
+func (l *rateLimiter) full(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := 0
+	for _, at := range l.hits[key] {
+		if now.Sub(at) < l.window {
+			recent++
+		}
+	}
+	return recent >= limit
+}
+
+// Portal rate limits, shared by all portal handlers
+var (
+	portalSearchLimiter   = newRateLimiter(time.Minute)
+	portalRequestLimiter  = newRateLimiter(time.Hour)
+	portalPasscodeLimiter = newRateLimiter(portalPasscodeWindow)
+)
+
+// requirePortal wraps portal handlers: the portal must be enabled, the
+// caller's country allowed and the passcode, when set, given in the
+// X-Portal-Passcode header. Addresses that give too many wrong passcodes
+// are turned away for a while.
+func (s *Server) requirePortal(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if enabled, _ := s.db.GetSetting("portal_enabled"); enabled != "true" {
+			localizedError(w, r, http.StatusNotFound, "Request portal is not enabled")
+			return
+		}
+		if !allowCountry(w, r) {
+			return
+		}
+		passcode, _ := s.db.GetSetting("portal_passcode")
+		if passcode != "" {
+			ip, now := netaccess.ClientIP(r), time.Now()
+			if portalPasscodeLimiter.full(ip, portalPasscodeFailures, now) {
+				localizedError(w, r, http.StatusTooManyRequests, "Too many wrong passcodes, try again later")
+				return
+			}
+			given := r.Header.Get("X-Portal-Passcode")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(passcode)) != 1 {
+				portalPasscodeLimiter.allow(ip, portalPasscodeFailures, now)
+				requestLog(r).Infof("Portal: wrong passcode from %s", ip)
+				localizedError(w, r, http.StatusUnauthorized, "Invalid passcode")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handlePortal handles GET /api/portal, telling the portal page whether the
+// portal is open and needs a passcode
+func (s *Server) handlePortal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enabled, _ := s.db.GetSetting("portal_enabled")
+	passcode, _ := s.db.GetSetting("portal_passcode")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":          enabled == "true",
+		"passcodeRequired": passcode != "",
+	})
+}
+
+// portalResult is a TMDB search result on the portal, with whether it's
+// already available or requested
+type portalResult struct {
+	Type       string `json:"type"` // movie, show
+	TmdbID     int64  `json:"tmdbId"`
+	Title      string `json:"title"`
+	Year       int    `json:"year,omitempty"`
+	Overview   string `json:"overview,omitempty"`
+	PosterPath string `json:"posterPath,omitempty"`
+	Status     string `json:"status,omitempty"` // available, requested
+}
+
+// portalStatus reports whether a title is in the library or requested
+func (s *Server) portalStatus(mediaType string, tmdbID int64) string {
+	if mediaType == "movie" {
+		if _, err := s.db.GetMovieByTmdb(tmdbID); err == nil {
+			return "available"
+		}
+	} else if _, err := s.db.GetShowByTmdb(tmdbID); err == nil {
+		return "available"
+	}
+	if open, _ := s.db.HasOpenRequest(mediaType, tmdbID); open {
+		return "requested"
+	}
+	return ""
+}
+
+// releaseYear reads the year of a TMDB YYYY-MM-DD date
+func releaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(date[:4])
+	return year
+}
+
+// handlePortalSearch handles GET /api/portal/search?q=...&type=movie|show
+func (s *Server) handlePortalSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !portalSearchLimiter.allow(netaccess.ClientIP(r), portalSearchesPerMinute, time.Now()) {
+		localizedError(w, r, http.StatusTooManyRequests, "Too many searches, try again in a minute")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		httpError(w, "Query parameter 'q' required", http.StatusBadRequest)
+		return
+	}
+	mediaType := r.URL.Query().Get("type")
+	if mediaType != "" && mediaType != "movie" && mediaType != "show" {
+		httpError(w, "type must be movie or show", http.StatusBadRequest)
+		return
+	}
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
+		return
+	}
+
+	results := []portalResult{}
+	if mediaType != "show" {
+		movies, err := s.metadata.SearchMovies(query, 0)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, m := range movies {
+			results = append(results, portalResult{
+				Type: "movie", TmdbID: m.ID, Title: m.Title, Year: releaseYear(m.ReleaseDate),
+				Overview: m.Overview, PosterPath: m.PosterPath,
+			})
+		}
+	}
+	if mediaType != "movie" {
+		shows, err := s.metadata.SearchTV(query, 0)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, t := range shows {
+			results = append(results, portalResult{
+				Type: "show", TmdbID: t.ID, Title: t.Name, Year: releaseYear(t.FirstAirDate),
+				Overview: t.Overview, PosterPath: t.PosterPath,
+			})
+		}
+	}
+	for i := range results {
+		results[i].Status = s.portalStatus(results[i].Type, results[i].TmdbID)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+// portalUserID returns the account portal requests are filed under
+func (s *Server) portalUserID() (int64, error) {
+	if v, _ := s.db.GetSetting("portal_user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid portal account")
+		}
+		if _, err := s.db.GetUserByID(id); err != nil {
+			return 0, fmt.Errorf("portal account no longer exists")
+		}
+		return id, nil
+	}
+	admins, err := s.db.GetAdminUserIDs()
+	if err != nil || len(admins) == 0 {
+		return 0, fmt.Errorf("no account to file portal requests under")
+	}
+	first := admins[0]
+	for _, id := range admins[1:] {
+		if id < first {
+			first = id
+		}
+	}
+	return first, nil
+}
+
+// handlePortalRequests handles POST /api/portal/requests with
+// {type, tmdbId, name, seasons}. Title details come from TMDB, not the caller.
+func (s *Server) handlePortalRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Type    string `json:"type"`
+		TmdbID  int64  `json:"tmdbId"`
+		Name    string `json:"name"`
+		Seasons []int  `json:"seasons"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxPortalNameLength {
+		localizedError(w, r, http.StatusBadRequest, "A name of up to %d characters is required", maxPortalNameLength)
+		return
+	}
+	if (req.Type != "movie" && req.Type != "show") || req.TmdbID <= 0 {
+		httpError(w, "type (movie or show) and tmdbId are required", http.StatusBadRequest)
+		return
+	}
+
+	switch s.portalStatus(req.Type, req.TmdbID) {
+	case "available":
+		localizedError(w, r, http.StatusConflict, "Already in the library")
+		return
+	case "requested":
+		localizedError(w, r, http.StatusConflict, "Already requested")
+		return
+	}
+
+	limit := 5
+	if v, err := s.db.GetSetting("portal_requests_per_hour"); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	if !portalRequestLimiter.allow(netaccess.ClientIP(r), limit, time.Now()) {
+		localizedError(w, r, http.StatusTooManyRequests, "Request limit reached (%d per hour), try again later", limit)
+		return
+	}
+
+	userID, err := s.portalUserID()
+	if err != nil {
+		requestLog(r).Infof("Request portal: %v", err)
+		localizedError(w, r, http.StatusServiceUnavailable, "Request portal is not set up")
+		return
+	}
+	if !s.metadataConfigured() {
+		writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "TMDB API key not configured")
+		return
+	}
+
+	request := &database.Request{UserID: userID, Type: req.Type, TmdbID: req.TmdbID, RequesterName: &req.Name}
+	tmdbClient := s.metadata.GetTMDBClient()
+	var overview, poster, backdrop string
+	if req.Type == "movie" {
+		details, err := tmdbClient.GetMovieDetails(req.TmdbID)
+		if err != nil {
+			localizedError(w, r, http.StatusNotFound, "Title not found")
+			return
+		}
+		request.Title, request.Year = details.Title, releaseYear(details.ReleaseDate)
+		overview, poster, backdrop = details.Overview, details.PosterPath, details.BackdropPath
+	} else {
+		details, err := tmdbClient.GetTVDetails(req.TmdbID)
+		if err != nil {
+			localizedError(w, r, http.StatusNotFound, "Title not found")
+			return
+		}
+		request.Title, request.Year = details.Name, releaseYear(details.FirstAirDate)
+		overview, poster, backdrop = details.Overview, details.PosterPath, details.BackdropPath
+	}
+	if overview != "" {
+		request.Overview = &overview
+	}
+	if poster != "" {
+		request.PosterPath = &poster
+	}
+	if backdrop != "" {
+		request.BackdropPath = &backdrop
+	}
+	if len(req.Seasons) > 0 && req.Type == "show" {
+		seasonsBytes, _ := json.Marshal(req.Seasons)
+		seasons := string(seasonsBytes)
+		request.Seasons = &seasons
+	}
+
+	if err := s.db.CreateRequest(request); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	requestLog(r).Infof("Portal request created: id=%d type=%s tmdbId=%d title=%s by %q from %s",
+		request.ID, request.Type, request.TmdbID, request.Title, req.Name, netaccess.ClientIP(r))
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     request.ID,
+		"type":   request.Type,
+		"tmdbId": request.TmdbID,
+		"title":  request.Title,
+		"year":   request.Year,
+		"status": request.Status,
+	})
+}
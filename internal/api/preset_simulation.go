@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Quality preset simulation
+
+// handleQualityPresetSimulate handles POST /api/quality/presets/{id}/simulate.
+// Body: {"mediaType": "movie"|"show"|"anime", "tmdbId": n, "title": "", "year": n}.
+// Runs a real indexer search and returns the ranked decision trace without
+// grabbing anything. Title and year are only needed for titles that aren't
+// in the library or wanted list.
+func (s *Server) handleQualityPresetSimulate(w http.ResponseWriter, r *http.Request, presetID int64) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.scheduler == nil {
+		httpError(w, "Scheduler not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		MediaType string `json:"mediaType"`
+		TmdbID    int64  `json:"tmdbId"`
+		Title     string `json:"title"`
+		Year      int    `json:"year"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.MediaType != "movie" && req.MediaType != "show" && req.MediaType != "anime" {
+		httpError(w, "mediaType must be movie, show or anime", http.StatusBadRequest)
+		return
+	}
+	if req.TmdbID <= 0 {
+		httpError(w, "tmdbId is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.db.GetQualityPreset(presetID); err != nil {
+		httpError(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	sim, err := s.scheduler.SimulatePreset(presetID, req.MediaType, req.TmdbID, req.Title, req.Year)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(sim)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+	"github.com/outpost/outpost/internal/parser"
+)
+
+// Release exclusions
+//
+// Release groups and title patterns never to grab for one movie or show,
+// usually added from the item's interactive search results, where excluded
+// releases come back rejected with the reason:
+//
+//	GET    /api/release-exclusions?mediaType=movie|show&tmdbId=n  an item's exclusions
+//	POST   /api/release-exclusions                                exclude {mediaType, tmdbId, kind: group|title, value, isRegex, reason}
+//	DELETE /api/release-exclusions/{id}                           stop excluding
+//
+// A POST can give a releaseTitle from the search results instead of a value:
+// its group, or the whole title, is excluded.
+
+// handleReleaseExclusions handles GET and POST /api/release-exclusions
+func (s *Server) handleReleaseExclusions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		mediaType := query.Get("mediaType")
+		tmdbID, err := strconv.ParseInt(query.Get("tmdbId"), 10, 64)
+		if (mediaType != "movie" && mediaType != "show") || err != nil {
+			httpError(w, "mediaType (movie or show) and tmdbId are required", http.StatusBadRequest)
+			return
+		}
+		exclusions, err := s.db.GetReleaseExclusions(mediaType, tmdbID)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if exclusions == nil {
+			exclusions = []database.ReleaseExclusion{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(exclusions)
+
+	case http.MethodPost:
+		var req struct {
+			database.ReleaseExclusion
+			ReleaseTitle string `json:"releaseTitle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		exclusion := req.ReleaseExclusion
+		if exclusion.MediaType != "movie" && exclusion.MediaType != "show" {
+			httpError(w, "mediaType must be movie or show", http.StatusBadRequest)
+			return
+		}
+		if exclusion.TmdbID <= 0 {
+			httpError(w, "tmdbId is required", http.StatusBadRequest)
+			return
+		}
+
+		exclusion.Value = strings.TrimSpace(exclusion.Value)
+		title := strings.TrimSpace(req.ReleaseTitle)
+		switch exclusion.Kind {
+		case database.ExcludeGroup:
+			if exclusion.Value == "" && title != "" {
+				exclusion.Value = parser.Parse(title).ReleaseGroup
+				if exclusion.Value == "" {
+					httpError(w, "The release has no group", http.StatusBadRequest)
+					return
+				}
+			}
+			exclusion.IsRegex = false
+		case database.ExcludeTitle:
+			if exclusion.Value == "" && title != "" {
+				exclusion.Value = title
+				exclusion.IsRegex = false
+			}
+			if exclusion.IsRegex {
+				if _, err := regexp.Compile(exclusion.Value); err != nil {
+					httpError(w, "Invalid pattern: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		default:
+			httpError(w, "kind must be group or title", http.StatusBadRequest)
+			return
+		}
+		if exclusion.Value == "" {
+			httpError(w, "value or releaseTitle is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.db.AddReleaseExclusion(&exclusion); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(exclusion)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReleaseExclusion handles DELETE /api/release-exclusions/{id}
+func (s *Server) handleReleaseExclusion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/release-exclusions/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	exclusion, err := s.db.GetReleaseExclusion(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if exclusion == nil {
+		httpError(w, "Release exclusion not found", http.StatusNotFound)
+		return
+	}
+	if err := s.db.RemoveReleaseExclusion(id); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markExcludedReleases rejects the search results excluded for a movie or
+// show, giving the exclusion as the reason
+func (s *Server) markExcludedReleases(results []indexer.ScoredSearchResult, mediaType string, tmdbID int64) {
+	exclusions, err := s.db.GetReleaseExclusions(mediaType, tmdbID)
+	if err != nil || len(exclusions) == 0 {
+		return
+	}
+	for i := range results {
+		for _, exclusion := range exclusions {
+			if exclusion.Matches(results[i].Title, results[i].ReleaseGroup) {
+				results[i].Rejected = true
+				results[i].RejectionReason = exclusion.Describe()
+				break
+			}
+		}
+	}
+}
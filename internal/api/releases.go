@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/outpost/outpost/internal/parser"
+	"github.com/outpost/outpost/internal/quality"
+)
+
+// Release parsing handlers
+
+// maxBulkParseNames caps how many release names one bulk parse request scores
+const maxBulkParseNames = 500
+
+// bulkParseResult is one release name parsed and scored against a profile
+type bulkParseResult struct {
+	*parser.ParsedRelease
+	Name             string                    `json:"name"`
+	Quality          string                    `json:"quality"`
+	BaseScore        int                       `json:"baseScore"`
+	TotalScore       int                       `json:"totalScore"`
+	CustomFormatHits []quality.CustomFormatHit `json:"customFormatHits"`
+	Rejected         bool                      `json:"rejected"`
+	RejectionReason  string                    `json:"rejectionReason,omitempty"`
+}
+
+// loadScoringProfile loads a quality profile and every custom format for
+// scoring releases with quality.ScoreRelease
+func (s *Server) loadScoringProfile(profileID int64) (*quality.Profile, []quality.CustomFormatDef, error) {
+	dbProfile, err := s.db.GetQualityProfile(profileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	qualities, _ := quality.ParseQualities(dbProfile.Qualities)
+	scores, _ := quality.ParseCustomFormatScores(dbProfile.CustomFormatScores)
+	profile := &quality.Profile{
+		ID:                 dbProfile.ID,
+		Name:               dbProfile.Name,
+		UpgradeAllowed:     dbProfile.UpgradeAllowed,
+		UpgradeUntilScore:  dbProfile.UpgradeUntilScore,
+		MinFormatScore:     dbProfile.MinFormatScore,
+		CutoffFormatScore:  dbProfile.CutoffFormatScore,
+		Qualities:          qualities,
+		CustomFormatScores: scores,
+	}
+
+	dbFormats, err := s.db.GetCustomFormats()
+	if err != nil {
+		return nil, nil, err
+	}
+	var customFormats []quality.CustomFormatDef
+	for _, f := range dbFormats {
+		conditions, _ := quality.ParseConditions(f.Conditions)
+		customFormats = append(customFormats, quality.CustomFormatDef{
+			ID:         f.ID,
+			Name:       f.Name,
+			Conditions: conditions,
+		})
+	}
+	return profile, customFormats, nil
+}
+
+// handleParseReleaseBulk handles POST /api/releases/parse/bulk - parses a list
+// of release names and scores them against a profile, so custom formats can be
+// checked against real indexer results before auto-grab is enabled
+func (s *Server) handleParseReleaseBulk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Names     []string `json:"names"`
+		ProfileID int64    `json:"profileId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	}
+
+	var names []string
+	for _, name := range req.Names {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		httpError(w, "At least one name is required", http.StatusBadRequest)
+		return
+	}
+	if len(names) > maxBulkParseNames {
+		httpError(w, "Too many names, the limit is 500", http.StatusBadRequest)
+		return
+	}
+
+	var profile *quality.Profile
+	var customFormats []quality.CustomFormatDef
+	if req.ProfileID > 0 {
+		var err error
+		profile, customFormats, err = s.loadScoringProfile(req.ProfileID)
+		if err != nil {
+			httpError(w, "Profile not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	results := make([]bulkParseResult, 0, len(names))
+	formatHits := make(map[string]int)
+	accepted := 0
+	for _, name := range names {
+		parsed := parser.Parse(name)
+		result := bulkParseResult{
+			ParsedRelease:    parsed,
+			Name:             name,
+			Quality:          quality.ComputeQualityTier(parsed),
+			CustomFormatHits: []quality.CustomFormatHit{},
+		}
+
+		if profile != nil {
+			scored := quality.ScoreRelease(parsed, profile, customFormats)
+			result.BaseScore = scored.BaseScore
+			result.TotalScore = scored.TotalScore
+			result.CustomFormatHits = scored.CustomFormatHits
+			result.Rejected = scored.Rejected
+			result.RejectionReason = scored.RejectionReason
+			for _, hit := range scored.CustomFormatHits {
+				formatHits[hit.Name]++
+			}
+		} else {
+			result.BaseScore = quality.BaseQualityScores[result.Quality]
+			result.TotalScore = result.BaseScore
+		}
+
+		if parsed.ShouldBlock() && !result.Rejected {
+			result.Rejected = true
+			result.RejectionReason = parsed.BlockReason()
+		}
+		if !result.Rejected {
+			accepted++
+		}
+		results = append(results, result)
+	}
+
+	response := map[string]interface{}{
+		"results":    results,
+		"total":      len(results),
+		"accepted":   accepted,
+		"rejected":   len(results) - accepted,
+		"formatHits": formatHits,
+	}
+	if profile != nil {
+		response["profile"] = profile
+	}
+	json.NewEncoder(w).Encode(response)
+}
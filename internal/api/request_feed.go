@@ -0,0 +1,298 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Request feed
+//
+// /api/requests/feed lists requests that were approved, started downloading
+// or became available, most recent change first, as a JSON Feed or, with
+// ?format=rss, RSS. Each status a request reaches is its own item, so feed
+// readers and scripts see every step. Like the requests API it takes a
+// session or API key; since most feed readers can't send headers, the key
+// can also be given as ?apikey=. Admins see every request, others their own.
+
+const (
+	defaultRequestFeedLimit = 50
+	maxRequestFeedLimit     = 200
+)
+
+// requestFeedStatuses are the statuses the feed reports, with how each is
+// described in item titles
+var requestFeedStatuses = map[string]string{
+	"approved":   "Approved",
+	"processing": "Downloading",
+	"available":  "Available",
+}
+
+// requireFeedAuth lets a feed be authenticated with an apikey query
+// parameter, then hands it to requireAuth
+func (s *Server) requireFeedAuth(next http.HandlerFunc) http.HandlerFunc {
+	auth := s.requireAuth(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get("apikey"); key != "" && r.Header.Get(apiKeyHeader) == "" {
+			r.Header.Set(apiKeyHeader, key)
+		}
+		auth(w, r)
+	}
+}
+
+// handleRequestFeed handles GET /api/requests/feed[?format=json|rss]
+// [&status=approved,available][&since=RFC 3339 time][&limit=n]
+func (s *Server) handleRequestFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := s.getCurrentUser(r)
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "rss" {
+		httpError(w, "format must be json or rss", http.StatusBadRequest)
+		return
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status == "" {
+			continue
+		}
+		if _, ok := requestFeedStatuses[status]; !ok {
+			httpError(w, "status must be approved, processing or available", http.StatusBadRequest)
+			return
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		statuses = []string{"approved", "processing", "available"}
+	}
+
+	var since time.Time
+	if v := query.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			httpError(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	limit := defaultRequestFeedLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxRequestFeedLimit {
+			n = maxRequestFeedLimit
+		}
+		limit = n
+	}
+
+	var userID int64
+	if user.Role != "admin" {
+		userID = user.ID
+	}
+	requests, err := s.db.GetRequestFeed(userID, statuses, since, limit)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := s.publicBaseURL(r)
+	if format == "rss" {
+		writeRequestRSS(w, base, requests)
+		return
+	}
+	writeRequestJSONFeed(w, base, requests)
+}
+
+// requestFeedItemID identifies a request at one status, so each change is
+// a new item
+func requestFeedItemID(req *database.Request) string {
+	return fmt.Sprintf("outpost-request-%d-%s", req.ID, req.Status)
+}
+
+func requestFeedTitle(req *database.Request) string {
+	title := req.Title
+	if req.Year > 0 {
+		title = fmt.Sprintf("%s (%d)", req.Title, req.Year)
+	}
+	return requestFeedStatuses[req.Status] + ": " + title
+}
+
+// requestFeedLink is the title's page in the web interface
+func requestFeedLink(base string, req *database.Request) string {
+	kind := "movie"
+	if req.Type != "movie" {
+		kind = "show"
+	}
+	return fmt.Sprintf("%s/explore/%s/%d", base, kind, req.TmdbID)
+}
+
+// requestFeedRequester names who asked for a request
+func requestFeedRequester(req *database.Request) string {
+	if req.RequesterName != nil && *req.RequesterName != "" {
+		return *req.RequesterName
+	}
+	return req.Username
+}
+
+func requestFeedText(req *database.Request) string {
+	text := "Requested by " + requestFeedRequester(req)
+	if req.Overview != nil && *req.Overview != "" {
+		text += "\n\n" + *req.Overview
+	}
+	return text
+}
+
+func requestFeedImage(req *database.Request) string {
+	if req.PosterPath == nil {
+		return ""
+	}
+	return tmdb.ImageURL(*req.PosterPath, "w500")
+}
+
+// jsonFeed is a JSON Feed 1.1 document
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	Title         string          `json:"title"`
+	ContentText   string          `json:"content_text"`
+	Image         string          `json:"image,omitempty"`
+	DatePublished time.Time       `json:"date_published"`
+	Tags          []string        `json:"tags"`
+	Request       requestFeedInfo `json:"_outpost"`
+}
+
+// requestFeedInfo carries the request's details for scripts
+type requestFeedInfo struct {
+	RequestID   int64     `json:"requestId"`
+	Status      string    `json:"status"`
+	Type        string    `json:"type"`
+	TmdbID      int64     `json:"tmdbId"`
+	Title       string    `json:"title"`
+	Year        int       `json:"year,omitempty"`
+	RequestedBy string    `json:"requestedBy"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+func writeRequestJSONFeed(w http.ResponseWriter, base string, requests []database.Request) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Outpost requests",
+		HomePageURL: base,
+		Items:       make([]jsonFeedItem, 0, len(requests)),
+	}
+	for i := range requests {
+		req := &requests[i]
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            requestFeedItemID(req),
+			URL:           requestFeedLink(base, req),
+			Title:         requestFeedTitle(req),
+			ContentText:   requestFeedText(req),
+			Image:         requestFeedImage(req),
+			DatePublished: req.UpdatedAt.UTC(),
+			Tags:          []string{req.Status, req.Type},
+			Request: requestFeedInfo{
+				RequestID:   req.ID,
+				Status:      req.Status,
+				Type:        req.Type,
+				TmdbID:      req.TmdbID,
+				Title:       req.Title,
+				Year:        req.Year,
+				RequestedBy: requestFeedRequester(req),
+				RequestedAt: req.RequestedAt.UTC(),
+			},
+		})
+	}
+	w.Header().Set("Content-Type", "application/feed+json")
+	json.NewEncoder(w).Encode(feed)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+func writeRequestRSS(w http.ResponseWriter, base string, requests []database.Request) {
+	doc := rssDocument{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       "Outpost requests",
+			Link:        base,
+			Description: "Approved and newly available requests",
+		},
+	}
+	for i := range requests {
+		req := &requests[i]
+		item := rssItem{
+			Title:       requestFeedTitle(req),
+			Link:        requestFeedLink(base, req),
+			Description: requestFeedText(req),
+			GUID:        rssGUID{Value: requestFeedItemID(req)},
+			PubDate:     req.UpdatedAt.UTC().Format(time.RFC1123Z),
+			Categories:  []string{req.Status, req.Type},
+		}
+		if image := requestFeedImage(req); image != "" {
+			item.Enclosure = &rssEnclosure{URL: image, Type: "image/jpeg"}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(doc)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Request IDs
+//
+// Every request gets an ID, returned in the X-Request-ID header and attached
+// to whatever is logged while handling it, so a response can be matched to
+// its log lines. A client or proxy can pass its own ID in the same header.
+
+// requestIDHeader carries a request's ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestID bounds IDs taken from clients
+const maxRequestID = 64
+
+// withRequestID gives each request an ID before handing it on
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether a client's ID is safe to log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestLog returns the logger for a request, which tags what's logged with
+// its ID
+func requestLog(r *http.Request) *logging.Logger {
+	return logger.Ctx(r.Context())
+}
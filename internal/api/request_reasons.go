@@ -0,0 +1,121 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Request denial reasons
+
+// validateRequestReason checks a denial reason, defaulting its message to
+// its label
+func validateRequestReason(reason *database.RequestReason) string {
+	reason.Label = strings.TrimSpace(reason.Label)
+	reason.Message = strings.TrimSpace(reason.Message)
+	if reason.Label == "" {
+		return "label is required"
+	}
+	if reason.Message == "" {
+		reason.Message = reason.Label
+	}
+	return ""
+}
+
+// handleRequestReasons lists and creates the reusable denial reasons
+func (s *Server) handleRequestReasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		reasons, err := s.db.GetRequestReasons()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(reasons)
+
+	case http.MethodPost:
+		var reason database.RequestReason
+		if err := json.NewDecoder(r.Body).Decode(&reason); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if msg := validateRequestReason(&reason); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if err := s.db.CreateRequestReason(&reason); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(reason)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRequestReason updates or deletes a denial reason
+func (s *Server) handleRequestReason(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/settings/request-reasons/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reason, err := s.db.GetRequestReason(id)
+		if err != nil {
+			httpError(w, "Reason not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(reason)
+
+	case http.MethodPut:
+		var reason database.RequestReason
+		if err := json.NewDecoder(r.Body).Decode(&reason); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		reason.ID = id
+		if msg := validateRequestReason(&reason); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
+		if err := s.db.UpdateRequestReason(&reason); err == sql.ErrNoRows {
+			httpError(w, "Reason not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		updated, err := s.db.GetRequestReason(id)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		if err := s.db.DeleteRequestReason(id); err == sql.ErrNoRows {
+			httpError(w, "Reason not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
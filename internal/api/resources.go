@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/netaccess"
+	"github.com/outpost/outpost/internal/sysmon"
+)
+
+// Transcode admission
+//
+// A new transcode waits while the host's CPU, memory or every GPU is busier
+// than its transcode_max_* setting, for up to transcode_busy_wait_seconds,
+// and is then turned away with "server busy" instead of slowing down every
+// stream already running. Direct play and direct streams don't transcode and
+// are always let through.
+
+// busyRetryAfter is the Retry-After clients get when the server is busy
+const busyRetryAfter = 30 * time.Second
+
+// handleSystemResources handles GET /api/system/resources, the host's latest
+// CPU, memory and GPU use, the busy limits and whether new transcodes are
+// being turned away
+func (s *Server) handleSystemResources(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limits := s.transcodeLimits()
+	saturated := s.resources.Saturated(limits)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resources":  s.resources.Latest(),
+		"limits":     limits,
+		"saturated":  saturated != "",
+		"busy":       saturated,
+		"transcodes": len(s.transcodes.Sessions()),
+	})
+}
+
+// transcodeLimits reads the busy limits from settings
+func (s *Server) transcodeLimits() sysmon.Limits {
+	percent := func(key string) float64 {
+		v, _ := s.db.GetSetting(key)
+		n, _ := strconv.Atoi(v)
+		return float64(n)
+	}
+	return sysmon.Limits{
+		CPU:    percent("transcode_max_cpu"),
+		Memory: percent("transcode_max_memory"),
+		GPU:    percent("transcode_max_gpu"),
+	}
+}
+
+// admitTranscode waits for the host to have room for a new transcode,
+// answering 503 and returning false if it doesn't in time or the client
+// goes away
+func (s *Server) admitTranscode(w http.ResponseWriter, r *http.Request) bool {
+	limits := s.transcodeLimits()
+	busy := s.resources.Saturated(limits)
+	if busy == "" {
+		return true
+	}
+
+	wait := 0
+	if v, err := s.db.GetSetting("transcode_busy_wait_seconds"); err == nil {
+		wait, _ = strconv.Atoi(v)
+	}
+	if wait > 0 {
+		logger.Infof("Server busy (%s), queuing transcode for up to %ds", busy, wait)
+		deadline := time.NewTimer(time.Duration(wait) * time.Second)
+		defer deadline.Stop()
+		// Samples only change every SampleInterval, so checking more often
+		// doesn't help
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+	queue:
+		for {
+			select {
+			case <-r.Context().Done():
+				return false
+			case <-deadline.C:
+				break queue
+			case <-ticker.C:
+				if busy = s.resources.Saturated(limits); busy == "" {
+					return true
+				}
+			}
+		}
+	}
+
+	logger.Warnf("Server busy (%s), turning away transcode from %s", busy, netaccess.ClientIP(r))
+	writeServerBusy(w, r, busy)
+	return false
+}
+
+// writeServerBusy answers 503 with which resource is busy and a message in
+// the client's language
+func writeServerBusy(w http.ResponseWriter, r *http.Request, resource string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(busyRetryAfter.Seconds())))
+	writeErrorDetails(w, http.StatusServiceUnavailable, codeServerBusy,
+		i18n.T(requestLanguage(r, nil), "Server busy, try again later"),
+		map[string]string{"resource": resource})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Library scan throttle
+
+// handleLibraryScanThrottle handles /api/libraries/{id}/scan-throttle:
+// whether a library on network storage is scanned with the scan throttle,
+// and the throttle it gets
+func (s *Server) handleLibraryScanThrottle(w http.ResponseWriter, r *http.Request, libraryID int64) {
+	lib, err := s.db.GetLibrary(libraryID)
+	if err != nil {
+		httpError(w, "Library not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			ScanThrottle bool `json:"scanThrottle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if err := s.db.UpdateLibraryScanThrottle(libraryID, req.ScanThrottle); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lib.ScanThrottle = req.ScanThrottle
+		s.syncWatcher()
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"libraryId":    libraryID,
+		"scanThrottle": lib.ScanThrottle,
+		"throttle":     s.scanner.GetThrottle(),
+	})
+}
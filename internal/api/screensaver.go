@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Screensaver
+//
+// GET /api/screensaver gives TV clients a slideshow of backdrops from the
+// library the viewer can see, each with its title and, when TMDB has one, its
+// title logo. The set is picked at random and kept for the refresh interval
+// (a setting), so every screen a viewer has shows the same rotation and
+// title logos are only looked up once per set.
+
+const (
+	defaultScreensaverRefresh = 10 // minutes
+	maxScreensaverRefresh     = 24 * 60
+	defaultScreensaverItems   = 20
+	maxScreensaverItems       = 50
+)
+
+// screensaverItem is one slide
+type screensaverItem struct {
+	Type     string   `json:"type"` // movie, show
+	ID       int64    `json:"id"`
+	Title    string   `json:"title"`
+	Year     int      `json:"year,omitempty"`
+	Backdrop string   `json:"backdrop"`
+	Logo     string   `json:"logo,omitempty"`
+	FocalX   *float64 `json:"focalX,omitempty"`
+	FocalY   *float64 `json:"focalY,omitempty"`
+	Colors   *string  `json:"colors,omitempty"`
+}
+
+// screensaverSet is a viewer's current slides
+type screensaverSet struct {
+	items     []screensaverItem
+	builtAt   time.Time
+	expiresAt time.Time
+}
+
+// validateScreensaverSetting checks the screensaver settings. Other settings
+// are always valid.
+func validateScreensaverSetting(key, value string) error {
+	if key == "screensaver_refresh_minutes" {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > maxScreensaverRefresh {
+			return fmt.Errorf("Screensaver refresh must be between 1 and %d minutes", maxScreensaverRefresh)
+		}
+	}
+	return nil
+}
+
+// handleScreensaver handles GET /api/screensaver?limit=
+func (s *Server) handleScreensaver(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultScreensaverItems
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxScreensaverItems {
+			httpError(w, fmt.Sprintf("limit must be between 1 and %d", maxScreensaverItems), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	set, err := s.screensaverSlides(r, user)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items := set.items
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	// Clients can hold on to the set until it's replaced
+	remaining := int(time.Until(set.expiresAt).Seconds())
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", max(remaining, 0)))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":           items,
+		"refreshInterval": int(set.expiresAt.Sub(set.builtAt).Seconds()),
+		"generatedAt":     set.builtAt,
+		"expiresAt":       set.expiresAt,
+	})
+}
+
+// screensaverSlides returns the viewer's current set, picking a new one when
+// it expired
+func (s *Server) screensaverSlides(r *http.Request, user *database.User) (*screensaverSet, error) {
+	// Which items a viewer sees depends on the user and whether a PIN
+	// elevation lifts their content limit
+	key := fmt.Sprintf("%d:%t", user.ID, s.getElevationToken(r) != "")
+
+	s.screensaversMu.Lock()
+	cached := s.screensavers[key]
+	s.screensaversMu.Unlock()
+	if cached != nil && time.Now().Before(cached.expiresAt) {
+		return cached, nil
+	}
+
+	items, err := s.pickScreensaverItems(r, user)
+	if err != nil {
+		return nil, err
+	}
+	refresh := s.homeSettingInt("screensaver_refresh_minutes", defaultScreensaverRefresh)
+	if refresh > maxScreensaverRefresh {
+		refresh = maxScreensaverRefresh
+	}
+	now := time.Now()
+	set := &screensaverSet{
+		items:     items,
+		builtAt:   now,
+		expiresAt: now.Add(time.Duration(refresh) * time.Minute),
+	}
+
+	s.screensaversMu.Lock()
+	s.screensavers[key] = set
+	s.screensaversMu.Unlock()
+	return set, nil
+}
+
+// pickScreensaverItems picks random movies and shows with a backdrop from
+// the viewer's library and looks up their title logos
+func (s *Server) pickScreensaverItems(r *http.Request, user *database.User) ([]screensaverItem, error) {
+	type candidate struct {
+		item   screensaverItem
+		tmdbID *int64
+	}
+	var candidates []candidate
+
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range movies {
+		if m.BackdropPath == nil || *m.BackdropPath == "" {
+			continue
+		}
+		if !user.CanAccessLibrary(m.LibraryID) || !s.isContentAllowed(user, m.ContentRating, r) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			item: screensaverItem{Type: "movie", ID: m.ID, Title: m.Title, Year: m.Year, Backdrop: *m.BackdropPath,
+				FocalX: m.FocalX, FocalY: m.FocalY, Colors: m.Colors},
+			tmdbID: m.TmdbID,
+		})
+	}
+
+	shows, err := s.db.GetShows()
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shows {
+		if sh.BackdropPath == nil || *sh.BackdropPath == "" {
+			continue
+		}
+		if !user.CanAccessLibrary(sh.LibraryID) || !s.isContentAllowed(user, sh.ContentRating, r) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			item: screensaverItem{Type: "show", ID: sh.ID, Title: sh.Title, Year: sh.Year, Backdrop: *sh.BackdropPath,
+				FocalX: sh.FocalX, FocalY: sh.FocalY, Colors: sh.Colors},
+			tmdbID: sh.TmdbID,
+		})
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > maxScreensaverItems {
+		candidates = candidates[:maxScreensaverItems]
+	}
+
+	items := make([]screensaverItem, 0, len(candidates))
+	for _, c := range candidates {
+		if c.tmdbID != nil {
+			c.item.Logo = s.metadata.TitleLogo(c.item.Type, *c.tmdbID)
+		}
+		items = append(items, c.item)
+	}
+	return items, nil
+}
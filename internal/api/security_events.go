@@ -0,0 +1,188 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/netaccess"
+	"github.com/outpost/outpost/internal/notification"
+)
+
+// Security event notifications
+
+// loginFailureWindow is how far back failed logins count towards the limit
+const loginFailureWindow = 15 * time.Minute
+
+// defaultLoginFailureLimit is used when security_login_failure_limit isn't set
+const defaultLoginFailureLimit = 5
+
+// loginFailureTracker counts recent failed logins per address and per
+// username, so spread-out attempts on one account are caught as well as
+// attempts from one address
+type loginFailureTracker struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func newLoginFailureTracker() *loginFailureTracker {
+	return &loginFailureTracker{failures: make(map[string][]time.Time)}
+}
+
+// add records a failure for a key and returns how many failures the key has
+// had within the window
+func (t *loginFailureTracker) add(key string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent := t.failures[key][:0]
+	for _, at := range t.failures[key] {
+		if now.Sub(at) < loginFailureWindow {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	t.failures[key] = recent
+
+	// Drop keys that have gone quiet so the map doesn't grow forever
+	for k, times := range t.failures {
+		if now.Sub(times[len(times)-1]) >= loginFailureWindow {
+			delete(t.failures, k)
+		}
+	}
+	return len(recent)
+}
+
+// notifySecurity sends a security event notification in the background
+func (s *Server) notifySecurity(event string, title, message i18n.Message) {
+	if s.notifications == nil {
+		return
+	}
+	go s.notifications.NotifySecurityEvent(event, title, message)
+}
+
+// recordLoginFailure counts a failed login, notifying admins when an address
+// or username reaches the failure limit
+func (s *Server) recordLoginFailure(r *http.Request, username string) {
+	limit := defaultLoginFailureLimit
+	if v, err := s.db.GetSetting("security_login_failure_limit"); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	ip := netaccess.ClientIP(r)
+	now := time.Now()
+	// Only the attempt that reaches the limit notifies, once per burst
+	if s.loginFailures.add("ip:"+ip, now) == limit {
+		s.notifySecurity(notification.SecurityFailedLogins, i18n.M("Repeated Failed Logins"),
+			i18n.M("%d failed sign-ins from %s in the last %d minutes (last tried username %q)",
+				limit, ip, int(loginFailureWindow.Minutes()), username))
+	}
+	if username != "" && s.loginFailures.add("user:"+strings.ToLower(username), now) == limit {
+		s.notifySecurity(notification.SecurityFailedLogins, i18n.M("Repeated Failed Logins"),
+			i18n.M("%d failed sign-ins for %q in the last %d minutes (last from %s)",
+				limit, username, int(loginFailureWindow.Minutes()), ip))
+	}
+}
+
+// recordLoginDevice remembers the device an admin signed in from, notifying
+// admins when it hasn't been seen before
+func (s *Server) recordLoginDevice(r *http.Request, user *database.User) {
+	if user.Role != "admin" {
+		return
+	}
+	ip := netaccess.ClientIP(r)
+	userAgent := r.UserAgent()
+	isNew, seenBefore, err := s.db.TouchLoginDevice(user.ID, userAgent, ip)
+	if err != nil {
+		requestLog(r).Errorf("Failed to record login device for %s: %v", user.Username, err)
+		return
+	}
+	if isNew && seenBefore {
+		var device interface{} = userAgent
+		if userAgent == "" {
+			device = i18n.M("unknown browser")
+		}
+		s.notifySecurity(notification.SecurityNewDevice, i18n.M("New Admin Sign-in"),
+			i18n.M("Admin %s signed in from a new device: %s at %s", user.Username, device, ip))
+	}
+}
+
+// isAPIKeySetting reports whether a setting holds an API key
+func isAPIKeySetting(key string) bool {
+	return strings.HasSuffix(key, "api_key")
+}
+
+// isAuthSetting reports whether a setting affects who can sign in or how:
+// network access rules and trusted proxies, the mail server used for
+// password resets, the request portal, the Radarr and Sonarr API and the
+// security notifications themselves
+func isAuthSetting(key string) bool {
+	return strings.HasPrefix(key, "access_") || strings.HasPrefix(key, "smtp_") ||
+		strings.HasPrefix(key, "security_") || strings.HasPrefix(key, "portal_") ||
+		key == "arr_api_enabled" || key == "public_url" || key == netaccess.SettingTrustedProxies
+}
+
+// notifySettingsChanges notifies admins of API keys and auth settings that
+// a settings update changed. Only setting names are included, never values.
+func (s *Server) notifySettingsChanges(r *http.Request, previous, data map[string]string) {
+	var apiKeys, authKeys []string
+	for key, value := range data {
+		if previous[key] == value {
+			continue
+		}
+		if isAPIKeySetting(key) && value != "" {
+			apiKeys = append(apiKeys, key)
+		} else if isAuthSetting(key) {
+			authKeys = append(authKeys, key)
+		}
+	}
+	sort.Strings(apiKeys)
+	sort.Strings(authKeys)
+
+	by := s.actorName(r)
+	if len(apiKeys) > 0 {
+		s.notifySecurity(notification.SecurityAPIKey, i18n.M("API Key Changed"),
+			i18n.M("%s set %s", by, strings.Join(apiKeys, ", ")))
+	}
+	if len(authKeys) > 0 {
+		s.notifySecurity(notification.SecuritySettings, i18n.M("Security Settings Changed"),
+			i18n.M("%s changed %s", by, strings.Join(authKeys, ", ")))
+	}
+}
+
+// notifyIndexerChange notifies admins that an indexer was added, changed or
+// removed, and separately when its API key was set
+func (s *Server) notifyIndexerChange(r *http.Request, action, name string, apiKeySet bool) {
+	by := s.actorName(r)
+	if apiKeySet {
+		s.notifySecurity(notification.SecurityAPIKey, i18n.M("API Key Changed"),
+			i18n.M("%s set the API key for indexer %q", by, name))
+	}
+	var message i18n.Message
+	switch action {
+	case "added":
+		message = i18n.M("%s added indexer %q", by, name)
+	case "removed":
+		message = i18n.M("%s removed indexer %q", by, name)
+	case "configured":
+		message = i18n.M("%s configured indexer %q", by, name)
+	default:
+		message = i18n.M("%s changed indexer %q", by, name)
+	}
+	s.notifySecurity(notification.SecuritySettings, i18n.M("Indexers Changed"), message)
+}
+
+// actorName names the user making a request, for notification messages
+func (s *Server) actorName(r *http.Request) string {
+	if user := s.getCurrentUser(r); user != nil {
+		return user.Username
+	}
+	return "Someone"
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,24 +16,40 @@ import (
 	"time"
 
 	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/config"
 	"github.com/outpost/outpost/internal/database"
 	"github.com/outpost/outpost/internal/download"
+	"github.com/outpost/outpost/internal/email"
 	"github.com/outpost/outpost/internal/health"
+	"github.com/outpost/outpost/internal/i18n"
+	importpkg "github.com/outpost/outpost/internal/import"
 	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/downloadclient"
 	"github.com/outpost/outpost/internal/indexer"
+	"github.com/outpost/outpost/internal/jobs"
 	"github.com/outpost/outpost/internal/metadata"
+	"github.com/outpost/outpost/internal/netaccess"
 	"github.com/outpost/outpost/internal/prowlarr"
 	"github.com/outpost/outpost/internal/parser"
+	"github.com/outpost/outpost/internal/podcast"
 	"github.com/outpost/outpost/internal/quality"
 	"github.com/outpost/outpost/internal/scanner"
+	"github.com/outpost/outpost/internal/scheduler"
 	"github.com/outpost/outpost/internal/storage"
 	"github.com/outpost/outpost/internal/subtitles"
+	"github.com/outpost/outpost/internal/sysmon"
+	"github.com/outpost/outpost/internal/timezone"
 	"github.com/outpost/outpost/internal/tmdb"
+	"github.com/outpost/outpost/internal/trailer"
 	"github.com/outpost/outpost/internal/trakt"
+	"github.com/outpost/outpost/internal/transcode"
+	"github.com/outpost/outpost/internal/trash"
 )
 
+var logger = logging.Module("api")
+
 type contextKey string
 
 const userContextKey contextKey = "user"
@@ -51,9 +67,33 @@ type Server struct {
 	acquisition   AcquisitionService
 	notifications NotificationService
 	healthChecker *health.Checker
+	mailer        *email.Sender
 	mux           *http.ServeMux
 	subtitleCache map[string][]byte
 	subtitleMu    sync.RWMutex
+
+	snapshots   map[string]*cachedSnapshot // Library snapshots by viewer
+	snapshotsMu sync.Mutex
+
+	screensavers   map[string]*screensaverSet // Screensaver backdrops by viewer
+	screensaversMu sync.Mutex
+
+	transcodes *transcode.Manager // Running transcoded streams
+	resources  *sysmon.Monitor    // Host CPU, memory and GPU use, for turning away transcodes
+	podcasts   *podcast.Service
+	images     *blobstore.Switch // Cached artwork
+	backups    *blobstore.Switch // Saved backups
+	trailers   *trailer.Resolver // YouTube trailers resolved for clients that can't embed them
+	trash      *trash.Bin        // Where deleted movie and episode files go until they expire
+	jobs       *jobs.Manager     // Background jobs like scans and metadata refreshes
+	watcher    *scanner.Watcher  // Imports files as they change in library folders
+	avatarDir  string            // Where uploaded profile avatars are saved
+
+	jellyfinIDOnce sync.Once
+	jellyfinID     string // Server ID reported to Jellyfin apps
+
+	loginFailures *loginFailureTracker // Recent failed logins, for security notifications
+	maintenance   *maintenanceMode     // Maintenance mode and the streams draining through it
 }
 
 // Scheduler interface for task management
@@ -64,6 +104,10 @@ type Scheduler interface {
 	SearchWantedItem(tmdbID int64, mediaType string) error
 	GetActiveSearch() string
 	GetRunningTaskNames() []string
+	TaskStartedAt(name string) (time.Time, bool)
+	Start()
+	Stop()
+	SimulatePreset(presetID int64, mediaType string, tmdbID int64, title string, year int) (*scheduler.PresetSimulation, error)
 }
 
 // AcquisitionService interface for download tracking
@@ -71,6 +115,12 @@ type AcquisitionService interface {
 	GetActiveDownloads() ([]*download.TrackedDownload, error)
 	GetTrackedDownload(id int64) (*download.TrackedDownload, error)
 	DeleteTrackedDownload(id int64, deleteFromClient bool, deleteFiles bool) error
+	ManualImport(td *download.TrackedDownload, mediaID *int64, mediaType string) error
+	IgnoreImport(td *download.TrackedDownload) error
+	RetryImportStep(td *download.TrackedDownload, step int) error
+	RefreshRequestETAs()
+	Start()
+	Stop()
 }
 
 // NotificationService interface for in-app notifications
@@ -87,6 +137,9 @@ type NotificationService interface {
 	NotifyRequestDenied(userID int64, title string, reason string, posterPath *string) error
 	NotifyDownloadComplete(title string, mediaType string, mediaID int64, posterPath *string) error
 	NotifyDownloadFailed(title string, errorMsg string, posterPath *string) error
+	NotifySecurityEvent(event string, title, message i18n.Message) error
+	NotifyHealthFailure(check string, message i18n.Message) error
+	TestProvider(p *database.NotificationProvider) error
 }
 
 func NewServer(cfg *config.Config, db *database.Database, scan *scanner.Scanner, meta *metadata.Service, authSvc *auth.Service, downloads *downloadclient.Manager, indexers *indexer.Manager, sched Scheduler, acq AcquisitionService, notif NotificationService) *Server {
@@ -102,9 +155,27 @@ func NewServer(cfg *config.Config, db *database.Database, scan *scanner.Scanner,
 		acquisition:   acq,
 		notifications: notif,
 		healthChecker: health.NewChecker(db, downloads, indexers),
+		mailer:        email.New(db),
 		mux:           http.NewServeMux(),
 		subtitleCache: make(map[string][]byte),
-	}
+		snapshots:     make(map[string]*cachedSnapshot),
+		screensavers:  make(map[string]*screensaverSet),
+		transcodes:    transcode.NewManager(),
+		resources:     sysmon.NewMonitor(),
+		loginFailures: newLoginFailureTracker(),
+		maintenance:   newMaintenanceMode(),
+		trailers:      trailer.NewResolver(filepath.Join(filepath.Dir(cfg.DBPath), "trailers")),
+		trash:         trash.New(db, filepath.Join(filepath.Dir(cfg.DBPath), "trash")),
+		avatarDir:     filepath.Join(filepath.Dir(cfg.DBPath), "avatars"),
+		jobs:          jobs.New(db),
+		watcher:       scanner.NewWatcher(scan),
+	}
+	// The scanner's progress is shared, so libraries scan one at a time
+	s.jobs.SetLimit(jobScan, 1)
+	s.jobs.SetLimit(jobMetadataRefresh, 1)
+	s.healthChecker.SetNotifier(notif)
+	s.resources.Start()
+	s.loadMaintenance()
 	s.setupRoutes()
 	s.loadIndexers()
 	return s
@@ -113,10 +184,10 @@ func NewServer(cfg *config.Config, db *database.Database, scan *scanner.Scanner,
 func (s *Server) loadIndexers() {
 	indexers, err := s.db.GetEnabledIndexers()
 	if err != nil {
-		log.Printf("Error loading indexers from database: %v", err)
+		logger.Errorf("Error loading indexers from database: %v", err)
 		return
 	}
-	log.Printf("Found %d enabled indexers in database", len(indexers))
+	logger.Infof("Found %d enabled indexers in database", len(indexers))
 	for _, idx := range indexers {
 		config := &indexer.IndexerConfig{
 			ID:         idx.ID,
@@ -129,9 +200,9 @@ func (s *Server) loadIndexers() {
 			Enabled:    idx.Enabled,
 		}
 		if err := s.indexers.AddIndexer(config); err != nil {
-			log.Printf("Failed to add indexer %s: %v", idx.Name, err)
+			logger.Errorf("Failed to add indexer %s: %v", idx.Name, err)
 		} else {
-			log.Printf("Added indexer: %s (type=%s, url=%s)", idx.Name, idx.Type, idx.URL)
+			logger.Infof("Added indexer: %s (type=%s, url=%s)", idx.Name, idx.Type, idx.URL)
 		}
 	}
 }
@@ -140,7 +211,7 @@ func (s *Server) loadIndexers() {
 func (s *Server) reloadIndexers() {
 	s.indexers.Clear()
 	s.loadIndexers()
-	log.Printf("Reloaded %d indexers into manager", s.indexers.Count())
+	logger.Infof("Reloaded %d indexers into manager", s.indexers.Count())
 }
 
 func (s *Server) setupRoutes() {
@@ -153,6 +224,8 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/auth/me", s.handleMe)
 	s.mux.HandleFunc("/api/auth/setup", s.handleSetup)
 	s.mux.HandleFunc("/api/auth/verify-pin", s.requireAuth(s.handleVerifyPin))
+	s.mux.HandleFunc("/api/auth/forgot-password", s.handleForgotPassword)
+	s.mux.HandleFunc("/api/auth/reset-password", s.handleResetPassword)
 
 	// Setup wizard routes (admin only after initial setup)
 	s.mux.HandleFunc("/api/setup/status", s.handleSetupStatus)
@@ -162,14 +235,51 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/users", s.requireAdmin(s.handleUsers))
 	s.mux.HandleFunc("/api/users/", s.requireAdmin(s.handleUser))
 
+	// Account data routes (authenticated, for the caller's own account)
+	s.mux.HandleFunc("/api/account", s.requireAuth(s.handleAccount))
+	s.mux.HandleFunc("/api/account/export", s.requireAuth(s.handleAccountExport))
+
+	// Home screen routes (admins manage pins) and the household activity feed
+	s.mux.HandleFunc("/api/home", s.requireAuth(s.handleHome))
+	s.mux.HandleFunc("/api/home/pins", s.requireAdmin(s.handleHomePins))
+	s.mux.HandleFunc("/api/home/pins/", s.requireAdmin(s.handleHomePin))
+	s.mux.HandleFunc("/api/activity/feed", s.requireAuth(s.handleActivityFeed))
+	s.mux.HandleFunc("/api/screensaver", s.requireAuth(s.handleScreensaver))
+
+	// Invite routes (admin manages invites, registration is public)
+	s.mux.HandleFunc("/api/invites", s.requireAdmin(s.handleInvites))
+	s.mux.HandleFunc("/api/invites/", s.requireAdmin(s.handleInvite))
+	s.mux.HandleFunc("/api/invite/", s.handleRegisterInvite)
+
 	// Profile routes (authenticated)
 	s.mux.HandleFunc("/api/profiles", s.requireAuth(s.handleProfiles))
 	s.mux.HandleFunc("/api/profiles/", s.requireAuth(s.handleProfile))
+	s.mux.HandleFunc("/api/avatars", s.requireAuth(s.handleAvatars))
+	s.mux.HandleFunc("/api/avatars/", s.requireAuth(s.handleAvatar))
+
+	// Device routes (authenticated, admins see and block everyone's)
+	s.mux.HandleFunc("/api/devices", s.requireAuth(s.handleDevices))
+	s.mux.HandleFunc("/api/devices/", s.requireAuth(s.handleDevice))
 
 	// Library routes (admin only)
 	s.mux.HandleFunc("/api/libraries", s.requireAdmin(s.handleLibraries))
 	s.mux.HandleFunc("/api/libraries/", s.requireAdmin(s.handleLibrary))
 	s.mux.HandleFunc("/api/scan/progress", s.requireAuth(s.handleScanProgress))
+	s.mux.HandleFunc("/api/events", s.requireAuth(s.handleEvents))
+	s.mux.HandleFunc("/api/ws", s.requireAuth(s.handleWebSocket))
+	s.mux.HandleFunc("/api/snapshot", s.requireAuth(s.handleSnapshot))
+	s.mux.HandleFunc("/api/changes", s.requireAuth(s.handleChanges))
+	s.mux.HandleFunc("/api/changes/journal", s.requireAdmin(s.handleChangeJournal))
+
+	// Stream bandwidth accounting (admin only)
+	s.mux.HandleFunc("/api/stats/bandwidth", s.requireAdmin(s.handleBandwidthStats))
+	s.mux.HandleFunc("/api/stats/bandwidth/caps", s.requireAdmin(s.handleBandwidthCaps))
+	s.mux.HandleFunc("/api/stats/bandwidth/caps/", s.requireAdmin(s.handleBandwidthCaps))
+	s.mux.HandleFunc("/api/stats/trends", s.requireAdmin(s.handleStatsTrends))
+
+	// UI asset bundle
+	s.mux.HandleFunc("/api/assets/ui", s.requireAuth(s.handleUIAssets))
+	s.mux.HandleFunc("/api/assets/ui/refresh", s.requireAdmin(s.handleUIAssetsRefresh))
 
 	// Media routes (authenticated)
 	s.mux.HandleFunc("/api/movies", s.requireAuth(s.handleMovies))
@@ -184,10 +294,21 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/albums", s.requireAuth(s.handleAlbums))
 	s.mux.HandleFunc("/api/albums/", s.requireAuth(s.handleAlbum))
 	s.mux.HandleFunc("/api/tracks/", s.requireAuth(s.handleTrack))
+	s.mux.HandleFunc("/api/music/mix", s.requireAuth(s.handleMusicMix))
+	s.mux.HandleFunc("/api/lyrics/", s.requireAuth(s.handleLyrics))
+	s.mux.HandleFunc("/api/music/continue-listening", s.requireAuth(s.handleContinueListening))
+	s.mux.HandleFunc("/api/playlists", s.requireAuth(s.handlePlaylists))
+	s.mux.HandleFunc("/api/playlists/", s.requireAuth(s.handlePlaylist))
 
 	// Book routes (authenticated)
 	s.mux.HandleFunc("/api/books", s.requireAuth(s.handleBooks))
 	s.mux.HandleFunc("/api/books/", s.requireAuth(s.handleBook))
+	s.mux.HandleFunc("/api/comics", s.requireAuth(s.handleComics))
+	s.mux.HandleFunc("/api/comics/", s.requireAuth(s.handleComic))
+	s.mux.HandleFunc("/api/photos", s.requireAuth(s.handlePhotos))
+	s.mux.HandleFunc("/api/photos/", s.requireAuth(s.handlePhoto))
+	s.mux.HandleFunc("/api/audiobooks", s.requireAuth(s.handleAudiobooks))
+	s.mux.HandleFunc("/api/audiobooks/", s.requireAuth(s.handleAudiobook))
 
 	// Streaming routes (authenticated)
 	s.mux.HandleFunc("/api/stream/", s.requireAuth(s.handleStream))
@@ -202,6 +323,12 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/opensubtitles/languages", s.requireAuth(s.handleOpenSubtitlesLanguages))
 	s.mux.HandleFunc("/api/opensubtitles/test", s.requireAdmin(s.handleOpenSubtitlesTest))
 
+	// Subtitle profile routes (admin only)
+	s.mux.HandleFunc("/api/subtitle-profiles", s.requireAdmin(s.handleSubtitleProfiles))
+	s.mux.HandleFunc("/api/subtitle-profiles/", s.requireAdmin(s.handleSubtitleProfile))
+	s.mux.HandleFunc("/api/subtitle-searches/", s.requireAdmin(s.handleSubtitleSearch))
+	s.mux.HandleFunc("/api/subtitle-history", s.requireAdmin(s.handleSubtitleHistory))
+
 	// Trakt routes (user-specific)
 	s.mux.HandleFunc("/api/trakt/auth-url", s.requireAuth(s.handleTraktAuthURL))
 	s.mux.HandleFunc("/api/trakt/callback", s.requireAuth(s.handleTraktCallback))
@@ -218,6 +345,9 @@ func (s *Server) setupRoutes() {
 	// Chapter routes (authenticated)
 	s.mux.HandleFunc("/api/chapters/", s.requireAuth(s.handleChapters))
 
+	// Trickplay routes (authenticated)
+	s.mux.HandleFunc("/api/trickplay/", s.requireAuth(s.handleTrickplay))
+
 	// Skip segments routes (authenticated)
 	s.mux.HandleFunc("/api/skip-segments/", s.requireAuth(s.handleSkipSegments))
 
@@ -238,7 +368,11 @@ func (s *Server) setupRoutes() {
 
 	// Metadata refresh route (admin only)
 	s.mux.HandleFunc("/api/metadata/refresh", s.requireAdmin(s.handleMetadataRefresh))
+	s.mux.HandleFunc("/api/metadata/providers", s.requireAdmin(s.handleMetadataProviders))
 	s.mux.HandleFunc("/api/library/clear", s.requireAdmin(s.handleLibraryClear))
+	s.mux.HandleFunc("/api/library/bulk", s.requireAdmin(s.handleLibraryBulk))
+	s.mux.HandleFunc("/api/library/tags", s.requireAdmin(s.handleLibraryTags))
+	s.mux.HandleFunc("/api/maintenance/dedupe", s.requireAdmin(s.handleMaintenanceDedupe))
 
 	// Match review routes (admin only)
 	s.mux.HandleFunc("/api/review/movies", s.requireAdmin(s.handleMoviesNeedingReview))
@@ -250,6 +384,9 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/download-clients", s.requireAdmin(s.handleDownloadClients))
 	s.mux.HandleFunc("/api/download-clients/", s.requireAdmin(s.handleDownloadClient))
 	s.mux.HandleFunc("/api/downloads", s.requireAdmin(s.handleDownloads))
+	s.mux.HandleFunc("/api/bandwidth-schedules", s.requireAdmin(s.handleBandwidthSchedules))
+	s.mux.HandleFunc("/api/bandwidth-schedules/", s.requireAdmin(s.handleBandwidthSchedule))
+	s.mux.HandleFunc("/api/bandwidth/status", s.requireAdmin(s.handleBandwidthStatus))
 
 	// Indexer routes (admin only)
 	s.mux.HandleFunc("/api/indexers", s.requireAdmin(s.handleIndexers))
@@ -270,6 +407,7 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/api/custom-formats", s.requireAdmin(s.handleCustomFormats))
 	s.mux.HandleFunc("/api/custom-formats/", s.requireAdmin(s.handleCustomFormat))
 	s.mux.HandleFunc("/api/releases/parse", s.requireAdmin(s.handleParseRelease))
+	s.mux.HandleFunc("/api/releases/parse/bulk", s.requireAdmin(s.handleParseReleaseBulk))
 
 	// Quality preset routes (GET is auth only, modifications are admin only)
 	s.mux.HandleFunc("/api/quality/presets", s.requireAuth(s.handleQualityPresets))
@@ -298,6 +436,10 @@ func (s *Server) setupRoutes() {
 	// Import and naming routes (admin only)
 	s.mux.HandleFunc("/api/imports/history", s.requireAdmin(s.handleImportHistory))
 	s.mux.HandleFunc("/api/settings/naming", s.requireAdmin(s.handleNamingTemplates))
+	s.mux.HandleFunc("/api/settings/naming/preview", s.requireAdmin(s.handleNamingPreview))
+	s.mux.HandleFunc("/api/settings/request-reasons", s.requireAdmin(s.handleRequestReasons))
+	s.mux.HandleFunc("/api/settings/request-reasons/", s.requireAdmin(s.handleRequestReason))
+	s.mux.HandleFunc("/api/settings/network-access", s.requireAdmin(s.handleNetworkAccess))
 	s.mux.HandleFunc("/api/storage/status", s.requireAdmin(s.handleStorageStatus))
 	s.mux.HandleFunc("/api/storage/analytics", s.requireAdmin(s.handleStorageAnalytics))
 
@@ -309,6 +451,11 @@ func (s *Server) setupRoutes() {
 	// Public route for login background (no auth required)
 	s.mux.HandleFunc("/api/public/trending-posters", s.handlePublicTrendingPosters)
 
+	// Request portal (no account needed, see portal.go)
+	s.mux.HandleFunc("/api/portal", s.handlePortal)
+	s.mux.HandleFunc("/api/portal/search", s.requirePortal(s.handlePortalSearch))
+	s.mux.HandleFunc("/api/portal/requests", s.requirePortal(s.handlePortalRequests))
+
 	// Discover routes (authenticated)
 	s.mux.HandleFunc("/api/discover/movies/trending", s.requireAuth(s.handleDiscoverTrendingMovies))
 	s.mux.HandleFunc("/api/discover/movies/popular", s.requireAuth(s.handleDiscoverPopularMovies))
@@ -344,8 +491,17 @@ func (s *Server) setupRoutes() {
 	// Request routes
 	s.mux.HandleFunc("/api/requests", s.requireAuth(s.handleRequests))
 	s.mux.HandleFunc("/api/requests/clear-denied", s.requireAdmin(s.handleClearDeniedRequests))
+	s.mux.HandleFunc("/api/requests/feed", s.requireFeedAuth(s.handleRequestFeed))
 	s.mux.HandleFunc("/api/requests/", s.requireAuth(s.handleRequest))
 
+	// Airing shows followed week by week
+	s.mux.HandleFunc("/api/airing", s.requireAuth(s.handleAiringShows))
+	s.mux.HandleFunc("/api/airing/", s.requireAuth(s.handleAiringShow))
+
+	// Shows and people profiles follow
+	s.mux.HandleFunc("/api/follows", s.requireAuth(s.handleFollows))
+	s.mux.HandleFunc("/api/follows/", s.requireAuth(s.handleFollow))
+
 	// Watchlist routes
 	s.mux.HandleFunc("/api/watchlist", s.requireAuth(s.handleWatchlist))
 	s.mux.HandleFunc("/api/watchlist/", s.requireAuth(s.handleWatchlistItem))
@@ -356,6 +512,7 @@ func (s *Server) setupRoutes() {
 
 	// Grab history routes (admin only)
 	s.mux.HandleFunc("/api/grab-history", s.requireAdmin(s.handleGrabHistory))
+	s.mux.HandleFunc("/api/history", s.requireAdmin(s.handleHistory))
 
 	// Blocked groups routes (admin only)
 	s.mux.HandleFunc("/api/blocked-groups", s.requireAdmin(s.handleBlockedGroups))
@@ -372,6 +529,8 @@ func (s *Server) setupRoutes() {
 	// Exclusions routes (admin only)
 	s.mux.HandleFunc("/api/exclusions", s.requireAdmin(s.handleExclusions))
 	s.mux.HandleFunc("/api/exclusions/", s.requireAdmin(s.handleExclusion))
+	s.mux.HandleFunc("/api/release-exclusions", s.requireAdmin(s.handleReleaseExclusions))
+	s.mux.HandleFunc("/api/release-exclusions/", s.requireAdmin(s.handleReleaseExclusion))
 
 	// Movie quality status routes (admin only)
 	s.mux.HandleFunc("/api/movies/quality/", s.requireAdmin(s.handleMovieQuality))
@@ -386,20 +545,43 @@ func (s *Server) setupRoutes() {
 
 	// System status route (authenticated)
 	s.mux.HandleFunc("/api/system/status", s.requireAuth(s.handleSystemStatus))
+	s.mux.HandleFunc("/api/system/maintenance", s.requireAuth(s.handleMaintenance))
+	s.mux.HandleFunc("/api/system/transcode-capabilities", s.requireAdmin(s.handleTranscodeCapabilities))
+	s.mux.HandleFunc("/api/system/resources", s.requireAdmin(s.handleSystemResources))
+	s.mux.HandleFunc("/api/system/storage", s.requireAdmin(s.handleStorage))
+	s.mux.HandleFunc("/api/system/storage/", s.requireAdmin(s.handleStorageCopy))
+	s.mux.HandleFunc("/api/transcode/sessions", s.requireAdmin(s.handleTranscodeSessions))
+	s.mux.HandleFunc("/api/transcode/sessions/", s.requireAdmin(s.handleTranscodeSession))
+
+	// Podcast routes
+	s.mux.HandleFunc("/api/podcasts", s.requireAuth(s.handlePodcasts))
+	s.mux.HandleFunc("/api/podcasts/", s.requireAuth(s.handlePodcast))
 	s.mux.HandleFunc("/api/system/rescan-quality", s.requireAdmin(s.handleRescanQuality))
 	s.mux.HandleFunc("/api/system/redetect-quality", s.requireAdmin(s.handleRedetectQuality))
 
 	// Logs routes (admin only)
 	s.mux.HandleFunc("/api/logs", s.requireAdmin(s.handleLogs))
 	s.mux.HandleFunc("/api/logs/download", s.requireAdmin(s.handleLogsDownload))
+	s.mux.HandleFunc("/api/logs/config", s.requireAdmin(s.handleLogConfig))
 
 	// Health check routes (admin only)
 	s.mux.HandleFunc("/api/health/full", s.requireAdmin(s.handleHealthFull))
 	s.mux.HandleFunc("/api/health/check/", s.requireAdmin(s.handleHealthCheck))
 
+	// Notification provider routes (admin only)
+	s.mux.HandleFunc("/api/notification-providers", s.requireAdmin(s.handleNotificationProviders))
+	s.mux.HandleFunc("/api/notification-providers/", s.requireAdmin(s.handleNotificationProvider))
+
 	// Backup/Restore routes (admin only)
 	s.mux.HandleFunc("/api/backup", s.requireAdmin(s.handleBackup))
 	s.mux.HandleFunc("/api/backup/restore", s.requireAdmin(s.handleRestore))
+	s.mux.HandleFunc("/api/backups", s.requireAdmin(s.handleStoredBackups))
+	s.mux.HandleFunc("/api/backups/", s.requireAdmin(s.handleStoredBackup))
+	s.mux.HandleFunc("/api/operations", s.requireAdmin(s.handleOperations))
+	s.mux.HandleFunc("/api/operations/", s.requireAdmin(s.handleOperation))
+
+	// Audit log route (admin only)
+	s.mux.HandleFunc("/api/audit", s.requireAdmin(s.handleAuditLog))
 
 	// Filesystem browse route (admin only)
 	s.mux.HandleFunc("/api/filesystem/browse", s.requireAdmin(s.handleFilesystemBrowse))
@@ -407,6 +589,26 @@ func (s *Server) setupRoutes() {
 	// Image cache (public for posters)
 	s.mux.HandleFunc("/images/", s.handleImages)
 
+	// Jellyfin-compatible API for Jellyfin apps
+	s.mux.HandleFunc("/jellyfin/", s.handleJellyfin)
+
+	// API keys for machine-to-machine access
+	s.mux.HandleFunc("/api/apikeys", s.requireAdmin(s.handleAPIKeys))
+	s.mux.HandleFunc("/api/apikeys/", s.requireAdmin(s.handleAPIKey))
+	s.mux.HandleFunc("/api/trash", s.requireAdmin(s.handleTrash))
+	s.mux.HandleFunc("/api/trash/", s.requireAdmin(s.handleTrashItem))
+
+	// Background jobs (admin only)
+	s.mux.HandleFunc("/api/jobs", s.requireAdmin(s.handleJobs))
+	s.mux.HandleFunc("/api/jobs/", s.requireAdmin(s.handleJob))
+
+	// Radarr and Sonarr compatibility, authenticated by their own API key
+	s.mux.HandleFunc("/api/v3/", s.handleArr)
+	s.mux.HandleFunc("/radarr/", s.handleArr)
+	s.mux.HandleFunc("/sonarr/", s.handleArr)
+	s.mux.HandleFunc("/api/arr", s.requireAdmin(s.handleArrSettings))
+	s.mux.HandleFunc("/api/arr/", s.requireAdmin(s.handleArrSettings))
+
 	// Static file serving for frontend (catch-all)
 	s.mux.HandleFunc("/", s.handleStatic)
 }
@@ -429,20 +631,40 @@ func (s *Server) getSessionToken(r *http.Request) string {
 
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			s.serveWithAPIKey(w, r, key, false, next)
+			return
+		}
+
 		token := s.getSessionToken(r)
 		if token == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		user, err := s.auth.ValidateSession(token)
 		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !allowUserNetwork(w, r, user, false) {
+			return
+		}
+		if !allowGuest(w, r, user) {
 			return
 		}
 
 		// Get session to access active profile
 		session, _ := s.db.GetSessionByToken(token)
+		if !s.allowSessionDevice(w, r, session) {
+			return
+		}
+
+		// Flag impersonated sessions on every response so clients can show a banner
+		if session != nil && session.ImpersonatorID != nil {
+			setImpersonationHeaders(w, s.db, session)
+		}
 
 		// Add user and session to context
 		ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -455,23 +677,36 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 
 func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			s.serveWithAPIKey(w, r, key, true, next)
+			return
+		}
+
 		token := s.getSessionToken(r)
 		if token == "" {
-			log.Printf("Auth failed: no token for %s %s", r.Method, r.URL.Path)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			requestLog(r).Errorf("Auth failed: no token for %s %s", r.Method, r.URL.Path)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		user, err := s.auth.ValidateSession(token)
 		if err != nil {
-			log.Printf("Auth failed: invalid token for %s %s: %v", r.Method, r.URL.Path, err)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			requestLog(r).Errorf("Auth failed: invalid token for %s %s: %v", r.Method, r.URL.Path, err)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		if user.Role != "admin" {
-			log.Printf("Auth failed: not admin for %s %s (user: %s)", r.Method, r.URL.Path, user.Username)
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			requestLog(r).Errorf("Auth failed: not admin for %s %s (user: %s)", r.Method, r.URL.Path, user.Username)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+			return
+		}
+
+		if !allowUserNetwork(w, r, user, true) {
+			return
+		}
+		session, _ := s.db.GetSessionByToken(token)
+		if !s.allowSessionDevice(w, r, session) {
 			return
 		}
 
@@ -494,19 +729,78 @@ func (s *Server) getActiveProfileID(r *http.Request) *int64 {
 	return nil
 }
 
+// configuredBaseURL returns the public_url setting without a trailing slash,
+// or "" when it isn't set. Links sent by email use it alone, since the host a
+// request names is up to whoever sent it.
+func (s *Server) configuredBaseURL() string {
+	base, _ := s.db.GetSetting("public_url")
+	return strings.TrimRight(strings.TrimSpace(base), "/")
+}
+
+// publicBaseURL returns the externally reachable base URL for links shown to
+// users. Uses the public_url setting when configured, otherwise the host the
+// request came in on.
+func (s *Server) publicBaseURL(r *http.Request) string {
+	base := s.configuredBaseURL()
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimRight(base, "/")
+}
+
 func (s *Server) Start() error {
 	// Wrap mux with static file fallback for SPA
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Try the mux first
 		// Create a response recorder to check if mux handled it
-		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/images/") {
+		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/images/") ||
+			strings.HasPrefix(r.URL.Path, "/jellyfin/") || strings.HasPrefix(r.URL.Path, "/radarr/") ||
+			strings.HasPrefix(r.URL.Path, "/sonarr/") {
 			s.mux.ServeHTTP(w, r)
 			return
 		}
 		// For all other paths, serve static files
 		s.handleStatic(w, r)
 	})
-	return http.ListenAndServe(":"+s.config.Port, handler)
+	return http.ListenAndServe(":"+s.config.Port, withRequestID(handler))
+}
+
+// HealthChecker returns the server's health checker, for running the checks
+// on a schedule
+func (s *Server) HealthChecker() *health.Checker {
+	return s.healthChecker
+}
+
+// Trash returns the server's trash, for emptying it on a schedule
+func (s *Server) Trash() *trash.Bin {
+	return s.trash
+}
+
+// WatchLibraries starts watching library folders for changed files, unless
+// watching is turned off
+func (s *Server) WatchLibraries() {
+	s.watcher.Sync()
+}
+
+// syncWatcher updates the watched libraries after they or the watching
+// setting changed. Maintenance mode leaves watching paused.
+func (s *Server) syncWatcher() {
+	if !s.InMaintenance() {
+		go s.watcher.Sync()
+	}
+}
+
+// Stop ends the server's background work, such as running transcodes, for
+// shutdown
+func (s *Server) Stop() {
+	s.transcodes.StopAll()
+	s.resources.Stop()
+	s.watcher.Stop()
+	s.jobs.Stop()
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -525,7 +819,7 @@ func (s *Server) handleLibraries(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		libraries, err := s.db.GetLibraries()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if libraries == nil {
@@ -536,21 +830,30 @@ func (s *Server) handleLibraries(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var lib database.Library
 		if err := json.NewDecoder(r.Body).Decode(&lib); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 		if lib.ScanInterval == 0 {
 			lib.ScanInterval = 3600
 		}
+		if err := s.validateProviders(lib.MetadataProviders); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := validateArtwork(lib.ArtworkStyle, lib.ArtworkText); msg != "" {
+			httpError(w, msg, http.StatusBadRequest)
+			return
+		}
 		if err := s.db.CreateLibrary(&lib); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.syncWatcher()
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(lib)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -562,66 +865,94 @@ func (s *Server) handleLibrary(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Library ID required", http.StatusBadRequest)
+		httpError(w, "Library ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid library ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid library ID")
 		return
 	}
 
 	// Handle scan endpoint
 	if len(parts) == 2 && parts[1] == "scan" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		s.handleScan(w, r, id)
 		return
 	}
 
+	// Handle metadata provider order
+	if len(parts) == 2 && parts[1] == "providers" {
+		s.handleLibraryProviders(w, r, id)
+		return
+	}
+
+	// Handle artwork style
+	if len(parts) == 2 && parts[1] == "artwork" {
+		s.handleLibraryArtwork(w, r, id)
+		return
+	}
+
+	// Handle scan throttle
+	if len(parts) == 2 && parts[1] == "scan-throttle" {
+		s.handleLibraryScanThrottle(w, r, id)
+		return
+	}
+
+	// Handle subtitle profile
+	if len(parts) == 2 && parts[1] == "subtitle-profile" {
+		s.handleLibrarySubtitleProfile(w, r, id)
+		return
+	}
+
 	// Handle single library
 	switch r.Method {
 	case http.MethodGet:
 		lib, err := s.db.GetLibrary(id)
 		if err != nil {
-			http.Error(w, "Library not found", http.StatusNotFound)
+			httpError(w, "Library not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(lib)
 
 	case http.MethodDelete:
 		if err := s.db.DeleteLibrary(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.syncWatcher()
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleScan(w http.ResponseWriter, r *http.Request, libraryID int64) {
 	lib, err := s.db.GetLibrary(libraryID)
 	if err != nil {
-		http.Error(w, "Library not found", http.StatusNotFound)
+		httpError(w, "Library not found", http.StatusNotFound)
 		return
 	}
 
-	// Run scan in goroutine so we don't block the response
-	go func() {
-		if err := s.scanner.ScanLibrary(lib); err != nil {
-			// Log error (can't send to client since response already sent)
-			println("Scan error:", err.Error())
-		}
-	}()
+	// Scan as a job so we don't block the response
+	job, err := s.submitJob(r, jobScan, "Scan "+lib.Name, func(ctx context.Context, p *jobs.Progress) error {
+		return s.scanner.ScanLibraryContext(ctx, lib, p.Set)
+	})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	json.NewEncoder(w).Encode(map[string]string{
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "scanning",
 		"message": "Library scan started",
+		"jobId":   job.ID,
 	})
 }
 
@@ -684,7 +1015,7 @@ func (s *Server) isKidFriendly(contentRating *string) bool {
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	// Don't serve API routes here
 	if strings.HasPrefix(r.URL.Path, "/api/") {
-		http.NotFound(w, r)
+		notFound(w)
 		return
 	}
 
@@ -696,14 +1027,14 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := filepath.Join(staticDir, urlPath)
-	log.Printf("Static request: URL=%s, StaticDir=%s, Path=%s", r.URL.Path, staticDir, path)
+	requestLog(r).Infof("Static request: URL=%s, StaticDir=%s, Path=%s", r.URL.Path, staticDir, path)
 
 	// Check if file exists and is not a directory
 	info, err := os.Stat(path)
 	if err != nil || info.IsDir() {
 		// SPA fallback: serve index.html for all non-file routes
 		path = filepath.Join(staticDir, "index.html")
-		log.Printf("Falling back to index.html: %s", path)
+		requestLog(r).Infof("Falling back to index.html: %s", path)
 	}
 
 	http.ServeFile(w, r, path)
@@ -714,28 +1045,29 @@ func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Get active profile ID
 	profileID := s.getActiveProfileID(r)
 	if profileID == nil {
-		http.Error(w, "No profile selected", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNoProfile, "No profile selected")
 		return
 	}
 
 	var p database.Progress
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	// Override profile ID from session for security
 	p.ProfileID = *profileID
 
-	if err := s.db.SaveProgress(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Crossing the played threshold records a watch for Trakt
+	if _, err := s.db.SaveProgressPlayed(&p); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -746,7 +1078,7 @@ func (s *Server) handleProgressGet(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -766,14 +1098,14 @@ func (s *Server) handleProgressGet(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) != 2 {
-		http.Error(w, "Invalid progress path", http.StatusBadRequest)
+		httpError(w, "Invalid progress path", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
@@ -793,32 +1125,41 @@ func (s *Server) handleProgressGet(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(progress)
 }
 
+// handleChapters handles GET /api/chapters/{type}/{id} and, for admins,
+// POST /api/chapters/{type}/{id}/extract, which reads the file's chapters
+// again
 func (s *Server) handleChapters(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Parse path: /api/chapters/{type}/{id}
+	// Parse path: /api/chapters/{type}/{id}[/extract]
 	path := strings.TrimPrefix(r.URL.Path, "/api/chapters/")
 	parts := strings.Split(path, "/")
 
+	if len(parts) == 3 && parts[2] == "extract" {
+		s.handleExtractChapters(w, r, parts[0], parts[1])
+		return
+	}
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	if len(parts) != 2 {
-		http.Error(w, "Invalid chapters path", http.StatusBadRequest)
+		httpError(w, "Invalid chapters path", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, mediaType, id) {
 		return
 	}
 
 	chapters, err := s.db.GetChapters(mediaType, id)
-	if err != nil {
+	if err != nil || chapters == nil {
 		// No chapters, return empty array
 		json.NewEncoder(w).Encode([]database.Chapter{})
 		return
@@ -827,6 +1168,55 @@ func (s *Server) handleChapters(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(chapters)
 }
 
+// handleExtractChapters handles POST /api/chapters/{type}/{id}/extract
+func (s *Server) handleExtractChapters(w http.ResponseWriter, r *http.Request, mediaType, idStr string) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if user := s.getCurrentUser(r); user == nil || user.Role != "admin" {
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	var filePath string
+	switch mediaType {
+	case "movie":
+		movie, err := s.db.GetMovie(id)
+		if err != nil {
+			httpError(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		filePath = movie.Path
+	case "episode":
+		episode, err := s.db.GetEpisode(id)
+		if err != nil {
+			httpError(w, "Episode not found", http.StatusNotFound)
+			return
+		}
+		filePath = episode.Path
+	default:
+		httpError(w, "Chapters can only be extracted from movies and episodes", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		httpError(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	chapters, err := s.scanner.ExtractChapters(mediaType, id, filePath)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(chapters)
+}
+
 func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -835,13 +1225,13 @@ func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) < 1 || parts[0] == "" {
-		http.Error(w, "Invalid skip-segments path", http.StatusBadRequest)
+		httpError(w, "Invalid skip-segments path", http.StatusBadRequest)
 		return
 	}
 
 	showID, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
@@ -849,7 +1239,7 @@ func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		segments, err := s.db.GetSkipSegments(showID)
 		if err != nil {
-			http.Error(w, "Failed to get skip segments", http.StatusInternalServerError)
+			httpError(w, "Failed to get skip segments", http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(segments)
@@ -861,22 +1251,22 @@ func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 			EndTime   float64 `json:"endTime"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if req.Type != "intro" && req.Type != "credits" {
-			http.Error(w, "Type must be 'intro' or 'credits'", http.StatusBadRequest)
+			httpError(w, "Type must be 'intro' or 'credits'", http.StatusBadRequest)
 			return
 		}
 
 		if req.StartTime >= req.EndTime {
-			http.Error(w, "Start time must be less than end time", http.StatusBadRequest)
+			httpError(w, "Start time must be less than end time", http.StatusBadRequest)
 			return
 		}
 
 		if err := s.db.SaveSkipSegment(showID, req.Type, req.StartTime, req.EndTime); err != nil {
-			http.Error(w, "Failed to save skip segment", http.StatusInternalServerError)
+			httpError(w, "Failed to save skip segment", http.StatusInternalServerError)
 			return
 		}
 
@@ -885,17 +1275,17 @@ func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if len(parts) < 2 {
-			http.Error(w, "Segment type required for delete", http.StatusBadRequest)
+			httpError(w, "Segment type required for delete", http.StatusBadRequest)
 			return
 		}
 		segmentType := parts[1]
 		if segmentType != "intro" && segmentType != "credits" {
-			http.Error(w, "Type must be 'intro' or 'credits'", http.StatusBadRequest)
+			httpError(w, "Type must be 'intro' or 'credits'", http.StatusBadRequest)
 			return
 		}
 
 		if err := s.db.DeleteSkipSegment(showID, segmentType); err != nil {
-			http.Error(w, "Failed to delete skip segment", http.StatusInternalServerError)
+			httpError(w, "Failed to delete skip segment", http.StatusInternalServerError)
 			return
 		}
 
@@ -903,7 +1293,7 @@ func (s *Server) handleSkipSegments(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -915,17 +1305,17 @@ func (s *Server) handleContinueWatching(w http.ResponseWriter, r *http.Request)
 		path := strings.TrimPrefix(r.URL.Path, "/api/continue-watching/")
 		parts := strings.Split(path, "/")
 		if len(parts) != 2 {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
+			httpError(w, "Invalid path", http.StatusBadRequest)
 			return
 		}
 		mediaType := parts[0]
 		id, err := strconv.ParseInt(parts[1], 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 			return
 		}
 		if err := s.db.DeleteProgress(mediaType, id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
@@ -933,13 +1323,13 @@ func (s *Server) handleContinueWatching(w http.ResponseWriter, r *http.Request)
 	}
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	items, err := s.db.GetContinueWatching(20)
+	items, err := s.db.GetContinueWatching(s.db.GetPlayedRule(s.getActiveProfileID(r)), 20)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -962,19 +1352,19 @@ func (s *Server) handleWatched(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) != 2 {
-		http.Error(w, "Invalid path - expected /api/watched/{type}/{id}", http.StatusBadRequest)
+		httpError(w, "Invalid path - expected /api/watched/{type}/{id}", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
 	if mediaType != "movie" && mediaType != "episode" {
-		http.Error(w, "Invalid media type - must be 'movie' or 'episode'", http.StatusBadRequest)
+		httpError(w, "Invalid media type - must be 'movie' or 'episode'", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
@@ -993,7 +1383,7 @@ func (s *Server) handleWatched(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.MarkAsWatched(mediaType, id, req.Duration); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]any{"status": "watched", "mediaType": mediaType, "mediaId": id})
@@ -1001,16 +1391,16 @@ func (s *Server) handleWatched(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		// Mark as unwatched
 		if err := s.db.MarkAsUnwatched(mediaType, id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]any{"status": "unwatched", "mediaType": mediaType, "mediaId": id})
 
 	case http.MethodGet:
 		// Get watch status
-		watched, progress, err := s.db.GetWatchedStatus(mediaType, id)
+		watched, progress, err := s.db.GetWatchedStatus(s.db.GetPlayedRule(s.getActiveProfileID(r)), mediaType, id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]any{
@@ -1019,7 +1409,7 @@ func (s *Server) handleWatched(w http.ResponseWriter, r *http.Request) {
 		})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -1031,7 +1421,7 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		settings, err := s.db.GetAllSettings()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if settings == nil {
@@ -1042,23 +1432,221 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var data map[string]string
 		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
+		if v, ok := data["change_journal_retention_days"]; ok {
+			if days, err := strconv.Atoi(v); err != nil || days < 0 {
+				httpError(w, "Change journal retention must be 0 (keep forever) or more days", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["bandwidth_monthly_cap_gb"]; ok {
+			if gb, err := strconv.Atoi(v); err != nil || gb < 0 {
+				httpError(w, "Monthly transfer cap must be 0 (unlimited) or more GB", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["import_retry_max_attempts"]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				httpError(w, "Import retries must be 0 (retry by hand only) or more", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["subtitle_upgrade_days"]; ok {
+			if days, err := strconv.Atoi(v); err != nil || days < 0 {
+				httpError(w, "Subtitle upgrade days must be 0 (never upgrade) or more days", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["request_recheck_policy"]; ok && v != "reopen" && v != "approve" {
+			httpError(w, "Request recheck policy must be reopen or approve", http.StatusBadRequest)
+			return
+		}
+		if v, ok := data["played_threshold"]; ok {
+			if threshold, err := strconv.Atoi(v); err != nil || !database.ValidPlayedThreshold(threshold) {
+				httpError(w, "Played threshold must be between 50 and 100", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["security_login_failure_limit"]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n < 1 {
+				httpError(w, "Failed login limit must be at least 1", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["portal_requests_per_hour"]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n < 1 {
+				httpError(w, "Portal request limit must be at least 1 per hour", http.StatusBadRequest)
+				return
+			}
+		}
+		if v, ok := data["portal_user_id"]; ok && v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err == nil {
+				_, err = s.db.GetUserByID(id)
+			}
+			if err != nil {
+				writeInvalidSetting(w, "portal_user_id", "Portal account must be an existing user")
+				return
+			}
+		}
+		if v, ok := data["security_webhook_url"]; ok && v != "" {
+			if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				writeInvalidSetting(w, "security_webhook_url", "Security webhook must be an http or https URL")
+				return
+			}
+		}
+		reloadChaos := false
+		reloadAccess := false
+		for key, value := range data {
+			if err := chaos.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := netaccess.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := i18n.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := timezone.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := validateHomeSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := validateScreensaverSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := transcode.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := podcast.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := blobstore.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := logging.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := validateFourEyesSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := validateMaintenanceSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := validateArrSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := importpkg.ValidatePermissionSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := importpkg.ValidateStagingSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := trailer.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := indexer.ValidateQuerySetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := trash.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := metadata.ValidateSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := scanner.ValidateWatchSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := scanner.ValidateThrottleSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := scanner.ValidateWorkersSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if err := scheduler.ValidateAiringSetting(key, value); err != nil {
+				writeInvalidSetting(w, key, err.Error())
+				return
+			}
+			if strings.HasPrefix(key, "security_notify_") && value != "true" && value != "false" {
+				writeInvalidSetting(w, key, "Security notification toggles must be true or false")
+				return
+			}
+			if strings.HasPrefix(key, "chaos_") {
+				reloadChaos = true
+			}
+			if strings.HasPrefix(key, "access_") || key == netaccess.SettingTrustedProxies {
+				reloadAccess = true
+			}
+		}
+		if s.holdFourEyesSettings(w, r, data) {
+			return
+		}
+		previous, _ := s.db.GetAllSettings()
 		for key, value := range data {
 			if err := s.db.SetSetting(key, value); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			// Update metadata service if TMDB API key changed
 			if key == "tmdb_api_key" && s.metadata != nil {
 				s.metadata.UpdateAPIKey(value)
 			}
+			if key == "language" {
+				i18n.SetDefault(value)
+			}
+			if key == "timezone" {
+				timezone.SetDefault(value)
+			}
+			if key == logging.ConfigSetting {
+				logging.Configure(logging.FromSettings(map[string]string{key: value}))
+			}
+			if key == scanner.SettingWatch {
+				s.syncWatcher()
+			}
+			if key == blobstore.SettingImages || key == blobstore.SettingBackups {
+				s.configureStorage(key, value)
+			}
+		}
+		if reloadChaos {
+			if settings, err := s.db.GetAllSettings(); err == nil {
+				chaos.Configure(chaos.FromSettings(settings))
+			}
 		}
+		if reloadAccess {
+			if settings, err := s.db.GetAllSettings(); err == nil {
+				netaccess.Configure(netaccess.FromSettings(settings))
+			}
+		}
+		s.notifySettingsChanges(r, previous, data)
 		json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -1070,7 +1658,7 @@ func (s *Server) handleFormatSettings(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		settings, err := s.db.GetFormatSettings()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(settings)
@@ -1078,101 +1666,140 @@ func (s *Server) handleFormatSettings(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut, http.MethodPost:
 		var settings database.FormatSettings
 		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 		if err := s.db.SaveFormatSettings(&settings); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(settings)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleMetadataRefresh(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
-	// Get all movies and refresh their metadata
-	movies, err := s.db.GetMovies()
+	job, err := s.submitJob(r, jobMetadataRefresh, "Refresh all metadata", s.refreshAllMetadata)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newJobView(job))
+}
+
+// refreshAllMetadata refetches the metadata of every movie and show, its
+// result counting how many were refreshed and how many failed
+func (s *Server) refreshAllMetadata(ctx context.Context, p *jobs.Progress) error {
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		return err
+	}
+	shows, err := s.db.GetShows()
+	if err != nil {
+		return err
+	}
+	total := len(movies) + len(shows)
+	p.SetTotal(total)
+
 	refreshed := 0
 	errors := 0
-	for _, movie := range movies {
-		if err := s.metadata.FetchMovieMetadata(&movie); err != nil {
-			log.Printf("Failed to refresh metadata for movie %s: %v", movie.Title, err)
+	record := func() {
+		p.SetResult(map[string]int{"refreshed": refreshed, "errors": errors, "total": total})
+		p.Step()
+	}
+
+	p.SetMessage("Refreshing movies")
+	for i := range movies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.metadata.FetchMovieMetadata(&movies[i]); err != nil {
+			logger.Errorf("Failed to refresh metadata for movie %s: %v", movies[i].Title, err)
 			errors++
 		} else {
 			refreshed++
 		}
+		record()
 	}
 
-	// Get all shows and refresh their metadata
-	shows, err := s.db.GetShows()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	for _, show := range shows {
-		if err := s.metadata.FetchShowMetadata(&show); err != nil {
-			log.Printf("Failed to refresh metadata for show %s: %v", show.Title, err)
+	p.SetMessage("Refreshing shows")
+	for i := range shows {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.metadata.FetchShowMetadata(&shows[i]); err != nil {
+			logger.Errorf("Failed to refresh metadata for show %s: %v", shows[i].Title, err)
 			errors++
 		} else {
 			refreshed++
 		}
+		record()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"refreshed": refreshed,
-		"errors":    errors,
-		"total":     len(movies) + len(shows),
-	})
+	p.SetResult(map[string]int{"refreshed": refreshed, "errors": errors, "total": total})
+	p.SetMessage("")
+	return nil
 }
 
 func (s *Server) handleLibraryClear(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if isDryRun(r) {
+		s.simulateLibraryClear(w, r)
+		return
+	}
+	if s.holdForApproval(w, r, opLibraryClear, "Clear all library data", nil, nil) {
 		return
 	}
 
-	if err := s.db.ClearAllLibraryData(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	result, err := s.clearLibrary()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	json.NewEncoder(w).Encode(result)
+}
+
+// clearLibrary removes all library data
+func (s *Server) clearLibrary() (interface{}, error) {
+	if err := s.db.ClearAllLibraryData(); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
 		"success": true,
 		"message": "All library data cleared",
-	})
+	}, nil
 }
 
 // handleMoviesNeedingReview returns movies flagged for manual review
 func (s *Server) handleMoviesNeedingReview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	movies, err := s.db.GetMoviesNeedingReview()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1183,13 +1810,13 @@ func (s *Server) handleMoviesNeedingReview(w http.ResponseWriter, r *http.Reques
 // handleShowsNeedingReview returns shows flagged for manual review
 func (s *Server) handleShowsNeedingReview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	shows, err := s.db.GetShowsNeedingReview()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1200,7 +1827,7 @@ func (s *Server) handleShowsNeedingReview(w http.ResponseWriter, r *http.Request
 // handleUpdateMovieMatch updates the TMDB ID for a movie and clears the review flag
 func (s *Server) handleUpdateMovieMatch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1208,7 +1835,7 @@ func (s *Server) handleUpdateMovieMatch(w http.ResponseWriter, r *http.Request)
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/review/movie/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid movie ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid movie ID")
 		return
 	}
 
@@ -1216,26 +1843,26 @@ func (s *Server) handleUpdateMovieMatch(w http.ResponseWriter, r *http.Request)
 		TmdbID int64 `json:"tmdbId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	// Update TMDB ID and clear review flag
 	if err := s.db.UpdateMovieTmdbMatch(id, req.TmdbID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch fresh metadata in background
-	if s.metadata != nil {
+	if s.metadataConfigured() {
 		go func() {
 			movie, err := s.db.GetMovie(id)
 			if err != nil {
-				log.Printf("Failed to get movie %d for metadata refresh: %v", id, err)
+				requestLog(r).Errorf("Failed to get movie %d for metadata refresh: %v", id, err)
 				return
 			}
 			if err := s.metadata.FetchMovieMetadata(movie); err != nil {
-				log.Printf("Failed to fetch metadata for movie %s: %v", movie.Title, err)
+				requestLog(r).Errorf("Failed to fetch metadata for movie %s: %v", movie.Title, err)
 			}
 		}()
 	}
@@ -1250,7 +1877,7 @@ func (s *Server) handleUpdateMovieMatch(w http.ResponseWriter, r *http.Request)
 // handleUpdateShowMatch updates the TMDB ID for a show and clears the review flag
 func (s *Server) handleUpdateShowMatch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1258,7 +1885,7 @@ func (s *Server) handleUpdateShowMatch(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/review/show/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
@@ -1266,26 +1893,26 @@ func (s *Server) handleUpdateShowMatch(w http.ResponseWriter, r *http.Request) {
 		TmdbID int64 `json:"tmdbId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	// Update TMDB ID and clear review flag
 	if err := s.db.UpdateShowTmdbMatch(id, req.TmdbID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch fresh metadata in background
-	if s.metadata != nil {
+	if s.metadataConfigured() {
 		go func() {
 			show, err := s.db.GetShow(id)
 			if err != nil {
-				log.Printf("Failed to get show %d for metadata refresh: %v", id, err)
+				requestLog(r).Errorf("Failed to get show %d for metadata refresh: %v", id, err)
 				return
 			}
 			if err := s.metadata.FetchShowMetadata(show); err != nil {
-				log.Printf("Failed to fetch metadata for show %s: %v", show.Title, err)
+				requestLog(r).Errorf("Failed to fetch metadata for show %s: %v", show.Title, err)
 			}
 		}()
 	}
@@ -1302,7 +1929,7 @@ func (s *Server) handleSetting(w http.ResponseWriter, r *http.Request) {
 
 	key := strings.TrimPrefix(r.URL.Path, "/api/settings/")
 	if key == "" {
-		http.Error(w, "Setting key required", http.StatusBadRequest)
+		httpError(w, "Setting key required", http.StatusBadRequest)
 		return
 	}
 
@@ -1310,7 +1937,7 @@ func (s *Server) handleSetting(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		value, err := s.db.GetSetting(key)
 		if err != nil {
-			http.Error(w, "Setting not found", http.StatusNotFound)
+			httpError(w, "Setting not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]string{"key": key, "value": value})
@@ -1320,38 +1947,42 @@ func (s *Server) handleSetting(w http.ResponseWriter, r *http.Request) {
 			Value string `json:"value"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if err := validateFourEyesSetting(key, data.Value); err != nil {
+			writeInvalidSetting(w, key, err.Error())
+			return
+		}
+		if s.holdFourEyesSettings(w, r, map[string]string{key: data.Value}) {
 			return
 		}
 		if err := s.db.SetSetting(key, data.Value); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // Image cache handler - serves cached TMDB images
 func (s *Server) handleImages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Serve from data/images directory
 	imagePath := strings.TrimPrefix(r.URL.Path, "/images/")
-	fullPath := filepath.Join(filepath.Dir(s.config.DBPath), "images", imagePath)
 
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		http.NotFound(w, r)
-		return
+	// Versioned URLs (UI assets) change whenever the file does
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
 	}
 
-	http.ServeFile(w, r, fullPath)
+	s.serveStoredFile(w, r, s.images, imagePath)
 }
 
 // TMDB search handlers
@@ -1359,13 +1990,13 @@ func (s *Server) handleTmdbSearchMovie(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	query := r.URL.Query().Get("q")
 	if query == "" {
-		http.Error(w, "Query parameter 'q' required", http.StatusBadRequest)
+		httpError(w, "Query parameter 'q' required", http.StatusBadRequest)
 		return
 	}
 
@@ -1374,14 +2005,14 @@ func (s *Server) handleTmdbSearchMovie(w http.ResponseWriter, r *http.Request) {
 		year, _ = strconv.Atoi(y)
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "Metadata service not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
 	results, err := s.metadata.SearchMovies(query, year)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1392,13 +2023,13 @@ func (s *Server) handleTmdbSearchTV(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	query := r.URL.Query().Get("q")
 	if query == "" {
-		http.Error(w, "Query parameter 'q' required", http.StatusBadRequest)
+		httpError(w, "Query parameter 'q' required", http.StatusBadRequest)
 		return
 	}
 
@@ -1407,14 +2038,14 @@ func (s *Server) handleTmdbSearchTV(w http.ResponseWriter, r *http.Request) {
 		year, _ = strconv.Atoi(y)
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "Metadata service not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
 	results, err := s.metadata.SearchTV(query, year)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1430,7 +2061,7 @@ func (s *Server) handleProwlarrConfig(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		config, err := s.db.GetProwlarrConfig()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if config == nil {
@@ -1444,25 +2075,27 @@ func (s *Server) handleProwlarrConfig(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var config database.ProwlarrConfig
 		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if config.URL == "" || config.APIKey == "" {
-			http.Error(w, "URL and API key are required", http.StatusBadRequest)
+			httpError(w, "URL and API key are required", http.StatusBadRequest)
 			return
 		}
 
+		existing, _ := s.db.GetProwlarrConfig()
 		if err := s.db.SaveProwlarrConfig(&config); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		s.notifyIndexerChange(r, "configured", "Prowlarr", existing == nil || existing.APIKey != config.APIKey)
 
 		config.APIKey = ""
 		json.NewEncoder(w).Encode(config)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -1470,7 +2103,7 @@ func (s *Server) handleProwlarrTest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1479,7 +2112,7 @@ func (s *Server) handleProwlarrTest(w http.ResponseWriter, r *http.Request) {
 		APIKey string `json:"apiKey"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
@@ -1504,7 +2137,7 @@ func (s *Server) handleProwlarrSync(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1534,13 +2167,13 @@ func (s *Server) handleIndexerTags(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	tags, err := s.db.GetIndexerTags()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if tags == nil {
@@ -1553,7 +2186,7 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1586,7 +2219,7 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	results, err := s.indexers.Search(params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1601,7 +2234,7 @@ func (s *Server) handleSearchScored(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1641,7 +2274,7 @@ func (s *Server) handleSearchScored(w http.ResponseWriter, r *http.Request) {
 	// Search indexers
 	results, err := s.indexers.Search(params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1688,6 +2321,7 @@ func (s *Server) handleSearchScored(w http.ResponseWriter, r *http.Request) {
 	scoredResults := make([]indexer.ScoredSearchResult, 0, len(results))
 	for _, result := range results {
 		parsed := parser.Parse(result.Title)
+		parsed.Size = result.Size
 		qualityTier := quality.ComputeQualityTier(parsed)
 
 		// Convert HDR string to slice (indexer uses []string)
@@ -1734,6 +2368,16 @@ func (s *Server) handleSearchScored(w http.ResponseWriter, r *http.Request) {
 		scoredResults = append(scoredResults, scored)
 	}
 
+	// Searches for a movie or show show what's excluded for it
+	if tmdbID, err := strconv.ParseInt(params.TmdbID, 10, 64); err == nil {
+		switch params.Type {
+		case "movie":
+			s.markExcludedReleases(scoredResults, "movie", tmdbID)
+		case "tvsearch":
+			s.markExcludedReleases(scoredResults, "show", tmdbID)
+		}
+	}
+
 	// Sort by total score (descending)
 	for i := 0; i < len(scoredResults)-1; i++ {
 		for j := i + 1; j < len(scoredResults); j++ {
@@ -1750,7 +2394,7 @@ func (s *Server) handleGrab(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1759,16 +2403,22 @@ func (s *Server) handleGrab(w http.ResponseWriter, r *http.Request) {
 		MagnetLink string `json:"magnetLink"`
 		IndexerType string `json:"indexerType"`
 		Category   string `json:"category"`
+		Priority   string `json:"priority"` // low, normal or high
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	if req.Link == "" && req.MagnetLink == "" {
-		http.Error(w, "Either link or magnetLink is required", http.StatusBadRequest)
+		httpError(w, "Either link or magnetLink is required", http.StatusBadRequest)
 		return
 	}
+	if req.Priority != "" && !database.ValidPriority(req.Priority) {
+		httpError(w, "Priority must be low, normal or high", http.StatusBadRequest)
+		return
+	}
+	priority := downloadclient.ParsePriority(req.Priority)
 
 	// Get appropriate download client
 	var downloadURL string
@@ -1784,7 +2434,7 @@ func (s *Server) handleGrab(w http.ResponseWriter, r *http.Request) {
 	// Get enabled download clients
 	clients, err := s.db.GetEnabledDownloadClients()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1802,19 +2452,19 @@ func (s *Server) handleGrab(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if targetClient == nil {
-		http.Error(w, "No suitable download client found", http.StatusBadRequest)
+		httpError(w, "No suitable download client found", http.StatusBadRequest)
 		return
 	}
 
 	// Add to download client
 	if isTorrent {
-		err = s.downloads.AddTorrent(targetClient.ID, downloadURL, req.Category)
+		err = s.downloads.AddTorrent(targetClient.ID, downloadURL, req.Category, priority)
 	} else {
-		err = s.downloads.AddNZB(targetClient.ID, downloadURL, req.Category)
+		err = s.downloads.AddNZB(targetClient.ID, downloadURL, req.Category, priority)
 	}
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -1834,7 +2484,7 @@ func (s *Server) handleQualityProfiles(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		profiles, err := s.db.GetQualityProfiles()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if profiles == nil {
@@ -1846,18 +2496,18 @@ func (s *Server) handleQualityProfiles(w http.ResponseWriter, r *http.Request) {
 		// POST requires admin
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
 
 		var profile database.QualityProfile
 		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if profile.Name == "" {
-			http.Error(w, "Name is required", http.StatusBadRequest)
+			httpError(w, "Name is required", http.StatusBadRequest)
 			return
 		}
 
@@ -1870,7 +2520,7 @@ func (s *Server) handleQualityProfiles(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.CreateQualityProfile(&profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1878,7 +2528,7 @@ func (s *Server) handleQualityProfiles(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(profile)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -1888,7 +2538,7 @@ func (s *Server) handleQualityProfile(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/quality-profiles/")
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid profile ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid profile ID")
 		return
 	}
 
@@ -1896,7 +2546,7 @@ func (s *Server) handleQualityProfile(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		profile, err := s.db.GetQualityProfile(id)
 		if err != nil {
-			http.Error(w, "Profile not found", http.StatusNotFound)
+			httpError(w, "Profile not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(profile)
@@ -1904,13 +2554,13 @@ func (s *Server) handleQualityProfile(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var req database.QualityProfile
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		profile, err := s.db.GetQualityProfile(id)
 		if err != nil {
-			http.Error(w, "Profile not found", http.StatusNotFound)
+			httpError(w, "Profile not found", http.StatusNotFound)
 			return
 		}
 
@@ -1930,7 +2580,7 @@ func (s *Server) handleQualityProfile(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.UpdateQualityProfile(profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1938,13 +2588,13 @@ func (s *Server) handleQualityProfile(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if err := s.db.DeleteQualityProfile(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -1957,7 +2607,7 @@ func (s *Server) handleCustomFormats(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		formats, err := s.db.GetCustomFormats()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if formats == nil {
@@ -1968,12 +2618,12 @@ func (s *Server) handleCustomFormats(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var format database.CustomFormat
 		if err := json.NewDecoder(r.Body).Decode(&format); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if format.Name == "" {
-			http.Error(w, "Name is required", http.StatusBadRequest)
+			httpError(w, "Name is required", http.StatusBadRequest)
 			return
 		}
 
@@ -1982,7 +2632,7 @@ func (s *Server) handleCustomFormats(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.CreateCustomFormat(&format); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -1990,7 +2640,7 @@ func (s *Server) handleCustomFormats(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(format)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -2000,7 +2650,7 @@ func (s *Server) handleCustomFormat(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/custom-formats/")
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid format ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid format ID")
 		return
 	}
 
@@ -2008,7 +2658,7 @@ func (s *Server) handleCustomFormat(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		format, err := s.db.GetCustomFormat(id)
 		if err != nil {
-			http.Error(w, "Format not found", http.StatusNotFound)
+			httpError(w, "Format not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(format)
@@ -2016,13 +2666,13 @@ func (s *Server) handleCustomFormat(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var req database.CustomFormat
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		format, err := s.db.GetCustomFormat(id)
 		if err != nil {
-			http.Error(w, "Format not found", http.StatusNotFound)
+			httpError(w, "Format not found", http.StatusNotFound)
 			return
 		}
 
@@ -2034,7 +2684,7 @@ func (s *Server) handleCustomFormat(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.UpdateCustomFormat(format); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -2042,13 +2692,13 @@ func (s *Server) handleCustomFormat(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if err := s.db.DeleteCustomFormat(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -2058,7 +2708,7 @@ func (s *Server) handleParseRelease(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2066,12 +2716,12 @@ func (s *Server) handleParseRelease(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		httpError(w, "Name is required", http.StatusBadRequest)
 		return
 	}
 
@@ -2096,7 +2746,7 @@ func (s *Server) handleWantedItems(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		items, err := s.db.GetWantedItems()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if items == nil {
@@ -2107,12 +2757,12 @@ func (s *Server) handleWantedItems(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var item database.WantedItem
 		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if item.Title == "" || item.TmdbID == 0 {
-			http.Error(w, "Title and tmdbId are required", http.StatusBadRequest)
+			httpError(w, "Title and tmdbId are required", http.StatusBadRequest)
 			return
 		}
 
@@ -2124,15 +2774,31 @@ func (s *Server) handleWantedItems(w http.ResponseWriter, r *http.Request) {
 			item.Seasons = "[]"
 		}
 
+		if item.Priority == "" {
+			item.Priority = database.PriorityNormal
+		} else if !database.ValidPriority(item.Priority) {
+			httpError(w, "Priority must be low, normal or high", http.StatusBadRequest)
+			return
+		}
+
+		if item.Edition != nil {
+			edition, ok := editionParam(*item.Edition)
+			if !ok {
+				httpError(w, "Unknown edition", http.StatusBadRequest)
+				return
+			}
+			item.Edition = edition
+		}
+
 		// Check if already exists
 		existing, _ := s.db.GetWantedByTmdb(item.Type, item.TmdbID)
 		if existing != nil {
-			http.Error(w, "Item already in wanted list", http.StatusConflict)
+			httpError(w, "Item already in wanted list", http.StatusConflict)
 			return
 		}
 
 		if err := s.db.CreateWantedItem(&item); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -2140,7 +2806,7 @@ func (s *Server) handleWantedItems(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(item)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -2156,7 +2822,7 @@ func (s *Server) handleWantedItem(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
@@ -2164,7 +2830,7 @@ func (s *Server) handleWantedItem(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		item, err := s.db.GetWantedItem(id)
 		if err != nil {
-			http.Error(w, "Item not found", http.StatusNotFound)
+			httpError(w, "Item not found", http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(item)
@@ -2172,19 +2838,28 @@ func (s *Server) handleWantedItem(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		item, err := s.db.GetWantedItem(id)
 		if err != nil {
-			http.Error(w, "Item not found", http.StatusNotFound)
+			httpError(w, "Item not found", http.StatusNotFound)
 			return
 		}
 
 		var update struct {
-			QualityProfileID *int64 `json:"qualityProfileId"`
-			Monitored        *bool  `json:"monitored"`
-			Seasons          string `json:"seasons"`
+			QualityProfileID *int64  `json:"qualityProfileId"`
+			Monitored        *bool   `json:"monitored"`
+			Seasons          string  `json:"seasons"`
+			Edition          *string `json:"edition"` // "" for any edition
+			Priority         *string `json:"priority"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
+		if update.Priority != nil {
+			if !database.ValidPriority(*update.Priority) {
+				httpError(w, "Priority must be low, normal or high", http.StatusBadRequest)
+				return
+			}
+			item.Priority = *update.Priority
+		}
 
 		if update.QualityProfileID != nil {
 			item.QualityProfileID = *update.QualityProfileID
@@ -2195,9 +2870,17 @@ func (s *Server) handleWantedItem(w http.ResponseWriter, r *http.Request) {
 		if update.Seasons != "" {
 			item.Seasons = update.Seasons
 		}
+		if update.Edition != nil {
+			edition, ok := editionParam(*update.Edition)
+			if !ok {
+				httpError(w, "Unknown edition", http.StatusBadRequest)
+				return
+			}
+			item.Edition = edition
+		}
 
 		if err := s.db.UpdateWantedItem(item); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -2205,13 +2888,13 @@ func (s *Server) handleWantedItem(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if err := s.db.DeleteWantedItem(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -2219,7 +2902,7 @@ func (s *Server) handleWantedSearch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2227,13 +2910,13 @@ func (s *Server) handleWantedSearch(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/wanted/search/")
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
 	item, err := s.db.GetWantedItem(id)
 	if err != nil {
-		http.Error(w, "Item not found", http.StatusNotFound)
+		httpError(w, "Item not found", http.StatusNotFound)
 		return
 	}
 
@@ -2256,7 +2939,7 @@ func (s *Server) handleWantedSearch(w http.ResponseWriter, r *http.Request) {
 
 	results, err := s.indexers.Search(params)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2301,6 +2984,7 @@ func (s *Server) handleWantedSearch(w http.ResponseWriter, r *http.Request) {
 	scoredResults := make([]indexer.ScoredSearchResult, 0, len(results))
 	for _, result := range results {
 		parsed := parser.Parse(result.Title)
+		parsed.Size = result.Size
 		qualityTier := quality.ComputeQualityTier(parsed)
 
 		// Convert HDR string to slice (indexer uses []string)
@@ -2343,6 +3027,7 @@ func (s *Server) handleWantedSearch(w http.ResponseWriter, r *http.Request) {
 
 		scoredResults = append(scoredResults, scored)
 	}
+	s.markExcludedReleases(scoredResults, item.Type, item.TmdbID)
 
 	// Sort by score
 	for i := 0; i < len(scoredResults)-1; i++ {
@@ -2479,11 +3164,16 @@ func (s *Server) enrichTVResults(result *metadata.DiscoverResult) *DiscoverResul
 // Public endpoint for login page background - returns poster URLs only
 func (s *Server) handlePublicTrendingPosters(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var posters []string
+	posters := []string{}
+	if !s.metadataConfigured() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"posters": posters})
+		return
+	}
 
 	// Get trending movies
 	movies, err := s.metadata.GetTrendingMovies(1)
@@ -2511,7 +3201,12 @@ func (s *Server) handlePublicTrendingPosters(w http.ResponseWriter, r *http.Requ
 
 func (s *Server) handleDiscoverTrendingMovies(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2522,7 +3217,7 @@ func (s *Server) handleDiscoverTrendingMovies(w http.ResponseWriter, r *http.Req
 
 	result, err := s.metadata.GetTrendingMovies(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2532,7 +3227,12 @@ func (s *Server) handleDiscoverTrendingMovies(w http.ResponseWriter, r *http.Req
 
 func (s *Server) handleDiscoverPopularMovies(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2543,7 +3243,7 @@ func (s *Server) handleDiscoverPopularMovies(w http.ResponseWriter, r *http.Requ
 
 	result, err := s.metadata.GetPopularMovies(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2553,7 +3253,12 @@ func (s *Server) handleDiscoverPopularMovies(w http.ResponseWriter, r *http.Requ
 
 func (s *Server) handleDiscoverUpcomingMovies(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2564,7 +3269,7 @@ func (s *Server) handleDiscoverUpcomingMovies(w http.ResponseWriter, r *http.Req
 
 	result, err := s.metadata.GetUpcomingMovies(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2574,7 +3279,12 @@ func (s *Server) handleDiscoverUpcomingMovies(w http.ResponseWriter, r *http.Req
 
 func (s *Server) handleDiscoverTheatricalReleases(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2587,7 +3297,7 @@ func (s *Server) handleDiscoverTheatricalReleases(w http.ResponseWriter, r *http
 
 	result, err := s.metadata.GetTheatricalReleases(region, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2597,7 +3307,12 @@ func (s *Server) handleDiscoverTheatricalReleases(w http.ResponseWriter, r *http
 
 func (s *Server) handleDiscoverUpcomingTV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2608,7 +3323,7 @@ func (s *Server) handleDiscoverUpcomingTV(w http.ResponseWriter, r *http.Request
 
 	result, err := s.metadata.GetUpcomingTV(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2618,7 +3333,12 @@ func (s *Server) handleDiscoverUpcomingTV(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleDiscoverTopRatedMovies(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2629,7 +3349,7 @@ func (s *Server) handleDiscoverTopRatedMovies(w http.ResponseWriter, r *http.Req
 
 	result, err := s.metadata.GetTopRatedMovies(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2639,7 +3359,12 @@ func (s *Server) handleDiscoverTopRatedMovies(w http.ResponseWriter, r *http.Req
 
 func (s *Server) handleDiscoverTrendingTV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2650,7 +3375,7 @@ func (s *Server) handleDiscoverTrendingTV(w http.ResponseWriter, r *http.Request
 
 	result, err := s.metadata.GetTrendingTV(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2660,7 +3385,12 @@ func (s *Server) handleDiscoverTrendingTV(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleDiscoverPopularTV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2671,7 +3401,7 @@ func (s *Server) handleDiscoverPopularTV(w http.ResponseWriter, r *http.Request)
 
 	result, err := s.metadata.GetPopularTV(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2681,7 +3411,12 @@ func (s *Server) handleDiscoverPopularTV(w http.ResponseWriter, r *http.Request)
 
 func (s *Server) handleDiscoverTopRatedTV(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
@@ -2692,7 +3427,7 @@ func (s *Server) handleDiscoverTopRatedTV(w http.ResponseWriter, r *http.Request
 
 	result, err := s.metadata.GetTopRatedTV(page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2702,18 +3437,18 @@ func (s *Server) handleDiscoverTopRatedTV(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleMovieGenres(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "genres")
 		return
 	}
 
 	genres, err := s.metadata.GetMovieGenres()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2723,18 +3458,18 @@ func (s *Server) handleMovieGenres(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleTVGenres(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "genres")
 		return
 	}
 
 	genres, err := s.metadata.GetTVGenres()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2744,7 +3479,7 @@ func (s *Server) handleTVGenres(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleDiscoverMoviesByGenre(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2752,7 +3487,7 @@ func (s *Server) handleDiscoverMoviesByGenre(w http.ResponseWriter, r *http.Requ
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/discover/movies/genre/")
 	genreID, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid genre ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid genre ID")
 		return
 	}
 
@@ -2761,14 +3496,14 @@ func (s *Server) handleDiscoverMoviesByGenre(w http.ResponseWriter, r *http.Requ
 		page, _ = strconv.Atoi(p)
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
 	result, err := s.metadata.GetMoviesByGenre(genreID, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2778,7 +3513,7 @@ func (s *Server) handleDiscoverMoviesByGenre(w http.ResponseWriter, r *http.Requ
 
 func (s *Server) handleDiscoverTVByGenre(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2786,7 +3521,7 @@ func (s *Server) handleDiscoverTVByGenre(w http.ResponseWriter, r *http.Request)
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/discover/shows/genre/")
 	genreID, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid genre ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid genre ID")
 		return
 	}
 
@@ -2795,14 +3530,14 @@ func (s *Server) handleDiscoverTVByGenre(w http.ResponseWriter, r *http.Request)
 		page, _ = strconv.Atoi(p)
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
 	result, err := s.metadata.GetTVByGenre(genreID, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2812,7 +3547,7 @@ func (s *Server) handleDiscoverTVByGenre(w http.ResponseWriter, r *http.Request)
 
 func (s *Server) handleDiscoverMovieDetail(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2820,25 +3555,25 @@ func (s *Server) handleDiscoverMovieDetail(w http.ResponseWriter, r *http.Reques
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/discover/movie/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
-	log.Printf("Discover movie detail request for ID: %d", id)
+	requestLog(r).Infof("Discover movie detail request for ID: %d", id)
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
 	result, err := s.metadata.GetMovieDetail(id)
 	if err != nil {
-		log.Printf("Error getting movie detail: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		requestLog(r).Errorf("Error getting movie detail: %v", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Movie %s has %d recommendations", result.Title, len(result.Recommendations))
+	requestLog(r).Infof("Movie %s has %d recommendations", result.Title, len(result.Recommendations))
 
 	// Enrich with library/request status
 	response := struct {
@@ -2867,7 +3602,7 @@ func (s *Server) handleDiscoverMovieDetail(w http.ResponseWriter, r *http.Reques
 
 func (s *Server) handleDiscoverShowDetail(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -2875,18 +3610,18 @@ func (s *Server) handleDiscoverShowDetail(w http.ResponseWriter, r *http.Request
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/discover/show/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
-	if s.metadata == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
 	result, err := s.metadata.GetShowDetail(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2919,38 +3654,39 @@ func (s *Server) handleDiscoverShowDetail(w http.ResponseWriter, r *http.Request
 // Path: /api/discover/show-season/{showId}/{seasonNumber}
 func (s *Server) handleDiscoverShowSeason(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract showId and seasonNumber from path: /api/discover/show-season/{showId}/{seasonNumber}
 	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/discover/show-season/"), "/")
 	if len(pathParts) != 2 {
-		http.Error(w, "Invalid path - expected /api/discover/show-season/{showId}/{seasonNumber}", http.StatusBadRequest)
+		httpError(w, "Invalid path - expected /api/discover/show-season/{showId}/{seasonNumber}", http.StatusBadRequest)
 		return
 	}
 
 	showID, err := strconv.ParseInt(pathParts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
 	seasonNumber, err := strconv.Atoi(pathParts[1])
 	if err != nil {
-		http.Error(w, "Invalid season number", http.StatusBadRequest)
+		httpError(w, "Invalid season number", http.StatusBadRequest)
 		return
 	}
 
-	tmdbClient := s.metadata.GetTMDBClient()
-	if tmdbClient == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
+	tmdbClient := s.metadata.GetTMDBClient()
+
 	seasonDetails, err := tmdbClient.GetSeasonDetails(showID, seasonNumber)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2960,37 +3696,41 @@ func (s *Server) handleDiscoverShowSeason(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleMovieTrailers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/trailers/movie/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
-	tmdbClient := s.metadata.GetTMDBClient()
-	if tmdbClient == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		// Extras shown on library pages just come back empty
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]interface{}{})
 		return
 	}
 
+	tmdbClient := s.metadata.GetTMDBClient()
+
 	details, err := tmdbClient.GetMovieDetails(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	trailers := make([]map[string]interface{}, 0)
 	for _, v := range details.Videos.Results {
 		trailers = append(trailers, map[string]interface{}{
-			"key":      v.Key,
-			"name":     v.Name,
-			"type":     v.Type,
-			"site":     v.Site,
-			"official": v.Official,
+			"key":       v.Key,
+			"name":      v.Name,
+			"type":      v.Type,
+			"site":      v.Site,
+			"official":  v.Official,
+			"streamUrl": s.trailerStreamURL(v.Site, v.Key),
 		})
 	}
 
@@ -3000,37 +3740,41 @@ func (s *Server) handleMovieTrailers(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleTVTrailers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/trailers/tv/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
-	tmdbClient := s.metadata.GetTMDBClient()
-	if tmdbClient == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		// Extras shown on library pages just come back empty
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]interface{}{})
 		return
 	}
 
+	tmdbClient := s.metadata.GetTMDBClient()
+
 	details, err := tmdbClient.GetTVDetails(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	trailers := make([]map[string]interface{}, 0)
 	for _, v := range details.Videos.Results {
 		trailers = append(trailers, map[string]interface{}{
-			"key":      v.Key,
-			"name":     v.Name,
-			"type":     v.Type,
-			"site":     v.Site,
-			"official": v.Official,
+			"key":       v.Key,
+			"name":      v.Name,
+			"type":      v.Type,
+			"site":      v.Site,
+			"official":  v.Official,
+			"streamUrl": s.trailerStreamURL(v.Site, v.Key),
 		})
 	}
 
@@ -3045,18 +3789,18 @@ func (s *Server) handleMovieRecommendations(w http.ResponseWriter, r *http.Reque
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid TMDB ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid TMDB ID")
 		return
 	}
 
-	if s.metadata == nil || s.metadata.GetTMDBClient() == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "results")
 		return
 	}
 
 	result, err := s.metadata.GetTMDBClient().GetMovieRecommendations(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -3072,19 +3816,21 @@ func (s *Server) handleMovieSuggestions(w http.ResponseWriter, r *http.Request)
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid movie ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid movie ID")
 		return
 	}
 
 	// Get the movie from database
 	movie, err := s.db.GetMovie(id)
 	if err != nil {
-		http.Error(w, "Movie not found", http.StatusNotFound)
+		httpError(w, "Movie not found", http.StatusNotFound)
 		return
 	}
 
-	if s.metadata == nil || s.metadata.GetTMDBClient() == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		// Extras shown on library pages just come back empty
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]interface{}{})
 		return
 	}
 
@@ -3229,19 +3975,21 @@ func (s *Server) handleShowSuggestions(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
 	// Get the show from database
 	show, err := s.db.GetShow(id)
 	if err != nil {
-		http.Error(w, "Show not found", http.StatusNotFound)
+		httpError(w, "Show not found", http.StatusNotFound)
 		return
 	}
 
-	if s.metadata == nil || s.metadata.GetTMDBClient() == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		// Extras shown on library pages just come back empty
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]interface{}{})
 		return
 	}
 
@@ -3384,7 +4132,7 @@ func (s *Server) handleShowSuggestions(w http.ResponseWriter, r *http.Request) {
 // handlePerson returns detailed information about a person (actor/crew member)
 func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -3394,12 +4142,12 @@ func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
 
 	personID, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid person ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid person ID")
 		return
 	}
 
-	if s.metadata == nil || s.metadata.GetTMDBClient() == nil {
-		http.Error(w, "TMDB not configured", http.StatusServiceUnavailable)
+	if !s.metadataConfigured() {
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
@@ -3432,7 +4180,7 @@ func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
 	creditsRes := <-creditsChan
 
 	if detailsRes.err != nil {
-		http.Error(w, "Failed to fetch person details", http.StatusInternalServerError)
+		httpError(w, "Failed to fetch person details", http.StatusInternalServerError)
 		return
 	}
 
@@ -3659,6 +4407,20 @@ func (s *Server) getKnownForCredits(creditsInterface interface{}, limit int) []m
 	return results
 }
 
+// editionParam checks a movie edition given by name or label ("extended",
+// "Director's Cut"), returning the parser's name for it, or nil for none.
+// Reports false if the edition isn't known.
+func editionParam(value string) (*string, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	edition := parser.EditionName(value)
+	if edition == "" {
+		return nil, false
+	}
+	return &edition, true
+}
+
 func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value(userContextKey).(*database.User)
 
@@ -3680,7 +4442,7 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -3703,14 +4465,35 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 			QualityProfileID *int64  `json:"qualityProfileId"`
 			QualityPresetID  *int64  `json:"qualityPresetId"`
 			Seasons          []int   `json:"seasons"` // Season numbers for TV shows
+			Edition          string  `json:"edition"` // Edition of a movie, e.g. "Director's Cut"
+			Priority         string  `json:"priority"` // low, normal or high; set by admins
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if req.Type == "" || req.TmdbID == 0 || req.Title == "" {
-			http.Error(w, "type, tmdbId, and title are required", http.StatusBadRequest)
+			httpError(w, "type, tmdbId, and title are required", http.StatusBadRequest)
+			return
+		}
+		if req.Priority == "" {
+			req.Priority = database.PriorityNormal
+		} else if !database.ValidPriority(req.Priority) {
+			httpError(w, "Priority must be low, normal or high", http.StatusBadRequest)
+			return
+		}
+		if req.Priority != database.PriorityNormal && user.Role != "admin" {
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Only administrators can set a request's priority")
+			return
+		}
+		edition, ok := editionParam(req.Edition)
+		if !ok {
+			httpError(w, "Unknown edition", http.StatusBadRequest)
+			return
+		}
+		if edition != nil && req.Type != "movie" {
+			httpError(w, "Only movies can be requested in an edition", http.StatusBadRequest)
 			return
 		}
 
@@ -3725,10 +4508,23 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 		// Check if already requested (excludes denied)
 		existing, _ := s.db.GetRequestByTmdb(user.ID, req.Type, req.TmdbID)
 		if existing != nil {
-			http.Error(w, "Already requested", http.StatusConflict)
+			localizedError(w, r, http.StatusConflict, "Already requested")
 			return
 		}
 
+		// Enforce the user's request quota
+		if user.RequestQuota != nil && user.Role != "admin" {
+			count, err := s.db.CountRecentRequests(user.ID, user.RequestQuotaDays)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if count >= *user.RequestQuota {
+				localizedError(w, r, http.StatusTooManyRequests, "Request quota exceeded (%d per %d days)", *user.RequestQuota, user.RequestQuotaDays)
+				return
+			}
+		}
+
 		// Check if there's a denied request we can reactivate
 		deniedRequest, _ := s.db.GetDeniedRequestByTmdb(user.ID, req.Type, req.TmdbID)
 		var request *database.Request
@@ -3736,20 +4532,30 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 		if deniedRequest != nil {
 			// Reactivate the denied request with potentially new seasons
 			if err := s.db.UpdateRequestStatus(deniedRequest.ID, "requested", nil); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			// Update seasons if provided
 			if seasonsJSON != nil {
 				if err := s.db.UpdateRequestSeasons(deniedRequest.ID, seasonsJSON); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+					httpError(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
 			}
 			deniedRequest.Seasons = seasonsJSON
+			if err := s.db.UpdateRequestEdition(deniedRequest.ID, edition); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			deniedRequest.Edition = edition
+			if err := s.db.UpdateRequestPriority(deniedRequest.ID, req.Priority); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			deniedRequest.Priority = req.Priority
 			request = deniedRequest
 			request.Status = "requested"
-			log.Printf("Request reactivated: id=%d type=%s tmdbId=%d title=%s seasons=%v", request.ID, request.Type, request.TmdbID, request.Title, req.Seasons)
+			requestLog(r).Infof("Request reactivated: id=%d type=%s tmdbId=%d title=%s seasons=%v", request.ID, request.Type, request.TmdbID, request.Title, req.Seasons)
 		} else {
 			// Create new request
 			request = &database.Request{
@@ -3764,13 +4570,15 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 				QualityProfileID: req.QualityProfileID,
 				QualityPresetID:  req.QualityPresetID,
 				Seasons:          seasonsJSON,
+				Edition:          edition,
+				Priority:         req.Priority,
 			}
 
 			if err := s.db.CreateRequest(request); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			log.Printf("Request created: id=%d type=%s tmdbId=%d title=%s seasons=%v", request.ID, request.Type, request.TmdbID, request.Title, req.Seasons)
+			requestLog(r).Infof("Request created: id=%d type=%s tmdbId=%d title=%s seasons=%v", request.ID, request.Type, request.TmdbID, request.Title, req.Seasons)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -3778,7 +4586,7 @@ func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(request)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -3789,13 +4597,13 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/requests/")
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid request ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid request ID")
 		return
 	}
 
 	request, err := s.db.GetRequest(id)
 	if err != nil {
-		http.Error(w, "Request not found", http.StatusNotFound)
+		httpError(w, "Request not found", http.StatusNotFound)
 		return
 	}
 
@@ -3803,7 +4611,7 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		// Users can only see their own requests
 		if user.Role != "admin" && request.UserID != user.ID {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			httpError(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
@@ -3813,22 +4621,32 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		// Only admin can update status
 		if user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 			return
 		}
 
 		var updates struct {
 			Status          string  `json:"status"`
 			StatusReason    *string `json:"statusReason"`
+			StatusReasonID  *int64  `json:"statusReasonId"` // Denial reason to use
 			QualityPresetID *int64  `json:"qualityPresetId"`
+			Priority        *string `json:"priority"`
+
+			// Reopen a denied request once the title is released; defaults
+			// to the picked reason's setting
+			RecheckAfterRelease *bool `json:"recheckAfterRelease"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
-		if updates.Status == "" {
-			http.Error(w, "status is required", http.StatusBadRequest)
+		if updates.Status == "" && updates.Priority == nil {
+			httpError(w, "status or priority is required", http.StatusBadRequest)
+			return
+		}
+		if updates.Priority != nil && !database.ValidPriority(*updates.Priority) {
+			httpError(w, "Priority must be low, normal or high", http.StatusBadRequest)
 			return
 		}
 
@@ -3839,23 +4657,73 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 			"denied":    true,
 			"available": true,
 		}
-		if !validStatuses[updates.Status] {
-			http.Error(w, "Invalid status", http.StatusBadRequest)
+		if updates.Status != "" && !validStatuses[updates.Status] {
+			httpError(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		// A new priority applies to the wanted item of an approved request
+		// too
+		if updates.Priority != nil {
+			if err := s.db.UpdateRequestPriority(id, *updates.Priority); err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			request.Priority = *updates.Priority
+			if wanted, err := s.db.GetWantedByTmdb(request.Type, request.TmdbID); err == nil && !wanted.IsUpgrade {
+				wanted.Priority = *updates.Priority
+				if err := s.db.UpdateWantedItem(wanted); err != nil {
+					requestLog(r).Errorf("Failed to update wanted item priority: %v", err)
+				}
+			}
+			if updates.Status == "" {
+				request, _ = s.db.GetRequest(id)
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(request)
+				return
+			}
+		}
+
+		if updates.RecheckAfterRelease != nil && *updates.RecheckAfterRelease && updates.Status != "denied" {
+			httpError(w, "recheckAfterRelease is only for denied requests", http.StatusBadRequest)
 			return
 		}
+		recheck := updates.RecheckAfterRelease != nil && *updates.RecheckAfterRelease
+
+		// A picked denial reason becomes the request's reason, with any
+		// extra note added after it
+		if updates.StatusReasonID != nil {
+			if updates.Status != "denied" {
+				httpError(w, "statusReasonId is only for denied requests", http.StatusBadRequest)
+				return
+			}
+			reason, err := s.db.GetRequestReason(*updates.StatusReasonID)
+			if err != nil {
+				httpError(w, "Denial reason not found", http.StatusBadRequest)
+				return
+			}
+			message := reason.Render(request)
+			if updates.StatusReason != nil && strings.TrimSpace(*updates.StatusReason) != "" {
+				message += " (" + strings.TrimSpace(*updates.StatusReason) + ")"
+			}
+			updates.StatusReason = &message
+			if updates.RecheckAfterRelease == nil {
+				recheck = reason.RecheckAfterRelease
+			}
+		}
 
-		if err := s.db.UpdateRequestStatus(id, updates.Status, updates.StatusReason); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := s.db.UpdateRequestStatusWithReason(id, updates.Status, updates.StatusReason, updates.StatusReasonID, recheck); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// If approved, optionally add to wanted list
 		if updates.Status == "approved" {
-			log.Printf("Request approved: %s (tmdb=%d, type=%s)", request.Title, request.TmdbID, request.Type)
+			requestLog(r).Infof("Request approved: %s (tmdb=%d, type=%s)", request.Title, request.TmdbID, request.Type)
 			// Check if not already in wanted
 			existing, _ := s.db.GetWantedByTmdb(request.Type, request.TmdbID)
 			if existing == nil {
-				log.Printf("Adding to wanted list: %s", request.Title)
+				requestLog(r).Infof("Adding to wanted list: %s", request.Title)
 				// Use quality preset from request, or provided in update, or get default
 				var presetID *int64
 				if request.QualityPresetID != nil && *request.QualityPresetID > 0 {
@@ -3896,20 +4764,27 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 					QualityPresetID: presetID,
 					Monitored:       true,
 					Seasons:         seasonsStr,
+					Edition:         request.Edition,
+					Priority:        request.Priority,
 				}
 				if err := s.db.CreateWantedItem(wanted); err != nil {
-					log.Printf("Failed to create wanted item: %v", err)
+					requestLog(r).Errorf("Failed to create wanted item: %v", err)
 				}
 
 				// Trigger immediate search for the item
 				if s.scheduler != nil {
-					log.Printf("Triggering search for: %s", request.Title)
+					requestLog(r).Infof("Triggering search for: %s", request.Title)
 					go s.scheduler.SearchWantedItem(request.TmdbID, request.Type)
 				} else {
-					log.Printf("Scheduler is nil, cannot trigger search")
+					requestLog(r).Infof("Scheduler is nil, cannot trigger search")
 				}
 			} else {
-				log.Printf("Already in wanted list: %s", request.Title)
+				requestLog(r).Infof("Already in wanted list: %s", request.Title)
+			}
+
+			// Give the request an ETA straight away
+			if s.acquisition != nil {
+				go s.acquisition.RefreshRequestETAs()
 			}
 
 			// Notify the requesting user that their request was approved
@@ -3936,41 +4811,57 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		// Users can only delete their own pending requests
 		if user.Role != "admin" {
 			if request.UserID != user.ID {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				httpError(w, "Forbidden", http.StatusForbidden)
 				return
 			}
 			if request.Status != "requested" {
-				http.Error(w, "Cannot delete processed request", http.StatusForbidden)
+				localizedError(w, r, http.StatusForbidden, "Cannot delete processed request")
 				return
 			}
 		}
 
 		if err := s.db.DeleteRequest(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleClearDeniedRequests(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if isDryRun(r) {
+		s.simulateClearDeniedRequests(w, r)
+		return
+	}
+	if s.holdForApproval(w, r, opClearDeniedRequests, "Delete all denied requests", nil, nil) {
 		return
 	}
 
-	count, err := s.db.DeleteDeniedRequests()
+	result, err := s.clearDeniedRequests()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int64{"deleted": count})
+	json.NewEncoder(w).Encode(result)
+}
+
+// clearDeniedRequests deletes every denied request
+func (s *Server) clearDeniedRequests() (interface{}, error) {
+	count, err := s.db.DeleteDeniedRequests()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"deleted": count}, nil
 }
 
 // Watchlist handlers
@@ -3983,7 +4874,7 @@ func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		items, err := s.db.GetWatchlist(user.ID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -4031,7 +4922,7 @@ func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
 							}
 						}
 					}
-				} else if s.metadata != nil && s.metadata.GetTMDBClient() != nil {
+				} else if s.metadataConfigured() {
 					// Fetch from TMDB
 					details, err := s.metadata.GetTMDBClient().GetMovieDetails(item.TmdbID)
 					if err == nil {
@@ -4061,7 +4952,7 @@ func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
 						e.BackdropPath = show.BackdropPath
 						e.Year = show.Year
 					}
-				} else if s.metadata != nil && s.metadata.GetTMDBClient() != nil {
+				} else if s.metadataConfigured() {
 					// Fetch from TMDB
 					details, err := s.metadata.GetTMDBClient().GetTVDetails(item.TmdbID)
 					if err == nil {
@@ -4092,12 +4983,12 @@ func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
 			MediaType string `json:"mediaType"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if req.TmdbID == 0 || (req.MediaType != "movie" && req.MediaType != "tv") {
-			http.Error(w, "tmdbId and valid mediaType (movie/tv) are required", http.StatusBadRequest)
+			httpError(w, "tmdbId and valid mediaType (movie/tv) are required", http.StatusBadRequest)
 			return
 		}
 
@@ -4108,14 +4999,14 @@ func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.AddToWatchlist(item); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusCreated)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -4127,19 +5018,19 @@ func (s *Server) handleWatchlistItem(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) < 2 {
-		http.Error(w, "Invalid path, expected /api/watchlist/{tmdbId}/{mediaType}", http.StatusBadRequest)
+		httpError(w, "Invalid path, expected /api/watchlist/{tmdbId}/{mediaType}", http.StatusBadRequest)
 		return
 	}
 
 	tmdbID, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid TMDB ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid TMDB ID")
 		return
 	}
 
 	mediaType := parts[1]
 	if mediaType != "movie" && mediaType != "tv" {
-		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		httpError(w, "Invalid media type", http.StatusBadRequest)
 		return
 	}
 
@@ -4148,7 +5039,7 @@ func (s *Server) handleWatchlistItem(w http.ResponseWriter, r *http.Request) {
 		// Check if in watchlist
 		inWatchlist, err := s.db.IsInWatchlist(user.ID, tmdbID, mediaType)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -4157,14 +5048,14 @@ func (s *Server) handleWatchlistItem(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodDelete:
 		if err := s.db.RemoveFromWatchlist(user.ID, tmdbID, mediaType); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -4172,43 +5063,62 @@ func (s *Server) handleWatchlistItem(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleArtists(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	artists, err := s.db.GetArtists()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if artists == nil {
-		artists = []database.Artist{}
+	user := s.getCurrentUser(r)
+	visible := []database.Artist{}
+	for _, a := range artists {
+		if user == nil || user.CanAccessLibrary(a.LibraryID) {
+			visible = append(visible, a)
+		}
 	}
+	artists = visible
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(artists)
 }
 
 func (s *Server) handleArtist(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/artists/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	// Parse path: /api/artists/{id} or /api/artists/{id}/refresh
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/artists/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, "artist", id) {
 		return
 	}
+	refresh := len(parts) == 2 && parts[1] == "refresh"
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if (refresh && r.Method != http.MethodPost) || (!refresh && r.Method != http.MethodGet) {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	artist, err := s.db.GetArtist(id)
 	if err != nil {
-		http.Error(w, "Artist not found", http.StatusNotFound)
+		httpError(w, "Artist not found", http.StatusNotFound)
 		return
 	}
 
+	// Handle refresh endpoint
+	if refresh {
+		if !s.refreshMusicMetadata(w, func() error { return s.metadata.FetchArtistMetadata(artist) }) {
+			return
+		}
+		// Reload artist to get updated data
+		artist, _ = s.db.GetArtist(id)
+	}
+
 	// Get albums for this artist
 	albums, _ := s.db.GetAlbumsByArtist(id)
 	if albums == nil {
@@ -4229,43 +5139,72 @@ func (s *Server) handleArtist(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleAlbums(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	albums, err := s.db.GetAlbums()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if albums == nil {
-		albums = []database.Album{}
+	// Albums are in their artist's library
+	user := s.getCurrentUser(r)
+	artists, err := s.db.GetArtists()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	allowed := make(map[int64]bool, len(artists))
+	for _, a := range artists {
+		allowed[a.ID] = user == nil || user.CanAccessLibrary(a.LibraryID)
+	}
+	visible := []database.Album{}
+	for _, a := range albums {
+		if allowed[a.ArtistID] {
+			visible = append(visible, a)
+		}
+	}
+	albums = visible
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(albums)
 }
 
 func (s *Server) handleAlbum(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/albums/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	// Parse path: /api/albums/{id} or /api/albums/{id}/refresh
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/albums/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, "album", id) {
 		return
 	}
+	refresh := len(parts) == 2 && parts[1] == "refresh"
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if (refresh && r.Method != http.MethodPost) || (!refresh && r.Method != http.MethodGet) {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	album, err := s.db.GetAlbum(id)
 	if err != nil {
-		http.Error(w, "Album not found", http.StatusNotFound)
+		httpError(w, "Album not found", http.StatusNotFound)
 		return
 	}
 
+	// Handle refresh endpoint
+	if refresh {
+		if !s.refreshMusicMetadata(w, func() error { return s.metadata.FetchAlbumMetadata(album) }) {
+			return
+		}
+		// Reload album to get updated data
+		album, _ = s.db.GetAlbum(id)
+	}
+
 	// Get artist info
 	artist, _ := s.db.GetArtist(album.ArtistID)
 
@@ -4289,22 +5228,45 @@ func (s *Server) handleAlbum(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// refreshMusicMetadata looks an artist or album up on MusicBrainz again for
+// a refresh endpoint, writing the error and returning false if it can't be
+func (s *Server) refreshMusicMetadata(w http.ResponseWriter, fetch func() error) bool {
+	if s.metadata == nil || !s.metadata.MusicBrainzEnabled() {
+		httpError(w, "MusicBrainz lookups are turned off", http.StatusBadRequest)
+		return false
+	}
+	if err := fetch(); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleTrack(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/tracks/")
+	idStr, played := strings.CutSuffix(idStr, "/played")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, "track", id) {
+		return
+	}
+
+	if played {
+		s.handleTrackPlayed(w, r, id)
 		return
 	}
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	track, err := s.db.GetTrack(id)
 	if err != nil {
-		http.Error(w, "Track not found", http.StatusNotFound)
+		httpError(w, "Track not found", http.StatusNotFound)
 		return
 	}
 
@@ -4316,40 +5278,53 @@ func (s *Server) handleTrack(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleBooks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	books, err := s.db.GetBooks()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if books == nil {
-		books = []database.Book{}
+	user := s.getCurrentUser(r)
+	visible := []database.Book{}
+	for _, b := range books {
+		if user == nil || user.CanAccessLibrary(b.LibraryID) {
+			visible = append(visible, b)
+		}
 	}
+	books = visible
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(books)
 }
 
 func (s *Server) handleBook(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/books/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	// Parse path: /api/books/{id}, or /api/books/{id}/progress|toc|position|annotations...
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/books/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !s.allowMediaAccess(w, r, "book", id) {
 		return
 	}
 
 	book, err := s.db.GetBook(id)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		httpError(w, "Book not found", http.StatusNotFound)
+		return
+	}
+	if len(parts) > 1 {
+		s.handleBookReading(w, r, book, parts[1:])
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -4374,25 +5349,33 @@ type SubtitleTrack struct {
 //   GET /api/subtitles/{type}/{id} - List subtitle tracks
 //   GET /api/subtitles/{type}/{id}/track/{index} - Get subtitle as WebVTT
 func (s *Server) handleSubtitles(w http.ResponseWriter, r *http.Request) {
-	log.Printf("handleSubtitles: %s %s", r.Method, r.URL.Path)
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	requestLog(r).Infof("handleSubtitles: %s %s", r.Method, r.URL.Path)
 
-	// Parse path: /api/subtitles/{type}/{id} or /api/subtitles/{type}/{id}/track/{index}
+	// Parse path: /api/subtitles/{type}/{id}, /api/subtitles/{type}/{id}/track/{index}
+	// or /api/subtitles/{type}/{id}/extract
 	path := strings.TrimPrefix(r.URL.Path, "/api/subtitles/")
 	parts := strings.Split(path, "/")
 
+	if len(parts) == 3 && parts[2] == "extract" {
+		s.handleExtractSubtitles(w, r, parts[0], parts[1])
+		return
+	}
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	if len(parts) < 2 {
-		http.Error(w, "Invalid subtitle path", http.StatusBadRequest)
+		httpError(w, "Invalid subtitle path", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, mediaType, id) {
 		return
 	}
 
@@ -4402,19 +5385,19 @@ func (s *Server) handleSubtitles(w http.ResponseWriter, r *http.Request) {
 	case "movie":
 		movie, err := s.db.GetMovie(id)
 		if err != nil {
-			http.Error(w, "Movie not found", http.StatusNotFound)
+			httpError(w, "Movie not found", http.StatusNotFound)
 			return
 		}
 		filePath = movie.Path
 	case "episode":
 		episode, err := s.db.GetEpisode(id)
 		if err != nil {
-			http.Error(w, "Episode not found", http.StatusNotFound)
+			httpError(w, "Episode not found", http.StatusNotFound)
 			return
 		}
 		filePath = episode.Path
 	default:
-		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		httpError(w, "Invalid media type", http.StatusBadRequest)
 		return
 	}
 
@@ -4423,7 +5406,7 @@ func (s *Server) handleSubtitles(w http.ResponseWriter, r *http.Request) {
 		// Extract specific subtitle track
 		trackIndex, err := strconv.Atoi(parts[3])
 		if err != nil {
-			http.Error(w, "Invalid track index", http.StatusBadRequest)
+			httpError(w, "Invalid track index", http.StatusBadRequest)
 			return
 		}
 		s.serveSubtitleTrack(w, r, filePath, trackIndex)
@@ -4436,6 +5419,80 @@ func (s *Server) handleSubtitles(w http.ResponseWriter, r *http.Request) {
 
 // listSubtitleTracks uses ffprobe to list all subtitle streams in a file
 // and scans for external subtitle files
+// handleExtractSubtitles handles POST /api/subtitles/{type}/{id}/extract,
+// which extracts the file's embedded subtitles again as a job
+func (s *Server) handleExtractSubtitles(w http.ResponseWriter, r *http.Request, mediaType, idStr string) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if user := s.getCurrentUser(r); user == nil || user.Role != "admin" {
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	var filePath, title string
+	switch mediaType {
+	case "movie":
+		movie, err := s.db.GetMovie(id)
+		if err != nil {
+			httpError(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		filePath, title = movie.Path, movie.Title
+	case "episode":
+		episode, err := s.db.GetEpisode(id)
+		if err != nil {
+			httpError(w, "Episode not found", http.StatusNotFound)
+			return
+		}
+		filePath, title = episode.Path, filepath.Base(episode.Path)
+	default:
+		httpError(w, "Subtitles can only be extracted from movies and episodes", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		httpError(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	job, err := s.submitJob(r, jobSubtitleExtraction, "Extract subtitles of "+title, func(ctx context.Context, p *jobs.Progress) error {
+		s.scanner.ClearExtractedSubtitles(mediaType, id, filePath)
+		// Tracks extracted on demand since are cached in memory too
+		s.subtitleMu.Lock()
+		for key := range s.subtitleCache {
+			if strings.HasPrefix(key, filePath+":") {
+				delete(s.subtitleCache, key)
+			}
+		}
+		s.subtitleMu.Unlock()
+
+		tracks, err := s.scanner.ExtractSubtitleTracks(ctx, mediaType, id, filePath)
+		if err != nil {
+			return err
+		}
+		p.SetMessage(fmt.Sprintf("%d subtitle tracks ready", tracks))
+		return nil
+	})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "extracting",
+		"message": "Subtitle extraction started",
+		"jobId":   job.ID,
+	})
+}
+
 func (s *Server) listSubtitleTracks(w http.ResponseWriter, filePath string) {
 	tracks := []SubtitleTrack{}
 
@@ -4630,15 +5687,15 @@ func (s *Server) findExternalSubtitles(mediaPath string, startIndex int) []Subti
 
 // serveSubtitleTrack extracts and serves a subtitle track as WebVTT
 func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, filePath string, trackIndex int) {
-	log.Printf("serveSubtitleTrack: file=%s, trackIndex=%d", filePath, trackIndex)
+	requestLog(r).Infof("serveSubtitleTrack: file=%s, trackIndex=%d", filePath, trackIndex)
 
 	// First, get count of embedded subtitles to determine if this is external
 	embeddedCount := s.countEmbeddedSubtitles(filePath)
-	log.Printf("serveSubtitleTrack: embeddedCount=%d", embeddedCount)
+	requestLog(r).Infof("serveSubtitleTrack: embeddedCount=%d", embeddedCount)
 
 	if trackIndex >= embeddedCount {
 		// This is an external subtitle file
-		log.Printf("serveSubtitleTrack: looking for external subtitle")
+		requestLog(r).Infof("serveSubtitleTrack: looking for external subtitle")
 		externalTracks := s.findExternalSubtitles(filePath, embeddedCount)
 		for _, track := range externalTracks {
 			if track.Index == trackIndex {
@@ -4646,7 +5703,7 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 				return
 			}
 		}
-		http.Error(w, "Subtitle track not found", http.StatusNotFound)
+		httpError(w, "Subtitle track not found", http.StatusNotFound)
 		return
 	}
 
@@ -4663,7 +5720,7 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 			if strings.HasPrefix(f.Name(), pattern) && strings.HasSuffix(f.Name(), ".vtt") {
 				vttPath := filepath.Join(subtitlesDir, f.Name())
 				if cached, err := os.ReadFile(vttPath); err == nil {
-					log.Printf("serveSubtitleTrack: found pre-extracted subtitle: %s", f.Name())
+					requestLog(r).Infof("serveSubtitleTrack: found pre-extracted subtitle: %s", f.Name())
 					w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
 					w.Header().Set("Cache-Control", "max-age=86400")
 					w.Write(cached)
@@ -4676,11 +5733,11 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 	// Fallback: check central cache directory
 	cacheDir := filepath.Join(filepath.Dir(s.config.DBPath), "subtitles")
 	os.MkdirAll(cacheDir, 0755)
-	cacheFile := filepath.Join(cacheDir, fmt.Sprintf("%s.track%d.vtt", baseName, trackIndex))
+	cacheFile := scanner.SubtitleCachePath(cacheDir, filePath, trackIndex)
 
 	// Check disk cache
 	if cached, err := os.ReadFile(cacheFile); err == nil {
-		log.Printf("serveSubtitleTrack: disk cache hit, returning %d bytes", len(cached))
+		requestLog(r).Infof("serveSubtitleTrack: disk cache hit, returning %d bytes", len(cached))
 		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
 		w.Header().Set("Cache-Control", "max-age=86400")
 		w.Write(cached)
@@ -4694,7 +5751,7 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 	s.subtitleMu.RUnlock()
 
 	if found {
-		log.Printf("serveSubtitleTrack: memory cache hit, returning %d bytes", len(cached))
+		requestLog(r).Infof("serveSubtitleTrack: memory cache hit, returning %d bytes", len(cached))
 		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
 		w.Header().Set("Cache-Control", "max-age=86400")
 		w.Write(cached)
@@ -4702,7 +5759,7 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 	}
 
 	// Embedded subtitle - extract and convert to WebVTT
-	log.Printf("serveSubtitleTrack: cache miss, extracting from: %s (this may take 1-2 minutes for large files)", filePath)
+	requestLog(r).Infof("serveSubtitleTrack: cache miss, extracting from: %s (this may take 1-2 minutes for large files)", filePath)
 
 	var output []byte
 	var err error
@@ -4715,15 +5772,15 @@ func (s *Server) serveSubtitleTrack(w http.ResponseWriter, r *http.Request, file
 	}
 
 	if err != nil {
-		log.Printf("serveSubtitleTrack: extraction error: %v", err)
-		http.Error(w, "Failed to extract subtitle", http.StatusInternalServerError)
+		requestLog(r).Errorf("serveSubtitleTrack: extraction error: %v", err)
+		httpError(w, "Failed to extract subtitle", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("serveSubtitleTrack: extracted %d bytes, caching to disk", len(output))
+	requestLog(r).Infof("serveSubtitleTrack: extracted %d bytes, caching to disk", len(output))
 
 	// Save to disk cache (persistent)
 	if err := os.WriteFile(cacheFile, output, 0644); err != nil {
-		log.Printf("serveSubtitleTrack: failed to save to disk cache: %v", err)
+		requestLog(r).Errorf("serveSubtitleTrack: failed to save to disk cache: %v", err)
 	}
 
 	// Also cache in memory for current session
@@ -4781,7 +5838,7 @@ func (s *Server) extractSubtitleMKV(filePath string, trackIndex int) ([]byte, er
 	cmd = exec.Command("mkvextract", "tracks", filePath, fmt.Sprintf("%d:%s", actualIndex, tmpPath))
 	if err := cmd.Run(); err != nil {
 		// Fall back to ffmpeg if mkvextract fails
-		log.Printf("mkvextract failed, falling back to ffmpeg: %v", err)
+		logger.Errorf("mkvextract failed, falling back to ffmpeg: %v", err)
 		return s.extractSubtitleFFmpeg(filePath, trackIndex)
 	}
 
@@ -4871,7 +5928,7 @@ func (s *Server) serveExternalSubtitle(w http.ResponseWriter, subPath string) {
 	if ext == ".vtt" {
 		content, err := os.ReadFile(subPath)
 		if err != nil {
-			http.Error(w, "Failed to read subtitle file", http.StatusInternalServerError)
+			httpError(w, "Failed to read subtitle file", http.StatusInternalServerError)
 			return
 		}
 		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
@@ -4889,7 +5946,7 @@ func (s *Server) serveExternalSubtitle(w http.ResponseWriter, subPath string) {
 
 	output, err := cmd.Output()
 	if err != nil {
-		http.Error(w, "Failed to convert subtitle", http.StatusInternalServerError)
+		httpError(w, "Failed to convert subtitle", http.StatusInternalServerError)
 		return
 	}
 
@@ -4907,7 +5964,7 @@ func (s *Server) handleQualityPresets(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		presets, err := s.db.GetQualityPresets()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(presets)
@@ -4916,24 +5973,24 @@ func (s *Server) handleQualityPresets(w http.ResponseWriter, r *http.Request) {
 		// POST requires admin
 		user := r.Context().Value(userContextKey).(*database.User)
 		if user.Role != "admin" {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Admin access required")
 			return
 		}
 
 		var preset database.QualityPreset
 		if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.CreateQualityPreset(&preset); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(preset)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -4942,74 +5999,135 @@ func (s *Server) handleQualityPreset(w http.ResponseWriter, r *http.Request) {
 
 	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/quality/presets/"), "/")
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Preset ID required", http.StatusBadRequest)
+		httpError(w, "Preset ID required", http.StatusBadRequest)
 		return
 	}
 
 	idStr := parts[0]
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid preset ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid preset ID")
+		return
+	}
+
+	// Check for /api/quality/presets/:id/simulate - dry-run a search with this preset
+	if len(parts) > 1 && parts[1] == "simulate" {
+		s.handleQualityPresetSimulate(w, r, id)
 		return
 	}
 
 	// Check for /api/quality/presets/:id/default
 	if len(parts) > 1 && parts[1] == "default" && r.Method == http.MethodPost {
 		if err := s.db.SetDefaultQualityPreset(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Check for /api/quality/presets/:id/toggle - toggle enabled status
+	if len(parts) > 1 && parts[1] == "toggle" && r.Method == http.MethodPost {
+		var req struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := s.db.ToggleQualityPresetEnabled(id, req.Enabled); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "enabled": req.Enabled})
+		return
+	}
+
+	// Check for /api/quality/presets/:id/priority - update priority
+	if len(parts) > 1 && parts[1] == "priority" && r.Method == http.MethodPost {
+		var req struct {
+			Priority int `json:"priority"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := s.db.UpdateQualityPresetPriority(id, req.Priority); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "priority": req.Priority})
+		return
+	}
+
+	// Check for /api/quality/presets/:id/anime-preferences - update anime-specific preferences
+	if len(parts) > 1 && parts[1] == "anime-preferences" && r.Method == http.MethodPatch {
+		var req struct {
+			PreferDualAudio   *bool   `json:"preferDualAudio,omitempty"`
+			PreferDubbed      *bool   `json:"preferDubbed,omitempty"`
+			PreferredLanguage *string `json:"preferredLanguage,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if err := s.db.UpdateQualityPresetAnimePreferences(id, req.PreferDualAudio, req.PreferDubbed, req.PreferredLanguage); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 		return
 	}
 
-	// Check for /api/quality/presets/:id/toggle - toggle enabled status
-	if len(parts) > 1 && parts[1] == "toggle" && r.Method == http.MethodPost {
+	// Check for /api/quality/presets/:id/thresholds - update seeder and release age thresholds (built-in presets too)
+	if len(parts) > 1 && parts[1] == "thresholds" && r.Method == http.MethodPatch {
 		var req struct {
-			Enabled bool `json:"enabled"`
+			MinSeeders         int `json:"minSeeders"`
+			MaxAgeDays         int `json:"maxAgeDays"`
+			FirstSeenWaitHours int `json:"firstSeenWaitHours"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
-		if err := s.db.ToggleQualityPresetEnabled(id, req.Enabled); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if req.MinSeeders < 0 || req.MaxAgeDays < 0 || req.FirstSeenWaitHours < 0 {
+			httpError(w, "Thresholds cannot be negative", http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "enabled": req.Enabled})
+		if err := s.db.UpdateQualityPresetGrabThresholds(id, req.MinSeeders, req.MaxAgeDays, req.FirstSeenWaitHours); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 		return
 	}
 
-	// Check for /api/quality/presets/:id/priority - update priority
-	if len(parts) > 1 && parts[1] == "priority" && r.Method == http.MethodPost {
+	// Check for /api/quality/presets/:id/size-limits - update size and bitrate limits (built-in presets too)
+	if len(parts) > 1 && parts[1] == "size-limits" && r.Method == http.MethodPatch {
 		var req struct {
-			Priority int `json:"priority"`
+			MinSizePerHour int `json:"minSizePerHour"`
+			MaxSizePerHour int `json:"maxSizePerHour"`
+			MinBitrate     int `json:"minBitrate"`
+			MaxBitrate     int `json:"maxBitrate"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
-		if err := s.db.UpdateQualityPresetPriority(id, req.Priority); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if req.MinSizePerHour < 0 || req.MaxSizePerHour < 0 || req.MinBitrate < 0 || req.MaxBitrate < 0 {
+			httpError(w, "Limits cannot be negative", http.StatusBadRequest)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "priority": req.Priority})
-		return
-	}
-
-	// Check for /api/quality/presets/:id/anime-preferences - update anime-specific preferences
-	if len(parts) > 1 && parts[1] == "anime-preferences" && r.Method == http.MethodPatch {
-		var req struct {
-			PreferDualAudio   *bool   `json:"preferDualAudio,omitempty"`
-			PreferDubbed      *bool   `json:"preferDubbed,omitempty"`
-			PreferredLanguage *string `json:"preferredLanguage,omitempty"`
+		if req.MaxSizePerHour > 0 && req.MinSizePerHour > req.MaxSizePerHour {
+			httpError(w, "minSizePerHour cannot exceed maxSizePerHour", http.StatusBadRequest)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if req.MaxBitrate > 0 && req.MinBitrate > req.MaxBitrate {
+			httpError(w, "minBitrate cannot exceed maxBitrate", http.StatusBadRequest)
 			return
 		}
-		if err := s.db.UpdateQualityPresetAnimePreferences(id, req.PreferDualAudio, req.PreferDubbed, req.PreferredLanguage); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := s.db.UpdateQualityPresetSizeLimits(id, req.MinSizePerHour, req.MaxSizePerHour, req.MinBitrate, req.MaxBitrate); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -5020,7 +6138,7 @@ func (s *Server) handleQualityPreset(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		preset, err := s.db.GetQualityPreset(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			httpError(w, err.Error(), http.StatusNotFound)
 			return
 		}
 		json.NewEncoder(w).Encode(preset)
@@ -5028,35 +6146,36 @@ func (s *Server) handleQualityPreset(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var preset database.QualityPreset
 		if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		preset.ID = id
 		if err := s.db.UpdateQualityPreset(&preset); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(preset)
 
 	case http.MethodDelete:
 		if err := s.db.DeleteQualityPreset(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // Import History handler
 
+// Deprecated: GET /api/history includes these events; kept for older clients
 func (s *Server) handleImportHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -5069,7 +6188,7 @@ func (s *Server) handleImportHistory(w http.ResponseWriter, r *http.Request) {
 
 	history, err := s.db.GetImportHistory(limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(history)
@@ -5081,13 +6200,13 @@ func (s *Server) handleDownloadItems(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	downloads, err := s.acquisition.GetActiveDownloads()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -5161,10 +6280,23 @@ func (s *Server) handleDownloadItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Extract ID from URL
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/download-items/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	path := strings.TrimPrefix(r.URL.Path, "/api/download-items/")
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid download ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid download ID")
+		return
+	}
+
+	// Import step retries: /api/download-items/{id}/steps/{n}/retry
+	if len(parts) == 4 && parts[1] == "steps" && parts[3] == "retry" {
+		s.handleImportStepRetry(w, r, id, parts[2])
+		return
+	}
+
+	// Manual import queue actions: /api/download-items/{id}/import and /ignore
+	if len(parts) > 1 {
+		s.handleDownloadItemAction(w, r, id, parts[1])
 		return
 	}
 
@@ -5175,14 +6307,115 @@ func (s *Server) handleDownloadItem(w http.ResponseWriter, r *http.Request) {
 		deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
 
 		if err := s.acquisition.DeleteTrackedDownload(id, deleteFromClient, deleteFiles); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDownloadItemAction handles manual import queue actions for a tracked download
+func (s *Server) handleDownloadItemAction(w http.ResponseWriter, r *http.Request, id int64, action string) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	td, err := s.acquisition.GetTrackedDownload(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if td == nil {
+		httpError(w, "Download not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "import":
+		var req struct {
+			MediaID   *int64 `json:"mediaId"` // TMDB ID
+			MediaType string `json:"mediaType"`
+		}
+		// Body is optional when the download is already matched
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.MediaType {
+		case "", "movie", "show", "music", "book":
+		default:
+			httpError(w, "Invalid media type (must be movie, show, music or book)", http.StatusBadRequest)
+			return
+		}
+		mediaType := req.MediaType
+		if mediaType == "" {
+			mediaType = td.MediaType
+		}
+		// Music and books are named from tags and filenames, so they don't need a match
+		if mediaType != "music" && mediaType != "book" && req.MediaID == nil && td.MediaID == nil {
+			httpError(w, "mediaId is required for unmatched downloads", http.StatusBadRequest)
+			return
+		}
+
+		err = s.acquisition.ManualImport(td, req.MediaID, req.MediaType)
+	case "ignore":
+		err = s.acquisition.IgnoreImport(td)
+	default:
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if err == download.ErrNotAwaitingImport {
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if action == "import" {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(td)
+}
+
+// handleImportStepRetry retries a failed step of a download's import
+func (s *Server) handleImportStepRetry(w http.ResponseWriter, r *http.Request, id int64, stepParam string) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	td, err := s.acquisition.GetTrackedDownload(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	if td == nil {
+		httpError(w, "Download not found", http.StatusNotFound)
+		return
+	}
+	step, err := strconv.Atoi(stepParam)
+	if err != nil || step < 0 || step >= len(td.ImportSteps) {
+		httpError(w, "Import step not found", http.StatusNotFound)
+		return
+	}
+
+	err = s.acquisition.RetryImportStep(td, step)
+	if err == download.ErrNotAwaitingImport || err == download.ErrImportStepNotFailed {
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(td)
 }
 
 // Naming Templates handler
@@ -5194,7 +6427,7 @@ func (s *Server) handleNamingTemplates(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		templates, err := s.db.GetNamingTemplates()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(templates)
@@ -5202,17 +6435,17 @@ func (s *Server) handleNamingTemplates(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var template database.NamingTemplate
 		if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.UpdateNamingTemplate(&template); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(template)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5237,14 +6470,14 @@ func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Get storage settings
 	settings, err := s.db.GetAllSettings()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -5268,11 +6501,11 @@ func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
 	// Get libraries and calculate sizes by scanning folders
 	libraries, err := s.db.GetLibraries()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var moviesSize, tvSize, musicSize, booksSize int64
+	var moviesSize, tvSize, musicSize, booksSize, photosSize int64
 	for _, lib := range libraries {
 		size := calculateDirSize(lib.Path)
 		switch lib.Type {
@@ -5282,8 +6515,10 @@ func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
 			tvSize += size
 		case "music":
 			musicSize += size
-		case "books":
+		case "books", "comics":
 			booksSize += size
+		case "photos":
+			photosSize += size
 		}
 	}
 
@@ -5306,6 +6541,7 @@ func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
 		TvSize           int64              `json:"tvSize"`
 		MusicSize        int64              `json:"musicSize"`
 		BooksSize        int64              `json:"booksSize"`
+		PhotosSize       int64              `json:"photosSize"`
 		DiskUsage        *storage.DiskUsage `json:"diskUsage,omitempty"`
 	}{
 		ThresholdGB:      thresholdGB,
@@ -5315,6 +6551,7 @@ func (s *Server) handleStorageStatus(w http.ResponseWriter, r *http.Request) {
 		TvSize:           tvSize,
 		MusicSize:        musicSize,
 		BooksSize:        booksSize,
+		PhotosSize:       photosSize,
 		DiskUsage:        diskUsage,
 	}
 
@@ -5325,7 +6562,7 @@ func (s *Server) handleStorageAnalytics(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -5344,35 +6581,35 @@ func (s *Server) handleStorageAnalytics(w http.ResponseWriter, r *http.Request)
 	// Get storage by library
 	byLibrary, err := s.db.GetStorageByLibrary()
 	if err != nil {
-		log.Printf("Error getting storage by library: %v", err)
+		requestLog(r).Errorf("Error getting storage by library: %v", err)
 		byLibrary = []database.LibrarySize{}
 	}
 
 	// Get storage by quality
 	byQuality, err := s.db.GetStorageByQuality()
 	if err != nil {
-		log.Printf("Error getting storage by quality: %v", err)
+		requestLog(r).Errorf("Error getting storage by quality: %v", err)
 		byQuality = []database.QualitySize{}
 	}
 
 	// Get storage by year
 	byYear, err := s.db.GetStorageByYear()
 	if err != nil {
-		log.Printf("Error getting storage by year: %v", err)
+		requestLog(r).Errorf("Error getting storage by year: %v", err)
 		byYear = []database.YearSize{}
 	}
 
 	// Get largest items
 	largest, err := s.db.GetLargestItems(20)
 	if err != nil {
-		log.Printf("Error getting largest items: %v", err)
+		requestLog(r).Errorf("Error getting largest items: %v", err)
 		largest = []database.LargestItem{}
 	}
 
 	// Get duplicates
 	duplicates, err := s.db.GetMovieDuplicates()
 	if err != nil {
-		log.Printf("Error getting duplicates: %v", err)
+		requestLog(r).Errorf("Error getting duplicates: %v", err)
 		duplicates = []database.DuplicateItem{}
 	}
 
@@ -5399,7 +6636,7 @@ func (s *Server) handleBlocklist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		entries, err := s.db.GetBlocklist()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(entries)
@@ -5407,11 +6644,11 @@ func (s *Server) handleBlocklist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var entry database.BlocklistEntry
 		if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.AddToBlocklist(&entry); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
@@ -5421,14 +6658,14 @@ func (s *Server) handleBlocklist(w http.ResponseWriter, r *http.Request) {
 		// Clear all or expired
 		if r.URL.Query().Get("expired") == "true" {
 			if err := s.db.ClearExpiredBlocklist(); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5438,30 +6675,31 @@ func (s *Server) handleBlocklistItem(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/blocklist/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodDelete:
 		if err := s.db.RemoveFromBlocklist(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // Grab history handler
 
+// Deprecated: GET /api/history includes these events; kept for older clients
 func (s *Server) handleGrabHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -5474,7 +6712,7 @@ func (s *Server) handleGrabHistory(w http.ResponseWriter, r *http.Request) {
 
 	history, err := s.db.GetGrabHistory(limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(history)
@@ -5489,7 +6727,7 @@ func (s *Server) handleBlockedGroups(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		groups, err := s.db.GetBlockedGroups()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(groups)
@@ -5500,18 +6738,18 @@ func (s *Server) handleBlockedGroups(w http.ResponseWriter, r *http.Request) {
 			Reason string `json:"reason"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.AddBlockedGroup(req.Name, req.Reason, false); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5521,20 +6759,20 @@ func (s *Server) handleBlockedGroup(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/blocked-groups/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodDelete:
 		if err := s.db.RemoveBlockedGroup(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5545,12 +6783,12 @@ func (s *Server) handleReleaseFilters(w http.ResponseWriter, r *http.Request) {
 
 	presetIDStr := r.URL.Query().Get("preset_id")
 	if presetIDStr == "" {
-		http.Error(w, "preset_id is required", http.StatusBadRequest)
+		httpError(w, "preset_id is required", http.StatusBadRequest)
 		return
 	}
 	presetID, err := strconv.ParseInt(presetIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid preset_id", http.StatusBadRequest)
+		httpError(w, "Invalid preset_id", http.StatusBadRequest)
 		return
 	}
 
@@ -5558,7 +6796,7 @@ func (s *Server) handleReleaseFilters(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		filters, err := s.db.GetReleaseFilters(presetID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(filters)
@@ -5566,19 +6804,19 @@ func (s *Server) handleReleaseFilters(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var filter database.ReleaseFilter
 		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		filter.PresetID = presetID
 		if err := s.db.AddReleaseFilter(&filter); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(filter)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5588,20 +6826,20 @@ func (s *Server) handleReleaseFilter(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/release-filters/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodDelete:
 		if err := s.db.RemoveReleaseFilter(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5614,7 +6852,7 @@ func (s *Server) handleDelayProfiles(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		profiles, err := s.db.GetDelayProfiles()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(profiles)
@@ -5622,18 +6860,18 @@ func (s *Server) handleDelayProfiles(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var profile database.DelayProfile
 		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.CreateDelayProfile(&profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(profile)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5643,7 +6881,7 @@ func (s *Server) handleDelayProfile(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/delay-profiles/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
@@ -5651,25 +6889,25 @@ func (s *Server) handleDelayProfile(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var profile database.DelayProfile
 		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		profile.ID = id
 		if err := s.db.UpdateDelayProfile(&profile); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(profile)
 
 	case http.MethodDelete:
 		if err := s.db.DeleteDelayProfile(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5689,7 +6927,7 @@ func (s *Server) handleExclusions(w http.ResponseWriter, r *http.Request) {
 			exclusions, err = s.db.GetExclusions()
 		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(exclusions)
@@ -5697,18 +6935,18 @@ func (s *Server) handleExclusions(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		var exclusion database.Exclusion
 		if err := json.NewDecoder(r.Body).Decode(&exclusion); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		if err := s.db.AddExclusion(&exclusion); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(exclusion)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5718,20 +6956,20 @@ func (s *Server) handleExclusion(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/exclusions/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
 		return
 	}
 
 	switch r.Method {
 	case http.MethodDelete:
 		if err := s.db.RemoveExclusion(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5743,7 +6981,7 @@ func (s *Server) handleMovieQuality(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/movies/quality/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid movie ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid movie ID")
 		return
 	}
 
@@ -5752,13 +6990,13 @@ func (s *Server) handleMovieQuality(w http.ResponseWriter, r *http.Request) {
 		// Get quality status
 		status, err := s.db.GetMediaQualityStatus(id, "movie")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		// Get quality override
 		override, err := s.db.GetMediaQualityOverride(id, "movie")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		response := struct {
@@ -5773,26 +7011,26 @@ func (s *Server) handleMovieQuality(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var override database.MediaQualityOverride
 		if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		override.MediaID = id
 		override.MediaType = "movie"
 		if err := s.db.SetMediaQualityOverride(&override); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(override)
 
 	case http.MethodDelete:
 		if err := s.db.DeleteMediaQualityOverride(id, "movie"); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5804,7 +7042,7 @@ func (s *Server) handleShowQuality(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/shows/quality/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid show ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid show ID")
 		return
 	}
 
@@ -5813,13 +7051,13 @@ func (s *Server) handleShowQuality(w http.ResponseWriter, r *http.Request) {
 		// Get quality status
 		status, err := s.db.GetMediaQualityStatus(id, "show")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		// Get quality override
 		override, err := s.db.GetMediaQualityOverride(id, "show")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		response := struct {
@@ -5834,26 +7072,26 @@ func (s *Server) handleShowQuality(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		var override database.MediaQualityOverride
 		if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 		override.MediaID = id
 		override.MediaType = "show"
 		if err := s.db.SetMediaQualityOverride(&override); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		json.NewEncoder(w).Encode(override)
 
 	case http.MethodDelete:
 		if err := s.db.DeleteMediaQualityOverride(id, "show"); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5862,7 +7100,7 @@ func (s *Server) handleShowQuality(w http.ResponseWriter, r *http.Request) {
 // handleTasks returns all scheduled tasks
 func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -5874,7 +7112,7 @@ func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
 // handleTaskHistory returns recent task execution history
 func (s *Server) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -5888,7 +7126,7 @@ func (s *Server) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	history, err := s.db.GetAllTaskHistory(limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(history)
@@ -5899,30 +7137,49 @@ func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
 	w.Header().Set("Content-Type", "application/json")
 
+	// Check for /logs suffix - live log stream
+	if strings.HasSuffix(path, "/logs") {
+		id, err := strconv.ParseInt(strings.TrimSuffix(path, "/logs"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid task ID")
+			return
+		}
+		if r.Method != http.MethodGet {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleTaskLogStream(w, r, id)
+		return
+	}
+
 	// Check for /trigger suffix
 	if strings.HasSuffix(path, "/trigger") {
 		idStr := strings.TrimSuffix(path, "/trigger")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid task ID")
 			return
 		}
 
 		if r.Method == http.MethodPost {
+			if state := s.maintenance.current(); state.Enabled {
+				writeMaintenance(w, r, state)
+				return
+			}
 			if err := s.scheduler.TriggerTask(id); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				httpError(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 			json.NewEncoder(w).Encode(map[string]string{"status": "triggered"})
 			return
 		}
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	id, err := strconv.ParseInt(path, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid task ID")
 		return
 	}
 
@@ -5930,7 +7187,7 @@ func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		task, err := s.db.GetTask(id)
 		if err != nil {
-			http.Error(w, "Task not found", http.StatusNotFound)
+			httpError(w, "Task not found", http.StatusNotFound)
 			return
 		}
 		history, _ := s.db.GetTaskHistory(id, 10)
@@ -5945,12 +7202,12 @@ func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
 			IntervalMinutes int  `json:"intervalMinutes"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		if err := s.scheduler.UpdateTask(id, req.Enabled, req.IntervalMinutes); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -5958,7 +7215,7 @@ func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(task)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -5967,10 +7224,16 @@ func (s *Server) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	json.NewEncoder(w).Encode(s.systemStatus())
+}
+
+// systemStatus summarizes requests, downloads, tasks and disk usage for
+// /api/system/status and the status event
+func (s *Server) systemStatus() interface{} {
 	// Get pending requests count (status is "requested" not "pending")
 	requests, _ := s.db.GetRequestsByStatus("requested")
 	pendingRequests := len(requests)
@@ -6022,13 +7285,14 @@ func (s *Server) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response := struct {
+	return struct {
 		PendingRequests int      `json:"pendingRequests"`
 		ActiveDownloads int      `json:"activeDownloads"`
 		RunningTasks    []string `json:"runningTasks"`
 		ActiveSearch    string   `json:"activeSearch"`
 		DiskUsed        int64    `json:"diskUsed"`
 		DiskTotal       int64    `json:"diskTotal"`
+		Maintenance     bool     `json:"maintenance"`
 	}{
 		PendingRequests: pendingRequests,
 		ActiveDownloads: activeDownloads,
@@ -6036,9 +7300,9 @@ func (s *Server) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
 		ActiveSearch:    activeSearch,
 		DiskUsed:        diskUsed,
 		DiskTotal:       diskTotal,
+		Maintenance:     s.InMaintenance(),
 	}
 
-	json.NewEncoder(w).Encode(response)
 }
 
 // handleRescanQuality rescans all media to update quality detection
@@ -6046,13 +7310,13 @@ func (s *Server) handleRescanQuality(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	movies, episodes, err := s.scanner.RescanQualityStatus()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -6065,7 +7329,7 @@ func (s *Server) handleRescanQuality(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleRedetectQuality(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -6081,7 +7345,7 @@ func (s *Server) handleRedetectQuality(w http.ResponseWriter, r *http.Request) {
 // handleFilesystemBrowse returns directory contents for the file browser
 func (s *Server) handleFilesystemBrowse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -6098,21 +7362,21 @@ func (s *Server) handleFilesystemBrowse(w http.ResponseWriter, r *http.Request)
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			http.Error(w, "Path does not exist", http.StatusNotFound)
+			httpError(w, "Path does not exist", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Cannot access path", http.StatusForbidden)
+		httpError(w, "Cannot access path", http.StatusForbidden)
 		return
 	}
 	if !info.IsDir() {
-		http.Error(w, "Path is not a directory", http.StatusBadRequest)
+		httpError(w, "Path is not a directory", http.StatusBadRequest)
 		return
 	}
 
 	// Read directory contents
 	entries, err := os.ReadDir(path)
 	if err != nil {
-		http.Error(w, "Cannot read directory", http.StatusForbidden)
+		httpError(w, "Cannot read directory", http.StatusForbidden)
 		return
 	}
 
@@ -6174,9 +7438,18 @@ type CalendarItem struct {
 	AirTime    string  `json:"airTime,omitempty"` // Optional time if known
 }
 
+// calendarDay parses the day of a TMDB date ("2024-03-01" or
+// "2024-03-01T00:00:00.000Z") as midnight in a time zone
+func calendarDay(value string, loc *time.Location) (time.Time, error) {
+	if len(value) > 10 {
+		value = value[:10]
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
 func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -6189,26 +7462,28 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 		filter = "all"
 	}
 
-	// Parse dates, default to current month if not provided
-	now := time.Now()
+	// Days are the caller's: parse dates and default to the current month in
+	// their time zone
+	loc := requestLocation(r)
+	now := time.Now().In(loc)
 	var startDate, endDate time.Time
 	var err error
 
 	if startStr != "" {
-		startDate, err = time.Parse("2006-01-02", startStr)
+		startDate, err = time.ParseInLocation("2006-01-02", startStr, loc)
 		if err != nil {
-			http.Error(w, "Invalid start date format", http.StatusBadRequest)
+			httpError(w, "Invalid start date format", http.StatusBadRequest)
 			return
 		}
 	} else {
 		// Default to first day of current month
-		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
 	}
 
 	if endStr != "" {
-		endDate, err = time.Parse("2006-01-02", endStr)
+		endDate, err = time.ParseInLocation("2006-01-02", endStr, loc)
 		if err != nil {
-			http.Error(w, "Invalid end date format", http.StatusBadRequest)
+			httpError(w, "Invalid end date format", http.StatusBadRequest)
 			return
 		}
 	} else {
@@ -6217,6 +7492,14 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []CalendarItem
+	w.Header().Set("X-Timezone", loc.String())
+
+	// Air dates all come from TMDB
+	if !s.metadataConfigured() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]CalendarItem{})
+		return
+	}
 
 	// Get TMDB client
 	tmdbClient := s.metadata.GetTMDBClient()
@@ -6252,7 +7535,7 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 							continue
 						}
 
-						epDate, err := time.Parse("2006-01-02", ep.AirDate)
+						epDate, err := time.ParseInLocation("2006-01-02", ep.AirDate, loc)
 						if err != nil {
 							continue
 						}
@@ -6295,13 +7578,10 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 					// Get US release dates
 					theatrical, digital := tmdb.GetUSReleaseDates(movieDetails.ReleaseDates)
 
-					// Add theatrical release if in range
+					// Add theatrical release if in range. TMDB gives release
+					// dates as midnight UTC; only the day means anything.
 					if theatrical != "" {
-						// Parse the theatrical date (it comes with time)
-						theatricalDate, err := time.Parse("2006-01-02T15:04:05.000Z", theatrical)
-						if err != nil {
-							theatricalDate, err = time.Parse("2006-01-02", theatrical[:10])
-						}
+						theatricalDate, err := calendarDay(theatrical, loc)
 						if err == nil && !theatricalDate.Before(startDate) && !theatricalDate.After(endDate) {
 							items = append(items, CalendarItem{
 								Date:       theatricalDate.Format("2006-01-02"),
@@ -6319,10 +7599,7 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 
 					// Add digital release if in range
 					if digital != "" {
-						digitalDate, err := time.Parse("2006-01-02T15:04:05.000Z", digital)
-						if err != nil {
-							digitalDate, err = time.Parse("2006-01-02", digital[:10])
-						}
+						digitalDate, err := calendarDay(digital, loc)
 						if err == nil && !digitalDate.Before(startDate) && !digitalDate.After(endDate) {
 							items = append(items, CalendarItem{
 								Date:       digitalDate.Format("2006-01-02"),
@@ -6340,7 +7617,7 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 
 					// If no US dates, use general release date
 					if theatrical == "" && digital == "" && movieDetails.ReleaseDate != "" {
-						releaseDate, err := time.Parse("2006-01-02", movieDetails.ReleaseDate)
+						releaseDate, err := time.ParseInLocation("2006-01-02", movieDetails.ReleaseDate, loc)
 						if err == nil && !releaseDate.Before(startDate) && !releaseDate.After(endDate) {
 							items = append(items, CalendarItem{
 								Date:       movieDetails.ReleaseDate,
@@ -6377,7 +7654,7 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 								continue
 							}
 
-							epDate, err := time.Parse("2006-01-02", ep.AirDate)
+							epDate, err := time.ParseInLocation("2006-01-02", ep.AirDate, loc)
 							if err != nil {
 								continue
 							}
@@ -6417,7 +7694,7 @@ func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -6434,7 +7711,7 @@ func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
 
 	notifications, err := s.notifications.GetForUser(user.ID, unreadOnly, limit)
 	if err != nil {
-		http.Error(w, "Failed to get notifications", http.StatusInternalServerError)
+		httpError(w, "Failed to get notifications", http.StatusInternalServerError)
 		return
 	}
 
@@ -6448,7 +7725,7 @@ func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleNotificationUnreadCount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -6456,7 +7733,7 @@ func (s *Server) handleNotificationUnreadCount(w http.ResponseWriter, r *http.Re
 
 	count, err := s.notifications.GetUnreadCount(user.ID)
 	if err != nil {
-		http.Error(w, "Failed to get unread count", http.StatusInternalServerError)
+		httpError(w, "Failed to get unread count", http.StatusInternalServerError)
 		return
 	}
 
@@ -6466,14 +7743,14 @@ func (s *Server) handleNotificationUnreadCount(w http.ResponseWriter, r *http.Re
 
 func (s *Server) handleNotificationReadAll(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	user := r.Context().Value(userContextKey).(*database.User)
 
 	if err := s.notifications.MarkAllRead(user.ID); err != nil {
-		http.Error(w, "Failed to mark all as read", http.StatusInternalServerError)
+		httpError(w, "Failed to mark all as read", http.StatusInternalServerError)
 		return
 	}
 
@@ -6485,25 +7762,25 @@ func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/notifications/")
 	parts := strings.Split(path, "/")
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Notification ID required", http.StatusBadRequest)
+		httpError(w, "Notification ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid notification ID")
 		return
 	}
 
 	// Check if this is a /read sub-route
 	if len(parts) > 1 && parts[1] == "read" {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		if err := s.notifications.MarkRead(id); err != nil {
-			http.Error(w, "Failed to mark as read", http.StatusInternalServerError)
+			httpError(w, "Failed to mark as read", http.StatusInternalServerError)
 			return
 		}
 
@@ -6514,7 +7791,7 @@ func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
 	// Handle DELETE
 	if r.Method == http.MethodDelete {
 		if err := s.notifications.Delete(id); err != nil {
-			http.Error(w, "Failed to delete notification", http.StatusInternalServerError)
+			httpError(w, "Failed to delete notification", http.StatusInternalServerError)
 			return
 		}
 
@@ -6522,7 +7799,7 @@ func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
 // Missing episodes types and handlers
@@ -6562,23 +7839,23 @@ func (s *Server) handleMissingEpisodes(w http.ResponseWriter, r *http.Request, s
 	// Get TMDB API key
 	apiKey, _ := s.db.GetSetting("tmdb_api_key")
 	if apiKey == "" {
-		http.Error(w, "TMDB API key not configured", http.StatusInternalServerError)
+		s.sendMetadataUnconfigured(w, "missing")
 		return
 	}
 
-	tmdbClient := tmdb.NewClient(apiKey, "")
+	tmdbClient := tmdb.NewClient(apiKey, nil)
 
 	// Get show details from TMDB including seasons
 	tvDetails, err := tmdbClient.GetTVDetails(*show.TmdbID)
 	if err != nil {
-		http.Error(w, "Failed to fetch show from TMDB: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Failed to fetch show from TMDB: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Get owned episodes from database
 	ownedEpisodes, err := s.db.GetOwnedEpisodesByShow(show.ID)
 	if err != nil {
-		http.Error(w, "Failed to get owned episodes: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Failed to get owned episodes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -6602,7 +7879,7 @@ func (s *Server) handleMissingEpisodes(w http.ResponseWriter, r *http.Request, s
 		// Fetch detailed episode info for this season
 		seasonDetails, err := tmdbClient.GetSeasonDetails(*show.TmdbID, seasonInfo.SeasonNumber)
 		if err != nil {
-			log.Printf("Failed to fetch season %d details: %v", seasonInfo.SeasonNumber, err)
+			requestLog(r).Errorf("Failed to fetch season %d details: %v", seasonInfo.SeasonNumber, err)
 			continue
 		}
 
@@ -6658,30 +7935,30 @@ func (s *Server) handleRequestMissingEpisodes(w http.ResponseWriter, r *http.Req
 
 	// Show must have a TMDB ID
 	if show.TmdbID == nil {
-		http.Error(w, "Show has no TMDB ID", http.StatusBadRequest)
+		httpError(w, "Show has no TMDB ID", http.StatusBadRequest)
 		return
 	}
 
 	// Get TMDB API key
 	apiKey, _ := s.db.GetSetting("tmdb_api_key")
 	if apiKey == "" {
-		http.Error(w, "TMDB API key not configured", http.StatusInternalServerError)
+		s.sendMetadataUnconfigured(w, "")
 		return
 	}
 
-	tmdbClient := tmdb.NewClient(apiKey, "")
+	tmdbClient := tmdb.NewClient(apiKey, nil)
 
 	// Get show details from TMDB
 	tvDetails, err := tmdbClient.GetTVDetails(*show.TmdbID)
 	if err != nil {
-		http.Error(w, "Failed to fetch show from TMDB: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Failed to fetch show from TMDB: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Get owned episodes
 	ownedEpisodes, err := s.db.GetOwnedEpisodesByShow(show.ID)
 	if err != nil {
-		http.Error(w, "Failed to get owned episodes: "+err.Error(), http.StatusInternalServerError)
+		httpError(w, "Failed to get owned episodes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -6777,7 +8054,7 @@ func (s *Server) handleRequestMissingEpisodes(w http.ResponseWriter, r *http.Req
 			existingWanted.Monitored = true
 			existingWanted.SearchNow = true
 			if err := s.db.UpdateWantedItem(existingWanted); err != nil {
-				log.Printf("Failed to update wanted item: %v", err)
+				requestLog(r).Errorf("Failed to update wanted item: %v", err)
 			}
 		} else {
 			// Create new wanted item
@@ -6793,7 +8070,7 @@ func (s *Server) handleRequestMissingEpisodes(w http.ResponseWriter, r *http.Req
 				SearchNow:       true,
 			}
 			if err := s.db.CreateWantedItem(wanted); err != nil {
-				log.Printf("Failed to create wanted item: %v", err)
+				requestLog(r).Errorf("Failed to create wanted item: %v", err)
 			}
 		}
 	}
@@ -6821,7 +8098,7 @@ func (s *Server) handleCollections(w http.ResponseWriter, r *http.Request) {
 		if tmdbIdStr != "" && mediaType != "" {
 			tmdbId, parseErr := strconv.ParseInt(tmdbIdStr, 10, 64)
 			if parseErr != nil {
-				http.Error(w, "Invalid tmdbId", http.StatusBadRequest)
+				writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid tmdbId")
 				return
 			}
 			collections, err = s.db.GetCollectionsForMedia(tmdbId, mediaType)
@@ -6830,45 +8107,91 @@ func (s *Server) handleCollections(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if collections == nil {
 			collections = []database.Collection{}
 		}
+
+		// Smart collections have no stored items, so count their current matches
+		profileID := s.getActiveProfileID(r)
+		for i := range collections {
+			if !collections[i].IsSmart {
+				continue
+			}
+			if items, err := s.db.GetSmartCollectionItems(&collections[i], profileID); err == nil {
+				collections[i].ItemCount = len(items)
+				collections[i].OwnedCount = len(items)
+			}
+		}
 		json.NewEncoder(w).Encode(collections)
 
 	case http.MethodPost:
 		// Admin only for creation
 		user := s.getCurrentUser(r)
 		if user == nil || user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 			return
 		}
 
 		var input struct {
-			Name        string  `json:"name"`
-			Description *string `json:"description"`
+			Name         string  `json:"name"`
+			Description  *string `json:"description"`
+			PosterPath   *string `json:"posterPath"`
+			BackdropPath *string `json:"backdropPath"`
+			Rules        *string `json:"rules"`
+			MediaType    string  `json:"mediaType"`
+			SortOrder    string  `json:"sortOrder"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if input.Name == "" {
-			http.Error(w, "Name is required", http.StatusBadRequest)
+			httpError(w, "Name is required", http.StatusBadRequest)
 			return
 		}
 
 		coll := &database.Collection{
-			Name:        input.Name,
-			Description: input.Description,
-			IsAuto:      false,
-			SortOrder:   "custom",
+			Name:         input.Name,
+			Description:  input.Description,
+			PosterPath:   input.PosterPath,
+			BackdropPath: input.BackdropPath,
+			IsAuto:       false,
+			SortOrder:    "custom",
+			MediaType:    "both",
+		}
+
+		// A collection created with rules is a smart collection
+		if input.Rules != nil && *input.Rules != "" {
+			var rules database.PlaylistRules
+			if err := json.Unmarshal([]byte(*input.Rules), &rules); err != nil {
+				httpError(w, "Invalid rules format", http.StatusBadRequest)
+				return
+			}
+			coll.IsSmart = true
+			coll.Rules = input.Rules
+			coll.SortOrder = "added"
+		}
+		if input.MediaType != "" {
+			if !isValidCollectionMediaType(input.MediaType) {
+				httpError(w, "mediaType must be movie, show, or both", http.StatusBadRequest)
+				return
+			}
+			coll.MediaType = input.MediaType
+		}
+		if input.SortOrder != "" {
+			if !isValidCollectionSortOrder(input.SortOrder) {
+				httpError(w, "Invalid sortOrder", http.StatusBadRequest)
+				return
+			}
+			coll.SortOrder = input.SortOrder
 		}
 
 		if err := s.db.CreateCollection(coll); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -6876,7 +8199,7 @@ func (s *Server) handleCollections(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(coll)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -6888,13 +8211,13 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Collection ID required", http.StatusBadRequest)
+		httpError(w, "Collection ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid collection ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid collection ID")
 		return
 	}
 
@@ -6907,6 +8230,9 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 		case "reorder":
 			s.handleCollectionReorder(w, r, id)
 			return
+		case "artwork":
+			s.handleCollectionArtwork(w, r, id)
+			return
 		}
 	}
 
@@ -6915,12 +8241,20 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 		// Get collection with items
 		coll, err := s.db.GetCollection(id)
 		if err != nil {
-			http.Error(w, "Collection not found", http.StatusNotFound)
+			httpError(w, "Collection not found", http.StatusNotFound)
 			return
 		}
 
-		items, err := s.db.GetCollectionItems(id)
-		if err != nil {
+		var items []database.CollectionItem
+		if coll.IsSmart {
+			items, err = s.db.GetSmartCollectionItems(coll, s.getActiveProfileID(r))
+			coll.ItemCount = len(items)
+			coll.OwnedCount = len(items)
+		} else {
+			items, err = s.db.GetCollectionItems(id)
+			database.SortCollectionItems(items, coll.SortOrder)
+		}
+		if err != nil || items == nil {
 			items = []database.CollectionItem{}
 		}
 
@@ -6932,6 +8266,9 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 			"posterPath":       coll.PosterPath,
 			"backdropPath":     coll.BackdropPath,
 			"isAuto":           coll.IsAuto,
+			"isSmart":          coll.IsSmart,
+			"rules":            coll.Rules,
+			"mediaType":        coll.MediaType,
 			"sortOrder":        coll.SortOrder,
 			"itemCount":        coll.ItemCount,
 			"ownedCount":       coll.OwnedCount,
@@ -6944,23 +8281,27 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 		// Admin only
 		user := s.getCurrentUser(r)
 		if user == nil || user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 			return
 		}
 
 		coll, err := s.db.GetCollection(id)
 		if err != nil {
-			http.Error(w, "Collection not found", http.StatusNotFound)
+			httpError(w, "Collection not found", http.StatusNotFound)
 			return
 		}
 
 		var input struct {
-			Name        *string `json:"name"`
-			Description *string `json:"description"`
-			SortOrder   *string `json:"sortOrder"`
+			Name         *string `json:"name"`
+			Description  *string `json:"description"`
+			PosterPath   *string `json:"posterPath"`
+			BackdropPath *string `json:"backdropPath"`
+			SortOrder    *string `json:"sortOrder"`
+			Rules        *string `json:"rules"`
+			MediaType    *string `json:"mediaType"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
@@ -6970,12 +8311,50 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 		if input.Description != nil {
 			coll.Description = input.Description
 		}
+		// Empty strings clear custom artwork
+		if input.PosterPath != nil {
+			coll.PosterPath = input.PosterPath
+			if *input.PosterPath == "" {
+				coll.PosterPath = nil
+			}
+		}
+		if input.BackdropPath != nil {
+			coll.BackdropPath = input.BackdropPath
+			if *input.BackdropPath == "" {
+				coll.BackdropPath = nil
+			}
+		}
 		if input.SortOrder != nil {
+			if !isValidCollectionSortOrder(*input.SortOrder) {
+				httpError(w, "Invalid sortOrder", http.StatusBadRequest)
+				return
+			}
 			coll.SortOrder = *input.SortOrder
 		}
+		if input.Rules != nil {
+			if *input.Rules == "" {
+				coll.IsSmart = false
+				coll.Rules = nil
+			} else {
+				var rules database.PlaylistRules
+				if err := json.Unmarshal([]byte(*input.Rules), &rules); err != nil {
+					httpError(w, "Invalid rules format", http.StatusBadRequest)
+					return
+				}
+				coll.IsSmart = true
+				coll.Rules = input.Rules
+			}
+		}
+		if input.MediaType != nil {
+			if !isValidCollectionMediaType(*input.MediaType) {
+				httpError(w, "mediaType must be movie, show, or both", http.StatusBadRequest)
+				return
+			}
+			coll.MediaType = *input.MediaType
+		}
 
 		if err := s.db.UpdateCollection(coll); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -6985,19 +8364,19 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 		// Admin only
 		user := s.getCurrentUser(r)
 		if user == nil || user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 			return
 		}
 
 		if err := s.db.DeleteCollection(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -7005,7 +8384,7 @@ func (s *Server) handleCollectionItems(w http.ResponseWriter, r *http.Request, c
 	// Admin only for modifications
 	user := s.getCurrentUser(r)
 	if user == nil || user.Role != "admin" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 		return
 	}
 
@@ -7019,12 +8398,23 @@ func (s *Server) handleCollectionItems(w http.ResponseWriter, r *http.Request, c
 			PosterPath *string `json:"posterPath"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if input.MediaType == "" || input.TmdbID == 0 {
-			http.Error(w, "mediaType and tmdbId are required", http.StatusBadRequest)
+			httpError(w, "mediaType and tmdbId are required", http.StatusBadRequest)
+			return
+		}
+		if input.MediaType != "movie" && input.MediaType != "show" {
+			httpError(w, "mediaType must be movie or show", http.StatusBadRequest)
+			return
+		}
+		if coll, err := s.db.GetCollection(collectionID); err != nil {
+			httpError(w, "Collection not found", http.StatusNotFound)
+			return
+		} else if coll.IsSmart {
+			httpError(w, "Smart collection items are defined by rules", http.StatusBadRequest)
 			return
 		}
 
@@ -7049,7 +8439,7 @@ func (s *Server) handleCollectionItems(w http.ResponseWriter, r *http.Request, c
 		}
 
 		if err := s.db.AddCollectionItem(item); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -7062,25 +8452,25 @@ func (s *Server) handleCollectionItems(w http.ResponseWriter, r *http.Request, c
 		mediaType := r.URL.Query().Get("mediaType")
 
 		if tmdbIDStr == "" || mediaType == "" {
-			http.Error(w, "tmdbId and mediaType query params required", http.StatusBadRequest)
+			httpError(w, "tmdbId and mediaType query params required", http.StatusBadRequest)
 			return
 		}
 
 		tmdbID, err := strconv.ParseInt(tmdbIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid tmdbId", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid tmdbId")
 			return
 		}
 
 		if err := s.db.RemoveCollectionItem(collectionID, tmdbID, mediaType); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -7088,12 +8478,12 @@ func (s *Server) handleCollectionReorder(w http.ResponseWriter, r *http.Request,
 	// Admin only
 	user := s.getCurrentUser(r)
 	if user == nil || user.Role != "admin" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7101,12 +8491,20 @@ func (s *Server) handleCollectionReorder(w http.ResponseWriter, r *http.Request,
 		ItemIDs []int64 `json:"itemIds"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+		return
+	}
+
+	if coll, err := s.db.GetCollection(collectionID); err != nil {
+		httpError(w, "Collection not found", http.StatusNotFound)
+		return
+	} else if coll.IsSmart {
+		httpError(w, "Smart collections cannot be reordered manually", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.db.UpdateCollectionItemOrder(collectionID, input.ItemIDs); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7121,18 +8519,109 @@ func (s *Server) handleCollectionReorder(w http.ResponseWriter, r *http.Request,
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// handleCollectionArtwork handles POST /api/collections/{id}/artwork
+// Accepts a multipart upload with a "poster" or "backdrop" image file
+func (s *Server) handleCollectionArtwork(w http.ResponseWriter, r *http.Request, collectionID int64) {
+	user := s.getCurrentUser(r)
+	if user == nil || user.Role != "admin" {
+		writeError(w, http.StatusForbidden, codeAdminRequired, "Forbidden")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coll, err := s.db.GetCollection(collectionID)
+	if err != nil {
+		httpError(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		httpError(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated := false
+	for _, kind := range []string{"poster", "backdrop"} {
+		file, header, err := r.FormFile(kind)
+		if err != nil {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(header.Filename))
+		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".webp" {
+			file.Close()
+			httpError(w, "Artwork must be a jpg, png, or webp image", http.StatusBadRequest)
+			return
+		}
+
+		relPath := filepath.Join("collections", fmt.Sprintf("%d_%s_%d%s", collectionID, kind, time.Now().Unix(), ext))
+		err = s.images.Put(relPath, file, blobstore.ContentType(relPath))
+		file.Close()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		if kind == "poster" {
+			coll.PosterPath = &relPath
+		} else {
+			coll.BackdropPath = &relPath
+		}
+		updated = true
+	}
+
+	if !updated {
+		httpError(w, "poster or backdrop file required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateCollection(coll); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(coll)
+}
+
+func isValidCollectionSortOrder(sortOrder string) bool {
+	switch sortOrder {
+	case "release", "added", "title", "custom":
+		return true
+	}
+	return false
+}
+
+func isValidCollectionMediaType(mediaType string) bool {
+	switch mediaType {
+	case "movie", "show", "both":
+		return true
+	}
+	return false
+}
+
 // handleLogs handles GET /api/logs
 func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	query := logging.LogQuery{
-		Level:  r.URL.Query().Get("level"),
-		Source: r.URL.Query().Get("source"),
-		Search: r.URL.Query().Get("search"),
-		Limit:  500, // Default limit
+		Level:     r.URL.Query().Get("level"),
+		Source:    r.URL.Query().Get("source"),
+		Search:    r.URL.Query().Get("search"),
+		RequestID: r.URL.Query().Get("requestId"),
+		Limit:     500, // Default limit
+	}
+	// Sources are the modules that log
+	if module := r.URL.Query().Get("module"); module != "" {
+		query.Source = module
 	}
 
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
@@ -7153,7 +8642,7 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 // handleLogsDownload handles GET /api/logs/download
 func (s *Server) handleLogsDownload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7167,16 +8656,15 @@ func (s *Server) handleLogsDownload(w http.ResponseWriter, r *http.Request) {
 // handleHealthFull handles GET /api/health/full
 func (s *Server) handleHealthFull(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Set TMDB key from settings if needed
-	if key, err := s.db.GetSetting("tmdb_api_key"); err == nil && key != "" {
-		s.healthChecker.SetTMDBKey(key)
-	}
+	// Pick up the current TMDB key, including it being removed
+	key, _ := s.db.GetSetting("tmdb_api_key")
+	s.healthChecker.SetTMDBKey(key)
 
-	status := s.healthChecker.GetFullStatus()
+	status := s.healthChecker.GetFullStatus().Localize(requestLanguage(r, nil))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
@@ -7185,53 +8673,42 @@ func (s *Server) handleHealthFull(w http.ResponseWriter, r *http.Request) {
 // handleHealthCheck handles POST /api/health/check/{name}
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract check name from path: /api/health/check/{name}
 	path := strings.TrimPrefix(r.URL.Path, "/api/health/check/")
 	if path == "" {
-		http.Error(w, "Check name required", http.StatusBadRequest)
+		httpError(w, "Check name required", http.StatusBadRequest)
 		return
 	}
 
-	// Set TMDB key from settings if needed
-	if key, err := s.db.GetSetting("tmdb_api_key"); err == nil && key != "" {
-		s.healthChecker.SetTMDBKey(key)
-	}
+	// Pick up the current TMDB key, including it being removed
+	key, _ := s.db.GetSetting("tmdb_api_key")
+	s.healthChecker.SetTMDBKey(key)
 
 	check := s.healthChecker.RunSingleCheck(path)
 	if check == nil {
-		http.Error(w, "Unknown health check", http.StatusNotFound)
+		httpError(w, "Unknown health check", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(check)
+	json.NewEncoder(w).Encode(check.Localize(requestLanguage(r, nil)))
 }
 
 // handleBackup handles POST /api/backup - creates and downloads a backup
 func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Create backup
-	appVersion := "0.1.0" // TODO: Get from config or build info
-	backup, err := s.db.CreateBackup(appVersion)
-	if err != nil {
-		log.Printf("Failed to create backup: %v", err)
-		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Convert to JSON
-	data, err := json.MarshalIndent(backup, "", "  ")
+	data, err := s.createBackup()
 	if err != nil {
-		log.Printf("Failed to marshal backup: %v", err)
-		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		requestLog(r).Errorf("Failed to create backup: %v", err)
+		httpError(w, "Failed to create backup", http.StatusInternalServerError)
 		return
 	}
 
@@ -7246,7 +8723,7 @@ func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
 // handleRestore handles POST /api/backup/restore - restores from a backup file
 func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7258,14 +8735,14 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 
 	// Parse multipart form
 	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB max
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		httpError(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
 
 	// Get the uploaded file
 	file, _, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "No file uploaded", http.StatusBadRequest)
+		httpError(w, "No file uploaded", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
@@ -7283,18 +8760,32 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	s.restoreBackupRequest(w, r, data, mode)
+}
+
+// restoreBackupRequest restores an uploaded or stored backup for a request,
+// holding it for approval when that's required
+func (s *Server) restoreBackupRequest(w http.ResponseWriter, r *http.Request, data []byte, mode string) {
 	// Validate backup
 	backup, err := database.ValidateBackup(data)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isDryRun(r) {
+		s.simulateRestore(w, r, backup, mode)
+		return
+	}
+	summary := fmt.Sprintf("Restore backup from %s (%s)", backup.CreatedAt.Format("2006-01-02 15:04"), mode)
+	if s.holdForApproval(w, r, opBackupRestore, summary, map[string]string{"mode": mode}, data) {
 		return
 	}
 
 	// Perform restore
-	result, err := s.db.RestoreBackup(backup, mode)
+	result, err := s.restoreBackup(data, mode)
 	if err != nil {
-		log.Printf("Failed to restore backup: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to restore backup: %v", err), http.StatusInternalServerError)
+		requestLog(r).Errorf("Failed to restore backup: %v", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7302,6 +8793,31 @@ func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// createBackup exports the settings and configuration as a backup file
+func (s *Server) createBackup() ([]byte, error) {
+	appVersion := "0.1.0" // TODO: Get from config or build info
+	backup, err := s.db.CreateBackup(appVersion)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(backup, "", "  ")
+}
+
+// restoreBackup restores a backup file in replace or merge mode
+func (s *Server) restoreBackup(data []byte, mode string) (interface{}, error) {
+	backup, err := database.ValidateBackup(data)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.RestoreBackup(backup, mode)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to restore backup: %v", err)
+	}
+	// The backup's storage settings apply at once, like saved settings
+	s.reloadStorage()
+	return result, nil
+}
+
 // Smart playlist handlers
 func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -7316,7 +8832,7 @@ func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 		}
 		playlists, err := s.db.GetSmartPlaylists(userID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		if playlists == nil {
@@ -7327,7 +8843,7 @@ func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		user := s.getCurrentUser(r)
 		if user == nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -7342,23 +8858,23 @@ func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 			AutoRefresh bool    `json:"autoRefresh"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
 		if input.Name == "" {
-			http.Error(w, "Name is required", http.StatusBadRequest)
+			httpError(w, "Name is required", http.StatusBadRequest)
 			return
 		}
 		if input.Rules == "" {
-			http.Error(w, "Rules are required", http.StatusBadRequest)
+			httpError(w, "Rules are required", http.StatusBadRequest)
 			return
 		}
 
 		// Validate rules JSON
 		var rules database.PlaylistRules
 		if err := json.Unmarshal([]byte(input.Rules), &rules); err != nil {
-			http.Error(w, "Invalid rules format", http.StatusBadRequest)
+			httpError(w, "Invalid rules format", http.StatusBadRequest)
 			return
 		}
 
@@ -7387,7 +8903,7 @@ func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.CreateSmartPlaylist(playlist); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -7395,7 +8911,7 @@ func (s *Server) handleSmartPlaylists(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(playlist)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -7407,13 +8923,13 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Playlist ID required", http.StatusBadRequest)
+		httpError(w, "Playlist ID required", http.StatusBadRequest)
 		return
 	}
 
 	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid playlist ID")
 		return
 	}
 
@@ -7433,7 +8949,7 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		playlist, err := s.db.GetSmartPlaylist(id)
 		if err != nil {
-			http.Error(w, "Playlist not found", http.StatusNotFound)
+			httpError(w, "Playlist not found", http.StatusNotFound)
 			return
 		}
 
@@ -7467,25 +8983,25 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		user := s.getCurrentUser(r)
 		if user == nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		playlist, err := s.db.GetSmartPlaylist(id)
 		if err != nil {
-			http.Error(w, "Playlist not found", http.StatusNotFound)
+			httpError(w, "Playlist not found", http.StatusNotFound)
 			return
 		}
 
 		// Only owner or admin can update
 		if playlist.UserID != nil && *playlist.UserID != user.ID && user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			httpError(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
 		// System playlists can only be updated by admin
 		if playlist.IsSystem && user.Role != "admin" {
-			http.Error(w, "Cannot modify system playlist", http.StatusForbidden)
+			httpError(w, "Cannot modify system playlist", http.StatusForbidden)
 			return
 		}
 
@@ -7500,7 +9016,7 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 			AutoRefresh *bool   `json:"autoRefresh"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 			return
 		}
 
@@ -7514,7 +9030,7 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 			// Validate rules JSON
 			var rules database.PlaylistRules
 			if err := json.Unmarshal([]byte(*input.Rules), &rules); err != nil {
-				http.Error(w, "Invalid rules format", http.StatusBadRequest)
+				httpError(w, "Invalid rules format", http.StatusBadRequest)
 				return
 			}
 			playlist.Rules = *input.Rules
@@ -7536,7 +9052,7 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.db.UpdateSmartPlaylist(playlist); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -7545,49 +9061,49 @@ func (s *Server) handleSmartPlaylist(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		user := s.getCurrentUser(r)
 		if user == nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		playlist, err := s.db.GetSmartPlaylist(id)
 		if err != nil {
-			http.Error(w, "Playlist not found", http.StatusNotFound)
+			httpError(w, "Playlist not found", http.StatusNotFound)
 			return
 		}
 
 		// System playlists cannot be deleted
 		if playlist.IsSystem {
-			http.Error(w, "Cannot delete system playlist", http.StatusForbidden)
+			httpError(w, "Cannot delete system playlist", http.StatusForbidden)
 			return
 		}
 
 		// Only owner or admin can delete
 		if playlist.UserID != nil && *playlist.UserID != user.ID && user.Role != "admin" {
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			httpError(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 
 		if err := s.db.DeleteSmartPlaylist(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleSmartPlaylistRefresh(w http.ResponseWriter, r *http.Request, id int64) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	playlist, err := s.db.GetSmartPlaylist(id)
 	if err != nil {
-		http.Error(w, "Playlist not found", http.StatusNotFound)
+		httpError(w, "Playlist not found", http.StatusNotFound)
 		return
 	}
 
@@ -7596,7 +9112,7 @@ func (s *Server) handleSmartPlaylistRefresh(w http.ResponseWriter, r *http.Reque
 	// Get fresh items
 	items, err := s.db.GetSmartPlaylistItems(playlist, profileID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7611,13 +9127,13 @@ func (s *Server) handleSmartPlaylistRefresh(w http.ResponseWriter, r *http.Reque
 
 func (s *Server) handleSmartPlaylistItems(w http.ResponseWriter, r *http.Request, id int64) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	playlist, err := s.db.GetSmartPlaylist(id)
 	if err != nil {
-		http.Error(w, "Playlist not found", http.StatusNotFound)
+		httpError(w, "Playlist not found", http.StatusNotFound)
 		return
 	}
 
@@ -7625,7 +9141,7 @@ func (s *Server) handleSmartPlaylistItems(w http.ResponseWriter, r *http.Request
 
 	items, err := s.db.GetSmartPlaylistItems(playlist, profileID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7634,7 +9150,7 @@ func (s *Server) handleSmartPlaylistItems(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleSmartPlaylistPreview(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7648,14 +9164,14 @@ func (s *Server) handleSmartPlaylistPreview(w http.ResponseWriter, r *http.Reque
 		MediaType  string `json:"mediaType"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
 		return
 	}
 
 	// Validate rules
 	var rules database.PlaylistRules
 	if err := json.Unmarshal([]byte(input.Rules), &rules); err != nil {
-		http.Error(w, "Invalid rules format", http.StatusBadRequest)
+		httpError(w, "Invalid rules format", http.StatusBadRequest)
 		return
 	}
 
@@ -7683,7 +9199,7 @@ func (s *Server) handleSmartPlaylistPreview(w http.ResponseWriter, r *http.Reque
 
 	items, err := s.db.GetSmartPlaylistItems(playlist, profileID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7699,13 +9215,13 @@ func (s *Server) handleUpgrades(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	summary, err := s.db.GetUpgradesSummary()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -7716,7 +9232,7 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7725,12 +9241,12 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 		MediaID   int64  `json:"mediaId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.MediaType == "" || req.MediaID == 0 {
-		http.Error(w, "mediaType and mediaId are required", http.StatusBadRequest)
+		httpError(w, "mediaType and mediaId are required", http.StatusBadRequest)
 		return
 	}
 
@@ -7744,7 +9260,7 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 	if req.MediaType == "movie" {
 		movie, err := s.db.GetMovie(req.MediaID)
 		if err != nil {
-			http.Error(w, "Movie not found", http.StatusNotFound)
+			httpError(w, "Movie not found", http.StatusNotFound)
 			return
 		}
 		if movie.TmdbID != nil {
@@ -7773,17 +9289,17 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 		// For episodes, we need to get the season and show info
 		episode, err := s.db.GetEpisode(req.MediaID)
 		if err != nil {
-			http.Error(w, "Episode not found", http.StatusNotFound)
+			httpError(w, "Episode not found", http.StatusNotFound)
 			return
 		}
 		season, err := s.db.GetSeasonByID(episode.SeasonID)
 		if err != nil {
-			http.Error(w, "Season not found", http.StatusNotFound)
+			httpError(w, "Season not found", http.StatusNotFound)
 			return
 		}
 		show, err := s.db.GetShow(season.ShowID)
 		if err != nil {
-			http.Error(w, "Show not found", http.StatusNotFound)
+			httpError(w, "Show not found", http.StatusNotFound)
 			return
 		}
 		if show.TmdbID != nil {
@@ -7809,7 +9325,7 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	} else {
-		http.Error(w, "Invalid mediaType, must be 'movie' or 'episode'", http.StatusBadRequest)
+		httpError(w, "Invalid mediaType, must be 'movie' or 'episode'", http.StatusBadRequest)
 		return
 	}
 
@@ -7824,8 +9340,8 @@ func (s *Server) handleUpgradeSearch(w http.ResponseWriter, r *http.Request) {
 
 		err := s.db.CreateUpgradeWantedItem(req.MediaType, tmdbID, imdbID, title, year, "", qualityPresetID, req.MediaID, currentScore)
 		if err != nil {
-			log.Printf("Failed to create upgrade wanted item: %v", err)
-			http.Error(w, "Failed to queue upgrade search", http.StatusInternalServerError)
+			requestLog(r).Errorf("Failed to create upgrade wanted item: %v", err)
+			httpError(w, "Failed to queue upgrade search", http.StatusInternalServerError)
 			return
 		}
 
@@ -7852,7 +9368,7 @@ func (s *Server) handleUpgradeSearchAll(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7861,7 +9377,7 @@ func (s *Server) handleUpgradeSearchAll(w http.ResponseWriter, r *http.Request)
 		MediaType string `json:"mediaType"` // "movie", "episode", or "" for both
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -7878,7 +9394,7 @@ func (s *Server) handleUpgradeSearchAll(w http.ResponseWriter, r *http.Request)
 	if req.MediaType == "" || req.MediaType == "movie" {
 		movies, err := s.db.GetUpgradeableMovies(req.Limit)
 		if err != nil {
-			log.Printf("Failed to get upgradeable movies: %v", err)
+			requestLog(r).Errorf("Failed to get upgradeable movies: %v", err)
 		} else {
 			for _, item := range movies {
 				// Get full movie details
@@ -7925,7 +9441,7 @@ func (s *Server) handleUpgradeSearchAll(w http.ResponseWriter, r *http.Request)
 	if req.MediaType == "" || req.MediaType == "episode" {
 		episodes, err := s.db.GetUpgradeableEpisodes(req.Limit)
 		if err != nil {
-			log.Printf("Failed to get upgradeable episodes: %v", err)
+			requestLog(r).Errorf("Failed to get upgradeable episodes: %v", err)
 		} else {
 			for _, item := range episodes {
 				// Get episode, season and show details
@@ -7989,7 +9505,7 @@ func (s *Server) handleUpgradeResetSearch(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -7998,25 +9514,25 @@ func (s *Server) handleUpgradeResetSearch(w http.ResponseWriter, r *http.Request
 		MediaID   int64  `json:"mediaId"`   // existing media ID (movie or episode ID)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.MediaType == "" || req.MediaID == 0 {
-		http.Error(w, "mediaType and mediaId are required", http.StatusBadRequest)
+		httpError(w, "mediaType and mediaId are required", http.StatusBadRequest)
 		return
 	}
 
 	if req.MediaType != "movie" && req.MediaType != "episode" {
-		http.Error(w, "mediaType must be 'movie' or 'episode'", http.StatusBadRequest)
+		httpError(w, "mediaType must be 'movie' or 'episode'", http.StatusBadRequest)
 		return
 	}
 
 	// Reset the backoff for this item
 	err := s.db.ResetWantedSearchBackoff(req.MediaID, req.MediaType)
 	if err != nil {
-		log.Printf("Failed to reset search backoff for %s %d: %v", req.MediaType, req.MediaID, err)
-		http.Error(w, "Failed to reset search backoff", http.StatusInternalServerError)
+		requestLog(r).Errorf("Failed to reset search backoff for %s %d: %v", req.MediaType, req.MediaID, err)
+		httpError(w, "Failed to reset search backoff", http.StatusInternalServerError)
 		return
 	}
 
@@ -8031,7 +9547,7 @@ func (s *Server) handleUpgradePause(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8041,24 +9557,24 @@ func (s *Server) handleUpgradePause(w http.ResponseWriter, r *http.Request) {
 		Paused    bool   `json:"paused"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.MediaType == "" || req.MediaID == 0 {
-		http.Error(w, "mediaType and mediaId are required", http.StatusBadRequest)
+		httpError(w, "mediaType and mediaId are required", http.StatusBadRequest)
 		return
 	}
 
 	if req.MediaType != "movie" && req.MediaType != "episode" {
-		http.Error(w, "mediaType must be 'movie' or 'episode'", http.StatusBadRequest)
+		httpError(w, "mediaType must be 'movie' or 'episode'", http.StatusBadRequest)
 		return
 	}
 
 	err := s.db.SetUpgradePaused(req.MediaID, req.MediaType, req.Paused)
 	if err != nil {
-		log.Printf("Failed to set upgrade paused for %s %d: %v", req.MediaType, req.MediaID, err)
-		http.Error(w, "Failed to update pause status", http.StatusInternalServerError)
+		requestLog(r).Errorf("Failed to set upgrade paused for %s %d: %v", req.MediaType, req.MediaID, err)
+		httpError(w, "Failed to update pause status", http.StatusInternalServerError)
 		return
 	}
 
@@ -8075,7 +9591,7 @@ func (s *Server) handleUpgradePause(w http.ResponseWriter, r *http.Request) {
 // handleOpenSubtitlesSearch searches for subtitles on OpenSubtitles
 func (s *Server) handleOpenSubtitlesSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8091,13 +9607,13 @@ func (s *Server) handleOpenSubtitlesSearch(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	apiKey, _ := s.db.GetSetting("opensubtitles_api_key")
 	if apiKey == "" {
-		http.Error(w, "OpenSubtitles API key not configured", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNotConfigured, "OpenSubtitles API key not configured")
 		return
 	}
 
@@ -8126,8 +9642,8 @@ func (s *Server) handleOpenSubtitlesSearch(w http.ResponseWriter, r *http.Reques
 
 	results, err := client.Search(searchReq)
 	if err != nil {
-		log.Printf("OpenSubtitles search error: %v", err)
-		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		requestLog(r).Errorf("OpenSubtitles search error: %v", err)
+		httpError(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -8138,7 +9654,7 @@ func (s *Server) handleOpenSubtitlesSearch(w http.ResponseWriter, r *http.Reques
 // handleOpenSubtitlesDownload downloads a subtitle file
 func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8151,18 +9667,18 @@ func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Requ
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.FileID == 0 {
-		http.Error(w, "fileId is required", http.StatusBadRequest)
+		httpError(w, "fileId is required", http.StatusBadRequest)
 		return
 	}
 
 	apiKey, _ := s.db.GetSetting("opensubtitles_api_key")
 	if apiKey == "" {
-		http.Error(w, "OpenSubtitles API key not configured", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNotConfigured, "OpenSubtitles API key not configured")
 		return
 	}
 
@@ -8171,24 +9687,24 @@ func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Requ
 	if req.MediaType == "movie" {
 		movie, err := s.db.GetMovie(req.MediaID)
 		if err != nil || movie == nil {
-			http.Error(w, "Movie not found", http.StatusNotFound)
+			httpError(w, "Movie not found", http.StatusNotFound)
 			return
 		}
 		videoPath = movie.Path
 	} else if req.MediaType == "episode" && req.EpisodeID != nil {
 		episode, err := s.db.GetEpisode(*req.EpisodeID)
 		if err != nil || episode == nil {
-			http.Error(w, "Episode not found", http.StatusNotFound)
+			httpError(w, "Episode not found", http.StatusNotFound)
 			return
 		}
 		videoPath = episode.Path
 	} else {
-		http.Error(w, "Invalid media type or missing episodeId", http.StatusBadRequest)
+		httpError(w, "Invalid media type or missing episodeId", http.StatusBadRequest)
 		return
 	}
 
 	if videoPath == "" {
-		http.Error(w, "No video file found", http.StatusNotFound)
+		httpError(w, "No video file found", http.StatusNotFound)
 		return
 	}
 
@@ -8197,8 +9713,8 @@ func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Requ
 	// Get download link
 	dlResp, err := client.GetDownloadLink(req.FileID)
 	if err != nil {
-		log.Printf("OpenSubtitles download link error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get download link: %v", err), http.StatusInternalServerError)
+		requestLog(r).Errorf("OpenSubtitles download link error: %v", err)
+		httpError(w, fmt.Sprintf("Failed to get download link: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -8208,12 +9724,12 @@ func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Requ
 
 	// Download the subtitle
 	if err := client.Download(dlResp.Link, subPath); err != nil {
-		log.Printf("OpenSubtitles download error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to download subtitle: %v", err), http.StatusInternalServerError)
+		requestLog(r).Errorf("OpenSubtitles download error: %v", err)
+		httpError(w, fmt.Sprintf("Failed to download subtitle: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Downloaded subtitle to: %s", subPath)
+	requestLog(r).Infof("Downloaded subtitle to: %s", subPath)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -8227,7 +9743,7 @@ func (s *Server) handleOpenSubtitlesDownload(w http.ResponseWriter, r *http.Requ
 // handleOpenSubtitlesLanguages returns available subtitle languages
 func (s *Server) handleOpenSubtitlesLanguages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8264,8 +9780,8 @@ func (s *Server) handleOpenSubtitlesLanguages(w http.ResponseWriter, r *http.Req
 	client := subtitles.NewClient(apiKey)
 	languages, err := client.GetLanguages()
 	if err != nil {
-		log.Printf("OpenSubtitles languages error: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get languages: %v", err), http.StatusInternalServerError)
+		requestLog(r).Errorf("OpenSubtitles languages error: %v", err)
+		httpError(w, fmt.Sprintf("Failed to get languages: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -8276,7 +9792,7 @@ func (s *Server) handleOpenSubtitlesLanguages(w http.ResponseWriter, r *http.Req
 // handleOpenSubtitlesTest tests the OpenSubtitles API connection
 func (s *Server) handleOpenSubtitlesTest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8285,7 +9801,7 @@ func (s *Server) handleOpenSubtitlesTest(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -8295,7 +9811,7 @@ func (s *Server) handleOpenSubtitlesTest(w http.ResponseWriter, r *http.Request)
 	}
 
 	if apiKey == "" {
-		http.Error(w, "No API key provided", http.StatusBadRequest)
+		httpError(w, "No API key provided", http.StatusBadRequest)
 		return
 	}
 
@@ -8319,19 +9835,19 @@ func (s *Server) handleOpenSubtitlesTest(w http.ResponseWriter, r *http.Request)
 // handleTraktAuthURL returns the OAuth authorization URL for Trakt
 func (s *Server) handleTraktAuthURL(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	clientID, _ := s.db.GetSetting("trakt_client_id")
 	if clientID == "" {
-		http.Error(w, "Trakt client ID not configured", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNotConfigured, "Trakt client ID not configured")
 		return
 	}
 
 	redirectURI := r.URL.Query().Get("redirect_uri")
 	if redirectURI == "" {
-		http.Error(w, "redirect_uri is required", http.StatusBadRequest)
+		httpError(w, "redirect_uri is required", http.StatusBadRequest)
 		return
 	}
 
@@ -8347,7 +9863,7 @@ func (s *Server) handleTraktAuthURL(w http.ResponseWriter, r *http.Request) {
 // handleTraktCallback handles the OAuth callback from Trakt
 func (s *Server) handleTraktCallback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8359,7 +9875,7 @@ func (s *Server) handleTraktCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -8367,23 +9883,23 @@ func (s *Server) handleTraktCallback(w http.ResponseWriter, r *http.Request) {
 	clientSecret, _ := s.db.GetSetting("trakt_client_secret")
 
 	if clientID == "" || clientSecret == "" {
-		http.Error(w, "Trakt not configured", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNotConfigured, "Trakt not configured")
 		return
 	}
 
 	client := trakt.NewClient(clientID, clientSecret)
 	tokenResp, err := client.ExchangeCode(req.Code, req.RedirectURI)
 	if err != nil {
-		log.Printf("Trakt code exchange error: %v", err)
-		http.Error(w, "Failed to exchange code: "+err.Error(), http.StatusBadRequest)
+		requestLog(r).Errorf("Trakt code exchange error: %v", err)
+		httpError(w, "Failed to exchange code: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Get user info from Trakt
 	settings, err := client.GetUserSettings()
 	if err != nil {
-		log.Printf("Trakt get user settings error: %v", err)
-		http.Error(w, "Failed to get user info", http.StatusInternalServerError)
+		requestLog(r).Errorf("Trakt get user settings error: %v", err)
+		httpError(w, "Failed to get user info", http.StatusInternalServerError)
 		return
 	}
 
@@ -8402,8 +9918,8 @@ func (s *Server) handleTraktCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.db.SaveTraktConfig(config); err != nil {
-		log.Printf("Failed to save Trakt config: %v", err)
-		http.Error(w, "Failed to save configuration", http.StatusInternalServerError)
+		requestLog(r).Errorf("Failed to save Trakt config: %v", err)
+		httpError(w, "Failed to save configuration", http.StatusInternalServerError)
 		return
 	}
 
@@ -8422,7 +9938,7 @@ func (s *Server) handleTraktConfig(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		config, err := s.db.GetTraktConfig(user.ID)
 		if err != nil {
-			http.Error(w, "Failed to get config", http.StatusInternalServerError)
+			httpError(w, "Failed to get config", http.StatusInternalServerError)
 			return
 		}
 
@@ -8452,13 +9968,13 @@ func (s *Server) handleTraktConfig(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
 			return
 		}
 
 		config, err := s.db.GetTraktConfig(user.ID)
 		if err != nil || config == nil {
-			http.Error(w, "Trakt not connected", http.StatusBadRequest)
+			httpError(w, "Trakt not connected", http.StatusBadRequest)
 			return
 		}
 
@@ -8468,7 +9984,7 @@ func (s *Server) handleTraktConfig(w http.ResponseWriter, r *http.Request) {
 		config.SyncWatchlist = req.SyncWatchlist
 
 		if err := s.db.SaveTraktConfig(config); err != nil {
-			http.Error(w, "Failed to save config", http.StatusInternalServerError)
+			httpError(w, "Failed to save config", http.StatusInternalServerError)
 			return
 		}
 
@@ -8476,21 +9992,21 @@ func (s *Server) handleTraktConfig(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]bool{"success": true})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // handleTraktDisconnect disconnects Trakt for the current user
 func (s *Server) handleTraktDisconnect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	user := r.Context().Value(userContextKey).(*database.User)
 
 	if err := s.db.DeleteTraktConfig(user.ID); err != nil {
-		http.Error(w, "Failed to disconnect", http.StatusInternalServerError)
+		httpError(w, "Failed to disconnect", http.StatusInternalServerError)
 		return
 	}
 
@@ -8501,7 +10017,7 @@ func (s *Server) handleTraktDisconnect(w http.ResponseWriter, r *http.Request) {
 // handleTraktSync triggers a manual sync with Trakt
 func (s *Server) handleTraktSync(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -8512,7 +10028,7 @@ func (s *Server) handleTraktSync(w http.ResponseWriter, r *http.Request) {
 	if profileID == nil {
 		profile, err := s.db.GetDefaultProfile(user.ID)
 		if err != nil || profile == nil {
-			http.Error(w, "No profile found", http.StatusBadRequest)
+			httpError(w, "No profile found", http.StatusBadRequest)
 			return
 		}
 		profileID = &profile.ID
@@ -8520,7 +10036,7 @@ func (s *Server) handleTraktSync(w http.ResponseWriter, r *http.Request) {
 
 	config, err := s.db.GetTraktConfig(user.ID)
 	if err != nil || config == nil || config.AccessToken == "" {
-		http.Error(w, "Trakt not connected", http.StatusBadRequest)
+		httpError(w, "Trakt not connected", http.StatusBadRequest)
 		return
 	}
 
@@ -8534,8 +10050,8 @@ func (s *Server) handleTraktSync(w http.ResponseWriter, r *http.Request) {
 	if client.NeedsRefresh() {
 		tokenResp, err := client.RefreshAccessToken()
 		if err != nil {
-			log.Printf("Trakt token refresh error: %v", err)
-			http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+			requestLog(r).Errorf("Trakt token refresh error: %v", err)
+			httpError(w, "Failed to refresh token", http.StatusInternalServerError)
 			return
 		}
 		expiresAt := time.Unix(tokenResp.CreatedAt, 0).Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
@@ -8568,16 +10084,14 @@ func (s *Server) performTraktSync(profileID int64, client *trakt.Client, config
 	pulled := map[string]int{"movies": 0, "shows": 0}
 	pushed := map[string]int{"movies": 0, "episodes": 0}
 
-	// Helper to check if watched (>90% progress)
+	// Helper to check if watched under the profile's played rule
+	rule := s.db.GetPlayedRule(&profileID)
 	isWatched := func(mediaType string, mediaID int64) bool {
 		progress, err := s.db.GetProgress(profileID, mediaType, mediaID)
 		if err != nil || progress == nil {
 			return false
 		}
-		if progress.Duration > 0 && progress.Position/progress.Duration >= 0.9 {
-			return true
-		}
-		return false
+		return s.db.IsPlayed(rule, mediaType, mediaID, progress.Position, progress.Duration)
 	}
 
 	// Helper to mark as watched
@@ -8716,7 +10230,7 @@ func (s *Server) performTraktSync(profileID int64, client *trakt.Client, config
 // handleTraktTest tests the Trakt API connection for the current user
 func (s *Server) handleTraktTest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
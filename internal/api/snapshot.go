@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Library snapshot
+//
+// /api/snapshot returns everything an app needs to draw the library - ids,
+// titles, artwork and watch state - in one compact response, so mobile and TV
+// apps can show the library at once and then keep it current from the change
+// feed, starting at the snapshot's cursor. Snapshots are built once per viewer
+// and cached until the library changes. They're compressed once when built,
+// and served with Brotli to clients that accept it, as it's smaller, or
+// otherwise gzip.
+
+const (
+	// snapshotVersion is bumped whenever the snapshot format changes, so apps
+	// can throw away cached snapshots they can't read
+	snapshotVersion = 1
+	// snapshotMaxAge rebuilds cached snapshots that are this old even if the
+	// library didn't change, to pick up changes the journal doesn't cover such
+	// as a user's content limits
+	snapshotMaxAge = 10 * time.Minute
+)
+
+// LibrarySnapshot is the body of /api/snapshot
+type LibrarySnapshot struct {
+	Version     int               `json:"version"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+	Cursor      int64             `json:"cursor"` // Change feed cursor to sync from
+	Libraries   []SnapshotLibrary `json:"libraries"`
+	Movies      []SnapshotMovie   `json:"movies"`
+	Shows       []SnapshotShow    `json:"shows"`
+}
+
+// SnapshotLibrary is a library the viewer can see
+type SnapshotLibrary struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	ArtworkStyle string `json:"artworkStyle"`
+	ArtworkText  string `json:"artworkText"`
+}
+
+// SnapshotMovie is the part of a movie needed to list it
+type SnapshotMovie struct {
+	ID         int64   `json:"id"`
+	LibraryID  int64   `json:"libraryId"`
+	Title      string  `json:"title"`
+	Year       int     `json:"year,omitempty"`
+	Poster     string  `json:"poster,omitempty"`
+	Backdrop   string  `json:"backdrop,omitempty"`
+	Artwork    string  `json:"artwork,omitempty"`    // In the library's artwork style
+	WatchState string  `json:"watchState,omitempty"` // Omitted when unwatched
+	Progress   float64 `json:"progress,omitempty"`
+}
+
+// SnapshotShow is the part of a show needed to list it
+type SnapshotShow struct {
+	ID              int64  `json:"id"`
+	LibraryID       int64  `json:"libraryId"`
+	Title           string `json:"title"`
+	Year            int    `json:"year,omitempty"`
+	Poster          string `json:"poster,omitempty"`
+	Backdrop        string `json:"backdrop,omitempty"`
+	Artwork         string `json:"artwork,omitempty"`    // In the library's artwork style
+	WatchState      string `json:"watchState,omitempty"` // Omitted when unwatched
+	WatchedEpisodes int    `json:"watchedEpisodes,omitempty"`
+	TotalEpisodes   int    `json:"totalEpisodes,omitempty"`
+}
+
+// cachedSnapshot is a built snapshot, ready to send
+type cachedSnapshot struct {
+	cursor  int64
+	builtAt time.Time
+	etag    string
+	body    []byte
+	gzipped []byte
+	brotli  []byte
+}
+
+// handleSnapshot handles GET /api/snapshot
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	snapshot, err := s.librarySnapshot(r, user)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", snapshot.etag)
+	if r.Header.Get("If-None-Match") == snapshot.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	switch {
+	case acceptsEncoding(r, "br"):
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(snapshot.brotli)
+	case acceptsEncoding(r, "gzip"):
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(snapshot.gzipped)
+	default:
+		w.Write(snapshot.body)
+	}
+}
+
+// acceptsEncoding reports whether a request's Accept-Encoding header lists a
+// content coding without refusing it with q=0
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		return q > 0
+	}
+	return false
+}
+
+// librarySnapshot returns the viewer's cached snapshot, rebuilding it if the
+// library changed since it was built
+func (s *Server) librarySnapshot(r *http.Request, user *database.User) (*cachedSnapshot, error) {
+	cursor, err := s.db.GetChangeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	// What a viewer sees depends on the user, the profile's played rule and
+	// whether a PIN elevation lifts their content limit
+	profileID := int64(0)
+	if id := s.getActiveProfileID(r); id != nil {
+		profileID = *id
+	}
+	key := fmt.Sprintf("%d:%d:%t", user.ID, profileID, s.getElevationToken(r) != "")
+
+	s.snapshotsMu.Lock()
+	cached := s.snapshots[key]
+	s.snapshotsMu.Unlock()
+	if cached != nil && cached.cursor == cursor && time.Since(cached.builtAt) < snapshotMaxAge {
+		return cached, nil
+	}
+
+	snapshot, err := s.buildSnapshot(r, user, cursor)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	var gz bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	zw.Write(body)
+	zw.Close()
+
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, brotli.BestCompression)
+	bw.Write(body)
+	bw.Close()
+
+	sum := sha1.Sum(body)
+	cached = &cachedSnapshot{
+		cursor:  cursor,
+		builtAt: snapshot.GeneratedAt,
+		etag:    `"` + hex.EncodeToString(sum[:]) + `"`,
+		body:    body,
+		gzipped: gz.Bytes(),
+		brotli:  br.Bytes(),
+	}
+
+	s.snapshotsMu.Lock()
+	s.snapshots[key] = cached
+	s.snapshotsMu.Unlock()
+	return cached, nil
+}
+
+// buildSnapshot collects the libraries, movies and shows a viewer can see.
+// cursor is read before building, so changes made meanwhile are synced again
+// rather than missed.
+func (s *Server) buildSnapshot(r *http.Request, user *database.User, cursor int64) (*LibrarySnapshot, error) {
+	snapshot := &LibrarySnapshot{
+		Version:     snapshotVersion,
+		GeneratedAt: time.Now(),
+		Cursor:      cursor,
+		Libraries:   []SnapshotLibrary{},
+		Movies:      []SnapshotMovie{},
+		Shows:       []SnapshotShow{},
+	}
+
+	libraries, err := s.db.GetLibraries()
+	if err != nil {
+		return nil, err
+	}
+	for _, lib := range libraries {
+		if user.CanAccessLibrary(lib.ID) {
+			snapshot.Libraries = append(snapshot.Libraries, SnapshotLibrary{
+				ID:           lib.ID,
+				Name:         lib.Name,
+				Type:         lib.Type,
+				ArtworkStyle: lib.ArtworkStyle,
+				ArtworkText:  lib.ArtworkText,
+			})
+		}
+	}
+
+	rule := s.db.GetPlayedRule(s.getActiveProfileID(r))
+	filtered := user.ContentRatingLimit != nil || user.LibraryIDs != nil
+
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		return nil, err
+	}
+	movieStates, _ := s.db.GetAllMovieWatchStates(rule)
+	for _, m := range movies {
+		if filtered && !(user.CanAccessLibrary(m.LibraryID) && s.isContentAllowed(user, m.ContentRating, r)) {
+			continue
+		}
+		item := SnapshotMovie{
+			ID:        m.ID,
+			LibraryID: m.LibraryID,
+			Title:     m.Title,
+			Year:      m.Year,
+			Poster:    stringValue(m.PosterPath),
+			Backdrop:  stringValue(m.BackdropPath),
+			Artwork:   stringValue(m.ArtworkPath),
+		}
+		if state, ok := movieStates[m.ID]; ok && state.WatchState != "unwatched" {
+			item.WatchState = state.WatchState
+			item.Progress = state.Progress
+		}
+		snapshot.Movies = append(snapshot.Movies, item)
+	}
+
+	shows, err := s.db.GetShows()
+	if err != nil {
+		return nil, err
+	}
+	showStates, _ := s.db.GetAllShowWatchStates(rule)
+	for _, sh := range shows {
+		if filtered && !(user.CanAccessLibrary(sh.LibraryID) && s.isContentAllowed(user, sh.ContentRating, r)) {
+			continue
+		}
+		item := SnapshotShow{
+			ID:        sh.ID,
+			LibraryID: sh.LibraryID,
+			Title:     sh.Title,
+			Year:      sh.Year,
+			Poster:    stringValue(sh.PosterPath),
+			Backdrop:  stringValue(sh.BackdropPath),
+			Artwork:   stringValue(sh.ArtworkPath),
+		}
+		if state, ok := showStates[sh.ID]; ok {
+			if state.WatchState != "unwatched" {
+				item.WatchState = state.WatchState
+			}
+			item.WatchedEpisodes = state.WatchedEpisodes
+			item.TotalEpisodes = state.TotalEpisodes
+		}
+		snapshot.Shows = append(snapshot.Shows, item)
+	}
+
+	return snapshot, nil
+}
+
+// stringValue returns the value of an optional string, or "" if unset
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/jobs"
+)
+
+// Storage
+//
+// Cached artwork and saved backups live in stores that are local folders by
+// default and can be moved to S3-compatible object storage with the
+// storage_images and storage_backups settings. Trickplay sheets, extracted
+// subtitles and trailers stay on local disk, since ffmpeg writes them
+// straight to files.
+//
+// Admins see where each purpose is stored at GET /api/system/storage and
+// copy what's in the default folder to the configured store with POST
+// /api/system/storage/{images|backups}/copy. Backups are saved with POST
+// /api/backups, listed with GET /api/backups, downloaded or deleted at
+// /api/backups/{name} and restored with POST /api/backups/{name}/restore.
+
+// backupPrefix starts the names of saved backups
+const backupPrefix = "outpost-backup-"
+
+// SetStorage sets the stores for cached artwork and saved backups
+func (s *Server) SetStorage(images, backups *blobstore.Switch) {
+	s.images = images
+	s.backups = backups
+}
+
+// storageFor returns the store for a purpose, "images" or "backups"
+func (s *Server) storageFor(purpose string) (*blobstore.Switch, string) {
+	switch purpose {
+	case "images":
+		return s.images, blobstore.SettingImages
+	case "backups":
+		return s.backups, blobstore.SettingBackups
+	}
+	return nil, ""
+}
+
+// configureStorage applies a changed storage setting
+func (s *Server) configureStorage(key, value string) {
+	store := s.images
+	if key == blobstore.SettingBackups {
+		store = s.backups
+	}
+	if store == nil {
+		return
+	}
+	if err := store.Configure(value); err != nil {
+		logger.Errorf("Failed to apply %s: %v", key, err)
+	}
+}
+
+// reloadStorage applies the saved storage settings, as after a restore
+func (s *Server) reloadStorage() {
+	for _, key := range []string{blobstore.SettingImages, blobstore.SettingBackups} {
+		value, _ := s.db.GetSetting(key)
+		s.configureStorage(key, value)
+	}
+}
+
+// serveStoredFile serves a file from a store, redirecting to it when clients
+// can fetch it directly
+func (s *Server) serveStoredFile(w http.ResponseWriter, r *http.Request, store blobstore.Store, key string) {
+	key, err := blobstore.CleanKey(key)
+	if err != nil || store == nil {
+		notFound(w)
+		return
+	}
+	if u := store.URL(key); u != "" {
+		http.Redirect(w, r, u, http.StatusFound)
+		return
+	}
+
+	file, info, err := store.Open(key)
+	if errors.Is(err, blobstore.ErrNotExist) {
+		notFound(w)
+		return
+	}
+	if err != nil {
+		requestLog(r).Errorf("Failed to open %s: %v", key, err)
+		httpError(w, "Failed to read file", http.StatusBadGateway)
+		return
+	}
+	defer file.Close()
+
+	// Local files can be seeked, so ranges and conditional requests work
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(key), info.ModTime, seeker)
+		return
+	}
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = blobstore.ContentType(key)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	io.Copy(w, file)
+}
+
+// storageStatus describes where a purpose's files are stored
+type storageStatus struct {
+	Purpose    string `json:"purpose"`
+	Setting    string `json:"setting"`
+	Location   string `json:"location"`
+	DefaultDir string `json:"defaultDir"`
+	Default    bool   `json:"default"` // Stored in the default folder
+}
+
+// handleStorage handles GET /api/system/storage
+func (s *Server) handleStorage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := []storageStatus{}
+	for _, purpose := range []string{"images", "backups"} {
+		store, setting := s.storageFor(purpose)
+		if store == nil {
+			continue
+		}
+		statuses = append(statuses, storageStatus{
+			Purpose:    purpose,
+			Setting:    setting,
+			Location:   store.Describe(),
+			DefaultDir: store.DefaultDir(),
+			Default:    store.IsDefault(),
+		})
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// handleStorageCopy handles POST /api/system/storage/{images|backups}/copy,
+// copying what's in the purpose's default folder to the store it's
+// configured to use as a job
+func (s *Server) handleStorageCopy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/system/storage/"), "/")
+	if len(parts) != 2 || parts[1] != "copy" {
+		notFound(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	purpose := parts[0]
+	store, _ := s.storageFor(purpose)
+	if store == nil {
+		httpError(w, "Unknown storage", http.StatusNotFound)
+		return
+	}
+
+	if store.IsDefault() {
+		httpError(w, "Storage is already the default folder", http.StatusBadRequest)
+		return
+	}
+	src, err := blobstore.NewLocal(store.DefaultDir(), "")
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dst := store.Current()
+
+	// Trickplay sheets are always read from local disk
+	var keep func(blobstore.Info) bool
+	if purpose == "images" {
+		keep = func(f blobstore.Info) bool { return !strings.HasPrefix(f.Key, "trickplay/") }
+	}
+	job, err := s.submitJob(r, jobStorageCopy, "Copy "+purpose+" to "+dst.Describe(), func(ctx context.Context, p *jobs.Progress) error {
+		copied, err := blobstore.Copy(ctx, dst, src, keep, p.Set)
+		p.SetResult(map[string]int{"copied": copied})
+		return err
+	})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "copying",
+		"jobId":  job.ID,
+	})
+}
+
+// handleStoredBackups handles /api/backups: GET lists saved backups newest
+// first and POST saves a new one
+func (s *Server) handleStoredBackups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.backups == nil {
+		writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "Backup storage not configured")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		files, err := s.backups.List(backupPrefix)
+		if err != nil {
+			requestLog(r).Errorf("Failed to list backups: %v", err)
+			httpError(w, "Failed to list backups", http.StatusBadGateway)
+			return
+		}
+		backups := []blobstore.Info{}
+		for _, f := range files {
+			if isBackupName(f.Key) {
+				backups = append(backups, f)
+			}
+		}
+		sort.Slice(backups, func(i, j int) bool { return backups[i].Key > backups[j].Key })
+		json.NewEncoder(w).Encode(backups)
+
+	case http.MethodPost:
+		data, err := s.createBackup()
+		if err != nil {
+			requestLog(r).Errorf("Failed to create backup: %v", err)
+			httpError(w, "Failed to create backup", http.StatusInternalServerError)
+			return
+		}
+		name := fmt.Sprintf("%s%s.json", backupPrefix, time.Now().UTC().Format("2006-01-02-150405"))
+		if err := blobstore.WriteFile(s.backups, name, data); err != nil {
+			requestLog(r).Errorf("Failed to save backup: %v", err)
+			httpError(w, "Failed to save backup", http.StatusBadGateway)
+			return
+		}
+		requestLog(r).Infof("Saved backup %s to %s", name, s.backups.Describe())
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(blobstore.Info{Key: name, Size: int64(len(data)), ModTime: time.Now(), ContentType: "application/json"})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStoredBackup handles /api/backups/{name}: GET downloads a saved
+// backup, DELETE removes it and POST /api/backups/{name}/restore?mode=
+// restores it like an uploaded one
+func (s *Server) handleStoredBackup(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/backups/"), "/")
+	name := parts[0]
+	if !isBackupName(name) || len(parts) > 2 || (len(parts) == 2 && parts[1] != "restore") {
+		notFound(w)
+		return
+	}
+	if s.backups == nil {
+		writeError(w, http.StatusServiceUnavailable, codeNotConfigured, "Backup storage not configured")
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mode := r.URL.Query().Get("mode")
+		if mode != "replace" && mode != "merge" {
+			mode = "merge" // Default to merge for safety
+		}
+		data, err := blobstore.ReadFile(s.backups, name)
+		if errors.Is(err, blobstore.ErrNotExist) {
+			httpError(w, "Backup not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			requestLog(r).Errorf("Failed to read backup %s: %v", name, err)
+			httpError(w, "Failed to read backup", http.StatusBadGateway)
+			return
+		}
+		s.restoreBackupRequest(w, r, data, mode)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+		s.serveStoredFile(w, r, s.backups, name)
+
+	case http.MethodDelete:
+		if _, err := s.backups.Stat(name); errors.Is(err, blobstore.ErrNotExist) {
+			httpError(w, "Backup not found", http.StatusNotFound)
+			return
+		}
+		if err := s.backups.Delete(name); err != nil {
+			requestLog(r).Errorf("Failed to delete backup %s: %v", name, err)
+			httpError(w, "Failed to delete backup", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// isBackupName reports whether a key names a saved backup
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, ".json") && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Stream bandwidth accounting
+
+// meterFlushBytes is how many bytes a stream serves between usage updates
+const meterFlushBytes = 8 << 20
+
+// meteredWriter counts the bytes a stream request serves towards the user's
+// bandwidth usage. The session is only recorded once something is served, so
+// failed requests don't show up.
+type meteredWriter struct {
+	http.ResponseWriter
+	db      *database.Database
+	session *database.StreamSession
+	status  int
+	pending int64
+	started bool
+}
+
+func (m *meteredWriter) WriteHeader(status int) {
+	m.status = status
+	m.ResponseWriter.WriteHeader(status)
+}
+
+func (m *meteredWriter) Write(p []byte) (int, error) {
+	n, err := m.ResponseWriter.Write(p)
+	m.pending += int64(n)
+	if m.pending >= meterFlushBytes {
+		m.record()
+	}
+	return n, err
+}
+
+// Flush passes flushes through for transcoded streams
+func (m *meteredWriter) Flush() {
+	if f, ok := m.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// record saves the bytes served since the last update
+func (m *meteredWriter) record() {
+	if m.db == nil || m.pending == 0 || m.status >= http.StatusBadRequest {
+		return
+	}
+	if !m.started {
+		if err := m.db.StartStreamSession(m.session); err != nil {
+			logger.Errorf("Failed to start stream session: %v", err)
+			return
+		}
+		m.started = true
+	}
+	if err := m.db.AddStreamBytes(m.session, m.pending); err != nil {
+		logger.Errorf("Failed to record stream bandwidth: %v", err)
+		return
+	}
+	m.pending = 0
+}
+
+// meterStream wraps a stream response to count its bytes. It returns false,
+// having written an error, when the user has used up their monthly transfer
+// cap. Caps only apply to remote streams, and never to admins.
+func (s *Server) meterStream(w http.ResponseWriter, r *http.Request, mediaType string, mediaID int64) (*meteredWriter, bool) {
+	user := s.getCurrentUser(r)
+	if user == nil {
+		return &meteredWriter{ResponseWriter: w}, true
+	}
+
+	ip := netaccess.ClientIP(r)
+	session := &database.StreamSession{
+		UserID:    user.ID,
+		MediaType: mediaType,
+		MediaID:   mediaID,
+		ClientIP:  ip,
+		Remote:    !netaccess.IsLocal(ip),
+	}
+	if authSession, ok := r.Context().Value(sessionContextKey).(*database.Session); ok {
+		session.AuthSessionID = &authSession.ID
+		session.DeviceID = authSession.DeviceID
+		session.ProfileID = authSession.ActiveProfileID
+	}
+
+	if session.Remote && user.Role != "admin" {
+		if limit := s.db.GetTransferCapBytes(user.ID); limit > 0 {
+			if used, err := s.db.GetMonthRemoteBytes(user.ID); err == nil && used >= limit {
+				localizedError(w, r, http.StatusForbidden, "Monthly transfer cap reached")
+				return nil, false
+			}
+		}
+	}
+
+	return &meteredWriter{ResponseWriter: w, db: s.db, session: session}, true
+}
+
+// validDay reports whether a value is a YYYY-MM-DD date
+func validDay(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+// handleBandwidthStats returns streaming transfer per user and per day, and
+// recent streaming sessions. Query: from, to (YYYY-MM-DD, default the last 30
+// days) and userId to narrow the daily totals and sessions to one user.
+func (s *Server) handleBandwidthStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Usage is counted in server days
+	now := timezone.Now()
+	from := now.AddDate(0, 0, -29).Format("2006-01-02")
+	to := now.Format("2006-01-02")
+	if v := r.URL.Query().Get("from"); v != "" {
+		from = v
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		to = v
+	}
+	if !validDay(from) || !validDay(to) {
+		httpError(w, "from and to must be dates (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if from > to {
+		httpError(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	var userID int64
+	if v := r.URL.Query().Get("userId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid userId")
+			return
+		}
+		userID = id
+	}
+
+	users, err := s.db.GetUserBandwidth(from, to)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	daily, err := s.db.GetDailyBandwidth(userID, from, to)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessions, err := s.db.GetStreamSessions(userID, 50)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type userUsage struct {
+		database.UserBandwidth
+		MonthRemoteBytes int64 `json:"monthRemoteBytes"`
+		CapBytes         int64 `json:"capBytes,omitempty"` // Monthly remote cap, omitted when unlimited
+	}
+	usage := make([]userUsage, 0, len(users))
+	for _, u := range users {
+		month, _ := s.db.GetMonthRemoteBytes(u.UserID)
+		usage = append(usage, userUsage{
+			UserBandwidth:    u,
+			MonthRemoteBytes: month,
+			CapBytes:         s.db.GetTransferCapBytes(u.UserID),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":     from,
+		"to":       to,
+		"users":    usage,
+		"daily":    daily,
+		"sessions": sessions,
+	})
+}
+
+// handleBandwidthCaps lists per-user monthly transfer caps, and sets or
+// clears one at /api/stats/bandwidth/caps/{userId} with {"monthlyGb": n}
+// (0 for unlimited, null to use the default)
+func (s *Server) handleBandwidthCaps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/stats/bandwidth/caps"), "/")
+	if idStr == "" {
+		if r.Method != http.MethodGet {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		caps, err := s.db.GetTransferCaps()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defaultGB, _ := s.db.GetSetting("bandwidth_monthly_cap_gb")
+		def, _ := strconv.Atoi(defaultGB)
+		byUser := make(map[string]int, len(caps))
+		for userID, gb := range caps {
+			byUser[strconv.FormatInt(userID, 10)] = gb
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"defaultGb": def,
+			"users":     byUser,
+		})
+		return
+	}
+
+	userID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid user ID")
+		return
+	}
+	if r.Method != http.MethodPut {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := s.db.GetUserByID(userID); err != nil {
+		httpError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		MonthlyGB *int `json:"monthlyGb"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.MonthlyGB != nil && *req.MonthlyGB < 0 {
+		httpError(w, "monthlyGb must be 0 (unlimited) or more", http.StatusBadRequest)
+		return
+	}
+	if err := s.db.SetTransferCap(userID, req.MonthlyGB); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"userId":   userID,
+		"capBytes": s.db.GetTransferCapBytes(userID),
+	})
+}
@@ -9,6 +9,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/netaccess"
+	"github.com/outpost/outpost/internal/transcode"
 )
 
 // Streaming handlers
@@ -43,7 +47,7 @@ type AudioStream struct {
 // handleStream handles streaming with transcoding support
 func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -52,38 +56,64 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) != 2 {
-		http.Error(w, "Invalid stream path", http.StatusBadRequest)
+		httpError(w, "Invalid stream path", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
+
+	// Trailers are keyed by their YouTube video rather than an ID
+	if mediaType == "trailer" {
+		if allowCountry(w, r) && s.admitStream(w, r, mediaType, 0) {
+			s.serveTrailer(w, r, parts[1])
+		}
+		return
+	}
+
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	if !allowCountry(w, r) || !s.allowMediaAccess(w, r, mediaType, id) {
+		return
+	}
+
+	// New streams wait out maintenance; ones already playing drain
+	if !s.admitStream(w, r, mediaType, id) {
 		return
 	}
 
+	// Count the bytes served towards the user's bandwidth usage
+	meter, ok := s.meterStream(w, r, mediaType, id)
+	if !ok {
+		return
+	}
+	defer meter.record()
+	w = meter
+
 	var filePath string
 
 	switch mediaType {
 	case "movie":
 		movie, err := s.db.GetMovie(id)
 		if err != nil {
-			http.Error(w, "Movie not found", http.StatusNotFound)
+			httpError(w, "Movie not found", http.StatusNotFound)
 			return
 		}
 		filePath = movie.Path
 	case "episode":
 		episode, err := s.db.GetEpisode(id)
 		if err != nil {
-			http.Error(w, "Episode not found", http.StatusNotFound)
+			httpError(w, "Episode not found", http.StatusNotFound)
 			return
 		}
 		filePath = episode.Path
 	case "track":
 		track, err := s.db.GetTrack(id)
 		if err != nil {
-			http.Error(w, "Track not found", http.StatusNotFound)
+			httpError(w, "Track not found", http.StatusNotFound)
 			return
 		}
 		filePath = track.Path
@@ -93,27 +123,57 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	case "book":
 		book, err := s.db.GetBook(id)
 		if err != nil {
-			http.Error(w, "Book not found", http.StatusNotFound)
+			httpError(w, "Book not found", http.StatusNotFound)
 			return
 		}
 		filePath = book.Path
 		// Serve books directly
 		s.serveFileDirectly(w, r, filePath)
 		return
+	case "podcast_episode":
+		episode, err := s.db.GetPodcastEpisode(id)
+		if err != nil {
+			httpError(w, "Episode not found", http.StatusNotFound)
+			return
+		}
+		// Episodes that aren't downloaded play from the feed's host
+		if episode.FilePath == nil {
+			http.Redirect(w, r, episode.AudioURL, http.StatusFound)
+			return
+		}
+		s.serveFileDirectly(w, r, *episode.FilePath)
+		return
+	case "audiobook_file":
+		file, err := s.db.GetAudiobookFile(id)
+		if err != nil {
+			httpError(w, "Audiobook file not found", http.StatusNotFound)
+			return
+		}
+		s.serveFileDirectly(w, r, file.Path)
+		return
 	default:
-		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		httpError(w, "Invalid media type", http.StatusBadRequest)
 		return
 	}
 
 	// Check file exists
 	if _, err := os.Stat(filePath); err != nil {
-		http.Error(w, "File not found", http.StatusNotFound)
+		httpError(w, "File not found", http.StatusNotFound)
 		return
 	}
 
-	// Check if file is browser-compatible (direct play)
+	// Check if file is browser-compatible (direct play), unless the device
+	// prefers one or the other. Players that handle any container ask for
+	// the file as it is with static=true.
 	ext := strings.ToLower(filepath.Ext(filePath))
 	canDirectPlay := ext == ".mp4" || ext == ".webm" || ext == ".m4v"
+	device := s.currentDevice(r)
+	if device != nil && device.PreferredPlayer != nil {
+		canDirectPlay = *device.PreferredPlayer == "direct"
+	}
+	if r.URL.Query().Get("static") == "true" {
+		canDirectPlay = true
+	}
 
 	// Direct play for compatible files (browser handles seeking via Range requests)
 	if canDirectPlay {
@@ -122,20 +182,20 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Transcode for non-compatible files (MKV, AVI, etc.)
-	s.serveTranscodedVideo(w, r, filePath)
+	s.serveTranscodedVideo(w, r, mediaType, id, filePath, device)
 }
 
 // serveFileDirectly serves a file without transcoding
 func (s *Server) serveFileDirectly(w http.ResponseWriter, r *http.Request, filePath string) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		http.Error(w, "File not found", http.StatusNotFound)
+		httpError(w, "File not found", http.StatusNotFound)
 		return
 	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		http.Error(w, "Cannot open file", http.StatusInternalServerError)
+		httpError(w, "Cannot open file", http.StatusInternalServerError)
 		return
 	}
 	defer file.Close()
@@ -175,7 +235,7 @@ func (s *Server) serveFileDirectly(w http.ResponseWriter, r *http.Request, fileP
 // handleMediaInfo returns media information including duration
 func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -184,14 +244,26 @@ func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(path, "/")
 
 	if len(parts) != 2 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		httpError(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
 
 	mediaType := parts[0]
+
+	// Trailers are keyed by their YouTube video rather than an ID
+	if mediaType == "trailer" {
+		if allowCountry(w, r) && s.admitStream(w, r, mediaType, 0) {
+			s.serveTrailer(w, r, parts[1])
+		}
+		return
+	}
+
 	id, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	if !s.allowMediaAccess(w, r, mediaType, id) {
 		return
 	}
 
@@ -201,19 +273,19 @@ func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 	case "movie":
 		movie, err := s.db.GetMovie(id)
 		if err != nil {
-			http.Error(w, "Movie not found", http.StatusNotFound)
+			httpError(w, "Movie not found", http.StatusNotFound)
 			return
 		}
 		filePath = movie.Path
 	case "episode":
 		episode, err := s.db.GetEpisode(id)
 		if err != nil {
-			http.Error(w, "Episode not found", http.StatusNotFound)
+			httpError(w, "Episode not found", http.StatusNotFound)
 			return
 		}
 		filePath = episode.Path
 	default:
-		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		httpError(w, "Invalid media type", http.StatusBadRequest)
 		return
 	}
 
@@ -228,7 +300,7 @@ func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 
 	output, err := cmd.Output()
 	if err != nil {
-		http.Error(w, "Failed to get media info", http.StatusInternalServerError)
+		httpError(w, "Failed to get media info", http.StatusInternalServerError)
 		return
 	}
 
@@ -264,7 +336,7 @@ func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.Unmarshal(output, &probeResult); err != nil {
-		http.Error(w, "Failed to parse media info", http.StatusInternalServerError)
+		httpError(w, "Failed to parse media info", http.StatusInternalServerError)
 		return
 	}
 
@@ -367,24 +439,32 @@ func (s *Server) handleMediaInfo(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// serveTranscodedVideo transcodes video on-the-fly using FFmpeg
-func (s *Server) serveTranscodedVideo(w http.ResponseWriter, r *http.Request, filePath string) {
+// serveTranscodedVideo transcodes video on-the-fly using FFmpeg, within the
+// device's bitrate cap if it has one
+func (s *Server) serveTranscodedVideo(w http.ResponseWriter, r *http.Request, mediaType string, id int64, filePath string, device *database.Device) {
 	// Check for seek position (in seconds)
 	startTime := r.URL.Query().Get("t")
 
+	// Video is re-encoded to ensure proper sync after seek, by the
+	// configured encoder
+	setting, _ := s.db.GetSetting("transcode_encoder")
+	encoder := transcode.Resolve(setting)
+	inputArgs, videoArgs := transcode.Args(encoder)
+
 	// Build FFmpeg arguments
-	args := []string{}
+	args := append([]string{}, inputArgs...)
 
 	// Add seek position before input for fast initial seek
 	if startTime != "" {
 		args = append(args, "-ss", startTime)
 	}
 
+	args = append(args, "-i", filePath)
+	args = append(args, videoArgs...)
+	if device != nil && device.MaxBitrate != nil {
+		args = append(args, transcode.MaxBitrateArgs(*device.MaxBitrate)...)
+	}
 	args = append(args,
-		"-i", filePath,
-		"-c:v", "libx264",      // Re-encode video to ensure proper sync after seek
-		"-preset", "ultrafast", // Fast encoding
-		"-crf", "23",           // Quality level
 		"-c:a", "aac",          // Transcode audio to AAC
 		"-b:a", "192k",         // Audio bitrate
 		"-ac", "2",             // Stereo audio
@@ -393,20 +473,38 @@ func (s *Server) serveTranscodedVideo(w http.ResponseWriter, r *http.Request, fi
 		"-",         // Output to stdout
 	)
 
-	cmd := exec.Command("ffmpeg", args...)
-
-	// Get stdout pipe
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		http.Error(w, "Failed to create pipe", http.StatusInternalServerError)
+	session := &transcode.Session{
+		MediaType: mediaType,
+		MediaID:   id,
+		Encoder:   encoder,
+		ClientIP:  netaccess.ClientIP(r),
+	}
+	if device != nil {
+		session.Device = device.Name
+	}
+	if user := s.getCurrentUser(r); user != nil {
+		session.UserID = user.ID
+		session.Username = user.Username
+	}
+	limit := 0
+	if v, err := s.db.GetSetting("transcode_max_sessions"); err == nil {
+		limit, _ = strconv.Atoi(v)
+	}
+	if !s.admitTranscode(w, r) {
 		return
 	}
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		http.Error(w, "Failed to start transcoding", http.StatusInternalServerError)
+	// FFmpeg is killed when the client goes away
+	stdout, err := s.transcodes.Start(r.Context(), session, limit, args)
+	if err == transcode.ErrLimitReached {
+		localizedError(w, r, http.StatusServiceUnavailable, "Too many transcoded streams, try again later")
 		return
 	}
+	if err != nil {
+		httpError(w, "Failed to start transcoding", http.StatusInternalServerError)
+		return
+	}
+	defer s.transcodes.Finish(session)
 
 	// Set headers for streaming
 	w.Header().Set("Content-Type", "video/mp4")
@@ -419,7 +517,6 @@ func (s *Server) serveTranscodedVideo(w http.ResponseWriter, r *http.Request, fi
 		n, err := stdout.Read(buf)
 		if n > 0 {
 			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
-				cmd.Process.Kill()
 				break
 			}
 			if f, ok := w.(http.Flusher); ok {
@@ -430,6 +527,4 @@ func (s *Server) serveTranscodedVideo(w http.ResponseWriter, r *http.Request, fi
 			break
 		}
 	}
-
-	cmd.Wait()
 }
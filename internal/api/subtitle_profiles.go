@@ -0,0 +1,373 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/jobs"
+)
+
+// Subtitle profiles
+//
+// The subtitle languages each library wants, downloaded from OpenSubtitles
+// by the Subtitle Search task for new files:
+//
+//	GET    /api/subtitle-profiles                      the profiles
+//	POST   /api/subtitle-profiles                      add {name, languages, hearingImpaired, forcedOnly}
+//	GET    /api/subtitle-profiles/{id}                 a profile
+//	PUT    /api/subtitle-profiles/{id}                 change a profile
+//	DELETE /api/subtitle-profiles/{id}                 delete a profile, leaving its libraries without one
+//	GET    /api/libraries/{id}/subtitle-profile        a library's profile
+//	PUT    /api/libraries/{id}/subtitle-profile        set it {subtitleProfileId}, null for none
+//	GET    /api/subtitle-searches/{type}/{id}          where the search is with a movie or episode, its
+//	                                                   downloaded subtitles and their scores, and its history
+//	POST   /api/subtitle-searches/{type}/{id}/retry    search a movie or episode again now
+//	GET    /api/subtitle-history?limit=n               what was downloaded lately, for everything
+//
+// Libraries without a profile keep the OpenSubtitles auto-download settings.
+
+// subtitleProfileHI are the hearing impaired preferences a profile can have
+var subtitleProfileHI = map[string]bool{
+	database.SubtitleHIAny:     true,
+	database.SubtitleHIPrefer:  true,
+	database.SubtitleHIOnly:    true,
+	database.SubtitleHIExclude: true,
+}
+
+// handleSubtitleProfiles handles GET and POST /api/subtitle-profiles
+func (s *Server) handleSubtitleProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		profiles, err := s.db.GetSubtitleProfiles()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if profiles == nil {
+			profiles = []database.SubtitleProfile{}
+		}
+		json.NewEncoder(w).Encode(profiles)
+
+	case http.MethodPost:
+		var profile database.SubtitleProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		if !s.validateSubtitleProfile(w, &profile) {
+			return
+		}
+		if err := s.db.CreateSubtitleProfile(&profile); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(profile)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSubtitleProfile handles GET, PUT and DELETE /api/subtitle-profiles/{id}
+func (s *Server) handleSubtitleProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/subtitle-profiles/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+	profile, err := s.db.GetSubtitleProfile(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		httpError(w, "Subtitle profile not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(profile)
+
+	case http.MethodPut:
+		var update database.SubtitleProfile
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, "Invalid request body")
+			return
+		}
+		update.ID = id
+		update.CreatedAt = profile.CreatedAt
+		if !s.validateSubtitleProfile(w, &update) {
+			return
+		}
+		if err := s.db.UpdateSubtitleProfile(&update); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(update)
+
+	case http.MethodDelete:
+		if err := s.db.DeleteSubtitleProfile(id); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validateSubtitleProfile checks and tidies a profile being saved, writing
+// the error if it isn't valid
+func (s *Server) validateSubtitleProfile(w http.ResponseWriter, profile *database.SubtitleProfile) bool {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		httpError(w, "name is required", http.StatusBadRequest)
+		return false
+	}
+	existing, err := s.db.GetSubtitleProfiles()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	for _, other := range existing {
+		if other.ID != profile.ID && strings.EqualFold(other.Name, profile.Name) {
+			httpError(w, "A subtitle profile with that name already exists", http.StatusConflict)
+			return false
+		}
+	}
+
+	var languages []string
+	seen := make(map[string]bool)
+	for _, lang := range profile.Languages {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || strings.ContainsAny(lang, ",. /") {
+			if lang != "" {
+				httpError(w, "Invalid language: "+lang, http.StatusBadRequest)
+				return false
+			}
+			continue
+		}
+		if !seen[strings.ToLower(lang)] {
+			seen[strings.ToLower(lang)] = true
+			languages = append(languages, lang)
+		}
+	}
+	if len(languages) == 0 {
+		httpError(w, "At least one language is required", http.StatusBadRequest)
+		return false
+	}
+	profile.Languages = languages
+
+	if profile.HearingImpaired == "" {
+		profile.HearingImpaired = database.SubtitleHIAny
+	}
+	if !subtitleProfileHI[profile.HearingImpaired] {
+		httpError(w, "hearingImpaired must be any, prefer, only or exclude", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// handleLibrarySubtitleProfile handles /api/libraries/{id}/subtitle-profile:
+// which subtitle profile a library's files are searched for
+func (s *Server) handleLibrarySubtitleProfile(w http.ResponseWriter, r *http.Request, libraryID int64) {
+	w.Header().Set("Content-Type", "application/json")
+
+	lib, err := s.db.GetLibrary(libraryID)
+	if err != nil {
+		httpError(w, "Library not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			SubtitleProfileID *int64 `json:"subtitleProfileId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidBody, err.Error())
+			return
+		}
+		if req.SubtitleProfileID != nil {
+			if lib.Type != "movies" && lib.Type != "tv" && lib.Type != "anime" {
+				httpError(w, "Only movie and TV libraries have subtitles", http.StatusBadRequest)
+				return
+			}
+			profile, err := s.db.GetSubtitleProfile(*req.SubtitleProfileID)
+			if err != nil {
+				httpError(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if profile == nil {
+				httpError(w, "Subtitle profile not found", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.db.UpdateLibrarySubtitleProfile(libraryID, req.SubtitleProfileID); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lib.SubtitleProfileID = req.SubtitleProfileID
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"libraryId":         libraryID,
+		"subtitleProfileId": lib.SubtitleProfileID,
+	})
+}
+
+// handleSubtitleSearch handles /api/subtitle-searches/{type}/{id} and
+// /api/subtitle-searches/{type}/{id}/retry
+func (s *Server) handleSubtitleSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/subtitle-searches/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "retry") {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	mediaType := parts[0]
+	if mediaType != "movie" && mediaType != "episode" {
+		httpError(w, "Subtitles are only searched for movies and episodes", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	if len(parts) == 3 {
+		s.retrySubtitleSearch(w, r, mediaType, id)
+		return
+	}
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	search, err := s.db.GetSubtitleSearch(mediaType, id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	history, err := s.db.GetSubtitleHistory(mediaType, id, 100)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []database.SubtitleHistoryEntry{}
+	}
+	files, err := s.db.GetSubtitleFiles(mediaType, id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = []database.SubtitleFile{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"search":  search,
+		"files":   files,
+		"history": history,
+	})
+}
+
+// retrySubtitleSearch handles POST /api/subtitle-searches/{type}/{id}/retry,
+// searching a movie or episode's subtitles again as a job, however long it
+// was to wait
+func (s *Server) retrySubtitleSearch(w http.ResponseWriter, r *http.Request, mediaType string, id int64) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if apiKey, _ := s.db.GetSetting("opensubtitles_api_key"); apiKey == "" {
+		writeError(w, http.StatusBadRequest, codeNotConfigured, "OpenSubtitles API key not configured")
+		return
+	}
+	item, err := s.db.GetSubtitleSearchItem(mediaType, id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if item == nil {
+		httpError(w, "Not in a library with a subtitle profile", http.StatusNotFound)
+		return
+	}
+
+	title := item.Title
+	if mediaType == "episode" {
+		title = fmt.Sprintf("%s S%02dE%02d", item.Title, item.Season, item.Episode)
+	} else if title == "" {
+		title = filepath.Base(item.Path)
+	}
+	job, err := s.submitJob(r, jobSubtitleSearch, "Search subtitles of "+title, func(ctx context.Context, p *jobs.Progress) error {
+		if err := s.db.DeleteSubtitleSearch(mediaType, id); err != nil {
+			return err
+		}
+		downloaded, err := s.scanner.SearchSubtitles(item)
+		if err != nil {
+			return err
+		}
+		p.SetMessage(fmt.Sprintf("%d subtitles downloaded", downloaded))
+		return nil
+	})
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "searching",
+		"message": "Subtitle search started",
+		"jobId":   job.ID,
+	})
+}
+
+// handleSubtitleHistory handles GET /api/subtitle-history, the subtitles
+// downloaded or not found lately, newest first
+func (s *Server) handleSubtitleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			httpError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	history, err := s.db.GetSubtitleHistory("", 0, limit)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []database.SubtitleHistoryEntry{}
+	}
+	json.NewEncoder(w).Encode(history)
+}
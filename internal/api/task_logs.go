@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Task log streaming
+
+// taskLogHeartbeat keeps idle streams open through proxies
+const taskLogHeartbeat = 15 * time.Second
+
+// handleTaskLogStream handles GET /api/tasks/:id/logs - a server-sent event
+// stream of log lines written while the task runs. The stream stays open
+// across runs: "started" and "finished" events mark each run, and a client
+// connecting mid-run first gets the lines logged since it started. Lines come
+// from the application log, so anything else logged during the run is
+// included too.
+func (s *Server) handleTaskLogStream(w http.ResponseWriter, r *http.Request, taskID int64) {
+	task, err := s.db.GetTask(taskID)
+	if err != nil {
+		httpError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before reading the backlog so no lines fall between them
+	entries, unsubscribe := logging.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	send := func(event string, data interface{}) bool {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return true
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send("status", s.taskStatus(task.ID)) {
+		return
+	}
+
+	startedAt, running := s.scheduler.TaskStartedAt(task.Name)
+	if running {
+		for _, entry := range logging.Since(startedAt) {
+			if !send("log", entry) {
+				return
+			}
+		}
+	}
+
+	// refresh emits started/finished events when the task's run state changes
+	refresh := func() bool {
+		nowStartedAt, nowRunning := s.scheduler.TaskStartedAt(task.Name)
+		ok := true
+		switch {
+		case nowRunning && (!running || !nowStartedAt.Equal(startedAt)):
+			ok = send("started", map[string]time.Time{"startedAt": nowStartedAt})
+		case !nowRunning && running:
+			ok = send("finished", s.taskStatus(task.ID))
+		}
+		startedAt, running = nowStartedAt, nowRunning
+		return ok
+	}
+
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(taskLogHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case entry := <-entries:
+			// Catch runs that start or end between polls
+			if !refresh() {
+				return
+			}
+			if running && !send("log", entry) {
+				return
+			}
+
+		case <-poll.C:
+			if !refresh() {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// taskStatus returns a task as reported by the scheduler, with its runtime
+// state, next run and recent durations
+func (s *Server) taskStatus(taskID int64) *database.ScheduledTask {
+	for _, task := range s.scheduler.GetStatus() {
+		if task.ID == taskID {
+			return &task
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Time zone handling
+//
+// Dates a user picks or reads, such as calendar days and history ranges, are
+// in their own time zone when they've set one, otherwise the server's.
+
+// requestLocation returns the time zone of the signed-in user
+func requestLocation(r *http.Request) *time.Location {
+	if user, ok := r.Context().Value(userContextKey).(*database.User); ok {
+		return timezone.Resolve(user.Timezone)
+	}
+	return timezone.Location()
+}
+
+// normalizeTimezone validates a user's time zone. An empty value returns
+// nil, meaning the server's zone; ok is false if the zone is unknown.
+func normalizeTimezone(value string) (*string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, true
+	}
+	if _, err := timezone.Load(value); err != nil {
+		return nil, false
+	}
+	return &value, true
+}
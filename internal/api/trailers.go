@@ -0,0 +1,102 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/outpost/outpost/internal/trailer"
+)
+
+// Trailer playback
+//
+// With the trailer resolver turned on, YouTube trailers also play through
+// /api/stream/trailer/{key}, for clients that can't embed YouTube.
+
+// trailerMode returns how trailers are resolved, one of the trailer.Mode values
+func (s *Server) trailerMode() string {
+	mode, _ := s.db.GetSetting(trailer.Setting)
+	if mode == "" {
+		return trailer.ModeOff
+	}
+	return mode
+}
+
+// trailerStreamURL returns where a YouTube trailer can be streamed from, or
+// "" if trailers aren't resolved
+func (s *Server) trailerStreamURL(site, key string) string {
+	if site != "YouTube" || !trailer.ValidKey(key) || s.trailerMode() == trailer.ModeOff {
+		return ""
+	}
+	return "/api/stream/trailer/" + key
+}
+
+// serveTrailer handles GET /api/stream/trailer/{key}
+func (s *Server) serveTrailer(w http.ResponseWriter, r *http.Request, key string) {
+	if !trailer.ValidKey(key) {
+		httpError(w, "Invalid trailer key", http.StatusBadRequest)
+		return
+	}
+
+	switch s.trailerMode() {
+	case trailer.ModeCache:
+		path, err := s.trailers.CachedFile(key)
+		if err != nil {
+			requestLog(r).Errorf("Failed to download trailer %s: %v", key, err)
+			httpError(w, "Trailer unavailable", http.StatusBadGateway)
+			return
+		}
+		s.serveFileDirectly(w, r, path)
+	case trailer.ModeProxy:
+		s.proxyTrailer(w, r, key, true)
+	default:
+		httpError(w, "Trailer playback is turned off", http.StatusNotFound)
+	}
+}
+
+// proxyTrailer streams a trailer from its resolved URL, passing Range
+// requests through so players can seek. A URL that stopped working is
+// resolved again once.
+func (s *Server) proxyTrailer(w http.ResponseWriter, r *http.Request, key string, retry bool) {
+	url, err := s.trailers.StreamURL(key)
+	if err != nil {
+		requestLog(r).Errorf("Failed to resolve trailer %s: %v", key, err)
+		httpError(w, "Trailer unavailable", http.StatusBadGateway)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		httpError(w, "Trailer unavailable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		s.trailers.Forget(key)
+		if retry {
+			s.proxyTrailer(w, r, key, false)
+			return
+		}
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		requestLog(r).Errorf("Trailer %s stream answered %s", key, resp.Status)
+		httpError(w, "Trailer unavailable", http.StatusBadGateway)
+		return
+	}
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/transcode"
+)
+
+// handleTranscodeCapabilities handles GET /api/system/transcode-capabilities,
+// listing the video encoders and which of them work on this machine, and the
+// encoder transcoded streams use. ?refresh=true checks the encoders again,
+// e.g. after a driver was installed.
+func (s *Server) handleTranscodeCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caps := transcode.Detect(r.URL.Query().Get("refresh") == "true")
+	setting, _ := s.db.GetSetting("transcode_encoder")
+	if setting == "" {
+		setting = transcode.Auto
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ffmpeg":      caps.FFmpeg,
+		"version":     caps.Version,
+		"vaapiDevice": caps.VAAPIDevice,
+		"encoders":    caps.Encoders,
+		"detectedAt":  caps.DetectedAt,
+		"setting":     setting,
+		"active":      transcode.Resolve(setting),
+	})
+}
+
+// handleTranscodeSessions handles GET /api/transcode/sessions, listing the
+// running transcodes and the limit on how many may run at once
+func (s *Server) handleTranscodeSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 0
+	if v, err := s.db.GetSetting("transcode_max_sessions"); err == nil {
+		limit, _ = strconv.Atoi(v)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": s.transcodes.Sessions(),
+		"limit":    limit,
+	})
+}
+
+// handleTranscodeSession handles DELETE /api/transcode/sessions/{id},
+// stopping a transcode. The client's stream ends with it.
+func (s *Server) handleTranscodeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/transcode/sessions/")
+	if !s.transcodes.Stop(id) {
+		httpError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/trash"
+)
+
+// Trash
+//
+// Deleted movie and episode files wait in the trash until the retention
+// period runs out. Admins can list what's there at /api/trash, restore an
+// item to put it back in the library, or purge items early.
+
+// handleTrash handles GET /api/trash, listing the items in the trash with
+// where they're kept and for how long, and DELETE to empty it
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.trash.List()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var size int64
+		for _, item := range items {
+			size += item.Size
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items":         items,
+			"size":          size,
+			"dir":           s.trash.Dir(),
+			"retentionDays": s.trash.Retention(),
+		})
+
+	case http.MethodDelete:
+		if isDryRun(r) {
+			s.simulateTrashEmpty(w, r)
+			return
+		}
+		removed, err := s.trash.Empty()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "trash.empty", "trash", nil, fmt.Sprintf("%d items", removed))
+		json.NewEncoder(w).Encode(map[string]int{"removed": removed})
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTrashItem handles GET and DELETE /api/trash/{id}, DELETE removing
+// the item for good, and POST /api/trash/{id}/restore
+func (s *Server) handleTrashItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/trash/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "restore" {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.restoreTrashItem(w, r, id)
+		return
+	}
+	if len(parts) != 1 {
+		httpError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	item, err := s.trash.Get(id)
+	if errors.Is(err, trash.ErrNotFound) {
+		httpError(w, "Trash item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(item)
+
+	case http.MethodDelete:
+		if err := s.trash.Purge(id); err != nil {
+			requestLog(r).Errorf("Failed to purge trash item %d: %v", id, err)
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.recordAudit(r, "trash.purge", "trash", &id, item.Title)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// restoreTrashItem puts an item's file back and adds it to the library
+// again. mediaId is the ID it's added back with, or 0 if the next library
+// scan has to pick it up.
+func (s *Server) restoreTrashItem(w http.ResponseWriter, r *http.Request, id int64) {
+	item, mediaID, err := s.trash.Restore(id)
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		httpError(w, "Trash item not found", http.StatusNotFound)
+		return
+	case errors.Is(err, trash.ErrRestoreConflict):
+		httpError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		requestLog(r).Errorf("Failed to restore trash item %d: %v", id, err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.recordAudit(r, "trash.restore", "trash", &id, item.Title)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item":    item,
+		"mediaId": mediaID,
+	})
+}
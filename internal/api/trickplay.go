@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/scanner"
+)
+
+// Trickplay
+//
+// Scrubbing previews for the player, made by the Trickplay task:
+//
+//	GET /api/trickplay/{type}/{id}         how the storyboard is laid out, and its sheets
+//	GET /api/trickplay/{type}/{id}/{n}.jpg a sheet
+//
+// Both are 404 until the storyboard has been made.
+
+// trickplayManifest is a storyboard's layout and where its sheets are
+type trickplayManifest struct {
+	database.Trickplay
+	SheetURLs []string `json:"sheetUrls"`
+}
+
+// handleTrickplay handles GET /api/trickplay/{type}/{id}[/{n}.jpg]
+func (s *Server) handleTrickplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/trickplay/"), "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		httpError(w, "Invalid trickplay path", http.StatusBadRequest)
+		return
+	}
+	mediaType := parts[0]
+	if mediaType != "movie" && mediaType != "episode" {
+		httpError(w, "Trickplay is only made for movies and episodes", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidID, "Invalid ID")
+		return
+	}
+
+	t, err := s.db.GetTrickplay(mediaType, id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user := s.getCurrentUser(r)
+	if t == nil || t.Error != nil || !user.CanAccessLibrary(t.LibraryID) || !s.isContentAllowed(user, t.ContentRating, r) {
+		httpError(w, "Trickplay not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(strings.TrimSuffix(parts[2], ".jpg"))
+		if err != nil || !strings.HasSuffix(parts[2], ".jpg") || n < 0 || n >= t.Sheets {
+			httpError(w, "Sheet not found", http.StatusNotFound)
+			return
+		}
+		imageDir := filepath.Join(filepath.Dir(s.config.DBPath), "images")
+		sheet := filepath.Join(scanner.TrickplayDir(imageDir, mediaType, id), strconv.Itoa(n)+".jpg")
+		if _, err := os.Stat(sheet); err != nil {
+			httpError(w, "Sheet not found", http.StatusNotFound)
+			return
+		}
+		http.ServeFile(w, r, sheet)
+		return
+	}
+
+	manifest := trickplayManifest{Trickplay: *t, SheetURLs: make([]string, t.Sheets)}
+	for i := range manifest.SheetURLs {
+		manifest.SheetURLs[i] = fmt.Sprintf("/api/trickplay/%s/%d/%d.jpg", mediaType, id, i)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
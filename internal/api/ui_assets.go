@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// UI asset bundle
+
+// handleUIAssets returns the provider logos, genre icons and rating badges
+// clients should render. The ETag is the bundle version, so clients can
+// revalidate cheaply and refetch assets only when it changes.
+func (s *Server) handleUIAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.metadata == nil {
+		httpError(w, "Metadata service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	bundle, err := s.metadata.UIAssets(false)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := `"` + bundle.Version + `"`
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// handleUIAssetsRefresh rebuilds the UI asset bundle, picking up new
+// providers and genres from TMDB
+func (s *Server) handleUIAssetsRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.metadata == nil {
+		httpError(w, "Metadata service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	bundle, err := s.metadata.UIAssets(true)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
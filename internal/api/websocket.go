@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket events
+//
+// /api/ws pushes the same topics as /api/events over a WebSocket, one JSON
+// message per event: {"topic": "scan", "data": {...}}. Only what the server
+// needs is implemented - unfragmented text messages out, control frames in -
+// so the stream needs no third-party library. Messages from the client other
+// than pings and close are ignored.
+
+const (
+	// wsGUID is appended to the client's key to prove the server speaks WebSocket
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC11B59"
+	// wsWriteTimeout is how long a frame may take to write before the client
+	// is considered gone
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxClientFrame bounds frames from the client, which only sends control
+	// frames and small messages
+	wsMaxClientFrame = 4096
+)
+
+// WebSocket opcodes
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsMessage is an event as sent over the WebSocket
+type wsMessage struct {
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// wsConn is a server-side WebSocket connection. Writes are serialized so the
+// read loop can answer pings while events are sent.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// handleWebSocket handles GET /api/ws?topics=scan,downloads,notifications,
+// tasks,status, upgrading the connection to a WebSocket. Topics work as for
+// /api/events.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := s.getCurrentUser(r)
+	if user == nil {
+		httpError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	topics, err := parseEventTopics(r.URL.Query().Get("topics"), user.Role == "admin")
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errTopicForbidden {
+			status = http.StatusForbidden
+		}
+		httpError(w, err.Error(), status)
+		return
+	}
+
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		httpError(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		httpError(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		httpError(w, "Missing WebSocket key", http.StatusBadRequest)
+		return
+	}
+	// The session cookie goes along with cross-site WebSockets too, so other
+	// sites mustn't be able to open one
+	if !sameOrigin(r) {
+		httpError(w, "Cross-origin WebSockets are not allowed", http.StatusForbidden)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		httpError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ws := &wsConn{conn: conn, rw: rw}
+	accept := sha1.Sum([]byte(key + wsGUID))
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	// The read loop ends when the client closes the connection or goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		ws.readLoop()
+	}()
+
+	feed := s.newEventFeed(user, topics)
+	send := func(now time.Time) bool {
+		for _, event := range feed.poll(now) {
+			msg, err := json.Marshal(wsMessage{Topic: event.topic, Data: event.payload})
+			if err != nil {
+				continue
+			}
+			if err := ws.writeFrame(wsOpText, msg); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !send(time.Now()) {
+		return
+	}
+
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case now := <-poll.C:
+			if !send(now) {
+				return
+			}
+
+		case <-heartbeat.C:
+			if err := ws.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop reads client frames, answering pings and close frames, until the
+// connection ends
+func (ws *wsConn) readLoop() {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := ws.writeFrame(wsOpPong, payload); err != nil {
+				return
+			}
+		case wsOpClose:
+			// Echo the status code back, as the protocol asks
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			ws.writeFrame(wsOpClose, payload)
+			return
+		}
+	}
+}
+
+// readFrame reads one frame from the client. Client frames must be masked.
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		return 0, nil, errors.New("unmasked client frame")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxClientFrame {
+		return 0, nil, errors.New("client frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// writeFrame writes one unfragmented, unmasked frame
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := ws.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(payload); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+// headerHasToken reports whether a comma-separated header contains a token,
+// ignoring case
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether a request comes from a page on this server.
+// Requests without an Origin header come from non-browser clients.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
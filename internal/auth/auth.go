@@ -2,7 +2,10 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -13,8 +16,27 @@ import (
 const (
 	SessionDuration = 7 * 24 * time.Hour // 7 days
 	TokenLength     = 32
+
+	// Impersonation sessions are short-lived and capped
+	DefaultImpersonationDuration = 30 * time.Minute
+	MaxImpersonationDuration     = 4 * time.Hour
+
+	// Password reset links: admin-generated links are handed over out of band,
+	// so they live longer than ones sent by email
+	AdminResetLinkDuration = 24 * time.Hour
+	EmailResetLinkDuration = 1 * time.Hour
+)
+
+var (
+	ErrCannotImpersonateAdmin = errors.New("cannot impersonate an admin account")
+	ErrInvalidResetToken      = errors.New("reset link is invalid or has expired")
+	ErrAccountDisabled        = errors.New("account is disabled or has expired")
+	ErrInvalidAPIKey          = errors.New("API key is invalid or has been revoked")
 )
 
+// APIKeyPrefix starts every API key, so they're recognizable in configs
+const APIKeyPrefix = "op_"
+
 type Service struct {
 	db *database.Database
 }
@@ -54,6 +76,9 @@ func (s *Service) Login(username, password string) (*database.Session, *database
 	if !CheckPassword(password, user.PasswordHash) {
 		return nil, nil, bcrypt.ErrMismatchedHashAndPassword
 	}
+	if !user.Active() {
+		return nil, nil, ErrAccountDisabled
+	}
 
 	token, err := GenerateToken()
 	if err != nil {
@@ -73,6 +98,178 @@ func (s *Service) Login(username, password string) (*database.Session, *database
 	return session, user, nil
 }
 
+// Impersonate creates a time-limited session that acts as the target user on
+// behalf of an admin. The session records the admin as its impersonator.
+func (s *Service) Impersonate(admin *database.User, targetUserID int64, duration time.Duration) (*database.Session, *database.User, error) {
+	target, err := s.db.GetUserByID(targetUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.Role == "admin" {
+		return nil, nil, ErrCannotImpersonateAdmin
+	}
+
+	if duration <= 0 {
+		duration = DefaultImpersonationDuration
+	}
+	if duration > MaxImpersonationDuration {
+		duration = MaxImpersonationDuration
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adminID := admin.ID
+	session := &database.Session{
+		UserID:         target.ID,
+		Token:          token,
+		ExpiresAt:      time.Now().Add(duration),
+		ImpersonatorID: &adminID,
+	}
+
+	// Start in the target's default profile so profile-scoped data matches what they see
+	if profile, err := s.db.GetDefaultProfile(target.ID); err == nil && profile != nil {
+		session.ActiveProfileID = &profile.ID
+	}
+
+	if err := s.db.CreateSession(session); err != nil {
+		return nil, nil, err
+	}
+	if session.ActiveProfileID != nil {
+		s.db.SetActiveProfile(token, *session.ActiveProfileID)
+	}
+
+	return session, target, nil
+}
+
+// hashToken returns the stored form of a token that is only kept hashed:
+// password reset tokens and API keys
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreatePasswordReset issues a one-time reset token for a user, replacing any
+// outstanding ones. createdBy is the admin generating the link, or nil for
+// self-service email resets.
+func (s *Service) CreatePasswordReset(userID int64, createdBy *int64, duration time.Duration) (string, *database.PasswordReset, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.db.DeleteUserPasswordResets(userID)
+
+	reset := &database.PasswordReset{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(duration),
+	}
+	if err := s.db.CreatePasswordReset(reset); err != nil {
+		return "", nil, err
+	}
+	return token, reset, nil
+}
+
+// ValidatePasswordReset checks that a reset token is unused and unexpired
+func (s *Service) ValidatePasswordReset(token string) (*database.PasswordReset, *database.User, error) {
+	if token == "" {
+		return nil, nil, ErrInvalidResetToken
+	}
+	reset, err := s.db.GetPasswordResetByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, nil, ErrInvalidResetToken
+	}
+	if time.Now().After(reset.ExpiresAt) {
+		return nil, nil, ErrInvalidResetToken
+	}
+	user, err := s.db.GetUserByID(reset.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidResetToken
+	}
+	return reset, user, nil
+}
+
+// ResetPassword sets a new password using a reset token. The token is consumed
+// and every existing session for the user is invalidated.
+func (s *Service) ResetPassword(token, newPassword string) (*database.User, error) {
+	reset, user, err := s.ValidatePasswordReset(token)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	// The token is consumed before the password changes, so a token used
+	// twice at once only changes it once
+	if err := s.db.MarkPasswordResetUsed(reset.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidResetToken
+		}
+		return nil, err
+	}
+	if err := s.db.UpdateUserPassword(user.ID, hash); err != nil {
+		return nil, err
+	}
+
+	s.db.DeleteUserPasswordResets(user.ID)
+	s.db.DeleteUserSessions(user.ID)
+	s.db.DeleteUserPinElevations(user.ID)
+
+	return user, nil
+}
+
+// CreateAPIKey issues an API key acting as a user with the given scope.
+// Returns the key itself, which isn't stored and can't be shown again.
+func (s *Service) CreateAPIKey(name, scope string, userID int64, createdBy *int64) (string, *database.APIKey, error) {
+	token, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	key := APIKeyPrefix + token
+
+	apiKey := &database.APIKey{
+		Name:      name,
+		KeyHash:   hashToken(key),
+		KeyPrefix: key[:len(APIKeyPrefix)+8],
+		Scope:     scope,
+		UserID:    userID,
+		CreatedBy: createdBy,
+	}
+	if err := s.db.CreateAPIKey(apiKey); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// ValidateAPIKey checks an API key and returns it with the user it acts as.
+// Keys stop working when revoked or when their user is disabled or expires.
+func (s *Service) ValidateAPIKey(key string) (*database.APIKey, *database.User, error) {
+	if key == "" {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	apiKey, err := s.db.GetAPIKeyByHash(hashToken(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	if apiKey == nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	user, err := s.db.GetUserByID(apiKey.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if !user.Active() {
+		return nil, nil, ErrAccountDisabled
+	}
+	return apiKey, user, nil
+}
+
 // Logout invalidates a session
 func (s *Service) Logout(token string) error {
 	return s.db.DeleteSession(token)
@@ -90,7 +287,17 @@ func (s *Service) ValidateSession(token string) (*database.User, error) {
 		return nil, bcrypt.ErrMismatchedHashAndPassword // Session expired
 	}
 
-	return s.db.GetUserByID(session.UserID)
+	user, err := s.db.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// Expired accounts lose their sessions straight away rather than at the
+	// next expiry sweep
+	if !user.Active() {
+		s.db.DeleteUserSessions(user.ID)
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
 }
 
 // CreateUser creates a new user with hashed password
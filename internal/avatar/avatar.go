@@ -0,0 +1,86 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Profile avatars
+//
+// Profiles pick an avatar from the built-in gallery, generated SVGs that need
+// nothing downloaded, or upload a picture of their own. Uploads are cropped
+// to a square, scaled to Size and saved as JPEG, so whatever a phone sends
+// comes out small and the same shape as the gallery.
+
+const (
+	// Size is the width and height of processed uploads
+	Size = 256
+	// MaxUploadSize bounds the pictures accepted for processing
+	MaxUploadSize = 10 << 20
+	// maxPixels bounds the decoded size of an upload, so a small file can't
+	// claim a huge canvas
+	maxPixels = 40_000_000
+	quality   = 90
+)
+
+// ErrInvalidCrop is returned by Process for a crop outside the picture
+var ErrInvalidCrop = errors.New("avatar: crop is outside the picture")
+
+// Crop is a square in an uploaded picture, in its pixels
+type Crop struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Size int `json:"size"`
+}
+
+// Process crops a picture to a square and scales it to Size, returning a
+// JPEG. Without a crop the largest centered square is used.
+func Process(data []byte, crop *Crop) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("avatar: picture is too large (%dx%d)", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	var rect image.Rectangle
+	if crop == nil {
+		side := min(bounds.Dx(), bounds.Dy())
+		x := bounds.Min.X + (bounds.Dx()-side)/2
+		y := bounds.Min.Y + (bounds.Dy()-side)/2
+		rect = image.Rect(x, y, x+side, y+side)
+	} else {
+		if crop.Size <= 0 || crop.X < 0 || crop.Y < 0 {
+			return nil, ErrInvalidCrop
+		}
+		rect = image.Rect(crop.X, crop.Y, crop.X+crop.Size, crop.Y+crop.Size).Add(bounds.Min)
+		if !rect.In(bounds) {
+			return nil, ErrInvalidCrop
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, Size, Size))
+	// Transparent pictures go on white rather than JPEG's black
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, rect, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
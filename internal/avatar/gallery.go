@@ -0,0 +1,108 @@
+package avatar
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Built-in avatars are simple faces on colored circles. Each has an ID that
+// stays the same between versions, so profiles can keep pointing at one.
+
+// Avatar is a built-in avatar
+type Avatar struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	shape string
+}
+
+// gallery is the built-in avatars, in the order they're offered
+var gallery = []Avatar{
+	{ID: "smile-blue", Name: "Blue", Color: "#1e88e5", shape: "smile"},
+	{ID: "smile-green", Name: "Green", Color: "#43a047", shape: "smile"},
+	{ID: "smile-orange", Name: "Orange", Color: "#fb8c00", shape: "smile"},
+	{ID: "smile-purple", Name: "Purple", Color: "#8e24aa", shape: "smile"},
+	{ID: "cat", Name: "Cat", Color: "#f4511e", shape: "cat"},
+	{ID: "bear", Name: "Bear", Color: "#8d6e63", shape: "bear"},
+	{ID: "fox", Name: "Fox", Color: "#ef6c00", shape: "cat"},
+	{ID: "panda", Name: "Panda", Color: "#546e7a", shape: "bear"},
+	{ID: "robot", Name: "Robot", Color: "#00acc1", shape: "robot"},
+	{ID: "robot-red", Name: "Red Robot", Color: "#e53935", shape: "robot"},
+	{ID: "ghost", Name: "Ghost", Color: "#5c6bc0", shape: "ghost"},
+	{ID: "ghost-teal", Name: "Teal Ghost", Color: "#26a69a", shape: "ghost"},
+}
+
+// Gallery returns the built-in avatars
+func Gallery() []Avatar {
+	return append([]Avatar(nil), gallery...)
+}
+
+// Builtin returns a built-in avatar by its ID
+func Builtin(id string) (Avatar, bool) {
+	for _, a := range gallery {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Avatar{}, false
+}
+
+// Version returns a tag that changes with the avatar's drawing, for cache
+// busting
+func (a Avatar) Version() string {
+	sum := sha1.Sum([]byte(a.SVG()))
+	return hex.EncodeToString(sum[:4])
+}
+
+// SVG draws the avatar
+func (a Avatar) SVG() string {
+	var parts []string
+	switch a.shape {
+	case "cat":
+		parts = append(parts,
+			`<path d="M30 44 L38 18 L54 36 Z" fill="#ffffff" opacity="0.9"/>`,
+			`<path d="M98 44 L90 18 L74 36 Z" fill="#ffffff" opacity="0.9"/>`,
+			`<circle cx="64" cy="70" r="36" fill="#ffffff"/>`,
+			eyes(52, 76, 64),
+			`<path d="M60 76 L68 76 L64 81 Z" fill="#37474f"/>`,
+		)
+	case "bear":
+		parts = append(parts,
+			`<circle cx="36" cy="38" r="14" fill="#ffffff"/>`,
+			`<circle cx="92" cy="38" r="14" fill="#ffffff"/>`,
+			`<circle cx="64" cy="70" r="36" fill="#ffffff"/>`,
+			eyes(52, 76, 64),
+			`<ellipse cx="64" cy="82" rx="10" ry="7" fill="#37474f"/>`,
+		)
+	case "robot":
+		parts = append(parts,
+			`<rect x="61" y="18" width="6" height="14" fill="#ffffff"/>`,
+			`<circle cx="64" cy="18" r="6" fill="#ffffff"/>`,
+			`<rect x="30" y="32" width="68" height="64" rx="12" fill="#ffffff"/>`,
+			`<rect x="44" y="52" width="14" height="14" rx="3" fill="#37474f"/>`,
+			`<rect x="70" y="52" width="14" height="14" rx="3" fill="#37474f"/>`,
+			`<rect x="46" y="78" width="36" height="6" rx="3" fill="#37474f"/>`,
+		)
+	case "ghost":
+		parts = append(parts,
+			`<path d="M32 104 L32 62 A32 32 0 0 1 96 62 L96 104 L85 94 L75 104 L64 94 L53 104 L43 94 Z" fill="#ffffff"/>`,
+			eyes(52, 76, 62),
+			`<ellipse cx="64" cy="80" rx="6" ry="8" fill="#37474f"/>`,
+		)
+	default:
+		parts = append(parts,
+			`<circle cx="64" cy="64" r="38" fill="#ffffff"/>`,
+			eyes(50, 78, 56),
+			`<path d="M46 74 Q64 92 82 74" stroke="#37474f" stroke-width="6" fill="none" stroke-linecap="round"/>`,
+		)
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128" viewBox="0 0 128 128">`+
+		`<circle cx="64" cy="64" r="64" fill="%s"/>%s</svg>`, a.Color, strings.Join(parts, ""))
+}
+
+// eyes draws a pair of eyes
+func eyes(left, right, y int) string {
+	return fmt.Sprintf(`<circle cx="%d" cy="%d" r="5" fill="#37474f"/><circle cx="%d" cy="%d" r="5" fill="#37474f"/>`, left, y, right, y)
+}
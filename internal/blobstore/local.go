@@ -0,0 +1,163 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps files in a folder
+type LocalStore struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocal creates a store in dir, creating the folder if needed. publicURL,
+// if set, is where clients fetch files directly.
+func NewLocal(dir, publicURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, publicURL: strings.TrimRight(publicURL, "/")}, nil
+}
+
+// Dir returns the store's folder
+func (s *LocalStore) Dir() string {
+	return s.dir
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file first, so a reader never sees half a file
+func (s *LocalStore) Put(key string, r io.Reader, contentType string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".put-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Open returns an *os.File, so callers can seek in it
+func (s *LocalStore) Open(key string) (io.ReadCloser, *Info, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotExist
+	}
+	return f, s.info(key, stat), nil
+}
+
+func (s *LocalStore) Stat(key string) (*Info, error) {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.info(key, stat), nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	fullPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List only walks the folder the prefix is in
+func (s *LocalStore) List(prefix string) ([]Info, error) {
+	root := s.dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir, err := s.path(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+	var files []Info
+	err := filepath.WalkDir(root, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, fullPath)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		stat, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, *s.info(key, stat))
+		return nil
+	})
+	return files, err
+}
+
+func (s *LocalStore) URL(key string) string {
+	if s.publicURL == "" {
+		return ""
+	}
+	return s.publicURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *LocalStore) Describe() string {
+	return "local folder " + s.dir
+}
+
+func (s *LocalStore) info(key string, stat fs.FileInfo) *Info {
+	return &Info{Key: key, Size: stat.Size(), ModTime: stat.ModTime(), ContentType: ContentType(key)}
+}
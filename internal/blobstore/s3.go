@@ -0,0 +1,323 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Store keeps files in an S3-compatible bucket. Requests are signed with
+// AWS Signature Version 4, which MinIO, Backblaze B2, Wasabi, Cloudflare R2
+// and the like accept too.
+type S3Store struct {
+	cfg        Config
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+// s3Timeout bounds each request. Files are small, posters and backups, so a
+// slow request is a stuck one.
+const s3Timeout = 2 * time.Minute
+
+// NewS3 creates a store for the bucket a config names. Nothing is checked
+// until the first request.
+func NewS3(cfg *Config) *S3Store {
+	c := *cfg
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	c.Prefix = strings.TrimLeft(c.Prefix, "/")
+	c.PublicURL = strings.TrimRight(c.PublicURL, "/")
+	endpoint, _ := url.Parse(strings.TrimRight(c.Endpoint, "/"))
+	return &S3Store{cfg: c, endpoint: endpoint, httpClient: &http.Client{Timeout: s3Timeout}}
+}
+
+// Put reads the file into memory, since the signature covers its hash
+func (s *S3Store) Put(key string, r io.Reader, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(http.MethodPut, s.cfg.Prefix+key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Open(key string) (io.ReadCloser, *Info, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(http.MethodGet, s.cfg.Prefix+key, nil, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, s.info(key, resp), nil
+}
+
+func (s *S3Store) Stat(key string) (*Info, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(http.MethodHead, s.cfg.Prefix+key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return s.info(key, resp), nil
+}
+
+func (s *S3Store) Delete(key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodDelete, s.cfg.Prefix+key, nil, nil, nil)
+	if err == ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is a page of ListObjectsV2
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Store) List(prefix string) ([]Info, error) {
+	var files []Info
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bucket listing: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := strings.TrimPrefix(obj.Key, s.cfg.Prefix)
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue
+			}
+			files = append(files, Info{Key: key, Size: obj.Size, ModTime: obj.LastModified, ContentType: ContentType(key)})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3Store) URL(key string) string {
+	if s.cfg.PublicURL == "" {
+		return ""
+	}
+	return s.cfg.PublicURL + "/" + uriEncode(s.cfg.Prefix+strings.TrimPrefix(key, "/"), false)
+}
+
+func (s *S3Store) Describe() string {
+	return fmt.Sprintf("S3 bucket %s at %s", s.cfg.Bucket, s.cfg.Endpoint)
+}
+
+// info reads a file's details from the headers of a GET or HEAD
+func (s *S3Store) info(key string, resp *http.Response) *Info {
+	info := &Info{Key: key, ContentType: resp.Header.Get("Content-Type")}
+	info.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info
+}
+
+// s3Error is the error document S3 answers failed requests with
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a signed request for an object, or for the bucket when objectKey
+// is empty. A missing object is ErrNotExist; other failures are errors with
+// S3's message.
+func (s *S3Store) do(method, objectKey string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	if s.endpoint == nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", s.cfg.Endpoint)
+	}
+	u := *s.endpoint
+	objectPath := "/" + objectKey
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + objectPath
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	var e s3Error
+	if method != http.MethodHead {
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	}
+	if e.Code != "" {
+		return nil, fmt.Errorf("S3 %s %s failed: %s: %s", method, objectKey, e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("S3 %s %s failed: %s", method, objectKey, resp.Status)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a request
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Every header sent is signed, with the host, which Go sends itself
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes a query string the way signatures expect: sorted
+// by name, with everything but unreserved characters escaped
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode escapes everything but unreserved characters, and slashes
+// unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
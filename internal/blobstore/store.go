@@ -0,0 +1,340 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("blobstore")
+
+// Blob storage
+//
+// Cached artwork and saved backups are kept in a Store rather than straight
+// on the data volume, so either can be moved to S3-compatible object storage
+// such as AWS S3, MinIO or Backblaze B2 when the posters and backups grow
+// large. Each purpose is configured on its own by a JSON setting,
+// storage_images and storage_backups, and uses a folder under the data
+// directory when that's empty. Changed settings take effect at once; files
+// already stored aren't moved, but Copy can move them.
+//
+// Files are stored by key, a relative path with forward slashes like
+// "w500/abc.jpg", which is also how the database refers to cached images.
+
+// Backends
+const (
+	Local = "local"
+	S3    = "s3"
+)
+
+// Settings that configure a purpose's storage
+const (
+	SettingImages  = "storage_images"
+	SettingBackups = "storage_backups"
+)
+
+// ErrNotExist is returned for keys with nothing stored
+var ErrNotExist = errors.New("file does not exist")
+
+// Store keeps files by key
+type Store interface {
+	// Put stores a file, replacing any stored under the key
+	Put(key string, r io.Reader, contentType string) error
+	// Open returns a stored file and its details. The caller closes it.
+	Open(key string) (io.ReadCloser, *Info, error)
+	// Stat returns a stored file's details
+	Stat(key string) (*Info, error)
+	// Delete removes a file. Deleting a missing file isn't an error.
+	Delete(key string) error
+	// List returns the files whose keys start with prefix
+	List(prefix string) ([]Info, error)
+	// URL returns where clients can fetch a file directly, or "" when
+	// they have to get it through the server
+	URL(key string) string
+	// Describe names where files go, for logs and the API
+	Describe() string
+}
+
+// Info describes a stored file
+type Info struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	ContentType string    `json:"contentType,omitempty"`
+}
+
+// Config configures a purpose's storage, as stored in its setting
+type Config struct {
+	Backend string `json:"backend"` // local or s3
+
+	// Local: the folder files go in; the purpose's folder under the data
+	// directory when empty
+	Path string `json:"path,omitempty"`
+
+	// S3
+	Endpoint  string `json:"endpoint,omitempty"` // e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string `json:"region,omitempty"`   // us-east-1 when empty
+	Bucket    string `json:"bucket,omitempty"`
+	Prefix    string `json:"prefix,omitempty"` // Put in front of every key, e.g. "outpost/images/"
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	PathStyle bool   `json:"pathStyle,omitempty"` // Bucket in the path rather than the host name, as MinIO needs
+
+	// Where clients can fetch files directly, such as a public bucket or a
+	// CDN in front of it. Files are served through the server when empty.
+	PublicURL string `json:"publicUrl,omitempty"`
+}
+
+// ParseConfig reads a storage setting. An empty setting is local storage in
+// the default folder.
+func ParseConfig(value string) (*Config, error) {
+	cfg := &Config{Backend: Local}
+	if strings.TrimSpace(value) == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(value), cfg); err != nil {
+		return nil, fmt.Errorf("Invalid storage settings: %v", err)
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = Local
+	}
+	return cfg, cfg.validate()
+}
+
+func (c *Config) validate() error {
+	switch c.Backend {
+	case Local:
+		return nil
+	case S3:
+		if c.Bucket == "" {
+			return fmt.Errorf("S3 storage needs a bucket")
+		}
+		if c.AccessKey == "" || c.SecretKey == "" {
+			return fmt.Errorf("S3 storage needs an access key and secret key")
+		}
+		if c.Endpoint != "" {
+			u, err := url.Parse(c.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("S3 endpoint must be an http or https URL")
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown storage backend: %s", c.Backend)
+	}
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("Public URL must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// ValidateSetting checks the storage settings. Other settings are always
+// valid.
+func ValidateSetting(key, value string) error {
+	if key != SettingImages && key != SettingBackups {
+		return nil
+	}
+	_, err := ParseConfig(value)
+	return err
+}
+
+// Open creates the store a config describes. defaultDir is the folder local
+// storage uses when the config names none.
+func Open(cfg *Config, defaultDir string) (Store, error) {
+	switch cfg.Backend {
+	case S3:
+		return NewS3(cfg), nil
+	default:
+		dir := cfg.Path
+		if dir == "" {
+			dir = defaultDir
+		}
+		return NewLocal(dir, cfg.PublicURL)
+	}
+}
+
+// CleanKey checks a key and returns it in its canonical form. Keys can't
+// climb out of the store with "..".
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, `\`, "/")
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "\x00") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
+
+// ContentType guesses a file's type from its key
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// WriteFile stores data under a key
+func WriteFile(store Store, key string, data []byte) error {
+	return store.Put(key, bytes.NewReader(data), ContentType(key))
+}
+
+// ReadFile returns what's stored under a key
+func ReadFile(store Store, key string) ([]byte, error) {
+	r, _, err := store.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Exists reports whether something is stored under a key. A store that
+// can't be reached counts as not having it.
+func Exists(store Store, key string) bool {
+	_, err := store.Stat(key)
+	return err == nil
+}
+
+// Copy copies the files keep accepts, or all of them when it's nil, from one
+// store to another, skipping those already there with the same size. It
+// returns how many it copied. progress, if set, is called after each file.
+func Copy(ctx context.Context, dst, src Store, keep func(Info) bool, progress func(done, total int)) (int, error) {
+	listed, err := src.List("")
+	if err != nil {
+		return 0, err
+	}
+	var files []Info
+	for _, f := range listed {
+		if keep == nil || keep(f) {
+			files = append(files, f)
+		}
+	}
+	copied := 0
+	for i, f := range files {
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+		if existing, err := dst.Stat(f.Key); err != nil || existing.Size != f.Size {
+			r, info, err := src.Open(f.Key)
+			if err != nil {
+				return copied, err
+			}
+			contentType := info.ContentType
+			if contentType == "" {
+				contentType = ContentType(f.Key)
+			}
+			err = dst.Put(f.Key, r, contentType)
+			r.Close()
+			if err != nil {
+				return copied, fmt.Errorf("%s: %w", f.Key, err)
+			}
+			copied++
+		}
+		if progress != nil {
+			progress(i+1, len(files))
+		}
+	}
+	return copied, nil
+}
+
+// Switch is a Store whose backend can be swapped while it's in use, so
+// changed storage settings take effect without a restart
+type Switch struct {
+	mu         sync.RWMutex
+	store      Store
+	defaultDir string
+}
+
+// NewSwitch creates a switch using local storage in defaultDir until it's
+// configured otherwise
+func NewSwitch(defaultDir string) (*Switch, error) {
+	store, err := NewLocal(defaultDir, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Switch{store: store, defaultDir: defaultDir}, nil
+}
+
+// Configure swaps to the backend a storage setting describes. The backend in
+// use is kept when the setting is invalid.
+func (s *Switch) Configure(value string) error {
+	cfg, err := ParseConfig(value)
+	if err != nil {
+		return err
+	}
+	store, err := Open(cfg, s.defaultDir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	changed := s.store.Describe() != store.Describe()
+	s.store = store
+	s.mu.Unlock()
+	if changed {
+		logger.Infof("Storage now %s", store.Describe())
+	}
+	return nil
+}
+
+// DefaultDir returns the folder local storage uses by default
+func (s *Switch) DefaultDir() string {
+	return s.defaultDir
+}
+
+// IsDefault reports whether files are kept in the default folder
+func (s *Switch) IsDefault() bool {
+	local, ok := s.Current().(*LocalStore)
+	return ok && filepath.Clean(local.Dir()) == filepath.Clean(s.defaultDir)
+}
+
+// Current returns the backend in use
+func (s *Switch) Current() Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+func (s *Switch) Put(key string, r io.Reader, contentType string) error {
+	return s.Current().Put(key, r, contentType)
+}
+
+func (s *Switch) Open(key string) (io.ReadCloser, *Info, error) {
+	return s.Current().Open(key)
+}
+
+func (s *Switch) Stat(key string) (*Info, error) {
+	return s.Current().Stat(key)
+}
+
+func (s *Switch) Delete(key string) error {
+	return s.Current().Delete(key)
+}
+
+func (s *Switch) List(prefix string) ([]Info, error) {
+	return s.Current().List(prefix)
+}
+
+func (s *Switch) URL(key string) string {
+	return s.Current().URL(key)
+}
+
+func (s *Switch) Describe() string {
+	return s.Current().Describe()
+}
@@ -0,0 +1,181 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("chaos")
+
+// Chaos mode
+//
+// A debug setting that slows down and fails calls to external services so
+// admins and developers can check the UI and scheduler cope with timeouts,
+// retries and health warnings. HTTP clients of the covered services use
+// Transport, which does nothing while chaos mode is off.
+
+// Targets chaos mode can be aimed at
+const (
+	TargetTMDB     = "tmdb"
+	TargetIndexer  = "indexer"
+	TargetDownload = "download"
+)
+
+// Targets lists every target, in the order they're shown
+var Targets = []string{TargetTMDB, TargetIndexer, TargetDownload}
+
+// maxLatency caps injected latency; anything longer is just an outage
+const maxLatency = 2 * time.Minute
+
+// ErrInjected is returned by calls chaos mode made fail
+var ErrInjected = errors.New("chaos mode: injected failure")
+
+// Config is the chaos mode configuration
+type Config struct {
+	Enabled        bool          `json:"enabled"`
+	Latency        time.Duration `json:"latency"`        // Added before each call
+	FailurePercent int           `json:"failurePercent"` // Chance a call fails, 0-100
+	Targets        []string      `json:"targets"`        // Empty means all
+}
+
+var (
+	mu      sync.RWMutex
+	current Config
+)
+
+// Configure replaces the chaos mode configuration
+func Configure(cfg Config) {
+	mu.Lock()
+	current = cfg
+	mu.Unlock()
+
+	if cfg.Enabled {
+		targets := "all"
+		if len(cfg.Targets) > 0 {
+			targets = strings.Join(cfg.Targets, ", ")
+		}
+		logger.Infof("Chaos mode enabled: %s latency, %d%% failures, targets: %s", cfg.Latency, cfg.FailurePercent, targets)
+	}
+}
+
+// Current returns the chaos mode configuration
+func Current() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// applies reports whether calls to target are affected
+func (c Config) applies(target string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Targets) == 0 {
+		return true
+	}
+	for _, t := range c.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// FromSettings builds a configuration from the chaos_* settings. Invalid
+// values are ignored.
+func FromSettings(settings map[string]string) Config {
+	cfg := Config{Enabled: settings["chaos_enabled"] == "true"}
+	if ms, err := strconv.Atoi(settings["chaos_latency_ms"]); err == nil && ms > 0 {
+		cfg.Latency = time.Duration(ms) * time.Millisecond
+		if cfg.Latency > maxLatency {
+			cfg.Latency = maxLatency
+		}
+	}
+	if pct, err := strconv.Atoi(settings["chaos_failure_percent"]); err == nil && pct > 0 {
+		cfg.FailurePercent = pct
+		if cfg.FailurePercent > 100 {
+			cfg.FailurePercent = 100
+		}
+	}
+	for _, t := range strings.Split(settings["chaos_targets"], ",") {
+		if t = strings.TrimSpace(t); validTarget(t) {
+			cfg.Targets = append(cfg.Targets, t)
+		}
+	}
+	return cfg
+}
+
+// ValidateSetting checks the value of a chaos_* setting. Other settings are
+// always valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case "chaos_latency_ms":
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > maxLatency {
+			return fmt.Errorf("Chaos latency must be between 0 and %d ms", maxLatency.Milliseconds())
+		}
+	case "chaos_failure_percent":
+		pct, err := strconv.Atoi(value)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("Chaos failure percent must be between 0 and 100")
+		}
+	case "chaos_targets":
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" && !validTarget(t) {
+				return fmt.Errorf("Unknown chaos target: %s", t)
+			}
+		}
+	}
+	return nil
+}
+
+func validTarget(target string) bool {
+	for _, t := range Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Transport returns an HTTP transport for calls to target that injects
+// latency and failures while chaos mode is on
+func Transport(target string) http.RoundTripper {
+	return &transport{target: target, base: http.DefaultTransport}
+}
+
+type transport struct {
+	target string
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cfg := Current()
+	if !cfg.applies(t.target) {
+		return t.base.RoundTrip(req)
+	}
+
+	if cfg.Latency > 0 {
+		// Waiting on the request's context lets client timeouts fire as they
+		// would against a slow server
+		timer := time.NewTimer(cfg.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if cfg.FailurePercent > 0 && rand.Intn(100) < cfg.FailurePercent {
+		return nil, fmt.Errorf("%w (%s: %s)", ErrInjected, t.target, req.URL.Host)
+	}
+	return t.base.RoundTrip(req)
+}
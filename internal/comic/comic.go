@@ -0,0 +1,317 @@
+package comic
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Comic archives
+//
+// CBZ files are zip archives of page images and are read directly. CBR files
+// are RAR archives, which are read with unrar or, failing that, bsdtar
+// (libarchive); a CBR that's really a zip, as many are, is read as one.
+// Pages are the archive's images in natural order ("page2" before
+// "page10"). ComicInfo.xml, the ComicRack metadata file, names the series,
+// issue, writer and cover when it's there.
+
+// maxPageSize bounds how much of a page is read; scans rarely pass 20 MB
+const maxPageSize = 64 << 20
+
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".bmp": true,
+}
+
+// ComicInfo is the part of ComicInfo.xml Outpost uses
+type ComicInfo struct {
+	Title     string `xml:"Title"`
+	Series    string `xml:"Series"`
+	Number    string `xml:"Number"`
+	Volume    int    `xml:"Volume"`
+	Summary   string `xml:"Summary"`
+	Year      int    `xml:"Year"`
+	Writer    string `xml:"Writer"`
+	Publisher string `xml:"Publisher"`
+	PageCount int    `xml:"PageCount"`
+	Manga     string `xml:"Manga"` // Yes, No, YesAndRightToLeft
+	Pages     []struct {
+		Image int    `xml:"Image,attr"`
+		Type  string `xml:"Type,attr"`
+	} `xml:"Pages>Page"`
+}
+
+// CoverIndex returns the index of the page ComicInfo marks as the front
+// cover, or 0
+func (c *ComicInfo) CoverIndex() int {
+	if c == nil {
+		return 0
+	}
+	for _, p := range c.Pages {
+		if p.Type == "FrontCover" {
+			return p.Image
+		}
+	}
+	return 0
+}
+
+// Archive is an opened comic archive
+type Archive struct {
+	path  string
+	zip   *zip.ReadCloser // nil for RAR
+	Pages []string        // Page image names, in reading order
+	Info  *ComicInfo      // nil without ComicInfo.xml
+}
+
+// Open opens a CBZ or CBR file, listing its pages
+func Open(filePath string) (*Archive, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(f, magic)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("not a comic archive")
+	}
+
+	a := &Archive{path: filePath}
+	var names []string
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK")):
+		a.zip, err = zip.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range a.zip.File {
+			names = append(names, f.Name)
+		}
+	case bytes.Equal(magic, []byte("Rar!")):
+		names, err = rarEntries(filePath)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("not a comic archive")
+	}
+
+	for _, name := range names {
+		base := path.Base(name)
+		if strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if strings.EqualFold(base, "ComicInfo.xml") {
+			if data, err := a.read(name); err == nil {
+				var info ComicInfo
+				if xml.Unmarshal(data, &info) == nil {
+					a.Info = &info
+				}
+			}
+			continue
+		}
+		if imageExtensions[strings.ToLower(path.Ext(name))] {
+			a.Pages = append(a.Pages, name)
+		}
+	}
+	sort.Slice(a.Pages, func(i, j int) bool { return NaturalLess(a.Pages[i], a.Pages[j]) })
+	if len(a.Pages) == 0 {
+		a.Close()
+		return nil, fmt.Errorf("no pages in archive")
+	}
+	return a, nil
+}
+
+// Close closes the archive
+func (a *Archive) Close() error {
+	if a.zip != nil {
+		return a.zip.Close()
+	}
+	return nil
+}
+
+// Page returns page n (from 0) and its content type
+func (a *Archive) Page(n int) ([]byte, string, error) {
+	if n < 0 || n >= len(a.Pages) {
+		return nil, "", fmt.Errorf("page %d out of range", n+1)
+	}
+	data, err := a.read(a.Pages[n])
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType(a.Pages[n]), nil
+}
+
+// read reads an entry of the archive
+func (a *Archive) read(name string) ([]byte, error) {
+	if a.zip == nil {
+		return rarRead(a.path, name)
+	}
+	f, err := a.zip.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxPageSize))
+}
+
+func contentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".bmp":
+		return "image/bmp"
+	}
+	return "image/jpeg"
+}
+
+// Extension returns the file extension for a page's content type
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	}
+	return ".jpg"
+}
+
+// RAR archives
+
+// rarTimeout bounds listing or extracting one entry of a RAR archive
+const rarTimeout = 30 * time.Second
+
+var (
+	rarListMu sync.Mutex
+	rarLists  = make(map[string][]string) // Entries by path and modification time
+)
+
+// rarEntries lists a RAR archive's entries. Listings are cached as every
+// page request needs one.
+func rarEntries(filePath string) ([]string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	key := filePath + ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+
+	rarListMu.Lock()
+	entries, ok := rarLists[key]
+	rarListMu.Unlock()
+	if ok {
+		return entries, nil
+	}
+
+	var out []byte
+	if _, err := exec.LookPath("unrar"); err == nil {
+		out, err = runRar("unrar", "lb", "--", filePath)
+		if err != nil {
+			return nil, err
+		}
+	} else if _, err := exec.LookPath("bsdtar"); err == nil {
+		out, err = runRar("bsdtar", "-tf", filePath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("reading CBR files needs unrar or bsdtar")
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" && !strings.HasSuffix(line, "/") {
+			entries = append(entries, strings.ReplaceAll(line, "\\", "/"))
+		}
+	}
+
+	rarListMu.Lock()
+	// A library's worth of listings is small; start over rather than track age
+	if len(rarLists) >= 256 {
+		rarLists = make(map[string][]string)
+	}
+	rarLists[key] = entries
+	rarListMu.Unlock()
+	return entries, nil
+}
+
+// rarRead extracts one entry of a RAR archive
+func rarRead(filePath, name string) ([]byte, error) {
+	if _, err := exec.LookPath("unrar"); err == nil {
+		return runRar("unrar", "p", "-inul", "--", filePath, name)
+	}
+	// bsdtar takes entry names as patterns, so wildcards in them are escaped
+	return runRar("bsdtar", "-xOf", filePath, "--", globEscaper.Replace(name))
+}
+
+// globEscaper escapes the wildcards of bsdtar's patterns
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+func runRar(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rarTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(out) > maxPageSize {
+		return nil, fmt.Errorf("entry too large")
+	}
+	return out, nil
+}
+
+// NaturalLess orders names with numbers by value, so "2.jpg" comes before
+// "10.jpg"
+func NaturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			// Without leading zeros the longer number is the larger
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,42 @@
+package comic
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// resizeQuality is the JPEG quality of resized pages; text in speech bubbles
+// blurs noticeably much below it
+const resizeQuality = 85
+
+// Resize scales a page down to a width, keeping its aspect ratio, and
+// encodes it as JPEG. Pages already no wider are returned as they are.
+func Resize(data []byte, contentType string, width int) ([]byte, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	bounds := src.Bounds()
+	if width <= 0 || bounds.Dx() <= width {
+		return data, contentType, nil
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: resizeQuality}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
@@ -8,6 +8,7 @@ type Config struct {
 	Port      string
 	StaticDir string
 	DBPath    string
+	LogFormat string // text or json
 }
 
 func Load() *Config {
@@ -26,9 +27,15 @@ func Load() *Config {
 		dbPath = "./data/outpost.db"
 	}
 
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = "text"
+	}
+
 	return &Config{
 		Port:      port,
 		StaticDir: staticDir,
 		DBPath:    dbPath,
+		LogFormat: logFormat,
 	}
 }
@@ -0,0 +1,98 @@
+package database
+
+import "time"
+
+// Activity feed operations
+//
+// The household activity feed is what everyone's been watching and what's
+// come into the library: watches by profiles that share their activity, and
+// movies and shows added. Which entries a viewer may see is up to the caller.
+
+// Kinds of activity feed entry
+const (
+	ActivityPlayed = "played"
+	ActivityAdded  = "added"
+)
+
+// ActivityFeedEntry is a watch or an addition in the activity feed
+type ActivityFeedEntry struct {
+	Kind          string    `json:"kind"` // played, added
+	At            time.Time `json:"at"`
+	MediaType     string    `json:"mediaType"` // movie, episode, show
+	MediaID       int64     `json:"mediaId"`
+	Title         string    `json:"title"` // Movie or show title
+	ShowID        *int64    `json:"showId,omitempty"`
+	SeasonNumber  *int      `json:"seasonNumber,omitempty"`
+	EpisodeNumber *int      `json:"episodeNumber,omitempty"`
+	EpisodeTitle  *string   `json:"episodeTitle,omitempty"`
+	PosterPath    *string   `json:"posterPath,omitempty"`
+
+	// Who watched, for watches
+	ProfileID   *int64  `json:"profileId,omitempty"`
+	ProfileName *string `json:"profileName,omitempty"`
+	AvatarURL   *string `json:"avatarUrl,omitempty"`
+
+	// For filtering by who may see the entry
+	LibraryID     int64   `json:"-"`
+	ContentRating *string `json:"-"`
+}
+
+// GetActivityFeed returns the watches shared by profiles and the movies and
+// shows added since a time, latest first
+func (d *Database) GetActivityFeed(since time.Time, limit int) ([]ActivityFeedEntry, error) {
+	rows, err := d.db.Query(`
+		SELECT kind, at, media_type, media_id, title, show_id, season_number, episode_number, episode_title,
+		       poster_path, profile_id, profile_name, avatar_url, library_id, content_rating
+		FROM (
+			SELECT 'played' AS kind, datetime(w.watched_at) AS at, 'movie' AS media_type, m.id AS media_id,
+			       m.title AS title, NULL AS show_id, NULL AS season_number, NULL AS episode_number,
+			       NULL AS episode_title, m.poster_path AS poster_path, p.id AS profile_id, p.name AS profile_name,
+			       p.avatar_url AS avatar_url, m.library_id AS library_id, m.content_rating AS content_rating
+			FROM watch_history w
+			JOIN profiles p ON w.profile_id = p.id
+			JOIN movies m ON w.media_id = m.id
+			WHERE w.media_type = 'movie' AND p.share_activity = 1
+
+			UNION ALL
+			SELECT 'played', datetime(w.watched_at), 'episode', e.id, s.title, s.id, sea.season_number,
+			       e.episode_number, e.title, s.poster_path, p.id, p.name, p.avatar_url, s.library_id, s.content_rating
+			FROM watch_history w
+			JOIN profiles p ON w.profile_id = p.id
+			JOIN episodes e ON w.media_id = e.id
+			JOIN seasons sea ON e.season_id = sea.id
+			JOIN shows s ON sea.show_id = s.id
+			WHERE w.media_type = 'episode' AND p.share_activity = 1
+
+			UNION ALL
+			SELECT 'added', datetime(m.added_at), 'movie', m.id, m.title, NULL, NULL, NULL, NULL, m.poster_path,
+			       NULL, NULL, NULL, m.library_id, m.content_rating
+			FROM movies m
+			WHERE m.missing_since IS NULL
+
+			UNION ALL
+			SELECT 'added', datetime(s.added_at), 'show', s.id, s.title, NULL, NULL, NULL, NULL, s.poster_path,
+			       NULL, NULL, NULL, s.library_id, s.content_rating
+			FROM shows s
+		)
+		WHERE at >= ?
+		ORDER BY at DESC
+		LIMIT ?`, since.UTC().Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ActivityFeedEntry
+	for rows.Next() {
+		var e ActivityFeedEntry
+		var at string
+		if err := rows.Scan(&e.Kind, &at, &e.MediaType, &e.MediaID, &e.Title, &e.ShowID, &e.SeasonNumber,
+			&e.EpisodeNumber, &e.EpisodeTitle, &e.PosterPath, &e.ProfileID, &e.ProfileName, &e.AvatarURL,
+			&e.LibraryID, &e.ContentRating); err != nil {
+			return nil, err
+		}
+		e.At, _ = time.Parse("2006-01-02 15:04:05", at)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
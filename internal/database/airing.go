@@ -0,0 +1,245 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Airing show operations
+
+// AiringShow is a show followed week by week while it airs
+type AiringShow struct {
+	ShowID     int64     `json:"showId"`
+	Title      string    `json:"title"`                // Populated from join
+	TmdbID     *int64    `json:"tmdbId,omitempty"`     // Populated from join
+	PosterPath *string   `json:"posterPath,omitempty"` // Populated from join
+	AirTime    string    `json:"airTime"`              // HH:MM in the server's time zone
+	CreatedBy  *int64    `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Followers  int       `json:"followers"`
+}
+
+// AiringSearch is the search for one new episode of an airing show
+type AiringSearch struct {
+	ShowID         int64      `json:"showId"`
+	SeasonNumber   int        `json:"seasonNumber"`
+	EpisodeNumber  int        `json:"episodeNumber"`
+	AirAt          time.Time  `json:"airAt"`
+	Attempts       int        `json:"attempts"`
+	NextSearchAt   time.Time  `json:"nextSearchAt"`
+	LastSearchedAt *time.Time `json:"lastSearchedAt,omitempty"`
+	GrabbedRelease *string    `json:"grabbedRelease,omitempty"`
+	AvailableAt    *time.Time `json:"availableAt,omitempty"`
+}
+
+const airingShowColumns = `
+	SELECT a.show_id, s.title, s.tmdb_id, s.poster_path, a.air_time, a.created_by, a.created_at,
+	       (SELECT COUNT(*) FROM airing_followers f JOIN users u ON f.user_id = u.id WHERE f.show_id = a.show_id)
+	FROM airing_shows a
+	JOIN shows s ON a.show_id = s.id`
+
+func scanAiringShow(row interface{ Scan(...interface{}) error }) (*AiringShow, error) {
+	var a AiringShow
+	if err := row.Scan(&a.ShowID, &a.Title, &a.TmdbID, &a.PosterPath, &a.AirTime, &a.CreatedBy, &a.CreatedAt, &a.Followers); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAiringShows returns the shows followed while they air, by title
+func (d *Database) GetAiringShows() ([]AiringShow, error) {
+	rows, err := d.db.Query(airingShowColumns + ` ORDER BY s.title COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []AiringShow
+	for rows.Next() {
+		a, err := scanAiringShow(rows)
+		if err != nil {
+			return nil, err
+		}
+		shows = append(shows, *a)
+	}
+	return shows, rows.Err()
+}
+
+// GetAiringShow returns a followed airing show, or nil if the show isn't
+// followed
+func (d *Database) GetAiringShow(showID int64) (*AiringShow, error) {
+	a, err := scanAiringShow(d.db.QueryRow(airingShowColumns+` WHERE a.show_id = ?`, showID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// SetAiringShow follows a show while it airs, or changes its air time
+func (d *Database) SetAiringShow(showID int64, airTime string, createdBy *int64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO airing_shows (show_id, air_time, created_by) VALUES (?, ?, ?)
+		ON CONFLICT(show_id) DO UPDATE SET air_time = excluded.air_time`,
+		showID, airTime, createdBy)
+	return err
+}
+
+// DeleteAiringShow stops following a show, along with its followers and
+// searches
+func (d *Database) DeleteAiringShow(showID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM airing_searches WHERE show_id = ?`,
+		`DELETE FROM airing_followers WHERE show_id = ?`,
+		`DELETE FROM airing_shows WHERE show_id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, showID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddAiringFollower has a user told when new episodes of a show come in
+func (d *Database) AddAiringFollower(showID, userID int64) error {
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO airing_followers (show_id, user_id) VALUES (?, ?)`, showID, userID)
+	return err
+}
+
+// RemoveAiringFollower stops telling a user about a show's new episodes
+func (d *Database) RemoveAiringFollower(showID, userID int64) error {
+	_, err := d.db.Exec(`DELETE FROM airing_followers WHERE show_id = ? AND user_id = ?`, showID, userID)
+	return err
+}
+
+// GetAiringFollowers returns the IDs of the users following a show
+func (d *Database) GetAiringFollowers(showID int64) ([]int64, error) {
+	rows, err := d.db.Query(`
+		SELECT f.user_id FROM airing_followers f
+		JOIN users u ON f.user_id = u.id
+		WHERE f.show_id = ?`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetFollowedAiringShowIDs returns the shows a user follows
+func (d *Database) GetFollowedAiringShowIDs(userID int64) (map[int64]bool, error) {
+	rows, err := d.db.Query(`SELECT show_id FROM airing_followers WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// AddAiringSearch schedules the search for a new episode, unless it's
+// already scheduled
+func (d *Database) AddAiringSearch(search *AiringSearch) (bool, error) {
+	result, err := d.db.Exec(`
+		INSERT OR IGNORE INTO airing_searches (show_id, season_number, episode_number, air_at, next_search_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		search.ShowID, search.SeasonNumber, search.EpisodeNumber, search.AirAt.UTC().Truncate(time.Second), search.NextSearchAt.UTC().Truncate(time.Second))
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+const airingSearchColumns = `
+	SELECT show_id, season_number, episode_number, air_at, attempts, next_search_at,
+	       last_searched_at, grabbed_release, available_at
+	FROM airing_searches`
+
+func scanAiringSearches(rows *sql.Rows) ([]AiringSearch, error) {
+	defer rows.Close()
+
+	var searches []AiringSearch
+	for rows.Next() {
+		var a AiringSearch
+		if err := rows.Scan(&a.ShowID, &a.SeasonNumber, &a.EpisodeNumber, &a.AirAt, &a.Attempts, &a.NextSearchAt,
+			&a.LastSearchedAt, &a.GrabbedRelease, &a.AvailableAt); err != nil {
+			return nil, err
+		}
+		searches = append(searches, a)
+	}
+	return searches, rows.Err()
+}
+
+// GetPendingAiringSearches returns the episodes that aired since the given
+// time and aren't in the library yet, the next due first
+func (d *Database) GetPendingAiringSearches(airedSince time.Time) ([]AiringSearch, error) {
+	rows, err := d.db.Query(airingSearchColumns+`
+		WHERE available_at IS NULL AND air_at >= ?
+		ORDER BY next_search_at`, airedSince.UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return scanAiringSearches(rows)
+}
+
+// GetAiringSearchesByShow returns a show's episode searches, latest first
+func (d *Database) GetAiringSearchesByShow(showID int64, limit int) ([]AiringSearch, error) {
+	rows, err := d.db.Query(airingSearchColumns+`
+		WHERE show_id = ?
+		ORDER BY air_at DESC LIMIT ?`, showID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanAiringSearches(rows)
+}
+
+// RecordAiringSearch stores the outcome of searching for an episode: the
+// release grabbed, or when to try again
+func (d *Database) RecordAiringSearch(search *AiringSearch) error {
+	_, err := d.db.Exec(`
+		UPDATE airing_searches
+		SET attempts = ?, next_search_at = ?, last_searched_at = ?, grabbed_release = ?
+		WHERE show_id = ? AND season_number = ? AND episode_number = ?`,
+		search.Attempts, search.NextSearchAt.UTC().Truncate(time.Second), search.LastSearchedAt, search.GrabbedRelease,
+		search.ShowID, search.SeasonNumber, search.EpisodeNumber)
+	return err
+}
+
+// MarkAiringSearchAvailable records that a searched-for episode is in the
+// library
+func (d *Database) MarkAiringSearchAvailable(showID int64, season, episode int) error {
+	_, err := d.db.Exec(`
+		UPDATE airing_searches SET available_at = CURRENT_TIMESTAMP
+		WHERE show_id = ? AND season_number = ? AND episode_number = ?`,
+		showID, season, episode)
+	return err
+}
+
+// DeleteAiringSearchesBefore removes searches for episodes that aired
+// before the given time
+func (d *Database) DeleteAiringSearchesBefore(airedBefore time.Time) error {
+	_, err := d.db.Exec(`DELETE FROM airing_searches WHERE air_at < ?`, airedBefore.UTC().Truncate(time.Second))
+	return err
+}
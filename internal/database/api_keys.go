@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// API key operations
+//
+// API keys let scripts and other services call the API as a user without
+// signing in. Only a hash of each key is stored; the key itself is shown
+// once, when it's created. Revoked keys are kept so their usage stays on
+// record.
+
+// API key scopes, from least to most access
+const (
+	APIKeyScopeRead    = "read"    // GET requests only
+	APIKeyScopeRequest = "request" // Reading, plus making media requests
+	APIKeyScopeFull    = "full"    // Anything the key's user can do
+)
+
+// APIKey is a key for machine-to-machine access, acting as its user
+type APIKey struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	KeyHash      string     `json:"-"`
+	KeyPrefix    string     `json:"keyPrefix"` // Start of the key, to tell keys apart
+	Scope        string     `json:"scope"`
+	UserID       int64      `json:"userId"`
+	Username     string     `json:"username,omitempty"`
+	CreatedBy    *int64     `json:"createdBy,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP   *string    `json:"lastUsedIp,omitempty"`
+	RequestCount int64      `json:"requestCount"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ValidAPIKeyScope reports whether a scope is one of the APIKeyScope values
+func ValidAPIKeyScope(scope string) bool {
+	return scope == APIKeyScopeRead || scope == APIKeyScopeRequest || scope == APIKeyScopeFull
+}
+
+const apiKeyColumns = `k.id, k.name, k.key_hash, k.key_prefix, k.scope, k.user_id, COALESCE(u.username, ''),
+	k.created_by, k.created_at, k.last_used_at, k.last_used_ip, k.request_count, k.revoked_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*APIKey, error) {
+	var k APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.KeyHash, &k.KeyPrefix, &k.Scope, &k.UserID, &k.Username,
+		&k.CreatedBy, &k.CreatedAt, &k.LastUsedAt, &k.LastUsedIP, &k.RequestCount, &k.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CreateAPIKey stores a new API key
+func (d *Database) CreateAPIKey(key *APIKey) error {
+	result, err := d.db.Exec(`
+		INSERT INTO api_keys (name, key_hash, key_prefix, scope, user_id, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		key.Name, key.KeyHash, key.KeyPrefix, key.Scope, key.UserID, key.CreatedBy,
+	)
+	if err != nil {
+		return err
+	}
+	key.ID, _ = result.LastInsertId()
+	key.CreatedAt = time.Now()
+	return nil
+}
+
+// GetAPIKey returns an API key, or nil if there's none with the ID
+func (d *Database) GetAPIKey(id int64) (*APIKey, error) {
+	key, err := scanAPIKey(d.db.QueryRow(`
+		SELECT `+apiKeyColumns+` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id
+		WHERE k.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// GetAPIKeyByHash returns the unrevoked API key with a hash, or nil if
+// there's none
+func (d *Database) GetAPIKeyByHash(keyHash string) (*APIKey, error) {
+	key, err := scanAPIKey(d.db.QueryRow(`
+		SELECT `+apiKeyColumns+` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = ? AND k.revoked_at IS NULL`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// GetAPIKeys returns every API key, revoked ones too when includeRevoked is
+// set, newest first
+func (d *Database) GetAPIKeys(includeRevoked bool) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys k LEFT JOIN users u ON u.id = k.user_id`
+	if !includeRevoked {
+		query += ` WHERE k.revoked_at IS NULL`
+	}
+	rows, err := d.db.Query(query + ` ORDER BY k.created_at DESC, k.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RecordAPIKeyUse counts a request made with an API key
+func (d *Database) RecordAPIKeyUse(id int64, ip string) error {
+	_, err := d.db.Exec(`
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = ?, request_count = request_count + 1
+		WHERE id = ?`, ip, id)
+	return err
+}
+
+// RevokeAPIKey stops an API key working. Returns false if it was already
+// revoked.
+func (d *Database) RevokeAPIKey(id int64) (bool, error) {
+	result, err := d.db.Exec(`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
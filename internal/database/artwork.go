@@ -0,0 +1,124 @@
+package database
+
+import "database/sql"
+
+// Library artwork operations
+//
+// Each library picks the artwork its grids show. Movies and shows keep the
+// picked image in artwork_path next to their regular poster and backdrop.
+// Backdrops also carry a focal point, so clients cropping them for hero
+// images keep faces in frame, and posters and backdrops carry their dominant
+// and accent colors for placeholders and themed pages.
+
+// Artwork styles
+const (
+	ArtworkPoster    = "poster"
+	ArtworkLandscape = "landscape"
+)
+
+// Artwork text preferences
+const (
+	ArtworkTextAny  = "any"      // Whatever TMDB ranks best
+	ArtworkTextWith = "text"     // Carries the title, e.g. backdrops with a logo
+	ArtworkTextless = "textless" // No title or other text
+)
+
+// ValidArtworkStyle reports whether style is a known artwork style
+func ValidArtworkStyle(style string) bool {
+	return style == ArtworkPoster || style == ArtworkLandscape
+}
+
+// ValidArtworkText reports whether text is a known artwork text preference
+func ValidArtworkText(text string) bool {
+	return text == ArtworkTextAny || text == ArtworkTextWith || text == ArtworkTextless
+}
+
+// defaultArtwork fills in the artwork settings of a library that has none
+func (lib *Library) defaultArtwork() {
+	if lib.ArtworkStyle == "" {
+		lib.ArtworkStyle = ArtworkPoster
+	}
+	if lib.ArtworkText == "" {
+		lib.ArtworkText = ArtworkTextAny
+	}
+}
+
+// UpdateMovieArtwork sets the grid artwork of a movie
+func (d *Database) UpdateMovieArtwork(id int64, path *string) error {
+	_, err := d.db.Exec(`UPDATE movies SET artwork_path = ? WHERE id = ?`, path, id)
+	return err
+}
+
+// UpdateShowArtwork sets the grid artwork of a show
+func (d *Database) UpdateShowArtwork(id int64, path *string) error {
+	_, err := d.db.Exec(`UPDATE shows SET artwork_path = ? WHERE id = ?`, path, id)
+	return err
+}
+
+// UpdateMovieFocalPoint sets the focal point of a movie's backdrop
+func (d *Database) UpdateMovieFocalPoint(id int64, x, y *float64) error {
+	_, err := d.db.Exec(`UPDATE movies SET focal_x = ?, focal_y = ? WHERE id = ?`, x, y, id)
+	return err
+}
+
+// UpdateShowFocalPoint sets the focal point of a show's backdrop
+func (d *Database) UpdateShowFocalPoint(id int64, x, y *float64) error {
+	_, err := d.db.Exec(`UPDATE shows SET focal_x = ?, focal_y = ? WHERE id = ?`, x, y, id)
+	return err
+}
+
+// GetImageFocalPoint returns the stored focal point of an image; ok is false
+// when it hasn't been analyzed
+func (d *Database) GetImageFocalPoint(image string) (x, y float64, ok bool, err error) {
+	err = d.db.QueryRow(`SELECT focal_x, focal_y FROM image_focal_points WHERE image = ?`, image).Scan(&x, &y)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return x, y, true, nil
+}
+
+// SetImageFocalPoint stores the focal point of an image
+func (d *Database) SetImageFocalPoint(image string, x, y float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO image_focal_points (image, focal_x, focal_y) VALUES (?, ?, ?)
+		ON CONFLICT(image) DO UPDATE SET focal_x = excluded.focal_x, focal_y = excluded.focal_y,
+			analyzed_at = CURRENT_TIMESTAMP`, image, x, y)
+	return err
+}
+
+// UpdateMovieColors sets the artwork colors of a movie
+func (d *Database) UpdateMovieColors(id int64, colors *string) error {
+	_, err := d.db.Exec(`UPDATE movies SET colors = ? WHERE id = ?`, colors, id)
+	return err
+}
+
+// UpdateShowColors sets the artwork colors of a show
+func (d *Database) UpdateShowColors(id int64, colors *string) error {
+	_, err := d.db.Exec(`UPDATE shows SET colors = ? WHERE id = ?`, colors, id)
+	return err
+}
+
+// GetImageColors returns the stored colors of an image as #rrggbb; ok is
+// false when it hasn't been analyzed
+func (d *Database) GetImageColors(image string) (dominant, accent string, ok bool, err error) {
+	err = d.db.QueryRow(`SELECT dominant, accent FROM image_colors WHERE image = ?`, image).Scan(&dominant, &accent)
+	if err == sql.ErrNoRows {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return dominant, accent, true, nil
+}
+
+// SetImageColors stores the colors of an image
+func (d *Database) SetImageColors(image, dominant, accent string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO image_colors (image, dominant, accent) VALUES (?, ?, ?)
+		ON CONFLICT(image) DO UPDATE SET dominant = excluded.dominant, accent = excluded.accent,
+			analyzed_at = CURRENT_TIMESTAMP`, image, dominant, accent)
+	return err
+}
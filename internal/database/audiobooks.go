@@ -0,0 +1,305 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Audiobook operations
+//
+// An audiobook is an M4B file, or a folder of audio files played one after
+// another, in a book library. Its files are laid end to end, so a position
+// in the book is seconds from the start of the first file, and its chapters
+// are stored in chapters as audiobook with times on that same timeline.
+// Each profile has its own position and speed per audiobook.
+
+// Audiobook is an audiobook in a book library
+type Audiobook struct {
+	ID          int64     `json:"id"`
+	LibraryID   int64     `json:"libraryId"`
+	Title       string    `json:"title"`
+	Author      *string   `json:"author,omitempty"`
+	Narrator    *string   `json:"narrator,omitempty"`
+	Year        int       `json:"year,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Path        string    `json:"path"`     // The M4B file, or the folder of files
+	Duration    float64   `json:"duration"` // seconds
+	Size        int64     `json:"size"`
+	FileCount   int       `json:"fileCount"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+// AudiobookFile is one of an audiobook's files
+type AudiobookFile struct {
+	ID          int64   `json:"id"`
+	AudiobookID int64   `json:"audiobookId"`
+	Index       int     `json:"index"`
+	Path        string  `json:"path"`
+	StartTime   float64 `json:"startTime"` // seconds into the book it starts at
+	Duration    float64 `json:"duration"`  // seconds
+	Size        int64   `json:"size"`
+}
+
+// AudiobookProgress is where a profile is in an audiobook
+type AudiobookProgress struct {
+	AudiobookID int64     `json:"audiobookId"`
+	Position    float64   `json:"position"` // seconds from the start of the book
+	Speed       float64   `json:"speed"`
+	Finished    bool      `json:"finished"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// AudiobookInProgress is an audiobook a profile is partway through
+type AudiobookInProgress struct {
+	Audiobook
+	Progress AudiobookProgress `json:"progress"`
+}
+
+const audiobookColumns = `a.id, a.library_id, a.title, a.author, a.narrator, COALESCE(a.year, 0), a.description,
+	a.path, a.duration, a.size, (SELECT COUNT(*) FROM audiobook_files f WHERE f.audiobook_id = a.id), a.added_at`
+
+// audiobookDest returns where a row of audiobookColumns is scanned
+func audiobookDest(a *Audiobook) []interface{} {
+	return []interface{}{&a.ID, &a.LibraryID, &a.Title, &a.Author, &a.Narrator, &a.Year, &a.Description,
+		&a.Path, &a.Duration, &a.Size, &a.FileCount, &a.AddedAt}
+}
+
+func (d *Database) queryAudiobooks(query string, args ...interface{}) ([]Audiobook, error) {
+	rows, err := d.db.Query(`SELECT `+audiobookColumns+` FROM audiobooks a `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []Audiobook
+	for rows.Next() {
+		var a Audiobook
+		if err := rows.Scan(audiobookDest(&a)...); err != nil {
+			return nil, err
+		}
+		books = append(books, a)
+	}
+	return books, rows.Err()
+}
+
+// GetAudiobooks returns every audiobook by title
+func (d *Database) GetAudiobooks() ([]Audiobook, error) {
+	return d.queryAudiobooks(`ORDER BY a.title COLLATE NOCASE`)
+}
+
+// GetAudiobooksByLibrary returns a library's audiobooks
+func (d *Database) GetAudiobooksByLibrary(libraryID int64) ([]Audiobook, error) {
+	return d.queryAudiobooks(`WHERE a.library_id = ? ORDER BY a.title COLLATE NOCASE`, libraryID)
+}
+
+func (d *Database) GetAudiobook(id int64) (*Audiobook, error) {
+	var a Audiobook
+	err := d.db.QueryRow(`SELECT `+audiobookColumns+` FROM audiobooks a WHERE a.id = ?`, id).Scan(audiobookDest(&a)...)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (d *Database) GetAudiobookByPath(path string) (*Audiobook, error) {
+	var a Audiobook
+	err := d.db.QueryRow(`SELECT `+audiobookColumns+` FROM audiobooks a WHERE a.path = ?`, path).Scan(audiobookDest(&a)...)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SaveAudiobook adds an audiobook, or updates the one at its path, with its
+// files and chapters, replacing the ones it had
+func (d *Database) SaveAudiobook(book *Audiobook, files []AudiobookFile, chapters []Chapter) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	book.Duration, book.Size = 0, 0
+	for _, f := range files {
+		book.Duration += f.Duration
+		book.Size += f.Size
+	}
+	_, err = tx.Exec(`
+		INSERT INTO audiobooks (library_id, title, author, narrator, year, description, path, duration, size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			library_id = excluded.library_id, title = excluded.title, author = excluded.author,
+			narrator = excluded.narrator, year = excluded.year, description = excluded.description,
+			duration = excluded.duration, size = excluded.size`,
+		book.LibraryID, book.Title, book.Author, book.Narrator, book.Year, book.Description,
+		book.Path, book.Duration, book.Size)
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`SELECT id, added_at FROM audiobooks WHERE path = ?`, book.Path).Scan(&book.ID, &book.AddedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM audiobook_files WHERE audiobook_id = ?`, book.ID); err != nil {
+		return err
+	}
+	for i := range files {
+		f := &files[i]
+		f.AudiobookID = book.ID
+		f.Index = i
+		result, err := tx.Exec(`
+			INSERT INTO audiobook_files (audiobook_id, file_index, path, start_time, duration, size)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			f.AudiobookID, f.Index, f.Path, f.StartTime, f.Duration, f.Size)
+		if err != nil {
+			return err
+		}
+		f.ID, _ = result.LastInsertId()
+	}
+	book.FileCount = len(files)
+
+	if _, err := tx.Exec(`DELETE FROM chapters WHERE media_type = 'audiobook' AND media_id = ?`, book.ID); err != nil {
+		return err
+	}
+	for i, c := range chapters {
+		_, err := tx.Exec(
+			`INSERT INTO chapters (media_type, media_id, chapter_index, title, start_time, end_time) VALUES ('audiobook', ?, ?, ?, ?, ?)`,
+			book.ID, i, c.Title, c.StartTime, c.EndTime)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteAudiobook removes an audiobook with its chapters and everyone's
+// progress in it. Its files are left alone.
+func (d *Database) DeleteAudiobook(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chapters WHERE media_type = 'audiobook' AND media_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM audiobooks WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const audiobookFileColumns = `id, audiobook_id, file_index, path, start_time, duration, size`
+
+func scanAudiobookFile(row interface{ Scan(...interface{}) error }) (*AudiobookFile, error) {
+	var f AudiobookFile
+	if err := row.Scan(&f.ID, &f.AudiobookID, &f.Index, &f.Path, &f.StartTime, &f.Duration, &f.Size); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetAudiobookFiles returns an audiobook's files in the order they play
+func (d *Database) GetAudiobookFiles(audiobookID int64) ([]AudiobookFile, error) {
+	rows, err := d.db.Query(`SELECT `+audiobookFileColumns+` FROM audiobook_files
+		WHERE audiobook_id = ? ORDER BY file_index`, audiobookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudiobookFile
+	for rows.Next() {
+		f, err := scanAudiobookFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+	return files, rows.Err()
+}
+
+func (d *Database) GetAudiobookFile(id int64) (*AudiobookFile, error) {
+	return scanAudiobookFile(d.db.QueryRow(`SELECT `+audiobookFileColumns+` FROM audiobook_files WHERE id = ?`, id))
+}
+
+// GetAudiobookProgress returns where a profile is in an audiobook, or nil if
+// it hasn't listened to it
+func (d *Database) GetAudiobookProgress(profileID, audiobookID int64) (*AudiobookProgress, error) {
+	var p AudiobookProgress
+	err := d.db.QueryRow(`
+		SELECT audiobook_id, position, speed, finished, updated_at FROM audiobook_progress
+		WHERE profile_id = ? AND audiobook_id = ?`, profileID, audiobookID,
+	).Scan(&p.AudiobookID, &p.Position, &p.Speed, &p.Finished, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveAudiobookProgress records where a profile is in an audiobook and the
+// speed it listens at
+func (d *Database) SaveAudiobookProgress(profileID int64, p *AudiobookProgress) error {
+	p.UpdatedAt = time.Now()
+	_, err := d.db.Exec(`
+		INSERT INTO audiobook_progress (profile_id, audiobook_id, position, speed, finished, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(profile_id, audiobook_id) DO UPDATE SET
+			position = excluded.position, speed = excluded.speed,
+			finished = excluded.finished, updated_at = excluded.updated_at`,
+		profileID, p.AudiobookID, p.Position, p.Speed, p.Finished, p.UpdatedAt)
+	return err
+}
+
+// DeleteAudiobookProgress forgets where a profile is in an audiobook
+func (d *Database) DeleteAudiobookProgress(profileID, audiobookID int64) error {
+	_, err := d.db.Exec(`DELETE FROM audiobook_progress WHERE profile_id = ? AND audiobook_id = ?`, profileID, audiobookID)
+	return err
+}
+
+// GetAudiobookSpeed returns the speed a profile last listened to an
+// audiobook at, which books it starts take on, or 0 if it hasn't listened
+// to any
+func (d *Database) GetAudiobookSpeed(profileID int64) (float64, error) {
+	var speed float64
+	err := d.db.QueryRow(`
+		SELECT speed FROM audiobook_progress WHERE profile_id = ?
+		ORDER BY updated_at DESC LIMIT 1`, profileID,
+	).Scan(&speed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return speed, err
+}
+
+// GetAudiobooksInProgress returns the audiobooks a profile started and
+// hasn't finished, most recently listened to first
+func (d *Database) GetAudiobooksInProgress(profileID int64, limit int) ([]AudiobookInProgress, error) {
+	rows, err := d.db.Query(`SELECT `+audiobookColumns+`, p.position, p.speed, p.finished, p.updated_at
+		FROM audiobook_progress p
+		JOIN audiobooks a ON a.id = p.audiobook_id
+		WHERE p.profile_id = ? AND p.finished = 0 AND p.position > 0
+		ORDER BY p.updated_at DESC
+		LIMIT ?`, profileID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []AudiobookInProgress
+	for rows.Next() {
+		var item AudiobookInProgress
+		dest := append(audiobookDest(&item.Audiobook), &item.Progress.Position, &item.Progress.Speed, &item.Progress.Finished, &item.Progress.UpdatedAt)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		item.Progress.AudiobookID = item.ID
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AuditEntry records a security-relevant or administrative action
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	UserID     *int64    `json:"userId,omitempty"`
+	Username   string    `json:"username"`
+	Action     string    `json:"action"` // e.g. impersonation.start, impersonation.end
+	TargetType *string   `json:"targetType,omitempty"`
+	TargetID   *int64    `json:"targetId,omitempty"`
+	Details    *string   `json:"details,omitempty"`
+	IPAddress  *string   `json:"ipAddress,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Audit log operations
+
+func (d *Database) CreateAuditEntry(entry *AuditEntry) error {
+	result, err := d.db.Exec(`
+		INSERT INTO audit_log (user_id, username, action, target_type, target_id, details, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.UserID, entry.Username, entry.Action, entry.TargetType, entry.TargetID, entry.Details, entry.IPAddress,
+	)
+	if err != nil {
+		return err
+	}
+	entry.ID, _ = result.LastInsertId()
+	entry.CreatedAt = time.Now()
+	return nil
+}
+
+// GetAuditLog returns the most recent audit entries, optionally filtered by action prefix
+func (d *Database) GetAuditLog(action string, limit int) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, user_id, username, action, target_type, target_id, details, ip_address, created_at
+		FROM audit_log`
+	var args []interface{}
+	if action != "" {
+		query += ` WHERE action LIKE ?`
+		args = append(args, action+"%")
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var userID, targetID sql.NullInt64
+		var targetType, details, ipAddress sql.NullString
+		if err := rows.Scan(&e.ID, &userID, &e.Username, &e.Action, &targetType, &targetID,
+			&details, &ipAddress, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			e.UserID = &userID.Int64
+		}
+		if targetType.Valid {
+			e.TargetType = &targetType.String
+		}
+		if targetID.Valid {
+			e.TargetID = &targetID.Int64
+		}
+		if details.Valid {
+			e.Details = &details.String
+		}
+		if ipAddress.Valid {
+			e.IPAddress = &ipAddress.String
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// CleanupAuditLog removes audit entries older than the given number of days
+func (d *Database) CleanupAuditLog(daysToKeep int) error {
+	_, err := d.db.Exec(`DELETE FROM audit_log WHERE created_at < datetime('now', '-' || ? || ' days')`, daysToKeep)
+	return err
+}
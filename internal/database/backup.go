@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -44,6 +45,7 @@ type BackupUser struct {
 	Role               string  `json:"role"`
 	ContentRatingLimit *string `json:"contentRatingLimit,omitempty"`
 	RequirePin         bool    `json:"requirePin"`
+	Email              *string `json:"email,omitempty"`
 }
 
 // BackupSkipSegment stores skip segments with their show association
@@ -60,6 +62,8 @@ type BackupSkipSegment struct {
 // RestoreResult contains the result of a restore operation
 type RestoreResult struct {
 	Success  bool              `json:"success"`
+	DryRun   bool              `json:"dryRun,omitempty"`  // Nothing was changed
+	Cleared  map[string]int    `json:"cleared,omitempty"` // Rows deleted before restoring, by table, in replace mode
 	Restored map[string]int    `json:"restored"`
 	Warnings []string          `json:"warnings"`
 	Errors   []string          `json:"errors,omitempty"`
@@ -199,7 +203,7 @@ func (d *Database) CreateBackup(appVersion string) (*Backup, error) {
 // Helper functions for backup export
 
 func (d *Database) getAllUsersForBackup() ([]BackupUser, error) {
-	rows, err := d.db.Query(`SELECT id, username, role, content_rating_limit, require_pin FROM users`)
+	rows, err := d.db.Query(`SELECT id, username, role, content_rating_limit, require_pin, email FROM users`)
 	if err != nil {
 		return nil, err
 	}
@@ -208,7 +212,7 @@ func (d *Database) getAllUsersForBackup() ([]BackupUser, error) {
 	var users []BackupUser
 	for rows.Next() {
 		var u BackupUser
-		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.ContentRatingLimit, &u.RequirePin); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.ContentRatingLimit, &u.RequirePin, &u.Email); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -301,7 +305,8 @@ func (d *Database) getQualityProfilesForBackup() ([]QualityProfile, error) {
 
 func (d *Database) getCollectionsForBackup() ([]Collection, error) {
 	rows, err := d.db.Query(`
-		SELECT id, name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto, sort_order, created_at, updated_at
+		SELECT id, name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto,
+		       COALESCE(is_smart, 0), rules, COALESCE(media_type, 'both'), sort_order, created_at, updated_at
 		FROM collections
 	`)
 	if err != nil {
@@ -312,7 +317,7 @@ func (d *Database) getCollectionsForBackup() ([]Collection, error) {
 	var collections []Collection
 	for rows.Next() {
 		var c Collection
-		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.TmdbCollectionID, &c.PosterPath, &c.BackdropPath, &c.IsAuto, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.TmdbCollectionID, &c.PosterPath, &c.BackdropPath, &c.IsAuto, &c.IsSmart, &c.Rules, &c.MediaType, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
 		collections = append(collections, c)
@@ -493,8 +498,19 @@ func ValidateBackup(data []byte) (*Backup, error) {
 // RestoreBackup restores settings and configuration from a backup
 // mode can be "replace" (clear existing data) or "merge" (keep existing, add new)
 func (d *Database) RestoreBackup(backup *Backup, mode string) (*RestoreResult, error) {
+	return d.restoreBackup(backup, mode, false)
+}
+
+// SimulateRestoreBackup runs a restore and rolls it back, returning what it
+// would have changed
+func (d *Database) SimulateRestoreBackup(backup *Backup, mode string) (*RestoreResult, error) {
+	return d.restoreBackup(backup, mode, true)
+}
+
+func (d *Database) restoreBackup(backup *Backup, mode string, dryRun bool) (*RestoreResult, error) {
 	result := &RestoreResult{
 		Success:  true,
+		DryRun:   dryRun,
 		Restored: make(map[string]int),
 		Warnings: []string{},
 	}
@@ -507,9 +523,11 @@ func (d *Database) RestoreBackup(backup *Backup, mode string) (*RestoreResult, e
 
 	// If replace mode, clear existing data
 	if mode == "replace" {
-		if err := d.clearDataForRestore(tx); err != nil {
+		cleared, err := d.clearDataForRestore(tx)
+		if err != nil {
 			return nil, fmt.Errorf("failed to clear existing data: %w", err)
 		}
+		result.Cleared = cleared
 	}
 
 	// Restore settings
@@ -650,8 +668,11 @@ func (d *Database) RestoreBackup(backup *Backup, mode string) (*RestoreResult, e
 		result.Restored["scheduledTasks"] = count
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	// A dry run is rolled back by the deferred Rollback
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
 	}
 
 	// Add warning about password reset
@@ -662,8 +683,9 @@ func (d *Database) RestoreBackup(backup *Backup, mode string) (*RestoreResult, e
 	return result, nil
 }
 
-// clearDataForRestore clears tables that will be restored
-func (d *Database) clearDataForRestore(tx *sql.Tx) error {
+// clearDataForRestore clears tables that will be restored, returning how
+// many rows it deleted from each
+func (d *Database) clearDataForRestore(tx *sql.Tx) (map[string]int, error) {
 	tables := []string{
 		"settings",
 		"download_clients",
@@ -682,17 +704,22 @@ func (d *Database) clearDataForRestore(tx *sql.Tx) error {
 		"trusted_groups",
 	}
 
+	cleared := make(map[string]int)
 	for _, table := range tables {
-		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table)); err != nil {
+		res, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", table))
+		if err != nil {
 			// Ignore errors for tables that might not exist
 			continue
 		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			cleared[table] = int(n)
+		}
 	}
 
 	// Don't clear users or libraries in replace mode - too dangerous
 	// Just update existing ones
 
-	return nil
+	return cleared, nil
 }
 
 // Restore helper functions
@@ -721,9 +748,9 @@ func (d *Database) restoreUsers(tx *sql.Tx, users []BackupUser, mode string) (in
 		if err == sql.ErrNoRows {
 			// Insert new user with placeholder password
 			_, err = tx.Exec(`
-				INSERT INTO users (username, password_hash, role, content_rating_limit, require_pin, created_at)
-				VALUES (?, '', ?, ?, ?, CURRENT_TIMESTAMP)
-			`, u.Username, u.Role, u.ContentRatingLimit, u.RequirePin)
+				INSERT INTO users (username, password_hash, role, content_rating_limit, require_pin, email, created_at)
+				VALUES (?, '', ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			`, u.Username, u.Role, u.ContentRatingLimit, u.RequirePin, u.Email)
 			if err != nil {
 				warnings = append(warnings, fmt.Sprintf("Failed to restore user %s: %v", u.Username, err))
 				continue
@@ -732,9 +759,9 @@ func (d *Database) restoreUsers(tx *sql.Tx, users []BackupUser, mode string) (in
 		} else if err == nil && mode == "replace" {
 			// Update existing user (but not password)
 			_, err = tx.Exec(`
-				UPDATE users SET role = ?, content_rating_limit = ?, require_pin = ?
+				UPDATE users SET role = ?, content_rating_limit = ?, require_pin = ?, email = ?
 				WHERE username = ?
-			`, u.Role, u.ContentRatingLimit, u.RequirePin, u.Username)
+			`, u.Role, u.ContentRatingLimit, u.RequirePin, u.Email, u.Username)
 			if err != nil {
 				warnings = append(warnings, fmt.Sprintf("Failed to update user %s: %v", u.Username, err))
 				continue
@@ -750,11 +777,12 @@ func (d *Database) restoreUsers(tx *sql.Tx, users []BackupUser, mode string) (in
 func (d *Database) restoreLibraries(tx *sql.Tx, libraries []Library, mode string) (int, error) {
 	count := 0
 	for _, lib := range libraries {
+		lib.defaultArtwork()
 		if mode == "replace" {
 			_, err := tx.Exec(`
-				INSERT OR REPLACE INTO libraries (name, path, type, scan_interval)
-				VALUES (?, ?, ?, ?)
-			`, lib.Name, lib.Path, lib.Type, lib.ScanInterval)
+				INSERT OR REPLACE INTO libraries (name, path, type, scan_interval, metadata_providers, artwork_style, artwork_text, scan_throttle)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, lib.Name, lib.Path, lib.Type, lib.ScanInterval, strings.Join(lib.MetadataProviders, ","), lib.ArtworkStyle, lib.ArtworkText, lib.ScanThrottle)
 			if err != nil {
 				return count, err
 			}
@@ -765,9 +793,9 @@ func (d *Database) restoreLibraries(tx *sql.Tx, libraries []Library, mode string
 			err := tx.QueryRow(`SELECT id FROM libraries WHERE path = ?`, lib.Path).Scan(&existingID)
 			if err == sql.ErrNoRows {
 				_, err = tx.Exec(`
-					INSERT INTO libraries (name, path, type, scan_interval)
-					VALUES (?, ?, ?, ?)
-				`, lib.Name, lib.Path, lib.Type, lib.ScanInterval)
+					INSERT INTO libraries (name, path, type, scan_interval, metadata_providers, artwork_style, artwork_text, scan_throttle)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				`, lib.Name, lib.Path, lib.Type, lib.ScanInterval, strings.Join(lib.MetadataProviders, ","), lib.ArtworkStyle, lib.ArtworkText, lib.ScanThrottle)
 				if err != nil {
 					return count, err
 				}
@@ -876,9 +904,9 @@ func (d *Database) restoreQualityPresets(tx *sql.Tx, presets []QualityPreset, mo
 		audioJSON, _ := json.Marshal(p.AudioFormats)
 
 		_, err := tx.Exec(`
-			INSERT OR REPLACE INTO quality_presets (name, media_type, is_default, is_built_in, enabled, priority, resolution, source, hdr_formats, codec, audio_formats, preferred_edition, min_seeders, prefer_season_packs, auto_upgrade, prefer_dual_audio, prefer_dubbed, preferred_language, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		`, p.Name, p.MediaType, p.IsDefault, p.IsBuiltIn, p.Enabled, p.Priority, p.Resolution, p.Source, string(hdrJSON), p.Codec, string(audioJSON), p.PreferredEdition, p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade, p.PreferDualAudio, p.PreferDubbed, p.PreferredLanguage)
+			INSERT OR REPLACE INTO quality_presets (name, media_type, is_default, is_built_in, enabled, priority, resolution, source, hdr_formats, codec, audio_formats, preferred_edition, min_seeders, prefer_season_packs, auto_upgrade, prefer_dual_audio, prefer_dubbed, preferred_language, min_size_per_hour, max_size_per_hour, min_bitrate_kbps, max_bitrate_kbps, max_age_days, first_seen_wait_hours, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		`, p.Name, p.MediaType, p.IsDefault, p.IsBuiltIn, p.Enabled, p.Priority, p.Resolution, p.Source, string(hdrJSON), p.Codec, string(audioJSON), p.PreferredEdition, p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade, p.PreferDualAudio, p.PreferDubbed, p.PreferredLanguage, p.MinSizePerHour, p.MaxSizePerHour, p.MinBitrate, p.MaxBitrate, p.MaxAgeDays, p.FirstSeenWaitHours)
 		if err != nil {
 			return count, err
 		}
@@ -906,9 +934,9 @@ func (d *Database) restoreCollections(tx *sql.Tx, collections []Collection, mode
 	count := 0
 	for _, c := range collections {
 		_, err := tx.Exec(`
-			INSERT OR REPLACE INTO collections (name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto, sort_order, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, c.Name, c.Description, c.TmdbCollectionID, c.PosterPath, c.BackdropPath, c.IsAuto, c.SortOrder, c.CreatedAt, c.UpdatedAt)
+			INSERT OR REPLACE INTO collections (name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto, is_smart, rules, media_type, sort_order, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, c.Name, c.Description, c.TmdbCollectionID, c.PosterPath, c.BackdropPath, c.IsAuto, c.IsSmart, c.Rules, c.MediaType, c.SortOrder, c.CreatedAt, c.UpdatedAt)
 		if err != nil {
 			return count, err
 		}
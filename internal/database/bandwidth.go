@@ -0,0 +1,85 @@
+package database
+
+import "time"
+
+// BandwidthSchedule applies alternative speed limits to download clients
+// during a recurring time window
+type BandwidthSchedule struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Enabled       bool      `json:"enabled"`
+	Days          string    `json:"days"`               // Comma-separated weekdays, 0 = Sunday (e.g. "1,2,3,4,5")
+	StartTime     string    `json:"startTime"`          // HH:MM, local time
+	EndTime       string    `json:"endTime"`            // HH:MM, may be earlier than StartTime to span midnight
+	DownloadLimit int       `json:"downloadLimit"`      // KB/s, 0 = unlimited
+	UploadLimit   int       `json:"uploadLimit"`        // KB/s, 0 = unlimited
+	ClientID      *int64    `json:"clientId,omitempty"` // nil applies to all clients
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Bandwidth schedule operations
+
+func (d *Database) CreateBandwidthSchedule(schedule *BandwidthSchedule) error {
+	result, err := d.db.Exec(`
+		INSERT INTO bandwidth_schedules (name, enabled, days, start_time, end_time, download_limit, upload_limit, client_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		schedule.Name, schedule.Enabled, schedule.Days, schedule.StartTime, schedule.EndTime,
+		schedule.DownloadLimit, schedule.UploadLimit, schedule.ClientID,
+	)
+	if err != nil {
+		return err
+	}
+	schedule.ID, _ = result.LastInsertId()
+	schedule.CreatedAt = time.Now()
+	return nil
+}
+
+func (d *Database) GetBandwidthSchedules() ([]BandwidthSchedule, error) {
+	rows, err := d.db.Query(`
+		SELECT id, name, enabled, days, start_time, end_time, download_limit, upload_limit, client_id, created_at
+		FROM bandwidth_schedules ORDER BY start_time, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []BandwidthSchedule
+	for rows.Next() {
+		var s BandwidthSchedule
+		if err := rows.Scan(&s.ID, &s.Name, &s.Enabled, &s.Days, &s.StartTime, &s.EndTime,
+			&s.DownloadLimit, &s.UploadLimit, &s.ClientID, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, nil
+}
+
+func (d *Database) GetBandwidthSchedule(id int64) (*BandwidthSchedule, error) {
+	var s BandwidthSchedule
+	err := d.db.QueryRow(`
+		SELECT id, name, enabled, days, start_time, end_time, download_limit, upload_limit, client_id, created_at
+		FROM bandwidth_schedules WHERE id = ?`, id,
+	).Scan(&s.ID, &s.Name, &s.Enabled, &s.Days, &s.StartTime, &s.EndTime,
+		&s.DownloadLimit, &s.UploadLimit, &s.ClientID, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d *Database) UpdateBandwidthSchedule(schedule *BandwidthSchedule) error {
+	_, err := d.db.Exec(`
+		UPDATE bandwidth_schedules SET name = ?, enabled = ?, days = ?, start_time = ?, end_time = ?,
+			download_limit = ?, upload_limit = ?, client_id = ?
+		WHERE id = ?`,
+		schedule.Name, schedule.Enabled, schedule.Days, schedule.StartTime, schedule.EndTime,
+		schedule.DownloadLimit, schedule.UploadLimit, schedule.ClientID, schedule.ID,
+	)
+	return err
+}
+
+func (d *Database) DeleteBandwidthSchedule(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM bandwidth_schedules WHERE id = ?`, id)
+	return err
+}
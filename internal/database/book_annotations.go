@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Book reading operations
+//
+// EPUB locations are EPUB CFIs (canonical fragment identifiers), which the
+// reader resolves to a point in the book whatever the screen size. Each
+// profile has a position per book, and bookmarks and highlights, so reading
+// carries on from another device.
+
+// BookPosition is where a profile is in a book
+type BookPosition struct {
+	BookID    int64     `json:"bookId"`
+	CFI       string    `json:"cfi"`
+	Progress  float64   `json:"progress"` // 0-1, for display
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BookAnnotation is a bookmark or highlight. Highlights cover a CFI range
+// and keep the text they cover.
+type BookAnnotation struct {
+	ID        int64     `json:"id"`
+	BookID    int64     `json:"bookId"`
+	Type      string    `json:"type"` // bookmark, highlight
+	CFI       string    `json:"cfi"`
+	Text      *string   `json:"text,omitempty"`
+	Note      *string   `json:"note,omitempty"`
+	Color     *string   `json:"color,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GetBookPosition returns a profile's position in a book, or nil if it
+// hasn't been opened
+func (d *Database) GetBookPosition(profileID, bookID int64) (*BookPosition, error) {
+	var p BookPosition
+	err := d.db.QueryRow(`
+		SELECT book_id, cfi, progress, updated_at FROM book_positions
+		WHERE profile_id = ? AND book_id = ?`, profileID, bookID,
+	).Scan(&p.BookID, &p.CFI, &p.Progress, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveBookPosition records where a profile is in a book
+func (d *Database) SaveBookPosition(profileID, bookID int64, cfi string, progress float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO book_positions (profile_id, book_id, cfi, progress, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(profile_id, book_id) DO UPDATE SET
+			cfi = excluded.cfi, progress = excluded.progress, updated_at = CURRENT_TIMESTAMP`,
+		profileID, bookID, cfi, progress,
+	)
+	return err
+}
+
+// DeleteBookPosition forgets a profile's position in a book
+func (d *Database) DeleteBookPosition(profileID, bookID int64) error {
+	_, err := d.db.Exec("DELETE FROM book_positions WHERE profile_id = ? AND book_id = ?", profileID, bookID)
+	return err
+}
+
+const bookAnnotationColumns = `id, book_id, type, cfi, text, note, color, created_at, updated_at`
+
+func scanBookAnnotation(row interface{ Scan(...interface{}) error }) (*BookAnnotation, error) {
+	var a BookAnnotation
+	if err := row.Scan(&a.ID, &a.BookID, &a.Type, &a.CFI, &a.Text, &a.Note, &a.Color, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetBookAnnotations returns a profile's bookmarks and highlights in a book,
+// oldest first
+func (d *Database) GetBookAnnotations(profileID, bookID int64) ([]BookAnnotation, error) {
+	rows, err := d.db.Query(`SELECT `+bookAnnotationColumns+` FROM book_annotations
+		WHERE profile_id = ? AND book_id = ? ORDER BY created_at, id`, profileID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var annotations []BookAnnotation
+	for rows.Next() {
+		a, err := scanBookAnnotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, *a)
+	}
+	return annotations, rows.Err()
+}
+
+// GetBookAnnotation returns one of a profile's annotations
+func (d *Database) GetBookAnnotation(profileID, id int64) (*BookAnnotation, error) {
+	return scanBookAnnotation(d.db.QueryRow(`SELECT `+bookAnnotationColumns+` FROM book_annotations
+		WHERE profile_id = ? AND id = ?`, profileID, id))
+}
+
+// CreateBookAnnotation adds a bookmark or highlight for a profile
+func (d *Database) CreateBookAnnotation(profileID int64, a *BookAnnotation) error {
+	result, err := d.db.Exec(`
+		INSERT INTO book_annotations (profile_id, book_id, type, cfi, text, note, color)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		profileID, a.BookID, a.Type, a.CFI, a.Text, a.Note, a.Color,
+	)
+	if err != nil {
+		return err
+	}
+	a.ID, _ = result.LastInsertId()
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = a.CreatedAt
+	return nil
+}
+
+// UpdateBookAnnotation saves the note and color of an annotation
+func (d *Database) UpdateBookAnnotation(profileID int64, a *BookAnnotation) error {
+	_, err := d.db.Exec(`
+		UPDATE book_annotations SET note = ?, color = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE profile_id = ? AND id = ?`,
+		a.Note, a.Color, profileID, a.ID,
+	)
+	return err
+}
+
+// DeleteBookAnnotation removes one of a profile's annotations, reporting
+// whether there was one
+func (d *Database) DeleteBookAnnotation(profileID, id int64) (bool, error) {
+	result, err := d.db.Exec("DELETE FROM book_annotations WHERE profile_id = ? AND id = ?", profileID, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
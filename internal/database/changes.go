@@ -0,0 +1,271 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change journal operations
+//
+// Triggers record every insert, update and delete of the tables clients
+// cache, so apps can ask what changed since their last sync instead of
+// fetching whole lists again. Entries are compacted to the latest per row and
+// dropped after a retention period; a client whose cursor predates the
+// retained journal has to start over from a snapshot. Each entry records the
+// library or profile its row belongs to, so viewers are only sent changes to
+// what they can see; a deleted row can't be looked up afterwards.
+
+// JournaledTables are the tables whose changes are journaled
+var JournaledTables = []string{
+	"libraries", "movies", "shows", "seasons", "episodes",
+	"progress", "watch_history", "collections", "collection_items",
+}
+
+// DefaultChangeRetentionDays is how long journal entries are kept by default
+const DefaultChangeRetentionDays = 30
+
+// libraryTables are the journaled tables whose rows belong to a library, and
+// profileTables those whose rows belong to a profile
+var (
+	libraryTables = []string{"libraries", "movies", "shows", "seasons", "episodes"}
+	profileTables = []string{"progress", "watch_history"}
+)
+
+// Change is a journaled change to a row. Cursor orders changes; pass the
+// last one seen to get the changes after it.
+type Change struct {
+	Cursor    int64     `json:"cursor"`
+	Table     string    `json:"table"`
+	RowID     int64     `json:"rowId"`
+	Op        string    `json:"op"` // insert, update, delete
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// ChangeJournalStatus describes the journal, for admins
+type ChangeJournalStatus struct {
+	Entries       int64 `json:"entries"`
+	Cursor        int64 `json:"cursor"`        // Latest change
+	Floor         int64 `json:"floor"`         // Oldest cursor still valid for sync
+	RetentionDays int   `json:"retentionDays"` // 0 keeps entries forever
+}
+
+// ChangeFilter narrows the changes a viewer is sent
+type ChangeFilter struct {
+	Tables     []string // Only changes to these tables; empty for all
+	LibraryIDs []int64  // Library rows only from these libraries; nil for all
+	ProfileID  *int64   // Progress and watch history of this profile only; nil for none
+}
+
+// journalOwner returns the SQL for the library and profile a row of a
+// journaled table belongs to, row being NEW or OLD
+func journalOwner(table, row string) (library, profile string) {
+	switch table {
+	case "libraries":
+		return row + ".id", "NULL"
+	case "movies", "shows":
+		return row + ".library_id", "NULL"
+	case "seasons":
+		return "(SELECT library_id FROM shows WHERE id = " + row + ".show_id)", "NULL"
+	case "episodes":
+		return "(SELECT sh.library_id FROM seasons se JOIN shows sh ON sh.id = se.show_id WHERE se.id = " + row + ".season_id)", "NULL"
+	case "progress", "watch_history":
+		return "NULL", row + ".profile_id"
+	}
+	return "NULL", "NULL"
+}
+
+// setupChangeJournal creates the journal triggers of each journaled table.
+// They're recreated on every start so changes to them reach old databases.
+func (d *Database) setupChangeJournal() {
+	for _, table := range JournaledTables {
+		for _, t := range []struct{ event, op, row string }{
+			{"INSERT", "insert", "NEW"},
+			{"UPDATE", "update", "NEW"},
+			{"DELETE", "delete", "OLD"},
+		} {
+			library, profile := journalOwner(table, t.row)
+			stmt := fmt.Sprintf(`
+				CREATE TRIGGER journal_%s_%s AFTER %s ON %s
+				BEGIN
+					INSERT INTO change_journal (table_name, row_id, op, library_id, profile_id)
+					VALUES ('%s', %s.id, '%s', %s, %s);
+				END`, table, t.op, t.event, table, table, t.row, t.op, library, profile)
+			d.db.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS journal_%s_%s`, table, t.op))
+			if _, err := d.db.Exec(stmt); err != nil {
+				logger.Errorf("Failed to create change journal trigger for %s: %v", table, err)
+			}
+		}
+	}
+}
+
+// GetChanges returns changes after a cursor, oldest first, with only the
+// latest change of each row, narrowed by a filter. Library rows whose library
+// isn't known, such as seasons deleted along with their show, are only sent
+// to viewers who see every library.
+func (d *Database) GetChanges(since int64, filter ChangeFilter, limit int) ([]Change, error) {
+	var where []string
+	var args []interface{}
+	if filter.ProfileID != nil {
+		where = append(where, `(table_name NOT IN `+sqlList(profileTables)+` OR profile_id = ?)`)
+		args = append(args, *filter.ProfileID)
+	} else {
+		where = append(where, `table_name NOT IN `+sqlList(profileTables))
+	}
+	if len(filter.Tables) > 0 {
+		where = append(where, `table_name IN (?`+strings.Repeat(", ?", len(filter.Tables)-1)+`)`)
+		for _, t := range filter.Tables {
+			args = append(args, t)
+		}
+	}
+	if filter.LibraryIDs != nil {
+		cond := `table_name NOT IN ` + sqlList(libraryTables)
+		if len(filter.LibraryIDs) > 0 {
+			cond += ` OR library_id IN (?` + strings.Repeat(", ?", len(filter.LibraryIDs)-1) + `)`
+			for _, id := range filter.LibraryIDs {
+				args = append(args, id)
+			}
+		}
+		where = append(where, `(`+cond+`)`)
+	}
+
+	// SQLite takes the other columns from the row holding MAX(id), so the
+	// filter applies to the latest change of each row
+	query := `
+		SELECT id, table_name, row_id, op, changed_at FROM (
+			SELECT MAX(id) AS id, table_name, row_id, op, changed_at, library_id, profile_id
+			FROM change_journal
+			WHERE id > ?
+			GROUP BY table_name, row_id
+		)
+		WHERE ` + strings.Join(where, " AND ")
+	args = append([]interface{}{since}, args...)
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Cursor, &c.Table, &c.RowID, &c.Op, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// GetChangeCursor returns the cursor of the latest change, 0 if none
+func (d *Database) GetChangeCursor() (int64, error) {
+	var cursor int64
+	err := d.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM change_journal`).Scan(&cursor)
+	return cursor, err
+}
+
+// GetChangeFloor returns the oldest cursor changes can still be synced from.
+// Older cursors may have missed changes that were dropped from the journal.
+func (d *Database) GetChangeFloor() int64 {
+	value, _ := d.GetSetting("change_journal_floor")
+	floor, _ := strconv.ParseInt(value, 10, 64)
+	return floor
+}
+
+// GetChangeRetentionDays returns how many days journal entries are kept
+func (d *Database) GetChangeRetentionDays() int {
+	value, err := d.GetSetting("change_journal_retention_days")
+	if err != nil || value == "" {
+		return DefaultChangeRetentionDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return DefaultChangeRetentionDays
+	}
+	return days
+}
+
+// GetChangeJournalStatus returns the size and bounds of the journal
+func (d *Database) GetChangeJournalStatus() (*ChangeJournalStatus, error) {
+	status := &ChangeJournalStatus{
+		Floor:         d.GetChangeFloor(),
+		RetentionDays: d.GetChangeRetentionDays(),
+	}
+	err := d.db.QueryRow(`SELECT COUNT(*), COALESCE(MAX(id), 0) FROM change_journal`).Scan(&status.Entries, &status.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// CompactChangeJournal drops entries superseded by a later change of the same
+// row. Syncing clients only ever get the latest change of a row, so no cursor
+// is invalidated. Returns the number of entries dropped.
+func (d *Database) CompactChangeJournal() (int64, error) {
+	result, err := d.db.Exec(`
+		DELETE FROM change_journal
+		WHERE id NOT IN (SELECT MAX(id) FROM change_journal GROUP BY table_name, row_id)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SimulateChangeJournalCleanup returns how many entries PruneChangeJournal
+// and then CompactChangeJournal would drop, without dropping them
+func (d *Database) SimulateChangeJournalCleanup(retentionDays int) (pruned, compacted int64, err error) {
+	var newest int64
+	if retentionDays > 0 {
+		cutoff := fmt.Sprintf("-%d days", retentionDays)
+		if err := d.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM change_journal WHERE changed_at < datetime('now', ?)`, cutoff).Scan(&newest); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM change_journal WHERE id <= ?`, newest).Scan(&pruned); err != nil {
+		return 0, 0, err
+	}
+	err = d.db.QueryRow(`
+		SELECT COUNT(*) FROM change_journal
+		WHERE id > ? AND id NOT IN (SELECT MAX(id) FROM change_journal WHERE id > ? GROUP BY table_name, row_id)`,
+		newest, newest).Scan(&compacted)
+	return pruned, compacted, err
+}
+
+// PruneChangeJournal drops entries older than the retention period and raises
+// the floor past them, so clients with older cursors know to resync. Returns
+// the number of entries dropped.
+func (d *Database) PruneChangeJournal(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := fmt.Sprintf("-%d days", retentionDays)
+
+	var newest int64
+	if err := d.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM change_journal WHERE changed_at < datetime('now', ?)`, cutoff).Scan(&newest); err != nil {
+		return 0, err
+	}
+	if newest == 0 {
+		return 0, nil
+	}
+
+	result, err := d.db.Exec(`DELETE FROM change_journal WHERE id <= ?`, newest)
+	if err != nil {
+		return 0, err
+	}
+	if newest > d.GetChangeFloor() {
+		if err := d.SetSetting("change_journal_floor", strconv.FormatInt(newest, 10)); err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected()
+}
+
+// sqlList returns names as an SQL list of string literals. Only for names
+// fixed in the code.
+func sqlList(names []string) string {
+	return "('" + strings.Join(names, "', '") + "')"
+}
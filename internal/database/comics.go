@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Comic operations
+//
+// Comics are books in CBZ or CBR format. Their series, issue and page count
+// come from the archive when it's scanned, and each profile's reading
+// position is kept as the page it's on.
+
+// ReadingProgress is how far a profile has read a comic
+type ReadingProgress struct {
+	BookID    int64     `json:"bookId"`
+	Page      int       `json:"page"` // From 1
+	PageCount int       `json:"pageCount"`
+	Finished  bool      `json:"finished"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UpdateComicDetails stores what was read from a comic archive
+func (d *Database) UpdateComicDetails(book *Book) error {
+	_, err := d.db.Exec(`
+		UPDATE books SET title = ?, author = ?, publisher = ?, year = ?, description = ?, cover_path = ?,
+			series = ?, issue_number = ?, page_count = ?
+		WHERE id = ?`,
+		book.Title, book.Author, book.Publisher, book.Year, book.Description, book.CoverPath,
+		book.Series, book.IssueNumber, book.PageCount, book.ID,
+	)
+	return err
+}
+
+// GetReadingProgress returns a profile's position in a comic, or nil if it
+// hasn't been opened
+func (d *Database) GetReadingProgress(profileID, bookID int64) (*ReadingProgress, error) {
+	var p ReadingProgress
+	err := d.db.QueryRow(`
+		SELECT book_id, page, page_count, updated_at FROM reading_progress
+		WHERE profile_id = ? AND book_id = ?`, profileID, bookID,
+	).Scan(&p.BookID, &p.Page, &p.PageCount, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Finished = p.Page >= p.PageCount
+	return &p, nil
+}
+
+// SaveReadingProgress records the page a profile is on
+func (d *Database) SaveReadingProgress(profileID, bookID int64, page, pageCount int) error {
+	_, err := d.db.Exec(`
+		INSERT INTO reading_progress (profile_id, book_id, page, page_count, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(profile_id, book_id) DO UPDATE SET
+			page = excluded.page, page_count = excluded.page_count, updated_at = CURRENT_TIMESTAMP`,
+		profileID, bookID, page, pageCount,
+	)
+	return err
+}
+
+// DeleteReadingProgress forgets a profile's position in a comic
+func (d *Database) DeleteReadingProgress(profileID, bookID int64) error {
+	_, err := d.db.Exec("DELETE FROM reading_progress WHERE profile_id = ? AND book_id = ?", profileID, bookID)
+	return err
+}
@@ -4,14 +4,19 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/outpost/outpost/internal/logging"
 )
 
+var logger = logging.Module("database")
+
 type Database struct {
 	db *sql.DB
 }
@@ -25,8 +30,19 @@ type Library struct {
 	ID           int64  `json:"id"`
 	Name         string `json:"name"`
 	Path         string `json:"path"`
-	Type         string `json:"type"` // movies, tv, anime, music, books
+	Type         string `json:"type"` // movies, tv, anime, music, books, comics, photos
 	ScanInterval int    `json:"scanInterval"`
+
+	// Metadata providers in the order they're asked; empty uses the default
+	MetadataProviders []string `json:"metadataProviders"`
+	// Artwork shown in grids: poster or landscape, and whether it should
+	// carry the title (text), not (textless), or either (any)
+	ArtworkStyle string `json:"artworkStyle"`
+	ArtworkText  string `json:"artworkText"`
+	// Scanned gently, for libraries on network storage
+	ScanThrottle bool `json:"scanThrottle"`
+	// Subtitle profile whose languages are downloaded for new files
+	SubtitleProfileID *int64 `json:"subtitleProfileId,omitempty"`
 }
 
 type Movie struct {
@@ -64,12 +80,18 @@ type Movie struct {
 	FocalY             *float64   `json:"focalY,omitempty"`
 	Path               string     `json:"path"`
 	Size               int64      `json:"size"`
+	Edition            *string    `json:"edition,omitempty"` // Edition of the file, e.g. "directors" or "extended"
 	AddedAt            time.Time  `json:"addedAt"`
 	LastWatchedAt      *string    `json:"lastWatchedAt,omitempty"`
 	PlayCount          int        `json:"playCount"`
 	MissingSince       *time.Time `json:"missingSince,omitempty"`
 	MatchConfidence    float64    `json:"matchConfidence"`
 	NeedsMatchReview   bool       `json:"needsMatchReview"`
+
+	Versions []MediaVersion `json:"versions,omitempty"` // Extra files, loaded for single-movie requests
+
+	ArtworkPath *string `json:"artworkPath,omitempty"` // Grid artwork in the library's artwork style
+	Colors      *string `json:"colors,omitempty"`      // JSON: {"poster": {"dominant": "#rrggbb", "accent": "#rrggbb"}, "backdrop": {...}}
 }
 
 type Show struct {
@@ -97,6 +119,9 @@ type Show struct {
 	AddedAt          *time.Time `json:"addedAt,omitempty"`
 	MatchConfidence  float64    `json:"matchConfidence"`
 	NeedsMatchReview bool       `json:"needsMatchReview"`
+
+	ArtworkPath *string `json:"artworkPath,omitempty"` // Grid artwork in the library's artwork style
+	Colors      *string `json:"colors,omitempty"`      // JSON: {"poster": {"dominant": "#rrggbb", "accent": "#rrggbb"}, "backdrop": {...}}
 }
 
 type Season struct {
@@ -124,6 +149,8 @@ type Episode struct {
 	Size            int64      `json:"size"`
 	MissingSince    *time.Time `json:"missingSince,omitempty"`
 	MatchConfidence float64    `json:"matchConfidence"`
+
+	Versions []MediaVersion `json:"versions,omitempty"` // Extra files, loaded for single-episode requests
 }
 
 type Progress struct {
@@ -269,6 +296,12 @@ type WantedItem struct {
 	CurrentScore     int        `json:"currentScore"`            // Quality score of existing media (for upgrade comparison)
 	SearchAttempts   int        `json:"searchAttempts"`          // Number of search attempts for upgrade backoff
 	NextSearchAt     *time.Time `json:"nextSearchAt,omitempty"`  // When upgrade can be searched again
+	Edition          *string    `json:"edition,omitempty"`       // Only releases of this edition are grabbed; nil for any
+	Priority         string     `json:"priority"`                // low, normal, high
+
+	// AlternateTitles are other titles a search found releases under, which
+	// releases are matched against too. Not stored.
+	AlternateTitles []string `json:"-"`
 }
 
 type Request struct {
@@ -289,6 +322,28 @@ type Request struct {
 	StatusReason     *string   `json:"statusReason,omitempty"`
 	RequestedAt      time.Time `json:"requestedAt"`
 	UpdatedAt        time.Time `json:"updatedAt"`
+
+	// Denial reason picked, and whether a denied request reopens once the
+	// title is released
+	StatusReasonID      *int64 `json:"statusReasonId,omitempty"`
+	RecheckAfterRelease bool   `json:"recheckAfterRelease,omitempty"`
+
+	// Fulfillment estimate for approved requests, updated by the acquisition service
+	EtaStatus        *string    `json:"etaStatus,omitempty"` // unreleased, searching, queued, downloading, paused, stalled, importing, blocked, available
+	EtaMessage       *string    `json:"etaMessage,omitempty"`
+	EtaAt            *time.Time `json:"etaAt,omitempty"`            // Digital release date or expected download completion
+	DownloadProgress *float64   `json:"downloadProgress,omitempty"` // 0-100
+	EtaUpdatedAt     *time.Time `json:"etaUpdatedAt,omitempty"`
+
+	// Name given on the request portal; the request belongs to the portal's account
+	RequesterName *string `json:"requesterName,omitempty"`
+
+	// Edition of a movie asked for, e.g. "directors" or "extended"; nil for any
+	Edition *string `json:"edition,omitempty"`
+
+	// How soon it's wanted: low, normal or high. Carried over to the wanted
+	// item when the request is approved.
+	Priority string `json:"priority"`
 }
 
 // Music types
@@ -305,14 +360,16 @@ type Artist struct {
 }
 
 type Album struct {
-	ID            int64   `json:"id"`
-	ArtistID      int64   `json:"artistId"`
-	MusicBrainzID *string `json:"musicBrainzId,omitempty"`
-	Title         string  `json:"title"`
-	Year          int     `json:"year,omitempty"`
-	Overview      *string `json:"overview,omitempty"`
-	CoverPath     *string `json:"coverPath,omitempty"`
-	Path          string  `json:"path"`
+	ID             int64   `json:"id"`
+	ArtistID       int64   `json:"artistId"`
+	MusicBrainzID  *string `json:"musicBrainzId,omitempty"` // The release
+	ReleaseGroupID *string `json:"releaseGroupId,omitempty"`
+	ReleaseType    *string `json:"releaseType,omitempty"` // Album, EP, Single, ...
+	Title          string  `json:"title"`
+	Year           int     `json:"year,omitempty"`
+	Overview       *string `json:"overview,omitempty"`
+	CoverPath      *string `json:"coverPath,omitempty"`
+	Path           string  `json:"path"`
 }
 
 type Track struct {
@@ -325,6 +382,8 @@ type Track struct {
 	Duration      int     `json:"duration"` // seconds
 	Path          string  `json:"path"`
 	Size          int64   `json:"size"`
+	Genre         *string `json:"genre,omitempty"` // As tagged, e.g. "Rock; Indie"
+	BPM           *int    `json:"bpm,omitempty"`
 }
 
 // Book types
@@ -340,6 +399,9 @@ type Book struct {
 	Description *string   `json:"description,omitempty"`
 	CoverPath   *string   `json:"coverPath,omitempty"`
 	Format      string    `json:"format"` // epub, pdf, mobi, cbz, cbr
+	Series      *string   `json:"series,omitempty"`
+	IssueNumber *string   `json:"issueNumber,omitempty"`
+	PageCount   *int      `json:"pageCount,omitempty"`
 	Path        string    `json:"path"`
 	Size        int64     `json:"size"`
 	AddedAt     time.Time `json:"addedAt"`
@@ -381,6 +443,14 @@ type QualityPreset struct {
 	PreferDualAudio   bool   `json:"preferDualAudio"`
 	PreferDubbed      bool   `json:"preferDubbed"`
 	PreferredLanguage string `json:"preferredLanguage"` // "english", "japanese", "any"
+	// Size and bitrate sanity limits, 0 = no limit
+	MinSizePerHour    int `json:"minSizePerHour"` // MB per hour of runtime
+	MaxSizePerHour    int `json:"maxSizePerHour"` // MB per hour of runtime
+	MinBitrate        int `json:"minBitrate"`     // Kbps, estimated from size and runtime
+	MaxBitrate        int `json:"maxBitrate"`     // Kbps, estimated from size and runtime
+	// Release thresholds for automatic grabbing, 0 = no limit
+	MaxAgeDays         int `json:"maxAgeDays"`         // Skip releases published longer ago than this
+	FirstSeenWaitHours int `json:"firstSeenWaitHours"` // Hours a release must have been seen before it's grabbed
 	CreatedAt         time.Time `json:"createdAt"`
 	UpdatedAt         time.Time `json:"updatedAt"`
 }
@@ -603,20 +673,22 @@ type Exclusion struct {
 
 // ScheduledTask represents a background task
 type ScheduledTask struct {
-	ID              int64      `json:"id"`
-	Name            string     `json:"name"`
-	Description     string     `json:"description"`
-	TaskType        string     `json:"taskType"`
-	Enabled         bool       `json:"enabled"`
-	IntervalMinutes int        `json:"intervalMinutes"`
-	LastRun         *time.Time `json:"lastRun"`
-	NextRun         *time.Time `json:"nextRun"`
-	LastDurationMs  *int64     `json:"lastDurationMs"`
-	LastStatus      string     `json:"lastStatus"`
-	LastError       *string    `json:"lastError"`
-	RunCount        int        `json:"runCount"`
-	FailCount       int        `json:"failCount"`
-	IsRunning       bool       `json:"isRunning"` // Computed at runtime
+	ID                int64      `json:"id"`
+	Name              string     `json:"name"`
+	Description       string     `json:"description"`
+	TaskType          string     `json:"taskType"`
+	Enabled           bool       `json:"enabled"`
+	IntervalMinutes   int        `json:"intervalMinutes"`
+	LastRun           *time.Time `json:"lastRun"`
+	NextRun           *time.Time `json:"nextRun"`
+	LastDurationMs    *int64     `json:"lastDurationMs"`
+	LastStatus        string     `json:"lastStatus"`
+	LastError         *string    `json:"lastError"`
+	RunCount          int        `json:"runCount"`
+	FailCount         int        `json:"failCount"`
+	IsRunning         bool       `json:"isRunning"`           // Computed at runtime
+	StartedAt         *time.Time `json:"startedAt,omitempty"` // Computed at runtime, start of the current run
+	RecentDurationsMs []int64    `json:"recentDurationsMs"`   // Computed at runtime, newest first
 }
 
 // TaskHistory represents a task execution record
@@ -656,7 +728,10 @@ type Collection struct {
 	PosterPath       *string   `json:"posterPath,omitempty"`
 	BackdropPath     *string   `json:"backdropPath,omitempty"`
 	IsAuto           bool      `json:"isAuto"`
-	SortOrder        string    `json:"sortOrder"` // release, added, title, custom
+	IsSmart          bool      `json:"isSmart"`
+	Rules            *string   `json:"rules,omitempty"`     // JSON PlaylistRules for smart collections
+	MediaType        string    `json:"mediaType,omitempty"` // movie, show, both (smart collections)
+	SortOrder        string    `json:"sortOrder"`           // release, added, title, custom
 	ItemCount        int       `json:"itemCount,omitempty"`
 	OwnedCount       int       `json:"ownedCount,omitempty"`
 	CreatedAt        time.Time `json:"createdAt"`
@@ -713,6 +788,7 @@ type PlaylistCondition struct {
 type SmartPlaylistItem struct {
 	ID         int64   `json:"id"`
 	MediaType  string  `json:"mediaType"`
+	TmdbID     *int64  `json:"tmdbId,omitempty"`
 	Title      string  `json:"title"`
 	Year       int     `json:"year,omitempty"`
 	PosterPath *string `json:"posterPath,omitempty"`
@@ -765,7 +841,11 @@ type TraktSyncQueueItem struct {
 }
 
 func New(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	// Wait up to 5 seconds when the database is busy. It's set in the DSN
+	// so the driver applies it to every pooled connection; a PRAGMA would
+	// only reach the one it ran on, and jobs write alongside requests.
+	params := url.Values{"_pragma": {"busy_timeout(5000)"}}
+	db, err := sql.Open("sqlite", dbPath+"?"+params.Encode())
 	if err != nil {
 		return nil, err
 	}
@@ -775,11 +855,6 @@ func New(dbPath string) (*Database, error) {
 		db.Close()
 		return nil, err
 	}
-	// Set busy timeout to 5 seconds
-	if _, err := db.Exec("PRAGMA busy_timeout=5000"); err != nil {
-		db.Close()
-		return nil, err
-	}
 
 	d := &Database{db: db}
 	if err := d.migrate(); err != nil {
@@ -800,7 +875,11 @@ func (d *Database) migrate() error {
 		name TEXT NOT NULL,
 		path TEXT NOT NULL UNIQUE,
 		type TEXT NOT NULL,
-		scan_interval INTEGER DEFAULT 3600
+		scan_interval INTEGER DEFAULT 3600,
+		metadata_providers TEXT DEFAULT '',
+		artwork_style TEXT DEFAULT 'poster',
+		artwork_text TEXT DEFAULT 'any',
+		scan_throttle INTEGER DEFAULT 0
 	);
 
 	CREATE TABLE IF NOT EXISTS movies (
@@ -833,6 +912,8 @@ func (d *Database) migrate() error {
 		backdrop_path TEXT,
 		focal_x REAL,
 		focal_y REAL,
+		artwork_path TEXT,
+		colors TEXT,
 		path TEXT NOT NULL UNIQUE,
 		size INTEGER,
 		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -862,6 +943,8 @@ func (d *Database) migrate() error {
 		backdrop_path TEXT,
 		focal_x REAL,
 		focal_y REAL,
+		artwork_path TEXT,
+		colors TEXT,
 		path TEXT NOT NULL UNIQUE,
 		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (library_id) REFERENCES libraries(id) ON DELETE CASCADE
@@ -1065,8 +1148,15 @@ func (d *Database) migrate() error {
 		seasons TEXT,
 		status TEXT NOT NULL DEFAULT 'requested',
 		status_reason TEXT,
+		status_reason_id INTEGER,
+		recheck_after_release INTEGER DEFAULT 0,
 		requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		eta_status TEXT,
+		eta_message TEXT,
+		eta_at DATETIME,
+		download_progress REAL,
+		eta_updated_at DATETIME,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 		FOREIGN KEY (quality_profile_id) REFERENCES quality_profiles(id),
 		UNIQUE(user_id, type, tmdb_id)
@@ -1114,6 +1204,8 @@ func (d *Database) migrate() error {
 		duration INTEGER DEFAULT 0,
 		path TEXT NOT NULL UNIQUE,
 		size INTEGER,
+		genre TEXT,
+		bpm INTEGER,
 		FOREIGN KEY (album_id) REFERENCES albums(id) ON DELETE CASCADE
 	);
 
@@ -1128,6 +1220,9 @@ func (d *Database) migrate() error {
 		description TEXT,
 		cover_path TEXT,
 		format TEXT NOT NULL,
+		series TEXT,
+		issue_number TEXT,
+		page_count INTEGER,
 		path TEXT NOT NULL UNIQUE,
 		size INTEGER,
 		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -1508,6 +1603,9 @@ func (d *Database) migrate() error {
 		poster_path TEXT,
 		backdrop_path TEXT,
 		is_auto INTEGER DEFAULT 0,
+		is_smart INTEGER DEFAULT 0,
+		rules TEXT,
+		media_type TEXT DEFAULT 'both',
 		sort_order TEXT DEFAULT 'release',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -1541,6 +1639,9 @@ func (d *Database) migrate() error {
 		is_kid INTEGER DEFAULT 0,
 		content_rating_limit TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		played_threshold INTEGER,
+		played_credits INTEGER,
+		share_activity INTEGER DEFAULT 0,
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	);
 	CREATE INDEX IF NOT EXISTS idx_profiles_user ON profiles(user_id);
@@ -1611,6 +1712,660 @@ func (d *Database) migrate() error {
 		processed_at DATETIME
 	);
 	CREATE INDEX IF NOT EXISTS idx_trakt_queue_status ON trakt_sync_queue(status);
+
+	-- Audit log of administrative and security-relevant actions
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER,
+		username TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_type TEXT,
+		target_id INTEGER,
+		details TEXT,
+		ip_address TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+
+	-- One-time password reset tokens (admin-generated links and email resets)
+	CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_by INTEGER,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id);
+
+	-- Invite links for self-registration with preset permissions
+	CREATE TABLE IF NOT EXISTS invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token TEXT NOT NULL UNIQUE,
+		note TEXT,
+		role TEXT NOT NULL DEFAULT 'user',
+		content_rating_limit TEXT,
+		library_ids TEXT,
+		request_quota INTEGER,
+		request_quota_days INTEGER DEFAULT 7,
+		max_uses INTEGER DEFAULT 0,
+		uses INTEGER DEFAULT 0,
+		expires_at DATETIME,
+		created_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Time-based alternative speed limits for download clients
+	CREATE TABLE IF NOT EXISTS bandwidth_schedules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		enabled INTEGER DEFAULT 1,
+		days TEXT NOT NULL DEFAULT '0,1,2,3,4,5,6',
+		start_time TEXT NOT NULL,
+		end_time TEXT NOT NULL,
+		download_limit INTEGER DEFAULT 0,
+		upload_limit INTEGER DEFAULT 0,
+		client_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (client_id) REFERENCES download_clients(id) ON DELETE CASCADE
+	);
+
+	-- Ranked acceptable releases from the last automatic search, used to swap stalled downloads
+	CREATE TABLE IF NOT EXISTS search_candidates (
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		results TEXT NOT NULL,
+		searched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (media_type, media_id)
+	);
+
+	-- When each release first appeared in search results
+	CREATE TABLE IF NOT EXISTS release_sightings (
+		release_title TEXT PRIMARY KEY,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- TMDB release dates of wanted movies that aren't in the library yet
+	CREATE TABLE IF NOT EXISTS movie_release_dates (
+		tmdb_id INTEGER PRIMARY KEY,
+		theatrical_release TEXT,
+		digital_release TEXT,
+		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Extra files of a movie or episode, kept when duplicate rows are merged
+	CREATE TABLE IF NOT EXISTS media_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		path TEXT NOT NULL UNIQUE,
+		size INTEGER,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_versions_media ON media_versions(media_type, media_id);
+
+	-- Journal of inserts, updates and deletes of library rows, filled by
+	-- triggers, for clients that sync incrementally
+	CREATE TABLE IF NOT EXISTS change_journal (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		op TEXT NOT NULL CHECK (op IN ('insert', 'update', 'delete')),
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		library_id INTEGER,
+		profile_id INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_change_journal_row ON change_journal(table_name, row_id);
+	CREATE INDEX IF NOT EXISTS idx_change_journal_changed ON change_journal(changed_at);
+
+	-- Streaming sessions and the bytes served to them
+	CREATE TABLE IF NOT EXISTS stream_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		auth_session_id INTEGER,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		client_ip TEXT,
+		remote INTEGER DEFAULT 0,
+		bytes_served INTEGER DEFAULT 0,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_stream_sessions_user ON stream_sessions(user_id, last_seen_at);
+
+	-- Bytes streamed per user per day; remote_bytes went outside the local network
+	CREATE TABLE IF NOT EXISTS bandwidth_usage (
+		user_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		bytes INTEGER DEFAULT 0,
+		remote_bytes INTEGER DEFAULT 0,
+		PRIMARY KEY (user_id, day),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	-- Per-user monthly transfer caps, overriding bandwidth_monthly_cap_gb
+	CREATE TABLE IF NOT EXISTS transfer_caps (
+		user_id INTEGER PRIMARY KEY,
+		monthly_gb INTEGER NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	-- Each library's size per day, for growth trends. Rows outlive their
+	-- library, so its history still adds up in the totals.
+	CREATE TABLE IF NOT EXISTS library_stats (
+		day TEXT NOT NULL,
+		library_id INTEGER NOT NULL,
+		library_name TEXT NOT NULL,
+		library_type TEXT NOT NULL,
+		items INTEGER DEFAULT 0,
+		files INTEGER DEFAULT 0,
+		size INTEGER DEFAULT 0,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (day, library_id)
+	);
+
+	-- Reusable reasons admins pick from when denying requests
+	CREATE TABLE IF NOT EXISTS request_reasons (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		label TEXT NOT NULL,
+		message TEXT NOT NULL,
+		recheck_after_release INTEGER DEFAULT 0,
+		sort_order INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Browsers and addresses admins have signed in from, for new device alerts
+	CREATE TABLE IF NOT EXISTS login_devices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		fingerprint TEXT NOT NULL,
+		user_agent TEXT,
+		ip_address TEXT,
+		first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, fingerprint),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	-- Upgrades and deletions, shown alongside grab and import history
+	CREATE TABLE IF NOT EXISTS media_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		media_type TEXT NOT NULL,
+		media_id INTEGER,
+		summary TEXT NOT NULL,
+		details TEXT,
+		user_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_events_media ON media_events(media_type, media_id);
+
+	-- Items pinned to the top of everyone's home screen
+	CREATE TABLE IF NOT EXISTS home_pins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		note TEXT,
+		position INTEGER DEFAULT 0,
+		expires_at DATETIME,
+		pinned_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(media_type, media_id)
+	);
+
+	-- Focal points found in cached images, keyed by cache path or image URL
+	CREATE TABLE IF NOT EXISTS image_focal_points (
+		image TEXT PRIMARY KEY,
+		focal_x REAL NOT NULL,
+		focal_y REAL NOT NULL,
+		analyzed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Dominant and accent colors found in cached images, keyed by cache path
+	CREATE TABLE IF NOT EXISTS image_colors (
+		image TEXT PRIMARY KEY,
+		dominant TEXT NOT NULL,
+		accent TEXT NOT NULL,
+		analyzed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Music listening history, for instant mixes
+	CREATE TABLE IF NOT EXISTS track_plays (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		profile_id INTEGER REFERENCES profiles(id) ON DELETE SET NULL,
+		track_id INTEGER NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+		played_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_track_plays_user ON track_plays(user_id, played_at);
+
+	-- Music playlists, ordered lists of tracks kept per profile
+	CREATE TABLE IF NOT EXISTS playlists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		profile_id INTEGER REFERENCES profiles(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		description TEXT,
+		resume_entry_id INTEGER, -- Entry last played, for continue listening
+		last_played_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_playlists_user ON playlists(user_id, profile_id);
+	CREATE TABLE IF NOT EXISTS playlist_tracks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+		track_id INTEGER NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_playlist_tracks_playlist ON playlist_tracks(playlist_id, position);
+
+	-- Lyrics fetched for tracks; a row with neither lyrics nor instrumental
+	-- set records a lookup that found nothing
+	CREATE TABLE IF NOT EXISTS lyrics (
+		track_id INTEGER PRIMARY KEY REFERENCES tracks(id) ON DELETE CASCADE,
+		source TEXT NOT NULL,
+		synced TEXT,
+		plain TEXT,
+		instrumental INTEGER NOT NULL DEFAULT 0,
+		fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Podcasts: feeds are shared, users subscribe to them
+	CREATE TABLE IF NOT EXISTS podcasts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		feed_url TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		author TEXT,
+		description TEXT,
+		image_url TEXT,
+		website TEXT,
+		auto_download INTEGER NOT NULL DEFAULT 1,
+		keep_episodes INTEGER NOT NULL DEFAULT 0,
+		last_refreshed DATETIME,
+		last_error TEXT,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS podcast_episodes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		podcast_id INTEGER NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+		guid TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		audio_url TEXT NOT NULL,
+		mime_type TEXT,
+		duration INTEGER,
+		published_at DATETIME,
+		file_path TEXT,
+		size INTEGER,
+		downloaded_at DATETIME,
+		UNIQUE(podcast_id, guid)
+	);
+	CREATE INDEX IF NOT EXISTS idx_podcast_episodes_podcast ON podcast_episodes(podcast_id, published_at);
+
+	CREATE TABLE IF NOT EXISTS podcast_subscriptions (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		podcast_id INTEGER NOT NULL REFERENCES podcasts(id) ON DELETE CASCADE,
+		subscribed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, podcast_id)
+	);
+
+	-- Page each profile has read to in a comic
+	CREATE TABLE IF NOT EXISTS reading_progress (
+		profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+		book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		page INTEGER NOT NULL,
+		page_count INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (profile_id, book_id)
+	);
+
+	-- Where each profile is in an EPUB, as an EPUB CFI
+	CREATE TABLE IF NOT EXISTS book_positions (
+		profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+		book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		cfi TEXT NOT NULL,
+		progress REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (profile_id, book_id)
+	);
+
+	-- Bookmarks and highlights in EPUBs
+	CREATE TABLE IF NOT EXISTS book_annotations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+		book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		type TEXT NOT NULL,
+		cfi TEXT NOT NULL,
+		text TEXT,
+		note TEXT,
+		color TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_book_annotations_profile ON book_annotations(profile_id, book_id);
+
+	-- Audiobooks in book libraries: an M4B file, or a folder of audio files
+	-- played one after another. Their chapters are in chapters as audiobook.
+	CREATE TABLE IF NOT EXISTS audiobooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		library_id INTEGER NOT NULL REFERENCES libraries(id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		author TEXT,
+		narrator TEXT,
+		year INTEGER,
+		description TEXT,
+		path TEXT NOT NULL UNIQUE,
+		duration REAL NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS audiobook_files (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		audiobook_id INTEGER NOT NULL REFERENCES audiobooks(id) ON DELETE CASCADE,
+		file_index INTEGER NOT NULL,
+		path TEXT NOT NULL UNIQUE,
+		start_time REAL NOT NULL DEFAULT 0,
+		duration REAL NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		UNIQUE(audiobook_id, file_index)
+	);
+
+	-- Where each profile is in an audiobook, in seconds from the start, and
+	-- the speed it listens at
+	CREATE TABLE IF NOT EXISTS audiobook_progress (
+		profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+		audiobook_id INTEGER NOT NULL REFERENCES audiobooks(id) ON DELETE CASCADE,
+		position REAL NOT NULL DEFAULT 0,
+		speed REAL NOT NULL DEFAULT 1,
+		finished INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (profile_id, audiobook_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_audiobook_progress_updated ON audiobook_progress(profile_id, updated_at);
+
+	-- Pictures in photo libraries. album is the folder a photo is in,
+	-- relative to the library, and taken_at the camera's wall clock time.
+	CREATE TABLE IF NOT EXISTS photos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		library_id INTEGER NOT NULL REFERENCES libraries(id) ON DELETE CASCADE,
+		path TEXT NOT NULL UNIQUE,
+		album TEXT NOT NULL DEFAULT '',
+		title TEXT NOT NULL,
+		format TEXT NOT NULL,
+		width INTEGER DEFAULT 0,
+		height INTEGER DEFAULT 0,
+		size INTEGER DEFAULT 0,
+		taken_at DATETIME NOT NULL,
+		exif_date INTEGER DEFAULT 0,
+		camera TEXT,
+		latitude REAL,
+		longitude REAL,
+		thumbnail_path TEXT,
+		file_mtime INTEGER,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_photos_taken ON photos(library_id, taken_at);
+	CREATE INDEX IF NOT EXISTS idx_photos_album ON photos(library_id, album);
+
+	CREATE TABLE IF NOT EXISTS notification_providers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		config TEXT NOT NULL DEFAULT '{}',
+		events TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Devices clients register when they sign in
+	CREATE TABLE IF NOT EXISTS devices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		identifier TEXT NOT NULL,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		max_bitrate INTEGER,
+		preferred_player TEXT,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		last_ip TEXT,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, identifier)
+	);
+
+	-- Destructive operations waiting for a second admin
+	CREATE TABLE IF NOT EXISTS pending_operations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		summary TEXT NOT NULL DEFAULT '',
+		params TEXT NOT NULL DEFAULT '{}',
+		payload BLOB,
+		status TEXT NOT NULL DEFAULT 'pending',
+		requested_by INTEGER NOT NULL,
+		decided_by INTEGER,
+		result TEXT,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		decided_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_pending_operations_status ON pending_operations(status);
+
+	-- API keys for scripts and other services, stored hashed
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		key_prefix TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		last_used_ip TEXT,
+		request_count INTEGER NOT NULL DEFAULT 0,
+		revoked_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+
+	-- Deleted movie and episode files kept in the trash until it's emptied
+	CREATE TABLE IF NOT EXISTS trash_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		original_path TEXT NOT NULL,
+		trash_path TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		record TEXT,
+		deleted_by INTEGER,
+		deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_trash_items_deleted ON trash_items(deleted_at);
+
+	-- Tags admins put on movies and shows
+	CREATE TABLE IF NOT EXISTS media_tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		tag TEXT NOT NULL COLLATE NOCASE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(media_type, media_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_tags_tag ON media_tags(tag);
+
+	-- Background jobs and how they went
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		progress INTEGER NOT NULL DEFAULT 0,
+		current INTEGER NOT NULL DEFAULT 0,
+		total INTEGER NOT NULL DEFAULT 0,
+		message TEXT,
+		error TEXT,
+		result TEXT,
+		created_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+
+	-- Airing shows followed week by week: new episodes are searched for
+	-- soon after they air and their followers told once they're in
+	CREATE TABLE IF NOT EXISTS airing_shows (
+		show_id INTEGER PRIMARY KEY,
+		air_time TEXT NOT NULL,
+		created_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS airing_followers (
+		show_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (show_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS airing_searches (
+		show_id INTEGER NOT NULL,
+		season_number INTEGER NOT NULL,
+		episode_number INTEGER NOT NULL,
+		air_at DATETIME NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_search_at DATETIME NOT NULL,
+		last_searched_at DATETIME,
+		grabbed_release TEXT,
+		available_at DATETIME,
+		PRIMARY KEY (show_id, season_number, episode_number)
+	);
+
+	-- Shows and people a profile follows, to be told of new episodes and
+	-- movies
+	CREATE TABLE IF NOT EXISTS follows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		tmdb_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		image_path TEXT,
+		since_id INTEGER NOT NULL DEFAULT 0,
+		releases_checked_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(profile_id, kind, tmdb_id)
+	);
+
+	-- What a follow has already been told about
+	CREATE TABLE IF NOT EXISTS follow_notices (
+		follow_id INTEGER NOT NULL REFERENCES follows(id) ON DELETE CASCADE,
+		item TEXT NOT NULL,
+		notified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (follow_id, item)
+	);
+
+	-- Trickplay storyboards of movies and episodes, and files that failed
+	CREATE TABLE IF NOT EXISTS trickplay (
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		interval_seconds INTEGER NOT NULL,
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		tile_columns INTEGER NOT NULL DEFAULT 0,
+		tile_rows INTEGER NOT NULL DEFAULT 0,
+		thumbnails INTEGER NOT NULL DEFAULT 0,
+		sheets INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (media_type, media_id)
+	);
+
+	-- Release groups and titles never to grab for one movie or show
+	CREATE TABLE IF NOT EXISTS release_exclusions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		tmdb_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		value TEXT NOT NULL,
+		is_regex INTEGER DEFAULT 0,
+		reason TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(media_type, tmdb_id, kind, value)
+	);
+
+	-- Movie and episode files whose embedded subtitles have been extracted
+	CREATE TABLE IF NOT EXISTS subtitle_extractions (
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		tracks INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		extracted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (media_type, media_id)
+	);
+
+	-- Subtitle languages wanted for libraries, downloaded from OpenSubtitles
+	CREATE TABLE IF NOT EXISTS subtitle_profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		languages TEXT NOT NULL,
+		hearing_impaired TEXT NOT NULL DEFAULT 'any',
+		forced_only INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Where the automatic subtitle search is with each movie and episode file
+	CREATE TABLE IF NOT EXISTS subtitle_searches (
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		status TEXT NOT NULL,
+		missing TEXT,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		searched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		next_search_at DATETIME,
+		PRIMARY KEY (media_type, media_id)
+	);
+
+	-- Subtitles the automatic subtitle search downloaded or couldn't find
+	CREATE TABLE IF NOT EXISTS subtitle_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		language TEXT NOT NULL,
+		action TEXT NOT NULL,
+		release TEXT,
+		path TEXT,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_subtitle_history_media ON subtitle_history(media_type, media_id);
+
+	-- Subtitles the automatic subtitle search downloaded and how well they
+	-- scored, for upgrading
+	CREATE TABLE IF NOT EXISTS subtitle_files (
+		media_type TEXT NOT NULL,
+		media_id INTEGER NOT NULL,
+		language TEXT NOT NULL,
+		forced INTEGER NOT NULL DEFAULT 0,
+		video_path TEXT NOT NULL,
+		path TEXT NOT NULL,
+		file_id INTEGER NOT NULL,
+		release TEXT,
+		score INTEGER NOT NULL,
+		downloaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checked_at DATETIME,
+		PRIMARY KEY (media_type, media_id, language, forced)
+	);
 	`
 	_, err := d.db.Exec(schema)
 	if err != nil {
@@ -1682,6 +2437,14 @@ func (d *Database) migrate() error {
 		"ALTER TABLE quality_presets ADD COLUMN prefer_dual_audio INTEGER DEFAULT 0",
 		"ALTER TABLE quality_presets ADD COLUMN prefer_dubbed INTEGER DEFAULT 0",
 		"ALTER TABLE quality_presets ADD COLUMN preferred_language TEXT DEFAULT 'any'",
+		// Size and bitrate limits for presets
+		"ALTER TABLE quality_presets ADD COLUMN min_size_per_hour INTEGER DEFAULT 0",
+		"ALTER TABLE quality_presets ADD COLUMN max_size_per_hour INTEGER DEFAULT 0",
+		"ALTER TABLE quality_presets ADD COLUMN min_bitrate_kbps INTEGER DEFAULT 0",
+		"ALTER TABLE quality_presets ADD COLUMN max_bitrate_kbps INTEGER DEFAULT 0",
+		// Release age thresholds for presets
+		"ALTER TABLE quality_presets ADD COLUMN max_age_days INTEGER DEFAULT 0",
+		"ALTER TABLE quality_presets ADD COLUMN first_seen_wait_hours INTEGER DEFAULT 0",
 		// Per-season monitoring
 		"ALTER TABLE media_quality_override ADD COLUMN monitored_seasons TEXT DEFAULT ''",
 		// Audio/subtitle preferences for shows
@@ -1723,6 +2486,101 @@ func (d *Database) migrate() error {
 		"ALTER TABLE indexers ADD COLUMN content_types TEXT DEFAULT ''",
 		// Season selection for TV show requests
 		"ALTER TABLE requests ADD COLUMN seasons TEXT",
+		// Smart collections (rule-based, powered by the smart playlist engine)
+		"ALTER TABLE collections ADD COLUMN is_smart INTEGER DEFAULT 0",
+		"ALTER TABLE collections ADD COLUMN rules TEXT",
+		"ALTER TABLE collections ADD COLUMN media_type TEXT DEFAULT 'both'",
+		// Admin impersonation sessions
+		"ALTER TABLE sessions ADD COLUMN impersonator_id INTEGER",
+		// Email address for self-service password resets
+		"ALTER TABLE users ADD COLUMN email TEXT",
+		// Per-user library access and request quotas (presettable via invites)
+		"ALTER TABLE users ADD COLUMN library_ids TEXT",
+		"ALTER TABLE users ADD COLUMN request_quota INTEGER",
+		"ALTER TABLE users ADD COLUMN request_quota_days INTEGER DEFAULT 7",
+		// Downloads added to clients outside of Outpost (manual import queue)
+		"ALTER TABLE tracked_downloads ADD COLUMN external INTEGER DEFAULT 0",
+		// Per-file import steps and structured import errors
+		"ALTER TABLE tracked_downloads ADD COLUMN import_steps TEXT",
+		"ALTER TABLE tracked_downloads ADD COLUMN import_error TEXT",
+		"ALTER TABLE tracked_downloads ADD COLUMN import_retry TEXT",
+		// MusicBrainz metadata of music libraries
+		"ALTER TABLE albums ADD COLUMN release_group_id TEXT",
+		"ALTER TABLE albums ADD COLUMN release_type TEXT",
+		"ALTER TABLE albums ADD COLUMN metadata_checked_at DATETIME",
+		"ALTER TABLE artists ADD COLUMN metadata_checked_at DATETIME",
+		// Request fulfillment ETAs, kept up to date by the acquisition service
+		"ALTER TABLE requests ADD COLUMN eta_status TEXT",
+		"ALTER TABLE requests ADD COLUMN eta_message TEXT",
+		"ALTER TABLE requests ADD COLUMN eta_at DATETIME",
+		"ALTER TABLE requests ADD COLUMN download_progress REAL",
+		"ALTER TABLE requests ADD COLUMN eta_updated_at DATETIME",
+		// Per-profile overrides of the played threshold
+		"ALTER TABLE profiles ADD COLUMN played_threshold INTEGER",
+		"ALTER TABLE profiles ADD COLUMN played_credits INTEGER",
+		// Per-library metadata provider order
+		"ALTER TABLE libraries ADD COLUMN metadata_providers TEXT DEFAULT ''",
+		// Per-library artwork style, and the artwork picked for it
+		"ALTER TABLE libraries ADD COLUMN artwork_style TEXT DEFAULT 'poster'",
+		"ALTER TABLE libraries ADD COLUMN artwork_text TEXT DEFAULT 'any'",
+		"ALTER TABLE movies ADD COLUMN artwork_path TEXT",
+		"ALTER TABLE shows ADD COLUMN artwork_path TEXT",
+		// Denial reason picked for a request
+		"ALTER TABLE requests ADD COLUMN status_reason_id INTEGER",
+		// Denied requests to reopen once the title is released
+		"ALTER TABLE request_reasons ADD COLUMN recheck_after_release INTEGER DEFAULT 0",
+		"ALTER TABLE requests ADD COLUMN recheck_after_release INTEGER DEFAULT 0",
+		// Users who may only sign in from the local network
+		"ALTER TABLE users ADD COLUMN lan_only INTEGER DEFAULT 0",
+		// Household member who asked, for requests from the request portal
+		"ALTER TABLE requests ADD COLUMN requester_name TEXT",
+		// Expiring accounts, such as guests, and accounts disabled at expiry
+		"ALTER TABLE users ADD COLUMN expires_at DATETIME",
+		"ALTER TABLE users ADD COLUMN disabled INTEGER DEFAULT 0",
+		// Accounts deleted by anonymizing them, hidden from the user list
+		"ALTER TABLE users ADD COLUMN deleted_at DATETIME",
+		// Language for a user's notifications and messages
+		"ALTER TABLE users ADD COLUMN language TEXT",
+		// Time zone a user's calendar and dates are shown in
+		"ALTER TABLE users ADD COLUMN timezone TEXT",
+		// Dominant and accent colors of a movie's or show's poster and backdrop
+		"ALTER TABLE movies ADD COLUMN colors TEXT",
+		"ALTER TABLE shows ADD COLUMN colors TEXT",
+		// Genre and tempo tags of music tracks, for instant mixes
+		"ALTER TABLE tracks ADD COLUMN genre TEXT",
+		"ALTER TABLE tracks ADD COLUMN bpm INTEGER",
+		// Series, issue and page count of comic archives
+		"ALTER TABLE books ADD COLUMN series TEXT",
+		"ALTER TABLE books ADD COLUMN issue_number TEXT",
+		"ALTER TABLE books ADD COLUMN page_count INTEGER",
+		// Device a session or stream came from
+		"ALTER TABLE sessions ADD COLUMN device_id INTEGER",
+		"ALTER TABLE stream_sessions ADD COLUMN device_id INTEGER",
+		// Throttled scanning of libraries on network storage
+		"ALTER TABLE libraries ADD COLUMN scan_throttle INTEGER DEFAULT 0",
+		// Modification times of library files, for scans to skip unchanged ones
+		"ALTER TABLE movies ADD COLUMN file_mtime INTEGER",
+		"ALTER TABLE episodes ADD COLUMN file_mtime INTEGER",
+		// Movie editions (Director's Cut, Extended, ...) of files, and asked for
+		"ALTER TABLE movies ADD COLUMN edition TEXT",
+		"ALTER TABLE media_versions ADD COLUMN edition TEXT",
+		"ALTER TABLE wanted ADD COLUMN edition TEXT",
+		"ALTER TABLE requests ADD COLUMN edition TEXT",
+		// Profiles sharing their watches in the household activity feed
+		"ALTER TABLE profiles ADD COLUMN share_activity INTEGER DEFAULT 0",
+		// Subtitle language profile of a library
+		"ALTER TABLE libraries ADD COLUMN subtitle_profile_id INTEGER",
+		// Scores of subtitles in the subtitle history
+		"ALTER TABLE subtitle_history ADD COLUMN score INTEGER",
+		// Priority of requests and wanted items
+		"ALTER TABLE wanted ADD COLUMN priority TEXT DEFAULT 'normal'",
+		"ALTER TABLE requests ADD COLUMN priority TEXT DEFAULT 'normal'",
+		// Profile a stream was played on
+		"ALTER TABLE stream_sessions ADD COLUMN profile_id INTEGER",
+		// Library and profile of journaled changes, for sending each viewer
+		// only their own
+		"ALTER TABLE change_journal ADD COLUMN library_id INTEGER",
+		"ALTER TABLE change_journal ADD COLUMN profile_id INTEGER",
 	}
 	for _, m := range migrations {
 		// Ignore errors (column may already exist)
@@ -1820,6 +2678,16 @@ func (d *Database) migrate() error {
 		}
 	}
 
+	// Seed default request denial reasons if none exist
+	var reasonCount int
+	d.db.QueryRow("SELECT COUNT(*) FROM request_reasons").Scan(&reasonCount)
+	if reasonCount == 0 {
+		for i, label := range []string{"Not released yet", "Available on Netflix", "Quota exceeded"} {
+			d.db.Exec(`INSERT INTO request_reasons (label, message, recheck_after_release, sort_order) VALUES (?, ?, ?, ?)`,
+				label, label, i == 0, i)
+		}
+	}
+
 	// Seed default quality profiles if none exist
 	var profileCount int
 	d.db.QueryRow("SELECT COUNT(*) FROM quality_profiles").Scan(&profileCount)
@@ -1840,6 +2708,8 @@ func (d *Database) migrate() error {
 		"scheduler_auto_grab":            "true",
 		"scheduler_rss_enabled":          "true",
 		"scheduler_min_score":            "0",
+		"request_recheck_policy":         "reopen",
+		"bandwidth_monthly_cap_gb":       "0",
 		"storage_pause_enabled":          "false",
 		"storage_threshold_gb":           "50",
 		"upgrade_search_enabled":         "false",
@@ -1850,6 +2720,71 @@ func (d *Database) migrate() error {
 		"opensubtitles_languages":        "en",
 		"opensubtitles_auto_download":    "false",
 		"opensubtitles_hearing_impaired": "include",
+		"subtitle_upgrade_days":          "7",
+		"smtp_host":                      "",
+		"smtp_port":                      "587",
+		"smtp_username":                  "",
+		"smtp_password":                  "",
+		"smtp_from":                      "",
+		"public_url":                     "",
+		"bandwidth_normal_download_kbps": "0",
+		"bandwidth_normal_upload_kbps":   "0",
+		"stale_swap_enabled":             "false",
+		"stale_min_speed_kbps":           "50",
+		"stale_hours":                    "6",
+		"change_journal_retention_days":  "30",
+		"security_notify_failed_logins":  "true",
+		"security_notify_new_device":     "true",
+		"security_notify_api_key":        "true",
+		"security_notify_settings":       "true",
+		"security_login_failure_limit":   "5",
+		"security_webhook_url":           "",
+		"portal_enabled":                 "false",
+		"portal_passcode":                "",
+		"portal_user_id":                 "",
+		"portal_requests_per_hour":       "5",
+		"language":                       "en",
+		"timezone":                       "",
+		"home_recently_added_days":       "30",
+		"home_recently_added_limit":      "20",
+		"home_excluded_libraries":        "",
+		"screensaver_refresh_minutes":    "10",
+		"transcode_encoder":              "auto",
+		"transcode_max_sessions":         "4",
+		"transcode_max_cpu":              "90",
+		"transcode_max_memory":           "95",
+		"transcode_max_gpu":              "95",
+		"transcode_busy_wait_seconds":    "10",
+		"podcast_auto_download":          "true",
+		"podcast_keep_episodes":          "5",
+		"storage_images":                 "",
+		"storage_backups":                "",
+		"four_eyes_enabled":              "false",
+		"four_eyes_window_minutes":       "30",
+		"arr_api_enabled":                "false",
+		"arr_api_key":                    "",
+		"import_file_mode":               "",
+		"import_folder_mode":             "",
+		"import_owner":                   "",
+		"import_group":                   "",
+		"import_staging_path":            "",
+		"import_virus_scan":              "false",
+		"import_retry_max_attempts":      "5",
+		"musicbrainz_enabled":            "true",
+		"acoustid_api_key":               "",
+		"lyrics_enabled":                 "true",
+		"trailer_resolver":               "off",
+		"search_query_fallbacks":         "original_title,alternate_title,stripped_punctuation,year_tolerance,romanized_title",
+		"search_fallback_min_results":    "1",
+		"trash_path":                     "",
+		"trash_retention_days":           "30",
+		"scan_throttle_max_io":           "1",
+		"scan_throttle_sleep_ms":         "250",
+		"scan_throttle_skip_unchanged":   "true",
+		"scan_workers":                   "4",
+		"metadata_languages":             "en",
+		"library_watch":                  "true",
+		"airing_search_delay":            "30",
 	}
 	for key, value := range defaultSettings {
 		d.db.Exec(`INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)`, key, value)
@@ -1871,6 +2806,9 @@ func (d *Database) migrate() error {
 		d.db.Exec(`UPDATE progress SET profile_id = ? WHERE profile_id IS NULL`, defaultProfileID)
 	}
 
+	// Journal changes to library rows for delta sync
+	d.setupChangeJournal()
+
 	// Create built-in smart playlists if they don't exist
 	d.CreateBuiltInSmartPlaylists()
 
@@ -1880,9 +2818,10 @@ func (d *Database) migrate() error {
 // Library operations
 
 func (d *Database) CreateLibrary(lib *Library) error {
+	lib.defaultArtwork()
 	result, err := d.db.Exec(
-		"INSERT INTO libraries (name, path, type, scan_interval) VALUES (?, ?, ?, ?)",
-		lib.Name, lib.Path, lib.Type, lib.ScanInterval,
+		"INSERT INTO libraries (name, path, type, scan_interval, metadata_providers, artwork_style, artwork_text, scan_throttle) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		lib.Name, lib.Path, lib.Type, lib.ScanInterval, strings.Join(lib.MetadataProviders, ","), lib.ArtworkStyle, lib.ArtworkText, lib.ScanThrottle,
 	)
 	if err != nil {
 		return err
@@ -1892,7 +2831,9 @@ func (d *Database) CreateLibrary(lib *Library) error {
 }
 
 func (d *Database) GetLibraries() ([]Library, error) {
-	rows, err := d.db.Query("SELECT id, name, path, type, scan_interval FROM libraries")
+	rows, err := d.db.Query(`SELECT id, name, path, type, scan_interval, COALESCE(metadata_providers, ''),
+		COALESCE(artwork_style, 'poster'), COALESCE(artwork_text, 'any'), COALESCE(scan_throttle, 0),
+		subtitle_profile_id FROM libraries`)
 	if err != nil {
 		return nil, err
 	}
@@ -1901,9 +2842,12 @@ func (d *Database) GetLibraries() ([]Library, error) {
 	var libraries []Library
 	for rows.Next() {
 		var lib Library
-		if err := rows.Scan(&lib.ID, &lib.Name, &lib.Path, &lib.Type, &lib.ScanInterval); err != nil {
+		var providers string
+		if err := rows.Scan(&lib.ID, &lib.Name, &lib.Path, &lib.Type, &lib.ScanInterval, &providers,
+			&lib.ArtworkStyle, &lib.ArtworkText, &lib.ScanThrottle, &lib.SubtitleProfileID); err != nil {
 			return nil, err
 		}
+		lib.MetadataProviders = splitProviders(providers)
 		libraries = append(libraries, lib)
 	}
 	return libraries, nil
@@ -1911,15 +2855,59 @@ func (d *Database) GetLibraries() ([]Library, error) {
 
 func (d *Database) GetLibrary(id int64) (*Library, error) {
 	var lib Library
+	var providers string
 	err := d.db.QueryRow(
-		"SELECT id, name, path, type, scan_interval FROM libraries WHERE id = ?", id,
-	).Scan(&lib.ID, &lib.Name, &lib.Path, &lib.Type, &lib.ScanInterval)
+		`SELECT id, name, path, type, scan_interval, COALESCE(metadata_providers, ''),
+			COALESCE(artwork_style, 'poster'), COALESCE(artwork_text, 'any'), COALESCE(scan_throttle, 0),
+			subtitle_profile_id FROM libraries WHERE id = ?`, id,
+	).Scan(&lib.ID, &lib.Name, &lib.Path, &lib.Type, &lib.ScanInterval, &providers, &lib.ArtworkStyle, &lib.ArtworkText, &lib.ScanThrottle, &lib.SubtitleProfileID)
 	if err != nil {
 		return nil, err
 	}
+	lib.MetadataProviders = splitProviders(providers)
 	return &lib, nil
 }
 
+// UpdateLibraryMetadataProviders sets the order metadata providers are asked
+// in for a library. An empty list goes back to the default order.
+func (d *Database) UpdateLibraryMetadataProviders(id int64, providers []string) error {
+	_, err := d.db.Exec("UPDATE libraries SET metadata_providers = ? WHERE id = ?", strings.Join(providers, ","), id)
+	return err
+}
+
+// UpdateLibraryArtwork sets the artwork style of a library
+func (d *Database) UpdateLibraryArtwork(id int64, style, text string) error {
+	_, err := d.db.Exec("UPDATE libraries SET artwork_style = ?, artwork_text = ? WHERE id = ?", style, text, id)
+	return err
+}
+
+// UpdateLibraryScanThrottle sets whether a library is scanned with the
+// scan throttle
+func (d *Database) UpdateLibraryScanThrottle(id int64, throttled bool) error {
+	_, err := d.db.Exec("UPDATE libraries SET scan_throttle = ? WHERE id = ?", throttled, id)
+	return err
+}
+
+// UpdateLibrarySubtitleProfile sets the subtitle profile of a library, nil
+// for none, and has the library's files searched for it again
+func (d *Database) UpdateLibrarySubtitleProfile(id int64, profileID *int64) error {
+	if _, err := d.db.Exec("UPDATE libraries SET subtitle_profile_id = ? WHERE id = ?", profileID, id); err != nil {
+		return err
+	}
+	return d.resetLibrarySubtitleSearches(id)
+}
+
+// splitProviders parses a stored comma-separated provider list
+func splitProviders(value string) []string {
+	providers := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
+
 func (d *Database) DeleteLibrary(id int64) error {
 	_, err := d.db.Exec("DELETE FROM libraries WHERE id = ?", id)
 	return err
@@ -1927,26 +2915,33 @@ func (d *Database) DeleteLibrary(id int64) error {
 
 // ClearAllLibraryData removes all movies, shows, seasons, and episodes but keeps library definitions
 func (d *Database) ClearAllLibraryData() error {
-	// Delete in order to respect foreign key constraints
-	if _, err := d.db.Exec("DELETE FROM episodes"); err != nil {
-		return err
-	}
-	if _, err := d.db.Exec("DELETE FROM seasons"); err != nil {
-		return err
-	}
-	if _, err := d.db.Exec("DELETE FROM shows"); err != nil {
-		return err
-	}
-	if _, err := d.db.Exec("DELETE FROM movies"); err != nil {
-		return err
-	}
-	// Also clear progress and continue watching
-	if _, err := d.db.Exec("DELETE FROM progress"); err != nil {
-		return err
+	for _, table := range libraryDataTables {
+		if _, err := d.db.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// libraryDataTables are the tables ClearAllLibraryData empties, in order to
+// respect foreign key constraints. Progress goes too, so continue watching
+// is cleared.
+var libraryDataTables = []string{"episodes", "seasons", "shows", "movies", "media_versions", "progress"}
+
+// CountLibraryData returns how many rows ClearAllLibraryData would delete
+// from each table
+func (d *Database) CountLibraryData() (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, table := range libraryDataTables {
+		var n int
+		if err := d.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			return nil, err
+		}
+		counts[table] = n
+	}
+	return counts, nil
+}
+
 // Settings operations
 
 func (d *Database) GetSetting(key string) (string, error) {
@@ -1989,6 +2984,9 @@ type FormatSettings struct {
 	AcceptedContainers []string `json:"acceptedContainers"` // e.g., ["mkv", "mp4", "avi"]
 	RejectedKeywords   []string `json:"rejectedKeywords"`   // Keywords to reject (e.g., "bdmv", "rar", "cam")
 	AutoBlocklist      bool     `json:"autoBlocklist"`      // Add rejected releases to blocklist
+	// Video codecs staged imports may have, as ffprobe names them (e.g.,
+	// "h264", "hevc"); empty accepts any
+	AcceptedVideoCodecs []string `json:"acceptedVideoCodecs"`
 }
 
 // DefaultFormatSettings returns sensible defaults
@@ -2694,21 +3692,22 @@ func (d *Database) DeleteCustomFormat(id int64) error {
 
 func (d *Database) CreateWantedItem(item *WantedItem) error {
 	result, err := d.db.Exec(`
-		INSERT INTO wanted (type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO wanted (type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, edition, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		item.Type, item.TmdbID, item.ImdbID, item.Title, item.Year, item.PosterPath,
-		item.QualityProfileID, item.QualityPresetID, item.Monitored, item.Seasons,
+		item.QualityProfileID, item.QualityPresetID, item.Monitored, item.Seasons, item.Edition, NormalizePriority(item.Priority),
 	)
 	if err != nil {
 		return err
 	}
 	item.ID, _ = result.LastInsertId()
+	item.Priority = NormalizePriority(item.Priority)
 	return nil
 }
 
 func (d *Database) GetWantedItems() ([]WantedItem, error) {
 	rows, err := d.db.Query(`
-		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at
+		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at, edition, COALESCE(priority, 'normal')
 		FROM wanted ORDER BY added_at DESC`)
 	if err != nil {
 		return nil, err
@@ -2720,7 +3719,7 @@ func (d *Database) GetWantedItems() ([]WantedItem, error) {
 		var item WantedItem
 		if err := rows.Scan(&item.ID, &item.Type, &item.TmdbID, &item.ImdbID, &item.Title, &item.Year,
 			&item.PosterPath, &item.QualityProfileID, &item.QualityPresetID, &item.Monitored, &item.Seasons,
-			&item.LastSearched, &item.AddedAt); err != nil {
+			&item.LastSearched, &item.AddedAt, &item.Edition, &item.Priority); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -2731,11 +3730,11 @@ func (d *Database) GetWantedItems() ([]WantedItem, error) {
 func (d *Database) GetWantedItem(id int64) (*WantedItem, error) {
 	var item WantedItem
 	err := d.db.QueryRow(`
-		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at
+		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at, edition, COALESCE(priority, 'normal')
 		FROM wanted WHERE id = ?`, id,
 	).Scan(&item.ID, &item.Type, &item.TmdbID, &item.ImdbID, &item.Title, &item.Year,
 		&item.PosterPath, &item.QualityProfileID, &item.QualityPresetID, &item.Monitored, &item.Seasons,
-		&item.LastSearched, &item.AddedAt)
+		&item.LastSearched, &item.AddedAt, &item.Edition, &item.Priority)
 	if err != nil {
 		return nil, err
 	}
@@ -2746,11 +3745,11 @@ func (d *Database) GetWantedByTmdb(itemType string, tmdbID int64) (*WantedItem,
 	var item WantedItem
 	err := d.db.QueryRow(`
 		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at,
-		       COALESCE(is_upgrade, 0), existing_media_id, COALESCE(current_score, 0)
+		       COALESCE(is_upgrade, 0), existing_media_id, COALESCE(current_score, 0), edition, COALESCE(priority, 'normal')
 		FROM wanted WHERE type = ? AND tmdb_id = ?`, itemType, tmdbID,
 	).Scan(&item.ID, &item.Type, &item.TmdbID, &item.ImdbID, &item.Title, &item.Year,
 		&item.PosterPath, &item.QualityProfileID, &item.QualityPresetID, &item.Monitored, &item.Seasons,
-		&item.LastSearched, &item.AddedAt, &item.IsUpgrade, &item.ExistingMediaID, &item.CurrentScore)
+		&item.LastSearched, &item.AddedAt, &item.IsUpgrade, &item.ExistingMediaID, &item.CurrentScore, &item.Edition, &item.Priority)
 	if err != nil {
 		return nil, err
 	}
@@ -2760,8 +3759,8 @@ func (d *Database) GetWantedByTmdb(itemType string, tmdbID int64) (*WantedItem,
 func (d *Database) GetMonitoredItems() ([]WantedItem, error) {
 	rows, err := d.db.Query(`
 		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id, monitored, seasons, last_searched, added_at,
-		       COALESCE(is_upgrade, 0), existing_media_id, COALESCE(current_score, 0)
-		FROM wanted WHERE monitored = 1 ORDER BY added_at DESC`)
+		       COALESCE(is_upgrade, 0), existing_media_id, COALESCE(current_score, 0), edition, COALESCE(priority, 'normal')
+		FROM wanted WHERE monitored = 1 ORDER BY `+priorityOrder+`, added_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -2772,7 +3771,7 @@ func (d *Database) GetMonitoredItems() ([]WantedItem, error) {
 		var item WantedItem
 		if err := rows.Scan(&item.ID, &item.Type, &item.TmdbID, &item.ImdbID, &item.Title, &item.Year,
 			&item.PosterPath, &item.QualityProfileID, &item.QualityPresetID, &item.Monitored, &item.Seasons,
-			&item.LastSearched, &item.AddedAt, &item.IsUpgrade, &item.ExistingMediaID, &item.CurrentScore); err != nil {
+			&item.LastSearched, &item.AddedAt, &item.IsUpgrade, &item.ExistingMediaID, &item.CurrentScore, &item.Edition, &item.Priority); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -2783,9 +3782,9 @@ func (d *Database) GetMonitoredItems() ([]WantedItem, error) {
 func (d *Database) UpdateWantedItem(item *WantedItem) error {
 	_, err := d.db.Exec(`
 		UPDATE wanted SET
-			quality_profile_id = ?, quality_preset_id = ?, monitored = ?, seasons = ?
+			quality_profile_id = ?, quality_preset_id = ?, monitored = ?, seasons = ?, edition = ?, priority = ?
 		WHERE id = ?`,
-		item.QualityProfileID, item.QualityPresetID, item.Monitored, item.Seasons, item.ID,
+		item.QualityProfileID, item.QualityPresetID, item.Monitored, item.Seasons, item.Edition, NormalizePriority(item.Priority), item.ID,
 	)
 	return err
 }
@@ -2810,15 +3809,16 @@ func (d *Database) DeleteWantedByTmdb(itemType string, tmdbID int64) error {
 
 func (d *Database) CreateRequest(req *Request) error {
 	result, err := d.db.Exec(`
-		INSERT INTO requests (user_id, type, tmdb_id, title, year, overview, poster_path, backdrop_path, quality_profile_id, quality_preset_id, seasons, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		req.UserID, req.Type, req.TmdbID, req.Title, req.Year, req.Overview, req.PosterPath, req.BackdropPath, req.QualityProfileID, req.QualityPresetID, req.Seasons, "requested",
+		INSERT INTO requests (user_id, type, tmdb_id, title, year, overview, poster_path, backdrop_path, quality_profile_id, quality_preset_id, seasons, status, requester_name, edition, priority)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		req.UserID, req.Type, req.TmdbID, req.Title, req.Year, req.Overview, req.PosterPath, req.BackdropPath, req.QualityProfileID, req.QualityPresetID, req.Seasons, "requested", req.RequesterName, req.Edition, NormalizePriority(req.Priority),
 	)
 	if err != nil {
 		return err
 	}
 	req.ID, _ = result.LastInsertId()
 	req.Status = "requested"
+	req.Priority = NormalizePriority(req.Priority)
 	req.RequestedAt = time.Now()
 	req.UpdatedAt = time.Now()
 	return nil
@@ -2827,7 +3827,8 @@ func (d *Database) CreateRequest(req *Request) error {
 func (d *Database) GetRequests() ([]Request, error) {
 	rows, err := d.db.Query(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.status != 'denied'
@@ -2842,7 +3843,8 @@ func (d *Database) GetRequests() ([]Request, error) {
 		var req Request
 		if err := rows.Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID, &req.Title,
 			&req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-			&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt); err != nil {
+			&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+			&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority); err != nil {
 			return nil, err
 		}
 		requests = append(requests, req)
@@ -2853,7 +3855,8 @@ func (d *Database) GetRequests() ([]Request, error) {
 func (d *Database) GetRequestsByUser(userID int64) ([]Request, error) {
 	rows, err := d.db.Query(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.user_id = ? AND r.status != 'denied'
@@ -2868,7 +3871,8 @@ func (d *Database) GetRequestsByUser(userID int64) ([]Request, error) {
 		var req Request
 		if err := rows.Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID, &req.Title,
 			&req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-			&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt); err != nil {
+			&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+			&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority); err != nil {
 			return nil, err
 		}
 		requests = append(requests, req)
@@ -2879,7 +3883,8 @@ func (d *Database) GetRequestsByUser(userID int64) ([]Request, error) {
 func (d *Database) GetRequestsByStatus(status string) ([]Request, error) {
 	rows, err := d.db.Query(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.status = ?
@@ -2894,7 +3899,8 @@ func (d *Database) GetRequestsByStatus(status string) ([]Request, error) {
 		var req Request
 		if err := rows.Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID, &req.Title,
 			&req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-			&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt); err != nil {
+			&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+			&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority); err != nil {
 			return nil, err
 		}
 		requests = append(requests, req)
@@ -2906,47 +3912,74 @@ func (d *Database) GetRequest(id int64) (*Request, error) {
 	var req Request
 	err := d.db.QueryRow(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.id = ?`, id).Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID,
 		&req.Title, &req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-		&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt)
+		&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+		&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority)
 	if err != nil {
 		return nil, err
 	}
 	return &req, nil
 }
 
+// CountRecentRequests returns how many requests a user has made in the last N days
+func (d *Database) CountRecentRequests(userID int64, days int) (int, error) {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM requests
+		WHERE user_id = ? AND requested_at >= datetime('now', '-' || ? || ' days')`,
+		userID, days,
+	).Scan(&count)
+	return count, err
+}
+
 func (d *Database) GetRequestByTmdb(userID int64, mediaType string, tmdbID int64) (*Request, error) {
 	var req Request
 	// Exclude denied requests so users can re-request
 	err := d.db.QueryRow(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.user_id = ? AND r.type = ? AND r.tmdb_id = ? AND r.status != 'denied'`,
 		userID, mediaType, tmdbID).Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID,
 		&req.Title, &req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-		&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt)
+		&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+		&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority)
 	if err != nil {
 		return nil, err
 	}
 	return &req, nil
 }
 
+// HasOpenRequest reports whether anyone has a request for a title that
+// hasn't been denied
+func (d *Database) HasOpenRequest(mediaType string, tmdbID int64) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM requests WHERE type = ? AND tmdb_id = ? AND status != 'denied'`,
+		mediaType, tmdbID).Scan(&count)
+	return count > 0, err
+}
+
 func (d *Database) GetDeniedRequestByTmdb(userID int64, mediaType string, tmdbID int64) (*Request, error) {
 	var req Request
 	err := d.db.QueryRow(`
 		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
-		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.requested_at, r.updated_at
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
 		FROM requests r
 		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.user_id = ? AND r.type = ? AND r.tmdb_id = ? AND r.status = 'denied'`,
 		userID, mediaType, tmdbID).Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID,
 		&req.Title, &req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
-		&req.Seasons, &req.Status, &req.StatusReason, &req.RequestedAt, &req.UpdatedAt)
+		&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+		&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority)
 	if err != nil {
 		return nil, err
 	}
@@ -2954,10 +3987,17 @@ func (d *Database) GetDeniedRequestByTmdb(userID int64, mediaType string, tmdbID
 }
 
 func (d *Database) UpdateRequestStatus(id int64, status string, reason *string) error {
+	return d.UpdateRequestStatusWithReason(id, status, reason, nil, false)
+}
+
+// UpdateRequestStatusWithReason updates a request's status along with the
+// denial reason it was given, and whether to recheck it once the title is
+// released
+func (d *Database) UpdateRequestStatusWithReason(id int64, status string, reason *string, reasonID *int64, recheck bool) error {
 	_, err := d.db.Exec(`
 		UPDATE requests
-		SET status = ?, status_reason = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?`, status, reason, id)
+		SET status = ?, status_reason = ?, status_reason_id = ?, recheck_after_release = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, status, reason, reasonID, recheck, id)
 	return err
 }
 
@@ -2970,6 +4010,25 @@ func (d *Database) UpdateRequestSeasons(id int64, seasons *string) error {
 	return err
 }
 
+// UpdateRequestPriority changes how soon a request is wanted
+func (d *Database) UpdateRequestPriority(id int64, priority string) error {
+	_, err := d.db.Exec(`
+		UPDATE requests
+		SET priority = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, NormalizePriority(priority), id)
+	return err
+}
+
+// UpdateRequestEdition updates the edition a request asks for (used when
+// reactivating a denied request)
+func (d *Database) UpdateRequestEdition(id int64, edition *string) error {
+	_, err := d.db.Exec(`
+		UPDATE requests
+		SET edition = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, edition, id)
+	return err
+}
+
 func (d *Database) DeleteRequest(id int64) error {
 	_, err := d.db.Exec("DELETE FROM requests WHERE id = ?", id)
 	return err
@@ -2997,9 +4056,12 @@ func (d *Database) CreateArtist(artist *Artist) error {
 	return nil
 }
 
+// UpdateArtistMetadata saves an artist's MusicBrainz metadata, recording
+// that it was looked up
 func (d *Database) UpdateArtistMetadata(artist *Artist) error {
 	_, err := d.db.Exec(`
-		UPDATE artists SET musicbrainz_id = ?, sort_name = ?, overview = ?, image_path = ?
+		UPDATE artists SET musicbrainz_id = ?, sort_name = ?, overview = ?, image_path = ?,
+			metadata_checked_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		artist.MusicBrainzID, artist.SortName, artist.Overview, artist.ImagePath, artist.ID,
 	)
@@ -3064,18 +4126,22 @@ func (d *Database) CreateAlbum(album *Album) error {
 	return nil
 }
 
+// UpdateAlbumMetadata saves an album's MusicBrainz metadata, recording that
+// it was looked up
 func (d *Database) UpdateAlbumMetadata(album *Album) error {
 	_, err := d.db.Exec(`
-		UPDATE albums SET musicbrainz_id = ?, overview = ?, cover_path = ?
+		UPDATE albums SET musicbrainz_id = ?, release_group_id = ?, release_type = ?, title = ?, year = ?,
+			overview = ?, cover_path = ?, metadata_checked_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
-		album.MusicBrainzID, album.Overview, album.CoverPath, album.ID,
+		album.MusicBrainzID, album.ReleaseGroupID, album.ReleaseType, album.Title, album.Year,
+		album.Overview, album.CoverPath, album.ID,
 	)
 	return err
 }
 
 func (d *Database) GetAlbumsByArtist(artistID int64) ([]Album, error) {
 	rows, err := d.db.Query(`
-		SELECT id, artist_id, musicbrainz_id, title, year, overview, cover_path, path
+		SELECT id, artist_id, musicbrainz_id, release_group_id, release_type, title, year, overview, cover_path, path
 		FROM albums WHERE artist_id = ? ORDER BY year, title`, artistID)
 	if err != nil {
 		return nil, err
@@ -3085,7 +4151,7 @@ func (d *Database) GetAlbumsByArtist(artistID int64) ([]Album, error) {
 	var albums []Album
 	for rows.Next() {
 		var a Album
-		if err := rows.Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path); err != nil {
+		if err := rows.Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.ReleaseGroupID, &a.ReleaseType, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path); err != nil {
 			return nil, err
 		}
 		albums = append(albums, a)
@@ -3095,7 +4161,7 @@ func (d *Database) GetAlbumsByArtist(artistID int64) ([]Album, error) {
 
 func (d *Database) GetAlbums() ([]Album, error) {
 	rows, err := d.db.Query(`
-		SELECT id, artist_id, musicbrainz_id, title, year, overview, cover_path, path
+		SELECT id, artist_id, musicbrainz_id, release_group_id, release_type, title, year, overview, cover_path, path
 		FROM albums ORDER BY year DESC, title`)
 	if err != nil {
 		return nil, err
@@ -3105,7 +4171,7 @@ func (d *Database) GetAlbums() ([]Album, error) {
 	var albums []Album
 	for rows.Next() {
 		var a Album
-		if err := rows.Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path); err != nil {
+		if err := rows.Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.ReleaseGroupID, &a.ReleaseType, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path); err != nil {
 			return nil, err
 		}
 		albums = append(albums, a)
@@ -3116,9 +4182,9 @@ func (d *Database) GetAlbums() ([]Album, error) {
 func (d *Database) GetAlbum(id int64) (*Album, error) {
 	var a Album
 	err := d.db.QueryRow(`
-		SELECT id, artist_id, musicbrainz_id, title, year, overview, cover_path, path
+		SELECT id, artist_id, musicbrainz_id, release_group_id, release_type, title, year, overview, cover_path, path
 		FROM albums WHERE id = ?`, id,
-	).Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path)
+	).Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.ReleaseGroupID, &a.ReleaseType, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -3128,9 +4194,9 @@ func (d *Database) GetAlbum(id int64) (*Album, error) {
 func (d *Database) GetAlbumByPath(path string) (*Album, error) {
 	var a Album
 	err := d.db.QueryRow(`
-		SELECT id, artist_id, musicbrainz_id, title, year, overview, cover_path, path
+		SELECT id, artist_id, musicbrainz_id, release_group_id, release_type, title, year, overview, cover_path, path
 		FROM albums WHERE path = ?`, path,
-	).Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path)
+	).Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.ReleaseGroupID, &a.ReleaseType, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -3141,8 +4207,8 @@ func (d *Database) GetAlbumByPath(path string) (*Album, error) {
 
 func (d *Database) CreateTrack(track *Track) error {
 	result, err := d.db.Exec(
-		"INSERT INTO tracks (album_id, title, track_number, disc_number, duration, path, size) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		track.AlbumID, track.Title, track.TrackNumber, track.DiscNumber, track.Duration, track.Path, track.Size,
+		"INSERT INTO tracks (album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size, genre, bpm) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		track.AlbumID, track.MusicBrainzID, track.Title, track.TrackNumber, track.DiscNumber, track.Duration, track.Path, track.Size, track.Genre, track.BPM,
 	)
 	if err != nil {
 		return err
@@ -3153,7 +4219,7 @@ func (d *Database) CreateTrack(track *Track) error {
 
 func (d *Database) GetTracksByAlbum(albumID int64) ([]Track, error) {
 	rows, err := d.db.Query(`
-		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size
+		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size, genre, bpm
 		FROM tracks WHERE album_id = ? ORDER BY disc_number, track_number`, albumID)
 	if err != nil {
 		return nil, err
@@ -3163,7 +4229,7 @@ func (d *Database) GetTracksByAlbum(albumID int64) ([]Track, error) {
 	var tracks []Track
 	for rows.Next() {
 		var t Track
-		if err := rows.Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size); err != nil {
+		if err := rows.Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size, &t.Genre, &t.BPM); err != nil {
 			return nil, err
 		}
 		tracks = append(tracks, t)
@@ -3174,9 +4240,9 @@ func (d *Database) GetTracksByAlbum(albumID int64) ([]Track, error) {
 func (d *Database) GetTrack(id int64) (*Track, error) {
 	var t Track
 	err := d.db.QueryRow(`
-		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size
+		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size, genre, bpm
 		FROM tracks WHERE id = ?`, id,
-	).Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size)
+	).Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size, &t.Genre, &t.BPM)
 	if err != nil {
 		return nil, err
 	}
@@ -3186,9 +4252,9 @@ func (d *Database) GetTrack(id int64) (*Track, error) {
 func (d *Database) GetTrackByPath(path string) (*Track, error) {
 	var t Track
 	err := d.db.QueryRow(`
-		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size
+		SELECT id, album_id, musicbrainz_id, title, track_number, disc_number, duration, path, size, genre, bpm
 		FROM tracks WHERE path = ?`, path,
-	).Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size)
+	).Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size, &t.Genre, &t.BPM)
 	if err != nil {
 		return nil, err
 	}
@@ -3199,8 +4265,10 @@ func (d *Database) GetTrackByPath(path string) (*Track, error) {
 
 func (d *Database) CreateBook(book *Book) error {
 	result, err := d.db.Exec(
-		"INSERT INTO books (library_id, title, author, format, path, size) VALUES (?, ?, ?, ?, ?, ?)",
-		book.LibraryID, book.Title, book.Author, book.Format, book.Path, book.Size,
+		`INSERT INTO books (library_id, title, author, publisher, year, description, cover_path, format, series, issue_number, page_count, path, size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		book.LibraryID, book.Title, book.Author, book.Publisher, book.Year, book.Description, book.CoverPath, book.Format,
+		book.Series, book.IssueNumber, book.PageCount, book.Path, book.Size,
 	)
 	if err != nil {
 		return err
@@ -3219,9 +4287,15 @@ func (d *Database) UpdateBookMetadata(book *Book) error {
 	return err
 }
 
+// SetBookCover stores the path of a book's cover in the image cache
+func (d *Database) SetBookCover(bookID int64, coverPath string) error {
+	_, err := d.db.Exec("UPDATE books SET cover_path = ? WHERE id = ?", coverPath, bookID)
+	return err
+}
+
 func (d *Database) GetBooks() ([]Book, error) {
 	rows, err := d.db.Query(`
-		SELECT id, library_id, title, author, isbn, publisher, year, description, cover_path, format, path, size, added_at
+		SELECT id, library_id, title, author, isbn, publisher, COALESCE(year, 0), description, cover_path, format, series, issue_number, page_count, path, size, added_at
 		FROM books ORDER BY title`)
 	if err != nil {
 		return nil, err
@@ -3231,7 +4305,7 @@ func (d *Database) GetBooks() ([]Book, error) {
 	var books []Book
 	for rows.Next() {
 		var b Book
-		if err := rows.Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Path, &b.Size, &b.AddedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Series, &b.IssueNumber, &b.PageCount, &b.Path, &b.Size, &b.AddedAt); err != nil {
 			return nil, err
 		}
 		books = append(books, b)
@@ -3242,9 +4316,9 @@ func (d *Database) GetBooks() ([]Book, error) {
 func (d *Database) GetBook(id int64) (*Book, error) {
 	var b Book
 	err := d.db.QueryRow(`
-		SELECT id, library_id, title, author, isbn, publisher, year, description, cover_path, format, path, size, added_at
+		SELECT id, library_id, title, author, isbn, publisher, COALESCE(year, 0), description, cover_path, format, series, issue_number, page_count, path, size, added_at
 		FROM books WHERE id = ?`, id,
-	).Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Path, &b.Size, &b.AddedAt)
+	).Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Series, &b.IssueNumber, &b.PageCount, &b.Path, &b.Size, &b.AddedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -3254,9 +4328,9 @@ func (d *Database) GetBook(id int64) (*Book, error) {
 func (d *Database) GetBookByPath(path string) (*Book, error) {
 	var b Book
 	err := d.db.QueryRow(`
-		SELECT id, library_id, title, author, isbn, publisher, year, description, cover_path, format, path, size, added_at
+		SELECT id, library_id, title, author, isbn, publisher, COALESCE(year, 0), description, cover_path, format, series, issue_number, page_count, path, size, added_at
 		FROM books WHERE path = ?`, path,
-	).Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Path, &b.Size, &b.AddedAt)
+	).Scan(&b.ID, &b.LibraryID, &b.Title, &b.Author, &b.ISBN, &b.Publisher, &b.Year, &b.Description, &b.CoverPath, &b.Format, &b.Series, &b.IssueNumber, &b.PageCount, &b.Path, &b.Size, &b.AddedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -3280,8 +4354,9 @@ type ContinueWatchingItem struct {
 	UpdatedAt       string   `json:"updatedAt"`
 }
 
-// GetContinueWatching returns in-progress items (position > 0 and not completed)
-func (d *Database) GetContinueWatching(limit int) ([]ContinueWatchingItem, error) {
+// GetContinueWatching returns in-progress items (position > 0 and not played
+// under the rule)
+func (d *Database) GetContinueWatching(rule PlayedRule, limit int) ([]ContinueWatchingItem, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -3294,7 +4369,7 @@ func (d *Database) GetContinueWatching(limit int) ([]ContinueWatchingItem, error
 		WHERE p.media_type = 'movie'
 		  AND p.position > 0
 		  AND p.duration > 0
-		  AND (p.position / p.duration) < 0.95
+		  AND NOT `+rule.playedCondition()+`
 		ORDER BY p.updated_at DESC
 		LIMIT ?`, limit)
 	if err != nil {
@@ -3328,7 +4403,7 @@ func (d *Database) GetContinueWatching(limit int) ([]ContinueWatchingItem, error
 		WHERE p.media_type = 'episode'
 		  AND p.position > 0
 		  AND p.duration > 0
-		  AND (p.position / p.duration) < 0.95
+		  AND NOT `+rule.playedCondition()+`
 		ORDER BY p.updated_at DESC
 		LIMIT ?`, limit)
 	if err != nil {
@@ -3414,8 +4489,8 @@ func (d *Database) MarkAsUnwatched(mediaType string, mediaID int64) error {
 	return err
 }
 
-// GetWatchedStatus returns whether an item is watched and its progress
-func (d *Database) GetWatchedStatus(mediaType string, mediaID int64) (bool, float64, error) {
+// GetWatchedStatus returns whether an item is watched under the rule and its progress
+func (d *Database) GetWatchedStatus(rule PlayedRule, mediaType string, mediaID int64) (bool, float64, error) {
 	var position, duration float64
 	err := d.db.QueryRow(`
 		SELECT position, duration FROM progress
@@ -3424,11 +4499,11 @@ func (d *Database) GetWatchedStatus(mediaType string, mediaID int64) (bool, floa
 	if err != nil {
 		return false, 0, nil // Not watched
 	}
-	// Consider watched if > 90% complete
-	if duration > 0 && position/duration >= 0.9 {
-		return true, position / duration * 100, nil
+	var progress float64
+	if duration > 0 {
+		progress = position / duration * 100
 	}
-	return false, position / duration * 100, nil
+	return d.IsPlayed(rule, mediaType, mediaID, position, duration), progress, nil
 }
 
 // MovieWatchState represents watch state for a movie
@@ -3445,12 +4520,12 @@ type ShowWatchState struct {
 }
 
 // GetAllMovieWatchStates returns watch states for all movies
-func (d *Database) GetAllMovieWatchStates() (map[int64]MovieWatchState, error) {
+func (d *Database) GetAllMovieWatchStates(rule PlayedRule) (map[int64]MovieWatchState, error) {
 	states := make(map[int64]MovieWatchState)
 
 	rows, err := d.db.Query(`
-		SELECT media_id, position, duration FROM progress
-		WHERE media_type = 'movie'
+		SELECT p.media_id, p.position, p.duration, ` + rule.playedCondition() + ` FROM progress p
+		WHERE p.media_type = 'movie'
 	`)
 	if err != nil {
 		return states, err
@@ -3460,7 +4535,8 @@ func (d *Database) GetAllMovieWatchStates() (map[int64]MovieWatchState, error) {
 	for rows.Next() {
 		var mediaID int64
 		var position, duration float64
-		if err := rows.Scan(&mediaID, &position, &duration); err != nil {
+		var played bool
+		if err := rows.Scan(&mediaID, &position, &duration, &played); err != nil {
 			continue
 		}
 
@@ -3468,7 +4544,7 @@ func (d *Database) GetAllMovieWatchStates() (map[int64]MovieWatchState, error) {
 		if duration > 0 {
 			progress := position / duration
 			state.Progress = progress * 100
-			if progress >= 0.9 {
+			if played {
 				state.WatchState = "watched"
 			} else if progress > 0.05 {
 				state.WatchState = "partial"
@@ -3483,7 +4559,7 @@ func (d *Database) GetAllMovieWatchStates() (map[int64]MovieWatchState, error) {
 }
 
 // GetAllShowWatchStates returns watch states for all shows based on episode progress
-func (d *Database) GetAllShowWatchStates() (map[int64]ShowWatchState, error) {
+func (d *Database) GetAllShowWatchStates(rule PlayedRule) (map[int64]ShowWatchState, error) {
 	states := make(map[int64]ShowWatchState)
 
 	// First, get total episode counts per show
@@ -3511,14 +4587,14 @@ func (d *Database) GetAllShowWatchStates() (map[int64]ShowWatchState, error) {
 		}
 	}
 
-	// Now get watched episode counts (episodes with >= 90% progress)
+	// Now get watched episode counts (episodes played under the rule)
 	watchedRows, err := d.db.Query(`
 		SELECT s.id, COUNT(DISTINCT p.media_id) as watched_episodes
 		FROM shows s
 		JOIN seasons sea ON sea.show_id = s.id
 		JOIN episodes e ON e.season_id = sea.id
 		JOIN progress p ON p.media_type = 'episode' AND p.media_id = e.id
-		WHERE p.duration > 0 AND (p.position / p.duration) >= 0.9
+		WHERE ` + rule.playedCondition() + `
 		GROUP BY s.id
 	`)
 	if err != nil {
@@ -3789,6 +4865,9 @@ func (d *Database) GetQualityPresets() ([]QualityPreset, error) {
 		       COALESCE(prefer_dual_audio, 0) as prefer_dual_audio,
 		       COALESCE(prefer_dubbed, 0) as prefer_dubbed,
 		       COALESCE(preferred_language, 'any') as preferred_language,
+		       COALESCE(min_size_per_hour, 0), COALESCE(max_size_per_hour, 0),
+		       COALESCE(min_bitrate_kbps, 0), COALESCE(max_bitrate_kbps, 0),
+		       COALESCE(max_age_days, 0), COALESCE(first_seen_wait_hours, 0),
 		       created_at, updated_at
 		FROM quality_presets
 		ORDER BY media_type ASC, priority ASC, is_default DESC, name ASC
@@ -3808,6 +4887,8 @@ func (d *Database) GetQualityPresets() ([]QualityPreset, error) {
 			&hdrFormatsJSON, &p.Codec, &audioFormatsJSON, &p.PreferredEdition,
 			&p.MinSeeders, &preferSeasonPacks, &autoUpgrade,
 			&preferDualAudio, &preferDubbed, &p.PreferredLanguage,
+			&p.MinSizePerHour, &p.MaxSizePerHour, &p.MinBitrate, &p.MaxBitrate,
+		&p.MaxAgeDays, &p.FirstSeenWaitHours,
 			&p.CreatedAt, &p.UpdatedAt,
 		); err != nil {
 			return nil, err
@@ -3848,6 +4929,9 @@ func (d *Database) GetQualityPreset(id int64) (*QualityPreset, error) {
 		       COALESCE(prefer_dual_audio, 0) as prefer_dual_audio,
 		       COALESCE(prefer_dubbed, 0) as prefer_dubbed,
 		       COALESCE(preferred_language, 'any') as preferred_language,
+		       COALESCE(min_size_per_hour, 0), COALESCE(max_size_per_hour, 0),
+		       COALESCE(min_bitrate_kbps, 0), COALESCE(max_bitrate_kbps, 0),
+		       COALESCE(max_age_days, 0), COALESCE(first_seen_wait_hours, 0),
 		       created_at, updated_at
 		FROM quality_presets WHERE id = ?
 	`, id).Scan(
@@ -3855,6 +4939,8 @@ func (d *Database) GetQualityPreset(id int64) (*QualityPreset, error) {
 		&hdrFormatsJSON, &p.Codec, &audioFormatsJSON, &p.PreferredEdition,
 		&p.MinSeeders, &preferSeasonPacks, &autoUpgrade,
 		&preferDualAudio, &preferDubbed, &p.PreferredLanguage,
+		&p.MinSizePerHour, &p.MaxSizePerHour, &p.MinBitrate, &p.MaxBitrate,
+		&p.MaxAgeDays, &p.FirstSeenWaitHours,
 		&p.CreatedAt, &p.UpdatedAt,
 	)
 	if err != nil {
@@ -3894,6 +4980,9 @@ func (d *Database) GetDefaultQualityPreset() (*QualityPreset, error) {
 		       COALESCE(prefer_dual_audio, 0) as prefer_dual_audio,
 		       COALESCE(prefer_dubbed, 0) as prefer_dubbed,
 		       COALESCE(preferred_language, 'any') as preferred_language,
+		       COALESCE(min_size_per_hour, 0), COALESCE(max_size_per_hour, 0),
+		       COALESCE(min_bitrate_kbps, 0), COALESCE(max_bitrate_kbps, 0),
+		       COALESCE(max_age_days, 0), COALESCE(first_seen_wait_hours, 0),
 		       cutoff_resolution, cutoff_source,
 		       created_at, updated_at
 		FROM quality_presets WHERE is_default = 1 LIMIT 1
@@ -3902,6 +4991,8 @@ func (d *Database) GetDefaultQualityPreset() (*QualityPreset, error) {
 		&hdrFormatsJSON, &p.Codec, &audioFormatsJSON, &p.PreferredEdition,
 		&p.MinSeeders, &preferSeasonPacks, &autoUpgrade,
 		&preferDualAudio, &preferDubbed, &p.PreferredLanguage,
+		&p.MinSizePerHour, &p.MaxSizePerHour, &p.MinBitrate, &p.MaxBitrate,
+		&p.MaxAgeDays, &p.FirstSeenWaitHours,
 		&cutoffRes, &cutoffSrc,
 		&p.CreatedAt, &p.UpdatedAt,
 	)
@@ -3955,11 +5046,15 @@ func (d *Database) CreateQualityPreset(p *QualityPreset) error {
 	result, err := d.db.Exec(`
 		INSERT INTO quality_presets (name, media_type, is_default, is_built_in, enabled, priority, resolution, source,
 		                            hdr_formats, codec, audio_formats, preferred_edition,
-		                            min_seeders, prefer_season_packs, auto_upgrade)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                            min_seeders, prefer_season_packs, auto_upgrade,
+		                            min_size_per_hour, max_size_per_hour, min_bitrate_kbps, max_bitrate_kbps,
+		                            max_age_days, first_seen_wait_hours)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, p.Name, mediaType, p.IsDefault, p.IsBuiltIn, enabled, priority, p.Resolution, p.Source,
 		string(hdrFormatsJSON), p.Codec, string(audioFormatsJSON), p.PreferredEdition,
-		p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade)
+		p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade,
+		p.MinSizePerHour, p.MaxSizePerHour, p.MinBitrate, p.MaxBitrate,
+		p.MaxAgeDays, p.FirstSeenWaitHours)
 	if err != nil {
 		return err
 	}
@@ -3975,11 +5070,15 @@ func (d *Database) UpdateQualityPreset(p *QualityPreset) error {
 			name = ?, enabled = ?, priority = ?, resolution = ?, source = ?, hdr_formats = ?,
 			codec = ?, audio_formats = ?, preferred_edition = ?,
 			min_seeders = ?, prefer_season_packs = ?, auto_upgrade = ?,
+			min_size_per_hour = ?, max_size_per_hour = ?, min_bitrate_kbps = ?, max_bitrate_kbps = ?,
+			max_age_days = ?, first_seen_wait_hours = ?,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND is_built_in = 0
 	`, p.Name, p.Enabled, p.Priority, p.Resolution, p.Source, string(hdrFormatsJSON),
 		p.Codec, string(audioFormatsJSON), p.PreferredEdition,
-		p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade, p.ID)
+		p.MinSeeders, p.PreferSeasonPacks, p.AutoUpgrade,
+		p.MinSizePerHour, p.MaxSizePerHour, p.MinBitrate, p.MaxBitrate,
+		p.MaxAgeDays, p.FirstSeenWaitHours, p.ID)
 	return err
 }
 
@@ -4003,6 +5102,26 @@ func (d *Database) UpdateQualityPresetPriority(id int64, priority int) error {
 	return err
 }
 
+// UpdateQualityPresetSizeLimits sets the size and bitrate limits for any preset (including built-in)
+func (d *Database) UpdateQualityPresetSizeLimits(id int64, minSizePerHour, maxSizePerHour, minBitrate, maxBitrate int) error {
+	_, err := d.db.Exec(`
+		UPDATE quality_presets SET min_size_per_hour = ?, max_size_per_hour = ?, min_bitrate_kbps = ?, max_bitrate_kbps = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, minSizePerHour, maxSizePerHour, minBitrate, maxBitrate, id)
+	return err
+}
+
+// UpdateQualityPresetGrabThresholds sets the seeder and release age thresholds for any preset (including built-in)
+func (d *Database) UpdateQualityPresetGrabThresholds(id int64, minSeeders, maxAgeDays, firstSeenWaitHours int) error {
+	_, err := d.db.Exec(`
+		UPDATE quality_presets SET min_seeders = ?, max_age_days = ?, first_seen_wait_hours = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, minSeeders, maxAgeDays, firstSeenWaitHours, id)
+	return err
+}
+
 // UpdateQualityPresetAnimePreferences updates anime-specific preferences for a preset
 func (d *Database) UpdateQualityPresetAnimePreferences(id int64, preferDualAudio, preferDubbed *bool, preferredLanguage *string) error {
 	// Build dynamic SQL based on which fields are provided
@@ -4544,7 +5663,8 @@ func (d *Database) SetUpgradePaused(mediaID int64, mediaType string, paused bool
 	return err
 }
 
-// CreateUpgradeWantedItem creates a wanted item for an upgrade
+// CreateUpgradeWantedItem creates a wanted item for an upgrade. Movie
+// upgrades keep to the edition of the file being upgraded.
 func (d *Database) CreateUpgradeWantedItem(mediaType string, tmdbID int64, imdbID, title string, year int, posterPath string, qualityProfileID, existingMediaID int64, currentScore int) error {
 	// Check for existing upgrade wanted item first
 	existing, _ := d.GetUpgradeWantedItem(existingMediaID, mediaType)
@@ -4554,15 +5674,17 @@ func (d *Database) CreateUpgradeWantedItem(mediaType string, tmdbID int64, imdbI
 	}
 
 	_, err := d.db.Exec(`
-		INSERT INTO wanted (type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, is_upgrade, existing_media_id, upgrade_for_type, current_score, search_attempts, next_search_at, monitored)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, 0, datetime('now'), 1)
+		INSERT INTO wanted (type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, is_upgrade, existing_media_id, upgrade_for_type, current_score, search_attempts, next_search_at, monitored, edition)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, 0, datetime('now'), 1,
+		        CASE WHEN ? = 'movie' THEN (SELECT edition FROM movies WHERE id = ?) END)
 		ON CONFLICT(type, tmdb_id) DO UPDATE SET
 		    is_upgrade = 1,
 		    existing_media_id = excluded.existing_media_id,
 		    upgrade_for_type = excluded.upgrade_for_type,
 		    quality_profile_id = excluded.quality_profile_id,
-		    current_score = excluded.current_score
-	`, mediaType, tmdbID, imdbID, title, year, posterPath, qualityProfileID, existingMediaID, mediaType, currentScore)
+		    current_score = excluded.current_score,
+		    edition = excluded.edition
+	`, mediaType, tmdbID, imdbID, title, year, posterPath, qualityProfileID, existingMediaID, mediaType, currentScore, mediaType, existingMediaID)
 	return err
 }
 
@@ -4573,14 +5695,14 @@ func (d *Database) GetUpgradeWantedItem(existingMediaID int64, mediaType string)
 	err := d.db.QueryRow(`
 		SELECT id, type, tmdb_id, imdb_id, title, year, poster_path, quality_profile_id, quality_preset_id,
 		       monitored, seasons, last_searched, added_at, is_upgrade, existing_media_id, current_score,
-		       search_attempts, next_search_at
+		       search_attempts, next_search_at, edition, COALESCE(priority, 'normal')
 		FROM wanted
 		WHERE is_upgrade = 1 AND existing_media_id = ? AND upgrade_for_type = ?
 	`, existingMediaID, mediaType).Scan(&item.ID, &item.Type, &item.TmdbID, &item.ImdbID, &item.Title,
 		&item.Year, &item.PosterPath, &item.QualityProfileID, &item.QualityPresetID,
 		&item.Monitored, &seasons, &item.LastSearched, &item.AddedAt,
 		&item.IsUpgrade, &item.ExistingMediaID, &item.CurrentScore,
-		&item.SearchAttempts, &item.NextSearchAt)
+		&item.SearchAttempts, &item.NextSearchAt, &item.Edition, &item.Priority)
 	if err != nil {
 		return nil, err
 	}
@@ -5459,6 +6581,34 @@ func (d *Database) GetAllTaskHistory(limit int) ([]TaskHistory, error) {
 	return history, nil
 }
 
+// GetRecentTaskDurations returns the durations of each task's most recent
+// runs, newest first, keyed by task ID
+func (d *Database) GetRecentTaskDurations(perTask int) (map[int64][]int64, error) {
+	rows, err := d.db.Query(`
+		SELECT task_id, duration_ms FROM (
+			SELECT task_id, duration_ms,
+			       ROW_NUMBER() OVER (PARTITION BY task_id ORDER BY started_at DESC) AS n
+			FROM task_history
+			WHERE duration_ms IS NOT NULL
+		)
+		WHERE n <= ?
+		ORDER BY task_id, n`, perTask)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	durations := make(map[int64][]int64)
+	for rows.Next() {
+		var taskID, durationMs int64
+		if err := rows.Scan(&taskID, &durationMs); err != nil {
+			return nil, err
+		}
+		durations[taskID] = append(durations[taskID], durationMs)
+	}
+	return durations, rows.Err()
+}
+
 // CleanupTaskHistory removes old task history entries
 func (d *Database) CleanupTaskHistory(daysToKeep int) error {
 	_, err := d.db.Exec(`
@@ -5572,9 +6722,9 @@ func (d *Database) GetAdminUserIDs() ([]int64, error) {
 func (d *Database) GetCollections() ([]Collection, error) {
 	rows, err := d.db.Query(`
 		SELECT c.id, c.name, c.description, c.tmdb_collection_id, c.poster_path, c.backdrop_path,
-			   c.is_auto, c.sort_order, c.created_at, c.updated_at,
+			   c.is_auto, COALESCE(c.is_smart, 0), c.rules, COALESCE(c.media_type, 'both'), c.sort_order, c.created_at, c.updated_at,
 			   COUNT(ci.id) as item_count,
-			   SUM(CASE WHEN ci.media_id IS NOT NULL THEN 1 ELSE 0 END) as owned_count
+			   COALESCE(SUM(CASE WHEN ci.media_id IS NOT NULL THEN 1 ELSE 0 END), 0) as owned_count
 		FROM collections c
 		LEFT JOIN collection_items ci ON c.id = ci.collection_id
 		GROUP BY c.id
@@ -5587,12 +6737,12 @@ func (d *Database) GetCollections() ([]Collection, error) {
 	var collections []Collection
 	for rows.Next() {
 		var c Collection
-		var description, posterPath, backdropPath sql.NullString
+		var description, posterPath, backdropPath, rules sql.NullString
 		var tmdbID sql.NullInt64
-		var isAuto int
+		var isAuto, isSmart int
 
 		if err := rows.Scan(&c.ID, &c.Name, &description, &tmdbID, &posterPath, &backdropPath,
-			&isAuto, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount, &c.OwnedCount); err != nil {
+			&isAuto, &isSmart, &rules, &c.MediaType, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount, &c.OwnedCount); err != nil {
 			return nil, err
 		}
 
@@ -5609,6 +6759,10 @@ func (d *Database) GetCollections() ([]Collection, error) {
 			c.BackdropPath = &backdropPath.String
 		}
 		c.IsAuto = isAuto == 1
+		c.IsSmart = isSmart == 1
+		if rules.Valid {
+			c.Rules = &rules.String
+		}
 
 		collections = append(collections, c)
 	}
@@ -5618,20 +6772,20 @@ func (d *Database) GetCollections() ([]Collection, error) {
 // GetCollection returns a single collection by ID
 func (d *Database) GetCollection(id int64) (*Collection, error) {
 	var c Collection
-	var description, posterPath, backdropPath sql.NullString
+	var description, posterPath, backdropPath, rules sql.NullString
 	var tmdbID sql.NullInt64
-	var isAuto int
+	var isAuto, isSmart int
 
 	err := d.db.QueryRow(`
 		SELECT c.id, c.name, c.description, c.tmdb_collection_id, c.poster_path, c.backdrop_path,
-			   c.is_auto, c.sort_order, c.created_at, c.updated_at,
+			   c.is_auto, COALESCE(c.is_smart, 0), c.rules, COALESCE(c.media_type, 'both'), c.sort_order, c.created_at, c.updated_at,
 			   COUNT(ci.id) as item_count,
-			   SUM(CASE WHEN ci.media_id IS NOT NULL THEN 1 ELSE 0 END) as owned_count
+			   COALESCE(SUM(CASE WHEN ci.media_id IS NOT NULL THEN 1 ELSE 0 END), 0) as owned_count
 		FROM collections c
 		LEFT JOIN collection_items ci ON c.id = ci.collection_id
 		WHERE c.id = ?
 		GROUP BY c.id`, id).Scan(&c.ID, &c.Name, &description, &tmdbID, &posterPath, &backdropPath,
-		&isAuto, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount, &c.OwnedCount)
+		&isAuto, &isSmart, &rules, &c.MediaType, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount, &c.OwnedCount)
 
 	if err != nil {
 		return nil, err
@@ -5650,6 +6804,10 @@ func (d *Database) GetCollection(id int64) (*Collection, error) {
 		c.BackdropPath = &backdropPath.String
 	}
 	c.IsAuto = isAuto == 1
+	c.IsSmart = isSmart == 1
+	if rules.Valid {
+		c.Rules = &rules.String
+	}
 
 	return &c, nil
 }
@@ -5657,16 +6815,16 @@ func (d *Database) GetCollection(id int64) (*Collection, error) {
 // GetCollectionByTmdbID returns a collection by its TMDB collection ID
 func (d *Database) GetCollectionByTmdbID(tmdbCollectionID int64) (*Collection, error) {
 	var c Collection
-	var description, posterPath, backdropPath sql.NullString
+	var description, posterPath, backdropPath, rules sql.NullString
 	var tmdbID sql.NullInt64
-	var isAuto int
+	var isAuto, isSmart int
 
 	err := d.db.QueryRow(`
 		SELECT id, name, description, tmdb_collection_id, poster_path, backdrop_path,
-			   is_auto, sort_order, created_at, updated_at
+			   is_auto, COALESCE(is_smart, 0), rules, COALESCE(media_type, 'both'), sort_order, created_at, updated_at
 		FROM collections WHERE tmdb_collection_id = ?`, tmdbCollectionID).Scan(
 		&c.ID, &c.Name, &description, &tmdbID, &posterPath, &backdropPath,
-		&isAuto, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt)
+		&isAuto, &isSmart, &rules, &c.MediaType, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt)
 
 	if err != nil {
 		return nil, err
@@ -5685,6 +6843,10 @@ func (d *Database) GetCollectionByTmdbID(tmdbCollectionID int64) (*Collection, e
 		c.BackdropPath = &backdropPath.String
 	}
 	c.IsAuto = isAuto == 1
+	c.IsSmart = isSmart == 1
+	if rules.Valid {
+		c.Rules = &rules.String
+	}
 
 	return &c, nil
 }
@@ -5695,14 +6857,21 @@ func (d *Database) CreateCollection(c *Collection) error {
 	if c.IsAuto {
 		isAuto = 1
 	}
+	isSmart := 0
+	if c.IsSmart {
+		isSmart = 1
+	}
 	if c.SortOrder == "" {
 		c.SortOrder = "release"
 	}
+	if c.MediaType == "" {
+		c.MediaType = "both"
+	}
 
 	result, err := d.db.Exec(`
-		INSERT INTO collections (name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto, sort_order)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		c.Name, c.Description, c.TmdbCollectionID, c.PosterPath, c.BackdropPath, isAuto, c.SortOrder)
+		INSERT INTO collections (name, description, tmdb_collection_id, poster_path, backdrop_path, is_auto, is_smart, rules, media_type, sort_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Name, c.Description, c.TmdbCollectionID, c.PosterPath, c.BackdropPath, isAuto, isSmart, c.Rules, c.MediaType, c.SortOrder)
 	if err != nil {
 		return err
 	}
@@ -5720,12 +6889,17 @@ func (d *Database) UpdateCollection(c *Collection) error {
 		isAuto = 1
 	}
 
+	isSmart := 0
+	if c.IsSmart {
+		isSmart = 1
+	}
+
 	_, err := d.db.Exec(`
 		UPDATE collections SET
 			name = ?, description = ?, poster_path = ?, backdrop_path = ?,
-			is_auto = ?, sort_order = ?, updated_at = CURRENT_TIMESTAMP
+			is_auto = ?, is_smart = ?, rules = ?, media_type = ?, sort_order = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
-		c.Name, c.Description, c.PosterPath, c.BackdropPath, isAuto, c.SortOrder, c.ID)
+		c.Name, c.Description, c.PosterPath, c.BackdropPath, isAuto, isSmart, c.Rules, c.MediaType, c.SortOrder, c.ID)
 	return err
 }
 
@@ -5834,7 +7008,7 @@ func (d *Database) UpdateCollectionItemOrder(collectionID int64, itemIDs []int64
 func (d *Database) GetCollectionsForMedia(tmdbID int64, mediaType string) ([]Collection, error) {
 	rows, err := d.db.Query(`
 		SELECT c.id, c.name, c.description, c.tmdb_collection_id, c.poster_path, c.backdrop_path,
-			   c.is_auto, c.sort_order, c.created_at, c.updated_at
+			   c.is_auto, COALESCE(c.is_smart, 0), c.rules, COALESCE(c.media_type, 'both'), c.sort_order, c.created_at, c.updated_at
 		FROM collections c
 		INNER JOIN collection_items ci ON c.id = ci.collection_id
 		WHERE ci.tmdb_id = ? AND ci.media_type = ?
@@ -5847,12 +7021,12 @@ func (d *Database) GetCollectionsForMedia(tmdbID int64, mediaType string) ([]Col
 	var collections []Collection
 	for rows.Next() {
 		var c Collection
-		var description, posterPath, backdropPath sql.NullString
+		var description, posterPath, backdropPath, rules sql.NullString
 		var tmdbCollID sql.NullInt64
-		var isAuto int
+		var isAuto, isSmart int
 
 		if err := rows.Scan(&c.ID, &c.Name, &description, &tmdbCollID, &posterPath, &backdropPath,
-			&isAuto, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			&isAuto, &isSmart, &rules, &c.MediaType, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
 
@@ -5869,6 +7043,10 @@ func (d *Database) GetCollectionsForMedia(tmdbID int64, mediaType string) ([]Col
 			c.BackdropPath = &backdropPath.String
 		}
 		c.IsAuto = isAuto == 1
+		c.IsSmart = isSmart == 1
+		if rules.Valid {
+			c.Rules = &rules.String
+		}
 
 		collections = append(collections, c)
 	}
@@ -5883,6 +7061,77 @@ func (d *Database) UpdateCollectionItemMediaID(tmdbID int64, mediaType string, m
 	return err
 }
 
+// GetSmartCollectionItems evaluates a smart collection's rules with the smart
+// playlist engine and returns the matches as collection items
+func (d *Database) GetSmartCollectionItems(c *Collection, profileID *int64) ([]CollectionItem, error) {
+	if c.Rules == nil {
+		return nil, fmt.Errorf("collection %d has no rules", c.ID)
+	}
+
+	// Map the collection sort order onto the playlist engine's sort fields
+	sortBy, sortOrder := "added", "desc"
+	switch c.SortOrder {
+	case "release":
+		sortBy, sortOrder = "year", "asc"
+	case "title":
+		sortBy, sortOrder = "title", "asc"
+	}
+
+	playlist := &SmartPlaylist{
+		Rules:     *c.Rules,
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+		MediaType: c.MediaType,
+	}
+	matches, err := d.GetSmartPlaylistItems(playlist, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]CollectionItem, 0, len(matches))
+	for i, m := range matches {
+		mediaID := m.ID
+		item := CollectionItem{
+			CollectionID: c.ID,
+			MediaType:    m.MediaType,
+			MediaID:      &mediaID,
+			Title:        m.Title,
+			Year:         m.Year,
+			PosterPath:   m.PosterPath,
+			SortOrder:    i,
+			InLibrary:    true,
+		}
+		if m.TmdbID != nil {
+			item.TmdbID = *m.TmdbID
+		}
+		if t, err := time.Parse(time.RFC3339, m.AddedAt); err == nil {
+			item.AddedAt = t
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SortCollectionItems orders items according to a collection sort order.
+// "custom" keeps the manual sort_order assigned by reordering.
+func SortCollectionItems(items []CollectionItem, sortOrder string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		switch sortOrder {
+		case "release":
+			if items[i].Year != items[j].Year {
+				return items[i].Year < items[j].Year
+			}
+			return items[i].Title < items[j].Title
+		case "title":
+			return strings.ToLower(items[i].Title) < strings.ToLower(items[j].Title)
+		case "added":
+			return items[i].AddedAt.After(items[j].AddedAt)
+		default: // custom
+			return items[i].SortOrder < items[j].SortOrder
+		}
+	})
+}
+
 // Storage Analytics Types
 
 // LibrarySize represents storage usage per library
@@ -6405,7 +7654,7 @@ func (d *Database) querySmartPlaylistMovies(rules *PlaylistRules, sortBy, sortOr
 	orderBy := getMovieOrderBy(sortBy, sortOrder)
 
 	query := fmt.Sprintf(`
-		SELECT m.id, 'movie' as media_type, m.title, m.year, m.poster_path, m.tmdb_rating, m.runtime, m.added_at
+		SELECT m.id, 'movie' as media_type, m.tmdb_id, m.title, COALESCE(m.year, 0), m.poster_path, COALESCE(m.tmdb_rating, 0), COALESCE(m.runtime, 0), m.added_at
 		FROM movies m
 		%s
 		%s
@@ -6425,7 +7674,7 @@ func (d *Database) querySmartPlaylistMovies(rules *PlaylistRules, sortBy, sortOr
 	for rows.Next() {
 		var item SmartPlaylistItem
 		var addedAt sql.NullString
-		err := rows.Scan(&item.ID, &item.MediaType, &item.Title, &item.Year, &item.PosterPath, &item.Rating, &item.Runtime, &addedAt)
+		err := rows.Scan(&item.ID, &item.MediaType, &item.TmdbID, &item.Title, &item.Year, &item.PosterPath, &item.Rating, &item.Runtime, &addedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -6443,7 +7692,7 @@ func (d *Database) querySmartPlaylistShows(rules *PlaylistRules, sortBy, sortOrd
 	orderBy := getShowOrderBy(sortBy, sortOrder)
 
 	query := fmt.Sprintf(`
-		SELECT s.id, 'show' as media_type, s.title, s.year, s.poster_path, s.tmdb_rating, 0 as runtime, s.added_at
+		SELECT s.id, 'show' as media_type, s.tmdb_id, s.title, COALESCE(s.year, 0), s.poster_path, COALESCE(s.tmdb_rating, 0), 0 as runtime, s.added_at
 		FROM shows s
 		%s
 		%s
@@ -6463,7 +7712,7 @@ func (d *Database) querySmartPlaylistShows(rules *PlaylistRules, sortBy, sortOrd
 	for rows.Next() {
 		var item SmartPlaylistItem
 		var addedAt sql.NullString
-		err := rows.Scan(&item.ID, &item.MediaType, &item.Title, &item.Year, &item.PosterPath, &item.Rating, &item.Runtime, &addedAt)
+		err := rows.Scan(&item.ID, &item.MediaType, &item.TmdbID, &item.Title, &item.Year, &item.PosterPath, &item.Rating, &item.Runtime, &addedAt)
 		if err != nil {
 			return nil, err
 		}
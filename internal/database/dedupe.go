@@ -0,0 +1,325 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Library deduplication operations
+//
+// Rescans of reorganized folders can add a second row for a movie or show
+// that's already in the library. Duplicates matched to the same TMDB ID are
+// merged into one row: watch state is combined and extra files are kept as
+// versions.
+
+// DuplicateGroup is a set of rows in one library matched to the same TMDB ID
+type DuplicateGroup struct {
+	LibraryID int64   `json:"libraryId"`
+	MediaType string  `json:"mediaType"` // movie, show
+	TmdbID    int64   `json:"tmdbId"`
+	Title     string  `json:"title"`
+	IDs       []int64 `json:"ids"` // Oldest first
+}
+
+// FindDuplicateMovies returns movies sharing a TMDB ID within a library.
+// libraryID 0 searches every library.
+func (d *Database) FindDuplicateMovies(libraryID int64) ([]DuplicateGroup, error) {
+	return d.findDuplicates("movies", "movie", libraryID)
+}
+
+// FindDuplicateShows returns shows sharing a TMDB ID within a library.
+// libraryID 0 searches every library.
+func (d *Database) FindDuplicateShows(libraryID int64) ([]DuplicateGroup, error) {
+	return d.findDuplicates("shows", "show", libraryID)
+}
+
+func (d *Database) findDuplicates(table, mediaType string, libraryID int64) ([]DuplicateGroup, error) {
+	query := `
+		SELECT library_id, tmdb_id, MIN(title), GROUP_CONCAT(id)
+		FROM ` + table + `
+		WHERE tmdb_id IS NOT NULL AND tmdb_id > 0`
+	var args []interface{}
+	if libraryID > 0 {
+		query += ` AND library_id = ?`
+		args = append(args, libraryID)
+	}
+	query += ` GROUP BY library_id, tmdb_id HAVING COUNT(*) > 1`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		g := DuplicateGroup{MediaType: mediaType}
+		var ids string
+		if err := rows.Scan(&g.LibraryID, &g.TmdbID, &g.Title, &ids); err != nil {
+			return nil, err
+		}
+		for _, s := range strings.Split(ids, ",") {
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+				g.IDs = append(g.IDs, id)
+			}
+		}
+		sort.Slice(g.IDs, func(i, j int) bool { return g.IDs[i] < g.IDs[j] })
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// MergeMovies folds a duplicate movie into the one being kept: watch state,
+// play counts and collections move over, and the duplicate's file becomes a
+// version of the kept movie when keepFile is set
+func (d *Database) MergeMovies(keepID, dupID int64, keepFile bool) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var path string
+	var size int64
+	var playCount int
+	var lastWatched, edition sql.NullString
+	err = tx.QueryRow(`SELECT path, COALESCE(size, 0), COALESCE(play_count, 0), last_watched_at, edition FROM movies WHERE id = ?`, dupID).
+		Scan(&path, &size, &playCount, &lastWatched, &edition)
+	if err != nil {
+		return err
+	}
+
+	if err := mergeMediaState(tx, "movie", keepID, dupID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE movies SET
+			play_count = COALESCE(play_count, 0) + ?,
+			last_watched_at = CASE WHEN last_watched_at IS NULL OR last_watched_at < ? THEN ? ELSE last_watched_at END
+		WHERE id = ?`, playCount, lastWatched, lastWatched, keepID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE collection_items SET media_id = ? WHERE media_type = 'movie' AND media_id = ?`, keepID, dupID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM movies WHERE id = ?`, dupID); err != nil {
+		return err
+	}
+	if keepFile {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO media_versions (media_type, media_id, path, size, edition) VALUES ('movie', ?, ?, ?, ?)`,
+			keepID, path, size, edition); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MergeShows folds a duplicate show into the one being kept. Seasons the kept
+// show lacks move over whole; episodes it already has are merged, keeping the
+// duplicate's file as a version. exists reports whether a file is on disk, so
+// missing files are dropped rather than kept.
+func (d *Database) MergeShows(keepID, dupID int64, exists func(path string) bool) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keepPath, dupPath string
+	if err := tx.QueryRow(`SELECT path FROM shows WHERE id = ?`, keepID).Scan(&keepPath); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`SELECT path FROM shows WHERE id = ?`, dupID).Scan(&dupPath); err != nil {
+		return err
+	}
+
+	keepSeasons, err := querySeasonIDs(tx, keepID)
+	if err != nil {
+		return err
+	}
+	dupSeasons, err := querySeasonIDs(tx, dupID)
+	if err != nil {
+		return err
+	}
+
+	for number, dupSeason := range dupSeasons {
+		keepSeason, ok := keepSeasons[number]
+		if !ok {
+			if _, err := tx.Exec(`UPDATE seasons SET show_id = ? WHERE id = ?`, keepID, dupSeason); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := mergeSeasonEpisodes(tx, keepSeason, dupSeason, exists); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM seasons WHERE id = ?`, dupSeason); err != nil {
+			return err
+		}
+	}
+
+	stmts := []string{
+		`UPDATE OR IGNORE skip_segments SET show_id = ?1 WHERE show_id = ?2`,
+		`UPDATE media_quality_override SET media_id = ?1 WHERE media_type = 'show' AND media_id = ?2
+			AND NOT EXISTS (SELECT 1 FROM media_quality_override WHERE media_type = 'show' AND media_id = ?1)`,
+		`UPDATE collection_items SET media_id = ?1 WHERE media_type = 'show' AND media_id = ?2`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, keepID, dupID); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range []string{
+		`DELETE FROM skip_segments WHERE show_id = ?`,
+		`DELETE FROM media_quality_override WHERE media_type = 'show' AND media_id = ?`,
+		`DELETE FROM shows WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, dupID); err != nil {
+			return err
+		}
+	}
+
+	// Follow the show to its new folder if the old one is gone
+	if !exists(keepPath) && exists(dupPath) {
+		if _, err := tx.Exec(`UPDATE shows SET path = ? WHERE id = ?`, dupPath, keepID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// querySeasonIDs maps season numbers to season IDs for a show
+func querySeasonIDs(tx *sql.Tx, showID int64) (map[int]int64, error) {
+	rows, err := tx.Query(`SELECT season_number, id FROM seasons WHERE show_id = ?`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := make(map[int]int64)
+	for rows.Next() {
+		var number int
+		var id int64
+		if err := rows.Scan(&number, &id); err != nil {
+			return nil, err
+		}
+		seasons[number] = id
+	}
+	return seasons, rows.Err()
+}
+
+// episodeFile is the part of an episode row needed to merge it
+type episodeFile struct {
+	id     int64
+	number int
+	path   string
+	size   int64
+}
+
+func queryEpisodeFiles(tx *sql.Tx, seasonID int64) ([]episodeFile, error) {
+	rows, err := tx.Query(`SELECT id, episode_number, path, COALESCE(size, 0) FROM episodes WHERE season_id = ? ORDER BY id`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []episodeFile
+	for rows.Next() {
+		var e episodeFile
+		if err := rows.Scan(&e.id, &e.number, &e.path, &e.size); err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
+
+// mergeSeasonEpisodes moves a duplicate season's episodes into the kept season
+func mergeSeasonEpisodes(tx *sql.Tx, keepSeason, dupSeason int64, exists func(string) bool) error {
+	keepEpisodes, err := queryEpisodeFiles(tx, keepSeason)
+	if err != nil {
+		return err
+	}
+	dupEpisodes, err := queryEpisodeFiles(tx, dupSeason)
+	if err != nil {
+		return err
+	}
+
+	byNumber := make(map[int]episodeFile)
+	for _, e := range keepEpisodes {
+		if _, ok := byNumber[e.number]; !ok {
+			byNumber[e.number] = e
+		}
+	}
+
+	for _, dup := range dupEpisodes {
+		keep, ok := byNumber[dup.number]
+		if !ok {
+			if _, err := tx.Exec(`UPDATE episodes SET season_id = ? WHERE id = ?`, keepSeason, dup.id); err != nil {
+				return err
+			}
+			byNumber[dup.number] = dup
+			continue
+		}
+
+		if err := mergeMediaState(tx, "episode", keep.id, dup.id); err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			`DELETE FROM media_segments WHERE episode_id = ?`,
+			`DELETE FROM audio_fingerprints WHERE episode_id = ?`,
+			`DELETE FROM episodes WHERE id = ?`,
+		} {
+			if _, err := tx.Exec(stmt, dup.id); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case !exists(dup.path):
+			// Nothing to keep
+		case !exists(keep.path):
+			// The kept episode's file moved; point it at the new one
+			if _, err := tx.Exec(`UPDATE episodes SET path = ?, size = ?, missing_since = NULL WHERE id = ?`,
+				dup.path, dup.size, keep.id); err != nil {
+				return err
+			}
+		default:
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO media_versions (media_type, media_id, path, size) VALUES ('episode', ?, ?, ?)`,
+				keep.id, dup.path, dup.size); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeMediaState moves the watch state of a duplicate movie or episode to
+// the kept one. The most recent playback position wins; watch history,
+// versions and quality tracking are carried over.
+func mergeMediaState(tx *sql.Tx, mediaType string, keepID, dupID int64) error {
+	stmts := []string{
+		// Drop the kept position if the duplicate's is newer, then move the duplicate's over
+		`DELETE FROM progress WHERE media_type = ?1 AND media_id = ?2
+			AND updated_at < (SELECT updated_at FROM progress WHERE media_type = ?1 AND media_id = ?3)`,
+		`UPDATE OR IGNORE progress SET media_id = ?2 WHERE media_type = ?1 AND media_id = ?3`,
+		`UPDATE watch_history SET media_id = ?2 WHERE media_type = ?1 AND media_id = ?3`,
+		`UPDATE media_versions SET media_id = ?2 WHERE media_type = ?1 AND media_id = ?3`,
+		`UPDATE OR IGNORE media_quality_status SET media_id = ?2 WHERE media_type = ?1 AND media_id = ?3`,
+		`UPDATE media_quality_override SET media_id = ?2 WHERE media_type = ?1 AND media_id = ?3
+			AND NOT EXISTS (SELECT 1 FROM media_quality_override WHERE media_type = ?1 AND media_id = ?2)`,
+		// Whatever couldn't move belongs to the duplicate's file alone
+		`DELETE FROM progress WHERE media_type = ?1 AND media_id = ?3`,
+		`DELETE FROM media_quality_status WHERE media_type = ?1 AND media_id = ?3`,
+		`DELETE FROM media_quality_override WHERE media_type = ?1 AND media_id = ?3`,
+		`DELETE FROM chapters WHERE media_type = ?1 AND media_id = ?3`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, mediaType, keepID, dupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
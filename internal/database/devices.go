@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Device operations
+//
+// Clients register a device when they sign in: an identifier of their own
+// choosing, a name and a type. Devices are per user, keep their playback
+// preferences across sign-ins, and can be blocked by an admin, which signs
+// them out and stops them signing in again.
+
+// Device is a client a user signs in from
+type Device struct {
+	ID              int64     `json:"id"`
+	UserID          int64     `json:"userId"`
+	Username        string    `json:"username,omitempty"`
+	Identifier      string    `json:"identifier"`
+	Name            string    `json:"name"`
+	Type            string    `json:"type"`
+	MaxBitrate      *int      `json:"maxBitrate,omitempty"` // kbps
+	PreferredPlayer *string   `json:"preferredPlayer,omitempty"`
+	Blocked         bool      `json:"blocked"`
+	LastIP          *string   `json:"lastIp,omitempty"`
+	LastSeenAt      time.Time `json:"lastSeenAt"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+const deviceColumns = `d.id, d.user_id, COALESCE(u.username, ''), d.identifier, d.name, d.type, d.max_bitrate,
+	d.preferred_player, d.blocked, d.last_ip, d.last_seen_at, d.created_at`
+
+func scanDevice(row interface{ Scan(...interface{}) error }) (*Device, error) {
+	var dev Device
+	err := row.Scan(&dev.ID, &dev.UserID, &dev.Username, &dev.Identifier, &dev.Name, &dev.Type, &dev.MaxBitrate,
+		&dev.PreferredPlayer, &dev.Blocked, &dev.LastIP, &dev.LastSeenAt, &dev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &dev, nil
+}
+
+// RegisterDevice records a sign-in from a device, adding it the first time
+// it's seen. A device keeps the name it was first given, or renamed to,
+// after that. Returns the device, which may be blocked.
+func (d *Database) RegisterDevice(userID int64, identifier, name, deviceType, ip string) (*Device, error) {
+	_, err := d.db.Exec(`
+		INSERT INTO devices (user_id, identifier, name, type, last_ip, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id, identifier) DO UPDATE SET
+			last_ip = excluded.last_ip, last_seen_at = CURRENT_TIMESTAMP`,
+		userID, identifier, name, deviceType, ip,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanDevice(d.db.QueryRow(`
+		SELECT `+deviceColumns+` FROM devices d LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.user_id = ? AND d.identifier = ?`, userID, identifier))
+}
+
+// GetDevice returns a device, or nil if there's none with the ID
+func (d *Database) GetDevice(id int64) (*Device, error) {
+	dev, err := scanDevice(d.db.QueryRow(`
+		SELECT `+deviceColumns+` FROM devices d LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return dev, err
+}
+
+// GetDevices returns a user's devices, or everyone's when userID is 0, most
+// recently seen first
+func (d *Database) GetDevices(userID int64) ([]Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices d LEFT JOIN users u ON u.id = d.user_id`
+	var args []interface{}
+	if userID > 0 {
+		query += ` WHERE d.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY d.last_seen_at DESC`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		dev, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *dev)
+	}
+	return devices, rows.Err()
+}
+
+// UpdateDevice saves a device's name, playback preferences and whether it's
+// blocked
+func (d *Database) UpdateDevice(dev *Device) error {
+	_, err := d.db.Exec(`
+		UPDATE devices SET name = ?, max_bitrate = ?, preferred_player = ?, blocked = ? WHERE id = ?`,
+		dev.Name, dev.MaxBitrate, dev.PreferredPlayer, dev.Blocked, dev.ID,
+	)
+	return err
+}
+
+// DeleteDevice removes a device
+func (d *Database) DeleteDevice(id int64) error {
+	_, err := d.db.Exec("DELETE FROM devices WHERE id = ?", id)
+	return err
+}
+
+// SetSessionDevice records the device a session signed in from
+func (d *Database) SetSessionDevice(token string, deviceID int64) error {
+	_, err := d.db.Exec("UPDATE sessions SET device_id = ? WHERE token = ?", deviceID, token)
+	return err
+}
+
+// DeleteDeviceSessions signs a device out
+func (d *Database) DeleteDeviceSessions(deviceID int64) error {
+	_, err := d.db.Exec("DELETE FROM sessions WHERE device_id = ?", deviceID)
+	return err
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Follow operations
+//
+// A profile follows a show to be told when new episodes of it come into the
+// library, and a person to be told when a movie they're in comes into the
+// library or is released digitally. Only what's added after the follow
+// counts: each follow remembers the last episode or movie ID when it was
+// made, and what it has already been told about.
+
+// Kinds of follow
+const (
+	FollowShow   = "show"
+	FollowPerson = "person"
+)
+
+// Follow is a show or person a profile follows, by TMDB ID
+type Follow struct {
+	ID                int64      `json:"id"`
+	ProfileID         int64      `json:"profileId"`
+	UserID            int64      `json:"-"` // Populated from join
+	Kind              string     `json:"kind"`
+	TmdbID            int64      `json:"tmdbId"`
+	Name              string     `json:"name"`
+	ImagePath         *string    `json:"imagePath,omitempty"`
+	SinceID           int64      `json:"-"` // Episodes or movies with higher IDs are new
+	ReleasesCheckedAt *time.Time `json:"releasesCheckedAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+// FollowedEpisode is a new episode of a followed show
+type FollowedEpisode struct {
+	ID            int64
+	SeasonNumber  int
+	EpisodeNumber int
+	ShowID        int64
+	ShowTitle     string
+	PosterPath    *string
+	LibraryID     int64
+}
+
+// FollowedMovie is a new movie with a followed person in it
+type FollowedMovie struct {
+	ID         int64
+	Title      string
+	PosterPath *string
+	LibraryID  int64
+}
+
+const followColumns = `
+	SELECT f.id, f.profile_id, p.user_id, f.kind, f.tmdb_id, f.name, f.image_path, f.since_id,
+	       f.releases_checked_at, f.created_at
+	FROM follows f
+	JOIN profiles p ON f.profile_id = p.id`
+
+func scanFollows(rows *sql.Rows) ([]Follow, error) {
+	defer rows.Close()
+
+	var follows []Follow
+	for rows.Next() {
+		var f Follow
+		if err := rows.Scan(&f.ID, &f.ProfileID, &f.UserID, &f.Kind, &f.TmdbID, &f.Name, &f.ImagePath, &f.SinceID,
+			&f.ReleasesCheckedAt, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, f)
+	}
+	return follows, rows.Err()
+}
+
+// GetFollows returns what a profile follows, latest first
+func (d *Database) GetFollows(profileID int64) ([]Follow, error) {
+	rows, err := d.db.Query(followColumns+` WHERE f.profile_id = ? ORDER BY f.created_at DESC, f.id DESC`, profileID)
+	if err != nil {
+		return nil, err
+	}
+	return scanFollows(rows)
+}
+
+// GetAllFollows returns every profile's follows
+func (d *Database) GetAllFollows() ([]Follow, error) {
+	rows, err := d.db.Query(followColumns + ` ORDER BY f.id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanFollows(rows)
+}
+
+// GetFollow returns a follow, or nil if there's no such follow
+func (d *Database) GetFollow(id int64) (*Follow, error) {
+	rows, err := d.db.Query(followColumns+` WHERE f.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := scanFollows(rows)
+	if err != nil || len(follows) == 0 {
+		return nil, err
+	}
+	return &follows[0], nil
+}
+
+// IsFollowing reports whether a profile already follows a show or person
+func (d *Database) IsFollowing(profileID int64, kind string, tmdbID int64) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM follows WHERE profile_id = ? AND kind = ? AND tmdb_id = ?`,
+		profileID, kind, tmdbID).Scan(&n)
+	return n > 0, err
+}
+
+// CreateFollow has a profile follow a show or person from now on
+func (d *Database) CreateFollow(follow *Follow) error {
+	table := "episodes"
+	if follow.Kind == FollowPerson {
+		table = "movies"
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO follows (profile_id, kind, tmdb_id, name, image_path, since_id)
+		VALUES (?, ?, ?, ?, ?, (SELECT COALESCE(MAX(id), 0) FROM `+table+`))`,
+		follow.ProfileID, follow.Kind, follow.TmdbID, follow.Name, follow.ImagePath)
+	if err != nil {
+		return err
+	}
+	follow.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// DeleteFollow stops a follow, forgetting what it was told about
+func (d *Database) DeleteFollow(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM follow_notices WHERE follow_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM follows WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddFollowNotice records that a follow was told about an item, reporting
+// false if it already had been
+func (d *Database) AddFollowNotice(followID int64, item string) (bool, error) {
+	result, err := d.db.Exec(`INSERT OR IGNORE INTO follow_notices (follow_id, item) VALUES (?, ?)`, followID, item)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// HasFollowNotice reports whether a follow was told about an item
+func (d *Database) HasFollowNotice(followID int64, item string) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM follow_notices WHERE follow_id = ? AND item = ?`, followID, item).Scan(&n)
+	return n > 0, err
+}
+
+// SetFollowReleasesChecked records when a followed person's releases were
+// last looked up
+func (d *Database) SetFollowReleasesChecked(id int64, at time.Time) error {
+	_, err := d.db.Exec(`UPDATE follows SET releases_checked_at = ? WHERE id = ?`, at.UTC().Truncate(time.Second), id)
+	return err
+}
+
+// GetNewFollowedEpisodes returns the episodes of a followed show added since
+// the follow that it hasn't been told about
+func (d *Database) GetNewFollowedEpisodes(follow *Follow) ([]FollowedEpisode, error) {
+	rows, err := d.db.Query(`
+		SELECT e.id, sea.season_number, e.episode_number, s.id, s.title, s.poster_path, s.library_id
+		FROM episodes e
+		JOIN seasons sea ON e.season_id = sea.id
+		JOIN shows s ON sea.show_id = s.id
+		WHERE s.tmdb_id = ? AND e.id > ? AND e.missing_since IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM follow_notices n WHERE n.follow_id = ? AND n.item = 'episode:' || e.id)
+		ORDER BY sea.season_number, e.episode_number`,
+		follow.TmdbID, follow.SinceID, follow.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []FollowedEpisode
+	for rows.Next() {
+		var e FollowedEpisode
+		if err := rows.Scan(&e.ID, &e.SeasonNumber, &e.EpisodeNumber, &e.ShowID, &e.ShowTitle, &e.PosterPath, &e.LibraryID); err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, e)
+	}
+	return episodes, rows.Err()
+}
+
+// GetNewFollowedMovies returns the movies with a followed person in their
+// cast added since the follow that it hasn't been told about
+func (d *Database) GetNewFollowedMovies(follow *Follow) ([]FollowedMovie, error) {
+	rows, err := d.db.Query(`
+		SELECT id, title, poster_path, library_id FROM movies
+		WHERE id > ? AND missing_since IS NULL AND "cast" LIKE ?
+		  AND NOT EXISTS (SELECT 1 FROM follow_notices n WHERE n.follow_id = ? AND n.item = 'movie:' || movies.id)
+		ORDER BY id`,
+		follow.SinceID, fmt.Sprintf(`%%"id":%d,%%`, follow.TmdbID), follow.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movies []FollowedMovie
+	for rows.Next() {
+		var m FollowedMovie
+		if err := rows.Scan(&m.ID, &m.Title, &m.PosterPath, &m.LibraryID); err != nil {
+			return nil, err
+		}
+		movies = append(movies, m)
+	}
+	return movies, rows.Err()
+}
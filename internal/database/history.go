@@ -0,0 +1,204 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// History operations
+//
+// The unified history combines grab history, import history and media events
+// (upgrades, deletions, searches, renames and quality changes) into one
+// timeline. Media are identified by TMDB ID and media type, as in grab and
+// import history.
+
+// Media event types
+const (
+	MediaEventUpgrade  = "upgrade"
+	MediaEventDeletion = "deletion"
+	MediaEventSearch   = "search"
+	MediaEventRename   = "rename"
+	MediaEventQuality  = "quality"
+)
+
+// HistoryEventTypes are the event types of the unified history
+var HistoryEventTypes = []string{"grab", "import", MediaEventUpgrade, MediaEventDeletion}
+
+// ActivityEventTypes are the event types of a media item's activity, which
+// adds the searches run for it and changes to its files to the history
+var ActivityEventTypes = append(append([]string{}, HistoryEventTypes...),
+	MediaEventSearch, MediaEventRename, MediaEventQuality)
+
+// MediaEvent is a media event to record in the history
+type MediaEvent struct {
+	EventType string
+	MediaType string
+	MediaID   *int64 // TMDB ID
+	Summary   string
+	Details   map[string]interface{}
+	UserID    *int64
+}
+
+// HistoryEvent is an entry of the unified history. Details holds the fields
+// of the underlying grab, import or media event.
+type HistoryEvent struct {
+	ID        string          `json:"id"`   // Type and row, e.g. "grab:12"
+	Type      string          `json:"type"` // grab, import, or a media event type
+	MediaType string          `json:"mediaType,omitempty"`
+	MediaID   *int64          `json:"mediaId,omitempty"` // TMDB ID
+	Title     string          `json:"title,omitempty"`   // Media title, when it's in the library
+	Summary   string          `json:"summary"`
+	Status    string          `json:"status,omitempty"`
+	At        time.Time       `json:"at"`
+	Details   json.RawMessage `json:"details"`
+}
+
+// HistoryFilter narrows the unified history. Zero values don't filter,
+// except Types, which defaults to HistoryEventTypes.
+type HistoryFilter struct {
+	Types     []string
+	MediaType string
+	MediaID   int64 // TMDB ID
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Offset    int
+}
+
+// RecordMediaEvent adds a media event to the history
+func (d *Database) RecordMediaEvent(e *MediaEvent) error {
+	var details *string
+	if len(e.Details) > 0 {
+		data, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		text := string(data)
+		details = &text
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO media_events (event_type, media_type, media_id, summary, details, user_id)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		e.EventType, e.MediaType, e.MediaID, e.Summary, details, e.UserID)
+	return err
+}
+
+// historyTitle looks up the library title of a history row's media
+func historyTitle(mediaType, mediaID string) string {
+	return `CASE WHEN ` + mediaType + ` = 'movie'
+			THEN (SELECT title FROM movies WHERE tmdb_id = ` + mediaID + ` LIMIT 1)
+			ELSE (SELECT title FROM shows WHERE tmdb_id = ` + mediaID + ` LIMIT 1) END`
+}
+
+// historyQuery selects every history source in a common shape. Times are
+// normalised to UTC ISO 8601 so they sort and parse the same way.
+var historyQuery = `
+	SELECT 'grab:' || id AS id, 'grab' AS type, media_type, media_id,
+		` + historyTitle("media_type", "media_id") + ` AS title,
+		release_title AS summary, status,
+		strftime('%Y-%m-%dT%H:%M:%SZ', grabbed_at) AS at,
+		json_object(
+			'releaseTitle', release_title, 'indexerId', indexer_id, 'indexerName', indexer_name,
+			'resolution', quality_resolution, 'source', quality_source, 'codec', quality_codec,
+			'audio', quality_audio, 'hdr', quality_hdr, 'releaseGroup', release_group, 'size', size,
+			'downloadClientId', download_client_id, 'downloadId', download_id,
+			'error', error_message, 'importedAt', imported_at) AS details
+	FROM grab_history
+	UNION ALL
+	SELECT 'import:' || id, 'import', media_type, media_id,
+		` + historyTitle("media_type", "media_id") + `,
+		dest_path, CASE WHEN success = 1 THEN 'imported' ELSE 'failed' END,
+		strftime('%Y-%m-%dT%H:%M:%SZ', created_at),
+		json_object(
+			'downloadId', download_id, 'sourcePath', source_path, 'destPath', dest_path,
+			'success', CASE WHEN success = 1 THEN json('true') ELSE json('false') END, 'error', error)
+	FROM import_history
+	UNION ALL
+	SELECT event_type || ':' || id, event_type, media_type, media_id,
+		` + historyTitle("media_type", "media_id") + `,
+		summary, '',
+		strftime('%Y-%m-%dT%H:%M:%SZ', created_at),
+		json_set(COALESCE(details, '{}'), '$.userId', user_id)
+	FROM media_events`
+
+// GetHistory returns a page of the unified history, newest first, and the
+// number of events matching the filter
+func (d *Database) GetHistory(filter HistoryFilter) ([]HistoryEvent, int, error) {
+	if len(filter.Types) == 0 {
+		filter.Types = HistoryEventTypes
+	}
+	where := []string{`type IN (?` + strings.Repeat(", ?", len(filter.Types)-1) + `)`}
+	var args []interface{}
+	for _, t := range filter.Types {
+		args = append(args, t)
+	}
+	if filter.MediaType == "show" {
+		// Anime series are shows searched and grabbed as anime
+		where = append(where, `media_type IN ('show', 'anime')`)
+	} else if filter.MediaType != "" {
+		where = append(where, `media_type = ?`)
+		args = append(args, filter.MediaType)
+	}
+	if filter.MediaID > 0 {
+		where = append(where, `media_id = ?`)
+		args = append(args, filter.MediaID)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, `at >= ?`)
+		args = append(args, filter.Since.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, `at < ?`)
+		args = append(args, filter.Until.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+
+	query := `SELECT id, type, COALESCE(media_type, ''), media_id, COALESCE(title, ''), COALESCE(summary, ''),
+		COALESCE(status, ''), at, details FROM (` + historyQuery + `)`
+	countQuery := `SELECT COUNT(*) FROM (` + historyQuery + `)`
+	query += ` WHERE ` + strings.Join(where, " AND ")
+	countQuery += ` WHERE ` + strings.Join(where, " AND ")
+
+	var total int
+	if err := d.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	query += ` ORDER BY at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []HistoryEvent{}
+	for rows.Next() {
+		var e HistoryEvent
+		var at, details string
+		if err := rows.Scan(&e.ID, &e.Type, &e.MediaType, &e.MediaID, &e.Title, &e.Summary,
+			&e.Status, &at, &details); err != nil {
+			return nil, 0, err
+		}
+		e.At, _ = time.Parse(time.RFC3339, at)
+		e.Details = json.RawMessage(details)
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// GetShowTmdbIDForEpisode returns the TMDB ID of an episode's show, for
+// recording episode events against the show
+func (d *Database) GetShowTmdbIDForEpisode(episodeID int64) (*int64, error) {
+	var tmdbID *int64
+	err := d.db.QueryRow(`
+		SELECT sh.tmdb_id FROM episodes e
+		JOIN seasons se ON se.id = e.season_id
+		JOIN shows sh ON sh.id = se.show_id
+		WHERE e.id = ?`, episodeID).Scan(&tmdbID)
+	return tmdbID, err
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Home screen operations
+//
+// The home screen's rows are built from the library; admins can pin items
+// above them, for a while or until unpinned.
+
+// HomePin is an item pinned to the home screen
+type HomePin struct {
+	ID        int64      `json:"id"`
+	MediaType string     `json:"mediaType"` // movie, show
+	MediaID   int64      `json:"mediaId"`
+	Note      *string    `json:"note,omitempty"` // Shown with the item, e.g. "Friday movie night"
+	Position  int        `json:"position"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	PinnedBy  *int64     `json:"pinnedBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// GetHomePins returns the pins that haven't expired, in position order
+func (d *Database) GetHomePins() ([]HomePin, error) {
+	rows, err := d.db.Query(`
+		SELECT id, media_type, media_id, note, COALESCE(position, 0), expires_at, pinned_by, created_at
+		FROM home_pins
+		WHERE expires_at IS NULL OR expires_at > ?
+		ORDER BY position, created_at`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []HomePin{}
+	for rows.Next() {
+		var p HomePin
+		if err := rows.Scan(&p.ID, &p.MediaType, &p.MediaID, &p.Note, &p.Position, &p.ExpiresAt, &p.PinnedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
+
+// CreateHomePin pins an item, replacing an earlier pin of the same item
+func (d *Database) CreateHomePin(pin *HomePin) error {
+	_, err := d.db.Exec(`
+		INSERT INTO home_pins (media_type, media_id, note, position, expires_at, pinned_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(media_type, media_id) DO UPDATE SET
+			note = excluded.note, position = excluded.position, expires_at = excluded.expires_at,
+			pinned_by = excluded.pinned_by, created_at = CURRENT_TIMESTAMP`,
+		pin.MediaType, pin.MediaID, pin.Note, pin.Position, pin.ExpiresAt, pin.PinnedBy)
+	if err != nil {
+		return err
+	}
+	return d.db.QueryRow(`SELECT id, created_at FROM home_pins WHERE media_type = ? AND media_id = ?`,
+		pin.MediaType, pin.MediaID).Scan(&pin.ID, &pin.CreatedAt)
+}
+
+// DeleteHomePin unpins an item
+func (d *Database) DeleteHomePin(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM home_pins WHERE id = ?`, id)
+	return err
+}
+
+// ParseLibraryIDList parses a comma-separated list of library IDs, as in the
+// home_excluded_libraries setting
+func ParseLibraryIDList(value string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
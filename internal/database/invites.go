@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Invite is an admin-created link that lets someone self-register with preset
+// role, parental controls, library access, and request quota
+type Invite struct {
+	ID                 int64      `json:"id"`
+	Token              string     `json:"token"`
+	Note               *string    `json:"note,omitempty"`
+	Role               string     `json:"role"`
+	ContentRatingLimit *string    `json:"contentRatingLimit,omitempty"`
+	LibraryIDs         []int64    `json:"libraryIds"`
+	RequestQuota       *int       `json:"requestQuota,omitempty"`
+	RequestQuotaDays   int        `json:"requestQuotaDays"`
+	MaxUses            int        `json:"maxUses"` // 0 means unlimited
+	Uses               int        `json:"uses"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+	CreatedBy          *int64     `json:"createdBy,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
+// IsUsable reports whether the invite can still be redeemed
+func (i *Invite) IsUsable() bool {
+	if i.ExpiresAt != nil && time.Now().After(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// Invite operations
+
+func (d *Database) CreateInvite(invite *Invite) error {
+	if invite.RequestQuotaDays <= 0 {
+		invite.RequestQuotaDays = DefaultRequestQuotaDays
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO invites (token, note, role, content_rating_limit, library_ids, request_quota, request_quota_days, max_uses, expires_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		invite.Token, invite.Note, invite.Role, invite.ContentRatingLimit, encodeLibraryIDs(invite.LibraryIDs),
+		invite.RequestQuota, invite.RequestQuotaDays, invite.MaxUses, invite.ExpiresAt, invite.CreatedBy,
+	)
+	if err != nil {
+		return err
+	}
+	invite.ID, _ = result.LastInsertId()
+	invite.CreatedAt = time.Now()
+	return nil
+}
+
+const inviteColumns = `id, token, note, role, content_rating_limit, library_ids, request_quota,
+	COALESCE(request_quota_days, 7), COALESCE(max_uses, 0), COALESCE(uses, 0), expires_at, created_by, created_at`
+
+func scanInvite(row interface{ Scan(...interface{}) error }) (*Invite, error) {
+	var i Invite
+	var libraryIDs sql.NullString
+	if err := row.Scan(&i.ID, &i.Token, &i.Note, &i.Role, &i.ContentRatingLimit, &libraryIDs, &i.RequestQuota,
+		&i.RequestQuotaDays, &i.MaxUses, &i.Uses, &i.ExpiresAt, &i.CreatedBy, &i.CreatedAt); err != nil {
+		return nil, err
+	}
+	i.LibraryIDs = decodeLibraryIDs(libraryIDs)
+	return &i, nil
+}
+
+func (d *Database) GetInvites() ([]Invite, error) {
+	rows, err := d.db.Query(`SELECT ` + inviteColumns + ` FROM invites ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, nil
+}
+
+func (d *Database) GetInvite(id int64) (*Invite, error) {
+	return scanInvite(d.db.QueryRow(`SELECT `+inviteColumns+` FROM invites WHERE id = ?`, id))
+}
+
+func (d *Database) GetInviteByToken(token string) (*Invite, error) {
+	return scanInvite(d.db.QueryRow(`SELECT `+inviteColumns+` FROM invites WHERE token = ?`, token))
+}
+
+// ClaimInvite atomically consumes one use of an invite. Returns false if the
+// invite was used up by a concurrent registration.
+func (d *Database) ClaimInvite(id int64) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE invites SET uses = uses + 1
+		WHERE id = ? AND (max_uses = 0 OR uses < max_uses)`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ReleaseInvite gives back a use claimed for a registration that failed
+func (d *Database) ReleaseInvite(id int64) error {
+	_, err := d.db.Exec(`UPDATE invites SET uses = uses - 1 WHERE id = ? AND uses > 0`, id)
+	return err
+}
+
+func (d *Database) DeleteInvite(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM invites WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Job operations
+//
+// Records of the background jobs run by the jobs package, kept so what a job
+// did can be seen after it finished or the server restarted.
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a background job
+type Job struct {
+	ID         int64      `json:"id"`
+	Type       string     `json:"type"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	Progress   int        `json:"progress"` // Percent done; 0 while unknown
+	Current    int        `json:"current"`
+	Total      int        `json:"total"`
+	Message    string     `json:"message,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Result     string     `json:"-"` // JSON summary of what the job did
+	CreatedBy  *int64     `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Finished reports whether a job has stopped for good
+func (j *Job) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// JobFilter narrows a job listing
+type JobFilter struct {
+	Status string
+	Type   string
+	Limit  int
+}
+
+const jobColumns = `id, type, title, status, progress, current, total, COALESCE(message, ''), COALESCE(error, ''),
+	COALESCE(result, ''), created_by, created_at, started_at, finished_at`
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Type, &j.Title, &j.Status, &j.Progress, &j.Current, &j.Total, &j.Message, &j.Error,
+		&j.Result, &j.CreatedBy, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// CreateJob records a new queued job
+func (d *Database) CreateJob(job *Job) error {
+	job.Status = JobQueued
+	result, err := d.db.Exec(`INSERT INTO jobs (type, title, status, created_by) VALUES (?, ?, ?, ?)`,
+		job.Type, job.Title, job.Status, job.CreatedBy)
+	if err != nil {
+		return err
+	}
+	job.ID, _ = result.LastInsertId()
+	job.CreatedAt = time.Now()
+	return nil
+}
+
+// StartJob marks a job as running
+func (d *Database) StartJob(id int64) error {
+	_, err := d.db.Exec(`UPDATE jobs SET status = ?, started_at = CURRENT_TIMESTAMP WHERE id = ?`, JobRunning, id)
+	return err
+}
+
+// UpdateJobProgress records how far a running job has got
+func (d *Database) UpdateJobProgress(id int64, progress, current, total int, message string) error {
+	_, err := d.db.Exec(`UPDATE jobs SET progress = ?, current = ?, total = ?, message = ? WHERE id = ?`,
+		progress, current, total, message, id)
+	return err
+}
+
+// FinishJob records how a job ended
+func (d *Database) FinishJob(job *Job) error {
+	_, err := d.db.Exec(`
+		UPDATE jobs SET status = ?, progress = ?, current = ?, total = ?, message = ?, error = ?, result = ?,
+			finished_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		job.Status, job.Progress, job.Current, job.Total, job.Message, job.Error, job.Result, job.ID)
+	return err
+}
+
+// GetJob returns a job, or nil if there's none with the ID
+func (d *Database) GetJob(id int64) (*Job, error) {
+	job, err := scanJob(d.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// GetJobs returns jobs matching a filter, newest first
+func (d *Database) GetJobs(filter JobFilter) ([]Job, error) {
+	var where []string
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// InterruptJobs marks the jobs left queued or running when the server
+// stopped as failed, returning how many there were
+func (d *Database) InterruptJobs() (int64, error) {
+	result, err := d.db.Exec(`
+		UPDATE jobs SET status = ?, error = 'Interrupted by a server restart', finished_at = CURRENT_TIMESTAMP
+		WHERE status IN (?, ?)`, JobFailed, JobQueued, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteJobsBefore removes the records of jobs that finished more than the
+// given number of days ago
+func (d *Database) DeleteJobsBefore(days int) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM jobs WHERE finished_at < datetime('now', '-' || ? || ' days')`, days)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,187 @@
+package database
+
+import "database/sql"
+
+// Library file operations, for scans to tell which files they already know
+
+// LibraryFile is what's known about a file already in a library
+type LibraryFile struct {
+	ID      int64 // Movie or episode ID, 0 for other versions of one
+	Size    int64
+	ModTime int64 // Unix seconds, 0 if not recorded yet
+}
+
+// MediaFile is a movie or episode's file, for work done file by file
+type MediaFile struct {
+	MediaType string // movie, episode
+	MediaID   int64
+	Path      string
+}
+
+// FileStamp records a movie or episode file's size and modification time
+type FileStamp struct {
+	ID      int64
+	Size    int64
+	ModTime int64
+}
+
+// GetLibraryFiles returns the movie or episode files of a library, and the
+// other versions of its media, by path
+func (d *Database) GetLibraryFiles(libraryID int64, mediaType string) (map[string]LibraryFile, error) {
+	var query string
+	switch mediaType {
+	case "movie":
+		query = `
+			SELECT id, path, COALESCE(size, 0), COALESCE(file_mtime, 0)
+			FROM movies WHERE library_id = ? AND path != ''`
+	case "episode":
+		query = `
+			SELECT e.id, e.path, COALESCE(e.size, 0), COALESCE(e.file_mtime, 0)
+			FROM episodes e
+			JOIN seasons sea ON e.season_id = sea.id
+			JOIN shows s ON sea.show_id = s.id
+			WHERE s.library_id = ? AND e.path != ''`
+	default:
+		return map[string]LibraryFile{}, nil
+	}
+
+	files := make(map[string]LibraryFile)
+	rows, err := d.db.Query(query, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanLibraryFiles(rows, files); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.Query(`SELECT 0, path, COALESCE(size, 0), 0 FROM media_versions WHERE media_type = ?`, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanLibraryFiles(rows, files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func scanLibraryFiles(rows *sql.Rows, files map[string]LibraryFile) error {
+	defer rows.Close()
+	for rows.Next() {
+		var f LibraryFile
+		var path string
+		if err := rows.Scan(&f.ID, &path, &f.Size, &f.ModTime); err != nil {
+			return err
+		}
+		if _, ok := files[path]; !ok {
+			files[path] = f
+		}
+	}
+	return rows.Err()
+}
+
+// StampFiles records the sizes and modification times of movie or episode
+// files in one transaction
+func (d *Database) StampFiles(mediaType string, stamps []FileStamp) error {
+	if len(stamps) == 0 {
+		return nil
+	}
+	table := "movies"
+	if mediaType == "episode" {
+		table = "episodes"
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE ` + table + ` SET size = ?, file_mtime = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, stamp := range stamps {
+		if _, err := stmt.Exec(stamp.Size, stamp.ModTime, stamp.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddMovies adds movies found by a scan in one transaction. A file whose
+// title and year match a movie already in its library becomes another
+// version of that movie instead, so the same title is never added twice.
+// Returns the movies added as new rows, with their IDs set.
+func (d *Database) AddMovies(movies []*Movie) ([]*Movie, error) {
+	if len(movies) == 0 {
+		return nil, nil
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var added []*Movie
+	for _, movie := range movies {
+		var existingID int64
+		err := tx.QueryRow(`
+			SELECT id FROM movies
+			WHERE library_id = ? AND title = ? AND year = ? AND path != ''
+			ORDER BY id LIMIT 1`, movie.LibraryID, movie.Title, movie.Year).Scan(&existingID)
+		switch {
+		case err == nil:
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO media_versions (media_type, media_id, path, size, edition) VALUES ('movie', ?, ?, ?, ?)`,
+				existingID, movie.Path, movie.Size, movie.Edition); err != nil {
+				return nil, err
+			}
+			continue
+		case err != sql.ErrNoRows:
+			return nil, err
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO movies (library_id, title, year, path, size, edition) VALUES (?, ?, ?, ?, ?, ?)",
+			movie.LibraryID, movie.Title, movie.Year, movie.Path, movie.Size, movie.Edition,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movie.ID, _ = result.LastInsertId()
+		added = append(added, movie)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// AddEpisodes adds episodes found by a scan in one transaction, setting
+// their IDs
+func (d *Database) AddEpisodes(episodes []*Episode) error {
+	if len(episodes) == 0 {
+		return nil
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO episodes (season_id, episode_number, episode_end, absolute_number, title, path, size, match_confidence)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, ep := range episodes {
+		result, err := stmt.Exec(ep.SeasonID, ep.EpisodeNumber, ep.EpisodeEnd, ep.AbsoluteNumber, ep.Title, ep.Path, ep.Size, ep.MatchConfidence)
+		if err != nil {
+			return err
+		}
+		ep.ID, _ = result.LastInsertId()
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Library statistics snapshots
+//
+// The Library Stats task records each library's item count, file count and
+// size from what the scanner stored, so growth can be charted without
+// walking any folders. It runs more than once a day; a day's row is replaced
+// until the day is over, leaving the last count of each day.
+// Items are movies, shows, albums, books, audiobooks and photos; files are
+// the movie files, episodes, tracks, books, audiobook files and photos
+// behind them.
+
+// LibrarySnapshot is a library's size on a day
+type LibrarySnapshot struct {
+	Day         string `json:"day"` // YYYY-MM-DD, server time
+	LibraryID   int64  `json:"libraryId"`
+	LibraryName string `json:"libraryName"`
+	LibraryType string `json:"libraryType"`
+	Items       int    `json:"items"`
+	Files       int    `json:"files"`
+	Size        int64  `json:"size"`
+}
+
+// SnapshotLibraryStats records every library's size for today, returning how
+// many libraries were recorded
+func (d *Database) SnapshotLibraryStats() (int, error) {
+	now := time.Now()
+	result, err := d.db.Exec(`
+		INSERT OR REPLACE INTO library_stats (day, library_id, library_name, library_type, items, files, size, recorded_at)
+		SELECT ?, l.id, l.name, l.type, COALESCE(SUM(c.items), 0), COALESCE(SUM(c.files), 0), COALESCE(SUM(c.size), 0), ?
+		FROM libraries l
+		LEFT JOIN (
+			SELECT library_id, COUNT(*) AS items, COUNT(*) AS files, COALESCE(SUM(size), 0) AS size
+			FROM movies GROUP BY library_id
+			UNION ALL
+			SELECT s.library_id, COUNT(DISTINCT s.id), COUNT(e.id), COALESCE(SUM(e.size), 0)
+			FROM shows s
+			LEFT JOIN seasons sea ON sea.show_id = s.id
+			LEFT JOIN episodes e ON e.season_id = sea.id
+			GROUP BY s.library_id
+			UNION ALL
+			SELECT ar.library_id, COUNT(DISTINCT al.id), COUNT(t.id), COALESCE(SUM(t.size), 0)
+			FROM artists ar
+			JOIN albums al ON al.artist_id = ar.id
+			LEFT JOIN tracks t ON t.album_id = al.id
+			GROUP BY ar.library_id
+			UNION ALL
+			SELECT library_id, COUNT(*), COUNT(*), COALESCE(SUM(size), 0)
+			FROM books GROUP BY library_id
+			UNION ALL
+			SELECT a.library_id, COUNT(DISTINCT a.id), COUNT(f.id), COALESCE(SUM(f.size), 0)
+			FROM audiobooks a
+			LEFT JOIN audiobook_files f ON f.audiobook_id = a.id
+			GROUP BY a.library_id
+			UNION ALL
+			SELECT library_id, COUNT(*), COUNT(*), COALESCE(SUM(size), 0)
+			FROM photos GROUP BY library_id
+		) c ON c.library_id = l.id
+		GROUP BY l.id`,
+		now.In(timezone.Location()).Format("2006-01-02"), now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetLibrarySnapshots returns the snapshots between two days, inclusive,
+// oldest first. A libraryID above 0 narrows them to one library.
+func (d *Database) GetLibrarySnapshots(from, to string, libraryID int64) ([]LibrarySnapshot, error) {
+	query := `
+		SELECT day, library_id, library_name, library_type, COALESCE(items, 0), COALESCE(files, 0), COALESCE(size, 0)
+		FROM library_stats
+		WHERE day >= ? AND day <= ?`
+	args := []interface{}{from, to}
+	if libraryID > 0 {
+		query += ` AND library_id = ?`
+		args = append(args, libraryID)
+	}
+	query += ` ORDER BY day, library_id`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []LibrarySnapshot{}
+	for rows.Next() {
+		var s LibrarySnapshot
+		if err := rows.Scan(&s.Day, &s.LibraryID, &s.LibraryName, &s.LibraryType, &s.Items, &s.Files, &s.Size); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
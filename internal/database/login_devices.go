@@ -0,0 +1,43 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Login device operations
+
+// loginDeviceFingerprint identifies a device by its user agent and address
+func loginDeviceFingerprint(userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(userAgent + "|" + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+// TouchLoginDevice records a sign-in from a device. It reports whether the
+// device is new, and whether the user had signed in from any device before,
+// so the first device seen for a user isn't treated as suspicious.
+func (d *Database) TouchLoginDevice(userID int64, userAgent, ip string) (isNew, seenBefore bool, err error) {
+	fingerprint := loginDeviceFingerprint(userAgent, ip)
+
+	result, err := d.db.Exec(`
+		UPDATE login_devices SET last_seen_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND fingerprint = ?`, userID, fingerprint)
+	if err != nil {
+		return false, false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return false, true, nil
+	}
+
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM login_devices WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		return false, false, err
+	}
+	_, err = d.db.Exec(`
+		INSERT INTO login_devices (user_id, fingerprint, user_agent, ip_address) VALUES (?, ?, ?, ?)`,
+		userID, fingerprint, userAgent, ip)
+	if err != nil {
+		return false, false, err
+	}
+	return true, count > 0, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Lyrics operations
+
+// Lyrics are a track's cached lyrics
+type Lyrics struct {
+	TrackID      int64     `json:"trackId"`
+	Source       string    `json:"source"`           // Where they came from, e.g. lrclib
+	Synced       string    `json:"synced,omitempty"` // LRC
+	Plain        string    `json:"plain,omitempty"`
+	Instrumental bool      `json:"instrumental"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// Found reports whether the lookup found anything, lyrics or that the track
+// is instrumental
+func (l *Lyrics) Found() bool {
+	return l.Synced != "" || l.Plain != "" || l.Instrumental
+}
+
+// GetLyrics returns a track's cached lyrics, or nil if it hasn't been looked
+// up
+func (d *Database) GetLyrics(trackID int64) (*Lyrics, error) {
+	var l Lyrics
+	var synced, plain sql.NullString
+	err := d.db.QueryRow(`
+		SELECT track_id, source, synced, plain, instrumental, fetched_at
+		FROM lyrics WHERE track_id = ?`, trackID,
+	).Scan(&l.TrackID, &l.Source, &synced, &plain, &l.Instrumental, &l.FetchedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Synced = synced.String
+	l.Plain = plain.String
+	return &l, nil
+}
+
+// SaveLyrics caches a track's lyrics, replacing any it had
+func (d *Database) SaveLyrics(l *Lyrics) error {
+	l.FetchedAt = time.Now()
+	_, err := d.db.Exec(`
+		INSERT INTO lyrics (track_id, source, synced, plain, instrumental, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(track_id) DO UPDATE SET
+			source = excluded.source, synced = excluded.synced, plain = excluded.plain,
+			instrumental = excluded.instrumental, fetched_at = excluded.fetched_at`,
+		l.TrackID, l.Source, l.Synced, l.Plain, l.Instrumental, l.FetchedAt)
+	return err
+}
+
+// DeleteLyrics forgets a track's cached lyrics
+func (d *Database) DeleteLyrics(trackID int64) error {
+	_, err := d.db.Exec(`DELETE FROM lyrics WHERE track_id = ?`, trackID)
+	return err
+}
@@ -15,8 +15,8 @@ type OwnedEpisode struct {
 
 func (d *Database) CreateMovie(movie *Movie) error {
 	result, err := d.db.Exec(
-		"INSERT INTO movies (library_id, title, year, path, size) VALUES (?, ?, ?, ?, ?)",
-		movie.LibraryID, movie.Title, movie.Year, movie.Path, movie.Size,
+		"INSERT INTO movies (library_id, title, year, path, size, edition) VALUES (?, ?, ?, ?, ?, ?)",
+		movie.LibraryID, movie.Title, movie.Year, movie.Path, movie.Size, movie.Edition,
 	)
 	if err != nil {
 		return err
@@ -32,13 +32,13 @@ func (d *Database) UpdateMovieMetadata(movie *Movie) error {
 			runtime = ?, rating = ?, content_rating = ?, genres = ?, "cast" = ?, crew = ?,
 			director = ?, writer = ?, editor = ?, producers = ?, status = ?, budget = ?, revenue = ?,
 			country = ?, original_language = ?, theatrical_release = ?, digital_release = ?, studios = ?, trailers = ?,
-			poster_path = ?, backdrop_path = ?, focal_x = ?, focal_y = ?
+			poster_path = ?, backdrop_path = ?, focal_x = ?, focal_y = ?, artwork_path = ?, colors = ?
 		WHERE id = ?`,
 		movie.TmdbID, movie.ImdbID, movie.OriginalTitle, movie.Overview, movie.Tagline,
 		movie.Runtime, movie.Rating, movie.ContentRating, movie.Genres, movie.Cast, movie.Crew,
 		movie.Director, movie.Writer, movie.Editor, movie.Producers, movie.Status, movie.Budget, movie.Revenue,
 		movie.Country, movie.OriginalLanguage, movie.TheatricalRelease, movie.DigitalRelease, movie.Studios, movie.Trailers,
-		movie.PosterPath, movie.BackdropPath, movie.FocalX, movie.FocalY, movie.ID,
+		movie.PosterPath, movie.BackdropPath, movie.FocalX, movie.FocalY, movie.ArtworkPath, movie.Colors, movie.ID,
 	)
 	return err
 }
@@ -52,7 +52,7 @@ func (d *Database) GetMovies() ([]Movie, error) {
 	rows, err := d.db.Query(`
 		SELECT id, library_id, tmdb_id, imdb_id, title, original_title, year, overview, tagline,
 			runtime, rating, content_rating, genres, "cast", crew, director, writer, editor, producers, status, budget, revenue,
-			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, path, size, added_at, last_watched_at, play_count
+			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, size, added_at, last_watched_at, play_count, edition
 		FROM movies ORDER BY added_at DESC`)
 	if err != nil {
 		return nil, err
@@ -66,7 +66,7 @@ func (d *Database) GetMovies() ([]Movie, error) {
 			&m.Overview, &m.Tagline, &m.Runtime, &m.Rating, &m.ContentRating, &m.Genres, &m.Cast, &m.Crew,
 			&m.Director, &m.Writer, &m.Editor, &m.Producers, &m.Status, &m.Budget, &m.Revenue,
 			&m.Country, &m.OriginalLanguage, &m.TheatricalRelease, &m.DigitalRelease, &m.Studios, &m.Trailers,
-			&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount); err != nil {
+			&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.ArtworkPath, &m.Colors, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount, &m.Edition); err != nil {
 			return nil, err
 		}
 		movies = append(movies, m)
@@ -117,13 +117,13 @@ func (d *Database) GetMovieByPath(path string) (*Movie, error) {
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, imdb_id, title, original_title, year, overview, tagline,
 			runtime, rating, content_rating, genres, "cast", crew, director, writer, editor, producers, status, budget, revenue,
-			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, path, size, added_at, last_watched_at, play_count
+			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, size, added_at, last_watched_at, play_count, edition
 		FROM movies WHERE path = ?`, path,
 	).Scan(&m.ID, &m.LibraryID, &m.TmdbID, &m.ImdbID, &m.Title, &m.OriginalTitle, &m.Year,
 		&m.Overview, &m.Tagline, &m.Runtime, &m.Rating, &m.ContentRating, &m.Genres, &m.Cast, &m.Crew,
 		&m.Director, &m.Writer, &m.Editor, &m.Producers, &m.Status, &m.Budget, &m.Revenue,
 		&m.Country, &m.OriginalLanguage, &m.TheatricalRelease, &m.DigitalRelease, &m.Studios, &m.Trailers,
-		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount)
+		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.ArtworkPath, &m.Colors, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount, &m.Edition)
 	if err != nil {
 		return nil, err
 	}
@@ -149,11 +149,11 @@ func (d *Database) UpdateShowMetadata(show *Show) error {
 		UPDATE shows SET
 			tmdb_id = ?, tvdb_id = ?, imdb_id = ?, original_title = ?, year = ?, overview = ?,
 			status = ?, rating = ?, content_rating = ?, genres = ?, "cast" = ?, crew = ?,
-			network = ?, poster_path = ?, backdrop_path = ?, focal_x = ?, focal_y = ?
+			network = ?, poster_path = ?, backdrop_path = ?, focal_x = ?, focal_y = ?, artwork_path = ?, colors = ?
 		WHERE id = ?`,
 		show.TmdbID, show.TvdbID, show.ImdbID, show.OriginalTitle, show.Year, show.Overview,
 		show.Status, show.Rating, show.ContentRating, show.Genres, show.Cast, show.Crew,
-		show.Network, show.PosterPath, show.BackdropPath, show.FocalX, show.FocalY, show.ID,
+		show.Network, show.PosterPath, show.BackdropPath, show.FocalX, show.FocalY, show.ArtworkPath, show.Colors, show.ID,
 	)
 	return err
 }
@@ -161,7 +161,7 @@ func (d *Database) UpdateShowMetadata(show *Show) error {
 func (d *Database) GetShows() ([]Show, error) {
 	rows, err := d.db.Query(`
 		SELECT id, library_id, tmdb_id, tvdb_id, imdb_id, title, original_title, year,
-			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, path, added_at
+			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, added_at
 		FROM shows ORDER BY added_at DESC`)
 	if err != nil {
 		return nil, err
@@ -174,7 +174,7 @@ func (d *Database) GetShows() ([]Show, error) {
 		var addedAt sql.NullTime
 		if err := rows.Scan(&s.ID, &s.LibraryID, &s.TmdbID, &s.TvdbID, &s.ImdbID, &s.Title, &s.OriginalTitle, &s.Year,
 			&s.Overview, &s.Status, &s.Rating, &s.ContentRating, &s.Genres, &s.Cast, &s.Crew,
-			&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.Path, &addedAt); err != nil {
+			&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.ArtworkPath, &s.Colors, &s.Path, &addedAt); err != nil {
 			return nil, err
 		}
 		if addedAt.Valid {
@@ -190,11 +190,11 @@ func (d *Database) GetShowByPath(path string) (*Show, error) {
 	var addedAt sql.NullTime
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, tvdb_id, imdb_id, title, original_title, year,
-			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, path, added_at
+			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, added_at
 		FROM shows WHERE path = ?`, path,
 	).Scan(&s.ID, &s.LibraryID, &s.TmdbID, &s.TvdbID, &s.ImdbID, &s.Title, &s.OriginalTitle, &s.Year,
 		&s.Overview, &s.Status, &s.Rating, &s.ContentRating, &s.Genres, &s.Cast, &s.Crew,
-		&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.Path, &addedAt)
+		&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.ArtworkPath, &s.Colors, &s.Path, &addedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -209,11 +209,11 @@ func (d *Database) GetShow(id int64) (*Show, error) {
 	var addedAt sql.NullTime
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, tvdb_id, imdb_id, title, original_title, year,
-			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, path, added_at
+			overview, status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, added_at
 		FROM shows WHERE id = ?`, id,
 	).Scan(&s.ID, &s.LibraryID, &s.TmdbID, &s.TvdbID, &s.ImdbID, &s.Title, &s.OriginalTitle, &s.Year,
 		&s.Overview, &s.Status, &s.Rating, &s.ContentRating, &s.Genres, &s.Cast, &s.Crew,
-		&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.Path, &addedAt)
+		&s.Network, &s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.ArtworkPath, &s.Colors, &s.Path, &addedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -465,6 +465,12 @@ func (d *Database) UpdateEpisodeSize(id int64, size int64) error {
 	return err
 }
 
+// UpdateMovieSize records a movie file's new size after it changed
+func (d *Database) UpdateMovieSize(id int64, size int64) error {
+	_, err := d.db.Exec("UPDATE movies SET size = ? WHERE id = ?", size, id)
+	return err
+}
+
 func (d *Database) GetEpisodesWithMissingSize() ([]Episode, error) {
 	rows, err := d.db.Query(`
 		SELECT id, season_id, episode_number, title, overview, air_date, runtime, still_path, path, size
@@ -490,13 +496,13 @@ func (d *Database) GetMovie(id int64) (*Movie, error) {
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, imdb_id, title, original_title, year, overview, tagline,
 			runtime, rating, content_rating, genres, "cast", crew, director, writer, editor, producers, status, budget, revenue,
-			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, path, size, added_at, last_watched_at, play_count
+			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, size, added_at, last_watched_at, play_count, edition
 		FROM movies WHERE id = ?`, id,
 	).Scan(&m.ID, &m.LibraryID, &m.TmdbID, &m.ImdbID, &m.Title, &m.OriginalTitle, &m.Year,
 		&m.Overview, &m.Tagline, &m.Runtime, &m.Rating, &m.ContentRating, &m.Genres, &m.Cast, &m.Crew,
 		&m.Director, &m.Writer, &m.Editor, &m.Producers, &m.Status, &m.Budget, &m.Revenue,
 		&m.Country, &m.OriginalLanguage, &m.TheatricalRelease, &m.DigitalRelease, &m.Studios, &m.Trailers,
-		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount)
+		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.ArtworkPath, &m.Colors, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount, &m.Edition)
 	if err != nil {
 		return nil, err
 	}
@@ -505,10 +511,43 @@ func (d *Database) GetMovie(id int64) (*Movie, error) {
 
 // DeleteMovie removes a movie from the database
 func (d *Database) DeleteMovie(id int64) error {
+	if _, err := d.db.Exec("DELETE FROM media_versions WHERE media_type = 'movie' AND media_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := d.db.Exec("DELETE FROM media_tags WHERE media_type = 'movie' AND media_id = ?", id); err != nil {
+		return err
+	}
 	_, err := d.db.Exec("DELETE FROM movies WHERE id = ?", id)
 	return err
 }
 
+// DeleteShow removes a show from the database with its seasons and episodes
+func (d *Database) DeleteShow(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM media_versions WHERE media_type = 'episode' AND media_id IN (
+			SELECT e.id FROM episodes e JOIN seasons sea ON e.season_id = sea.id WHERE sea.show_id = ?)`,
+		`DELETE FROM episodes WHERE season_id IN (SELECT id FROM seasons WHERE show_id = ?)`,
+		`DELETE FROM seasons WHERE show_id = ?`,
+		`DELETE FROM media_quality_override WHERE media_type = 'show' AND media_id = ?`,
+		`DELETE FROM media_tags WHERE media_type = 'show' AND media_id = ?`,
+		`DELETE FROM airing_searches WHERE show_id = ?`,
+		`DELETE FROM airing_followers WHERE show_id = ?`,
+		`DELETE FROM airing_shows WHERE show_id = ?`,
+		`DELETE FROM shows WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // GetMoviesByLibrary retrieves all movies for a library (for cleanup)
 func (d *Database) GetMoviesByLibrary(libraryID int64) ([]Movie, error) {
 	rows, err := d.db.Query(`SELECT id, title, path, missing_since FROM movies WHERE library_id = ?`, libraryID)
@@ -534,13 +573,13 @@ func (d *Database) GetMovieByTmdb(tmdbID int64) (*Movie, error) {
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, imdb_id, title, original_title, year, overview, tagline,
 			runtime, rating, content_rating, genres, "cast", crew, director, writer, editor, producers, status, budget, revenue,
-			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, path, size, added_at, last_watched_at, play_count
+			country, original_language, theatrical_release, digital_release, studios, trailers, poster_path, backdrop_path, focal_x, focal_y, artwork_path, colors, path, size, added_at, last_watched_at, play_count, edition
 		FROM movies WHERE tmdb_id = ?`, tmdbID,
 	).Scan(&m.ID, &m.LibraryID, &m.TmdbID, &m.ImdbID, &m.Title, &m.OriginalTitle, &m.Year,
 		&m.Overview, &m.Tagline, &m.Runtime, &m.Rating, &m.ContentRating, &m.Genres, &m.Cast, &m.Crew,
 		&m.Director, &m.Writer, &m.Editor, &m.Producers, &m.Status, &m.Budget, &m.Revenue,
 		&m.Country, &m.OriginalLanguage, &m.TheatricalRelease, &m.DigitalRelease, &m.Studios, &m.Trailers,
-		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount)
+		&m.PosterPath, &m.BackdropPath, &m.FocalX, &m.FocalY, &m.ArtworkPath, &m.Colors, &m.Path, &m.Size, &m.AddedAt, &m.LastWatchedAt, &m.PlayCount, &m.Edition)
 	if err != nil {
 		return nil, err
 	}
@@ -553,17 +592,33 @@ func (d *Database) GetShowByTmdb(tmdbID int64) (*Show, error) {
 	err := d.db.QueryRow(`
 		SELECT id, library_id, tmdb_id, tvdb_id, imdb_id, title, original_title, year, overview,
 			status, rating, content_rating, genres, "cast", crew, network, poster_path, backdrop_path,
-			focal_x, focal_y, path, added_at
+			focal_x, focal_y, artwork_path, colors, path, added_at
 		FROM shows WHERE tmdb_id = ?`, tmdbID,
 	).Scan(&s.ID, &s.LibraryID, &s.TmdbID, &s.TvdbID, &s.ImdbID, &s.Title, &s.OriginalTitle, &s.Year,
 		&s.Overview, &s.Status, &s.Rating, &s.ContentRating, &s.Genres, &s.Cast, &s.Crew, &s.Network,
-		&s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.Path, &s.AddedAt)
+		&s.PosterPath, &s.BackdropPath, &s.FocalX, &s.FocalY, &s.ArtworkPath, &s.Colors, &s.Path, &s.AddedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &s, nil
 }
 
+// GetEpisodeRuntime returns the average episode runtime in minutes for a show,
+// and how many episodes the given season has (season < 0 counts every season).
+// Runtime is 0 when no episode has one.
+func (d *Database) GetEpisodeRuntime(tmdbID int64, season int) (runtime int, episodes int, err error) {
+	var avg float64
+	err = d.db.QueryRow(`
+		SELECT COALESCE(AVG(NULLIF(e.runtime, 0)), 0),
+			COALESCE(SUM(CASE WHEN ? < 0 OR s.season_number = ? THEN 1 ELSE 0 END), 0)
+		FROM episodes e
+		JOIN seasons s ON e.season_id = s.id
+		JOIN shows sh ON s.show_id = sh.id
+		WHERE sh.tmdb_id = ?`, season, season, tmdbID,
+	).Scan(&avg, &episodes)
+	return int(avg), episodes, err
+}
+
 // UpdateMoviePlayCount increments the play count and updates last watched time
 func (d *Database) UpdateMoviePlayCount(id int64) error {
 	now := time.Now().Format(time.RFC3339)
@@ -757,6 +812,48 @@ func (d *Database) CreateEpisodeWithExtras(ep *Episode) error {
 	return nil
 }
 
+// HasMovieEdition reports whether the library has an edition of a movie, as
+// its main file or another version. Files with no edition count as the
+// theatrical edition.
+func (d *Database) HasMovieEdition(tmdbID int64, edition string) (bool, error) {
+	var n int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM movies m
+		WHERE m.tmdb_id = ? AND (
+			COALESCE(m.edition, 'theatrical') = ?
+			OR EXISTS (SELECT 1 FROM media_versions v
+			           WHERE v.media_type = 'movie' AND v.media_id = m.id AND COALESCE(v.edition, 'theatrical') = ?))`,
+		tmdbID, edition, edition).Scan(&n)
+	return n > 0, err
+}
+
+// GetMoviePathsWithoutEdition returns the paths of a library's movies with no
+// edition recorded, by movie ID
+func (d *Database) GetMoviePathsWithoutEdition(libraryID int64) (map[int64]string, error) {
+	rows, err := d.db.Query(`SELECT id, path FROM movies WHERE library_id = ? AND edition IS NULL AND path != ''`, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		paths[id] = path
+	}
+	return paths, rows.Err()
+}
+
+// SetMovieEdition records the edition of a movie's file
+func (d *Database) SetMovieEdition(id int64, edition string) error {
+	_, err := d.db.Exec(`UPDATE movies SET edition = ? WHERE id = ?`, edition, id)
+	return err
+}
+
 // SetMovieMatchConfidence updates the match confidence and review flag for a movie
 func (d *Database) SetMovieMatchConfidence(id int64, confidence float64, needsReview bool) error {
 	reviewInt := 0
@@ -778,3 +875,28 @@ func (d *Database) SetShowMatchConfidence(id int64, confidence float64, needsRev
 		confidence, reviewInt, id)
 	return err
 }
+
+// mediaLibraryQueries find the library of each kind of media by its ID
+var mediaLibraryQueries = map[string]string{
+	"movie":          `SELECT library_id FROM movies WHERE id = ?`,
+	"show":           `SELECT library_id FROM shows WHERE id = ?`,
+	"season":         `SELECT sh.library_id FROM seasons se JOIN shows sh ON sh.id = se.show_id WHERE se.id = ?`,
+	"episode":        `SELECT sh.library_id FROM episodes e JOIN seasons se ON se.id = e.season_id JOIN shows sh ON sh.id = se.show_id WHERE e.id = ?`,
+	"artist":         `SELECT library_id FROM artists WHERE id = ?`,
+	"album":          `SELECT ar.library_id FROM albums al JOIN artists ar ON ar.id = al.artist_id WHERE al.id = ?`,
+	"track":          `SELECT ar.library_id FROM tracks t JOIN albums al ON al.id = t.album_id JOIN artists ar ON ar.id = al.artist_id WHERE t.id = ?`,
+	"book":           `SELECT library_id FROM books WHERE id = ?`,
+	"audiobook_file": `SELECT a.library_id FROM audiobook_files f JOIN audiobooks a ON a.id = f.audiobook_id WHERE f.id = ?`,
+}
+
+// GetMediaLibraryID returns the library a movie, show, season, episode,
+// artist, album, track, book or audiobook file is in. ok is false for other
+// kinds of media, which aren't in libraries.
+func (d *Database) GetMediaLibraryID(mediaType string, id int64) (libraryID int64, ok bool, err error) {
+	query, ok := mediaLibraryQueries[mediaType]
+	if !ok {
+		return 0, false, nil
+	}
+	err = d.db.QueryRow(query, id).Scan(&libraryID)
+	return libraryID, true, err
+}
@@ -18,3 +18,37 @@ func (d *Database) GetShowsByLibrary(libraryID int64) ([]Show, error) {
 	}
 	return shows, nil
 }
+
+// SeasonFileStats counts the episode files of one season of a show
+type SeasonFileStats struct {
+	ShowID       int64 `json:"showId"`
+	SeasonNumber int   `json:"seasonNumber"`
+	EpisodeFiles int   `json:"episodeFiles"`
+	Size         int64 `json:"size"`
+}
+
+// GetSeasonFileStats counts episode files and their size for every season
+// of every show. Episodes whose file has gone missing aren't counted.
+func (d *Database) GetSeasonFileStats() ([]SeasonFileStats, error) {
+	rows, err := d.db.Query(`
+		SELECT sea.show_id, sea.season_number,
+		       COUNT(e.id), COALESCE(SUM(e.size), 0)
+		FROM seasons sea
+		LEFT JOIN episodes e ON e.season_id = sea.id AND e.missing_since IS NULL
+		GROUP BY sea.show_id, sea.season_number
+		ORDER BY sea.show_id, sea.season_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []SeasonFileStats
+	for rows.Next() {
+		var st SeasonFileStats
+		if err := rows.Scan(&st.ShowID, &st.SeasonNumber, &st.EpisodeFiles, &st.Size); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
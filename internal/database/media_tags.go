@@ -0,0 +1,92 @@
+package database
+
+import "strings"
+
+// Media tag operations
+//
+// Admins tag movies and shows to group them however suits them, for
+// example in bulk from /api/library/bulk.
+
+// TagCount is a tag and how many movies and shows have it
+type TagCount struct {
+	Tag    string `json:"tag"`
+	Movies int    `json:"movies"`
+	Shows  int    `json:"shows"`
+}
+
+// AddMediaTags tags a movie or show. Tags it already has are left as they are.
+func (d *Database) AddMediaTags(mediaType string, mediaID int64, tags []string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO media_tags (media_type, media_id, tag) VALUES (?, ?, ?)`,
+			mediaType, mediaID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetMediaTags returns a movie or show's tags in alphabetical order
+func (d *Database) GetMediaTags(mediaType string, mediaID int64) ([]string, error) {
+	rows, err := d.db.Query(`SELECT tag FROM media_tags WHERE media_type = ? AND media_id = ? ORDER BY tag`,
+		mediaType, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTagCounts returns every tag in use with how many movies and shows have it
+func (d *Database) GetTagCounts() ([]TagCount, error) {
+	rows, err := d.db.Query(`
+		SELECT tag,
+			SUM(CASE WHEN media_type = 'movie' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN media_type = 'show' THEN 1 ELSE 0 END)
+		FROM media_tags GROUP BY tag ORDER BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Tag, &c.Movies, &c.Shows); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// NormalizeTags trims tags and drops empty and repeated ones, keeping their
+// order
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, tag)
+	}
+	return out
+}
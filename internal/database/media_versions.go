@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Media version operations
+//
+// A version is an extra file of a movie or episode. The row's own path is the
+// main file; versions come from merging duplicate rows, such as another
+// edition of a movie.
+
+// MediaVersion is an extra file of a movie or episode
+type MediaVersion struct {
+	ID        int64     `json:"id"`
+	MediaType string    `json:"mediaType"` // movie, episode
+	MediaID   int64     `json:"mediaId"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	Edition   *string   `json:"edition,omitempty"` // Movie edition of the file, e.g. "extended"
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// AddMediaVersion records an extra file of a movie or episode. A path that's
+// already a version is left alone.
+func (d *Database) AddMediaVersion(mediaType string, mediaID int64, path string, size int64) error {
+	_, err := d.db.Exec(`
+		INSERT OR IGNORE INTO media_versions (media_type, media_id, path, size)
+		VALUES (?, ?, ?, ?)`, mediaType, mediaID, path, size)
+	return err
+}
+
+// GetMediaVersions returns the extra files of a movie or episode
+func (d *Database) GetMediaVersions(mediaType string, mediaID int64) ([]MediaVersion, error) {
+	return d.queryMediaVersions(`
+		SELECT id, media_type, media_id, path, COALESCE(size, 0), edition, added_at
+		FROM media_versions WHERE media_type = ? AND media_id = ?
+		ORDER BY added_at`, mediaType, mediaID)
+}
+
+// GetAllMediaVersions returns every extra file in the library
+func (d *Database) GetAllMediaVersions() ([]MediaVersion, error) {
+	return d.queryMediaVersions(`
+		SELECT id, media_type, media_id, path, COALESCE(size, 0), edition, added_at
+		FROM media_versions ORDER BY id`)
+}
+
+func (d *Database) queryMediaVersions(query string, args ...interface{}) ([]MediaVersion, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []MediaVersion
+	for rows.Next() {
+		var v MediaVersion
+		if err := rows.Scan(&v.ID, &v.MediaType, &v.MediaID, &v.Path, &v.Size, &v.Edition, &v.AddedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// IsMediaVersionPath reports whether a file is already known as a version, so
+// scans don't add it back as a new movie or episode
+func (d *Database) IsMediaVersionPath(path string) bool {
+	var id int64
+	err := d.db.QueryRow(`SELECT id FROM media_versions WHERE path = ?`, path).Scan(&id)
+	return err == nil
+}
+
+// DeleteMediaVersion removes a version (the file itself is left alone)
+func (d *Database) DeleteMediaVersion(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM media_versions WHERE id = ?`, id)
+	return err
+}
+
+// DeleteOrphanedMediaVersions removes versions whose movie or episode is gone
+func (d *Database) DeleteOrphanedMediaVersions() (int64, error) {
+	result, err := d.db.Exec(`
+		DELETE FROM media_versions
+		WHERE (media_type = 'movie' AND media_id NOT IN (SELECT id FROM movies))
+		   OR (media_type = 'episode' AND media_id NOT IN (SELECT id FROM episodes))`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PromoteMediaVersion makes a version the main file of its movie or episode,
+// replacing a main file that has gone missing
+func (d *Database) PromoteMediaVersion(v *MediaVersion) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM media_versions WHERE id = ?`, v.ID); err != nil {
+		return err
+	}
+	var result sql.Result
+	if v.MediaType == "episode" {
+		result, err = tx.Exec(`UPDATE episodes SET path = ?, size = ?, missing_since = NULL WHERE id = ?`,
+			v.Path, v.Size, v.MediaID)
+	} else {
+		result, err = tx.Exec(`UPDATE movies SET path = ?, size = ?, edition = ?, missing_since = NULL WHERE id = ?`,
+			v.Path, v.Size, v.Edition, v.MediaID)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Movie release date operations
+//
+// Release dates of wanted movies are cached so searches can wait for the
+// digital release without asking TMDB on every pass.
+
+// MovieReleaseDates holds the US release dates of a movie as reported by TMDB
+type MovieReleaseDates struct {
+	TmdbID            int64     `json:"tmdbId"`
+	TheatricalRelease string    `json:"theatricalRelease,omitempty"`
+	DigitalRelease    string    `json:"digitalRelease,omitempty"`
+	CheckedAt         time.Time `json:"checkedAt"`
+}
+
+// GetMovieReleaseDates returns the cached release dates of a movie, or nil if
+// they haven't been looked up
+func (d *Database) GetMovieReleaseDates(tmdbID int64) (*MovieReleaseDates, error) {
+	dates := MovieReleaseDates{TmdbID: tmdbID}
+	var theatrical, digital sql.NullString
+	err := d.db.QueryRow(`
+		SELECT theatrical_release, digital_release, checked_at
+		FROM movie_release_dates WHERE tmdb_id = ?`, tmdbID,
+	).Scan(&theatrical, &digital, &dates.CheckedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dates.TheatricalRelease = theatrical.String
+	dates.DigitalRelease = digital.String
+	return &dates, nil
+}
+
+// SaveMovieReleaseDates caches the release dates of a movie
+func (d *Database) SaveMovieReleaseDates(tmdbID int64, theatrical, digital string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO movie_release_dates (tmdb_id, theatrical_release, digital_release, checked_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(tmdb_id) DO UPDATE SET
+			theatrical_release = excluded.theatrical_release,
+			digital_release = excluded.digital_release,
+			checked_at = CURRENT_TIMESTAMP`, tmdbID, theatrical, digital)
+	return err
+}
+
+// CleanupMovieReleaseDates removes cached dates for movies that are no longer
+// wanted, waiting on a request or denied until release
+func (d *Database) CleanupMovieReleaseDates() error {
+	_, err := d.db.Exec(`
+		DELETE FROM movie_release_dates
+		WHERE tmdb_id NOT IN (SELECT tmdb_id FROM wanted WHERE type = 'movie')
+		  AND tmdb_id NOT IN (SELECT tmdb_id FROM requests WHERE type = 'movie' AND status IN ('approved', 'processing'))
+		  AND tmdb_id NOT IN (SELECT tmdb_id FROM requests WHERE type = 'movie' AND status = 'denied' AND recheck_after_release = 1)`)
+	return err
+}
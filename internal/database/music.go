@@ -0,0 +1,134 @@
+package database
+
+import "time"
+
+// Music listening operations
+//
+// Plays of music tracks are kept per user, and per profile when one is
+// active, so instant mixes can lean towards what a listener plays often and
+// away from what they just heard.
+
+// MixTrack is a track with the artist and album details mixes use
+type MixTrack struct {
+	Track
+	ArtistID   int64   `json:"artistId"`
+	ArtistName string  `json:"artistName"`
+	AlbumTitle string  `json:"albumTitle"`
+	AlbumYear  int     `json:"albumYear,omitempty"`
+	CoverPath  *string `json:"coverPath,omitempty"`
+	LibraryID  int64   `json:"libraryId"`
+}
+
+// TrackPlayStats is how often and how recently a listener played a track
+type TrackPlayStats struct {
+	Plays      int
+	LastPlayed time.Time
+}
+
+// GetMixTracks returns every track with its artist and album
+func (d *Database) GetMixTracks() ([]MixTrack, error) {
+	rows, err := d.db.Query(`
+		SELECT t.id, t.album_id, t.musicbrainz_id, t.title, t.track_number, t.disc_number, t.duration, t.path, t.size,
+			t.genre, t.bpm, ar.id, ar.name, al.title, COALESCE(al.year, 0), al.cover_path, ar.library_id
+		FROM tracks t
+		JOIN albums al ON al.id = t.album_id
+		JOIN artists ar ON ar.id = al.artist_id
+		ORDER BY ar.name, al.year, t.disc_number, t.track_number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []MixTrack
+	for rows.Next() {
+		var t MixTrack
+		if err := rows.Scan(&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size,
+			&t.Genre, &t.BPM, &t.ArtistID, &t.ArtistName, &t.AlbumTitle, &t.AlbumYear, &t.CoverPath, &t.LibraryID); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, rows.Err()
+}
+
+// RecordTrackPlay records that a user, on a profile if one is active, played
+// a track
+func (d *Database) RecordTrackPlay(userID int64, profileID *int64, trackID int64) error {
+	_, err := d.db.Exec(`INSERT INTO track_plays (user_id, profile_id, track_id) VALUES (?, ?, ?)`,
+		userID, profileID, trackID)
+	return err
+}
+
+// GetTrackPlayStats returns a listener's plays by track. With a profile only
+// that profile's plays count.
+func (d *Database) GetTrackPlayStats(userID int64, profileID *int64) (map[int64]TrackPlayStats, error) {
+	query := `SELECT track_id, COUNT(*), MAX(played_at) FROM track_plays WHERE user_id = ?`
+	args := []interface{}{userID}
+	if profileID != nil {
+		query += ` AND profile_id = ?`
+		args = append(args, *profileID)
+	}
+	rows, err := d.db.Query(query+` GROUP BY track_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int64]TrackPlayStats)
+	for rows.Next() {
+		var trackID int64
+		var s TrackPlayStats
+		var lastPlayed string
+		if err := rows.Scan(&trackID, &s.Plays, &lastPlayed); err != nil {
+			return nil, err
+		}
+		if parsed, err := time.Parse("2006-01-02 15:04:05", lastPlayed); err == nil {
+			s.LastPlayed = parsed
+		}
+		stats[trackID] = s
+	}
+	return stats, rows.Err()
+}
+
+// AlbumPlay is the track of an album a listener played last
+type AlbumPlay struct {
+	AlbumID  int64
+	TrackID  int64
+	PlayedAt time.Time
+}
+
+// GetRecentAlbumPlays returns the albums a listener played a track of since
+// a time, with the track played last, most recently played first. With a
+// profile only that profile's plays count.
+func (d *Database) GetRecentAlbumPlays(userID int64, profileID *int64, since time.Time, limit int) ([]AlbumPlay, error) {
+	query := `
+		SELECT t.album_id, tp.track_id, MAX(tp.played_at)
+		FROM track_plays tp
+		JOIN tracks t ON t.id = tp.track_id
+		WHERE tp.user_id = ? AND tp.played_at > ?`
+	args := []interface{}{userID, since.UTC().Format("2006-01-02 15:04:05")}
+	if profileID != nil {
+		query += ` AND tp.profile_id = ?`
+		args = append(args, *profileID)
+	}
+	// SQLite takes the bare track_id from the row holding the MAX
+	rows, err := d.db.Query(query+` GROUP BY t.album_id ORDER BY MAX(tp.played_at) DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plays []AlbumPlay
+	for rows.Next() {
+		var p AlbumPlay
+		var playedAt string
+		if err := rows.Scan(&p.AlbumID, &p.TrackID, &playedAt); err != nil {
+			return nil, err
+		}
+		if parsed, err := time.Parse("2006-01-02 15:04:05", playedAt); err == nil {
+			p.PlayedAt = parsed
+		}
+		plays = append(plays, p)
+	}
+	return plays, rows.Err()
+}
@@ -0,0 +1,77 @@
+package database
+
+// Music metadata operations
+//
+// Artists and albums are looked up on MusicBrainz once, by the Music
+// Metadata task, and again when refreshed by hand. MusicBrainz IDs tagged in
+// the files, as taggers like Picard write them, are kept as they're scanned
+// so the lookups don't have to search.
+
+// UpdateTrackMetadata saves a track's MusicBrainz recording and the title,
+// numbering and length its release gives it
+func (d *Database) UpdateTrackMetadata(track *Track) error {
+	_, err := d.db.Exec(`
+		UPDATE tracks SET musicbrainz_id = ?, title = ?, track_number = ?, disc_number = ?, duration = ?
+		WHERE id = ?`,
+		track.MusicBrainzID, track.Title, track.TrackNumber, track.DiscNumber, track.Duration, track.ID,
+	)
+	return err
+}
+
+// SetArtistMusicBrainzID records the MusicBrainz ID an artist's files are
+// tagged with, unless it already has one
+func (d *Database) SetArtistMusicBrainzID(id int64, mbid string) error {
+	_, err := d.db.Exec(`UPDATE artists SET musicbrainz_id = ? WHERE id = ? AND musicbrainz_id IS NULL`, mbid, id)
+	return err
+}
+
+// SetAlbumMusicBrainzID records the MusicBrainz release an album's files are
+// tagged with, unless it already has one
+func (d *Database) SetAlbumMusicBrainzID(id int64, mbid string) error {
+	_, err := d.db.Exec(`UPDATE albums SET musicbrainz_id = ? WHERE id = ? AND musicbrainz_id IS NULL`, mbid, id)
+	return err
+}
+
+// GetArtistsWithoutMetadata returns artists never looked up on MusicBrainz
+func (d *Database) GetArtistsWithoutMetadata(limit int) ([]Artist, error) {
+	rows, err := d.db.Query(`
+		SELECT id, library_id, musicbrainz_id, name, sort_name, overview, image_path, path
+		FROM artists WHERE metadata_checked_at IS NULL
+		ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artists []Artist
+	for rows.Next() {
+		var a Artist
+		if err := rows.Scan(&a.ID, &a.LibraryID, &a.MusicBrainzID, &a.Name, &a.SortName, &a.Overview, &a.ImagePath, &a.Path); err != nil {
+			return nil, err
+		}
+		artists = append(artists, a)
+	}
+	return artists, rows.Err()
+}
+
+// GetAlbumsWithoutMetadata returns albums never looked up on MusicBrainz
+func (d *Database) GetAlbumsWithoutMetadata(limit int) ([]Album, error) {
+	rows, err := d.db.Query(`
+		SELECT id, artist_id, musicbrainz_id, release_group_id, release_type, title, year, overview, cover_path, path
+		FROM albums WHERE metadata_checked_at IS NULL
+		ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var albums []Album
+	for rows.Next() {
+		var a Album
+		if err := rows.Scan(&a.ID, &a.ArtistID, &a.MusicBrainzID, &a.ReleaseGroupID, &a.ReleaseType, &a.Title, &a.Year, &a.Overview, &a.CoverPath, &a.Path); err != nil {
+			return nil, err
+		}
+		albums = append(albums, a)
+	}
+	return albums, rows.Err()
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Naming samples
+//
+// Real library items that naming templates are previewed against, picked to
+// cover the cases templates tend to get wrong.
+
+// Naming sample kinds
+const (
+	NamingSampleMovie        = "movie"
+	NamingSampleEpisode      = "episode"
+	NamingSampleMultiEpisode = "multi_episode"
+	NamingSampleSpecial      = "special"
+	NamingSampleAbsolute     = "absolute" // Anime with absolute episode numbers
+	NamingSampleDaily        = "daily"    // Episodes with air dates
+)
+
+// NamingSample is a movie or episode to preview a naming template against
+type NamingSample struct {
+	Kind         string `json:"kind"`
+	MediaType    string `json:"mediaType"` // movie or episode
+	MediaID      int64  `json:"mediaId"`
+	Title        string `json:"title"` // The movie or show title
+	Year         int    `json:"year,omitempty"`
+	Season       int    `json:"season,omitempty"`
+	Episode      int    `json:"episode,omitempty"`
+	EpisodeEnd   int    `json:"episodeEnd,omitempty"`
+	Absolute     int    `json:"absolute,omitempty"`
+	EpisodeTitle string `json:"episodeTitle,omitempty"`
+	AirDate      string `json:"airDate,omitempty"`
+	Path         string `json:"path"`
+}
+
+// episodeSampleFilters picks the episodes of each kind
+var episodeSampleFilters = map[string]string{
+	NamingSampleEpisode:      "se.season_number > 0 AND e.episode_end IS NULL AND e.absolute_number IS NULL",
+	NamingSampleMultiEpisode: "e.episode_end IS NOT NULL AND e.episode_end > e.episode_number",
+	NamingSampleSpecial:      "se.season_number = 0",
+	NamingSampleAbsolute:     "e.absolute_number IS NOT NULL",
+	NamingSampleDaily:        "se.season_number > 0 AND e.air_date IS NOT NULL AND e.air_date != ''",
+}
+
+// GetMovieNamingSamples returns the most recently added movies
+func (d *Database) GetMovieNamingSamples(limit int) ([]NamingSample, error) {
+	rows, err := d.db.Query(`
+		SELECT id, title, COALESCE(year, 0), path
+		FROM movies
+		WHERE missing_since IS NULL
+		ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []NamingSample
+	for rows.Next() {
+		s := NamingSample{Kind: NamingSampleMovie, MediaType: "movie"}
+		if err := rows.Scan(&s.MediaID, &s.Title, &s.Year, &s.Path); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// GetEpisodeNamingSamples returns recently added episodes of a kind, from
+// different shows where there are enough
+func (d *Database) GetEpisodeNamingSamples(kind string, limit int) ([]NamingSample, error) {
+	filter, ok := episodeSampleFilters[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown naming sample kind %q", kind)
+	}
+
+	rows, err := d.db.Query(`
+		SELECT e.id, sh.id, sh.title, COALESCE(sh.year, 0), se.season_number, e.episode_number,
+		       e.episode_end, e.absolute_number, COALESCE(e.title, ''), COALESCE(e.air_date, ''), e.path
+		FROM episodes e
+		JOIN seasons se ON se.id = e.season_id
+		JOIN shows sh ON sh.id = se.show_id
+		WHERE e.missing_since IS NULL AND `+filter+`
+		ORDER BY e.id DESC LIMIT ?
+	`, limit*10)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples, repeats []NamingSample
+	shows := make(map[int64]bool)
+	for rows.Next() {
+		s := NamingSample{Kind: kind, MediaType: "episode"}
+		var showID int64
+		var episodeEnd, absolute sql.NullInt64
+		if err := rows.Scan(&s.MediaID, &showID, &s.Title, &s.Year, &s.Season, &s.Episode,
+			&episodeEnd, &absolute, &s.EpisodeTitle, &s.AirDate, &s.Path); err != nil {
+			return nil, err
+		}
+		s.EpisodeEnd = int(episodeEnd.Int64)
+		s.Absolute = int(absolute.Int64)
+		if shows[showID] {
+			repeats = append(repeats, s)
+			continue
+		}
+		shows[showID] = true
+		samples = append(samples, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	samples = append(samples, repeats...)
+	if len(samples) > limit {
+		samples = samples[:limit]
+	}
+	return samples, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Notification provider operations
+//
+// Providers send notifications outside Outpost, to Discord, Telegram,
+// Pushover, a webhook or an email address. Each provider's settings are kept
+// as JSON since every type needs different ones, and its events as a
+// comma-separated list.
+
+// NotificationProvider is an outbound notification channel
+type NotificationProvider struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Config    map[string]string `json:"config"`
+	Events    []string          `json:"events"`
+	Enabled   bool              `json:"enabled"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Sends reports whether the provider is enabled and sends an event
+func (p *NotificationProvider) Sends(event string) bool {
+	return p.Enabled && slices.Contains(p.Events, event)
+}
+
+const notificationProviderColumns = "id, name, type, config, events, enabled, created_at, updated_at"
+
+func scanNotificationProvider(row interface{ Scan(...interface{}) error }) (*NotificationProvider, error) {
+	var p NotificationProvider
+	var config, events string
+	if err := row.Scan(&p.ID, &p.Name, &p.Type, &config, &events, &p.Enabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.Config = map[string]string{}
+	json.Unmarshal([]byte(config), &p.Config)
+	p.Events = []string{}
+	if events != "" {
+		p.Events = strings.Split(events, ",")
+	}
+	return &p, nil
+}
+
+// GetNotificationProviders returns every notification provider
+func (d *Database) GetNotificationProviders() ([]NotificationProvider, error) {
+	rows, err := d.db.Query("SELECT " + notificationProviderColumns + " FROM notification_providers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := []NotificationProvider{}
+	for rows.Next() {
+		p, err := scanNotificationProvider(rows)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, *p)
+	}
+	return providers, rows.Err()
+}
+
+// GetNotificationProvider returns a notification provider, or nil if there's
+// none with the ID
+func (d *Database) GetNotificationProvider(id int64) (*NotificationProvider, error) {
+	p, err := scanNotificationProvider(d.db.QueryRow(
+		"SELECT "+notificationProviderColumns+" FROM notification_providers WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// CreateNotificationProvider adds a notification provider, setting its ID
+func (d *Database) CreateNotificationProvider(p *NotificationProvider) error {
+	config, err := json.Marshal(p.Config)
+	if err != nil {
+		return err
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO notification_providers (name, type, config, events, enabled)
+		VALUES (?, ?, ?, ?, ?)`,
+		p.Name, p.Type, string(config), strings.Join(p.Events, ","), p.Enabled,
+	)
+	if err != nil {
+		return err
+	}
+	p.ID, err = result.LastInsertId()
+	return err
+}
+
+// UpdateNotificationProvider saves a notification provider's settings
+func (d *Database) UpdateNotificationProvider(p *NotificationProvider) error {
+	config, err := json.Marshal(p.Config)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`
+		UPDATE notification_providers SET name = ?, type = ?, config = ?, events = ?, enabled = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		p.Name, p.Type, string(config), strings.Join(p.Events, ","), p.Enabled, p.ID,
+	)
+	return err
+}
+
+// DeleteNotificationProvider removes a notification provider
+func (d *Database) DeleteNotificationProvider(id int64) error {
+	_, err := d.db.Exec("DELETE FROM notification_providers WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Pending operations
+//
+// In four-eyes mode a destructive action isn't run when it's asked for: it's
+// stored with whatever it needs to run, such as an uploaded backup, until a
+// second admin confirms or rejects it or its window runs out.
+
+// Pending operation statuses
+const (
+	OperationPending   = "pending"
+	OperationConfirmed = "confirmed"
+	OperationFailed    = "failed"
+	OperationRejected  = "rejected"
+	OperationCancelled = "cancelled"
+	OperationExpired   = "expired"
+)
+
+// PendingOperation is a destructive action waiting for, or given, a second
+// admin's decision
+type PendingOperation struct {
+	ID              int64             `json:"id"`
+	Action          string            `json:"action"`
+	Summary         string            `json:"summary"`
+	Params          map[string]string `json:"params"`
+	Payload         []byte            `json:"-"`
+	Status          string            `json:"status"`
+	RequestedBy     int64             `json:"requestedBy"`
+	RequestedByName string            `json:"requestedByName"`
+	DecidedBy       *int64            `json:"decidedBy,omitempty"`
+	DecidedByName   *string           `json:"decidedByName,omitempty"`
+	Result          json.RawMessage   `json:"result,omitempty"`
+	Error           *string           `json:"error,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	ExpiresAt       time.Time         `json:"expiresAt"`
+	DecidedAt       *time.Time        `json:"decidedAt,omitempty"`
+}
+
+const operationColumns = `o.id, o.action, o.summary, o.params, o.payload, o.status, o.requested_by,
+	COALESCE(r.username, ''), o.decided_by, d.username, o.result, o.error, o.created_at, o.expires_at, o.decided_at`
+
+const operationFrom = ` FROM pending_operations o
+	LEFT JOIN users r ON r.id = o.requested_by
+	LEFT JOIN users d ON d.id = o.decided_by`
+
+func scanOperation(row interface{ Scan(...interface{}) error }) (*PendingOperation, error) {
+	var op PendingOperation
+	var params string
+	var result sql.NullString
+	err := row.Scan(&op.ID, &op.Action, &op.Summary, &params, &op.Payload, &op.Status, &op.RequestedBy,
+		&op.RequestedByName, &op.DecidedBy, &op.DecidedByName, &result, &op.Error, &op.CreatedAt, &op.ExpiresAt, &op.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	op.Params = map[string]string{}
+	json.Unmarshal([]byte(params), &op.Params)
+	if result.Valid && result.String != "" {
+		op.Result = json.RawMessage(result.String)
+	}
+	return &op, nil
+}
+
+// CreatePendingOperation stores an operation that expires after window
+func (d *Database) CreatePendingOperation(op *PendingOperation, window time.Duration) error {
+	params, err := json.Marshal(op.Params)
+	if err != nil {
+		return err
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO pending_operations (action, summary, params, payload, requested_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, datetime('now', '+' || ? || ' seconds'))`,
+		op.Action, op.Summary, string(params), op.Payload, op.RequestedBy, int(window.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+	op.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetPendingOperation returns an operation, or nil if there's none with the ID
+func (d *Database) GetPendingOperation(id int64) (*PendingOperation, error) {
+	op, err := scanOperation(d.db.QueryRow(`SELECT `+operationColumns+operationFrom+` WHERE o.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return op, err
+}
+
+// GetPendingOperations returns the operations waiting for a decision, or with
+// all set every operation, newest first
+func (d *Database) GetPendingOperations(all bool, limit int) ([]PendingOperation, error) {
+	query := `SELECT ` + operationColumns + operationFrom
+	if !all {
+		query += ` WHERE o.status = 'pending'`
+	}
+	query += ` ORDER BY o.id DESC LIMIT ?`
+
+	rows, err := d.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []PendingOperation{}
+	for rows.Next() {
+		op, err := scanOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, *op)
+	}
+	return ops, rows.Err()
+}
+
+// ExpirePendingOperations marks operations whose window has run out as
+// expired and drops what they'd have needed to run
+func (d *Database) ExpirePendingOperations() error {
+	_, err := d.db.Exec(`
+		UPDATE pending_operations SET status = 'expired', payload = NULL
+		WHERE status = 'pending' AND expires_at <= datetime('now')`)
+	return err
+}
+
+// DecidePendingOperation moves an operation that's still pending and within
+// its window to status. Returns false if it was already decided or expired,
+// so two admins can't both confirm it. Only a confirmed operation keeps its
+// payload, to run with.
+func (d *Database) DecidePendingOperation(id int64, status string, decidedBy int64) (bool, error) {
+	result, err := d.db.Exec(`
+		UPDATE pending_operations SET status = ?, decided_by = ?, decided_at = CURRENT_TIMESTAMP,
+			payload = CASE WHEN ? = 'confirmed' THEN payload ELSE NULL END
+		WHERE id = ? AND status = 'pending' AND expires_at > datetime('now')`,
+		status, decidedBy, status, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// FinishPendingOperation records how a confirmed operation went. It fails the
+// operation if errMsg is set. The payload is no longer needed either way.
+func (d *Database) FinishPendingOperation(id int64, result []byte, errMsg *string) error {
+	status := OperationConfirmed
+	if errMsg != nil {
+		status = OperationFailed
+	}
+	var resultText *string
+	if result != nil {
+		text := string(result)
+		resultText = &text
+	}
+	_, err := d.db.Exec(`
+		UPDATE pending_operations SET status = ?, result = ?, error = ?, payload = NULL WHERE id = ?`,
+		status, resultText, errMsg, id,
+	)
+	return err
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Photo operations
+//
+// Photos are the pictures found in photo libraries. Each keeps the folder it
+// is in as its album and when it was taken, from its EXIF metadata or,
+// failing that, when the file was last changed. Times are the camera's wall
+// clock stored as UTC, so a photo taken at 9pm on holiday is on that day in
+// the timeline wherever the server is. Thumbnails are cached in the image
+// cache under thumbnail_path, one JPEG per size.
+
+// photoTimeLayout is how taken_at is stored, so it sorts and groups as text
+const photoTimeLayout = "2006-01-02 15:04:05"
+
+// Photo is a picture in a photo library
+type Photo struct {
+	ID            int64     `json:"id"`
+	LibraryID     int64     `json:"libraryId"`
+	Path          string    `json:"path"`
+	Album         string    `json:"album"` // Folder in the library, "" for its top
+	Title         string    `json:"title"`
+	Format        string    `json:"format"` // jpeg, png, gif, webp
+	Width         int       `json:"width"`  // Upright, as thumbnails are
+	Height        int       `json:"height"`
+	Size          int64     `json:"size"`
+	TakenAt       time.Time `json:"takenAt"`
+	EXIFDate      bool      `json:"exifDate"` // TakenAt is from EXIF, not the file's time
+	Camera        *string   `json:"camera,omitempty"`
+	Latitude      *float64  `json:"latitude,omitempty"`
+	Longitude     *float64  `json:"longitude,omitempty"`
+	ThumbnailPath *string   `json:"thumbnailPath,omitempty"` // Folder in the image cache
+	FileMtime     int64     `json:"-"`
+	AddedAt       time.Time `json:"addedAt"`
+}
+
+// PhotoFilter narrows a list of photos
+type PhotoFilter struct {
+	LibraryIDs []int64 // Photos in these libraries; none matches nothing
+	Album      *string
+	From, To   string // YYYY-MM-DD, inclusive
+	Limit      int
+	Offset     int
+}
+
+// PhotoGroup is a day or month of the timeline, or an album, with the
+// newest photo in it as its cover
+type PhotoGroup struct {
+	LibraryID int64     `json:"libraryId,omitempty"`
+	Key       string    `json:"key"` // YYYY-MM-DD or YYYY-MM for dates, the folder for albums
+	Count     int       `json:"count"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Cover     Photo     `json:"cover"`
+}
+
+const photoColumns = `p.id, p.library_id, p.path, p.album, p.title, p.format, COALESCE(p.width, 0), COALESCE(p.height, 0),
+	COALESCE(p.size, 0), p.taken_at, COALESCE(p.exif_date, 0), p.camera, p.latitude, p.longitude, p.thumbnail_path,
+	COALESCE(p.file_mtime, 0), p.added_at`
+
+// photoDest returns where a row of photoColumns is scanned
+func photoDest(p *Photo) []interface{} {
+	return []interface{}{&p.ID, &p.LibraryID, &p.Path, &p.Album, &p.Title, &p.Format, &p.Width, &p.Height,
+		&p.Size, &p.TakenAt, &p.EXIFDate, &p.Camera, &p.Latitude, &p.Longitude, &p.ThumbnailPath,
+		&p.FileMtime, &p.AddedAt}
+}
+
+// SavePhoto adds a photo, or updates the one at its path
+func (d *Database) SavePhoto(p *Photo) error {
+	err := d.db.QueryRow(`
+		INSERT INTO photos (library_id, path, album, title, format, width, height, size, taken_at, exif_date,
+			camera, latitude, longitude, thumbnail_path, file_mtime)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			library_id = excluded.library_id, album = excluded.album, title = excluded.title,
+			format = excluded.format, width = excluded.width, height = excluded.height, size = excluded.size,
+			taken_at = excluded.taken_at, exif_date = excluded.exif_date, camera = excluded.camera,
+			latitude = excluded.latitude, longitude = excluded.longitude,
+			thumbnail_path = excluded.thumbnail_path, file_mtime = excluded.file_mtime
+		RETURNING id, added_at`,
+		p.LibraryID, p.Path, p.Album, p.Title, p.Format, p.Width, p.Height, p.Size,
+		p.TakenAt.UTC().Format(photoTimeLayout), p.EXIFDate, p.Camera, p.Latitude, p.Longitude,
+		p.ThumbnailPath, p.FileMtime,
+	).Scan(&p.ID, &p.AddedAt)
+	return err
+}
+
+func (d *Database) GetPhoto(id int64) (*Photo, error) {
+	var p Photo
+	err := d.db.QueryRow(`SELECT `+photoColumns+` FROM photos p WHERE p.id = ?`, id).Scan(photoDest(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (d *Database) GetPhotoByPath(path string) (*Photo, error) {
+	var p Photo
+	err := d.db.QueryRow(`SELECT `+photoColumns+` FROM photos p WHERE p.path = ?`, path).Scan(photoDest(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPhotoPaths returns the paths of a library's photos and their IDs
+func (d *Database) GetPhotoPaths(libraryID int64) (map[string]int64, error) {
+	rows, err := d.db.Query(`SELECT id, path FROM photos WHERE library_id = ?`, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		paths[path] = id
+	}
+	return paths, rows.Err()
+}
+
+func (d *Database) DeletePhoto(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM photos WHERE id = ?`, id)
+	return err
+}
+
+// photoWhere returns the WHERE clause and arguments of a filter
+func photoWhere(f PhotoFilter) (string, []interface{}) {
+	if len(f.LibraryIDs) == 0 {
+		return "WHERE 0", nil
+	}
+	placeholders := make([]string, len(f.LibraryIDs))
+	args := make([]interface{}, 0, len(f.LibraryIDs)+3)
+	for i, id := range f.LibraryIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	where := "WHERE p.library_id IN (" + strings.Join(placeholders, ",") + ")"
+	if f.Album != nil {
+		where += " AND p.album = ?"
+		args = append(args, *f.Album)
+	}
+	if f.From != "" {
+		where += " AND p.taken_at >= ?"
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		// Everything on the last day sorts before the next one
+		where += " AND p.taken_at < ?"
+		if to, err := time.Parse("2006-01-02", f.To); err == nil {
+			args = append(args, to.AddDate(0, 0, 1).Format("2006-01-02"))
+		} else {
+			args = append(args, f.To)
+		}
+	}
+	return where, args
+}
+
+// GetPhotos returns the photos a filter matches, newest first, and how many
+// there are in all
+func (d *Database) GetPhotos(f PhotoFilter) ([]Photo, int, error) {
+	where, args := photoWhere(f)
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM photos p `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + photoColumns + ` FROM photos p ` + where + ` ORDER BY p.taken_at DESC, p.id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	photos := []Photo{}
+	for rows.Next() {
+		var p Photo
+		if err := rows.Scan(photoDest(&p)...); err != nil {
+			return nil, 0, err
+		}
+		photos = append(photos, p)
+	}
+	return photos, total, rows.Err()
+}
+
+// GetPhotoTimeline returns how many photos a filter matches on each day, or
+// each month when byMonth is set, newest first
+func (d *Database) GetPhotoTimeline(f PhotoFilter, byMonth bool) ([]PhotoGroup, error) {
+	key := "substr(p.taken_at, 1, 10)"
+	if byMonth {
+		key = "substr(p.taken_at, 1, 7)"
+	}
+	where, args := photoWhere(f)
+	return d.queryPhotoGroups(key, "", where, args)
+}
+
+// GetPhotoAlbums returns the albums of the photos a filter matches, by
+// library and folder
+func (d *Database) GetPhotoAlbums(f PhotoFilter) ([]PhotoGroup, error) {
+	where, args := photoWhere(f)
+	return d.queryPhotoGroups("p.album", "p.library_id", where, args)
+}
+
+// queryPhotoGroups groups photos by a key and, when one is given, a
+// library, newest group first. Each group's cover is its newest photo,
+// found from the largest of its photos' times with their IDs on the end.
+func (d *Database) queryPhotoGroups(key, library, where string, args []interface{}) ([]PhotoGroup, error) {
+	groupBy := key
+	libraryColumn := "0"
+	if library != "" {
+		groupBy = library + ", " + key
+		libraryColumn = library
+	}
+	rows, err := d.db.Query(`
+		SELECT g.library_id, g.key, g.count, g.first, g.last, `+photoColumns+`
+		FROM (
+			SELECT `+libraryColumn+` AS library_id, `+key+` AS key, COUNT(*) AS count,
+			       MIN(p.taken_at) AS first, MAX(p.taken_at) AS last,
+			       MAX(p.taken_at || printf('%020d', p.id)) AS cover
+			FROM photos p `+where+`
+			GROUP BY `+groupBy+`
+		) g
+		JOIN photos p ON p.id = CAST(substr(g.cover, -20) AS INTEGER)
+		ORDER BY g.last DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []PhotoGroup{}
+	for rows.Next() {
+		var g PhotoGroup
+		var first, last string
+		dest := append([]interface{}{&g.LibraryID, &g.Key, &g.Count, &first, &last}, photoDest(&g.Cover)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		g.From, _ = time.Parse(photoTimeLayout, first)
+		g.To, _ = time.Parse(photoTimeLayout, last)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
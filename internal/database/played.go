@@ -0,0 +1,119 @@
+package database
+
+import (
+	"strconv"
+	"time"
+)
+
+// Played threshold operations
+//
+// Progress counts as watched once it passes a share of the duration, or,
+// when enabled, once playback reaches the credits. The server sets the
+// defaults (played_threshold, played_credits) and profiles can override them.
+
+// DefaultPlayedThreshold is the share of the duration, in percent, after which
+// progress counts as watched when nothing is configured
+const DefaultPlayedThreshold = 90
+
+// PlayedRule decides when progress counts as watched
+type PlayedRule struct {
+	Threshold int  `json:"threshold"` // Percent of the duration
+	Credits   bool `json:"credits"`   // Reaching the credits also counts
+}
+
+// ValidPlayedThreshold reports whether a played threshold percentage is usable
+func ValidPlayedThreshold(threshold int) bool {
+	return threshold >= 50 && threshold <= 100
+}
+
+// GetServerPlayedRule returns the server-wide played rule
+func (d *Database) GetServerPlayedRule() PlayedRule {
+	rule := PlayedRule{Threshold: DefaultPlayedThreshold}
+	if v, err := d.GetSetting("played_threshold"); err == nil {
+		if threshold, err := strconv.Atoi(v); err == nil && ValidPlayedThreshold(threshold) {
+			rule.Threshold = threshold
+		}
+	}
+	if v, _ := d.GetSetting("played_credits"); v == "true" {
+		rule.Credits = true
+	}
+	return rule
+}
+
+// GetPlayedRule returns the played rule for a profile: the server's, with the
+// profile's overrides applied. A nil profileID gets the server's rule.
+func (d *Database) GetPlayedRule(profileID *int64) PlayedRule {
+	rule := d.GetServerPlayedRule()
+	if profileID == nil {
+		return rule
+	}
+	profile, err := d.GetProfile(*profileID)
+	if err != nil {
+		return rule
+	}
+	if profile.PlayedThreshold != nil && ValidPlayedThreshold(*profile.PlayedThreshold) {
+		rule.Threshold = *profile.PlayedThreshold
+	}
+	if profile.PlayedCredits != nil {
+		rule.Credits = *profile.PlayedCredits
+	}
+	return rule
+}
+
+// playedCondition returns a SQL condition that's true when the progress row
+// aliased p counts as watched under the rule
+func (r PlayedRule) playedCondition() string {
+	cond := `(p.duration > 0 AND (p.position / p.duration) * 100 >= ` + strconv.Itoa(r.Threshold) + `)`
+	if r.Credits {
+		cond = `(` + cond + ` OR (p.media_type = 'episode' AND p.position > 0 AND p.position >= ` + creditsStartSQL + `))`
+	}
+	return cond
+}
+
+// creditsStartSQL is where the credits of the episode p.media_id start: its
+// own detected credits, or else the show-wide credits marker
+const creditsStartSQL = `COALESCE(
+	(SELECT MIN(ms.start_seconds) FROM media_segments ms
+	 WHERE ms.episode_id = p.media_id AND ms.segment_type = 'credits' AND ms.start_seconds > 0),
+	(SELECT ss.start_time FROM skip_segments ss
+	 JOIN seasons cs ON cs.show_id = ss.show_id
+	 JOIN episodes ce ON ce.season_id = cs.id
+	 WHERE ce.id = p.media_id AND ss.segment_type = 'credits' AND ss.start_time > 0),
+	1e18)`
+
+// IsPlayed reports whether a position in a movie or episode counts as watched
+func (d *Database) IsPlayed(rule PlayedRule, mediaType string, mediaID int64, position, duration float64) bool {
+	var played bool
+	err := d.db.QueryRow(`
+		SELECT `+rule.playedCondition()+`
+		FROM (SELECT ? AS media_type, ? AS media_id, ? AS position, ? AS duration) p`,
+		mediaType, mediaID, position, duration).Scan(&played)
+	return err == nil && played
+}
+
+// SaveProgressPlayed saves playback progress and records a watch in the
+// history (which is pushed to Trakt) when it crosses the profile's played
+// rule. Returns whether this save marked the item watched.
+func (d *Database) SaveProgressPlayed(p *Progress) (bool, error) {
+	profileID := p.ProfileID
+	rule := d.GetPlayedRule(&profileID)
+
+	wasPlayed := false
+	if prev, err := d.GetProgress(p.ProfileID, p.MediaType, p.MediaID); err == nil {
+		wasPlayed = d.IsPlayed(rule, prev.MediaType, prev.MediaID, prev.Position, prev.Duration)
+	}
+	if err := d.SaveProgress(p); err != nil {
+		return false, err
+	}
+	if wasPlayed || !d.IsPlayed(rule, p.MediaType, p.MediaID, p.Position, p.Duration) {
+		return false, nil
+	}
+
+	err := d.AddWatchHistoryItem(&WatchHistoryItem{
+		ProfileID: p.ProfileID,
+		MediaType: p.MediaType,
+		MediaID:   p.MediaID,
+		WatchedAt: time.Now().UTC(),
+	})
+	return true, err
+}
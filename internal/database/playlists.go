@@ -0,0 +1,338 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Music playlist operations
+//
+// Playlists are ordered lists of tracks a profile puts together by hand or
+// imports from M3U files, unlike smart playlists, which are worked out from
+// rules. The same track can be in a playlist more than once, so each place
+// in it is an entry with its own ID. Entries of tracks that were removed
+// from the library are left out. Playing a playlist moves its resume point,
+// so it shows under continue listening until its last track is reached.
+
+// ErrPlaylistEntries is returned when a new order of a playlist's entries
+// doesn't name each of its entries exactly once
+var ErrPlaylistEntries = errors.New("the order must list every entry of the playlist once")
+
+// Playlist is a profile's music playlist
+type Playlist struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"userId"`
+	ProfileID     *int64     `json:"profileId,omitempty"`
+	Name          string     `json:"name"`
+	Description   *string    `json:"description,omitempty"`
+	TrackCount    int        `json:"trackCount"`
+	Duration      int        `json:"duration"`                // seconds
+	ResumeEntryID *int64     `json:"resumeEntryId,omitempty"` // Entry last played
+	LastPlayedAt  *time.Time `json:"lastPlayedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// PlaylistEntry is a track's place in a playlist
+type PlaylistEntry struct {
+	EntryID  int64     `json:"entryId"`
+	Position int       `json:"position"` // From 0, in the playlist's order
+	AddedAt  time.Time `json:"addedAt"`
+	MixTrack
+}
+
+// ContinueListeningPlaylist is a playlist played partway through, with the
+// entry to carry on from
+type ContinueListeningPlaylist struct {
+	Playlist
+	Next PlaylistEntry `json:"next"`
+}
+
+// playlistColumns selects a playlists row as p with its track count and
+// duration
+const playlistColumns = `
+	p.id, p.user_id, p.profile_id, p.name, p.description,
+	(SELECT COUNT(*) FROM playlist_tracks pt JOIN tracks t ON t.id = pt.track_id WHERE pt.playlist_id = p.id),
+	(SELECT COALESCE(SUM(t.duration), 0) FROM playlist_tracks pt JOIN tracks t ON t.id = pt.track_id WHERE pt.playlist_id = p.id),
+	p.resume_entry_id, p.last_played_at, p.created_at, p.updated_at`
+
+// playlistDest returns where a row of playlistColumns is scanned
+func playlistDest(p *Playlist) []interface{} {
+	return []interface{}{&p.ID, &p.UserID, &p.ProfileID, &p.Name, &p.Description, &p.TrackCount, &p.Duration,
+		&p.ResumeEntryID, &p.LastPlayedAt, &p.CreatedAt, &p.UpdatedAt}
+}
+
+// CreatePlaylist creates an empty playlist
+func (d *Database) CreatePlaylist(p *Playlist) error {
+	result, err := d.db.Exec(`INSERT INTO playlists (user_id, profile_id, name, description) VALUES (?, ?, ?, ?)`,
+		p.UserID, p.ProfileID, p.Name, p.Description)
+	if err != nil {
+		return err
+	}
+	p.ID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	return nil
+}
+
+// GetPlaylists returns a user's playlists on a profile, or those made
+// without one when profileID is nil, most recently changed first
+func (d *Database) GetPlaylists(userID int64, profileID *int64) ([]Playlist, error) {
+	rows, err := d.db.Query(`SELECT `+playlistColumns+`
+		FROM playlists p
+		WHERE p.user_id = ? AND p.profile_id IS ?
+		ORDER BY p.updated_at DESC, p.id DESC`, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var playlists []Playlist
+	for rows.Next() {
+		var p Playlist
+		if err := rows.Scan(playlistDest(&p)...); err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	return playlists, rows.Err()
+}
+
+// GetPlaylist returns a playlist by ID
+func (d *Database) GetPlaylist(id int64) (*Playlist, error) {
+	var p Playlist
+	err := d.db.QueryRow(`SELECT `+playlistColumns+` FROM playlists p WHERE p.id = ?`, id).Scan(playlistDest(&p)...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdatePlaylist renames a playlist and changes its description
+func (d *Database) UpdatePlaylist(p *Playlist) error {
+	_, err := d.db.Exec(`UPDATE playlists SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		p.Name, p.Description, p.ID)
+	return err
+}
+
+// DeletePlaylist deletes a playlist and its entries
+func (d *Database) DeletePlaylist(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM playlist_tracks WHERE playlist_id = ?`,
+		`DELETE FROM playlists WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetPlaylistEntries returns a playlist's tracks in order
+func (d *Database) GetPlaylistEntries(playlistID int64) ([]PlaylistEntry, error) {
+	rows, err := d.db.Query(`
+		SELECT pt.id, pt.added_at,
+			t.id, t.album_id, t.musicbrainz_id, t.title, t.track_number, t.disc_number, t.duration, t.path, t.size,
+			t.genre, t.bpm, ar.id, ar.name, al.title, COALESCE(al.year, 0), al.cover_path, ar.library_id
+		FROM playlist_tracks pt
+		JOIN tracks t ON t.id = pt.track_id
+		JOIN albums al ON al.id = t.album_id
+		JOIN artists ar ON ar.id = al.artist_id
+		WHERE pt.playlist_id = ?
+		ORDER BY pt.position, pt.id`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []PlaylistEntry
+	for rows.Next() {
+		var e PlaylistEntry
+		t := &e.MixTrack
+		if err := rows.Scan(&e.EntryID, &e.AddedAt,
+			&t.ID, &t.AlbumID, &t.MusicBrainzID, &t.Title, &t.TrackNumber, &t.DiscNumber, &t.Duration, &t.Path, &t.Size,
+			&t.Genre, &t.BPM, &t.ArtistID, &t.ArtistName, &t.AlbumTitle, &t.AlbumYear, &t.CoverPath, &t.LibraryID); err != nil {
+			return nil, err
+		}
+		e.Position = len(entries)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// AddPlaylistTracks inserts tracks into a playlist before the entry at
+// position, or at the end when position is out of range
+func (d *Database) AddPlaylistTracks(playlistID int64, trackIDs []int64, position int) error {
+	entries, err := d.GetPlaylistEntries(playlistID)
+	if err != nil {
+		return err
+	}
+	if position < 0 || position > len(entries) {
+		position = len(entries)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Entries are renumbered around the new ones, which also closes gaps
+	// left by removed tracks
+	for i, e := range entries {
+		pos := i
+		if i >= position {
+			pos += len(trackIDs)
+		}
+		if _, err := tx.Exec(`UPDATE playlist_tracks SET position = ? WHERE id = ?`, pos, e.EntryID); err != nil {
+			return err
+		}
+	}
+	for i, trackID := range trackIDs {
+		if _, err := tx.Exec(`INSERT INTO playlist_tracks (playlist_id, track_id, position) VALUES (?, ?, ?)`,
+			playlistID, trackID, position+i); err != nil {
+			return err
+		}
+	}
+	if err := touchPlaylist(tx, playlistID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderPlaylist puts a playlist's entries in the order given by their IDs,
+// which must name each entry once
+func (d *Database) ReorderPlaylist(playlistID int64, entryIDs []int64) error {
+	entries, err := d.GetPlaylistEntries(playlistID)
+	if err != nil {
+		return err
+	}
+	if len(entryIDs) != len(entries) {
+		return ErrPlaylistEntries
+	}
+	current := make(map[int64]bool, len(entries))
+	for _, e := range entries {
+		current[e.EntryID] = true
+	}
+	for _, id := range entryIDs {
+		if !current[id] {
+			return ErrPlaylistEntries
+		}
+		delete(current, id)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, id := range entryIDs {
+		if _, err := tx.Exec(`UPDATE playlist_tracks SET position = ? WHERE id = ?`, i, id); err != nil {
+			return err
+		}
+	}
+	if err := touchPlaylist(tx, playlistID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemovePlaylistEntry takes an entry out of a playlist, returning
+// sql.ErrNoRows if the playlist has no such entry
+func (d *Database) RemovePlaylistEntry(playlistID, entryID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM playlist_tracks WHERE id = ? AND playlist_id = ?`, entryID, playlistID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`UPDATE playlists SET resume_entry_id = NULL WHERE id = ? AND resume_entry_id = ?`,
+		playlistID, entryID); err != nil {
+		return err
+	}
+	if err := touchPlaylist(tx, playlistID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetPlaylistResume records that a playlist was played up to an entry
+func (d *Database) SetPlaylistResume(playlistID, entryID int64) error {
+	_, err := d.db.Exec(`UPDATE playlists SET resume_entry_id = ?, last_played_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		entryID, playlistID)
+	return err
+}
+
+// ClearPlaylistResume takes a playlist off continue listening
+func (d *Database) ClearPlaylistResume(playlistID int64) error {
+	_, err := d.db.Exec(`UPDATE playlists SET resume_entry_id = NULL WHERE id = ?`, playlistID)
+	return err
+}
+
+// GetContinueListeningPlaylists returns the playlists of a user's profile
+// that were played partway through, most recently played first, with the
+// entry after the one last played
+func (d *Database) GetContinueListeningPlaylists(userID int64, profileID *int64, limit int) ([]ContinueListeningPlaylist, error) {
+	rows, err := d.db.Query(`SELECT `+playlistColumns+`
+		FROM playlists p
+		WHERE p.user_id = ? AND p.profile_id IS ? AND p.resume_entry_id IS NOT NULL
+		ORDER BY p.last_played_at DESC`, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	var playlists []Playlist
+	for rows.Next() {
+		var p Playlist
+		if err := rows.Scan(playlistDest(&p)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var items []ContinueListeningPlaylist
+	for _, p := range playlists {
+		if len(items) >= limit {
+			break
+		}
+		entries, err := d.GetPlaylistEntries(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range entries {
+			if e.EntryID == *p.ResumeEntryID && i+1 < len(entries) {
+				items = append(items, ContinueListeningPlaylist{Playlist: p, Next: entries[i+1]})
+				break
+			}
+		}
+	}
+	return items, nil
+}
+
+// touchPlaylist marks a playlist as changed
+func touchPlaylist(tx *sql.Tx, playlistID int64) error {
+	_, err := tx.Exec(`UPDATE playlists SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, playlistID)
+	return err
+}
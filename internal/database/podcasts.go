@@ -0,0 +1,307 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Podcast operations
+//
+// A podcast feed is stored once however many users subscribe to it, so its
+// episodes are fetched and downloaded once. Playback progress of episodes
+// goes in the progress table like any other media, as podcast_episode.
+
+// Podcast is a subscribed RSS feed
+type Podcast struct {
+	ID            int64      `json:"id"`
+	FeedURL       string     `json:"feedUrl"`
+	Title         string     `json:"title"`
+	Author        *string    `json:"author,omitempty"`
+	Description   *string    `json:"description,omitempty"`
+	ImageURL      *string    `json:"imageUrl,omitempty"`
+	Website       *string    `json:"website,omitempty"`
+	AutoDownload  bool       `json:"autoDownload"`
+	KeepEpisodes  int        `json:"keepEpisodes"` // Downloads kept, newest first; 0 keeps all
+	LastRefreshed *time.Time `json:"lastRefreshed,omitempty"`
+	LastError     *string    `json:"lastError,omitempty"`
+	AddedAt       time.Time  `json:"addedAt"`
+
+	EpisodeCount int `json:"episodeCount"`
+	Subscribers  int `json:"subscribers"`
+}
+
+// PodcastEpisode is an episode of a podcast. FilePath is set once it's
+// downloaded.
+type PodcastEpisode struct {
+	ID           int64      `json:"id"`
+	PodcastID    int64      `json:"podcastId"`
+	GUID         string     `json:"guid"`
+	Title        string     `json:"title"`
+	Description  *string    `json:"description,omitempty"`
+	AudioURL     string     `json:"audioUrl"`
+	MimeType     *string    `json:"mimeType,omitempty"`
+	Duration     *int       `json:"duration,omitempty"` // Seconds
+	PublishedAt  *time.Time `json:"publishedAt,omitempty"`
+	FilePath     *string    `json:"-"`
+	Size         *int64     `json:"size,omitempty"`
+	DownloadedAt *time.Time `json:"downloadedAt,omitempty"`
+	Downloaded   bool       `json:"downloaded"`
+}
+
+const podcastColumns = `p.id, p.feed_url, p.title, p.author, p.description, p.image_url, p.website,
+	p.auto_download, p.keep_episodes, p.last_refreshed, p.last_error, p.added_at,
+	(SELECT COUNT(*) FROM podcast_episodes e WHERE e.podcast_id = p.id),
+	(SELECT COUNT(*) FROM podcast_subscriptions s WHERE s.podcast_id = p.id)`
+
+func scanPodcast(row interface{ Scan(...interface{}) error }) (*Podcast, error) {
+	var p Podcast
+	if err := row.Scan(&p.ID, &p.FeedURL, &p.Title, &p.Author, &p.Description, &p.ImageURL, &p.Website,
+		&p.AutoDownload, &p.KeepEpisodes, &p.LastRefreshed, &p.LastError, &p.AddedAt,
+		&p.EpisodeCount, &p.Subscribers); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (d *Database) queryPodcasts(query string, args ...interface{}) ([]Podcast, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var podcasts []Podcast
+	for rows.Next() {
+		p, err := scanPodcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		podcasts = append(podcasts, *p)
+	}
+	return podcasts, rows.Err()
+}
+
+// GetPodcasts returns every podcast
+func (d *Database) GetPodcasts() ([]Podcast, error) {
+	return d.queryPodcasts(`SELECT ` + podcastColumns + ` FROM podcasts p ORDER BY p.title COLLATE NOCASE`)
+}
+
+// GetUserPodcasts returns the podcasts a user subscribes to
+func (d *Database) GetUserPodcasts(userID int64) ([]Podcast, error) {
+	return d.queryPodcasts(`
+		SELECT `+podcastColumns+` FROM podcasts p
+		JOIN podcast_subscriptions sub ON sub.podcast_id = p.id AND sub.user_id = ?
+		ORDER BY p.title COLLATE NOCASE`, userID)
+}
+
+func (d *Database) GetPodcast(id int64) (*Podcast, error) {
+	return scanPodcast(d.db.QueryRow(`SELECT `+podcastColumns+` FROM podcasts p WHERE p.id = ?`, id))
+}
+
+// GetPodcastByFeed returns the podcast for a feed URL, or nil when nobody
+// subscribes to it
+func (d *Database) GetPodcastByFeed(feedURL string) (*Podcast, error) {
+	p, err := scanPodcast(d.db.QueryRow(`SELECT `+podcastColumns+` FROM podcasts p WHERE p.feed_url = ?`, feedURL))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+func (d *Database) CreatePodcast(p *Podcast) error {
+	result, err := d.db.Exec(`
+		INSERT INTO podcasts (feed_url, title, author, description, image_url, website, auto_download, keep_episodes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.FeedURL, p.Title, p.Author, p.Description, p.ImageURL, p.Website, p.AutoDownload, p.KeepEpisodes)
+	if err != nil {
+		return err
+	}
+	p.ID, _ = result.LastInsertId()
+	p.AddedAt = time.Now()
+	return nil
+}
+
+// UpdatePodcastFeed stores a podcast's details from its feed after a refresh.
+// A refresh that failed only records the error.
+func (d *Database) UpdatePodcastFeed(p *Podcast, refreshErr error) error {
+	if refreshErr != nil {
+		_, err := d.db.Exec(`UPDATE podcasts SET last_refreshed = CURRENT_TIMESTAMP, last_error = ? WHERE id = ?`,
+			refreshErr.Error(), p.ID)
+		return err
+	}
+	_, err := d.db.Exec(`
+		UPDATE podcasts SET title = ?, author = ?, description = ?, image_url = ?, website = ?,
+			last_refreshed = CURRENT_TIMESTAMP, last_error = NULL
+		WHERE id = ?`,
+		p.Title, p.Author, p.Description, p.ImageURL, p.Website, p.ID)
+	return err
+}
+
+// UpdatePodcastSettings stores a podcast's download and retention settings
+func (d *Database) UpdatePodcastSettings(id int64, autoDownload bool, keepEpisodes int) error {
+	_, err := d.db.Exec(`UPDATE podcasts SET auto_download = ?, keep_episodes = ? WHERE id = ?`,
+		autoDownload, keepEpisodes, id)
+	return err
+}
+
+// DeletePodcast deletes a podcast with its episodes, their progress and the
+// subscriptions
+func (d *Database) DeletePodcast(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM progress WHERE media_type = 'podcast_episode'
+			AND media_id IN (SELECT id FROM podcast_episodes WHERE podcast_id = ?)`,
+		`DELETE FROM podcast_episodes WHERE podcast_id = ?`,
+		`DELETE FROM podcast_subscriptions WHERE podcast_id = ?`,
+		`DELETE FROM podcasts WHERE id = ?`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUnsubscribedPodcasts returns podcasts nobody subscribes to anymore
+func (d *Database) GetUnsubscribedPodcasts() ([]Podcast, error) {
+	return d.queryPodcasts(`
+		SELECT ` + podcastColumns + ` FROM podcasts p
+		WHERE NOT EXISTS (SELECT 1 FROM podcast_subscriptions s WHERE s.podcast_id = p.id)`)
+}
+
+// Subscriptions
+
+func (d *Database) SubscribePodcast(userID, podcastID int64) error {
+	_, err := d.db.Exec(`INSERT OR IGNORE INTO podcast_subscriptions (user_id, podcast_id) VALUES (?, ?)`,
+		userID, podcastID)
+	return err
+}
+
+// UnsubscribePodcast removes a subscription, returning false when the user
+// wasn't subscribed
+func (d *Database) UnsubscribePodcast(userID, podcastID int64) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM podcast_subscriptions WHERE user_id = ? AND podcast_id = ?`,
+		userID, podcastID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (d *Database) IsSubscribedToPodcast(userID, podcastID int64) bool {
+	var n int
+	d.db.QueryRow(`SELECT COUNT(*) FROM podcast_subscriptions WHERE user_id = ? AND podcast_id = ?`,
+		userID, podcastID).Scan(&n)
+	return n > 0
+}
+
+// Episodes
+
+const podcastEpisodeColumns = `id, podcast_id, guid, title, description, audio_url, mime_type, duration,
+	published_at, file_path, size, downloaded_at`
+
+func scanPodcastEpisode(row interface{ Scan(...interface{}) error }) (*PodcastEpisode, error) {
+	var e PodcastEpisode
+	if err := row.Scan(&e.ID, &e.PodcastID, &e.GUID, &e.Title, &e.Description, &e.AudioURL, &e.MimeType, &e.Duration,
+		&e.PublishedAt, &e.FilePath, &e.Size, &e.DownloadedAt); err != nil {
+		return nil, err
+	}
+	e.Downloaded = e.FilePath != nil
+	return &e, nil
+}
+
+// GetPodcastEpisodes returns a podcast's episodes, newest first
+func (d *Database) GetPodcastEpisodes(podcastID int64) ([]PodcastEpisode, error) {
+	rows, err := d.db.Query(`
+		SELECT `+podcastEpisodeColumns+` FROM podcast_episodes
+		WHERE podcast_id = ?
+		ORDER BY published_at DESC, id DESC`, podcastID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var episodes []PodcastEpisode
+	for rows.Next() {
+		e, err := scanPodcastEpisode(rows)
+		if err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, *e)
+	}
+	return episodes, rows.Err()
+}
+
+func (d *Database) GetPodcastEpisode(id int64) (*PodcastEpisode, error) {
+	return scanPodcastEpisode(d.db.QueryRow(`SELECT `+podcastEpisodeColumns+` FROM podcast_episodes WHERE id = ?`, id))
+}
+
+// UpsertPodcastEpisode adds an episode from a feed, or updates its details
+// when the feed already had it. Returns whether the episode is new.
+func (d *Database) UpsertPodcastEpisode(e *PodcastEpisode) (bool, error) {
+	var id int64
+	err := d.db.QueryRow(`SELECT id FROM podcast_episodes WHERE podcast_id = ? AND guid = ?`, e.PodcastID, e.GUID).Scan(&id)
+	if err == nil {
+		e.ID = id
+		_, err = d.db.Exec(`
+			UPDATE podcast_episodes SET title = ?, description = ?, audio_url = ?, mime_type = ?, duration = ?, published_at = ?
+			WHERE id = ?`,
+			e.Title, e.Description, e.AudioURL, e.MimeType, e.Duration, e.PublishedAt, id)
+		return false, err
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO podcast_episodes (podcast_id, guid, title, description, audio_url, mime_type, duration, published_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.PodcastID, e.GUID, e.Title, e.Description, e.AudioURL, e.MimeType, e.Duration, e.PublishedAt)
+	if err != nil {
+		return false, err
+	}
+	e.ID, _ = result.LastInsertId()
+	return true, nil
+}
+
+// SetPodcastEpisodeFile records where an episode was downloaded to, or, with
+// an empty path, that its download was removed
+func (d *Database) SetPodcastEpisodeFile(id int64, path string, size int64) error {
+	if path == "" {
+		_, err := d.db.Exec(`UPDATE podcast_episodes SET file_path = NULL, size = NULL, downloaded_at = NULL WHERE id = ?`, id)
+		return err
+	}
+	_, err := d.db.Exec(`UPDATE podcast_episodes SET file_path = ?, size = ?, downloaded_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		path, size, id)
+	return err
+}
+
+// GetUnfinishedPodcastEpisodes returns a podcast's episodes someone is
+// part-way through under the server's played rule
+func (d *Database) GetUnfinishedPodcastEpisodes(podcastID int64) (map[int64]bool, error) {
+	rows, err := d.db.Query(`
+		SELECT p.media_id FROM progress p
+		JOIN podcast_episodes e ON e.id = p.media_id
+		WHERE p.media_type = 'podcast_episode' AND e.podcast_id = ? AND p.position > 0
+		  AND NOT `+d.GetServerPlayedRule().playedCondition(), podcastID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unfinished := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		unfinished[id] = true
+	}
+	return unfinished, rows.Err()
+}
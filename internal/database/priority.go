@@ -0,0 +1,29 @@
+package database
+
+// Priorities
+//
+// Requests and wanted items are wanted low, normal or high priority. High
+// priority items are searched for first and more often, and their downloads
+// are queued ahead in download clients that have priorities.
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// priorityOrder sorts wanted items high priority first
+const priorityOrder = `CASE COALESCE(priority, 'normal') WHEN 'high' THEN 0 WHEN 'low' THEN 2 ELSE 1 END`
+
+// ValidPriority reports whether p is a priority
+func ValidPriority(p string) bool {
+	return p == PriorityLow || p == PriorityNormal || p == PriorityHigh
+}
+
+// NormalizePriority returns p, or normal if p isn't a priority
+func NormalizePriority(p string) string {
+	if !ValidPriority(p) {
+		return PriorityNormal
+	}
+	return p
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Release exclusion operations
+//
+// A release exclusion keeps one release group, or releases with titles
+// matching a pattern, from being grabbed for one movie or show, where
+// blocking the group everywhere would be too much. Exclusions are kept by
+// TMDB ID so they outlast the wanted item.
+
+// Kinds of release exclusion
+const (
+	ExcludeGroup = "group" // Value is a release group
+	ExcludeTitle = "title" // Value is text or, with IsRegex, a pattern in the title
+)
+
+// ReleaseExclusion is a release group or title pattern never to grab for a
+// movie or show
+type ReleaseExclusion struct {
+	ID        int64     `json:"id"`
+	MediaType string    `json:"mediaType"` // movie, show
+	TmdbID    int64     `json:"tmdbId"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	IsRegex   bool      `json:"isRegex"`
+	Reason    *string   `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Matches reports whether a release, by title and parsed group, is excluded.
+// Groups and text match regardless of case; invalid patterns match nothing.
+func (e *ReleaseExclusion) Matches(title, group string) bool {
+	switch e.Kind {
+	case ExcludeGroup:
+		return group != "" && strings.EqualFold(group, e.Value)
+	case ExcludeTitle:
+		if e.IsRegex {
+			re, err := regexp.Compile("(?i)" + e.Value)
+			return err == nil && re.MatchString(title)
+		}
+		return strings.Contains(strings.ToLower(title), strings.ToLower(e.Value))
+	}
+	return false
+}
+
+// Describe says what an exclusion keeps out, for rejection reasons
+func (e *ReleaseExclusion) Describe() string {
+	if e.Kind == ExcludeGroup {
+		return "group " + e.Value + " is excluded for this item"
+	}
+	return "title matches \"" + e.Value + "\", excluded for this item"
+}
+
+// GetReleaseExclusions returns a movie or show's release exclusions, oldest
+// first
+func (d *Database) GetReleaseExclusions(mediaType string, tmdbID int64) ([]ReleaseExclusion, error) {
+	rows, err := d.db.Query(`
+		SELECT id, media_type, tmdb_id, kind, value, is_regex, reason, created_at
+		FROM release_exclusions
+		WHERE media_type = ? AND tmdb_id = ?
+		ORDER BY id`, mediaType, tmdbID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exclusions []ReleaseExclusion
+	for rows.Next() {
+		var e ReleaseExclusion
+		if err := rows.Scan(&e.ID, &e.MediaType, &e.TmdbID, &e.Kind, &e.Value, &e.IsRegex, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, e)
+	}
+	return exclusions, rows.Err()
+}
+
+// GetReleaseExclusion returns a release exclusion, or nil if there's no such
+// exclusion
+func (d *Database) GetReleaseExclusion(id int64) (*ReleaseExclusion, error) {
+	var e ReleaseExclusion
+	err := d.db.QueryRow(`
+		SELECT id, media_type, tmdb_id, kind, value, is_regex, reason, created_at
+		FROM release_exclusions WHERE id = ?`, id).Scan(
+		&e.ID, &e.MediaType, &e.TmdbID, &e.Kind, &e.Value, &e.IsRegex, &e.Reason, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// AddReleaseExclusion excludes a release group or title pattern for a movie
+// or show. Adding one already there updates its reason.
+func (d *Database) AddReleaseExclusion(e *ReleaseExclusion) error {
+	_, err := d.db.Exec(`
+		INSERT INTO release_exclusions (media_type, tmdb_id, kind, value, is_regex, reason)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(media_type, tmdb_id, kind, value) DO UPDATE SET
+			is_regex = excluded.is_regex,
+			reason = excluded.reason`,
+		e.MediaType, e.TmdbID, e.Kind, e.Value, e.IsRegex, e.Reason)
+	if err != nil {
+		return err
+	}
+	return d.db.QueryRow(`
+		SELECT id, created_at FROM release_exclusions
+		WHERE media_type = ? AND tmdb_id = ? AND kind = ? AND value = ?`,
+		e.MediaType, e.TmdbID, e.Kind, e.Value).Scan(&e.ID, &e.CreatedAt)
+}
+
+// RemoveReleaseExclusion deletes a release exclusion
+func (d *Database) RemoveReleaseExclusion(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM release_exclusions WHERE id = ?`, id)
+	return err
+}
+
+// ReleaseExcluded returns the exclusion keeping a release, by title and
+// parsed group, from a movie or show, or nil if none does
+func (d *Database) ReleaseExcluded(mediaType string, tmdbID int64, title, group string) (*ReleaseExclusion, error) {
+	exclusions, err := d.GetReleaseExclusions(mediaType, tmdbID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range exclusions {
+		if exclusions[i].Matches(title, group) {
+			return &exclusions[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Release sighting operations
+//
+// Sightings track when Outpost first saw a release title in search results,
+// so presets can wait for new releases to settle before grabbing them.
+
+// RecordReleaseSightings records the releases seen in a search and returns
+// when each was first seen. Titles seen before keep their original time.
+func (d *Database) RecordReleaseSightings(titles []string) (map[string]time.Time, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`INSERT OR IGNORE INTO release_sightings (release_title) VALUES (?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer insert.Close()
+
+	query, err := tx.Prepare(`SELECT first_seen_at FROM release_sightings WHERE release_title = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+
+	firstSeen := make(map[string]time.Time, len(titles))
+	for _, title := range titles {
+		if _, seen := firstSeen[title]; seen {
+			continue
+		}
+		if _, err := insert.Exec(title); err != nil {
+			return nil, err
+		}
+		var at time.Time
+		if err := query.QueryRow(title).Scan(&at); err != nil {
+			return nil, err
+		}
+		firstSeen[title] = at
+	}
+
+	return firstSeen, tx.Commit()
+}
+
+// GetReleaseSightings returns when releases were first seen, without
+// recording new ones. Titles never seen are left out.
+func (d *Database) GetReleaseSightings(titles []string) (map[string]time.Time, error) {
+	query, err := d.db.Prepare(`SELECT first_seen_at FROM release_sightings WHERE release_title = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+
+	firstSeen := make(map[string]time.Time, len(titles))
+	for _, title := range titles {
+		var at time.Time
+		err := query.QueryRow(title).Scan(&at)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		firstSeen[title] = at
+	}
+	return firstSeen, nil
+}
+
+// CleanupReleaseSightings removes sightings older than the given number of days
+func (d *Database) CleanupReleaseSightings(daysToKeep int) error {
+	_, err := d.db.Exec(`DELETE FROM release_sightings WHERE first_seen_at < datetime('now', '-' || ? || ' days')`, daysToKeep)
+	return err
+}
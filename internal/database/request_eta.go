@@ -0,0 +1,32 @@
+package database
+
+import "time"
+
+// Request ETA operations
+
+// RequestETA is a fulfillment estimate for an approved request
+type RequestETA struct {
+	Status   string
+	Message  string
+	At       *time.Time
+	Progress *float64
+}
+
+// UpdateRequestETA stores the fulfillment estimate for a request
+func (d *Database) UpdateRequestETA(id int64, eta RequestETA) error {
+	_, err := d.db.Exec(`
+		UPDATE requests
+		SET eta_status = ?, eta_message = ?, eta_at = ?, download_progress = ?, eta_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, eta.Status, eta.Message, eta.At, eta.Progress, id)
+	return err
+}
+
+// ClearInactiveRequestETAs removes estimates from requests that are no longer
+// waiting on a download (available, denied, failed or back to requested)
+func (d *Database) ClearInactiveRequestETAs() error {
+	_, err := d.db.Exec(`
+		UPDATE requests
+		SET eta_status = NULL, eta_message = NULL, eta_at = NULL, download_progress = NULL, eta_updated_at = NULL
+		WHERE status NOT IN ('approved', 'processing') AND eta_status IS NOT NULL`)
+	return err
+}
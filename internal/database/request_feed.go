@@ -0,0 +1,54 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Request feed operations
+
+// GetRequestFeed returns requests in the given statuses, most recently
+// changed first. userID limits it to one user's requests when non-zero, and
+// requests last changed before since are left out when it's set.
+func (d *Database) GetRequestFeed(userID int64, statuses []string, since time.Time, limit int) ([]Request, error) {
+	query := `
+		SELECT r.id, r.user_id, u.username, r.type, r.tmdb_id, r.title, r.year, r.overview,
+		       r.poster_path, r.backdrop_path, r.quality_profile_id, r.quality_preset_id, r.seasons, r.status, r.status_reason, r.status_reason_id, COALESCE(r.recheck_after_release, 0), r.requested_at, r.updated_at,
+		       r.eta_status, r.eta_message, r.eta_at, r.download_progress, r.eta_updated_at, r.requester_name, r.edition, COALESCE(r.priority, 'normal')
+		FROM requests r
+		LEFT JOIN users u ON r.user_id = u.id
+		WHERE r.status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+	args := make([]interface{}, 0, len(statuses)+3)
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	if userID != 0 {
+		query += " AND r.user_id = ?"
+		args = append(args, userID)
+	}
+	if !since.IsZero() {
+		query += " AND datetime(r.updated_at) >= datetime(?)"
+		args = append(args, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	query += " ORDER BY r.updated_at DESC, r.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []Request
+	for rows.Next() {
+		var req Request
+		if err := rows.Scan(&req.ID, &req.UserID, &req.Username, &req.Type, &req.TmdbID, &req.Title,
+			&req.Year, &req.Overview, &req.PosterPath, &req.BackdropPath, &req.QualityProfileID, &req.QualityPresetID,
+			&req.Seasons, &req.Status, &req.StatusReason, &req.StatusReasonID, &req.RecheckAfterRelease, &req.RequestedAt, &req.UpdatedAt,
+			&req.EtaStatus, &req.EtaMessage, &req.EtaAt, &req.DownloadProgress, &req.EtaUpdatedAt, &req.RequesterName, &req.Edition, &req.Priority); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
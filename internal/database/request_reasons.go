@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request reason operations
+//
+// Admins keep a list of reusable reasons to pick from when denying requests.
+// The reason's message is copied onto the request, so editing or deleting a
+// reason later doesn't change what users were told.
+
+// RequestReason is a reusable reason for denying requests. The message may
+// use {title} and {year} for the requested title. Requests denied with a
+// RecheckAfterRelease reason are reopened once the title is released.
+type RequestReason struct {
+	ID                  int64     `json:"id"`
+	Label               string    `json:"label"`   // Shown to admins when picking a reason
+	Message             string    `json:"message"` // Shown to the user
+	RecheckAfterRelease bool      `json:"recheckAfterRelease"`
+	SortOrder           int       `json:"sortOrder"`
+	CreatedAt           time.Time `json:"createdAt"`
+}
+
+// Render fills in a reason's message for a request
+func (r *RequestReason) Render(req *Request) string {
+	year := ""
+	if req.Year > 0 {
+		year = strconv.Itoa(req.Year)
+	}
+	return strings.NewReplacer("{title}", req.Title, "{year}", year).Replace(r.Message)
+}
+
+// GetRequestReasons returns the denial reasons in display order
+func (d *Database) GetRequestReasons() ([]RequestReason, error) {
+	rows, err := d.db.Query(`
+		SELECT id, label, message, COALESCE(recheck_after_release, 0), COALESCE(sort_order, 0), created_at
+		FROM request_reasons ORDER BY sort_order, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reasons := []RequestReason{}
+	for rows.Next() {
+		var r RequestReason
+		if err := rows.Scan(&r.ID, &r.Label, &r.Message, &r.RecheckAfterRelease, &r.SortOrder, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, r)
+	}
+	return reasons, rows.Err()
+}
+
+// GetRequestReason returns a denial reason by ID
+func (d *Database) GetRequestReason(id int64) (*RequestReason, error) {
+	var r RequestReason
+	err := d.db.QueryRow(`
+		SELECT id, label, message, COALESCE(recheck_after_release, 0), COALESCE(sort_order, 0), created_at
+		FROM request_reasons WHERE id = ?`, id,
+	).Scan(&r.ID, &r.Label, &r.Message, &r.RecheckAfterRelease, &r.SortOrder, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRequestReason adds a denial reason
+func (d *Database) CreateRequestReason(r *RequestReason) error {
+	result, err := d.db.Exec(`
+		INSERT INTO request_reasons (label, message, recheck_after_release, sort_order) VALUES (?, ?, ?, ?)`,
+		r.Label, r.Message, r.RecheckAfterRelease, r.SortOrder)
+	if err != nil {
+		return err
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.CreatedAt = time.Now()
+	return nil
+}
+
+// UpdateRequestReason saves a denial reason
+func (d *Database) UpdateRequestReason(r *RequestReason) error {
+	result, err := d.db.Exec(`
+		UPDATE request_reasons SET label = ?, message = ?, recheck_after_release = ?, sort_order = ? WHERE id = ?`,
+		r.Label, r.Message, r.RecheckAfterRelease, r.SortOrder, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteRequestReason removes a denial reason. Requests denied with it keep
+// their message.
+func (d *Database) DeleteRequestReason(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE requests SET status_reason_id = NULL WHERE status_reason_id = ?`, id); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM request_reasons WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// GetRecheckRequests returns denied requests waiting to be reopened once
+// their title is released
+func (d *Database) GetRecheckRequests() ([]Request, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, type, tmdb_id, title, COALESCE(year, 0), poster_path, quality_preset_id, seasons
+		FROM requests
+		WHERE status = 'denied' AND recheck_after_release = 1
+		ORDER BY requested_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []Request
+	for rows.Next() {
+		req := Request{Status: "denied", RecheckAfterRelease: true}
+		if err := rows.Scan(&req.ID, &req.UserID, &req.Type, &req.TmdbID, &req.Title, &req.Year,
+			&req.PosterPath, &req.QualityPresetID, &req.Seasons); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
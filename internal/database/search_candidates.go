@@ -0,0 +1,35 @@
+package database
+
+// Search candidate operations
+//
+// Results are stored as JSON-encoded indexer results so this package doesn't
+// depend on the indexer types.
+
+// SaveSearchCandidates replaces the stored candidates for a media item
+func (d *Database) SaveSearchCandidates(mediaType string, mediaID int64, results string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO search_candidates (media_type, media_id, results, searched_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(media_type, media_id) DO UPDATE SET
+			results = excluded.results,
+			searched_at = excluded.searched_at`,
+		mediaType, mediaID, results,
+	)
+	return err
+}
+
+// GetSearchCandidates returns the stored candidates for a media item
+func (d *Database) GetSearchCandidates(mediaType string, mediaID int64) (string, error) {
+	var results string
+	err := d.db.QueryRow(`
+		SELECT results FROM search_candidates WHERE media_type = ? AND media_id = ?`,
+		mediaType, mediaID,
+	).Scan(&results)
+	return results, err
+}
+
+// DeleteSearchCandidates removes the stored candidates for a media item
+func (d *Database) DeleteSearchCandidates(mediaType string, mediaID int64) error {
+	_, err := d.db.Exec(`DELETE FROM search_candidates WHERE media_type = ? AND media_id = ?`, mediaType, mediaID)
+	return err
+}
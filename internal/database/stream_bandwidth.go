@@ -0,0 +1,288 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// Stream bandwidth accounting operations
+//
+// Bytes served by the stream endpoint are counted per streaming session and
+// summed per user per day. Bytes sent outside the local network count
+// separately, and are what monthly transfer caps apply to.
+
+// streamSessionIdle is how long a stream can go without requests before the
+// next one starts a new session
+const streamSessionIdle = 30 * time.Minute
+
+// StreamSession is one playback of a movie, episode, track or book
+type StreamSession struct {
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"userId"`
+	Username      string    `json:"username,omitempty"`
+	AuthSessionID *int64    `json:"-"`
+	DeviceID      *int64    `json:"deviceId,omitempty"`
+	DeviceName    string    `json:"deviceName,omitempty"`
+	ProfileID     *int64    `json:"profileId,omitempty"`
+	ProfileName   *string   `json:"profileName,omitempty"`
+	AvatarURL     *string   `json:"avatarUrl,omitempty"`
+	MediaType     string    `json:"mediaType"`
+	MediaID       int64     `json:"mediaId"`
+	ClientIP      string    `json:"clientIp"`
+	Remote        bool      `json:"remote"`
+	BytesServed   int64     `json:"bytesServed"`
+	StartedAt     time.Time `json:"startedAt"`
+	LastSeenAt    time.Time `json:"lastSeenAt"`
+}
+
+// UserBandwidth is a user's streaming transfer over a period
+type UserBandwidth struct {
+	UserID      int64  `json:"userId"`
+	Username    string `json:"username"`
+	Bytes       int64  `json:"bytes"`
+	RemoteBytes int64  `json:"remoteBytes"`
+	Sessions    int    `json:"sessions"`
+}
+
+// DailyBandwidth is the streaming transfer of one day
+type DailyBandwidth struct {
+	Day         string `json:"day"` // YYYY-MM-DD, server time
+	Bytes       int64  `json:"bytes"`
+	RemoteBytes int64  `json:"remoteBytes"`
+}
+
+// bandwidthDay is the usage day of a time, in the server's time zone
+func bandwidthDay(t time.Time) string {
+	return t.In(timezone.Location()).Format("2006-01-02")
+}
+
+// StartStreamSession finds the session a stream request belongs to: the same
+// user, login and media seen within the last half hour, or a new one
+func (d *Database) StartStreamSession(session *StreamSession) error {
+	cutoff := time.Now().Add(-streamSessionIdle)
+	err := d.db.QueryRow(`
+		SELECT id, started_at FROM stream_sessions
+		WHERE user_id = ? AND auth_session_id IS ? AND media_type = ? AND media_id = ? AND last_seen_at > ?
+		ORDER BY last_seen_at DESC LIMIT 1`,
+		session.UserID, session.AuthSessionID, session.MediaType, session.MediaID, cutoff,
+	).Scan(&session.ID, &session.StartedAt)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	now := time.Now()
+	result, err := d.db.Exec(`
+		INSERT INTO stream_sessions (user_id, auth_session_id, device_id, profile_id, media_type, media_id, client_ip, remote, started_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.UserID, session.AuthSessionID, session.DeviceID, session.ProfileID, session.MediaType, session.MediaID, session.ClientIP, session.Remote, now, now)
+	if err != nil {
+		return err
+	}
+	session.ID, err = result.LastInsertId()
+	session.StartedAt = now
+	return err
+}
+
+// AddStreamBytes counts bytes served to a streaming session towards its user's
+// daily usage
+func (d *Database) AddStreamBytes(session *StreamSession, bytes int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(`
+		UPDATE stream_sessions SET bytes_served = bytes_served + ?, last_seen_at = ? WHERE id = ?`,
+		bytes, now, session.ID); err != nil {
+		return err
+	}
+
+	var remoteBytes int64
+	if session.Remote {
+		remoteBytes = bytes
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO bandwidth_usage (user_id, day, bytes, remote_bytes) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, day) DO UPDATE SET
+			bytes = bytes + excluded.bytes,
+			remote_bytes = remote_bytes + excluded.remote_bytes`,
+		session.UserID, bandwidthDay(now), bytes, remoteBytes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserBandwidth returns each user's transfer between two days (inclusive),
+// heaviest first
+func (d *Database) GetUserBandwidth(from, to string) ([]UserBandwidth, error) {
+	start, err := time.ParseInLocation("2006-01-02", from, timezone.Location())
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.ParseInLocation("2006-01-02", to, timezone.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT b.user_id, COALESCE(u.username, ''), SUM(b.bytes), SUM(b.remote_bytes),
+		       (SELECT COUNT(*) FROM stream_sessions s
+		        WHERE s.user_id = b.user_id AND s.started_at >= ?3 AND s.started_at < ?4)
+		FROM bandwidth_usage b
+		LEFT JOIN users u ON u.id = b.user_id
+		WHERE b.day BETWEEN ?1 AND ?2
+		GROUP BY b.user_id
+		ORDER BY SUM(b.bytes) DESC`, from, to, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserBandwidth{}
+	for rows.Next() {
+		var u UserBandwidth
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Bytes, &u.RemoteBytes, &u.Sessions); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetDailyBandwidth returns the transfer of each day between two days
+// (inclusive), for one user or everyone when userID is 0
+func (d *Database) GetDailyBandwidth(userID int64, from, to string) ([]DailyBandwidth, error) {
+	query := `SELECT day, SUM(bytes), SUM(remote_bytes) FROM bandwidth_usage WHERE day BETWEEN ? AND ?`
+	args := []interface{}{from, to}
+	if userID > 0 {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` GROUP BY day ORDER BY day`
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []DailyBandwidth{}
+	for rows.Next() {
+		var day DailyBandwidth
+		if err := rows.Scan(&day.Day, &day.Bytes, &day.RemoteBytes); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// GetStreamSessions returns the most recent streaming sessions, for one user
+// or everyone when userID is 0
+func (d *Database) GetStreamSessions(userID int64, limit int) ([]StreamSession, error) {
+	query := `
+		SELECT s.id, s.user_id, COALESCE(u.username, ''), s.device_id, COALESCE(dev.name, ''), p.id, p.name, p.avatar_url,
+		       s.media_type, s.media_id, COALESCE(s.client_ip, ''), COALESCE(s.remote, 0), COALESCE(s.bytes_served, 0), s.started_at, s.last_seen_at
+		FROM stream_sessions s
+		LEFT JOIN users u ON u.id = s.user_id
+		LEFT JOIN devices dev ON dev.id = s.device_id
+		LEFT JOIN profiles p ON p.id = s.profile_id`
+	var args []interface{}
+	if userID > 0 {
+		query += ` WHERE s.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY s.last_seen_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []StreamSession{}
+	for rows.Next() {
+		var s StreamSession
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Username, &s.DeviceID, &s.DeviceName, &s.ProfileID, &s.ProfileName, &s.AvatarURL,
+			&s.MediaType, &s.MediaID, &s.ClientIP, &s.Remote, &s.BytesServed, &s.StartedAt, &s.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// GetMonthRemoteBytes returns how many bytes a user has streamed outside the
+// local network this calendar month
+func (d *Database) GetMonthRemoteBytes(userID int64) (int64, error) {
+	monthStart := timezone.Now().Format("2006-01") + "-01"
+	var bytes int64
+	err := d.db.QueryRow(`
+		SELECT COALESCE(SUM(remote_bytes), 0) FROM bandwidth_usage WHERE user_id = ? AND day >= ?`,
+		userID, monthStart).Scan(&bytes)
+	return bytes, err
+}
+
+// GetTransferCaps returns the per-user monthly caps in GB
+func (d *Database) GetTransferCaps() (map[int64]int, error) {
+	rows, err := d.db.Query(`SELECT user_id, monthly_gb FROM transfer_caps`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	caps := make(map[int64]int)
+	for rows.Next() {
+		var userID int64
+		var gb int
+		if err := rows.Scan(&userID, &gb); err != nil {
+			return nil, err
+		}
+		caps[userID] = gb
+	}
+	return caps, rows.Err()
+}
+
+// SetTransferCap sets a user's monthly cap in GB (0 for unlimited), or
+// removes it when gb is nil so the default applies
+func (d *Database) SetTransferCap(userID int64, gb *int) error {
+	if gb == nil {
+		_, err := d.db.Exec(`DELETE FROM transfer_caps WHERE user_id = ?`, userID)
+		return err
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO transfer_caps (user_id, monthly_gb) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET monthly_gb = excluded.monthly_gb`, userID, *gb)
+	return err
+}
+
+// GetTransferCapBytes returns a user's monthly remote transfer cap in bytes:
+// their own cap, or the bandwidth_monthly_cap_gb default. 0 means unlimited.
+func (d *Database) GetTransferCapBytes(userID int64) int64 {
+	var gb int
+	err := d.db.QueryRow(`SELECT monthly_gb FROM transfer_caps WHERE user_id = ?`, userID).Scan(&gb)
+	if err != nil {
+		value, _ := d.GetSetting("bandwidth_monthly_cap_gb")
+		gb, _ = strconv.Atoi(value)
+	}
+	if gb <= 0 {
+		return 0
+	}
+	return int64(gb) << 30
+}
+
+// CleanupStreamSessions removes streaming sessions older than the given
+// number of days. Daily usage totals are kept.
+func (d *Database) CleanupStreamSessions(days int) error {
+	_, err := d.db.Exec(`DELETE FROM stream_sessions WHERE last_seen_at < ?`, time.Now().AddDate(0, 0, -days))
+	return err
+}
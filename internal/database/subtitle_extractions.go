@@ -0,0 +1,72 @@
+package database
+
+// Subtitle extraction operations
+//
+// Embedded subtitle tracks are extracted to WebVTT ahead of playback. Each
+// movie or episode file that's been through extraction is recorded, with
+// how many tracks it has ready and what went wrong, so the catch-up task
+// only looks at new and changed files.
+
+// SaveSubtitleExtraction records that a movie or episode file's subtitles
+// were extracted, replacing what was recorded before
+func (d *Database) SaveSubtitleExtraction(file *MediaFile, tracks int, errMsg *string) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO subtitle_extractions (media_type, media_id, path, tracks, error, extracted_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		file.MediaType, file.MediaID, file.Path, tracks, errMsg)
+	return err
+}
+
+// DeleteSubtitleExtraction forgets a movie or episode's subtitle extraction,
+// so the catch-up task extracts them again
+func (d *Database) DeleteSubtitleExtraction(mediaType string, mediaID int64) error {
+	_, err := d.db.Exec(`DELETE FROM subtitle_extractions WHERE media_type = ? AND media_id = ?`, mediaType, mediaID)
+	return err
+}
+
+// GetSubtitleExtractionCandidates returns the movie and episode files whose
+// subtitles haven't been extracted, or were extracted from a file since
+// moved, movies first and newest first
+func (d *Database) GetSubtitleExtractionCandidates(limit int) ([]MediaFile, error) {
+	rows, err := d.db.Query(`
+		SELECT media_type, media_id, path FROM (
+			SELECT 'movie' AS media_type, m.id AS media_id, m.path AS path, 0 AS rank
+			FROM movies m
+			WHERE m.path != '' AND m.missing_since IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM subtitle_extractions x
+			                  WHERE x.media_type = 'movie' AND x.media_id = m.id AND x.path = m.path)
+
+			UNION ALL
+			SELECT 'episode', e.id, e.path, 1
+			FROM episodes e
+			WHERE e.path != '' AND e.missing_since IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM subtitle_extractions x
+			                  WHERE x.media_type = 'episode' AND x.media_id = e.id AND x.path = e.path)
+		)
+		ORDER BY rank, media_id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		var f MediaFile
+		if err := rows.Scan(&f.MediaType, &f.MediaID, &f.Path); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// DeleteOrphanedSubtitleExtractions forgets the subtitle extractions of
+// movies and episodes no longer in the library
+func (d *Database) DeleteOrphanedSubtitleExtractions() error {
+	_, err := d.db.Exec(`
+		DELETE FROM subtitle_extractions
+		WHERE (media_type = 'movie' AND media_id NOT IN (SELECT id FROM movies))
+		   OR (media_type = 'episode' AND media_id NOT IN (SELECT id FROM episodes))`)
+	return err
+}
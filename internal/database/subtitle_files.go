@@ -0,0 +1,126 @@
+package database
+
+import (
+	"time"
+)
+
+// Downloaded subtitle operations
+//
+// Each subtitle the automatic subtitle search downloads is kept with how
+// well it scored against its video, so the Subtitle Upgrade task can look
+// for better ones while it's new. Subtitles that couldn't score better, and
+// ones added by hand, are never upgraded.
+
+// SubtitleFile is a subtitle the automatic subtitle search downloaded
+type SubtitleFile struct {
+	MediaType    string     `json:"mediaType"`
+	MediaID      int64      `json:"mediaId"`
+	Language     string     `json:"language"`
+	Forced       bool       `json:"forced"`
+	VideoPath    string     `json:"videoPath"`
+	Path         string     `json:"path"`
+	FileID       int        `json:"fileId"` // OpenSubtitles file ID
+	Release      *string    `json:"release,omitempty"`
+	Score        int        `json:"score"`
+	DownloadedAt time.Time  `json:"downloadedAt"`
+	CheckedAt    *time.Time `json:"checkedAt,omitempty"` // Last looked at for an upgrade
+}
+
+// SubtitleUpgradeCandidate is a downloaded subtitle to look for a better
+// one for, with the file it's for
+type SubtitleUpgradeCandidate struct {
+	Item SubtitleSearchItem
+	File SubtitleFile
+}
+
+// subtitleFileColumns selects a subtitle_files row as f
+const subtitleFileColumns = `
+	f.media_type, f.media_id, f.language, f.forced, f.video_path, f.path, f.file_id, f.release,
+	f.score, f.downloaded_at, f.checked_at`
+
+// subtitleFileDest returns where a row of subtitleFileColumns is scanned
+func subtitleFileDest(f *SubtitleFile) []interface{} {
+	return []interface{}{&f.MediaType, &f.MediaID, &f.Language, &f.Forced, &f.VideoPath, &f.Path,
+		&f.FileID, &f.Release, &f.Score, &f.DownloadedAt, &f.CheckedAt}
+}
+
+// SaveSubtitleFile records a downloaded subtitle, replacing the one before
+// in its language
+func (d *Database) SaveSubtitleFile(f *SubtitleFile) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO subtitle_files
+			(media_type, media_id, language, forced, video_path, path, file_id, release, score, downloaded_at, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, NULL)`,
+		f.MediaType, f.MediaID, f.Language, f.Forced, f.VideoPath, f.Path, f.FileID, f.Release, f.Score)
+	return err
+}
+
+// GetSubtitleFiles returns the subtitles downloaded for a movie or episode
+func (d *Database) GetSubtitleFiles(mediaType string, mediaID int64) ([]SubtitleFile, error) {
+	rows, err := d.db.Query(`SELECT `+subtitleFileColumns+`
+		FROM subtitle_files f
+		WHERE f.media_type = ? AND f.media_id = ?
+		ORDER BY f.language, f.forced`, mediaType, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []SubtitleFile
+	for rows.Next() {
+		var f SubtitleFile
+		if err := rows.Scan(subtitleFileDest(&f)...); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// MarkSubtitleFileChecked records that a downloaded subtitle was looked at
+// for an upgrade
+func (d *Database) MarkSubtitleFileChecked(f *SubtitleFile) error {
+	_, err := d.db.Exec(`
+		UPDATE subtitle_files SET checked_at = CURRENT_TIMESTAMP
+		WHERE media_type = ? AND media_id = ? AND language = ? AND forced = ?`,
+		f.MediaType, f.MediaID, f.Language, f.Forced)
+	return err
+}
+
+// DeleteSubtitleFile forgets a downloaded subtitle, so it's never upgraded
+func (d *Database) DeleteSubtitleFile(f *SubtitleFile) error {
+	_, err := d.db.Exec(`
+		DELETE FROM subtitle_files
+		WHERE media_type = ? AND media_id = ? AND language = ? AND forced = ?`,
+		f.MediaType, f.MediaID, f.Language, f.Forced)
+	return err
+}
+
+// GetSubtitleUpgradeCandidates returns the downloaded subtitles that could
+// score better, were downloaded since downloadedAfter and haven't been looked
+// at for an upgrade since checkedBefore, newest first. Only subtitles of
+// files still in a library with a subtitle profile, and still for the same
+// video, are returned.
+func (d *Database) GetSubtitleUpgradeCandidates(maxScore int, downloadedAfter, checkedBefore time.Time, limit int) ([]SubtitleUpgradeCandidate, error) {
+	rows, err := d.db.Query(`SELECT `+subtitleSearchItemsColumns+`, `+subtitleFileColumns+subtitleSearchItemsFrom+`
+		JOIN subtitle_files f ON f.media_type = i.media_type AND f.media_id = i.media_id AND f.video_path = i.path
+		WHERE f.score < ? AND f.downloaded_at > ?
+		  AND (f.checked_at IS NULL OR f.checked_at < ?)
+		ORDER BY f.downloaded_at DESC
+		LIMIT ?`,
+		maxScore, downloadedAfter.UTC().Format("2006-01-02 15:04:05"), checkedBefore.UTC().Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []SubtitleUpgradeCandidate
+	for rows.Next() {
+		var c SubtitleUpgradeCandidate
+		if err := rows.Scan(append(subtitleSearchItemDest(&c.Item), subtitleFileDest(&c.File)...)...); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
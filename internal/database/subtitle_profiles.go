@@ -0,0 +1,393 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Subtitle profile operations
+//
+// A subtitle profile is the subtitle languages a library wants, downloaded
+// from OpenSubtitles by the Subtitle Search task for the library's movie
+// and episode files that don't have them. Where the search is with each file
+// is kept, so files whose subtitles couldn't all be found are searched again
+// later, backing off, and what was downloaded, upgraded or not found is kept
+// as history.
+
+// Hearing impaired preferences of subtitle profiles
+const (
+	SubtitleHIAny     = "any"     // Whatever comes first
+	SubtitleHIPrefer  = "prefer"  // Hearing impaired when there are some
+	SubtitleHIOnly    = "only"    // Only hearing impaired
+	SubtitleHIExclude = "exclude" // Never hearing impaired
+)
+
+// Subtitle search statuses
+const (
+	SubtitleSearchDone    = "done"    // Every wanted language is there
+	SubtitleSearchMissing = "missing" // Some languages weren't found yet
+)
+
+// Subtitle history actions
+const (
+	SubtitleDownloaded = "downloaded"
+	SubtitleUpgraded   = "upgraded"
+	SubtitleNotFound   = "not_found"
+	SubtitleFailed     = "failed"
+)
+
+// SubtitleProfile is the subtitle languages wanted for a library
+type SubtitleProfile struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Languages       []string  `json:"languages"` // OpenSubtitles codes, e.g. en, pt-BR
+	HearingImpaired string    `json:"hearingImpaired"`
+	ForcedOnly      bool      `json:"forcedOnly"` // Only subtitles for foreign parts
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// SubtitleSearchItem is a movie or episode file to search subtitles for,
+// with what OpenSubtitles is searched by
+type SubtitleSearchItem struct {
+	MediaFile
+	LibraryID int64
+	ProfileID int64
+	Title     string // The show's for episodes
+	Year      int
+	TmdbID    int64 // The show's for episodes
+	ImdbID    string
+	Season    int
+	Episode   int
+}
+
+// SubtitleSearch is where the automatic subtitle search is with a movie or
+// episode file
+type SubtitleSearch struct {
+	MediaType    string     `json:"mediaType"`
+	MediaID      int64      `json:"mediaId"`
+	Path         string     `json:"path"`
+	Status       string     `json:"status"`
+	Missing      []string   `json:"missing"` // Languages not found yet
+	Attempts     int        `json:"attempts"`
+	Error        *string    `json:"error,omitempty"`
+	SearchedAt   time.Time  `json:"searchedAt"`
+	NextSearchAt *time.Time `json:"nextSearchAt,omitempty"`
+}
+
+// SubtitleHistoryEntry is a subtitle the automatic search downloaded or
+// upgraded, with its score, or a language it couldn't find
+type SubtitleHistoryEntry struct {
+	ID        int64     `json:"id"`
+	MediaType string    `json:"mediaType"`
+	MediaID   int64     `json:"mediaId"`
+	Language  string    `json:"language"`
+	Action    string    `json:"action"`
+	Release   *string   `json:"release,omitempty"`
+	Score     *int      `json:"score,omitempty"`
+	Path      *string   `json:"path,omitempty"`
+	Error     *string   `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetSubtitleProfiles returns the subtitle profiles by name
+func (d *Database) GetSubtitleProfiles() ([]SubtitleProfile, error) {
+	rows, err := d.db.Query(`
+		SELECT id, name, languages, hearing_impaired, forced_only, created_at
+		FROM subtitle_profiles ORDER BY name COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []SubtitleProfile
+	for rows.Next() {
+		var p SubtitleProfile
+		var languages string
+		if err := rows.Scan(&p.ID, &p.Name, &languages, &p.HearingImpaired, &p.ForcedOnly, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		p.Languages = splitProviders(languages)
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetSubtitleProfile returns a subtitle profile, or nil if there's no such
+// profile
+func (d *Database) GetSubtitleProfile(id int64) (*SubtitleProfile, error) {
+	var p SubtitleProfile
+	var languages string
+	err := d.db.QueryRow(`
+		SELECT id, name, languages, hearing_impaired, forced_only, created_at
+		FROM subtitle_profiles WHERE id = ?`, id).Scan(
+		&p.ID, &p.Name, &languages, &p.HearingImpaired, &p.ForcedOnly, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Languages = splitProviders(languages)
+	return &p, nil
+}
+
+// CreateSubtitleProfile adds a subtitle profile
+func (d *Database) CreateSubtitleProfile(p *SubtitleProfile) error {
+	result, err := d.db.Exec(`
+		INSERT INTO subtitle_profiles (name, languages, hearing_impaired, forced_only)
+		VALUES (?, ?, ?, ?)`,
+		p.Name, strings.Join(p.Languages, ","), p.HearingImpaired, p.ForcedOnly)
+	if err != nil {
+		return err
+	}
+	p.ID, _ = result.LastInsertId()
+	return d.db.QueryRow(`SELECT created_at FROM subtitle_profiles WHERE id = ?`, p.ID).Scan(&p.CreatedAt)
+}
+
+// UpdateSubtitleProfile saves a subtitle profile, and has the files of the
+// libraries using it searched again for what it now wants
+func (d *Database) UpdateSubtitleProfile(p *SubtitleProfile) error {
+	_, err := d.db.Exec(`
+		UPDATE subtitle_profiles SET name = ?, languages = ?, hearing_impaired = ?, forced_only = ?
+		WHERE id = ?`,
+		p.Name, strings.Join(p.Languages, ","), p.HearingImpaired, p.ForcedOnly, p.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := d.db.Query(`SELECT id FROM libraries WHERE subtitle_profile_id = ?`, p.ID)
+	if err != nil {
+		return err
+	}
+	var libraryIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		libraryIDs = append(libraryIDs, id)
+	}
+	rows.Close()
+	for _, id := range libraryIDs {
+		if err := d.resetLibrarySubtitleSearches(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSubtitleProfile deletes a subtitle profile, leaving the libraries
+// that used it without one
+func (d *Database) DeleteSubtitleProfile(id int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE libraries SET subtitle_profile_id = NULL WHERE subtitle_profile_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM subtitle_profiles WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// subtitleSearchItemsColumns and subtitleSearchItemsFrom select the movie
+// and episode files of libraries with a subtitle profile as i, movies ranked
+// first
+const subtitleSearchItemsColumns = `
+	i.media_type, i.media_id, i.path, i.library_id, i.profile_id, i.title, i.year, i.tmdb_id, i.imdb_id, i.season, i.episode`
+
+const subtitleSearchItemsFrom = `
+	FROM (
+		SELECT 'movie' AS media_type, m.id AS media_id, m.path AS path, m.library_id AS library_id,
+		       l.subtitle_profile_id AS profile_id, m.title AS title, COALESCE(m.year, 0) AS year,
+		       COALESCE(m.tmdb_id, 0) AS tmdb_id, COALESCE(m.imdb_id, '') AS imdb_id,
+		       0 AS season, 0 AS episode, 0 AS rank
+		FROM movies m
+		JOIN libraries l ON l.id = m.library_id
+		JOIN subtitle_profiles p ON p.id = l.subtitle_profile_id
+		WHERE m.path != '' AND m.missing_since IS NULL
+
+		UNION ALL
+		SELECT 'episode', e.id, e.path, sh.library_id, l.subtitle_profile_id, sh.title, COALESCE(sh.year, 0),
+		       COALESCE(sh.tmdb_id, 0), COALESCE(sh.imdb_id, ''), s.season_number, e.episode_number, 1
+		FROM episodes e
+		JOIN seasons s ON s.id = e.season_id
+		JOIN shows sh ON sh.id = s.show_id
+		JOIN libraries l ON l.id = sh.library_id
+		JOIN subtitle_profiles p ON p.id = l.subtitle_profile_id
+		WHERE e.path != '' AND e.missing_since IS NULL
+	) i`
+
+// subtitleSearchItemDest returns where a row of subtitleSearchItemsColumns
+// is scanned into an item
+func subtitleSearchItemDest(item *SubtitleSearchItem) []interface{} {
+	return []interface{}{&item.MediaType, &item.MediaID, &item.Path, &item.LibraryID, &item.ProfileID,
+		&item.Title, &item.Year, &item.TmdbID, &item.ImdbID, &item.Season, &item.Episode}
+}
+
+// scanSubtitleSearchItems reads the files selected by subtitleSearchItemsColumns
+func scanSubtitleSearchItems(rows *sql.Rows) ([]SubtitleSearchItem, error) {
+	defer rows.Close()
+
+	var items []SubtitleSearchItem
+	for rows.Next() {
+		var item SubtitleSearchItem
+		if err := rows.Scan(subtitleSearchItemDest(&item)...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetSubtitleSearchCandidates returns the files of libraries with a subtitle
+// profile that haven't been searched, were searched as another file, or are
+// due to be searched again, movies first and newest first
+func (d *Database) GetSubtitleSearchCandidates(limit int) ([]SubtitleSearchItem, error) {
+	rows, err := d.db.Query(`SELECT `+subtitleSearchItemsColumns+subtitleSearchItemsFrom+`
+		WHERE NOT EXISTS (SELECT 1 FROM subtitle_searches x
+		                  WHERE x.media_type = i.media_type AND x.media_id = i.media_id AND x.path = i.path
+		                    AND (x.status = 'done' OR x.next_search_at > CURRENT_TIMESTAMP))
+		ORDER BY i.rank, i.media_id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSubtitleSearchItems(rows)
+}
+
+// GetSubtitleSearchItem returns a movie or episode file to search subtitles
+// for, or nil if there's no such file or its library has no subtitle profile
+func (d *Database) GetSubtitleSearchItem(mediaType string, mediaID int64) (*SubtitleSearchItem, error) {
+	rows, err := d.db.Query(`SELECT `+subtitleSearchItemsColumns+subtitleSearchItemsFrom+`
+		WHERE i.media_type = ? AND i.media_id = ?`, mediaType, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanSubtitleSearchItems(rows)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// GetSubtitleSearch returns where the subtitle search is with a movie or
+// episode, or nil if it hasn't been searched
+func (d *Database) GetSubtitleSearch(mediaType string, mediaID int64) (*SubtitleSearch, error) {
+	var s SubtitleSearch
+	var missing sql.NullString
+	var nextSearchAt sql.NullTime
+	err := d.db.QueryRow(`
+		SELECT media_type, media_id, path, status, missing, attempts, error, searched_at, next_search_at
+		FROM subtitle_searches WHERE media_type = ? AND media_id = ?`, mediaType, mediaID).Scan(
+		&s.MediaType, &s.MediaID, &s.Path, &s.Status, &missing, &s.Attempts, &s.Error, &s.SearchedAt, &nextSearchAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Missing = splitProviders(missing.String)
+	if nextSearchAt.Valid {
+		s.NextSearchAt = &nextSearchAt.Time
+	}
+	return &s, nil
+}
+
+// SaveSubtitleSearch records where the subtitle search is with a movie or
+// episode file, replacing what was recorded before
+func (d *Database) SaveSubtitleSearch(s *SubtitleSearch) error {
+	var nextSearchAt interface{}
+	if s.NextSearchAt != nil {
+		nextSearchAt = s.NextSearchAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO subtitle_searches
+			(media_type, media_id, path, status, missing, attempts, error, searched_at, next_search_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)`,
+		s.MediaType, s.MediaID, s.Path, s.Status, strings.Join(s.Missing, ","), s.Attempts, s.Error, nextSearchAt)
+	return err
+}
+
+// DeleteSubtitleSearch forgets a movie or episode's subtitle search, so it's
+// searched again from scratch
+func (d *Database) DeleteSubtitleSearch(mediaType string, mediaID int64) error {
+	_, err := d.db.Exec(`DELETE FROM subtitle_searches WHERE media_type = ? AND media_id = ?`, mediaType, mediaID)
+	return err
+}
+
+// resetLibrarySubtitleSearches forgets the subtitle searches of a library's
+// files, for when what the library wants changes
+func (d *Database) resetLibrarySubtitleSearches(libraryID int64) error {
+	_, err := d.db.Exec(`
+		DELETE FROM subtitle_searches
+		WHERE (media_type = 'movie' AND media_id IN (SELECT id FROM movies WHERE library_id = ?))
+		   OR (media_type = 'episode' AND media_id IN (
+		           SELECT e.id FROM episodes e
+		           JOIN seasons s ON s.id = e.season_id
+		           JOIN shows sh ON sh.id = s.show_id
+		           WHERE sh.library_id = ?))`, libraryID, libraryID)
+	return err
+}
+
+// DeleteOrphanedSubtitleSearches forgets the subtitle searches, history and
+// downloaded subtitles of movies and episodes no longer in the library
+func (d *Database) DeleteOrphanedSubtitleSearches() error {
+	for _, table := range []string{"subtitle_searches", "subtitle_history", "subtitle_files"} {
+		_, err := d.db.Exec(`
+			DELETE FROM ` + table + `
+			WHERE (media_type = 'movie' AND media_id NOT IN (SELECT id FROM movies))
+			   OR (media_type = 'episode' AND media_id NOT IN (SELECT id FROM episodes))`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSubtitleHistory records a subtitle the automatic search downloaded or
+// couldn't find
+func (d *Database) AddSubtitleHistory(e *SubtitleHistoryEntry) error {
+	_, err := d.db.Exec(`
+		INSERT INTO subtitle_history (media_type, media_id, language, action, release, score, path, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.MediaType, e.MediaID, e.Language, e.Action, e.Release, e.Score, e.Path, e.Error)
+	return err
+}
+
+// GetSubtitleHistory returns the subtitle history of a movie or episode,
+// or of everything when mediaType is empty, newest first
+func (d *Database) GetSubtitleHistory(mediaType string, mediaID int64, limit int) ([]SubtitleHistoryEntry, error) {
+	query := `
+		SELECT id, media_type, media_id, language, action, release, score, path, error, created_at
+		FROM subtitle_history`
+	args := []interface{}{}
+	if mediaType != "" {
+		query += ` WHERE media_type = ? AND media_id = ?`
+		args = append(args, mediaType, mediaID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []SubtitleHistoryEntry
+	for rows.Next() {
+		var e SubtitleHistoryEntry
+		if err := rows.Scan(&e.ID, &e.MediaType, &e.MediaID, &e.Language, &e.Action,
+			&e.Release, &e.Score, &e.Path, &e.Error, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
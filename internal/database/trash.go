@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Trash operations
+//
+// Deleted movie and episode files are moved to the trash rather than
+// removed, and recorded here with what's needed to put them back.
+
+// TrashItem is a deleted file waiting in the trash
+type TrashItem struct {
+	ID            int64     `json:"id"`
+	MediaType     string    `json:"mediaType"` // movie or episode
+	MediaID       int64     `json:"mediaId"`   // The ID the media had before it was deleted
+	Title         string    `json:"title"`
+	OriginalPath  string    `json:"originalPath"`
+	TrashPath     string    `json:"trashPath"`
+	Size          int64     `json:"size"`
+	Record        string    `json:"-"` // JSON of the deleted library record, to restore it
+	DeletedBy     *int64    `json:"deletedBy,omitempty"`
+	DeletedByName string    `json:"deletedByName,omitempty"`
+	DeletedAt     time.Time `json:"deletedAt"`
+	ExpiresAt     time.Time `json:"expiresAt"` // When the trash task removes it for good
+}
+
+const trashColumns = `t.id, t.media_type, t.media_id, t.title, t.original_path, t.trash_path, t.size,
+	COALESCE(t.record, ''), t.deleted_by, COALESCE(u.username, ''), t.deleted_at`
+
+func scanTrashItem(row interface{ Scan(...interface{}) error }) (*TrashItem, error) {
+	var t TrashItem
+	err := row.Scan(&t.ID, &t.MediaType, &t.MediaID, &t.Title, &t.OriginalPath, &t.TrashPath, &t.Size,
+		&t.Record, &t.DeletedBy, &t.DeletedByName, &t.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTrashItem records a file moved to the trash
+func (d *Database) CreateTrashItem(item *TrashItem) error {
+	result, err := d.db.Exec(`
+		INSERT INTO trash_items (media_type, media_id, title, original_path, trash_path, size, record, deleted_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.MediaType, item.MediaID, item.Title, item.OriginalPath, item.TrashPath, item.Size, item.Record, item.DeletedBy,
+	)
+	if err != nil {
+		return err
+	}
+	item.ID, _ = result.LastInsertId()
+	item.DeletedAt = time.Now()
+	return nil
+}
+
+// GetTrashItem returns an item in the trash, or nil if there's none with the ID
+func (d *Database) GetTrashItem(id int64) (*TrashItem, error) {
+	item, err := scanTrashItem(d.db.QueryRow(`
+		SELECT `+trashColumns+` FROM trash_items t LEFT JOIN users u ON u.id = t.deleted_by
+		WHERE t.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return item, err
+}
+
+// GetTrashItems returns the items in the trash, most recently deleted first
+func (d *Database) GetTrashItems() ([]TrashItem, error) {
+	return d.queryTrashItems(``)
+}
+
+// GetExpiredTrashItems returns the items that have been in the trash for
+// more than the given number of days
+func (d *Database) GetExpiredTrashItems(days int) ([]TrashItem, error) {
+	return d.queryTrashItems(`WHERE t.deleted_at < datetime('now', '-' || ? || ' days')`, days)
+}
+
+func (d *Database) queryTrashItems(where string, args ...interface{}) ([]TrashItem, error) {
+	rows, err := d.db.Query(`
+		SELECT `+trashColumns+` FROM trash_items t LEFT JOIN users u ON u.id = t.deleted_by
+		`+where+` ORDER BY t.deleted_at DESC, t.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// DeleteTrashItem removes an item's record from the trash
+func (d *Database) DeleteTrashItem(id int64) error {
+	_, err := d.db.Exec(`DELETE FROM trash_items WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Trickplay operations
+//
+// A movie or episode's trickplay storyboard is a set of sprite sheets of
+// thumbnails taken at a fixed interval, which players show while scrubbing.
+// The sheets themselves live in the image cache; the database records how
+// they're laid out. Files that couldn't be read are recorded with the error
+// so they aren't tried again until the file changes.
+
+// Trickplay describes a movie or episode's trickplay storyboard
+type Trickplay struct {
+	MediaType       string    `json:"mediaType"`
+	MediaID         int64     `json:"mediaId"`
+	IntervalSeconds int       `json:"interval"`   // Seconds between thumbnails
+	Width           int       `json:"width"`      // Of a thumbnail
+	Height          int       `json:"height"`     // Of a thumbnail
+	Columns         int       `json:"columns"`    // Thumbnails across a sheet
+	Rows            int       `json:"rows"`       // Thumbnails down a sheet
+	Thumbnails      int       `json:"thumbnails"` // Across all sheets
+	Sheets          int       `json:"sheets"`
+	Error           *string   `json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+
+	// For filtering by who may see it
+	LibraryID     int64   `json:"-"`
+	ContentRating *string `json:"-"`
+}
+
+// GetTrickplay returns a movie or episode's storyboard, or nil if it has
+// none or is no longer in the library
+func (d *Database) GetTrickplay(mediaType string, mediaID int64) (*Trickplay, error) {
+	var t Trickplay
+	var libraryID sql.NullInt64
+	err := d.db.QueryRow(`
+		SELECT t.media_type, t.media_id, t.interval_seconds, t.width, t.height, t.tile_columns, t.tile_rows,
+		       t.thumbnails, t.sheets, t.error, t.created_at,
+		       COALESCE(m.library_id, s.library_id), COALESCE(m.content_rating, s.content_rating)
+		FROM trickplay t
+		LEFT JOIN movies m ON t.media_type = 'movie' AND m.id = t.media_id
+		LEFT JOIN episodes e ON t.media_type = 'episode' AND e.id = t.media_id
+		LEFT JOIN seasons sea ON e.season_id = sea.id
+		LEFT JOIN shows s ON sea.show_id = s.id
+		WHERE t.media_type = ? AND t.media_id = ?`, mediaType, mediaID).Scan(
+		&t.MediaType, &t.MediaID, &t.IntervalSeconds, &t.Width, &t.Height, &t.Columns, &t.Rows,
+		&t.Thumbnails, &t.Sheets, &t.Error, &t.CreatedAt, &libraryID, &t.ContentRating)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !libraryID.Valid {
+		return nil, nil
+	}
+	t.LibraryID = libraryID.Int64
+	return &t, nil
+}
+
+// SaveTrickplay records a movie or episode's storyboard, or why it couldn't
+// be made, replacing what was recorded before
+func (d *Database) SaveTrickplay(t *Trickplay) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO trickplay (media_type, media_id, interval_seconds, width, height, tile_columns,
+			tile_rows, thumbnails, sheets, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		t.MediaType, t.MediaID, t.IntervalSeconds, t.Width, t.Height, t.Columns, t.Rows, t.Thumbnails, t.Sheets, t.Error)
+	return err
+}
+
+// DeleteTrickplay forgets a movie or episode's storyboard
+func (d *Database) DeleteTrickplay(mediaType string, mediaID int64) error {
+	_, err := d.db.Exec(`DELETE FROM trickplay WHERE media_type = ? AND media_id = ?`, mediaType, mediaID)
+	return err
+}
+
+// GetTrickplayCandidates returns movie and episode files with no storyboard
+// and no failed attempt at one, movies first and newest first
+func (d *Database) GetTrickplayCandidates(limit int) ([]MediaFile, error) {
+	rows, err := d.db.Query(`
+		SELECT media_type, media_id, path FROM (
+			SELECT 'movie' AS media_type, m.id AS media_id, m.path AS path, 0 AS rank
+			FROM movies m
+			WHERE m.path != '' AND m.missing_since IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM trickplay t WHERE t.media_type = 'movie' AND t.media_id = m.id)
+
+			UNION ALL
+			SELECT 'episode', e.id, e.path, 1
+			FROM episodes e
+			WHERE e.path != '' AND e.missing_since IS NULL
+			  AND NOT EXISTS (SELECT 1 FROM trickplay t WHERE t.media_type = 'episode' AND t.media_id = e.id)
+		)
+		ORDER BY rank, media_id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []MediaFile
+	for rows.Next() {
+		var c MediaFile
+		if err := rows.Scan(&c.MediaType, &c.MediaID, &c.Path); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// GetOrphanedTrickplay returns the storyboards of movies and episodes no
+// longer in the library
+func (d *Database) GetOrphanedTrickplay() ([]Trickplay, error) {
+	rows, err := d.db.Query(`
+		SELECT media_type, media_id FROM trickplay t
+		WHERE (t.media_type = 'movie' AND NOT EXISTS (SELECT 1 FROM movies m WHERE m.id = t.media_id))
+		   OR (t.media_type = 'episode' AND NOT EXISTS (SELECT 1 FROM episodes e WHERE e.id = t.media_id))`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orphans []Trickplay
+	for rows.Next() {
+		var t Trickplay
+		if err := rows.Scan(&t.MediaType, &t.MediaID); err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, t)
+	}
+	return orphans, rows.Err()
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Account data operations
+//
+// Users can download everything the server keeps about their viewing, and
+// deleting an account anonymizes it rather than removing its rows: personal
+// data goes, while watch history, streams and requests stay behind under an
+// anonymous name so library and bandwidth stats still add up.
+
+// ExportedWatch is a watch history entry in an account export
+type ExportedWatch struct {
+	Profile   string    `json:"profile"`
+	MediaType string    `json:"mediaType"` // movie, episode, podcast_episode
+	TmdbID    *int64    `json:"tmdbId,omitempty"`
+	Title     string    `json:"title"`
+	WatchedAt time.Time `json:"watchedAt"`
+}
+
+// ExportedProgress is a partly watched item in an account export
+type ExportedProgress struct {
+	Profile   string    `json:"profile"`
+	MediaType string    `json:"mediaType"` // movie, episode, podcast_episode
+	Title     string    `json:"title"`
+	Position  float64   `json:"position"` // seconds
+	Duration  float64   `json:"duration"` // seconds
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExportedRating is a rating in an account export
+type ExportedRating struct {
+	MediaType string    `json:"mediaType"`
+	TmdbID    int64     `json:"tmdbId"`
+	Rating    string    `json:"rating"` // As given, e.g. {"rating":8}
+	RatedAt   time.Time `json:"ratedAt"`
+}
+
+// ExportedAnnotation is a book bookmark or highlight in an account export
+type ExportedAnnotation struct {
+	Profile   string    `json:"profile"`
+	Book      string    `json:"book"`
+	Type      string    `json:"type"` // bookmark, highlight
+	CFI       string    `json:"cfi"`
+	Text      *string   `json:"text,omitempty"`
+	Note      *string   `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// UserExport is everything kept about a user's account and viewing
+type UserExport struct {
+	ExportedAt   time.Time          `json:"exportedAt"`
+	Account      *User              `json:"account"`
+	Profiles     []Profile          `json:"profiles"`
+	WatchHistory []ExportedWatch    `json:"watchHistory"`
+	Progress     []ExportedProgress `json:"progress"`
+	Ratings      []ExportedRating   `json:"ratings"`
+	Requests     []Request          `json:"requests"`
+	Watchlist    []WatchlistItem    `json:"watchlist"`
+
+	BookAnnotations []ExportedAnnotation `json:"bookAnnotations"`
+}
+
+// exportMediaTitle names a watched movie, episode or podcast episode, such
+// as "Show Name S01E02 - Episode"
+func exportMediaTitle(mediaType, mediaID string) string {
+	return `CASE WHEN ` + mediaType + ` = 'movie'
+			THEN (SELECT title FROM movies WHERE id = ` + mediaID + `)
+			WHEN ` + mediaType + ` = 'podcast_episode'
+			THEN (SELECT pc.title || ' - ' || pe.title FROM podcast_episodes pe JOIN podcasts pc ON pc.id = pe.podcast_id
+				WHERE pe.id = ` + mediaID + `)
+			ELSE (SELECT sh.title || printf(' S%02dE%02d', se.season_number, e.episode_number) ||
+				COALESCE(' - ' || e.title, '')
+				FROM episodes e JOIN seasons se ON se.id = e.season_id JOIN shows sh ON sh.id = se.show_id
+				WHERE e.id = ` + mediaID + `) END`
+}
+
+// ExportUserData collects a user's account, profiles, watch history,
+// progress, ratings, requests, watchlist and book annotations
+func (d *Database) ExportUserData(userID int64) (*UserExport, error) {
+	user, err := d.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	export := &UserExport{
+		ExportedAt:   time.Now(),
+		Account:      user,
+		Profiles:     []Profile{},
+		WatchHistory: []ExportedWatch{},
+		Progress:     []ExportedProgress{},
+		Ratings:      []ExportedRating{},
+		Requests:     []Request{},
+		Watchlist:    []WatchlistItem{},
+
+		BookAnnotations: []ExportedAnnotation{},
+	}
+
+	if profiles, err := d.GetProfilesByUser(userID); err != nil {
+		return nil, err
+	} else if profiles != nil {
+		export.Profiles = profiles
+	}
+
+	rows, err := d.db.Query(`
+		SELECT p.name, w.media_type, w.tmdb_id, COALESCE(`+exportMediaTitle("w.media_type", "w.media_id")+`, ''), w.watched_at
+		FROM watch_history w
+		JOIN profiles p ON p.id = w.profile_id
+		WHERE p.user_id = ?
+		ORDER BY w.watched_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var w ExportedWatch
+		var watchedAt string
+		if err := rows.Scan(&w.Profile, &w.MediaType, &w.TmdbID, &w.Title, &watchedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		w.WatchedAt, _ = time.Parse(time.RFC3339, watchedAt)
+		export.WatchHistory = append(export.WatchHistory, w)
+	}
+	rows.Close()
+
+	rows, err = d.db.Query(`
+		SELECT p.name, pr.media_type, COALESCE(`+exportMediaTitle("pr.media_type", "pr.media_id")+`, ''),
+		       pr.position, pr.duration, pr.updated_at
+		FROM progress pr
+		JOIN profiles p ON p.id = pr.profile_id
+		WHERE p.user_id = ?
+		ORDER BY pr.updated_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p ExportedProgress
+		if err := rows.Scan(&p.Profile, &p.MediaType, &p.Title, &p.Position, &p.Duration, &p.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.Progress = append(export.Progress, p)
+	}
+	rows.Close()
+
+	// Ratings are only kept while they wait to be synced to Trakt
+	rows, err = d.db.Query(`
+		SELECT media_type, tmdb_id, COALESCE(data, ''), created_at
+		FROM trakt_sync_queue
+		WHERE user_id = ? AND action = 'rating'
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var r ExportedRating
+		if err := rows.Scan(&r.MediaType, &r.TmdbID, &r.Rating, &r.RatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.Ratings = append(export.Ratings, r)
+	}
+	rows.Close()
+
+	rows, err = d.db.Query(`
+		SELECT p.name, COALESCE(b.title, ''), a.type, a.cfi, a.text, a.note, a.created_at
+		FROM book_annotations a
+		JOIN profiles p ON p.id = a.profile_id
+		LEFT JOIN books b ON b.id = a.book_id
+		WHERE p.user_id = ?
+		ORDER BY a.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a ExportedAnnotation
+		if err := rows.Scan(&a.Profile, &a.Book, &a.Type, &a.CFI, &a.Text, &a.Note, &a.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.BookAnnotations = append(export.BookAnnotations, a)
+	}
+	rows.Close()
+
+	if requests, err := d.GetRequestsByUser(userID); err != nil {
+		return nil, err
+	} else if requests != nil {
+		export.Requests = requests
+	}
+	if watchlist, err := d.GetWatchlist(userID); err != nil {
+		return nil, err
+	} else if watchlist != nil {
+		export.Watchlist = watchlist
+	}
+
+	return export, nil
+}
+
+// AnonymizeUser deletes an account's personal data and leaves its history
+// behind under an anonymous name. The user row is kept, disabled and marked
+// deleted, so requests, streams and audit entries still point somewhere.
+func (d *Database) AnonymizeUser(userID int64) error {
+	// An unguessable password hash nobody can sign in with
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("deleted-user-%d", userID)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		// Personal data and anything that could sign in
+		`DELETE FROM sessions WHERE user_id = ?1`,
+		`DELETE FROM pin_elevations WHERE user_id = ?1`,
+		`DELETE FROM password_resets WHERE user_id = ?1`,
+		`DELETE FROM api_keys WHERE user_id = ?1`,
+		`DELETE FROM login_devices WHERE user_id = ?1`,
+		`DELETE FROM devices WHERE user_id = ?1`,
+		`DELETE FROM pending_operations WHERE requested_by = ?1 AND status = 'pending'`,
+		`DELETE FROM trakt_config WHERE user_id = ?1`,
+		`DELETE FROM trakt_sync_queue WHERE user_id = ?1`,
+		`DELETE FROM user_watchlist WHERE user_id = ?1`,
+		`DELETE FROM notifications WHERE user_id = ?1`,
+		`DELETE FROM smart_playlists WHERE user_id = ?1`,
+		`DELETE FROM transfer_caps WHERE user_id = ?1`,
+		`DELETE FROM track_plays WHERE user_id = ?1`,
+		`DELETE FROM playlist_tracks WHERE playlist_id IN (SELECT id FROM playlists WHERE user_id = ?1)`,
+		`DELETE FROM playlists WHERE user_id = ?1`,
+		`DELETE FROM podcast_subscriptions WHERE user_id = ?1`,
+		`DELETE FROM progress WHERE profile_id IN (SELECT id FROM profiles WHERE user_id = ?1)`,
+		`DELETE FROM reading_progress WHERE profile_id IN (SELECT id FROM profiles WHERE user_id = ?1)`,
+		`DELETE FROM book_positions WHERE profile_id IN (SELECT id FROM profiles WHERE user_id = ?1)`,
+		`DELETE FROM book_annotations WHERE profile_id IN (SELECT id FROM profiles WHERE user_id = ?1)`,
+
+		// Watch history stays for play counts, detached from the profiles
+		`UPDATE watch_history SET profile_id = NULL WHERE profile_id IN (SELECT id FROM profiles WHERE user_id = ?1)`,
+		`DELETE FROM profiles WHERE user_id = ?1`,
+
+		// Requests, streams and audit entries stay under the anonymous name
+		`UPDATE requests SET requester_name = NULL WHERE user_id = ?1`,
+		`UPDATE stream_sessions SET client_ip = NULL WHERE user_id = ?1`,
+		`UPDATE audit_log SET username = ?2, ip_address = NULL WHERE user_id = ?1`,
+
+		`UPDATE users SET username = ?2, password_hash = ?3, role = 'user', email = NULL, pin_hash = NULL,
+			require_pin = 0, disabled = 1, expires_at = NULL, deleted_at = CURRENT_TIMESTAMP WHERE id = ?1`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, userID, name, hex.EncodeToString(secret)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
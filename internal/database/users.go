@@ -1,17 +1,78 @@
 package database
 
-import "time"
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
 
 // User represents a user account
 type User struct {
 	ID                 int64     `json:"id"`
 	Username           string    `json:"username"`
 	PasswordHash       string    `json:"-"` // Never expose in JSON
-	Role               string    `json:"role"` // admin, user, kid
+	Role               string    `json:"role"` // admin, user, kid, guest
 	ContentRatingLimit *string   `json:"contentRatingLimit,omitempty"` // G, PG, PG-13, R, NC-17, or nil (no limit)
 	PinHash            *string   `json:"-"`                            // PIN hash, never expose
 	RequirePin         bool      `json:"requirePin"`                   // Require PIN for elevated content
+	Email              *string   `json:"email,omitempty"`              // Used for self-service password resets
+	LibraryIDs         []int64   `json:"libraryIds"`                   // Libraries the user can see, nil means all
+	RequestQuota       *int      `json:"requestQuota,omitempty"`       // Max requests per RequestQuotaDays, nil means unlimited
+	RequestQuotaDays   int       `json:"requestQuotaDays"`
 	CreatedAt          time.Time `json:"createdAt"`
+
+	// LanOnly users may only sign in and stream from the local network
+	LanOnly bool `json:"lanOnly"`
+
+	// Accounts past ExpiresAt are disabled; disabled accounts can't sign in
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Disabled  bool       `json:"disabled"`
+
+	// Language for notifications and messages, nil uses the server's
+	Language *string `json:"language,omitempty"`
+	// IANA time zone for calendars and dates, nil uses the server's
+	Timezone *string `json:"timezone,omitempty"`
+}
+
+// Active reports whether the account may sign in: it isn't disabled and
+// hasn't expired
+func (u *User) Active() bool {
+	return !u.Disabled && (u.ExpiresAt == nil || time.Now().Before(*u.ExpiresAt))
+}
+
+// DefaultRequestQuotaDays is the quota window used when none is set
+const DefaultRequestQuotaDays = 7
+
+// CanAccessLibrary reports whether the user is allowed to see content from a library
+func (u *User) CanAccessLibrary(libraryID int64) bool {
+	if u.LibraryIDs == nil || u.Role == "admin" {
+		return true
+	}
+	for _, id := range u.LibraryIDs {
+		if id == libraryID {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeLibraryIDs stores a library access list as JSON. nil means all libraries.
+func encodeLibraryIDs(ids []int64) *string {
+	if ids == nil {
+		return nil
+	}
+	data, _ := json.Marshal(ids)
+	str := string(data)
+	return &str
+}
+
+func decodeLibraryIDs(raw sql.NullString) []int64 {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	ids := []int64{}
+	json.Unmarshal([]byte(raw.String), &ids)
+	return ids
 }
 
 // Profile represents a viewing profile within a user account (Netflix-style)
@@ -24,6 +85,13 @@ type Profile struct {
 	IsKid              bool      `json:"isKid"`
 	ContentRatingLimit *string   `json:"contentRatingLimit,omitempty"`
 	CreatedAt          time.Time `json:"createdAt"`
+
+	// Overrides of the server's played rule; nil uses the server's
+	PlayedThreshold *int  `json:"playedThreshold,omitempty"`
+	PlayedCredits   *bool `json:"playedCredits,omitempty"`
+
+	// Whether the profile's watches show in the household activity feed
+	ShareActivity bool `json:"shareActivity"`
 }
 
 // ContentRatingLevel returns the numeric level for a content rating (for comparison)
@@ -147,6 +215,20 @@ type Session struct {
 	Token           string    `json:"token"`
 	ExpiresAt       time.Time `json:"expiresAt"`
 	ActiveProfileID *int64    `json:"activeProfileId,omitempty"`
+	ImpersonatorID  *int64    `json:"impersonatorId,omitempty"` // Admin who created this session on the user's behalf
+	DeviceID        *int64    `json:"deviceId,omitempty"`
+}
+
+// PasswordReset represents a one-time password reset token. Only a hash of the
+// token is stored so a leaked database can't be used to take over accounts.
+type PasswordReset struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"userId"`
+	TokenHash string     `json:"-"`
+	CreatedBy *int64     `json:"createdBy,omitempty"` // Admin who generated the link, nil for self-service
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
 }
 
 // PinElevation represents a temporary elevated access session after PIN verification
@@ -160,9 +242,13 @@ type PinElevation struct {
 // User operations
 
 func (d *Database) CreateUser(user *User) error {
+	if user.RequestQuotaDays <= 0 {
+		user.RequestQuotaDays = DefaultRequestQuotaDays
+	}
 	result, err := d.db.Exec(
-		"INSERT INTO users (username, password_hash, role, content_rating_limit, pin_hash, require_pin) VALUES (?, ?, ?, ?, ?, ?)",
-		user.Username, user.PasswordHash, user.Role, user.ContentRatingLimit, user.PinHash, user.RequirePin,
+		"INSERT INTO users (username, password_hash, role, content_rating_limit, pin_hash, require_pin, email, library_ids, request_quota, request_quota_days, lan_only, expires_at, disabled, language, timezone) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		user.Username, user.PasswordHash, user.Role, user.ContentRatingLimit, user.PinHash, user.RequirePin, user.Email,
+		encodeLibraryIDs(user.LibraryIDs), user.RequestQuota, user.RequestQuotaDays, user.LanOnly, user.ExpiresAt, user.Disabled, user.Language, user.Timezone,
 	)
 	if err != nil {
 		return err
@@ -174,31 +260,35 @@ func (d *Database) CreateUser(user *User) error {
 func (d *Database) GetUserByUsername(username string) (*User, error) {
 	var u User
 	var requirePin int
+	var libraryIDs sql.NullString
 	err := d.db.QueryRow(
-		"SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, created_at FROM users WHERE username = ?", username,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.CreatedAt)
+		"SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, email, library_ids, request_quota, COALESCE(request_quota_days, 7), created_at, COALESCE(lan_only, 0), expires_at, COALESCE(disabled, 0), language, timezone FROM users WHERE username = ?", username,
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.Email, &libraryIDs, &u.RequestQuota, &u.RequestQuotaDays, &u.CreatedAt, &u.LanOnly, &u.ExpiresAt, &u.Disabled, &u.Language, &u.Timezone)
 	if err != nil {
 		return nil, err
 	}
 	u.RequirePin = requirePin == 1
+	u.LibraryIDs = decodeLibraryIDs(libraryIDs)
 	return &u, nil
 }
 
 func (d *Database) GetUserByID(id int64) (*User, error) {
 	var u User
 	var requirePin int
+	var libraryIDs sql.NullString
 	err := d.db.QueryRow(
-		"SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, created_at FROM users WHERE id = ?", id,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.CreatedAt)
+		"SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, email, library_ids, request_quota, COALESCE(request_quota_days, 7), created_at, COALESCE(lan_only, 0), expires_at, COALESCE(disabled, 0), language, timezone FROM users WHERE id = ?", id,
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.Email, &libraryIDs, &u.RequestQuota, &u.RequestQuotaDays, &u.CreatedAt, &u.LanOnly, &u.ExpiresAt, &u.Disabled, &u.Language, &u.Timezone)
 	if err != nil {
 		return nil, err
 	}
 	u.RequirePin = requirePin == 1
+	u.LibraryIDs = decodeLibraryIDs(libraryIDs)
 	return &u, nil
 }
 
 func (d *Database) GetUsers() ([]User, error) {
-	rows, err := d.db.Query("SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, created_at FROM users ORDER BY created_at")
+	rows, err := d.db.Query("SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, email, library_ids, request_quota, COALESCE(request_quota_days, 7), created_at, COALESCE(lan_only, 0), expires_at, COALESCE(disabled, 0), language, timezone FROM users WHERE deleted_at IS NULL ORDER BY created_at")
 	if err != nil {
 		return nil, err
 	}
@@ -208,19 +298,26 @@ func (d *Database) GetUsers() ([]User, error) {
 	for rows.Next() {
 		var u User
 		var requirePin int
-		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.CreatedAt); err != nil {
+		var libraryIDs sql.NullString
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.Email, &libraryIDs, &u.RequestQuota, &u.RequestQuotaDays, &u.CreatedAt, &u.LanOnly, &u.ExpiresAt, &u.Disabled, &u.Language, &u.Timezone); err != nil {
 			return nil, err
 		}
 		u.RequirePin = requirePin == 1
+		u.LibraryIDs = decodeLibraryIDs(libraryIDs)
 		users = append(users, u)
 	}
 	return users, nil
 }
 
 func (d *Database) UpdateUser(user *User) error {
+	if user.RequestQuotaDays <= 0 {
+		user.RequestQuotaDays = DefaultRequestQuotaDays
+	}
 	_, err := d.db.Exec(
-		"UPDATE users SET username = ?, role = ?, content_rating_limit = ?, require_pin = ? WHERE id = ?",
-		user.Username, user.Role, user.ContentRatingLimit, user.RequirePin, user.ID,
+		"UPDATE users SET username = ?, role = ?, content_rating_limit = ?, require_pin = ?, email = ?, library_ids = ?, request_quota = ?, request_quota_days = ?, lan_only = ?, expires_at = ?, disabled = ?, language = ?, timezone = ? WHERE id = ?",
+		user.Username, user.Role, user.ContentRatingLimit, user.RequirePin, user.Email,
+		encodeLibraryIDs(user.LibraryIDs), user.RequestQuota, user.RequestQuotaDays, user.LanOnly, user.ExpiresAt, user.Disabled,
+		user.Language, user.Timezone, user.ID,
 	)
 	return err
 }
@@ -240,6 +337,52 @@ func (d *Database) DeleteUser(id int64) error {
 	return err
 }
 
+// GetUserByEmail looks up a user by email address (case-insensitive)
+func (d *Database) GetUserByEmail(email string) (*User, error) {
+	var u User
+	var requirePin int
+	var libraryIDs sql.NullString
+	err := d.db.QueryRow(
+		"SELECT id, username, password_hash, role, content_rating_limit, pin_hash, require_pin, email, library_ids, request_quota, COALESCE(request_quota_days, 7), created_at, COALESCE(lan_only, 0), expires_at, COALESCE(disabled, 0), language, timezone FROM users WHERE email = ? COLLATE NOCASE", email,
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.ContentRatingLimit, &u.PinHash, &requirePin, &u.Email, &libraryIDs, &u.RequestQuota, &u.RequestQuotaDays, &u.CreatedAt, &u.LanOnly, &u.ExpiresAt, &u.Disabled, &u.Language, &u.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	u.RequirePin = requirePin == 1
+	u.LibraryIDs = decodeLibraryIDs(libraryIDs)
+	return &u, nil
+}
+
+// DisableExpiredUsers disables accounts whose expiry has passed and returns
+// their IDs, so their sessions can be revoked
+func (d *Database) DisableExpiredUsers() ([]int64, error) {
+	rows, err := d.db.Query(
+		"SELECT id FROM users WHERE expires_at IS NOT NULL AND expires_at <= ? AND COALESCE(disabled, 0) = 0", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err := d.db.Exec("UPDATE users SET disabled = 1 WHERE id = ?", id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
 func (d *Database) CountUsers() (int, error) {
 	var count int
 	err := d.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
@@ -250,9 +393,10 @@ func (d *Database) CountUsers() (int, error) {
 
 func (d *Database) CreateProfile(profile *Profile) error {
 	result, err := d.db.Exec(
-		`INSERT INTO profiles (user_id, name, avatar_url, is_default, is_kid, content_rating_limit)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO profiles (user_id, name, avatar_url, is_default, is_kid, content_rating_limit, played_threshold, played_credits, share_activity)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		profile.UserID, profile.Name, profile.AvatarURL, profile.IsDefault, profile.IsKid, profile.ContentRatingLimit,
+		profile.PlayedThreshold, profile.PlayedCredits, profile.ShareActivity,
 	)
 	if err != nil {
 		return err
@@ -265,9 +409,11 @@ func (d *Database) GetProfile(id int64) (*Profile, error) {
 	var p Profile
 	var isDefault, isKid int
 	err := d.db.QueryRow(
-		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at
+		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at,
+		        played_threshold, played_credits, COALESCE(share_activity, 0)
 		 FROM profiles WHERE id = ?`, id,
-	).Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt)
+	).Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt,
+		&p.PlayedThreshold, &p.PlayedCredits, &p.ShareActivity)
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +424,8 @@ func (d *Database) GetProfile(id int64) (*Profile, error) {
 
 func (d *Database) GetProfilesByUser(userID int64) ([]Profile, error) {
 	rows, err := d.db.Query(
-		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at
+		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at,
+		        played_threshold, played_credits, COALESCE(share_activity, 0)
 		 FROM profiles WHERE user_id = ? ORDER BY is_default DESC, created_at ASC`, userID,
 	)
 	if err != nil {
@@ -290,7 +437,8 @@ func (d *Database) GetProfilesByUser(userID int64) ([]Profile, error) {
 	for rows.Next() {
 		var p Profile
 		var isDefault, isKid int
-		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt,
+			&p.PlayedThreshold, &p.PlayedCredits, &p.ShareActivity); err != nil {
 			return nil, err
 		}
 		p.IsDefault = isDefault == 1
@@ -304,9 +452,11 @@ func (d *Database) GetDefaultProfile(userID int64) (*Profile, error) {
 	var p Profile
 	var isDefault, isKid int
 	err := d.db.QueryRow(
-		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at
+		`SELECT id, user_id, name, avatar_url, is_default, is_kid, content_rating_limit, created_at,
+		        played_threshold, played_credits, COALESCE(share_activity, 0)
 		 FROM profiles WHERE user_id = ? AND is_default = 1`, userID,
-	).Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt)
+	).Scan(&p.ID, &p.UserID, &p.Name, &p.AvatarURL, &isDefault, &isKid, &p.ContentRatingLimit, &p.CreatedAt,
+		&p.PlayedThreshold, &p.PlayedCredits, &p.ShareActivity)
 	if err != nil {
 		return nil, err
 	}
@@ -317,9 +467,11 @@ func (d *Database) GetDefaultProfile(userID int64) (*Profile, error) {
 
 func (d *Database) UpdateProfile(profile *Profile) error {
 	_, err := d.db.Exec(
-		`UPDATE profiles SET name = ?, avatar_url = ?, is_kid = ?, content_rating_limit = ?
+		`UPDATE profiles SET name = ?, avatar_url = ?, is_kid = ?, content_rating_limit = ?,
+		 played_threshold = ?, played_credits = ?, share_activity = ?
 		 WHERE id = ?`,
-		profile.Name, profile.AvatarURL, profile.IsKid, profile.ContentRatingLimit, profile.ID,
+		profile.Name, profile.AvatarURL, profile.IsKid, profile.ContentRatingLimit,
+		profile.PlayedThreshold, profile.PlayedCredits, profile.ShareActivity, profile.ID,
 	)
 	return err
 }
@@ -352,8 +504,8 @@ func (d *Database) CreateDefaultProfileForUser(userID int64, username string) (*
 
 func (d *Database) CreateSession(session *Session) error {
 	result, err := d.db.Exec(
-		"INSERT INTO sessions (user_id, token, expires_at) VALUES (?, ?, ?)",
-		session.UserID, session.Token, session.ExpiresAt,
+		"INSERT INTO sessions (user_id, token, expires_at, impersonator_id) VALUES (?, ?, ?, ?)",
+		session.UserID, session.Token, session.ExpiresAt, session.ImpersonatorID,
 	)
 	if err != nil {
 		return err
@@ -365,8 +517,8 @@ func (d *Database) CreateSession(session *Session) error {
 func (d *Database) GetSessionByToken(token string) (*Session, error) {
 	var s Session
 	err := d.db.QueryRow(
-		"SELECT id, user_id, token, expires_at, active_profile_id FROM sessions WHERE token = ?", token,
-	).Scan(&s.ID, &s.UserID, &s.Token, &s.ExpiresAt, &s.ActiveProfileID)
+		"SELECT id, user_id, token, expires_at, active_profile_id, impersonator_id, device_id FROM sessions WHERE token = ?", token,
+	).Scan(&s.ID, &s.UserID, &s.Token, &s.ExpiresAt, &s.ActiveProfileID, &s.ImpersonatorID, &s.DeviceID)
 	if err != nil {
 		return nil, err
 	}
@@ -388,6 +540,12 @@ func (d *Database) DeleteExpiredSessions() error {
 	return err
 }
 
+// DeleteImpersonationSessions removes all impersonation sessions targeting a user
+func (d *Database) DeleteImpersonationSessions(userID int64) error {
+	_, err := d.db.Exec("DELETE FROM sessions WHERE user_id = ? AND impersonator_id IS NOT NULL", userID)
+	return err
+}
+
 func (d *Database) DeleteUserSessions(userID int64) error {
 	_, err := d.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
 	return err
@@ -432,3 +590,55 @@ func (d *Database) DeleteUserPinElevations(userID int64) error {
 	_, err := d.db.Exec("DELETE FROM pin_elevations WHERE user_id = ?", userID)
 	return err
 }
+
+// Password reset operations
+
+func (d *Database) CreatePasswordReset(reset *PasswordReset) error {
+	result, err := d.db.Exec(
+		"INSERT INTO password_resets (user_id, token_hash, created_by, expires_at) VALUES (?, ?, ?, ?)",
+		reset.UserID, reset.TokenHash, reset.CreatedBy, reset.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	reset.ID, _ = result.LastInsertId()
+	reset.CreatedAt = time.Now()
+	return nil
+}
+
+// GetPasswordResetByTokenHash returns an unused reset token. Expiry is checked by the caller.
+func (d *Database) GetPasswordResetByTokenHash(tokenHash string) (*PasswordReset, error) {
+	var r PasswordReset
+	err := d.db.QueryRow(
+		"SELECT id, user_id, token_hash, created_by, expires_at, used_at, created_at FROM password_resets WHERE token_hash = ? AND used_at IS NULL", tokenHash,
+	).Scan(&r.ID, &r.UserID, &r.TokenHash, &r.CreatedBy, &r.ExpiresAt, &r.UsedAt, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// MarkPasswordResetUsed consumes a reset token, returning sql.ErrNoRows if
+// it was already used, so two requests can't both use it
+func (d *Database) MarkPasswordResetUsed(id int64) error {
+	result, err := d.db.Exec("UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUserPasswordResets removes all outstanding reset tokens for a user
+func (d *Database) DeleteUserPasswordResets(userID int64) error {
+	_, err := d.db.Exec("DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", userID)
+	return err
+}
+
+// DeleteExpiredPasswordResets removes used and expired reset tokens
+func (d *Database) DeleteExpiredPasswordResets() error {
+	_, err := d.db.Exec("DELETE FROM password_resets WHERE expires_at < ? OR used_at IS NOT NULL", time.Now())
+	return err
+}
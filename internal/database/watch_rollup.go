@@ -0,0 +1,149 @@
+package database
+
+import "database/sql"
+
+// Season and show watch state operations
+
+// SeasonWatchState is the watch state of one season of a show
+type SeasonWatchState struct {
+	SeasonNumber    int    `json:"seasonNumber"`
+	WatchState      string `json:"watchState"` // "unwatched", "partial", "watched"
+	WatchedEpisodes int    `json:"watchedEpisodes"`
+	TotalEpisodes   int    `json:"totalEpisodes"`
+}
+
+// ShowWatchDetail is a show's watch state with a breakdown per season
+type ShowWatchDetail struct {
+	ShowWatchState
+	Seasons []SeasonWatchState `json:"seasons"`
+}
+
+// SetShowWatched marks every episode of a show watched or unwatched for a
+// profile in one transaction. A non-nil seasonNumber limits it to one season.
+// Returns how many episodes were updated.
+func (d *Database) SetShowWatched(profileID *int64, showID int64, seasonNumber *int, watched bool) (int, error) {
+	query := `
+		SELECT e.id, COALESCE(e.runtime, 0)
+		FROM episodes e
+		JOIN seasons sea ON sea.id = e.season_id
+		WHERE sea.show_id = ?`
+	args := []interface{}{showID}
+	if seasonNumber != nil {
+		query += ` AND sea.season_number = ?`
+		args = append(args, *seasonNumber)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	type episodeRuntime struct {
+		id      int64
+		runtime int
+	}
+	var episodes []episodeRuntime
+	for rows.Next() {
+		var e episodeRuntime
+		if err := rows.Scan(&e.id, &e.runtime); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		episodes = append(episodes, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stmt *sql.Stmt
+	if watched {
+		// Keep the duration the player reported if there is one
+		stmt, err = tx.Prepare(`
+			INSERT INTO progress (profile_id, media_type, media_id, position, duration, updated_at)
+			VALUES (?, 'episode', ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(media_type, media_id) DO UPDATE SET
+				profile_id = excluded.profile_id,
+				position = CASE WHEN progress.duration > 0 THEN progress.duration ELSE excluded.duration END,
+				duration = CASE WHEN progress.duration > 0 THEN progress.duration ELSE excluded.duration END,
+				updated_at = CURRENT_TIMESTAMP`)
+	} else {
+		stmt, err = tx.Prepare(`DELETE FROM progress WHERE media_type = 'episode' AND media_id = ?`)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, e := range episodes {
+		if watched {
+			duration := float64(e.runtime * 60)
+			if duration <= 0 {
+				duration = 3600 // Default 1 hour, as for single episodes
+			}
+			_, err = stmt.Exec(profileID, e.id, duration, duration)
+		} else {
+			_, err = stmt.Exec(e.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(episodes), nil
+}
+
+// GetShowWatchDetail returns the watch state of a show and each of its
+// seasons, counting episodes played under the rule
+func (d *Database) GetShowWatchDetail(rule PlayedRule, showID int64) (*ShowWatchDetail, error) {
+	rows, err := d.db.Query(`
+		SELECT sea.season_number, COUNT(e.id),
+			COUNT(CASE WHEN `+rule.playedCondition()+` THEN 1 END)
+		FROM seasons sea
+		LEFT JOIN episodes e ON e.season_id = sea.id
+		LEFT JOIN progress p ON p.media_type = 'episode' AND p.media_id = e.id
+		WHERE sea.show_id = ?
+		GROUP BY sea.id
+		ORDER BY sea.season_number`, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	detail := &ShowWatchDetail{Seasons: []SeasonWatchState{}}
+	for rows.Next() {
+		var season SeasonWatchState
+		if err := rows.Scan(&season.SeasonNumber, &season.TotalEpisodes, &season.WatchedEpisodes); err != nil {
+			return nil, err
+		}
+		season.WatchState = rollUpWatchState(season.WatchedEpisodes, season.TotalEpisodes)
+		detail.Seasons = append(detail.Seasons, season)
+		detail.TotalEpisodes += season.TotalEpisodes
+		detail.WatchedEpisodes += season.WatchedEpisodes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	detail.WatchState = rollUpWatchState(detail.WatchedEpisodes, detail.TotalEpisodes)
+	return detail, nil
+}
+
+// rollUpWatchState turns episode counts into a watch state, the same way
+// GetAllShowWatchStates does
+func rollUpWatchState(watched, total int) string {
+	if watched >= total && total > 0 {
+		return "watched"
+	}
+	if watched > 0 {
+		return "partial"
+	}
+	return "unwatched"
+}
@@ -0,0 +1,88 @@
+package download
+
+import (
+	"time"
+)
+
+// Import retries
+//
+// Imports that fail for reasons that can go away on their own - a locked
+// file, a library share that's down, a full disk - are retried
+// automatically, waiting longer after each attempt. Once the attempts run
+// out the download stays in the manual import queue, with the error of every
+// attempt.
+
+const (
+	// ImportRetryDelay is how long after a first failure an import is
+	// retried; it doubles with each attempt
+	ImportRetryDelay = 5 * time.Minute
+	// ImportRetryMaxDelay is the longest an import waits to be retried
+	ImportRetryMaxDelay = 6 * time.Hour
+	// DefaultImportRetryAttempts is how many times an import is retried
+	// when the import_retry_max_attempts setting isn't set
+	DefaultImportRetryAttempts = 5
+)
+
+// ImportRetry is where the automatic retries of a download's import are
+type ImportRetry struct {
+	Attempts int        `json:"attempts"`
+	NextAt   *time.Time `json:"nextAt,omitempty"` // Unset while no retry is waiting
+	// Failures are the import's failures, oldest first
+	Failures []ImportAttemptFailure `json:"failures"`
+}
+
+// ImportAttemptFailure is why one attempt at an import failed
+type ImportAttemptFailure struct {
+	ImportFailure
+	At time.Time `json:"at"`
+}
+
+// RecordImportFailure adds a failure to the download's import error history
+func (t *TrackedDownload) RecordImportFailure(failure *ImportFailure) {
+	if t.ImportRetry == nil {
+		t.ImportRetry = &ImportRetry{}
+	}
+	t.ImportRetry.NextAt = nil
+	t.ImportRetry.Failures = append(t.ImportRetry.Failures, ImportAttemptFailure{ImportFailure: *failure, At: time.Now()})
+}
+
+// ScheduleImportRetry schedules another automatic attempt at the import
+// after its last failure, returning false once maxAttempts retries have run
+func (t *TrackedDownload) ScheduleImportRetry(maxAttempts int) bool {
+	if t.ImportRetry == nil {
+		t.ImportRetry = &ImportRetry{}
+	}
+	if t.ImportRetry.Attempts >= maxAttempts {
+		return false
+	}
+	t.ImportRetry.Attempts++
+	next := time.Now().Add(importRetryAfter(t.ImportRetry.Attempts))
+	t.ImportRetry.NextAt = &next
+	return true
+}
+
+// ImportRetryDue reports whether an automatic retry of the import is due
+func (t *TrackedDownload) ImportRetryDue(now time.Time) bool {
+	return t.State == StateImportBlocked && t.ImportRetry != nil &&
+		t.ImportRetry.NextAt != nil && !t.ImportRetry.NextAt.After(now)
+}
+
+// CancelImportRetry drops a waiting automatic retry, for imports retried by
+// hand
+func (t *TrackedDownload) CancelImportRetry() {
+	if t.ImportRetry != nil {
+		t.ImportRetry.NextAt = nil
+	}
+}
+
+// importRetryAfter returns how long to wait before the attempts-th retry
+func importRetryAfter(attempts int) time.Duration {
+	delay := ImportRetryDelay
+	for i := 1; i < attempts && delay < ImportRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > ImportRetryMaxDelay {
+		delay = ImportRetryMaxDelay
+	}
+	return delay
+}
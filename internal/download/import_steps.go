@@ -0,0 +1,197 @@
+package download
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Import steps
+//
+// An import is broken into steps for each file - working out its name in the
+// library, checking it in the staging folder, moving it there, moving its
+// subtitles - followed by recording the import. Steps are planned up front and saved as they run, so the queue can
+// show where an import is and, when one fails, why and which steps are left.
+
+// ImportStepKind is what an import step does
+type ImportStepKind string
+
+const (
+	ImportStepRename   ImportStepKind = "rename"   // Working out the file's name and folder in the library
+	ImportStepStage    ImportStepKind = "stage"    // Moving the main video to the staging folder and checking it
+	ImportStepTransfer ImportStepKind = "transfer" // Moving a media file into the library
+	ImportStepSubtitle ImportStepKind = "subtitle" // Moving a subtitle next to its video
+	ImportStepMetadata ImportStepKind = "metadata" // Recording the import and the media's new quality
+)
+
+// ImportStepStatus is how far an import step has got
+type ImportStepStatus string
+
+const (
+	ImportStepPending ImportStepStatus = "pending"
+	ImportStepRunning ImportStepStatus = "running"
+	ImportStepDone    ImportStepStatus = "done"
+	ImportStepFailed  ImportStepStatus = "failed"
+)
+
+// Transfer methods. Files are renamed into place when the library is on the
+// same filesystem as the download, and copied then removed when it isn't.
+const (
+	TransferMove = "move"
+	TransferCopy = "copy"
+)
+
+// ImportStep is one step of an import
+type ImportStep struct {
+	Kind   ImportStepKind   `json:"kind"`
+	Status ImportStepStatus `json:"status"`
+	Source string           `json:"source,omitempty"`
+	Dest   string           `json:"dest,omitempty"`
+	Main   bool             `json:"main,omitempty"` // The main video of a movie or episode
+	// Optional steps, like moving extras and subtitles, don't stop the
+	// import when they fail
+	Optional bool `json:"optional,omitempty"`
+
+	// Transfer progress
+	Method     string  `json:"method,omitempty"`
+	BytesTotal int64   `json:"bytesTotal,omitempty"`
+	BytesDone  int64   `json:"bytesDone,omitempty"`
+	Progress   float64 `json:"progress"`
+
+	Error      *ImportFailure `json:"error,omitempty"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// Import failure codes
+const (
+	ImportErrDiskFull    = "disk_full"
+	ImportErrPermission  = "permission_denied"
+	ImportErrNotFound    = "not_found"
+	ImportErrLocked      = "file_locked"        // In use by another program
+	ImportErrUnavailable = "target_unavailable" // A network share or disk that's gone away
+	ImportErrParse       = "parse_failure"
+	ImportErrValidation  = "validation_failed"
+	ImportErrUnknown     = "unknown"
+)
+
+// ImportFailure describes why an import or one of its steps failed
+type ImportFailure struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+	// Retryable is set for failures that can go away without a new release,
+	// like a full disk or wrong permissions on the library
+	Retryable bool `json:"retryable"`
+}
+
+func (f *ImportFailure) Error() string {
+	return f.Message
+}
+
+// NewImportFailure classifies an error from importing the file at path. The
+// path the error names, if any, is used instead.
+func NewImportFailure(err error, path string) *ImportFailure {
+	var f *ImportFailure
+	if errors.As(err, &f) {
+		return f
+	}
+
+	f = &ImportFailure{Code: ImportErrUnknown, Message: err.Error(), Path: path, Retryable: true}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		f.Path = pathErr.Path
+	}
+	switch {
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		f.Code = ImportErrDiskFull
+	case errors.Is(err, os.ErrPermission), errors.Is(err, syscall.EROFS):
+		f.Code = ImportErrPermission
+	case errors.Is(err, syscall.EBUSY), errors.Is(err, syscall.ETXTBSY), errors.Is(err, syscall.EAGAIN):
+		f.Code = ImportErrLocked
+	case errors.Is(err, syscall.ENOTCONN), errors.Is(err, syscall.ESTALE), errors.Is(err, syscall.EHOSTDOWN),
+		errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.EIO):
+		f.Code = ImportErrUnavailable
+	case errors.Is(err, os.ErrNotExist):
+		f.Code = ImportErrNotFound
+		f.Retryable = false
+	}
+	return f
+}
+
+// ErrImportStepNotFailed is returned for retries of import steps that haven't
+// failed
+var ErrImportStepNotFailed = errors.New("import step hasn't failed")
+
+// ParseFailure is an ImportFailure for downloads that can't be matched to
+// importable files, which retrying won't fix
+func ParseFailure(message string) *ImportFailure {
+	return &ImportFailure{Code: ImportErrParse, Message: message}
+}
+
+// AddImportStep plans a step at the end of the import
+func (t *TrackedDownload) AddImportStep(kind ImportStepKind, source, dest string) *ImportStep {
+	step := &ImportStep{Kind: kind, Status: ImportStepPending, Source: source, Dest: dest}
+	t.ImportSteps = append(t.ImportSteps, step)
+	return step
+}
+
+// ImportStarted reports whether any file has been moved into the library or
+// may have been moved to the staging folder, so the download can no longer
+// be imported again from scratch
+func (t *TrackedDownload) ImportStarted() bool {
+	for _, step := range t.ImportSteps {
+		if step.Kind == ImportStepTransfer && step.Status == ImportStepDone {
+			return true
+		}
+		if step.Kind == ImportStepStage && step.Status != ImportStepPending {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFailedImportSteps reports whether any import step failed
+func (t *TrackedDownload) HasFailedImportSteps() bool {
+	for _, step := range t.ImportSteps {
+		if step.Status == ImportStepFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Start marks a step as running
+func (s *ImportStep) Start() {
+	now := time.Now()
+	s.Status = ImportStepRunning
+	s.StartedAt = &now
+	s.FinishedAt = nil
+	s.Error = nil
+}
+
+// Finish marks a step as done, or failed if err is set
+func (s *ImportStep) Finish(err error) {
+	now := time.Now()
+	s.FinishedAt = &now
+	if err != nil {
+		s.Status = ImportStepFailed
+		s.Error = NewImportFailure(err, s.Source)
+		return
+	}
+	s.Status = ImportStepDone
+	s.Progress = 100
+	if s.BytesTotal > 0 {
+		s.BytesDone = s.BytesTotal
+	}
+}
+
+// SetProgress records how much of a transfer is done
+func (s *ImportStep) SetProgress(done, total int64) {
+	s.BytesDone = done
+	s.BytesTotal = total
+	if total > 0 {
+		s.Progress = float64(done) / float64(total) * 100
+	}
+}
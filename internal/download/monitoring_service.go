@@ -3,15 +3,17 @@ package download
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/outpost/outpost/internal/downloadclient"
+	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/parser"
 )
 
+var logger = logging.Module("download")
+
 // MonitoringService polls download clients and manages download lifecycle
 type MonitoringService struct {
 	repo      *Repository
@@ -22,9 +24,14 @@ type MonitoringService struct {
 	stalledThreshold time.Duration
 	seedingConfig    SeedingConfig
 
+	// Progress rate tracking for stall detection (only touched by the poll loop)
+	progress map[int64]*progressSample
+	stale    StaleConfig
+
 	// Import callback - will be set by the application
 	OnReadyForImport func(td *TrackedDownload)
 	OnReadyToRemove  func(td *TrackedDownload)
+	OnStalled        func(td *TrackedDownload, reason string)
 
 	stopCh  chan struct{}
 	wg      sync.WaitGroup
@@ -57,6 +64,7 @@ func NewMonitoringService(db *sql.DB, clients *downloadclient.Manager, config Mo
 		pollInterval:     config.PollInterval,
 		stalledThreshold: config.StalledThreshold,
 		seedingConfig:    config.SeedingConfig,
+		progress:         make(map[int64]*progressSample),
 		stopCh:           make(chan struct{}),
 	}
 }
@@ -69,12 +77,13 @@ func (m *MonitoringService) Start() {
 		return
 	}
 	m.running = true
+	m.stopCh = make(chan struct{})
 	m.mu.Unlock()
 
 	m.wg.Add(1)
 	go m.pollLoop()
 
-	log.Println("Download monitoring service started")
+	logger.Infof("Download monitoring service started")
 }
 
 // Stop stops the monitoring loop
@@ -85,12 +94,12 @@ func (m *MonitoringService) Stop() {
 		return
 	}
 	m.running = false
+	close(m.stopCh)
 	m.mu.Unlock()
 
-	close(m.stopCh)
 	m.wg.Wait()
 
-	log.Println("Download monitoring service stopped")
+	logger.Infof("Download monitoring service stopped")
 }
 
 // pollLoop runs the main monitoring loop
@@ -115,10 +124,12 @@ func (m *MonitoringService) pollLoop() {
 
 // poll checks all download clients and updates tracked downloads
 func (m *MonitoringService) poll() {
+	m.stale = m.loadStaleConfig()
+
 	// Get all downloads from clients
 	clientDownloads, err := m.clients.GetAllDownloads()
 	if err != nil {
-		log.Printf("Error getting downloads from clients: %v", err)
+		logger.Errorf("Error getting downloads from clients: %v", err)
 		return
 	}
 
@@ -127,22 +138,22 @@ func (m *MonitoringService) poll() {
 	for _, dl := range clientDownloads {
 		key := m.makeKey(dl.ClientID, dl.ID)
 		clientMap[key] = dl
-		log.Printf("poll: client download key=%s name=%s", key, dl.Name)
+		logger.Infof("poll: client download key=%s name=%s", key, dl.Name)
 	}
 
 	// Get active tracked downloads
 	tracked, err := m.repo.GetActive()
 	if err != nil {
-		log.Printf("Error getting tracked downloads: %v", err)
+		logger.Errorf("Error getting tracked downloads: %v", err)
 		return
 	}
 
-	log.Printf("poll: found %d client downloads, %d tracked downloads", len(clientDownloads), len(tracked))
+	logger.Infof("poll: found %d client downloads, %d tracked downloads", len(clientDownloads), len(tracked))
 
 	// Update tracked downloads from client state
 	for _, td := range tracked {
 		key := m.makeKey(td.DownloadClientID, td.ExternalID)
-		log.Printf("poll: checking tracked download key=%s title=%s", key, td.Title)
+		logger.Infof("poll: checking tracked download key=%s title=%s", key, td.Title)
 		if clientDL, ok := clientMap[key]; ok {
 			m.updateFromClient(td, clientDL)
 			delete(clientMap, key) // Remove from map so we know what's new
@@ -152,15 +163,14 @@ func (m *MonitoringService) poll() {
 		}
 	}
 
+	m.pruneProgress(tracked)
+
 	// Handle new downloads in clients that we're not tracking
-	log.Printf("poll: %d new downloads to track", len(clientMap))
+	logger.Infof("poll: %d new downloads to track", len(clientMap))
 	for _, dl := range clientMap {
 		m.handleNewDownload(dl)
 	}
 
-	// Check for stalled downloads
-	m.checkStalled()
-
 	// Check for downloads ready for removal
 	m.checkReadyForRemoval()
 }
@@ -176,6 +186,9 @@ func (m *MonitoringService) updateFromClient(td *TrackedDownload, dl downloadcli
 	td.Size = dl.Size
 	td.Downloaded = int64(float64(dl.Size) * dl.Progress / 100)
 	td.Progress = dl.Progress
+	td.Speed = dl.Speed
+	td.ETA = time.Duration(dl.ETA) * time.Second
+	td.Seeders = dl.Seeders
 	td.Ratio = dl.Ratio
 	if td.CompletedAt != nil {
 		td.SeedingTime = time.Since(*td.CompletedAt)
@@ -187,18 +200,34 @@ func (m *MonitoringService) updateFromClient(td *TrackedDownload, dl downloadcli
 			td.MediaID = gh.MediaID
 			td.MediaType = gh.MediaType
 			m.repo.Update(td)
-			log.Printf("Linked existing download to grab history: %s -> mediaID=%d", td.Title, *gh.MediaID)
+			logger.Infof("Linked existing download to grab history: %s -> mediaID=%d", td.Title, *gh.MediaID)
 		}
 	}
 
 	// Map client status to our state
 	newState := m.mapClientStatus(dl.Status, td)
 
+	// Torrents that keep downloading too slowly stay stalled until they pick up again
+	stallReason := ""
+	if newState == StateDownloading {
+		var stalled bool
+		if stalled, stallReason = m.trackProgress(td, dl); stalled {
+			newState = StateStalled
+		}
+	} else {
+		delete(m.progress, td.ID)
+	}
+
 	// Handle state transitions
 	if newState != td.State && td.CanTransitionTo(newState) {
 		reason := "Client status: " + dl.Status
+		if newState == StateStalled {
+			reason = "Stalled: " + stallReason
+			td.AddWarning("Download stalled - " + stallReason)
+			logger.Infof("Download %s marked as stalled (%s)", td.Title, stallReason)
+		}
 		if err := m.repo.UpdateState(td, newState, reason); err != nil {
-			log.Printf("Error updating download state: %v", err)
+			logger.Errorf("Error updating download state: %v", err)
 			return
 		}
 
@@ -207,19 +236,20 @@ func (m *MonitoringService) updateFromClient(td *TrackedDownload, dl downloadcli
 			td.DownloadPath = dl.SavePath
 			m.repo.Update(td)
 			// Automatically transition to import_pending
-			if td.CanTransitionTo(StateImportPending) {
-				m.repo.UpdateState(td, StateImportPending, "Download completed")
-				if m.OnReadyForImport != nil {
-					m.OnReadyForImport(td)
-				}
-			}
+			m.queueImport(td, "Download completed")
 		}
 	} else {
 		// Just update the record with new progress
 		if err := m.repo.Update(td); err != nil {
-			log.Printf("Error updating download: %v", err)
+			logger.Errorf("Error updating download: %v", err)
 		}
 	}
+
+	// Hand stalled torrents off to be swapped for another release
+	if td.State == StateStalled && stallReason != "" && m.stale.SwapEnabled && m.OnStalled != nil {
+		delete(m.progress, td.ID)
+		m.OnStalled(td, stallReason)
+	}
 }
 
 // mapClientStatus maps download client status to our state
@@ -256,7 +286,7 @@ func (m *MonitoringService) handleMissingFromClient(td *TrackedDownload) {
 
 	// If it's still being tracked, something went wrong
 	if td.IsActive() {
-		log.Printf("Download %s missing from client, marking as failed", td.Title)
+		logger.Errorf("Download %s missing from client, marking as failed", td.Title)
 		td.AddError("Download removed from client unexpectedly")
 		m.repo.UpdateState(td, StateFailed, "Download missing from client")
 	}
@@ -282,7 +312,7 @@ func (m *MonitoringService) getGrabHistoryByTitle(title string) *grabHistoryResu
 	err := row.Scan(&gh.MediaID, &gh.MediaType)
 	if err != nil {
 		if err != sql.ErrNoRows {
-			log.Printf("Error looking up grab history: %v", err)
+			logger.Errorf("Error looking up grab history: %v", err)
 		}
 		return nil
 	}
@@ -294,7 +324,7 @@ func (m *MonitoringService) handleNewDownload(dl downloadclient.Download) {
 	// Check if we already have this download
 	existing, err := m.repo.GetByExternalID(dl.ClientID, dl.ID)
 	if err != nil {
-		log.Printf("Error checking for existing download: %v", err)
+		logger.Errorf("Error checking for existing download: %v", err)
 		return
 	}
 	if existing != nil {
@@ -318,8 +348,10 @@ func (m *MonitoringService) handleNewDownload(dl downloadclient.Download) {
 		GrabbedAt:        time.Now(),
 	}
 
-	// Determine media type from parsed info
-	if parsed.Season > 0 || parsed.Episode > 0 {
+	// Determine media type from the client category, then parsed info
+	if mediaType := mediaTypeForCategory(dl.Category); mediaType != "" {
+		td.MediaType = mediaType
+	} else if parsed.Season > 0 || parsed.Episode > 0 {
 		td.MediaType = "show"
 	} else {
 		td.MediaType = "movie"
@@ -332,51 +364,106 @@ func (m *MonitoringService) handleNewDownload(dl downloadclient.Download) {
 		if gh.RequestID != nil {
 			td.RequestID = gh.RequestID
 		}
-		log.Printf("Linked download to grab history: mediaID=%v, mediaType=%s", gh.MediaID, gh.MediaType)
+		logger.Infof("Linked download to grab history: mediaID=%v, mediaType=%s", gh.MediaID, gh.MediaType)
+	} else {
+		// Not grabbed by Outpost - only pick it up if it's in Outpost's category
+		if category := m.clientCategory(dl.ClientID); category != "" && !strings.EqualFold(dl.Category, category) {
+			return
+		}
+		td.External = true
+		td.MediaID = m.matchLibraryMedia(td.MediaType, parsed)
+		logger.Infof("Found externally added download: %s (matched mediaID=%v)", dl.Name, td.MediaID)
 	}
 
 	// Set initial state based on client status
 	td.State = m.mapClientStatus(dl.Status, td)
 
 	if err := m.repo.Create(td); err != nil {
-		log.Printf("Error creating tracked download: %v", err)
+		logger.Errorf("Error creating tracked download: %v", err)
 		return
 	}
 
-	log.Printf("Now tracking download: %s (state: %s)", td.Title, td.State)
+	logger.Infof("Now tracking download: %s (state: %s)", td.Title, td.State)
 
 	// If already completed, trigger import
 	if td.State == StateCompleted {
-		if td.CanTransitionTo(StateImportPending) {
-			m.repo.UpdateState(td, StateImportPending, "Download already completed")
-			if m.OnReadyForImport != nil {
-				m.OnReadyForImport(td)
-			}
-		}
+		m.queueImport(td, "Download already completed")
 	}
 }
 
-// checkStalled checks for downloads that have stalled
-func (m *MonitoringService) checkStalled() {
-	// Get downloading downloads
-	downloading, err := m.repo.GetByState(StateDownloading)
-	if err != nil {
+// queueImport moves a completed download to import_pending and hands it to the
+// importer. Downloads added outside of Outpost wait in the manual import queue
+// until a user confirms what they are.
+func (m *MonitoringService) queueImport(td *TrackedDownload, reason string) {
+	if !td.CanTransitionTo(StateImportPending) {
 		return
 	}
+	m.repo.UpdateState(td, StateImportPending, reason)
 
-	now := time.Now()
-	for _, td := range downloading {
-		// Check if no progress for threshold duration
-		if now.Sub(td.StateChangedAt) > m.stalledThreshold && td.Progress < 100 {
-			// Check if progress has changed (would need to track last progress)
-			// For now, mark as stalled if it's been downloading too long
-			if td.CanTransitionTo(StateStalled) {
-				td.AddWarning("Download appears stalled - no progress")
-				m.repo.UpdateState(td, StateStalled, "No progress detected")
-				log.Printf("Download %s marked as stalled", td.Title)
-			}
+	if td.External {
+		if err := m.MarkImportBlocked(td, ExternalImportReason); err != nil {
+			logger.Errorf("Error queueing external download for manual import: %v", err)
+		}
+		return
+	}
+	if m.OnReadyForImport != nil {
+		m.OnReadyForImport(td)
+	}
+}
+
+// clientCategory returns the category Outpost assigns on a download client
+func (m *MonitoringService) clientCategory(clientID int64) string {
+	var category sql.NullString
+	if err := m.db.QueryRow(`SELECT category FROM download_clients WHERE id = ?`, clientID).Scan(&category); err != nil {
+		return ""
+	}
+	return category.String
+}
+
+// mediaTypeForCategory recognizes audio and ebook download client categories
+// (e.g. "music", "audiobooks", "books-outpost"). Returns "" for anything else.
+func mediaTypeForCategory(category string) string {
+	category = strings.ToLower(category)
+	switch {
+	case category == "":
+		return ""
+	case strings.Contains(category, "book"), strings.Contains(category, "readarr"):
+		return "book"
+	case strings.Contains(category, "music"), strings.Contains(category, "audio"), strings.Contains(category, "lidarr"):
+		return "music"
+	default:
+		return ""
+	}
+}
+
+// matchLibraryMedia looks for a library movie or show matching a parsed
+// release and returns its TMDB ID
+func (m *MonitoringService) matchLibraryMedia(mediaType string, parsed *parser.ParsedRelease) *int64 {
+	if parsed == nil || parsed.Title == "" {
+		return nil
+	}
+	if mediaType != "movie" && mediaType != "show" {
+		return nil // Music and books are imported by name rather than library ID
+	}
+
+	table := "movies"
+	if mediaType == "show" {
+		table = "shows"
+	}
+
+	var tmdbID int64
+	err := m.db.QueryRow(`
+		SELECT tmdb_id FROM `+table+`
+		WHERE tmdb_id IS NOT NULL AND title = ? COLLATE NOCASE AND (? = 0 OR year = ?)
+		ORDER BY id LIMIT 1
+	`, parsed.Title, parsed.Year, parsed.Year).Scan(&tmdbID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Errorf("Error matching download to library: %v", err)
 		}
+		return nil
 	}
+	return &tmdbID
 }
 
 // checkReadyForRemoval checks for imported downloads ready to be removed
@@ -439,7 +526,7 @@ func (m *MonitoringService) TrackDownload(clientID int64, externalID string, tit
 		return nil, err
 	}
 
-	log.Printf("Tracking new download: %s", title)
+	logger.Infof("Tracking new download: %s", title)
 	return td, nil
 }
 
@@ -480,6 +567,16 @@ func (m *MonitoringService) MarkImportBlocked(td *TrackedDownload, reason string
 	return m.repo.UpdateState(td, StateImportBlocked, reason)
 }
 
+// SaveImportSteps saves a download's import steps and import error
+func (m *MonitoringService) SaveImportSteps(td *TrackedDownload) error {
+	return m.repo.UpdateImportSteps(td)
+}
+
+// UpdateTrackedDownload saves changes to a tracked download
+func (m *MonitoringService) UpdateTrackedDownload(td *TrackedDownload) error {
+	return m.repo.Update(td)
+}
+
 // MarkFailed marks a download as failed
 func (m *MonitoringService) MarkFailed(td *TrackedDownload, errorMsg string) error {
 	td.AddError(errorMsg)
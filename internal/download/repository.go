@@ -30,15 +30,15 @@ func (r *Repository) Create(td *TrackedDownload) error {
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ratio, seeding_time, can_remove, external, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		td.DownloadClientID, td.ExternalID, td.RequestID, td.MediaID, td.MediaType,
 		td.State, td.PreviousState, td.StateChangedAt, td.Title, string(parsedInfoJSON),
 		td.Size, td.Downloaded, td.Progress, td.Speed, int64(td.ETA.Seconds()), td.Seeders,
 		td.DownloadPath, td.ImportPath, td.Quality, td.CustomFormatScore,
 		td.GrabbedAt, td.CompletedAt, td.ImportedAt,
 		string(warningsJSON), string(errorsJSON), td.ImportBlockReason,
-		td.Ratio, int64(td.SeedingTime.Seconds()), td.CanRemove, time.Now(), time.Now(),
+		td.Ratio, int64(td.SeedingTime.Seconds()), td.CanRemove, td.External, time.Now(), time.Now(),
 	)
 	if err != nil {
 		return err
@@ -61,7 +61,8 @@ func (r *Repository) GetByID(id int64) (*TrackedDownload, error) {
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads WHERE id = ?`, id)
 	return r.scanRow(row)
 }
@@ -75,7 +76,8 @@ func (r *Repository) GetByExternalID(clientID int64, externalID string) (*Tracke
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads WHERE download_client_id = ? AND external_id = ?`, clientID, externalID)
 	return r.scanRow(row)
 }
@@ -89,7 +91,8 @@ func (r *Repository) GetActive() ([]*TrackedDownload, error) {
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads
 		WHERE state NOT IN ('imported', 'ignored')
 		ORDER BY created_at DESC`)
@@ -109,7 +112,8 @@ func (r *Repository) GetByState(state DownloadState) ([]*TrackedDownload, error)
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads WHERE state = ?
 		ORDER BY created_at DESC`, state)
 	if err != nil {
@@ -128,7 +132,8 @@ func (r *Repository) GetPendingImport() ([]*TrackedDownload, error) {
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads
 		WHERE state IN ('completed', 'import_pending')
 		ORDER BY completed_at ASC`)
@@ -151,7 +156,8 @@ func (r *Repository) GetReadyForRemoval(config SeedingConfig) ([]*TrackedDownloa
 			download_path, import_path, quality, custom_format_score,
 			grabbed_at, completed_at, imported_at,
 			warnings, errors, import_block_reason,
-			ratio, seeding_time, can_remove, created_at, updated_at
+			ratio, seeding_time, can_remove, external, created_at, updated_at,
+			import_steps, import_error, import_retry
 		FROM tracked_downloads
 		WHERE state = 'imported'
 		AND (seeding_time >= ? OR (ratio >= ? AND seeding_time >= ?))`,
@@ -177,7 +183,7 @@ func (r *Repository) Update(td *TrackedDownload) error {
 			download_path = ?, import_path = ?, quality = ?, custom_format_score = ?,
 			grabbed_at = ?, completed_at = ?, imported_at = ?,
 			warnings = ?, errors = ?, import_block_reason = ?,
-			ratio = ?, seeding_time = ?, can_remove = ?, updated_at = ?
+			ratio = ?, seeding_time = ?, can_remove = ?, external = ?, updated_at = ?
 		WHERE id = ?`,
 		td.DownloadClientID, td.ExternalID, td.RequestID, td.MediaID, td.MediaType,
 		td.State, td.PreviousState, td.StateChangedAt, td.Title, string(parsedInfoJSON),
@@ -185,7 +191,7 @@ func (r *Repository) Update(td *TrackedDownload) error {
 		td.DownloadPath, td.ImportPath, td.Quality, td.CustomFormatScore,
 		td.GrabbedAt, td.CompletedAt, td.ImportedAt,
 		string(warningsJSON), string(errorsJSON), td.ImportBlockReason,
-		td.Ratio, int64(td.SeedingTime.Seconds()), td.CanRemove, time.Now(),
+		td.Ratio, int64(td.SeedingTime.Seconds()), td.CanRemove, td.External, time.Now(),
 		td.ID,
 	)
 	return err
@@ -228,6 +234,17 @@ func (r *Repository) UpdateState(td *TrackedDownload, newState DownloadState, re
 	return tx.Commit()
 }
 
+// UpdateImportSteps saves a download's import steps, error and retries,
+// which change too often during an import to rewrite the whole row each time
+func (r *Repository) UpdateImportSteps(td *TrackedDownload) error {
+	stepsJSON, _ := json.Marshal(td.ImportSteps)
+	errorJSON, _ := json.Marshal(td.ImportError)
+	retryJSON, _ := json.Marshal(td.ImportRetry)
+	_, err := r.db.Exec(`UPDATE tracked_downloads SET import_steps = ?, import_error = ?, import_retry = ?, updated_at = ? WHERE id = ?`,
+		string(stepsJSON), string(errorJSON), string(retryJSON), time.Now(), td.ID)
+	return err
+}
+
 // Delete removes a tracked download
 func (r *Repository) Delete(id int64) error {
 	_, err := r.db.Exec(`DELETE FROM tracked_downloads WHERE id = ?`, id)
@@ -268,8 +285,9 @@ func (r *Repository) scanRow(row *sql.Row) (*TrackedDownload, error) {
 	var mediaType, prevState, quality, downloadPath, importPath sql.NullString
 	var stateChangedAt, grabbedAt, completedAt, importedAt sql.NullTime
 	var parsedInfoJSON, warningsJSON, errorsJSON, importBlockReason sql.NullString
+	var importStepsJSON, importErrorJSON, importRetryJSON sql.NullString
 	var etaSeconds, seedingTimeSeconds int64
-	var canRemove int
+	var canRemove, external int
 
 	err := row.Scan(
 		&td.ID, &td.DownloadClientID, &td.ExternalID, &requestID, &mediaID, &mediaType,
@@ -278,7 +296,8 @@ func (r *Repository) scanRow(row *sql.Row) (*TrackedDownload, error) {
 		&downloadPath, &importPath, &quality, &td.CustomFormatScore,
 		&grabbedAt, &completedAt, &importedAt,
 		&warningsJSON, &errorsJSON, &importBlockReason,
-		&td.Ratio, &seedingTimeSeconds, &canRemove, &td.CreatedAt, &td.UpdatedAt,
+		&td.Ratio, &seedingTimeSeconds, &canRemove, &external, &td.CreatedAt, &td.UpdatedAt,
+		&importStepsJSON, &importErrorJSON, &importRetryJSON,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -328,6 +347,7 @@ func (r *Repository) scanRow(row *sql.Row) (*TrackedDownload, error) {
 	td.ETA = time.Duration(etaSeconds) * time.Second
 	td.SeedingTime = time.Duration(seedingTimeSeconds) * time.Second
 	td.CanRemove = canRemove == 1
+	td.External = external == 1
 
 	// Parse JSON fields
 	if parsedInfoJSON.Valid && parsedInfoJSON.String != "" && parsedInfoJSON.String != "null" {
@@ -339,6 +359,15 @@ func (r *Repository) scanRow(row *sql.Row) (*TrackedDownload, error) {
 	if errorsJSON.Valid && errorsJSON.String != "" {
 		json.Unmarshal([]byte(errorsJSON.String), &td.Errors)
 	}
+	if importStepsJSON.Valid && importStepsJSON.String != "" {
+		json.Unmarshal([]byte(importStepsJSON.String), &td.ImportSteps)
+	}
+	if importErrorJSON.Valid && importErrorJSON.String != "" && importErrorJSON.String != "null" {
+		json.Unmarshal([]byte(importErrorJSON.String), &td.ImportError)
+	}
+	if importRetryJSON.Valid && importRetryJSON.String != "" && importRetryJSON.String != "null" {
+		json.Unmarshal([]byte(importRetryJSON.String), &td.ImportRetry)
+	}
 
 	return td, nil
 }
@@ -352,8 +381,9 @@ func (r *Repository) scanRows(rows *sql.Rows) ([]*TrackedDownload, error) {
 		var mediaType, prevState, quality, downloadPath, importPath sql.NullString
 		var stateChangedAt, grabbedAt, completedAt, importedAt sql.NullTime
 		var parsedInfoJSON, warningsJSON, errorsJSON, importBlockReason sql.NullString
+		var importStepsJSON, importErrorJSON, importRetryJSON sql.NullString
 		var etaSeconds, seedingTimeSeconds int64
-		var canRemove int
+		var canRemove, external int
 
 		err := rows.Scan(
 			&td.ID, &td.DownloadClientID, &td.ExternalID, &requestID, &mediaID, &mediaType,
@@ -362,7 +392,8 @@ func (r *Repository) scanRows(rows *sql.Rows) ([]*TrackedDownload, error) {
 			&downloadPath, &importPath, &quality, &td.CustomFormatScore,
 			&grabbedAt, &completedAt, &importedAt,
 			&warningsJSON, &errorsJSON, &importBlockReason,
-			&td.Ratio, &seedingTimeSeconds, &canRemove, &td.CreatedAt, &td.UpdatedAt,
+			&td.Ratio, &seedingTimeSeconds, &canRemove, &external, &td.CreatedAt, &td.UpdatedAt,
+			&importStepsJSON, &importErrorJSON, &importRetryJSON,
 		)
 		if err != nil {
 			return nil, err
@@ -409,6 +440,7 @@ func (r *Repository) scanRows(rows *sql.Rows) ([]*TrackedDownload, error) {
 		td.ETA = time.Duration(etaSeconds) * time.Second
 		td.SeedingTime = time.Duration(seedingTimeSeconds) * time.Second
 		td.CanRemove = canRemove == 1
+		td.External = external == 1
 
 		// Parse JSON fields
 		if parsedInfoJSON.Valid && parsedInfoJSON.String != "" && parsedInfoJSON.String != "null" {
@@ -420,6 +452,15 @@ func (r *Repository) scanRows(rows *sql.Rows) ([]*TrackedDownload, error) {
 		if errorsJSON.Valid && errorsJSON.String != "" {
 			json.Unmarshal([]byte(errorsJSON.String), &td.Errors)
 		}
+		if importStepsJSON.Valid && importStepsJSON.String != "" {
+			json.Unmarshal([]byte(importStepsJSON.String), &td.ImportSteps)
+		}
+		if importErrorJSON.Valid && importErrorJSON.String != "" && importErrorJSON.String != "null" {
+			json.Unmarshal([]byte(importErrorJSON.String), &td.ImportError)
+		}
+		if importRetryJSON.Valid && importRetryJSON.String != "" && importRetryJSON.String != "null" {
+			json.Unmarshal([]byte(importRetryJSON.String), &td.ImportRetry)
+		}
 
 		downloads = append(downloads, td)
 	}
@@ -0,0 +1,120 @@
+package download
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/downloadclient"
+)
+
+// progressSampleInterval is the minimum time between progress rate samples
+const progressSampleInterval = time.Minute
+
+// StaleConfig controls when a torrent is considered stalled
+type StaleConfig struct {
+	SwapEnabled bool          // Replace stalled downloads with the next-best release
+	MinSpeed    int64         // Bytes/sec below which a download counts as slow
+	Window      time.Duration // How long a download must stay slow before it's stalled
+}
+
+// progressSample tracks the measured progress rate of a download
+type progressSample struct {
+	downloaded int64
+	at         time.Time
+	rate       int64     // Bytes/sec over the last sample interval
+	slowSince  time.Time // Zero while the download is keeping up
+}
+
+// getSetting reads a setting value, returning def if it isn't set
+func (m *MonitoringService) getSetting(key, def string) string {
+	var value string
+	if err := m.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value); err != nil || value == "" {
+		return def
+	}
+	return value
+}
+
+// loadStaleConfig reads the stalled download settings
+func (m *MonitoringService) loadStaleConfig() StaleConfig {
+	cfg := StaleConfig{
+		SwapEnabled: m.getSetting("stale_swap_enabled", "false") == "true",
+		MinSpeed:    50 * 1024,
+		Window:      m.stalledThreshold,
+	}
+	if kbps, err := strconv.Atoi(m.getSetting("stale_min_speed_kbps", "")); err == nil && kbps >= 0 {
+		cfg.MinSpeed = int64(kbps) * 1024
+	}
+	if hours, err := strconv.ParseFloat(m.getSetting("stale_hours", ""), 64); err == nil && hours > 0 {
+		cfg.Window = time.Duration(hours * float64(time.Hour))
+	}
+	return cfg
+}
+
+// isTorrentClient reports whether a download comes from a torrent client
+func isTorrentClient(clientType string) bool {
+	return clientType == "qbittorrent" || clientType == "transmission"
+}
+
+// trackProgress samples a torrent's progress rate and reports whether it has
+// stayed below the minimum speed for the whole stall window, with a reason.
+func (m *MonitoringService) trackProgress(td *TrackedDownload, dl downloadclient.Download) (bool, string) {
+	if !isTorrentClient(dl.ClientType) {
+		return false, ""
+	}
+
+	now := time.Now()
+	sample, ok := m.progress[td.ID]
+	if !ok || td.Downloaded < sample.downloaded {
+		m.progress[td.ID] = &progressSample{downloaded: td.Downloaded, at: now}
+		return false, ""
+	}
+
+	elapsed := now.Sub(sample.at)
+	if elapsed >= progressSampleInterval {
+		sample.rate = int64(float64(td.Downloaded-sample.downloaded) / elapsed.Seconds())
+		sample.downloaded = td.Downloaded
+		sample.at = now
+
+		if sample.rate < m.stale.MinSpeed {
+			if sample.slowSince.IsZero() {
+				sample.slowSince = now
+			}
+		} else {
+			sample.slowSince = time.Time{}
+		}
+	}
+
+	if sample.slowSince.IsZero() || now.Sub(sample.slowSince) < m.stale.Window {
+		return false, ""
+	}
+
+	if dl.Seeders == 0 && dl.ClientType == "qbittorrent" {
+		return true, fmt.Sprintf("no seeders for %s", formatStallDuration(now.Sub(sample.slowSince)))
+	}
+	return true, fmt.Sprintf("below %d KB/s for %s", m.stale.MinSpeed/1024, formatStallDuration(now.Sub(sample.slowSince)))
+}
+
+// pruneProgress drops samples for downloads that are no longer tracked
+func (m *MonitoringService) pruneProgress(tracked []*TrackedDownload) {
+	active := make(map[int64]bool, len(tracked))
+	for _, td := range tracked {
+		if td.State == StateDownloading || td.State == StateStalled {
+			active[td.ID] = true
+		}
+	}
+	for id := range m.progress {
+		if !active[id] {
+			delete(m.progress, id)
+		}
+	}
+}
+
+// formatStallDuration renders a stall duration in hours and minutes
+func formatStallDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
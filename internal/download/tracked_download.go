@@ -1,6 +1,7 @@
 package download
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -24,12 +25,20 @@ const (
 	StateIgnored       DownloadState = "ignored"
 )
 
+// ExternalImportReason is the block reason for downloads that were added to a
+// client outside of Outpost and need to be confirmed before import
+const ExternalImportReason = "Added outside Outpost - confirm the match to import"
+
+// ErrNotAwaitingImport is returned for manual import actions on downloads
+// that aren't in the manual import queue
+var ErrNotAwaitingImport = errors.New("download is not waiting for manual import")
+
 // ValidTransitions defines allowed state transitions
 var ValidTransitions = map[DownloadState][]DownloadState{
 	StateQueued:        {StateDownloading, StateFailed},
 	StateDownloading:   {StateCompleted, StatePaused, StateStalled, StateFailed},
 	StatePaused:        {StateDownloading, StateFailed},
-	StateStalled:       {StateDownloading, StateFailed, StateIgnored},
+	StateStalled:       {StateDownloading, StateCompleted, StatePaused, StateFailed, StateIgnored},
 	StateCompleted:     {StateImportPending},
 	StateImportPending: {StateImporting, StateImportBlocked},
 	StateImporting:     {StateImported, StateImportBlocked, StateFailed},
@@ -84,11 +93,19 @@ type TrackedDownload struct {
 	Errors            []string `json:"errors,omitempty"`
 	ImportBlockReason string   `json:"importBlockReason,omitempty"`
 
+	// Import progress, why the last import failed, and its automatic retries
+	ImportSteps []*ImportStep  `json:"importSteps,omitempty"`
+	ImportError *ImportFailure `json:"importError,omitempty"`
+	ImportRetry *ImportRetry   `json:"importRetry,omitempty"`
+
 	// Seeding
 	Ratio       float64       `json:"ratio"`
 	SeedingTime time.Duration `json:"seedingTime"`
 	CanRemove   bool          `json:"canRemove"`
 
+	// External is set for downloads added to the client outside of Outpost
+	External bool `json:"external"`
+
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
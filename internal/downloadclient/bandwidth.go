@@ -0,0 +1,214 @@
+package downloadclient
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// BandwidthState describes the speed limits currently applied to a client
+type BandwidthState struct {
+	ClientID      int64     `json:"clientId"`
+	ClientName    string    `json:"clientName"`
+	Mode          string    `json:"mode"` // normal, scheduled
+	ScheduleID    *int64    `json:"scheduleId,omitempty"`
+	ScheduleName  string    `json:"scheduleName,omitempty"`
+	DownloadLimit int       `json:"downloadLimit"` // KB/s, 0 = unlimited
+	UploadLimit   int       `json:"uploadLimit"`   // KB/s, 0 = unlimited
+	AppliedAt     time.Time `json:"appliedAt"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// ParseClock parses an HH:MM time into minutes after midnight
+func ParseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid minute in %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// ParseDays parses a comma-separated weekday list (0 = Sunday)
+func ParseDays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil || day < 0 || day > 6 {
+			return nil, fmt.Errorf("invalid weekday %q (expected 0-6)", part)
+		}
+		days[time.Weekday(day)] = true
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("at least one weekday is required")
+	}
+	return days, nil
+}
+
+// ScheduleActive reports whether a bandwidth schedule covers the given time,
+// read in the time's location.
+// Windows that end before they start span midnight and belong to the day they start on.
+func ScheduleActive(schedule *database.BandwidthSchedule, now time.Time) bool {
+	if !schedule.Enabled {
+		return false
+	}
+	days, err := ParseDays(schedule.Days)
+	if err != nil {
+		return false
+	}
+	start, err := ParseClock(schedule.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := ParseClock(schedule.EndTime)
+	if err != nil {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	today := now.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case start == end:
+		return days[today]
+	case start < end:
+		return days[today] && minute >= start && minute < end
+	default:
+		return (days[today] && minute >= start) || (days[yesterday] && minute < end)
+	}
+}
+
+// normalLimits returns the limits applied outside of any schedule
+func (m *Manager) normalLimits() (int, int) {
+	down, up := 0, 0
+	if val, err := m.db.GetSetting("bandwidth_normal_download_kbps"); err == nil {
+		down, _ = strconv.Atoi(val)
+	}
+	if val, err := m.db.GetSetting("bandwidth_normal_upload_kbps"); err == nil {
+		up, _ = strconv.Atoi(val)
+	}
+	return down, up
+}
+
+// ApplyBandwidthSchedules pushes the speed limits that should be active at the
+// given time to every enabled client. Limits are only sent when they change,
+// unless force is set. Schedule times are in the server's time zone.
+func (m *Manager) ApplyBandwidthSchedules(now time.Time, force bool) {
+	now = now.In(timezone.Location())
+	clients, err := m.db.GetEnabledDownloadClients()
+	if err != nil {
+		logger.Errorf("Bandwidth: failed to get download clients: %v", err)
+		return
+	}
+	schedules, err := m.db.GetBandwidthSchedules()
+	if err != nil {
+		logger.Errorf("Bandwidth: failed to get schedules: %v", err)
+		return
+	}
+	normalDown, normalUp := m.normalLimits()
+
+	// Leave clients alone entirely unless schedules are in use, so limits
+	// configured directly in the client aren't overwritten
+	managed := false
+	for _, sched := range schedules {
+		if sched.Enabled {
+			managed = true
+			break
+		}
+	}
+
+	enabled := make(map[int64]bool)
+	for _, clientConfig := range clients {
+		enabled[clientConfig.ID] = true
+
+		desired := BandwidthState{
+			ClientID:      clientConfig.ID,
+			ClientName:    clientConfig.Name,
+			Mode:          "normal",
+			DownloadLimit: normalDown,
+			UploadLimit:   normalUp,
+		}
+		// First matching schedule wins; client-specific and global schedules are treated alike
+		for i := range schedules {
+			sched := &schedules[i]
+			if sched.ClientID != nil && *sched.ClientID != clientConfig.ID {
+				continue
+			}
+			if ScheduleActive(sched, now) {
+				desired.Mode = "scheduled"
+				desired.ScheduleID = &sched.ID
+				desired.ScheduleName = sched.Name
+				desired.DownloadLimit = sched.DownloadLimit
+				desired.UploadLimit = sched.UploadLimit
+				break
+			}
+		}
+
+		m.bandwidthMu.Lock()
+		current, ok := m.bandwidth[clientConfig.ID]
+		m.bandwidthMu.Unlock()
+		if !managed && (!ok || current.Mode == "normal") {
+			continue
+		}
+		if ok && !force && current.Error == "" && current.Mode == desired.Mode &&
+			current.DownloadLimit == desired.DownloadLimit && current.UploadLimit == desired.UploadLimit {
+			continue
+		}
+
+		client, err := New(&clientConfig)
+		if err == nil {
+			err = client.SetSpeedLimits(desired.DownloadLimit, desired.UploadLimit)
+		}
+		desired.AppliedAt = now
+		if err != nil {
+			desired.Error = err.Error()
+			logger.Errorf("Bandwidth: failed to set limits on %s: %v", clientConfig.Name, err)
+		} else {
+			logger.Infof("Bandwidth: %s now in %s mode (down %d KB/s, up %d KB/s)",
+				clientConfig.Name, desired.Mode, desired.DownloadLimit, desired.UploadLimit)
+		}
+
+		m.bandwidthMu.Lock()
+		m.bandwidth[clientConfig.ID] = desired
+		m.bandwidthMu.Unlock()
+	}
+
+	// Forget clients that were removed or disabled
+	m.bandwidthMu.Lock()
+	for id := range m.bandwidth {
+		if !enabled[id] {
+			delete(m.bandwidth, id)
+		}
+	}
+	m.bandwidthMu.Unlock()
+}
+
+// GetBandwidthStatus returns the last applied speed limits for each client
+func (m *Manager) GetBandwidthStatus() []BandwidthState {
+	m.bandwidthMu.Lock()
+	defer m.bandwidthMu.Unlock()
+
+	states := make([]BandwidthState, 0, len(m.bandwidth))
+	for _, state := range m.bandwidth {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ClientID < states[j].ClientID })
+	return states
+}
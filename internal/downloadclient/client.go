@@ -2,11 +2,14 @@ package downloadclient
 
 import (
 	"fmt"
-	"log"
+	"sync"
 
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
 )
 
+var logger = logging.Module("download")
+
 // Download represents an active or completed download
 type Download struct {
 	ID            string  `json:"id"`
@@ -35,10 +38,10 @@ type Client interface {
 	GetDownloads() ([]Download, error)
 
 	// AddTorrent adds a torrent by URL or magnet link (torrent clients only)
-	AddTorrent(url string, category string) error
+	AddTorrent(url string, category string, priority Priority) error
 
 	// AddNZB adds an NZB by URL (usenet clients only)
-	AddNZB(url string, category string) error
+	AddNZB(url string, category string, priority Priority) error
 
 	// PauseDownload pauses a specific download
 	PauseDownload(id string) error
@@ -54,6 +57,13 @@ type Client interface {
 
 	// GetClientType returns the type of client (torrent/usenet)
 	GetClientType() string
+
+	// SetSpeedLimits sets global download/upload limits in KB/s (0 = unlimited)
+	SetSpeedLimits(downloadKBps, uploadKBps int) error
+
+	// GetStatus returns the client's own free space and the problems it
+	// reports. Queue totals are filled in by the Manager.
+	GetStatus() (*ClientStatus, error)
 }
 
 // New creates a new download client based on the config
@@ -75,39 +85,54 @@ func New(config *database.DownloadClient) (Client, error) {
 // Manager manages multiple download clients
 type Manager struct {
 	db *database.Database
+
+	// Last speed limits pushed to each client, keyed by client ID
+	bandwidth   map[int64]BandwidthState
+	bandwidthMu sync.Mutex
+
+	// Guards the persisted low storage pause state
+	storageMu sync.Mutex
+
+	// Last polled status of each client, keyed by client ID
+	statuses map[int64]ClientStatus
+	statusMu sync.RWMutex
 }
 
 // NewManager creates a new download client manager
 func NewManager(db *database.Database) *Manager {
-	return &Manager{db: db}
+	return &Manager{
+		db:        db,
+		bandwidth: make(map[int64]BandwidthState),
+		statuses:  make(map[int64]ClientStatus),
+	}
 }
 
 // GetAllDownloads returns downloads from all enabled clients
 func (m *Manager) GetAllDownloads() ([]Download, error) {
 	clients, err := m.db.GetEnabledDownloadClients()
 	if err != nil {
-		log.Printf("GetAllDownloads: error getting enabled clients: %v", err)
+		logger.Errorf("GetAllDownloads: error getting enabled clients: %v", err)
 		return nil, err
 	}
 
-	log.Printf("GetAllDownloads: found %d enabled download clients", len(clients))
+	logger.Infof("GetAllDownloads: found %d enabled download clients", len(clients))
 
 	var allDownloads []Download
 	for _, clientConfig := range clients {
-		log.Printf("GetAllDownloads: checking client %s (ID=%d, type=%s)", clientConfig.Name, clientConfig.ID, clientConfig.Type)
+		logger.Infof("GetAllDownloads: checking client %s (ID=%d, type=%s)", clientConfig.Name, clientConfig.ID, clientConfig.Type)
 		client, err := New(&clientConfig)
 		if err != nil {
-			log.Printf("GetAllDownloads: failed to initialize client %s: %v", clientConfig.Name, err)
+			logger.Errorf("GetAllDownloads: failed to initialize client %s: %v", clientConfig.Name, err)
 			continue // Skip clients we can't initialize
 		}
 
 		downloads, err := client.GetDownloads()
 		if err != nil {
-			log.Printf("GetAllDownloads: failed to get downloads from %s: %v", clientConfig.Name, err)
+			logger.Errorf("GetAllDownloads: failed to get downloads from %s: %v", clientConfig.Name, err)
 			continue // Skip clients we can't connect to
 		}
 
-		log.Printf("GetAllDownloads: got %d downloads from %s", len(downloads), clientConfig.Name)
+		logger.Infof("GetAllDownloads: got %d downloads from %s", len(downloads), clientConfig.Name)
 
 		// Add client info to each download
 		for i := range downloads {
@@ -119,7 +144,7 @@ func (m *Manager) GetAllDownloads() ([]Download, error) {
 		allDownloads = append(allDownloads, downloads...)
 	}
 
-	log.Printf("GetAllDownloads: returning %d total downloads", len(allDownloads))
+	logger.Infof("GetAllDownloads: returning %d total downloads", len(allDownloads))
 	return allDownloads, nil
 }
 
@@ -139,7 +164,7 @@ func (m *Manager) TestClient(id int64) error {
 }
 
 // AddDownload adds a download to the first available client of the appropriate type
-func (m *Manager) AddDownload(url string, category string, isTorrent bool) error {
+func (m *Manager) AddDownload(url string, category string, isTorrent bool, priority Priority) error {
 	clients, err := m.db.GetEnabledDownloadClients()
 	if err != nil {
 		return err
@@ -155,9 +180,9 @@ func (m *Manager) AddDownload(url string, category string, isTorrent bool) error
 
 		// Match torrent URLs to torrent clients, NZB URLs to usenet clients
 		if isTorrent && clientType == "torrent" {
-			return client.AddTorrent(url, category)
+			return client.AddTorrent(url, category, priority)
 		} else if !isTorrent && clientType == "usenet" {
-			return client.AddNZB(url, category)
+			return client.AddNZB(url, category, priority)
 		}
 	}
 
@@ -165,7 +190,7 @@ func (m *Manager) AddDownload(url string, category string, isTorrent bool) error
 }
 
 // AddTorrent adds a torrent to a specific client
-func (m *Manager) AddTorrent(clientID int64, url string, category string) error {
+func (m *Manager) AddTorrent(clientID int64, url string, category string, priority Priority) error {
 	clientConfig, err := m.db.GetDownloadClient(clientID)
 	if err != nil {
 		return err
@@ -176,11 +201,11 @@ func (m *Manager) AddTorrent(clientID int64, url string, category string) error
 		return err
 	}
 
-	return client.AddTorrent(url, category)
+	return client.AddTorrent(url, category, priority)
 }
 
 // AddNZB adds an NZB to a specific client
-func (m *Manager) AddNZB(clientID int64, url string, category string) error {
+func (m *Manager) AddNZB(clientID int64, url string, category string, priority Priority) error {
 	clientConfig, err := m.db.GetDownloadClient(clientID)
 	if err != nil {
 		return err
@@ -191,7 +216,7 @@ func (m *Manager) AddNZB(clientID int64, url string, category string) error {
 		return err
 	}
 
-	return client.AddNZB(url, category)
+	return client.AddNZB(url, category, priority)
 }
 
 // DeleteDownload removes a download from a specific client
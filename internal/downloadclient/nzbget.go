@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/database"
 )
 
@@ -38,7 +39,8 @@ func NewNZBGet(config *database.DownloadClient) *NZBGet {
 		config:  config,
 		baseURL: fmt.Sprintf("%s://%s:%s@%s:%d/jsonrpc", scheme, username, password, config.Host, config.Port),
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetDownload),
 		},
 	}
 }
@@ -228,18 +230,28 @@ func (n *NZBGet) mapHistoryStatus(status string) string {
 	}
 }
 
-func (n *NZBGet) AddTorrent(url string, category string) error {
+func (n *NZBGet) AddTorrent(url string, category string, priority Priority) error {
 	return fmt.Errorf("NZBGet does not support torrent files")
 }
 
-func (n *NZBGet) AddNZB(nzbURL string, category string) error {
+func (n *NZBGet) AddNZB(nzbURL string, category string, priority Priority) error {
 	cat := category
 	if cat == "" {
 		cat = n.config.Category
 	}
 
+	// NZBGet priorities run from -100 (very low) to 100 (very high), and
+	// high priority downloads go to the top of the queue
+	nzbPriority := 0
+	switch priority {
+	case PriorityLow:
+		nzbPriority = -50
+	case PriorityHigh:
+		nzbPriority = 50
+	}
+
 	// NZBGet append method: Name, URL, Category, Priority, AddToTop, AddPaused, DupeKey, DupeScore, DupeMode
-	_, err := n.doRequest("append", "", nzbURL, cat, 0, false, false, "", 0, "SCORE")
+	_, err := n.doRequest("append", "", nzbURL, cat, nzbPriority, priority == PriorityHigh, false, "", 0, "SCORE")
 	return err
 }
 
@@ -291,3 +303,35 @@ func (n *NZBGet) GetCategories() ([]string, error) {
 func (n *NZBGet) GetClientType() string {
 	return "usenet"
 }
+
+// SetSpeedLimits sets the download limit in KB/s (0 = unlimited).
+// NZBGet has no upload limit, so uploadKBps is ignored.
+func (n *NZBGet) SetSpeedLimits(downloadKBps, uploadKBps int) error {
+	_, err := n.doRequest("rate", downloadKBps)
+	return err
+}
+
+// GetStatus reports free space in NZBGet's destination directory, whether
+// downloading is paused and whether its download quota has been reached
+func (n *NZBGet) GetStatus() (*ClientStatus, error) {
+	resp, err := n.doRequest("status")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		FreeDiskSpaceMB int64 `json:"FreeDiskSpaceMB"`
+		DownloadPaused  bool  `json:"DownloadPaused"`
+		QuotaReached    bool  `json:"QuotaReached"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse status: %w", err)
+	}
+
+	free := result.FreeDiskSpaceMB * 1024 * 1024
+	status := &ClientStatus{FreeSpace: &free, Paused: result.DownloadPaused}
+	if result.QuotaReached {
+		status.Errors = append(status.Errors, "NZBGet download quota reached")
+	}
+	return status, nil
+}
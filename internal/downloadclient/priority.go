@@ -0,0 +1,27 @@
+package downloadclient
+
+import "github.com/outpost/outpost/internal/database"
+
+// Priority is how soon a download is wanted, for clients that queue by
+// priority. qBittorrent puts high priority torrents at the top of its queue
+// and has no way to add one at the bottom; Transmission, SABnzbd and NZBGet
+// take all three.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// ParsePriority returns the download priority of a request or wanted item
+// priority
+func ParsePriority(p string) Priority {
+	switch p {
+	case database.PriorityLow:
+		return PriorityLow
+	case database.PriorityHigh:
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
@@ -13,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/database"
 )
 
@@ -35,8 +35,9 @@ func NewQBittorrent(config *database.DownloadClient) *QBittorrent {
 		config:  config,
 		baseURL: fmt.Sprintf("%s://%s:%d", scheme, config.Host, config.Port),
 		client: &http.Client{
-			Timeout: 30 * time.Second,
-			Jar:     jar,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetDownload),
+			Jar:       jar,
 		},
 	}
 }
@@ -53,6 +54,7 @@ type qbTorrent struct {
 	State          string  `json:"state"`
 	SavePath       string  `json:"save_path"`
 	Category       string  `json:"category"`
+	NumComplete    int     `json:"num_complete"`
 }
 
 func (q *QBittorrent) login() error {
@@ -130,6 +132,7 @@ func (q *QBittorrent) GetDownloads() ([]Download, error) {
 			Status:     q.mapState(t.State),
 			SavePath:   t.SavePath,
 			Category:   t.Category,
+			Seeders:    t.NumComplete,
 		}
 	}
 
@@ -153,46 +156,46 @@ func (q *QBittorrent) mapState(state string) string {
 	}
 }
 
-func (q *QBittorrent) AddTorrent(torrentURL string, category string) error {
-	log.Printf("DEBUG qBit AddTorrent: starting, URL length=%d, category=%s", len(torrentURL), category)
+func (q *QBittorrent) AddTorrent(torrentURL string, category string, priority Priority) error {
+	logger.Debugf("qBit AddTorrent: starting, URL length=%d, category=%s", len(torrentURL), category)
 
 	if err := q.login(); err != nil {
-		log.Printf("DEBUG qBit AddTorrent: login failed: %v", err)
+		logger.Debugf("qBit AddTorrent: login failed: %v", err)
 		return err
 	}
-	log.Printf("DEBUG qBit AddTorrent: login successful")
+	logger.Debugf("qBit AddTorrent: login successful")
 
 	// Check if this is a magnet link or HTTP URL
 	if strings.HasPrefix(torrentURL, "magnet:") {
 		// Magnet links can be sent directly via URL
-		log.Printf("DEBUG qBit AddTorrent: detected magnet link, sending directly")
-		return q.addTorrentByURL(torrentURL, category)
+		logger.Debugf("qBit AddTorrent: detected magnet link, sending directly")
+		return q.addTorrentByURL(torrentURL, category, priority)
 	}
 
 	// For HTTP URLs (like Prowlarr download links), we need to resolve them first
 	// They may redirect to magnet links or return .torrent files
-	log.Printf("DEBUG qBit AddTorrent: resolving download URL")
+	logger.Debugf("qBit AddTorrent: resolving download URL")
 	resolvedURL, torrentData, err := q.resolveDownloadURL(torrentURL)
 	if err != nil {
-		log.Printf("DEBUG qBit AddTorrent: failed to resolve URL: %v", err)
+		logger.Debugf("qBit AddTorrent: failed to resolve URL: %v", err)
 		return err
 	}
 
 	// If we got a magnet link, send it directly
 	if strings.HasPrefix(resolvedURL, "magnet:") {
-		log.Printf("DEBUG qBit AddTorrent: URL resolved to magnet link, sending directly")
-		return q.addTorrentByURL(resolvedURL, category)
+		logger.Debugf("qBit AddTorrent: URL resolved to magnet link, sending directly")
+		return q.addTorrentByURL(resolvedURL, category, priority)
 	}
 
 	// If we got torrent data, upload it
 	if len(torrentData) > 0 {
-		log.Printf("DEBUG qBit AddTorrent: got %d bytes of torrent data, uploading", len(torrentData))
-		return q.addTorrentByFile(torrentData, category)
+		logger.Debugf("qBit AddTorrent: got %d bytes of torrent data, uploading", len(torrentData))
+		return q.addTorrentByFile(torrentData, category, priority)
 	}
 
 	// Shouldn't get here, but fall back to URL method
-	log.Printf("DEBUG qBit AddTorrent: falling back to URL method")
-	return q.addTorrentByURL(torrentURL, category)
+	logger.Debugf("qBit AddTorrent: falling back to URL method")
+	return q.addTorrentByURL(torrentURL, category, priority)
 }
 
 // resolveDownloadURL follows redirects and returns either a magnet link or torrent file data
@@ -223,7 +226,7 @@ func (q *QBittorrent) resolveDownloadURL(downloadURL string) (string, []byte, er
 	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusTemporaryRedirect {
 		location := resp.Header.Get("Location")
 		if strings.HasPrefix(location, "magnet:") {
-			log.Printf("DEBUG qBit resolveDownloadURL: got magnet redirect: %s", location[:min(100, len(location))])
+			logger.Debugf("qBit resolveDownloadURL: got magnet redirect: %s", location[:min(100, len(location))])
 			return location, nil, nil
 		}
 	}
@@ -241,16 +244,16 @@ func (q *QBittorrent) resolveDownloadURL(downloadURL string) (string, []byte, er
 	// Check if the response is a magnet link (some APIs return magnet as plain text)
 	dataStr := strings.TrimSpace(string(data))
 	if strings.HasPrefix(dataStr, "magnet:") {
-		log.Printf("DEBUG qBit resolveDownloadURL: response body is magnet link")
+		logger.Debugf("qBit resolveDownloadURL: response body is magnet link")
 		return dataStr, nil, nil
 	}
 
 	// Otherwise it should be torrent file data
-	log.Printf("DEBUG qBit resolveDownloadURL: got %d bytes of torrent data", len(data))
+	logger.Debugf("qBit resolveDownloadURL: got %d bytes of torrent data", len(data))
 	return "", data, nil
 }
 
-func (q *QBittorrent) addTorrentByURL(torrentURL string, category string) error {
+func (q *QBittorrent) addTorrentByURL(torrentURL string, category string, priority Priority) error {
 	data := url.Values{
 		"urls": {torrentURL},
 	}
@@ -259,17 +262,20 @@ func (q *QBittorrent) addTorrentByURL(torrentURL string, category string) error
 	} else if q.config.Category != "" {
 		data.Set("category", q.config.Category)
 	}
+	if priority == PriorityHigh {
+		data.Set("addToTopOfQueue", "true")
+	}
 
-	log.Printf("DEBUG qBit addTorrentByURL: sending to %s/api/v2/torrents/add", q.baseURL)
+	logger.Debugf("qBit addTorrentByURL: sending to %s/api/v2/torrents/add", q.baseURL)
 	resp, err := q.client.PostForm(q.baseURL+"/api/v2/torrents/add", data)
 	if err != nil {
-		log.Printf("DEBUG qBit addTorrentByURL: request failed: %v", err)
+		logger.Debugf("qBit addTorrentByURL: request failed: %v", err)
 		return fmt.Errorf("failed to add torrent: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	log.Printf("DEBUG qBit addTorrentByURL: response status=%d, body=%q", resp.StatusCode, string(body))
+	logger.Debugf("qBit addTorrentByURL: response status=%d, body=%q", resp.StatusCode, string(body))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to add torrent: %s", string(body))
@@ -278,7 +284,7 @@ func (q *QBittorrent) addTorrentByURL(torrentURL string, category string) error
 	return nil
 }
 
-func (q *QBittorrent) addTorrentByFile(torrentData []byte, category string) error {
+func (q *QBittorrent) addTorrentByFile(torrentData []byte, category string, priority Priority) error {
 	// Create multipart form
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -298,6 +304,9 @@ func (q *QBittorrent) addTorrentByFile(torrentData []byte, category string) erro
 	} else if q.config.Category != "" {
 		writer.WriteField("category", q.config.Category)
 	}
+	if priority == PriorityHigh {
+		writer.WriteField("addToTopOfQueue", "true")
+	}
 
 	writer.Close()
 
@@ -307,16 +316,16 @@ func (q *QBittorrent) addTorrentByFile(torrentData []byte, category string) erro
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	log.Printf("DEBUG qBit addTorrentByFile: uploading torrent file to %s/api/v2/torrents/add", q.baseURL)
+	logger.Debugf("qBit addTorrentByFile: uploading torrent file to %s/api/v2/torrents/add", q.baseURL)
 	resp, err := q.client.Do(req)
 	if err != nil {
-		log.Printf("DEBUG qBit addTorrentByFile: request failed: %v", err)
+		logger.Debugf("qBit addTorrentByFile: request failed: %v", err)
 		return fmt.Errorf("failed to add torrent: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	log.Printf("DEBUG qBit addTorrentByFile: response status=%d, body=%q", resp.StatusCode, string(body))
+	logger.Debugf("qBit addTorrentByFile: response status=%d, body=%q", resp.StatusCode, string(body))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to add torrent: %s", string(body))
@@ -325,7 +334,7 @@ func (q *QBittorrent) addTorrentByFile(torrentData []byte, category string) erro
 	return nil
 }
 
-func (q *QBittorrent) AddNZB(url string, category string) error {
+func (q *QBittorrent) AddNZB(url string, category string, priority Priority) error {
 	return fmt.Errorf("qBittorrent does not support NZB files")
 }
 
@@ -366,10 +375,10 @@ func (q *QBittorrent) ResumeDownload(id string) error {
 }
 
 func (q *QBittorrent) DeleteDownload(id string, deleteFiles bool) error {
-	log.Printf("DEBUG qBit DeleteDownload: hash=%s, deleteFiles=%v", id, deleteFiles)
+	logger.Debugf("qBit DeleteDownload: hash=%s, deleteFiles=%v", id, deleteFiles)
 
 	if err := q.login(); err != nil {
-		log.Printf("DEBUG qBit DeleteDownload: login failed: %v", err)
+		logger.Debugf("qBit DeleteDownload: login failed: %v", err)
 		return err
 	}
 
@@ -385,17 +394,17 @@ func (q *QBittorrent) DeleteDownload(id string, deleteFiles bool) error {
 
 	resp, err := q.client.PostForm(q.baseURL+"/api/v2/torrents/delete", data)
 	if err != nil {
-		log.Printf("DEBUG qBit DeleteDownload: request failed: %v", err)
+		logger.Debugf("qBit DeleteDownload: request failed: %v", err)
 		return fmt.Errorf("failed to delete torrent: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("DEBUG qBit DeleteDownload: unexpected status %d", resp.StatusCode)
+		logger.Debugf("qBit DeleteDownload: unexpected status %d", resp.StatusCode)
 		return fmt.Errorf("delete returned status %d", resp.StatusCode)
 	}
 
-	log.Printf("DEBUG qBit DeleteDownload: success for hash=%s", id)
+	logger.Debugf("qBit DeleteDownload: success for hash=%s", id)
 	return nil
 }
 
@@ -440,3 +449,77 @@ func containsAny(s string, substrs ...string) bool {
 	}
 	return false
 }
+
+// SetSpeedLimits sets the global download/upload limits in KB/s (0 = unlimited)
+func (q *QBittorrent) SetSpeedLimits(downloadKBps, uploadKBps int) error {
+	if err := q.login(); err != nil {
+		return err
+	}
+
+	// qBittorrent expects bytes/sec
+	limits := map[string]int{
+		"setDownloadLimit": downloadKBps * 1024,
+		"setUploadLimit":   uploadKBps * 1024,
+	}
+	for endpoint, limit := range limits {
+		data := url.Values{
+			"limit": {fmt.Sprintf("%d", limit)},
+		}
+		resp, err := q.client.PostForm(q.baseURL+"/api/v2/transfer/"+endpoint, data)
+		if err != nil {
+			return fmt.Errorf("failed to set speed limit: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to set speed limit: status %d", resp.StatusCode)
+		}
+	}
+
+	return nil
+}
+
+// GetStatus reports free space in qBittorrent's save path, its connection
+// state and torrents it has marked as errored (usually a full disk or
+// missing files)
+func (q *QBittorrent) GetStatus() (*ClientStatus, error) {
+	if err := q.login(); err != nil {
+		return nil, err
+	}
+
+	resp, err := q.client.Get(q.baseURL + "/api/v2/sync/maindata")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get status: status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		ServerState struct {
+			FreeSpaceOnDisk  int64  `json:"free_space_on_disk"`
+			ConnectionStatus string `json:"connection_status"`
+		} `json:"server_state"`
+		Torrents map[string]qbTorrent `json:"torrents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	status := &ClientStatus{FreeSpace: &data.ServerState.FreeSpaceOnDisk}
+	if data.ServerState.ConnectionStatus == "disconnected" {
+		status.Errors = append(status.Errors, "qBittorrent has no network connection")
+	}
+	errored := 0
+	for _, t := range data.Torrents {
+		if q.mapState(t.State) == "error" {
+			errored++
+		}
+	}
+	if errored > 0 {
+		status.Errors = append(status.Errors,
+			fmt.Sprintf("%d torrents errored, usually from a full disk or missing files", errored))
+	}
+	return status, nil
+}
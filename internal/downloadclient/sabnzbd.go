@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/database"
 )
 
@@ -28,7 +30,8 @@ func NewSABnzbd(config *database.DownloadClient) *SABnzbd {
 		config:  config,
 		baseURL: fmt.Sprintf("%s://%s:%d/api", scheme, config.Host, config.Port),
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetDownload),
 		},
 	}
 }
@@ -184,11 +187,11 @@ func (s *SABnzbd) mapHistoryStatus(status string) string {
 	}
 }
 
-func (s *SABnzbd) AddTorrent(url string, category string) error {
+func (s *SABnzbd) AddTorrent(url string, category string, priority Priority) error {
 	return fmt.Errorf("SABnzbd does not support torrent files")
 }
 
-func (s *SABnzbd) AddNZB(nzbURL string, category string) error {
+func (s *SABnzbd) AddNZB(nzbURL string, category string, priority Priority) error {
 	params := url.Values{
 		"name": {nzbURL},
 	}
@@ -198,6 +201,11 @@ func (s *SABnzbd) AddNZB(nzbURL string, category string) error {
 	} else if s.config.Category != "" {
 		params.Set("cat", s.config.Category)
 	}
+	// SABnzbd's priorities are -1 (low), 0 (normal) and 1 (high); normal
+	// downloads keep the category's priority
+	if priority != PriorityNormal {
+		params.Set("priority", strconv.Itoa(int(priority)))
+	}
 
 	resp, err := s.doRequest("addurl", params)
 	if err != nil {
@@ -282,3 +290,74 @@ func (s *SABnzbd) GetCategories() ([]string, error) {
 func (s *SABnzbd) GetClientType() string {
 	return "usenet"
 }
+
+// SetSpeedLimits sets the download limit in KB/s (0 = unlimited).
+// SABnzbd has no upload limit, so uploadKBps is ignored.
+func (s *SABnzbd) SetSpeedLimits(downloadKBps, uploadKBps int) error {
+	// A bare number is a percentage of the configured line speed; "K" makes it absolute
+	value := "100"
+	if downloadKBps > 0 {
+		value = fmt.Sprintf("%dK", downloadKBps)
+	}
+	resp, err := s.doRequest("config", url.Values{"name": {"speedlimit"}, "value": {value}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetStatus reports free space in SABnzbd's download folder, whether its
+// queue is paused and its current warnings, which include a full disk
+func (s *SABnzbd) GetStatus() (*ClientStatus, error) {
+	resp, err := s.doRequest("queue", url.Values{"limit": {"1"}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var queueResult struct {
+		Queue struct {
+			DiskSpace1 string `json:"diskspace1"` // GB free in the incomplete folder
+			Paused     bool   `json:"paused"`
+		} `json:"queue"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queueResult); err != nil {
+		return nil, fmt.Errorf("failed to decode queue: %w", err)
+	}
+
+	status := &ClientStatus{Paused: queueResult.Queue.Paused}
+	var freeGB float64
+	if _, err := fmt.Sscanf(queueResult.Queue.DiskSpace1, "%f", &freeGB); err == nil {
+		free := int64(freeGB * 1024 * 1024 * 1024)
+		status.FreeSpace = &free
+	}
+
+	warningsResp, err := s.doRequest("warnings", nil)
+	if err != nil {
+		return status, nil // Status is still useful without warnings
+	}
+	defer warningsResp.Body.Close()
+
+	// Older versions list warnings as strings, newer ones as objects
+	var warningsResult struct {
+		Warnings []json.RawMessage `json:"warnings"`
+	}
+	if err := json.NewDecoder(warningsResp.Body).Decode(&warningsResult); err != nil {
+		return status, nil
+	}
+	for _, raw := range warningsResult.Warnings {
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			var warning struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(raw, &warning)
+			text = warning.Text
+		}
+		if text != "" {
+			status.Errors = append(status.Errors, text)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,143 @@
+package downloadclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// lowClientSpace is the free space below which a client is reported as
+// nearly out of room
+const lowClientSpace = 1 << 30 // 1 GB
+
+// maxStatusErrors caps how many reported problems a status keeps
+const maxStatusErrors = 5
+
+// statusMaxAge is how old a polled status can be before it's polled again
+// on request
+const statusMaxAge = 2 * time.Minute
+
+// ClientStatus is a download client's view of itself: free space where it
+// saves downloads, its queue and any problems it reports
+type ClientStatus struct {
+	Reachable   bool      `json:"reachable"`
+	Error       string    `json:"error,omitempty"`     // Why the client couldn't be polled
+	FreeSpace   *int64    `json:"freeSpace,omitempty"` // Bytes free in the client's download directory
+	Paused      bool      `json:"paused"`              // The whole queue is paused
+	Queued      int       `json:"queued"`              // Downloads not yet finished
+	Downloading int       `json:"downloading"`
+	Errored     int       `json:"errored"`   // Downloads in an error state
+	QueueSize   int64     `json:"queueSize"` // Bytes of unfinished downloads
+	Remaining   int64     `json:"remaining"` // Bytes left to download
+	Errors      []string  `json:"errors,omitempty"`
+	CheckedAt   time.Time `json:"checkedAt"`
+}
+
+// Healthy reports whether the client is reachable and reports no problems
+func (s ClientStatus) Healthy() bool {
+	return s.Reachable && len(s.Errors) == 0
+}
+
+// PollClientStatus asks a client for its status and queue and remembers it
+func (m *Manager) PollClientStatus(config *database.DownloadClient) ClientStatus {
+	status := m.pollClientStatus(config)
+	m.statusMu.Lock()
+	m.statuses[config.ID] = status
+	m.statusMu.Unlock()
+	return status
+}
+
+func (m *Manager) pollClientStatus(config *database.DownloadClient) ClientStatus {
+	now := time.Now()
+	client, err := New(config)
+	if err != nil {
+		return ClientStatus{Error: err.Error(), CheckedAt: now}
+	}
+
+	reported, err := client.GetStatus()
+	if err != nil {
+		return ClientStatus{Error: err.Error(), CheckedAt: now}
+	}
+	status := *reported
+	status.Reachable = true
+	status.CheckedAt = now
+
+	if downloads, err := client.GetDownloads(); err == nil {
+		for _, dl := range downloads {
+			switch dl.Status {
+			case "completed":
+				continue
+			case "downloading":
+				status.Downloading++
+			case "error":
+				status.Errored++
+			}
+			status.Queued++
+			status.QueueSize += dl.Size
+			if dl.Size > dl.Downloaded {
+				status.Remaining += dl.Size - dl.Downloaded
+			}
+		}
+	}
+
+	if status.FreeSpace != nil && *status.FreeSpace < lowClientSpace {
+		status.Errors = append([]string{fmt.Sprintf("Only %d MB free in the download directory",
+			*status.FreeSpace/(1024*1024))}, status.Errors...)
+	}
+	if len(status.Errors) > maxStatusErrors {
+		status.Errors = status.Errors[:maxStatusErrors]
+	}
+	return status
+}
+
+// PollClientStatuses polls every enabled client concurrently and forgets
+// clients that were removed or disabled
+func (m *Manager) PollClientStatuses() {
+	clients, err := m.db.GetEnabledDownloadClients()
+	if err != nil {
+		logger.Errorf("Download clients: failed to get clients for status poll: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	enabled := make(map[int64]bool)
+	for i := range clients {
+		enabled[clients[i].ID] = true
+		wg.Add(1)
+		go func(config *database.DownloadClient) {
+			defer wg.Done()
+			status := m.PollClientStatus(config)
+			if !status.Healthy() {
+				logger.Infof("Download clients: %s reports problems: %s %v", config.Name, status.Error, status.Errors)
+			}
+		}(&clients[i])
+	}
+	wg.Wait()
+
+	m.statusMu.Lock()
+	for id := range m.statuses {
+		if !enabled[id] {
+			delete(m.statuses, id)
+		}
+	}
+	m.statusMu.Unlock()
+}
+
+// GetClientStatus returns the last polled status of a client, if any
+func (m *Manager) GetClientStatus(id int64) (ClientStatus, bool) {
+	m.statusMu.RLock()
+	defer m.statusMu.RUnlock()
+	status, ok := m.statuses[id]
+	return status, ok
+}
+
+// CurrentClientStatus returns a client's polled status, polling it again
+// when the last one is missing or stale
+func (m *Manager) CurrentClientStatus(config *database.DownloadClient) ClientStatus {
+	if status, ok := m.GetClientStatus(config.ID); ok && time.Since(status.CheckedAt) < statusMaxAge {
+		return status
+	}
+	return m.PollClientStatus(config)
+}
@@ -0,0 +1,144 @@
+package downloadclient
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// storagePauseSetting persists which downloads were paused for low storage,
+// so they can still be resumed after a restart
+const storagePauseSetting = "storage_pause_state"
+
+// StoragePauseState describes downloads paused because disk space ran low
+type StoragePauseState struct {
+	Paused    bool               `json:"paused"`
+	Reason    string             `json:"reason,omitempty"`
+	PausedAt  *time.Time         `json:"pausedAt,omitempty"`
+	Downloads map[int64][]string `json:"downloads,omitempty"` // Client ID -> download IDs paused by Outpost
+}
+
+// loadStoragePause reads the persisted pause state. Callers must hold storageMu.
+func (m *Manager) loadStoragePause() StoragePauseState {
+	var state StoragePauseState
+	if val, err := m.db.GetSetting(storagePauseSetting); err == nil && val != "" {
+		if err := json.Unmarshal([]byte(val), &state); err != nil {
+			logger.Infof("Storage pause: ignoring invalid saved state: %v", err)
+		}
+	}
+	if state.Downloads == nil {
+		state.Downloads = make(map[int64][]string)
+	}
+	return state
+}
+
+// saveStoragePause persists the pause state. Callers must hold storageMu.
+func (m *Manager) saveStoragePause(state StoragePauseState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := m.db.SetSetting(storagePauseSetting, string(data)); err != nil {
+		logger.Errorf("Storage pause: failed to save state: %v", err)
+	}
+}
+
+// GetStoragePauseState returns the current low storage pause state
+func (m *Manager) GetStoragePauseState() StoragePauseState {
+	m.storageMu.Lock()
+	defer m.storageMu.Unlock()
+	return m.loadStoragePause()
+}
+
+// PauseForLowStorage pauses every active download on all enabled clients and
+// remembers which ones Outpost paused. It can be called repeatedly to catch
+// downloads added while paused. Returns the number of newly paused downloads.
+func (m *Manager) PauseForLowStorage(reason string) int {
+	m.storageMu.Lock()
+	defer m.storageMu.Unlock()
+
+	state := m.loadStoragePause()
+	if !state.Paused {
+		now := time.Now()
+		state.Paused = true
+		state.PausedAt = &now
+	}
+	state.Reason = reason
+
+	clients, err := m.db.GetEnabledDownloadClients()
+	if err != nil {
+		logger.Errorf("Storage pause: failed to get download clients: %v", err)
+		m.saveStoragePause(state)
+		return 0
+	}
+
+	paused := 0
+	for _, clientConfig := range clients {
+		client, err := New(&clientConfig)
+		if err != nil {
+			continue
+		}
+		downloads, err := client.GetDownloads()
+		if err != nil {
+			logger.Errorf("Storage pause: failed to get downloads from %s: %v", clientConfig.Name, err)
+			continue
+		}
+
+		already := make(map[string]bool)
+		for _, id := range state.Downloads[clientConfig.ID] {
+			already[id] = true
+		}
+
+		for _, dl := range downloads {
+			if dl.Status != "downloading" && dl.Status != "queued" {
+				continue
+			}
+			if err := client.PauseDownload(dl.ID); err != nil {
+				logger.Errorf("Storage pause: failed to pause %s on %s: %v", dl.Name, clientConfig.Name, err)
+				continue
+			}
+			if !already[dl.ID] {
+				state.Downloads[clientConfig.ID] = append(state.Downloads[clientConfig.ID], dl.ID)
+				already[dl.ID] = true
+				paused++
+			}
+		}
+	}
+
+	m.saveStoragePause(state)
+	return paused
+}
+
+// ResumeAfterLowStorage resumes the downloads paused by PauseForLowStorage and
+// clears the pause state. Downloads paused manually are left alone. Returns the
+// number of resumed downloads.
+func (m *Manager) ResumeAfterLowStorage() int {
+	m.storageMu.Lock()
+	defer m.storageMu.Unlock()
+
+	state := m.loadStoragePause()
+	if !state.Paused {
+		return 0
+	}
+
+	resumed := 0
+	for clientID, ids := range state.Downloads {
+		clientConfig, err := m.db.GetDownloadClient(clientID)
+		if err != nil {
+			continue // Client was removed
+		}
+		client, err := New(clientConfig)
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			if err := client.ResumeDownload(id); err != nil {
+				logger.Errorf("Storage pause: failed to resume %s on %s: %v", id, clientConfig.Name, err)
+				continue
+			}
+			resumed++
+		}
+	}
+
+	m.saveStoragePause(StoragePauseState{})
+	return resumed
+}
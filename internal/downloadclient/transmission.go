@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/database"
 )
 
@@ -30,7 +31,8 @@ func NewTransmission(config *database.DownloadClient) *Transmission {
 		config:  config,
 		baseURL: fmt.Sprintf("%s://%s:%d/transmission/rpc", scheme, config.Host, config.Port),
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetDownload),
 		},
 	}
 }
@@ -181,7 +183,7 @@ func (t *Transmission) mapStatus(status int) string {
 	}
 }
 
-func (t *Transmission) AddTorrent(torrentURL string, category string) error {
+func (t *Transmission) AddTorrent(torrentURL string, category string, priority Priority) error {
 	args := map[string]interface{}{
 		"filename": torrentURL,
 	}
@@ -191,6 +193,11 @@ func (t *Transmission) AddTorrent(torrentURL string, category string) error {
 	} else if t.config.Category != "" {
 		args["labels"] = []string{t.config.Category}
 	}
+	// Transmission's bandwidth priorities are -1 (low), 0 (normal) and 1
+	// (high)
+	if priority != PriorityNormal {
+		args["bandwidthPriority"] = int(priority)
+	}
 
 	req := &transmissionRequest{
 		Method:    "torrent-add",
@@ -201,7 +208,7 @@ func (t *Transmission) AddTorrent(torrentURL string, category string) error {
 	return err
 }
 
-func (t *Transmission) AddNZB(url string, category string) error {
+func (t *Transmission) AddNZB(url string, category string, priority Priority) error {
 	return fmt.Errorf("Transmission does not support NZB files")
 }
 
@@ -264,3 +271,77 @@ func (t *Transmission) GetCategories() ([]string, error) {
 func (t *Transmission) GetClientType() string {
 	return "torrent"
 }
+
+// SetSpeedLimits sets the global download/upload limits in KB/s (0 = unlimited)
+func (t *Transmission) SetSpeedLimits(downloadKBps, uploadKBps int) error {
+	req := &transmissionRequest{
+		Method: "session-set",
+		Arguments: map[string]interface{}{
+			"speed-limit-down":         downloadKBps,
+			"speed-limit-down-enabled": downloadKBps > 0,
+			"speed-limit-up":           uploadKBps,
+			"speed-limit-up-enabled":   uploadKBps > 0,
+		},
+	}
+	_, err := t.doRequest(req)
+	return err
+}
+
+// GetStatus reports free space in Transmission's download directory and
+// the errors it reports for torrents
+func (t *Transmission) GetStatus() (*ClientStatus, error) {
+	resp, err := t.doRequest(&transmissionRequest{Method: "session-get"})
+	if err != nil {
+		return nil, err
+	}
+	var session struct {
+		DownloadDir string `json:"download-dir"`
+	}
+	if err := json.Unmarshal(resp.Arguments, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session: %w", err)
+	}
+
+	status := &ClientStatus{}
+	if session.DownloadDir != "" {
+		resp, err := t.doRequest(&transmissionRequest{
+			Method:    "free-space",
+			Arguments: map[string]interface{}{"path": session.DownloadDir},
+		})
+		if err == nil {
+			var space struct {
+				SizeBytes int64 `json:"size-bytes"`
+			}
+			if json.Unmarshal(resp.Arguments, &space) == nil {
+				status.FreeSpace = &space.SizeBytes
+			}
+		}
+	}
+
+	resp, err = t.doRequest(&transmissionRequest{
+		Method: "torrent-get",
+		Arguments: map[string]interface{}{
+			"fields": []string{"name", "error", "errorString"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var args struct {
+		Torrents []struct {
+			Name        string `json:"name"`
+			Error       int    `json:"error"`
+			ErrorString string `json:"errorString"`
+		} `json:"torrents"`
+	}
+	if err := json.Unmarshal(resp.Arguments, &args); err != nil {
+		return nil, fmt.Errorf("failed to parse torrents: %w", err)
+	}
+	for _, torrent := range args.Torrents {
+		// Error 3 is a local error such as a full disk; 1 and 2 are tracker
+		// warnings and errors, which don't stop the client
+		if torrent.Error == 3 {
+			status.Errors = append(status.Errors, torrent.Name+": "+torrent.ErrorString)
+		}
+	}
+	return status, nil
+}
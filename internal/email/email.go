@@ -0,0 +1,135 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+var ErrNotConfigured = errors.New("email is not configured")
+
+// Config holds SMTP connection settings
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Sender sends transactional email using the SMTP settings stored in the database
+type Sender struct {
+	db *database.Database
+}
+
+// New creates a new email sender
+func New(db *database.Database) *Sender {
+	return &Sender{db: db}
+}
+
+// config loads the current SMTP settings. Returns nil if email is not configured.
+func (s *Sender) config() *Config {
+	cfg := &Config{}
+	cfg.Host, _ = s.db.GetSetting("smtp_host")
+	cfg.Port, _ = s.db.GetSetting("smtp_port")
+	cfg.Username, _ = s.db.GetSetting("smtp_username")
+	cfg.Password, _ = s.db.GetSetting("smtp_password")
+	cfg.From, _ = s.db.GetSetting("smtp_from")
+
+	if cfg.Host == "" || cfg.From == "" {
+		return nil
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return cfg
+}
+
+// Enabled reports whether SMTP has been configured
+func (s *Sender) Enabled() bool {
+	return s.config() != nil
+}
+
+// Send delivers a plain-text email
+func (s *Sender) Send(to, subject, body string) error {
+	cfg := s.config()
+	if cfg == nil {
+		return ErrNotConfigured
+	}
+
+	msg := buildMessage(cfg.From, to, subject, body)
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	// Port 465 uses implicit TLS; everything else negotiates STARTTLS when offered
+	if cfg.Port == "465" {
+		return sendImplicitTLS(addr, cfg.Host, auth, cfg.From, to, msg)
+	}
+	return smtp.SendMail(addr, auth, cfg.From, []string{to}, msg)
+}
+
+func sendImplicitTLS(addr, host string, auth smtp.Auth, from, to string, msg []byte) error {
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	wc, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(msg); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", sanitizeHeader(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// sanitizeHeader strips line breaks so values can't inject extra headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(value)
+}
@@ -0,0 +1,403 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// EPUB files
+//
+// An EPUB is a zip archive. META-INF/container.xml points at the package
+// document (the OPF), which lists the book's files in its manifest. The
+// table of contents is the EPUB 3 navigation document, or the NCX file of
+// EPUB 2 books, and the cover is the manifest image marked as one.
+// Locations in the table of contents are relative to the package document,
+// as readers expect them.
+
+// maxFileSize bounds how much of a file in the archive is read
+const maxFileSize = 32 << 20
+
+// ErrNoCover is returned by Cover for books without a cover image
+var ErrNoCover = errors.New("epub: no cover image")
+
+// TOCEntry is a heading in the table of contents
+type TOCEntry struct {
+	Title    string     `json:"title"`
+	Href     string     `json:"href"`
+	Children []TOCEntry `json:"children,omitempty"`
+}
+
+// manifestItem is a file listed in the package document
+type manifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// packageDocument is the part of the OPF Outpost uses
+type packageDocument struct {
+	Meta []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []manifestItem `xml:"manifest>item"`
+	Spine struct {
+		TOC string `xml:"toc,attr"`
+	} `xml:"spine"`
+}
+
+// Book is an open EPUB file
+type Book struct {
+	zip     *zip.ReadCloser
+	files   map[string]*zip.File
+	opfPath string
+	pkg     packageDocument
+}
+
+// Open opens an EPUB file and reads its package document
+func Open(filePath string) (*Book, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	b := &Book{zip: zr, files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		b.files[f.Name] = f
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := b.decode("META-INF/container.xml", &container); err != nil {
+		zr.Close()
+		return nil, err
+	}
+	for _, rf := range container.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			b.opfPath = rf.FullPath
+			break
+		}
+	}
+	if b.opfPath == "" {
+		zr.Close()
+		return nil, fmt.Errorf("epub: no package document")
+	}
+	if err := b.decode(b.opfPath, &b.pkg); err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close closes the archive
+func (b *Book) Close() error {
+	return b.zip.Close()
+}
+
+// read returns a file in the archive
+func (b *Book) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("epub: %s not found", name)
+	}
+	if f.UncompressedSize64 > maxFileSize {
+		return nil, fmt.Errorf("epub: %s is too large", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxFileSize))
+}
+
+// decode parses an XML or XHTML file in the archive. Books are often not
+// quite valid XML, so HTML entities and unclosed tags are allowed.
+func (b *Book) decode(name string, v interface{}) error {
+	data, err := b.read(name)
+	if err != nil {
+		return err
+	}
+	if err := newDecoder(data).Decode(v); err != nil {
+		return fmt.Errorf("epub: %s: %w", name, err)
+	}
+	return nil
+}
+
+// newDecoder returns a decoder that reads XML the way browsers read XHTML
+func newDecoder(data []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Other encodings are read as they are; some text may come out
+		// garbled, but the structure is still there
+		return input, nil
+	}
+	return d
+}
+
+// resolve returns the archive path of an href in a file, keeping its
+// fragment
+func resolve(base, href string) string {
+	href, fragment, _ := strings.Cut(href, "#")
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	resolved := path.Join(path.Dir(base), href)
+	if href == "" {
+		resolved = base
+	}
+	if fragment != "" {
+		resolved += "#" + fragment
+	}
+	return resolved
+}
+
+// relative returns an archive path relative to the package document
+func (b *Book) relative(name string) string {
+	dir := path.Dir(b.opfPath)
+	if dir == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, dir+"/")
+}
+
+// item returns a manifest item by its ID
+func (b *Book) item(id string) *manifestItem {
+	for i := range b.pkg.Items {
+		if b.pkg.Items[i].ID == id {
+			return &b.pkg.Items[i]
+		}
+	}
+	return nil
+}
+
+// hasProperty reports whether a manifest item has a property
+func (it *manifestItem) hasProperty(property string) bool {
+	for _, p := range strings.Fields(it.Properties) {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// TOC returns the table of contents, from the navigation document or the
+// NCX file. A book with neither has none.
+func (b *Book) TOC() ([]TOCEntry, error) {
+	for i := range b.pkg.Items {
+		if it := &b.pkg.Items[i]; it.hasProperty("nav") {
+			toc, err := b.navTOC(resolve(b.opfPath, it.Href))
+			if err == nil && len(toc) > 0 {
+				return toc, nil
+			}
+		}
+	}
+
+	ncx := b.item(b.pkg.Spine.TOC)
+	if ncx == nil {
+		for i := range b.pkg.Items {
+			if b.pkg.Items[i].MediaType == "application/x-dtbncx+xml" {
+				ncx = &b.pkg.Items[i]
+				break
+			}
+		}
+	}
+	if ncx == nil {
+		return nil, nil
+	}
+	return b.ncxTOC(resolve(b.opfPath, ncx.Href))
+}
+
+// node is an element of an XHTML document
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   []byte     `xml:",innerxml"`
+	Nodes   []node     `xml:",any"`
+}
+
+// attr returns an attribute's value by its local name
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// text returns the text in an element, with its markup left out
+func (n *node) text() string {
+	d := newDecoder(n.Inner)
+	var sb strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.StartElement, xml.EndElement:
+			sb.WriteString(" ")
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// find returns the first element, depth first, that matches
+func (n *node) find(match func(*node) bool) *node {
+	if match(n) {
+		return n
+	}
+	for i := range n.Nodes {
+		if found := n.Nodes[i].find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// navTOC reads the toc nav of an EPUB 3 navigation document, falling back to
+// its first nav
+func (b *Book) navTOC(name string) ([]TOCEntry, error) {
+	var doc node
+	if err := b.decode(name, &doc); err != nil {
+		return nil, err
+	}
+	nav := doc.find(func(n *node) bool {
+		return n.XMLName.Local == "nav" && strings.Contains(" "+n.attr("type")+" ", " toc ")
+	})
+	if nav == nil {
+		nav = doc.find(func(n *node) bool { return n.XMLName.Local == "nav" })
+	}
+	if nav == nil {
+		return nil, nil
+	}
+	list := nav.find(func(n *node) bool { return n.XMLName.Local == "ol" || n.XMLName.Local == "ul" })
+	if list == nil {
+		return nil, nil
+	}
+	return b.navList(name, list), nil
+}
+
+// navList reads the entries of a nav list and the lists nested in them
+func (b *Book) navList(name string, list *node) []TOCEntry {
+	var entries []TOCEntry
+	for i := range list.Nodes {
+		li := &list.Nodes[i]
+		if li.XMLName.Local != "li" {
+			continue
+		}
+		var entry TOCEntry
+		for j := range li.Nodes {
+			child := &li.Nodes[j]
+			switch child.XMLName.Local {
+			case "a", "span":
+				entry.Title = child.text()
+				if href := child.attr("href"); href != "" {
+					entry.Href = b.relative(resolve(name, href))
+				}
+			case "ol", "ul":
+				entry.Children = append(entry.Children, b.navList(name, child)...)
+			}
+		}
+		if entry.Title != "" || len(entry.Children) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// navPoint is an entry in an NCX navMap
+type navPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []navPoint `xml:"navPoint"`
+}
+
+// ncxTOC reads the navMap of an EPUB 2 NCX file
+func (b *Book) ncxTOC(name string) ([]TOCEntry, error) {
+	var ncx struct {
+		Points []navPoint `xml:"navMap>navPoint"`
+	}
+	if err := b.decode(name, &ncx); err != nil {
+		return nil, err
+	}
+	var convert func(points []navPoint) []TOCEntry
+	convert = func(points []navPoint) []TOCEntry {
+		var entries []TOCEntry
+		for _, p := range points {
+			entry := TOCEntry{
+				Title:    strings.Join(strings.Fields(p.Label), " "),
+				Children: convert(p.Children),
+			}
+			if p.Content.Src != "" {
+				entry.Href = b.relative(resolve(name, p.Content.Src))
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	return convert(ncx.Points), nil
+}
+
+// Cover returns the cover image and its content type: the manifest image
+// with the cover-image property, the one EPUB 2 metadata names, or failing
+// those an image called cover
+func (b *Book) Cover() ([]byte, string, error) {
+	isImage := func(it *manifestItem) bool { return strings.HasPrefix(it.MediaType, "image/") }
+
+	var cover *manifestItem
+	for i := range b.pkg.Items {
+		if it := &b.pkg.Items[i]; isImage(it) && it.hasProperty("cover-image") {
+			cover = it
+			break
+		}
+	}
+	if cover == nil {
+		for _, m := range b.pkg.Meta {
+			if m.Name == "cover" {
+				if it := b.item(m.Content); it != nil && isImage(it) {
+					cover = it
+				}
+				break
+			}
+		}
+	}
+	if cover == nil {
+		for i := range b.pkg.Items {
+			it := &b.pkg.Items[i]
+			if isImage(it) && (strings.Contains(strings.ToLower(it.ID), "cover") ||
+				strings.Contains(strings.ToLower(path.Base(it.Href)), "cover")) {
+				cover = it
+				break
+			}
+		}
+	}
+	if cover == nil {
+		return nil, "", ErrNoCover
+	}
+
+	data, err := b.read(resolve(b.opfPath, cover.Href))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, cover.MediaType, nil
+}
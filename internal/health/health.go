@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/database"
 	"github.com/outpost/outpost/internal/downloadclient"
+	"github.com/outpost/outpost/internal/i18n"
 	"github.com/outpost/outpost/internal/indexer"
 	"github.com/outpost/outpost/internal/storage"
 )
@@ -30,6 +33,18 @@ type Check struct {
 	Latency   *int64    `json:"latency,omitempty"` // milliseconds
 	LastCheck time.Time `json:"lastCheck"`
 	Error     *string   `json:"error,omitempty"`
+	Action    string    `json:"action,omitempty"` // Setup step that would fix it
+
+	message i18n.Message // Message before it's put in a reader's language
+}
+
+// Localize returns the check with its message and action in a language
+func (c Check) Localize(lang string) Check {
+	c.Message = c.message.String(lang)
+	if c.Action != "" {
+		c.Action = i18n.T(lang, c.Action)
+	}
+	return c
 }
 
 // HealthStatus represents the overall health status
@@ -37,6 +52,21 @@ type HealthStatus struct {
 	Overall       Status    `json:"overall"`
 	Checks        []Check   `json:"checks"`
 	LastFullCheck time.Time `json:"lastFullCheck"`
+	SetupActions  []Check   `json:"setupActions"` // Checks that need something set up
+}
+
+// Localize returns a copy of the status with its checks in a language
+func (h *HealthStatus) Localize(lang string) *HealthStatus {
+	localized := *h
+	localized.Checks = make([]Check, len(h.Checks))
+	for i, check := range h.Checks {
+		localized.Checks[i] = check.Localize(lang)
+	}
+	localized.SetupActions = make([]Check, len(h.SetupActions))
+	for i, check := range h.SetupActions {
+		localized.SetupActions[i] = check.Localize(lang)
+	}
+	return &localized
 }
 
 // Checker provides health check functionality
@@ -48,6 +78,14 @@ type Checker struct {
 	mu        sync.RWMutex
 	cache     *HealthStatus
 	cacheTime time.Time
+
+	notifier Notifier
+	failing  map[string]bool // Checks that were unhealthy on the last full check
+}
+
+// Notifier is told when a check starts failing
+type Notifier interface {
+	NotifyHealthFailure(check string, message i18n.Message) error
 }
 
 // NewChecker creates a new health checker
@@ -59,6 +97,28 @@ func NewChecker(db *database.Database, downloads *downloadclient.Manager, indexe
 	}
 }
 
+// SetNotifier sets who is told when a check starts failing
+func (c *Checker) SetNotifier(notifier Notifier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notifier = notifier
+}
+
+// CheckAll runs every check with the current TMDB key, for checking in the
+// background. Returns how many checks are unhealthy.
+func (c *Checker) CheckAll() int {
+	key, _ := c.db.GetSetting("tmdb_api_key")
+	c.SetTMDBKey(key)
+
+	unhealthy := 0
+	for _, check := range c.GetFullStatus().Checks {
+		if check.Status == StatusUnhealthy {
+			unhealthy++
+		}
+	}
+	return unhealthy
+}
+
 // SetTMDBKey sets the TMDB API key for health checks
 func (c *Checker) SetTMDBKey(key string) {
 	c.mu.Lock()
@@ -77,7 +137,7 @@ func (c *Checker) GetFullStatus() *HealthStatus {
 
 	addCheck := func(check Check) {
 		mu.Lock()
-		checks = append(checks, check)
+		checks = append(checks, check.Localize(i18n.Fallback))
 		mu.Unlock()
 	}
 
@@ -127,6 +187,15 @@ func (c *Checker) GetFullStatus() *HealthStatus {
 		}
 	}()
 
+	// Low storage pause check
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if check := c.checkStoragePause(); check != nil {
+			addCheck(*check)
+		}
+	}()
+
 	// TMDB check
 	wg.Add(1)
 	go func() {
@@ -136,6 +205,11 @@ func (c *Checker) GetFullStatus() *HealthStatus {
 
 	wg.Wait()
 
+	// Chaos mode check
+	if check := c.checkChaos(); check != nil {
+		addCheck(*check)
+	}
+
 	// Calculate overall status
 	overall := StatusHealthy
 	for _, check := range checks {
@@ -148,23 +222,60 @@ func (c *Checker) GetFullStatus() *HealthStatus {
 		}
 	}
 
+	setupActions := []Check{}
+	for _, check := range checks {
+		if check.Action != "" {
+			setupActions = append(setupActions, check)
+		}
+	}
+
 	status := &HealthStatus{
 		Overall:       overall,
 		Checks:        checks,
 		LastFullCheck: now,
+		SetupActions:  setupActions,
 	}
 
-	// Cache the result
+	// Cache the result, and tell the notifier about checks that just started
+	// failing
+	failing := make(map[string]bool)
+	var newlyFailing []Check
 	c.mu.Lock()
+	for _, check := range checks {
+		if check.Status != StatusUnhealthy {
+			continue
+		}
+		failing[check.Name] = true
+		if !c.failing[check.Name] {
+			newlyFailing = append(newlyFailing, check)
+		}
+	}
 	c.cache = status
 	c.cacheTime = now
+	c.failing = failing
+	notifier := c.notifier
 	c.mu.Unlock()
 
+	if notifier != nil {
+		for _, check := range newlyFailing {
+			notifier.NotifyHealthFailure(check.Name, check.message)
+		}
+	}
+
 	return status
 }
 
 // RunSingleCheck runs a single health check by name
 func (c *Checker) RunSingleCheck(name string) *Check {
+	check := c.runSingleCheck(name)
+	if check == nil {
+		return nil
+	}
+	localized := check.Localize(i18n.Fallback)
+	return &localized
+}
+
+func (c *Checker) runSingleCheck(name string) *Check {
 	switch name {
 	case "database":
 		check := c.checkDatabase()
@@ -174,6 +285,10 @@ func (c *Checker) RunSingleCheck(name string) *Check {
 		return &check
 	case "prowlarr":
 		return c.checkProwlarr()
+	case "storage_pause":
+		return c.checkStoragePause()
+	case "chaos":
+		return c.checkChaos()
 	default:
 		// Check if it's a download client
 		if len(name) > 17 && name[:17] == "download_client_" {
@@ -219,7 +334,7 @@ func (c *Checker) checkDatabase() Check {
 		return Check{
 			Name:      "Database",
 			Status:    StatusUnhealthy,
-			Message:   "Connection failed",
+			message:   i18n.M("Connection failed"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -229,7 +344,7 @@ func (c *Checker) checkDatabase() Check {
 	return Check{
 		Name:      "Database",
 		Status:    StatusHealthy,
-		Message:   "Connected",
+		message:   i18n.M("Connected"),
 		Latency:   &latency,
 		LastCheck: now,
 	}
@@ -261,7 +376,7 @@ func (c *Checker) checkSingleDownloadClient(clientConfig *database.DownloadClien
 		return Check{
 			Name:      fmt.Sprintf("Download Client: %s", clientConfig.Name),
 			Status:    StatusUnhealthy,
-			Message:   "Failed to initialize",
+			message:   i18n.M("Failed to initialize"),
 			LastCheck: now,
 			Error:     &errStr,
 		}
@@ -275,24 +390,39 @@ func (c *Checker) checkSingleDownloadClient(clientConfig *database.DownloadClien
 		return Check{
 			Name:      fmt.Sprintf("Download Client: %s", clientConfig.Name),
 			Status:    StatusUnhealthy,
-			Message:   "Connection failed",
+			message:   i18n.M("Connection failed"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
 		}
 	}
 
-	// Try to get active downloads count
-	downloads, err := client.GetDownloads()
-	msg := "Connected"
-	if err == nil {
-		msg = fmt.Sprintf("Connected, %d active downloads", len(downloads))
+	// Reachable isn't enough: the client may be stuck on a full disk
+	status := c.downloads.CurrentClientStatus(clientConfig)
+	msg := i18n.M("Connected")
+	if status.Reachable {
+		msg = i18n.M("Connected, %d active downloads", status.Queued)
+		if status.FreeSpace != nil {
+			msg = i18n.M("Connected, %d active downloads, %d GB free", status.Queued, *status.FreeSpace/(1024*1024*1024))
+		}
+	}
+
+	if len(status.Errors) > 0 {
+		errStr := strings.Join(status.Errors, "; ")
+		return Check{
+			Name:      fmt.Sprintf("Download Client: %s", clientConfig.Name),
+			Status:    StatusWarning,
+			message:   i18n.M("Client reports problems"),
+			Latency:   &latency,
+			LastCheck: now,
+			Error:     &errStr,
+		}
 	}
 
 	return Check{
 		Name:      fmt.Sprintf("Download Client: %s", clientConfig.Name),
 		Status:    StatusHealthy,
-		Message:   msg,
+		message:   msg,
 		Latency:   &latency,
 		LastCheck: now,
 	}
@@ -309,14 +439,14 @@ func (c *Checker) checkProwlarr() *Check {
 	now := time.Now()
 
 	// Make a simple request to Prowlarr
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: chaos.Transport(chaos.TargetIndexer)}
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/health", config.URL), nil)
 	if err != nil {
 		errStr := err.Error()
 		return &Check{
 			Name:      "Prowlarr",
 			Status:    StatusUnhealthy,
-			Message:   "Request failed",
+			message:   i18n.M("Request failed"),
 			LastCheck: now,
 			Error:     &errStr,
 		}
@@ -331,7 +461,7 @@ func (c *Checker) checkProwlarr() *Check {
 		return &Check{
 			Name:      "Prowlarr",
 			Status:    StatusUnhealthy,
-			Message:   "Connection failed",
+			message:   i18n.M("Connection failed"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -344,7 +474,7 @@ func (c *Checker) checkProwlarr() *Check {
 		return &Check{
 			Name:      "Prowlarr",
 			Status:    StatusUnhealthy,
-			Message:   "API error",
+			message:   i18n.M("API error"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -354,12 +484,67 @@ func (c *Checker) checkProwlarr() *Check {
 	return &Check{
 		Name:      "Prowlarr",
 		Status:    StatusHealthy,
-		Message:   "Connected",
+		message:   i18n.M("Connected"),
 		Latency:   &latency,
 		LastCheck: now,
 	}
 }
 
+// checkStoragePause reports whether downloads are paused for low disk space
+func (c *Checker) checkStoragePause() *Check {
+	now := time.Now()
+	state := c.downloads.GetStoragePauseState()
+
+	if state.Paused {
+		paused := 0
+		for _, ids := range state.Downloads {
+			paused += len(ids)
+		}
+		msg := i18n.M("%d downloads paused: %s", paused, state.Reason)
+		if state.PausedAt != nil {
+			msg = i18n.M("%d downloads paused: %s (since %s)", paused, state.Reason, state.PausedAt.Format("Jan 2 15:04"))
+		}
+		return &Check{
+			Name:      "Storage Pause",
+			Status:    StatusWarning,
+			message:   msg,
+			LastCheck: now,
+		}
+	}
+
+	if enabled, _ := c.db.GetSetting("storage_pause_enabled"); enabled != "true" {
+		return nil // Not configured
+	}
+
+	return &Check{
+		Name:      "Storage Pause",
+		Status:    StatusHealthy,
+		message:   i18n.M("Downloads running"),
+		LastCheck: now,
+	}
+}
+
+// checkChaos warns while chaos mode is injecting latency or failures, so it
+// isn't left on by accident
+func (c *Checker) checkChaos() *Check {
+	cfg := chaos.Current()
+	if !cfg.Enabled {
+		return nil
+	}
+
+	var targets interface{} = i18n.M("all external services")
+	if len(cfg.Targets) > 0 {
+		targets = strings.Join(cfg.Targets, ", ")
+	}
+	return &Check{
+		Name:      "Chaos Mode",
+		Status:    StatusWarning,
+		message:   i18n.M("Injecting %s latency and %d%% failures into %s", cfg.Latency, cfg.FailurePercent, targets),
+		LastCheck: time.Now(),
+		Action:    "Turn off chaos mode in Settings",
+	}
+}
+
 // checkIndexers checks enabled indexers
 func (c *Checker) checkIndexers() []Check {
 	indexers, err := c.db.GetEnabledIndexers()
@@ -390,7 +575,7 @@ func (c *Checker) checkSingleIndexer(idx *database.Indexer) Check {
 		return Check{
 			Name:      fmt.Sprintf("Indexer: %s", idx.Name),
 			Status:    StatusHealthy,
-			Message:   "Synced from Prowlarr",
+			message:   i18n.M("Synced from Prowlarr"),
 			LastCheck: now,
 		}
 	}
@@ -400,7 +585,7 @@ func (c *Checker) checkSingleIndexer(idx *database.Indexer) Check {
 	return Check{
 		Name:      fmt.Sprintf("Indexer: %s", idx.Name),
 		Status:    StatusHealthy,
-		Message:   "Enabled",
+		message:   i18n.M("Enabled"),
 		LastCheck: now,
 	}
 }
@@ -430,7 +615,7 @@ func (c *Checker) checkDiskSpace() []Check {
 			checks = append(checks, Check{
 				Name:      fmt.Sprintf("Disk: %s", lib.Name),
 				Status:    StatusWarning,
-				Message:   "Unable to check",
+				message:   i18n.M("Unable to check"),
 				LastCheck: now,
 				Error:     &errStr,
 			})
@@ -447,12 +632,12 @@ func (c *Checker) checkDiskSpace() []Check {
 			status = StatusWarning
 		}
 
-		msg := fmt.Sprintf("%.0f%% used (%d GB free)", usedPercent, freeGB)
+		msg := i18n.M("%.0f%% used (%d GB free)", usedPercent, freeGB)
 
 		checks = append(checks, Check{
 			Name:      fmt.Sprintf("Disk: %s", lib.Name),
 			Status:    status,
-			Message:   msg,
+			message:   msg,
 			LastCheck: now,
 		})
 	}
@@ -479,12 +664,12 @@ func (c *Checker) checkDiskSpace() []Check {
 			status = StatusWarning
 		}
 
-		msg := fmt.Sprintf("%.0f%% used (%d GB free)", usedPercent, freeGB)
+		msg := i18n.M("%.0f%% used (%d GB free)", usedPercent, freeGB)
 
 		checks = append(checks, Check{
 			Name:      fmt.Sprintf("Disk: %s", checkPath),
 			Status:    status,
-			Message:   msg,
+			message:   msg,
 			LastCheck: now,
 		})
 
@@ -515,15 +700,16 @@ func (c *Checker) checkTMDB() Check {
 		return Check{
 			Name:      "TMDB API",
 			Status:    StatusWarning,
-			Message:   "Not configured",
+			message:   i18n.M("Not configured - library works from local data, discovery and metadata are off"),
 			LastCheck: now,
+			Action:    "Add a TMDB API key in Settings",
 		}
 	}
 
 	start := time.Now()
 
 	// Make a simple API call to TMDB
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: chaos.Transport(chaos.TargetTMDB)}
 	url := fmt.Sprintf("https://api.themoviedb.org/3/configuration?api_key=%s", apiKey)
 	resp, err := client.Get(url)
 	latency := time.Since(start).Milliseconds()
@@ -533,7 +719,7 @@ func (c *Checker) checkTMDB() Check {
 		return Check{
 			Name:      "TMDB API",
 			Status:    StatusUnhealthy,
-			Message:   "Connection failed",
+			message:   i18n.M("Connection failed"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -546,7 +732,7 @@ func (c *Checker) checkTMDB() Check {
 		return Check{
 			Name:      "TMDB API",
 			Status:    StatusUnhealthy,
-			Message:   "Authentication failed",
+			message:   i18n.M("Authentication failed"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -558,7 +744,7 @@ func (c *Checker) checkTMDB() Check {
 		return Check{
 			Name:      "TMDB API",
 			Status:    StatusUnhealthy,
-			Message:   "API error",
+			message:   i18n.M("API error"),
 			Latency:   &latency,
 			LastCheck: now,
 			Error:     &errStr,
@@ -574,7 +760,7 @@ func (c *Checker) checkTMDB() Check {
 	return Check{
 		Name:      "TMDB API",
 		Status:    status,
-		Message:   "Reachable",
+		message:   i18n.M("Reachable"),
 		Latency:   &latency,
 		LastCheck: now,
 	}
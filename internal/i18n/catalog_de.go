@@ -0,0 +1,119 @@
+package i18n
+
+// German translations
+
+var catalogDE = map[string]string{
+	// Notifications
+	"New Content Available":                                              "Neue Inhalte verfügbar",
+	"%s is now available in your library":                                "%s ist jetzt in deiner Mediathek verfügbar",
+	"New Episode Available":                                              "Neue Folge verfügbar",
+	"%s S%02dE%02d is now available":                                     "%s S%02dE%02d ist jetzt verfügbar",
+	"%s, with %s, is now available in your library":                      "%s mit %s ist jetzt in deiner Mediathek verfügbar",
+	"Released Digitally":                                                 "Digital erschienen",
+	"%s, with %s, is out digitally":                                      "%s mit %s ist jetzt digital erschienen",
+	"Request Approved":                                                   "Anfrage genehmigt",
+	"Your request for \"%s\" has been approved":                          "Deine Anfrage für „%s“ wurde genehmigt",
+	"Request Denied":                                                     "Anfrage abgelehnt",
+	"Your request for \"%s\" was denied":                                 "Deine Anfrage für „%s“ wurde abgelehnt",
+	"Your request for \"%s\" was denied: %s":                             "Deine Anfrage für „%s“ wurde abgelehnt: %s",
+	"Request Reopened":                                                   "Anfrage wieder geöffnet",
+	"\"%s\" is out now and your request has been approved":               "„%s“ ist jetzt erschienen und deine Anfrage wurde genehmigt",
+	"\"%s\" was released, so its denied request was approved":            "„%s“ ist erschienen, daher wurde die abgelehnte Anfrage genehmigt",
+	"\"%s\" is out now and your request has been reopened":               "„%s“ ist jetzt erschienen und deine Anfrage wurde wieder geöffnet",
+	"\"%s\" was released, so its denied request was reopened for review": "„%s“ ist erschienen, daher wurde die abgelehnte Anfrage zur Prüfung wieder geöffnet",
+	"Download Complete":                                                  "Download abgeschlossen",
+	"%s has finished downloading":                                        "%s wurde fertig heruntergeladen",
+	"Download Failed":                                                    "Download fehlgeschlagen",
+	"Download failed for \"%s\"":                                         "Download von „%s“ fehlgeschlagen",
+	"Download failed for \"%s\": %s":                                     "Download von „%s“ fehlgeschlagen: %s",
+	"Download Swapped":                                                   "Download ersetzt",
+	"\"%s\" stalled (%s) and was replaced with \"%s\"":                   "„%s“ ist hängen geblieben (%s) und wurde durch „%s“ ersetzt",
+	"Downloads Paused":                                                   "Downloads pausiert",
+	"Downloads were paused because disk space is low: %s":                "Downloads wurden wegen wenig Speicherplatz pausiert: %s",
+	"%d GB free on %s (threshold: %d GB)":                                "%d GB frei auf %s (Schwelle: %d GB)",
+	"Downloads Resumed":                                                  "Downloads fortgesetzt",
+	"Resumed %d downloads - %d GB free on %s":                            "%d Downloads fortgesetzt - %d GB frei auf %s",
+	"Resumed %d downloads - low storage pausing was disabled":            "%d Downloads fortgesetzt - Pausieren bei wenig Speicher wurde deaktiviert",
+
+	// Notification providers
+	"Health Check Failed":                      "Gesundheitsprüfung fehlgeschlagen",
+	"%s is failing: %s":                        "%s schlägt fehl: %s",
+	"Test Notification":                        "Testbenachrichtigung",
+	"Outpost notifications are working":        "Outpost-Benachrichtigungen funktionieren",
+	"The request for \"%s\" has been approved": "Die Anfrage für „%s“ wurde genehmigt",
+
+	// Security notifications
+	"Outpost security: %s":   "Outpost-Sicherheit: %s",
+	"Repeated Failed Logins": "Wiederholte fehlgeschlagene Anmeldungen",
+	"%d failed sign-ins from %s in the last %d minutes (last tried username %q)": "%d fehlgeschlagene Anmeldungen von %s in den letzten %d Minuten (zuletzt versuchter Benutzername %q)",
+	"%d failed sign-ins for %q in the last %d minutes (last from %s)":            "%d fehlgeschlagene Anmeldungen für %q in den letzten %d Minuten (zuletzt von %s)",
+	"New Admin Sign-in": "Neue Admin-Anmeldung",
+	"Admin %s signed in from a new device: %s at %s": "Admin %s hat sich von einem neuen Gerät angemeldet: %s unter %s",
+	"unknown browser":                   "unbekannter Browser",
+	"API Key Changed":                   "API-Schlüssel geändert",
+	"%s set %s":                         "%s hat %s gesetzt",
+	"Security Settings Changed":         "Sicherheitseinstellungen geändert",
+	"%s changed %s":                     "%s hat %s geändert",
+	"%s set the API key for indexer %q": "%s hat den API-Schlüssel für den Indexer %q gesetzt",
+	"Indexers Changed":                  "Indexer geändert",
+	"%s added indexer %q":               "%s hat den Indexer %q hinzugefügt",
+	"%s changed indexer %q":             "%s hat den Indexer %q geändert",
+	"%s removed indexer %q":             "%s hat den Indexer %q entfernt",
+	"%s configured indexer %q":          "%s hat den Indexer %q eingerichtet",
+	"%s created API key %q":             "%s hat den API-Schlüssel %q erstellt",
+	"%s revoked API key %q":             "%s hat den API-Schlüssel %q widerrufen",
+
+	// Health checks
+	"Connected":                                      "Verbunden",
+	"Connection failed":                              "Verbindung fehlgeschlagen",
+	"Failed to initialize":                           "Initialisierung fehlgeschlagen",
+	"Connected, %d active downloads":                 "Verbunden, %d aktive Downloads",
+	"Connected, %d active downloads, %d GB free":     "Verbunden, %d aktive Downloads, %d GB frei",
+	"Client reports problems":                        "Client meldet Probleme",
+	"Request failed":                                 "Anfrage fehlgeschlagen",
+	"API error":                                      "API-Fehler",
+	"%d downloads paused: %s":                        "%d Downloads pausiert: %s",
+	"%d downloads paused: %s (since %s)":             "%d Downloads pausiert: %s (seit %s)",
+	"Downloads running":                              "Downloads laufen",
+	"Injecting %s latency and %d%% failures into %s": "Füge %s Latenz und %d%% Fehler in %s ein",
+	"all external services":                          "alle externen Dienste",
+	"Turn off chaos mode in Settings":                "Chaos-Modus in den Einstellungen ausschalten",
+	"Synced from Prowlarr":                           "Von Prowlarr synchronisiert",
+	"Enabled":                                        "Aktiviert",
+	"Unable to check":                                "Prüfung nicht möglich",
+	"%.0f%% used (%d GB free)":                       "%.0f%% belegt (%d GB frei)",
+	"Not configured - library works from local data, discovery and metadata are off": "Nicht eingerichtet - die Mediathek nutzt lokale Daten, Entdecken und Metadaten sind aus",
+	"Add a TMDB API key in Settings": "TMDB-API-Schlüssel in den Einstellungen hinzufügen",
+	"Authentication failed":          "Authentifizierung fehlgeschlagen",
+	"Reachable":                      "Erreichbar",
+
+	// Errors
+	"Invalid credentials":                                  "Ungültige Anmeldedaten",
+	"This account is disabled or has expired":              "Dieses Konto ist deaktiviert oder abgelaufen",
+	"This device has been blocked":                         "Dieses Gerät wurde gesperrt",
+	"This account can only be used from the local network": "Dieses Konto kann nur im lokalen Netzwerk verwendet werden",
+	"Admin access is not allowed from this network":        "Admin-Zugriff ist aus diesem Netzwerk nicht erlaubt",
+	"Access is not allowed from your location":             "Zugriff ist von deinem Standort aus nicht erlaubt",
+	"Guest accounts are read-only":                         "Gastkonten sind schreibgeschützt",
+	"Content not available":                                "Inhalt nicht verfügbar",
+	"Content restricted - PIN required":                    "Inhalt eingeschränkt - PIN erforderlich",
+	"Monthly transfer cap reached":                         "Monatliches Übertragungslimit erreicht",
+	"Already requested":                                    "Bereits angefragt",
+	"Already in the library":                               "Bereits in der Mediathek",
+	"Request quota exceeded (%d per %d days)":              "Anfragelimit überschritten (%d pro %d Tage)",
+	"Cannot delete processed request":                      "Bearbeitete Anfragen können nicht gelöscht werden",
+	"Request portal is not enabled":                        "Das Anfrageportal ist nicht aktiviert",
+	"Request portal is not set up":                         "Das Anfrageportal ist nicht eingerichtet",
+	"Invalid passcode":                                     "Ungültiger Zugangscode",
+	"Too many searches, try again in a minute":             "Zu viele Suchen, versuche es in einer Minute erneut",
+	"Too many wrong passcodes, try again later":            "Zu viele falsche Zugangscodes, versuche es später erneut",
+	"A name of up to %d characters is required":            "Ein Name mit bis zu %d Zeichen ist erforderlich",
+	"Request limit reached (%d per hour), try again later": "Anfragelimit erreicht (%d pro Stunde), versuche es später erneut",
+	"Title not found":                                      "Titel nicht gefunden",
+	"Incorrect password":                                   "Falsches Passwort",
+	"The last admin account can't be deleted":              "Das letzte Admin-Konto kann nicht gelöscht werden",
+	"Accounts can't be deleted while impersonating":        "Konten können während einer Identitätsübernahme nicht gelöscht werden",
+	"Too many transcoded streams, try again later":         "Es werden zu viele Streams transkodiert, versuche es später erneut",
+	"Outpost is down for maintenance, try again later":     "Outpost wird gerade gewartet, versuche es später erneut",
+	"Server busy, try again later":                         "Der Server ist ausgelastet, versuche es später erneut",
+}
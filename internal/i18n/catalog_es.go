@@ -0,0 +1,119 @@
+package i18n
+
+// Spanish translations
+
+var catalogES = map[string]string{
+	// Notifications
+	"New Content Available":                                              "Nuevo contenido disponible",
+	"%s is now available in your library":                                "%s ya está disponible en tu biblioteca",
+	"New Episode Available":                                              "Nuevo episodio disponible",
+	"%s S%02dE%02d is now available":                                     "%s S%02dE%02d ya está disponible",
+	"%s, with %s, is now available in your library":                      "%s, con %s, ya está disponible en tu biblioteca",
+	"Released Digitally":                                                 "Estreno digital",
+	"%s, with %s, is out digitally":                                      "%s, con %s, ya se ha estrenado en digital",
+	"Request Approved":                                                   "Solicitud aprobada",
+	"Your request for \"%s\" has been approved":                          "Tu solicitud de «%s» ha sido aprobada",
+	"Request Denied":                                                     "Solicitud rechazada",
+	"Your request for \"%s\" was denied":                                 "Tu solicitud de «%s» ha sido rechazada",
+	"Your request for \"%s\" was denied: %s":                             "Tu solicitud de «%s» ha sido rechazada: %s",
+	"Request Reopened":                                                   "Solicitud reabierta",
+	"\"%s\" is out now and your request has been approved":               "«%s» ya se ha estrenado y tu solicitud ha sido aprobada",
+	"\"%s\" was released, so its denied request was approved":            "«%s» se ha estrenado, así que su solicitud rechazada ha sido aprobada",
+	"\"%s\" is out now and your request has been reopened":               "«%s» ya se ha estrenado y tu solicitud ha sido reabierta",
+	"\"%s\" was released, so its denied request was reopened for review": "«%s» se ha estrenado, así que su solicitud rechazada se ha reabierto para revisarla",
+	"Download Complete":                                                  "Descarga completada",
+	"%s has finished downloading":                                        "%s ha terminado de descargarse",
+	"Download Failed":                                                    "Descarga fallida",
+	"Download failed for \"%s\"":                                         "La descarga de «%s» ha fallado",
+	"Download failed for \"%s\": %s":                                     "La descarga de «%s» ha fallado: %s",
+	"Download Swapped":                                                   "Descarga sustituida",
+	"\"%s\" stalled (%s) and was replaced with \"%s\"":                   "«%s» se ha atascado (%s) y se ha sustituido por «%s»",
+	"Downloads Paused":                                                   "Descargas en pausa",
+	"Downloads were paused because disk space is low: %s":                "Las descargas se han pausado por falta de espacio en disco: %s",
+	"%d GB free on %s (threshold: %d GB)":                                "%d GB libres en %s (umbral: %d GB)",
+	"Downloads Resumed":                                                  "Descargas reanudadas",
+	"Resumed %d downloads - %d GB free on %s":                            "%d descargas reanudadas - %d GB libres en %s",
+	"Resumed %d downloads - low storage pausing was disabled":            "%d descargas reanudadas - se ha desactivado la pausa por poco espacio",
+
+	// Notification providers
+	"Health Check Failed":                      "Comprobación de estado fallida",
+	"%s is failing: %s":                        "%s está fallando: %s",
+	"Test Notification":                        "Notificación de prueba",
+	"Outpost notifications are working":        "Las notificaciones de Outpost funcionan",
+	"The request for \"%s\" has been approved": "Se ha aprobado la solicitud de «%s»",
+
+	// Security notifications
+	"Outpost security: %s":   "Seguridad de Outpost: %s",
+	"Repeated Failed Logins": "Inicios de sesión fallidos repetidos",
+	"%d failed sign-ins from %s in the last %d minutes (last tried username %q)": "%d inicios de sesión fallidos desde %s en los últimos %d minutos (último usuario probado %q)",
+	"%d failed sign-ins for %q in the last %d minutes (last from %s)":            "%d inicios de sesión fallidos de %q en los últimos %d minutos (el último desde %s)",
+	"New Admin Sign-in": "Nuevo inicio de sesión de administrador",
+	"Admin %s signed in from a new device: %s at %s": "El administrador %s ha iniciado sesión desde un dispositivo nuevo: %s en %s",
+	"unknown browser":                   "navegador desconocido",
+	"API Key Changed":                   "Clave de API cambiada",
+	"%s set %s":                         "%s ha establecido %s",
+	"Security Settings Changed":         "Ajustes de seguridad cambiados",
+	"%s changed %s":                     "%s ha cambiado %s",
+	"%s set the API key for indexer %q": "%s ha establecido la clave de API del indexador %q",
+	"Indexers Changed":                  "Indexadores cambiados",
+	"%s added indexer %q":               "%s ha añadido el indexador %q",
+	"%s changed indexer %q":             "%s ha cambiado el indexador %q",
+	"%s removed indexer %q":             "%s ha eliminado el indexador %q",
+	"%s configured indexer %q":          "%s ha configurado el indexador %q",
+	"%s created API key %q":             "%s ha creado la clave de API %q",
+	"%s revoked API key %q":             "%s ha revocado la clave de API %q",
+
+	// Health checks
+	"Connected":                                      "Conectado",
+	"Connection failed":                              "Error de conexión",
+	"Failed to initialize":                           "No se pudo inicializar",
+	"Connected, %d active downloads":                 "Conectado, %d descargas activas",
+	"Connected, %d active downloads, %d GB free":     "Conectado, %d descargas activas, %d GB libres",
+	"Client reports problems":                        "El cliente informa de problemas",
+	"Request failed":                                 "La petición ha fallado",
+	"API error":                                      "Error de la API",
+	"%d downloads paused: %s":                        "%d descargas en pausa: %s",
+	"%d downloads paused: %s (since %s)":             "%d descargas en pausa: %s (desde %s)",
+	"Downloads running":                              "Descargas en curso",
+	"Injecting %s latency and %d%% failures into %s": "Inyectando %s de latencia y %d%% de fallos en %s",
+	"all external services":                          "todos los servicios externos",
+	"Turn off chaos mode in Settings":                "Desactiva el modo caos en Ajustes",
+	"Synced from Prowlarr":                           "Sincronizado desde Prowlarr",
+	"Enabled":                                        "Activado",
+	"Unable to check":                                "No se puede comprobar",
+	"%.0f%% used (%d GB free)":                       "%.0f%% usado (%d GB libres)",
+	"Not configured - library works from local data, discovery and metadata are off": "No configurado - la biblioteca usa datos locales, el descubrimiento y los metadatos están desactivados",
+	"Add a TMDB API key in Settings": "Añade una clave de API de TMDB en Ajustes",
+	"Authentication failed":          "Error de autenticación",
+	"Reachable":                      "Accesible",
+
+	// Errors
+	"Invalid credentials":                                  "Credenciales no válidas",
+	"This account is disabled or has expired":              "Esta cuenta está desactivada o ha caducado",
+	"This device has been blocked":                         "Este dispositivo ha sido bloqueado",
+	"This account can only be used from the local network": "Esta cuenta solo se puede usar desde la red local",
+	"Admin access is not allowed from this network":        "El acceso de administrador no está permitido desde esta red",
+	"Access is not allowed from your location":             "El acceso no está permitido desde tu ubicación",
+	"Guest accounts are read-only":                         "Las cuentas de invitado son de solo lectura",
+	"Content not available":                                "Contenido no disponible",
+	"Content restricted - PIN required":                    "Contenido restringido - se requiere PIN",
+	"Monthly transfer cap reached":                         "Se ha alcanzado el límite de transferencia mensual",
+	"Already requested":                                    "Ya solicitado",
+	"Already in the library":                               "Ya está en la biblioteca",
+	"Request quota exceeded (%d per %d days)":              "Cupo de solicitudes superado (%d cada %d días)",
+	"Cannot delete processed request":                      "No se puede eliminar una solicitud ya procesada",
+	"Request portal is not enabled":                        "El portal de solicitudes no está activado",
+	"Request portal is not set up":                         "El portal de solicitudes no está configurado",
+	"Invalid passcode":                                     "Código de acceso no válido",
+	"Too many searches, try again in a minute":             "Demasiadas búsquedas, inténtalo de nuevo en un minuto",
+	"Too many wrong passcodes, try again later":            "Demasiados códigos de acceso incorrectos, inténtalo más tarde",
+	"A name of up to %d characters is required":            "Se requiere un nombre de hasta %d caracteres",
+	"Request limit reached (%d per hour), try again later": "Límite de solicitudes alcanzado (%d por hora), inténtalo más tarde",
+	"Title not found":                                      "Título no encontrado",
+	"Incorrect password":                                   "Contraseña incorrecta",
+	"The last admin account can't be deleted":              "No se puede eliminar la última cuenta de administrador",
+	"Accounts can't be deleted while impersonating":        "No se pueden eliminar cuentas mientras se suplanta a un usuario",
+	"Too many transcoded streams, try again later":         "Se están transcodificando demasiadas emisiones, inténtalo más tarde",
+	"Outpost is down for maintenance, try again later":     "Outpost está en mantenimiento, inténtalo de nuevo más tarde",
+	"Server busy, try again later":                         "El servidor está ocupado, inténtalo más tarde",
+}
@@ -0,0 +1,119 @@
+package i18n
+
+// French translations
+
+var catalogFR = map[string]string{
+	// Notifications
+	"New Content Available":                                              "Nouveau contenu disponible",
+	"%s is now available in your library":                                "%s est maintenant disponible dans ta médiathèque",
+	"New Episode Available":                                              "Nouvel épisode disponible",
+	"%s S%02dE%02d is now available":                                     "%s S%02dE%02d est maintenant disponible",
+	"%s, with %s, is now available in your library":                      "%s, avec %s, est maintenant disponible dans ta médiathèque",
+	"Released Digitally":                                                 "Sortie numérique",
+	"%s, with %s, is out digitally":                                      "%s, avec %s, est sorti en numérique",
+	"Request Approved":                                                   "Demande approuvée",
+	"Your request for \"%s\" has been approved":                          "Ta demande pour « %s » a été approuvée",
+	"Request Denied":                                                     "Demande refusée",
+	"Your request for \"%s\" was denied":                                 "Ta demande pour « %s » a été refusée",
+	"Your request for \"%s\" was denied: %s":                             "Ta demande pour « %s » a été refusée : %s",
+	"Request Reopened":                                                   "Demande rouverte",
+	"\"%s\" is out now and your request has been approved":               "« %s » est sorti et ta demande a été approuvée",
+	"\"%s\" was released, so its denied request was approved":            "« %s » est sorti, sa demande refusée a donc été approuvée",
+	"\"%s\" is out now and your request has been reopened":               "« %s » est sorti et ta demande a été rouverte",
+	"\"%s\" was released, so its denied request was reopened for review": "« %s » est sorti, sa demande refusée a donc été rouverte pour examen",
+	"Download Complete":                                                  "Téléchargement terminé",
+	"%s has finished downloading":                                        "%s a fini de se télécharger",
+	"Download Failed":                                                    "Échec du téléchargement",
+	"Download failed for \"%s\"":                                         "Le téléchargement de « %s » a échoué",
+	"Download failed for \"%s\": %s":                                     "Le téléchargement de « %s » a échoué : %s",
+	"Download Swapped":                                                   "Téléchargement remplacé",
+	"\"%s\" stalled (%s) and was replaced with \"%s\"":                   "« %s » était bloqué (%s) et a été remplacé par « %s »",
+	"Downloads Paused":                                                   "Téléchargements en pause",
+	"Downloads were paused because disk space is low: %s":                "Les téléchargements ont été mis en pause faute d'espace disque : %s",
+	"%d GB free on %s (threshold: %d GB)":                                "%d Go libres sur %s (seuil : %d Go)",
+	"Downloads Resumed":                                                  "Téléchargements repris",
+	"Resumed %d downloads - %d GB free on %s":                            "%d téléchargements repris - %d Go libres sur %s",
+	"Resumed %d downloads - low storage pausing was disabled":            "%d téléchargements repris - la mise en pause faute d'espace a été désactivée",
+
+	// Notification providers
+	"Health Check Failed":                      "Échec du contrôle de santé",
+	"%s is failing: %s":                        "%s échoue : %s",
+	"Test Notification":                        "Notification de test",
+	"Outpost notifications are working":        "Les notifications Outpost fonctionnent",
+	"The request for \"%s\" has been approved": "La demande pour « %s » a été approuvée",
+
+	// Security notifications
+	"Outpost security: %s":   "Sécurité Outpost : %s",
+	"Repeated Failed Logins": "Échecs de connexion répétés",
+	"%d failed sign-ins from %s in the last %d minutes (last tried username %q)": "%d échecs de connexion depuis %s au cours des %d dernières minutes (dernier nom d'utilisateur essayé %q)",
+	"%d failed sign-ins for %q in the last %d minutes (last from %s)":            "%d échecs de connexion pour %q au cours des %d dernières minutes (le dernier depuis %s)",
+	"New Admin Sign-in": "Nouvelle connexion administrateur",
+	"Admin %s signed in from a new device: %s at %s": "L'administrateur %s s'est connecté depuis un nouvel appareil : %s à %s",
+	"unknown browser":                   "navigateur inconnu",
+	"API Key Changed":                   "Clé API modifiée",
+	"%s set %s":                         "%s a défini %s",
+	"Security Settings Changed":         "Paramètres de sécurité modifiés",
+	"%s changed %s":                     "%s a modifié %s",
+	"%s set the API key for indexer %q": "%s a défini la clé API de l'indexeur %q",
+	"Indexers Changed":                  "Indexeurs modifiés",
+	"%s added indexer %q":               "%s a ajouté l'indexeur %q",
+	"%s changed indexer %q":             "%s a modifié l'indexeur %q",
+	"%s removed indexer %q":             "%s a supprimé l'indexeur %q",
+	"%s configured indexer %q":          "%s a configuré l'indexeur %q",
+	"%s created API key %q":             "%s a créé la clé API %q",
+	"%s revoked API key %q":             "%s a révoqué la clé API %q",
+
+	// Health checks
+	"Connected":                                      "Connecté",
+	"Connection failed":                              "Échec de la connexion",
+	"Failed to initialize":                           "Échec de l'initialisation",
+	"Connected, %d active downloads":                 "Connecté, %d téléchargements actifs",
+	"Connected, %d active downloads, %d GB free":     "Connecté, %d téléchargements actifs, %d Go libres",
+	"Client reports problems":                        "Le client signale des problèmes",
+	"Request failed":                                 "La requête a échoué",
+	"API error":                                      "Erreur de l'API",
+	"%d downloads paused: %s":                        "%d téléchargements en pause : %s",
+	"%d downloads paused: %s (since %s)":             "%d téléchargements en pause : %s (depuis %s)",
+	"Downloads running":                              "Téléchargements en cours",
+	"Injecting %s latency and %d%% failures into %s": "Injection de %s de latence et de %d%% d'échecs dans %s",
+	"all external services":                          "tous les services externes",
+	"Turn off chaos mode in Settings":                "Désactive le mode chaos dans les paramètres",
+	"Synced from Prowlarr":                           "Synchronisé depuis Prowlarr",
+	"Enabled":                                        "Activé",
+	"Unable to check":                                "Vérification impossible",
+	"%.0f%% used (%d GB free)":                       "%.0f%% utilisé (%d Go libres)",
+	"Not configured - library works from local data, discovery and metadata are off": "Non configuré - la médiathèque utilise les données locales, la découverte et les métadonnées sont désactivées",
+	"Add a TMDB API key in Settings": "Ajoute une clé API TMDB dans les paramètres",
+	"Authentication failed":          "Échec de l'authentification",
+	"Reachable":                      "Accessible",
+
+	// Errors
+	"Invalid credentials":                                  "Identifiants invalides",
+	"This account is disabled or has expired":              "Ce compte est désactivé ou a expiré",
+	"This device has been blocked":                         "Cet appareil a été bloqué",
+	"This account can only be used from the local network": "Ce compte ne peut être utilisé que depuis le réseau local",
+	"Admin access is not allowed from this network":        "L'accès administrateur n'est pas autorisé depuis ce réseau",
+	"Access is not allowed from your location":             "L'accès n'est pas autorisé depuis ta position",
+	"Guest accounts are read-only":                         "Les comptes invités sont en lecture seule",
+	"Content not available":                                "Contenu non disponible",
+	"Content restricted - PIN required":                    "Contenu restreint - code PIN requis",
+	"Monthly transfer cap reached":                         "Limite de transfert mensuelle atteinte",
+	"Already requested":                                    "Déjà demandé",
+	"Already in the library":                               "Déjà dans la médiathèque",
+	"Request quota exceeded (%d per %d days)":              "Quota de demandes dépassé (%d par %d jours)",
+	"Cannot delete processed request":                      "Impossible de supprimer une demande déjà traitée",
+	"Request portal is not enabled":                        "Le portail de demandes n'est pas activé",
+	"Request portal is not set up":                         "Le portail de demandes n'est pas configuré",
+	"Invalid passcode":                                     "Code d'accès invalide",
+	"Too many searches, try again in a minute":             "Trop de recherches, réessaie dans une minute",
+	"Too many wrong passcodes, try again later":            "Trop de codes d'accès erronés, réessaie plus tard",
+	"A name of up to %d characters is required":            "Un nom de %d caractères maximum est requis",
+	"Request limit reached (%d per hour), try again later": "Limite de demandes atteinte (%d par heure), réessaie plus tard",
+	"Title not found":                                      "Titre introuvable",
+	"Incorrect password":                                   "Mot de passe incorrect",
+	"The last admin account can't be deleted":              "Le dernier compte administrateur ne peut pas être supprimé",
+	"Accounts can't be deleted while impersonating":        "Impossible de supprimer un compte pendant une usurpation d'identité",
+	"Too many transcoded streams, try again later":         "Trop de flux sont en cours de transcodage, réessayez plus tard",
+	"Outpost is down for maintenance, try again later":     "Outpost est en maintenance, réessaie plus tard",
+	"Server busy, try again later":                         "Le serveur est surchargé, réessaie plus tard",
+}
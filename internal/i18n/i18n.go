@@ -0,0 +1,183 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server message localization
+//
+// Notifications, health messages and errors shown to end users are written
+// in English in the code, and the English text (a fmt format) is the key
+// into each language's catalog. Text missing from a catalog is shown in
+// English, so a partial translation never hides a message.
+//
+// A user's language is their own setting when they have one, otherwise the
+// server's language setting. Requests without a user use the browser's
+// Accept-Language header before the server's language.
+
+// Fallback is the language messages are written in
+const Fallback = "en"
+
+// Language is a language messages can be shown in
+type Language struct {
+	Code string `json:"code"` // ISO 639-1
+	Name string `json:"name"` // In the language itself
+}
+
+// languages lists the supported languages, English first
+var languages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "de", Name: "Deutsch"},
+	{Code: "es", Name: "Español"},
+	{Code: "fr", Name: "Français"},
+}
+
+// catalogs maps a language to translations of English formats
+var catalogs = map[string]map[string]string{
+	"de": catalogDE,
+	"es": catalogES,
+	"fr": catalogFR,
+}
+
+var (
+	mu         sync.RWMutex
+	serverLang = Fallback
+)
+
+// Languages returns the supported languages
+func Languages() []Language {
+	return append([]Language{}, languages...)
+}
+
+// Normalize returns the supported language for a code such as "de" or
+// "de-AT", or "" when it isn't supported
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	for _, l := range languages {
+		if l.Code == code {
+			return code
+		}
+	}
+	return ""
+}
+
+// SetDefault sets the server's language, used for users without their own.
+// Unsupported codes fall back to English.
+func SetDefault(code string) {
+	lang := Normalize(code)
+	if lang == "" {
+		lang = Fallback
+	}
+	mu.Lock()
+	serverLang = lang
+	mu.Unlock()
+}
+
+// Default returns the server's language
+func Default() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return serverLang
+}
+
+// Resolve returns a supported language for an optional preference, falling
+// back to the server's language
+func Resolve(preferred *string) string {
+	if preferred != nil {
+		if lang := Normalize(*preferred); lang != "" {
+			return lang
+		}
+	}
+	return Default()
+}
+
+// Match returns the best supported language of an Accept-Language header,
+// or "" when none is supported
+func Match(acceptLanguage string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := Normalize(fields[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{lang, q})
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].lang
+}
+
+// ValidateSetting checks the value of the language setting. Other settings
+// are always valid.
+func ValidateSetting(key, value string) error {
+	if key == "language" && Normalize(value) == "" {
+		return fmt.Errorf("Unsupported language: %s", value)
+	}
+	return nil
+}
+
+// T translates an English format into a language and formats it with args.
+// Message arguments are translated into the same language.
+func T(lang, format string, args ...interface{}) string {
+	if translated, ok := catalogs[lang][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	localized := make([]interface{}, len(args))
+	for i, arg := range args {
+		if m, ok := arg.(Message); ok {
+			localized[i] = m.String(lang)
+		} else {
+			localized[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, localized...)
+}
+
+// Message is text to show in its reader's language, kept as its English
+// format and arguments until the language is known
+type Message struct {
+	Format string
+	Args   []interface{}
+}
+
+// M makes a message from an English format and its arguments
+func M(format string, args ...interface{}) Message {
+	return Message{Format: format, Args: args}
+}
+
+// String formats the message in a language
+func (m Message) String(lang string) string {
+	return T(lang, m.Format, m.Args...)
+}
+
+// IsZero reports whether the message is empty
+func (m Message) IsZero() bool {
+	return m.Format == ""
+}
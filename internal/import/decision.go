@@ -6,9 +6,12 @@ import (
 	"strings"
 
 	"github.com/outpost/outpost/internal/download"
+	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/parser"
 )
 
+var logger = logging.Module("importer")
+
 // RejectionType indicates if a rejection is permanent or can be retried
 type RejectionType int
 
@@ -53,6 +56,14 @@ func (d *DecisionMaker) SetSampleThreshold(bytes int64) {
 
 // EvaluateFiles examines all files from a download and returns decisions
 func (d *DecisionMaker) EvaluateFiles(sourcePath string, td *download.TrackedDownload) ([]FileDecision, error) {
+	// Music and books are evaluated against their own formats
+	switch td.MediaType {
+	case "music":
+		return d.evaluateNonVideo(sourcePath, isAudioFile, "audio")
+	case "book":
+		return d.evaluateNonVideo(sourcePath, isBookFile, "book")
+	}
+
 	// Find all video files
 	files, err := findVideoFiles(sourcePath)
 	if err != nil {
@@ -72,6 +83,39 @@ func (d *DecisionMaker) EvaluateFiles(sourcePath string, td *download.TrackedDow
 	return decisions, nil
 }
 
+// evaluateNonVideo approves every file of the given kind. Tracks and books are
+// small, so the size-based sample check doesn't apply.
+func (d *DecisionMaker) evaluateNonVideo(sourcePath string, match func(string) bool, kind string) ([]FileDecision, error) {
+	files, err := findFiles(sourcePath, match)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, &ImportError{Message: "No " + kind + " files found in download"}
+	}
+
+	var decisions []FileDecision
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			decisions = append(decisions, FileDecision{
+				FilePath:   file,
+				Approved:   false,
+				Rejections: []Rejection{{Reason: "Cannot read file: " + err.Error(), Type: RejectionPermanent}},
+			})
+			continue
+		}
+		decisions = append(decisions, FileDecision{
+			FilePath: file,
+			FileSize: info.Size(),
+			Approved: true,
+		})
+	}
+
+	return decisions, nil
+}
+
 // evaluateFile runs all checks on a single file
 func (d *DecisionMaker) evaluateFile(filePath string, td *download.TrackedDownload) FileDecision {
 	info, err := os.Stat(filePath)
@@ -176,6 +220,17 @@ func (d *DecisionMaker) GetMainFile(decisions []FileDecision) *FileDecision {
 	return main
 }
 
+// GetApprovedFiles returns every approved file that isn't an extra
+func (d *DecisionMaker) GetApprovedFiles(decisions []FileDecision) []FileDecision {
+	var approved []FileDecision
+	for _, dec := range decisions {
+		if dec.Approved && !dec.IsExtra {
+			approved = append(approved, dec)
+		}
+	}
+	return approved
+}
+
 // GetExtras returns all approved extra files
 func (d *DecisionMaker) GetExtras(decisions []FileDecision) []FileDecision {
 	var extras []FileDecision
@@ -198,14 +253,19 @@ func (e *ImportError) Error() string {
 
 // findVideoFiles recursively finds all video files in a path
 func findVideoFiles(root string) ([]string, error) {
+	return findFiles(root, isVideoFile)
+}
+
+// findFiles recursively finds all files in a path accepted by match
+func findFiles(root string, match func(string) bool) ([]string, error) {
 	info, err := os.Stat(root)
 	if err != nil {
 		return nil, err
 	}
 
-	// If it's a file, check if it's a video
+	// If it's a file, check it directly
 	if !info.IsDir() {
-		if isVideoFile(root) {
+		if match(root) {
 			return []string{root}, nil
 		}
 		return nil, nil
@@ -217,7 +277,7 @@ func findVideoFiles(root string) ([]string, error) {
 		if err != nil {
 			return nil // Skip files we can't read
 		}
-		if !info.IsDir() && isVideoFile(path) {
+		if !info.IsDir() && match(path) {
 			files = append(files, path)
 		}
 		return nil
@@ -237,3 +297,21 @@ func isVideoFile(path string) bool {
 	}
 	return false
 }
+
+// isAudioFile checks if a file has an audio extension
+func isAudioFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3", ".flac", ".m4a", ".aac", ".ogg", ".wav", ".wma", ".opus", ".alac", ".ape":
+		return true
+	}
+	return false
+}
+
+// isBookFile checks if a file has an ebook or audiobook extension
+func isBookFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".epub", ".pdf", ".mobi", ".azw", ".azw3", ".cbz", ".cbr", ".m4b":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package importpkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Naming templates
+//
+// Templates name the folders and files media is imported into, from
+// placeholders like {Title} and {Season:00}. A number placeholder's zeros set
+// how many digits it's padded to. Placeholders without a value are dropped
+// along with the brackets and separators around them, so
+// "{Title} ({Year})" renders as just the title when the year isn't known.
+
+// NamingValues are the values naming template placeholders are filled from
+type NamingValues struct {
+	Title        string
+	Year         int
+	Season       int
+	Episode      int
+	EpisodeEnd   int // Last episode of a multi-episode file, 0 if it's a single episode
+	Absolute     int // Absolute episode number, for anime; 0 if unknown
+	EpisodeTitle string
+	AirDate      string
+	Resolution   string
+	Source       string
+	Codec        string
+}
+
+// NamingPlaceholders lists the placeholders naming templates understand
+var NamingPlaceholders = []string{
+	"{Title}", "{Year}", "{Season:00}", "{Episode:00}", "{Absolute:000}",
+	"{EpisodeTitle}", "{Air-Date}", "{Resolution}", "{Source}", "{Codec}",
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{([A-Za-z-]+)(?::(0+))?\}`)
+	invalidNameChars   = regexp.MustCompile(`[/\\:*?"<>|]`)
+	emptyBrackets      = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
+	repeatedSeparators = regexp.MustCompile(`\s+-(\s+-)+\s+`)
+	repeatedSpaces     = regexp.MustCompile(`\s{2,}`)
+)
+
+// RenderNaming fills in a naming template. Folders in the template are kept,
+// separated by "/". Placeholders it doesn't know are left as written and
+// returned, so they can be reported.
+func RenderNaming(template string, v NamingValues) (string, []string) {
+	var unknown []string
+	rendered := placeholderPattern.ReplaceAllStringFunc(template, func(match string) string {
+		m := placeholderPattern.FindStringSubmatch(match)
+		value, ok := v.placeholder(m[1], len(m[2]))
+		if !ok {
+			unknown = append(unknown, match)
+			return match
+		}
+		return value
+	})
+
+	segments := strings.Split(rendered, "/")
+	for i, segment := range segments {
+		segments[i] = tidyName(segment)
+	}
+	return strings.Join(segments, "/"), unknown
+}
+
+// SanitizeName removes characters that aren't allowed in file names
+func SanitizeName(s string) string {
+	return invalidNameChars.ReplaceAllString(s, "")
+}
+
+// placeholder returns the value of a placeholder, padding numbers to width
+// digits
+func (v NamingValues) placeholder(name string, width int) (string, bool) {
+	switch name {
+	case "Title":
+		return SanitizeName(v.Title), true
+	case "Year":
+		return number(v.Year, 0), true
+	case "Season":
+		// Season 0 holds the specials
+		return fmt.Sprintf("%0*d", width, v.Season), true
+	case "Episode":
+		episode := number(v.Episode, width)
+		if v.EpisodeEnd > v.Episode {
+			episode += "-" + number(v.EpisodeEnd, width)
+		}
+		return episode, true
+	case "Absolute":
+		return number(v.Absolute, width), true
+	case "EpisodeTitle":
+		return SanitizeName(v.EpisodeTitle), true
+	case "Air-Date":
+		return v.AirDate, true
+	case "Resolution":
+		return v.Resolution, true
+	case "Source":
+		return v.Source, true
+	case "Codec":
+		return v.Codec, true
+	}
+	return "", false
+}
+
+// number formats a positive number padded to width digits, or "" if it's
+// unknown
+func number(n, width int) string {
+	if n <= 0 {
+		return ""
+	}
+	s := strconv.Itoa(n)
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	return s
+}
+
+// tidyName cleans up a rendered folder or file name after empty
+// placeholders were dropped
+func tidyName(name string) string {
+	name = emptyBrackets.ReplaceAllString(name, "")
+	name = repeatedSeparators.ReplaceAllString(name, " - ")
+	name = repeatedSpaces.ReplaceAllString(name, " ")
+	return strings.Trim(name, " -.")
+}
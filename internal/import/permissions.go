@@ -0,0 +1,160 @@
+package importpkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// Settings for the permissions given to imported files. Each is left alone
+// when its setting is empty.
+const (
+	SettingFileMode   = "import_file_mode"   // Octal mode for imported files, like 664
+	SettingFolderMode = "import_folder_mode" // Octal mode for folders created on import, like 775
+	SettingOwner      = "import_owner"       // User name or UID to own imported files and folders
+	SettingGroup      = "import_group"       // Group name or GID to own imported files and folders
+)
+
+// Permissions are the mode and ownership given to imported files and the
+// folders created for them, so other programs sharing the library (Samba,
+// Plex) can read and manage them
+type Permissions struct {
+	FileMode   os.FileMode // 0 to leave as is
+	FolderMode os.FileMode // 0 to leave as is
+	UID        int         // -1 to leave as is
+	GID        int         // -1 to leave as is
+}
+
+// ValidatePermissionSetting checks the import permission settings, including
+// that the owner and group exist. Other settings are always valid.
+func ValidatePermissionSetting(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	var err error
+	switch key {
+	case SettingFileMode, SettingFolderMode:
+		_, err = parseMode(value)
+	case SettingOwner:
+		_, err = lookupUID(value)
+	case SettingGroup:
+		_, err = lookupGID(value)
+	}
+	return err
+}
+
+// LoadPermissions reads the import permissions from the settings
+func LoadPermissions(settings map[string]string) (*Permissions, error) {
+	p := &Permissions{UID: -1, GID: -1}
+	var err error
+	if v := settings[SettingFileMode]; v != "" {
+		if p.FileMode, err = parseMode(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := settings[SettingFolderMode]; v != "" {
+		if p.FolderMode, err = parseMode(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := settings[SettingOwner]; v != "" {
+		if p.UID, err = lookupUID(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := settings[SettingGroup]; v != "" {
+		if p.GID, err = lookupGID(v); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ApplyFile sets the mode and ownership of an imported file
+func (p *Permissions) ApplyFile(path string) error {
+	return p.apply(path, p.FileMode)
+}
+
+// MkdirAll creates a folder and any missing parents like os.MkdirAll. The
+// folders it creates are returned, and should be given the folder
+// permissions with ApplyFolder.
+func (p *Permissions) MkdirAll(path string) ([]string, error) {
+	var missing []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	// Outermost first, the order they were created in
+	created := make([]string, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+// ApplyFolder sets the mode and ownership of a folder created on import
+func (p *Permissions) ApplyFolder(path string) error {
+	return p.apply(path, p.FolderMode)
+}
+
+func (p *Permissions) apply(path string, mode os.FileMode) error {
+	var errs []error
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.UID >= 0 || p.GID >= 0 {
+		if err := os.Lchown(path, p.UID, p.GID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseMode parses an octal mode like 664 or 0775
+func parseMode(value string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || n == 0 || n > 0777 {
+		return 0, fmt.Errorf("Invalid mode %q: use octal permissions like 664 or 775", value)
+	}
+	return os.FileMode(n), nil
+}
+
+// lookupUID returns the UID of a user name or UID, checking the user exists
+func lookupUID(value string) (int, error) {
+	lookup := user.Lookup
+	if _, err := strconv.Atoi(value); err == nil {
+		lookup = user.LookupId
+	}
+	u, err := lookup(value)
+	if err != nil {
+		return -1, fmt.Errorf("Unknown user %q", value)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID returns the GID of a group name or GID, checking the group exists
+func lookupGID(value string) (int, error) {
+	lookup := user.LookupGroup
+	if _, err := strconv.Atoi(value); err == nil {
+		lookup = user.LookupGroupId
+	}
+	g, err := lookup(value)
+	if err != nil {
+		return -1, fmt.Errorf("Unknown group %q", value)
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -0,0 +1,195 @@
+package importpkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Staging
+//
+// With a staging folder set, the main video of a movie or episode import is
+// first moved there and checked before it goes into the library: its
+// container and video codec must be ones the format settings accept,
+// ffprobe must be able to read it, and, with virus scanning on, ClamAV must
+// find nothing. A file that fails stays in the staging folder, in
+// quarantine, and the release is blocklisted.
+
+// Staging settings
+const (
+	SettingStagingPath = "import_staging_path" // Folder imports are checked in first; empty to import directly
+	SettingVirusScan   = "import_virus_scan"   // "true" to scan staged files with ClamAV
+)
+
+// stagingTimeout bounds how long checking a staged file may take
+const stagingTimeout = 30 * time.Minute
+
+// Validation checks a staged file can fail
+const (
+	CheckContainer = "container"
+	CheckCodec     = "codec"
+	CheckProbe     = "probe"
+	CheckVirus     = "virus"
+)
+
+// ValidationError is a staged file failing a check, which a retry won't fix
+type ValidationError struct {
+	Check   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// StagingChecks are what a staged file is checked against
+type StagingChecks struct {
+	Containers  []string // Accepted extensions without the dot; empty accepts any
+	VideoCodecs []string // Accepted ffprobe video codec names; empty accepts any
+	VirusScan   bool
+}
+
+// ValidateStagingSetting checks the staging settings: the folder must be an
+// absolute path, and virus scanning needs ClamAV installed. Other settings
+// are always valid.
+func ValidateStagingSetting(key, value string) error {
+	switch key {
+	case SettingStagingPath:
+		if value != "" && !filepath.IsAbs(value) {
+			return fmt.Errorf("%s must be an absolute path", key)
+		}
+	case SettingVirusScan:
+		if value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		if value == "true" && clamAVCommand() == "" {
+			return fmt.Errorf("ClamAV (clamdscan or clamscan) isn't installed")
+		}
+	}
+	return nil
+}
+
+// ValidateStagedFile checks a staged file, returning a *ValidationError if
+// the file fails a check, or another error if it couldn't be checked
+func ValidateStagedFile(path string, checks StagingChecks) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stagingTimeout)
+	defer cancel()
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if len(checks.Containers) > 0 && !containsFold(checks.Containers, ext) {
+		return &ValidationError{Check: CheckContainer, Message: fmt.Sprintf("Container %s isn't accepted", ext)}
+	}
+
+	codec, err := probeVideo(ctx, path)
+	if err != nil {
+		return err
+	}
+	if len(checks.VideoCodecs) > 0 && !containsFold(checks.VideoCodecs, codec) {
+		return &ValidationError{Check: CheckCodec, Message: fmt.Sprintf("Video codec %s isn't accepted", codec)}
+	}
+
+	if checks.VirusScan {
+		return scanForViruses(ctx, path)
+	}
+	return nil
+}
+
+// probeVideo reads a file with ffprobe, returning the codec of its video
+func probeVideo(ctx context.Context, path string) (string, error) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return "", fmt.Errorf("ffprobe isn't installed")
+	}
+	output, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_entries", "format=duration:stream=codec_type,codec_name",
+		path,
+	).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", &ValidationError{Check: CheckProbe, Message: "ffprobe couldn't read the file, it may be corrupt"}
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return "", &ValidationError{Check: CheckProbe, Message: "ffprobe couldn't read the file, it may be corrupt"}
+	}
+	if duration, _ := strconv.ParseFloat(probe.Format.Duration, 64); duration <= 0 {
+		return "", &ValidationError{Check: CheckProbe, Message: "The file has no duration, it may be truncated"}
+	}
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "video" {
+			return stream.CodecName, nil
+		}
+	}
+	return "", &ValidationError{Check: CheckProbe, Message: "The file has no video"}
+}
+
+// clamAVCommand returns the ClamAV scanner to use: the daemon's client,
+// which is much faster, or the standalone scanner. Empty if neither is
+// installed.
+func clamAVCommand() string {
+	for _, name := range []string{"clamdscan", "clamscan"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// scanForViruses scans a file with ClamAV
+func scanForViruses(ctx context.Context, path string) error {
+	command := clamAVCommand()
+	if command == "" {
+		return fmt.Errorf("virus scanning is on but ClamAV isn't installed")
+	}
+	args := []string{"--no-summary", "--infected"}
+	if command == "clamdscan" {
+		// The daemon may not be able to open files the server can
+		args = append(args, "--fdpass")
+	}
+	output, err := exec.CommandContext(ctx, command, append(args, path)...).Output()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Exit status 1 means something was found, anything else that the scan
+	// didn't work
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		found := strings.TrimSpace(string(output))
+		if i := strings.LastIndex(found, ": "); i >= 0 {
+			found = found[i+2:]
+		}
+		return &ValidationError{Check: CheckVirus, Message: "Virus scan found " + strings.TrimSuffix(found, " FOUND")}
+	}
+	return fmt.Errorf("virus scan failed: %w", err)
+}
+
+// containsFold reports whether a list holds a value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
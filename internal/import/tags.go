@@ -0,0 +1,278 @@
+package importpkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// AudioTags holds the metadata read from an audio file
+type AudioTags struct {
+	Title       string
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Track       int
+	Disc        int
+	Year        int
+}
+
+// maxTagSize caps how much of a file is read looking for tags
+const maxTagSize = 16 * 1024 * 1024
+
+// ReadAudioTags reads ID3v2 (MP3) or Vorbis comment (FLAC) tags from a file.
+// Returns nil if the file has no tags Outpost can read.
+func ReadAudioTags(path string) (*AudioTags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return nil, nil
+	}
+
+	switch {
+	case string(magic[:3]) == "ID3":
+		return readID3v2(f)
+	case string(magic) == "fLaC":
+		return readFLAC(f)
+	default:
+		return nil, nil
+	}
+}
+
+// readID3v2 parses an ID3v2.2/2.3/2.4 tag at the start of the file
+func readID3v2(f *os.File) (*AudioTags, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, nil
+	}
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if size <= 0 || size > maxTagSize {
+		return nil, nil
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, nil
+	}
+
+	// Skip the extended header
+	pos := 0
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		if version == 4 {
+			pos = syncsafe(data[0:4])
+		} else {
+			pos = int(binary.BigEndian.Uint32(data[0:4])) + 4
+		}
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	tags := &AudioTags{}
+	for pos+headerLen <= len(data) {
+		id := string(data[pos : pos+idLen])
+		if id[0] == 0 {
+			break // Padding
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(data[pos+3])<<16 | int(data[pos+4])<<8 | int(data[pos+5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		default:
+			frameSize = syncsafe(data[pos+4 : pos+8])
+		}
+		pos += headerLen
+		if frameSize <= 0 || pos+frameSize > len(data) {
+			break
+		}
+		frame := data[pos : pos+frameSize]
+		pos += frameSize
+
+		if !strings.HasPrefix(id, "T") {
+			continue
+		}
+		value := decodeID3Text(frame)
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = value
+		case "TPE1", "TP1":
+			tags.Artist = value
+		case "TPE2", "TP2":
+			tags.AlbumArtist = value
+		case "TALB", "TAL":
+			tags.Album = value
+		case "TRCK", "TRK":
+			tags.Track = leadingNumber(value)
+		case "TPOS", "TPA":
+			tags.Disc = leadingNumber(value)
+		case "TDRC", "TYER", "TYE":
+			tags.Year = leadingNumber(value)
+		}
+	}
+
+	return tags, nil
+}
+
+// decodeID3Text decodes a text frame body (encoding byte followed by text)
+func decodeID3Text(frame []byte) string {
+	if len(frame) < 2 {
+		return ""
+	}
+	body := frame[1:]
+	var s string
+	switch frame[0] {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		bigEndian := frame[0] == 2
+		if len(body) >= 2 {
+			if body[0] == 0xFF && body[1] == 0xFE {
+				bigEndian, body = false, body[2:]
+			} else if body[0] == 0xFE && body[1] == 0xFF {
+				bigEndian, body = true, body[2:]
+			}
+		}
+		units := make([]uint16, 0, len(body)/2)
+		for i := 0; i+1 < len(body); i += 2 {
+			if bigEndian {
+				units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+			} else {
+				units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+			}
+		}
+		s = string(utf16.Decode(units))
+	case 3: // UTF-8
+		s = string(body)
+	default: // ISO-8859-1
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	}
+	// Multiple values are null-separated; keep the first
+	if i := strings.IndexRune(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// readFLAC parses the Vorbis comment block of a FLAC file. The reader is
+// positioned after the "fLaC" marker.
+func readFLAC(f *os.File) (*AudioTags, error) {
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			return nil, nil
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		if blockType == 4 {
+			if length > maxTagSize {
+				return nil, nil
+			}
+			data := make([]byte, length)
+			if _, err := io.ReadFull(f, data); err != nil {
+				return nil, nil
+			}
+			return parseVorbisComments(data), nil
+		}
+		if last {
+			return nil, nil
+		}
+		if _, err := f.Seek(int64(length), io.SeekCurrent); err != nil {
+			return nil, nil
+		}
+	}
+}
+
+// parseVorbisComments parses a little-endian Vorbis comment block
+func parseVorbisComments(data []byte) *AudioTags {
+	r := bytes.NewReader(data)
+	readString := func() (string, bool) {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil || int(n) > r.Len() {
+			return "", false
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", false
+		}
+		return string(buf), true
+	}
+
+	tags := &AudioTags{}
+	if _, ok := readString(); !ok { // Vendor string
+		return tags
+	}
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return tags
+	}
+	for i := uint32(0); i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			break
+		}
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			if tags.Artist == "" {
+				tags.Artist = value
+			}
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			tags.AlbumArtist = value
+		case "ALBUM":
+			tags.Album = value
+		case "TRACKNUMBER":
+			tags.Track = leadingNumber(value)
+		case "DISCNUMBER":
+			tags.Disc = leadingNumber(value)
+		case "DATE", "YEAR":
+			if tags.Year == 0 {
+				tags.Year = leadingNumber(value)
+			}
+		}
+	}
+	return tags
+}
+
+// syncsafe decodes a 28-bit ID3 syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// leadingNumber parses the number at the start of values like "3/12" or "1999-05-01"
+func leadingNumber(value string) int {
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(value[:end])
+	return n
+}
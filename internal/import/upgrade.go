@@ -1,7 +1,6 @@
 package importpkg
 
 import (
-	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -97,7 +96,7 @@ func (u *UpgradeChecker) ShouldUpgrade(existing, new *parser.ParsedRelease) Upgr
 // HandleOldFile removes or recycles the old file after successful upgrade
 func (u *UpgradeChecker) HandleOldFile(oldPath string) error {
 	if u.keepOldFiles {
-		log.Printf("Keeping old file (keepOldFiles=true): %s", oldPath)
+		logger.Infof("Keeping old file (keepOldFiles=true): %s", oldPath)
 		return nil
 	}
 
@@ -121,7 +120,7 @@ func (u *UpgradeChecker) moveToRecycleBin(oldPath string) error {
 
 	dest := filepath.Join(u.recycleBinPath, newName)
 
-	log.Printf("Moving to recycle bin: %s -> %s", oldPath, dest)
+	logger.Infof("Moving to recycle bin: %s -> %s", oldPath, dest)
 	return os.Rename(oldPath, dest)
 }
 
@@ -136,11 +135,11 @@ func (u *UpgradeChecker) deleteFile(path string) error {
 	}
 
 	if info.IsDir() {
-		log.Printf("Deleting old directory: %s", path)
+		logger.Infof("Deleting old directory: %s", path)
 		return os.RemoveAll(path)
 	}
 
-	log.Printf("Deleting old file: %s", path)
+	logger.Infof("Deleting old file: %s", path)
 	return os.Remove(path)
 }
 
@@ -168,7 +167,7 @@ func (u *UpgradeChecker) CleanRecycleBin(maxAge time.Duration) error {
 
 		if info.ModTime().Before(cutoff) {
 			path := filepath.Join(u.recycleBinPath, entry.Name())
-			log.Printf("Cleaning old recycle bin item: %s", path)
+			logger.Infof("Cleaning old recycle bin item: %s", path)
 			if entry.IsDir() {
 				os.RemoveAll(path)
 			} else {
@@ -2,7 +2,6 @@ package importer
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -11,9 +10,13 @@ import (
 	"time"
 
 	"github.com/outpost/outpost/internal/database"
+	importpkg "github.com/outpost/outpost/internal/import"
+	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/parser"
 )
 
+var logger = logging.Module("importer")
+
 // Manager handles file imports and organization
 type Manager struct {
 	db       *database.Database
@@ -272,27 +275,20 @@ func (m *Manager) generateDestPath(mediaID int64, mediaType string, parsed *pars
 
 // applyTemplate applies naming template placeholders
 func (m *Manager) applyTemplate(template string, parsed *parser.ParsedRelease, mediaType string) string {
-	result := template
-
-	// Common replacements
-	result = strings.ReplaceAll(result, "{Title}", sanitizeFilename(parsed.Title))
-	result = strings.ReplaceAll(result, "{Year}", strconv.Itoa(parsed.Year))
-
-	// TV-specific
-	result = strings.ReplaceAll(result, "{Season:00}", fmt.Sprintf("%02d", parsed.Season))
-	result = strings.ReplaceAll(result, "{Episode:00}", fmt.Sprintf("%02d", parsed.Episode))
-	result = strings.ReplaceAll(result, "{EpisodeTitle}", sanitizeFilename(parsed.EpisodeTitle))
-
-	// Quality info
-	result = strings.ReplaceAll(result, "{Resolution}", parsed.Resolution)
-	result = strings.ReplaceAll(result, "{Source}", parsed.Source)
-	result = strings.ReplaceAll(result, "{Codec}", parsed.Codec)
-
-	// Daily shows
-	if parsed.IsDailyShow && parsed.AirDate != "" {
-		result = strings.ReplaceAll(result, "{Air-Date}", parsed.AirDate)
+	values := importpkg.NamingValues{
+		Title:        parsed.Title,
+		Year:         parsed.Year,
+		Season:       parsed.Season,
+		Episode:      parsed.Episode,
+		EpisodeTitle: parsed.EpisodeTitle,
+		Resolution:   parsed.Resolution,
+		Source:       parsed.Source,
+		Codec:        parsed.Codec,
 	}
-
+	if parsed.IsDailyShow {
+		values.AirDate = parsed.AirDate
+	}
+	result, _ := importpkg.RenderNaming(template, values)
 	return result
 }
 
@@ -407,7 +403,7 @@ func (m *Manager) failImport(download *database.Download, err error) error {
 		Error:      &errMsg,
 	})
 
-	log.Printf("Import failed for %s: %s", download.Title, err.Error())
+	logger.Errorf("Import failed for %s: %s", download.Title, err.Error())
 	return err
 }
 
@@ -486,7 +482,7 @@ func (t *DownloadTracker) checkDownloads() {
 	// Get downloads that are completed but not imported
 	downloads, err := t.db.GetDownloads()
 	if err != nil {
-		log.Printf("Failed to get downloads: %v", err)
+		logger.Errorf("Failed to get downloads: %v", err)
 		return
 	}
 
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+
+	"github.com/outpost/outpost/internal/logging"
 )
 
+var logger = logging.Module("indexer")
+
 // SearchResult represents a search result from an indexer
 type SearchResult struct {
 	IndexerID   int64  `json:"indexerId"`
@@ -24,6 +28,9 @@ type SearchResult struct {
 	ImdbID      string `json:"imdbId,omitempty"`
 	TvdbID      string `json:"tvdbId,omitempty"`
 	InfoURL     string `json:"infoUrl,omitempty"`
+	// QueryStrategy is the strategy of the wanted search query that found
+	// the release, one of the Query values
+	QueryStrategy string `json:"queryStrategy,omitempty"`
 }
 
 // ScoredSearchResult extends SearchResult with quality scoring info
@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/outpost/outpost/internal/chaos"
 )
 
 // NewznabClient implements the Newznab API for usenet indexers
@@ -24,7 +26,8 @@ func NewNewznabClient(baseURL, apiKey string) *NewznabClient {
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetIndexer),
 		},
 	}
 }
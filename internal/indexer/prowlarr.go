@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/outpost/outpost/internal/chaos"
 )
 
 // ProwlarrClient implements the Prowlarr API
@@ -25,7 +26,8 @@ func NewProwlarrClient(baseURL, apiKey string) *ProwlarrClient {
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second, // Prowlarr can take longer as it queries multiple indexers
+			Timeout:   60 * time.Second, // Prowlarr can take longer as it queries multiple indexers
+			Transport: chaos.Transport(chaos.TargetIndexer),
 		},
 	}
 }
@@ -144,8 +146,8 @@ func (c *ProwlarrClient) Search(params SearchParams) ([]SearchResult, error) {
 	u.RawQuery = q.Encode()
 
 	// DEBUG: Log the full search URL being sent to Prowlarr
-	log.Printf("DEBUG Prowlarr Search URL: %s", u.String())
-	log.Printf("DEBUG Search params: query=%q, type=%s, imdbId=%s, tmdbId=%s, tvdbId=%s, categories=%v",
+	logger.Debugf("Prowlarr Search URL: %s", u.String())
+	logger.Debugf("Search params: query=%q, type=%s, imdbId=%s, tmdbId=%s, tvdbId=%s, categories=%v",
 		params.Query, searchType, params.ImdbID, params.TmdbID, params.TvdbID, params.Categories)
 
 	req, err := http.NewRequest("GET", u.String(), nil)
@@ -176,14 +178,14 @@ func (c *ProwlarrClient) Search(params SearchParams) ([]SearchResult, error) {
 	}
 
 	// DEBUG: Log raw results count and first few results
-	log.Printf("DEBUG Prowlarr returned %d raw results", len(searchResults))
+	logger.Debugf("Prowlarr returned %d raw results", len(searchResults))
 	for i, r := range searchResults {
 		if i < 10 { // Only log first 10
 			catIDs := make([]int, len(r.Categories))
 			for j, c := range r.Categories {
 				catIDs[j] = c.ID
 			}
-			log.Printf("DEBUG Result[%d]: title=%q, indexer=%s, categories=%v, imdbId=%d",
+			logger.Debugf("Result[%d]: title=%q, indexer=%s, categories=%v, imdbId=%d",
 				i, r.Title, r.Indexer, catIDs, r.ImdbID)
 		}
 	}
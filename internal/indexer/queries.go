@@ -0,0 +1,205 @@
+package indexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Search query fallbacks
+//
+// Wanted searches use the title as Outpost knows it, which misses releases
+// named after the original title, another title the media is known by, or
+// with the title's punctuation left out. When a search finds too few
+// matching releases, fallback queries are tried in the configured order
+// until enough are found. Each result keeps the strategy of the query that
+// found it.
+
+// Settings for search query fallbacks
+const (
+	SettingQueryFallbacks     = "search_query_fallbacks"      // Comma-separated strategies, in the order they're tried
+	SettingFallbackMinResults = "search_fallback_min_results" // Matching releases that make a search good enough
+)
+
+// Query strategies
+const (
+	QueryExact         = "exact"                // The title, with the media's IDs
+	QueryOriginalTitle = "original_title"       // The title in its original language
+	QueryAlternate     = "alternate_title"      // Other titles, like a different country's
+	QueryStripped      = "stripped_punctuation" // The title without punctuation or accents
+	QueryYearTolerance = "year_tolerance"       // The title with the year either side of a movie's
+	QueryRomanized     = "romanized_title"      // Latin-script titles of media whose original title isn't
+)
+
+// DefaultQueryFallbacks is the fallback order used until it's configured
+const DefaultQueryFallbacks = "original_title,alternate_title,stripped_punctuation,year_tolerance,romanized_title"
+
+// maxAlternateQueries bounds the queries each title list strategy adds, as
+// media can have dozens of alternate titles
+const maxAlternateQueries = 3
+
+var fallbackStrategies = map[string]bool{
+	QueryOriginalTitle: true,
+	QueryAlternate:     true,
+	QueryStripped:      true,
+	QueryYearTolerance: true,
+	QueryRomanized:     true,
+}
+
+// Query is a search query and the strategy that made it
+type Query struct {
+	Strategy string `json:"strategy"`
+	Query    string `json:"query"`
+}
+
+// QueryTitles are the titles and year a search can build queries from
+type QueryTitles struct {
+	Title      string
+	Year       int
+	Movie      bool // Releases of movies carry their year, so it's worth searching for
+	Original   string
+	Alternates []string
+}
+
+// ParseQueryFallbacks parses the fallback strategies setting. Empty turns
+// fallbacks off.
+func ParseQueryFallbacks(value string) ([]string, error) {
+	var strategies []string
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !fallbackStrategies[s] {
+			return nil, fmt.Errorf("Unknown search fallback %q", s)
+		}
+		strategies = append(strategies, s)
+	}
+	return strategies, nil
+}
+
+// ValidateQuerySetting checks the search query fallback settings. Other
+// settings are always valid.
+func ValidateQuerySetting(key, value string) error {
+	switch key {
+	case SettingQueryFallbacks:
+		_, err := ParseQueryFallbacks(value)
+		return err
+	case SettingFallbackMinResults:
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("Minimum search results must be a whole number of at least 1")
+		}
+	}
+	return nil
+}
+
+// FallbackQueries builds the queries for each strategy, in order. Queries
+// that repeat the title or an earlier query are left out.
+func FallbackQueries(titles QueryTitles, strategies []string) []Query {
+	seen := map[string]bool{queryKey(titles.Title): true}
+	var queries []Query
+	add := func(strategy, query string) {
+		query = strings.TrimSpace(query)
+		if key := queryKey(query); query != "" && !seen[key] {
+			seen[key] = true
+			queries = append(queries, Query{Strategy: strategy, Query: query})
+		}
+	}
+
+	// A Latin-script title for media with a title in another script is
+	// usually a romanization, kept for its own strategy
+	foreign := titles.Original != "" && !isLatin(titles.Original)
+	var alternates, romanized []string
+	for _, alt := range titles.Alternates {
+		switch {
+		case !isLatin(alt):
+			// Indexers name releases in Latin script
+		case foreign:
+			romanized = append(romanized, alt)
+		default:
+			alternates = append(alternates, alt)
+		}
+	}
+
+	for _, strategy := range strategies {
+		switch strategy {
+		case QueryOriginalTitle:
+			add(strategy, titles.Original)
+		case QueryAlternate:
+			addTitles(add, strategy, alternates)
+		case QueryStripped:
+			add(strategy, StripPunctuation(titles.Title))
+			if !foreign {
+				add(strategy, StripPunctuation(titles.Original))
+			}
+		case QueryYearTolerance:
+			if titles.Movie && titles.Year > 0 {
+				add(strategy, fmt.Sprintf("%s %d", titles.Title, titles.Year-1))
+				add(strategy, fmt.Sprintf("%s %d", titles.Title, titles.Year+1))
+			}
+		case QueryRomanized:
+			addTitles(add, strategy, romanized)
+		}
+	}
+	return queries
+}
+
+// addTitles adds up to maxAlternateQueries titles as queries
+func addTitles(add func(strategy, query string), strategy string, titles []string) {
+	for i, title := range titles {
+		if i == maxAlternateQueries {
+			break
+		}
+		add(strategy, title)
+	}
+}
+
+// accents folds accented Latin letters to their plain forms
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+	"À", "A", "Á", "A", "Â", "A", "Ã", "A", "Ä", "A", "Å", "A", "Æ", "AE",
+	"Ç", "C", "È", "E", "É", "E", "Ê", "E", "Ë", "E",
+	"Ì", "I", "Í", "I", "Î", "I", "Ï", "I", "Ñ", "N",
+	"Ò", "O", "Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O", "Ø", "O", "Œ", "OE",
+	"Ù", "U", "Ú", "U", "Û", "U", "Ü", "U", "Ý", "Y",
+)
+
+// StripPunctuation reduces a title to plain letters, digits and spaces the
+// way release names usually spell it: "Marvel's Agents of S.H.I.E.L.D." is
+// searched as "Marvels Agents of SHIELD" and "Amélie" as "Amelie"
+func StripPunctuation(title string) string {
+	var b strings.Builder
+	for _, r := range accents.Replace(title) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’' || r == '.':
+			// Dropped without a gap, so contractions and acronyms stay whole
+		case r == '&':
+			b.WriteString(" and ")
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// isLatin reports whether every letter of s is in the Latin script
+func isLatin(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryKey is what makes two queries the same search
+func queryKey(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
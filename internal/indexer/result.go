@@ -0,0 +1,37 @@
+package indexer
+
+import (
+	"strings"
+	"time"
+)
+
+// publishDateFormats are the date formats indexers use for publish dates:
+// RFC 1123 from Torznab/Newznab feeds and ISO 8601 from Prowlarr
+var publishDateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// IsTorrent reports whether the result is a torrent rather than a usenet NZB.
+// Prowlarr results are typed by protocol when they're converted.
+func (r *SearchResult) IsTorrent() bool {
+	return r.IndexerType == "torznab" || r.IndexerType == "prowlarr" || r.MagnetLink != ""
+}
+
+// PublishTime returns when the indexer published the release, or the zero
+// time if the date is missing or unparseable
+func (r *SearchResult) PublishTime() time.Time {
+	date := strings.TrimSpace(r.PublishDate)
+	if date == "" {
+		return time.Time{}
+	}
+	for _, format := range publishDateFormats {
+		if t, err := time.Parse(format, date); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
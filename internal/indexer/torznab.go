@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/outpost/outpost/internal/chaos"
 )
 
 // TorznabClient implements the Torznab API for torrent indexers
@@ -24,7 +26,8 @@ func NewTorznabClient(baseURL, apiKey string) *TorznabClient {
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: chaos.Transport(chaos.TargetIndexer),
 		},
 	}
 }
@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Background jobs
+//
+// Long-running work like library scans and metadata refreshes runs as a job
+// rather than a bare goroutine. Each job is recorded in the database with its
+// progress and outcome, can be cancelled, and waits its turn when too many
+// jobs of its type are already running.
+
+var logger = logging.Module("jobs")
+
+// defaultLimit is how many jobs of a type run at once unless set otherwise
+const defaultLimit = 2
+
+// retentionDays is how long finished jobs are kept
+const retentionDays = 30
+
+var (
+	// ErrNotFound is returned for jobs that don't exist
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that has already finished
+	ErrFinished = errors.New("job has already finished")
+)
+
+// Func does a job's work, reporting its progress. It should stop soon after
+// ctx is cancelled.
+type Func func(ctx context.Context, p *Progress) error
+
+// Manager runs background jobs
+type Manager struct {
+	db *database.Database
+
+	mu      sync.Mutex
+	active  map[int64]*activeJob     // Queued and running jobs
+	limits  map[string]int           // Jobs of a type that run at once
+	slots   map[string]chan struct{} // Run slots per type
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type activeJob struct {
+	job    database.Job // Guarded by the manager's mutex
+	cancel context.CancelFunc
+}
+
+// New creates a job manager. Jobs left queued or running by the last run of
+// the server are marked as failed, and old finished jobs are forgotten.
+func New(db *database.Database) *Manager {
+	if n, err := db.InterruptJobs(); err != nil {
+		logger.Errorf("Failed to mark interrupted jobs: %v", err)
+	} else if n > 0 {
+		logger.Warnf("Marked %d jobs interrupted by the last shutdown as failed", n)
+	}
+	if _, err := db.DeleteJobsBefore(retentionDays); err != nil {
+		logger.Errorf("Failed to remove old jobs: %v", err)
+	}
+	return &Manager{
+		db:     db,
+		active: make(map[int64]*activeJob),
+		limits: make(map[string]int),
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// SetLimit sets how many jobs of a type run at once. Set limits before
+// submitting jobs of the type.
+func (m *Manager) SetLimit(jobType string, n int) {
+	if n < 1 {
+		n = 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits[jobType] = n
+}
+
+// slot returns the run slots of a job type
+func (m *Manager) slot(jobType string) chan struct{} {
+	if ch, ok := m.slots[jobType]; ok {
+		return ch
+	}
+	n := m.limits[jobType]
+	if n == 0 {
+		n = defaultLimit
+	}
+	ch := make(chan struct{}, n)
+	m.slots[jobType] = ch
+	return ch
+}
+
+// Submit queues a job, running it once a slot for its type is free
+func (m *Manager) Submit(jobType, title string, createdBy *int64, fn Func) (*database.Job, error) {
+	job := &database.Job{Type: jobType, Title: title, CreatedBy: createdBy}
+	if err := m.db.CreateJob(job); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		cancel()
+		return nil, errors.New("job manager is stopped")
+	}
+	active := &activeJob{job: *job, cancel: cancel}
+	m.active[job.ID] = active
+	slot := m.slot(jobType)
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go m.run(ctx, active, slot, fn)
+	return job, nil
+}
+
+// run waits for a slot and runs a job
+func (m *Manager) run(ctx context.Context, active *activeJob, slot chan struct{}, fn Func) {
+	defer m.wg.Done()
+	id := active.job.ID
+
+	select {
+	case slot <- struct{}{}:
+		defer func() { <-slot }()
+	case <-ctx.Done():
+		m.finish(active, ctx.Err())
+		return
+	}
+
+	m.mu.Lock()
+	active.job.Status = database.JobRunning
+	title := active.job.Title
+	m.mu.Unlock()
+	if err := m.db.StartJob(id); err != nil {
+		logger.Errorf("Failed to mark job %d running: %v", id, err)
+	}
+	logger.Infof("Started job %d: %s", id, title)
+
+	err := m.safeRun(ctx, fn, &Progress{m: m, active: active})
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	m.finish(active, err)
+}
+
+// safeRun runs a job's function, turning a panic into an error so one bad
+// job doesn't take the server down
+func (m *Manager) safeRun(ctx context.Context, fn Func, p *Progress) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Job %d panicked: %v", p.active.job.ID, r)
+			err = errors.New("the job crashed")
+		}
+	}()
+	return fn(ctx, p)
+}
+
+// finish records how a job ended and stops tracking it
+func (m *Manager) finish(active *activeJob, err error) {
+	m.mu.Lock()
+	job := &active.job
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = database.JobCancelled
+	case err != nil:
+		job.Status = database.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = database.JobCompleted
+		if job.Total > 0 {
+			job.Current = job.Total
+		}
+		job.Progress = 100
+	}
+	finished := *job
+	delete(m.active, job.ID)
+	m.mu.Unlock()
+	active.cancel()
+
+	if err := m.db.FinishJob(&finished); err != nil {
+		logger.Errorf("Failed to record the end of job %d: %v", finished.ID, err)
+	}
+	if finished.Status == database.JobFailed {
+		logger.Errorf("Job %d failed: %s: %s", finished.ID, finished.Title, finished.Error)
+	} else {
+		logger.Infof("Job %d %s: %s", finished.ID, finished.Status, finished.Title)
+	}
+}
+
+// Get returns a job, with its live progress if it's running
+func (m *Manager) Get(id int64) (*database.Job, error) {
+	job, err := m.db.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	m.overlay(job)
+	return job, nil
+}
+
+// List returns the jobs matching a filter, newest first
+func (m *Manager) List(filter database.JobFilter) ([]database.Job, error) {
+	jobs, err := m.db.GetJobs(filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		m.overlay(&jobs[i])
+	}
+	return jobs, nil
+}
+
+// overlay copies the live state of an active job over its stored record,
+// which only catches up every percent
+func (m *Manager) overlay(job *database.Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if active, ok := m.active[job.ID]; ok {
+		live := active.job
+		job.Status, job.Progress, job.Current, job.Total, job.Message = live.Status, live.Progress, live.Current, live.Total, live.Message
+	}
+}
+
+// Cancel stops a queued or running job
+func (m *Manager) Cancel(id int64) error {
+	m.mu.Lock()
+	active, ok := m.active[id]
+	m.mu.Unlock()
+	if ok {
+		active.cancel()
+		return nil
+	}
+
+	job, err := m.db.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return ErrNotFound
+	}
+	return ErrFinished
+}
+
+// Stop cancels every job and waits for them to end, for shutdown
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	m.stopped = true
+	for _, active := range m.active {
+		active.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+// Progress reports how far a job has got
+type Progress struct {
+	m      *Manager
+	active *activeJob
+}
+
+// SetTotal sets how many steps the job has
+func (p *Progress) SetTotal(total int) {
+	p.update(func(j *database.Job) { j.Total = total })
+}
+
+// Step records that another step is done
+func (p *Progress) Step() {
+	p.update(func(j *database.Job) { j.Current++ })
+}
+
+// Set records how many of how many steps are done
+func (p *Progress) Set(current, total int) {
+	p.update(func(j *database.Job) { j.Current, j.Total = current, total })
+}
+
+// SetMessage describes what the job is doing
+func (p *Progress) SetMessage(message string) {
+	p.update(func(j *database.Job) { j.Message = message })
+}
+
+// SetResult records a summary of what the job did, kept as JSON
+func (p *Progress) SetResult(result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	p.m.mu.Lock()
+	p.active.job.Result = string(data)
+	p.m.mu.Unlock()
+}
+
+// update changes the job's progress, storing it when the percent or message
+// changes so the record doesn't lag far behind
+func (p *Progress) update(change func(*database.Job)) {
+	p.m.mu.Lock()
+	job := &p.active.job
+	before, message := job.Progress, job.Message
+	change(job)
+	if job.Total > 0 {
+		job.Progress = job.Current * 100 / job.Total
+		if job.Progress > 100 {
+			job.Progress = 100
+		}
+	}
+	changed := job.Progress != before || job.Message != message
+	snapshot := *job
+	p.m.mu.Unlock()
+
+	if changed {
+		if err := p.m.db.UpdateJobProgress(snapshot.ID, snapshot.Progress, snapshot.Current, snapshot.Total, snapshot.Message); err != nil {
+			logger.Errorf("Failed to record progress of job %d: %v", snapshot.ID, err)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Structured logging
+//
+// Each package logs through its own module logger, which writes records with
+// slog as text or JSON and keeps them in the ring buffer behind /api/logs.
+// Every module logs at the default level unless it's given its own, and both
+// can be changed while the server runs. Records logged while handling a
+// request carry its request ID. Anything written with the standard log
+// package goes through the same handler under the "app" module.
+
+// Logger logs printf-style messages for one module
+type Logger struct {
+	logger *slog.Logger
+	ctx    context.Context
+}
+
+// Module returns the logger for a module
+func Module(name string) *Logger {
+	configMu.Lock()
+	modules[name] = true
+	configMu.Unlock()
+	return &Logger{logger: slog.New(&handler{module: name})}
+}
+
+// Ctx returns a logger whose records carry the context's request ID
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	return &Logger{logger: l.logger, ctx: ctx}
+}
+
+// Debugf logs at debug level
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, format, args...)
+}
+
+// Infof logs at info level
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+// Warnf logs at warning level
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(slog.LevelWarn, format, args...)
+}
+
+// Errorf logs at error level
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+// Fatalf logs at error level and exits
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, format, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) logf(level slog.Level, format string, args ...interface{}) {
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Skip formatting what won't be written
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+// Request IDs
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID a context carries, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Configuration
+
+// Config is the logging configuration that can be changed at runtime
+type Config struct {
+	Level   string            `json:"level"`   // Default level: debug, info, warn or error
+	Format  string            `json:"format"`  // text or json
+	Modules map[string]string `json:"modules"` // Levels of modules that don't use the default
+}
+
+var (
+	configMu     sync.RWMutex
+	defaultLevel = slog.LevelInfo
+	moduleLevels = map[string]slog.Level{}
+	modules      = map[string]bool{"app": true}
+	jsonOutput   bool
+
+	// output writes records to stderr in the configured format
+	output slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+)
+
+// ParseLevel parses a level name
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// levelName is a level as it's shown in the config
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// Configure applies a logging configuration. Modules with an empty level go
+// back to the default.
+func Configure(cfg Config) error {
+	level, levels, err := cfg.parse()
+	if err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	defaultLevel = level
+	moduleLevels = levels
+	if cfg.Format != "" {
+		setOutput(os.Stderr, cfg.Format == "json")
+	}
+	return nil
+}
+
+// parse checks a configuration and returns its default and module levels
+func (cfg Config) parse() (slog.Level, map[string]slog.Level, error) {
+	level := slog.LevelInfo
+	if cfg.Level != "" {
+		var err error
+		if level, err = ParseLevel(cfg.Level); err != nil {
+			return 0, nil, err
+		}
+	}
+	levels := make(map[string]slog.Level)
+	for module, name := range cfg.Modules {
+		if name == "" {
+			continue
+		}
+		moduleLevel, err := ParseLevel(name)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%s: %w", module, err)
+		}
+		levels[module] = moduleLevel
+	}
+	switch cfg.Format {
+	case "", "text", "json":
+	default:
+		return 0, nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	return level, levels, nil
+}
+
+// ConfigSetting is the setting the logging configuration is saved in, as JSON
+const ConfigSetting = "log_config"
+
+// FromSettings reads the saved logging configuration. A missing or invalid
+// one leaves everything at its default.
+func FromSettings(settings map[string]string) Config {
+	var cfg Config
+	if raw := settings[ConfigSetting]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			return Config{}
+		}
+	}
+	return cfg
+}
+
+// ValidateSetting checks the value of the logging setting. Other settings are
+// always valid.
+func ValidateSetting(key, value string) error {
+	if key != ConfigSetting || value == "" {
+		return nil
+	}
+	var cfg Config
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return fmt.Errorf("%s must be a JSON object", ConfigSetting)
+	}
+	_, _, err := cfg.parse()
+	return err
+}
+
+// CurrentConfig returns the logging configuration in effect
+func CurrentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	cfg := Config{Level: levelName(defaultLevel), Format: "text", Modules: map[string]string{}}
+	if jsonOutput {
+		cfg.Format = "json"
+	}
+	for module, level := range moduleLevels {
+		cfg.Modules[module] = levelName(level)
+	}
+	return cfg
+}
+
+// Modules returns the names of the modules that log
+func Modules() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setOutput switches the format records are written in. Call with configMu
+// held.
+func setOutput(w io.Writer, asJSON bool) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	if asJSON {
+		output = slog.NewJSONHandler(w, opts)
+	} else {
+		output = slog.NewTextHandler(w, opts)
+	}
+	jsonOutput = asJSON
+}
+
+// levelFor returns the level a module logs at
+func levelFor(module string) slog.Level {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if level, ok := moduleLevels[module]; ok {
+		return level
+	}
+	return defaultLevel
+}
+
+// handler writes a module's records to the output and the ring buffer. The
+// module is empty for records from the standard log package.
+type handler struct {
+	module string
+	attrs  []slog.Attr
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	module := h.module
+	if module == "" {
+		module = "app"
+	}
+	return level >= levelFor(module)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	module := h.module
+	level := record.Level
+	if module == "" {
+		module = "app"
+		// The standard log package logs everything at info, so its level
+		// comes from the message as it always has
+		level = detectLevel(record.Message)
+		if level < levelFor(module) {
+			return nil
+		}
+	}
+	requestID := RequestID(ctx)
+
+	out := slog.NewRecord(record.Time, level, record.Message, record.PC)
+	out.AddAttrs(slog.String("module", module))
+	if requestID != "" {
+		out.AddAttrs(slog.String("request_id", requestID))
+	}
+	out.AddAttrs(h.attrs...)
+	record.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(a)
+		return true
+	})
+
+	configMu.RLock()
+	w := output
+	configMu.RUnlock()
+	err := w.Handle(ctx, out)
+
+	if globalBuffer != nil {
+		entry := LogEntry{
+			Timestamp: record.Time,
+			Level:     entryLevel(level),
+			Source:    module,
+			Message:   record.Message,
+			RequestID: requestID,
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = time.Now()
+		}
+		globalBuffer.Add(entry)
+		publish(entry)
+	}
+	return err
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{module: h.module, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+func (h *handler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// detectLevel guesses the level of a message from the standard log package
+func detectLevel(message string) slog.Level {
+	switch {
+	case levelPatterns[LevelError].MatchString(message):
+		return slog.LevelError
+	case levelPatterns[LevelWarn].MatchString(message):
+		return slog.LevelWarn
+	case levelPatterns[LevelDebug].MatchString(message):
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// entryLevel converts a slog level to a buffered entry's level
+func entryLevel(level slog.Level) LogLevel {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	}
+	return LevelDebug
+}
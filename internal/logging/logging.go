@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"os"
 	"regexp"
 	"strings"
@@ -28,6 +28,7 @@ type LogEntry struct {
 	Level     LogLevel  `json:"level"`
 	Source    string    `json:"source"`
 	Message   string    `json:"message"`
+	RequestID string    `json:"requestId,omitempty"`
 }
 
 // RingBuffer is a thread-safe circular buffer for log entries
@@ -81,31 +82,23 @@ func (rb *RingBuffer) Count() int {
 	return rb.count
 }
 
-// LogWriter is a custom io.Writer that captures logs to a ring buffer
-type LogWriter struct {
-	buffer    *RingBuffer
-	output    io.Writer
-	mu        sync.Mutex
-	lineBuffer strings.Builder
-}
-
-// Global logger instance
+// Global log buffer
 var (
 	globalBuffer *RingBuffer
-	globalWriter *LogWriter
 	initOnce     sync.Once
 )
 
-// Initialize sets up the global log buffer and returns a writer to use with log.SetOutput
-func Initialize(size int) io.Writer {
+// Initialize sets up the global log buffer and routes the standard log
+// package, and everything else that logs through slog, into it. Records are
+// written to stderr as JSON when asJSON is set, text otherwise.
+func Initialize(size int, asJSON bool) {
 	initOnce.Do(func() {
 		globalBuffer = NewRingBuffer(size)
-		globalWriter = &LogWriter{
-			buffer: globalBuffer,
-			output: os.Stderr,
-		}
+		configMu.Lock()
+		setOutput(os.Stderr, asJSON)
+		configMu.Unlock()
+		slog.SetDefault(slog.New(&handler{}))
 	})
-	return globalWriter
 }
 
 // GetBuffer returns the global ring buffer
@@ -122,83 +115,62 @@ var levelPatterns = map[LogLevel]*regexp.Regexp{
 
 // Source patterns to detect from log output
 var sourcePatterns = map[string]*regexp.Regexp{
-	"scheduler":  regexp.MustCompile(`(?i)\b(scheduler|task|job|cron)\b`),
-	"indexer":    regexp.MustCompile(`(?i)\b(indexer|torznab|newznab|prowlarr|search)\b`),
-	"importer":   regexp.MustCompile(`(?i)\b(import|upgrade)\b`),
-	"download":   regexp.MustCompile(`(?i)\b(download|torrent|nzb|qbittorrent|transmission|sabnzbd|nzbget|grab)\b`),
-	"scanner":    regexp.MustCompile(`(?i)\b(scan|scanner|library)\b`),
-	"metadata":   regexp.MustCompile(`(?i)\b(metadata|tmdb|tvdb|imdb)\b`),
-	"auth":       regexp.MustCompile(`(?i)\b(auth|login|logout|token|session|user)\b`),
-	"api":        regexp.MustCompile(`(?i)\b(api|request|response|handler|endpoint)\b`),
+	"scheduler": regexp.MustCompile(`(?i)\b(scheduler|task|job|cron)\b`),
+	"indexer":   regexp.MustCompile(`(?i)\b(indexer|torznab|newznab|prowlarr|search)\b`),
+	"importer":  regexp.MustCompile(`(?i)\b(import|upgrade)\b`),
+	"download":  regexp.MustCompile(`(?i)\b(download|torrent|nzb|qbittorrent|transmission|sabnzbd|nzbget|grab)\b`),
+	"scanner":   regexp.MustCompile(`(?i)\b(scan|scanner|library)\b`),
+	"metadata":  regexp.MustCompile(`(?i)\b(metadata|tmdb|tvdb|imdb)\b`),
+	"auth":      regexp.MustCompile(`(?i)\b(auth|login|logout|token|session|user)\b`),
+	"api":       regexp.MustCompile(`(?i)\b(api|request|response|handler|endpoint)\b`),
 }
 
-// Write implements io.Writer
-func (lw *LogWriter) Write(p []byte) (n int, err error) {
-	lw.mu.Lock()
-	defer lw.mu.Unlock()
-
-	// Write to original output
-	n, err = lw.output.Write(p)
-
-	// Accumulate bytes into line buffer
-	lw.lineBuffer.Write(p)
-
-	// Process complete lines
-	content := lw.lineBuffer.String()
-	lines := strings.Split(content, "\n")
-
-	// Process all complete lines (all but the last if it doesn't end with newline)
-	for i := 0; i < len(lines)-1; i++ {
-		line := strings.TrimSpace(lines[i])
-		if line != "" {
-			lw.processLine(line)
-		}
-	}
+// Live subscribers to new log entries
+var (
+	subscribers   = make(map[chan LogEntry]struct{})
+	subscribersMu sync.Mutex
+)
 
-	// Keep incomplete line in buffer
-	if len(lines) > 0 {
-		lw.lineBuffer.Reset()
-		lw.lineBuffer.WriteString(lines[len(lines)-1])
+// Subscribe returns a channel that receives each log entry as it's written and
+// a function that ends the subscription. Entries are dropped rather than
+// blocking logging when a subscriber falls behind.
+func Subscribe() (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, 256)
+	subscribersMu.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersMu.Unlock()
+
+	return ch, func() {
+		subscribersMu.Lock()
+		delete(subscribers, ch)
+		subscribersMu.Unlock()
 	}
-
-	return n, err
 }
 
-// processLine parses a log line and adds it to the buffer
-func (lw *LogWriter) processLine(line string) {
-	entry := LogEntry{
-		Timestamp: time.Now(),
-		Level:     LevelInfo,
-		Source:    "app",
-		Message:   line,
-	}
-
-	// Try to parse Go's default log format: 2006/01/02 15:04:05 message
-	if len(line) > 20 && (line[4] == '/' || line[2] == ':') {
-		// Try parsing timestamp
-		if t, rest, ok := parseTimestamp(line); ok {
-			entry.Timestamp = t
-			entry.Message = rest
+// publish sends an entry to every subscriber without blocking
+func publish(entry LogEntry) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- entry:
+		default:
 		}
 	}
+}
 
-	// Detect log level
-	for level, pattern := range levelPatterns {
-		if pattern.MatchString(entry.Message) {
-			entry.Level = level
-			break
-		}
+// Since returns the buffered entries logged at or after t
+func Since(t time.Time) []LogEntry {
+	if globalBuffer == nil {
+		return nil
 	}
-
-	// Detect source
-	for source, pattern := range sourcePatterns {
-		if pattern.MatchString(entry.Message) {
-			entry.Source = source
-			break
+	var entries []LogEntry
+	for _, entry := range globalBuffer.GetAll() {
+		if !entry.Timestamp.Before(t) {
+			entries = append(entries, entry)
 		}
 	}
-
-	lw.buffer.Add(entry)
+	return entries
 }
 
 // parseTimestamp attempts to parse a timestamp from the beginning of a log line
@@ -212,7 +184,8 @@ func parseTimestamp(line string) (time.Time, string, bool) {
 		}
 
 		for _, format := range formats {
-			if t, err := time.Parse(format, line[:len(format)]); err == nil {
+			// The log package writes local time
+			if t, err := time.ParseInLocation(format, line[:len(format)], time.Local); err == nil {
 				rest := strings.TrimSpace(line[len(format):])
 				// If we only got time, use today's date
 				if format == "15:04:05" {
@@ -229,10 +202,11 @@ func parseTimestamp(line string) (time.Time, string, bool) {
 
 // LogQuery represents query parameters for filtering logs
 type LogQuery struct {
-	Level  string // Minimum level (DEBUG shows all)
-	Source string // Filter by source
-	Search string // Text search
-	Limit  int    // Max entries to return
+	Level     string // Minimum level (DEBUG shows all)
+	Source    string // Filter by source
+	Search    string // Text search
+	RequestID string // Filter by request ID
+	Limit     int    // Max entries to return
 }
 
 // LogsResponse represents the API response for logs
@@ -240,12 +214,13 @@ type LogsResponse struct {
 	Entries []LogEntry `json:"entries"`
 	Total   int        `json:"total"`
 	HasMore bool       `json:"hasMore"`
+	Modules []string   `json:"modules"` // Every module that logs, for filtering
 }
 
 // Query filters and returns log entries based on the query parameters
 func Query(q LogQuery) LogsResponse {
 	if globalBuffer == nil {
-		return LogsResponse{Entries: []LogEntry{}, Total: 0, HasMore: false}
+		return LogsResponse{Entries: []LogEntry{}, Total: 0, HasMore: false, Modules: Modules()}
 	}
 
 	entries := globalBuffer.GetAll()
@@ -265,6 +240,11 @@ func Query(q LogQuery) LogsResponse {
 			continue
 		}
 
+		// Filter by request ID
+		if q.RequestID != "" && entry.RequestID != q.RequestID {
+			continue
+		}
+
 		// Filter by search text
 		if q.Search != "" && !strings.Contains(strings.ToLower(entry.Message), searchLower) {
 			continue
@@ -286,6 +266,7 @@ func Query(q LogQuery) LogsResponse {
 		Entries: filtered,
 		Total:   total,
 		HasMore: hasMore,
+		Modules: Modules(),
 	}
 }
 
@@ -320,56 +301,20 @@ func ExportAll() string {
 	sb.WriteString(strings.Repeat("=", 80) + "\n\n")
 
 	for _, entry := range entries {
-		sb.WriteString(fmt.Sprintf("[%s] [%s] [%s] %s\n",
+		sb.WriteString(fmt.Sprintf("[%s] [%s] [%s] ",
 			entry.Timestamp.Format("2006-01-02 15:04:05"),
 			entry.Level,
 			entry.Source,
-			entry.Message,
 		))
+		if entry.RequestID != "" {
+			sb.WriteString("[" + entry.RequestID + "] ")
+		}
+		sb.WriteString(entry.Message + "\n")
 	}
 
 	return sb.String()
 }
 
-// LogWithSource creates a log message with an explicit source tag
-func LogWithSource(source, format string, args ...interface{}) string {
-	msg := fmt.Sprintf(format, args...)
-	return fmt.Sprintf("[%s] %s", source, msg)
-}
-
-// Helper functions for tagged logging
-func Scheduler(format string, args ...interface{}) string {
-	return LogWithSource("scheduler", format, args...)
-}
-
-func Indexer(format string, args ...interface{}) string {
-	return LogWithSource("indexer", format, args...)
-}
-
-func Importer(format string, args ...interface{}) string {
-	return LogWithSource("importer", format, args...)
-}
-
-func Download(format string, args ...interface{}) string {
-	return LogWithSource("download", format, args...)
-}
-
-func Scanner(format string, args ...interface{}) string {
-	return LogWithSource("scanner", format, args...)
-}
-
-func Metadata(format string, args ...interface{}) string {
-	return LogWithSource("metadata", format, args...)
-}
-
-func Auth(format string, args ...interface{}) string {
-	return LogWithSource("auth", format, args...)
-}
-
-func API(format string, args ...interface{}) string {
-	return LogWithSource("api", format, args...)
-}
-
 // MarshalJSON for LogEntry to format timestamp as ISO string
 func (e LogEntry) MarshalJSON() ([]byte, error) {
 	type Alias LogEntry
@@ -0,0 +1,87 @@
+package lyrics
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Line is a line of synced lyrics
+type Line struct {
+	Time int    `json:"time"` // Milliseconds from the start of the track
+	Text string `json:"text"` // Empty for instrumental breaks
+}
+
+// lrcTimestamp matches a line timestamp like [01:23.45] or [1:23]
+var lrcTimestamp = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+
+// lrcTag matches an ID tag like [ar:Artist]
+var lrcTag = regexp.MustCompile(`^\[([a-z#]+):(.*)\]\s*$`)
+
+// lrcWordTime matches the word timestamps of enhanced LRC, like <01:23.45>
+var lrcWordTime = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`)
+
+// ParseLRC reads LRC lyrics into lines sorted by time. A line with several
+// timestamps, as used for repeated choruses, becomes a line for each. The
+// [offset:] tag is applied, and word timestamps of enhanced LRC dropped.
+func ParseLRC(lrc string) []Line {
+	var lines []Line
+	offset := 0
+	for _, raw := range strings.Split(strings.ReplaceAll(lrc, "\r\n", "\n"), "\n") {
+		raw = strings.TrimSpace(strings.TrimPrefix(raw, "\ufeff"))
+
+		var times []int
+		for {
+			m := lrcTimestamp.FindStringSubmatch(raw)
+			if m == nil {
+				break
+			}
+			times = append(times, timestampMillis(m[1], m[2], m[3]))
+			raw = raw[len(m[0]):]
+		}
+		if len(times) == 0 {
+			if m := lrcTag.FindStringSubmatch(raw); m != nil && m[1] == "offset" {
+				offset, _ = strconv.Atoi(strings.TrimSpace(m[2]))
+			}
+			continue
+		}
+
+		text := strings.TrimSpace(lrcWordTime.ReplaceAllString(raw, ""))
+		text = strings.Join(strings.Fields(text), " ")
+		for _, t := range times {
+			lines = append(lines, Line{Time: t, Text: text})
+		}
+	}
+
+	// A positive offset shows lyrics sooner
+	for i := range lines {
+		lines[i].Time -= offset
+		if lines[i].Time < 0 {
+			lines[i].Time = 0
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time < lines[j].Time })
+	return lines
+}
+
+// timestampMillis converts the parts of a timestamp to milliseconds, reading
+// the fraction as a decimal fraction of a second
+func timestampMillis(min, sec, frac string) int {
+	m, _ := strconv.Atoi(min)
+	s, _ := strconv.Atoi(sec)
+	ms := 0
+	if frac != "" {
+		ms, _ = strconv.Atoi((frac + "00")[:3])
+	}
+	return (m*60+s)*1000 + ms
+}
+
+// PlainText returns the text of synced lyrics without their timing
+func PlainText(lines []Line) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
@@ -0,0 +1,137 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lyrics
+//
+// Lyrics come from LRCLIB, a free lyrics database that has time-synced LRC
+// lyrics for many tracks and plain lyrics for more. Tracks are looked up by
+// artist, title, album and duration, which LRCLIB matches exactly, and
+// searched by artist and title when that finds nothing.
+
+const (
+	BaseURL = "https://lrclib.net/api"
+	// UserAgent identifies Outpost, as LRCLIB asks of every client
+	UserAgent = "Outpost/1.0 ( https://github.com/outpost/outpost )"
+)
+
+// maxDurationDiff is how far apart in seconds a search result's duration
+// and the track's can be for it to be taken as a match
+const maxDurationDiff = 3
+
+// ErrNotFound is returned for tracks LRCLIB has no lyrics for
+var ErrNotFound = errors.New("no lyrics found")
+
+// Client handles LRCLIB requests
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new LRCLIB client
+func NewClient() *Client {
+	return &Client{baseURL: BaseURL, httpClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Query describes the track lyrics are wanted for
+type Query struct {
+	Artist   string
+	Title    string
+	Album    string
+	Duration int // Seconds; 0 when unknown
+}
+
+// Result is a track's lyrics as LRCLIB has them
+type Result struct {
+	ID           int64   `json:"id"`
+	TrackName    string  `json:"trackName"`
+	ArtistName   string  `json:"artistName"`
+	AlbumName    string  `json:"albumName"`
+	Duration     float64 `json:"duration"`
+	Instrumental bool    `json:"instrumental"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"` // LRC
+}
+
+// Lookup finds the lyrics of a track, preferring an exact match and then the
+// search result closest to it that has synced lyrics
+func (c *Client) Lookup(q Query) (*Result, error) {
+	if q.Artist == "" || q.Title == "" {
+		return nil, ErrNotFound
+	}
+
+	if q.Album != "" && q.Duration > 0 {
+		params := url.Values{
+			"artist_name": {q.Artist},
+			"track_name":  {q.Title},
+			"album_name":  {q.Album},
+			"duration":    {strconv.Itoa(q.Duration)},
+		}
+		var result Result
+		err := c.get("/get", params, &result)
+		if err == nil {
+			return &result, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	var results []Result
+	params := url.Values{"artist_name": {q.Artist}, "track_name": {q.Title}}
+	if err := c.get("/search", params, &results); err != nil {
+		return nil, err
+	}
+	var best *Result
+	for i := range results {
+		r := &results[i]
+		if q.Duration > 0 && math.Abs(r.Duration-float64(q.Duration)) > maxDurationDiff {
+			continue
+		}
+		if r.PlainLyrics == "" && r.SyncedLyrics == "" && !r.Instrumental {
+			continue
+		}
+		if best == nil || (best.SyncedLyrics == "" && r.SyncedLyrics != "") {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+	return best, nil
+}
+
+func (c *Client) get(endpoint string, params url.Values, result interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("LRCLIB request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
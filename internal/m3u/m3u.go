@@ -0,0 +1,109 @@
+// Package m3u reads and writes M3U and M3U8 playlists.
+//
+// Both are lists of file paths or URLs, one a line, optionally with an
+// #EXTINF line before each giving its length and a display title, usually
+// "Artist - Title". M3U8 is always UTF-8; plain M3U files are often in the
+// Windows Latin-1 code page, so they're read as that when they aren't valid
+// UTF-8.
+package m3u
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Header starts an extended M3U playlist
+const Header = "#EXTM3U"
+
+// MaxSize is how big a playlist Parse reads
+const MaxSize = 4 << 20
+
+// Entry is a file in a playlist
+type Entry struct {
+	Path     string // As written: absolute, relative to the playlist, or a URL
+	Title    string // From #EXTINF; "" if none
+	Duration int    // Seconds from #EXTINF; -1 or 0 if unknown
+}
+
+// Parse reads a playlist's entries
+func Parse(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("playlist is larger than %d MB", MaxSize>>20)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		data = latin1ToUTF8(data)
+	}
+
+	var entries []Entry
+	var info *Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), MaxSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseInfo(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+			// Other directives and comments
+		default:
+			entry := Entry{Path: line}
+			if info != nil {
+				entry.Title, entry.Duration = info.Title, info.Duration
+				info = nil
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseInfo reads "duration[ attributes],title" from an #EXTINF line
+func parseInfo(value string) *Entry {
+	info := &Entry{}
+	durationPart, title, _ := strings.Cut(value, ",")
+	info.Title = strings.TrimSpace(title)
+	if fields := strings.Fields(durationPart); len(fields) > 0 {
+		if n, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			info.Duration = int(n)
+		}
+	}
+	return info
+}
+
+// latin1ToUTF8 decodes Windows-1252 text. Its extra characters in 0x80-0x9f
+// are rare in file names, so those bytes are taken as Latin-1 too.
+func latin1ToUTF8(data []byte) []byte {
+	buf := make([]byte, 0, len(data)+len(data)/8)
+	for _, b := range data {
+		buf = utf8.AppendRune(buf, rune(b))
+	}
+	return buf
+}
+
+// Write writes an extended M3U playlist in UTF-8
+func Write(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, Header)
+	for _, e := range entries {
+		duration := e.Duration
+		if duration <= 0 {
+			duration = -1
+		}
+		// A line break would end the entry early
+		title := strings.NewReplacer("\r", " ", "\n", " ").Replace(e.Title)
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", duration, title)
+		fmt.Fprintln(bw, e.Path)
+	}
+	return bw.Flush()
+}
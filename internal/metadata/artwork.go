@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Library artwork
+//
+// Libraries choose poster or landscape artwork for their grids, with or
+// without the title on it. The regular poster and backdrop serve libraries
+// that don't mind text; otherwise the best matching image is picked from
+// TMDB's artwork and cached as the item's artwork_path.
+
+// Image sizes for grid artwork
+const (
+	artworkPosterSize    = "w500"
+	artworkLandscapeSize = "w780"
+	titleLogoSize        = "w500"
+)
+
+// libraryArtwork returns the artwork style and text preference of a library
+func (s *Service) libraryArtwork(libraryID int64) (style, text string) {
+	lib, err := s.db.GetLibrary(libraryID)
+	if err != nil {
+		return database.ArtworkPoster, database.ArtworkTextAny
+	}
+	return lib.ArtworkStyle, lib.ArtworkText
+}
+
+// pickArtwork returns the grid artwork for a movie or show in a library.
+// mediaType is "movie" or "show"; poster and backdrop are the item's cached
+// regular artwork, used when nothing better fits or TMDB can't be asked.
+func (s *Service) pickArtwork(libraryID int64, mediaType string, tmdbID *int64, poster, backdrop *string) *string {
+	style, text := s.libraryArtwork(libraryID)
+
+	fallback := poster
+	if style == database.ArtworkLandscape {
+		fallback = backdrop
+	}
+	if text == database.ArtworkTextAny || tmdbID == nil || !s.Configured() {
+		return fallback
+	}
+
+	// Titles don't say their original language here, so it's left out
+	chain := s.languageChain("")
+	var images *tmdb.ImageSet
+	var err error
+	if mediaType == "movie" {
+		images, err = s.tmdb.GetMovieImages(*tmdbID, chain...)
+	} else {
+		images, err = s.tmdb.GetTVImages(*tmdbID, chain...)
+	}
+	if err != nil {
+		logger.Errorf("Failed to get artwork for %s tmdb=%d: %v", mediaType, *tmdbID, err)
+		return fallback
+	}
+
+	candidates, size := images.Posters, artworkPosterSize
+	if style == database.ArtworkLandscape {
+		candidates, size = images.Backdrops, artworkLandscapeSize
+	}
+	var best *tmdb.Image
+	var lang string
+	if text == database.ArtworkTextWith {
+		best, lang = imageInChain(candidates, chain)
+	}
+	if best == nil {
+		best = bestImage(candidates, text == database.ArtworkTextless)
+	}
+	if best == nil {
+		return fallback
+	}
+
+	path, err := s.tmdb.DownloadLocalizedImage(best.FilePath, size, lang)
+	if err != nil || path == "" {
+		return fallback
+	}
+	return &path
+}
+
+// bestImage returns the highest rated image with or without text. TMDB tags
+// images carrying text with their language.
+func bestImage(images []tmdb.Image, textless bool) *tmdb.Image {
+	var best *tmdb.Image
+	for i := range images {
+		img := &images[i]
+		hasText := img.Language != nil && *img.Language != ""
+		if hasText == textless {
+			continue
+		}
+		if best == nil || img.VoteAverage > best.VoteAverage ||
+			(img.VoteAverage == best.VoteAverage && img.VoteCount > best.VoteCount) {
+			best = img
+		}
+	}
+	return best
+}
+
+// TitleLogo returns the cached title logo of a movie or show ("movie" or
+// "show"), or "" when TMDB has none. Logos are looked up once per run and
+// language chain.
+func (s *Service) TitleLogo(mediaType string, tmdbID int64) string {
+	chain := s.languageChain("")
+	key := fmt.Sprintf("%s:%d:%s", mediaType, tmdbID, strings.Join(chain, ","))
+	s.titleLogosMu.Lock()
+	path, ok := s.titleLogos[key]
+	s.titleLogosMu.Unlock()
+	if ok || !s.Configured() {
+		return path
+	}
+
+	var images *tmdb.ImageSet
+	var err error
+	if mediaType == "movie" {
+		images, err = s.tmdb.GetMovieImages(tmdbID, chain...)
+	} else {
+		images, err = s.tmdb.GetTVImages(tmdbID, chain...)
+	}
+	if err != nil {
+		// Not remembered, so it's asked for again next time
+		logger.Errorf("Failed to get logo for %s tmdb=%d: %v", mediaType, tmdbID, err)
+		return ""
+	}
+
+	// Logos carry the title, so one in the chain's languages is preferred
+	// over textless
+	best, lang := imageInChain(images.Logos, chain)
+	if best == nil {
+		best = bestImage(images.Logos, false)
+	}
+	if best == nil {
+		best = bestImage(images.Logos, true)
+	}
+	if best != nil {
+		if cached, err := s.tmdb.DownloadLocalizedImage(best.FilePath, titleLogoSize, lang); err == nil {
+			path = cached
+		}
+	}
+
+	s.titleLogosMu.Lock()
+	if s.titleLogos == nil {
+		s.titleLogos = make(map[string]string)
+	}
+	s.titleLogos[key] = path
+	s.titleLogosMu.Unlock()
+	return path
+}
+
+// RefreshLibraryArtwork picks the grid artwork of every movie and show in a
+// library again, after its artwork style changed
+func (s *Service) RefreshLibraryArtwork(libraryID int64) {
+	movies, err := s.db.GetMovies()
+	if err != nil {
+		logger.Errorf("Failed to get movies for artwork refresh: %v", err)
+	}
+	refreshed := 0
+	for _, m := range movies {
+		if m.LibraryID != libraryID {
+			continue
+		}
+		refreshed++
+		artwork := s.pickArtwork(libraryID, "movie", m.TmdbID, m.PosterPath, m.BackdropPath)
+		if err := s.db.UpdateMovieArtwork(m.ID, artwork); err != nil {
+			logger.Errorf("Failed to update artwork for movie %s: %v", m.Title, err)
+		}
+	}
+
+	shows, err := s.db.GetShows()
+	if err != nil {
+		logger.Errorf("Failed to get shows for artwork refresh: %v", err)
+	}
+	for _, sh := range shows {
+		if sh.LibraryID != libraryID {
+			continue
+		}
+		refreshed++
+		artwork := s.pickArtwork(libraryID, "show", sh.TmdbID, sh.PosterPath, sh.BackdropPath)
+		if err := s.db.UpdateShowArtwork(sh.ID, artwork); err != nil {
+			logger.Errorf("Failed to update artwork for show %s: %v", sh.Title, err)
+		}
+	}
+	logger.Infof("Refreshed artwork for %d items in library %d", refreshed, libraryID)
+}
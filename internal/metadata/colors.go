@@ -0,0 +1,199 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+)
+
+// Artwork colors
+//
+// Posters and backdrops get a dominant and an accent color when they're
+// cached, so clients can show a colored placeholder and theme detail pages
+// before the image itself loads. Colors are stored per image like focal
+// points, and on each movie and show as JSON next to its artwork.
+
+// colorSamples is how many pixels are sampled along each side of an image
+const colorSamples = 64
+
+// imageColors are the colors picked from an image, as #rrggbb
+type imageColors struct {
+	Dominant string `json:"dominant"`
+	Accent   string `json:"accent"`
+}
+
+// artworkColors are the colors of a movie's or show's artwork
+type artworkColors struct {
+	Poster   *imageColors `json:"poster,omitempty"`
+	Backdrop *imageColors `json:"backdrop,omitempty"`
+}
+
+// colorBucket sums the samples of similar colors
+type colorBucket struct {
+	r, g, b, n uint64
+}
+
+func (c colorBucket) average() (float64, float64, float64) {
+	n := float64(c.n)
+	return float64(c.r) / n, float64(c.g) / n, float64(c.b) / n
+}
+
+// extractColors picks the most common color of an image, and as the accent
+// the most vivid color that stands out from it. Images without a vivid color
+// use the dominant color as their accent.
+func extractColors(img image.Image) imageColors {
+	bounds := img.Bounds()
+	stepX := max(1, bounds.Dx()/colorSamples)
+	stepY := max(1, bounds.Dy()/colorSamples)
+
+	// Colors are grouped by the top 3 bits of each channel
+	buckets := make(map[int]*colorBucket)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			r, g, b = r>>8, g>>8, b>>8
+			key := int(r>>5)<<6 | int(g>>5)<<3 | int(b>>5)
+			bucket := buckets[key]
+			if bucket == nil {
+				bucket = &colorBucket{}
+				buckets[key] = bucket
+			}
+			bucket.r += uint64(r)
+			bucket.g += uint64(g)
+			bucket.b += uint64(b)
+			bucket.n++
+		}
+	}
+
+	var dominant *colorBucket
+	for _, bucket := range buckets {
+		if dominant == nil || bucket.n > dominant.n {
+			dominant = bucket
+		}
+	}
+	if dominant == nil {
+		return imageColors{Dominant: "#000000", Accent: "#000000"}
+	}
+	dr, dg, db := dominant.average()
+
+	var accent *colorBucket
+	bestScore := 0.0
+	for _, bucket := range buckets {
+		r, g, b := bucket.average()
+		sat, light := saturationLightness(r, g, b)
+		if light < 0.15 || light > 0.9 || math.Sqrt((r-dr)*(r-dr)+(g-dg)*(g-dg)+(b-db)*(b-db)) < 60 {
+			continue
+		}
+		if score := float64(bucket.n) * sat * sat; score > bestScore {
+			accent, bestScore = bucket, score
+		}
+	}
+	if accent == nil {
+		accent = dominant
+	}
+
+	return imageColors{Dominant: hexColor(dominant.average()), Accent: hexColor(accent.average())}
+}
+
+// saturationLightness returns the HSL saturation and lightness of an RGB
+// color with 0-255 channels
+func saturationLightness(r, g, b float64) (float64, float64) {
+	hi := math.Max(r, math.Max(g, b)) / 255
+	lo := math.Min(r, math.Min(g, b)) / 255
+	light := (hi + lo) / 2
+	if hi == lo {
+		return 0, light
+	}
+	return (hi - lo) / (1 - math.Abs(2*light-1)), light
+}
+
+func hexColor(r, g, b float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round(r)), int(math.Round(g)), int(math.Round(b)))
+}
+
+// colorsOf returns the colors of a cached image, analyzing it the first time.
+// It returns nil when the image can't be read.
+func (s *Service) colorsOf(localPath string) *imageColors {
+	if dominant, accent, ok, _ := s.db.GetImageColors(localPath); ok {
+		return &imageColors{Dominant: dominant, Accent: accent}
+	}
+
+	file, _, err := s.images.Open(localPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		logger.Errorf("Failed to find colors of %s: %v", localPath, err)
+		return nil
+	}
+	colors := extractColors(img)
+	if err := s.db.SetImageColors(localPath, colors.Dominant, colors.Accent); err != nil {
+		logger.Errorf("Failed to store colors of %s: %v", localPath, err)
+	}
+	return &colors
+}
+
+// artworkColors returns the colors of a poster and backdrop as stored on
+// movies and shows, or nil when neither has any
+func (s *Service) artworkColors(poster, backdrop *string) *string {
+	var colors artworkColors
+	if poster != nil && *poster != "" {
+		colors.Poster = s.colorsOf(*poster)
+	}
+	if backdrop != nil && *backdrop != "" {
+		colors.Backdrop = s.colorsOf(*backdrop)
+	}
+	if colors.Poster == nil && colors.Backdrop == nil {
+		return nil
+	}
+	data, err := json.Marshal(colors)
+	if err != nil {
+		return nil
+	}
+	value := string(data)
+	return &value
+}
+
+// BackfillArtworkColors finds colors for library artwork that doesn't have
+// them yet, such as artwork cached before colors were kept
+func (s *Service) BackfillArtworkColors() {
+	filled := 0
+	if movies, err := s.db.GetMovies(); err == nil {
+		for _, m := range movies {
+			if m.Colors != nil {
+				continue
+			}
+			colors := s.artworkColors(m.PosterPath, m.BackdropPath)
+			if colors == nil {
+				continue
+			}
+			if err := s.db.UpdateMovieColors(m.ID, colors); err == nil {
+				filled++
+			}
+		}
+	}
+	if shows, err := s.db.GetShows(); err == nil {
+		for _, sh := range shows {
+			if sh.Colors != nil {
+				continue
+			}
+			colors := s.artworkColors(sh.PosterPath, sh.BackdropPath)
+			if colors == nil {
+				continue
+			}
+			if err := s.db.UpdateShowColors(sh.ID, colors); err == nil {
+				filled++
+			}
+		}
+	}
+	if filled > 0 {
+		logger.Infof("Found artwork colors for %d items", filled)
+	}
+}
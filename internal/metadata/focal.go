@@ -0,0 +1,200 @@
+package metadata
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"sync"
+
+	"github.com/muesli/smartcrop"
+	"github.com/muesli/smartcrop/nfnt"
+)
+
+// Backdrop focal points
+//
+// Clients crop backdrops into hero images of other shapes, so each backdrop
+// gets a focal point to keep in frame. smartcrop scores the image by edges,
+// skin tones and saturation, which puts the point on faces when there are
+// any. Results are stored per image, so an image is only analyzed once.
+
+// Focal point used when an image can't be analyzed: centered, a quarter down,
+// where faces usually are in movie artwork
+const (
+	defaultFocalX = 0.5
+	defaultFocalY = 0.25
+)
+
+// discoverBackdropSize is the TMDB size analyzed for discover backdrops
+const discoverBackdropSize = "w780"
+
+// analyzeFocalPoint finds the focal point of an image as fractions of its
+// width and height
+func analyzeFocalPoint(img image.Image) (float64, float64, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	analyzer := smartcrop.NewAnalyzer(nfnt.NewDefaultResizer())
+
+	// A tall crop slides sideways to find x, a wide one up and down to find y
+	tall, err := analyzer.FindBestCrop(img, width/2, height)
+	if err != nil {
+		return defaultFocalX, defaultFocalY, err
+	}
+	wide, err := analyzer.FindBestCrop(img, width, height/2)
+	if err != nil {
+		return defaultFocalX, defaultFocalY, err
+	}
+
+	x := float64((tall.Min.X+tall.Max.X)/2-bounds.Min.X) / float64(width)
+	y := float64((wide.Min.Y+wide.Max.Y)/2-bounds.Min.Y) / float64(height)
+	return clampFocal(x), clampFocal(y), nil
+}
+
+func clampFocal(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// decodeFocalPoint decodes an image and finds its focal point
+func decodeFocalPoint(r io.Reader) (float64, float64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return defaultFocalX, defaultFocalY, err
+	}
+	return analyzeFocalPoint(img)
+}
+
+// focalPoint returns the focal point of a cached image, analyzing it the
+// first time. Images that can't be read get the default point.
+func (s *Service) focalPoint(localPath string) (float64, float64) {
+	if x, y, ok, _ := s.db.GetImageFocalPoint(localPath); ok {
+		return x, y
+	}
+
+	file, _, err := s.images.Open(localPath)
+	if err != nil {
+		return defaultFocalX, defaultFocalY
+	}
+	defer file.Close()
+
+	x, y, err := decodeFocalPoint(file)
+	if err != nil {
+		logger.Errorf("Failed to find focal point of %s: %v", localPath, err)
+		return x, y
+	}
+	if err := s.db.SetImageFocalPoint(localPath, x, y); err != nil {
+		logger.Errorf("Failed to store focal point of %s: %v", localPath, err)
+	}
+	return x, y
+}
+
+// backdropFocalPoint returns the focal point of a cached backdrop, or nils
+// when there's no backdrop
+func (s *Service) backdropFocalPoint(backdrop *string) (*float64, *float64) {
+	if backdrop == nil || *backdrop == "" {
+		return nil, nil
+	}
+	x, y := s.focalPoint(*backdrop)
+	return &x, &y
+}
+
+// discoverFocal analyzes discover backdrops in the background: they aren't
+// cached, and downloading a page of them would hold up the response
+type discoverFocal struct {
+	once    sync.Once
+	mu      sync.Mutex
+	pending map[string]bool
+	queue   chan string
+}
+
+// discoverFocalPoint returns the stored focal point of a TMDB backdrop. The
+// first time it's asked for, it returns nils and queues the backdrop for
+// analysis.
+func (s *Service) discoverFocalPoint(tmdbPath string) (*float64, *float64) {
+	if tmdbPath == "" {
+		return nil, nil
+	}
+	key := "tmdb:" + discoverBackdropSize + tmdbPath
+	if x, y, ok, _ := s.db.GetImageFocalPoint(key); ok {
+		return &x, &y
+	}
+
+	f := &s.discoverFocal
+	f.once.Do(func() {
+		f.pending = make(map[string]bool)
+		f.queue = make(chan string, 200)
+		go s.runDiscoverFocal()
+	})
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.pending[tmdbPath] {
+		select {
+		case f.queue <- tmdbPath:
+			f.pending[tmdbPath] = true
+		default: // Full; it's asked for again next time
+		}
+	}
+	return nil, nil
+}
+
+// runDiscoverFocal analyzes queued discover backdrops one at a time
+func (s *Service) runDiscoverFocal() {
+	f := &s.discoverFocal
+	for tmdbPath := range f.queue {
+		x, y, err := s.analyzeTMDBImage(tmdbPath)
+		if err == nil {
+			if err := s.db.SetImageFocalPoint("tmdb:"+discoverBackdropSize+tmdbPath, x, y); err != nil {
+				logger.Errorf("Failed to store focal point of %s: %v", tmdbPath, err)
+			}
+		}
+		f.mu.Lock()
+		delete(f.pending, tmdbPath)
+		f.mu.Unlock()
+	}
+}
+
+// analyzeTMDBImage downloads a TMDB backdrop and finds its focal point
+func (s *Service) analyzeTMDBImage(tmdbPath string) (float64, float64, error) {
+	body, err := s.tmdb.OpenImage(tmdbPath, discoverBackdropSize)
+	if err != nil {
+		return defaultFocalX, defaultFocalY, err
+	}
+	defer body.Close()
+	return decodeFocalPoint(body)
+}
+
+// BackfillFocalPoints finds focal points for library backdrops that don't
+// have one yet, such as local fanart
+func (s *Service) BackfillFocalPoints() {
+	filled := 0
+	if movies, err := s.db.GetMovies(); err == nil {
+		for _, m := range movies {
+			if m.FocalX != nil || m.BackdropPath == nil || *m.BackdropPath == "" {
+				continue
+			}
+			x, y := s.backdropFocalPoint(m.BackdropPath)
+			if err := s.db.UpdateMovieFocalPoint(m.ID, x, y); err == nil {
+				filled++
+			}
+		}
+	}
+	if shows, err := s.db.GetShows(); err == nil {
+		for _, sh := range shows {
+			if sh.FocalX != nil || sh.BackdropPath == nil || *sh.BackdropPath == "" {
+				continue
+			}
+			x, y := s.backdropFocalPoint(sh.BackdropPath)
+			if err := s.db.UpdateShowFocalPoint(sh.ID, x, y); err == nil {
+				filled++
+			}
+		}
+	}
+	if filled > 0 {
+		logger.Infof("Found focal points for %d backdrops", filled)
+	}
+}
@@ -0,0 +1,266 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Metadata languages
+//
+// Overviews, taglines and posters are taken from a chain of languages set in
+// metadata_languages, like "de,en,original". Each is tried in turn until one
+// has the text or artwork, so a title missing a German overview gets the
+// English one rather than none. "original" stands for the title's original
+// language. The default chain of just English keeps TMDB's own defaults and
+// costs no extra requests.
+
+// SettingLanguages is the setting holding the language chain
+const SettingLanguages = "metadata_languages"
+
+// originalLanguage stands for a title's original language in the chain
+const originalLanguage = "original"
+
+// defaultLanguages is the chain used when none is set
+var defaultLanguages = []string{"en"}
+
+// ParseLanguages parses a comma-separated language chain of two-letter
+// codes and "original", dropping repeats
+func ParseLanguages(value string) ([]string, error) {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		lang := strings.ToLower(strings.TrimSpace(part))
+		if lang == "" || seen[lang] {
+			continue
+		}
+		if lang != originalLanguage && !isLanguageCode(lang) {
+			return nil, fmt.Errorf("Unknown metadata language %q: use two-letter codes like en or de, or original", part)
+		}
+		seen[lang] = true
+		langs = append(langs, lang)
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("At least one metadata language is required")
+	}
+	return langs, nil
+}
+
+func isLanguageCode(lang string) bool {
+	if len(lang) != 2 {
+		return false
+	}
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateSetting checks the metadata language chain and the lyrics toggle.
+// Other settings are always valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case SettingLanguages:
+		_, err := ParseLanguages(value)
+		return err
+	case SettingLyrics:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%s must be true or false", SettingLyrics)
+		}
+	}
+	return nil
+}
+
+// Languages returns the configured language chain
+func (s *Service) Languages() []string {
+	value, err := s.db.GetSetting(SettingLanguages)
+	if err != nil || value == "" {
+		return defaultLanguages
+	}
+	langs, err := ParseLanguages(value)
+	if err != nil {
+		logger.Warnf("Ignoring invalid %s %q: %v", SettingLanguages, value, err)
+		return defaultLanguages
+	}
+	return langs
+}
+
+// languageChain returns the configured chain for a title, "original"
+// replaced by its original language. It's nil for the default chain, which
+// TMDB's own responses already follow.
+func (s *Service) languageChain(original string) []string {
+	langs := s.Languages()
+	if len(langs) == 1 && langs[0] == defaultLanguages[0] {
+		return nil
+	}
+
+	var chain []string
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		if lang == originalLanguage {
+			lang = original
+		}
+		if lang == "" || seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		chain = append(chain, lang)
+	}
+	return chain
+}
+
+// translated returns the first non-empty text in the chain's languages, as
+// picked out of each translation by field. ok is false if none has it.
+func translated(translations []tmdb.Translation, chain []string, field func(*tmdb.Translation) string) (string, bool) {
+	for _, lang := range chain {
+		for i := range translations {
+			t := &translations[i]
+			if t.ISO6391 != lang {
+				continue
+			}
+			if text := strings.TrimSpace(field(t)); text != "" {
+				return text, true
+			}
+		}
+	}
+	return "", false
+}
+
+// imageInChain returns the best rated image in the first language of the
+// chain that has one, along with that language
+func imageInChain(images []tmdb.Image, chain []string) (*tmdb.Image, string) {
+	for _, lang := range chain {
+		var best *tmdb.Image
+		for i := range images {
+			img := &images[i]
+			if img.Language == nil || *img.Language != lang {
+				continue
+			}
+			if best == nil || img.VoteAverage > best.VoteAverage ||
+				(img.VoteAverage == best.VoteAverage && img.VoteCount > best.VoteCount) {
+				best = img
+			}
+		}
+		if best != nil {
+			return best, lang
+		}
+	}
+	return nil, ""
+}
+
+// localizeMovie replaces a movie's overview, tagline and poster with those
+// in the first language of the chain that has them. What none of the
+// languages has keeps TMDB's default.
+func (s *Service) localizeMovie(movie *database.Movie, details *tmdb.MovieDetails) {
+	chain := s.languageChain(details.OriginalLanguage)
+	if chain == nil {
+		return
+	}
+
+	if translations, err := s.tmdb.GetMovieTranslations(details.ID); err != nil {
+		logger.Errorf("Failed to get translations for movie %s: %v", details.Title, err)
+	} else {
+		if overview, ok := translated(translations, chain, func(t *tmdb.Translation) string { return t.Data.Overview }); ok {
+			movie.Overview = &overview
+		}
+		if tagline, ok := translated(translations, chain, func(t *tmdb.Translation) string { return t.Data.Tagline }); ok {
+			movie.Tagline = &tagline
+		}
+	}
+
+	images, err := s.tmdb.GetMovieImages(details.ID, chain...)
+	if err != nil {
+		logger.Errorf("Failed to get posters for movie %s: %v", details.Title, err)
+		return
+	}
+	if poster := s.localizedPoster(images, chain); poster != "" {
+		movie.PosterPath = &poster
+	}
+}
+
+// localizeShow replaces a show's overview and poster with those in the
+// first language of the chain that has them
+func (s *Service) localizeShow(show *database.Show, details *tmdb.TVDetails) {
+	chain := s.languageChain(details.OriginalLanguage)
+	if chain == nil {
+		return
+	}
+
+	if translations, err := s.tmdb.GetTVTranslations(details.ID); err != nil {
+		logger.Errorf("Failed to get translations for show %s: %v", details.Name, err)
+	} else if overview, ok := translated(translations, chain, func(t *tmdb.Translation) string { return t.Data.Overview }); ok {
+		show.Overview = &overview
+	}
+
+	images, err := s.tmdb.GetTVImages(details.ID, chain...)
+	if err != nil {
+		logger.Errorf("Failed to get posters for show %s: %v", details.Name, err)
+		return
+	}
+	if poster := s.localizedPoster(images, chain); poster != "" {
+		show.PosterPath = &poster
+	}
+}
+
+// localizedPoster caches the poster in the first language of the chain that
+// has one, returning "" if none does
+func (s *Service) localizedPoster(images *tmdb.ImageSet, chain []string) string {
+	best, lang := imageInChain(images.Posters, chain)
+	if best == nil {
+		return ""
+	}
+	path, err := s.tmdb.DownloadLocalizedImage(best.FilePath, "w500", lang)
+	if err != nil {
+		logger.Errorf("Failed to download %s poster %s: %v", lang, best.FilePath, err)
+		return ""
+	}
+	return path
+}
+
+// localizedSeasonDetails fetches a season in each language of the chain, in
+// order. Languages TMDB fails to answer for are left out.
+func (s *Service) localizedSeasonDetails(showTmdbID int64, seasonNumber int, chain []string) []*tmdb.SeasonDetails {
+	var seasons []*tmdb.SeasonDetails
+	for _, lang := range chain {
+		details, err := s.tmdb.GetSeasonDetailsIn(showTmdbID, seasonNumber, lang)
+		if err != nil {
+			logger.Errorf("Failed to fetch season %d in %s: %v", seasonNumber, lang, err)
+			continue
+		}
+		seasons = append(seasons, details)
+	}
+	return seasons
+}
+
+// localizeSeason replaces a season's overview and those of its episodes
+// with the first the chain's languages have
+func localizeSeason(season *tmdb.SeasonDetails, localized []*tmdb.SeasonDetails) {
+	for _, l := range localized {
+		if l.Overview != "" {
+			season.Overview = l.Overview
+			break
+		}
+	}
+	for i := range season.Episodes {
+		ep := &season.Episodes[i]
+		for _, l := range localized {
+			if overview := episodeOverview(l, ep.EpisodeNumber); overview != "" {
+				ep.Overview = overview
+				break
+			}
+		}
+	}
+}
+
+func episodeOverview(season *tmdb.SeasonDetails, episodeNumber int) string {
+	for _, ep := range season.Episodes {
+		if ep.EpisodeNumber == episodeNumber {
+			return ep.Overview
+		}
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/lyrics"
+)
+
+// Lyrics
+//
+// A track's lyrics are read from an .lrc or .txt file next to it when it has
+// one, and otherwise fetched from LRCLIB and cached in the database. Synced
+// lyrics come with the time of each line, so players can highlight the line
+// being sung. Lookups that find nothing are cached too, and tried again once
+// lyricsRetryAfter has passed.
+
+// SettingLyrics is "false" to never fetch lyrics
+const SettingLyrics = "lyrics_enabled"
+
+// Lyrics sources
+const (
+	LyricsSourceFile   = "file"
+	LyricsSourceLRCLIB = "lrclib"
+)
+
+// lyricsRetryAfter is how long a lookup that found nothing is trusted
+const lyricsRetryAfter = 7 * 24 * time.Hour
+
+// maxLyricsFileSize bounds the lyrics files read next to tracks
+const maxLyricsFileSize = 1 << 20
+
+// ErrLyricsDisabled is returned for lookups while lyrics are turned off
+var ErrLyricsDisabled = errors.New("lyrics lookups are turned off")
+
+// TrackLyrics are a track's lyrics as players get them
+type TrackLyrics struct {
+	TrackID      int64         `json:"trackId"`
+	Source       string        `json:"source"`
+	Synced       bool          `json:"synced"` // Lines have times
+	Instrumental bool          `json:"instrumental"`
+	Lines        []lyrics.Line `json:"lines"` // Empty unless synced
+	Plain        string        `json:"plain"`
+}
+
+// LyricsEnabled reports whether lyrics may be fetched
+func (s *Service) LyricsEnabled() bool {
+	value, _ := s.db.GetSetting(SettingLyrics)
+	return value != "false"
+}
+
+// TrackLyrics returns a track's lyrics, looking them up unless they're
+// cached. refresh looks them up again even if they are. It returns nil for
+// tracks without lyrics.
+func (s *Service) TrackLyrics(track *database.Track, refresh bool) (*TrackLyrics, error) {
+	if result := lyricsFile(track); result != nil {
+		return result, nil
+	}
+
+	cached, err := s.db.GetLyrics(track.ID)
+	if err != nil {
+		return nil, err
+	}
+	stale := cached == nil || (!cached.Found() && time.Since(cached.FetchedAt) > lyricsRetryAfter)
+	if refresh || stale {
+		if !s.LyricsEnabled() {
+			if cached == nil || refresh {
+				return nil, ErrLyricsDisabled
+			}
+		} else {
+			fetched, err := s.fetchLyrics(track)
+			if err != nil {
+				return nil, err
+			}
+			cached = fetched
+		}
+	}
+	if !cached.Found() {
+		return nil, nil
+	}
+	return newTrackLyrics(track.ID, cached.Source, cached.Synced, cached.Plain, cached.Instrumental), nil
+}
+
+// fetchLyrics looks a track's lyrics up on LRCLIB and caches what it finds,
+// or that it found nothing
+func (s *Service) fetchLyrics(track *database.Track) (*database.Lyrics, error) {
+	query := lyrics.Query{Title: track.Title, Duration: track.Duration}
+	if album, err := s.db.GetAlbum(track.AlbumID); err == nil {
+		query.Album = album.Title
+		if artist, err := s.db.GetArtist(album.ArtistID); err == nil {
+			query.Artist = artist.Name
+		}
+	}
+
+	found := &database.Lyrics{TrackID: track.ID, Source: LyricsSourceLRCLIB}
+	result, err := s.lyrics.Lookup(query)
+	switch {
+	case errors.Is(err, lyrics.ErrNotFound):
+		logger.Debugf("No lyrics found for %s - %s", query.Artist, query.Title)
+	case err != nil:
+		return nil, err
+	default:
+		found.Synced = result.SyncedLyrics
+		found.Plain = result.PlainLyrics
+		found.Instrumental = result.Instrumental
+	}
+	if err := s.db.SaveLyrics(found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// lyricsFile reads the lyrics in an .lrc or .txt file named after a track,
+// or returns nil when there's none
+func lyricsFile(track *database.Track) *TrackLyrics {
+	base := strings.TrimSuffix(track.Path, filepath.Ext(track.Path))
+	for _, ext := range []string{".lrc", ".txt"} {
+		info, err := os.Stat(base + ext)
+		if err != nil || info.IsDir() || info.Size() > maxLyricsFileSize {
+			continue
+		}
+		data, err := os.ReadFile(base + ext)
+		if err != nil {
+			continue
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			continue
+		}
+		if ext == ".lrc" {
+			return newTrackLyrics(track.ID, LyricsSourceFile, text, "", false)
+		}
+		return newTrackLyrics(track.ID, LyricsSourceFile, "", text, false)
+	}
+	return nil
+}
+
+// newTrackLyrics parses LRC lyrics into timed lines, falling back to plain
+// lyrics when they have no timestamps
+func newTrackLyrics(trackID int64, source, synced, plain string, instrumental bool) *TrackLyrics {
+	result := &TrackLyrics{TrackID: trackID, Source: source, Instrumental: instrumental, Lines: []lyrics.Line{}}
+	if synced != "" {
+		if lines := lyrics.ParseLRC(synced); len(lines) > 0 {
+			result.Synced = true
+			result.Lines = lines
+			if plain == "" {
+				plain = lyrics.PlainText(lines)
+			}
+		} else if plain == "" {
+			plain = synced
+		}
+	}
+	result.Plain = strings.TrimSpace(plain)
+	return result
+}
@@ -0,0 +1,335 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/musicbrainz"
+)
+
+// Music metadata
+//
+// Artists and albums of music libraries get their metadata from MusicBrainz.
+// An artist is looked up by the MusicBrainz ID its files are tagged with, or
+// searched by name, and gets its sort name, and a bio and photo from the
+// Wikipedia article and Wikidata item MusicBrainz links it to. An album is
+// matched to a release the same way, falling back to AcoustID fingerprints
+// of its tracks when an AcoustID key is set, and gets its release group,
+// type, original year and cover from the Cover Art Archive. Its tracks get
+// their recordings and titles from the release's track list.
+
+// Music metadata settings
+const (
+	SettingMusicBrainz = "musicbrainz_enabled" // "false" to never look music up
+	SettingAcoustIDKey = "acoustid_api_key"    // AcoustID application key; empty to not fingerprint
+)
+
+// minMusicMatchScore is the lowest MusicBrainz search score taken as a match
+const minMusicMatchScore = 90
+
+// maxFingerprintedTracks is how many of an album's tracks are fingerprinted
+// at most when its release can't be found by name
+const maxFingerprintedTracks = 3
+
+// Image sizes for music artwork
+const musicImageWidth = 500
+
+// ErrMusicBrainzDisabled is returned for lookups while MusicBrainz is turned
+// off
+var ErrMusicBrainzDisabled = errors.New("MusicBrainz lookups are turned off")
+
+// albumYearPattern matches a year around an album's folder name, as in
+// "Abbey Road (1969)" or "1969 - Abbey Road"
+var albumYearPattern = regexp.MustCompile(`^\d{4}\s*-\s*|\s*[\(\[]\d{4}[\)\]]\s*$`)
+
+// MusicBrainzEnabled reports whether music may be looked up on MusicBrainz
+func (s *Service) MusicBrainzEnabled() bool {
+	value, _ := s.db.GetSetting(SettingMusicBrainz)
+	return value != "false"
+}
+
+// FetchArtistMetadata fills in an artist's metadata from MusicBrainz and
+// Wikipedia. An artist MusicBrainz doesn't know is left as it is, but
+// recorded as looked up.
+func (s *Service) FetchArtistMetadata(artist *database.Artist) error {
+	if !s.MusicBrainzEnabled() {
+		return ErrMusicBrainzDisabled
+	}
+
+	mbid := ""
+	if artist.MusicBrainzID != nil {
+		mbid = *artist.MusicBrainzID
+	}
+	if mbid == "" {
+		results, err := s.musicBrainz.SearchArtists(artist.Name)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Score >= minMusicMatchScore && strings.EqualFold(result.Name, artist.Name) {
+				mbid = result.ID
+				break
+			}
+		}
+	}
+	if mbid == "" {
+		logger.Infof("No MusicBrainz match for artist %s", artist.Name)
+		return s.db.UpdateArtistMetadata(artist)
+	}
+
+	found, err := s.musicBrainz.GetArtist(mbid)
+	if err != nil {
+		return err
+	}
+	artist.MusicBrainzID = &found.ID
+	if found.SortName != "" {
+		artist.SortName = &found.SortName
+	}
+
+	// The bio and photo are extras; the artist is still matched without them
+	if id := found.WikidataID(); id != "" {
+		if item, err := s.musicBrainz.GetWikidataItem(id); err != nil {
+			logger.Warnf("Failed to get Wikidata item %s for %s: %v", id, artist.Name, err)
+		} else {
+			if bio := s.wikipediaSummary(item); bio != "" {
+				artist.Overview = &bio
+			}
+			if file := item.ImageFile(); file != "" {
+				image, err := s.musicBrainz.GetCommonsImage(file, musicImageWidth)
+				if err == nil && image != nil {
+					if path, err := s.saveMusicImage("artists", found.ID, image); err == nil {
+						artist.ImagePath = &path
+					} else {
+						logger.Warnf("Failed to save photo of %s: %v", artist.Name, err)
+					}
+				}
+			}
+		}
+	}
+	return s.db.UpdateArtistMetadata(artist)
+}
+
+// FetchAlbumMetadata fills in an album's and its tracks' metadata from
+// MusicBrainz. An album no release is found for is left as it is, but
+// recorded as looked up.
+func (s *Service) FetchAlbumMetadata(album *database.Album) error {
+	if !s.MusicBrainzEnabled() {
+		return ErrMusicBrainzDisabled
+	}
+	artist, err := s.db.GetArtist(album.ArtistID)
+	if err != nil {
+		return err
+	}
+	tracks, err := s.db.GetTracksByAlbum(album.ID)
+	if err != nil {
+		return err
+	}
+
+	releaseID := ""
+	if album.MusicBrainzID != nil {
+		releaseID = *album.MusicBrainzID
+	}
+	if releaseID == "" {
+		releaseID, err = s.findRelease(album, artist, len(tracks))
+		if err != nil {
+			return err
+		}
+	}
+	if releaseID == "" {
+		releaseID = s.fingerprintRelease(album, tracks)
+	}
+	if releaseID == "" {
+		logger.Infof("No MusicBrainz match for album %s by %s", album.Title, artist.Name)
+		return s.db.UpdateAlbumMetadata(album)
+	}
+
+	release, err := s.musicBrainz.GetRelease(releaseID)
+	if err != nil {
+		return err
+	}
+	album.MusicBrainzID = &release.ID
+	album.Title = release.Title
+	if group := release.ReleaseGroup; group.ID != "" {
+		album.ReleaseGroupID = &group.ID
+		if group.PrimaryType != "" {
+			album.ReleaseType = &group.PrimaryType
+		}
+		if year := musicbrainz.Year(group.FirstReleaseDate); year > 0 {
+			album.Year = year
+		}
+	}
+	if album.Year == 0 {
+		album.Year = musicbrainz.Year(release.Date)
+	}
+	if album.CoverPath == nil {
+		if path := s.albumCover(release); path != "" {
+			album.CoverPath = &path
+		}
+	}
+
+	for i := range tracks {
+		if s.matchTrack(&tracks[i], release) {
+			if err := s.db.UpdateTrackMetadata(&tracks[i]); err != nil {
+				logger.Errorf("Failed to save metadata of track %s: %v", tracks[i].Title, err)
+			}
+		}
+	}
+	return s.db.UpdateAlbumMetadata(album)
+}
+
+// findRelease searches MusicBrainz for an album's release, preferring
+// official ones with as many tracks as the album has. Returns "" if none
+// matches.
+func (s *Service) findRelease(album *database.Album, artist *database.Artist, trackCount int) (string, error) {
+	title := strings.TrimSpace(albumYearPattern.ReplaceAllString(album.Title, ""))
+	artistID := ""
+	if artist.MusicBrainzID != nil {
+		artistID = *artist.MusicBrainzID
+	}
+	results, err := s.musicBrainz.SearchReleases(title, artist.Name, artistID)
+	if err != nil {
+		return "", err
+	}
+
+	best, bestRank := "", -1
+	for _, release := range results {
+		if release.Score < minMusicMatchScore {
+			continue
+		}
+		rank := release.Score
+		if release.Status == "Official" {
+			rank += 10
+		}
+		if trackCount > 0 && release.TrackCount == trackCount {
+			rank += 20
+		}
+		if rank > bestRank {
+			best, bestRank = release.ID, rank
+		}
+	}
+	return best, nil
+}
+
+// fingerprintRelease identifies an album's release by fingerprinting a few
+// of its tracks on AcoustID, taking the release most of them are on. Returns
+// "" without an AcoustID key, fpcalc or a match.
+func (s *Service) fingerprintRelease(album *database.Album, tracks []database.Track) string {
+	apiKey, _ := s.db.GetSetting(SettingAcoustIDKey)
+	if apiKey == "" || len(tracks) == 0 || !musicbrainz.FingerprintAvailable() {
+		return ""
+	}
+
+	votes := make(map[string]int)
+	best := ""
+	for i := 0; i < len(tracks) && i < maxFingerprintedTracks; i++ {
+		fp, err := musicbrainz.FingerprintFile(tracks[i].Path)
+		if err != nil {
+			logger.Warnf("Failed to fingerprint %s: %v", tracks[i].Path, err)
+			continue
+		}
+		matches, err := s.musicBrainz.LookupFingerprint(apiKey, fp)
+		if err != nil {
+			logger.Warnf("AcoustID lookup failed for %s: %v", tracks[i].Path, err)
+			return best
+		}
+		// Each track votes once for every release its best recording is on
+		if len(matches) == 0 {
+			continue
+		}
+		for _, id := range matches[0].ReleaseIDs {
+			votes[id]++
+			if votes[id] > votes[best] {
+				best = id
+			}
+		}
+	}
+	if best != "" {
+		logger.Infof("Matched album %s by fingerprint to release %s", album.Title, best)
+	}
+	return best
+}
+
+// matchTrack fills in a track from its place on a release, reporting whether
+// the release has it
+func (s *Service) matchTrack(track *database.Track, release *musicbrainz.Release) bool {
+	for _, medium := range release.Media {
+		// Single disc releases match whatever disc the track is tagged with
+		if len(release.Media) > 1 && medium.Position != track.DiscNumber {
+			continue
+		}
+		for _, t := range medium.Tracks {
+			if t.Position != track.TrackNumber {
+				continue
+			}
+			recordingID := t.Recording.ID
+			track.MusicBrainzID = &recordingID
+			if t.Title != "" {
+				track.Title = t.Title
+			}
+			track.DiscNumber = medium.Position
+			if track.Duration == 0 && t.Length > 0 {
+				track.Duration = (t.Length + 500) / 1000
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// albumCover downloads a release's cover, or its release group's when the
+// release has none, returning where it's cached or ""
+func (s *Service) albumCover(release *musicbrainz.Release) string {
+	image, err := s.musicBrainz.GetReleaseCover(release.ID)
+	if err == nil && image == nil && release.ReleaseGroup.ID != "" {
+		image, err = s.musicBrainz.GetReleaseGroupCover(release.ReleaseGroup.ID)
+	}
+	if err != nil {
+		logger.Warnf("Failed to get cover of %s: %v", release.Title, err)
+		return ""
+	}
+	if image == nil {
+		return ""
+	}
+	path, err := s.saveMusicImage("albums", release.ID, image)
+	if err != nil {
+		logger.Warnf("Failed to save cover of %s: %v", release.Title, err)
+		return ""
+	}
+	return path
+}
+
+// wikipediaSummary returns the opening of a Wikidata item's Wikipedia
+// article in the first metadata language that has one, or English
+func (s *Service) wikipediaSummary(item *musicbrainz.WikidataItem) string {
+	for _, lang := range append(s.Languages(), "en") {
+		title := item.WikipediaTitle(lang)
+		if title == "" {
+			continue
+		}
+		summary, err := s.musicBrainz.GetWikipediaSummary(lang, title)
+		if err != nil {
+			logger.Warnf("Failed to get Wikipedia summary of %s: %v", title, err)
+			return ""
+		}
+		return summary
+	}
+	return ""
+}
+
+// saveMusicImage caches an artist photo or album cover, returning its path
+// key in the image store
+func (s *Service) saveMusicImage(kind, id string, image *musicbrainz.Image) (string, error) {
+	if s.images == nil {
+		return "", fmt.Errorf("no image store")
+	}
+	path := filepath.Join("music", kind, id+image.Extension())
+	if err := blobstore.WriteFile(s.images, path, image.Data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,201 @@
+package metadata
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Metadata providers
+//
+// Movies and shows get their metadata from a chain of providers set per
+// library. Each provider is asked in turn until one matches; a provider with
+// no match, or one that's rate limited or failing, passes to the next.
+// Providers register themselves by name, so adding one is a new file with an
+// init function calling RegisterProvider.
+
+// DefaultProviders is the provider order for libraries that don't set one
+var DefaultProviders = []string{"tmdb", "tvdb", "omdb", "nfo"}
+
+var (
+	// ErrNoMatch is returned by a provider that doesn't know the title
+	ErrNoMatch = errors.New("no metadata match")
+	// ErrRateLimited is returned by a provider that's asked us to slow down
+	ErrRateLimited = errors.New("metadata provider rate limited")
+)
+
+// Provider supplies metadata for movies and shows. FetchMovie and FetchShow
+// fill in the fields they know; saving the row is left to the caller.
+type Provider interface {
+	// Name is the key libraries use to list the provider
+	Name() string
+	// Configured reports whether the provider can be used, e.g. has an API key
+	Configured() bool
+	FetchMovie(movie *database.Movie) error
+	FetchShow(show *database.Show) error
+}
+
+// ProviderFactory creates a provider for a metadata service
+type ProviderFactory func(s *Service) Provider
+
+var (
+	providerMu        sync.RWMutex
+	providerFactories = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes a provider available to libraries under name.
+// Call it from an init function; services created afterwards include it.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	providerFactories[name] = factory
+}
+
+// newProviders creates every registered provider for a service
+func newProviders(s *Service) map[string]Provider {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+
+	providers := make(map[string]Provider, len(providerFactories))
+	for name, factory := range providerFactories {
+		providers[name] = factory(s)
+	}
+	return providers
+}
+
+// ProviderInfo describes a registered provider
+type ProviderInfo struct {
+	Name       string `json:"name"`
+	Configured bool   `json:"configured"`
+}
+
+// Providers lists the registered providers by name
+func (s *Service) Providers() []ProviderInfo {
+	infos := make([]ProviderInfo, 0, len(s.providers))
+	for name, p := range s.providers {
+		infos = append(infos, ProviderInfo{Name: name, Configured: p.Configured()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// HasProvider reports whether a provider is registered under name
+func (s *Service) HasProvider(name string) bool {
+	_, ok := s.providers[name]
+	return ok
+}
+
+// DB returns the database, for providers registered outside this package
+func (s *Service) DB() *database.Database {
+	return s.db
+}
+
+// libraryProviders returns the providers of a library in the order it wants
+// them asked
+func (s *Service) libraryProviders(libraryID int64) []Provider {
+	names := DefaultProviders
+	if lib, err := s.db.GetLibrary(libraryID); err == nil && len(lib.MetadataProviders) > 0 {
+		names = lib.MetadataProviders
+	}
+
+	var providers []Provider
+	for _, name := range names {
+		if p, ok := s.providers[name]; ok {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// fetchFromProviders asks a library's providers in turn until one matches.
+// Returns false with no error if none of them knew the title.
+func (s *Service) fetchFromProviders(libraryID int64, kind, title string, year int, fetch func(p Provider) error) (bool, error) {
+	var lastErr error
+	for _, p := range s.libraryProviders(libraryID) {
+		if !p.Configured() {
+			continue
+		}
+		err := fetch(p)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, ErrNoMatch):
+			logger.Infof("No %s results for %s: %s (%d)", p.Name(), kind, title, year)
+		default:
+			logger.Errorf("Metadata provider %s failed for %s %s (%d), trying next: %v", p.Name(), kind, title, year, err)
+			lastErr = err
+		}
+	}
+	return false, lastErr
+}
+
+// imageClient downloads artwork for providers that hand out full image URLs
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// CacheImage downloads an image URL into the image cache and returns its
+// local path, as stored in poster and backdrop fields
+func (s *Service) CacheImage(imageURL string) (string, error) {
+	if imageURL == "" {
+		return "", nil
+	}
+
+	sum := sha1.Sum([]byte(imageURL))
+	ext := strings.ToLower(path.Ext(imageURL))
+	if ext == "" || len(ext) > 5 {
+		ext = ".jpg"
+	}
+	localPath := filepath.Join("external", hex.EncodeToString(sum[:])+ext)
+	if blobstore.Exists(s.images, localPath) {
+		return localPath, nil
+	}
+
+	resp, err := imageClient.Get(imageURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download image: %d", resp.StatusCode)
+	}
+
+	if err := s.images.Put(localPath, resp.Body, blobstore.ContentType(localPath)); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// cacheLocalImage copies an image file from the library into the image cache
+func (s *Service) cacheLocalImage(src string) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%d", src, info.ModTime().Unix())))
+	localPath := filepath.Join("local", hex.EncodeToString(sum[:])+strings.ToLower(filepath.Ext(src)))
+	if blobstore.Exists(s.images, localPath) {
+		return localPath, nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	if err := s.images.Put(localPath, in, blobstore.ContentType(localPath)); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
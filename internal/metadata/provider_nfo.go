@@ -0,0 +1,240 @@
+package metadata
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Local NFO metadata provider
+
+func init() {
+	RegisterProvider("nfo", func(s *Service) Provider { return &nfoProvider{s: s} })
+}
+
+// nfoProvider reads Kodi-style .nfo files and artwork saved next to the
+// media, e.g. by another media manager. It needs no network access.
+type nfoProvider struct {
+	s *Service
+}
+
+// nfoInfo is the part of a Kodi movie.nfo / tvshow.nfo used here
+type nfoInfo struct {
+	Title         string  `xml:"title"`
+	OriginalTitle string  `xml:"originaltitle"`
+	Year          int     `xml:"year"`
+	Plot          string  `xml:"plot"`
+	Outline       string  `xml:"outline"`
+	Tagline       string  `xml:"tagline"`
+	Runtime       int     `xml:"runtime"`
+	MPAA          string  `xml:"mpaa"`
+	Rating        float64 `xml:"rating"`
+	Ratings       []struct {
+		Default bool    `xml:"default,attr"`
+		Value   float64 `xml:"value"`
+	} `xml:"ratings>rating"`
+	Genres    []string `xml:"genre"`
+	Directors []string `xml:"director"`
+	Credits   []string `xml:"credits"`
+	Studios   []string `xml:"studio"`
+	Country   []string `xml:"country"`
+	Premiered string   `xml:"premiered"`
+	Status    string   `xml:"status"`
+	IMDbID    string   `xml:"imdbid"`
+	TmdbID    string   `xml:"tmdbid"`
+	UniqueIDs []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"uniqueid"`
+	Actors []struct {
+		Name  string `xml:"name"`
+		Role  string `xml:"role"`
+		Order int    `xml:"order"`
+	} `xml:"actor"`
+}
+
+func (p *nfoProvider) Name() string { return "nfo" }
+
+func (p *nfoProvider) Configured() bool { return true }
+
+func (p *nfoProvider) FetchMovie(movie *database.Movie) error {
+	dir := filepath.Dir(movie.Path)
+	base := strings.TrimSuffix(filepath.Base(movie.Path), filepath.Ext(movie.Path))
+	info, err := readNFO(filepath.Join(dir, base+".nfo"), filepath.Join(dir, "movie.nfo"))
+	if err != nil {
+		return err
+	}
+
+	if id := info.uniqueID("tmdb", info.TmdbID); id != "" {
+		if tmdbID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			movie.TmdbID = &tmdbID
+		}
+	}
+	if id := info.uniqueID("imdb", info.IMDbID); id != "" {
+		movie.ImdbID = &id
+	}
+	if info.OriginalTitle != "" && info.OriginalTitle != info.Title {
+		movie.OriginalTitle = &info.OriginalTitle
+	}
+	movie.Overview = info.plot()
+	movie.Tagline = nfoValue(info.Tagline)
+	movie.ContentRating = nfoValue(info.MPAA)
+	movie.Director = nfoValue(strings.Join(info.Directors, ", "))
+	movie.Writer = nfoValue(strings.Join(info.Credits, ", "))
+	movie.Studios = nfoValue(strings.Join(info.Studios, ", "))
+	if len(info.Country) > 0 {
+		movie.Country = &info.Country[0]
+	}
+	if info.Runtime > 0 {
+		movie.Runtime = &info.Runtime
+	}
+	if rating := info.rating(); rating > 0 {
+		movie.Rating = &rating
+	}
+	if info.Premiered != "" {
+		movie.TheatricalRelease = &info.Premiered
+	}
+	movie.Genres = info.genres()
+	movie.Cast = info.cast()
+	movie.PosterPath = p.artwork(dir, base+"-poster.jpg", "poster.jpg", "folder.jpg")
+	movie.BackdropPath = p.artwork(dir, base+"-fanart.jpg", "fanart.jpg", "backdrop.jpg")
+	return nil
+}
+
+func (p *nfoProvider) FetchShow(show *database.Show) error {
+	info, err := readNFO(filepath.Join(show.Path, "tvshow.nfo"))
+	if err != nil {
+		return err
+	}
+
+	if id := info.uniqueID("tmdb", info.TmdbID); id != "" {
+		if tmdbID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			show.TmdbID = &tmdbID
+		}
+	}
+	if id := info.uniqueID("tvdb", ""); id != "" {
+		if tvdbID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			show.TvdbID = &tvdbID
+		}
+	}
+	if id := info.uniqueID("imdb", info.IMDbID); id != "" {
+		show.ImdbID = &id
+	}
+	if info.OriginalTitle != "" && info.OriginalTitle != info.Title {
+		show.OriginalTitle = &info.OriginalTitle
+	}
+	if info.Year > 0 {
+		show.Year = info.Year
+	}
+	show.Overview = info.plot()
+	show.ContentRating = nfoValue(info.MPAA)
+	show.Status = nfoValue(info.Status)
+	if len(info.Studios) > 0 {
+		show.Network = &info.Studios[0]
+	}
+	if rating := info.rating(); rating > 0 {
+		show.Rating = &rating
+	}
+	show.Genres = info.genres()
+	show.Cast = info.cast()
+	show.PosterPath = p.artwork(show.Path, "poster.jpg", "folder.jpg")
+	show.BackdropPath = p.artwork(show.Path, "fanart.jpg", "backdrop.jpg")
+	return nil
+}
+
+// artwork caches the first of the named images that's in dir
+func (p *nfoProvider) artwork(dir string, names ...string) *string {
+	for _, name := range names {
+		path, err := p.s.cacheLocalImage(filepath.Join(dir, name))
+		if err == nil {
+			return &path
+		}
+	}
+	return nil
+}
+
+// readNFO parses the first of the given NFO files that exists
+func readNFO(paths ...string) (*nfoInfo, error) {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var info nfoInfo
+		if err := xml.Unmarshal(data, &info); err != nil {
+			// Some tools write a bare URL instead of XML; there's nothing to read
+			return nil, ErrNoMatch
+		}
+		return &info, nil
+	}
+	return nil, ErrNoMatch
+}
+
+// uniqueID returns an ID of the given type, falling back to the legacy tag
+func (n *nfoInfo) uniqueID(idType, legacy string) string {
+	for _, id := range n.UniqueIDs {
+		if strings.EqualFold(id.Type, idType) && strings.TrimSpace(id.Value) != "" {
+			return strings.TrimSpace(id.Value)
+		}
+	}
+	return strings.TrimSpace(legacy)
+}
+
+func (n *nfoInfo) plot() *string {
+	if n.Plot != "" {
+		return &n.Plot
+	}
+	return nfoValue(n.Outline)
+}
+
+// rating prefers the default of the <ratings> list over the legacy tag
+func (n *nfoInfo) rating() float64 {
+	for _, r := range n.Ratings {
+		if r.Default {
+			return r.Value
+		}
+	}
+	if n.Rating == 0 && len(n.Ratings) > 0 {
+		return n.Ratings[0].Value
+	}
+	return n.Rating
+}
+
+// genres converts NFO genres to the JSON array TMDB genres use
+func (n *nfoInfo) genres() *string {
+	if len(n.Genres) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(n.Genres)
+	genres := string(data)
+	return &genres
+}
+
+// cast converts NFO actors to the cast JSON TMDB credits use
+func (n *nfoInfo) cast() *string {
+	if len(n.Actors) == 0 {
+		return nil
+	}
+	cast := make([]tmdb.CastMember, len(n.Actors))
+	for i, a := range n.Actors {
+		cast[i] = tmdb.CastMember{Name: a.Name, Character: a.Role, Order: a.Order}
+	}
+	data := tmdb.CastToJSON(cast, 0)
+	return &data
+}
+
+func nfoValue(value string) *string {
+	if value = strings.TrimSpace(value); value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// OMDb metadata provider
+
+const omdbBaseURL = "https://www.omdbapi.com/"
+
+func init() {
+	RegisterProvider("omdb", func(s *Service) Provider {
+		return &omdbProvider{s: s, client: &http.Client{Timeout: 30 * time.Second}}
+	})
+}
+
+// omdbProvider matches against OMDb, which mirrors IMDb. It has no cast
+// photos or episode data but knows titles TMDB sometimes misses.
+type omdbProvider struct {
+	s      *Service
+	client *http.Client
+}
+
+// omdbResult is a title lookup response from OMDb
+type omdbResult struct {
+	Response   string `json:"Response"`
+	Error      string `json:"Error"`
+	Title      string `json:"Title"`
+	Year       string `json:"Year"`
+	Rated      string `json:"Rated"`
+	Runtime    string `json:"Runtime"`
+	Genre      string `json:"Genre"`
+	Director   string `json:"Director"`
+	Writer     string `json:"Writer"`
+	Actors     string `json:"Actors"`
+	Plot       string `json:"Plot"`
+	Country    string `json:"Country"`
+	Language   string `json:"Language"`
+	Poster     string `json:"Poster"`
+	ImdbRating string `json:"imdbRating"`
+	ImdbID     string `json:"imdbID"`
+	Production string `json:"Production"`
+}
+
+func (p *omdbProvider) Name() string { return "omdb" }
+
+func (p *omdbProvider) apiKey() string {
+	key, _ := p.s.db.GetSetting("omdb_api_key")
+	return key
+}
+
+func (p *omdbProvider) Configured() bool { return p.apiKey() != "" }
+
+func (p *omdbProvider) lookup(title string, year int, kind string) (*omdbResult, error) {
+	params := url.Values{}
+	params.Set("apikey", p.apiKey())
+	params.Set("t", title)
+	params.Set("type", kind)
+	params.Set("plot", "full")
+	if year > 0 {
+		params.Set("y", strconv.Itoa(year))
+	}
+
+	resp, err := p.client.Get(omdbBaseURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// OMDb answers 401 once a key's daily request limit is used up
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: OMDb returned %d", ErrRateLimited, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OMDb API error: %d", resp.StatusCode)
+	}
+
+	var result omdbResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Response != "True" {
+		switch {
+		case strings.Contains(result.Error, "not found"):
+			return nil, ErrNoMatch
+		case strings.Contains(result.Error, "limit"):
+			return nil, fmt.Errorf("%w: %s", ErrRateLimited, result.Error)
+		}
+		return nil, fmt.Errorf("OMDb API error: %s", result.Error)
+	}
+	return &result, nil
+}
+
+func (p *omdbProvider) FetchMovie(movie *database.Movie) error {
+	result, err := p.lookup(movie.Title, movie.Year, "movie")
+	if err != nil {
+		return err
+	}
+
+	if result.ImdbID != "" {
+		movie.ImdbID = &result.ImdbID
+	}
+	movie.Overview = omdbValue(result.Plot)
+	movie.ContentRating = omdbValue(result.Rated)
+	movie.Director = omdbValue(result.Director)
+	movie.Writer = omdbValue(result.Writer)
+	movie.Studios = omdbValue(result.Production)
+	if country := omdbFirst(result.Country); country != "" {
+		movie.Country = &country
+	}
+	if runtime, err := strconv.Atoi(strings.TrimSuffix(result.Runtime, " min")); err == nil && runtime > 0 {
+		movie.Runtime = &runtime
+	}
+	if rating, err := strconv.ParseFloat(result.ImdbRating, 64); err == nil && rating > 0 {
+		movie.Rating = &rating
+	}
+	movie.Genres = omdbGenres(result.Genre)
+	movie.Cast = omdbCast(result.Actors)
+	movie.PosterPath = p.poster(result.Poster)
+	return nil
+}
+
+func (p *omdbProvider) FetchShow(show *database.Show) error {
+	result, err := p.lookup(show.Title, show.Year, "series")
+	if err != nil {
+		return err
+	}
+
+	if result.ImdbID != "" {
+		show.ImdbID = &result.ImdbID
+	}
+	show.Overview = omdbValue(result.Plot)
+	show.ContentRating = omdbValue(result.Rated)
+	if rating, err := strconv.ParseFloat(result.ImdbRating, 64); err == nil && rating > 0 {
+		show.Rating = &rating
+	}
+	show.Genres = omdbGenres(result.Genre)
+	show.Cast = omdbCast(result.Actors)
+	show.PosterPath = p.poster(result.Poster)
+	return nil
+}
+
+func (p *omdbProvider) poster(posterURL string) *string {
+	if omdbValue(posterURL) == nil {
+		return nil
+	}
+	path, err := p.s.CacheImage(posterURL)
+	if err != nil || path == "" {
+		return nil
+	}
+	return &path
+}
+
+// omdbValue returns nil for the empty and "N/A" values OMDb uses for unknowns
+func omdbValue(value string) *string {
+	if value == "" || value == "N/A" {
+		return nil
+	}
+	return &value
+}
+
+// omdbFirst returns the first entry of a comma-separated OMDb list
+func omdbFirst(list string) string {
+	if omdbValue(list) == nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Split(list, ",")[0])
+}
+
+// omdbGenres converts OMDb's genre list to the JSON array TMDB genres use
+func omdbGenres(list string) *string {
+	if omdbValue(list) == nil {
+		return nil
+	}
+	var genres []tmdb.Genre
+	for _, name := range strings.Split(list, ",") {
+		genres = append(genres, tmdb.Genre{Name: strings.TrimSpace(name)})
+	}
+	data := tmdb.GenresToJSON(genres)
+	return &data
+}
+
+// omdbCast converts OMDb's lead actors to the cast JSON TMDB credits use
+func omdbCast(list string) *string {
+	if omdbValue(list) == nil {
+		return nil
+	}
+	var cast []tmdb.CastMember
+	for i, name := range strings.Split(list, ",") {
+		cast = append(cast, tmdb.CastMember{Name: strings.TrimSpace(name), Order: i})
+	}
+	data := tmdb.CastToJSON(cast, 0)
+	return &data
+}
@@ -0,0 +1,335 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// TMDB metadata provider
+
+func init() {
+	RegisterProvider("tmdb", func(s *Service) Provider { return &tmdbProvider{s: s} })
+}
+
+// tmdbProvider matches by title and year against TMDB. It also fills in
+// seasons and episodes of shows and creates TMDB collections for movies.
+type tmdbProvider struct {
+	s *Service
+}
+
+func (p *tmdbProvider) Name() string { return "tmdb" }
+
+func (p *tmdbProvider) Configured() bool { return p.s.Configured() }
+
+func (p *tmdbProvider) FetchMovie(movie *database.Movie) error {
+	searchResult, err := p.s.tmdb.SearchMovie(movie.Title, movie.Year)
+	if err != nil {
+		return tmdbError(err)
+	}
+	if len(searchResult.Results) == 0 {
+		return ErrNoMatch
+	}
+
+	// Use the first result (best match)
+	return tmdbError(p.s.applyTMDBMovie(movie, searchResult.Results[0].ID))
+}
+
+func (p *tmdbProvider) FetchShow(show *database.Show) error {
+	searchResult, err := p.s.tmdb.SearchTV(show.Title, show.Year)
+	if err != nil {
+		return tmdbError(err)
+	}
+	if len(searchResult.Results) == 0 {
+		return ErrNoMatch
+	}
+
+	// Use the first result
+	return tmdbError(p.s.applyTMDBShow(show, searchResult.Results[0].ID))
+}
+
+// tmdbError marks TMDB rate limiting so the next provider is tried
+func tmdbError(err error) error {
+	if errors.Is(err, tmdb.ErrRateLimited) {
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	}
+	return err
+}
+
+// applyTMDBMovie fills in a movie from TMDB's details for tmdbID and sets up
+// the collection it belongs to
+func (s *Service) applyTMDBMovie(movie *database.Movie, tmdbID int64) error {
+	// Get detailed info
+	details, err := s.tmdb.GetMovieDetails(tmdbID)
+	if err != nil {
+		return err
+	}
+
+	// Get content rating
+	contentRating, _ := s.tmdb.GetMovieContentRating(tmdbID)
+
+	// Download and cache images
+	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
+	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
+
+	// Update movie with metadata
+	movie.TmdbID = &details.ID
+	if details.ImdbID != "" {
+		movie.ImdbID = &details.ImdbID
+	}
+	if details.OriginalTitle != "" && details.OriginalTitle != details.Title {
+		movie.OriginalTitle = &details.OriginalTitle
+	}
+	if details.Overview != "" {
+		movie.Overview = &details.Overview
+	}
+	if details.Tagline != "" {
+		movie.Tagline = &details.Tagline
+	}
+	if details.Runtime > 0 {
+		movie.Runtime = &details.Runtime
+	}
+	if details.VoteAverage > 0 {
+		movie.Rating = &details.VoteAverage
+	}
+	if contentRating != "" {
+		movie.ContentRating = &contentRating
+	}
+	if len(details.Genres) > 0 {
+		genres := tmdb.GenresToJSON(details.Genres)
+		movie.Genres = &genres
+	}
+	if len(details.Credits.Cast) > 0 {
+		cast := tmdb.CastToJSON(details.Credits.Cast, 0) // Full cast (0 = no limit)
+		movie.Cast = &cast
+	}
+	if len(details.Credits.Crew) > 0 {
+		crew := tmdb.CrewToJSON(details.Credits.Crew, 0) // Full crew (0 = no limit)
+		movie.Crew = &crew
+	}
+	director := tmdb.GetDirector(details.Credits.Crew)
+	if director != "" {
+		movie.Director = &director
+	}
+	writer := tmdb.GetWriter(details.Credits.Crew)
+	if writer != "" {
+		movie.Writer = &writer
+	}
+	editor := tmdb.GetEditor(details.Credits.Crew)
+	if editor != "" {
+		movie.Editor = &editor
+	}
+	producers := tmdb.GetProducers(details.Credits.Crew, 3)
+	if producers != "" {
+		movie.Producers = &producers
+	}
+	if details.Status != "" {
+		movie.Status = &details.Status
+	}
+	if details.Budget > 0 {
+		movie.Budget = &details.Budget
+	}
+	if details.Revenue > 0 {
+		movie.Revenue = &details.Revenue
+	}
+	if len(details.ProductionCountries) > 0 {
+		country := details.ProductionCountries[0].Name
+		movie.Country = &country
+	}
+	if details.OriginalLanguage != "" {
+		movie.OriginalLanguage = &details.OriginalLanguage
+	}
+	// Extract release dates
+	theatrical, digital := tmdb.GetUSReleaseDates(details.ReleaseDates)
+	if theatrical != "" {
+		movie.TheatricalRelease = &theatrical
+	}
+	if digital != "" {
+		movie.DigitalRelease = &digital
+	}
+	// Extract studios
+	studios := tmdb.GetStudios(details.ProductionCompanies)
+	if studios != "" {
+		movie.Studios = &studios
+	}
+	trailers := tmdb.TrailersToJSON(details.Videos)
+	if trailers != "" {
+		movie.Trailers = &trailers
+	}
+	if posterPath != "" {
+		movie.PosterPath = &posterPath
+	}
+	if backdropPath != "" {
+		movie.BackdropPath = &backdropPath
+	}
+	s.localizeMovie(movie, details)
+
+	// Process collection if movie belongs to one
+	if details.BelongsToCollection != nil {
+		s.processMovieCollection(movie, details.BelongsToCollection)
+	}
+
+	return nil
+}
+
+// applyTMDBShow fills in a show from TMDB's details for tmdbID, along with
+// its seasons and episodes
+func (s *Service) applyTMDBShow(show *database.Show, tmdbID int64) error {
+	// Get detailed info
+	details, err := s.tmdb.GetTVDetails(tmdbID)
+	if err != nil {
+		return err
+	}
+
+	// Get content rating
+	contentRating, _ := s.tmdb.GetTVContentRating(tmdbID)
+
+	// Download and cache images
+	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
+	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
+
+	// Update show with metadata
+	show.TmdbID = &details.ID
+	if details.ExternalIDs.TvdbID > 0 {
+		show.TvdbID = &details.ExternalIDs.TvdbID
+	}
+	if details.ExternalIDs.ImdbID != "" {
+		show.ImdbID = &details.ExternalIDs.ImdbID
+	}
+	if details.OriginalName != "" && details.OriginalName != details.Name {
+		show.OriginalTitle = &details.OriginalName
+	}
+	if details.FirstAirDate != "" && len(details.FirstAirDate) >= 4 {
+		year, _ := strconv.Atoi(details.FirstAirDate[:4])
+		if year > 0 {
+			show.Year = year
+		}
+	}
+	if details.Overview != "" {
+		show.Overview = &details.Overview
+	}
+	if details.Status != "" {
+		show.Status = &details.Status
+	}
+	if details.VoteAverage > 0 {
+		show.Rating = &details.VoteAverage
+	}
+	if contentRating != "" {
+		show.ContentRating = &contentRating
+	}
+	if len(details.Genres) > 0 {
+		genres := tmdb.GenresToJSON(details.Genres)
+		show.Genres = &genres
+	}
+	if len(details.Credits.Cast) > 0 {
+		cast := tmdb.CastToJSON(details.Credits.Cast, 0)
+		show.Cast = &cast
+	}
+	if len(details.Credits.Crew) > 0 {
+		crew := tmdb.CrewToJSON(details.Credits.Crew, 0)
+		show.Crew = &crew
+	}
+	if len(details.Networks) > 0 {
+		show.Network = &details.Networks[0].Name
+	}
+	if posterPath != "" {
+		show.PosterPath = &posterPath
+	}
+	if backdropPath != "" {
+		show.BackdropPath = &backdropPath
+	}
+	s.localizeShow(show, details)
+
+	// Fetch season and episode metadata
+	return s.fetchSeasonMetadata(show, details.ID, s.languageChain(details.OriginalLanguage))
+}
+
+// fetchSeasonMetadata fetches metadata for all seasons of a show, taking
+// overviews from the language chain when one is given
+func (s *Service) fetchSeasonMetadata(show *database.Show, showTmdbID int64, chain []string) error {
+	seasons, err := s.db.GetSeasonsByShow(show.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range seasons {
+		season := &seasons[i]
+
+		// Fetch season details from TMDB
+		seasonDetails, err := s.tmdb.GetSeasonDetails(showTmdbID, season.SeasonNumber)
+		if err != nil {
+			logger.Errorf("Failed to fetch season %d metadata: %v", season.SeasonNumber, err)
+			continue
+		}
+		if chain != nil {
+			localizeSeason(seasonDetails, s.localizedSeasonDetails(showTmdbID, season.SeasonNumber, chain))
+		}
+
+		// Download season poster
+		posterPath, _ := s.tmdb.DownloadImage(seasonDetails.PosterPath, "w500")
+
+		// Update season
+		if seasonDetails.Name != "" {
+			season.Name = &seasonDetails.Name
+		}
+		if seasonDetails.Overview != "" {
+			season.Overview = &seasonDetails.Overview
+		}
+		if posterPath != "" {
+			season.PosterPath = &posterPath
+		}
+		if seasonDetails.AirDate != "" {
+			season.AirDate = &seasonDetails.AirDate
+		}
+
+		if err := s.db.UpdateSeasonMetadata(season); err != nil {
+			logger.Errorf("Failed to update season %d metadata: %v", season.SeasonNumber, err)
+			continue
+		}
+
+		// Update episode metadata
+		episodes, err := s.db.GetEpisodesBySeason(season.ID)
+		if err != nil {
+			continue
+		}
+
+		for j := range episodes {
+			ep := &episodes[j]
+
+			// Find matching TMDB episode
+			for _, tmdbEp := range seasonDetails.Episodes {
+				if tmdbEp.EpisodeNumber == ep.EpisodeNumber {
+					// Download still image
+					stillPath, _ := s.tmdb.DownloadImage(tmdbEp.StillPath, "w300")
+
+					if tmdbEp.Name != "" {
+						ep.Title = tmdbEp.Name
+					}
+					if tmdbEp.Overview != "" {
+						ep.Overview = &tmdbEp.Overview
+					}
+					if tmdbEp.AirDate != "" {
+						ep.AirDate = &tmdbEp.AirDate
+					}
+					if tmdbEp.Runtime > 0 {
+						ep.Runtime = &tmdbEp.Runtime
+					}
+					if stillPath != "" {
+						ep.StillPath = &stillPath
+					}
+
+					if err := s.db.UpdateEpisodeMetadata(ep); err != nil {
+						logger.Errorf("Failed to update episode S%02dE%02d metadata: %v",
+							season.SeasonNumber, ep.EpisodeNumber, err)
+					}
+					break
+				}
+			}
+		}
+	}
+
+	return nil
+}
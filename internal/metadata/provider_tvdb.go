@@ -0,0 +1,246 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// TheTVDB metadata provider
+
+const tvdbBaseURL = "https://api4.thetvdb.com/v4"
+
+// tvdbTokenLifetime is how long a login token is reused; TVDB tokens last a
+// month, so refresh well before that
+const tvdbTokenLifetime = 24 * time.Hour
+
+func init() {
+	RegisterProvider("tvdb", func(s *Service) Provider {
+		return &tvdbProvider{s: s, client: &http.Client{Timeout: 30 * time.Second}}
+	})
+}
+
+// tvdbProvider matches against TheTVDB v4 API, which often knows shows (and
+// anime in particular) better than TMDB
+type tvdbProvider struct {
+	s      *Service
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	tokenKey string
+	tokenAt  time.Time
+}
+
+// tvdbSearchResult is an entry of a TVDB search response
+type tvdbSearchResult struct {
+	TvdbID      string            `json:"tvdb_id"`
+	Name        string            `json:"name"`
+	Year        string            `json:"year"`
+	Overview    string            `json:"overview"`
+	Overviews   map[string]string `json:"overviews"`
+	Network     string            `json:"network"`
+	ImageURL    string            `json:"image_url"`
+	Genres      []string          `json:"genres"`
+	RemoteIDs   []tvdbRemoteID    `json:"remote_ids"`
+	PrimaryLang string            `json:"primary_language"`
+	Status      string            `json:"status"`
+}
+
+type tvdbRemoteID struct {
+	ID         string `json:"id"`
+	SourceName string `json:"sourceName"`
+}
+
+func (p *tvdbProvider) Name() string { return "tvdb" }
+
+func (p *tvdbProvider) apiKey() string {
+	key, _ := p.s.db.GetSetting("tvdb_api_key")
+	return key
+}
+
+func (p *tvdbProvider) Configured() bool { return p.apiKey() != "" }
+
+// login returns a bearer token, logging in again when the key changed or the
+// token is old
+func (p *tvdbProvider) login() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.apiKey()
+	if p.token != "" && p.tokenKey == key && time.Since(p.tokenAt) < tvdbTokenLifetime {
+		return p.token, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"apikey": key})
+	resp, err := p.client.Post(tvdbBaseURL+"/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", fmt.Errorf("%w: TVDB returned %d", ErrRateLimited, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("TVDB login failed: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	p.token, p.tokenKey, p.tokenAt = result.Data.Token, key, time.Now()
+	return p.token, nil
+}
+
+// search returns the best TVDB match for a title
+func (p *tvdbProvider) search(title string, year int, kind string) (*tvdbSearchResult, error) {
+	token, err := p.login()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("query", title)
+	params.Set("type", kind)
+	params.Set("limit", "5")
+	if year > 0 {
+		params.Set("year", strconv.Itoa(year))
+	}
+
+	req, err := http.NewRequest("GET", tvdbBaseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: TVDB returned %d", ErrRateLimited, resp.StatusCode)
+	case http.StatusUnauthorized:
+		// Token expired early; log in again next time
+		p.mu.Lock()
+		p.token = ""
+		p.mu.Unlock()
+		return nil, fmt.Errorf("TVDB API error: %d", resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("TVDB API error: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []tvdbSearchResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) == 0 {
+		return nil, ErrNoMatch
+	}
+	return &result.Data[0], nil
+}
+
+func (p *tvdbProvider) FetchMovie(movie *database.Movie) error {
+	result, err := p.search(movie.Title, movie.Year, "movie")
+	if err != nil {
+		return err
+	}
+
+	if imdbID := result.remoteID("IMDB"); imdbID != "" {
+		movie.ImdbID = &imdbID
+	}
+	if tmdbID, err := strconv.ParseInt(result.remoteID("TheMovieDB.com"), 10, 64); err == nil {
+		movie.TmdbID = &tmdbID
+	}
+	movie.Overview = result.overview()
+	if len(result.Genres) > 0 {
+		genres, _ := json.Marshal(result.Genres)
+		g := string(genres)
+		movie.Genres = &g
+	}
+	if result.PrimaryLang != "" {
+		movie.OriginalLanguage = &result.PrimaryLang
+	}
+	movie.PosterPath = p.image(result.ImageURL)
+	return nil
+}
+
+func (p *tvdbProvider) FetchShow(show *database.Show) error {
+	result, err := p.search(show.Title, show.Year, "series")
+	if err != nil {
+		return err
+	}
+
+	if tvdbID, err := strconv.ParseInt(result.TvdbID, 10, 64); err == nil {
+		show.TvdbID = &tvdbID
+	}
+	if imdbID := result.remoteID("IMDB"); imdbID != "" {
+		show.ImdbID = &imdbID
+	}
+	if tmdbID, err := strconv.ParseInt(result.remoteID("TheMovieDB.com"), 10, 64); err == nil {
+		show.TmdbID = &tmdbID
+	}
+	if year, err := strconv.Atoi(result.Year); err == nil && year > 0 {
+		show.Year = year
+	}
+	show.Overview = result.overview()
+	if result.Network != "" {
+		show.Network = &result.Network
+	}
+	if result.Status != "" {
+		show.Status = &result.Status
+	}
+	if len(result.Genres) > 0 {
+		genres, _ := json.Marshal(result.Genres)
+		g := string(genres)
+		show.Genres = &g
+	}
+	show.PosterPath = p.image(result.ImageURL)
+	return nil
+}
+
+func (p *tvdbProvider) image(imageURL string) *string {
+	path, err := p.s.CacheImage(imageURL)
+	if err != nil || path == "" {
+		return nil
+	}
+	return &path
+}
+
+// overview prefers the English overview over the one in the original language
+func (r *tvdbSearchResult) overview() *string {
+	if eng := r.Overviews["eng"]; eng != "" {
+		return &eng
+	}
+	if r.Overview != "" {
+		return &r.Overview
+	}
+	return nil
+}
+
+// remoteID returns the ID another site uses for the title
+func (r *tvdbSearchResult) remoteID(source string) string {
+	for _, remote := range r.RemoteIDs {
+		if remote.SourceName == source {
+			return remote.ID
+		}
+	}
+	return ""
+}
@@ -1,31 +1,71 @@
 package metadata
 
 import (
-	"log"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/outpost/outpost/internal/blobstore"
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+	"github.com/outpost/outpost/internal/lyrics"
+	"github.com/outpost/outpost/internal/musicbrainz"
 	"github.com/outpost/outpost/internal/tmdb"
 )
 
+var logger = logging.Module("metadata")
+
 type Service struct {
-	db       *database.Database
-	tmdb     *tmdb.Client
-	imageDir string
+	db        *database.Database
+	tmdb      *tmdb.Client
+	images    blobstore.Store
+	providers map[string]Provider
+
+	musicBrainz *musicbrainz.Client
+	lyrics      *lyrics.Client
+
+	uiAssets   *UIAssetBundle // Built on first request
+	uiAssetsMu sync.Mutex
+
+	discoverFocal discoverFocal
+
+	titleLogos   map[string]string // Cached title logos by "movie:tmdbID:languages", "" for none
+	titleLogosMu sync.Mutex
 }
 
-func NewService(db *database.Database, apiKey, imageDir string) *Service {
-	return &Service{
-		db:       db,
-		tmdb:     tmdb.NewClient(apiKey, imageDir),
-		imageDir: imageDir,
+func NewService(db *database.Database, apiKey string, images blobstore.Store) *Service {
+	s := &Service{
+		db:          db,
+		tmdb:        tmdb.NewClient(apiKey, images),
+		images:      images,
+		musicBrainz: musicbrainz.NewClient(),
+		lyrics:      lyrics.NewClient(),
 	}
+	s.providers = newProviders(s)
+	return s
+}
+
+// Images returns the store cached artwork is kept in
+func (s *Service) Images() blobstore.Store {
+	return s.images
 }
 
 // UpdateAPIKey updates the TMDB client with a new API key
 func (s *Service) UpdateAPIKey(apiKey string) {
-	s.tmdb = tmdb.NewClient(apiKey, s.imageDir)
+	s.tmdb = tmdb.NewClient(apiKey, s.images)
+
+	// Provider logos and genres come from TMDB
+	s.uiAssetsMu.Lock()
+	s.uiAssets = nil
+	s.uiAssetsMu.Unlock()
+}
+
+// Configured reports whether a TMDB API key is set. Without one, metadata
+// lookups fail and callers should fall back to local data.
+func (s *Service) Configured() bool {
+	return s.tmdb != nil && s.tmdb.HasAPIKey()
 }
 
 // GetTMDBClient returns the TMDB client for direct API access
@@ -33,514 +73,144 @@ func (s *Service) GetTMDBClient() *tmdb.Client {
 	return s.tmdb
 }
 
-// FetchMovieMetadata fetches metadata from TMDB for a movie
+// FetchMovieMetadata fills in a movie's metadata from its library's
+// providers, falling back to the next one when a provider has no match
 func (s *Service) FetchMovieMetadata(movie *database.Movie) error {
-	// Search for the movie
-	searchResult, err := s.tmdb.SearchMovie(movie.Title, movie.Year)
-	if err != nil {
+	matched, err := s.fetchFromProviders(movie.LibraryID, "movie", movie.Title, movie.Year, func(p Provider) error {
+		return p.FetchMovie(movie)
+	})
+	if err != nil || !matched {
 		return err
 	}
-
-	if len(searchResult.Results) == 0 {
-		log.Printf("No TMDB results for movie: %s (%d)", movie.Title, movie.Year)
-		return nil
-	}
-
-	// Use the first result (best match)
-	bestMatch := searchResult.Results[0]
-
-	// Get detailed info
-	details, err := s.tmdb.GetMovieDetails(bestMatch.ID)
-	if err != nil {
-		return err
-	}
-
-	// Get content rating
-	contentRating, _ := s.tmdb.GetMovieContentRating(bestMatch.ID)
-
-	// Download and cache images
-	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
-	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
-
-	// Analyze focal point for backdrop
-	if backdropPath != "" {
-		focalX, focalY, _ := s.tmdb.AnalyzeFocalPoint(backdropPath)
-		movie.FocalX = &focalX
-		movie.FocalY = &focalY
-	}
-
-	// Update movie with metadata
-	movie.TmdbID = &details.ID
-	if details.ImdbID != "" {
-		movie.ImdbID = &details.ImdbID
-	}
-	if details.OriginalTitle != "" && details.OriginalTitle != details.Title {
-		movie.OriginalTitle = &details.OriginalTitle
-	}
-	if details.Overview != "" {
-		movie.Overview = &details.Overview
-	}
-	if details.Tagline != "" {
-		movie.Tagline = &details.Tagline
-	}
-	if details.Runtime > 0 {
-		movie.Runtime = &details.Runtime
-	}
-	if details.VoteAverage > 0 {
-		movie.Rating = &details.VoteAverage
-	}
-	if contentRating != "" {
-		movie.ContentRating = &contentRating
-	}
-	if len(details.Genres) > 0 {
-		genres := tmdb.GenresToJSON(details.Genres)
-		movie.Genres = &genres
-	}
-	if len(details.Credits.Cast) > 0 {
-		cast := tmdb.CastToJSON(details.Credits.Cast, 0) // Full cast (0 = no limit)
-		movie.Cast = &cast
-	}
-	if len(details.Credits.Crew) > 0 {
-		crew := tmdb.CrewToJSON(details.Credits.Crew, 0) // Full crew (0 = no limit)
-		movie.Crew = &crew
-	}
-	director := tmdb.GetDirector(details.Credits.Crew)
-	if director != "" {
-		movie.Director = &director
-	}
-	writer := tmdb.GetWriter(details.Credits.Crew)
-	if writer != "" {
-		movie.Writer = &writer
-	}
-	editor := tmdb.GetEditor(details.Credits.Crew)
-	if editor != "" {
-		movie.Editor = &editor
-	}
-	producers := tmdb.GetProducers(details.Credits.Crew, 3)
-	if producers != "" {
-		movie.Producers = &producers
-	}
-	if details.Status != "" {
-		movie.Status = &details.Status
-	}
-	if details.Budget > 0 {
-		movie.Budget = &details.Budget
-	}
-	if details.Revenue > 0 {
-		movie.Revenue = &details.Revenue
-	}
-	if len(details.ProductionCountries) > 0 {
-		country := details.ProductionCountries[0].Name
-		movie.Country = &country
-	}
-	if details.OriginalLanguage != "" {
-		movie.OriginalLanguage = &details.OriginalLanguage
-	}
-	// Extract release dates
-	theatrical, digital := tmdb.GetUSReleaseDates(details.ReleaseDates)
-	if theatrical != "" {
-		movie.TheatricalRelease = &theatrical
-	}
-	if digital != "" {
-		movie.DigitalRelease = &digital
-	}
-	// Extract studios
-	studios := tmdb.GetStudios(details.ProductionCompanies)
-	if studios != "" {
-		movie.Studios = &studios
-	}
-	trailers := tmdb.TrailersToJSON(details.Videos)
-	if trailers != "" {
-		movie.Trailers = &trailers
-	}
-	if posterPath != "" {
-		movie.PosterPath = &posterPath
-	}
-	if backdropPath != "" {
-		movie.BackdropPath = &backdropPath
-	}
-
-	// Save to database
-	if err := s.db.UpdateMovieMetadata(movie); err != nil {
-		return err
-	}
-
-	// Process collection if movie belongs to one
-	if details.BelongsToCollection != nil {
-		s.processMovieCollection(movie, details.BelongsToCollection)
-	}
-
-	return nil
+	movie.ArtworkPath = s.pickArtwork(movie.LibraryID, "movie", movie.TmdbID, movie.PosterPath, movie.BackdropPath)
+	movie.FocalX, movie.FocalY = s.backdropFocalPoint(movie.BackdropPath)
+	movie.Colors = s.artworkColors(movie.PosterPath, movie.BackdropPath)
+	return s.db.UpdateMovieMetadata(movie)
 }
 
 // FetchMovieMetadataByTmdbID fetches metadata for a specific TMDB ID (manual match)
 func (s *Service) FetchMovieMetadataByTmdbID(movie *database.Movie, tmdbID int64) error {
-	details, err := s.tmdb.GetMovieDetails(tmdbID)
-	if err != nil {
+	if err := s.applyTMDBMovie(movie, tmdbID); err != nil {
 		return err
 	}
-
-	contentRating, _ := s.tmdb.GetMovieContentRating(tmdbID)
-	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
-	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
-
-	// Analyze focal point for backdrop
-	if backdropPath != "" {
-		focalX, focalY, _ := s.tmdb.AnalyzeFocalPoint(backdropPath)
-		movie.FocalX = &focalX
-		movie.FocalY = &focalY
-	}
-
-	movie.TmdbID = &details.ID
-	if details.ImdbID != "" {
-		movie.ImdbID = &details.ImdbID
-	}
-	if details.OriginalTitle != "" && details.OriginalTitle != details.Title {
-		movie.OriginalTitle = &details.OriginalTitle
-	}
-	if details.Overview != "" {
-		movie.Overview = &details.Overview
-	}
-	if details.Tagline != "" {
-		movie.Tagline = &details.Tagline
-	}
-	if details.Runtime > 0 {
-		movie.Runtime = &details.Runtime
-	}
-	if details.VoteAverage > 0 {
-		movie.Rating = &details.VoteAverage
-	}
-	if contentRating != "" {
-		movie.ContentRating = &contentRating
-	}
-	if len(details.Genres) > 0 {
-		genres := tmdb.GenresToJSON(details.Genres)
-		movie.Genres = &genres
-	}
-	if len(details.Credits.Cast) > 0 {
-		cast := tmdb.CastToJSON(details.Credits.Cast, 0)
-		movie.Cast = &cast
-	}
-	director := tmdb.GetDirector(details.Credits.Crew)
-	if director != "" {
-		movie.Director = &director
-	}
-	writer := tmdb.GetWriter(details.Credits.Crew)
-	if writer != "" {
-		movie.Writer = &writer
-	}
-	editor := tmdb.GetEditor(details.Credits.Crew)
-	if editor != "" {
-		movie.Editor = &editor
-	}
-	producers := tmdb.GetProducers(details.Credits.Crew, 3)
-	if producers != "" {
-		movie.Producers = &producers
-	}
-	if details.Status != "" {
-		movie.Status = &details.Status
-	}
-	if details.Budget > 0 {
-		movie.Budget = &details.Budget
-	}
-	if details.Revenue > 0 {
-		movie.Revenue = &details.Revenue
-	}
-	if len(details.ProductionCountries) > 0 {
-		country := details.ProductionCountries[0].Name
-		movie.Country = &country
-	}
-	if details.OriginalLanguage != "" {
-		movie.OriginalLanguage = &details.OriginalLanguage
-	}
-	// Extract release dates
-	theatrical2, digital2 := tmdb.GetUSReleaseDates(details.ReleaseDates)
-	if theatrical2 != "" {
-		movie.TheatricalRelease = &theatrical2
-	}
-	if digital2 != "" {
-		movie.DigitalRelease = &digital2
-	}
-	// Extract studios
-	studios2 := tmdb.GetStudios(details.ProductionCompanies)
-	if studios2 != "" {
-		movie.Studios = &studios2
-	}
-	trailers := tmdb.TrailersToJSON(details.Videos)
-	if trailers != "" {
-		movie.Trailers = &trailers
-	}
-	if posterPath != "" {
-		movie.PosterPath = &posterPath
-	}
-	if backdropPath != "" {
-		movie.BackdropPath = &backdropPath
-	}
-
-	if err := s.db.UpdateMovieMetadata(movie); err != nil {
-		return err
-	}
-
-	// Process collection if movie belongs to one
-	if details.BelongsToCollection != nil {
-		s.processMovieCollection(movie, details.BelongsToCollection)
-	}
-
-	return nil
+	movie.ArtworkPath = s.pickArtwork(movie.LibraryID, "movie", movie.TmdbID, movie.PosterPath, movie.BackdropPath)
+	movie.FocalX, movie.FocalY = s.backdropFocalPoint(movie.BackdropPath)
+	movie.Colors = s.artworkColors(movie.PosterPath, movie.BackdropPath)
+	return s.db.UpdateMovieMetadata(movie)
 }
 
-// FetchShowMetadata fetches metadata from TMDB for a TV show
+// FetchShowMetadata fills in a show's metadata from its library's providers,
+// falling back to the next one when a provider has no match
 func (s *Service) FetchShowMetadata(show *database.Show) error {
-	// Search for the show
-	searchResult, err := s.tmdb.SearchTV(show.Title, show.Year)
-	if err != nil {
+	matched, err := s.fetchFromProviders(show.LibraryID, "show", show.Title, show.Year, func(p Provider) error {
+		return p.FetchShow(show)
+	})
+	if err != nil || !matched {
 		return err
 	}
+	show.ArtworkPath = s.pickArtwork(show.LibraryID, "show", show.TmdbID, show.PosterPath, show.BackdropPath)
+	show.FocalX, show.FocalY = s.backdropFocalPoint(show.BackdropPath)
+	show.Colors = s.artworkColors(show.PosterPath, show.BackdropPath)
+	return s.db.UpdateShowMetadata(show)
+}
 
-	if len(searchResult.Results) == 0 {
-		log.Printf("No TMDB results for show: %s (%d)", show.Title, show.Year)
-		return nil
-	}
-
-	// Use the first result
-	bestMatch := searchResult.Results[0]
-
-	// Get detailed info
-	details, err := s.tmdb.GetTVDetails(bestMatch.ID)
-	if err != nil {
+// FetchShowMetadataByTmdbID fetches metadata for a specific TMDB ID (manual match)
+func (s *Service) FetchShowMetadataByTmdbID(show *database.Show, tmdbID int64) error {
+	if err := s.applyTMDBShow(show, tmdbID); err != nil {
 		return err
 	}
+	show.ArtworkPath = s.pickArtwork(show.LibraryID, "show", show.TmdbID, show.PosterPath, show.BackdropPath)
+	show.FocalX, show.FocalY = s.backdropFocalPoint(show.BackdropPath)
+	show.Colors = s.artworkColors(show.PosterPath, show.BackdropPath)
+	return s.db.UpdateShowMetadata(show)
+}
 
-	// Get content rating
-	contentRating, _ := s.tmdb.GetTVContentRating(bestMatch.ID)
-
-	// Download and cache images
-	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
-	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
+// releaseDateRecheck is how long cached release dates are trusted before TMDB
+// is asked again (dates often move before a release)
+const releaseDateRecheck = 24 * time.Hour
 
-	// Analyze focal point for backdrop
-	if backdropPath != "" {
-		focalX, focalY, _ := s.tmdb.AnalyzeFocalPoint(backdropPath)
-		show.FocalX = &focalX
-		show.FocalY = &focalY
+// GetMovieReleaseDates returns a movie's US theatrical and digital release
+// dates, used for request ETAs and to hold off searching until the digital
+// release. Dates are cached and refreshed from TMDB once a day.
+func (s *Service) GetMovieReleaseDates(tmdbID int64) (theatrical, digital string, err error) {
+	cached, _ := s.db.GetMovieReleaseDates(tmdbID)
+	if cached != nil && time.Since(cached.CheckedAt) < releaseDateRecheck {
+		return cached.TheatricalRelease, cached.DigitalRelease, nil
 	}
 
-	// Update show with metadata
-	show.TmdbID = &details.ID
-	if details.ExternalIDs.TvdbID > 0 {
-		show.TvdbID = &details.ExternalIDs.TvdbID
-	}
-	if details.ExternalIDs.ImdbID != "" {
-		show.ImdbID = &details.ExternalIDs.ImdbID
-	}
-	if details.OriginalName != "" && details.OriginalName != details.Name {
-		show.OriginalTitle = &details.OriginalName
-	}
-	if details.FirstAirDate != "" && len(details.FirstAirDate) >= 4 {
-		year, _ := strconv.Atoi(details.FirstAirDate[:4])
-		if year > 0 {
-			show.Year = year
+	details, err := s.tmdb.GetMovieDetails(tmdbID)
+	if err != nil {
+		if cached != nil {
+			// Stale dates beat none; try TMDB again next time
+			return cached.TheatricalRelease, cached.DigitalRelease, nil
 		}
+		return "", "", err
 	}
-	if details.Overview != "" {
-		show.Overview = &details.Overview
-	}
-	if details.Status != "" {
-		show.Status = &details.Status
-	}
-	if details.VoteAverage > 0 {
-		show.Rating = &details.VoteAverage
-	}
-	if contentRating != "" {
-		show.ContentRating = &contentRating
-	}
-	if len(details.Genres) > 0 {
-		genres := tmdb.GenresToJSON(details.Genres)
-		show.Genres = &genres
-	}
-	if len(details.Credits.Cast) > 0 {
-		cast := tmdb.CastToJSON(details.Credits.Cast, 0)
-		show.Cast = &cast
-	}
-	if len(details.Credits.Crew) > 0 {
-		crew := tmdb.CrewToJSON(details.Credits.Crew, 0)
-		show.Crew = &crew
-	}
-	if len(details.Networks) > 0 {
-		show.Network = &details.Networks[0].Name
+	theatrical, digital = tmdb.GetUSReleaseDates(details.ReleaseDates)
+	if err := s.db.SaveMovieReleaseDates(tmdbID, theatrical, digital); err != nil {
+		logger.Errorf("Failed to cache release dates for tmdb=%d: %v", tmdbID, err)
 	}
-	if posterPath != "" {
-		show.PosterPath = &posterPath
-	}
-	if backdropPath != "" {
-		show.BackdropPath = &backdropPath
-	}
-
-	// Save show metadata
-	if err := s.db.UpdateShowMetadata(show); err != nil {
-		return err
-	}
-
-	// Fetch season and episode metadata
-	return s.fetchSeasonMetadata(show, details.ID)
+	return theatrical, digital, nil
 }
 
-// FetchShowMetadataByTmdbID fetches metadata for a specific TMDB ID (manual match)
-func (s *Service) FetchShowMetadataByTmdbID(show *database.Show, tmdbID int64) error {
+// GetShowFirstAirDate returns when a show's first episode aired (or will air)
+func (s *Service) GetShowFirstAirDate(tmdbID int64) (string, error) {
 	details, err := s.tmdb.GetTVDetails(tmdbID)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return details.FirstAirDate, nil
+}
 
-	contentRating, _ := s.tmdb.GetTVContentRating(tmdbID)
-	posterPath, _ := s.tmdb.DownloadImage(details.PosterPath, "w500")
-	backdropPath, _ := s.tmdb.DownloadImage(details.BackdropPath, "w1280")
-
-	// Analyze focal point for backdrop
-	if backdropPath != "" {
-		focalX, focalY, _ := s.tmdb.AnalyzeFocalPoint(backdropPath)
-		show.FocalX = &focalX
-		show.FocalY = &focalY
+// GetEpisodeSchedule returns the latest episode of a show to have aired and
+// the next one due, either of which is nil if TMDB doesn't know it
+func (s *Service) GetEpisodeSchedule(tmdbID int64) (last, next *tmdb.AiringEpisode, err error) {
+	details, err := s.tmdb.GetTVDetails(tmdbID)
+	if err != nil {
+		return nil, nil, err
 	}
+	return details.LastEpisodeToAir, details.NextEpisodeToAir, nil
+}
 
-	show.TmdbID = &details.ID
-	if details.ExternalIDs.TvdbID > 0 {
-		show.TvdbID = &details.ExternalIDs.TvdbID
-	}
-	if details.ExternalIDs.ImdbID != "" {
-		show.ImdbID = &details.ExternalIDs.ImdbID
-	}
-	if details.OriginalName != "" && details.OriginalName != details.Name {
-		show.OriginalTitle = &details.OriginalName
+// GetPersonMovieCredits returns the movies a person acted in
+func (s *Service) GetPersonMovieCredits(personID int64) ([]tmdb.PersonCreditCast, error) {
+	credits, err := s.tmdb.GetPersonCombinedCredits(personID)
+	if err != nil {
+		return nil, err
 	}
-	if details.FirstAirDate != "" && len(details.FirstAirDate) >= 4 {
-		year, _ := strconv.Atoi(details.FirstAirDate[:4])
-		if year > 0 {
-			show.Year = year
+	var movies []tmdb.PersonCreditCast
+	for _, credit := range credits.Cast {
+		if credit.MediaType == "movie" {
+			movies = append(movies, credit)
 		}
 	}
-	if details.Overview != "" {
-		show.Overview = &details.Overview
-	}
-	if details.Status != "" {
-		show.Status = &details.Status
-	}
-	if details.VoteAverage > 0 {
-		show.Rating = &details.VoteAverage
-	}
-	if contentRating != "" {
-		show.ContentRating = &contentRating
-	}
-	if len(details.Genres) > 0 {
-		genres := tmdb.GenresToJSON(details.Genres)
-		show.Genres = &genres
-	}
-	if len(details.Credits.Cast) > 0 {
-		cast := tmdb.CastToJSON(details.Credits.Cast, 0)
-		show.Cast = &cast
-	}
-	if len(details.Credits.Crew) > 0 {
-		crew := tmdb.CrewToJSON(details.Credits.Crew, 0)
-		show.Crew = &crew
-	}
-	if len(details.Networks) > 0 {
-		show.Network = &details.Networks[0].Name
-	}
-	if posterPath != "" {
-		show.PosterPath = &posterPath
-	}
-	if backdropPath != "" {
-		show.BackdropPath = &backdropPath
-	}
-
-	if err := s.db.UpdateShowMetadata(show); err != nil {
-		return err
-	}
-
-	return s.fetchSeasonMetadata(show, tmdbID)
+	return movies, nil
 }
 
-// fetchSeasonMetadata fetches metadata for all seasons of a show
-func (s *Service) fetchSeasonMetadata(show *database.Show, showTmdbID int64) error {
-	seasons, err := s.db.GetSeasonsByShow(show.ID)
+// GetAlternateTitles returns a movie or show's original title and the other
+// titles it's known by, those used in English-speaking countries first
+func (s *Service) GetAlternateTitles(mediaType string, tmdbID int64) (string, []string, error) {
+	var original string
+	var titles []tmdb.AlternativeTitle
+	var err error
+	if mediaType == "movie" {
+		original, titles, err = s.tmdb.GetMovieAlternativeTitles(tmdbID)
+	} else {
+		original, titles, err = s.tmdb.GetTVAlternativeTitles(tmdbID)
+	}
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	for i := range seasons {
-		season := &seasons[i]
-
-		// Fetch season details from TMDB
-		seasonDetails, err := s.tmdb.GetSeasonDetails(showTmdbID, season.SeasonNumber)
-		if err != nil {
-			log.Printf("Failed to fetch season %d metadata: %v", season.SeasonNumber, err)
-			continue
-		}
-
-		// Download season poster
-		posterPath, _ := s.tmdb.DownloadImage(seasonDetails.PosterPath, "w500")
-
-		// Update season
-		if seasonDetails.Name != "" {
-			season.Name = &seasonDetails.Name
-		}
-		if seasonDetails.Overview != "" {
-			season.Overview = &seasonDetails.Overview
-		}
-		if posterPath != "" {
-			season.PosterPath = &posterPath
-		}
-		if seasonDetails.AirDate != "" {
-			season.AirDate = &seasonDetails.AirDate
-		}
-
-		if err := s.db.UpdateSeasonMetadata(season); err != nil {
-			log.Printf("Failed to update season %d metadata: %v", season.SeasonNumber, err)
-			continue
-		}
-
-		// Update episode metadata
-		episodes, err := s.db.GetEpisodesBySeason(season.ID)
-		if err != nil {
-			continue
-		}
-
-		for j := range episodes {
-			ep := &episodes[j]
-
-			// Find matching TMDB episode
-			for _, tmdbEp := range seasonDetails.Episodes {
-				if tmdbEp.EpisodeNumber == ep.EpisodeNumber {
-					// Download still image
-					stillPath, _ := s.tmdb.DownloadImage(tmdbEp.StillPath, "w300")
-
-					if tmdbEp.Name != "" {
-						ep.Title = tmdbEp.Name
-					}
-					if tmdbEp.Overview != "" {
-						ep.Overview = &tmdbEp.Overview
-					}
-					if tmdbEp.AirDate != "" {
-						ep.AirDate = &tmdbEp.AirDate
-					}
-					if tmdbEp.Runtime > 0 {
-						ep.Runtime = &tmdbEp.Runtime
-					}
-					if stillPath != "" {
-						ep.StillPath = &stillPath
-					}
-
-					if err := s.db.UpdateEpisodeMetadata(ep); err != nil {
-						log.Printf("Failed to update episode S%02dE%02d metadata: %v",
-							season.SeasonNumber, ep.EpisodeNumber, err)
-					}
-					break
-				}
-			}
-		}
+	english := map[string]bool{"US": true, "GB": true, "CA": true, "AU": true}
+	sort.SliceStable(titles, func(i, j int) bool {
+		return english[titles[i].ISO31661] && !english[titles[j].ISO31661]
+	})
+	alternates := make([]string, 0, len(titles))
+	for _, t := range titles {
+		alternates = append(alternates, t.Title)
 	}
-
-	return nil
+	return original, alternates, nil
 }
 
 // SearchMovies searches TMDB for movies (for manual matching)
@@ -715,6 +385,7 @@ func (s *Service) convertMovieResults(result *tmdb.DiscoverMovieResult) *Discove
 			Rating:       r.VoteAverage,
 			Popularity:   r.Popularity,
 		}
+		items[i].FocalX, items[i].FocalY = s.discoverFocalPoint(r.BackdropPath)
 	}
 	return &DiscoverResult{
 		Page:         result.Page,
@@ -738,6 +409,7 @@ func (s *Service) convertTVResults(result *tmdb.DiscoverTVResult) *DiscoverResul
 			Rating:       r.VoteAverage,
 			Popularity:   r.Popularity,
 		}
+		items[i].FocalX, items[i].FocalY = s.discoverFocalPoint(r.BackdropPath)
 	}
 	return &DiscoverResult{
 		Page:         result.Page,
@@ -1079,7 +751,7 @@ func (s *Service) processMovieCollection(movie *database.Movie, tmdbColl *tmdb.M
 	// Collection doesn't exist, fetch full details from TMDB
 	collDetails, err := s.tmdb.GetCollectionDetails(tmdbColl.ID)
 	if err != nil {
-		log.Printf("Failed to fetch collection details for %s: %v", tmdbColl.Name, err)
+		logger.Errorf("Failed to fetch collection details for %s: %v", tmdbColl.Name, err)
 		return
 	}
 
@@ -1106,11 +778,11 @@ func (s *Service) processMovieCollection(movie *database.Movie, tmdbColl *tmdb.M
 	}
 
 	if err := s.db.CreateCollection(coll); err != nil {
-		log.Printf("Failed to create collection %s: %v", collDetails.Name, err)
+		logger.Errorf("Failed to create collection %s: %v", collDetails.Name, err)
 		return
 	}
 
-	log.Printf("Created collection: %s with %d movies", collDetails.Name, len(collDetails.Parts))
+	logger.Infof("Created collection: %s with %d movies", collDetails.Name, len(collDetails.Parts))
 
 	// Add all parts as collection items
 	for i, part := range collDetails.Parts {
@@ -1134,14 +806,16 @@ func (s *Service) processMovieCollection(movie *database.Movie, tmdbColl *tmdb.M
 			item.PosterPath = &partPoster
 		}
 
-		// Check if this movie is already in the library
-		existingMovie, err := s.db.GetMovieByTmdb(part.ID)
-		if err == nil && existingMovie != nil {
+		// Check if this movie is already in the library (the movie being
+		// matched may not be saved yet)
+		if part.ID == *movie.TmdbID {
+			item.MediaID = &movie.ID
+		} else if existingMovie, err := s.db.GetMovieByTmdb(part.ID); err == nil && existingMovie != nil {
 			item.MediaID = &existingMovie.ID
 		}
 
 		if err := s.db.AddCollectionItem(item); err != nil {
-			log.Printf("Failed to add collection item %s: %v", part.Title, err)
+			logger.Errorf("Failed to add collection item %s: %v", part.Title, err)
 		}
 	}
 }
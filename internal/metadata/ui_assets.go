@@ -0,0 +1,309 @@
+package metadata
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// UI asset bundle
+//
+// Streaming provider logos, genre icons and rating badges are kept on the
+// server so every client renders the same imagery. Logos are cached from TMDB;
+// genre icons and rating badges are generated SVGs. Each asset URL carries a
+// version tag that changes with its content, so clients can cache them for good.
+
+const (
+	// uiAssetMaxAge is how long a built bundle is served before it's rebuilt
+	uiAssetMaxAge = 24 * time.Hour
+	// uiAssetRegion is the region whose streaming providers are bundled
+	uiAssetRegion = "US"
+	// uiProviderLimit caps how many provider logos are bundled
+	uiProviderLimit = 60
+)
+
+// UIAsset is one image in the UI asset bundle
+type UIAsset struct {
+	Key   string `json:"key"` // Provider ID, genre slug or rating
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Color string `json:"color,omitempty"`
+}
+
+// UIAssetBundle is the set of images shared by all clients
+type UIAssetBundle struct {
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	Providers   []UIAsset `json:"providers"`
+	Genres      []UIAsset `json:"genres"`
+	Ratings     []UIAsset `json:"ratings"`
+}
+
+// defaultGenres are bundled when TMDB isn't configured
+var defaultGenres = []string{
+	"Action", "Action & Adventure", "Adventure", "Animation", "Comedy", "Crime",
+	"Documentary", "Drama", "Family", "Fantasy", "History", "Horror", "Kids",
+	"Music", "Mystery", "News", "Reality", "Romance", "Sci-Fi & Fantasy",
+	"Science Fiction", "Soap", "Talk", "Thriller", "TV Movie", "War",
+	"War & Politics", "Western",
+}
+
+// genreColors are the icon colors of well-known genres; others get one from
+// genrePalette
+var genreColors = map[string]string{
+	"action":          "#e53935",
+	"adventure":       "#fb8c00",
+	"animation":       "#8e24aa",
+	"comedy":          "#fdd835",
+	"crime":           "#546e7a",
+	"documentary":     "#43a047",
+	"drama":           "#3949ab",
+	"family":          "#00acc1",
+	"fantasy":         "#7e57c2",
+	"horror":          "#b71c1c",
+	"romance":         "#d81b60",
+	"science-fiction": "#1e88e5",
+	"thriller":        "#37474f",
+	"western":         "#a1887f",
+}
+
+var genrePalette = []string{"#5c6bc0", "#26a69a", "#ef6c00", "#ab47bc", "#789262", "#8d6e63", "#0097a7", "#c0ca33"}
+
+// ratingBadge is a content rating and its badge color
+type ratingBadge struct {
+	rating string
+	color  string
+}
+
+var ratingBadges = []ratingBadge{
+	{"G", "#2e7d32"},
+	{"PG", "#f9a825"},
+	{"PG-13", "#ef6c00"},
+	{"R", "#c62828"},
+	{"NC-17", "#6a1b9a"},
+	{"NR", "#616161"},
+	{"TV-Y", "#2e7d32"},
+	{"TV-Y7", "#558b2f"},
+	{"TV-G", "#2e7d32"},
+	{"TV-PG", "#f9a825"},
+	{"TV-14", "#ef6c00"},
+	{"TV-MA", "#c62828"},
+}
+
+// UIAssets returns the UI asset bundle, building it when there's none yet,
+// it's older than a day or refresh is set
+func (s *Service) UIAssets(refresh bool) (*UIAssetBundle, error) {
+	s.uiAssetsMu.Lock()
+	defer s.uiAssetsMu.Unlock()
+
+	if !refresh && s.uiAssets != nil && time.Since(s.uiAssets.GeneratedAt) < uiAssetMaxAge {
+		return s.uiAssets, nil
+	}
+
+	bundle, err := s.buildUIAssets(s.uiAssets)
+	if err != nil {
+		if s.uiAssets != nil {
+			logger.Errorf("UI assets: rebuild failed, keeping previous bundle: %v", err)
+			return s.uiAssets, nil
+		}
+		return nil, err
+	}
+	s.uiAssets = bundle
+	return bundle, nil
+}
+
+func (s *Service) buildUIAssets(previous *UIAssetBundle) (*UIAssetBundle, error) {
+	bundle := &UIAssetBundle{GeneratedAt: time.Now()}
+
+	providers, err := s.providerLogos()
+	if err != nil {
+		logger.Errorf("UI assets: failed to fetch provider logos: %v", err)
+		if previous != nil {
+			providers = previous.Providers
+		}
+	}
+	if providers == nil {
+		providers = []UIAsset{}
+	}
+	bundle.Providers = providers
+
+	for _, name := range s.genreNames() {
+		slug := assetSlug(name)
+		color := genreColors[slug]
+		if color == "" {
+			sum := sha1.Sum([]byte(slug))
+			color = genrePalette[int(sum[0])%len(genrePalette)]
+		}
+		url, err := s.writeUIAsset("genres", slug, genreIconSVG(name, color))
+		if err != nil {
+			return nil, err
+		}
+		bundle.Genres = append(bundle.Genres, UIAsset{Key: slug, Name: name, URL: url, Color: color})
+	}
+
+	for _, badge := range ratingBadges {
+		url, err := s.writeUIAsset("ratings", assetSlug(badge.rating), ratingBadgeSVG(badge.rating, badge.color))
+		if err != nil {
+			return nil, err
+		}
+		bundle.Ratings = append(bundle.Ratings, UIAsset{Key: badge.rating, Name: badge.rating, URL: url, Color: badge.color})
+	}
+
+	// The bundle version covers every asset URL, which carry their own tags
+	h := sha1.New()
+	for _, group := range [][]UIAsset{bundle.Providers, bundle.Genres, bundle.Ratings} {
+		for _, asset := range group {
+			fmt.Fprintf(h, "%s|%s|%s\n", asset.Key, asset.Name, asset.URL)
+		}
+	}
+	bundle.Version = hex.EncodeToString(h.Sum(nil))[:12]
+	return bundle, nil
+}
+
+// providerLogos caches the logos of the region's most prominent streaming
+// providers for movies and TV
+func (s *Service) providerLogos() ([]UIAsset, error) {
+	if !s.Configured() {
+		return nil, fmt.Errorf("TMDB API key not configured")
+	}
+
+	byID := make(map[int64]tmdb.WatchProvider)
+	for _, mediaType := range []string{"movie", "tv"} {
+		providers, err := s.tmdb.GetWatchProviders(mediaType, uiAssetRegion)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range providers {
+			if existing, ok := byID[p.ID]; !ok || p.DisplayPriority < existing.DisplayPriority {
+				byID[p.ID] = p
+			}
+		}
+	}
+
+	providers := make([]tmdb.WatchProvider, 0, len(byID))
+	for _, p := range byID {
+		if p.LogoPath != "" {
+			providers = append(providers, p)
+		}
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].DisplayPriority != providers[j].DisplayPriority {
+			return providers[i].DisplayPriority < providers[j].DisplayPriority
+		}
+		return providers[i].ID < providers[j].ID
+	})
+	if len(providers) > uiProviderLimit {
+		providers = providers[:uiProviderLimit]
+	}
+
+	assets := make([]UIAsset, 0, len(providers))
+	for _, p := range providers {
+		localPath, err := s.tmdb.DownloadImage(p.LogoPath, "w92")
+		if err != nil {
+			logger.Errorf("UI assets: failed to cache logo of %s: %v", p.Name, err)
+			continue
+		}
+		// TMDB image paths change whenever the image does
+		assets = append(assets, UIAsset{
+			Key:  strconv.FormatInt(p.ID, 10),
+			Name: p.Name,
+			URL:  "/images/" + filepath.ToSlash(localPath) + "?v=" + contentTag([]byte(p.LogoPath)),
+		})
+	}
+	return assets, nil
+}
+
+// genreNames returns the movie and TV genres, falling back to defaultGenres
+func (s *Service) genreNames() []string {
+	if !s.Configured() {
+		return defaultGenres
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, fetch := range []func() ([]tmdb.Genre, error){s.tmdb.GetMovieGenres, s.tmdb.GetTVGenres} {
+		genres, err := fetch()
+		if err != nil {
+			logger.Errorf("UI assets: failed to fetch genres: %v", err)
+			return defaultGenres
+		}
+		for _, g := range genres {
+			if !seen[g.Name] {
+				seen[g.Name] = true
+				names = append(names, g.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeUIAsset stores a generated SVG under ui/<kind>/ in the image cache and
+// returns its versioned URL. Unchanged files aren't rewritten.
+func (s *Service) writeUIAsset(kind, slug, svg string) (string, error) {
+	localPath := filepath.Join("ui", kind, slug+".svg")
+
+	if existing, err := blobstore.ReadFile(s.images, localPath); err != nil || string(existing) != svg {
+		if err := blobstore.WriteFile(s.images, localPath, []byte(svg)); err != nil {
+			return "", err
+		}
+	}
+	return "/images/" + filepath.ToSlash(localPath) + "?v=" + contentTag([]byte(svg)), nil
+}
+
+// genreIconSVG draws a round icon with a genre's initials
+func genreIconSVG(name, color string) string {
+	var initials []rune
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		initials = append(initials, unicode.ToUpper([]rune(word)[0]))
+		if len(initials) == 2 {
+			break
+		}
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64" viewBox="0 0 64 64">`+
+		`<circle cx="32" cy="32" r="32" fill="%s"/>`+
+		`<text x="32" y="41" font-family="Helvetica, Arial, sans-serif" font-size="24" font-weight="700" fill="#ffffff" text-anchor="middle">%s</text>`+
+		`</svg>`, color, html.EscapeString(string(initials)))
+}
+
+// ratingBadgeSVG draws an outlined badge with a content rating
+func ratingBadgeSVG(rating, color string) string {
+	width := 16 + 10*len(rating)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="24" viewBox="0 0 %d 24">`+
+		`<rect x="1" y="1" width="%d" height="22" rx="4" fill="#111111" stroke="%s" stroke-width="2"/>`+
+		`<text x="%d" y="17" font-family="Helvetica, Arial, sans-serif" font-size="14" font-weight="700" fill="#ffffff" text-anchor="middle">%s</text>`+
+		`</svg>`, width, width, width-2, color, width/2, html.EscapeString(rating))
+}
+
+// assetSlug turns a genre or rating into a file name ("Sci-Fi & Fantasy" ->
+// "sci-fi-fantasy")
+func assetSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// contentTag is the version tag of an asset
+func contentTag(content []byte) string {
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:4])
+}
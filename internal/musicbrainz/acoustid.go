@@ -0,0 +1,105 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// AcoustID
+//
+// Tracks nothing else identifies can be fingerprinted with Chromaprint's
+// fpcalc and looked up on AcoustID, which knows the MusicBrainz recordings
+// and releases of a fingerprint. It needs an AcoustID application key.
+
+const AcoustIDURL = "https://api.acoustid.org/v2/lookup"
+
+// fingerprintTimeout bounds how long fingerprinting a file may take
+const fingerprintTimeout = 2 * time.Minute
+
+// Fingerprint is a Chromaprint fingerprint of an audio file
+type Fingerprint struct {
+	Duration    float64 `json:"duration"`
+	Fingerprint string  `json:"fingerprint"`
+}
+
+// AcoustIDMatch is a recording a fingerprint matched, with the releases
+// it's on
+type AcoustIDMatch struct {
+	Score       float64
+	RecordingID string
+	ReleaseIDs  []string
+}
+
+// FingerprintAvailable reports whether fpcalc is installed
+func FingerprintAvailable() bool {
+	_, err := exec.LookPath("fpcalc")
+	return err == nil
+}
+
+// FingerprintFile fingerprints an audio file with fpcalc
+func FingerprintFile(path string) (*Fingerprint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fingerprintTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "fpcalc", "-json", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc failed: %w", err)
+	}
+	var fp Fingerprint
+	if err := json.Unmarshal(output, &fp); err != nil {
+		return nil, err
+	}
+	if fp.Fingerprint == "" {
+		return nil, fmt.Errorf("fpcalc returned no fingerprint")
+	}
+	return &fp, nil
+}
+
+// LookupFingerprint looks up the recordings a fingerprint matches on
+// AcoustID, best first
+func (c *Client) LookupFingerprint(apiKey string, fp *Fingerprint) ([]AcoustIDMatch, error) {
+	params := url.Values{}
+	params.Set("client", apiKey)
+	params.Set("meta", "recordings releaseids")
+	params.Set("duration", strconv.Itoa(int(fp.Duration)))
+	params.Set("fingerprint", fp.Fingerprint)
+
+	var result struct {
+		Status string `json:"status"`
+		Error  struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Results []struct {
+			Score      float64 `json:"score"`
+			Recordings []struct {
+				ID       string `json:"id"`
+				Releases []struct {
+					ID string `json:"id"`
+				} `json:"releases"`
+			} `json:"recordings"`
+		} `json:"results"`
+	}
+	if err := c.getJSON(AcoustIDURL+"?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if result.Status != "ok" {
+		return nil, fmt.Errorf("AcoustID lookup failed: %s", result.Error.Message)
+	}
+
+	var matches []AcoustIDMatch
+	for _, r := range result.Results {
+		for _, rec := range r.Recordings {
+			match := AcoustIDMatch{Score: r.Score, RecordingID: rec.ID}
+			for _, rel := range rec.Releases {
+				match.ReleaseIDs = append(match.ReleaseIDs, rel.ID)
+			}
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,271 @@
+package musicbrainz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MusicBrainz
+//
+// Artists, releases and recordings are looked up on MusicBrainz, album art
+// on the Cover Art Archive, and artist bios and photos on Wikidata and
+// Wikipedia, which MusicBrainz links artists to. MusicBrainz allows one
+// request a second, so requests to it wait their turn across all clients.
+
+const (
+	BaseURL        = "https://musicbrainz.org/ws/2"
+	CoverArtURL    = "https://coverartarchive.org"
+	WikidataURL    = "https://www.wikidata.org/wiki/Special:EntityData"
+	WikipediaURL   = "https://%s.wikipedia.org/api/rest_v1/page/summary/"
+	CommonsFileURL = "https://commons.wikimedia.org/wiki/Special:FilePath/"
+	// UserAgent identifies Outpost, as MusicBrainz asks of every client
+	UserAgent = "Outpost/1.0 ( https://github.com/outpost/outpost )"
+)
+
+// requestInterval is how long MusicBrainz wants between requests
+const requestInterval = time.Second
+
+// maxImageSize bounds the images downloaded
+const maxImageSize = 20 << 20
+
+// ErrNotFound is returned for lookups of things MusicBrainz doesn't have
+var ErrNotFound = errors.New("not found on MusicBrainz")
+
+var (
+	rateMu      sync.Mutex
+	lastRequest time.Time
+)
+
+// Client handles MusicBrainz, Cover Art Archive and Wikipedia requests
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient creates a new MusicBrainz client
+func NewClient() *Client {
+	return &Client{httpClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Artist is a MusicBrainz artist
+type Artist struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	SortName       string     `json:"sort-name"`
+	Type           string     `json:"type"`
+	Country        string     `json:"country"`
+	Disambiguation string     `json:"disambiguation"`
+	Score          int        `json:"score"` // Search results only
+	Relations      []Relation `json:"relations"`
+	Genres         []Genre    `json:"genres"`
+}
+
+// Relation is a link from an artist to another site
+type Relation struct {
+	Type string `json:"type"`
+	URL  struct {
+		Resource string `json:"resource"`
+	} `json:"url"`
+}
+
+// Genre is a genre MusicBrainz users tagged something with
+type Genre struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ReleaseGroup is an album, EP or single across all its releases
+type ReleaseGroup struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	PrimaryType      string  `json:"primary-type"` // Album, Single, EP, ...
+	FirstReleaseDate string  `json:"first-release-date"`
+	Genres           []Genre `json:"genres"`
+}
+
+// ArtistCredit is an artist a release or recording is credited to
+type ArtistCredit struct {
+	Name   string `json:"name"`
+	Artist struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+}
+
+// Release is one edition of a release group
+type Release struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Status       string         `json:"status"` // Official, Bootleg, ...
+	Date         string         `json:"date"`
+	Country      string         `json:"country"`
+	Score        int            `json:"score"` // Search results only
+	TrackCount   int            `json:"track-count"`
+	ReleaseGroup ReleaseGroup   `json:"release-group"`
+	ArtistCredit []ArtistCredit `json:"artist-credit"`
+	Media        []Medium       `json:"media"`
+}
+
+// Medium is a disc of a release
+type Medium struct {
+	Position   int     `json:"position"`
+	Format     string  `json:"format"`
+	TrackCount int     `json:"track-count"`
+	Tracks     []Track `json:"tracks"`
+}
+
+// Track is a track of a medium
+type Track struct {
+	ID        string    `json:"id"`
+	Number    string    `json:"number"` // As printed, e.g. "A1"
+	Position  int       `json:"position"`
+	Title     string    `json:"title"`
+	Length    int       `json:"length"` // Milliseconds
+	Recording Recording `json:"recording"`
+}
+
+// Recording is a recorded performance, which tracks of many releases share
+type Recording struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Length int    `json:"length"` // Milliseconds
+}
+
+// Year returns the year of a MusicBrainz date like 1969-09-26, or 0
+func Year(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year := 0
+	for _, c := range date[:4] {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		year = year*10 + int(c-'0')
+	}
+	return year
+}
+
+// SearchArtists searches artists by name, best matches first
+func (c *Client) SearchArtists(name string) ([]Artist, error) {
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf("artist:%s", quote(name)))
+	params.Set("limit", "10")
+
+	var result struct {
+		Artists []Artist `json:"artists"`
+	}
+	if err := c.get("/artist", params, &result); err != nil {
+		return nil, err
+	}
+	return result.Artists, nil
+}
+
+// GetArtist looks up an artist with its links and genres
+func (c *Client) GetArtist(id string) (*Artist, error) {
+	params := url.Values{}
+	params.Set("inc", "url-rels+genres")
+
+	var artist Artist
+	if err := c.get("/artist/"+url.PathEscape(id), params, &artist); err != nil {
+		return nil, err
+	}
+	return &artist, nil
+}
+
+// SearchReleases searches releases by title and artist, best matches first.
+// The artist's MusicBrainz ID is used when known, its name when not.
+func (c *Client) SearchReleases(title, artistName, artistID string) ([]Release, error) {
+	query := "release:" + quote(title)
+	switch {
+	case artistID != "":
+		query += " AND arid:" + artistID
+	case artistName != "":
+		query += " AND artist:" + quote(artistName)
+	}
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", "25")
+
+	var result struct {
+		Releases []Release `json:"releases"`
+	}
+	if err := c.get("/release", params, &result); err != nil {
+		return nil, err
+	}
+	return result.Releases, nil
+}
+
+// GetRelease looks up a release with its release group and tracks
+func (c *Client) GetRelease(id string) (*Release, error) {
+	params := url.Values{}
+	params.Set("inc", "recordings+release-groups+artist-credits+genres")
+
+	var release Release
+	if err := c.get("/release/"+url.PathEscape(id), params, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// WikidataID returns the Wikidata item an artist links to, like Q1299, or ""
+func (a *Artist) WikidataID() string {
+	for _, rel := range a.Relations {
+		if rel.Type == "wikidata" {
+			resource := strings.TrimSuffix(rel.URL.Resource, "/")
+			return resource[strings.LastIndex(resource, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// get requests a MusicBrainz endpoint, waiting for its turn
+func (c *Client) get(endpoint string, params url.Values, result interface{}) error {
+	params.Set("fmt", "json")
+
+	rateMu.Lock()
+	if wait := requestInterval - time.Since(lastRequest); wait > 0 {
+		time.Sleep(wait)
+	}
+	lastRequest = time.Now()
+	rateMu.Unlock()
+
+	return c.getJSON(BaseURL+endpoint+"?"+params.Encode(), result)
+}
+
+// getJSON requests a JSON document
+func (c *Client) getJSON(rawURL string, result interface{}) error {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// quote makes a value a Lucene phrase for a search query
+func quote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + value + `"`
+}
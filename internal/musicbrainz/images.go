@@ -0,0 +1,76 @@
+package musicbrainz
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Image is a downloaded image
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// Extension returns the file extension for the image
+func (i *Image) Extension() string {
+	switch i.ContentType {
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".jpg"
+}
+
+// GetReleaseCover downloads the front cover of a release, or nil if the
+// Cover Art Archive has none
+func (c *Client) GetReleaseCover(releaseID string) (*Image, error) {
+	return c.getImage(fmt.Sprintf("%s/release/%s/front-500", CoverArtURL, url.PathEscape(releaseID)))
+}
+
+// GetReleaseGroupCover downloads the front cover the Cover Art Archive
+// picked for a release group, or nil if it has none
+func (c *Client) GetReleaseGroupCover(releaseGroupID string) (*Image, error) {
+	return c.getImage(fmt.Sprintf("%s/release-group/%s/front-500", CoverArtURL, url.PathEscape(releaseGroupID)))
+}
+
+// GetCommonsImage downloads a Wikimedia Commons file scaled to width
+func (c *Client) GetCommonsImage(fileName string, width int) (*Image, error) {
+	return c.getImage(fmt.Sprintf("%s%s?width=%d", CommonsFileURL, url.PathEscape(fileName), width))
+}
+
+// getImage downloads an image, or nil if there's none at the URL
+func (c *Client) getImage(rawURL string) (*Image, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: %d", resp.StatusCode)
+	}
+	contentType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("not an image: %s", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Data: data, ContentType: contentType}, nil
+}
@@ -0,0 +1,68 @@
+package musicbrainz
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// WikidataItem is what's used of a Wikidata item
+type WikidataItem struct {
+	Sitelinks map[string]struct {
+		Title string `json:"title"`
+	} `json:"sitelinks"`
+	Claims map[string][]struct {
+		Mainsnak struct {
+			Datavalue struct {
+				Value interface{} `json:"value"`
+			} `json:"datavalue"`
+		} `json:"mainsnak"`
+	} `json:"claims"`
+}
+
+// GetWikidataItem looks up a Wikidata item, like Q1299
+func (c *Client) GetWikidataItem(id string) (*WikidataItem, error) {
+	var result struct {
+		Entities map[string]WikidataItem `json:"entities"`
+	}
+	if err := c.getJSON(WikidataURL+"/"+url.PathEscape(id)+".json", &result); err != nil {
+		return nil, err
+	}
+	// Redirected items come back under their new ID
+	for _, item := range result.Entities {
+		return &item, nil
+	}
+	return nil, ErrNotFound
+}
+
+// WikipediaTitle returns the title of the item's article on a language's
+// Wikipedia, or ""
+func (w *WikidataItem) WikipediaTitle(language string) string {
+	return w.Sitelinks[language+"wiki"].Title
+}
+
+// ImageFile returns the Commons file name of the item's image, or ""
+func (w *WikidataItem) ImageFile() string {
+	for _, claim := range w.Claims["P18"] { // image
+		if name, ok := claim.Mainsnak.Datavalue.Value.(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// GetWikipediaSummary returns the opening paragraphs of a Wikipedia article
+func (c *Client) GetWikipediaSummary(language, title string) (string, error) {
+	var result struct {
+		Type    string `json:"type"`
+		Extract string `json:"extract"`
+	}
+	endpoint := strings.Replace(WikipediaURL, "%s", url.PathEscape(language), 1)
+	if err := c.getJSON(endpoint+url.PathEscape(strings.ReplaceAll(title, " ", "_")), &result); err != nil {
+		return "", err
+	}
+	if result.Type == "disambiguation" {
+		return "", errors.New("article is a disambiguation page")
+	}
+	return strings.TrimSpace(result.Extract), nil
+}
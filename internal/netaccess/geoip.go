@@ -0,0 +1,97 @@
+package netaccess
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeoDB maps IP ranges to countries. It's loaded from a CSV file with one
+// range per line: first address, last address and country code, as in the
+// free DB-IP and IP2Location "IP to country" downloads. Addresses may be
+// written out or given as IPv4 integers; extra columns are ignored.
+type GeoDB struct {
+	ranges []geoRange // Sorted by start
+}
+
+type geoRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// LoadGeoDB reads a GeoIP CSV file
+func LoadGeoDB(path string) (*GeoDB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	db := &GeoDB{}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		start, err1 := parseGeoAddr(fields[0])
+		end, err2 := parseGeoAddr(fields[1])
+		country := strings.ToUpper(strings.Trim(strings.TrimSpace(fields[2]), `"`))
+		if err1 != nil || err2 != nil || start.BitLen() != end.BitLen() {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("line %d: invalid address range", line)
+		}
+		if !validCountry(country) {
+			continue // "-" and "ZZ" mark unassigned ranges
+		}
+		db.ranges = append(db.ranges, geoRange{start: start, end: end, country: country})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(db.ranges) == 0 {
+		return nil, fmt.Errorf("no address ranges found")
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+// parseGeoAddr parses an address written out or as an IPv4 integer
+func parseGeoAddr(value string) (netip.Addr, error) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(n))
+		return netip.AddrFrom4(b), nil
+	}
+	ip, err := netip.ParseAddr(value)
+	return ip.Unmap(), err
+}
+
+// Lookup returns the country of an address, or "" if it isn't in any range
+func (db *GeoDB) Lookup(ip netip.Addr) string {
+	// Last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return ip.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 {
+		return ""
+	}
+	r := db.ranges[i]
+	if r.start.BitLen() != ip.BitLen() || r.end.Less(ip) {
+		return ""
+	}
+	return r.country
+}
@@ -0,0 +1,288 @@
+package netaccess
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("netaccess")
+
+// Network access rules
+//
+// For servers exposed directly to the internet: admin routes can be limited
+// to a list of subnets, and sign-ins and streams from some countries can be
+// refused using a GeoIP database. Local addresses are never refused by
+// country, and loopback can always reach admin routes so a bad subnet list
+// can be fixed from the server itself.
+//
+// Behind a reverse proxy every request comes from the proxy, so the client's
+// address is read from X-Forwarded-For - but only when the request came from
+// one of the trusted proxies, since anyone can send the header.
+
+// Config is the network access configuration
+type Config struct {
+	AdminSubnets    []netip.Prefix  `json:"adminSubnets"`    // Empty allows admin routes from anywhere
+	DeniedCountries map[string]bool `json:"deniedCountries"` // ISO 3166 codes, upper case
+	TrustedProxies  []netip.Prefix  `json:"trustedProxies"`  // Peers whose forwarding headers are read
+	geo             *GeoDB
+}
+
+// SettingTrustedProxies lists the reverse proxies, as addresses or subnets,
+// whose X-Forwarded-For and X-Real-IP headers are trusted
+const SettingTrustedProxies = "trusted_proxies"
+
+var (
+	mu      sync.RWMutex
+	current Config
+)
+
+// Configure replaces the network access configuration
+func Configure(cfg Config) {
+	mu.Lock()
+	current = cfg
+	mu.Unlock()
+
+	if len(cfg.AdminSubnets) > 0 {
+		logger.Infof("Network access: admin routes limited to %d subnets", len(cfg.AdminSubnets))
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		logger.Infof("Network access: trusting forwarding headers from %d proxy subnets", len(cfg.TrustedProxies))
+	}
+	if len(cfg.DeniedCountries) > 0 {
+		if cfg.geo == nil {
+			logger.Infof("Network access: country rules set but no GeoIP database is loaded - they won't apply")
+		} else {
+			logger.Infof("Network access: denying sign-ins and streams from %d countries", len(cfg.DeniedCountries))
+		}
+	}
+}
+
+// Current returns the network access configuration
+func Current() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// FromSettings builds a configuration from the access_* settings and the
+// trusted proxies, loading
+// the GeoIP database if one is set. Invalid values are skipped.
+func FromSettings(settings map[string]string) Config {
+	cfg := Config{DeniedCountries: make(map[string]bool)}
+	for _, value := range splitList(settings["access_admin_subnets"]) {
+		if prefix, err := parsePrefix(value); err == nil {
+			cfg.AdminSubnets = append(cfg.AdminSubnets, prefix)
+		}
+	}
+	for _, value := range splitList(settings[SettingTrustedProxies]) {
+		if prefix, err := parsePrefix(value); err == nil {
+			cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
+		}
+	}
+	for _, code := range splitList(settings["access_denied_countries"]) {
+		if validCountry(code) {
+			cfg.DeniedCountries[strings.ToUpper(code)] = true
+		}
+	}
+	if path := strings.TrimSpace(settings["access_geoip_database"]); path != "" {
+		geo, err := LoadGeoDB(path)
+		if err != nil {
+			logger.Errorf("Network access: failed to load GeoIP database %s: %v", path, err)
+		} else {
+			cfg.geo = geo
+		}
+	}
+	return cfg
+}
+
+// ValidateSetting checks the value of an access_* setting or the trusted
+// proxies. Other settings are always valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case "access_admin_subnets", SettingTrustedProxies:
+		for _, v := range splitList(value) {
+			if _, err := parsePrefix(v); err != nil {
+				return fmt.Errorf("Invalid subnet: %s", v)
+			}
+		}
+	case "access_denied_countries":
+		for _, code := range splitList(value) {
+			if !validCountry(code) {
+				return fmt.Errorf("Invalid country code: %s (use two-letter ISO codes)", code)
+			}
+		}
+	case "access_geoip_database":
+		if path := strings.TrimSpace(value); path != "" {
+			if _, err := LoadGeoDB(path); err != nil {
+				return fmt.Errorf("Invalid GeoIP database: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// GeoIPLoaded reports whether a GeoIP database is loaded
+func (c Config) GeoIPLoaded() bool {
+	return c.geo != nil
+}
+
+// ClientIP returns the address a request came from. Forwarding headers are
+// only read when the peer is a trusted proxy. X-Forwarded-For is walked from
+// the right, as each proxy appends the address it got the request from, and
+// the first hop that isn't a trusted proxy is the client: anything left of
+// it was sent by the client and can't be believed.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	cfg := Current()
+	if !cfg.trustedProxy(host) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			if _, err := netip.ParseAddr(realIP); err == nil {
+				return realIP
+			}
+		}
+		return host
+	}
+
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(hops[i]); err != nil {
+			// A garbled hop ends the chain at the last proxy that relayed it
+			return client
+		}
+		client = hops[i]
+		if !cfg.trustedProxy(client) {
+			return client
+		}
+	}
+	return client
+}
+
+// trustedProxy reports whether an address is one of the trusted proxies
+func (c Config) trustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocal reports whether an address is on the local network (loopback,
+// private or link-local). Unparseable addresses aren't.
+func IsLocal(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+}
+
+// AdminAllowed reports whether admin routes may be used from an address
+func AdminAllowed(addr string) bool {
+	cfg := Current()
+	if len(cfg.AdminSubnets) == 0 {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, prefix := range cfg.AdminSubnets {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Country returns the ISO country code of an address, or "" if unknown
+func Country(addr string) string {
+	cfg := Current()
+	if cfg.geo == nil {
+		return ""
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	return cfg.geo.Lookup(ip.Unmap())
+}
+
+// CountryDenied reports whether sign-ins and streams from an address are
+// refused because of its country, and which country that is
+func CountryDenied(addr string) (string, bool) {
+	cfg := Current()
+	if len(cfg.DeniedCountries) == 0 || cfg.geo == nil || IsLocal(addr) {
+		return "", false
+	}
+	country := Country(addr)
+	return country, country != "" && cfg.DeniedCountries[country]
+}
+
+// parsePrefix parses a subnet ("192.168.1.0/24") or a single address
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+	ip, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+func validCountry(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,221 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/email"
+	"github.com/outpost/outpost/internal/i18n"
+)
+
+// Notification providers
+//
+// Besides in-app notifications, admins can send events to Discord, Telegram,
+// Pushover, a webhook or an email address. Each provider picks the events it
+// sends. Providers are called in the background and in the server's
+// language; one that fails is logged and doesn't hold up the others.
+
+// Provider types
+const (
+	ProviderDiscord  = "discord"
+	ProviderTelegram = "telegram"
+	ProviderPushover = "pushover"
+	ProviderWebhook  = "webhook"
+	ProviderEmail    = "email"
+)
+
+// ProviderTypes lists every provider type
+var ProviderTypes = []string{ProviderDiscord, ProviderTelegram, ProviderPushover, ProviderWebhook, ProviderEmail}
+
+// Events providers can send
+const (
+	EventDownloadComplete = "download_complete"
+	EventRequestApproved  = "request_approved"
+	EventHealthFailure    = "health_failure"
+)
+
+// ProviderEvents lists every event providers can send
+var ProviderEvents = []string{EventDownloadComplete, EventRequestApproved, EventHealthFailure}
+
+// providerFields lists the config each provider type requires
+var providerFields = map[string][]string{
+	ProviderDiscord:  {"webhookUrl"},
+	ProviderTelegram: {"botToken", "chatId"},
+	ProviderPushover: {"appToken", "userKey"},
+	ProviderWebhook:  {"url"},
+	ProviderEmail:    {"to"},
+}
+
+// Telegram and Pushover endpoints
+const (
+	telegramAPI = "https://api.telegram.org"
+	pushoverAPI = "https://api.pushover.net/1/messages.json"
+)
+
+// ValidateProvider checks a provider's type, config and events
+func ValidateProvider(p *database.NotificationProvider) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	fields, ok := providerFields[p.Type]
+	if !ok {
+		return fmt.Errorf("unknown provider type %q", p.Type)
+	}
+	for _, field := range fields {
+		if strings.TrimSpace(p.Config[field]) == "" {
+			return fmt.Errorf("%s is required", field)
+		}
+	}
+	for _, event := range p.Events {
+		if !slices.Contains(ProviderEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	switch p.Type {
+	case ProviderDiscord:
+		return validateURL(p.Config["webhookUrl"])
+	case ProviderWebhook:
+		return validateURL(p.Config["url"])
+	case ProviderEmail:
+		if _, err := mail.ParseAddress(p.Config["to"]); err != nil {
+			return errors.New("to must be an email address")
+		}
+	}
+	return nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return nil
+}
+
+// TestProvider sends a test notification through a provider, returning why
+// it failed
+func (s *Service) TestProvider(p *database.NotificationProvider) error {
+	lang := i18n.Default()
+	return s.sendProvider(p, "test", i18n.T(lang, "Test Notification"),
+		i18n.T(lang, "Outpost notifications are working"))
+}
+
+// notifyProviders sends an event to every enabled provider that sends it.
+// Call it in the background.
+func (s *Service) notifyProviders(event string, title, message i18n.Message) {
+	providers, err := s.db.GetNotificationProviders()
+	if err != nil {
+		logger.Errorf("Failed to get notification providers: %v", err)
+		return
+	}
+
+	lang := i18n.Default()
+	for _, p := range providers {
+		if !p.Sends(event) {
+			continue
+		}
+		if err := s.sendProvider(&p, event, title.String(lang), message.String(lang)); err != nil {
+			logger.Errorf("Failed to send %s to notification provider %s: %v", event, p.Name, err)
+		}
+	}
+}
+
+// sendProvider sends one notification through a provider
+func (s *Service) sendProvider(p *database.NotificationProvider, event, title, message string) error {
+	switch p.Type {
+	case ProviderDiscord:
+		return postJSON(p.Config["webhookUrl"], map[string]interface{}{
+			"content": "**" + title + "**\n" + message,
+		})
+
+	case ProviderTelegram:
+		return postJSON(telegramAPI+"/bot"+p.Config["botToken"]+"/sendMessage", map[string]interface{}{
+			"chat_id": p.Config["chatId"],
+			"text":    title + "\n" + message,
+		})
+
+	case ProviderPushover:
+		resp, err := webhookClient.PostForm(pushoverAPI, url.Values{
+			"token":   {p.Config["appToken"]},
+			"user":    {p.Config["userKey"]},
+			"title":   {title},
+			"message": {message},
+		})
+		if err != nil {
+			return requestError(pushoverAPI, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("pushover returned %s", resp.Status)
+		}
+		return nil
+
+	case ProviderWebhook:
+		// text and content make the payload usable as a Slack or Discord webhook
+		text := title + " - " + message
+		return postJSON(p.Config["url"], map[string]interface{}{
+			"event":   event,
+			"title":   title,
+			"message": message,
+			"time":    time.Now().UTC().Format(time.RFC3339),
+			"text":    text,
+			"content": text,
+		})
+
+	case ProviderEmail:
+		return email.New(s.db).Send(p.Config["to"], "Outpost: "+title, message+"\n")
+	}
+	return fmt.Errorf("unknown provider type %q", p.Type)
+}
+
+// postJSON posts a JSON body, failing on anything but a 2xx response
+func postJSON(target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return requestError(target, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", hostOf(target), resp.Status)
+	}
+	return nil
+}
+
+// requestError reports a failed request by host, since the URL of some
+// providers has a token in it
+func requestError(target string, err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %v", hostOf(target), urlErr.Err)
+	}
+	return err
+}
+
+// hostOf returns a URL's host, for errors that shouldn't show its path
+func hostOf(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Host
+	}
+	return "provider"
+}
+
+// NotifyHealthFailure sends a health check that started failing to the
+// notification providers
+func (s *Service) NotifyHealthFailure(check string, message i18n.Message) error {
+	go s.notifyProviders(EventHealthFailure, i18n.M("Health Check Failed"),
+		i18n.M("%s is failing: %s", check, message))
+	return nil
+}
@@ -0,0 +1,99 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/outpost/outpost/internal/email"
+	"github.com/outpost/outpost/internal/i18n"
+)
+
+// Security event notifications
+//
+// Security events go to admins in-app, by email to admins with an address
+// (when SMTP is set up) and to the security_webhook_url if one is set. Each
+// event can be switched off with its security_notify_* setting.
+
+// TypeSecurity is the notification type for security events
+const TypeSecurity = "security"
+
+// Security events
+const (
+	SecurityFailedLogins = "failed_logins" // Repeated failed sign-ins
+	SecurityNewDevice    = "new_device"    // Admin signed in from a new device
+	SecurityAPIKey       = "api_key"       // API key added or changed
+	SecuritySettings     = "settings"      // Auth or indexer settings changed
+)
+
+// SecurityEvents lists every security event
+var SecurityEvents = []string{SecurityFailedLogins, SecurityNewDevice, SecurityAPIKey, SecuritySettings}
+
+// SecuritySettingKey returns the setting that toggles a security event
+func SecuritySettingKey(event string) string {
+	return "security_notify_" + event
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// NotifySecurityEvent notifies admins of a security event, unless the event
+// has been switched off. Outbound channels are sent in the background.
+// Admins get notifications and emails in their own language; the webhook
+// uses the server's.
+func (s *Service) NotifySecurityEvent(event string, title, message i18n.Message) error {
+	if enabled, err := s.db.GetSetting(SecuritySettingKey(event)); err == nil && enabled == "false" {
+		return nil
+	}
+
+	link := "/settings"
+	err := s.createLocalizedForAdmins(TypeSecurity, title, message, nil, &link)
+	go s.sendSecurityOutbound(event, title, message)
+	return err
+}
+
+// sendSecurityOutbound emails admins and calls the security webhook
+func (s *Service) sendSecurityOutbound(event string, title, message i18n.Message) {
+	mailer := email.New(s.db)
+	if mailer.Enabled() {
+		users, err := s.db.GetUsers()
+		if err != nil {
+			logger.Errorf("Failed to get admins for security email: %v", err)
+		}
+		for _, u := range users {
+			if u.Role != "admin" || u.Email == nil || *u.Email == "" {
+				continue
+			}
+			lang := i18n.Resolve(u.Language)
+			subject := i18n.T(lang, "Outpost security: %s", title)
+			if err := mailer.Send(*u.Email, subject, message.String(lang)+"\n"); err != nil {
+				logger.Errorf("Failed to send security email to %s: %v", u.Username, err)
+			}
+		}
+	}
+
+	url, _ := s.db.GetSetting("security_webhook_url")
+	if url == "" {
+		return
+	}
+	// text and content make the payload usable as a Slack or Discord webhook
+	lang := i18n.Default()
+	text := i18n.T(lang, "Outpost security: %s", title) + " - " + message.String(lang)
+	body, _ := json.Marshal(map[string]interface{}{
+		"event":   event,
+		"title":   title.String(lang),
+		"message": message.String(lang),
+		"time":    time.Now().UTC().Format(time.RFC3339),
+		"text":    text,
+		"content": text,
+	})
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Errorf("Failed to call security webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Infof("Security webhook returned %s", resp.Status)
+	}
+}
@@ -1,19 +1,28 @@
 package notification
 
 import (
-	"log"
 	"strconv"
 
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/i18n"
+	"github.com/outpost/outpost/internal/logging"
 )
 
+var logger = logging.Module("notification")
+
 // NotificationType constants
 const (
 	TypeNewContent        = "new_content"
+	TypeNewEpisode        = "new_episode"
+	TypeFollowedPerson    = "followed_person"
 	TypeRequestApproved   = "request_approved"
 	TypeRequestDenied     = "request_denied"
+	TypeRequestReopened   = "request_reopened"
 	TypeDownloadComplete  = "download_complete"
 	TypeDownloadFailed    = "download_failed"
+	TypeDownloadSwapped   = "download_swapped"
+	TypeStoragePaused     = "storage_paused"
+	TypeStorageResumed    = "storage_resumed"
 )
 
 // Service handles in-app notifications
@@ -30,7 +39,7 @@ func New(db *database.Database) *Service {
 func (s *Service) Create(userID int64, notifType, title, message string, imageURL, link *string) error {
 	err := s.db.CreateNotification(userID, notifType, title, message, imageURL, link)
 	if err != nil {
-		log.Printf("Failed to create notification for user %d: %v", userID, err)
+		logger.Errorf("Failed to create notification for user %d: %v", userID, err)
 	}
 	return err
 }
@@ -39,13 +48,45 @@ func (s *Service) Create(userID int64, notifType, title, message string, imageUR
 func (s *Service) CreateForAdmins(notifType, title, message string, imageURL, link *string) error {
 	adminIDs, err := s.db.GetAdminUserIDs()
 	if err != nil {
-		log.Printf("Failed to get admin IDs for notification: %v", err)
+		logger.Errorf("Failed to get admin IDs for notification: %v", err)
 		return err
 	}
 
 	for _, adminID := range adminIDs {
 		if err := s.db.CreateNotification(adminID, notifType, title, message, imageURL, link); err != nil {
-			log.Printf("Failed to create notification for admin %d: %v", adminID, err)
+			logger.Errorf("Failed to create notification for admin %d: %v", adminID, err)
+		}
+	}
+	return nil
+}
+
+// language returns the language to write a user's notifications in
+func (s *Service) language(userID int64) string {
+	if user, err := s.db.GetUserByID(userID); err == nil {
+		return i18n.Resolve(user.Language)
+	}
+	return i18n.Default()
+}
+
+// createLocalized creates a notification for a user in their language
+func (s *Service) createLocalized(userID int64, notifType string, title, message i18n.Message, imageURL, link *string) error {
+	lang := s.language(userID)
+	return s.Create(userID, notifType, title.String(lang), message.String(lang), imageURL, link)
+}
+
+// createLocalizedForAdmins creates a notification for all admin users, each
+// in their own language
+func (s *Service) createLocalizedForAdmins(notifType string, title, message i18n.Message, imageURL, link *string) error {
+	adminIDs, err := s.db.GetAdminUserIDs()
+	if err != nil {
+		logger.Errorf("Failed to get admin IDs for notification: %v", err)
+		return err
+	}
+
+	for _, adminID := range adminIDs {
+		lang := s.language(adminID)
+		if err := s.db.CreateNotification(adminID, notifType, title.String(lang), message.String(lang), imageURL, link); err != nil {
+			logger.Errorf("Failed to create notification for admin %d: %v", adminID, err)
 		}
 	}
 	return nil
@@ -87,59 +128,142 @@ func (s *Service) Cleanup(olderThanDays int) error {
 	return s.db.CleanupOldNotifications(olderThanDays)
 }
 
-// Helper methods for creating specific notification types
+// Helper methods for creating specific notification types. Each is written
+// in the language of the user it goes to.
 
 // NotifyNewContent notifies a user that their requested content is now available
 func (s *Service) NotifyNewContent(userID int64, title, mediaType string, mediaID int64, posterPath *string) error {
-	message := title + " is now available in your library"
 	var link string
 	if mediaType == "movie" {
 		link = "/movies/" + strconv.FormatInt(mediaID, 10)
 	} else {
 		link = "/tv/" + strconv.FormatInt(mediaID, 10)
 	}
-	return s.Create(userID, TypeNewContent, "New Content Available", message, posterPath, &link)
+	return s.createLocalized(userID, TypeNewContent, i18n.M("New Content Available"),
+		i18n.M("%s is now available in your library", title), posterPath, &link)
+}
+
+// NotifyEpisodeAvailable notifies a follower of an airing show that its new
+// episode is in the library
+func (s *Service) NotifyEpisodeAvailable(userID int64, showTitle string, season, episode int, showID int64, posterPath *string) error {
+	link := "/tv/" + strconv.FormatInt(showID, 10)
+	return s.createLocalized(userID, TypeNewEpisode, i18n.M("New Episode Available"),
+		i18n.M("%s S%02dE%02d is now available", showTitle, season, episode), posterPath, &link)
+}
+
+// NotifyFollowedPersonMovie notifies a user following a person that a movie
+// they're in is in the library
+func (s *Service) NotifyFollowedPersonMovie(userID int64, personName, title string, movieID int64, posterPath *string) error {
+	link := "/movies/" + strconv.FormatInt(movieID, 10)
+	return s.createLocalized(userID, TypeFollowedPerson, i18n.M("New Content Available"),
+		i18n.M("%s, with %s, is now available in your library", title, personName), posterPath, &link)
+}
+
+// NotifyFollowedPersonRelease notifies a user following a person that a
+// movie they're in was released digitally
+func (s *Service) NotifyFollowedPersonRelease(userID int64, personName, title string, tmdbID int64, posterPath *string) error {
+	link := "/explore/movie/" + strconv.FormatInt(tmdbID, 10)
+	return s.createLocalized(userID, TypeFollowedPerson, i18n.M("Released Digitally"),
+		i18n.M("%s, with %s, is out digitally", title, personName), posterPath, &link)
 }
 
-// NotifyRequestApproved notifies a user that their request was approved
+// NotifyRequestApproved notifies a user that their request was approved, and
+// the notification providers that send approvals
 func (s *Service) NotifyRequestApproved(userID int64, title string, tmdbID int64, mediaType string, posterPath *string) error {
-	message := "Your request for \"" + title + "\" has been approved"
 	var link string
 	if mediaType == "movie" {
 		link = "/explore/movie/" + strconv.FormatInt(tmdbID, 10)
 	} else {
 		link = "/explore/show/" + strconv.FormatInt(tmdbID, 10)
 	}
-	return s.Create(userID, TypeRequestApproved, "Request Approved", message, posterPath, &link)
+	go s.notifyProviders(EventRequestApproved, i18n.M("Request Approved"),
+		i18n.M("The request for \"%s\" has been approved", title))
+	return s.createLocalized(userID, TypeRequestApproved, i18n.M("Request Approved"),
+		i18n.M("Your request for \"%s\" has been approved", title), posterPath, &link)
 }
 
-// NotifyRequestDenied notifies a user that their request was denied
+// NotifyRequestDenied notifies a user that their request was denied. The
+// reason is the admin's own text, so it isn't translated.
 func (s *Service) NotifyRequestDenied(userID int64, title string, reason string, posterPath *string) error {
-	message := "Your request for \"" + title + "\" was denied"
+	message := i18n.M("Your request for \"%s\" was denied", title)
 	if reason != "" {
-		message += ": " + reason
+		message = i18n.M("Your request for \"%s\" was denied: %s", title, reason)
 	}
-	return s.Create(userID, TypeRequestDenied, "Request Denied", message, posterPath, nil)
+	return s.createLocalized(userID, TypeRequestDenied, i18n.M("Request Denied"), message, posterPath, nil)
 }
 
-// NotifyDownloadComplete notifies admins that a download completed
+// NotifyRequestReleased notifies a user and the admins that a request denied
+// until its title came out was reopened, or approved, now that it's released
+func (s *Service) NotifyRequestReleased(userID int64, title, mediaType string, tmdbID int64, approved bool, posterPath *string) error {
+	var link string
+	if mediaType == "movie" {
+		link = "/explore/movie/" + strconv.FormatInt(tmdbID, 10)
+	} else {
+		link = "/explore/show/" + strconv.FormatInt(tmdbID, 10)
+	}
+
+	if approved {
+		go s.notifyProviders(EventRequestApproved, i18n.M("Request Approved"),
+			i18n.M("\"%s\" was released, so its denied request was approved", title))
+		if err := s.createLocalized(userID, TypeRequestApproved, i18n.M("Request Approved"),
+			i18n.M("\"%s\" is out now and your request has been approved", title), posterPath, &link); err != nil {
+			return err
+		}
+		return s.createLocalizedForAdmins(TypeRequestApproved, i18n.M("Request Approved"),
+			i18n.M("\"%s\" was released, so its denied request was approved", title), posterPath, &link)
+	}
+
+	if err := s.createLocalized(userID, TypeRequestReopened, i18n.M("Request Reopened"),
+		i18n.M("\"%s\" is out now and your request has been reopened", title), posterPath, &link); err != nil {
+		return err
+	}
+	requestsLink := "/requests"
+	return s.createLocalizedForAdmins(TypeRequestReopened, i18n.M("Request Reopened"),
+		i18n.M("\"%s\" was released, so its denied request was reopened for review", title), posterPath, &requestsLink)
+}
+
+// NotifyDownloadComplete notifies admins and the notification providers that
+// a download completed
 func (s *Service) NotifyDownloadComplete(title string, mediaType string, mediaID int64, posterPath *string) error {
-	message := title + " has finished downloading"
 	var link string
 	if mediaType == "movie" {
 		link = "/movies/" + strconv.FormatInt(mediaID, 10)
 	} else {
 		link = "/tv/" + strconv.FormatInt(mediaID, 10)
 	}
-	return s.CreateForAdmins(TypeDownloadComplete, "Download Complete", message, posterPath, &link)
+	go s.notifyProviders(EventDownloadComplete, i18n.M("Download Complete"),
+		i18n.M("%s has finished downloading", title))
+	return s.createLocalizedForAdmins(TypeDownloadComplete, i18n.M("Download Complete"),
+		i18n.M("%s has finished downloading", title), posterPath, &link)
 }
 
 // NotifyDownloadFailed notifies admins that a download failed
 func (s *Service) NotifyDownloadFailed(title string, errorMsg string, posterPath *string) error {
-	message := "Download failed for \"" + title + "\""
+	message := i18n.M("Download failed for \"%s\"", title)
 	if errorMsg != "" {
-		message += ": " + errorMsg
+		message = i18n.M("Download failed for \"%s\": %s", title, errorMsg)
 	}
 	link := "/activity"
-	return s.CreateForAdmins(TypeDownloadFailed, "Download Failed", message, posterPath, &link)
+	return s.createLocalizedForAdmins(TypeDownloadFailed, i18n.M("Download Failed"), message, posterPath, &link)
+}
+
+// NotifyDownloadSwapped notifies admins that a stalled download was replaced
+// with another release
+func (s *Service) NotifyDownloadSwapped(oldTitle, newTitle, reason string, posterPath *string) error {
+	link := "/activity"
+	return s.createLocalizedForAdmins(TypeDownloadSwapped, i18n.M("Download Swapped"),
+		i18n.M("\"%s\" stalled (%s) and was replaced with \"%s\"", oldTitle, reason, newTitle), posterPath, &link)
+}
+
+// NotifyDownloadsPaused notifies admins that downloads were paused for low disk space
+func (s *Service) NotifyDownloadsPaused(reason i18n.Message) error {
+	link := "/settings"
+	return s.createLocalizedForAdmins(TypeStoragePaused, i18n.M("Downloads Paused"),
+		i18n.M("Downloads were paused because disk space is low: %s", reason), nil, &link)
+}
+
+// NotifyDownloadsResumed notifies admins that downloads paused for low disk space were resumed
+func (s *Service) NotifyDownloadsResumed(message i18n.Message) error {
+	link := "/activity"
+	return s.createLocalizedForAdmins(TypeStorageResumed, i18n.M("Downloads Resumed"), message, nil, &link)
 }
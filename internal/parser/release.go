@@ -62,7 +62,8 @@ type ParsedRelease struct {
 	HasMultipleSubs   bool
 
 	// Edition
-	Edition string // "extended", "directors", "theatrical", "unrated", "remastered", "imax", "criterion", "ultimate", "collectors", "anniversary", "special", "openmatte"
+	Edition  string   // "extended", "directors", "theatrical", "unrated", "remastered", "imax", "criterion", "ultimate", "collectors", "anniversary", "special", "openmatte"
+	Editions []string // Every edition tagged, e.g. extended and imax; Edition is the first
 
 	// Aspect ratio
 	IsFullscreen bool // cropped — avoid
@@ -143,6 +144,13 @@ var streamingServices = map[string]string{
 	"iT":   "iTunes",
 	"ZEE5": "ZEE5",
 	"ANGL": "Angel Studios",
+	"MAX":  "Max",
+	"CRAV": "Crave",
+	"STAN": "Stan",
+	"CR":   "Crunchyroll",
+	"FUNI": "Funimation",
+	"HTSR": "Hotstar",
+	"ROKU": "Roku",
 }
 
 // Regex patterns for parsing
@@ -296,8 +304,30 @@ var (
 	groupPattern        = regexp.MustCompile(`-([a-zA-Z0-9]+)(?:\.[a-z]+)?$`)
 	animeGroupPattern   = regexp.MustCompile(`^\[([^\]]+)\]`)
 	streamServicePattern = regexp.MustCompile(`(?i)\b(AMZN|NF|ATVP|DSNP|HMAX|HULU|PCOK|PMTP|iT|ZEE5|ANGL)\b`)
+	// Short codes that also appear in titles ("Mad Max") only count next to WEB
+	streamServiceWebPattern = regexp.MustCompile(`(?i)\b(MAX|CRAV|STAN|CR|FUNI|HTSR|ROKU)[\.\s_-]+WEB`)
 )
 
+// editionPatterns maps edition names to their patterns, in order of
+// precedence for the primary Edition
+var editionPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"directors", directorsPattern},
+	{"extended", extendedPattern},
+	{"theatrical", theatricalPattern},
+	{"unrated", unratedPattern},
+	{"remastered", remasteredPattern},
+	{"imax", imaxPattern},
+	{"criterion", criterionPattern},
+	{"ultimate", ultimatePattern},
+	{"collectors", collectorsPattern},
+	{"anniversary", anniversaryPattern},
+	{"special", specialEdPattern},
+	{"openmatte", openMattePattern},
+}
+
 // Language code mappings
 var languageCodes = map[string]string{
 	"ENG": "en", "ENGLISH": "en", "EN": "en",
@@ -546,33 +576,15 @@ func Parse(name string) *ParsedRelease {
 	}
 
 	// Edition
-	switch {
-	case directorsPattern.MatchString(name):
-		r.Edition = "directors"
-	case extendedPattern.MatchString(name):
-		r.Edition = "extended"
-	case theatricalPattern.MatchString(name):
-		r.Edition = "theatrical"
-	case unratedPattern.MatchString(name):
-		r.Edition = "unrated"
-	case remasteredPattern.MatchString(name):
-		r.Edition = "remastered"
-		r.IsRemastered = true
-	case imaxPattern.MatchString(name):
-		r.Edition = "imax"
-	case criterionPattern.MatchString(name):
-		r.Edition = "criterion"
-	case ultimatePattern.MatchString(name):
-		r.Edition = "ultimate"
-	case collectorsPattern.MatchString(name):
-		r.Edition = "collectors"
-	case anniversaryPattern.MatchString(name):
-		r.Edition = "anniversary"
-	case specialEdPattern.MatchString(name):
-		r.Edition = "special"
-	case openMattePattern.MatchString(name):
-		r.Edition = "openmatte"
+	for _, ed := range editionPatterns {
+		if ed.pattern.MatchString(name) {
+			r.Editions = append(r.Editions, ed.name)
+		}
 	}
+	if len(r.Editions) > 0 {
+		r.Edition = r.Editions[0]
+	}
+	r.IsRemastered = remasteredPattern.MatchString(name)
 
 	// 3D
 	if threeDPattern.MatchString(name) {
@@ -595,7 +607,9 @@ func Parse(name string) *ParsedRelease {
 
 	// Streaming service
 	if matches := streamServicePattern.FindStringSubmatch(name); matches != nil {
-		r.StreamingService = strings.ToUpper(matches[1])
+		r.StreamingService = StreamingServiceCode(matches[1])
+	} else if matches := streamServiceWebPattern.FindStringSubmatch(name); matches != nil {
+		r.StreamingService = StreamingServiceCode(matches[1])
 	}
 
 	// Release group (if not already set by anime pattern)
@@ -745,6 +759,105 @@ func extractLanguages(name string) []string {
 	return langs
 }
 
+// StreamingServiceCode returns the release tag for a streaming service given
+// its tag or name in any case ("nf", "Netflix"), or "" if it isn't known
+func StreamingServiceCode(value string) string {
+	for code, name := range streamingServices {
+		if strings.EqualFold(value, code) || strings.EqualFold(value, name) {
+			return code
+		}
+	}
+	return ""
+}
+
+// LanguageCode returns the ISO 639-1 code for a language given its code, tag
+// or name in any case ("ja", "JPN", "Japanese"), or "" if it isn't known
+func LanguageCode(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if code, ok := languageCodes[value]; ok {
+		return code
+	}
+	for _, code := range languageCodes {
+		if strings.EqualFold(value, code) {
+			return code
+		}
+	}
+	return ""
+}
+
+// editionLabels are the display names of the editions the parser knows
+var editionLabels = map[string]string{
+	"directors":   "Director's Cut",
+	"extended":    "Extended",
+	"theatrical":  "Theatrical",
+	"unrated":     "Unrated",
+	"remastered":  "Remastered",
+	"imax":        "IMAX Enhanced",
+	"criterion":   "Criterion",
+	"ultimate":    "Ultimate",
+	"collectors":  "Collector's Edition",
+	"anniversary": "Anniversary Edition",
+	"special":     "Special Edition",
+	"openmatte":   "Open Matte",
+}
+
+// editionAliases maps spelled-out edition names to the parser's edition names
+var editionAliases = map[string]string{
+	"directorscut":        "directors",
+	"director":            "directors",
+	"extendedcut":         "extended",
+	"extendededition":     "extended",
+	"theatricalcut":       "theatrical",
+	"imaxenhanced":        "imax",
+	"imaxedition":         "imax",
+	"collectorsedition":   "collectors",
+	"specialedition":      "special",
+	"anniversaryedition":  "anniversary",
+	"ultimateedition":     "ultimate",
+	"criterioncollection": "criterion",
+	"criterionedition":    "criterion",
+}
+
+// EditionName returns the parser's name for an edition given its name or
+// label in any case ("directors", "Director's Cut", "IMAX Enhanced"), or ""
+// if it isn't known
+func EditionName(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	edition := b.String()
+	if alias, ok := editionAliases[edition]; ok {
+		edition = alias
+	}
+	if _, ok := editionLabels[edition]; !ok {
+		return ""
+	}
+	return edition
+}
+
+// EditionOf returns the edition a name is tagged with, the first in order of
+// precedence if it has several, or "" if it has none
+func EditionOf(name string) string {
+	for _, ed := range editionPatterns {
+		if ed.pattern.MatchString(name) {
+			return ed.name
+		}
+	}
+	return ""
+}
+
+// EditionLabel returns the display name of an edition ("Director's Cut" for
+// "directors"), or the name itself if it isn't known
+func EditionLabel(edition string) string {
+	if label, ok := editionLabels[edition]; ok {
+		return label
+	}
+	return edition
+}
+
 // IsTrustedGroup checks if a release group is trusted
 func IsTrustedGroup(group, category string) bool {
 	groups, ok := TrustedGroups[category]
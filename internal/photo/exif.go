@@ -0,0 +1,358 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Photo files
+//
+// EXIF metadata is read from JPEG files, the eXIf chunk of PNGs and the EXIF
+// chunk of WebP images: when the picture was taken, the camera, how it's
+// turned and where it was taken. Thumbnails are JPEGs scaled to each of
+// Sizes and turned upright, so clients never have to read EXIF themselves.
+
+// ErrNoEXIF is returned by ReadEXIF for files without EXIF metadata
+var ErrNoEXIF = errors.New("photo: no EXIF metadata")
+
+// EXIF is the part of a photo's EXIF metadata Outpost uses
+type EXIF struct {
+	// TakenAt is when the picture was taken, as the camera's clock read
+	// it, in UTC. Cameras rarely know their time zone, so the wall clock is
+	// kept rather than guessing the instant.
+	TakenAt     *time.Time
+	Make        string
+	Model       string
+	Orientation int // 1-8, as EXIF numbers them; 1 is upright
+	Latitude    *float64
+	Longitude   *float64
+}
+
+// Camera returns the camera's make and model, without the make repeated
+func (e *EXIF) Camera() string {
+	if e.Model == "" || strings.HasPrefix(strings.ToLower(e.Model), strings.ToLower(e.Make)) {
+		if e.Model != "" {
+			return e.Model
+		}
+		return e.Make
+	}
+	if e.Make == "" {
+		return e.Model
+	}
+	return e.Make + " " + e.Model
+}
+
+// maxEXIFSize bounds the EXIF read from a PNG or WebP file. JPEG segments
+// can't be larger than 64 KB anyway.
+const maxEXIFSize = 1 << 20
+
+// exifHeader starts the EXIF segment of a JPEG
+var exifHeader = []byte("Exif\x00\x00")
+
+// ReadEXIF reads the EXIF metadata of a JPEG, PNG or WebP file of the given
+// size. Only the file's headers and its EXIF are read, not the whole file.
+func ReadEXIF(r io.ReaderAt, size int64) (*EXIF, error) {
+	header := readAt(r, 0, 12)
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8}):
+		tiff = jpegEXIF(r, size)
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		tiff = pngEXIF(r, size)
+	case header != nil && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		tiff = webpEXIF(r, size)
+	}
+	if tiff == nil {
+		return nil, ErrNoEXIF
+	}
+	return parseTIFF(tiff)
+}
+
+// readAt reads n bytes at an offset, or returns nil if they can't all be
+// read
+func readAt(r io.ReaderAt, off int64, n int) []byte {
+	buf := make([]byte, n)
+	if read, _ := r.ReadAt(buf, off); read < n {
+		return nil
+	}
+	return buf
+}
+
+// jpegEXIF finds the EXIF segment of a JPEG, which comes before the image
+// data
+func jpegEXIF(r io.ReaderAt, size int64) []byte {
+	pos := int64(2)
+	for pos+4 <= size {
+		head := readAt(r, pos, 4)
+		if head == nil || head[0] != 0xff {
+			return nil
+		}
+		marker := head[1]
+		if marker == 0xff {
+			// Fill byte
+			pos++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan or end of image: no more metadata
+			return nil
+		}
+		length := int64(binary.BigEndian.Uint16(head[2:]))
+		end := pos + 2 + length
+		if length < 2 || end > size {
+			return nil
+		}
+		if marker == 0xe1 {
+			segment := readAt(r, pos+4, int(length-2))
+			if bytes.HasPrefix(segment, exifHeader) {
+				return segment[len(exifHeader):]
+			}
+		}
+		pos = end
+	}
+	return nil
+}
+
+// pngEXIF finds the eXIf chunk of a PNG
+func pngEXIF(r io.ReaderAt, size int64) []byte {
+	pos := int64(8)
+	for pos+8 <= size {
+		head := readAt(r, pos, 8)
+		if head == nil {
+			return nil
+		}
+		length := int64(binary.BigEndian.Uint32(head))
+		kind := string(head[4:8])
+		end := pos + 8 + length
+		if end > size {
+			return nil
+		}
+		switch kind {
+		case "eXIf":
+			if length > maxEXIFSize {
+				return nil
+			}
+			return readAt(r, pos+8, int(length))
+		case "IDAT", "IEND":
+			// eXIf must come before the image data
+			return nil
+		}
+		pos = end + 4 // CRC
+	}
+	return nil
+}
+
+// webpEXIF finds the EXIF chunk of a WebP image
+func webpEXIF(r io.ReaderAt, size int64) []byte {
+	pos := int64(12)
+	for pos+8 <= size {
+		head := readAt(r, pos, 8)
+		if head == nil {
+			return nil
+		}
+		kind := string(head[:4])
+		length := int64(binary.LittleEndian.Uint32(head[4:]))
+		end := pos + 8 + length
+		if end > size {
+			return nil
+		}
+		if kind == "EXIF" {
+			if length > maxEXIFSize {
+				return nil
+			}
+			// Some writers keep the JPEG segment's header
+			return bytes.TrimPrefix(readAt(r, pos+8, int(length)), exifHeader)
+		}
+		pos = end + length%2 // Chunks are padded to an even size
+	}
+	return nil
+}
+
+// EXIF tags Outpost reads
+const (
+	tagMake              = 0x010f
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+	tagGPSLatitudeRef    = 0x0001
+	tagGPSLatitude       = 0x0002
+	tagGPSLongitudeRef   = 0x0003
+	tagGPSLongitude      = 0x0004
+)
+
+const (
+	// exifTimeLayout is how EXIF writes dates and times
+	exifTimeLayout = "2006:01:02 15:04:05"
+	// maxIFDEntries bounds how many tags an IFD is read for
+	maxIFDEntries = 1000
+)
+
+// typeSizes are the sizes of the EXIF value types, by type number
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// tiffReader reads the IFDs of a TIFF structure, as EXIF is stored
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is a tag in an IFD
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// parseTIFF reads the tags Outpost uses from EXIF's TIFF structure
+func parseTIFF(data []byte) (*EXIF, error) {
+	if len(data) < 8 {
+		return nil, ErrNoEXIF
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, ErrNoEXIF
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, ErrNoEXIF
+	}
+
+	ifd0 := t.ifd(t.order.Uint32(data[4:]))
+	if ifd0 == nil {
+		return nil, ErrNoEXIF
+	}
+	e := &EXIF{
+		Make:        t.ascii(ifd0[tagMake]),
+		Model:       t.ascii(ifd0[tagModel]),
+		Orientation: int(t.uint(ifd0[tagOrientation])),
+	}
+	if e.Orientation < 1 || e.Orientation > 8 {
+		e.Orientation = 1
+	}
+
+	var taken string
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		if exif := t.ifd(t.uint(entry)); exif != nil {
+			taken = t.ascii(exif[tagDateTimeOriginal])
+			if taken == "" {
+				taken = t.ascii(exif[tagDateTimeDigitized])
+			}
+		}
+	}
+	if taken == "" {
+		taken = t.ascii(ifd0[tagDateTime])
+	}
+	if len(taken) >= len(exifTimeLayout) {
+		// Some cameras add subseconds or a time zone after the time
+		if parsed, err := time.Parse(exifTimeLayout, taken[:len(exifTimeLayout)]); err == nil && parsed.Year() > 1900 {
+			e.TakenAt = &parsed
+		}
+	}
+
+	if entry, ok := ifd0[tagGPSIFD]; ok {
+		if gps := t.ifd(t.uint(entry)); gps != nil {
+			e.Latitude = t.coordinate(gps[tagGPSLatitude], t.ascii(gps[tagGPSLatitudeRef]), "S", 90)
+			e.Longitude = t.coordinate(gps[tagGPSLongitude], t.ascii(gps[tagGPSLongitudeRef]), "W", 180)
+		}
+	}
+	return e, nil
+}
+
+// ifd reads the entries of the IFD at an offset
+func (t *tiffReader) ifd(offset uint32) map[uint16]ifdEntry {
+	pos := int(offset)
+	if offset == 0 || pos+2 > len(t.data) {
+		return nil
+	}
+	count := int(t.order.Uint16(t.data[pos:]))
+	if count > maxIFDEntries {
+		return nil
+	}
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		at := pos + 2 + i*12
+		if at+12 > len(t.data) {
+			break
+		}
+		tag := t.order.Uint16(t.data[at:])
+		kind := t.order.Uint16(t.data[at+2:])
+		n := t.order.Uint32(t.data[at+4:])
+		size, ok := typeSizes[kind]
+		if !ok || n > uint32(len(t.data)) {
+			continue
+		}
+		total := size * int(n)
+		var value []byte
+		if total <= 4 {
+			value = t.data[at+8 : at+8+total]
+		} else {
+			start := int(t.order.Uint32(t.data[at+8:]))
+			if start < 0 || start+total > len(t.data) {
+				continue
+			}
+			value = t.data[start : start+total]
+		}
+		entries[tag] = ifdEntry{kind: kind, count: n, value: value}
+	}
+	return entries
+}
+
+// ascii returns a text value
+func (t *tiffReader) ascii(e ifdEntry) string {
+	if e.kind != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+// uint returns a short or long value
+func (t *tiffReader) uint(e ifdEntry) uint32 {
+	switch {
+	case e.kind == 3 && len(e.value) >= 2:
+		return uint32(t.order.Uint16(e.value))
+	case e.kind == 4 && len(e.value) >= 4:
+		return t.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// coordinate reads a GPS latitude or longitude, given as degrees, minutes and
+// seconds, negative when its reference is negativeRef
+func (t *tiffReader) coordinate(e ifdEntry, ref, negativeRef string, limit float64) *float64 {
+	if e.kind != 5 || e.count < 3 || len(e.value) < 24 {
+		return nil
+	}
+	var parts [3]float64
+	for i := range parts {
+		num := t.order.Uint32(e.value[i*8:])
+		den := t.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			if num != 0 {
+				return nil
+			}
+			continue
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(ref, negativeRef) {
+		value = -value
+	}
+	if math.IsNaN(value) || math.Abs(value) > limit {
+		return nil
+	}
+	return &value
+}
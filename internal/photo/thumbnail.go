@@ -0,0 +1,165 @@
+package photo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Size is a thumbnail size, bounding its longer side
+type Size struct {
+	Name string
+	Max  int
+}
+
+// Sizes are the thumbnails made of each photo, largest first: large for
+// viewing full screen, medium for viewing on phones and small for grids
+var Sizes = []Size{
+	{Name: "large", Max: 2048},
+	{Name: "medium", Max: 1024},
+	{Name: "small", Max: 320},
+}
+
+const (
+	// maxPixels bounds the decoded size of a photo, as a corrupt or hostile
+	// file can claim a huge canvas
+	maxPixels = 200_000_000
+	quality   = 85
+)
+
+// Thumbnail is a JPEG made of a photo
+type Thumbnail struct {
+	Size   Size
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Config returns a photo's format and its width and height upright, reading
+// only as much of it as that takes
+func Config(r io.Reader, orientation int) (format string, width, height int, err error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	width, height = cfg.Width, cfg.Height
+	if turnedSideways(orientation) {
+		width, height = height, width
+	}
+	return format, width, height, nil
+}
+
+// Thumbnails makes a photo's thumbnails in each of Sizes, turned upright.
+// Photos smaller than a size are kept at their own size rather than scaled
+// up. The photo is streamed from r, which is read from the start twice: for
+// its size, then to decode it.
+func Thumbnails(r io.ReadSeeker, orientation int) ([]Thumbnail, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("photo: too large to read (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each size is scaled from the last, which is quicker than scaling the
+	// photo each time and looks the same. Turning happens after scaling, on
+	// far fewer pixels.
+	var thumbnails []Thumbnail
+	for _, size := range Sizes {
+		scaled := scale(src, size.Max)
+		src = scaled
+		upright := orient(scaled, orientation)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, upright, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		b := upright.Bounds()
+		thumbnails = append(thumbnails, Thumbnail{Size: size, Data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()})
+	}
+	return thumbnails, nil
+}
+
+// scale fits an image within limit on its longer side, on white for
+// pictures with transparency
+func scale(src image.Image, limit int) image.Image {
+	b := src.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width > limit || height > limit {
+		if width >= height {
+			height = height * limit / width
+			width = limit
+		} else {
+			width = width * limit / height
+			height = limit
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+// turnedSideways reports whether an EXIF orientation turns the picture a
+// quarter turn, swapping its width and height
+func turnedSideways(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// orient turns an image upright from its EXIF orientation
+func orient(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if turnedSideways(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored
+				dx, dy = w-1-x, y
+			case 3: // Upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // Upside down, mirrored
+				dx, dy = x, h-1-y
+			case 5: // Mirrored, turned left
+				dx, dy = y, x
+			case 6: // Turned left, so turn right
+				dx, dy = h-1-y, x
+			case 7: // Mirrored, turned right
+				dx, dy = h-1-y, w-1-x
+			case 8: // Turned right, so turn left
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,76 @@
+package podcast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for feeds and episodes on addresses that
+// aren't on the public internet
+var ErrPrivateAddress = errors.New("podcast: address is not on the public internet")
+
+// maxRedirects bounds the redirects followed for a feed or episode
+const maxRedirects = 10
+
+// Feed and episode URLs come from users and from the feeds themselves, so
+// the clients only connect to public addresses: otherwise anyone who can
+// subscribe could have the server fetch from itself, the local network or a
+// cloud metadata service. The check is made on the address dialed, after DNS,
+// so it holds for every redirect and for names that resolve to private
+// addresses. Proxies from the environment aren't used, as the proxy would
+// make the connection instead.
+var (
+	feedClient = newClient(&http.Transport{}, 30*time.Second)
+
+	// downloadClient has no overall timeout as episodes can be large; a
+	// stalled host is caught by the header timeout
+	downloadClient = newClient(&http.Transport{ResponseHeaderTimeout: 60 * time.Second}, 0)
+)
+
+// newClient returns a client using transport that only connects to public
+// addresses
+func newClient(transport *http.Transport, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: publicOnly}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: timeout, CheckRedirect: checkRedirect}
+}
+
+// publicOnly refuses connections to loopback, private, link-local and other
+// addresses that aren't on the public internet
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip.Unmap()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't public
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func publicAddr(ip netip.Addr) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// checkRedirect follows only http and https redirects, and not too many
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirected to a %s URL", req.URL.Scheme)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package podcast
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxFeedSize bounds how much of a feed is read; feeds with thousands of
+// episodes run to a few megabytes
+const maxFeedSize = 20 << 20
+
+// Feed is a podcast's RSS feed
+type Feed struct {
+	Title       string
+	Author      string
+	Description string
+	ImageURL    string
+	Website     string
+	Episodes    []FeedEpisode
+}
+
+// FeedEpisode is an item of a feed with an audio enclosure
+type FeedEpisode struct {
+	GUID        string
+	Title       string
+	Description string
+	AudioURL    string
+	MimeType    string
+	Duration    int // Seconds, 0 when unknown
+	PublishedAt time.Time
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title       string     `xml:"title"`
+		Links       []string   `xml:"link"` // Also matches atom:link, which is empty
+		Description string     `xml:"description"`
+		Summary     string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+		Author      string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		Images      []rssImage `xml:"image"` // RSS image and itunes:image
+		Items       []rssItem  `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssImage struct {
+	URL  string `xml:"url"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Summary     string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	PubDate     string `xml:"pubDate"`
+	Duration    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosure   struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+}
+
+// ValidateFeedURL checks that a feed URL is an absolute http(s) URL
+func ValidateFeedURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Feed URL must be an http or https URL")
+	}
+	return nil
+}
+
+// FetchFeed downloads and parses a podcast feed
+func FetchFeed(feedURL string) (*Feed, error) {
+	if err := ValidateFeedURL(feedURL); err != nil {
+		return nil, err
+	}
+	resp, err := feedClient.Get(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned %s", resp.Status)
+	}
+	return ParseFeed(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+// ParseFeed parses an RSS 2.0 podcast feed. Items without an audio
+// enclosure, such as blog posts in the same feed, are skipped.
+func ParseFeed(r io.Reader) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	decoder.Strict = false
+
+	var rss rssFeed
+	if err := decoder.Decode(&rss); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+	ch := rss.Channel
+	if strings.TrimSpace(ch.Title) == "" {
+		return nil, fmt.Errorf("invalid feed: no title")
+	}
+
+	feed := &Feed{
+		Title:       strings.TrimSpace(ch.Title),
+		Author:      strings.TrimSpace(ch.Author),
+		Description: strings.TrimSpace(firstNonEmpty(ch.Summary, ch.Description)),
+	}
+	for _, link := range ch.Links {
+		if link = strings.TrimSpace(link); link != "" {
+			feed.Website = link
+			break
+		}
+	}
+	// itunes:image is usually the larger one
+	for _, img := range ch.Images {
+		if img.Href != "" {
+			feed.ImageURL = strings.TrimSpace(img.Href)
+			break
+		}
+		if feed.ImageURL == "" {
+			feed.ImageURL = strings.TrimSpace(img.URL)
+		}
+	}
+
+	for _, item := range ch.Items {
+		audioURL := strings.TrimSpace(item.Enclosure.URL)
+		if audioURL == "" {
+			continue
+		}
+		if item.Enclosure.Type != "" && !strings.HasPrefix(item.Enclosure.Type, "audio/") {
+			continue
+		}
+		ep := FeedEpisode{
+			GUID:        strings.TrimSpace(item.GUID),
+			Title:       strings.TrimSpace(item.Title),
+			Description: strings.TrimSpace(firstNonEmpty(item.Description, item.Summary)),
+			AudioURL:    audioURL,
+			MimeType:    item.Enclosure.Type,
+			Duration:    parseDuration(item.Duration),
+			PublishedAt: parsePubDate(item.PubDate),
+		}
+		if ep.GUID == "" {
+			ep.GUID = audioURL
+		}
+		if ep.Title == "" {
+			ep.Title = ep.PublishedAt.Format("2006-01-02")
+		}
+		feed.Episodes = append(feed.Episodes, ep)
+	}
+	return feed, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseDuration parses itunes:duration, which is seconds or [HH:]MM:SS
+func parseDuration(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	total := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + int(n)
+	}
+	return total
+}
+
+// pubDateLayouts are the RFC 822 date variants feeds use in practice
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 MST",
+	time.RFC3339,
+}
+
+// parsePubDate parses an item's pubDate, returning the zero time when it
+// can't be read
+func parsePubDate(value string) time.Time {
+	value = strings.Join(strings.Fields(value), " ")
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// charsetReader reads feeds declared as Latin-1 or Windows-1252, which some
+// older hosts still serve
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252":
+		return &latin1Reader{r: bufio.NewReader(input)}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
+
+// latin1Reader converts Latin-1 to UTF-8
+type latin1Reader struct {
+	r       *bufio.Reader
+	pending []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(l.pending) > 0 {
+			c := copy(p[n:], l.pending)
+			l.pending = l.pending[c:]
+			n += c
+			continue
+		}
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		buf := make([]byte, 2)
+		utf8.EncodeRune(buf, rune(b))
+		l.pending = buf
+	}
+	return n, nil
+}
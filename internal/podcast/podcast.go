@@ -0,0 +1,404 @@
+package podcast
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("podcast")
+
+// Podcasts
+//
+// Users subscribe to RSS feeds; a feed is kept once however many users
+// subscribe to it. Feeds are refreshed by the scheduler's Podcast Refresh
+// task, which also downloads new episodes of podcasts set to download
+// automatically and removes downloads the podcast's retention doesn't keep.
+// Episodes that aren't downloaded stream from the feed's host.
+
+// Service manages podcast feeds and their downloads
+type Service struct {
+	db  *database.Database
+	dir string // Downloads go in dir/{podcast ID}/
+
+	mu sync.Mutex // Serializes refreshes and downloads
+}
+
+// New creates a service storing downloads under dir
+func New(db *database.Database, dir string) *Service {
+	return &Service{db: db, dir: dir}
+}
+
+// ValidateSetting checks the podcast settings. Other settings are always
+// valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case "podcast_auto_download":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("Podcast auto download must be true or false")
+		}
+	case "podcast_keep_episodes":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("Episodes to keep must be 0 (all) or more")
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribes a user to a feed, adding the podcast with the
+// server's default download settings when it's new
+func (s *Service) Subscribe(userID int64, feedURL string) (*database.Podcast, error) {
+	feedURL = strings.TrimSpace(feedURL)
+	if err := ValidateFeedURL(feedURL); err != nil {
+		return nil, err
+	}
+
+	podcast, err := s.db.GetPodcastByFeed(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	if podcast == nil {
+		feed, err := FetchFeed(feedURL)
+		if err != nil {
+			return nil, err
+		}
+		podcast = &database.Podcast{FeedURL: feedURL, AutoDownload: true}
+		if v, _ := s.db.GetSetting("podcast_auto_download"); v == "false" {
+			podcast.AutoDownload = false
+		}
+		if v, err := s.db.GetSetting("podcast_keep_episodes"); err == nil {
+			podcast.KeepEpisodes, _ = strconv.Atoi(v)
+		}
+		applyFeed(podcast, feed)
+		if err := s.db.CreatePodcast(podcast); err != nil {
+			return nil, err
+		}
+		if _, err := s.storeEpisodes(podcast, feed); err != nil {
+			return nil, err
+		}
+		s.db.UpdatePodcastFeed(podcast, nil)
+		logger.Infof("Podcasts: added %s (%d episodes)", podcast.Title, len(feed.Episodes))
+
+		// The first downloads shouldn't hold up the subscription
+		go func(id int64) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if p, err := s.db.GetPodcast(id); err == nil {
+				s.downloadAndPrune(p)
+			}
+		}(podcast.ID)
+	}
+
+	if err := s.db.SubscribePodcast(userID, podcast.ID); err != nil {
+		return nil, err
+	}
+	return s.db.GetPodcast(podcast.ID)
+}
+
+// Unsubscribe removes a user's subscription. The podcast and its downloads
+// are removed at the next refresh when nobody else subscribes.
+func (s *Service) Unsubscribe(userID, podcastID int64) (bool, error) {
+	return s.db.UnsubscribePodcast(userID, podcastID)
+}
+
+// Refresh fetches a podcast's feed, adds new episodes and, when set to,
+// downloads them. Returns how many episodes are new.
+func (s *Service) Refresh(podcastID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	podcast, err := s.db.GetPodcast(podcastID)
+	if err != nil {
+		return 0, err
+	}
+	added, err := s.refresh(podcast)
+	if err != nil {
+		return 0, err
+	}
+	s.downloadAndPrune(podcast)
+	return added, nil
+}
+
+// RefreshAll refreshes every podcast, for the scheduler. Podcasts nobody
+// subscribes to anymore are removed with their downloads. Returns how many
+// podcasts were refreshed.
+func (s *Service) RefreshAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if orphans, err := s.db.GetUnsubscribedPodcasts(); err == nil {
+		for _, p := range orphans {
+			s.remove(&p)
+		}
+	}
+
+	podcasts, err := s.db.GetPodcasts()
+	if err != nil {
+		logger.Errorf("Podcasts: failed to load podcasts: %v", err)
+		return 0
+	}
+	refreshed := 0
+	for i := range podcasts {
+		p := &podcasts[i]
+		added, err := s.refresh(p)
+		if err != nil {
+			logger.Errorf("Podcasts: failed to refresh %s: %v", p.Title, err)
+			continue
+		}
+		if added > 0 {
+			logger.Infof("Podcasts: %d new episodes of %s", added, p.Title)
+		}
+		s.downloadAndPrune(p)
+		refreshed++
+	}
+	return refreshed
+}
+
+// DownloadEpisode downloads an episode now, whatever the podcast's settings
+func (s *Service) DownloadEpisode(episodeID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	episode, err := s.db.GetPodcastEpisode(episodeID)
+	if err != nil {
+		return err
+	}
+	if episode.Downloaded {
+		return nil
+	}
+	return s.download(episode)
+}
+
+// DeleteDownload removes an episode's download; it streams from the feed's
+// host again
+func (s *Service) DeleteDownload(episodeID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	episode, err := s.db.GetPodcastEpisode(episodeID)
+	if err != nil {
+		return err
+	}
+	return s.deleteFile(episode)
+}
+
+// refresh fetches a podcast's feed and stores it
+func (s *Service) refresh(podcast *database.Podcast) (int, error) {
+	feed, err := FetchFeed(podcast.FeedURL)
+	if err != nil {
+		s.db.UpdatePodcastFeed(podcast, err)
+		return 0, err
+	}
+	applyFeed(podcast, feed)
+	added, err := s.storeEpisodes(podcast, feed)
+	if err != nil {
+		return 0, err
+	}
+	return added, s.db.UpdatePodcastFeed(podcast, nil)
+}
+
+// applyFeed copies a feed's details onto its podcast
+func applyFeed(podcast *database.Podcast, feed *Feed) {
+	optional := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	podcast.Title = feed.Title
+	podcast.Author = optional(feed.Author)
+	podcast.Description = optional(feed.Description)
+	podcast.ImageURL = optional(feed.ImageURL)
+	podcast.Website = optional(feed.Website)
+}
+
+// storeEpisodes adds a feed's episodes to its podcast, returning how many
+// are new
+func (s *Service) storeEpisodes(podcast *database.Podcast, feed *Feed) (int, error) {
+	added := 0
+	for _, fe := range feed.Episodes {
+		episode := &database.PodcastEpisode{
+			PodcastID: podcast.ID,
+			GUID:      fe.GUID,
+			Title:     fe.Title,
+			AudioURL:  fe.AudioURL,
+		}
+		if fe.Description != "" {
+			episode.Description = &fe.Description
+		}
+		if fe.MimeType != "" {
+			episode.MimeType = &fe.MimeType
+		}
+		if fe.Duration > 0 {
+			episode.Duration = &fe.Duration
+		}
+		if !fe.PublishedAt.IsZero() {
+			published := fe.PublishedAt
+			episode.PublishedAt = &published
+		}
+		isNew, err := s.db.UpsertPodcastEpisode(episode)
+		if err != nil {
+			return added, err
+		}
+		if isNew {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// downloadAndPrune applies a podcast's download and retention settings.
+// With a number of episodes to keep, the newest that many are downloaded and
+// older downloads removed, except those someone is part-way through. Keeping
+// all episodes downloads the latest one and anything published since the
+// podcast was added, never the back catalog.
+func (s *Service) downloadAndPrune(podcast *database.Podcast) {
+	episodes, err := s.db.GetPodcastEpisodes(podcast.ID)
+	if err != nil {
+		logger.Errorf("Podcasts: failed to load episodes of %s: %v", podcast.Title, err)
+		return
+	}
+	// Newest first; episodes without a date go last
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i].PublishedAt, episodes[j].PublishedAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+
+	if podcast.AutoDownload {
+		for i := range episodes {
+			e := &episodes[i]
+			wanted := i < podcast.KeepEpisodes
+			if podcast.KeepEpisodes == 0 {
+				wanted = i == 0 || (e.PublishedAt != nil && e.PublishedAt.After(podcast.AddedAt))
+			}
+			if !wanted || e.Downloaded {
+				continue
+			}
+			if err := s.download(e); err != nil {
+				logger.Errorf("Podcasts: failed to download %s - %s: %v", podcast.Title, e.Title, err)
+				continue
+			}
+			e.Downloaded = true
+		}
+	}
+
+	if podcast.KeepEpisodes == 0 {
+		return
+	}
+	unfinished, err := s.db.GetUnfinishedPodcastEpisodes(podcast.ID)
+	if err != nil {
+		logger.Errorf("Podcasts: failed to load progress of %s: %v", podcast.Title, err)
+		return
+	}
+	for i := podcast.KeepEpisodes; i < len(episodes); i++ {
+		e := &episodes[i]
+		if e.Downloaded && !unfinished[e.ID] {
+			s.deleteFile(e)
+		}
+	}
+}
+
+// download downloads an episode to dir/{podcast ID}/{episode ID}.{ext}
+func (s *Service) download(episode *database.PodcastEpisode) error {
+	dir := filepath.Join(s.dir, strconv.FormatInt(episode.PodcastID, 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	resp, err := downloadClient.Get(episode.AudioURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned %s", resp.Status)
+	}
+
+	dest := filepath.Join(dir, strconv.FormatInt(episode.ID, 10)+audioExtension(episode, resp.Header.Get("Content-Type")))
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return s.db.SetPodcastEpisodeFile(episode.ID, dest, size)
+}
+
+// audioExtension picks a file extension for an episode from its URL, falling
+// back to the content type
+func audioExtension(episode *database.PodcastEpisode, contentType string) string {
+	if u, err := url.Parse(episode.AudioURL); err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".mp3", ".m4a", ".aac", ".ogg", ".oga", ".opus", ".flac", ".wav":
+			return ext
+		}
+	}
+	if episode.MimeType != nil && contentType == "" {
+		contentType = *episode.MimeType
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "audio/mpeg", "audio/mp3":
+			return ".mp3"
+		case "audio/mp4", "audio/x-m4a", "audio/m4a":
+			return ".m4a"
+		case "audio/aac":
+			return ".aac"
+		case "audio/ogg":
+			return ".ogg"
+		case "audio/opus":
+			return ".opus"
+		}
+	}
+	return ".mp3"
+}
+
+// deleteFile removes an episode's download
+func (s *Service) deleteFile(episode *database.PodcastEpisode) error {
+	if episode.FilePath != nil {
+		if err := os.Remove(*episode.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	episode.Downloaded = false
+	return s.db.SetPodcastEpisodeFile(episode.ID, "", 0)
+}
+
+// remove deletes a podcast and its downloads
+func (s *Service) remove(podcast *database.Podcast) {
+	if err := os.RemoveAll(filepath.Join(s.dir, strconv.FormatInt(podcast.ID, 10))); err != nil {
+		logger.Errorf("Podcasts: failed to remove downloads of %s: %v", podcast.Title, err)
+		return
+	}
+	if err := s.db.DeletePodcast(podcast.ID); err != nil {
+		logger.Errorf("Podcasts: failed to remove %s: %v", podcast.Title, err)
+		return
+	}
+	logger.Infof("Podcasts: removed %s, which nobody subscribes to", podcast.Title)
+}
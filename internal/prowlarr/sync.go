@@ -3,14 +3,16 @@ package prowlarr
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
 )
 
+var logger = logging.Module("prowlarr")
+
 type SyncService struct {
 	db     *database.Database
 	client *http.Client
@@ -148,13 +150,13 @@ func (s *SyncService) SyncAll() (int, error) {
 	for _, t := range tags {
 		outpostID, err := s.db.UpsertIndexerTag(t.ID, t.Label)
 		if err != nil {
-			log.Printf("Failed to upsert tag %s: %v", t.Label, err)
+			logger.Errorf("Failed to upsert tag %s: %v", t.Label, err)
 			continue
 		}
 		tagMap[t.ID] = outpostID
 	}
 
-	log.Printf("Synced %d tags from Prowlarr", len(tags))
+	logger.Infof("Synced %d tags from Prowlarr", len(tags))
 
 	// 2. Sync indexers
 	indexers, err := s.FetchIndexers(config.URL, config.APIKey)
@@ -192,7 +194,7 @@ func (s *SyncService) SyncAll() (int, error) {
 		// Upsert indexer
 		indexerID, err := s.db.UpsertSyncedIndexer(indexer)
 		if err != nil {
-			log.Printf("Failed to upsert indexer %s: %v", pi.Name, err)
+			logger.Errorf("Failed to upsert indexer %s: %v", pi.Name, err)
 			continue
 		}
 
@@ -207,13 +209,13 @@ func (s *SyncService) SyncAll() (int, error) {
 		// Sync category IDs for filtering
 		categoryIDs := collectCategoryIDs(pi.Capabilities.Categories)
 		if err := s.db.SetIndexerCategories(indexerID, categoryIDs); err != nil {
-			log.Printf("Failed to set categories for indexer %s: %v", pi.Name, err)
+			logger.Errorf("Failed to set categories for indexer %s: %v", pi.Name, err)
 		}
 
 		syncedCount++
 	}
 
-	log.Printf("Synced %d indexers from Prowlarr", syncedCount)
+	logger.Infof("Synced %d indexers from Prowlarr", syncedCount)
 
 	// 3. Mark stale indexers (in Outpost but not in Prowlarr)
 	s.markStaleIndexers(indexers)
@@ -301,14 +303,14 @@ func (s *SyncService) markStaleIndexers(currentIndexers []ProwlarrIndexer) {
 
 	syncedIndexers, err := s.db.GetSyncedIndexers()
 	if err != nil {
-		log.Printf("Failed to get synced indexers: %v", err)
+		logger.Errorf("Failed to get synced indexers: %v", err)
 		return
 	}
 
 	for _, idx := range syncedIndexers {
 		if idx.ProwlarrID != nil && !prowlarrIDs[*idx.ProwlarrID] {
 			// Disable rather than delete to preserve history
-			log.Printf("Disabling stale indexer: %s", idx.Name)
+			logger.Infof("Disabling stale indexer: %s", idx.Name)
 			s.db.DisableIndexer(idx.ID)
 		}
 	}
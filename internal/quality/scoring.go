@@ -31,10 +31,12 @@ var BaseQualityScores = map[string]int{
 
 // Condition represents a single condition for a custom format
 type Condition struct {
-	Type     string `json:"type"`     // resolution, source, codec, audioCodec, audioFeature, keyword, notKeyword, releaseGroup
-	Value    string `json:"value"`    // The value to match
-	Required bool   `json:"required"` // If true, must match or release rejected
-	Negate   bool   `json:"negate"`   // If true, condition is inverted
+	Type     string  `json:"type"`          // resolution, source, codec, audioCodec, audioFeature, keyword, notKeyword, releaseGroup, language, edition, streamingService, size
+	Value    string  `json:"value"`         // The value to match
+	Min      float64 `json:"min,omitempty"` // Size conditions: minimum size in GB, 0 = no minimum
+	Max      float64 `json:"max,omitempty"` // Size conditions: maximum size in GB, 0 = no maximum
+	Required bool    `json:"required"`      // If true, must match or release rejected
+	Negate   bool    `json:"negate"`        // If true, condition is inverted
 }
 
 // FormatScore represents a custom format with its score
@@ -180,7 +182,41 @@ func conditionMatches(release *parser.ParsedRelease, cond Condition) bool {
 		return strings.Contains(strings.ToLower(release.Title), value)
 
 	case "edition":
-		return strings.ToLower(release.Edition) == value
+		edition := parser.EditionName(value)
+		if edition == "" {
+			return false
+		}
+		for _, e := range release.Editions {
+			if e == edition {
+				return true
+			}
+		}
+		return release.Edition == edition
+
+	case "language":
+		if value == "multi" {
+			return release.HasMultiAudio || len(release.Languages) > 1
+		}
+		code := parser.LanguageCode(value)
+		for _, lang := range release.Languages {
+			if lang == code {
+				return true
+			}
+		}
+		return false
+
+	case "streamingService":
+		if code := parser.StreamingServiceCode(cond.Value); code != "" {
+			return release.StreamingService == code
+		}
+		return release.StreamingService != "" && strings.EqualFold(release.StreamingService, cond.Value)
+
+	case "size":
+		if release.Size <= 0 {
+			return false
+		}
+		gb := float64(release.Size) / (1024 * 1024 * 1024)
+		return (cond.Min <= 0 || gb >= cond.Min) && (cond.Max <= 0 || gb <= cond.Max)
 
 	case "proper":
 		return release.IsProper
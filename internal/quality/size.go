@@ -0,0 +1,58 @@
+package quality
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// HasSizeLimits reports whether a preset sets any size or bitrate limit
+func HasSizeLimits(preset *database.QualityPreset) bool {
+	return preset != nil && (preset.MinSizePerHour > 0 || preset.MaxSizePerHour > 0 ||
+		preset.MinBitrate > 0 || preset.MaxBitrate > 0)
+}
+
+// EstimateBitrate returns the average bitrate in Kbps of a file of the given
+// size played over runtime
+func EstimateBitrate(size int64, runtime time.Duration) int {
+	if runtime <= 0 {
+		return 0
+	}
+	return int(float64(size) * 8 / 1000 / runtime.Seconds())
+}
+
+// CheckSizeLimits checks a release's size against a preset's size per hour and
+// bitrate limits. runtime is the total runtime the release covers; nothing is
+// checked when it's unknown. Catches tiny fakes and bloated remuxes.
+func CheckSizeLimits(size int64, runtime time.Duration, preset *database.QualityPreset) *FormatRejection {
+	if !HasSizeLimits(preset) || size <= 0 || runtime <= 0 {
+		return nil
+	}
+
+	sizePerHour := int(float64(size) / (1024 * 1024) / runtime.Hours())
+	if preset.MinSizePerHour > 0 && sizePerHour < preset.MinSizePerHour {
+		return &FormatRejection{
+			Reason: fmt.Sprintf("Too small for runtime: %d MB/hour < %d MB/hour minimum", sizePerHour, preset.MinSizePerHour),
+		}
+	}
+	if preset.MaxSizePerHour > 0 && sizePerHour > preset.MaxSizePerHour {
+		return &FormatRejection{
+			Reason: fmt.Sprintf("Too large for runtime: %d MB/hour > %d MB/hour maximum", sizePerHour, preset.MaxSizePerHour),
+		}
+	}
+
+	bitrate := EstimateBitrate(size, runtime)
+	if preset.MinBitrate > 0 && bitrate < preset.MinBitrate {
+		return &FormatRejection{
+			Reason: fmt.Sprintf("Bitrate too low: ~%d Kbps < %d Kbps minimum", bitrate, preset.MinBitrate),
+		}
+	}
+	if preset.MaxBitrate > 0 && bitrate > preset.MaxBitrate {
+		return &FormatRejection{
+			Reason: fmt.Sprintf("Bitrate too high: ~%d Kbps > %d Kbps maximum", bitrate, preset.MaxBitrate),
+		}
+	}
+
+	return nil
+}
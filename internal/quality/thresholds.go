@@ -0,0 +1,48 @@
+package quality
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+)
+
+// CheckGrabThresholds checks a release against a preset's minimum seeders,
+// maximum age and first-seen wait. firstSeen is when the release first showed
+// up in search results; the wait is skipped when it's the zero time.
+func CheckGrabThresholds(result *indexer.SearchResult, firstSeen time.Time, preset *database.QualityPreset) *FormatRejection {
+	if preset == nil {
+		return nil
+	}
+
+	// Seeders only mean something for torrents; Newznab reports grabs there
+	if preset.MinSeeders > 0 && result.IsTorrent() && result.Seeders < preset.MinSeeders {
+		return &FormatRejection{
+			Reason: fmt.Sprintf("Insufficient seeders: %d < %d required", result.Seeders, preset.MinSeeders),
+		}
+	}
+
+	if preset.MaxAgeDays > 0 {
+		if published := result.PublishTime(); !published.IsZero() {
+			age := int(time.Since(published).Hours() / 24)
+			if age > preset.MaxAgeDays {
+				return &FormatRejection{
+					Reason: fmt.Sprintf("Release too old: %d days > %d days maximum", age, preset.MaxAgeDays),
+				}
+			}
+		}
+	}
+
+	if preset.FirstSeenWaitHours > 0 && !firstSeen.IsZero() {
+		wait := time.Duration(preset.FirstSeenWaitHours) * time.Hour
+		if seen := time.Since(firstSeen); seen < wait {
+			return &FormatRejection{
+				Reason: fmt.Sprintf("Waiting for release to settle: first seen %s ago, grabbing after %d hours",
+					seen.Truncate(time.Minute), preset.FirstSeenWaitHours),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/outpost/outpost/internal/comic"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Audiobooks
+//
+// Audio files in book libraries are audiobooks. Each M4B file is a book of
+// its own; other audio files in a folder are the parts of one book, played
+// in name order, unless there's only one. Chapters are read from the files,
+// and parts without any are chapters themselves. Titles and authors come
+// from the tags, or from the folder layout, Author/Title/ or
+// Author - Title.m4b, as for ebooks.
+
+var audiobookExtensions = map[string]bool{
+	".m4b": true, ".mp3": true, ".m4a": true, ".aac": true,
+	".ogg": true, ".opus": true, ".flac": true,
+}
+
+// audiobookGroup is the files of one audiobook. path is the M4B or single
+// file, or the folder of parts.
+type audiobookGroup struct {
+	path  string
+	files []string
+}
+
+// groupAudiobooks sorts the audio files found in each folder into
+// audiobooks
+func groupAudiobooks(audio map[string][]string) []audiobookGroup {
+	var groups []audiobookGroup
+	for dir, files := range audio {
+		sort.Slice(files, func(i, j int) bool { return comic.NaturalLess(filepath.Base(files[i]), filepath.Base(files[j])) })
+		var parts []string
+		for _, path := range files {
+			if strings.EqualFold(filepath.Ext(path), ".m4b") {
+				groups = append(groups, audiobookGroup{path: path, files: []string{path}})
+			} else {
+				parts = append(parts, path)
+			}
+		}
+		switch len(parts) {
+		case 0:
+		case 1:
+			groups = append(groups, audiobookGroup{path: parts[0], files: parts})
+		default:
+			groups = append(groups, audiobookGroup{path: dir, files: parts})
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].path < groups[j].path })
+	return groups
+}
+
+// audiobookFilesIn returns the audio files directly in a folder
+func audiobookFilesIn(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && audiobookExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
+
+// scanAudiobooks adds or updates the audiobooks in the audio files found in
+// a book library, by folder, and removes the ones whose files are gone
+func (s *Scanner) scanAudiobooks(ctx context.Context, lib *database.Library, audio map[string][]string, throttle *scanThrottle) error {
+	seen := make(map[string]bool)
+	for _, group := range groupAudiobooks(audio) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		seen[group.path] = true
+		s.importAudiobook(lib, group, throttle)
+	}
+
+	// A library folder that can't be read, such as an unmounted share,
+	// would look like every audiobook was removed
+	if _, err := os.Stat(lib.Path); err != nil {
+		return nil
+	}
+	books, err := s.db.GetAudiobooksByLibrary(lib.ID)
+	if err != nil {
+		return err
+	}
+	for _, book := range books {
+		if seen[book.Path] {
+			continue
+		}
+		if _, err := os.Stat(book.Path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := s.db.DeleteAudiobook(book.ID); err != nil {
+			logger.Errorf("Failed to remove audiobook %s: %v", book.Title, err)
+		} else {
+			logger.Infof("Removed audiobook: %s", book.Title)
+		}
+	}
+	return nil
+}
+
+// importAudiobookFolder adds or updates the audiobooks in a folder, as when
+// the watcher sees files added to it
+func (s *Scanner) importAudiobookFolder(lib *database.Library, dir string, throttle *scanThrottle) {
+	files := audiobookFilesIn(dir)
+	if len(files) == 0 {
+		return
+	}
+	for _, group := range groupAudiobooks(map[string][]string{dir: files}) {
+		s.importAudiobook(lib, group, throttle)
+	}
+}
+
+// importAudiobook adds an audiobook to a book library, or reads it again if
+// its files changed since it was added
+func (s *Scanner) importAudiobook(lib *database.Library, group audiobookGroup, throttle *scanThrottle) {
+	files := make([]database.AudiobookFile, 0, len(group.files))
+	for _, path := range group.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, database.AudiobookFile{Path: path, Size: info.Size()})
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	existing, err := s.db.GetAudiobookByPath(group.path)
+	if err == nil && !s.audiobookChanged(existing, files) {
+		return
+	}
+
+	// Lay the files end to end; their chapters, or the files themselves,
+	// are the book's chapters
+	var chapters []database.Chapter
+	var tags map[string]string
+	var start float64
+	for i := range files {
+		f := &files[i]
+		throttle.pause()
+		probe := probeAudiobookFile(f.Path)
+		if i == 0 {
+			tags = probe.tags
+		}
+		f.StartTime = start
+		f.Duration = probe.duration
+
+		switch {
+		case len(probe.chapters) > 0:
+			for _, ch := range probe.chapters {
+				chapters = append(chapters, database.Chapter{
+					Title:     ch.Title,
+					StartTime: start + ch.StartTime,
+					EndTime:   start + ch.EndTime,
+				})
+			}
+		case len(files) > 1:
+			title := probe.tags["title"]
+			if title == "" {
+				_, title = parseTrackFilename(strings.TrimSuffix(filepath.Base(f.Path), filepath.Ext(f.Path)))
+			}
+			chapters = append(chapters, database.Chapter{Title: title, StartTime: start, EndTime: start + probe.duration})
+		}
+		start += probe.duration
+	}
+	for i := range chapters {
+		if chapters[i].Title == "" {
+			chapters[i].Title = fmt.Sprintf("Chapter %d", i+1)
+		}
+	}
+
+	book := audiobookDetails(lib, group, tags)
+	if err := s.db.SaveAudiobook(book, files, chapters); err != nil {
+		logger.Errorf("Failed to save audiobook %s: %v", group.path, err)
+		return
+	}
+	if existing != nil {
+		logger.Infof("Updated audiobook: %s", book.Title)
+	} else if book.Author != nil {
+		logger.Infof("Added audiobook: %s by %s", book.Title, *book.Author)
+	} else {
+		logger.Infof("Added audiobook: %s", book.Title)
+	}
+}
+
+// audiobookChanged reports whether an audiobook's files were added,
+// removed or changed in size since it was read
+func (s *Scanner) audiobookChanged(book *database.Audiobook, files []database.AudiobookFile) bool {
+	stored, err := s.db.GetAudiobookFiles(book.ID)
+	if err != nil || len(stored) != len(files) {
+		return true
+	}
+	for i := range files {
+		if stored[i].Path != files[i].Path || stored[i].Size != files[i].Size {
+			return true
+		}
+	}
+	return false
+}
+
+// audiobookDetails names an audiobook from its first file's tags, falling
+// back to its path
+func audiobookDetails(lib *database.Library, group audiobookGroup, tags map[string]string) *database.Audiobook {
+	optional := func(keys ...string) *string {
+		for _, key := range keys {
+			if v := strings.TrimSpace(tags[key]); v != "" {
+				return &v
+			}
+		}
+		return nil
+	}
+
+	book := &database.Audiobook{LibraryID: lib.ID, Path: group.path}
+
+	// A lone file's title tag is the book's; parts have their own titles
+	// and share an album
+	titleKeys := []string{"album"}
+	if len(group.files) == 1 {
+		titleKeys = append(titleKeys, "title")
+	}
+	if title := optional(titleKeys...); title != nil {
+		book.Title = *title
+	}
+	book.Author = optional("album_artist", "artist", "author")
+	book.Narrator = optional("narrator", "composer")
+	book.Description = optional("description", "synopsis")
+	if date := tags["date"]; len(date) >= 4 {
+		book.Year, _ = strconv.Atoi(date[:4])
+	}
+
+	if book.Title == "" || book.Author == nil {
+		name := filepath.Base(group.path)
+		if len(group.files) == 1 && group.path == group.files[0] {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		title, author := parseBookFilename(name)
+		// Author/Title/ folders
+		if parent := filepath.Dir(group.path); author == "" && len(group.files) > 1 && parent != filepath.Clean(lib.Path) {
+			author = filepath.Base(parent)
+		}
+		if book.Title == "" {
+			book.Title = title
+		}
+		if book.Author == nil && author != "" {
+			book.Author = &author
+		}
+	}
+	return book
+}
+
+// audiobookProbe is what probeAudiobookFile reads from an audio file
+type audiobookProbe struct {
+	duration float64
+	tags     map[string]string // Lower case names
+	chapters []database.Chapter
+}
+
+// probeAudiobookFile reads an audio file's duration, tags and chapters with
+// ffprobe. A file ffprobe can't read has none.
+func probeAudiobookFile(path string) audiobookProbe {
+	probe := audiobookProbe{tags: make(map[string]string)}
+	cmd := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "format=duration:format_tags", "-show_chapters", "-of", "json", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return probe
+	}
+
+	var result struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Chapters []struct {
+			TimeBase  string            `json:"time_base"`
+			Start     int64             `json:"start"`
+			StartTime string            `json:"start_time"`
+			End       int64             `json:"end"`
+			EndTime   string            `json:"end_time"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return probe
+	}
+	probe.duration, _ = strconv.ParseFloat(result.Format.Duration, 64)
+	for k, v := range result.Format.Tags {
+		probe.tags[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	for _, ch := range result.Chapters {
+		startTime, err := strconv.ParseFloat(ch.StartTime, 64)
+		if err != nil {
+			startTime = chapterSeconds(ch.Start, ch.TimeBase)
+		}
+		endTime, err := strconv.ParseFloat(ch.EndTime, 64)
+		if err != nil {
+			endTime = chapterSeconds(ch.End, ch.TimeBase)
+		}
+		probe.chapters = append(probe.chapters, database.Chapter{
+			Title:     strings.TrimSpace(ch.Tags["title"]),
+			StartTime: startTime,
+			EndTime:   endTime,
+		})
+	}
+	return probe
+}
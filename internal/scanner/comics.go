@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/outpost/outpost/internal/comic"
+	"github.com/outpost/outpost/internal/database"
+)
+
+// coverWidth is the width comic covers are cached at
+const coverWidth = 600
+
+// isComic reports whether a book is a comic archive
+func isComic(book *database.Book) bool {
+	return book.Format == "cbz" || book.Format == "cbr"
+}
+
+// readComic fills in a comic's page count, its ComicInfo.xml details and
+// its cover. Fails only if the archive can't be read.
+func (s *Scanner) readComic(book *database.Book, info os.FileInfo) error {
+	archive, err := comic.Open(book.Path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	pages := len(archive.Pages)
+	book.PageCount = &pages
+
+	if ci := archive.Info; ci != nil {
+		optional := func(v string) *string {
+			if v = strings.TrimSpace(v); v == "" {
+				return nil
+			}
+			return &v
+		}
+		if ci.Series != "" {
+			book.Series = optional(ci.Series)
+			book.IssueNumber = optional(ci.Number)
+			book.Title = comicTitle(ci)
+		} else if ci.Title != "" {
+			book.Title = strings.TrimSpace(ci.Title)
+		}
+		if ci.Writer != "" {
+			book.Author = optional(ci.Writer)
+		}
+		if ci.Publisher != "" {
+			book.Publisher = optional(ci.Publisher)
+		}
+		if ci.Summary != "" {
+			book.Description = optional(ci.Summary)
+		}
+		if ci.Year > 0 {
+			book.Year = ci.Year
+		}
+	}
+
+	// A comic without a cover can still be read
+	if cover, err := s.cacheComicCover(archive, book.Path, info); err == nil {
+		book.CoverPath = &cover
+	} else {
+		logger.Errorf("Failed to cache cover of %s: %v", book.Path, err)
+	}
+	return nil
+}
+
+// comicTitle names an issue "Series #Number: Title", leaving out what
+// ComicInfo.xml doesn't have
+func comicTitle(ci *comic.ComicInfo) string {
+	title := strings.TrimSpace(ci.Series)
+	if n := strings.TrimSpace(ci.Number); n != "" {
+		title += " #" + n
+	}
+	if t := strings.TrimSpace(ci.Title); t != "" {
+		title += ": " + t
+	}
+	return title
+}
+
+// cacheComicCover saves a comic's cover page to the image cache, returning
+// its path there
+func (s *Scanner) cacheComicCover(archive *comic.Archive, path string, info os.FileInfo) (string, error) {
+	return s.cacheCover("comics", path, info, func() ([]byte, string, error) {
+		index := archive.Info.CoverIndex()
+		if index >= len(archive.Pages) {
+			index = 0
+		}
+		data, contentType, err := archive.Page(index)
+		if err != nil {
+			return nil, "", err
+		}
+		// Covers no wider than coverWidth are kept in their own format
+		return comic.Resize(data, contentType, coverWidth)
+	})
+}
+
+// cacheCover saves a cover read from a file to a folder of the image cache,
+// returning its path there. Covers are named after the file and when it
+// changed, so one already cached isn't read again.
+func (s *Scanner) cacheCover(dir, path string, info os.FileInfo, read func() ([]byte, string, error)) (string, error) {
+	if s.meta == nil || s.meta.Images() == nil {
+		return "", fmt.Errorf("no image cache")
+	}
+	images := s.meta.Images()
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%d", path, info.ModTime().Unix())))
+	name := hex.EncodeToString(sum[:])
+	if existing, _ := images.List(dir + "/" + name + "."); len(existing) > 0 {
+		return existing[0].Key, nil
+	}
+
+	data, contentType, err := read()
+	if err != nil {
+		return "", err
+	}
+	coverPath := filepath.Join(dir, name+comic.Extension(contentType))
+	if err := images.Put(coverPath, bytes.NewReader(data), contentType); err != nil {
+		return "", err
+	}
+	return coverPath, nil
+}
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"os"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// DedupeResult summarizes a library deduplication pass
+type DedupeResult struct {
+	MoviesMerged int                       `json:"moviesMerged"`
+	ShowsMerged  int                       `json:"showsMerged"`
+	Groups       []database.DuplicateGroup `json:"groups"`
+}
+
+// fileExists reports whether a file or folder is on disk
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Dedupe merges movies and shows in a library that were matched to the same
+// TMDB ID, usually after a rescan of reorganized folders added them a second
+// time. The oldest row whose file is still on disk is kept; the others' files
+// become versions of it. libraryID 0 dedupes every library.
+func (s *Scanner) Dedupe(libraryID int64) (*DedupeResult, error) {
+	result := &DedupeResult{Groups: []database.DuplicateGroup{}}
+
+	movieGroups, err := s.db.FindDuplicateMovies(libraryID)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range movieGroups {
+		merged := s.mergeMovieGroup(group)
+		if merged > 0 {
+			result.MoviesMerged += merged
+			result.Groups = append(result.Groups, group)
+		}
+	}
+
+	showGroups, err := s.db.FindDuplicateShows(libraryID)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range showGroups {
+		merged := s.mergeShowGroup(group)
+		if merged > 0 {
+			result.ShowsMerged += merged
+			result.Groups = append(result.Groups, group)
+		}
+	}
+
+	s.pruneVersions()
+
+	if result.MoviesMerged > 0 || result.ShowsMerged > 0 {
+		logger.Infof("Dedupe: merged %d duplicate movies and %d duplicate shows", result.MoviesMerged, result.ShowsMerged)
+	}
+	return result, nil
+}
+
+// mergeMovieGroup merges a group of duplicate movies, returning how many
+// were merged away
+func (s *Scanner) mergeMovieGroup(group database.DuplicateGroup) int {
+	paths := make(map[int64]string, len(group.IDs))
+	keepID := group.IDs[0]
+	found := false
+	for _, id := range group.IDs {
+		movie, err := s.db.GetMovie(id)
+		if err != nil {
+			continue
+		}
+		paths[id] = movie.Path
+		if !found && fileExists(movie.Path) {
+			keepID, found = id, true
+		}
+	}
+
+	merged := 0
+	for _, id := range group.IDs {
+		path, ok := paths[id]
+		if id == keepID || !ok {
+			continue
+		}
+		if err := s.db.MergeMovies(keepID, id, fileExists(path)); err != nil {
+			logger.Errorf("Dedupe: failed to merge movie %d into %d (%s): %v", id, keepID, group.Title, err)
+			continue
+		}
+		logger.Infof("Dedupe: merged duplicate movie %s (%d -> %d)", group.Title, id, keepID)
+		merged++
+	}
+	return merged
+}
+
+// mergeShowGroup merges a group of duplicate shows, returning how many were
+// merged away
+func (s *Scanner) mergeShowGroup(group database.DuplicateGroup) int {
+	keepID := group.IDs[0]
+	for _, id := range group.IDs {
+		if show, err := s.db.GetShow(id); err == nil && fileExists(show.Path) {
+			keepID = id
+			break
+		}
+	}
+
+	merged := 0
+	for _, id := range group.IDs {
+		if id == keepID {
+			continue
+		}
+		if err := s.db.MergeShows(keepID, id, fileExists); err != nil {
+			logger.Errorf("Dedupe: failed to merge show %d into %d (%s): %v", id, keepID, group.Title, err)
+			continue
+		}
+		logger.Infof("Dedupe: merged duplicate show %s (%d -> %d)", group.Title, id, keepID)
+		merged++
+	}
+	return merged
+}
+
+// pruneVersions forgets versions whose file or movie/episode is gone
+func (s *Scanner) pruneVersions() {
+	if n, err := s.db.DeleteOrphanedMediaVersions(); err != nil {
+		logger.Errorf("Failed to delete orphaned versions: %v", err)
+	} else if n > 0 {
+		logger.Infof("Deleted %d orphaned versions", n)
+	}
+
+	versions, err := s.db.GetAllMediaVersions()
+	if err != nil {
+		logger.Errorf("Failed to get versions: %v", err)
+		return
+	}
+	for _, v := range versions {
+		if !fileExists(v.Path) {
+			if err := s.db.DeleteMediaVersion(v.ID); err == nil {
+				logger.Infof("Removed missing version: %s", v.Path)
+			}
+		}
+	}
+}
+
+// promoteVersion replaces a missing main file with one of its versions that's
+// still on disk. Returns false if there is none.
+func (s *Scanner) promoteVersion(mediaType string, mediaID int64) bool {
+	versions, err := s.db.GetMediaVersions(mediaType, mediaID)
+	if err != nil {
+		return false
+	}
+	for i := range versions {
+		if !fileExists(versions[i].Path) {
+			continue
+		}
+		if err := s.db.PromoteMediaVersion(&versions[i]); err != nil {
+			logger.Errorf("Failed to promote version %s: %v", versions[i].Path, err)
+			return false
+		}
+		s.RemoveTrickplay(mediaType, mediaID)
+		logger.Infof("Main file missing, switched to version: %s", versions[i].Path)
+		return true
+	}
+	return false
+}
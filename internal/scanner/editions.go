@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/outpost/outpost/internal/parser"
+)
+
+// Movie editions
+//
+// A movie file's edition (Director's Cut, Extended, IMAX Enhanced, ...) comes
+// from an "{edition-...}" tag in its file or folder name, or else from the
+// edition words after the year in them. Organized files keep their edition
+// in an "{edition-...}" tag so it survives renames and rescans, and so two
+// editions of a movie can share its folder.
+
+// editionTagPattern matches an edition tag, e.g. "{edition-Director's Cut}"
+var editionTagPattern = regexp.MustCompile(`(?i)\{edition-([^}]+)\}`)
+
+// fileEdition returns the edition of a movie file, or nil if it's the plain
+// release
+func fileEdition(path string) *string {
+	file := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, name := range []string{file, filepath.Base(filepath.Dir(path))} {
+		if m := editionTagPattern.FindStringSubmatch(name); m != nil {
+			if edition := parser.EditionName(m[1]); edition != "" {
+				return &edition
+			}
+			continue
+		}
+		// Only what follows the year counts, so titles with edition words in
+		// them ("The Ultimate Gift") aren't taken for editions
+		if loc := movieYearPattern.FindStringIndex(name); loc != nil {
+			name = name[loc[1]:]
+		}
+		if edition := parser.EditionOf(name); edition != "" {
+			return &edition
+		}
+	}
+	return nil
+}
+
+// editionFileName adds a movie's edition tag to the name its file is
+// organized under
+func editionFileName(name string, edition *string) string {
+	if edition == nil || *edition == "" {
+		return name
+	}
+	return fmt.Sprintf("%s {edition-%s}", name, parser.EditionLabel(*edition))
+}
+
+// detectEditions records the editions of a library's movies that have none
+// recorded, such as those scanned before editions were
+func (s *Scanner) detectEditions(libraryID int64) {
+	paths, err := s.db.GetMoviePathsWithoutEdition(libraryID)
+	if err != nil {
+		logger.Errorf("Failed to get movies without editions: %v", err)
+		return
+	}
+	for id, path := range paths {
+		edition := fileEdition(path)
+		if edition == nil {
+			continue
+		}
+		if err := s.db.SetMovieEdition(id, *edition); err != nil {
+			logger.Errorf("Failed to record the edition of %s: %v", path, err)
+		}
+	}
+}
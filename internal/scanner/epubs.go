@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"os"
+
+	"github.com/outpost/outpost/internal/comic"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/epub"
+)
+
+// readEpubCover caches the cover image of an EPUB, as books in the library
+// have no artwork until a metadata match gives them some. Books without a
+// cover fail with epub.ErrNoCover.
+func (s *Scanner) readEpubCover(book *database.Book, info os.FileInfo) error {
+	b, err := epub.Open(book.Path)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	cover, err := s.cacheCover("books", book.Path, info, func() ([]byte, string, error) {
+		data, contentType, err := b.Cover()
+		if err != nil {
+			return nil, "", err
+		}
+		// SVG covers can't be decoded, and are left out
+		return comic.Resize(data, contentType, coverWidth)
+	})
+	if err != nil {
+		return err
+	}
+	book.CoverPath = &cover
+	return nil
+}
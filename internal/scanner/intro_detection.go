@@ -3,7 +3,6 @@ package scanner
 import (
 	"bytes"
 	"encoding/binary"
-	"log"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -38,7 +37,7 @@ func (d *IntroDetector) ExtractFingerprint(videoPath string, maxDuration float64
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		log.Printf("fpcalc error for %s: %s", videoPath, stderr.String())
+		logger.Errorf("fpcalc error for %s: %s", videoPath, stderr.String())
 		return nil, 0, err
 	}
 
@@ -211,7 +210,7 @@ func FindCommonSegments(fp1, fp2 []uint32, minSimilarity float64, minDurationSec
 	}
 	var topMatches []matchInfo
 
-	log.Printf("FindCommonSegments: searching range %d-%d (%.1f-%.1f sec) with window %d (%.1f sec), step %d",
+	logger.Infof("FindCommonSegments: searching range %d-%d (%.1f-%.1f sec) with window %d (%.1f sec), step %d",
 		minOffset, maxOffset, float64(minOffset)/valuesPerSecond, float64(maxOffset)/valuesPerSecond,
 		windowSizeValues, float64(windowSizeValues)/valuesPerSecond, step)
 
@@ -269,9 +268,9 @@ func FindCommonSegments(fp1, fp2 []uint32, minSimilarity float64, minDurationSec
 	}
 
 	// Log top matches for debugging
-	log.Printf("FindCommonSegments: top 5 matches (threshold %.2f):", minSimilarity)
+	logger.Infof("FindCommonSegments: top 5 matches (threshold %.2f):", minSimilarity)
 	for _, m := range topMatches {
-		log.Printf("  %.1f-%.1f sec <-> %.1f-%.1f sec: sim=%.3f",
+		logger.Infof("  %.1f-%.1f sec <-> %.1f-%.1f sec: sim=%.3f",
 			float64(m.i)/valuesPerSecond, float64(m.i+windowSizeValues)/valuesPerSecond,
 			float64(m.j)/valuesPerSecond, float64(m.j+windowSizeValues)/valuesPerSecond, m.sim)
 	}
@@ -279,11 +278,11 @@ func FindCommonSegments(fp1, fp2 []uint32, minSimilarity float64, minDurationSec
 	if bestSimilarity >= minSimilarity {
 		// Refine the match by extending it
 		refined := refineMatch(fp1, fp2, bestMatch, minSimilarity, valuesPerSecond)
-		log.Printf("FindCommonSegments: refined match %.1f-%.1f <-> %.1f-%.1f",
+		logger.Infof("FindCommonSegments: refined match %.1f-%.1f <-> %.1f-%.1f",
 			refined.StartSeconds1, refined.EndSeconds1, refined.StartSeconds2, refined.EndSeconds2)
 		matches = append(matches, refined)
 	} else {
-		log.Printf("FindCommonSegments: no match above threshold (best was %.3f)", bestSimilarity)
+		logger.Infof("FindCommonSegments: no match above threshold (best was %.3f)", bestSimilarity)
 	}
 
 	return matches
@@ -335,11 +334,11 @@ func (d *IntroDetector) DetectIntroForSeason(seasonID int64) error {
 	}
 
 	if len(fingerprints) < 2 {
-		log.Printf("Season %d has fewer than 2 fingerprints, skipping intro detection", seasonID)
+		logger.Infof("Season %d has fewer than 2 fingerprints, skipping intro detection", seasonID)
 		return nil
 	}
 
-	log.Printf("Analyzing %d episodes for intro detection in season %d", len(fingerprints), seasonID)
+	logger.Infof("Analyzing %d episodes for intro detection in season %d", len(fingerprints), seasonID)
 
 	// Compare each pair of consecutive episodes
 	minSimilarity := 0.65 // 65% similarity threshold - higher to reduce false positives
@@ -356,7 +355,7 @@ func (d *IntroDetector) DetectIntroForSeason(seasonID int64) error {
 		matches := FindCommonSegments(fp1, fp2, minSimilarity, minDuration)
 
 		if len(matches) == 0 {
-			log.Printf("No matching segments found between episode %d and %d", fingerprints[i].EpisodeID, fingerprints[i+1].EpisodeID)
+			logger.Infof("No matching segments found between episode %d and %d", fingerprints[i].EpisodeID, fingerprints[i+1].EpisodeID)
 		}
 
 		for _, match := range matches {
@@ -366,7 +365,7 @@ func (d *IntroDetector) DetectIntroForSeason(seasonID int64) error {
 			introStarts[fingerprints[i+1].EpisodeID] = append(introStarts[fingerprints[i+1].EpisodeID], match.StartSeconds2)
 			introEnds[fingerprints[i+1].EpisodeID] = append(introEnds[fingerprints[i+1].EpisodeID], match.EndSeconds2)
 
-			log.Printf("Found common segment: Episode %d (%.1f-%.1f) <-> Episode %d (%.1f-%.1f), similarity: %.2f",
+			logger.Infof("Found common segment: Episode %d (%.1f-%.1f) <-> Episode %d (%.1f-%.1f), similarity: %.2f",
 				fingerprints[i].EpisodeID, match.StartSeconds1, match.EndSeconds1,
 				fingerprints[i+1].EpisodeID, match.StartSeconds2, match.EndSeconds2,
 				match.Similarity)
@@ -405,9 +404,9 @@ func (d *IntroDetector) DetectIntroForSeason(seasonID int64) error {
 		}
 
 		if err := d.db.CreateMediaSegment(segment); err != nil {
-			log.Printf("Failed to save intro segment for episode %d: %v", episodeID, err)
+			logger.Errorf("Failed to save intro segment for episode %d: %v", episodeID, err)
 		} else {
-			log.Printf("Saved intro segment for episode %d: %.1f-%.1f", episodeID, medianStart, medianEnd)
+			logger.Infof("Saved intro segment for episode %d: %.1f-%.1f", episodeID, medianStart, medianEnd)
 		}
 	}
 
@@ -437,7 +436,7 @@ func (d *IntroDetector) AnalyzeEpisode(episode *database.Episode) error {
 		return nil
 	}
 
-	log.Printf("Extracting fingerprint for episode %d: %s", episode.ID, episode.Path)
+	logger.Infof("Extracting fingerprint for episode %d: %s", episode.ID, episode.Path)
 
 	// Extract first 5 minutes for intro detection
 	fp, duration, err := d.ExtractFingerprint(episode.Path, 300)
@@ -446,7 +445,7 @@ func (d *IntroDetector) AnalyzeEpisode(episode *database.Episode) error {
 	}
 
 	if len(fp) == 0 {
-		log.Printf("No fingerprint data extracted for episode %d", episode.ID)
+		logger.Infof("No fingerprint data extracted for episode %d", episode.ID)
 		return nil
 	}
 
@@ -471,7 +470,7 @@ func (d *IntroDetector) AnalyzeSeason(seasonID int64) error {
 	// Extract fingerprints for each episode
 	for _, ep := range episodes {
 		if err := d.AnalyzeEpisode(&ep); err != nil {
-			log.Printf("Failed to analyze episode %d: %v", ep.ID, err)
+			logger.Errorf("Failed to analyze episode %d: %v", ep.ID, err)
 			continue
 		}
 	}
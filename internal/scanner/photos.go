@@ -0,0 +1,230 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/photo"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+// photoExtensions are the pictures photo libraries hold. Formats that can't
+// be decoded for thumbnails, like HEIC and camera raw files, are left out.
+var photoExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+}
+
+// maxPhotoSize bounds the photos read; larger files are skipped
+const maxPhotoSize = 200 << 20
+
+// photoThumbnailDir returns where a photo's thumbnail folder is, dir being
+// the folder as saved with the photo. Thumbnails are kept in the data
+// directory rather than the image cache, which is served to anyone, so only
+// those who can see a photo's library can see them.
+func photoThumbnailDir(dataDir, dir string) string {
+	return filepath.Join(dataDir, "thumbnails", filepath.FromSlash(dir))
+}
+
+// PhotoThumbnailFile returns the file of a photo's thumbnail in a size
+func PhotoThumbnailFile(dataDir, dir, size string) string {
+	return filepath.Join(photoThumbnailDir(dataDir, dir), size+".jpg")
+}
+
+// scanPhotos scans a photo library, adding new and changed pictures and
+// removing the ones no longer there
+func (s *Scanner) scanPhotos(ctx context.Context, lib *database.Library) error {
+	throttle := s.throttleFor(lib)
+	known, err := s.db.GetPhotoPaths(lib.ID)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if !photoExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		delete(known, path)
+		s.scanPhotoFile(lib, path, info, throttle)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// What's left wasn't found. A folder that can't be read, as with an
+	// unmounted share, doesn't lose its photos.
+	for path, id := range known {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			s.removePhoto(id)
+		}
+	}
+	return nil
+}
+
+// scanPhotoFile adds a photo found by a scan, or reads it again if it
+// changed or its thumbnails couldn't be made before
+func (s *Scanner) scanPhotoFile(lib *database.Library, path string, info os.FileInfo, throttle *scanThrottle) {
+	existing, err := s.db.GetPhotoByPath(path)
+	if err == nil && existing.FileMtime == info.ModTime().Unix() && existing.ThumbnailPath != nil {
+		return
+	}
+	throttle.pause()
+	if err := s.importPhoto(lib, path, info, existing); err != nil {
+		logger.Errorf("Failed to import photo %s: %v", path, err)
+	}
+}
+
+// importPhoto reads a photo's details and EXIF metadata, makes its
+// thumbnails and saves it. existing is the photo saved for the file before,
+// if there is one.
+func (s *Scanner) importPhoto(lib *database.Library, path string, info os.FileInfo, existing *database.Photo) error {
+	if info.Size() > maxPhotoSize {
+		return fmt.Errorf("file is larger than %d MB", maxPhotoSize>>20)
+	}
+	// The file is streamed rather than read into memory, as scans import
+	// several photos at once
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	orientation := 1
+	exif, err := photo.ReadEXIF(file, info.Size())
+	if err != nil && !errors.Is(err, photo.ErrNoEXIF) {
+		logger.Debugf("Failed to read EXIF of %s: %v", path, err)
+	}
+	if exif != nil {
+		orientation = exif.Orientation
+	}
+	format, width, height, err := photo.Config(io.NewSectionReader(file, 0, info.Size()), orientation)
+	if err != nil {
+		return err
+	}
+
+	album, _ := filepath.Rel(lib.Path, filepath.Dir(path))
+	if album == "." || strings.HasPrefix(album, "..") {
+		album = ""
+	}
+	p := &database.Photo{
+		LibraryID: lib.ID,
+		Path:      path,
+		Album:     filepath.ToSlash(album),
+		Title:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Format:    format,
+		Width:     width,
+		Height:    height,
+		Size:      info.Size(),
+		FileMtime: info.ModTime().Unix(),
+	}
+
+	// Photos are dated by the wall clock, so a file's time is read in the
+	// server's time zone
+	modified := info.ModTime().In(timezone.Location())
+	p.TakenAt = time.Date(modified.Year(), modified.Month(), modified.Day(),
+		modified.Hour(), modified.Minute(), modified.Second(), 0, time.UTC)
+	if exif != nil {
+		if exif.TakenAt != nil {
+			p.TakenAt = *exif.TakenAt
+			p.EXIFDate = true
+		}
+		if camera := exif.Camera(); camera != "" {
+			p.Camera = &camera
+		}
+		p.Latitude = exif.Latitude
+		p.Longitude = exif.Longitude
+	}
+
+	// A photo without thumbnails is still listed, and they're tried again
+	// on the next scan
+	if thumbnails, err := s.savePhotoThumbnails(path, info, io.NewSectionReader(file, 0, info.Size()), orientation); err == nil {
+		p.ThumbnailPath = &thumbnails
+	} else {
+		logger.Errorf("Failed to make thumbnails of %s: %v", path, err)
+	}
+
+	if err := s.db.SavePhoto(p); err != nil {
+		return err
+	}
+	if existing != nil && existing.ThumbnailPath != nil && (p.ThumbnailPath == nil || *existing.ThumbnailPath != *p.ThumbnailPath) {
+		s.deletePhotoThumbnails(*existing.ThumbnailPath)
+	}
+	return nil
+}
+
+// savePhotoThumbnails saves a photo's thumbnails to a folder, one JPEG per
+// size, returning the folder. Folders are named after the file and when it
+// changed, so thumbnails already made aren't made again.
+func (s *Scanner) savePhotoThumbnails(path string, info os.FileInfo, r io.ReadSeeker, orientation int) (string, error) {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s:%d", path, info.ModTime().Unix())))
+	dir := "photos/" + hex.EncodeToString(sum[:])
+	if s.hasPhotoThumbnails(dir) {
+		return dir, nil
+	}
+
+	thumbnails, err := photo.Thumbnails(r, orientation)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(photoThumbnailDir(s.cacheDir, dir), 0755); err != nil {
+		return "", err
+	}
+	for _, t := range thumbnails {
+		if err := os.WriteFile(PhotoThumbnailFile(s.cacheDir, dir, t.Size.Name), t.Data, 0644); err != nil {
+			s.deletePhotoThumbnails(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// hasPhotoThumbnails reports whether a folder has a thumbnail in each size
+func (s *Scanner) hasPhotoThumbnails(dir string) bool {
+	for _, size := range photo.Sizes {
+		if _, err := os.Stat(PhotoThumbnailFile(s.cacheDir, dir, size.Name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// deletePhotoThumbnails removes a folder of thumbnails
+func (s *Scanner) deletePhotoThumbnails(dir string) {
+	if !strings.HasPrefix(dir, "photos/") {
+		return
+	}
+	if err := os.RemoveAll(photoThumbnailDir(s.cacheDir, dir)); err != nil {
+		logger.Errorf("Failed to delete thumbnails %s: %v", dir, err)
+	}
+}
+
+// removePhoto deletes a photo whose file is gone, with its thumbnails
+func (s *Scanner) removePhoto(id int64) {
+	p, err := s.db.GetPhoto(id)
+	if err != nil {
+		return
+	}
+	if err := s.db.DeletePhoto(id); err != nil {
+		logger.Errorf("Failed to remove photo %s: %v", p.Path, err)
+		return
+	}
+	if p.ThumbnailPath != nil {
+		s.deletePhotoThumbnails(*p.ThumbnailPath)
+	}
+	logger.Infof("Removed photo: %s", p.Path)
+}
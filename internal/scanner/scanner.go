@@ -1,26 +1,32 @@
 package scanner
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/epub"
+	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/metadata"
 	"github.com/outpost/outpost/internal/parser"
 	"github.com/outpost/outpost/internal/quality"
 	"github.com/outpost/outpost/internal/subtitles"
 )
 
+var logger = logging.Module("scanner")
+
 var videoExtensions = map[string]bool{
 	".mkv": true, ".mp4": true, ".avi": true, ".mov": true,
 	".wmv": true, ".flv": true, ".webm": true, ".m4v": true,
@@ -36,6 +42,9 @@ var bookExtensions = map[string]bool{
 	".cbz": true, ".cbr": true, ".azw": true, ".azw3": true,
 }
 
+// comicExtensions are the files comics libraries hold
+var comicExtensions = map[string]bool{".cbz": true, ".cbr": true}
+
 // Movie filename patterns
 // Examples: "Movie Name (2020).mkv", "Movie.Name.2020.1080p.BluRay.mkv"
 var movieYearPattern = regexp.MustCompile(`^(.+?)[\.\s\-_]*\(?(\d{4})\)?`)
@@ -90,12 +99,14 @@ type Scanner struct {
 	cacheDir string
 
 	// Progress tracking
-	scanning     bool
-	scanLibrary  string
-	scanTotal    int
-	scanCurrent  int
-	scanPhase    string // "counting", "scanning", "extracting"
-	mu           sync.RWMutex
+	scanning    bool
+	scanLibrary string
+	scanTotal   int
+	scanCurrent int
+	scanPhase   string    // "counting", "scanning", "extracting"
+	scanStarted time.Time // When the scanning phase started, for estimating the time left
+	throttled   bool
+	mu          sync.RWMutex
 
 	// Result tracking (persists after scan completes)
 	lastLibrary string
@@ -103,15 +114,24 @@ type Scanner struct {
 	lastSkipped int
 	lastErrors  int
 	lastScanAt  time.Time
+
+	// Held while a library is scanned or watched changes are imported, so
+	// the same file isn't added twice
+	libraryLocks   map[int64]*sync.Mutex
+	libraryLocksMu sync.Mutex
 }
 
 type ScanProgress struct {
-	Scanning    bool   `json:"scanning"`
-	Library     string `json:"library"`
-	Phase       string `json:"phase"`
-	Current     int    `json:"current"`
-	Total       int    `json:"total"`
-	Percent     int    `json:"percent"`
+	Scanning  bool   `json:"scanning"`
+	Library   string `json:"library"`
+	Phase     string `json:"phase"`
+	Current   int    `json:"current"`
+	Total     int    `json:"total"`
+	Percent   int    `json:"percent"`
+	Throttled bool   `json:"throttled"`
+	// Seconds the scan has left at the rate it's going, which is slower when
+	// throttled; 0 until it can tell
+	EstimatedSeconds int `json:"estimatedSeconds"`
 	// Result of last scan
 	LastLibrary string `json:"lastLibrary,omitempty"`
 	LastAdded   int    `json:"lastAdded"`
@@ -137,7 +157,7 @@ func New(db *database.Database, meta *metadata.Service, cacheDir string) *Scanne
 func (s *Scanner) FixMissingSizes() {
 	episodes, err := s.db.GetEpisodesWithMissingSize()
 	if err != nil {
-		log.Printf("Failed to get episodes with missing sizes: %v", err)
+		logger.Errorf("Failed to get episodes with missing sizes: %v", err)
 		return
 	}
 
@@ -145,7 +165,7 @@ func (s *Scanner) FixMissingSizes() {
 		return
 	}
 
-	log.Printf("Fixing file sizes for %d episodes...", len(episodes))
+	logger.Infof("Fixing file sizes for %d episodes...", len(episodes))
 	fixed := 0
 	for _, ep := range episodes {
 		info, err := os.Stat(ep.Path)
@@ -157,7 +177,7 @@ func (s *Scanner) FixMissingSizes() {
 		}
 	}
 	if fixed > 0 {
-		log.Printf("Fixed file sizes for %d episodes", fixed)
+		logger.Infof("Fixed file sizes for %d episodes", fixed)
 	}
 }
 
@@ -252,7 +272,7 @@ func (s *Scanner) detectAndStoreQuality(mediaID int64, mediaType string, filenam
 			if newScore, ok := quality.BaseQualityScores[qualityTier]; ok && newScore > 0 {
 				baseScore = newScore
 			}
-			log.Printf("Detected quality from file %s: %s %s (score: %d)", filename, parsed.Resolution, parsed.Source, baseScore)
+			logger.Infof("Detected quality from file %s: %s %s (score: %d)", filename, parsed.Resolution, parsed.Source, baseScore)
 		}
 	}
 
@@ -310,8 +330,63 @@ func (s *Scanner) detectAndStoreQuality(mediaID int64, mediaType string, filenam
 		UpgradeAvailable:  false,
 	}
 
+	previous, _ := s.db.GetMediaQualityStatus(mediaID, mediaType)
 	if err := s.db.UpsertMediaQualityStatus(status); err != nil {
-		log.Printf("Failed to store quality status for %s %d: %v", mediaType, mediaID, err)
+		logger.Errorf("Failed to store quality status for %s %d: %v", mediaType, mediaID, err)
+		return
+	}
+	if previous != nil {
+		s.recordQualityChange(mediaID, mediaType, filename, previous, status)
+	}
+}
+
+// qualityLabel describes a quality status as resolution and source
+func qualityLabel(status *database.MediaQualityStatus) string {
+	var parts []string
+	for _, v := range []*string{status.CurrentResolution, status.CurrentSource} {
+		if v != nil && *v != "" {
+			parts = append(parts, *v)
+		}
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordQualityChange adds a change of detected quality to the media's
+// activity. Episodes are recorded against their show.
+func (s *Scanner) recordQualityChange(mediaID int64, mediaType, filename string, previous, current *database.MediaQualityStatus) {
+	from, to := qualityLabel(previous), qualityLabel(current)
+	if from == to && previous.CurrentScore == current.CurrentScore {
+		return
+	}
+
+	event := &database.MediaEvent{
+		EventType: database.MediaEventQuality,
+		MediaType: mediaType,
+		Summary:   fmt.Sprintf("Quality changed from %s to %s", from, to),
+		Details: map[string]interface{}{
+			"file":      filename,
+			"from":      from,
+			"to":        to,
+			"fromScore": previous.CurrentScore,
+			"toScore":   current.CurrentScore,
+			"targetMet": current.TargetMet,
+		},
+	}
+	if mediaType == "episode" {
+		event.MediaType = "show"
+		event.MediaID, _ = s.db.GetShowTmdbIDForEpisode(mediaID)
+		event.Details["episodeId"] = mediaID
+	} else if movie, err := s.db.GetMovie(mediaID); err == nil {
+		event.MediaID = movie.TmdbID
+	}
+	if event.MediaID == nil {
+		return
+	}
+	if err := s.db.RecordMediaEvent(event); err != nil {
+		logger.Errorf("Failed to record quality change for %s %d: %v", mediaType, mediaID, err)
 	}
 }
 
@@ -346,7 +421,7 @@ func (s *Scanner) RescanQualityStatus() (int, int, error) {
 		}
 	}
 
-	log.Printf("Rescanned quality status: %d movies, %d episodes", moviesUpdated, episodesUpdated)
+	logger.Infof("Rescanned quality status: %d movies, %d episodes", moviesUpdated, episodesUpdated)
 	return moviesUpdated, episodesUpdated, nil
 }
 
@@ -389,12 +464,12 @@ func mapPresetSource(presetSource string) string {
 // DetectQualityForExistingMedia scans all existing movies and episodes to detect and store their quality
 // This is useful for media that was added before quality detection was implemented
 func (s *Scanner) DetectQualityForExistingMedia() {
-	log.Println("Starting quality detection for existing media...")
+	logger.Infof("Starting quality detection for existing media...")
 
 	// Process all movies
 	movies, err := s.db.GetMovies()
 	if err != nil {
-		log.Printf("Failed to get movies for quality detection: %v", err)
+		logger.Errorf("Failed to get movies for quality detection: %v", err)
 	} else {
 		detected := 0
 		for _, movie := range movies {
@@ -410,14 +485,14 @@ func (s *Scanner) DetectQualityForExistingMedia() {
 			}
 		}
 		if detected > 0 {
-			log.Printf("Detected quality for %d movies", detected)
+			logger.Infof("Detected quality for %d movies", detected)
 		}
 	}
 
 	// Process all episodes
 	episodes, err := s.db.GetAllEpisodes()
 	if err != nil {
-		log.Printf("Failed to get episodes for quality detection: %v", err)
+		logger.Errorf("Failed to get episodes for quality detection: %v", err)
 	} else {
 		detected := 0
 		for _, ep := range episodes {
@@ -433,44 +508,47 @@ func (s *Scanner) DetectQualityForExistingMedia() {
 			}
 		}
 		if detected > 0 {
-			log.Printf("Detected quality for %d episodes", detected)
+			logger.Infof("Detected quality for %d episodes", detected)
 		}
 	}
 
-	log.Println("Quality detection for existing media complete")
+	logger.Infof("Quality detection for existing media complete")
 }
 
-// RedetectAllQuality forces re-detection of quality for ALL media using ffprobe
+// RedetectAllQuality forces re-detection of quality for ALL media using ffprobe,
+// except media in throttled libraries that already has its quality when
+// unchanged files are skipped
 func (s *Scanner) RedetectAllQuality() {
-	log.Println("Starting full quality re-detection using ffprobe...")
+	logger.Infof("Starting full quality re-detection using ffprobe...")
+	unchangedMovies, unchangedEpisodes := s.throttledUnchanged()
 
 	// Process all movies
 	movies, err := s.db.GetMovies()
 	if err != nil {
-		log.Printf("Failed to get movies for quality re-detection: %v", err)
+		logger.Errorf("Failed to get movies for quality re-detection: %v", err)
 	} else {
 		for _, movie := range movies {
-			if movie.Path != "" {
+			if movie.Path != "" && !unchangedMovies[movie.ID] {
 				s.detectAndStoreQuality(movie.ID, "movie", filepath.Base(movie.Path), movie.Path)
 			}
 		}
-		log.Printf("Re-detected quality for %d movies", len(movies))
+		logger.Infof("Re-detected quality for %d movies", len(movies)-len(unchangedMovies))
 	}
 
 	// Process all episodes
 	episodes, err := s.db.GetAllEpisodes()
 	if err != nil {
-		log.Printf("Failed to get episodes for quality re-detection: %v", err)
+		logger.Errorf("Failed to get episodes for quality re-detection: %v", err)
 	} else {
 		for _, ep := range episodes {
-			if ep.Path != "" {
+			if ep.Path != "" && !unchangedEpisodes[ep.ID] {
 				s.detectAndStoreQuality(ep.ID, "episode", filepath.Base(ep.Path), ep.Path)
 			}
 		}
-		log.Printf("Re-detected quality for %d episodes", len(episodes))
+		logger.Infof("Re-detected quality for %d episodes", len(episodes)-len(unchangedEpisodes))
 	}
 
-	log.Println("Quality re-detection complete")
+	logger.Infof("Quality re-detection complete")
 }
 
 // missingGracePeriod is how long a file can be missing before being deleted
@@ -480,7 +558,7 @@ const missingGracePeriod = 24 * time.Hour
 func (s *Scanner) cleanupOrphanedMovies(libraryID int64) {
 	movies, err := s.db.GetMoviesByLibrary(libraryID)
 	if err != nil {
-		log.Printf("Failed to get movies for cleanup: %v", err)
+		logger.Errorf("Failed to get movies for cleanup: %v", err)
 		return
 	}
 
@@ -493,16 +571,20 @@ func (s *Scanner) cleanupOrphanedMovies(libraryID int64) {
 		fileExists := statErr == nil
 
 		if !fileExists && os.IsNotExist(statErr) {
+			// Another version of the movie may still be there
+			if s.promoteVersion("movie", movie.ID) {
+				continue
+			}
 			// File is missing - mark it (if not already marked)
 			if err := s.db.MarkMovieMissing(movie.ID); err == nil {
 				marked++
-				log.Printf("Marked movie as missing: %s", movie.Title)
+				logger.Infof("Marked movie as missing: %s", movie.Title)
 			}
 		} else if fileExists && movie.MissingSince != nil {
 			// File reappeared - clear missing status
 			if err := s.db.ClearMovieMissing(movie.ID); err == nil {
 				cleared++
-				log.Printf("Movie file reappeared: %s", movie.Title)
+				logger.Infof("Movie file reappeared: %s", movie.Title)
 			}
 		}
 	}
@@ -510,11 +592,11 @@ func (s *Scanner) cleanupOrphanedMovies(libraryID int64) {
 	// Delete movies that have been missing for longer than grace period
 	deleted, err := s.db.DeleteMissingMovies(missingGracePeriod)
 	if err != nil {
-		log.Printf("Failed to delete missing movies: %v", err)
+		logger.Errorf("Failed to delete missing movies: %v", err)
 	}
 
 	if marked > 0 || cleared > 0 || deleted > 0 {
-		log.Printf("Movie cleanup: %d marked missing, %d reappeared, %d deleted", marked, cleared, deleted)
+		logger.Infof("Movie cleanup: %d marked missing, %d reappeared, %d deleted", marked, cleared, deleted)
 	}
 }
 
@@ -522,7 +604,7 @@ func (s *Scanner) cleanupOrphanedMovies(libraryID int64) {
 func (s *Scanner) cleanupOrphanedEpisodes(libraryID int64) {
 	episodes, err := s.db.GetEpisodesByLibrary(libraryID)
 	if err != nil {
-		log.Printf("Failed to get episodes for cleanup: %v", err)
+		logger.Errorf("Failed to get episodes for cleanup: %v", err)
 		return
 	}
 
@@ -535,16 +617,20 @@ func (s *Scanner) cleanupOrphanedEpisodes(libraryID int64) {
 		fileExists := statErr == nil
 
 		if !fileExists && os.IsNotExist(statErr) {
+			// Another version of the episode may still be there
+			if s.promoteVersion("episode", ep.ID) {
+				continue
+			}
 			// File is missing - mark it
 			if err := s.db.MarkEpisodeMissing(ep.ID); err == nil {
 				marked++
-				log.Printf("Marked episode as missing: E%02d", ep.EpisodeNumber)
+				logger.Infof("Marked episode as missing: E%02d", ep.EpisodeNumber)
 			}
 		} else if fileExists && ep.MissingSince != nil {
 			// File reappeared - clear missing status
 			if err := s.db.ClearEpisodeMissing(ep.ID); err == nil {
 				cleared++
-				log.Printf("Episode file reappeared: E%02d", ep.EpisodeNumber)
+				logger.Infof("Episode file reappeared: E%02d", ep.EpisodeNumber)
 			}
 		}
 	}
@@ -552,11 +638,11 @@ func (s *Scanner) cleanupOrphanedEpisodes(libraryID int64) {
 	// Delete episodes that have been missing for longer than grace period
 	deleted, err := s.db.DeleteMissingEpisodes(missingGracePeriod)
 	if err != nil {
-		log.Printf("Failed to delete missing episodes: %v", err)
+		logger.Errorf("Failed to delete missing episodes: %v", err)
 	}
 
 	if marked > 0 || cleared > 0 || deleted > 0 {
-		log.Printf("Episode cleanup: %d marked missing, %d reappeared, %d deleted", marked, cleared, deleted)
+		logger.Infof("Episode cleanup: %d marked missing, %d reappeared, %d deleted", marked, cleared, deleted)
 	}
 }
 
@@ -574,18 +660,26 @@ func (s *Scanner) GetProgress() ScanProgress {
 		lastScanAt = s.lastScanAt.Format(time.RFC3339)
 	}
 
+	estimated := 0
+	if done := s.scanCurrent - 1; s.scanPhase == "scanning" && done > 0 {
+		perFile := time.Since(s.scanStarted) / time.Duration(done)
+		estimated = int((perFile * time.Duration(s.scanTotal-done)).Seconds())
+	}
+
 	return ScanProgress{
-		Scanning:    s.scanning,
-		Library:     s.scanLibrary,
-		Phase:       s.scanPhase,
-		Current:     s.scanCurrent,
-		Total:       s.scanTotal,
-		Percent:     percent,
-		LastLibrary: s.lastLibrary,
-		LastAdded:   s.lastAdded,
-		LastSkipped: s.lastSkipped,
-		LastErrors:  s.lastErrors,
-		LastScanAt:  lastScanAt,
+		Scanning:         s.scanning,
+		Library:          s.scanLibrary,
+		Phase:            s.scanPhase,
+		Current:          s.scanCurrent,
+		Total:            s.scanTotal,
+		Percent:          percent,
+		Throttled:        s.throttled,
+		EstimatedSeconds: estimated,
+		LastLibrary:      s.lastLibrary,
+		LastAdded:        s.lastAdded,
+		LastSkipped:      s.lastSkipped,
+		LastErrors:       s.lastErrors,
+		LastScanAt:       lastScanAt,
 	}
 }
 
@@ -594,6 +688,9 @@ func (s *Scanner) setProgress(library, phase string, current, total int) {
 	defer s.mu.Unlock()
 	s.scanning = true
 	s.scanLibrary = library
+	if phase != s.scanPhase {
+		s.scanStarted = time.Now()
+	}
 	s.scanPhase = phase
 	s.scanCurrent = current
 	s.scanTotal = total
@@ -607,6 +704,14 @@ func (s *Scanner) clearProgress() {
 	s.scanPhase = ""
 	s.scanCurrent = 0
 	s.scanTotal = 0
+	s.throttled = false
+}
+
+// setThrottled records whether the running scan is throttled
+func (s *Scanner) setThrottled(throttled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled = throttled
 }
 
 func (s *Scanner) setResult(library string, added, skipped, errors int) {
@@ -619,316 +724,489 @@ func (s *Scanner) setResult(library string, added, skipped, errors int) {
 	s.lastScanAt = time.Now()
 }
 
+// libraryLock returns the lock of a library
+func (s *Scanner) libraryLock(libraryID int64) *sync.Mutex {
+	s.libraryLocksMu.Lock()
+	defer s.libraryLocksMu.Unlock()
+	if s.libraryLocks == nil {
+		s.libraryLocks = make(map[int64]*sync.Mutex)
+	}
+	lock, ok := s.libraryLocks[libraryID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.libraryLocks[libraryID] = lock
+	}
+	return lock
+}
+
 func (s *Scanner) ScanLibrary(lib *database.Library) error {
-	log.Printf("Scanning library: %s (%s)", lib.Name, lib.Path)
+	return s.ScanLibraryContext(context.Background(), lib, nil)
+}
+
+// ScanLibraryContext scans a library, stopping early with ctx's error when
+// ctx is cancelled. progress, if given, is told how many of the library's
+// movie and episode files have been looked at.
+func (s *Scanner) ScanLibraryContext(ctx context.Context, lib *database.Library, progress func(current, total int)) error {
+	lock := s.libraryLock(lib.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	logger.Infof("Scanning library: %s (%s)", lib.Name, lib.Path)
 
 	switch lib.Type {
 	case "movies":
-		return s.scanMovies(lib)
+		return s.scanMovies(ctx, lib, progress)
 	case "tv":
-		return s.scanTV(lib)
+		return s.scanTV(ctx, lib, progress)
 	case "music":
-		return s.scanMusic(lib)
+		return s.scanMusic(ctx, lib)
 	case "books":
-		return s.scanBooks(lib)
+		return s.scanBooks(ctx, lib)
+	case "comics":
+		return s.scanComics(ctx, lib)
+	case "photos":
+		return s.scanPhotos(ctx, lib)
 	default:
-		log.Printf("Unknown library type: %s", lib.Type)
+		logger.Infof("Unknown library type: %s", lib.Type)
 		return nil
 	}
 }
 
-func (s *Scanner) scanMovies(lib *database.Library) error {
+func (s *Scanner) scanMovies(ctx context.Context, lib *database.Library, progress func(current, total int)) error {
 	defer s.clearProgress()
 
-	var added, skipped, errors int
+	throttle := s.throttleFor(lib)
+	s.setThrottled(throttle != nil)
 
 	// Phase 0: Clean up orphaned entries (files that no longer exist)
 	s.cleanupOrphanedMovies(lib.ID)
 
-	// Phase 1: Count video files
+	// Phase 1: Find video files
 	s.setProgress(lib.Name, "counting", 0, 0)
-	var videoFiles []string
-	filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-		ext := strings.ToLower(filepath.Ext(path))
-		if videoExtensions[ext] {
-			videoFiles = append(videoFiles, path)
-		}
-		return nil
-	})
+	videoFiles := walkVideoFiles(lib.Path)
+	known, err := s.db.GetLibraryFiles(lib.ID, "movie")
+	if err != nil {
+		return err
+	}
 
 	total := len(videoFiles)
-	log.Printf("Found %d video files in %s", total, lib.Name)
-
-	// Phase 2: Process each file
-	for i, path := range videoFiles {
-		s.setProgress(lib.Name, "scanning", i+1, total)
-
-		info, err := os.Stat(path)
+	workers := s.scanWorkers(throttle)
+	logger.Infof("Found %d video files in %s, scanning with %d workers", total, lib.Name, workers)
+
+	// Phase 2: Look for changes to the files already in the library
+	tally := s.newTally(lib.Name, total, progress)
+	stamps := s.newStampBatch("movie")
+	var newMu sync.Mutex
+	var newMovies []*database.Movie
+	forEach(ctx, workers, total, func(i int) {
+		file := videoFiles[i]
+
+		// Files already in the library are only looked at again if they changed
+		if s.knownFile(known, "movie", file, stamps, throttle) {
+			tally.step()
+			tally.skip()
+			return
+		}
+		newMu.Lock()
+		newMovies = append(newMovies, newMovie(lib, file.path, file.info))
+		newMu.Unlock()
+	})
+	stamps.flush()
+
+	// Phase 3: Add the new files a batch at a time, on one goroutine so files
+	// of the same movie become versions of it rather than adding it twice.
+	// Files are added in path order, whichever worker found them.
+	sort.Slice(newMovies, func(i, j int) bool { return newMovies[i].Path < newMovies[j].Path })
+	var added []*database.Movie
+	for start := 0; start < len(newMovies) && ctx.Err() == nil; start += batchSize {
+		batch := newMovies[start:min(start+batchSize, len(newMovies))]
+		movies, err := s.db.AddMovies(batch)
 		if err != nil {
-			errors++
+			logger.Errorf("Failed to add %d movies: %v", len(batch), err)
+			for range batch {
+				tally.step()
+				tally.fail()
+			}
 			continue
 		}
-
-		// Check if already in database
-		if _, err := s.db.GetMovieByPath(path); err == nil {
-			skipped++
-			continue // Already exists
+		added = append(added, movies...)
+		// Files added as versions are done
+		for range len(batch) - len(movies) {
+			tally.step()
+			tally.add()
 		}
+	}
 
-		// Parse filename
-		ext := filepath.Ext(path)
-		filename := strings.TrimSuffix(filepath.Base(path), ext)
-		title, year := parseMovieFilename(filename)
+	// Phase 4: Fetch the new movies' metadata
+	forEach(ctx, workers, len(added), func(i int) {
+		tally.step()
+		throttle.pause()
+		s.movieAdded(lib, added[i], throttle)
+		tally.add()
+	})
 
-		movie := &database.Movie{
-			LibraryID: lib.ID,
-			Title:     title,
-			Year:      year,
-			Path:      path,
-			Size:      info.Size(),
-		}
+	if err := ctx.Err(); err != nil {
+		tally.setResult()
+		return err
+	}
 
-		if err := s.db.CreateMovie(movie); err != nil {
-			log.Printf("Failed to add movie %s: %v", path, err)
-			errors++
-		} else {
-			added++
-			log.Printf("Added movie: %s (%d)", title, year)
-			// Detect and store quality from filename
-			s.detectAndStoreQuality(movie.ID, "movie", filepath.Base(path), path)
-			// Fetch metadata from TMDB
-			if s.meta != nil {
-				if err := s.meta.FetchMovieMetadata(movie); err != nil {
-					log.Printf("Failed to fetch metadata for %s: %v", title, err)
-				}
-			}
-			// Organize folder, extract subtitles, extract chapters, and auto-download subtitles in background
-			go func(m *database.Movie, libPath string) {
-				s.OrganizeAndExtractSubtitles(m, libPath)
-				s.ExtractChapters("movie", m.ID, m.Path)
-				s.AutoDownloadSubtitles("movie", m.Path, m.Title, m.Year, 0, 0)
-			}(movie, lib.Path)
-		}
+	// Phase 5: Merge movies added again after their folders were
+	// reorganized, and other editions of movies, once their editions are known
+	s.detectEditions(lib.ID)
+	if _, err := s.Dedupe(lib.ID); err != nil {
+		logger.Errorf("Failed to dedupe %s: %v", lib.Name, err)
 	}
 
-	s.setResult(lib.Name, added, skipped, errors)
+	tally.setResult()
 	return nil
 }
 
-func (s *Scanner) scanTV(lib *database.Library) error {
+// importMovie adds a video file to a movie library, fetching its metadata
+// and organizing it and extracting its subtitles and chapters in the
+// background
+func (s *Scanner) importMovie(lib *database.Library, path string, info os.FileInfo, throttle *scanThrottle) error {
+	added, err := s.db.AddMovies([]*database.Movie{newMovie(lib, path, info)})
+	if err != nil {
+		logger.Errorf("Failed to add movie %s: %v", path, err)
+		return err
+	}
+	for _, movie := range added {
+		s.movieAdded(lib, movie, throttle)
+	}
+	return nil
+}
+
+// newMovie returns the movie of a video file, from its file name
+func newMovie(lib *database.Library, path string, info os.FileInfo) *database.Movie {
+	ext := filepath.Ext(path)
+	filename := strings.TrimSuffix(filepath.Base(path), ext)
+	title, year := parseMovieFilename(filename)
+
+	return &database.Movie{
+		LibraryID: lib.ID,
+		Title:     title,
+		Year:      year,
+		Path:      path,
+		Size:      info.Size(),
+		Edition:   fileEdition(path),
+	}
+}
+
+// movieAdded fetches the metadata of a movie just added, organizing it and
+// extracting its subtitles and chapters in the background
+func (s *Scanner) movieAdded(lib *database.Library, movie *database.Movie, throttle *scanThrottle) {
+	logger.Infof("Added movie: %s (%d)", movie.Title, movie.Year)
+	// Detect and store quality from filename
+	s.detectAndStoreQuality(movie.ID, "movie", filepath.Base(movie.Path), movie.Path)
+	// Fetch metadata from TMDB
+	if s.meta != nil {
+		if err := s.meta.FetchMovieMetadata(movie); err != nil {
+			logger.Errorf("Failed to fetch metadata for %s: %v", movie.Title, err)
+		}
+	}
+	// Organize folder, extract subtitles, extract chapters, and auto-download subtitles in background
+	throttle.background(func() {
+		s.OrganizeAndExtractSubtitles(movie, lib.Path)
+		s.ExtractSubtitleTracks(context.Background(), "movie", movie.ID, movie.Path)
+		s.ExtractChapters("movie", movie.ID, movie.Path)
+		// Libraries with a subtitle profile are left to the Subtitle Search task
+		if lib.SubtitleProfileID == nil {
+			s.AutoDownloadSubtitles("movie", movie.Path, movie.Title, movie.Year, 0, 0)
+		}
+	})
+}
+
+func (s *Scanner) scanTV(ctx context.Context, lib *database.Library, progress func(current, total int)) error {
 	defer s.clearProgress()
 
-	var added, skipped, errors int
 	modifiedSeasons := make(map[int64]bool) // Track seasons with new episodes
+	var seasonsMu sync.Mutex
+	throttle := s.throttleFor(lib)
+	s.setThrottled(throttle != nil)
 
 	// Phase 0: Clean up orphaned entries (files that no longer exist)
 	s.cleanupOrphanedEpisodes(lib.ID)
 
 	// Phase 1: Group files by show folder
 	s.setProgress(lib.Name, "counting", 0, 0)
-	showFiles := make(map[string][]string) // showFolder -> list of video files
-
-	filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
+	showFiles := make(map[string][]scanFile) // showFolder -> list of video files
+	var showFolders []string
+	total := 0
+	for _, file := range walkVideoFiles(lib.Path) {
+		showFolder, _, _ := s.findShowFolder(file.path, lib.Path)
+		if showFolder == "" {
+			continue
 		}
-		ext := strings.ToLower(filepath.Ext(path))
-		if !videoExtensions[ext] {
-			return nil
+		if _, ok := showFiles[showFolder]; !ok {
+			showFolders = append(showFolders, showFolder)
 		}
+		showFiles[showFolder] = append(showFiles[showFolder], file)
+		total++
+	}
+	known, err := s.db.GetLibraryFiles(lib.ID, "episode")
+	if err != nil {
+		return err
+	}
+
+	workers := s.scanWorkers(throttle)
+	logger.Infof("Found %d video files in %d shows in %s, scanning with %d workers", total, len(showFiles), lib.Name, workers)
 
-		showFolder, _, _ := s.findShowFolder(path, lib.Path)
-		if showFolder != "" {
-			showFiles[showFolder] = append(showFiles[showFolder], path)
+	// Phase 2: Process the show folders, each on one worker so its show and
+	// seasons are only added once
+	tally := s.newTally(lib.Name, total, progress)
+	stamps := s.newStampBatch("episode")
+	forEach(ctx, workers, len(showFolders), func(i int) {
+		showFolder := showFolders[i]
+
+		// Files already in the library are only looked at again if they changed
+		var newFiles []scanFile
+		for _, file := range showFiles[showFolder] {
+			if s.knownFile(known, "episode", file, stamps, throttle) {
+				tally.step()
+				tally.skip()
+				continue
+			}
+			newFiles = append(newFiles, file)
+		}
+		if len(newFiles) == 0 {
+			return
 		}
-		return nil
-	})
 
-	// Count total files
-	total := 0
-	for _, files := range showFiles {
-		total += len(files)
-	}
-	log.Printf("Found %d video files in %d shows in %s", total, len(showFiles), lib.Name)
-
-	// Phase 2: Process each show folder
-	current := 0
-	for showFolder, files := range showFiles {
-		folderName := filepath.Base(showFolder)
-		folderInfo := parseShowFolder(folderName)
-
-		// Get or create show
-		show, err := s.db.GetShowByPath(showFolder)
-		isNewShow := false
-		if err == sql.ErrNoRows {
-			// Use folder info for show title/year, with confidence scoring
-			showYear, yearConfidence := extractYearFromPathEnhanced(showFolder, lib.Path)
-			confidence := folderInfo.Confidence
-			if yearConfidence > 0 {
-				confidence = (confidence + yearConfidence) / 2
+		// The show is only looked up once one of its files is new
+		show, folderInfo, isNewShow, err := s.showForFolder(lib, showFolder)
+		if err != nil {
+			for range newFiles {
+				tally.step()
+				tally.fail()
 			}
-			needsReview := confidence < lowConfidenceThreshold
-
-			show = &database.Show{
-				LibraryID:        lib.ID,
-				Title:            folderInfo.Title,
-				Year:             showYear,
-				Path:             showFolder,
-				MatchConfidence:  confidence,
-				NeedsMatchReview: needsReview,
+			return
+		}
+
+		// Add the new episodes a batch at a time
+		for start := 0; start < len(newFiles) && ctx.Err() == nil; start += batchSize {
+			var episodes []*database.Episode
+			var parsed []ParseResult
+			for _, file := range newFiles[start:min(start+batchSize, len(newFiles))] {
+				episode, parseResult, err := s.newEpisode(lib, show, file.path, file.info)
+				if err != nil {
+					tally.step()
+					tally.fail()
+					continue
+				}
+				episodes = append(episodes, episode)
+				parsed = append(parsed, parseResult)
 			}
-			if err := s.db.CreateShow(show); err != nil {
-				log.Printf("Failed to create show %s: %v", folderInfo.Title, err)
-				errors++
+			if err := s.db.AddEpisodes(episodes); err != nil {
+				logger.Errorf("Failed to add %d episodes of %s: %v", len(episodes), folderInfo.Title, err)
+				for range episodes {
+					tally.step()
+					tally.fail()
+				}
 				continue
 			}
-			if needsReview {
-				log.Printf("Added show (needs review): %s (confidence: %.2f)", folderInfo.Title, confidence)
-			} else {
-				log.Printf("Added show: %s", folderInfo.Title)
+
+			for j, episode := range episodes {
+				tally.step()
+				throttle.pause()
+				s.episodeAdded(lib, folderInfo.Title, episode, parsed[j], throttle)
+				tally.add()
+				seasonsMu.Lock()
+				modifiedSeasons[episode.SeasonID] = true
+				seasonsMu.Unlock()
 			}
-			isNewShow = true
-		} else if err != nil {
-			errors++
-			continue
 		}
 
-		// Process each episode file in this show
-		for _, path := range files {
-			current++
-			s.setProgress(lib.Name, "scanning", current, total)
-
-			info, err := os.Stat(path)
-			if err != nil {
-				errors++
-				continue
+		// Fetch show metadata if this is a new show
+		if isNewShow && s.meta != nil {
+			if err := s.meta.FetchShowMetadata(show); err != nil {
+				logger.Errorf("Failed to fetch metadata for %s: %v", folderInfo.Title, err)
 			}
+		}
+	})
+	stamps.flush()
 
-			// Check if already in database
-			if _, err := s.db.GetEpisodeByPath(path); err == nil {
-				skipped++
-				continue
-			}
+	if err := ctx.Err(); err != nil {
+		tally.setResult()
+		return err
+	}
 
-			// Parse filename with enhanced parser
-			ext := filepath.Ext(path)
-			filename := strings.TrimSuffix(filepath.Base(path), ext)
-			parseResult := parseTVFilenameEnhanced(filename)
-
-			// Fall back to original parser if enhanced parser fails
-			if parseResult.Season == 0 && parseResult.Episode == 0 {
-				title, sNum, eNum := parseTVFilename(filename)
-				if sNum > 0 || eNum > 0 {
-					parseResult.Title = title
-					parseResult.Season = sNum
-					parseResult.Episode = eNum
-					parseResult.Confidence = 0.8
-				}
-			}
+	// Phase 3: Merge shows added again after their folders were reorganized
+	if _, err := s.Dedupe(lib.ID); err != nil {
+		logger.Errorf("Failed to dedupe %s: %v", lib.Name, err)
+	}
 
-			// Also try to get season from folder structure
-			_, folderSeason, _ := s.findShowFolder(path, lib.Path)
-			if parseResult.Season == 0 && folderSeason > 0 {
-				parseResult.Season = folderSeason
-			}
+	tally.setResult()
 
-			if parseResult.Season == 0 {
-				log.Printf("Could not parse TV filename: %s", filename)
-				errors++
-				continue
-			}
+	// Trigger intro detection for modified seasons in background
+	s.detectIntros(modifiedSeasons)
 
-			// Get or create season
-			season, err := s.db.GetSeason(show.ID, parseResult.Season)
-			if err == sql.ErrNoRows {
-				season = &database.Season{
-					ShowID:       show.ID,
-					SeasonNumber: parseResult.Season,
-				}
-				if err := s.db.CreateSeason(season); err != nil {
-					log.Printf("Failed to create season %d: %v", parseResult.Season, err)
-					errors++
-					continue
-				}
-			} else if err != nil {
-				errors++
-				continue
-			}
+	return nil
+}
 
-			// Create episode with multi-episode support
-			episode := &database.Episode{
-				SeasonID:        season.ID,
-				EpisodeNumber:   parseResult.Episode,
-				Title:           "",
-				Path:            path,
-				Size:            info.Size(),
-				MatchConfidence: parseResult.Confidence,
+// detectIntros runs intro detection for seasons with new episodes in the
+// background
+func (s *Scanner) detectIntros(seasons map[int64]bool) {
+	if len(seasons) == 0 || !CheckFFmpegChromaprint() {
+		return
+	}
+	go func() {
+		detector := NewIntroDetector(s.db)
+		for seasonID := range seasons {
+			logger.Infof("Running intro detection for season %d", seasonID)
+			if err := detector.DetectIntroForSeason(seasonID); err != nil {
+				logger.Errorf("Intro detection failed for season %d: %v", seasonID, err)
 			}
+		}
+	}()
+}
 
-			// Set multi-episode end if applicable
-			if parseResult.EpisodeEnd > parseResult.Episode {
-				episode.EpisodeEnd = &parseResult.EpisodeEnd
-			}
+// showForFolder returns the show of a show folder, adding it to the library
+// if it's new, along with what the folder's name says about it
+func (s *Scanner) showForFolder(lib *database.Library, showFolder string) (*database.Show, ShowParseResult, bool, error) {
+	folderName := filepath.Base(showFolder)
+	folderInfo := parseShowFolder(folderName)
 
-			// Set absolute number for anime
-			if parseResult.Absolute > 0 {
-				episode.AbsoluteNumber = &parseResult.Absolute
-			}
+	show, err := s.db.GetShowByPath(showFolder)
+	if err != sql.ErrNoRows {
+		return show, folderInfo, false, err
+	}
 
-			// Use enhanced create that includes new fields
-			if err := s.db.CreateEpisodeWithExtras(episode); err != nil {
-				log.Printf("Failed to add episode: %v", err)
-				errors++
-			} else {
-				added++
-				if parseResult.EpisodeEnd > 0 {
-					log.Printf("Added multi-episode: %s S%02dE%02d-E%02d", folderInfo.Title, parseResult.Season, parseResult.Episode, parseResult.EpisodeEnd)
-				} else if parseResult.Absolute > 0 {
-					log.Printf("Added anime episode: %s - %d (S%02dE%02d)", folderInfo.Title, parseResult.Absolute, parseResult.Season, parseResult.Episode)
-				} else {
-					log.Printf("Added episode: %s S%02dE%02d", folderInfo.Title, parseResult.Season, parseResult.Episode)
-				}
-				modifiedSeasons[season.ID] = true
-				// Detect and store quality from filename
-				s.detectAndStoreQuality(episode.ID, "episode", filepath.Base(path), path)
-				// Extract subtitles, chapters, fingerprint, and auto-download subtitles in background
-				go func(ep *database.Episode, p string, showName string, sNum, eNum int, seasonID int64) {
-					s.ExtractSubtitles(p)
-					s.ExtractChapters("episode", ep.ID, p)
-					s.AutoDownloadSubtitles("episode", p, showName, 0, sNum, eNum)
-					// Extract audio fingerprint for intro detection
-					s.ExtractEpisodeFingerprint(ep)
-				}(episode, path, folderInfo.Title, parseResult.Season, parseResult.Episode, season.ID)
-			}
+	// Use folder info for show title/year, with confidence scoring
+	showYear, yearConfidence := extractYearFromPathEnhanced(showFolder, lib.Path)
+	confidence := folderInfo.Confidence
+	if yearConfidence > 0 {
+		confidence = (confidence + yearConfidence) / 2
+	}
+	needsReview := confidence < lowConfidenceThreshold
+
+	show = &database.Show{
+		LibraryID:        lib.ID,
+		Title:            folderInfo.Title,
+		Year:             showYear,
+		Path:             showFolder,
+		MatchConfidence:  confidence,
+		NeedsMatchReview: needsReview,
+	}
+	if err := s.db.CreateShow(show); err != nil {
+		logger.Errorf("Failed to create show %s: %v", folderInfo.Title, err)
+		return nil, folderInfo, false, err
+	}
+	if needsReview {
+		logger.Infof("Added show (needs review): %s (confidence: %.2f)", folderInfo.Title, confidence)
+	} else {
+		logger.Infof("Added show: %s", folderInfo.Title)
+	}
+	return show, folderInfo, true, nil
+}
+
+// importEpisode adds a video file to a show, creating its season if need be
+// and extracting its subtitles, chapters and fingerprint in the background.
+// Returns the ID of the episode's season.
+func (s *Scanner) importEpisode(lib *database.Library, show *database.Show, showName, path string, info os.FileInfo, throttle *scanThrottle) (int64, error) {
+	episode, parseResult, err := s.newEpisode(lib, show, path, info)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.db.CreateEpisodeWithExtras(episode); err != nil {
+		logger.Errorf("Failed to add episode: %v", err)
+		return 0, err
+	}
+	s.episodeAdded(lib, showName, episode, parseResult, throttle)
+	return episode.SeasonID, nil
+}
+
+// newEpisode returns the episode of a video file in a show, from its file
+// and folder names, creating its season if need be
+func (s *Scanner) newEpisode(lib *database.Library, show *database.Show, path string, info os.FileInfo) (*database.Episode, ParseResult, error) {
+	// Parse filename with enhanced parser
+	ext := filepath.Ext(path)
+	filename := strings.TrimSuffix(filepath.Base(path), ext)
+	parseResult := parseTVFilenameEnhanced(filename)
+
+	// Fall back to original parser if enhanced parser fails
+	if parseResult.Season == 0 && parseResult.Episode == 0 {
+		title, sNum, eNum := parseTVFilename(filename)
+		if sNum > 0 || eNum > 0 {
+			parseResult.Title = title
+			parseResult.Season = sNum
+			parseResult.Episode = eNum
+			parseResult.Confidence = 0.8
 		}
+	}
 
-		// Fetch show metadata if this is a new show
-		if isNewShow && s.meta != nil {
-			if err := s.meta.FetchShowMetadata(show); err != nil {
-				log.Printf("Failed to fetch metadata for %s: %v", folderInfo.Title, err)
-			}
+	// Also try to get season from folder structure
+	_, folderSeason, _ := s.findShowFolder(path, lib.Path)
+	if parseResult.Season == 0 && folderSeason > 0 {
+		parseResult.Season = folderSeason
+	}
+
+	if parseResult.Season == 0 {
+		logger.Infof("Could not parse TV filename: %s", filename)
+		return nil, parseResult, fmt.Errorf("could not parse TV filename: %s", filename)
+	}
+
+	// Get or create season
+	season, err := s.db.GetSeason(show.ID, parseResult.Season)
+	if err == sql.ErrNoRows {
+		season = &database.Season{
+			ShowID:       show.ID,
+			SeasonNumber: parseResult.Season,
+		}
+		if err := s.db.CreateSeason(season); err != nil {
+			logger.Errorf("Failed to create season %d: %v", parseResult.Season, err)
+			return nil, parseResult, err
 		}
+	} else if err != nil {
+		return nil, parseResult, err
 	}
 
-	s.setResult(lib.Name, added, skipped, errors)
+	// Create episode with multi-episode support
+	episode := &database.Episode{
+		SeasonID:        season.ID,
+		EpisodeNumber:   parseResult.Episode,
+		Title:           "",
+		Path:            path,
+		Size:            info.Size(),
+		MatchConfidence: parseResult.Confidence,
+	}
 
-	// Trigger intro detection for modified seasons in background
-	if len(modifiedSeasons) > 0 && CheckFFmpegChromaprint() {
-		go func(seasons map[int64]bool) {
-			detector := NewIntroDetector(s.db)
-			for seasonID := range seasons {
-				log.Printf("Running intro detection for season %d", seasonID)
-				if err := detector.DetectIntroForSeason(seasonID); err != nil {
-					log.Printf("Intro detection failed for season %d: %v", seasonID, err)
-				}
-			}
-		}(modifiedSeasons)
+	// Set multi-episode end if applicable
+	if parseResult.EpisodeEnd > parseResult.Episode {
+		episode.EpisodeEnd = &parseResult.EpisodeEnd
 	}
 
-	return nil
+	// Set absolute number for anime
+	if parseResult.Absolute > 0 {
+		episode.AbsoluteNumber = &parseResult.Absolute
+	}
+
+	return episode, parseResult, nil
+}
+
+// episodeAdded detects the quality of an episode just added, extracting its
+// subtitles, chapters and fingerprint in the background
+func (s *Scanner) episodeAdded(lib *database.Library, showName string, episode *database.Episode, parseResult ParseResult, throttle *scanThrottle) {
+	path := episode.Path
+	if parseResult.EpisodeEnd > 0 {
+		logger.Infof("Added multi-episode: %s S%02dE%02d-E%02d", showName, parseResult.Season, parseResult.Episode, parseResult.EpisodeEnd)
+	} else if parseResult.Absolute > 0 {
+		logger.Infof("Added anime episode: %s - %d (S%02dE%02d)", showName, parseResult.Absolute, parseResult.Season, parseResult.Episode)
+	} else {
+		logger.Infof("Added episode: %s S%02dE%02d", showName, parseResult.Season, parseResult.Episode)
+	}
+	// Detect and store quality from filename
+	s.detectAndStoreQuality(episode.ID, "episode", filepath.Base(path), path)
+	// Extract subtitles, chapters, fingerprint, and auto-download subtitles in background
+	sNum, eNum := parseResult.Season, parseResult.Episode
+	throttle.background(func() {
+		s.ExtractSubtitleTracks(context.Background(), "episode", episode.ID, path)
+		s.ExtractChapters("episode", episode.ID, path)
+		if lib.SubtitleProfileID == nil {
+			s.AutoDownloadSubtitles("episode", path, showName, 0, sNum, eNum)
+		}
+		// Extract audio fingerprint for intro detection
+		s.ExtractEpisodeFingerprint(episode)
+	})
 }
 
 // ExtractEpisodeFingerprint extracts audio fingerprint for an episode (for intro detection)
@@ -944,13 +1222,18 @@ func (s *Scanner) ExtractEpisodeFingerprint(episode *database.Episode) {
 
 	detector := NewIntroDetector(s.db)
 	if err := detector.AnalyzeEpisode(episode); err != nil {
-		log.Printf("Failed to extract fingerprint for episode %d: %v", episode.ID, err)
+		logger.Errorf("Failed to extract fingerprint for episode %d: %v", episode.ID, err)
 	}
 }
 
-func (s *Scanner) scanMusic(lib *database.Library) error {
+func (s *Scanner) scanMusic(ctx context.Context, lib *database.Library) error {
+	throttle := s.throttleFor(lib)
+
 	// Music structure: Artist/Album/Track.mp3
 	return filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil
 		}
@@ -969,82 +1252,208 @@ func (s *Scanner) scanMusic(lib *database.Library) error {
 			return nil
 		}
 
-		// Parse path structure: Artist/Album/Track.ext
-		relPath, _ := filepath.Rel(lib.Path, path)
-		parts := strings.Split(relPath, string(filepath.Separator))
+		s.importTrack(lib, path, info, throttle)
+		return nil
+	})
+}
 
-		var artistName, albumName string
-		if len(parts) >= 3 {
-			artistName = parts[0]
-			albumName = parts[1]
-		} else if len(parts) == 2 {
-			artistName = parts[0]
-			albumName = "Unknown Album"
-		} else {
-			artistName = "Unknown Artist"
-			albumName = "Unknown Album"
-		}
+// importTrack adds an audio file to a music library, creating its artist
+// and album from the folders it's in if need be
+func (s *Scanner) importTrack(lib *database.Library, path string, info os.FileInfo, throttle *scanThrottle) {
+	// Parse path structure: Artist/Album/Track.ext
+	relPath, _ := filepath.Rel(lib.Path, path)
+	parts := strings.Split(relPath, string(filepath.Separator))
+
+	var artistName, albumName string
+	if len(parts) >= 3 {
+		artistName = parts[0]
+		albumName = parts[1]
+	} else if len(parts) == 2 {
+		artistName = parts[0]
+		albumName = "Unknown Album"
+	} else {
+		artistName = "Unknown Artist"
+		albumName = "Unknown Album"
+	}
 
-		// Get or create artist
-		artistPath := filepath.Join(lib.Path, artistName)
-		artist, err := s.db.GetArtistByPath(artistPath)
-		if err != nil {
-			artist = &database.Artist{
-				LibraryID: lib.ID,
-				Name:      artistName,
-				Path:      artistPath,
-			}
-			if err := s.db.CreateArtist(artist); err != nil {
-				log.Printf("Failed to create artist %s: %v", artistName, err)
-				return nil
-			}
-			log.Printf("Added artist: %s", artistName)
+	// Get or create artist
+	artistPath := filepath.Join(lib.Path, artistName)
+	artist, err := s.db.GetArtistByPath(artistPath)
+	if err != nil {
+		artist = &database.Artist{
+			LibraryID: lib.ID,
+			Name:      artistName,
+			Path:      artistPath,
+		}
+		if err := s.db.CreateArtist(artist); err != nil {
+			logger.Errorf("Failed to create artist %s: %v", artistName, err)
+			return
 		}
+		logger.Infof("Added artist: %s", artistName)
+	}
 
-		// Get or create album
-		albumPath := filepath.Join(artistPath, albumName)
-		album, err := s.db.GetAlbumByPath(albumPath)
-		if err != nil {
-			albumYear := extractYearFromPath(albumPath)
-			album = &database.Album{
-				ArtistID: artist.ID,
-				Title:    albumName,
-				Year:     albumYear,
-				Path:     albumPath,
-			}
-			if err := s.db.CreateAlbum(album); err != nil {
-				log.Printf("Failed to create album %s: %v", albumName, err)
-				return nil
-			}
-			log.Printf("Added album: %s by %s", albumName, artistName)
+	// Get or create album
+	albumPath := filepath.Join(artistPath, albumName)
+	album, err := s.db.GetAlbumByPath(albumPath)
+	if err != nil {
+		albumYear := extractYearFromPath(albumPath)
+		album = &database.Album{
+			ArtistID: artist.ID,
+			Title:    albumName,
+			Year:     albumYear,
+			Path:     albumPath,
+		}
+		if err := s.db.CreateAlbum(album); err != nil {
+			logger.Errorf("Failed to create album %s: %v", albumName, err)
+			return
 		}
+		logger.Infof("Added album: %s by %s", albumName, artistName)
+	}
 
-		// Parse track info from filename
-		filename := strings.TrimSuffix(filepath.Base(path), ext)
-		trackNum, title := parseTrackFilename(filename)
+	// Parse track info from filename
+	ext := filepath.Ext(path)
+	filename := strings.TrimSuffix(filepath.Base(path), ext)
+	trackNum, title := parseTrackFilename(filename)
 
-		track := &database.Track{
-			AlbumID:     album.ID,
-			Title:       title,
-			TrackNumber: trackNum,
-			DiscNumber:  1,
-			Duration:    0, // Would need audio parsing library to get actual duration
-			Path:        path,
-			Size:        info.Size(),
+	track := &database.Track{
+		AlbumID:     album.ID,
+		Title:       title,
+		TrackNumber: trackNum,
+		DiscNumber:  1,
+		Path:        path,
+		Size:        info.Size(),
+	}
+	tags := probeAudioTags(path)
+	throttle.pause()
+	track.Duration, track.Genre, track.BPM = tags.Duration, tags.Genre, tags.BPM
+	if tags.Title != "" {
+		track.Title = tags.Title
+	}
+	if tags.Track > 0 {
+		track.TrackNumber = tags.Track
+	}
+	if tags.Disc > 0 {
+		track.DiscNumber = tags.Disc
+	}
+	if tags.RecordingID != "" {
+		track.MusicBrainzID = &tags.RecordingID
+	}
+
+	// MusicBrainz IDs tagged by Picard and the like let the Music Metadata
+	// task skip searching for the artist and album
+	if tags.ArtistID != "" && artist.MusicBrainzID == nil {
+		if err := s.db.SetArtistMusicBrainzID(artist.ID, tags.ArtistID); err == nil {
+			artist.MusicBrainzID = &tags.ArtistID
+		}
+	}
+	if tags.ReleaseID != "" && album.MusicBrainzID == nil {
+		if err := s.db.SetAlbumMusicBrainzID(album.ID, tags.ReleaseID); err == nil {
+			album.MusicBrainzID = &tags.ReleaseID
 		}
+	}
 
-		if err := s.db.CreateTrack(track); err != nil {
-			log.Printf("Failed to add track: %v", err)
-		} else {
-			log.Printf("Added track: %s", title)
+	if err := s.db.CreateTrack(track); err != nil {
+		logger.Errorf("Failed to add track: %v", err)
+	} else {
+		logger.Infof("Added track: %s", track.Title)
+	}
+}
+
+// audioTags is what probeAudioTags reads from an audio file. Missing tags
+// are zero.
+type audioTags struct {
+	Duration int
+	Genre    *string
+	BPM      *int
+	Title    string
+	Track    int
+	Disc     int
+
+	// MusicBrainz IDs
+	ArtistID    string
+	ReleaseID   string
+	RecordingID string
+}
+
+// probeAudioTags reads an audio file's duration and its tags with ffprobe. A
+// file ffprobe can't read has none.
+func probeAudioTags(path string) audioTags {
+	var t audioTags
+	cmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "a:0",
+		"-show_entries", "format=duration:format_tags:stream_tags", "-of", "json", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return t
+	}
+
+	var result struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return t
+	}
+	if secs, err := strconv.ParseFloat(result.Format.Duration, 64); err == nil {
+		t.Duration = int(secs + 0.5)
+	}
+
+	// Tag names differ by format (ID3 TBPM, Vorbis BPM) and in case, and
+	// Ogg and FLAC keep them on the stream rather than the container
+	tags := make(map[string]string)
+	for _, stream := range result.Streams {
+		for k, v := range stream.Tags {
+			tags[strings.ToLower(k)] = strings.TrimSpace(v)
 		}
+	}
+	for k, v := range result.Format.Tags {
+		tags[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+	// first returns the first of the tags that's set, and the first of its
+	// values when it has several
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			values := strings.FieldsFunc(tags[key], func(r rune) bool { return r == ';' || r == '/' })
+			if len(values) > 0 {
+				return strings.TrimSpace(values[0])
+			}
+		}
+		return ""
+	}
 
-		return nil
-	})
+	if g := tags["genre"]; g != "" {
+		t.Genre = &g
+	}
+	for _, key := range []string{"tbpm", "bpm"} {
+		if v, err := strconv.ParseFloat(tags[key], 64); err == nil && v > 0 {
+			b := int(v + 0.5)
+			t.BPM = &b
+			break
+		}
+	}
+	t.Title = tags["title"]
+	// Track and disc numbers may be "3/12"
+	t.Track, _ = strconv.Atoi(first("track", "tracknumber"))
+	t.Disc, _ = strconv.Atoi(first("disc", "discnumber"))
+	t.ArtistID = first("musicbrainz_albumartistid", "musicbrainz album artist id", "musicbrainz_artistid", "musicbrainz artist id")
+	t.ReleaseID = first("musicbrainz_albumid", "musicbrainz album id")
+	t.RecordingID = first("musicbrainz_trackid", "musicbrainz track id")
+	return t
 }
 
-func (s *Scanner) scanBooks(lib *database.Library) error {
-	return filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
+func (s *Scanner) scanBooks(ctx context.Context, lib *database.Library) error {
+	throttle := s.throttleFor(lib)
+
+	// Audio files are audiobooks, read by folder once the walk is done
+	audio := make(map[string][]string)
+	err := filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil
 		}
@@ -1054,39 +1463,107 @@ func (s *Scanner) scanBooks(lib *database.Library) error {
 		}
 
 		ext := strings.ToLower(filepath.Ext(path))
+		if audiobookExtensions[ext] {
+			audio[filepath.Dir(path)] = append(audio[filepath.Dir(path)], path)
+			return nil
+		}
 		if !bookExtensions[ext] {
 			return nil
 		}
 
-		// Check if already in database
-		if _, err := s.db.GetBookByPath(path); err == nil {
+		s.scanBookFile(lib, path, info, throttle)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.scanAudiobooks(ctx, lib, audio, throttle)
+}
+
+// scanComics scans a comics library, which holds only comic archives
+func (s *Scanner) scanComics(ctx context.Context, lib *database.Library) error {
+	throttle := s.throttleFor(lib)
+
+	return filepath.Walk(lib.Path, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || info.IsDir() {
 			return nil
 		}
+		if !comicExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		s.scanBookFile(lib, path, info, throttle)
+		return nil
+	})
+}
 
-		// Parse filename for title and author
-		filename := strings.TrimSuffix(filepath.Base(path), ext)
-		title, author := parseBookFilename(filename)
-
-		// Determine format from extension
-		format := strings.TrimPrefix(ext, ".")
+// scanBookFile adds a book or comic file found by a scan. Comics added
+// before archives were read, and EPUBs before covers were, are filled in.
+func (s *Scanner) scanBookFile(lib *database.Library, path string, info os.FileInfo, throttle *scanThrottle) {
+	existing, err := s.db.GetBookByPath(path)
+	if err != nil {
+		s.importBook(lib, path, info, throttle)
+		return
+	}
 
-		book := &database.Book{
-			LibraryID: lib.ID,
-			Title:     title,
-			Author:    &author,
-			Format:    format,
-			Path:      path,
-			Size:      info.Size(),
+	if isComic(existing) && existing.PageCount == nil {
+		throttle.pause()
+		if err := s.readComic(existing, info); err != nil {
+			logger.Errorf("Failed to read comic %s: %v", path, err)
+		} else if err := s.db.UpdateComicDetails(existing); err != nil {
+			logger.Errorf("Failed to update comic: %v", err)
 		}
+	}
+	if existing.Format == "epub" && existing.CoverPath == nil {
+		throttle.pause()
+		if err := s.readEpubCover(existing, info); err != nil {
+			if !errors.Is(err, epub.ErrNoCover) {
+				logger.Errorf("Failed to read cover of %s: %v", path, err)
+			}
+		} else if err := s.db.SetBookCover(existing.ID, *existing.CoverPath); err != nil {
+			logger.Errorf("Failed to update book cover: %v", err)
+		}
+	}
+}
 
-		if err := s.db.CreateBook(book); err != nil {
-			log.Printf("Failed to add book: %v", err)
-		} else {
-			log.Printf("Added book: %s by %s", title, author)
+// importBook adds a book or comic file to a book library
+func (s *Scanner) importBook(lib *database.Library, path string, info os.FileInfo, throttle *scanThrottle) {
+	// Parse filename for title and author
+	ext := strings.ToLower(filepath.Ext(path))
+	filename := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	title, author := parseBookFilename(filename)
+
+	// Determine format from extension
+	format := strings.TrimPrefix(ext, ".")
+
+	book := &database.Book{
+		LibraryID: lib.ID,
+		Title:     title,
+		Author:    &author,
+		Format:    format,
+		Path:      path,
+		Size:      info.Size(),
+	}
+	if isComic(book) {
+		throttle.pause()
+		if err := s.readComic(book, info); err != nil {
+			logger.Errorf("Failed to read comic %s: %v", path, err)
+		}
+	}
+	if format == "epub" {
+		throttle.pause()
+		if err := s.readEpubCover(book, info); err != nil && !errors.Is(err, epub.ErrNoCover) {
+			logger.Errorf("Failed to read cover of %s: %v", path, err)
 		}
+	}
 
-		return nil
-	})
+	if err := s.db.CreateBook(book); err != nil {
+		logger.Errorf("Failed to add book: %v", err)
+	} else {
+		logger.Infof("Added book: %s by %s", book.Title, *book.Author)
+	}
 }
 
 func parseTrackFilename(filename string) (trackNum int, title string) {
@@ -1385,6 +1862,7 @@ func calculateMatchConfidence(folderInfo ShowParseResult, filenameInfo ParseResu
 
 // OrganizeAndExtractSubtitles renames folder to proper format and extracts subtitles
 func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath string) {
+	originalPath := movie.Path
 	videoPath := movie.Path
 	videoDir := filepath.Dir(videoPath)
 	videoFile := filepath.Base(videoPath)
@@ -1397,6 +1875,8 @@ func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath
 	}
 	// Clean folder name of invalid characters
 	expectedFolder = cleanFolderName(expectedFolder)
+	// The file keeps its edition, if it has one
+	expectedName := editionFileName(expectedFolder, movie.Edition)
 
 	expectedPath := filepath.Join(libraryPath, expectedFolder)
 	currentFolder := filepath.Base(videoDir)
@@ -1405,24 +1885,24 @@ func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath
 	if videoDir == libraryPath {
 		// Check if expected folder already exists
 		if _, err := os.Stat(expectedPath); os.IsNotExist(err) {
-			log.Printf("Creating folder for movie at library root: %s", expectedFolder)
+			logger.Infof("Creating folder for movie at library root: %s", expectedFolder)
 			if err := os.MkdirAll(expectedPath, 0755); err != nil {
-				log.Printf("Failed to create folder: %v", err)
+				logger.Errorf("Failed to create folder: %v", err)
 			} else {
 				// Move video file into the new folder
-				expectedVideoName := expectedFolder + ext
+				expectedVideoName := expectedName + ext
 				newVideoPath := filepath.Join(expectedPath, expectedVideoName)
 				if err := os.Rename(videoPath, newVideoPath); err != nil {
-					log.Printf("Failed to move video file: %v", err)
+					logger.Errorf("Failed to move video file: %v", err)
 				} else {
-					log.Printf("Moved video to: %s", newVideoPath)
+					logger.Infof("Moved video to: %s", newVideoPath)
 					movie.Path = newVideoPath
 					videoPath = newVideoPath
 					videoDir = expectedPath
 
 					// Update path in database
 					if err := s.db.UpdateMoviePath(movie.ID, newVideoPath); err != nil {
-						log.Printf("Failed to update movie path in database: %v", err)
+						logger.Errorf("Failed to update movie path in database: %v", err)
 					}
 				}
 			}
@@ -1431,9 +1911,9 @@ func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath
 		// Case 2: Video is in a folder but folder name doesn't match - rename folder
 		// Check if expected folder already exists
 		if _, err := os.Stat(expectedPath); os.IsNotExist(err) {
-			log.Printf("Renaming folder: %s -> %s", currentFolder, expectedFolder)
+			logger.Infof("Renaming folder: %s -> %s", currentFolder, expectedFolder)
 			if err := os.Rename(videoDir, expectedPath); err != nil {
-				log.Printf("Failed to rename folder: %v", err)
+				logger.Errorf("Failed to rename folder: %v", err)
 			} else {
 				// Update video path
 				newVideoPath := filepath.Join(expectedPath, videoFile)
@@ -1443,20 +1923,20 @@ func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath
 
 				// Update path in database
 				if err := s.db.UpdateMoviePath(movie.ID, newVideoPath); err != nil {
-					log.Printf("Failed to update movie path in database: %v", err)
+					logger.Errorf("Failed to update movie path in database: %v", err)
 				}
 
 				// Rename video file to match folder
-				expectedVideoName := expectedFolder + ext
+				expectedVideoName := expectedName + ext
 				if videoFile != expectedVideoName {
 					finalVideoPath := filepath.Join(expectedPath, expectedVideoName)
 					if err := os.Rename(newVideoPath, finalVideoPath); err != nil {
-						log.Printf("Failed to rename video file: %v", err)
+						logger.Errorf("Failed to rename video file: %v", err)
 					} else {
 						movie.Path = finalVideoPath
 						videoPath = finalVideoPath
 						if err := s.db.UpdateMoviePath(movie.ID, finalVideoPath); err != nil {
-							log.Printf("Failed to update movie path in database: %v", err)
+							logger.Errorf("Failed to update movie path in database: %v", err)
 						}
 					}
 				}
@@ -1464,29 +1944,41 @@ func (s *Scanner) OrganizeAndExtractSubtitles(movie *database.Movie, libraryPath
 		}
 	} else {
 		// Case 3: Folder name matches but video file might not - rename video file only
-		expectedVideoName := expectedFolder + ext
+		expectedVideoName := expectedName + ext
 		if videoFile != expectedVideoName {
 			finalVideoPath := filepath.Join(videoDir, expectedVideoName)
 			// Check if target doesn't already exist
 			if _, err := os.Stat(finalVideoPath); os.IsNotExist(err) {
-				log.Printf("Renaming video file: %s -> %s", videoFile, expectedVideoName)
+				logger.Infof("Renaming video file: %s -> %s", videoFile, expectedVideoName)
 				if err := os.Rename(videoPath, finalVideoPath); err != nil {
-					log.Printf("Failed to rename video file: %v", err)
+					logger.Errorf("Failed to rename video file: %v", err)
 				} else {
 					movie.Path = finalVideoPath
 					videoPath = finalVideoPath
 					if err := s.db.UpdateMoviePath(movie.ID, finalVideoPath); err != nil {
-						log.Printf("Failed to update movie path in database: %v", err)
+						logger.Errorf("Failed to update movie path in database: %v", err)
 					}
 				}
 			}
 		}
 	}
 
+	if movie.Path != originalPath && movie.TmdbID != nil {
+		if err := s.db.RecordMediaEvent(&database.MediaEvent{
+			EventType: database.MediaEventRename,
+			MediaType: "movie",
+			MediaID:   movie.TmdbID,
+			Summary:   fmt.Sprintf("Renamed %s to %s", filepath.Base(originalPath), filepath.Base(movie.Path)),
+			Details:   map[string]interface{}{"from": originalPath, "to": movie.Path},
+		}); err != nil {
+			logger.Errorf("Failed to record rename of %s: %v", originalPath, err)
+		}
+	}
+
 	// Create subtitles subfolder
 	subtitleDir := filepath.Join(filepath.Dir(videoPath), "subtitles")
 	if err := os.MkdirAll(subtitleDir, 0755); err != nil {
-		log.Printf("Failed to create subtitles directory: %v", err)
+		logger.Errorf("Failed to create subtitles directory: %v", err)
 		return
 	}
 
@@ -1520,7 +2012,7 @@ func (s *Scanner) extractSubtitlesToDir(videoPath, subtitleDir string) {
 	)
 	output, err := cmd.Output()
 	if err != nil {
-		log.Printf("Failed to probe subtitles for %s: %v", baseName, err)
+		logger.Errorf("Failed to probe subtitles for %s: %v", baseName, err)
 		return
 	}
 
@@ -1531,16 +2023,16 @@ func (s *Scanner) extractSubtitlesToDir(videoPath, subtitleDir string) {
 		} `json:"streams"`
 	}
 	if err := json.Unmarshal(output, &probeResult); err != nil {
-		log.Printf("Failed to parse ffprobe output for %s: %v", baseName, err)
+		logger.Errorf("Failed to parse ffprobe output for %s: %v", baseName, err)
 		return
 	}
 
 	if len(probeResult.Streams) == 0 {
-		log.Printf("No subtitles found in %s", baseName)
+		logger.Infof("No subtitles found in %s", baseName)
 		return
 	}
 
-	log.Printf("Extracting %d subtitle tracks from %s", len(probeResult.Streams), baseName)
+	logger.Infof("Extracting %d subtitle tracks from %s", len(probeResult.Streams), baseName)
 
 	// Extract each subtitle track
 	for i, stream := range probeResult.Streams {
@@ -1568,86 +2060,19 @@ func (s *Scanner) extractSubtitlesToDir(videoPath, subtitleDir string) {
 			"-y",
 		)
 		if err := cmd.Run(); err != nil {
-			log.Printf("Failed to extract subtitle track %d from %s: %v", i, baseName, err)
+			logger.Errorf("Failed to extract subtitle track %d from %s: %v", i, baseName, err)
 			continue
 		}
-		log.Printf("Extracted subtitle: %s", filepath.Base(subtitleFile))
+		logger.Infof("Extracted subtitle: %s", filepath.Base(subtitleFile))
 	}
 
-	log.Printf("Finished extracting subtitles from %s", baseName)
+	logger.Infof("Finished extracting subtitles from %s", baseName)
 }
 
-// ExtractSubtitles extracts all subtitle tracks from a video file to the cache directory
-func (s *Scanner) ExtractSubtitles(videoPath string) {
-	if s.cacheDir == "" {
-		return
-	}
-
-	subtitleDir := filepath.Join(s.cacheDir, "subtitles")
-	baseName := filepath.Base(videoPath)
-
-	// Get subtitle track count using ffprobe
-	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		"-select_streams", "s",
-		videoPath,
-	)
-	output, err := cmd.Output()
-	if err != nil {
-		log.Printf("Failed to probe subtitles for %s: %v", baseName, err)
-		return
-	}
-
-	var probeResult struct {
-		Streams []struct {
-			Index int `json:"index"`
-		} `json:"streams"`
-	}
-	if err := json.Unmarshal(output, &probeResult); err != nil {
-		log.Printf("Failed to parse ffprobe output for %s: %v", baseName, err)
-		return
-	}
-
-	if len(probeResult.Streams) == 0 {
-		log.Printf("No subtitles found in %s", baseName)
-		return
-	}
-
-	log.Printf("Extracting %d subtitle tracks from %s in background", len(probeResult.Streams), baseName)
-
-	// Extract each subtitle track
-	for i := range probeResult.Streams {
-		cacheFile := filepath.Join(subtitleDir, fmt.Sprintf("%s.track%d.vtt", baseName, i))
-
-		// Skip if already extracted
-		if _, err := os.Stat(cacheFile); err == nil {
-			continue
-		}
-
-		// Extract subtitle using ffmpeg
-		cmd := exec.Command("ffmpeg",
-			"-i", videoPath,
-			"-map", fmt.Sprintf("0:s:%d", i),
-			"-an", "-vn",
-			"-c:s", "webvtt",
-			"-f", "webvtt",
-			cacheFile,
-			"-y",
-		)
-		if err := cmd.Run(); err != nil {
-			log.Printf("Failed to extract subtitle track %d from %s: %v", i, baseName, err)
-			continue
-		}
-		log.Printf("Extracted subtitle track %d from %s", i, baseName)
-	}
-
-	log.Printf("Finished extracting subtitles from %s", baseName)
-}
-
-// ExtractChapters extracts chapter information from a video file and saves to database
-func (s *Scanner) ExtractChapters(mediaType string, mediaID int64, videoPath string) {
+// ExtractChapters reads a video file's chapters with ffprobe and stores
+// them, replacing any stored before. Chapters without a title are named by
+// number. Returns the chapters found, none if the file has none.
+func (s *Scanner) ExtractChapters(mediaType string, mediaID int64, videoPath string) ([]database.Chapter, error) {
 	baseName := filepath.Base(videoPath)
 
 	// Get chapter info using ffprobe
@@ -1659,8 +2084,7 @@ func (s *Scanner) ExtractChapters(mediaType string, mediaID int64, videoPath str
 	)
 	output, err := cmd.Output()
 	if err != nil {
-		// Not an error - many files don't have chapters
-		return
+		return nil, fmt.Errorf("ffprobe failed for %s: %w", baseName, err)
 	}
 
 	var probeResult struct {
@@ -1675,25 +2099,26 @@ func (s *Scanner) ExtractChapters(mediaType string, mediaID int64, videoPath str
 		} `json:"chapters"`
 	}
 	if err := json.Unmarshal(output, &probeResult); err != nil {
-		log.Printf("Failed to parse ffprobe chapters output for %s: %v", baseName, err)
-		return
+		logger.Errorf("Failed to parse ffprobe chapters output for %s: %v", baseName, err)
+		return nil, err
 	}
 
-	if len(probeResult.Chapters) == 0 {
-		return
-	}
-
-	log.Printf("Found %d chapters in %s", len(probeResult.Chapters), baseName)
-
-	var chapters []database.Chapter
+	chapters := []database.Chapter{}
 	for i, ch := range probeResult.Chapters {
-		// Parse start/end times from string (in seconds)
-		startTime, _ := strconv.ParseFloat(ch.StartTime, 64)
-		endTime, _ := strconv.ParseFloat(ch.EndTime, 64)
+		// Parse start/end times from string (in seconds), falling back to
+		// the time base
+		startTime, err := strconv.ParseFloat(ch.StartTime, 64)
+		if err != nil {
+			startTime = chapterSeconds(ch.Start, ch.TimeBase)
+		}
+		endTime, err := strconv.ParseFloat(ch.EndTime, 64)
+		if err != nil {
+			endTime = chapterSeconds(ch.End, ch.TimeBase)
+		}
 
-		title := ""
-		if t, ok := ch.Tags["title"]; ok {
-			title = t
+		title := strings.TrimSpace(ch.Tags["title"])
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
 		}
 
 		chapters = append(chapters, database.Chapter{
@@ -1706,16 +2131,36 @@ func (s *Scanner) ExtractChapters(mediaType string, mediaID int64, videoPath str
 		})
 	}
 
+	// Files without chapters drop any stored for an earlier version
 	if err := s.db.SaveChapters(mediaType, mediaID, chapters); err != nil {
-		log.Printf("Failed to save chapters for %s: %v", baseName, err)
-	} else {
-		log.Printf("Saved %d chapters for %s", len(chapters), baseName)
+		logger.Errorf("Failed to save chapters for %s: %v", baseName, err)
+		return nil, err
 	}
+	if len(chapters) == 0 {
+		return chapters, nil
+	}
+	logger.Infof("Saved %d chapters for %s", len(chapters), baseName)
 
 	// Also detect intro/credits segments from chapter titles (for episodes)
 	if mediaType == "episode" {
 		s.DetectSegmentsFromChapters(mediaID, chapters)
 	}
+	return chapters, nil
+}
+
+// chapterSeconds converts a chapter time in time base units, such as
+// 1/1000, to seconds
+func chapterSeconds(value int64, timeBase string) float64 {
+	num, den, ok := strings.Cut(timeBase, "/")
+	if !ok {
+		return 0
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return float64(value) * n / d
 }
 
 // DetectSegmentsFromChapters analyzes chapter titles to find intro/credits segments
@@ -1749,9 +2194,9 @@ func (s *Scanner) DetectSegmentsFromChapters(episodeID int64, chapters []databas
 				Source:       "chapter",
 			}
 			if err := s.db.CreateMediaSegment(segment); err != nil {
-				log.Printf("Failed to save %s segment for episode %d: %v", segmentType, episodeID, err)
+				logger.Errorf("Failed to save %s segment for episode %d: %v", segmentType, episodeID, err)
 			} else {
-				log.Printf("Detected %s segment from chapter '%s' (%.1f-%.1f)", segmentType, ch.Title, ch.StartTime, ch.EndTime)
+				logger.Infof("Detected %s segment from chapter '%s' (%.1f-%.1f)", segmentType, ch.Title, ch.StartTime, ch.EndTime)
 			}
 		}
 	}
@@ -1786,7 +2231,7 @@ func (s *Scanner) DetectSegmentsFromFile(episodeID int64, videoPath string) {
 		} `json:"chapters"`
 	}
 	if err := json.Unmarshal(output, &probeResult); err != nil {
-		log.Printf("Failed to parse ffprobe chapters for segment detection in %s: %v", baseName, err)
+		logger.Errorf("Failed to parse ffprobe chapters for segment detection in %s: %v", baseName, err)
 		return
 	}
 
@@ -1926,7 +2371,7 @@ func (s *Scanner) AutoDownloadSubtitles(mediaType string, videoPath string, titl
 		videoBase := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
 		subPath := videoBase + "." + lang + ".srt"
 		if _, err := os.Stat(subPath); err == nil {
-			log.Printf("Subtitle already exists for %s (%s), skipping", title, lang)
+			logger.Infof("Subtitle already exists for %s (%s), skipping", title, lang)
 			continue
 		}
 
@@ -1938,9 +2383,9 @@ func (s *Scanner) AutoDownloadSubtitles(mediaType string, videoPath string, titl
 		}
 
 		if downloadErr != nil {
-			log.Printf("Failed to auto-download %s subtitles for %s: %v", lang, title, downloadErr)
+			logger.Errorf("Failed to auto-download %s subtitles for %s: %v", lang, title, downloadErr)
 		} else {
-			log.Printf("Auto-downloaded %s subtitles for %s", lang, title)
+			logger.Infof("Auto-downloaded %s subtitles for %s", lang, title)
 		}
 	}
 }
@@ -0,0 +1,183 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Subtitle extraction
+//
+// Embedded subtitle tracks are extracted to WebVTT in the subtitles cache
+// after import, and by the Subtitle Extraction task for files imported
+// before or whose extraction didn't finish, so players don't wait minutes
+// for a large file to be read on first play. Tracks a movie's organizer
+// already extracted next to the file are left there. Picture-based tracks
+// can't be turned into text and are skipped.
+
+// bitmapSubtitleCodecs are subtitle codecs that are pictures, not text
+var bitmapSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// SubtitleCachePath returns where an embedded subtitle track of a video is
+// cached in a subtitles cache folder
+func SubtitleCachePath(subtitleDir, videoPath string, track int) string {
+	return filepath.Join(subtitleDir, fmt.Sprintf("%s.track%d.vtt", filepath.Base(videoPath), track))
+}
+
+// ExtractSubtitleTracks extracts a movie or episode file's embedded text
+// subtitle tracks to the subtitles cache, skipping those already extracted,
+// and records the extraction. Returns how many tracks are ready.
+func (s *Scanner) ExtractSubtitleTracks(ctx context.Context, mediaType string, mediaID int64, videoPath string) (int, error) {
+	if s.cacheDir == "" {
+		return 0, fmt.Errorf("no subtitles cache")
+	}
+	file := &database.MediaFile{MediaType: mediaType, MediaID: mediaID, Path: videoPath}
+	baseName := filepath.Base(videoPath)
+
+	ready, failed, err := s.extractSubtitleTracks(ctx, videoPath)
+	if ctx.Err() != nil {
+		// Left for next time
+		return ready, ctx.Err()
+	}
+	var errMsg *string
+	if err != nil {
+		msg := err.Error()
+		errMsg = &msg
+	} else if failed > 0 {
+		msg := fmt.Sprintf("%d tracks failed to extract", failed)
+		errMsg = &msg
+	}
+	if saveErr := s.db.SaveSubtitleExtraction(file, ready, errMsg); saveErr != nil {
+		logger.Errorf("Failed to record subtitle extraction for %s: %v", baseName, saveErr)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("subtitle extraction failed for %s: %w", baseName, err)
+	}
+	return ready, nil
+}
+
+// extractSubtitleTracks extracts what hasn't been of a file's subtitle
+// tracks, returning how many tracks are ready and how many failed
+func (s *Scanner) extractSubtitleTracks(ctx context.Context, videoPath string) (int, int, error) {
+	baseName := filepath.Base(videoPath)
+
+	output, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_streams",
+		"-select_streams", "s",
+		videoPath,
+	).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	var probeResult struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probeResult); err != nil {
+		return 0, 0, err
+	}
+	if len(probeResult.Streams) == 0 {
+		return 0, 0, nil
+	}
+
+	subtitleDir := filepath.Join(s.cacheDir, "subtitles")
+	if err := os.MkdirAll(subtitleDir, 0755); err != nil {
+		return 0, 0, err
+	}
+
+	ready, failed := 0, 0
+	for i, stream := range probeResult.Streams {
+		if bitmapSubtitleCodecs[stream.CodecName] {
+			continue
+		}
+		cacheFile := SubtitleCachePath(subtitleDir, videoPath, i)
+		if fileExists(cacheFile) || organizedSubtitleExists(videoPath, i) {
+			ready++
+			continue
+		}
+
+		// Extract to a scratch file so a half-written track is never served
+		scratch := cacheFile + ".part"
+		cmd := exec.CommandContext(ctx, "ffmpeg",
+			"-v", "error",
+			"-nostdin",
+			"-i", videoPath,
+			"-map", fmt.Sprintf("0:s:%d", i),
+			"-an", "-vn",
+			"-c:s", "webvtt",
+			"-f", "webvtt",
+			scratch,
+			"-y",
+		)
+		if err := cmd.Run(); err != nil {
+			os.Remove(scratch)
+			if ctx.Err() != nil {
+				return ready, failed, nil
+			}
+			logger.Errorf("Failed to extract subtitle track %d from %s: %v", i, baseName, err)
+			failed++
+			continue
+		}
+		if err := os.Rename(scratch, cacheFile); err != nil {
+			os.Remove(scratch)
+			failed++
+			continue
+		}
+		logger.Infof("Extracted subtitle track %d from %s", i, baseName)
+		ready++
+	}
+	return ready, failed, nil
+}
+
+// organizedSubtitleExists reports whether a movie's organizer extracted a
+// subtitle track to the subtitles folder next to the file
+func organizedSubtitleExists(videoPath string, track int) bool {
+	baseName := filepath.Base(videoPath)
+	prefix := fmt.Sprintf("%s.%d.", strings.TrimSuffix(baseName, filepath.Ext(baseName)), track)
+	entries, err := os.ReadDir(filepath.Join(filepath.Dir(videoPath), "subtitles"))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ".vtt") {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearExtractedSubtitles deletes the cached subtitle tracks of a movie or
+// episode file and forgets they were extracted, for when the file changes
+func (s *Scanner) ClearExtractedSubtitles(mediaType string, mediaID int64, videoPath string) {
+	if err := s.db.DeleteSubtitleExtraction(mediaType, mediaID); err != nil {
+		logger.Errorf("Failed to clear subtitle extraction of %s %d: %v", mediaType, mediaID, err)
+	}
+	if s.cacheDir == "" {
+		return
+	}
+	subtitleDir := filepath.Join(s.cacheDir, "subtitles")
+	entries, err := os.ReadDir(subtitleDir)
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(videoPath) + ".track"
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ".vtt") {
+			os.Remove(filepath.Join(subtitleDir, entry.Name()))
+		}
+	}
+}
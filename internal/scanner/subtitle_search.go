@@ -0,0 +1,351 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/subtitles"
+)
+
+// Subtitle search
+//
+// Libraries with a subtitle profile get the profile's languages downloaded
+// from OpenSubtitles next to their movie and episode files, by the Subtitle
+// Search task and when a file is retried. A language is wanted while no
+// subtitle file in it sits next to the video, so subtitles added by hand
+// count too. Files with languages still missing are searched again later,
+// waiting longer each time, since subtitles for new releases turn up over
+// the following days.
+//
+// Of the subtitles found, the best scoring one for the file is downloaded.
+// The Subtitle Upgrade task looks again for better ones while a download is
+// new, replacing it when one scores higher, much like quality upgrades for
+// videos.
+
+const (
+	// subtitleRetryDelay is how long after a first search that missed a
+	// language the file is searched again; it doubles with each miss
+	subtitleRetryDelay = 6 * time.Hour
+	// subtitleRetryMaxDelay is the longest a file waits to be searched again
+	subtitleRetryMaxDelay = 7 * 24 * time.Hour
+)
+
+// subtitleFileExts are the extensions of subtitle files next to videos
+var subtitleFileExts = map[string]bool{
+	".srt": true,
+	".ass": true,
+	".ssa": true,
+	".vtt": true,
+	".sub": true,
+}
+
+// SearchSubtitles downloads the languages a file's subtitle profile wants
+// that it doesn't have, recording each in the subtitle history and where the
+// search is with the file. Returns how many subtitles were downloaded; when
+// the day's downloads run out it stops with subtitles.ErrDownloadLimit,
+// leaving the file to be searched again.
+func (s *Scanner) SearchSubtitles(item *database.SubtitleSearchItem) (int, error) {
+	apiKey, _ := s.db.GetSetting("opensubtitles_api_key")
+	if apiKey == "" {
+		return 0, fmt.Errorf("OpenSubtitles API key not configured")
+	}
+	profile, err := s.db.GetSubtitleProfile(item.ProfileID)
+	if err != nil {
+		return 0, err
+	}
+	if profile == nil {
+		return 0, fmt.Errorf("subtitle profile %d not found", item.ProfileID)
+	}
+
+	attempts := 0
+	if previous, err := s.db.GetSubtitleSearch(item.MediaType, item.MediaID); err == nil && previous != nil && previous.Path == item.Path {
+		attempts = previous.Attempts
+	}
+
+	client := subtitles.NewClient(apiKey)
+	hash, _ := subtitles.ComputeMovieHash(item.Path)
+	baseName := filepath.Base(item.Path)
+
+	downloaded := 0
+	var missing []string
+	var lastErr error
+	for _, lang := range profile.Languages {
+		if hasSubtitleFile(item.Path, lang, profile.ForcedOnly) {
+			continue
+		}
+
+		entry := &database.SubtitleHistoryEntry{MediaType: item.MediaType, MediaID: item.MediaID, Language: lang}
+		path := subtitlePath(item.Path, lang, profile.ForcedOnly)
+		sub, err := findSubtitle(client, item, profile, lang, hash)
+		if err == nil && sub != nil {
+			err = fetchSubtitle(client, sub, path)
+		}
+		switch {
+		case errors.Is(err, subtitles.ErrDownloadLimit):
+			return downloaded, err
+		case err != nil:
+			logger.Errorf("Failed to download %s subtitles for %s: %v", lang, baseName, err)
+			msg := err.Error()
+			entry.Action = database.SubtitleFailed
+			entry.Error = &msg
+			missing = append(missing, lang)
+			lastErr = err
+		case sub == nil:
+			entry.Action = database.SubtitleNotFound
+			missing = append(missing, lang)
+		default:
+			logger.Infof("Downloaded %s subtitles for %s", lang, baseName)
+			entry.Action = database.SubtitleDownloaded
+			entry.Release = &sub.Release
+			entry.Score = &sub.Score
+			entry.Path = &path
+			s.recordSubtitleFile(item, lang, profile.ForcedOnly, sub, path)
+			downloaded++
+		}
+		if err := s.db.AddSubtitleHistory(entry); err != nil {
+			logger.Errorf("Failed to record subtitle history for %s: %v", baseName, err)
+		}
+	}
+
+	search := &database.SubtitleSearch{
+		MediaType: item.MediaType,
+		MediaID:   item.MediaID,
+		Path:      item.Path,
+		Status:    database.SubtitleSearchDone,
+	}
+	if len(missing) > 0 {
+		attempts++
+		next := time.Now().Add(subtitleRetryAfter(attempts))
+		search.Status = database.SubtitleSearchMissing
+		search.Missing = missing
+		search.Attempts = attempts
+		search.NextSearchAt = &next
+		if lastErr != nil {
+			msg := lastErr.Error()
+			search.Error = &msg
+		}
+	}
+	if err := s.db.SaveSubtitleSearch(search); err != nil {
+		logger.Errorf("Failed to record subtitle search for %s: %v", baseName, err)
+	}
+	return downloaded, nil
+}
+
+// subtitleRetryAfter returns how long to wait before searching a file again
+// after its attempts-th search missed a language
+func subtitleRetryAfter(attempts int) time.Duration {
+	delay := subtitleRetryDelay
+	for i := 1; i < attempts && delay < subtitleRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > subtitleRetryMaxDelay {
+		delay = subtitleRetryMaxDelay
+	}
+	return delay
+}
+
+// findSubtitle searches OpenSubtitles for a file's subtitles in a language,
+// returning the best scoring one, or nil if nothing was found
+func findSubtitle(client *subtitles.Client, item *database.SubtitleSearchItem, profile *database.SubtitleProfile, lang, hash string) (*subtitles.Subtitle, error) {
+	req := subtitles.SearchRequest{
+		Languages:        []string{lang},
+		MovieHash:        hash,
+		ForeignPartsOnly: profile.ForcedOnly,
+	}
+	if item.MediaType == "movie" {
+		switch {
+		case item.TmdbID > 0:
+			req.TMDbID = int(item.TmdbID)
+		case item.ImdbID != "":
+			req.IMDbID = strings.TrimPrefix(item.ImdbID, "tt")
+		default:
+			req.Query = item.Title
+			req.Year = item.Year
+		}
+	} else {
+		req.Season = item.Season
+		req.Episode = item.Episode
+		if item.TmdbID > 0 {
+			req.ParentTMDbID = int(item.TmdbID)
+		} else {
+			req.Query = item.Title
+		}
+	}
+	switch profile.HearingImpaired {
+	case database.SubtitleHIOnly:
+		hi := true
+		req.HearingImpaired = &hi
+	case database.SubtitleHIExclude:
+		hi := false
+		req.HearingImpaired = &hi
+	}
+
+	results, err := client.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	return subtitles.NewTarget(item.Path, profileHI(profile)).Best(results), nil
+}
+
+// profileHI returns whether a profile wants hearing impaired subtitles, or
+// nil if it doesn't mind
+func profileHI(profile *database.SubtitleProfile) *bool {
+	var hi bool
+	switch profile.HearingImpaired {
+	case database.SubtitleHIPrefer, database.SubtitleHIOnly:
+		hi = true
+	case database.SubtitleHIExclude:
+		hi = false
+	default:
+		return nil
+	}
+	return &hi
+}
+
+// fetchSubtitle downloads a subtitle to path. It's downloaded next to it
+// first, so a subtitle being replaced stays until the new one is there.
+func fetchSubtitle(client *subtitles.Client, sub *subtitles.Subtitle, path string) error {
+	link, err := client.GetDownloadLink(sub.FileID)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".part"
+	if err := client.Download(link.Link, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// subtitlePath returns where a file's downloaded subtitles in a language go,
+// e.g. movie.en.srt or movie.en.forced.srt
+func subtitlePath(videoPath, lang string, forced bool) string {
+	path := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + "." + lang
+	if forced {
+		path += ".forced"
+	}
+	return path + ".srt"
+}
+
+// recordSubtitleFile keeps a downloaded subtitle and its score, so it can be
+// upgraded
+func (s *Scanner) recordSubtitleFile(item *database.SubtitleSearchItem, lang string, forced bool, sub *subtitles.Subtitle, path string) {
+	file := &database.SubtitleFile{
+		MediaType: item.MediaType,
+		MediaID:   item.MediaID,
+		Language:  lang,
+		Forced:    forced,
+		VideoPath: item.Path,
+		Path:      path,
+		FileID:    sub.FileID,
+		Release:   &sub.Release,
+		Score:     sub.Score,
+	}
+	if err := s.db.SaveSubtitleFile(file); err != nil {
+		logger.Errorf("Failed to record subtitle file %s: %v", filepath.Base(path), err)
+	}
+}
+
+// UpgradeSubtitle looks for a better scoring subtitle than one the automatic
+// search downloaded, replacing it if there is one. Returns whether it was
+// replaced; when the day's downloads run out it stops with
+// subtitles.ErrDownloadLimit, leaving the subtitle to be looked at again.
+// Subtitles no longer wanted by the profile, or no longer there, are
+// forgotten.
+func (s *Scanner) UpgradeSubtitle(item *database.SubtitleSearchItem, file *database.SubtitleFile) (bool, error) {
+	apiKey, _ := s.db.GetSetting("opensubtitles_api_key")
+	if apiKey == "" {
+		return false, fmt.Errorf("OpenSubtitles API key not configured")
+	}
+	profile, err := s.db.GetSubtitleProfile(item.ProfileID)
+	if err != nil {
+		return false, err
+	}
+	wanted := profile != nil && profile.ForcedOnly == file.Forced
+	if wanted {
+		wanted = false
+		for _, lang := range profile.Languages {
+			if strings.EqualFold(lang, file.Language) {
+				wanted = true
+				break
+			}
+		}
+	}
+	if _, err := os.Stat(file.Path); !wanted || err != nil {
+		return false, s.db.DeleteSubtitleFile(file)
+	}
+
+	client := subtitles.NewClient(apiKey)
+	hash, _ := subtitles.ComputeMovieHash(item.Path)
+	baseName := filepath.Base(item.Path)
+
+	sub, err := findSubtitle(client, item, profile, file.Language, hash)
+	if err == nil && (sub == nil || sub.FileID == file.FileID || sub.Score <= file.Score) {
+		return false, s.db.MarkSubtitleFileChecked(file)
+	}
+	if err == nil {
+		err = fetchSubtitle(client, sub, file.Path)
+	}
+	if errors.Is(err, subtitles.ErrDownloadLimit) {
+		return false, err
+	}
+	if err != nil {
+		// The subtitle is still there, so this is only logged
+		logger.Errorf("Failed to upgrade %s subtitles for %s: %v", file.Language, baseName, err)
+		return false, s.db.MarkSubtitleFileChecked(file)
+	}
+
+	logger.Infof("Upgraded %s subtitles for %s (score %d to %d)", file.Language, baseName, file.Score, sub.Score)
+	s.recordSubtitleFile(item, file.Language, file.Forced, sub, file.Path)
+	entry := &database.SubtitleHistoryEntry{
+		MediaType: item.MediaType,
+		MediaID:   item.MediaID,
+		Language:  file.Language,
+		Action:    database.SubtitleUpgraded,
+		Release:   &sub.Release,
+		Score:     &sub.Score,
+		Path:      &file.Path,
+	}
+	if err := s.db.AddSubtitleHistory(entry); err != nil {
+		logger.Errorf("Failed to record subtitle history for %s: %v", baseName, err)
+	}
+	// Looked at this run, so it isn't again
+	return true, s.db.MarkSubtitleFileChecked(file)
+}
+
+// hasSubtitleFile reports whether there's a subtitle file in a language next
+// to a video, e.g. movie.en.srt or movie.en.forced.srt. Forced subtitles
+// only count when forced ones are wanted, and the other way around.
+func hasSubtitleFile(videoPath, lang string, forced bool) bool {
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	entries, err := os.ReadDir(filepath.Dir(videoPath))
+	if err != nil {
+		return false
+	}
+	lang = strings.ToLower(lang)
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if entry.IsDir() || !subtitleFileExts[ext] || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		hasLang, hasForced := false, false
+		for _, part := range strings.Split(strings.ToLower(strings.TrimSuffix(name[len(base)+1:], ext)), ".") {
+			switch part {
+			case lang:
+				hasLang = true
+			case "forced":
+				hasForced = true
+			}
+		}
+		if hasLang && hasForced == forced {
+			return true
+		}
+	}
+	return false
+}
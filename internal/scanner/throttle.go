@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Scan throttle
+//
+// Scanning a library on an SMB or NFS share flat out can saturate the NAS.
+// Libraries with the scan throttle on are scanned gently: subtitle, chapter
+// and fingerprint extraction run a few files at a time rather than all at
+// once, the scan pauses after each file it reads, and files already in the
+// library aren't stat'ed or probed again.
+
+// Settings for the scan throttle
+const (
+	SettingThrottleMaxIO         = "scan_throttle_max_io"         // Files read in the background at once
+	SettingThrottleSleep         = "scan_throttle_sleep_ms"       // Pause after each file read, in milliseconds
+	SettingThrottleSkipUnchanged = "scan_throttle_skip_unchanged" // Leave files already in the library alone
+)
+
+// Bounds of the throttle settings
+const (
+	maxThrottleIO    = 16
+	maxThrottleSleep = 10000
+)
+
+// Throttle is how gently throttled libraries are scanned
+type Throttle struct {
+	MaxIO         int           `json:"maxIO"`
+	Sleep         time.Duration `json:"-"`
+	SleepMs       int           `json:"sleepMs"`
+	SkipUnchanged bool          `json:"skipUnchanged"`
+}
+
+// ValidateThrottleSetting checks the scan throttle settings. Other settings
+// are always valid.
+func ValidateThrottleSetting(key, value string) error {
+	switch key {
+	case SettingThrottleMaxIO:
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > maxThrottleIO {
+			return fmt.Errorf("Scan throttle IO must be between 1 and %d files at once", maxThrottleIO)
+		}
+	case SettingThrottleSleep:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 || n > maxThrottleSleep {
+			return fmt.Errorf("Scan throttle pause must be between 0 and %d milliseconds", maxThrottleSleep)
+		}
+	case SettingThrottleSkipUnchanged:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("Scan throttle skip unchanged must be true or false")
+		}
+	}
+	return nil
+}
+
+// GetThrottle returns the configured scan throttle
+func (s *Scanner) GetThrottle() Throttle {
+	t := Throttle{MaxIO: 1, SleepMs: 250, SkipUnchanged: true}
+	if v, err := s.db.GetSetting(SettingThrottleMaxIO); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 1 && n <= maxThrottleIO {
+			t.MaxIO = n
+		}
+	}
+	if v, err := s.db.GetSetting(SettingThrottleSleep); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= maxThrottleSleep {
+			t.SleepMs = n
+		}
+	}
+	if v, err := s.db.GetSetting(SettingThrottleSkipUnchanged); err == nil {
+		t.SkipUnchanged = v != "false"
+	}
+	t.Sleep = time.Duration(t.SleepMs) * time.Millisecond
+	return t
+}
+
+// scanThrottle throttles one scan of a library. A nil scanThrottle scans
+// flat out.
+type scanThrottle struct {
+	Throttle
+	io chan struct{} // Slots for background reads
+}
+
+// throttleFor returns the throttle for scanning a library, or nil if the
+// library isn't throttled
+func (s *Scanner) throttleFor(lib *database.Library) *scanThrottle {
+	if !lib.ScanThrottle {
+		return nil
+	}
+	t := s.GetThrottle()
+	logger.Infof("Scanning %s throttled: %d files at once, %s between files", lib.Name, t.MaxIO, t.Sleep)
+	return &scanThrottle{Throttle: t, io: make(chan struct{}, t.MaxIO)}
+}
+
+// pause waits between files that were read
+func (t *scanThrottle) pause() {
+	if t != nil && t.Sleep > 0 {
+		time.Sleep(t.Sleep)
+	}
+}
+
+// skipUnchanged reports whether files already in the library are left alone
+func (t *scanThrottle) skipUnchanged() bool {
+	return t != nil && t.SkipUnchanged
+}
+
+// background runs fn in the background, waiting for a free slot when
+// throttled
+func (t *scanThrottle) background(fn func()) {
+	if t == nil {
+		go fn()
+		return
+	}
+	go func() {
+		t.io <- struct{}{}
+		defer func() { <-t.io }()
+		fn()
+	}()
+}
+
+// throttledUnchanged returns the IDs of the movies and episodes in throttled
+// libraries that already have their quality detected, for re-detection to
+// leave alone
+func (s *Scanner) throttledUnchanged() (movies, episodes map[int64]bool) {
+	movies, episodes = map[int64]bool{}, map[int64]bool{}
+	if !s.GetThrottle().SkipUnchanged {
+		return movies, episodes
+	}
+	libraries, err := s.db.GetLibraries()
+	if err != nil {
+		return movies, episodes
+	}
+	for _, lib := range libraries {
+		if !lib.ScanThrottle {
+			continue
+		}
+		switch lib.Type {
+		case "movies":
+			list, _ := s.db.GetMoviesByLibrary(lib.ID)
+			for _, m := range list {
+				if status, _ := s.db.GetMediaQualityStatus(m.ID, "movie"); status != nil && status.CurrentScore > 0 {
+					movies[m.ID] = true
+				}
+			}
+		case "tv":
+			list, _ := s.db.GetEpisodesByLibrary(lib.ID)
+			for _, ep := range list {
+				if status, _ := s.db.GetMediaQualityStatus(ep.ID, "episode"); status != nil && status.CurrentScore > 0 {
+					episodes[ep.ID] = true
+				}
+			}
+		}
+	}
+	return movies, episodes
+}
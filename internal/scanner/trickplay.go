@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/jpeg"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Trickplay storyboards
+//
+// A storyboard is a thumbnail every TrickplayInterval seconds of a movie or
+// episode, tiled into sprite sheets of TrickplayColumns by TrickplayRows
+// thumbnails. Sheets are JPEGs in the image cache at
+// trickplay/{type}/{id}/{n}.jpg, numbered from 0; the thumbnail at a time t
+// is number t/interval, counting across sheets then rows.
+
+// Storyboard layout
+const (
+	TrickplayInterval = 10  // Seconds between thumbnails
+	TrickplayWidth    = 320 // Of a thumbnail
+	TrickplayColumns  = 10
+	TrickplayRows     = 10
+)
+
+// trickplayTimeout is the longest ffmpeg may take over one file
+const trickplayTimeout = 30 * time.Minute
+
+// TrickplayDir returns the folder a movie or episode's sheets are in
+func TrickplayDir(imageDir, mediaType string, mediaID int64) string {
+	return filepath.Join(imageDir, "trickplay", mediaType, strconv.FormatInt(mediaID, 10))
+}
+
+// GenerateTrickplay makes a movie or episode's storyboard with ffmpeg,
+// replacing any made before. Files ffmpeg can't read are recorded as failed
+// so they aren't tried again until they change.
+func (s *Scanner) GenerateTrickplay(mediaType string, mediaID int64, videoPath string) (*database.Trickplay, error) {
+	if s.cacheDir == "" {
+		return nil, fmt.Errorf("no image cache")
+	}
+	baseName := filepath.Base(videoPath)
+
+	t, err := s.renderTrickplay(mediaType, mediaID, videoPath)
+	if err != nil {
+		msg := err.Error()
+		failed := &database.Trickplay{MediaType: mediaType, MediaID: mediaID, IntervalSeconds: TrickplayInterval, Error: &msg}
+		if saveErr := s.db.SaveTrickplay(failed); saveErr != nil {
+			logger.Errorf("Failed to record trickplay failure for %s: %v", baseName, saveErr)
+		}
+		return nil, fmt.Errorf("trickplay failed for %s: %w", baseName, err)
+	}
+	if err := s.db.SaveTrickplay(t); err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated %d trickplay thumbnails for %s", t.Thumbnails, baseName)
+	return t, nil
+}
+
+// renderTrickplay writes a file's sheets to a scratch folder and, once
+// they're all written, swaps it in for the old ones
+func (s *Scanner) renderTrickplay(mediaType string, mediaID int64, videoPath string) (*database.Trickplay, error) {
+	duration, err := videoDuration(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := TrickplayDir(filepath.Join(s.cacheDir, "images"), mediaType, mediaID)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	scratch, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), trickplayTimeout)
+	defer cancel()
+	// Only keyframes are decoded, which is much faster and close enough
+	// for scrubbing
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-v", "error", "-nostdin",
+		"-skip_frame", "nokey",
+		"-i", videoPath,
+		"-an", "-sn", "-dn",
+		"-vf", fmt.Sprintf("fps=1/%d,scale=%d:-2,tile=%dx%d", TrickplayInterval, TrickplayWidth, TrickplayColumns, TrickplayRows),
+		"-q:v", "5",
+		"-start_number", "0",
+		filepath.Join(scratch, "%d.jpg"),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg error: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	sheets, _ := filepath.Glob(filepath.Join(scratch, "*.jpg"))
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("ffmpeg wrote no thumbnails")
+	}
+	f, err := os.Open(filepath.Join(scratch, "0.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	config, err := jpeg.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	perSheet := TrickplayColumns * TrickplayRows
+	thumbnails := int(math.Ceil(duration / TrickplayInterval))
+	if thumbnails > len(sheets)*perSheet {
+		thumbnails = len(sheets) * perSheet
+	}
+	if thumbnails < 1 {
+		thumbnails = 1
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(scratch, dir); err != nil {
+		return nil, err
+	}
+	return &database.Trickplay{
+		MediaType:       mediaType,
+		MediaID:         mediaID,
+		IntervalSeconds: TrickplayInterval,
+		Width:           config.Width / TrickplayColumns,
+		Height:          config.Height / TrickplayRows,
+		Columns:         TrickplayColumns,
+		Rows:            TrickplayRows,
+		Thumbnails:      thumbnails,
+		Sheets:          len(sheets),
+	}, nil
+}
+
+// RemoveTrickplay deletes a movie or episode's storyboard, so the Trickplay
+// task makes it again if it's still in the library
+func (s *Scanner) RemoveTrickplay(mediaType string, mediaID int64) {
+	if err := s.db.DeleteTrickplay(mediaType, mediaID); err != nil {
+		logger.Errorf("Failed to delete trickplay of %s %d: %v", mediaType, mediaID, err)
+		return
+	}
+	if s.cacheDir != "" {
+		os.RemoveAll(TrickplayDir(filepath.Join(s.cacheDir, "images"), mediaType, mediaID))
+	}
+}
+
+// videoDuration reads a video file's length in seconds with ffprobe
+func videoDuration(videoPath string) (float64, error) {
+	output, err := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_entries", "format=duration",
+		videoPath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, err
+	}
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("unknown duration")
+	}
+	return duration, nil
+}
@@ -0,0 +1,534 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Library watching
+//
+// Between interval scans, library folders are watched for files being
+// added, changed or removed. Changes are collected until a library has been
+// quiet for a few seconds, so a file being copied in is imported once it's
+// complete, and then only the files that changed are imported or marked
+// missing rather than the whole library being scanned again. Network shares
+// whose changes the OS doesn't report still rely on interval scans.
+
+// SettingWatch turns library watching on or off
+const SettingWatch = "library_watch"
+
+// watchDebounce is how long a library has to be quiet before its changes are
+// imported. Files modified more recently than this are left for later, as
+// they may still be being written.
+const watchDebounce = 5 * time.Second
+
+// ValidateWatchSetting checks the library watching setting. Other settings
+// are always valid.
+func ValidateWatchSetting(key, value string) error {
+	if key == SettingWatch && value != "true" && value != "false" {
+		return fmt.Errorf("Library watching must be true or false")
+	}
+	return nil
+}
+
+// Watcher imports files as they change in library folders
+type Watcher struct {
+	s *Scanner
+
+	mu        sync.Mutex
+	fs        *fsnotify.Watcher          // Nil while not watching
+	libraries map[int64]database.Library // Watched libraries by ID
+	pending   map[int64]map[string]bool  // Changed paths by library
+	timer     *time.Timer                // Imports the pending changes once quiet
+}
+
+// NewWatcher creates a library watcher for a scanner. It does nothing until
+// started.
+func NewWatcher(s *Scanner) *Watcher {
+	return &Watcher{
+		s:         s,
+		libraries: make(map[int64]database.Library),
+		pending:   make(map[int64]map[string]bool),
+	}
+}
+
+// Enabled reports whether library watching is on
+func (w *Watcher) Enabled() bool {
+	value, err := w.s.db.GetSetting(SettingWatch)
+	return err != nil || value != "false"
+}
+
+// Sync watches the libraries as they are now, stopping if watching was
+// turned off. Call it after libraries are added, moved or removed.
+func (w *Watcher) Sync() {
+	if !w.Enabled() {
+		w.Stop()
+		return
+	}
+	libraries, err := w.s.db.GetLibraries()
+	if err != nil {
+		logger.Errorf("Failed to get libraries to watch: %v", err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fs == nil {
+		fs, err := fsnotify.NewWatcher()
+		if err != nil {
+			logger.Errorf("Failed to start watching libraries: %v", err)
+			return
+		}
+		w.fs = fs
+		go w.loop(fs)
+	}
+
+	wanted := make(map[int64]database.Library, len(libraries))
+	for _, lib := range libraries {
+		wanted[lib.ID] = lib
+	}
+	for id, lib := range w.libraries {
+		if next, ok := wanted[id]; !ok || next.Path != lib.Path {
+			w.unwatchLocked(lib.Path)
+			delete(w.libraries, id)
+			delete(w.pending, id)
+		}
+	}
+	for id, lib := range wanted {
+		if _, ok := w.libraries[id]; ok {
+			w.libraries[id] = lib // Pick up other changes, like the scan throttle
+			continue
+		}
+		dirs := w.watchTreeLocked(lib.Path)
+		w.libraries[id] = lib
+		logger.Infof("Watching %s: %d folders", lib.Name, dirs)
+	}
+}
+
+// Stop stops watching, dropping changes not yet imported
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fs == nil {
+		return
+	}
+	w.fs.Close()
+	w.fs = nil
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.libraries = make(map[int64]database.Library)
+	w.pending = make(map[int64]map[string]bool)
+	logger.Infof("Stopped watching libraries")
+}
+
+// watchTreeLocked watches a folder and every folder under it, returning how
+// many it watches
+func (w *Watcher) watchTreeLocked(root string) int {
+	dirs := 0
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if err := w.fs.Add(path); err != nil {
+			logger.Errorf("Failed to watch %s: %v", path, err)
+			return nil
+		}
+		dirs++
+		return nil
+	})
+	return dirs
+}
+
+// unwatchLocked stops watching a folder and the folders under it
+func (w *Watcher) unwatchLocked(root string) {
+	for _, path := range w.fs.WatchList() {
+		if isUnder(path, root) {
+			w.fs.Remove(path)
+		}
+	}
+}
+
+// isUnder reports whether path is root or inside it
+func isUnder(path, root string) bool {
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// loop receives file events until the watcher is closed
+func (w *Watcher) loop(fs *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-fs.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-fs.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("Library watcher error: %v", err)
+		}
+	}
+}
+
+// handle queues a changed path for import. New folders are watched too,
+// and the files already in them queued, as a folder moved into a library
+// brings its files without events for them.
+func (w *Watcher) handle(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fs == nil {
+		return
+	}
+	lib := w.libraryForLocked(event.Name)
+	if lib == nil {
+		return
+	}
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.watchTreeLocked(event.Name)
+			filepath.Walk(event.Name, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					w.queueLocked(lib.ID, path)
+				}
+				return nil
+			})
+			return
+		}
+	}
+	w.queueLocked(lib.ID, event.Name)
+}
+
+// libraryForLocked returns the watched library a path is in
+func (w *Watcher) libraryForLocked(path string) *database.Library {
+	for _, lib := range w.libraries {
+		if isUnder(path, lib.Path) {
+			return &lib
+		}
+	}
+	return nil
+}
+
+// queueLocked adds a changed path to a library's pending changes and waits
+// for quiet again
+func (w *Watcher) queueLocked(libraryID int64, path string) {
+	if w.pending[libraryID] == nil {
+		w.pending[libraryID] = make(map[string]bool)
+	}
+	w.pending[libraryID][path] = true
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(watchDebounce, w.flush)
+	} else {
+		w.timer.Reset(watchDebounce)
+	}
+}
+
+// flush imports the pending changes of each library. Libraries being
+// scanned, and files still being written, are left for the next round.
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[int64]map[string]bool)
+	libraries := make(map[int64]database.Library, len(pending))
+	for id := range pending {
+		if lib, ok := w.libraries[id]; ok {
+			libraries[id] = lib
+		}
+	}
+	w.mu.Unlock()
+
+	for id, paths := range pending {
+		lib, ok := libraries[id]
+		if !ok {
+			continue
+		}
+		list := make([]string, 0, len(paths))
+		for path := range paths {
+			list = append(list, path)
+		}
+		sort.Strings(list)
+
+		var later []string
+		lock := w.s.libraryLock(id)
+		if lock.TryLock() {
+			later = w.s.importChanges(&lib, list)
+			lock.Unlock()
+		} else {
+			later = list
+		}
+
+		if len(later) > 0 {
+			w.mu.Lock()
+			if _, watched := w.libraries[id]; watched && w.fs != nil {
+				for _, path := range later {
+					w.queueLocked(id, path)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// importChanges imports new files in a library, updates changed ones and
+// marks removed movies and episodes missing or removes photos, as a scan
+// would. Returns the files that are still being written.
+func (s *Scanner) importChanges(lib *database.Library, paths []string) []string {
+	var later []string
+	var removed []string
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			removed = append(removed, path)
+		case err != nil || info.IsDir():
+		case time.Since(info.ModTime()) < watchDebounce:
+			later = append(later, path)
+		default:
+			files = append(files, path)
+		}
+	}
+
+	if len(removed) > 0 {
+		s.markRemoved(lib, removed)
+	}
+	if len(files) == 0 {
+		return later
+	}
+
+	throttle := s.throttleFor(lib)
+	switch lib.Type {
+	case "movies":
+		for _, path := range files {
+			if !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+				continue
+			}
+			if movie, err := s.db.GetMovieByPath(path); err == nil {
+				s.movieChanged(movie, throttle)
+			} else if !s.db.IsMediaVersionPath(path) {
+				if info, err := os.Stat(path); err == nil {
+					s.importMovie(lib, path, info, throttle)
+				}
+			}
+		}
+	case "tv":
+		s.importEpisodeChanges(lib, files, throttle)
+	case "music":
+		for _, path := range files {
+			if !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+				continue
+			}
+			if _, err := s.db.GetTrackByPath(path); err == nil {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				s.importTrack(lib, path, info, throttle)
+			}
+		}
+	case "comics":
+		for _, path := range files {
+			if !comicExtensions[strings.ToLower(filepath.Ext(path))] {
+				continue
+			}
+			if _, err := s.db.GetBookByPath(path); err == nil {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				s.importBook(lib, path, info, throttle)
+			}
+		}
+	case "photos":
+		for _, path := range files {
+			if !photoExtensions[strings.ToLower(filepath.Ext(path))] {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				s.scanPhotoFile(lib, path, info, throttle)
+			}
+		}
+	case "books":
+		audioDirs := make(map[string]bool)
+		for _, path := range files {
+			ext := strings.ToLower(filepath.Ext(path))
+			if audiobookExtensions[ext] {
+				audioDirs[filepath.Dir(path)] = true
+				continue
+			}
+			if !bookExtensions[ext] {
+				continue
+			}
+			if _, err := s.db.GetBookByPath(path); err == nil {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil {
+				s.importBook(lib, path, info, throttle)
+			}
+		}
+		for dir := range audioDirs {
+			s.importAudiobookFolder(lib, dir, throttle)
+		}
+	}
+	return later
+}
+
+// importEpisodeChanges adds new episode files to their shows, fetching the
+// metadata of new shows, and updates changed ones
+func (s *Scanner) importEpisodeChanges(lib *database.Library, files []string, throttle *scanThrottle) {
+	showFiles := make(map[string][]string)
+	for _, path := range files {
+		if !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			continue
+		}
+		if ep, err := s.db.GetEpisodeByPath(path); err == nil {
+			s.episodeChanged(ep, throttle)
+			continue
+		}
+		if s.db.IsMediaVersionPath(path) {
+			continue
+		}
+		if showFolder, _, _ := s.findShowFolder(path, lib.Path); showFolder != "" {
+			showFiles[showFolder] = append(showFiles[showFolder], path)
+		}
+	}
+
+	modifiedSeasons := make(map[int64]bool)
+	for showFolder, paths := range showFiles {
+		show, folderInfo, isNewShow, err := s.showForFolder(lib, showFolder)
+		if err != nil {
+			continue
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if seasonID, err := s.importEpisode(lib, show, folderInfo.Title, path, info, throttle); err == nil {
+				modifiedSeasons[seasonID] = true
+			}
+		}
+		if isNewShow && s.meta != nil {
+			if err := s.meta.FetchShowMetadata(show); err != nil {
+				logger.Errorf("Failed to fetch metadata for %s: %v", folderInfo.Title, err)
+			}
+		}
+	}
+	s.detectIntros(modifiedSeasons)
+}
+
+// movieChanged updates a movie whose file was written to or came back
+func (s *Scanner) movieChanged(movie *database.Movie, throttle *scanThrottle) {
+	if err := s.db.ClearMovieMissing(movie.ID); err != nil {
+		logger.Errorf("Failed to clear missing status of %s: %v", movie.Title, err)
+	}
+	if info, err := os.Stat(movie.Path); err == nil && info.Size() != movie.Size {
+		s.db.UpdateMovieSize(movie.ID, info.Size())
+		s.fileReplaced("movie", movie.ID, movie.Path, throttle)
+	}
+}
+
+// episodeChanged updates an episode whose file was written to or came back
+func (s *Scanner) episodeChanged(ep *database.Episode, throttle *scanThrottle) {
+	if err := s.db.ClearEpisodeMissing(ep.ID); err != nil {
+		logger.Errorf("Failed to clear missing status of episode %d: %v", ep.ID, err)
+	}
+	if info, err := os.Stat(ep.Path); err == nil && info.Size() != ep.Size {
+		s.db.UpdateEpisodeSize(ep.ID, info.Size())
+		s.fileReplaced("episode", ep.ID, ep.Path, throttle)
+	}
+}
+
+// fileReplaced detects the quality of a movie or episode file that changed
+// again, and extracts its subtitles and chapters again in the background.
+// Its storyboard is dropped for the Trickplay task to make again.
+func (s *Scanner) fileReplaced(mediaType string, mediaID int64, path string, throttle *scanThrottle) {
+	s.detectAndStoreQuality(mediaID, mediaType, filepath.Base(path), path)
+	s.RemoveTrickplay(mediaType, mediaID)
+	s.ClearExtractedSubtitles(mediaType, mediaID, path)
+	throttle.background(func() {
+		s.ExtractSubtitleTracks(context.Background(), mediaType, mediaID, path)
+		s.ExtractChapters(mediaType, mediaID, path)
+	})
+}
+
+// markRemoved marks the movies and episodes whose files were removed, or
+// were in removed folders, as missing. They're deleted by a later scan once
+// the grace period runs out. Photos, which have no grace period, are removed
+// right away. Music and books are left to scans.
+func (s *Scanner) markRemoved(lib *database.Library, paths []string) {
+	gone := func(path string) bool {
+		for _, removed := range paths {
+			if isUnder(path, removed) {
+				_, err := os.Stat(path)
+				return os.IsNotExist(err)
+			}
+		}
+		return false
+	}
+
+	switch lib.Type {
+	case "movies":
+		movies, err := s.db.GetMoviesByLibrary(lib.ID)
+		if err != nil {
+			logger.Errorf("Failed to get movies of %s: %v", lib.Name, err)
+			return
+		}
+		for _, movie := range movies {
+			if movie.Path == "" || movie.MissingSince != nil || !gone(movie.Path) {
+				continue
+			}
+			if s.promoteVersion("movie", movie.ID) {
+				continue
+			}
+			if err := s.db.MarkMovieMissing(movie.ID); err == nil {
+				logger.Infof("Marked movie as missing: %s", movie.Title)
+			}
+		}
+	case "tv":
+		episodes, err := s.db.GetEpisodesByLibrary(lib.ID)
+		if err != nil {
+			logger.Errorf("Failed to get episodes of %s: %v", lib.Name, err)
+			return
+		}
+		for _, ep := range episodes {
+			if ep.Path == "" || ep.MissingSince != nil || !gone(ep.Path) {
+				continue
+			}
+			if s.promoteVersion("episode", ep.ID) {
+				continue
+			}
+			if err := s.db.MarkEpisodeMissing(ep.ID); err == nil {
+				logger.Infof("Marked episode as missing: E%02d", ep.EpisodeNumber)
+			}
+		}
+	case "photos":
+		photos, err := s.db.GetPhotoPaths(lib.ID)
+		if err != nil {
+			logger.Errorf("Failed to get photos of %s: %v", lib.Name, err)
+			return
+		}
+		for path, id := range photos {
+			if gone(path) {
+				s.removePhoto(id)
+			}
+		}
+	}
+}
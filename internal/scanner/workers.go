@@ -0,0 +1,229 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Parallel scanning
+//
+// Movie and TV libraries are scanned by a pool of workers, each looking at
+// one movie file or show folder at a time. Files already in the library
+// whose size and modification time haven't changed since the last scan are
+// skipped without touching the database; the sizes and times of the rest
+// are written in batches, a transaction per batch. New files are added in
+// batches too: movies on one goroutine, so two files of the same movie
+// can't both add it, and episodes by their show folder's worker. Their
+// metadata is then fetched by the pool. Throttled libraries are scanned by
+// one worker.
+
+// SettingScanWorkers is how many files or show folders are scanned at once
+const SettingScanWorkers = "scan_workers"
+
+// Bounds of the scan workers setting
+const (
+	defaultScanWorkers = 4
+	maxScanWorkers     = 16
+)
+
+// batchSize is how many new files, or file sizes and times, are written per
+// transaction
+const batchSize = 200
+
+// ValidateWorkersSetting checks the scan workers setting. Other settings are
+// always valid.
+func ValidateWorkersSetting(key, value string) error {
+	if key != SettingScanWorkers {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > maxScanWorkers {
+		return fmt.Errorf("Scan workers must be between 1 and %d", maxScanWorkers)
+	}
+	return nil
+}
+
+// scanWorkers returns how many workers scan a library
+func (s *Scanner) scanWorkers(throttle *scanThrottle) int {
+	if throttle != nil {
+		return 1
+	}
+	if v, err := s.db.GetSetting(SettingScanWorkers); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 1 && n <= maxScanWorkers {
+			return n
+		}
+	}
+	return defaultScanWorkers
+}
+
+// forEach calls fn with 0 to n-1 on the given number of workers, handing
+// out no more once ctx is cancelled, and waits for them to finish
+func forEach(ctx context.Context, workers, n int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+
+hand:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break hand
+		}
+	}
+	close(next)
+	wg.Wait()
+}
+
+// scanFile is a video file found by a scan
+type scanFile struct {
+	path string
+	info os.FileInfo
+}
+
+// walkVideoFiles returns the video files under a library's folder. Symlinks
+// are followed so their sizes and times are the files'.
+func walkVideoFiles(root string) []scanFile {
+	var files []scanFile
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil {
+				return nil
+			}
+		}
+		files = append(files, scanFile{path: path, info: info})
+		return nil
+	})
+	return files
+}
+
+// scanTally counts what a scan did and reports its progress as workers
+// finish files
+type scanTally struct {
+	s        *Scanner
+	library  string
+	progress func(current, total int)
+
+	mu                      sync.Mutex
+	current, total          int
+	added, skipped, errored int
+}
+
+func (s *Scanner) newTally(library string, total int, progress func(current, total int)) *scanTally {
+	return &scanTally{s: s, library: library, total: total, progress: progress}
+}
+
+// step records that a file was looked at
+func (t *scanTally) step() {
+	t.mu.Lock()
+	t.current++
+	current := t.current
+	t.mu.Unlock()
+
+	t.s.setProgress(t.library, "scanning", current, t.total)
+	if t.progress != nil {
+		t.progress(current, t.total)
+	}
+}
+
+func (t *scanTally) add()  { t.mu.Lock(); t.added++; t.mu.Unlock() }
+func (t *scanTally) skip() { t.mu.Lock(); t.skipped++; t.mu.Unlock() }
+func (t *scanTally) fail() { t.mu.Lock(); t.errored++; t.mu.Unlock() }
+
+// setResult records the scan's counts as the last scan's result
+func (t *scanTally) setResult() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.s.setResult(t.library, t.added, t.skipped, t.errored)
+}
+
+// stampBatch collects file sizes and times, writing them a batch at a time
+type stampBatch struct {
+	db        *database.Database
+	mediaType string
+
+	mu      sync.Mutex
+	pending []database.FileStamp
+}
+
+func (s *Scanner) newStampBatch(mediaType string) *stampBatch {
+	return &stampBatch{db: s.db, mediaType: mediaType}
+}
+
+// add queues a file's size and time, writing the batch once it's full
+func (b *stampBatch) add(stamp database.FileStamp) {
+	b.mu.Lock()
+	b.pending = append(b.pending, stamp)
+	if len(b.pending) < batchSize {
+		b.mu.Unlock()
+		return
+	}
+	stamps := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	b.write(stamps)
+}
+
+// flush writes what's queued
+func (b *stampBatch) flush() {
+	b.mu.Lock()
+	stamps := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	b.write(stamps)
+}
+
+func (b *stampBatch) write(stamps []database.FileStamp) {
+	if err := b.db.StampFiles(b.mediaType, stamps); err != nil {
+		logger.Errorf("Failed to record the sizes of %d %s files: %v", len(stamps), b.mediaType, err)
+	}
+}
+
+// knownFile reports whether a file found by a scan is already in the
+// library. Files whose size or modification time changed have them
+// recorded and their quality and chapters detected again, unless the
+// library is throttled to leave them alone.
+func (s *Scanner) knownFile(known map[string]database.LibraryFile, mediaType string, file scanFile, stamps *stampBatch, throttle *scanThrottle) bool {
+	f, ok := known[file.path]
+	if !ok {
+		return false
+	}
+	if f.ID == 0 || throttle.skipUnchanged() {
+		return true
+	}
+
+	size, modTime := file.info.Size(), file.info.ModTime().Unix()
+	if size == f.Size && modTime == f.ModTime {
+		return true
+	}
+	stamps.add(database.FileStamp{ID: f.ID, Size: size, ModTime: modTime})
+	// Files scanned before their times were recorded have only changed if
+	// their size has
+	if size != f.Size || f.ModTime != 0 {
+		s.fileReplaced(mediaType, f.ID, file.path, throttle)
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+)
+
+// Search activity
+//
+// Each automatic search is recorded as a media event, with the releases it
+// considered and why they were passed over, so admins can see why a title
+// downloaded the version it did.
+
+// maxConsideredReleases caps how many releases a search event keeps
+const maxConsideredReleases = 25
+
+// consideredRelease is a search result and why it was or wasn't acceptable
+type consideredRelease struct {
+	Title    string `json:"title"`
+	Indexer  string `json:"indexer,omitempty"`
+	Score    int    `json:"score"`
+	Reason   string `json:"reason,omitempty"`   // Empty when acceptable
+	Strategy string `json:"strategy,omitempty"` // The strategy of the query that found it
+}
+
+// searchActivity is what one search for a wanted item did
+type searchActivity struct {
+	Outcome    string // grabbed, delayed, grab failed, no acceptable release, no results, auto-grab disabled, failed
+	Error      string
+	Results    int
+	PresetID   *int64
+	Release    string
+	Until      *time.Time
+	Considered []consideredRelease
+	Queries    []QueryAttempt
+}
+
+// consideredReleases scores search results with a preset and records why
+// each one was or wasn't acceptable, best first
+func (s *Scheduler) consideredReleases(results []indexer.SearchResult, presetID *int64, runtime *mediaRuntime, firstSeen map[string]time.Time, item *database.WantedItem, libraryID int64) []consideredRelease {
+	scored := s.scorePresetResults(results, presetID, runtime, firstSeen, false)
+	if len(scored) > maxConsideredReleases {
+		scored = scored[:maxConsideredReleases]
+	}
+	considered := make([]consideredRelease, 0, len(scored))
+	for i := range scored {
+		considered = append(considered, consideredRelease{
+			Title:    scored[i].Title,
+			Indexer:  scored[i].IndexerName,
+			Score:    scored[i].TotalScore,
+			Reason:   s.candidateRejection(&scored[i], item, libraryID),
+			Strategy: scored[i].QueryStrategy,
+		})
+	}
+	return considered
+}
+
+// recordSearchActivity adds a finished search to the item's activity
+func (s *Scheduler) recordSearchActivity(item *database.WantedItem, activity *searchActivity) {
+	var summary string
+	switch activity.Outcome {
+	case "grabbed":
+		summary = "Searched and grabbed " + activity.Release
+	case "delayed":
+		summary = "Searched and held " + activity.Release + " for a delay profile"
+	case "failed":
+		summary = "Search failed: " + activity.Error
+	default:
+		summary = fmt.Sprintf("Searched, %d results: %s", activity.Results, activity.Outcome)
+	}
+
+	details := map[string]interface{}{
+		"outcome": activity.Outcome,
+		"results": activity.Results,
+		"upgrade": item.IsUpgrade,
+	}
+	if activity.Error != "" {
+		details["error"] = activity.Error
+	}
+	if activity.Release != "" {
+		details["release"] = activity.Release
+	}
+	if activity.PresetID != nil {
+		details["presetId"] = *activity.PresetID
+		if preset, err := s.db.GetQualityPreset(*activity.PresetID); err == nil {
+			details["preset"] = preset.Name
+		}
+	}
+	if activity.Until != nil {
+		details["availableAt"] = activity.Until.Format(time.RFC3339)
+	}
+	if len(activity.Considered) > 0 {
+		details["considered"] = activity.Considered
+	}
+	if len(activity.Queries) > 0 {
+		details["queries"] = activity.Queries
+	}
+
+	mediaID := item.TmdbID
+	if err := s.db.RecordMediaEvent(&database.MediaEvent{
+		EventType: database.MediaEventSearch,
+		MediaType: item.Type,
+		MediaID:   &mediaID,
+		Summary:   summary,
+		Details:   details,
+	}); err != nil {
+		logger.Errorf("Scheduler: failed to record search for %s: %v", item.Title, err)
+	}
+}
@@ -0,0 +1,350 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+	"github.com/outpost/outpost/internal/parser"
+	"github.com/outpost/outpost/internal/timezone"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Airing shows
+//
+// Shows followed while they air get each new episode searched for soon
+// after it airs instead of at the next regular search. TMDB only gives the
+// day an episode airs, so each followed show has the time of day it airs;
+// the first search runs airing_search_delay minutes after that. Searches
+// that find nothing are retried at doubling intervals for two days, and the
+// show's followers are notified once the episode is in the library.
+
+// SettingAiringSearchDelay is how many minutes after an episode airs it's
+// first searched for
+const SettingAiringSearchDelay = "airing_search_delay"
+
+const (
+	defaultAiringSearchDelay = 30 * time.Minute
+	// airingCheckInterval is how often due episode searches are run
+	airingCheckInterval = 5 * time.Minute
+	// airingPlanInterval is how often TMDB is asked for followed shows'
+	// latest and next episodes
+	airingPlanInterval = time.Hour
+	// airingSearchPeriod is how long after airing an episode is searched for
+	airingSearchPeriod = 48 * time.Hour
+	// airingRetryMin and airingRetryMax bound the wait between searches,
+	// which doubles with each one that finds nothing
+	airingRetryMin = 15 * time.Minute
+	airingRetryMax = 6 * time.Hour
+	// airingWatchPeriod is how long a grabbed episode is watched for to come
+	// into the library
+	airingWatchPeriod = 7 * 24 * time.Hour
+)
+
+// EpisodeScheduleLookup finds the latest episode of a show to have aired and
+// the next one due
+type EpisodeScheduleLookup interface {
+	GetEpisodeSchedule(tmdbID int64) (last, next *tmdb.AiringEpisode, err error)
+}
+
+// SetEpisodeScheduleLookup sets where followed shows' episode air dates come
+// from. Without one, no episodes are searched for as they air.
+func (s *Scheduler) SetEpisodeScheduleLookup(lookup EpisodeScheduleLookup) {
+	s.episodeSchedule = lookup
+}
+
+// ParseAirTime parses the HH:MM time of day a show airs at
+func ParseAirTime(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Air time must be HH:MM, like 21:00")
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// ValidateAiringSetting checks the airing search delay. Other settings are
+// always valid.
+func ValidateAiringSetting(key, value string) error {
+	if key == SettingAiringSearchDelay {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 || n > 24*60 {
+			return fmt.Errorf("Airing search delay must be a whole number of minutes from 0 to 1440")
+		}
+	}
+	return nil
+}
+
+// airingSearchDelay returns how long after airing episodes are first searched
+func (s *Scheduler) airingSearchDelay() time.Duration {
+	value, err := s.db.GetSetting(SettingAiringSearchDelay)
+	if err != nil || value == "" {
+		return defaultAiringSearchDelay
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return defaultAiringSearchDelay
+	}
+	return time.Duration(n) * time.Minute
+}
+
+// airingRetryAfter is the wait before searching again after the given
+// number of searches found nothing
+func airingRetryAfter(attempts int) time.Duration {
+	wait := airingRetryMin
+	for i := 1; i < attempts && wait < airingRetryMax; i++ {
+		wait *= 2
+	}
+	if wait > airingRetryMax {
+		wait = airingRetryMax
+	}
+	return wait
+}
+
+// episodeAirTime returns when an episode airs, from its air date and the
+// show's time of day in the server's time zone
+func episodeAirTime(airDate, airTime string) (time.Time, bool) {
+	date, ok := parseReleaseDate(airDate)
+	if !ok {
+		return time.Time{}, false
+	}
+	hour, minute, err := ParseAirTime(airTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, timezone.Location()), true
+}
+
+// runAiringJob plans and runs the searches for followed shows' new episodes
+func (s *Scheduler) runAiringJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(airingCheckInterval)
+	defer ticker.Stop()
+
+	var plannedAt time.Time
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			if time.Since(plannedAt) >= airingPlanInterval {
+				s.planAiringSearches()
+				plannedAt = time.Now()
+			}
+			s.runAiringSearches()
+		}
+	}
+}
+
+// planAiringSearches schedules searches for the episodes of followed shows
+// that have just aired or air next
+func (s *Scheduler) planAiringSearches() {
+	if s.episodeSchedule == nil {
+		return
+	}
+	shows, err := s.db.GetAiringShows()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get airing shows: %v", err)
+		return
+	}
+
+	delay := s.airingSearchDelay()
+	for _, show := range shows {
+		if show.TmdbID == nil {
+			continue
+		}
+		last, next, err := s.episodeSchedule.GetEpisodeSchedule(*show.TmdbID)
+		if err != nil {
+			logger.Errorf("Scheduler: failed to get episode schedule of %s: %v", show.Title, err)
+			continue
+		}
+
+		for _, ep := range []*tmdb.AiringEpisode{last, next} {
+			if ep == nil || ep.SeasonNumber == 0 {
+				continue
+			}
+			airAt, ok := episodeAirTime(ep.AirDate, show.AirTime)
+			if !ok || time.Since(airAt) > airingSearchPeriod {
+				continue
+			}
+			added, err := s.db.AddAiringSearch(&database.AiringSearch{
+				ShowID:        show.ShowID,
+				SeasonNumber:  ep.SeasonNumber,
+				EpisodeNumber: ep.EpisodeNumber,
+				AirAt:         airAt,
+				NextSearchAt:  airAt.Add(delay),
+			})
+			if err != nil {
+				logger.Errorf("Scheduler: failed to schedule search for %s S%02dE%02d: %v", show.Title, ep.SeasonNumber, ep.EpisodeNumber, err)
+			} else if added {
+				logger.Infof("Scheduler: %s S%02dE%02d airs %s - searching from %s", show.Title, ep.SeasonNumber, ep.EpisodeNumber,
+					airAt.Format("2006-01-02 15:04"), airAt.Add(delay).Format("2006-01-02 15:04"))
+			}
+		}
+	}
+
+	if err := s.db.DeleteAiringSearchesBefore(time.Now().Add(-airingWatchPeriod)); err != nil {
+		logger.Errorf("Scheduler: failed to clean up airing searches: %v", err)
+	}
+}
+
+// runAiringSearches notifies followers of episodes that have come in, and
+// searches for those that are due
+func (s *Scheduler) runAiringSearches() {
+	searches, err := s.db.GetPendingAiringSearches(time.Now().Add(-airingWatchPeriod))
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get airing searches: %v", err)
+		return
+	}
+	autoSearch, _ := s.db.GetSetting("scheduler_auto_search")
+
+	for i := range searches {
+		search := &searches[i]
+		show, err := s.db.GetShow(search.ShowID)
+		if err != nil || show.TmdbID == nil {
+			continue
+		}
+
+		if ep, _ := s.db.GetEpisodeByShowSeasonEpisode(show.ID, search.SeasonNumber, search.EpisodeNumber); ep != nil && ep.Path != "" {
+			s.airingEpisodeAvailable(show, search)
+			continue
+		}
+
+		now := time.Now()
+		if search.GrabbedRelease != nil || now.Before(search.NextSearchAt) ||
+			now.Sub(search.AirAt) > airingSearchPeriod || autoSearch != "true" {
+			continue
+		}
+
+		release, retryAt, err := s.searchAiringEpisode(show, search.SeasonNumber, search.EpisodeNumber)
+		search.Attempts++
+		search.LastSearchedAt = &now
+		switch {
+		case err != nil:
+			logger.Errorf("Scheduler: search for %s S%02dE%02d failed: %v", show.Title, search.SeasonNumber, search.EpisodeNumber, err)
+			search.NextSearchAt = now.Add(airingRetryAfter(search.Attempts))
+		case release != "":
+			search.GrabbedRelease = &release
+		case !retryAt.IsZero():
+			search.NextSearchAt = retryAt
+		default:
+			search.NextSearchAt = now.Add(airingRetryAfter(search.Attempts))
+			logger.Infof("Scheduler: nothing yet for %s S%02dE%02d - searching again at %s", show.Title,
+				search.SeasonNumber, search.EpisodeNumber, search.NextSearchAt.Format("2006-01-02 15:04"))
+		}
+		if err := s.db.RecordAiringSearch(search); err != nil {
+			logger.Errorf("Scheduler: failed to record search for %s S%02dE%02d: %v", show.Title, search.SeasonNumber, search.EpisodeNumber, err)
+		}
+
+		// Small delay between searches to avoid hammering indexers
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// airingEpisodeAvailable marks a searched-for episode as in the library and
+// notifies the show's followers
+func (s *Scheduler) airingEpisodeAvailable(show *database.Show, search *database.AiringSearch) {
+	if err := s.db.MarkAiringSearchAvailable(show.ID, search.SeasonNumber, search.EpisodeNumber); err != nil {
+		logger.Errorf("Scheduler: failed to mark %s S%02dE%02d available: %v", show.Title, search.SeasonNumber, search.EpisodeNumber, err)
+		return
+	}
+	logger.Infof("Scheduler: %s S%02dE%02d is in the library", show.Title, search.SeasonNumber, search.EpisodeNumber)
+	if s.notifier == nil {
+		return
+	}
+
+	followers, err := s.db.GetAiringFollowers(show.ID)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get followers of %s: %v", show.Title, err)
+		return
+	}
+	for _, userID := range followers {
+		if err := s.notifier.NotifyEpisodeAvailable(userID, show.Title, search.SeasonNumber, search.EpisodeNumber, show.ID, show.PosterPath); err != nil {
+			logger.Errorf("Scheduler: failed to notify user %d of %s S%02dE%02d: %v", userID, show.Title, search.SeasonNumber, search.EpisodeNumber, err)
+		}
+	}
+}
+
+// searchAiringEpisode searches for a single episode and grabs the best
+// release of it. It returns the release grabbed, or "" when there's none to
+// grab yet, along with when to try again if a delay profile holds the best
+// one back.
+func (s *Scheduler) searchAiringEpisode(show *database.Show, season, episode int) (string, time.Time, error) {
+	if s.shouldPauseDownloads() {
+		return "", time.Time{}, nil
+	}
+	tmdbID := *show.TmdbID
+
+	// A wanted show's preset picks the release; otherwise any is scored
+	item, _ := s.db.GetWantedByTmdb("show", tmdbID)
+	if item == nil {
+		item = &database.WantedItem{Type: "show", TmdbID: tmdbID, Title: show.Title, Year: show.Year, PosterPath: show.PosterPath}
+	}
+
+	params := indexer.SearchParams{
+		Query:      show.Title,
+		Type:       "tvsearch",
+		Limit:      50,
+		Categories: database.GetCategoriesForMediaType("tv"),
+		TmdbID:     strconv.FormatInt(tmdbID, 10),
+		ImdbID:     s.lookupImdbID("show", tmdbID),
+		TvdbID:     s.lookupTvdbID(tmdbID),
+		Season:     season,
+		Episode:    episode,
+	}
+	results, err := s.runSearch(params, s.getIndexerIDsForMediaType("show"))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Only releases of this episode, not season packs or other episodes
+	var matching []indexer.SearchResult
+	titles := make([]string, 0, len(results))
+	for _, result := range results {
+		parsed := parser.Parse(result.Title)
+		last := parsed.Episode
+		if parsed.EpisodeEnd > last {
+			last = parsed.EpisodeEnd
+		}
+		if parsed.Season != season || parsed.Episode == 0 || parsed.Episode > episode || last < episode {
+			continue
+		}
+		matching = append(matching, result)
+		titles = append(titles, result.Title)
+	}
+	logger.Infof("Scheduler: %d/%d releases are %s S%02dE%02d", len(matching), len(results), show.Title, season, episode)
+	if len(matching) == 0 {
+		return "", time.Time{}, nil
+	}
+
+	if autoGrab, _ := s.db.GetSetting("scheduler_auto_grab"); autoGrab != "true" {
+		logger.Infof("Scheduler: found %d releases of %s S%02dE%02d (auto-grab disabled)", len(matching), show.Title, season, episode)
+		return "", time.Time{}, nil
+	}
+
+	firstSeen, err := s.db.RecordReleaseSightings(titles)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to record release sightings: %v", err)
+	}
+	libraryID := s.libraryIDForItem(item)
+	scored := s.scoreResultsWithPreset(matching, item.QualityPresetID, s.lookupRuntime(item), firstSeen)
+
+	for i := range scored {
+		result := &scored[i]
+		if reason := s.candidateRejection(result, item, libraryID); reason != "" {
+			continue
+		}
+		if delay, availableAt := s.shouldDelayGrab(result, libraryID); delay {
+			logger.Infof("Scheduler: holding %s back until %s for its delay profile", result.Title, availableAt.Format(time.RFC3339))
+			return "", availableAt, nil
+		}
+		if err := s.grabRelease(result, "show", tmdbID); err != nil {
+			logger.Errorf("Scheduler: grab failed for %s, trying next: %v", result.Title, err)
+			continue
+		}
+		logger.Infof("Scheduler: grabbed %s for %s S%02dE%02d", result.Title, show.Title, season, episode)
+		return result.Title, time.Time{}, nil
+	}
+	return "", time.Time{}, nil
+}
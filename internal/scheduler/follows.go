@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/tmdb"
+)
+
+// Follows
+//
+// The Follows task tells profiles about what they follow: new episodes of
+// followed shows and new movies with followed people in them as they come
+// into the library, and, once a day per person, movies with them in that
+// were released digitally since the follow, whether in the library or not.
+
+const (
+	// followReleaseCheckInterval is how often a followed person's movies
+	// are looked up for digital releases
+	followReleaseCheckInterval = 24 * time.Hour
+	// followReleaseWindow is how long before now a movie's release date can
+	// be for it to still be checked for a digital release
+	followReleaseWindow = 365 * 24 * time.Hour
+)
+
+// PersonCreditsLookup finds the movies a person acted in
+type PersonCreditsLookup interface {
+	GetPersonMovieCredits(personID int64) ([]tmdb.PersonCreditCast, error)
+}
+
+// SetPersonCreditsLookup sets where followed people's movies come from.
+// Without one, followers of people are only told about movies coming into
+// the library.
+func (s *Scheduler) SetPersonCreditsLookup(lookup PersonCreditsLookup) {
+	s.personCredits = lookup
+}
+
+// runFollowTask tells followers about new episodes and movies, returning how
+// many notifications were sent
+func (s *Scheduler) runFollowTask() int {
+	if s.notifier == nil {
+		return 0
+	}
+	follows, err := s.db.GetAllFollows()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get follows: %v", err)
+		return 0
+	}
+
+	users := make(map[int64]*database.User)
+	user := func(id int64) *database.User {
+		if u, ok := users[id]; ok {
+			return u
+		}
+		u, err := s.db.GetUserByID(id)
+		if err != nil {
+			u = nil
+		}
+		users[id] = u
+		return u
+	}
+
+	sent := 0
+	for i := range follows {
+		follow := &follows[i]
+		u := user(follow.UserID)
+		if u == nil || !u.Active() {
+			continue
+		}
+		switch follow.Kind {
+		case database.FollowShow:
+			sent += s.notifyFollowedEpisodes(follow, u)
+		case database.FollowPerson:
+			sent += s.notifyFollowedMovies(follow, u)
+			sent += s.notifyFollowedReleases(follow)
+		}
+	}
+	return sent
+}
+
+// notifyFollowedEpisodes tells a show's follower about its new episodes
+func (s *Scheduler) notifyFollowedEpisodes(follow *database.Follow, user *database.User) int {
+	episodes, err := s.db.GetNewFollowedEpisodes(follow)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get new episodes of %s: %v", follow.Name, err)
+		return 0
+	}
+
+	sent := 0
+	for _, ep := range episodes {
+		if added, err := s.db.AddFollowNotice(follow.ID, "episode:"+strconv.FormatInt(ep.ID, 10)); err != nil || !added {
+			continue
+		}
+		if !user.CanAccessLibrary(ep.LibraryID) {
+			continue
+		}
+		if err := s.notifier.NotifyEpisodeAvailable(follow.UserID, ep.ShowTitle, ep.SeasonNumber, ep.EpisodeNumber, ep.ShowID, ep.PosterPath); err != nil {
+			logger.Errorf("Scheduler: failed to notify user %d of %s S%02dE%02d: %v", follow.UserID, ep.ShowTitle, ep.SeasonNumber, ep.EpisodeNumber, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// notifyFollowedMovies tells a person's follower about new movies in the
+// library with them in
+func (s *Scheduler) notifyFollowedMovies(follow *database.Follow, user *database.User) int {
+	movies, err := s.db.GetNewFollowedMovies(follow)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get new movies with %s: %v", follow.Name, err)
+		return 0
+	}
+
+	sent := 0
+	for _, movie := range movies {
+		if added, err := s.db.AddFollowNotice(follow.ID, "movie:"+strconv.FormatInt(movie.ID, 10)); err != nil || !added {
+			continue
+		}
+		if !user.CanAccessLibrary(movie.LibraryID) {
+			continue
+		}
+		if err := s.notifier.NotifyFollowedPersonMovie(follow.UserID, follow.Name, movie.Title, movie.ID, movie.PosterPath); err != nil {
+			logger.Errorf("Scheduler: failed to notify user %d of %s: %v", follow.UserID, movie.Title, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// notifyFollowedReleases tells a person's follower about movies with them in
+// released digitally since the follow, looking them up once a day
+func (s *Scheduler) notifyFollowedReleases(follow *database.Follow) int {
+	if s.personCredits == nil || s.releaseDates == nil {
+		return 0
+	}
+	now := time.Now()
+	if follow.ReleasesCheckedAt != nil && now.Sub(*follow.ReleasesCheckedAt) < followReleaseCheckInterval {
+		return 0
+	}
+
+	credits, err := s.personCredits.GetPersonMovieCredits(follow.TmdbID)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get movies with %s: %v", follow.Name, err)
+		return 0
+	}
+	if err := s.db.SetFollowReleasesChecked(follow.ID, now); err != nil {
+		logger.Errorf("Scheduler: failed to record release check of %s: %v", follow.Name, err)
+	}
+
+	followed := time.Date(follow.CreatedAt.Year(), follow.CreatedAt.Month(), follow.CreatedAt.Day(), 0, 0, 0, 0, time.UTC)
+	sent := 0
+	for _, credit := range credits {
+		// Only recent and upcoming movies can have come out since the follow
+		if date, ok := parseReleaseDate(credit.ReleaseDate); ok && now.Sub(date) > followReleaseWindow {
+			continue
+		}
+		item := "digital:" + strconv.FormatInt(credit.ID, 10)
+		if done, err := s.db.HasFollowNotice(follow.ID, item); err != nil || done {
+			continue
+		}
+		_, digital, err := s.releaseDates.GetMovieReleaseDates(credit.ID)
+		if err != nil {
+			continue
+		}
+		release, ok := parseReleaseDate(digital)
+		if !ok || release.After(now) || release.Before(followed) {
+			continue
+		}
+		if added, err := s.db.AddFollowNotice(follow.ID, item); err != nil || !added {
+			continue
+		}
+
+		var poster *string
+		if credit.PosterPath != "" {
+			poster = &credit.PosterPath
+		}
+		if err := s.notifier.NotifyFollowedPersonRelease(follow.UserID, follow.Name, credit.Title, credit.ID, poster); err != nil {
+			logger.Errorf("Scheduler: failed to notify user %d of %s: %v", follow.UserID, credit.Title, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// runFollowJob runs the Follows task on its interval
+func (s *Scheduler) runFollowJob() {
+	defer s.wg.Done()
+
+	interval := 15 * time.Minute
+	if task, err := s.db.GetTaskByName("Follows"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Follows", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Follows", tick.Add(interval))
+			s.executeTaskByName("Follows")
+		}
+	}
+}
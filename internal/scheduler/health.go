@@ -0,0 +1,47 @@
+package scheduler
+
+import "time"
+
+// HealthChecker runs the health checks, telling admins about checks that
+// start failing
+type HealthChecker interface {
+	CheckAll() int
+}
+
+// SetHealthChecker sets what the Health Check task runs. Without one the
+// task does nothing.
+func (s *Scheduler) SetHealthChecker(health HealthChecker) {
+	s.health = health
+}
+
+// runHealthTask runs every health check, returning how many are failing
+func (s *Scheduler) runHealthTask() int {
+	if s.health == nil {
+		return 0
+	}
+	return s.health.CheckAll()
+}
+
+// runHealthJob runs the Health Check task on its interval, so failures are
+// noticed without anyone opening the health page
+func (s *Scheduler) runHealthJob() {
+	defer s.wg.Done()
+
+	interval := 15 * time.Minute
+	if task, err := s.db.GetTaskByName("Health Check"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Health Check", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Health Check", tick.Add(interval))
+			s.executeTaskByName("Health Check")
+		}
+	}
+}
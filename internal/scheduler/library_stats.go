@@ -0,0 +1,40 @@
+package scheduler
+
+import "time"
+
+// runLibraryStatsTask records today's size of every library, returning how
+// many libraries were recorded
+func (s *Scheduler) runLibraryStatsTask() int {
+	n, err := s.db.SnapshotLibraryStats()
+	if err != nil {
+		logger.Errorf("Failed to record library stats: %v", err)
+		return 0
+	}
+	return n
+}
+
+// runLibraryStatsJob runs the Library Stats task on its interval. It also
+// runs at start, so a server restarted more often than the interval still
+// records every day.
+func (s *Scheduler) runLibraryStatsJob() {
+	defer s.wg.Done()
+
+	interval := time.Hour
+	if task, err := s.db.GetTaskByName("Library Stats"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Library Stats", time.Now().Add(interval))
+	s.executeTaskByName("Library Stats")
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Library Stats", tick.Add(interval))
+			s.executeTaskByName("Library Stats")
+		}
+	}
+}
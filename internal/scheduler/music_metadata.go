@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Music metadata
+//
+// The Music Metadata task looks up artists and albums on MusicBrainz that
+// haven't been looked up yet, artists first so albums can be searched under
+// their artist's MusicBrainz ID. MusicBrainz allows a request a second, so a
+// run looks up at most musicMetadataRunLimit of each.
+
+// musicMetadataRunLimit is how many artists, and how many albums, a run
+// looks up at most
+const musicMetadataRunLimit = 50
+
+// MusicMetadataFetcher looks up artists and albums on MusicBrainz
+type MusicMetadataFetcher interface {
+	MusicBrainzEnabled() bool
+	FetchArtistMetadata(artist *database.Artist) error
+	FetchAlbumMetadata(album *database.Album) error
+}
+
+// SetMusicMetadata sets what the Music Metadata task looks music up with.
+// Without one the task does nothing.
+func (s *Scheduler) SetMusicMetadata(music MusicMetadataFetcher) {
+	s.music = music
+}
+
+// runMusicMetadataTask looks up artists and albums that haven't been,
+// returning how many were looked up
+func (s *Scheduler) runMusicMetadataTask() int {
+	if s.music == nil || !s.music.MusicBrainzEnabled() {
+		return 0
+	}
+
+	processed := 0
+	artists, err := s.db.GetArtistsWithoutMetadata(musicMetadataRunLimit)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get artists to look up: %v", err)
+		return 0
+	}
+	for i := range artists {
+		select {
+		case <-s.stopChan:
+			return processed
+		default:
+		}
+		// A failed lookup is tried again next run; MusicBrainz being
+		// unreachable fails every other one too
+		if err := s.music.FetchArtistMetadata(&artists[i]); err != nil {
+			logger.Errorf("Scheduler: failed to look up artist %s: %v", artists[i].Name, err)
+			return processed
+		}
+		processed++
+	}
+
+	albums, err := s.db.GetAlbumsWithoutMetadata(musicMetadataRunLimit)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get albums to look up: %v", err)
+		return processed
+	}
+	for i := range albums {
+		select {
+		case <-s.stopChan:
+			return processed
+		default:
+		}
+		if err := s.music.FetchAlbumMetadata(&albums[i]); err != nil {
+			logger.Errorf("Scheduler: failed to look up album %s: %v", albums[i].Title, err)
+			return processed
+		}
+		processed++
+	}
+	return processed
+}
+
+// runMusicMetadataJob runs the Music Metadata task on its interval
+func (s *Scheduler) runMusicMetadataJob() {
+	defer s.wg.Done()
+
+	interval := 60 * time.Minute
+	if task, err := s.db.GetTaskByName("Music Metadata"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Music Metadata", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Music Metadata", tick.Add(interval))
+			s.executeTaskByName("Music Metadata")
+		}
+	}
+}
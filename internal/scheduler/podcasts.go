@@ -0,0 +1,46 @@
+package scheduler
+
+import "time"
+
+// PodcastRefresher refreshes podcast feeds and downloads new episodes
+type PodcastRefresher interface {
+	RefreshAll() int
+}
+
+// SetPodcasts sets what the Podcast Refresh task refreshes. Without one the
+// task does nothing.
+func (s *Scheduler) SetPodcasts(podcasts PodcastRefresher) {
+	s.podcasts = podcasts
+}
+
+// runPodcastTask refreshes every podcast
+func (s *Scheduler) runPodcastTask() int {
+	if s.podcasts == nil {
+		return 0
+	}
+	return s.podcasts.RefreshAll()
+}
+
+// runPodcastJob runs the Podcast Refresh task on its interval. The interval
+// is read at startup like the search and RSS intervals.
+func (s *Scheduler) runPodcastJob() {
+	defer s.wg.Done()
+
+	interval := 60 * time.Minute
+	if task, err := s.db.GetTaskByName("Podcast Refresh"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Podcast Refresh", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Podcast Refresh", tick.Add(interval))
+			s.executeTaskByName("Podcast Refresh")
+		}
+	}
+}
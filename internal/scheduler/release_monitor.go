@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/timezone"
+)
+
+const (
+	// unreleasedSearchInterval is how often movies that aren't out digitally
+	// yet are searched anyway, in case the release date is wrong
+	unreleasedSearchInterval = 24 * time.Hour
+	// releaseCheckInterval is how often wanted movies are checked for a
+	// digital release that has just passed
+	releaseCheckInterval = time.Hour
+	// newReleaseWindow is how long after its digital release a movie still
+	// gets a targeted search
+	newReleaseWindow = 7 * 24 * time.Hour
+)
+
+// ReleaseDateLookup fetches a movie's US theatrical and digital release dates,
+// and when a show first aired
+type ReleaseDateLookup interface {
+	GetMovieReleaseDates(tmdbID int64) (theatrical, digital string, err error)
+	GetShowFirstAirDate(tmdbID int64) (string, error)
+}
+
+// SetReleaseDateLookup sets where release dates of wanted movies come from.
+// Without one, wanted movies are searched as usual whatever their release date.
+func (s *Scheduler) SetReleaseDateLookup(lookup ReleaseDateLookup) {
+	s.releaseDates = lookup
+}
+
+// digitalRelease returns when a wanted movie is (or was) released digitally.
+// A movie with no digital date that hasn't reached theaters yet is treated as
+// unreleased until its theatrical date. Returns false when nothing is known.
+func (s *Scheduler) digitalRelease(item *database.WantedItem) (time.Time, bool) {
+	if s.releaseDates == nil || item.Type != "movie" || item.IsUpgrade {
+		return time.Time{}, false
+	}
+	theatrical, digital, err := s.releaseDates.GetMovieReleaseDates(item.TmdbID)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if date, ok := parseReleaseDate(digital); ok {
+		return date, true
+	}
+	if date, ok := parseReleaseDate(theatrical); ok && date.After(time.Now()) {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// skipUnreleased reports whether a regular search should skip a wanted movie
+// because it isn't out digitally yet. These are still searched once a day in
+// case the date is off; the release job searches them when the date passes.
+func (s *Scheduler) skipUnreleased(item *database.WantedItem) bool {
+	release, ok := s.digitalRelease(item)
+	if !ok || !release.After(time.Now()) {
+		return false
+	}
+	if item.LastSearched == nil || time.Since(*item.LastSearched) >= unreleasedSearchInterval {
+		logger.Infof("Scheduler: %s isn't released digitally until %s - daily fallback search", item.Title, release.Format("2006-01-02"))
+		return false
+	}
+	return true
+}
+
+// runReleaseJob searches wanted movies as soon as their digital release date
+// passes instead of waiting for the next regular search
+func (s *Scheduler) runReleaseJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(releaseCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.searchNewReleases()
+			s.recheckDeniedRequests()
+		}
+	}
+}
+
+// searchNewReleases searches wanted movies released digitally since they were
+// last searched
+func (s *Scheduler) searchNewReleases() {
+	autoSearch, _ := s.db.GetSetting("scheduler_auto_search")
+	if autoSearch != "true" || s.releaseDates == nil {
+		return
+	}
+
+	items, err := s.db.GetMonitoredItems()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get monitored items: %v", err)
+		return
+	}
+
+	for _, item := range items {
+		release, ok := s.digitalRelease(&item)
+		if !ok || release.After(time.Now()) || time.Since(release) > newReleaseWindow {
+			continue
+		}
+		if item.LastSearched != nil && item.LastSearched.After(release) {
+			continue
+		}
+
+		logger.Infof("Scheduler: %s was released digitally on %s - searching", item.Title, release.Format("2006-01-02"))
+		s.searchAndGrab(&item)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// parseReleaseDate parses TMDB release dates ("2024-03-01T00:00:00.000Z")
+// as midnight in the server's time zone
+func parseReleaseDate(value string) (time.Time, bool) {
+	if len(value) < 10 {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation("2006-01-02", value[:10], timezone.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+)
+
+// Denied requests flagged to be rechecked after release (usually denied as
+// "not released yet") are reopened once the title is out, or approved
+// straight away when the request_recheck_policy setting is "approve".
+
+// recheckDeniedRequests reopens or approves denied requests whose title has
+// been released since
+func (s *Scheduler) recheckDeniedRequests() {
+	if s.releaseDates == nil {
+		return
+	}
+
+	requests, err := s.db.GetRecheckRequests()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get denied requests to recheck: %v", err)
+		return
+	}
+	policy, _ := s.db.GetSetting("request_recheck_policy")
+	approve := policy == "approve"
+
+	for i := range requests {
+		req := &requests[i]
+		if !s.requestReleased(req) {
+			continue
+		}
+
+		if approve {
+			err = s.approveReleasedRequest(req)
+		} else {
+			err = s.db.UpdateRequestStatus(req.ID, "requested", nil)
+		}
+		if err != nil {
+			logger.Errorf("Scheduler: failed to recheck denied request for %s: %v", req.Title, err)
+			continue
+		}
+
+		if approve {
+			logger.Infof("Scheduler: %s is released - approved its denied request", req.Title)
+		} else {
+			logger.Infof("Scheduler: %s is released - reopened its denied request", req.Title)
+		}
+		if s.notifier != nil {
+			s.notifier.NotifyRequestReleased(req.UserID, req.Title, req.Type, req.TmdbID, approve, req.PosterPath)
+		}
+	}
+}
+
+// requestReleased reports whether a request's title is out: a movie once its
+// digital release date passes, a show once it first airs
+func (s *Scheduler) requestReleased(req *database.Request) bool {
+	var date string
+	var err error
+	if req.Type == "movie" {
+		_, date, err = s.releaseDates.GetMovieReleaseDates(req.TmdbID)
+	} else {
+		date, err = s.releaseDates.GetShowFirstAirDate(req.TmdbID)
+	}
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get release date of %s: %v", req.Title, err)
+		return false
+	}
+	release, ok := parseReleaseDate(date)
+	return ok && !release.After(time.Now())
+}
+
+// approveReleasedRequest approves a request the way an admin would: the title
+// is added to the wanted list with the request's quality preset (or the
+// default one) and searched for
+func (s *Scheduler) approveReleasedRequest(req *database.Request) error {
+	existing, _ := s.db.GetWantedByTmdb(req.Type, req.TmdbID)
+	if existing == nil {
+		presetID := req.QualityPresetID
+		if presetID == nil || *presetID <= 0 {
+			presetID = s.defaultPresetID()
+		}
+		seasons := ""
+		if req.Seasons != nil {
+			seasons = *req.Seasons
+		}
+		wanted := &database.WantedItem{
+			Type:            req.Type,
+			TmdbID:          req.TmdbID,
+			Title:           req.Title,
+			Year:            req.Year,
+			PosterPath:      req.PosterPath,
+			QualityPresetID: presetID,
+			Monitored:       true,
+			Seasons:         seasons,
+			Edition:         req.Edition,
+			Priority:        req.Priority,
+		}
+		if err := s.db.CreateWantedItem(wanted); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.UpdateRequestStatus(req.ID, "approved", nil); err != nil {
+		return err
+	}
+	return s.SearchWantedItem(req.TmdbID, req.Type)
+}
+
+// defaultPresetID returns the default quality preset, or the first enabled
+// one if there's no default
+func (s *Scheduler) defaultPresetID() *int64 {
+	presets, err := s.db.GetQualityPresets()
+	if err != nil {
+		return nil
+	}
+	for _, p := range presets {
+		if p.IsDefault && p.Enabled {
+			return &p.ID
+		}
+	}
+	for _, p := range presets {
+		if p.Enabled {
+			return &p.ID
+		}
+	}
+	return nil
+}
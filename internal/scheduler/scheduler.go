@@ -3,7 +3,6 @@ package scheduler
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,7 +11,9 @@ import (
 
 	"github.com/outpost/outpost/internal/database"
 	"github.com/outpost/outpost/internal/downloadclient"
+	"github.com/outpost/outpost/internal/i18n"
 	"github.com/outpost/outpost/internal/indexer"
+	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/parser"
 	"github.com/outpost/outpost/internal/quality"
 	"github.com/outpost/outpost/internal/scanner"
@@ -20,6 +21,8 @@ import (
 	"github.com/outpost/outpost/internal/trakt"
 )
 
+var logger = logging.Module("scheduler")
+
 type Scheduler struct {
 	db        *database.Database
 	indexers  *indexer.Manager
@@ -37,10 +40,32 @@ type Scheduler struct {
 
 	// Task tracking
 	taskRunning map[string]bool
+	taskStarted map[string]time.Time // Start of each running task's current run
+	nextRuns    map[string]time.Time // Next tick of tasks run on a schedule
 	taskMu      sync.RWMutex
 
 	// Active search tracking for UI
 	activeSearch string
+
+	notifier        Notifier
+	releaseDates    ReleaseDateLookup
+	episodeSchedule EpisodeScheduleLookup
+	personCredits   PersonCreditsLookup
+	titles          TitleLookup
+	podcasts        PodcastRefresher
+	music           MusicMetadataFetcher
+	health          HealthChecker
+	trash           TrashEmptier
+}
+
+// Notifier sends notifications for scheduler events
+type Notifier interface {
+	NotifyDownloadsPaused(reason i18n.Message) error
+	NotifyDownloadsResumed(message i18n.Message) error
+	NotifyRequestReleased(userID int64, title, mediaType string, tmdbID int64, approved bool, posterPath *string) error
+	NotifyEpisodeAvailable(userID int64, showTitle string, season, episode int, showID int64, posterPath *string) error
+	NotifyFollowedPersonMovie(userID int64, personName, title string, movieID int64, posterPath *string) error
+	NotifyFollowedPersonRelease(userID int64, personName, title string, tmdbID int64, posterPath *string) error
 }
 
 func New(db *database.Database, indexers *indexer.Manager, downloads *downloadclient.Manager, scan *scanner.Scanner) *Scheduler {
@@ -53,6 +78,8 @@ func New(db *database.Database, indexers *indexer.Manager, downloads *downloadcl
 		searchInterval: 60, // Default: search every 60 minutes
 		rssInterval:    15, // Default: check RSS every 15 minutes
 		taskRunning:    make(map[string]bool),
+		taskStarted:    make(map[string]time.Time),
+		nextRuns:       make(map[string]time.Time),
 	}
 	s.initDefaultTasks()
 	return s
@@ -124,17 +151,92 @@ func (s *Scheduler) initDefaultTasks() {
 			Enabled:         true, // Enabled by default for auto skip
 			IntervalMinutes: 360,  // 6 hours
 		},
+		{
+			Name:            "Podcast Refresh",
+			Description:     "Check podcast feeds for new episodes and download them",
+			TaskType:        "podcast_refresh",
+			Enabled:         true,
+			IntervalMinutes: 60, // 1 hour
+		},
+		{
+			Name:            "Health Check",
+			Description:     "Run health checks and notify when one starts failing",
+			TaskType:        "health_check",
+			Enabled:         true,
+			IntervalMinutes: 15,
+		},
+		{
+			Name:            "Empty Trash",
+			Description:     "Remove deleted files that have been in the trash longer than the retention period",
+			TaskType:        "empty_trash",
+			Enabled:         true,
+			IntervalMinutes: 1440, // 24 hours
+		},
+		{
+			Name:            "Follows",
+			Description:     "Notify profiles of new episodes and movies of the shows and people they follow",
+			TaskType:        "follows",
+			Enabled:         true,
+			IntervalMinutes: 15,
+		},
+		{
+			Name:            "Trickplay",
+			Description:     "Generate scrubbing preview thumbnails for movies and episodes",
+			TaskType:        "trickplay",
+			Enabled:         true,
+			IntervalMinutes: 60, // 1 hour
+		},
+		{
+			Name:            "Subtitle Extraction",
+			Description:     "Extract embedded subtitles ahead of playback for files not yet extracted",
+			TaskType:        "subtitle_extraction",
+			Enabled:         true,
+			IntervalMinutes: 60, // 1 hour
+		},
+		{
+			Name:            "Subtitle Search",
+			Description:     "Download missing subtitles in the languages of each library's subtitle profile",
+			TaskType:        "subtitle_search",
+			Enabled:         true,
+			IntervalMinutes: 30,
+		},
+		{
+			Name:            "Subtitle Upgrade",
+			Description:     "Replace recently downloaded subtitles when better scoring ones become available",
+			TaskType:        "subtitle_upgrade",
+			Enabled:         true,
+			IntervalMinutes: 720, // 12 hours
+		},
+		{
+			Name:            "Music Metadata",
+			Description:     "Look up new artists and albums on MusicBrainz for bios, artwork and track details",
+			TaskType:        "music_metadata",
+			Enabled:         true,
+			IntervalMinutes: 60, // 1 hour
+		},
+		{
+			Name:            "Library Stats",
+			Description:     "Record each library's item count and size for growth trends",
+			TaskType:        "library_stats",
+			Enabled:         true,
+			IntervalMinutes: 60, // 1 hour
+		},
 	}
 
 	for _, task := range defaultTasks {
 		if err := s.db.UpsertTask(&task); err != nil {
-			log.Printf("Failed to create task %s: %v", task.Name, err)
+			logger.Errorf("Failed to create task %s: %v", task.Name, err)
 		} else {
-			log.Printf("Created task: %s (ID: %d)", task.Name, task.ID)
+			logger.Infof("Created task: %s (ID: %d)", task.Name, task.ID)
 		}
 	}
 }
 
+// SetNotifier sets the handler for scheduler notifications
+func (s *Scheduler) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
 func (s *Scheduler) SetSearchInterval(minutes int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -172,7 +274,71 @@ func (s *Scheduler) Start() {
 	s.wg.Add(1)
 	go s.runRSSJob()
 
-	log.Printf("Scheduler started (search: %dm, rss: %dm)", s.searchInterval, s.rssInterval)
+	// Start the bandwidth schedule job
+	s.wg.Add(1)
+	go s.runBandwidthJob()
+
+	// Start the low storage pause job
+	s.wg.Add(1)
+	go s.runStorageJob()
+
+	// Start the download client status job
+	s.wg.Add(1)
+	go s.runClientStatusJob()
+
+	// Start the digital release job
+	s.wg.Add(1)
+	go s.runReleaseJob()
+
+	// Start the account expiry job
+	s.wg.Add(1)
+	go s.runAccountExpiryJob()
+
+	// Start the podcast refresh job
+	s.wg.Add(1)
+	go s.runPodcastJob()
+
+	// Start the health check job
+	s.wg.Add(1)
+	go s.runHealthJob()
+
+	// Start the trash emptying job
+	s.wg.Add(1)
+	go s.runTrashJob()
+
+	// Start the airing show job
+	s.wg.Add(1)
+	go s.runAiringJob()
+
+	// Start the follows job
+	s.wg.Add(1)
+	go s.runFollowJob()
+
+	// Start the trickplay job
+	s.wg.Add(1)
+	go s.runTrickplayJob()
+
+	// Start the subtitle extraction job
+	s.wg.Add(1)
+	go s.runSubtitleExtractionJob()
+
+	// Start the subtitle search job
+	s.wg.Add(1)
+	go s.runSubtitleSearchJob()
+
+	// Start the subtitle upgrade job
+	s.wg.Add(1)
+	go s.runSubtitleUpgradeJob()
+
+	// Start the music metadata job
+	s.wg.Add(1)
+	go s.runMusicMetadataJob()
+
+	// Start the library stats job
+	s.wg.Add(1)
+	go s.runLibraryStatsJob()
+
+	logger.Infof("Scheduler started (search: %dm, rss: %dm)", s.searchInterval, s.rssInterval)
 
 }
 
@@ -187,7 +353,12 @@ func (s *Scheduler) Stop() {
 	s.mu.Unlock()
 
 	s.wg.Wait()
-	log.Println("Scheduler stopped")
+
+	s.taskMu.Lock()
+	s.nextRuns = make(map[string]time.Time)
+	s.taskMu.Unlock()
+
+	logger.Infof("Scheduler stopped")
 }
 
 func (s *Scheduler) loadIntervals() {
@@ -203,9 +374,17 @@ func (s *Scheduler) loadIntervals() {
 	}
 }
 
-// GetStatus returns all tasks with their current running status
+// taskDurationHistory is how many recent run durations GetStatus reports per task
+const taskDurationHistory = 10
+
+// GetStatus returns all tasks with their current running status, when they
+// next run and how long their recent runs took
 func (s *Scheduler) GetStatus() []database.ScheduledTask {
 	tasks, _ := s.db.GetAllTasks()
+	durations, err := s.db.GetRecentTaskDurations(taskDurationHistory)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to load task durations: %v", err)
+	}
 
 	s.taskMu.RLock()
 	defer s.taskMu.RUnlock()
@@ -214,11 +393,42 @@ func (s *Scheduler) GetStatus() []database.ScheduledTask {
 		if running, ok := s.taskRunning[tasks[i].Name]; ok {
 			tasks[i].IsRunning = running
 		}
+		if startedAt, ok := s.taskStarted[tasks[i].Name]; ok {
+			tasks[i].StartedAt = &startedAt
+		}
+
+		// Only tasks with a job run on their own; the rest run when triggered
+		if nextRun, ok := s.nextRuns[tasks[i].Name]; ok && tasks[i].Enabled {
+			tasks[i].NextRun = &nextRun
+		} else {
+			tasks[i].NextRun = nil
+		}
+
+		tasks[i].RecentDurationsMs = durations[tasks[i].ID]
+		if tasks[i].RecentDurationsMs == nil {
+			tasks[i].RecentDurationsMs = []int64{}
+		}
 	}
 
 	return tasks
 }
 
+// TaskStartedAt returns when the named task's current run started, and
+// whether it's running
+func (s *Scheduler) TaskStartedAt(name string) (time.Time, bool) {
+	s.taskMu.RLock()
+	defer s.taskMu.RUnlock()
+	startedAt, ok := s.taskStarted[name]
+	return startedAt, ok
+}
+
+// setNextRun records when a scheduled task's job next ticks
+func (s *Scheduler) setNextRun(name string, at time.Time) {
+	s.taskMu.Lock()
+	s.nextRuns[name] = at
+	s.taskMu.Unlock()
+}
+
 // GetActiveSearch returns the title of the item currently being searched
 func (s *Scheduler) GetActiveSearch() string {
 	s.taskMu.RLock()
@@ -272,20 +482,22 @@ func (s *Scheduler) executeTask(task *database.ScheduledTask) {
 		s.taskMu.Unlock()
 		return
 	}
+	startedAt := time.Now()
 	s.taskRunning[task.Name] = true
+	s.taskStarted[task.Name] = startedAt
 	s.taskMu.Unlock()
 
 	defer func() {
 		s.taskMu.Lock()
 		s.taskRunning[task.Name] = false
+		delete(s.taskStarted, task.Name)
 		s.taskMu.Unlock()
 	}()
 
-	startedAt := time.Now()
 	var itemsProcessed, itemsFound int
 	var taskError error
 
-	log.Printf("Task started: %s (ID: %d, Type: %s)", task.Name, task.ID, task.TaskType)
+	logger.Infof("Task started: %s (ID: %d, Type: %s)", task.Name, task.ID, task.TaskType)
 
 	// Execute based on task type
 	switch task.TaskType {
@@ -307,12 +519,32 @@ func (s *Scheduler) executeTask(task *database.ScheduledTask) {
 		itemsProcessed = s.runTraktSyncTask()
 	case "intro_detection":
 		itemsProcessed = s.runIntroDetectionTask()
+	case "podcast_refresh":
+		itemsProcessed = s.runPodcastTask()
+	case "health_check":
+		itemsFound = s.runHealthTask()
+	case "empty_trash":
+		itemsProcessed = s.runTrashTask()
+	case "follows":
+		itemsFound = s.runFollowTask()
+	case "trickplay":
+		itemsProcessed = s.runTrickplayTask()
+	case "subtitle_extraction":
+		itemsProcessed = s.runSubtitleExtractionTask()
+	case "subtitle_search":
+		itemsProcessed, itemsFound = s.runSubtitleSearchTask()
+	case "subtitle_upgrade":
+		itemsProcessed, itemsFound = s.runSubtitleUpgradeTask()
+	case "music_metadata":
+		itemsProcessed = s.runMusicMetadataTask()
+	case "library_stats":
+		itemsProcessed = s.runLibraryStatsTask()
 	}
 
 	finishedAt := time.Now()
 	durationMs := finishedAt.Sub(startedAt).Milliseconds()
 
-	log.Printf("Task completed: %s - processed: %d, found: %d, duration: %dms", task.Name, itemsProcessed, itemsFound, durationMs)
+	logger.Infof("Task completed: %s - processed: %d, found: %d, duration: %dms", task.Name, itemsProcessed, itemsFound, durationMs)
 
 	status := "success"
 	var errorMsg *string
@@ -343,11 +575,12 @@ func (s *Scheduler) runSearchTask() (processed, found int) {
 	}
 
 	for _, item := range items {
-		if item.LastSearched != nil {
-			hoursSinceLast := time.Since(*item.LastSearched).Hours()
-			if hoursSinceLast < float64(s.searchInterval)/60.0 {
-				continue
-			}
+		if !s.searchDue(&item) {
+			continue
+		}
+		// Movies not out digitally yet wait for the release job
+		if s.skipUnreleased(&item) {
+			continue
 		}
 
 		s.searchAndGrab(&item)
@@ -394,13 +627,49 @@ func (s *Scheduler) runCleanupTask() int {
 	processed := 0
 	// Clear old fingerprint-based segments to allow re-detection with improved algorithm
 	s.db.DeleteMediaSegmentsBySource("fingerprint")
-	log.Printf("Scheduler: cleared old fingerprint-based intro segments")
+	logger.Infof("Scheduler: cleared old fingerprint-based intro segments")
 
 	// Cleanup task history older than 30 days
 	if err := s.db.CleanupTaskHistory(30); err == nil {
 		processed++
 	}
 
+	// Cleanup audit log entries older than 90 days
+	if err := s.db.CleanupAuditLog(90); err == nil {
+		processed++
+	}
+
+	// Cleanup used and expired password reset tokens
+	if err := s.db.DeleteExpiredPasswordResets(); err == nil {
+		processed++
+	}
+
+	// Cleanup streaming sessions older than 90 days (daily usage is kept)
+	if err := s.db.CleanupStreamSessions(90); err == nil {
+		processed++
+	}
+
+	// Cleanup release sightings older than 30 days
+	if err := s.db.CleanupReleaseSightings(30); err == nil {
+		processed++
+	}
+
+	// Cleanup release dates of movies that are no longer wanted
+	if err := s.db.CleanupMovieReleaseDates(); err == nil {
+		processed++
+	}
+
+	// Drop change journal entries past retention, then superseded ones
+	if _, err := s.db.PruneChangeJournal(s.db.GetChangeRetentionDays()); err == nil {
+		processed++
+	}
+	if n, err := s.db.CompactChangeJournal(); err == nil {
+		if n > 0 {
+			logger.Infof("Scheduler: compacted %d change journal entries", n)
+		}
+		processed++
+	}
+
 	return processed
 }
 
@@ -414,22 +683,22 @@ func (s *Scheduler) runMetadataRefreshTask() int {
 // runLibraryScanTask scans all libraries for new files
 func (s *Scheduler) runLibraryScanTask() int {
 	if s.scanner == nil {
-		log.Printf("Scheduler: scanner not available for library scan")
+		logger.Infof("Scheduler: scanner not available for library scan")
 		return 0
 	}
 
 	libraries, err := s.db.GetLibraries()
 	if err != nil {
-		log.Printf("Scheduler: failed to get libraries: %v", err)
+		logger.Errorf("Scheduler: failed to get libraries: %v", err)
 		return 0
 	}
 
 	scanned := 0
 	hasTVLibrary := false
 	for _, lib := range libraries {
-		log.Printf("Scheduler: scanning library %s (%s)", lib.Name, lib.Path)
+		logger.Infof("Scheduler: scanning library %s (%s)", lib.Name, lib.Path)
 		if err := s.scanner.ScanLibrary(&lib); err != nil {
-			log.Printf("Scheduler: failed to scan library %s: %v", lib.Name, err)
+			logger.Errorf("Scheduler: failed to scan library %s: %v", lib.Name, err)
 			continue
 		}
 		scanned++
@@ -441,7 +710,7 @@ func (s *Scheduler) runLibraryScanTask() int {
 	// Trigger intro detection for TV libraries after scan completes
 	if hasTVLibrary && scanner.CheckFFmpegChromaprint() {
 		go func() {
-			log.Printf("Scheduler: triggering intro detection after library scan")
+			logger.Infof("Scheduler: triggering intro detection after library scan")
 			s.runIntroDetectionTask()
 		}()
 	}
@@ -454,13 +723,13 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 	// Get upgrade search settings
 	settings, err := s.db.GetAllSettings()
 	if err != nil {
-		log.Printf("Scheduler: failed to get settings: %v", err)
+		logger.Errorf("Scheduler: failed to get settings: %v", err)
 		return 0, 0
 	}
 
 	// Check if upgrade search is enabled
 	if val, ok := settings["upgrade_search_enabled"]; ok && val != "true" {
-		log.Printf("Scheduler: upgrade search is disabled")
+		logger.Infof("Scheduler: upgrade search is disabled")
 		return 0, 0
 	}
 
@@ -472,12 +741,12 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 		}
 	}
 
-	log.Printf("Scheduler: searching for %d upgradeable items (excluding items in backoff)", limit)
+	logger.Infof("Scheduler: searching for %d upgradeable items (excluding items in backoff)", limit)
 
 	// Get upgradeable movies (excluding those in backoff)
 	movies, err := s.db.GetUpgradeableMoviesWithOptions(limit/2, true)
 	if err != nil {
-		log.Printf("Scheduler: failed to get upgradeable movies: %v", err)
+		logger.Errorf("Scheduler: failed to get upgradeable movies: %v", err)
 	} else {
 		for _, item := range movies {
 			movie, err := s.db.GetMovie(item.ID)
@@ -515,7 +784,7 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 				// Existing item - update backoff and search
 				s.db.UpdateWantedSearchBackoff(existing.ID)
 				processed++
-				log.Printf("Scheduler: searching upgrade for movie %s (attempt %d)", movie.Title, existing.SearchAttempts+1)
+				logger.Infof("Scheduler: searching upgrade for movie %s (attempt %d)", movie.Title, existing.SearchAttempts+1)
 				s.SearchWantedItem(*movie.TmdbID, "movie")
 			} else {
 				// Create new wanted item
@@ -523,7 +792,7 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 				if err == nil {
 					processed++
 					s.db.UpdateUpgradeSearched(item.ID, "movie", false)
-					log.Printf("Scheduler: searching upgrade for movie %s (first attempt)", movie.Title)
+					logger.Infof("Scheduler: searching upgrade for movie %s (first attempt)", movie.Title)
 					s.SearchWantedItem(*movie.TmdbID, "movie")
 				}
 			}
@@ -533,7 +802,7 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 	// Get upgradeable episodes (excluding those in backoff)
 	episodes, err := s.db.GetUpgradeableEpisodesWithOptions(limit/2, true)
 	if err != nil {
-		log.Printf("Scheduler: failed to get upgradeable episodes: %v", err)
+		logger.Errorf("Scheduler: failed to get upgradeable episodes: %v", err)
 	} else {
 		for _, item := range episodes {
 			episode, err := s.db.GetEpisode(item.ID)
@@ -581,7 +850,7 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 				// Existing item - update backoff and search
 				s.db.UpdateWantedSearchBackoff(existing.ID)
 				processed++
-				log.Printf("Scheduler: searching upgrade for %s (attempt %d)", title, existing.SearchAttempts+1)
+				logger.Infof("Scheduler: searching upgrade for %s (attempt %d)", title, existing.SearchAttempts+1)
 				s.SearchWantedItem(*show.TmdbID, "show")
 			} else {
 				// Create new wanted item
@@ -589,14 +858,14 @@ func (s *Scheduler) runUpgradeSearchTask() (processed, found int) {
 				if err == nil {
 					processed++
 					s.db.UpdateUpgradeSearched(item.ID, "episode", false)
-					log.Printf("Scheduler: searching upgrade for %s (first attempt)", title)
+					logger.Infof("Scheduler: searching upgrade for %s (first attempt)", title)
 					s.SearchWantedItem(*show.TmdbID, "show")
 				}
 			}
 		}
 	}
 
-	log.Printf("Scheduler: upgrade search complete - processed %d items", processed)
+	logger.Infof("Scheduler: upgrade search complete - processed %d items", processed)
 	return processed, found
 }
 
@@ -624,7 +893,7 @@ func (s *Scheduler) SearchWantedItem(tmdbID int64, mediaType string) error {
 			s.taskMu.Unlock()
 		}()
 
-		log.Printf("Scheduler: searching for requested item: %s (%s)", item.Title, mediaType)
+		logger.Infof("Scheduler: searching for requested item: %s (%s)", item.Title, mediaType)
 		s.searchAndGrab(item)
 	}()
 
@@ -635,8 +904,11 @@ func (s *Scheduler) SearchWantedItem(tmdbID int64, mediaType string) error {
 func (s *Scheduler) runSearchJob() {
 	defer s.wg.Done()
 
-	ticker := time.NewTicker(time.Duration(s.searchInterval) * time.Minute)
+	// High priority items are searched for more often than the interval
+	interval := s.searchTick()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	s.setNextRun("Search Monitored", time.Now().Add(interval))
 
 	// Run immediately on start
 	s.executeTaskByName("Search Monitored")
@@ -645,7 +917,8 @@ func (s *Scheduler) runSearchJob() {
 		select {
 		case <-s.stopChan:
 			return
-		case <-ticker.C:
+		case tick := <-ticker.C:
+			s.setNextRun("Search Monitored", tick.Add(interval))
 			s.executeTaskByName("Search Monitored")
 		}
 	}
@@ -654,8 +927,10 @@ func (s *Scheduler) runSearchJob() {
 func (s *Scheduler) runRSSJob() {
 	defer s.wg.Done()
 
-	ticker := time.NewTicker(time.Duration(s.rssInterval) * time.Minute)
+	interval := time.Duration(s.rssInterval) * time.Minute
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	s.setNextRun("RSS Sync", time.Now().Add(interval))
 
 	// Run immediately on start
 	s.executeTaskByName("RSS Sync")
@@ -664,17 +939,148 @@ func (s *Scheduler) runRSSJob() {
 		select {
 		case <-s.stopChan:
 			return
-		case <-ticker.C:
+		case tick := <-ticker.C:
+			s.setNextRun("RSS Sync", tick.Add(interval))
 			s.executeTaskByName("RSS Sync")
 		}
 	}
 }
 
+// runBandwidthJob applies download client speed limit schedules every minute
+func (s *Scheduler) runBandwidthJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	// Apply immediately on start so clients match the current schedule
+	s.downloads.ApplyBandwidthSchedules(time.Now(), true)
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.downloads.ApplyBandwidthSchedules(time.Now(), false)
+		}
+	}
+}
+
+// runClientStatusJob polls download clients for their free space, queue
+// and errors every minute
+func (s *Scheduler) runClientStatusJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	s.downloads.PollClientStatuses()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.downloads.PollClientStatuses()
+		}
+	}
+}
+
+// runAccountExpiryJob disables expired accounts, such as guests, every
+// minute and revokes their sessions
+func (s *Scheduler) runAccountExpiryJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	s.disableExpiredAccounts()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.disableExpiredAccounts()
+		}
+	}
+}
+
+func (s *Scheduler) disableExpiredAccounts() {
+	ids, err := s.db.DisableExpiredUsers()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to disable expired accounts: %v", err)
+		return
+	}
+	for _, id := range ids {
+		s.db.DeleteUserSessions(id)
+		s.db.DeleteUserPinElevations(id)
+		logger.Infof("Scheduler: account %d expired, disabled and signed out", id)
+	}
+}
+
+// storageResumeMarginGB is the free space required above the threshold before
+// paused downloads are resumed, so they don't flap around the threshold
+const storageResumeMarginGB = 5
+
+// runStorageJob pauses download clients while free space is below the
+// configured threshold and resumes them once it recovers
+func (s *Scheduler) runStorageJob() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	s.checkStoragePause()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.checkStoragePause()
+		}
+	}
+}
+
+// checkStoragePause pauses or resumes downloads based on free library space
+func (s *Scheduler) checkStoragePause() {
+	enabled, thresholdGB := s.storagePauseSettings()
+	path, freeGB, ok := s.lowestFreeSpace()
+	state := s.downloads.GetStoragePauseState()
+
+	switch {
+	case enabled && ok && freeGB < thresholdGB:
+		reason := i18n.M("%d GB free on %s (threshold: %d GB)", freeGB, path, thresholdGB)
+		// Runs every time while space is low to catch downloads added since
+		paused := s.downloads.PauseForLowStorage(reason.String(i18n.Fallback))
+		if !state.Paused {
+			logger.Infof("Scheduler: low disk space, paused %d downloads - %s", paused, reason.String(i18n.Fallback))
+			if s.notifier != nil {
+				s.notifier.NotifyDownloadsPaused(reason)
+			}
+		} else if paused > 0 {
+			logger.Infof("Scheduler: low disk space, paused %d more downloads", paused)
+		}
+
+	case state.Paused && (!enabled || (ok && freeGB >= thresholdGB+storageResumeMarginGB)):
+		resumed := s.downloads.ResumeAfterLowStorage()
+		message := i18n.M("Resumed %d downloads - low storage pausing was disabled", resumed)
+		if enabled {
+			message = i18n.M("Resumed %d downloads - %d GB free on %s", resumed, freeGB, path)
+		}
+		logger.Infof("Scheduler: %s", message.String(i18n.Fallback))
+		if s.notifier != nil {
+			s.notifier.NotifyDownloadsResumed(message)
+		}
+	}
+}
+
 // executeTaskByName runs a task by name
 func (s *Scheduler) executeTaskByName(name string) {
 	task, err := s.db.GetTaskByName(name)
 	if err != nil {
-		log.Printf("Scheduler: task not found: %s", name)
+		logger.Infof("Scheduler: task not found: %s", name)
 		return
 	}
 	if !task.Enabled {
@@ -692,7 +1098,7 @@ func (s *Scheduler) searchMonitoredItems() {
 
 	items, err := s.db.GetMonitoredItems()
 	if err != nil {
-		log.Printf("Scheduler: failed to get monitored items: %v", err)
+		logger.Errorf("Scheduler: failed to get monitored items: %v", err)
 		return
 	}
 
@@ -700,15 +1106,16 @@ func (s *Scheduler) searchMonitoredItems() {
 		return
 	}
 
-	log.Printf("Scheduler: searching %d monitored items", len(items))
+	logger.Infof("Scheduler: searching %d monitored items", len(items))
 
 	for _, item := range items {
-		// Check if we should search (based on last search time)
-		if item.LastSearched != nil {
-			hoursSinceLast := time.Since(*item.LastSearched).Hours()
-			if hoursSinceLast < float64(s.searchInterval)/60.0 {
-				continue
-			}
+		// Check if we should search (based on last search and priority)
+		if !s.searchDue(&item) {
+			continue
+		}
+		// Movies not out digitally yet wait for the release job
+		if s.skipUnreleased(&item) {
+			continue
 		}
 
 		s.searchAndGrab(&item)
@@ -719,120 +1126,63 @@ func (s *Scheduler) searchMonitoredItems() {
 }
 
 func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
-	log.Printf("Scheduler: searchAndGrab started for %s", item.Title)
+	logger.Infof("Scheduler: searchAndGrab started for %s", item.Title)
 
 	// Check if downloads should be paused due to low storage
 	if s.shouldPauseDownloads() {
-		log.Printf("Scheduler: downloads paused due to low storage")
+		logger.Infof("Scheduler: downloads paused due to low storage")
 		return
 	}
 
 	// Check if this media is excluded
 	excluded, _ := s.db.IsMediaExcluded(item.TmdbID, item.Type)
 	if excluded {
-		log.Printf("Scheduler: skipping excluded media: %s", item.Title)
+		logger.Infof("Scheduler: skipping excluded media: %s", item.Title)
 		return
 	}
 
-	searchType := "movie"
-	mediaTypeForCategories := "movie"
-	if item.Type == "show" {
-		searchType = "tvsearch"
-		mediaTypeForCategories = "tv"
-	} else if item.Type == "anime" {
-		searchType = "tvsearch" // Anime uses TV search type
-		mediaTypeForCategories = "anime"
-	}
-
-	params := indexer.SearchParams{
-		Query:      item.Title,
-		Type:       searchType,
-		Limit:      50,
-		Categories: database.GetCategoriesForMediaType(mediaTypeForCategories),
-	}
-
-	// Add TMDB ID if available
-	if item.TmdbID > 0 {
-		params.TmdbID = strconv.FormatInt(item.TmdbID, 10)
-	}
-
-	// Look up IMDB ID from the movie/show record for more accurate searches
-	imdbID := s.lookupImdbID(item.Type, item.TmdbID)
-	if imdbID != "" {
-		params.ImdbID = imdbID
-		log.Printf("Scheduler: found IMDB ID %s for %s (TMDB: %d)", imdbID, item.Title, item.TmdbID)
-	} else {
-		log.Printf("Scheduler: no IMDB ID found for %s (TMDB: %d) - using title search", item.Title, item.TmdbID)
-	}
-
-	// For TV shows/anime, also look up TVDB ID
-	if item.Type == "show" || item.Type == "anime" {
-		tvdbID := s.lookupTvdbID(item.TmdbID)
-		if tvdbID != "" {
-			params.TvdbID = tvdbID
-			log.Printf("Scheduler: found TVDB ID %s for %s", tvdbID, item.Title)
-		}
-	}
-
-	// Log search parameters for debugging
-	log.Printf("Scheduler: SEARCH PARAMS for '%s' (%s):", item.Title, item.Type)
-	log.Printf("  - Query: %s", params.Query)
-	log.Printf("  - Type: %s", params.Type)
-	log.Printf("  - IMDB ID: %s (enables exact matching)", params.ImdbID)
-	log.Printf("  - TVDB ID: %s", params.TvdbID)
-	log.Printf("  - TMDB ID: %s", params.TmdbID)
-	log.Printf("  - Categories: %v", params.Categories)
-
-	// Get indexers for this media type based on library tags
-	indexerIDs := s.getIndexerIDsForMediaType(item.Type)
-	log.Printf("Scheduler: using %d indexer IDs for search", len(indexerIDs))
+	// Record the search in the item's activity however it ends
+	activity := &searchActivity{}
+	defer s.recordSearchActivity(item, activity)
 
-	var results []indexer.SearchResult
-	var err error
-	if len(indexerIDs) > 0 {
-		results, err = s.indexers.SearchWithIndexerIDs(params, indexerIDs)
-	} else {
-		results, err = s.indexers.Search(params)
-	}
+	results, queries, err := s.searchReleases(item)
 	if err != nil {
-		log.Printf("Scheduler: search failed for %s: %v", item.Title, err)
+		logger.Errorf("Scheduler: search failed for %s: %v", item.Title, err)
+		activity.Outcome, activity.Error = "failed", err.Error()
 		return
 	}
-
-	log.Printf("Scheduler: found %d raw results for %s", len(results), item.Title)
-
-	// Filter out adult content (category 6000-6999)
-	results = filterAdultContent(results)
-	log.Printf("Scheduler: %d results after adult content filtering", len(results))
+	activity.Results = len(results)
+	activity.Queries = queries
 
 	// Update last searched
 	s.db.UpdateWantedLastSearched(item.ID)
 
 	if len(results) == 0 {
-		log.Printf("Scheduler: no results for %s", item.Title)
+		logger.Infof("Scheduler: no results for %s", item.Title)
+		activity.Outcome = "no results"
 		return
 	}
 
+	// Record sightings so presets can wait for new releases to settle
+	titles := make([]string, len(results))
+	for i := range results {
+		titles[i] = results[i].Title
+	}
+	firstSeen, err := s.db.RecordReleaseSightings(titles)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to record release sightings: %v", err)
+	}
+
 	// Check auto-grab setting
 	autoGrab, _ := s.db.GetSetting("scheduler_auto_grab")
 	if autoGrab != "true" {
-		log.Printf("Scheduler: found %d results for %s (auto-grab disabled)", len(results), item.Title)
+		logger.Infof("Scheduler: found %d results for %s (auto-grab disabled)", len(results), item.Title)
+		activity.Outcome = "auto-grab disabled"
 		return
 	}
 
 	// Get library ID for indexer exclusion check
-	var libraryID int64
-	libraries, _ := s.db.GetLibraries()
-	libType := "movies"
-	if item.Type == "show" {
-		libType = "tv"
-	}
-	for _, lib := range libraries {
-		if lib.Type == libType {
-			libraryID = lib.ID
-			break
-		}
-	}
+	libraryID := s.libraryIDForItem(item)
 
 	// Get all enabled quality presets for fallback
 	allPresets, _ := s.db.GetQualityPresets()
@@ -865,6 +1215,9 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 		presetsToTry = append(presetsToTry, nil)
 	}
 
+	// Runtime for size and bitrate limit checks
+	runtime := s.lookupRuntime(item)
+
 	// Try each preset until we find an acceptable result
 	var bestResult *indexer.ScoredSearchResult
 	var usedPresetID *int64
@@ -873,16 +1226,16 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 		if presetID != nil {
 			for _, p := range allPresets {
 				if p.ID == *presetID {
-					log.Printf("Scheduler: trying preset %d/%d: '%s' (res=%s, src=%s)", 
+					logger.Infof("Scheduler: trying preset %d/%d: '%s' (res=%s, src=%s)", 
 						presetIdx+1, len(presetsToTry), p.Name, p.Resolution, p.Source)
 					break
 				}
 			}
 		} else {
-			log.Printf("Scheduler: trying preset %d/%d: <no preset - accept all>", presetIdx+1, len(presetsToTry))
+			logger.Infof("Scheduler: trying preset %d/%d: <no preset - accept all>", presetIdx+1, len(presetsToTry))
 		}
 
-		scoredResults := s.scoreResultsWithPreset(results, presetID)
+		scoredResults := s.scoreResultsWithPreset(results, presetID, runtime, firstSeen)
 
 		// Count how many passed vs rejected
 		passed := 0
@@ -891,7 +1244,7 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 				passed++
 			}
 		}
-		log.Printf("Scheduler: %d/%d results passed preset filter", passed, len(scoredResults))
+		logger.Infof("Scheduler: %d/%d results passed preset filter", passed, len(scoredResults))
 
 		// Find best non-rejected, non-blocklisted result for this preset
 		for i := range scoredResults {
@@ -902,7 +1255,11 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 			// Verify the release actually matches the wanted item (title + year)
 			matches, reason := s.verifyReleaseMatch(scoredResults[i].Title, item)
 			if !matches {
-				log.Printf("Scheduler: rejecting %s - %s", scoredResults[i].Title, reason)
+				logger.Infof("Scheduler: rejecting %s - %s", scoredResults[i].Title, reason)
+				continue
+			}
+			if reason := editionRejection(scoredResults[i].Title, item); reason != "" {
+				logger.Infof("Scheduler: rejecting %s - %s", scoredResults[i].Title, reason)
 				continue
 			}
 
@@ -911,6 +1268,10 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 			if blocked {
 				continue
 			}
+			if reason := s.exclusionRejection(scoredResults[i].Title, item); reason != "" {
+				logger.Infof("Scheduler: rejecting %s - %s", scoredResults[i].Title, reason)
+				continue
+			}
 
 			// Check if indexer is excluded for this library
 			if libraryID > 0 {
@@ -928,11 +1289,11 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 			// For upgrade searches, only accept releases with higher quality score than current
 			if item.IsUpgrade && item.CurrentScore > 0 {
 				if scoredResults[i].TotalScore <= item.CurrentScore {
-					log.Printf("Scheduler: skipping %s for upgrade - release score %d not higher than current score %d",
+					logger.Infof("Scheduler: skipping %s for upgrade - release score %d not higher than current score %d",
 						scoredResults[i].Title, scoredResults[i].TotalScore, item.CurrentScore)
 					continue
 				}
-				log.Printf("Scheduler: upgrade candidate %s - release score %d > current score %d",
+				logger.Infof("Scheduler: upgrade candidate %s - release score %d > current score %d",
 					scoredResults[i].Title, scoredResults[i].TotalScore, item.CurrentScore)
 			}
 
@@ -954,17 +1315,25 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 						break
 					}
 				}
-				log.Printf("Scheduler: using fallback preset '%s' for %s", presetName, item.Title)
+				logger.Infof("Scheduler: using fallback preset '%s' for %s", presetName, item.Title)
 			}
 			break
 		}
 	}
 
+	// Keep the decisions of the preset that found a release, or the first
+	// preset tried when none did
+	if bestResult == nil {
+		usedPresetID = presetsToTry[0]
+	}
+	activity.PresetID = usedPresetID
+	activity.Considered = s.consideredReleases(results, usedPresetID, runtime, firstSeen, item, libraryID)
+
 	if bestResult == nil {
-		log.Printf("Scheduler: no acceptable releases for %s after trying %d presets", item.Title, len(presetsToTry))
+		logger.Infof("Scheduler: no acceptable releases for %s after trying %d presets", item.Title, len(presetsToTry))
+		activity.Outcome = "no acceptable release"
 		return
 	}
-	_ = usedPresetID // Mark as used
 
 	// Check if delay profile applies
 	shouldDelay, availableAt := s.shouldDelayGrab(bestResult, libraryID)
@@ -981,7 +1350,8 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 			IndexerID:    &bestResult.IndexerID,
 			AvailableAt:  availableAt,
 		})
-		log.Printf("Scheduler: delayed grab until %s: %s for %s", availableAt.Format(time.RFC3339), bestResult.Title, item.Title)
+		logger.Infof("Scheduler: delayed grab until %s: %s for %s", availableAt.Format(time.RFC3339), bestResult.Title, item.Title)
+		activity.Outcome, activity.Release, activity.Until = "delayed", bestResult.Title, &availableAt
 		return
 	}
 
@@ -989,48 +1359,36 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 	// Collect all acceptable results for failover
 	var acceptableResults []*indexer.ScoredSearchResult
 	for _, presetID := range presetsToTry {
-		scoredResults := s.scoreResultsWithPreset(results, presetID)
+		scoredResults := s.scoreResultsWithPreset(results, presetID, runtime, firstSeen)
 		for i := range scoredResults {
-			if scoredResults[i].Rejected || scoredResults[i].TotalScore <= 0 {
-				continue
-			}
-			matches, _ := s.verifyReleaseMatch(scoredResults[i].Title, item)
-			if !matches {
-				continue
-			}
-			blocked, _ := s.db.IsReleaseBlocklisted(scoredResults[i].Title)
-			if blocked {
-				continue
-			}
-			if libraryID > 0 {
-				excluded, _ := s.db.IsIndexerExcludedForLibrary(scoredResults[i].IndexerID, libraryID)
-				if excluded {
-					continue
-				}
-			}
-			if item.QualityProfileID > 0 && !s.passesReleaseFilters(scoredResults[i].Title, item.QualityProfileID) {
-				continue
-			}
-			// For upgrade searches, only accept releases with higher quality score than current
-			if item.IsUpgrade && item.CurrentScore > 0 && scoredResults[i].TotalScore <= item.CurrentScore {
+			if s.candidateRejection(&scoredResults[i], item, libraryID) != "" {
 				continue
 			}
 			acceptableResults = append(acceptableResults, &scoredResults[i])
 		}
 	}
 
+	// Remember the ranked candidates so a stalled download can be swapped
+	// for the next-best release without searching again
+	if data, err := json.Marshal(acceptableResults); err == nil {
+		if err := s.db.SaveSearchCandidates(item.Type, item.TmdbID, string(data)); err != nil {
+			logger.Errorf("Scheduler: failed to save search candidates for %s: %v", item.Title, err)
+		}
+	}
+
 	// Try each acceptable result until one succeeds
 	var grabbed bool
 	for i, result := range acceptableResults {
-		log.Printf("Scheduler: trying to grab %s (score: %d, seeders: %d, indexer: %s)",
+		logger.Infof("Scheduler: trying to grab %s (score: %d, seeders: %d, indexer: %s)",
 			result.Title, result.TotalScore, result.Seeders, result.IndexerName)
 		err = s.grabRelease(result, item.Type, item.TmdbID)
 		if err == nil {
-			log.Printf("Scheduler: grabbed %s for %s (score: %d, seeders: %d)", result.Title, item.Title, result.TotalScore, result.Seeders)
+			logger.Infof("Scheduler: grabbed %s for %s (score: %d, seeders: %d)", result.Title, item.Title, result.TotalScore, result.Seeders)
+			activity.Outcome, activity.Release = "grabbed", result.Title
 			grabbed = true
 			break
 		}
-		log.Printf("Scheduler: grab failed for %s, trying next: %v", result.Title, err)
+		logger.Errorf("Scheduler: grab failed for %s, trying next: %v", result.Title, err)
 		// Add delay between retries to avoid rate limiting
 		if i < len(acceptableResults)-1 {
 			if strings.Contains(err.Error(), "429") {
@@ -1042,10 +1400,207 @@ func (s *Scheduler) searchAndGrab(item *database.WantedItem) {
 	}
 
 	if !grabbed {
-		log.Printf("Scheduler: all grab attempts failed for %s", item.Title)
+		logger.Errorf("Scheduler: all grab attempts failed for %s", item.Title)
+		activity.Outcome, activity.Release = "grab failed", bestResult.Title
 	}
 }
 
+// searchReleases runs an indexer search for a wanted item, using its IMDB and
+// TVDB IDs when known and the indexers tagged for its media type, and drops
+// adult content from the results. Fallback queries are tried when too few
+// releases match; the queries run are returned with what each found.
+func (s *Scheduler) searchReleases(item *database.WantedItem) ([]indexer.SearchResult, []QueryAttempt, error) {
+	searchType := "movie"
+	mediaTypeForCategories := "movie"
+	if item.Type == "show" {
+		searchType = "tvsearch"
+		mediaTypeForCategories = "tv"
+	} else if item.Type == "anime" {
+		searchType = "tvsearch" // Anime uses TV search type
+		mediaTypeForCategories = "anime"
+	}
+
+	params := indexer.SearchParams{
+		Query:      item.Title,
+		Type:       searchType,
+		Limit:      50,
+		Categories: database.GetCategoriesForMediaType(mediaTypeForCategories),
+	}
+
+	// Add TMDB ID if available
+	if item.TmdbID > 0 {
+		params.TmdbID = strconv.FormatInt(item.TmdbID, 10)
+	}
+
+	// Look up IMDB ID from the movie/show record for more accurate searches
+	imdbID := s.lookupImdbID(item.Type, item.TmdbID)
+	if imdbID != "" {
+		params.ImdbID = imdbID
+		logger.Infof("Scheduler: found IMDB ID %s for %s (TMDB: %d)", imdbID, item.Title, item.TmdbID)
+	} else {
+		logger.Infof("Scheduler: no IMDB ID found for %s (TMDB: %d) - using title search", item.Title, item.TmdbID)
+	}
+
+	// For TV shows/anime, also look up TVDB ID
+	if item.Type == "show" || item.Type == "anime" {
+		tvdbID := s.lookupTvdbID(item.TmdbID)
+		if tvdbID != "" {
+			params.TvdbID = tvdbID
+			logger.Infof("Scheduler: found TVDB ID %s for %s", tvdbID, item.Title)
+		}
+	}
+
+	// Log search parameters for debugging
+	logger.Infof("Scheduler: SEARCH PARAMS for '%s' (%s):", item.Title, item.Type)
+	logger.Infof("  - Query: %s", params.Query)
+	logger.Infof("  - Type: %s", params.Type)
+	logger.Infof("  - IMDB ID: %s (enables exact matching)", params.ImdbID)
+	logger.Infof("  - TVDB ID: %s", params.TvdbID)
+	logger.Infof("  - TMDB ID: %s", params.TmdbID)
+	logger.Infof("  - Categories: %v", params.Categories)
+
+	// Get indexers for this media type based on library tags
+	indexerIDs := s.getIndexerIDsForMediaType(item.Type)
+	logger.Infof("Scheduler: using %d indexer IDs for search", len(indexerIDs))
+
+	results, err := s.runSearch(params, indexerIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range results {
+		results[i].QueryStrategy = indexer.QueryExact
+	}
+	attempts := []QueryAttempt{{
+		Strategy: indexer.QueryExact,
+		Query:    params.Query,
+		Results:  len(results),
+		Matching: s.countMatching(results, item),
+	}}
+
+	results, attempts = s.searchFallbacks(item, params, indexerIDs, results, attempts)
+	return results, attempts, nil
+}
+
+// runSearch searches the given indexers, or all of them if none are given,
+// dropping adult content from the results
+func (s *Scheduler) runSearch(params indexer.SearchParams, indexerIDs []int64) ([]indexer.SearchResult, error) {
+	var results []indexer.SearchResult
+	var err error
+	if len(indexerIDs) > 0 {
+		results, err = s.indexers.SearchWithIndexerIDs(params, indexerIDs)
+	} else {
+		results, err = s.indexers.Search(params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Scheduler: found %d raw results for '%s'", len(results), params.Query)
+
+	// Filter out adult content (category 6000-6999)
+	results = filterAdultContent(results)
+	logger.Infof("Scheduler: %d results after adult content filtering", len(results))
+	return results, nil
+}
+
+// candidateRejection checks a scored result against the wanted item: score,
+// title match, edition, blocklist, the item's release exclusions,
+// per-library indexer exclusions, legacy release filters and, for upgrades,
+// the current score. Returns why the
+// result can't be grabbed, or "" if it can.
+func (s *Scheduler) candidateRejection(result *indexer.ScoredSearchResult, item *database.WantedItem, libraryID int64) string {
+	if result.Rejected {
+		return result.RejectionReason
+	}
+	if result.TotalScore <= 0 {
+		return fmt.Sprintf("score %d is not positive", result.TotalScore)
+	}
+	if matches, reason := s.verifyReleaseMatch(result.Title, item); !matches {
+		return "title mismatch: " + reason
+	}
+	if reason := editionRejection(result.Title, item); reason != "" {
+		return reason
+	}
+	if blocked, _ := s.db.IsReleaseBlocklisted(result.Title); blocked {
+		return "blocklisted"
+	}
+	if reason := s.exclusionRejection(result.Title, item); reason != "" {
+		return reason
+	}
+	if libraryID > 0 {
+		if excluded, _ := s.db.IsIndexerExcludedForLibrary(result.IndexerID, libraryID); excluded {
+			return "indexer " + result.IndexerName + " is excluded for this library"
+		}
+	}
+	if item.QualityProfileID > 0 && !s.passesReleaseFilters(result.Title, item.QualityProfileID) {
+		return "release filters"
+	}
+	// For upgrade searches, only accept releases with higher quality score than current
+	if item.IsUpgrade && item.CurrentScore > 0 && result.TotalScore <= item.CurrentScore {
+		return fmt.Sprintf("score %d is not above the current score %d", result.TotalScore, item.CurrentScore)
+	}
+	return ""
+}
+
+// editionRejection checks a release's edition against the wanted movie's.
+// Movies wanted in an edition only take releases of it. Movies wanted in the
+// theatrical edition, and upgrades of files in it, don't take releases of
+// other editions, as those are other versions rather than better copies.
+// Returns why the release can't be grabbed, or "" if it can.
+func editionRejection(title string, item *database.WantedItem) string {
+	if item.Type != "movie" {
+		return ""
+	}
+	wanted := ""
+	if item.Edition != nil {
+		wanted = *item.Edition
+	}
+	release := parser.Parse(title)
+
+	if wanted != "" && wanted != "theatrical" {
+		for _, edition := range release.Editions {
+			if edition == wanted {
+				return ""
+			}
+		}
+		return "not the " + parser.EditionLabel(wanted) + " edition"
+	}
+	if (wanted == "theatrical" || item.IsUpgrade) && release.Edition != "" && release.Edition != "theatrical" {
+		return "another edition (" + parser.EditionLabel(release.Edition) + ")"
+	}
+	return ""
+}
+
+// exclusionRejection checks a release against the wanted item's release
+// exclusions. Returns why the release can't be grabbed, or "" if it can.
+func (s *Scheduler) exclusionRejection(title string, item *database.WantedItem) string {
+	exclusion, err := s.db.ReleaseExcluded(item.Type, item.TmdbID, title, parser.Parse(title).ReleaseGroup)
+	if err != nil {
+		logger.Errorf("Scheduler: failed to check release exclusions of %s: %v", item.Title, err)
+		return ""
+	}
+	if exclusion == nil {
+		return ""
+	}
+	return exclusion.Describe()
+}
+
+// libraryIDForItem returns the first library of the wanted item's type, used
+// for per-library indexer exclusions and delay profiles. Returns 0 if none.
+func (s *Scheduler) libraryIDForItem(item *database.WantedItem) int64 {
+	libraries, _ := s.db.GetLibraries()
+	libType := "movies"
+	if item.Type == "show" {
+		libType = "tv"
+	}
+	for _, lib := range libraries {
+		if lib.Type == libType {
+			return lib.ID
+		}
+	}
+	return 0
+}
+
 func (s *Scheduler) scoreResults(results []indexer.SearchResult, profileID int64) []indexer.ScoredSearchResult {
 	var profile *quality.Profile
 	var customFormats []quality.CustomFormatDef
@@ -1083,6 +1638,7 @@ func (s *Scheduler) scoreResults(results []indexer.SearchResult, profileID int64
 	scoredResults := make([]indexer.ScoredSearchResult, 0, len(results))
 	for _, result := range results {
 		parsed := parser.Parse(result.Title)
+		parsed.Size = result.Size
 		qualityTier := quality.ComputeQualityTier(parsed)
 
 		// Convert HDR string to slice (indexer uses []string)
@@ -1146,11 +1702,7 @@ func (s *Scheduler) grabRelease(result *indexer.ScoredSearchResult, mediaType st
 		downloadURL = result.Link
 	}
 
-	// Prowlarr uses "prowlarr" as type, treat as torrent unless explicitly newznab
-	isTorrent := result.IndexerType == "torznab" || result.IndexerType == "prowlarr" || result.MagnetLink != ""
-	if result.IndexerType == "newznab" && result.MagnetLink == "" {
-		isTorrent = false
-	}
+	isTorrent := result.IsTorrent()
 
 	clients, err := s.db.GetEnabledDownloadClients()
 	if err != nil {
@@ -1178,10 +1730,11 @@ func (s *Scheduler) grabRelease(result *indexer.ScoredSearchResult, mediaType st
 
 	// Attempt to add to download client
 	var grabErr error
+	priority := s.downloadPriority(mediaType, mediaID)
 	if isTorrent {
-		grabErr = s.downloads.AddTorrent(targetClient.ID, downloadURL, category)
+		grabErr = s.downloads.AddTorrent(targetClient.ID, downloadURL, category, priority)
 	} else {
-		grabErr = s.downloads.AddNZB(targetClient.ID, downloadURL, category)
+		grabErr = s.downloads.AddNZB(targetClient.ID, downloadURL, category, priority)
 	}
 
 	// Record grab in history
@@ -1211,7 +1764,7 @@ func (s *Scheduler) grabRelease(result *indexer.ScoredSearchResult, mediaType st
 	}
 
 	if dbErr := s.db.AddGrabHistory(grabHistory); dbErr != nil {
-		log.Printf("Scheduler: failed to record grab history: %v", dbErr)
+		logger.Errorf("Scheduler: failed to record grab history: %v", dbErr)
 	}
 
 	return grabErr
@@ -1260,7 +1813,7 @@ func (s *Scheduler) passesReleaseFilters(releaseName string, presetID int64) boo
 			// Use regex matching
 			re, err := regexp.Compile("(?i)" + filter.Value)
 			if err != nil {
-				log.Printf("Scheduler: invalid release filter regex: %s", filter.Value)
+				logger.Infof("Scheduler: invalid release filter regex: %s", filter.Value)
 				continue
 			}
 			matches = re.MatchString(releaseName)
@@ -1272,12 +1825,12 @@ func (s *Scheduler) passesReleaseFilters(releaseName string, presetID int64) boo
 		switch filter.FilterType {
 		case "must_contain":
 			if !matches {
-				log.Printf("Scheduler: release rejected (must_contain: %s): %s", filter.Value, releaseName)
+				logger.Infof("Scheduler: release rejected (must_contain: %s): %s", filter.Value, releaseName)
 				return false
 			}
 		case "must_not_contain":
 			if matches {
-				log.Printf("Scheduler: release rejected (must_not_contain: %s): %s", filter.Value, releaseName)
+				logger.Infof("Scheduler: release rejected (must_not_contain: %s): %s", filter.Value, releaseName)
 				return false
 			}
 		}
@@ -1431,16 +1984,22 @@ func (s *Scheduler) matchesPreset(parsed *parser.ParsedRelease, preset *database
 		return false, fmt.Sprintf("unacceptable codec: %s", parsed.Codec)
 	}
 
-	// 7. CHECK MINIMUM SEEDERS
-	if preset.MinSeeders > 0 && parsed.Seeders > 0 && parsed.Seeders < preset.MinSeeders {
-		return false, fmt.Sprintf("insufficient seeders: %d < %d required", parsed.Seeders, preset.MinSeeders)
-	}
+	// Seeders are checked with the other grab thresholds in scoreResultsWithPreset
 
 	return true, ""
 }
 
-// scoreResultsWithPreset scores results based on preset criteria
-func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, presetID *int64) []indexer.ScoredSearchResult {
+// scoreResultsWithPreset scores results based on preset criteria. runtime may be
+// nil, in which case the preset's size and bitrate limits aren't checked.
+// firstSeen maps release titles to when they first showed up in searches.
+func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, presetID *int64, runtime *mediaRuntime, firstSeen map[string]time.Time) []indexer.ScoredSearchResult {
+	return s.scorePresetResults(results, presetID, runtime, firstSeen, true)
+}
+
+// scorePresetResults is scoreResultsWithPreset with control over whether
+// releases with permanently rejected formats are auto-blocklisted, which
+// simulations mustn't do
+func (s *Scheduler) scorePresetResults(results []indexer.SearchResult, presetID *int64, runtime *mediaRuntime, firstSeen map[string]time.Time, autoBlocklist bool) []indexer.ScoredSearchResult {
 	var preset *database.QualityPreset
 	if presetID != nil {
 		p, err := s.db.GetQualityPreset(*presetID)
@@ -1451,10 +2010,10 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 
 	// Log which preset is being evaluated
 	if preset != nil {
-		log.Printf("Scheduler: scoring %d results against preset '%s' (res=%s, src=%s)", 
+		logger.Infof("Scheduler: scoring %d results against preset '%s' (res=%s, src=%s)", 
 			len(results), preset.Name, preset.Resolution, preset.Source)
 	} else {
-		log.Printf("Scheduler: scoring %d results with no preset (accept all)", len(results))
+		logger.Infof("Scheduler: scoring %d results with no preset (accept all)", len(results))
 	}
 
 	var scoredResults []indexer.ScoredSearchResult
@@ -1487,7 +2046,7 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 		if !matches {
 			scored.Rejected = true
 			scored.RejectionReason = reason
-			log.Printf("Scheduler: REJECTED '%s' - %s", result.Title, reason)
+			logger.Infof("Scheduler: REJECTED '%s' - %s", result.Title, reason)
 		}
 
 		// Check for blocked releases
@@ -1503,7 +2062,7 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 				scored.Rejected = true
 				scored.RejectionReason = rejection.Reason
 				// Auto-blocklist if configured
-				if formatSettings != nil && formatSettings.AutoBlocklist && rejection.Permanent {
+				if autoBlocklist && formatSettings != nil && formatSettings.AutoBlocklist && rejection.Permanent {
 					s.db.AddToBlocklist(&database.BlocklistEntry{
 						ReleaseTitle: result.Title,
 						Reason:       rejection.Reason,
@@ -1512,6 +2071,24 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 			}
 		}
 
+		// Check seeder, age and first-seen thresholds
+		if !scored.Rejected {
+			if rejection := quality.CheckGrabThresholds(&result, firstSeen[result.Title], preset); rejection != nil {
+				scored.Rejected = true
+				scored.RejectionReason = rejection.Reason
+				logger.Infof("Scheduler: REJECTED '%s' - %s", result.Title, rejection.Reason)
+			}
+		}
+
+		// Check size and bitrate limits against the runtime the release covers
+		if !scored.Rejected && quality.HasSizeLimits(preset) {
+			if rejection := quality.CheckSizeLimits(result.Size, s.releaseRuntime(parsed, runtime), preset); rejection != nil {
+				scored.Rejected = true
+				scored.RejectionReason = rejection.Reason
+				logger.Infof("Scheduler: REJECTED '%s' - %s", result.Title, rejection.Reason)
+			}
+		}
+
 		// Calculate base score based on quality tier
 		qualityTier := quality.ComputeQualityTier(parsed)
 		scored.BaseScore = quality.BaseQualityScores[qualityTier]
@@ -1549,7 +2126,7 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 			if result.Seeders == 0 {
 				scored.Rejected = true
 				scored.RejectionReason = "no seeders"
-				log.Printf("Scheduler: rejecting %s - no seeders reported", result.Title)
+				logger.Infof("Scheduler: rejecting %s - no seeders reported", result.Title)
 			} else if result.Seeders >= 50 {
 				scored.TotalScore += 50 // Excellent seeding
 			} else if result.Seeders >= 20 {
@@ -1561,7 +2138,7 @@ func (s *Scheduler) scoreResultsWithPreset(results []indexer.SearchResult, prese
 			} else if result.Seeders < 3 {
 				// Heavy penalty for very low seeds - likely to stall or fail
 				scored.TotalScore -= 100
-				log.Printf("Scheduler: penalizing low-seeder release %s (seeders: %d)", result.Title, result.Seeders)
+				logger.Infof("Scheduler: penalizing low-seeder release %s (seeders: %d)", result.Title, result.Seeders)
 			}
 		}
 
@@ -1616,7 +2193,7 @@ func (s *Scheduler) shouldDelayGrab(result *indexer.ScoredSearchResult, libraryI
 
 		// Apply delay
 		availableAt := time.Now().Add(time.Duration(profile.DelayMinutes) * time.Minute)
-		log.Printf("Scheduler: delaying grab for %d minutes: %s", profile.DelayMinutes, result.Title)
+		logger.Infof("Scheduler: delaying grab for %d minutes: %s", profile.DelayMinutes, result.Title)
 		return true, availableAt
 	}
 
@@ -1710,7 +2287,7 @@ func (s *Scheduler) getIndexerIDsForMediaType(mediaType string) []int64 {
 		if typeMatches {
 			filteredIDs = append(filteredIDs, idx.ID)
 		} else {
-			log.Printf("Scheduler: excluding indexer %s for %s search (content_types: %s)", idx.Name, mediaType, idx.ContentTypes)
+			logger.Infof("Scheduler: excluding indexer %s for %s search (content_types: %s)", idx.Name, mediaType, idx.ContentTypes)
 		}
 	}
 
@@ -1718,14 +2295,25 @@ func (s *Scheduler) getIndexerIDsForMediaType(mediaType string) []int64 {
 }
 
 func (s *Scheduler) shouldPauseDownloads() bool {
-	settings, err := s.db.GetAllSettings()
-	if err != nil {
+	enabled, thresholdGB := s.storagePauseSettings()
+	if !enabled {
 		return false
 	}
 
-	pauseEnabled := settings["storage_pause_enabled"] == "true"
-	if !pauseEnabled {
-		return false
+	path, freeGB, ok := s.lowestFreeSpace()
+	if ok && freeGB < thresholdGB {
+		logger.Infof("Scheduler: pausing downloads - low disk space on %s: %d GB free (threshold: %d GB)", path, freeGB, thresholdGB)
+		return true
+	}
+
+	return false
+}
+
+// storagePauseSettings returns whether low storage pausing is enabled and the threshold in GB
+func (s *Scheduler) storagePauseSettings() (bool, int64) {
+	settings, err := s.db.GetAllSettings()
+	if err != nil {
+		return false, 0
 	}
 
 	// Get threshold from settings
@@ -1737,12 +2325,19 @@ func (s *Scheduler) shouldPauseDownloads() bool {
 		}
 	}
 
-	// Check storage on all libraries
+	return settings["storage_pause_enabled"] == "true", thresholdGB
+}
+
+// lowestFreeSpace returns the library path with the least free space, in GB
+func (s *Scheduler) lowestFreeSpace() (string, int64, bool) {
 	libraries, err := s.db.GetLibraries()
 	if err != nil {
-		return false
+		return "", 0, false
 	}
 
+	var lowestPath string
+	var lowestFree int64
+	found := false
 	for _, lib := range libraries {
 		usage, err := storage.GetDiskUsage(lib.Path)
 		if err != nil {
@@ -1750,13 +2345,12 @@ func (s *Scheduler) shouldPauseDownloads() bool {
 		}
 
 		freeGB := int64(usage.Free / (1024 * 1024 * 1024))
-		if freeGB < thresholdGB {
-			log.Printf("Scheduler: pausing downloads - low disk space on %s: %d GB free (threshold: %d GB)", lib.Path, freeGB, thresholdGB)
-			return true
+		if !found || freeGB < lowestFree {
+			lowestPath, lowestFree, found = lib.Path, freeGB, true
 		}
 	}
 
-	return false
+	return lowestPath, lowestFree, found
 }
 
 func (s *Scheduler) checkRSSFeeds() {
@@ -1778,13 +2372,13 @@ func (s *Scheduler) checkRSSFeeds() {
 		return
 	}
 
-	log.Printf("Scheduler: checking RSS feeds from %d indexers", len(indexers))
+	logger.Infof("Scheduler: checking RSS feeds from %d indexers", len(indexers))
 
 	for _, idx := range indexers {
 		// Fetch RSS feed
 		results, err := s.indexers.FetchRSS(idx.ID)
 		if err != nil {
-			log.Printf("Scheduler: RSS fetch failed for %s: %v", idx.Name, err)
+			logger.Errorf("Scheduler: RSS fetch failed for %s: %v", idx.Name, err)
 			continue
 		}
 
@@ -1808,7 +2402,7 @@ func (s *Scheduler) matchRSSResult(result indexer.SearchResult, items []database
 			itemImdb := strings.TrimPrefix(*item.ImdbID, "tt")
 			if resultImdb == itemImdb {
 				// IMDB match - proceed with this item
-				log.Printf("Scheduler: RSS IMDB match for %s (tt%s)", item.Title, itemImdb)
+				logger.Infof("Scheduler: RSS IMDB match for %s (tt%s)", item.Title, itemImdb)
 				s.processRSSMatch(result, item)
 				continue
 			}
@@ -1819,7 +2413,7 @@ func (s *Scheduler) matchRSSResult(result indexer.SearchResult, items []database
 			// Try to get TVDB ID for this show
 			itemTvdbID := s.lookupTvdbID(item.TmdbID)
 			if itemTvdbID != "" && result.TvdbID == itemTvdbID {
-				log.Printf("Scheduler: RSS TVDB match for %s (tvdb:%s)", item.Title, itemTvdbID)
+				logger.Infof("Scheduler: RSS TVDB match for %s (tvdb:%s)", item.Title, itemTvdbID)
 				s.processRSSMatch(result, item)
 				continue
 			}
@@ -1829,7 +2423,7 @@ func (s *Scheduler) matchRSSResult(result indexer.SearchResult, items []database
 		matches, reason := s.verifyReleaseMatch(result.Title, &item)
 		if !matches {
 			if reason != "" {
-				log.Printf("DEBUG: RSS title mismatch for %s: %s - %s", item.Title, result.Title, reason)
+				logger.Debugf("RSS title mismatch for %s: %s - %s", item.Title, result.Title, reason)
 			}
 			continue
 		}
@@ -1849,10 +2443,21 @@ func (s *Scheduler) processRSSMatch(result indexer.SearchResult, item database.W
 	// Check auto-grab
 	autoGrab, _ := s.db.GetSetting("scheduler_auto_grab")
 	if autoGrab != "true" {
-		log.Printf("Scheduler: RSS match for %s: %s (auto-grab disabled)", item.Title, result.Title)
+		logger.Infof("Scheduler: RSS match for %s: %s (auto-grab disabled)", item.Title, result.Title)
 		return
 	}
 
+	// Apply the preset's seeder and age thresholds
+	if item.QualityPresetID != nil {
+		if preset, err := s.db.GetQualityPreset(*item.QualityPresetID); err == nil {
+			firstSeen, _ := s.db.RecordReleaseSightings([]string{result.Title})
+			if rejection := quality.CheckGrabThresholds(&result, firstSeen[result.Title], preset); rejection != nil {
+				logger.Infof("Scheduler: RSS match for %s rejected: %s - %s", item.Title, result.Title, rejection.Reason)
+				return
+			}
+		}
+	}
+
 	// Check minimum score threshold
 	minScore := 0
 	if minScoreStr, _ := s.db.GetSetting("scheduler_min_score"); minScoreStr != "" {
@@ -1865,11 +2470,11 @@ func (s *Scheduler) processRSSMatch(result indexer.SearchResult, item database.W
 
 	err := s.grabRelease(&scored[0], item.Type, item.TmdbID)
 	if err != nil {
-		log.Printf("Scheduler: RSS grab failed for %s: %v", item.Title, err)
+		logger.Errorf("Scheduler: RSS grab failed for %s: %v", item.Title, err)
 		return
 	}
 
-	log.Printf("Scheduler: RSS grabbed %s for %s (score: %d)", result.Title, item.Title, scored[0].TotalScore)
+	logger.Infof("Scheduler: RSS grabbed %s for %s (score: %d)", result.Title, item.Title, scored[0].TotalScore)
 }
 
 func (s *Scheduler) titleMatches(releaseTitle, wantedTitle string, year int) bool {
@@ -1923,7 +2528,7 @@ func filterAdultContent(results []indexer.SearchResult) []indexer.SearchResult {
 	filtered := make([]indexer.SearchResult, 0, len(results))
 	for _, r := range results {
 		if isAdultCategory(r.CategoryID) {
-			log.Printf("Scheduler: filtering adult content: %s (category: %s)", r.Title, r.CategoryID)
+			logger.Infof("Scheduler: filtering adult content: %s (category: %s)", r.Title, r.CategoryID)
 			continue
 		}
 		filtered = append(filtered, r)
@@ -2088,7 +2693,7 @@ func (s *Scheduler) verifyReleaseMatch(releaseTitle string, item *database.Wante
 
 	// Log rejected results at debug level
 	if score.CombinedScore < 60 {
-		log.Printf("DEBUG: Rejected '%s' for '%s' - title:%d%%, year:%d%%, type:%d%%, combined:%d%% - %s",
+		logger.Debugf("Rejected '%s' for '%s' - title:%d%%, year:%d%%, type:%d%%, combined:%d%% - %s",
 			releaseTitle, item.Title, score.TitleScore, score.YearScore, score.TypeScore, score.CombinedScore, score.Reason)
 		return false, score.Reason
 	}
@@ -2143,7 +2748,7 @@ func (s *Scheduler) calculateMatchScore(releaseTitle string, item *database.Want
 		score.TypeScore = 0
 		score.CombinedScore = 0
 		score.Reason = "contains adult content keywords"
-		log.Printf("DEBUG: Adult content detected in '%s'", releaseTitle)
+		logger.Debugf("Adult content detected in '%s'", releaseTitle)
 		return score
 	}
 
@@ -2154,6 +2759,17 @@ func (s *Scheduler) calculateMatchScore(releaseTitle string, item *database.Want
 	// First try word-based matching for better accuracy
 	score.TitleScore = calculateTitleScore(releaseTitleNorm, wantedTitleNorm)
 
+	// Releases found by a fallback search may use another of the titles
+	for _, alt := range item.AlternateTitles {
+		altNorm := normalizeTitle(alt)
+		if altNorm == "" {
+			continue
+		}
+		if altScore := calculateTitleScore(releaseTitleNorm, altNorm); altScore > score.TitleScore {
+			score.TitleScore = altScore
+		}
+	}
+
 	if score.TitleScore < 80 {
 		score.Reason = fmt.Sprintf("title similarity too low: %d%% (wanted '%s', got '%s')",
 			score.TitleScore, item.Title, parsed.Title)
@@ -2175,7 +2791,7 @@ func (s *Scheduler) calculateMatchScore(releaseTitle string, item *database.Want
 			// Year mismatch >1 year is a hard reject
 			score.YearScore = 0
 			score.Reason = fmt.Sprintf("year mismatch: wanted %d, got %d (diff: %d years)", item.Year, parsed.Year, yearDiff)
-			log.Printf("DEBUG: Year mismatch for '%s' - wanted %d, got %d", releaseTitle, item.Year, parsed.Year)
+			logger.Debugf("Year mismatch for '%s' - wanted %d, got %d", releaseTitle, item.Year, parsed.Year)
 		}
 	}
 	// If release doesn't have year, don't penalize (keep default 100)
@@ -2324,7 +2940,7 @@ func sortPresetsByQuality(presets []database.QualityPreset) []database.QualityPr
 func (s *Scheduler) runTraktSyncTask() int {
 	configs, err := s.db.GetAllTraktConfigs()
 	if err != nil {
-		log.Printf("Failed to get Trakt configs: %v", err)
+		logger.Errorf("Failed to get Trakt configs: %v", err)
 		return 0
 	}
 
@@ -2336,14 +2952,14 @@ func (s *Scheduler) runTraktSyncTask() int {
 	clientSecret, _ := s.db.GetSetting("trakt_client_secret")
 
 	if clientID == "" || clientSecret == "" {
-		log.Printf("Trakt client ID or secret not configured")
+		logger.Infof("Trakt client ID or secret not configured")
 		return 0
 	}
 
 	processed := 0
 	// Clear old fingerprint-based segments to allow re-detection with improved algorithm
 	s.db.DeleteMediaSegmentsBySource("fingerprint")
-	log.Printf("Scheduler: cleared old fingerprint-based intro segments")
+	logger.Infof("Scheduler: cleared old fingerprint-based intro segments")
 	for _, config := range configs {
 		if !config.SyncEnabled || config.AccessToken == "" {
 			continue
@@ -2352,7 +2968,7 @@ func (s *Scheduler) runTraktSyncTask() int {
 		// Get user's default profile
 		profile, err := s.db.GetDefaultProfile(config.UserID)
 		if err != nil || profile == nil {
-			log.Printf("No default profile for user %d, skipping Trakt sync", config.UserID)
+			logger.Infof("No default profile for user %d, skipping Trakt sync", config.UserID)
 			continue
 		}
 
@@ -2363,7 +2979,7 @@ func (s *Scheduler) runTraktSyncTask() int {
 		if client.NeedsRefresh() {
 			tokenResp, err := client.RefreshAccessToken()
 			if err != nil {
-				log.Printf("Trakt token refresh failed for user %d: %v", config.UserID, err)
+				logger.Errorf("Trakt token refresh failed for user %d: %v", config.UserID, err)
 				continue
 			}
 			expiresAt := time.Unix(tokenResp.CreatedAt, 0).Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
@@ -2388,6 +3004,7 @@ func (s *Scheduler) runTraktSyncTask() int {
 // syncTraktWatched syncs watched status between Trakt and local database
 func (s *Scheduler) syncTraktWatched(profileID int64, client *trakt.Client) int {
 	synced := 0
+	rule := s.db.GetPlayedRule(&profileID)
 
 	// Pull watched movies from Trakt
 	watchedMovies, err := client.GetWatchedMovies()
@@ -2399,7 +3016,7 @@ func (s *Scheduler) syncTraktWatched(profileID int64, client *trakt.Client) int
 			}
 			// Check if already watched
 			progress, _ := s.db.GetProgress(profileID, "movie", movie.ID)
-			if progress == nil || (progress.Duration > 0 && progress.Position/progress.Duration < 0.9) {
+			if progress == nil || !s.db.IsPlayed(rule, "movie", movie.ID, progress.Position, progress.Duration) {
 				// Mark as watched
 				s.db.SaveProgress(&database.Progress{
 					ProfileID: profileID,
@@ -2428,7 +3045,7 @@ func (s *Scheduler) syncTraktWatched(profileID int64, client *trakt.Client) int
 						continue
 					}
 					progress, _ := s.db.GetProgress(profileID, "episode", episode.ID)
-					if progress == nil || (progress.Duration > 0 && progress.Position/progress.Duration < 0.9) {
+					if progress == nil || !s.db.IsPlayed(rule, "episode", episode.ID, progress.Position, progress.Duration) {
 						s.db.SaveProgress(&database.Progress{
 							ProfileID: profileID,
 							MediaType: "episode",
@@ -2509,7 +3126,7 @@ func (s *Scheduler) syncTraktWatched(profileID int64, client *trakt.Client) int
 // runIntroDetectionTask analyzes episodes to detect intro/credits segments
 func (s *Scheduler) runIntroDetectionTask() int {
 	if s.scanner == nil {
-		log.Printf("Scheduler: scanner not available for intro detection")
+		logger.Infof("Scheduler: scanner not available for intro detection")
 		return 0
 	}
 
@@ -2518,38 +3135,38 @@ func (s *Scheduler) runIntroDetectionTask() int {
 
 	// Check if FFmpeg has chromaprint support
 	if !scanner.CheckFFmpegChromaprint() {
-		log.Printf("Scheduler: FFmpeg chromaprint not available, skipping intro detection")
+		logger.Infof("Scheduler: FFmpeg chromaprint not available, skipping intro detection")
 		return 0
 	}
 
 	processed := 0
 	// Clear old fingerprint-based segments to allow re-detection with improved algorithm
 	s.db.DeleteMediaSegmentsBySource("fingerprint")
-	log.Printf("Scheduler: cleared old fingerprint-based intro segments")
+	logger.Infof("Scheduler: cleared old fingerprint-based intro segments")
 
 	// Get all TV libraries
 	libraries, err := s.db.GetLibraries()
 	if err != nil {
-		log.Printf("Scheduler: failed to get libraries: %v", err)
+		logger.Errorf("Scheduler: failed to get libraries: %v", err)
 		return 0
 	}
 
 	for _, lib := range libraries {
 		if lib.Type != "tv" && lib.Type != "anime" {
-			log.Printf("Scheduler: skipping library %s (type=%s)", lib.Name, lib.Type)
+			logger.Infof("Scheduler: skipping library %s (type=%s)", lib.Name, lib.Type)
 			continue
 		}
 
-		log.Printf("Scheduler: processing TV library %s", lib.Name)
+		logger.Infof("Scheduler: processing TV library %s", lib.Name)
 
 		// Get shows in this library
 		shows, err := s.db.GetShowsByLibrary(lib.ID)
 		if err != nil {
-			log.Printf("Scheduler: failed to get shows for library %s: %v", lib.Name, err)
+			logger.Errorf("Scheduler: failed to get shows for library %s: %v", lib.Name, err)
 			continue
 		}
 
-		log.Printf("Scheduler: found %d shows in library %s", len(shows), lib.Name)
+		logger.Infof("Scheduler: found %d shows in library %s", len(shows), lib.Name)
 
 		for _, show := range shows {
 			// Get seasons for this show
@@ -2568,7 +3185,7 @@ func (s *Scheduler) runIntroDetectionTask() int {
 				// Check if any episodes need fingerprinting
 				needsAnalysis, err := s.db.GetEpisodesWithoutFingerprints(season.ID, 100)
 				if err != nil {
-					log.Printf("Scheduler: error checking fingerprints for %s S%d: %v", show.Title, season.SeasonNumber, err)
+					logger.Errorf("Scheduler: error checking fingerprints for %s S%d: %v", show.Title, season.SeasonNumber, err)
 					continue
 				}
 
@@ -2588,19 +3205,19 @@ func (s *Scheduler) runIntroDetectionTask() int {
 				}
 
 				if false && len(needsAnalysis) == 0 && hasIntros {
-					log.Printf("Scheduler: %s Season %d already has intros detected, skipping", show.Title, season.SeasonNumber)
+					logger.Infof("Scheduler: %s Season %d already has intros detected, skipping", show.Title, season.SeasonNumber)
 					continue
 				}
 
-				log.Printf("Scheduler: %s Season %d - %d episodes need fingerprints, hasIntros=%v",
+				logger.Infof("Scheduler: %s Season %d - %d episodes need fingerprints, hasIntros=%v",
 					show.Title, season.SeasonNumber, len(needsAnalysis), hasIntros)
 
-				log.Printf("Scheduler: analyzing intro for %s Season %d (%d episodes)",
+				logger.Infof("Scheduler: analyzing intro for %s Season %d (%d episodes)",
 					show.Title, season.SeasonNumber, len(episodes))
 
 				// Analyze the season (this will fingerprint missing episodes and detect intros)
 				if err := detector.AnalyzeSeason(season.ID); err != nil {
-					log.Printf("Scheduler: intro detection failed for %s S%d: %v",
+					logger.Errorf("Scheduler: intro detection failed for %s S%d: %v",
 						show.Title, season.SeasonNumber, err)
 					continue
 				}
@@ -2609,7 +3226,7 @@ func (s *Scheduler) runIntroDetectionTask() int {
 
 				// Limit processing per run to avoid long-running tasks
 				if processed >= 5 {
-					log.Printf("Scheduler: intro detection limit reached, will continue next run")
+					logger.Infof("Scheduler: intro detection limit reached, will continue next run")
 					return processed
 				}
 			}
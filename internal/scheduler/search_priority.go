@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/downloadclient"
+)
+
+// Search priority
+//
+// Monitored items come high priority first, and are searched for at an
+// interval that depends on their priority: high priority items twice as
+// often as the search interval, low priority ones half as often. The search
+// job ticks at the shortest of these, and searches the items that are due.
+// Releases grabbed for an item go to the download client at its priority.
+
+// searchTick is how often the search job runs
+func (s *Scheduler) searchTick() time.Duration {
+	return time.Duration(s.searchInterval) * time.Minute / 2
+}
+
+// itemSearchInterval is how long after its last search an item is searched
+// for again
+func (s *Scheduler) itemSearchInterval(item *database.WantedItem) time.Duration {
+	interval := time.Duration(s.searchInterval) * time.Minute
+	switch item.Priority {
+	case database.PriorityHigh:
+		return interval / 2
+	case database.PriorityLow:
+		return interval * 2
+	}
+	return interval
+}
+
+// searchDue reports whether a monitored item is due to be searched for
+func (s *Scheduler) searchDue(item *database.WantedItem) bool {
+	if item.LastSearched == nil {
+		return true
+	}
+	// Items are searched a little into a run, so an item is due on the tick
+	// nearest its interval rather than the one after
+	return time.Since(*item.LastSearched) >= s.itemSearchInterval(item)-s.searchTick()/2
+}
+
+// downloadPriority returns the download client priority of releases grabbed
+// for a wanted item
+func (s *Scheduler) downloadPriority(mediaType string, tmdbID int64) downloadclient.Priority {
+	item, err := s.db.GetWantedByTmdb(mediaType, tmdbID)
+	if err != nil {
+		return downloadclient.PriorityNormal
+	}
+	return downloadclient.ParsePriority(item.Priority)
+}
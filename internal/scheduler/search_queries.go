@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"strconv"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+)
+
+// TitleLookup fetches the original title of a movie or show and the other
+// titles it's known by
+type TitleLookup interface {
+	GetAlternateTitles(mediaType string, tmdbID int64) (original string, alternates []string, err error)
+}
+
+// SetTitleLookup sets where the titles for fallback search queries come from.
+// Without one, only fallbacks built from the wanted title are tried.
+func (s *Scheduler) SetTitleLookup(lookup TitleLookup) {
+	s.titles = lookup
+}
+
+// QueryAttempt is one query a wanted search ran and what it found
+type QueryAttempt struct {
+	Strategy string `json:"strategy"`
+	Query    string `json:"query"`
+	Results  int    `json:"results"`  // Releases no earlier query found
+	Matching int    `json:"matching"` // Of those, the releases matching the title
+	Error    string `json:"error,omitempty"`
+}
+
+// queryFallbacks returns the configured fallback strategies and how many
+// matching releases make a search good enough to stop
+func (s *Scheduler) queryFallbacks() ([]string, int) {
+	value, err := s.db.GetSetting(indexer.SettingQueryFallbacks)
+	if err != nil {
+		value = indexer.DefaultQueryFallbacks
+	}
+	strategies, err := indexer.ParseQueryFallbacks(value)
+	if err != nil {
+		logger.Errorf("Scheduler: ignoring search fallbacks: %v", err)
+		return nil, 0
+	}
+	minResults := 1
+	if v, _ := s.db.GetSetting(indexer.SettingFallbackMinResults); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			minResults = n
+		}
+	}
+	return strategies, minResults
+}
+
+// searchFallbacks tries the fallback queries for a wanted item in order until
+// enough releases match it. The IDs of the exact search are left out, as
+// they'd find the same releases again.
+func (s *Scheduler) searchFallbacks(item *database.WantedItem, params indexer.SearchParams, indexerIDs []int64, results []indexer.SearchResult, attempts []QueryAttempt) ([]indexer.SearchResult, []QueryAttempt) {
+	strategies, minResults := s.queryFallbacks()
+	matching := attempts[0].Matching
+	if len(strategies) == 0 || matching >= minResults {
+		return results, attempts
+	}
+
+	titles := indexer.QueryTitles{Title: item.Title, Year: item.Year, Movie: item.Type == "movie"}
+	if s.titles != nil && item.TmdbID > 0 {
+		original, alternates, err := s.titles.GetAlternateTitles(item.Type, item.TmdbID)
+		if err != nil {
+			logger.Warnf("Scheduler: couldn't look up other titles of %s: %v", item.Title, err)
+		} else {
+			titles.Original, titles.Alternates = original, alternates
+			// Releases found under another title still match the item
+			if original != "" {
+				item.AlternateTitles = append(item.AlternateTitles, original)
+			}
+			item.AlternateTitles = append(item.AlternateTitles, alternates...)
+		}
+	}
+
+	seen := make(map[string]bool, len(results))
+	for i := range results {
+		seen[resultKey(&results[i])] = true
+	}
+
+	for _, query := range indexer.FallbackQueries(titles, strategies) {
+		logger.Infof("Scheduler: %d matching results for %s, trying %s query '%s'", matching, item.Title, query.Strategy, query.Query)
+
+		fallback := params
+		fallback.Query = query.Query
+		fallback.ImdbID, fallback.TvdbID, fallback.TmdbID = "", "", ""
+
+		attempt := QueryAttempt{Strategy: query.Strategy, Query: query.Query}
+		found, err := s.runSearch(fallback, indexerIDs)
+		if err != nil {
+			logger.Errorf("Scheduler: %s query failed for %s: %v", query.Strategy, item.Title, err)
+			attempt.Error = err.Error()
+			attempts = append(attempts, attempt)
+			continue
+		}
+
+		var added []indexer.SearchResult
+		for i := range found {
+			key := resultKey(&found[i])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			found[i].QueryStrategy = query.Strategy
+			added = append(added, found[i])
+		}
+		attempt.Results = len(added)
+		attempt.Matching = s.countMatching(added, item)
+		attempts = append(attempts, attempt)
+
+		results = append(results, added...)
+		matching += attempt.Matching
+		if matching >= minResults {
+			break
+		}
+	}
+	return results, attempts
+}
+
+// countMatching counts the results whose titles match the wanted item
+func (s *Scheduler) countMatching(results []indexer.SearchResult, item *database.WantedItem) int {
+	n := 0
+	for i := range results {
+		if matches, _ := s.verifyReleaseMatch(results[i].Title, item); matches {
+			n++
+		}
+	}
+	return n
+}
+
+// resultKey identifies a release across searches
+func resultKey(result *indexer.SearchResult) string {
+	if result.GUID != "" {
+		return result.GUID
+	}
+	if result.Link != "" {
+		return result.Link
+	}
+	return strconv.FormatInt(result.IndexerID, 10) + ":" + result.Title
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/indexer"
+)
+
+// Preset simulation
+//
+// A simulation runs a real indexer search for a title and puts the results
+// through the same scoring, filters and delay profiles as an automatic
+// search with one preset, recording why each release was accepted or
+// rejected. Nothing is grabbed, blocklisted or recorded as seen.
+
+// SimulatedRelease is a search result and the decision made about it
+type SimulatedRelease struct {
+	indexer.ScoredSearchResult
+	Decision string `json:"decision"`       // grab, accepted or rejected
+	Rank     int    `json:"rank,omitempty"` // Order accepted releases would be tried in, from 1
+	Reason   string `json:"reason,omitempty"`
+}
+
+// PresetSimulation is the decision trace of a simulated search
+type PresetSimulation struct {
+	PresetID     int64              `json:"presetId"`
+	PresetName   string             `json:"presetName"`
+	MediaType    string             `json:"mediaType"`
+	TmdbID       int64              `json:"tmdbId"`
+	Title        string             `json:"title"`
+	Year         int                `json:"year,omitempty"`
+	Results      int                `json:"results"`
+	Accepted     int                `json:"accepted"`
+	Grab         *SimulatedRelease  `json:"grab,omitempty"`         // The release that would be grabbed
+	DelayedUntil *time.Time         `json:"delayedUntil,omitempty"` // Set when a delay profile would hold the grab
+	Notes        []string           `json:"notes,omitempty"`        // Things that would stop a real search grabbing
+	Queries      []QueryAttempt     `json:"queries"`                // The search's queries and what each found
+	Releases     []SimulatedRelease `json:"releases"`
+}
+
+// simulationItem builds the wanted item to simulate a search for: the wanted
+// list entry if there is one, otherwise the library item, otherwise the
+// given title and year
+func (s *Scheduler) simulationItem(mediaType string, tmdbID int64, title string, year int) (*database.WantedItem, error) {
+	if wanted, err := s.db.GetWantedByTmdb(mediaType, tmdbID); err == nil && wanted != nil {
+		return wanted, nil
+	}
+
+	item := &database.WantedItem{Type: mediaType, TmdbID: tmdbID, Title: title, Year: year}
+	if mediaType == "movie" {
+		if movie, err := s.db.GetMovieByTmdb(tmdbID); err == nil {
+			item.Title, item.Year = movie.Title, movie.Year
+		}
+	} else if show, err := s.db.GetShowByTmdb(tmdbID); err == nil {
+		item.Title, item.Year = show.Title, show.Year
+	}
+	if item.Title == "" {
+		return nil, fmt.Errorf("title is not in the library or wanted list, so a title is required")
+	}
+	return item, nil
+}
+
+// SimulatePreset searches for a title and returns how the preset would rank
+// and filter the results, without grabbing anything. The title is looked up
+// in the wanted list and library; title and year are used when it's in
+// neither.
+func (s *Scheduler) SimulatePreset(presetID int64, mediaType string, tmdbID int64, title string, year int) (*PresetSimulation, error) {
+	preset, err := s.db.GetQualityPreset(presetID)
+	if err != nil {
+		return nil, fmt.Errorf("preset not found")
+	}
+	item, err := s.simulationItem(mediaType, tmdbID, title, year)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &PresetSimulation{
+		PresetID:   preset.ID,
+		PresetName: preset.Name,
+		MediaType:  item.Type,
+		TmdbID:     item.TmdbID,
+		Title:      item.Title,
+		Year:       item.Year,
+		Releases:   []SimulatedRelease{},
+	}
+
+	// Conditions that would stop an automatic search before it grabs
+	if !preset.Enabled {
+		sim.Notes = append(sim.Notes, "Preset is disabled, so automatic searches only use it when a title is assigned to it")
+	}
+	if excluded, _ := s.db.IsMediaExcluded(item.TmdbID, item.Type); excluded {
+		sim.Notes = append(sim.Notes, "Title is excluded, so automatic searches skip it")
+	}
+	if s.shouldPauseDownloads() {
+		sim.Notes = append(sim.Notes, "Downloads are paused for low disk space")
+	}
+	if autoGrab, _ := s.db.GetSetting("scheduler_auto_grab"); autoGrab != "true" {
+		sim.Notes = append(sim.Notes, "Auto-grab is disabled, so automatic searches don't grab")
+	}
+
+	results, queries, err := s.searchReleases(item)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	sim.Results = len(results)
+	sim.Queries = queries
+
+	// Releases a real search hasn't seen before would be recorded as first
+	// seen now, so they count as just seen
+	titles := make([]string, len(results))
+	for i := range results {
+		titles[i] = results[i].Title
+	}
+	firstSeen, err := s.db.GetReleaseSightings(titles)
+	if err != nil {
+		firstSeen = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for _, title := range titles {
+		if _, ok := firstSeen[title]; !ok {
+			firstSeen[title] = now
+		}
+	}
+
+	libraryID := s.libraryIDForItem(item)
+	scored := s.scorePresetResults(results, &preset.ID, s.lookupRuntime(item), firstSeen, false)
+	for i := range scored {
+		release := SimulatedRelease{ScoredSearchResult: scored[i], Decision: "accepted"}
+		if reason := s.candidateRejection(&scored[i], item, libraryID); reason != "" {
+			release.Decision = "rejected"
+			release.Reason = reason
+		} else {
+			sim.Accepted++
+			release.Rank = sim.Accepted
+		}
+		sim.Releases = append(sim.Releases, release)
+	}
+
+	for i := range sim.Releases {
+		if sim.Releases[i].Rank != 1 {
+			continue
+		}
+		sim.Releases[i].Decision = "grab"
+		if delay, availableAt := s.shouldDelayGrab(&sim.Releases[i].ScoredSearchResult, libraryID); delay {
+			sim.DelayedUntil = &availableAt
+			sim.Releases[i].Reason = "held by a delay profile until " + availableAt.Format(time.RFC3339)
+		}
+		grab := sim.Releases[i]
+		sim.Grab = &grab
+		break
+	}
+
+	return sim, nil
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/parser"
+)
+
+// mediaRuntime is the runtime of a wanted item, used to check release sizes
+// against preset size and bitrate limits
+type mediaRuntime struct {
+	tmdbID   int64
+	isShow   bool
+	runtime  time.Duration // Movie runtime or average episode runtime
+	episodes map[int]int   // Season number -> episode count, filled on demand
+}
+
+// lookupRuntime returns the runtime of a wanted item, or nil if it isn't known
+func (s *Scheduler) lookupRuntime(item *database.WantedItem) *mediaRuntime {
+	if item.Type == "movie" {
+		movie, err := s.db.GetMovieByTmdb(item.TmdbID)
+		if err != nil || movie.Runtime == nil || *movie.Runtime <= 0 {
+			return nil
+		}
+		return &mediaRuntime{tmdbID: item.TmdbID, runtime: time.Duration(*movie.Runtime) * time.Minute}
+	}
+
+	minutes, _, err := s.db.GetEpisodeRuntime(item.TmdbID, -1)
+	if err != nil || minutes <= 0 {
+		return nil
+	}
+	return &mediaRuntime{
+		tmdbID:   item.TmdbID,
+		isShow:   true,
+		runtime:  time.Duration(minutes) * time.Minute,
+		episodes: make(map[int]int),
+	}
+}
+
+// releaseRuntime returns the total runtime a release covers: the movie, a
+// single or multi-episode release, or a whole season. Returns 0 when unknown.
+func (s *Scheduler) releaseRuntime(parsed *parser.ParsedRelease, rt *mediaRuntime) time.Duration {
+	if rt == nil {
+		return 0
+	}
+	if !rt.isShow {
+		return rt.runtime
+	}
+
+	if parsed.IsSeasonPack || (parsed.Season > 0 && parsed.Episode == 0) {
+		count, ok := rt.episodes[parsed.Season]
+		if !ok {
+			_, count, _ = s.db.GetEpisodeRuntime(rt.tmdbID, parsed.Season)
+			rt.episodes[parsed.Season] = count
+		}
+		return time.Duration(count) * rt.runtime
+	}
+
+	episodes := 1
+	if parsed.EpisodeEnd > parsed.Episode {
+		episodes = parsed.EpisodeEnd - parsed.Episode + 1
+	}
+	return time.Duration(episodes) * rt.runtime
+}
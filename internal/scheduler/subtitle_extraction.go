@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// Subtitle extraction
+//
+// Imports extract embedded subtitles in the background; the Subtitle
+// Extraction task catches up on files imported before that, files whose
+// extraction was cut short and files that moved, newest first, for up to
+// subtitleRunBudget a run.
+
+const (
+	// subtitleRunBudget is how long a run keeps starting new files
+	subtitleRunBudget = 45 * time.Minute
+	// subtitleBatchSize is how many files are looked up at a time
+	subtitleBatchSize = 20
+)
+
+// runSubtitleExtractionTask extracts subtitles that haven't been, returning
+// how many files were done
+func (s *Scheduler) runSubtitleExtractionTask() int {
+	if s.scanner == nil {
+		logger.Infof("Scheduler: scanner not available for subtitle extraction")
+		return 0
+	}
+	if err := s.db.DeleteOrphanedSubtitleExtractions(); err != nil {
+		logger.Errorf("Scheduler: failed to delete orphaned subtitle extractions: %v", err)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		logger.Infof("Scheduler: FFmpeg not available, skipping subtitle extraction")
+		return 0
+	}
+
+	// Stop extracting when the scheduler stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	deadline := time.Now().Add(subtitleRunBudget)
+	done := 0
+	for time.Now().Before(deadline) {
+		files, err := s.db.GetSubtitleExtractionCandidates(subtitleBatchSize)
+		if err != nil {
+			logger.Errorf("Scheduler: failed to get files to extract subtitles from: %v", err)
+			return done
+		}
+		if len(files) == 0 {
+			return done
+		}
+		for _, f := range files {
+			// Failures are recorded, so the next batch moves on
+			if _, err := s.scanner.ExtractSubtitleTracks(ctx, f.MediaType, f.MediaID, f.Path); err != nil {
+				if ctx.Err() != nil {
+					return done
+				}
+				logger.Errorf("Scheduler: %v", err)
+				continue
+			}
+			done++
+			if !time.Now().Before(deadline) {
+				break
+			}
+		}
+	}
+	return done
+}
+
+// runSubtitleExtractionJob runs the Subtitle Extraction task on its interval
+func (s *Scheduler) runSubtitleExtractionJob() {
+	defer s.wg.Done()
+
+	interval := time.Hour
+	if task, err := s.db.GetTaskByName("Subtitle Extraction"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Subtitle Extraction", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Subtitle Extraction", tick.Add(interval))
+			s.executeTaskByName("Subtitle Extraction")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/outpost/outpost/internal/subtitles"
+)
+
+// Subtitle search
+//
+// The Subtitle Search task downloads the languages of each library's
+// subtitle profile for the files missing them, newest first. OpenSubtitles
+// searches are slow and downloads are counted per day, so a run searches at
+// most subtitleSearchRunLimit files and stops once the downloads run out.
+
+// subtitleSearchRunLimit is how many files a run searches at most
+const subtitleSearchRunLimit = 100
+
+// runSubtitleSearchTask searches subtitles for the files that want them,
+// returning how many files were searched and how many subtitles downloaded
+func (s *Scheduler) runSubtitleSearchTask() (int, int) {
+	if s.scanner == nil {
+		logger.Infof("Scheduler: scanner not available for subtitle search")
+		return 0, 0
+	}
+	if err := s.db.DeleteOrphanedSubtitleSearches(); err != nil {
+		logger.Errorf("Scheduler: failed to delete orphaned subtitle searches: %v", err)
+	}
+	if apiKey, _ := s.db.GetSetting("opensubtitles_api_key"); apiKey == "" {
+		return 0, 0
+	}
+
+	searched, downloaded := 0, 0
+	for searched < subtitleSearchRunLimit {
+		items, err := s.db.GetSubtitleSearchCandidates(subtitleBatchSize)
+		if err != nil {
+			logger.Errorf("Scheduler: failed to get files to search subtitles for: %v", err)
+			break
+		}
+		if len(items) == 0 {
+			break
+		}
+		for i := range items {
+			select {
+			case <-s.stopChan:
+				return searched, downloaded
+			default:
+			}
+
+			// Files are recorded as searched, so the next batch moves on
+			n, err := s.scanner.SearchSubtitles(&items[i])
+			downloaded += n
+			if errors.Is(err, subtitles.ErrDownloadLimit) {
+				logger.Infof("Scheduler: OpenSubtitles downloads used up for today, stopping subtitle search")
+				return searched, downloaded
+			}
+			if err != nil {
+				logger.Errorf("Scheduler: subtitle search failed: %v", err)
+				return searched, downloaded
+			}
+			searched++
+			if searched >= subtitleSearchRunLimit {
+				break
+			}
+		}
+	}
+	if downloaded > 0 {
+		logger.Infof("Scheduler: downloaded %d subtitles for %d files", downloaded, searched)
+	}
+	return searched, downloaded
+}
+
+// runSubtitleSearchJob runs the Subtitle Search task on its interval
+func (s *Scheduler) runSubtitleSearchJob() {
+	defer s.wg.Done()
+
+	interval := 30 * time.Minute
+	if task, err := s.db.GetTaskByName("Subtitle Search"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Subtitle Search", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Subtitle Search", tick.Add(interval))
+			s.executeTaskByName("Subtitle Search")
+		}
+	}
+}
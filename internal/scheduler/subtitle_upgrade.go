@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/outpost/outpost/internal/subtitles"
+)
+
+// Subtitle upgrade
+//
+// The Subtitle Upgrade task looks again for better subtitles than the ones
+// the Subtitle Search task downloaded, for subtitle_upgrade_days after each
+// download, and replaces them when one scores higher. Each subtitle is
+// looked at once a run, at most subtitleUpgradeRunLimit a run.
+
+// subtitleUpgradeRunLimit is how many subtitles a run looks at most
+const subtitleUpgradeRunLimit = 100
+
+// runSubtitleUpgradeTask looks for better subtitles than recent downloads,
+// returning how many subtitles were looked at and how many upgraded
+func (s *Scheduler) runSubtitleUpgradeTask() (int, int) {
+	if s.scanner == nil {
+		logger.Infof("Scheduler: scanner not available for subtitle upgrade")
+		return 0, 0
+	}
+	if apiKey, _ := s.db.GetSetting("opensubtitles_api_key"); apiKey == "" {
+		return 0, 0
+	}
+	days := 7
+	if value, err := s.db.GetSetting("subtitle_upgrade_days"); err == nil && value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			days = n
+		}
+	}
+	if days <= 0 {
+		return 0, 0
+	}
+
+	// Subtitles looked at this run are marked checked after started, so the
+	// next batch moves on
+	started := time.Now()
+	downloadedAfter := started.AddDate(0, 0, -days)
+
+	checked, upgraded := 0, 0
+	for checked < subtitleUpgradeRunLimit {
+		candidates, err := s.db.GetSubtitleUpgradeCandidates(subtitles.MaxScore, downloadedAfter, started, subtitleBatchSize)
+		if err != nil {
+			logger.Errorf("Scheduler: failed to get subtitles to upgrade: %v", err)
+			break
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		for i := range candidates {
+			select {
+			case <-s.stopChan:
+				return checked, upgraded
+			default:
+			}
+
+			ok, err := s.scanner.UpgradeSubtitle(&candidates[i].Item, &candidates[i].File)
+			if errors.Is(err, subtitles.ErrDownloadLimit) {
+				logger.Infof("Scheduler: OpenSubtitles downloads used up for today, stopping subtitle upgrade")
+				return checked, upgraded
+			}
+			if err != nil {
+				logger.Errorf("Scheduler: subtitle upgrade failed: %v", err)
+				return checked, upgraded
+			}
+			if ok {
+				upgraded++
+			}
+			checked++
+			if checked >= subtitleUpgradeRunLimit {
+				break
+			}
+		}
+	}
+	if upgraded > 0 {
+		logger.Infof("Scheduler: upgraded %d of %d subtitles", upgraded, checked)
+	}
+	return checked, upgraded
+}
+
+// runSubtitleUpgradeJob runs the Subtitle Upgrade task on its interval
+func (s *Scheduler) runSubtitleUpgradeJob() {
+	defer s.wg.Done()
+
+	interval := 12 * time.Hour
+	if task, err := s.db.GetTaskByName("Subtitle Upgrade"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Subtitle Upgrade", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Subtitle Upgrade", tick.Add(interval))
+			s.executeTaskByName("Subtitle Upgrade")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import "time"
+
+// TrashEmptier removes deleted files once they've been in the trash for the
+// retention period
+type TrashEmptier interface {
+	EmptyExpired() int
+}
+
+// SetTrash sets what the Empty Trash task empties. Without one the task does
+// nothing.
+func (s *Scheduler) SetTrash(trash TrashEmptier) {
+	s.trash = trash
+}
+
+// runTrashTask removes the expired files from the trash, returning how many
+// were removed
+func (s *Scheduler) runTrashTask() int {
+	if s.trash == nil {
+		return 0
+	}
+	return s.trash.EmptyExpired()
+}
+
+// runTrashJob runs the Empty Trash task on its interval
+func (s *Scheduler) runTrashJob() {
+	defer s.wg.Done()
+
+	interval := 24 * time.Hour
+	if task, err := s.db.GetTaskByName("Empty Trash"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Empty Trash", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Empty Trash", tick.Add(interval))
+			s.executeTaskByName("Empty Trash")
+		}
+	}
+}
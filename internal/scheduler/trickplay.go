@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"os/exec"
+	"time"
+)
+
+// Trickplay
+//
+// The Trickplay task makes scrubbing storyboards for movies and episodes
+// that don't have one yet, newest first, for up to trickplayRunBudget a run
+// so a large library is worked through over several runs. Storyboards of
+// media no longer in the library are deleted.
+
+const (
+	// trickplayRunBudget is how long a run keeps starting new files
+	trickplayRunBudget = 45 * time.Minute
+	// trickplayBatchSize is how many files are looked up at a time
+	trickplayBatchSize = 20
+)
+
+// runTrickplayTask makes storyboards, returning how many were made
+func (s *Scheduler) runTrickplayTask() int {
+	if s.scanner == nil {
+		logger.Infof("Scheduler: scanner not available for trickplay")
+		return 0
+	}
+
+	orphans, err := s.db.GetOrphanedTrickplay()
+	if err != nil {
+		logger.Errorf("Scheduler: failed to get orphaned trickplay: %v", err)
+	}
+	for _, t := range orphans {
+		s.scanner.RemoveTrickplay(t.MediaType, t.MediaID)
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		logger.Infof("Scheduler: FFmpeg not available, skipping trickplay")
+		return 0
+	}
+
+	deadline := time.Now().Add(trickplayRunBudget)
+	made := 0
+	for time.Now().Before(deadline) {
+		candidates, err := s.db.GetTrickplayCandidates(trickplayBatchSize)
+		if err != nil {
+			logger.Errorf("Scheduler: failed to get media without trickplay: %v", err)
+			return made
+		}
+		if len(candidates) == 0 {
+			return made
+		}
+		for _, c := range candidates {
+			select {
+			case <-s.stopChan:
+				return made
+			default:
+			}
+			// Failures are recorded, so the next batch moves on
+			if _, err := s.scanner.GenerateTrickplay(c.MediaType, c.MediaID, c.Path); err != nil {
+				logger.Errorf("Scheduler: %v", err)
+				continue
+			}
+			made++
+			if !time.Now().Before(deadline) {
+				break
+			}
+		}
+	}
+	return made
+}
+
+// runTrickplayJob runs the Trickplay task on its interval
+func (s *Scheduler) runTrickplayJob() {
+	defer s.wg.Done()
+
+	interval := time.Hour
+	if task, err := s.db.GetTaskByName("Trickplay"); err == nil && task.IntervalMinutes > 0 {
+		interval = time.Duration(task.IntervalMinutes) * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.setNextRun("Trickplay", time.Now().Add(interval))
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case tick := <-ticker.C:
+			s.setNextRun("Trickplay", tick.Add(interval))
+			s.executeTaskByName("Trickplay")
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+	"github.com/outpost/outpost/internal/quality"
+)
+
+var logger = logging.Module("seed")
+
+// Startup seeding
+//
+// A fresh install can be seeded instead of set up by hand through the wizard.
+// SEED=true applies the defaults below: common custom formats scored in every
+// quality profile, the naming templates, the scheduler's settings and a demo
+// smart playlist. SEED_FILE points at a JSON seed file, which can also create
+// the admin account, libraries, extra quality presets and any settings, and
+// replaces the defaults for each section it has. The built-in quality presets
+// and scheduled tasks are always there, so the seed only adds to them.
+//
+// Seeding happens once, on the first run before anyone has signed up, so a
+// deployment restarted with the same environment is left alone.
+
+// seededSetting records when the server was seeded
+const seededSetting = "seeded_at"
+
+// Seed is what a fresh install is seeded with
+type Seed struct {
+	Admin           *Admin                   `json:"admin,omitempty"`
+	Settings        map[string]string        `json:"settings,omitempty"`
+	Libraries       []database.Library       `json:"libraries,omitempty"`
+	QualityPresets  []database.QualityPreset `json:"qualityPresets,omitempty"`
+	CustomFormats   []CustomFormat           `json:"customFormats,omitempty"`
+	NamingTemplates []NamingTemplate         `json:"namingTemplates,omitempty"`
+	SmartPlaylists  []SmartPlaylist          `json:"smartPlaylists,omitempty"`
+}
+
+// Admin is the admin account a seed creates
+type Admin struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CustomFormat is a custom format and the score every quality profile gives it
+type CustomFormat struct {
+	Name       string              `json:"name"`
+	Conditions []quality.Condition `json:"conditions"`
+	Score      int                 `json:"score"`
+}
+
+// NamingTemplate replaces the default naming template of a type
+type NamingTemplate struct {
+	Type           string `json:"type"` // movie, tv or daily
+	FolderTemplate string `json:"folderTemplate"`
+	FileTemplate   string `json:"fileTemplate"`
+}
+
+// SmartPlaylist is a smart playlist shared with every user
+type SmartPlaylist struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Rules       database.PlaylistRules `json:"rules"`
+	SortBy      string                 `json:"sortBy"`
+	SortOrder   string                 `json:"sortOrder"`
+	Limit       int                    `json:"limit"`
+	MediaType   string                 `json:"mediaType"` // movie, show or both
+}
+
+// Defaults returns the seed SEED=true applies
+func Defaults() *Seed {
+	required := func(conditionType, value string) quality.Condition {
+		return quality.Condition{Type: conditionType, Value: value, Required: true}
+	}
+	return &Seed{
+		Settings: map[string]string{
+			"scheduler_auto_search":     "true",
+			"scheduler_auto_grab":       "true",
+			"scheduler_rss_enabled":     "true",
+			"scheduler_search_interval": "60",
+			"scheduler_rss_interval":    "15",
+		},
+		CustomFormats: []CustomFormat{
+			{Name: "Dolby Vision", Conditions: []quality.Condition{required("hdr", "dv")}, Score: 150},
+			{Name: "HDR10+", Conditions: []quality.Condition{required("hdr", "hdr10plus")}, Score: 120},
+			{Name: "HDR10", Conditions: []quality.Condition{required("hdr", "hdr10")}, Score: 100},
+			{Name: "Atmos", Conditions: []quality.Condition{required("audioCodec", "atmos")}, Score: 80},
+			{Name: "Lossless Audio", Conditions: []quality.Condition{required("audioCodec", "truehd")}, Score: 60},
+			{Name: "HEVC", Conditions: []quality.Condition{required("codec", "hevc")}, Score: 30},
+			{Name: "Proper", Conditions: []quality.Condition{{Type: "proper", Required: true}}, Score: 20},
+			{Name: "Repack", Conditions: []quality.Condition{{Type: "repack", Required: true}}, Score: 20},
+			{Name: "Cam", Conditions: []quality.Condition{required("source", "cam")}, Score: -10000},
+			{Name: "Telesync", Conditions: []quality.Condition{required("source", "ts")}, Score: -10000},
+		},
+		NamingTemplates: []NamingTemplate{
+			{Type: "movie", FolderTemplate: "{Title} ({Year})", FileTemplate: "{Title} ({Year})"},
+			{Type: "tv", FolderTemplate: "{Title} ({Year})/Season {Season:00}", FileTemplate: "{Title} - S{Season:00}E{Episode:00} - {EpisodeTitle}"},
+			{Type: "daily", FolderTemplate: "{Title} ({Year})/Season {Year}", FileTemplate: "{Title} - {Air-Date} - {EpisodeTitle}"},
+		},
+		SmartPlaylists: []SmartPlaylist{
+			{
+				Name:        "Movie Night",
+				Description: "Well rated movies you haven't watched that fit in an evening",
+				Rules: database.PlaylistRules{Match: "all", Conditions: []database.PlaylistCondition{
+					{Field: "watched", Operator: "eq", Value: false},
+					{Field: "rating", Operator: "gte", Value: 7.0},
+					{Field: "runtime", Operator: "lte", Value: 130},
+				}},
+				SortBy:    "rating",
+				SortOrder: "desc",
+				Limit:     25,
+				MediaType: "movie",
+			},
+		},
+	}
+}
+
+// Load reads a seed file. Sections the file leaves out are taken from the
+// defaults.
+func Load(path string) (*Seed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file Seed
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seed := Defaults()
+	seed.Admin = file.Admin
+	seed.Libraries = file.Libraries
+	seed.QualityPresets = file.QualityPresets
+	if file.Settings != nil {
+		for key, value := range file.Settings {
+			seed.Settings[key] = value
+		}
+	}
+	if file.CustomFormats != nil {
+		seed.CustomFormats = file.CustomFormats
+	}
+	if file.NamingTemplates != nil {
+		seed.NamingTemplates = file.NamingTemplates
+	}
+	if file.SmartPlaylists != nil {
+		seed.SmartPlaylists = file.SmartPlaylists
+	}
+	return seed, nil
+}
+
+// FromEnv returns the seed the environment asks for: the SEED_FILE file, the
+// defaults if SEED is true, otherwise nil
+func FromEnv() (*Seed, error) {
+	if path := os.Getenv("SEED_FILE"); path != "" {
+		return Load(path)
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("SEED")); enabled {
+		return Defaults(), nil
+	}
+	return nil, nil
+}
+
+// Apply seeds a fresh install. It does nothing if the server has been seeded
+// before or anyone has signed up.
+func Apply(db *database.Database, seed *Seed) error {
+	settings, err := db.GetAllSettings()
+	if err != nil {
+		return err
+	}
+	if settings[seededSetting] != "" {
+		return nil
+	}
+	users, err := db.CountUsers()
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		logger.Infof("Skipping seed: this server is already set up")
+		return nil
+	}
+	if err := seed.validate(); err != nil {
+		return err
+	}
+
+	if seed.Admin != nil {
+		if _, err := auth.New(db).CreateUser(seed.Admin.Username, seed.Admin.Password, "admin"); err != nil {
+			return fmt.Errorf("admin: %w", err)
+		}
+		logger.Infof("Seeded admin account %s", seed.Admin.Username)
+	}
+	for key, value := range seed.Settings {
+		if err := db.SetSetting(key, value); err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	for i := range seed.Libraries {
+		lib := &seed.Libraries[i]
+		if lib.ScanInterval == 0 {
+			lib.ScanInterval = 3600
+		}
+		if err := db.CreateLibrary(lib); err != nil {
+			return fmt.Errorf("library %s: %w", lib.Name, err)
+		}
+	}
+	for i := range seed.QualityPresets {
+		preset := &seed.QualityPresets[i]
+		preset.IsBuiltIn = false
+		if err := db.CreateQualityPreset(preset); err != nil {
+			return fmt.Errorf("quality preset %s: %w", preset.Name, err)
+		}
+	}
+	if err := seedCustomFormats(db, seed.CustomFormats); err != nil {
+		return err
+	}
+	if err := seedNamingTemplates(db, seed.NamingTemplates); err != nil {
+		return err
+	}
+	for _, p := range seed.SmartPlaylists {
+		if err := seedSmartPlaylist(db, p); err != nil {
+			return fmt.Errorf("smart playlist %s: %w", p.Name, err)
+		}
+	}
+
+	if err := db.SetSetting(seededSetting, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	logger.Infof("Seeded %d settings, %d libraries, %d quality presets, %d custom formats, %d naming templates and %d smart playlists",
+		len(seed.Settings), len(seed.Libraries), len(seed.QualityPresets), len(seed.CustomFormats),
+		len(seed.NamingTemplates), len(seed.SmartPlaylists))
+	return nil
+}
+
+// validate checks a seed before anything is written, so a bad seed file
+// doesn't leave a half seeded server
+func (seed *Seed) validate() error {
+	if seed.Admin != nil && (strings.TrimSpace(seed.Admin.Username) == "" || seed.Admin.Password == "") {
+		return errors.New("admin: username and password required")
+	}
+	for _, lib := range seed.Libraries {
+		if lib.Name == "" || lib.Path == "" || lib.Type == "" {
+			return fmt.Errorf("library %q: name, path and type required", lib.Name)
+		}
+	}
+	for _, preset := range seed.QualityPresets {
+		if preset.Name == "" || preset.MediaType == "" {
+			return fmt.Errorf("quality preset %q: name and mediaType required", preset.Name)
+		}
+	}
+	for _, format := range seed.CustomFormats {
+		if format.Name == "" || len(format.Conditions) == 0 {
+			return fmt.Errorf("custom format %q: name and conditions required", format.Name)
+		}
+	}
+	for _, t := range seed.NamingTemplates {
+		if t.FolderTemplate == "" || t.FileTemplate == "" {
+			return fmt.Errorf("naming template %q: folderTemplate and fileTemplate required", t.Type)
+		}
+	}
+	for _, p := range seed.SmartPlaylists {
+		if p.Name == "" || len(p.Rules.Conditions) == 0 {
+			return fmt.Errorf("smart playlist %q: name and rules required", p.Name)
+		}
+	}
+	return nil
+}
+
+// seedCustomFormats creates custom formats, skipping ones that already exist,
+// and scores them in every quality profile
+func seedCustomFormats(db *database.Database, formats []CustomFormat) error {
+	if len(formats) == 0 {
+		return nil
+	}
+	existing, err := db.GetCustomFormats()
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]int64, len(existing))
+	for _, f := range existing {
+		ids[f.Name] = f.ID
+	}
+
+	scores := make(map[int64]int)
+	for _, format := range formats {
+		id, ok := ids[format.Name]
+		if !ok {
+			conditions, err := json.Marshal(format.Conditions)
+			if err != nil {
+				return err
+			}
+			created := &database.CustomFormat{Name: format.Name, Conditions: string(conditions)}
+			if err := db.CreateCustomFormat(created); err != nil {
+				return fmt.Errorf("custom format %s: %w", format.Name, err)
+			}
+			id = created.ID
+		}
+		if format.Score != 0 {
+			scores[id] = format.Score
+		}
+	}
+
+	profiles, err := db.GetQualityProfiles()
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		profileScores, err := quality.ParseCustomFormatScores(profile.CustomFormatScores)
+		if err != nil {
+			profileScores = map[int64]int{}
+		}
+		for id, score := range scores {
+			if _, ok := profileScores[id]; !ok {
+				profileScores[id] = score
+			}
+		}
+		encoded, err := json.Marshal(profileScores)
+		if err != nil {
+			return err
+		}
+		profile.CustomFormatScores = string(encoded)
+		if err := db.UpdateQualityProfile(&profile); err != nil {
+			return fmt.Errorf("quality profile %s: %w", profile.Name, err)
+		}
+	}
+	return nil
+}
+
+// seedNamingTemplates replaces the default naming template of each type
+func seedNamingTemplates(db *database.Database, templates []NamingTemplate) error {
+	for _, t := range templates {
+		existing, err := db.GetNamingTemplate(t.Type)
+		if err != nil {
+			return fmt.Errorf("naming template %s: %w", t.Type, err)
+		}
+		existing.FolderTemplate = t.FolderTemplate
+		existing.FileTemplate = t.FileTemplate
+		if err := db.UpdateNamingTemplate(existing); err != nil {
+			return fmt.Errorf("naming template %s: %w", t.Type, err)
+		}
+	}
+	return nil
+}
+
+// seedSmartPlaylist creates a smart playlist every user sees
+func seedSmartPlaylist(db *database.Database, p SmartPlaylist) error {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return err
+	}
+	playlist := &database.SmartPlaylist{
+		Name:        p.Name,
+		Rules:       string(rules),
+		SortBy:      p.SortBy,
+		SortOrder:   p.SortOrder,
+		MediaType:   p.MediaType,
+		AutoRefresh: true,
+	}
+	if p.Description != "" {
+		playlist.Description = &p.Description
+	}
+	if p.Limit > 0 {
+		playlist.LimitCount = &p.Limit
+	}
+	if playlist.SortBy == "" {
+		playlist.SortBy = "added"
+	}
+	if playlist.SortOrder == "" {
+		playlist.SortOrder = "desc"
+	}
+	if playlist.MediaType == "" {
+		playlist.MediaType = "both"
+	}
+	return db.CreateSmartPlaylist(playlist)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,10 @@ const (
 	UserAgent            = "Outpost v1.0"
 )
 
+// ErrDownloadLimit is returned when the account's downloads for the day are
+// used up
+var ErrDownloadLimit = errors.New("OpenSubtitles download limit reached")
+
 // Client handles OpenSubtitles API requests
 type Client struct {
 	APIKey     string
@@ -34,33 +39,39 @@ func NewClient(apiKey string) *Client {
 
 // Subtitle represents a subtitle result
 type Subtitle struct {
-	ID              string  `json:"id"`
-	FileID          int     `json:"fileId"`
-	LanguageCode    string  `json:"language"`
-	LanguageName    string  `json:"languageName"`
-	FileName        string  `json:"fileName"`
-	Release         string  `json:"release"`
-	UploadDate      string  `json:"uploadDate"`
-	Downloads       int     `json:"downloads"`
-	FPS             float64 `json:"fps"`
-	HearingImpaired bool    `json:"hearingImpaired"`
-	AITranslated    bool    `json:"aiTranslated"`
-	FromTrusted     bool    `json:"fromTrusted"`
-	FeatureTitle    string  `json:"featureTitle"`
-	FeatureYear     int     `json:"featureYear"`
+	ID                string  `json:"id"`
+	FileID            int     `json:"fileId"`
+	LanguageCode      string  `json:"language"`
+	LanguageName      string  `json:"languageName"`
+	FileName          string  `json:"fileName"`
+	Release           string  `json:"release"`
+	UploadDate        string  `json:"uploadDate"`
+	Downloads         int     `json:"downloads"`
+	FPS               float64 `json:"fps"`
+	HearingImpaired   bool    `json:"hearingImpaired"`
+	ForeignParts      bool    `json:"foreignPartsOnly"`
+	AITranslated      bool    `json:"aiTranslated"`
+	MachineTranslated bool    `json:"machineTranslated"`
+	FromTrusted       bool    `json:"fromTrusted"`
+	HashMatch         bool    `json:"hashMatch"` // Made for the searched file
+	Score             int     `json:"score,omitempty"`
+	FeatureTitle      string  `json:"featureTitle"`
+	FeatureYear       int     `json:"featureYear"`
 }
 
 // SearchRequest contains search parameters
 type SearchRequest struct {
-	Query           string
-	IMDbID          string
-	TMDbID          int
-	Year            int
-	Season          int
-	Episode         int
-	Languages       []string
-	MovieHash       string
-	HearingImpaired *bool // nil = any, true = only HI, false = exclude HI
+	Query            string
+	IMDbID           string
+	TMDbID           int
+	Year             int
+	Season           int
+	Episode          int
+	Languages        []string
+	MovieHash        string
+	HearingImpaired  *bool // nil = any, true = only HI, false = exclude HI
+	ParentTMDbID     int   // The show's, for episodes
+	ForeignPartsOnly bool  // Only subtitles for foreign parts (forced)
 }
 
 // SearchResponse represents the API search response
@@ -87,6 +98,7 @@ type SearchResponse struct {
 			UploadDate      string  `json:"upload_date"`
 			AITranslated    bool    `json:"ai_translated"`
 			MachineTranslated bool `json:"machine_translated"`
+			MovieHashMatch  bool    `json:"moviehash_match"`
 			Release         string  `json:"release"`
 			URL             string  `json:"url"`
 			FeatureDetails  struct {
@@ -145,6 +157,12 @@ func (c *Client) Search(req SearchRequest) ([]Subtitle, error) {
 	if req.MovieHash != "" {
 		params.Set("moviehash", req.MovieHash)
 	}
+	if req.ParentTMDbID > 0 {
+		params.Set("parent_tmdb_id", fmt.Sprintf("%d", req.ParentTMDbID))
+	}
+	if req.ForeignPartsOnly {
+		params.Set("foreign_parts_only", "only")
+	}
 	if req.HearingImpaired != nil {
 		if *req.HearingImpaired {
 			params.Set("hearing_impaired", "only")
@@ -190,19 +208,22 @@ func (c *Client) Search(req SearchRequest) ([]Subtitle, error) {
 		}
 
 		subtitles = append(subtitles, Subtitle{
-			ID:              item.ID,
-			FileID:          fileID,
-			LanguageCode:    item.Attributes.Language,
-			FileName:        fileName,
-			Release:         item.Attributes.Release,
-			UploadDate:      item.Attributes.UploadDate,
-			Downloads:       item.Attributes.DownloadCount,
-			FPS:             item.Attributes.FPS,
-			HearingImpaired: item.Attributes.HearingImpaired,
-			AITranslated:    item.Attributes.AITranslated,
-			FromTrusted:     item.Attributes.FromTrusted,
-			FeatureTitle:    item.Attributes.FeatureDetails.Title,
-			FeatureYear:     item.Attributes.FeatureDetails.Year,
+			ID:                item.ID,
+			FileID:            fileID,
+			LanguageCode:      item.Attributes.Language,
+			FileName:          fileName,
+			Release:           item.Attributes.Release,
+			UploadDate:        item.Attributes.UploadDate,
+			Downloads:         item.Attributes.DownloadCount,
+			FPS:               item.Attributes.FPS,
+			HearingImpaired:   item.Attributes.HearingImpaired,
+			ForeignParts:      item.Attributes.ForeignPartsOnly,
+			AITranslated:      item.Attributes.AITranslated,
+			MachineTranslated: item.Attributes.MachineTranslated,
+			FromTrusted:       item.Attributes.FromTrusted,
+			HashMatch:         item.Attributes.MovieHashMatch,
+			FeatureTitle:      item.Attributes.FeatureDetails.Title,
+			FeatureYear:       item.Attributes.FeatureDetails.Year,
 		})
 	}
 
@@ -230,6 +251,9 @@ func (c *Client) GetDownloadLink(fileID int) (*DownloadResponse, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotAcceptable {
+		return nil, ErrDownloadLimit
+	}
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
@@ -334,10 +358,9 @@ func (c *Client) SearchAndDownload(videoPath string, title string, year int, lan
 		return "", fmt.Errorf("no subtitles found")
 	}
 
-	// Get first result (best match)
-	sub := subtitles[0]
-
-	if sub.FileID == 0 {
+	// Get the best scoring result
+	sub := NewTarget(videoPath, hearingImpaired).Best(subtitles)
+	if sub == nil {
 		return "", fmt.Errorf("no file ID in subtitle result")
 	}
 
@@ -381,9 +404,8 @@ func (c *Client) SearchAndDownloadEpisode(videoPath string, showTitle string, se
 		return "", fmt.Errorf("no subtitles found")
 	}
 
-	sub := subtitles[0]
-
-	if sub.FileID == 0 {
+	sub := NewTarget(videoPath, hearingImpaired).Best(subtitles)
+	if sub == nil {
 		return "", fmt.Errorf("no file ID in subtitle result")
 	}
 
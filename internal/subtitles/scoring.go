@@ -0,0 +1,110 @@
+package subtitles
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/outpost/outpost/internal/parser"
+)
+
+// Subtitle scoring
+//
+// Subtitles are scored on how well they fit the video they're for, much as
+// Bazarr does: made for the very file (its hash matches), made for the same
+// release (group, source, resolution, codec), from a trusted uploader, and
+// hearing impaired or not as wanted. Machine translations lose points. The
+// best scoring subtitle is downloaded, and one that scores less than
+// MaxScore may later be upgraded to a better one.
+
+// Points a subtitle scores
+const (
+	scoreHash       = 100 // Made for the searched file
+	scoreGroup      = 15  // Same release group
+	scoreSource     = 7   // Same source (bluray, webdl, ...)
+	scoreResolution = 2
+	scoreCodec      = 2
+	scoreTrusted    = 5  // From a trusted uploader
+	scoreHI         = 1  // Hearing impaired or not, as wanted
+	scoreMachine    = 25 // Taken off machine and AI translations
+)
+
+// MaxScore is the score of a subtitle that couldn't fit better
+const MaxScore = scoreHash + scoreGroup + scoreSource + scoreResolution + scoreCodec + scoreTrusted + scoreHI
+
+// Target is the video subtitles are scored against
+type Target struct {
+	Release         *parser.ParsedRelease
+	HearingImpaired *bool // Wanted or not; nil for either
+}
+
+// NewTarget scores subtitles against a video file. Renamed files lose their
+// release group, so the folder's name is looked at for one too.
+func NewTarget(videoPath string, hearingImpaired *bool) Target {
+	release := parser.Parse(strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath)))
+	if release.ReleaseGroup == "" {
+		release.ReleaseGroup = parser.Parse(filepath.Base(filepath.Dir(videoPath))).ReleaseGroup
+	}
+	return Target{Release: release, HearingImpaired: hearingImpaired}
+}
+
+// Score returns how well a subtitle fits the target
+func (t Target) Score(sub *Subtitle) int {
+	score := 0
+	if sub.HashMatch {
+		score += scoreHash
+	}
+	if t.Release != nil && sub.Release != "" {
+		release := parser.Parse(sub.Release)
+		if sameValue(t.Release.ReleaseGroup, release.ReleaseGroup) {
+			score += scoreGroup
+		}
+		if sameValue(t.Release.Source, release.Source) {
+			score += scoreSource
+		}
+		if sameValue(t.Release.Resolution, release.Resolution) {
+			score += scoreResolution
+		}
+		if sameValue(t.Release.Codec, release.Codec) {
+			score += scoreCodec
+		}
+	}
+	if sub.FromTrusted {
+		score += scoreTrusted
+	}
+	if t.HearingImpaired != nil && *t.HearingImpaired == sub.HearingImpaired {
+		score += scoreHI
+	}
+	if sub.AITranslated || sub.MachineTranslated {
+		score -= scoreMachine
+	}
+	return score
+}
+
+// Rank scores subtitles and sorts them best first. Subtitles scoring the
+// same keep OpenSubtitles' order.
+func (t Target) Rank(subs []Subtitle) {
+	for i := range subs {
+		subs[i].Score = t.Score(&subs[i])
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].Score > subs[j].Score
+	})
+}
+
+// Best returns the best scoring subtitle that can be downloaded, or nil if
+// there's none
+func (t Target) Best(subs []Subtitle) *Subtitle {
+	t.Rank(subs)
+	for i := range subs {
+		if subs[i].FileID != 0 {
+			return &subs[i]
+		}
+	}
+	return nil
+}
+
+// sameValue reports whether a parsed release value is known and the same
+func sameValue(a, b string) bool {
+	return a != "" && strings.EqualFold(a, b)
+}
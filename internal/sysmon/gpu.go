@@ -0,0 +1,97 @@
+package sysmon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nvidiaTimeout bounds each nvidia-smi call, which can hang on a driver in
+// a bad state
+const nvidiaTimeout = 3 * time.Second
+
+// readGPUs reads the use of NVIDIA GPUs through nvidia-smi and AMD GPUs
+// through the amdgpu driver's sysfs files. Intel GPUs don't report their use
+// without root, so they're left out.
+func readGPUs() ([]GPUUsage, error) {
+	var gpus []GPUUsage
+	var errs []error
+	if _, err := exec.LookPath("nvidia-smi"); err == nil {
+		nvidia, err := readNvidiaGPUs()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		gpus = append(gpus, nvidia...)
+	}
+	gpus = append(gpus, readAMDGPUs()...)
+	return gpus, errors.Join(errs...)
+}
+
+// readNvidiaGPUs asks nvidia-smi for the use of each NVIDIA GPU. Memory is
+// reported in MiB.
+func readNvidiaGPUs() ([]GPUUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), nvidiaTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=name,utilization.gpu,memory.used,memory.total",
+		"--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var gpus []GPUUsage
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			continue
+		}
+		gpu := GPUUsage{Name: strings.TrimSpace(fields[0]), Vendor: "nvidia"}
+		gpu.Percent, _ = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if used, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64); err == nil {
+			gpu.MemoryUsed = used * 1024 * 1024
+		}
+		if total, err := strconv.ParseUint(strings.TrimSpace(fields[3]), 10, 64); err == nil {
+			gpu.MemoryTotal = total * 1024 * 1024
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// readAMDGPUs reads the use of AMD GPUs from the files the amdgpu driver
+// puts under each card's device folder
+func readAMDGPUs() []GPUUsage {
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device/gpu_busy_percent")
+	var gpus []GPUUsage
+	for _, busyPath := range cards {
+		device := filepath.Dir(busyPath)
+		busy, ok := readSysfsUint(busyPath)
+		if !ok {
+			continue
+		}
+		gpu := GPUUsage{
+			Name:    filepath.Base(filepath.Dir(device)),
+			Vendor:  "amd",
+			Percent: float64(busy),
+		}
+		gpu.MemoryUsed, _ = readSysfsUint(filepath.Join(device, "mem_info_vram_used"))
+		gpu.MemoryTotal, _ = readSysfsUint(filepath.Join(device, "mem_info_vram_total"))
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// readSysfsUint reads a sysfs file holding a number
+func readSysfsUint(path string) (uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return v, err == nil
+}
@@ -0,0 +1,186 @@
+package sysmon
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("sysmon")
+
+// System resources
+//
+// The monitor samples the host's CPU, memory and GPU use every few seconds,
+// so a new transcode can be turned away when the host is already saturated
+// rather than every running stream stuttering. CPU and memory are read from
+// /proc and GPUs from nvidia-smi and the amdgpu driver, so on other systems,
+// or without a supported GPU, those parts are missing.
+
+// SampleInterval is how often resources are sampled. CPU use is averaged
+// over it.
+const SampleInterval = 5 * time.Second
+
+// Resources is a sample of the host's resource use
+type Resources struct {
+	CPUs      int          `json:"cpus"`
+	CPU       *CPUUsage    `json:"cpu,omitempty"`
+	Memory    *MemoryUsage `json:"memory,omitempty"`
+	GPUs      []GPUUsage   `json:"gpus"`
+	SampledAt time.Time    `json:"sampledAt"`
+}
+
+// CPUUsage is how busy the CPUs were over the last sample interval
+type CPUUsage struct {
+	Percent float64    `json:"percent"` // Of all CPUs together
+	Load    [3]float64 `json:"load"`    // Load average over 1, 5 and 15 minutes
+}
+
+// MemoryUsage is how much memory is in use, not counting caches the kernel
+// gives back when asked
+type MemoryUsage struct {
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Percent float64 `json:"percent"`
+}
+
+// GPUUsage is how busy a GPU is
+type GPUUsage struct {
+	Name        string  `json:"name"`
+	Vendor      string  `json:"vendor"` // "nvidia" or "amd"
+	Percent     float64 `json:"percent"`
+	MemoryTotal uint64  `json:"memoryTotal,omitempty"`
+	MemoryUsed  uint64  `json:"memoryUsed,omitempty"`
+}
+
+// Limits is how busy the host may be for a new transcode to start. A zero
+// limit isn't checked.
+type Limits struct {
+	CPU    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+	GPU    float64 `json:"gpu"`
+}
+
+// Monitor samples resource use in the background
+type Monitor struct {
+	mu      sync.RWMutex
+	latest  Resources
+	prevCPU *cpuTimes
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMonitor creates a monitor. It samples once at once; Start keeps it
+// sampling.
+func NewMonitor() *Monitor {
+	m := &Monitor{}
+	m.sample()
+	return m
+}
+
+// Start samples resources every SampleInterval until Stop
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	stop, done := m.stop, m.done
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.sample()
+			}
+		}
+	}()
+}
+
+// Stop stops sampling
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Latest returns the latest sample
+func (m *Monitor) Latest() Resources {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r := m.latest
+	r.GPUs = append([]GPUUsage{}, m.latest.GPUs...)
+	return r
+}
+
+// Saturated returns which resource is busier than its limit in the latest
+// sample, "cpu", "memory" or "gpu", or "" if none is
+func (m *Monitor) Saturated(limits Limits) string {
+	r := m.Latest()
+	if limits.CPU > 0 && r.CPU != nil && r.CPU.Percent >= limits.CPU {
+		return "cpu"
+	}
+	if limits.Memory > 0 && r.Memory != nil && r.Memory.Percent >= limits.Memory {
+		return "memory"
+	}
+	if limits.GPU > 0 {
+		// Streams can go to any GPU, so only all of them being busy counts
+		busy := 0
+		for _, gpu := range r.GPUs {
+			if gpu.Percent >= limits.GPU {
+				busy++
+			}
+		}
+		if busy > 0 && busy == len(r.GPUs) {
+			return "gpu"
+		}
+	}
+	return ""
+}
+
+func (m *Monitor) sample() {
+	r := Resources{CPUs: runtime.NumCPU(), SampledAt: time.Now()}
+
+	times, cpuErr := readCPUTimes()
+	m.mu.RLock()
+	prev := m.prevCPU
+	m.mu.RUnlock()
+	if cpuErr == nil && prev != nil {
+		cpu := &CPUUsage{Percent: times.busySince(prev)}
+		cpu.Load, _ = readLoadAverage()
+		r.CPU = cpu
+	}
+	if memory, err := readMemory(); err == nil {
+		r.Memory = memory
+	}
+	gpus, err := readGPUs()
+	if err != nil {
+		logger.Debugf("Failed to read GPU use: %v", err)
+	}
+	r.GPUs = gpus
+	if r.GPUs == nil {
+		r.GPUs = []GPUUsage{}
+	}
+
+	m.mu.Lock()
+	m.latest = r
+	if cpuErr == nil {
+		m.prevCPU = times
+	}
+	m.mu.Unlock()
+}
@@ -0,0 +1,120 @@
+package sysmon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cpuTimes is the time all CPUs together spent busy and in total since boot,
+// in clock ticks
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// busySince returns the percentage of time the CPUs were busy since prev
+func (t *cpuTimes) busySince(prev *cpuTimes) float64 {
+	if t.total <= prev.total || t.busy < prev.busy {
+		return 0
+	}
+	return float64(t.busy-prev.busy) / float64(t.total-prev.total) * 100
+}
+
+// readCPUTimes reads the CPU line of /proc/stat. Idle and I/O wait time is
+// counted as not busy.
+func readCPUTimes() (*cpuTimes, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal ...; guest time is
+		// already counted in user
+		t := &cpuTimes{}
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			t.total += v
+			if i != 3 && i != 4 {
+				t.busy += v
+			}
+		}
+		return t, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// readLoadAverage reads /proc/loadavg
+func readLoadAverage() ([3]float64, error) {
+	var load [3]float64
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return load, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected /proc/loadavg: %q", data)
+	}
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, err
+		}
+	}
+	return load, nil
+}
+
+// readMemory reads /proc/meminfo. Available memory includes the caches the
+// kernel can free, so what isn't available is what's in use.
+func readMemory() (*MemoryUsage, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var total, available uint64
+	var haveAvailable bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+			haveAvailable = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if total == 0 || !haveAvailable || available > total {
+		return nil, fmt.Errorf("no memory totals in /proc/meminfo")
+	}
+	used := total - available
+	return &MemoryUsage{Total: total, Used: used, Percent: float64(used) / float64(total) * 100}, nil
+}
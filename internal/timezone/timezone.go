@@ -0,0 +1,83 @@
+package timezone
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// Containers often ship without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Server time zone
+//
+// Schedules, usage days and calendar dates follow the server's timezone
+// setting rather than the process's local time, which in a container is
+// usually UTC. An empty setting keeps the local time. Users can override the
+// zone their calendar and dates are shown in.
+
+var (
+	mu       sync.RWMutex
+	location = time.Local
+)
+
+// Load returns the location for an IANA zone name such as "Europe/Oslo".
+// An empty name is the process's local time.
+func Load(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Local, nil
+	}
+	// "Local" would follow the process rather than the setting
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// SetDefault sets the server's time zone. Unknown zones keep the local time.
+func SetDefault(name string) {
+	loc, err := Load(name)
+	if err != nil {
+		loc = time.Local
+	}
+	mu.Lock()
+	location = loc
+	mu.Unlock()
+}
+
+// Location returns the server's time zone
+func Location() *time.Location {
+	mu.RLock()
+	defer mu.RUnlock()
+	return location
+}
+
+// Now returns the current time in the server's time zone
+func Now() time.Time {
+	return time.Now().In(Location())
+}
+
+// Resolve returns the location for an optional per-user zone, falling back
+// to the server's
+func Resolve(preferred *string) *time.Location {
+	if preferred != nil && *preferred != "" {
+		if loc, err := Load(*preferred); err == nil {
+			return loc
+		}
+	}
+	return Location()
+}
+
+// ValidateSetting checks the value of the timezone setting. Other settings
+// are always valid.
+func ValidateSetting(key, value string) error {
+	if key != "timezone" {
+		return nil
+	}
+	if _, err := Load(value); err != nil {
+		return fmt.Errorf("Unknown time zone: %s", value)
+	}
+	return nil
+}
@@ -2,21 +2,18 @@ package tmdb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"image"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/muesli/smartcrop"
-	"github.com/muesli/smartcrop/nfnt"
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/chaos"
 )
 
 const (
@@ -24,22 +21,40 @@ const (
 	imageBaseURL = "https://image.tmdb.org/t/p"
 )
 
+// ImageURL returns the TMDB URL of an image path at the given size, or ""
+// for an empty path
+func ImageURL(tmdbPath, size string) string {
+	if tmdbPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s%s", imageBaseURL, size, tmdbPath)
+}
+
+// ErrRateLimited is returned when TMDB asks us to slow down
+var ErrRateLimited = errors.New("TMDB rate limit reached")
+
 type Client struct {
 	apiKey     string
 	httpClient *http.Client
-	imageDir   string
+	images     blobstore.Store
 }
 
-func NewClient(apiKey, imageDir string) *Client {
+func NewClient(apiKey string, images blobstore.Store) *Client {
 	return &Client{
 		apiKey: apiKey,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: chaos.Transport(chaos.TargetTMDB),
 		},
-		imageDir: imageDir,
+		images: images,
 	}
 }
 
+// HasAPIKey reports whether the client has an API key to make requests with
+func (c *Client) HasAPIKey() bool {
+	return c.apiKey != ""
+}
+
 // Movie types
 type MovieSearchResult struct {
 	Page         int           `json:"page"`
@@ -221,6 +236,16 @@ type TVDetails struct {
 	Recommendations     TVSearchResult      `json:"recommendations"`
 	OriginalLanguage    string              `json:"original_language"`
 	ProductionCountries []ProductionCountry `json:"production_countries"`
+	LastEpisodeToAir    *AiringEpisode      `json:"last_episode_to_air"`
+	NextEpisodeToAir    *AiringEpisode      `json:"next_episode_to_air"`
+}
+
+// AiringEpisode is the latest or next episode of a show on the air
+type AiringEpisode struct {
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+	Name          string `json:"name"`
+	AirDate       string `json:"air_date"`
 }
 
 type Network struct {
@@ -356,6 +381,9 @@ func (c *Client) get(endpoint string, params map[string]string) ([]byte, error)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrRateLimited
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("TMDB API error: %d", resp.StatusCode)
 	}
@@ -548,6 +576,84 @@ func (c *Client) GetTVDetails(tmdbID int64) (*TVDetails, error) {
 	return &result, nil
 }
 
+// FindResult holds the movies and shows TMDB matched to an ID from another
+// database
+type FindResult struct {
+	MovieResults []MovieResult `json:"movie_results"`
+	TVResults    []TVResult    `json:"tv_results"`
+}
+
+// Find looks up movies and shows by an ID from another database. source is
+// TMDB's name for it, such as "tvdb_id" or "imdb_id".
+func (c *Client) Find(externalID, source string) (*FindResult, error) {
+	data, err := c.get("/find/"+url.PathEscape(externalID), map[string]string{
+		"external_source": source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result FindResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AlternativeTitle is another title a movie or show is known by in a country
+type AlternativeTitle struct {
+	ISO31661 string `json:"iso_3166_1"`
+	Title    string `json:"title"`
+	Type     string `json:"type"` // Like "Romaji" or "working title", often empty
+}
+
+// GetMovieAlternativeTitles gets a movie's original title and the other
+// titles it's known by
+func (c *Client) GetMovieAlternativeTitles(tmdbID int64) (string, []AlternativeTitle, error) {
+	data, err := c.get(fmt.Sprintf("/movie/%d", tmdbID), map[string]string{
+		"append_to_response": "alternative_titles",
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var result struct {
+		OriginalTitle     string `json:"original_title"`
+		AlternativeTitles struct {
+			Titles []AlternativeTitle `json:"titles"`
+		} `json:"alternative_titles"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", nil, err
+	}
+
+	return result.OriginalTitle, result.AlternativeTitles.Titles, nil
+}
+
+// GetTVAlternativeTitles gets a show's original title and the other titles
+// it's known by
+func (c *Client) GetTVAlternativeTitles(tmdbID int64) (string, []AlternativeTitle, error) {
+	data, err := c.get(fmt.Sprintf("/tv/%d", tmdbID), map[string]string{
+		"append_to_response": "alternative_titles",
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var result struct {
+		OriginalName      string `json:"original_name"`
+		AlternativeTitles struct {
+			Results []AlternativeTitle `json:"results"`
+		} `json:"alternative_titles"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", nil, err
+	}
+
+	return result.OriginalName, result.AlternativeTitles.Results, nil
+}
+
 // GetTVContentRating gets the US content rating for a TV show
 func (c *Client) GetTVContentRating(tmdbID int64) (string, error) {
 	data, err := c.get(fmt.Sprintf("/tv/%d/content_ratings", tmdbID), nil)
@@ -572,7 +678,17 @@ func (c *Client) GetTVContentRating(tmdbID int64) (string, error) {
 
 // GetSeasonDetails gets detailed info about a season including episodes
 func (c *Client) GetSeasonDetails(showTmdbID int64, seasonNumber int) (*SeasonDetails, error) {
-	data, err := c.get(fmt.Sprintf("/tv/%d/season/%d", showTmdbID, seasonNumber), nil)
+	return c.GetSeasonDetailsIn(showTmdbID, seasonNumber, "")
+}
+
+// GetSeasonDetailsIn gets a season's details in a language, like "de".
+// Overviews TMDB has no translation for come back empty.
+func (c *Client) GetSeasonDetailsIn(showTmdbID int64, seasonNumber int, language string) (*SeasonDetails, error) {
+	var params map[string]string
+	if language != "" {
+		params = map[string]string{"language": language}
+	}
+	data, err := c.get(fmt.Sprintf("/tv/%d/season/%d", showTmdbID, seasonNumber), params)
 	if err != nil {
 		return nil, err
 	}
@@ -585,28 +701,69 @@ func (c *Client) GetSeasonDetails(showTmdbID int64, seasonNumber int) (*SeasonDe
 	return &result, nil
 }
 
-// DownloadImage downloads an image from TMDB and caches it locally
-// Returns the local path relative to the images directory
+// Translation is a movie or show's text in one language. Fields TMDB has
+// no translation for are empty.
+type Translation struct {
+	ISO6391  string `json:"iso_639_1"`
+	ISO31661 string `json:"iso_3166_1"`
+	Data     struct {
+		Title    string `json:"title"`
+		Name     string `json:"name"` // Shows have a name instead of a title
+		Overview string `json:"overview"`
+		Tagline  string `json:"tagline"`
+	} `json:"data"`
+}
+
+// GetMovieTranslations gets a movie's text in every language TMDB has
+func (c *Client) GetMovieTranslations(tmdbID int64) ([]Translation, error) {
+	return c.getTranslations(fmt.Sprintf("/movie/%d/translations", tmdbID))
+}
+
+// GetTVTranslations gets a show's text in every language TMDB has
+func (c *Client) GetTVTranslations(tmdbID int64) ([]Translation, error) {
+	return c.getTranslations(fmt.Sprintf("/tv/%d/translations", tmdbID))
+}
+
+func (c *Client) getTranslations(endpoint string) ([]Translation, error) {
+	data, err := c.get(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Translations []Translation `json:"translations"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Translations, nil
+}
+
+// DownloadImage downloads an image from TMDB and caches it in the image store
+// Returns its key in the store
 func (c *Client) DownloadImage(tmdbPath string, size string) (string, error) {
+	return c.DownloadLocalizedImage(tmdbPath, size, "")
+}
+
+// DownloadLocalizedImage downloads an image picked for a language and caches
+// it under that language, like "w500/de/abc.jpg", so cached artwork shows
+// which language it was chosen for. An empty language caches it as
+// DownloadImage does.
+func (c *Client) DownloadLocalizedImage(tmdbPath, size, language string) (string, error) {
 	if tmdbPath == "" {
 		return "", nil
 	}
 
 	// Create filename from TMDB path
 	filename := strings.TrimPrefix(tmdbPath, "/")
-	localPath := filepath.Join(size, filename)
-	fullPath := filepath.Join(c.imageDir, localPath)
+	localPath := filepath.Join(size, language, filename)
 
 	// Check if already cached
-	if _, err := os.Stat(fullPath); err == nil {
+	if blobstore.Exists(c.images, localPath) {
 		return localPath, nil
 	}
 
-	// Create directory if needed
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return "", err
-	}
-
 	// Download image
 	imageURL := fmt.Sprintf("%s/%s%s", imageBaseURL, size, tmdbPath)
 	resp, err := c.httpClient.Get(imageURL)
@@ -619,134 +776,26 @@ func (c *Client) DownloadImage(tmdbPath string, size string) (string, error) {
 		return "", fmt.Errorf("failed to download image: %d", resp.StatusCode)
 	}
 
-	// Save to file
-	file, err := os.Create(fullPath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	if _, err := io.Copy(file, resp.Body); err != nil {
+	if err := c.images.Put(localPath, resp.Body, blobstore.ContentType(localPath)); err != nil {
 		return "", err
 	}
 
 	return localPath, nil
 }
 
-// AnalyzeFocalPoint analyzes an image and returns the focal point as percentages (0.0-1.0)
-// Returns (focalX, focalY, error) where (0.5, 0.5) is center
-func (c *Client) AnalyzeFocalPoint(imagePath string) (float64, float64, error) {
-	fullPath := filepath.Join(c.imageDir, imagePath)
-
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return 0.5, 0.25, nil // Default to center-top on error
-	}
-	defer file.Close()
-
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return 0.5, 0.25, nil // Default to center-top on error
-	}
-
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-
-	// Use smartcrop to find the best crop area
-	// We'll use a 16:9 aspect ratio for backdrop analysis
-	resizer := nfnt.NewDefaultResizer()
-	analyzer := smartcrop.NewAnalyzer(resizer)
-
-	// Find best crop for a square to find the focal point
-	cropWidth := width
-	cropHeight := width // Square crop to find focal area
-	if cropHeight > height {
-		cropHeight = height
-		cropWidth = height
-	}
-
-	crop, err := analyzer.FindBestCrop(img, cropWidth, cropHeight)
+// OpenImage streams a TMDB image without caching it. The caller closes it.
+func (c *Client) OpenImage(tmdbPath string, size string) (io.ReadCloser, error) {
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/%s%s", imageBaseURL, size, tmdbPath))
 	if err != nil {
-		return 0.5, 0.25, nil // Default to center-top on error
-	}
-
-	// Calculate focal point as percentage of image dimensions
-	// The crop rectangle gives us where the "interesting" content is
-	// image.Rectangle uses Min/Max points, so we calculate center from those
-	cropCenterX := (crop.Min.X + crop.Max.X) / 2
-	cropCenterY := (crop.Min.Y + crop.Max.Y) / 2
-	focalX := float64(cropCenterX) / float64(width)
-	focalY := float64(cropCenterY) / float64(height)
-
-	// Clamp values between 0 and 1
-	if focalX < 0 {
-		focalX = 0
-	} else if focalX > 1 {
-		focalX = 1
-	}
-	if focalY < 0 {
-		focalY = 0
-	} else if focalY > 1 {
-		focalY = 1
-	}
-
-	return focalX, focalY, nil
-}
-
-// AnalyzeFocalPointFromURL fetches an image from a URL and analyzes it for focal point
-func (c *Client) AnalyzeFocalPointFromURL(backdropPath string) (float64, float64, error) {
-	if backdropPath == "" {
-		return 0.5, 0.25, nil
-	}
-	imageURL := fmt.Sprintf("%s/w780%s", imageBaseURL, backdropPath)
-	resp, err := c.httpClient.Get(imageURL)
-	if err != nil {
-		return 0.5, 0.25, nil
+		return nil, err
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return 0.5, 0.25, nil
-	}
-	img, _, err := image.Decode(resp.Body)
-	if err != nil {
-		return 0.5, 0.25, nil
-	}
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	resizer := nfnt.NewDefaultResizer()
-	analyzer := smartcrop.NewAnalyzer(resizer)
-	cropWidth := width
-	cropHeight := width
-	if cropHeight > height {
-		cropHeight = height
-		cropWidth = height
-	}
-	crop, err := analyzer.FindBestCrop(img, cropWidth, cropHeight)
-	if err != nil {
-		return 0.5, 0.25, nil
-	}
-	cropCenterX := (crop.Min.X + crop.Max.X) / 2
-	cropCenterY := (crop.Min.Y + crop.Max.Y) / 2
-	focalX := float64(cropCenterX) / float64(width)
-	focalY := float64(cropCenterY) / float64(height)
-	if focalX < 0 {
-		focalX = 0
-	} else if focalX > 1 {
-		focalX = 1
-	}
-	if focalY < 0 {
-		focalY = 0
-	} else if focalY > 1 {
-		focalY = 1
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download image: %d", resp.StatusCode)
 	}
-	// Bias Y towards top for movie/TV backdrops - faces are typically in upper third
-	focalY = focalY*0.6 + 0.25*0.4
-	return focalX, focalY, nil
+	return resp.Body, nil
 }
 
-
 // GetPersonDetails fetches detailed info about a person
 func (c *Client) GetPersonDetails(personID int64) (*PersonDetails, error) {
 	data, err := c.get(fmt.Sprintf("/person/%d", personID), nil)
@@ -1433,3 +1482,82 @@ func (c *Client) GetCollectionDetails(collectionID int64) (*CollectionDetails, e
 
 	return &details, nil
 }
+
+// ImageSet is the artwork TMDB has for a movie or show
+type ImageSet struct {
+	Backdrops []Image `json:"backdrops"`
+	Posters   []Image `json:"posters"`
+	Logos     []Image `json:"logos"` // Title logos, as transparent images
+}
+
+// Image is one poster, backdrop or logo of an ImageSet. Language is nil for
+// textless images; images with a language carry text such as the title.
+type Image struct {
+	FilePath    string  `json:"file_path"`
+	Language    *string `json:"iso_639_1"`
+	VoteAverage float64 `json:"vote_average"`
+	VoteCount   int     `json:"vote_count"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+}
+
+// GetMovieImages gets the textless posters, backdrops and logos of a movie
+// along with those in the given languages, English if none are given
+func (c *Client) GetMovieImages(tmdbID int64, languages ...string) (*ImageSet, error) {
+	return c.getImages(fmt.Sprintf("/movie/%d/images", tmdbID), languages)
+}
+
+// GetTVImages gets the textless posters, backdrops and logos of a show
+// along with those in the given languages, English if none are given
+func (c *Client) GetTVImages(tmdbID int64, languages ...string) (*ImageSet, error) {
+	return c.getImages(fmt.Sprintf("/tv/%d/images", tmdbID), languages)
+}
+
+func (c *Client) getImages(endpoint string, languages []string) (*ImageSet, error) {
+	if len(languages) == 0 {
+		languages = []string{"en"}
+	}
+	data, err := c.get(endpoint, map[string]string{
+		"include_image_language": strings.Join(languages, ",") + ",null",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var images ImageSet
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, err
+	}
+
+	return &images, nil
+}
+
+// WatchProvider is a streaming service a title can be watched on
+type WatchProvider struct {
+	ID              int64  `json:"provider_id"`
+	Name            string `json:"provider_name"`
+	LogoPath        string `json:"logo_path"`
+	DisplayPriority int    `json:"display_priority"`
+}
+
+// GetWatchProviders gets the streaming services TMDB knows for movies or TV
+// ("movie" or "tv") in a region
+func (c *Client) GetWatchProviders(mediaType, region string) ([]WatchProvider, error) {
+	params := map[string]string{}
+	if region != "" {
+		params["watch_region"] = region
+	}
+	data, err := c.get("/watch/providers/"+mediaType, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Results []WatchProvider `json:"results"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Results, nil
+}
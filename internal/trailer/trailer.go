@@ -0,0 +1,236 @@
+package trailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Trailer resolving
+//
+// TMDB lists trailers as YouTube videos, which TVs and other clients without
+// a YouTube player can't show. When turned on, yt-dlp resolves a trailer to
+// a direct video URL that the server proxies, or downloads it into a cache
+// that's served like any other file. YouTube's video URLs only work from the
+// address that resolved them, so clients can't be sent to them directly.
+
+var logger = logging.Module("trailer")
+
+// Setting picks how trailers are played: one of the Mode values
+const Setting = "trailer_resolver"
+
+// Trailer resolver modes
+const (
+	ModeOff   = "off"   // Clients only get YouTube keys
+	ModeProxy = "proxy" // Resolve a stream URL and proxy it
+	ModeCache = "cache" // Download the trailer once and serve the file
+)
+
+const (
+	resolveTimeout  = 30 * time.Second
+	downloadTimeout = 10 * time.Minute
+
+	// YouTube's stream URLs last about six hours
+	urlTTL = time.Hour
+
+	// maxCached bounds the trailers kept in the cache, oldest removed first
+	maxCached = 100
+
+	// Progressive MP4s play everywhere without merging streams
+	format = "best[ext=mp4][height<=1080]/best[ext=mp4]"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// ValidKey reports whether a key looks like a YouTube video ID
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// ValidateSetting checks the trailer resolver setting. Other settings are
+// always valid.
+func ValidateSetting(key, value string) error {
+	if key != Setting {
+		return nil
+	}
+	switch value {
+	case ModeOff:
+		return nil
+	case ModeProxy, ModeCache:
+		if !Available() {
+			return fmt.Errorf("yt-dlp isn't installed, so trailers can't be resolved")
+		}
+		return nil
+	}
+	return fmt.Errorf("Trailer resolver must be off, proxy or cache")
+}
+
+// Available reports whether yt-dlp is installed
+func Available() bool {
+	_, err := exec.LookPath("yt-dlp")
+	return err == nil
+}
+
+// Resolver resolves YouTube trailers with yt-dlp
+type Resolver struct {
+	cacheDir string
+
+	mu        sync.Mutex
+	urls      map[string]resolvedURL
+	downloads map[string]chan struct{} // Closed when a download finishes
+}
+
+type resolvedURL struct {
+	url     string
+	expires time.Time
+}
+
+// NewResolver creates a resolver that caches trailer files in cacheDir
+func NewResolver(cacheDir string) *Resolver {
+	return &Resolver{
+		cacheDir:  cacheDir,
+		urls:      make(map[string]resolvedURL),
+		downloads: make(map[string]chan struct{}),
+	}
+}
+
+// StreamURL returns a direct URL for a trailer's video
+func (r *Resolver) StreamURL(key string) (string, error) {
+	r.mu.Lock()
+	if u, ok := r.urls[key]; ok && time.Now().Before(u.expires) {
+		r.mu.Unlock()
+		return u.url, nil
+	}
+	r.mu.Unlock()
+
+	out, err := run(resolveTimeout, "-g", "-f", format, "--no-playlist", "--", watchURL(key))
+	if err != nil {
+		return "", err
+	}
+	url, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if url == "" {
+		return "", fmt.Errorf("yt-dlp found no video for %s", key)
+	}
+
+	r.mu.Lock()
+	r.urls[key] = resolvedURL{url: url, expires: time.Now().Add(urlTTL)}
+	r.mu.Unlock()
+	return url, nil
+}
+
+// Forget drops a resolved URL, for when it stopped working early
+func (r *Resolver) Forget(key string) {
+	r.mu.Lock()
+	delete(r.urls, key)
+	r.mu.Unlock()
+}
+
+// CachedFile returns the path of a trailer's video in the cache,
+// downloading it first if needed. Requests for a trailer that's already
+// downloading wait for it.
+func (r *Resolver) CachedFile(key string) (string, error) {
+	path := filepath.Join(r.cacheDir, key+".mp4")
+
+	r.mu.Lock()
+	if _, err := os.Stat(path); err == nil {
+		r.mu.Unlock()
+		return path, nil
+	}
+	if done, ok := r.downloads[key]; ok {
+		r.mu.Unlock()
+		<-done
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("trailer download failed")
+		}
+		return path, nil
+	}
+	done := make(chan struct{})
+	r.downloads[key] = done
+	r.mu.Unlock()
+
+	err := r.download(key, path)
+
+	r.mu.Lock()
+	delete(r.downloads, key)
+	close(done)
+	r.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	r.prune()
+	return path, nil
+}
+
+func (r *Resolver) download(key, path string) error {
+	if err := os.MkdirAll(r.cacheDir, 0755); err != nil {
+		return err
+	}
+	tmp := path + ".download"
+	defer os.Remove(tmp)
+
+	logger.Infof("Downloading trailer %s", key)
+	if _, err := run(downloadTimeout, "-f", format, "--no-playlist", "--no-part", "-o", tmp, "--", watchURL(key)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// prune removes the oldest cached trailers beyond maxCached
+func (r *Resolver) prune() {
+	entries, err := os.ReadDir(r.cacheDir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path    string
+		modTime time.Time
+	}
+	var files []cached
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".mp4" {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, cached{filepath.Join(r.cacheDir, e.Name()), info.ModTime()})
+		}
+	}
+	if len(files) <= maxCached {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files[:len(files)-maxCached] {
+		os.Remove(f.path)
+	}
+}
+
+func watchURL(key string) string {
+	return "https://www.youtube.com/watch?v=" + key
+}
+
+// run runs yt-dlp, returning its output
+func run(timeout time.Duration, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("yt-dlp: %s", msg)
+		}
+		return nil, fmt.Errorf("yt-dlp: %w", err)
+	}
+	return out, nil
+}
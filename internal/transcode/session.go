@@ -0,0 +1,167 @@
+package transcode
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Transcode sessions
+//
+// Every transcoded stream runs its own FFmpeg process. The manager keeps
+// track of them so the number running at once can be capped, a client that
+// goes away takes its process with it, and nothing is left behind when the
+// server stops. A new stream of the same item for the same user, such as
+// after seeking, replaces the old one.
+
+// ErrLimitReached is returned when the maximum number of transcodes is running
+var ErrLimitReached = errors.New("transcode limit reached")
+
+// Session is a running transcode
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"userId"`
+	Username  string    `json:"username"`
+	MediaType string    `json:"mediaType"`
+	MediaID   int64     `json:"mediaId"`
+	Encoder   string    `json:"encoder"`
+	ClientIP  string    `json:"clientIp"`
+	Device    string    `json:"device,omitempty"` // Name of the device it's for
+	StartedAt time.Time `json:"startedAt"`
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager tracks running transcodes
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewManager creates a manager with no sessions
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]*Session)}
+}
+
+// Start runs FFmpeg for a session and returns its output. limit caps how
+// many transcodes may run at once, 0 for no cap. The process is killed when
+// ctx ends, normally the stream request's context, or the session is
+// stopped; the caller must call Finish when it's done reading.
+func (m *Manager) Start(ctx context.Context, session *Session, limit int, args []string) (io.ReadCloser, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	session.ID = hex.EncodeToString(id)
+	session.StartedAt = time.Now()
+	session.done = make(chan struct{})
+
+	m.mu.Lock()
+	var replaced []*Session
+	for _, existing := range m.sessions {
+		if existing.UserID == session.UserID && existing.MediaType == session.MediaType && existing.MediaID == session.MediaID {
+			replaced = append(replaced, existing)
+		}
+	}
+	if limit > 0 && len(m.sessions)-len(replaced) >= limit {
+		m.mu.Unlock()
+		return nil, ErrLimitReached
+	}
+	for _, old := range replaced {
+		delete(m.sessions, old.ID)
+	}
+	ctx, session.cancel = context.WithCancel(ctx)
+	m.sessions[session.ID] = session
+	m.mu.Unlock()
+
+	for _, old := range replaced {
+		old.cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	// Don't wait on output pipes once the process is killed
+	cmd.WaitDelay = 5 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		m.Finish(session)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		m.Finish(session)
+		return nil, err
+	}
+	go func() {
+		cmd.Wait()
+		close(session.done)
+	}()
+	return stdout, nil
+}
+
+// Finish stops a session's process, if it's still running, and forgets it
+func (m *Manager) Finish(session *Session) {
+	m.mu.Lock()
+	if m.sessions[session.ID] == session {
+		delete(m.sessions, session.ID)
+	}
+	m.mu.Unlock()
+	if session.cancel != nil {
+		session.cancel()
+	}
+}
+
+// Stop stops a session by ID, returning false when there's no such session
+func (m *Manager) Stop(id string) bool {
+	m.mu.Lock()
+	session, ok := m.sessions[id]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+	m.Finish(session)
+	return true
+}
+
+// StopAll stops every session and waits briefly for the processes to exit,
+// for server shutdown
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+
+	for _, session := range sessions {
+		session.cancel()
+	}
+	for _, session := range sessions {
+		select {
+		case <-session.done:
+		case <-time.After(5 * time.Second):
+			logger.Infof("Transcode %s did not exit", session.ID)
+		}
+	}
+	if len(sessions) > 0 {
+		logger.Infof("Stopped %d transcodes", len(sessions))
+	}
+}
+
+// Sessions returns the running sessions, oldest first
+func (m *Manager) Sessions() []Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.Before(sessions[j].StartedAt) })
+	return sessions
+}
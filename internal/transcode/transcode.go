@@ -0,0 +1,263 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outpost/outpost/internal/logging"
+)
+
+var logger = logging.Module("transcode")
+
+// Video encoders for on-the-fly streams
+//
+// Transcoded streams are encoded to H.264 by libx264 unless a hardware
+// encoder is picked. FFmpeg lists every encoder it was built with, but a
+// listed encoder only works when the GPU and its driver are there too, so
+// each one is checked with a short test encode before it's offered. The
+// transcode_encoder setting picks one; "auto" uses the first working
+// hardware encoder and falls back to libx264.
+
+// Encoders, as stored in the transcode_encoder setting
+const (
+	Auto         = "auto"
+	Software     = "software"
+	VAAPI        = "vaapi"
+	NVENC        = "nvenc"
+	QSV          = "qsv"
+	VideoToolbox = "videotoolbox"
+)
+
+// hardware lists the hardware encoders in the order auto tries them
+var hardware = []struct {
+	id, name, ffmpeg string
+}{
+	{NVENC, "NVIDIA NVENC", "h264_nvenc"},
+	{QSV, "Intel Quick Sync", "h264_qsv"},
+	{VAAPI, "VA-API", "h264_vaapi"},
+	{VideoToolbox, "Apple VideoToolbox", "h264_videotoolbox"},
+}
+
+// testTimeout bounds each test encode; a missing driver usually fails at once
+const testTimeout = 15 * time.Second
+
+// Encoder is an encoder and whether it can be used on this machine
+type Encoder struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	FFmpeg    string `json:"ffmpeg"`          // FFmpeg encoder name
+	Compiled  bool   `json:"compiled"`        // FFmpeg was built with it
+	Available bool   `json:"available"`       // A test encode worked
+	Error     string `json:"error,omitempty"` // Why it isn't available
+}
+
+// Capabilities is what transcoding can use on this machine
+type Capabilities struct {
+	FFmpeg      bool      `json:"ffmpeg"` // FFmpeg was found
+	Version     string    `json:"version,omitempty"`
+	VAAPIDevice string    `json:"vaapiDevice,omitempty"`
+	Encoders    []Encoder `json:"encoders"` // Software first, then hardware
+	DetectedAt  time.Time `json:"detectedAt"`
+}
+
+var (
+	mu       sync.Mutex
+	detected *Capabilities
+)
+
+// ValidateSetting checks the transcode settings. Other settings are always
+// valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case "transcode_encoder":
+		if value == Auto || value == Software {
+			return nil
+		}
+		for _, hw := range hardware {
+			if value == hw.id {
+				return nil
+			}
+		}
+		return fmt.Errorf("Unknown encoder: %s", value)
+	case "transcode_max_sessions":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("Maximum transcodes must be 0 (no limit) or more")
+		}
+	case "transcode_max_cpu", "transcode_max_memory", "transcode_max_gpu":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 || n > 100 {
+			return fmt.Errorf("Busy limits must be a percentage, or 0 to not check")
+		}
+	case "transcode_busy_wait_seconds":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 || n > 60 {
+			return fmt.Errorf("Busy wait must be between 0 and 60 seconds")
+		}
+	}
+	return nil
+}
+
+// Detect returns what transcoding can use, checking the first time it's
+// called and whenever refresh is set. Checking runs test encodes, so it can
+// take a few seconds.
+func Detect(refresh bool) *Capabilities {
+	mu.Lock()
+	defer mu.Unlock()
+	if detected == nil || refresh {
+		detected = detect()
+	}
+	return detected
+}
+
+func detect() *Capabilities {
+	caps := &Capabilities{
+		Encoders:   []Encoder{{ID: Software, Name: "Software (libx264)", FFmpeg: "libx264"}},
+		DetectedAt: time.Now(),
+	}
+
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-version").Output()
+	if err != nil {
+		caps.Encoders[0].Error = "FFmpeg not found"
+		for _, hw := range hardware {
+			caps.Encoders = append(caps.Encoders, Encoder{ID: hw.id, Name: hw.name, FFmpeg: hw.ffmpeg, Error: "FFmpeg not found"})
+		}
+		return caps
+	}
+	caps.FFmpeg = true
+	if line, _, _ := strings.Cut(string(out), "\n"); line != "" {
+		caps.Version = strings.TrimPrefix(line, "ffmpeg version ")
+	}
+
+	listed, _ := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+	compiled := func(name string) bool {
+		for _, line := range strings.Split(string(listed), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[1] == name {
+				return true
+			}
+		}
+		return false
+	}
+	caps.VAAPIDevice = vaapiDevice()
+
+	caps.Encoders[0].Compiled = compiled("libx264")
+	caps.Encoders[0].Available = caps.Encoders[0].Compiled
+	if !caps.Encoders[0].Compiled {
+		caps.Encoders[0].Error = "FFmpeg was built without libx264"
+	}
+
+	for _, hw := range hardware {
+		enc := Encoder{ID: hw.id, Name: hw.name, FFmpeg: hw.ffmpeg, Compiled: compiled(hw.ffmpeg)}
+		switch {
+		case !enc.Compiled:
+			enc.Error = "FFmpeg was built without " + hw.ffmpeg
+		case hw.id == VAAPI && caps.VAAPIDevice == "":
+			enc.Error = "No render device in /dev/dri"
+		default:
+			if err := testEncode(hw.id, caps.VAAPIDevice); err != nil {
+				enc.Error = err.Error()
+			} else {
+				enc.Available = true
+			}
+		}
+		caps.Encoders = append(caps.Encoders, enc)
+	}
+
+	var available []string
+	for _, enc := range caps.Encoders[1:] {
+		if enc.Available {
+			available = append(available, enc.Name)
+		}
+	}
+	if len(available) > 0 {
+		logger.Infof("Hardware encoders available: %s", strings.Join(available, ", "))
+	}
+	return caps
+}
+
+// vaapiDevice returns the first DRM render node, or "" when there's none
+func vaapiDevice() string {
+	nodes, _ := filepath.Glob("/dev/dri/renderD*")
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0]
+}
+
+// testEncode encodes a fraction of a second of a test pattern with an
+// encoder, returning FFmpeg's last error line when it fails
+func testEncode(id, device string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, inputArgs(id, device)...)
+	args = append(args, "-f", "lavfi", "-i", "testsrc2=size=320x240:rate=25:duration=0.2")
+	args = append(args, videoArgs(id)...)
+	args = append(args, "-f", "null", "-")
+
+	out, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return fmt.Errorf("%s", last)
+	}
+	return err
+}
+
+// Resolve returns the encoder to use for a setting value: the chosen one if
+// it's available, otherwise the first available hardware encoder for auto,
+// otherwise software
+func Resolve(setting string) string {
+	caps := Detect(false)
+	if setting == Software {
+		return Software
+	}
+	for _, enc := range caps.Encoders[1:] {
+		if enc.Available && (setting == enc.ID || setting == Auto || setting == "") {
+			return enc.ID
+		}
+	}
+	return Software
+}
+
+// Args returns the FFmpeg arguments to encode video with an encoder:
+// input holds those that go before -i, output those that go after it
+func Args(id string) (input, output []string) {
+	return inputArgs(id, Detect(false).VAAPIDevice), videoArgs(id)
+}
+
+// MaxBitrateArgs returns the output options that cap the video bitrate at
+// kbps, for clients on a slow connection
+func MaxBitrateArgs(kbps int) []string {
+	return []string{"-maxrate", fmt.Sprintf("%dk", kbps), "-bufsize", fmt.Sprintf("%dk", 2*kbps)}
+}
+
+func inputArgs(id, device string) []string {
+	if id == VAAPI {
+		return []string{"-vaapi_device", device}
+	}
+	return nil
+}
+
+// videoArgs returns the encoder options, tuned like libx264's ultrafast
+// preset: speed first, at a quality around CRF 23
+func videoArgs(id string) []string {
+	switch id {
+	case VAAPI:
+		return []string{"-vf", "format=nv12,hwupload", "-c:v", "h264_vaapi", "-qp", "23"}
+	case NVENC:
+		return []string{"-c:v", "h264_nvenc", "-preset", "fast", "-rc", "vbr", "-cq", "23"}
+	case QSV:
+		return []string{"-c:v", "h264_qsv", "-preset", "veryfast", "-global_quality", "23"}
+	case VideoToolbox:
+		return []string{"-c:v", "h264_videotoolbox", "-realtime", "1", "-b:v", "8M"}
+	}
+	return []string{"-c:v", "libx264", "-preset", "ultrafast", "-crf", "23"}
+}
@@ -0,0 +1,314 @@
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/outpost/outpost/internal/database"
+	"github.com/outpost/outpost/internal/logging"
+)
+
+// Trash
+//
+// Deleting a movie or episode moves its file into the trash folder instead
+// of removing it, so a mistaken delete can be undone. Restoring puts the file
+// back and adds it to the library again with the metadata it had. Files are
+// removed for good once they've been in the trash for the retention period.
+
+var logger = logging.Module("trash")
+
+// Settings for the trash
+const (
+	SettingPath      = "trash_path"           // Folder deleted files are moved to; empty for the data folder's
+	SettingRetention = "trash_retention_days" // Days files stay in the trash; 0 deletes them straight away
+)
+
+// defaultRetention is used when the retention setting is missing or invalid
+const defaultRetention = 30
+
+var (
+	// ErrRestoreConflict is returned when a file is restored to a path
+	// that's been taken since it was deleted
+	ErrRestoreConflict = errors.New("a file already exists where the deleted file was")
+	// ErrNotFound is returned for items that aren't in the trash
+	ErrNotFound = errors.New("item isn't in the trash")
+)
+
+// ValidateSetting checks the trash settings. Other settings are always valid.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case SettingPath:
+		if value != "" && !filepath.IsAbs(value) {
+			return fmt.Errorf("Trash folder must be an absolute path")
+		}
+	case SettingRetention:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("Trash retention must be a whole number of days, 0 to delete files straight away")
+		}
+	}
+	return nil
+}
+
+// Bin moves deleted files to the trash and restores them
+type Bin struct {
+	db         *database.Database
+	defaultDir string
+}
+
+// New creates a trash that keeps files in defaultDir unless another folder
+// is configured
+func New(db *database.Database, defaultDir string) *Bin {
+	return &Bin{db: db, defaultDir: defaultDir}
+}
+
+// Dir returns the folder deleted files are moved to
+func (b *Bin) Dir() string {
+	if dir, _ := b.db.GetSetting(SettingPath); dir != "" {
+		return dir
+	}
+	return b.defaultDir
+}
+
+// Retention returns how many days files stay in the trash
+func (b *Bin) Retention() int {
+	value, _ := b.db.GetSetting(SettingRetention)
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return defaultRetention
+	}
+	return days
+}
+
+// Delete moves a deleted movie or episode's file to the trash. record is the
+// library record being deleted, kept so a restore can add it back. With a
+// retention of 0 the file is removed straight away. A file that's already
+// gone isn't an error.
+func (b *Bin) Delete(item *database.TrashItem, record interface{}) error {
+	if item.OriginalPath == "" {
+		return nil
+	}
+	info, err := os.Stat(item.OriginalPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if b.Retention() == 0 {
+		return os.Remove(item.OriginalPath)
+	}
+
+	dir := b.Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Prefixed so files with the same name don't collide
+	item.TrashPath = filepath.Join(dir, strconv.FormatInt(time.Now().UnixNano(), 10)+"_"+filepath.Base(item.OriginalPath))
+	item.Size = info.Size()
+	if data, err := json.Marshal(record); err == nil {
+		item.Record = string(data)
+	}
+
+	if err := move(item.OriginalPath, item.TrashPath); err != nil {
+		return err
+	}
+	if err := b.db.CreateTrashItem(item); err != nil {
+		// Put the file back rather than lose track of it
+		if moveErr := move(item.TrashPath, item.OriginalPath); moveErr != nil {
+			logger.Errorf("Failed to move %s back from the trash: %v", item.OriginalPath, moveErr)
+		}
+		return err
+	}
+	logger.Infof("Moved %s to the trash", item.OriginalPath)
+	return nil
+}
+
+// List returns the items in the trash with when they expire
+func (b *Bin) List() ([]database.TrashItem, error) {
+	items, err := b.db.GetTrashItems()
+	if err != nil {
+		return nil, err
+	}
+	b.setExpiry(items)
+	return items, nil
+}
+
+// Get returns an item in the trash
+func (b *Bin) Get(id int64) (*database.TrashItem, error) {
+	item, err := b.db.GetTrashItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	item.ExpiresAt = item.DeletedAt.AddDate(0, 0, b.Retention())
+	return item, nil
+}
+
+func (b *Bin) setExpiry(items []database.TrashItem) {
+	days := b.Retention()
+	for i := range items {
+		items[i].ExpiresAt = items[i].DeletedAt.AddDate(0, 0, days)
+	}
+}
+
+// Restore moves an item's file back to where it was and adds it to the
+// library again. Returns the item and the media's new ID, or 0 if it
+// couldn't be added back, in which case the next library scan picks the file
+// up.
+func (b *Bin) Restore(id int64) (*database.TrashItem, int64, error) {
+	item, err := b.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := os.Stat(item.OriginalPath); err == nil {
+		return nil, 0, ErrRestoreConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0755); err != nil {
+		return nil, 0, err
+	}
+	if err := move(item.TrashPath, item.OriginalPath); err != nil {
+		return nil, 0, err
+	}
+	if err := b.db.DeleteTrashItem(item.ID); err != nil {
+		return nil, 0, err
+	}
+	logger.Infof("Restored %s from the trash", item.OriginalPath)
+
+	mediaID, err := b.restoreRecord(item)
+	if err != nil {
+		logger.Warnf("Couldn't add %s back to the library, leaving it for the next scan: %v", item.OriginalPath, err)
+		return item, 0, nil
+	}
+	return item, mediaID, nil
+}
+
+// restoreRecord adds a restored item's library record back
+func (b *Bin) restoreRecord(item *database.TrashItem) (int64, error) {
+	if item.Record == "" {
+		return 0, fmt.Errorf("no record was kept")
+	}
+	switch item.MediaType {
+	case "movie":
+		var movie database.Movie
+		if err := json.Unmarshal([]byte(item.Record), &movie); err != nil {
+			return 0, err
+		}
+		movie.Path = item.OriginalPath
+		if err := b.db.CreateMovie(&movie); err != nil {
+			return 0, err
+		}
+		return movie.ID, b.db.UpdateMovieMetadata(&movie)
+	case "episode":
+		var episode database.Episode
+		if err := json.Unmarshal([]byte(item.Record), &episode); err != nil {
+			return 0, err
+		}
+		if _, err := b.db.GetSeasonByID(episode.SeasonID); err != nil {
+			return 0, fmt.Errorf("season %d is gone: %w", episode.SeasonID, err)
+		}
+		episode.Path = item.OriginalPath
+		if err := b.db.CreateEpisodeWithExtras(&episode); err != nil {
+			return 0, err
+		}
+		return episode.ID, b.db.UpdateEpisodeMetadata(&episode)
+	}
+	return 0, fmt.Errorf("unknown media type %q", item.MediaType)
+}
+
+// Purge removes an item from the trash for good
+func (b *Bin) Purge(id int64) error {
+	item, err := b.Get(id)
+	if err != nil {
+		return err
+	}
+	return b.purge(item)
+}
+
+func (b *Bin) purge(item *database.TrashItem) error {
+	if err := os.RemoveAll(item.TrashPath); err != nil {
+		return err
+	}
+	return b.db.DeleteTrashItem(item.ID)
+}
+
+// Empty removes everything from the trash, returning how many items were
+// removed
+func (b *Bin) Empty() (int, error) {
+	items, err := b.db.GetTrashItems()
+	if err != nil {
+		return 0, err
+	}
+	return b.purgeAll(items), nil
+}
+
+// EmptyExpired removes the items that have been in the trash longer than
+// the retention period, returning how many were removed
+func (b *Bin) EmptyExpired() int {
+	items, err := b.db.GetExpiredTrashItems(b.Retention())
+	if err != nil {
+		logger.Errorf("Failed to list expired trash: %v", err)
+		return 0
+	}
+	return b.purgeAll(items)
+}
+
+func (b *Bin) purgeAll(items []database.TrashItem) int {
+	removed := 0
+	for i := range items {
+		if err := b.purge(&items[i]); err != nil {
+			logger.Errorf("Failed to remove %s from the trash: %v", items[i].TrashPath, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// move renames a file, copying it when the destination is on another
+// filesystem
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := dst + ".partial"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
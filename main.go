@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -11,51 +10,86 @@ import (
 	"github.com/outpost/outpost/internal/acquisition"
 	"github.com/outpost/outpost/internal/api"
 	"github.com/outpost/outpost/internal/auth"
+	"github.com/outpost/outpost/internal/blobstore"
+	"github.com/outpost/outpost/internal/chaos"
 	"github.com/outpost/outpost/internal/config"
 	"github.com/outpost/outpost/internal/database"
 	"github.com/outpost/outpost/internal/downloadclient"
+	"github.com/outpost/outpost/internal/i18n"
 	"github.com/outpost/outpost/internal/indexer"
 	"github.com/outpost/outpost/internal/logging"
 	"github.com/outpost/outpost/internal/metadata"
+	"github.com/outpost/outpost/internal/netaccess"
 	"github.com/outpost/outpost/internal/notification"
+	"github.com/outpost/outpost/internal/podcast"
 	"github.com/outpost/outpost/internal/scanner"
 	"github.com/outpost/outpost/internal/scheduler"
+	"github.com/outpost/outpost/internal/seed"
+	"github.com/outpost/outpost/internal/timezone"
+	"github.com/outpost/outpost/internal/transcode"
 )
 
-func main() {
-	// Initialize logging with 1000 entry ring buffer
-	log.SetOutput(logging.Initialize(1000))
-	log.SetFlags(log.Ldate | log.Ltime)
+var logger = logging.Module("app")
 
+func main() {
 	cfg := config.Load()
 
+	// Initialize logging with 1000 entry ring buffer
+	logging.Initialize(1000, cfg.LogFormat == "json")
+
 	// Ensure data directory exists
 	dataDir := filepath.Dir(cfg.DBPath)
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+		logger.Fatalf("Failed to create data directory: %v", err)
 	}
 
 	// Ensure images directory exists
 	imageDir := filepath.Join(dataDir, "images")
 	if err := os.MkdirAll(imageDir, 0755); err != nil {
-		log.Fatalf("Failed to create images directory: %v", err)
+		logger.Fatalf("Failed to create images directory: %v", err)
 	}
 
 	// Initialize database
 	db, err := database.New(cfg.DBPath)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		logger.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// Seed a fresh install if SEED or SEED_FILE ask for it
+	if startupSeed, err := seed.FromEnv(); err != nil {
+		logger.Fatalf("Failed to load seed: %v", err)
+	} else if startupSeed != nil {
+		if err := seed.Apply(db, startupSeed); err != nil {
+			logger.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+
+	// Apply the saved log levels, chaos mode if it was left on (debug
+	// setting), network access rules, the server language and time zone
+	if settings, err := db.GetAllSettings(); err == nil {
+		if err := logging.Configure(logging.FromSettings(settings)); err != nil {
+			logger.Errorf("Invalid log config: %v", err)
+		}
+		chaos.Configure(chaos.FromSettings(settings))
+		netaccess.Configure(netaccess.FromSettings(settings))
+		i18n.SetDefault(settings["language"])
+		timezone.SetDefault(settings["timezone"])
+	}
+
 	// Initialize auth service
 	authSvc := auth.New(db)
 
+	// Open the stores for cached artwork and saved backups, which default to
+	// folders in the data directory
+	images := openStore(db, blobstore.SettingImages, imageDir)
+	backups := openStore(db, blobstore.SettingBackups, filepath.Join(dataDir, "backups"))
+
 	// Get TMDB API key from settings (may be empty initially)
 	apiKey, _ := db.GetSetting("tmdb_api_key")
 
 	// Initialize metadata service
-	meta := metadata.NewService(db, apiKey, imageDir)
+	meta := metadata.NewService(db, apiKey, images)
 
 	// Initialize scanner with metadata service
 	scan := scanner.New(db, meta, dataDir)
@@ -66,6 +100,16 @@ func main() {
 		scan.DetectQualityForExistingMedia()
 	}()
 
+	// Check which hardware encoders work before the first transcoded stream needs to know
+	go transcode.Detect(false)
+
+	// Find backdrop focal points and artwork colors for media that doesn't have them yet, such as local fanart
+	go func() {
+		time.Sleep(15 * time.Second)
+		meta.BackfillFocalPoints()
+		meta.BackfillArtworkColors()
+	}()
+
 	// Initialize shared managers
 	downloads := downloadclient.NewManager(db)
 	indexers := indexer.NewManager()
@@ -82,15 +126,51 @@ func main() {
 	// Wire notification service to acquisition for download events
 	acqSvc.SetNotificationHandler(notifSvc)
 
-	// Initialize server with scheduler and acquisition service
-	server := api.NewServer(cfg, db, scan, meta, authSvc, downloads, indexers, sched, acqSvc, notifSvc)
+	// Wire metadata service to acquisition for request ETAs (release dates)
+	acqSvc.SetReleaseDateLookup(meta)
+
+	// Wire notification service to scheduler for storage pause events
+	sched.SetNotifier(notifSvc)
+
+	// Wire metadata service to scheduler so unreleased movies wait for their digital release
+	sched.SetReleaseDateLookup(meta)
+
+	// Wire metadata service to scheduler so followed shows' new episodes are searched as they air
+	sched.SetEpisodeScheduleLookup(meta)
+
+	// Wire metadata service to scheduler so followers of people hear of their digital releases
+	sched.SetPersonCreditsLookup(meta)
+
+	// Wire metadata service to scheduler for searches under other titles
+	sched.SetTitleLookup(meta)
+
+	// Wire metadata service to scheduler so new music is looked up on MusicBrainz
+	sched.SetMusicMetadata(meta)
 
-	// Start scheduler
-	sched.Start()
+	// Initialize podcasts, refreshed by the scheduler
+	podcasts := podcast.New(db, filepath.Join(dataDir, "podcasts"))
+	sched.SetPodcasts(podcasts)
 
-	// Start acquisition service
-	acqSvc.Start()
-	log.Println("Acquisition service started")
+	// Initialize server with scheduler and acquisition service
+	server := api.NewServer(cfg, db, scan, meta, authSvc, downloads, indexers, sched, acqSvc, notifSvc)
+	server.SetPodcasts(podcasts)
+	server.SetStorage(images, backups)
+
+	// Run the server's health checks on a schedule so failures are notified,
+	// and empty its trash once files expire
+	sched.SetHealthChecker(server.HealthChecker())
+	sched.SetTrash(server.Trash())
+
+	// Start scheduler and acquisition service, unless maintenance mode was
+	// left on, in which case they wait for an admin to turn it off
+	if server.InMaintenance() {
+		logger.Warnf("Maintenance mode is on: scheduler and acquisition stay paused")
+	} else {
+		sched.Start()
+		acqSvc.Start()
+		logger.Infof("Acquisition service started")
+		go server.WatchLibraries()
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -98,19 +178,34 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Starting Outpost server on port %s", cfg.Port)
+		logger.Infof("Starting Outpost server on port %s", cfg.Port)
 		if err := server.Start(); err != nil {
-			log.Fatalf("Server failed to start: %v", err)
+			logger.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
 	// Wait for shutdown signal
 	<-sigChan
-	log.Println("Shutting down...")
+	logger.Infof("Shutting down...")
 
 	// Stop services
 	acqSvc.Stop()
 	sched.Stop()
+	server.Stop()
+
+	logger.Infof("Goodbye!")
+}
 
-	log.Println("Goodbye!")
+// openStore opens the store a storage setting describes. An invalid setting
+// leaves it in the default folder, so the server still starts.
+func openStore(db *database.Database, setting, defaultDir string) *blobstore.Switch {
+	store, err := blobstore.NewSwitch(defaultDir)
+	if err != nil {
+		logger.Fatalf("Failed to create %s: %v", defaultDir, err)
+	}
+	value, _ := db.GetSetting(setting)
+	if err := store.Configure(value); err != nil {
+		logger.Errorf("Invalid %s, using %s: %v", setting, defaultDir, err)
+	}
+	return store
 }